@@ -1,16 +1,30 @@
 package main
 
 import (
+    "bytes"
     "context"
+    "encoding/json"
     "fmt"
+    "io"
+    "net/http"
+    "net/http/httptest"
     "os"
     "path/filepath"
+    "slices"
+    "strings"
     "sync"
     "testing"
     "time"
 
+    "github.com/cfilipov/dockge/internal/docker"
     "github.com/cfilipov/dockge/internal/handlers"
+    "github.com/cfilipov/dockge/internal/models"
+    "github.com/cfilipov/dockge/internal/safehttp"
+    "github.com/cfilipov/dockge/internal/stack"
+    "github.com/cfilipov/dockge/internal/terminal"
     "github.com/cfilipov/dockge/internal/testutil"
+    "github.com/cfilipov/dockge/internal/ws"
+    "github.com/coder/websocket"
 )
 
 func TestNeedSetup(t *testing.T) {
@@ -122,6 +136,111 @@ func TestSaveStack(t *testing.T) {
     }
 }
 
+func TestComposeRiskGate(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    riskyYAML := "services:\n  agent:\n    image: portainer/agent\n    volumes:\n      - /var/run/docker.sock:/var/run/docker.sock\n"
+    resp := env.SendAndReceive(t, conn, "analyzeComposeRisks", riskyYAML)
+    report, _ := resp["riskReport"].(map[string]interface{})
+    if ok, _ := resp["ok"].(bool); !ok || report["level"] != "critical" || report["blocking"] != true {
+        t.Fatalf("analyzeComposeRisks = %v", resp)
+    }
+
+    // A new stack needs its risks accepted
+    resp = env.SendAndReceive(t, conn, "saveStack", "risky-stack", riskyYAML, "", "", true)
+    if ok, _ := resp["ok"].(bool); ok || resp["msg"] != "composeRisky" || resp["riskReport"] == nil {
+        t.Fatalf("expected the risk report, got %v", resp)
+    }
+    if _, err := os.Stat(filepath.Join(env.StacksDir, "risky-stack")); !os.IsNotExist(err) {
+        t.Fatal("risky stack saved without accepting its risks")
+    }
+    resp = env.SendAndReceive(t, conn, "saveStack", "risky-stack", riskyYAML, "", "", true, map[string]interface{}{"acceptRisks": true})
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("saveStack with accepted risks failed: %v", resp)
+    }
+
+    // Existing stacks were reviewed when they were added
+    riskyYAML += "    network_mode: host\n"
+    resp = env.SendAndReceive(t, conn, "saveStack", "risky-stack", riskyYAML, "", "", false)
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("saving an existing stack failed: %v", resp)
+    }
+}
+
+func TestSaveStackRequiresApprovalForOperator(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+    if _, err := env.App.Users.CreateWithRole("operator", "oppass123", models.RoleOperator); err != nil {
+        t.Fatal(err)
+    }
+    if err := env.App.Settings.Set("requireApproval", "1"); err != nil {
+        t.Fatal(err)
+    }
+    if err := env.App.Settings.Set("maskEnvSecrets", "1"); err != nil {
+        t.Fatal(err)
+    }
+
+    opConn := env.DialWS(t)
+    resp := env.SendAndReceive(t, opConn, "login", "operator", "oppass123", "", "")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("operator login failed: %v", resp)
+    }
+
+    newYAML := "services:\n  app:\n    image: alpine:3.19\n"
+    resp = env.SendAndReceive(t, opConn, "saveStack", "approval-stack", newYAML, "DB_PASSWORD=hunter2\n", "", false)
+    if pending, _ := resp["pending"].(bool); !pending {
+        t.Fatalf("expected saveStack to be pending approval: %v", resp)
+    }
+    composePath := filepath.Join(env.StacksDir, "approval-stack", "compose.yaml")
+    if _, err := os.Stat(composePath); !os.IsNotExist(err) {
+        t.Fatal("pending change must not be written to disk before approval")
+    }
+    changeID := resp["changeID"].(float64)
+
+    // Operators can't approve
+    resp = env.SendAndReceive(t, opConn, "approvePendingChange", changeID)
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Fatal("operator must not be able to approve")
+    }
+
+    // Masked users don't see the secret in the change or its diff
+    resp = env.SendAndReceive(t, opConn, "getPendingChangeList", "pending")
+    changes, _ := resp["changes"].([]interface{})
+    if len(changes) != 1 {
+        t.Fatalf("expected 1 pending change, got %v", resp)
+    }
+    if change, _ := changes[0].(map[string]interface{}); strings.Contains(change["composeENV"].(string), "hunter2") || strings.Contains(change["diff"].(string), "hunter2") {
+        t.Errorf("secret shown to a masked user: %v", change)
+    }
+
+    adminConn := env.DialWS(t)
+    env.Login(t, adminConn)
+    resp = env.SendAndReceive(t, adminConn, "getPendingChangeList", "pending")
+    changes, _ = resp["changes"].([]interface{})
+    if len(changes) != 1 {
+        t.Fatalf("expected 1 pending change, got %v", resp)
+    }
+    if change, _ := changes[0].(map[string]interface{}); change["composeENV"] != "DB_PASSWORD=hunter2\n" {
+        t.Errorf("admin got composeENV %v", change["composeENV"])
+    }
+
+    resp = env.SendAndReceive(t, adminConn, "approvePendingChange", changeID)
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("approvePendingChange failed: %v", resp)
+    }
+    data, err := os.ReadFile(composePath)
+    if err != nil {
+        t.Fatal("expected compose.yaml on disk after approval:", err)
+    }
+    if string(data) != newYAML {
+        t.Errorf("on-disk YAML mismatch:\ngot:  %q\nwant: %q", string(data), newYAML)
+    }
+}
+
 func TestDockerStats(t *testing.T) {
     env := testutil.Setup(t)
     env.SeedAdmin(t)
@@ -313,6 +432,36 @@ func TestRestartStack(t *testing.T) {
     }
 }
 
+func TestQuickAction(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+    env.SetStackRunning(t, "test-stack")
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    resp := env.SendAndReceive(t, conn, "quickAction", "test-stack", "restart", "key-1")
+    if ok, _ := resp["ok"].(bool); !ok || resp["status"] != "running" {
+        t.Fatalf("quickAction failed: %v", resp)
+    }
+
+    // A retry with the same key doesn't run again
+    resp = env.SendAndReceive(t, conn, "quickAction", "test-stack", "restart", "key-1")
+    if dup, _ := resp["duplicate"].(bool); !dup {
+        t.Errorf("expected a duplicate ack, got %v", resp)
+    }
+
+    resp = env.SendAndReceive(t, conn, "quickAction", "test-stack", "stop", "key-1")
+    if resp["code"] != "keyConflict" {
+        t.Errorf("expected keyConflict, got %v", resp)
+    }
+
+    resp = env.SendAndReceive(t, conn, "quickAction", "test-stack", "explode")
+    if ok, _ := resp["ok"].(bool); ok || resp["code"] != "unknownAction" || resp["status"] != "rejected" {
+        t.Errorf("expected unknownAction, got %v", resp)
+    }
+}
+
 func TestDownStack(t *testing.T) {
     env := testutil.Setup(t)
     env.SeedAdmin(t)
@@ -393,6 +542,45 @@ func TestSaveStackWithOverrideAndEnv(t *testing.T) {
     }
 }
 
+func TestSaveStackComposeFiles(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    yaml := "include:\n  - db/compose.yaml\nservices:\n  app:\n    image: nginx:latest\n"
+    files := []map[string]any{
+        {"name": "compose.monitoring.yaml", "yaml": "services:\n  grafana:\n    image: grafana/grafana\n"},
+        {"name": "db/compose.yaml", "yaml": "services:\n  db:\n    image: postgres:16\n", "included": true},
+    }
+    resp := env.SendAndReceive(t, conn, "saveStack", "multi-file", yaml, "", "", true, map[string]any{"composeFiles": files})
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("saveStack failed: %v", resp)
+    }
+
+    data, err := os.ReadFile(filepath.Join(env.StacksDir, "multi-file", ".compose-files"))
+    if err != nil || string(data) != "compose.monitoring.yaml\n" {
+        t.Errorf("compose file list = %q, %v", data, err)
+    }
+
+    resp = env.SendAndReceive(t, conn, "getStack", "multi-file")
+    s, _ := resp["stack"].(map[string]any)
+    got, _ := s["composeFiles"].([]any)
+    if len(got) != 2 {
+        t.Fatalf("composeFiles = %v", s["composeFiles"])
+    }
+    if f, _ := got[1].(map[string]any); f["name"] != "db/compose.yaml" || f["included"] != true {
+        t.Errorf("included file = %v", f)
+    }
+
+    bad := []map[string]any{{"name": "../escape.yaml", "yaml": "services: {}\n"}}
+    resp = env.SendAndReceive(t, conn, "saveStack", "multi-file", yaml, "", "", false, map[string]any{"composeFiles": bad})
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Error("expected saveStack to reject a file outside the stack directory")
+    }
+}
+
 func TestDeployStackEmptyYAML(t *testing.T) {
     env := testutil.Setup(t)
     env.SeedAdmin(t)
@@ -623,6 +811,36 @@ func TestCheckImageUpdates(t *testing.T) {
     }
 }
 
+func TestCheckUpdatesNow(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    // Scheduled checks skip the stack, but a check by hand doesn't
+    resp := env.SendAndReceive(t, conn, "setSettings", map[string]interface{}{
+        "imageUpdateCheckEnabled": false,
+        "imageUpdateCheckSkip":    "test-stack",
+    })
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("setSettings failed: %v", resp)
+    }
+
+    resp = env.SendAndReceive(t, conn, "checkUpdatesNow", "test-stack")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("checkUpdatesNow failed: %v", resp)
+    }
+    if checked, _ := resp["checked"].(float64); checked == 0 {
+        t.Errorf("expected services to be checked, got %v", resp)
+    }
+
+    resp = env.SendAndReceive(t, conn, "checkUpdatesNow", "no-such-stack")
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Error("expected checkUpdatesNow to fail for a missing stack")
+    }
+}
+
 func TestGetSettings(t *testing.T) {
     env := testutil.Setup(t)
     env.SeedAdmin(t)
@@ -690,6 +908,13 @@ func TestServiceMissingArgs(t *testing.T) {
     if ok {
         t.Error("expected stopService to fail with empty stack name")
     }
+
+    // A service name that compose would read as a flag
+    resp = env.SendAndReceive(t, conn, "restartService", "test-stack", "--force-recreate")
+    ok, _ = resp["ok"].(bool)
+    if ok {
+        t.Error("expected restartService to reject a flag as service name")
+    }
 }
 
 func TestUpdateStack(t *testing.T) {
@@ -707,6 +932,33 @@ func TestUpdateStack(t *testing.T) {
     }
 }
 
+func TestIdempotencyKey(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+    env.SetStackRunning(t, "test-stack")
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    first := env.SendAndReceiveWithKey(t, conn, "update-1", "updateStack", "test-stack")
+    if ok, _ := first["ok"].(bool); !ok {
+        t.Fatalf("updateStack failed: %v", first)
+    }
+    // The retry gets the first ack
+    retry := env.SendAndReceiveWithKey(t, conn, "update-1", "updateStack", "test-stack")
+    if ok, _ := retry["ok"].(bool); !ok {
+        t.Fatalf("retried updateStack failed: %v", retry)
+    }
+
+    resp := env.SendAndReceiveWithKey(t, conn, "update-1", "deleteStack", "test-stack", map[string]interface{}{"deleteStackFiles": true})
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Fatalf("key reused for another request was accepted: %v", resp)
+    }
+    if _, err := os.Stat(filepath.Join(env.StacksDir, "test-stack")); err != nil {
+        t.Errorf("stack gone after rejected delete: %v", err)
+    }
+}
+
 func TestContainerInspect(t *testing.T) {
     env := testutil.Setup(t)
     env.SeedAdmin(t)
@@ -722,166 +974,587 @@ func TestContainerInspect(t *testing.T) {
     }
 }
 
-// --- Global .env settings ---
-
-func TestGlobalENVRoundTrip(t *testing.T) {
+func TestSearchContainerLogs(t *testing.T) {
     env := testutil.Setup(t)
     env.SeedAdmin(t)
+    env.SetStackRunning(t, "test-stack")
 
     conn := env.DialWS(t)
     env.Login(t, conn)
 
-    // Set globalENV
-    settings := map[string]interface{}{
-        "globalENV": "MY_VAR=hello\nOTHER_VAR=world",
+    resp := env.SendAndReceive(t, conn, "searchContainerLogs", "test-stack-web-1", map[string]interface{}{
+        "query":   "",
+        "context": 2,
+        "limit":   5,
+    })
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("searchContainerLogs failed: %v", resp)
     }
-    resp := env.SendAndReceive(t, conn, "setSettings", settings, "")
-    ok, _ := resp["ok"].(bool)
-    if !ok {
-        t.Fatalf("setSettings failed: %v", resp)
+    matches, _ := resp["matches"].([]interface{})
+    if len(matches) == 0 || len(matches) > 5 {
+        t.Errorf("expected 1-5 matches for an empty query, got %d", len(matches))
     }
 
-    // Verify file on disk
-    globalEnvPath := filepath.Join(env.StacksDir, "global.env")
-    data, err := os.ReadFile(globalEnvPath)
-    if err != nil {
-        t.Fatal("expected global.env on disk:", err)
+    resp = env.SendAndReceive(t, conn, "searchContainerLogs", "test-stack-web-1", map[string]interface{}{
+        "query": "(",
+        "regex": true,
+    })
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Error("expected an invalid regexp to be rejected")
     }
-    if string(data) != "MY_VAR=hello\nOTHER_VAR=world" {
-        t.Errorf("global.env content = %q, want %q", string(data), "MY_VAR=hello\nOTHER_VAR=world")
+
+    resp = env.SendAndReceive(t, conn, "searchContainerLogs", "test-stack-web-1", map[string]interface{}{
+        "stream": "stderr",
+    })
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("searchContainerLogs of stderr failed: %v", resp)
+    }
+    matches, _ = resp["matches"].([]interface{})
+    for _, m := range matches {
+        if stream := m.(map[string]interface{})["stream"]; stream != "stderr" {
+            t.Errorf("expected only stderr lines, got %v", m)
+        }
     }
 
-    // Get settings and verify globalENV is returned
-    resp = env.SendAndReceive(t, conn, "getSettings")
-    respData, _ := resp["data"].(map[string]interface{})
-    globalENV, _ := respData["globalENV"].(string)
-    if globalENV != "MY_VAR=hello\nOTHER_VAR=world" {
-        t.Errorf("globalENV = %q, want %q", globalENV, "MY_VAR=hello\nOTHER_VAR=world")
+    resp = env.SendAndReceive(t, conn, "searchContainerLogs", "test-stack-web-1", map[string]interface{}{
+        "stream": "stdin",
+    })
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Error("expected an invalid stream to be rejected")
     }
 }
 
-func TestGlobalENVDefaultDeletes(t *testing.T) {
+func TestStackPullPolicy(t *testing.T) {
     env := testutil.Setup(t)
     env.SeedAdmin(t)
 
     conn := env.DialWS(t)
     env.Login(t, conn)
 
-    // First set a real value
-    settings := map[string]interface{}{
-        "globalENV": "MY_VAR=hello",
+    resp := env.SendAndReceive(t, conn, "getStackPullPolicy", "test-stack")
+    if ok, _ := resp["ok"].(bool); !ok || resp["policy"] != nil {
+        t.Fatalf("expected no pull policy: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "setStackPullPolicy", "test-stack", "sometimes")
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Fatalf("expected an unknown policy to be rejected: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "setStackPullPolicy", "test-stack", "never")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("setStackPullPolicy failed: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "getStackPullPolicy", "test-stack")
+    policy, _ := resp["policy"].(map[string]interface{})
+    if policy == nil || policy["policy"] != "never" || policy["updatedBy"] != "admin" {
+        t.Fatalf("expected the never policy: %v", resp)
     }
-    env.SendAndReceive(t, conn, "setSettings", settings, "")
 
-    // Verify file exists
-    globalEnvPath := filepath.Join(env.StacksDir, "global.env")
-    if _, err := os.Stat(globalEnvPath); err != nil {
-        t.Fatal("expected global.env to exist after set")
+    resp = env.SendAndReceive(t, conn, "startStack", "test-stack", map[string]interface{}{"pull": "sometimes"})
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Errorf("expected start with an unknown policy to be rejected: %v", resp)
     }
 
-    // Set to default content — should delete file
-    settings = map[string]interface{}{
-        "globalENV": "# VARIABLE=value #comment",
+    resp = env.SendAndReceive(t, conn, "setStackPullPolicy", "test-stack", "")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("clearing the policy failed: %v", resp)
     }
-    resp := env.SendAndReceive(t, conn, "setSettings", settings, "")
-    ok, _ := resp["ok"].(bool)
-    if !ok {
-        t.Fatalf("setSettings failed: %v", resp)
+    resp = env.SendAndReceive(t, conn, "getStackPullPolicy", "test-stack")
+    if resp["policy"] != nil {
+        t.Errorf("expected the policy cleared: %v", resp)
     }
+}
 
-    // File should be deleted
-    if _, err := os.Stat(globalEnvPath); !os.IsNotExist(err) {
-        t.Error("expected global.env to be deleted when set to default content")
+func TestStackProjectConflict(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    for name, yaml := range map[string]string{
+        "shop":    "services:\n  web:\n    image: nginx:latest\n",
+        "shop-v2": "name: shop\nservices:\n  web:\n    image: nginx:latest\n",
+    } {
+        dir := filepath.Join(env.StacksDir, name)
+        if err := os.MkdirAll(dir, 0755); err != nil {
+            t.Fatal(err)
+        }
+        if err := os.WriteFile(filepath.Join(dir, "compose.yaml"), []byte(yaml), 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    env.App.Projects.Refresh()
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+    env.App.TriggerStacksBroadcast()
+
+    stacks := env.WaitForEvent(t, conn, "stacks")
+    shop, _ := stacks["shop"].(map[string]interface{})
+    conflict, _ := shop["projectConflict"].([]interface{})
+    if len(conflict) != 1 || conflict[0] != "shop-v2" {
+        t.Fatalf("expected shop to conflict with shop-v2: %v", stacks["shop"])
+    }
+    if testStack, _ := stacks["test-stack"].(map[string]interface{}); testStack == nil || testStack["projectConflict"] != nil {
+        t.Errorf("expected no conflict for test-stack: %v", stacks["test-stack"])
     }
 }
 
-func TestGlobalENVNotInBoltDB(t *testing.T) {
+func TestDeployedComposeDiff(t *testing.T) {
     env := testutil.Setup(t)
     env.SeedAdmin(t)
 
     conn := env.DialWS(t)
     env.Login(t, conn)
 
-    settings := map[string]interface{}{
-        "globalENV":       "MY_VAR=hello",
-        "primaryHostname": "test.example.com",
+    resp := env.SendAndReceive(t, conn, "getDeployedComposeDiff", "test-stack")
+    if ok, _ := resp["ok"].(bool); !ok || resp["deployed"] != false {
+        t.Fatalf("expected no deploy recorded: %v", resp)
     }
-    resp := env.SendAndReceive(t, conn, "setSettings", settings, "")
-    ok, _ := resp["ok"].(bool)
-    if !ok {
-        t.Fatalf("setSettings failed: %v", resp)
+
+    composePath := filepath.Join(env.StacksDir, "test-stack", "compose.yaml")
+    data, err := os.ReadFile(composePath)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := env.App.DeployedCompose.Set(models.DeployedCompose{
+        StackName:       "test-stack",
+        ComposeFileName: "compose.yaml",
+        ComposeYAML:     string(data),
+        Hash:            "stale",
+    }); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(composePath, append(data, []byte("# edited\n")...), 0644); err != nil {
+        t.Fatal(err)
     }
 
-    // globalENV should NOT be stored in BoltDB
-    val, err := env.App.Settings.Get("globalENV")
-    if err == nil && val != "" {
-        t.Errorf("globalENV should NOT be in BoltDB, but got %q", val)
+    resp = env.SendAndReceive(t, conn, "getDeployedComposeDiff", "test-stack")
+    diff, _ := resp["diff"].(string)
+    if resp["deployed"] != true || resp["changed"] != true || !strings.Contains(diff, "+# edited") {
+        t.Fatalf("expected the edit in the diff: %v", resp)
     }
 
-    // primaryHostname SHOULD be in BoltDB
-    val, err = env.App.Settings.Get("primaryHostname")
-    if err != nil || val != "test.example.com" {
-        t.Errorf("primaryHostname should be in BoltDB, got %q, err=%v", val, err)
+    resp = env.SendAndReceive(t, conn, "getDeployedComposeDiff", "../etc")
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Errorf("expected an invalid stack name to be rejected: %v", resp)
+    }
+
+    // Masked users don't see secret .env values in the diff
+    env.SendAndReceive(t, conn, "setSettings", map[string]interface{}{"maskEnvSecrets": "1"}, "")
+    if err := os.WriteFile(filepath.Join(env.StacksDir, "test-stack", ".env"), []byte("DB_PASSWORD=hunter2\n"), 0644); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := env.App.Users.CreateWithRole("operator", "oppass123", models.RoleOperator); err != nil {
+        t.Fatal(err)
+    }
+    opConn := env.DialWS(t)
+    if resp := env.SendAndReceive(t, opConn, "login", "operator", "oppass123", "", ""); resp["ok"] != true {
+        t.Fatalf("operator login failed: %v", resp)
+    }
+    resp = env.SendAndReceive(t, opConn, "getDeployedComposeDiff", "test-stack")
+    diff, _ = resp["diff"].(string)
+    if strings.Contains(diff, "hunter2") || !strings.Contains(diff, "+DB_PASSWORD="+stack.SecretMask) {
+        t.Errorf("expected the secret masked in the diff: %q", diff)
     }
 }
 
-func TestGlobalENVEmptyDeletes(t *testing.T) {
+func TestStackWebhooks(t *testing.T) {
+    // The test server is on loopback, which the clients reject
+    defer safehttp.AllowLoopback()()
+
+    received := make(chan *http.Request, 1)
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        received <- r
+    }))
+    defer srv.Close()
+
     env := testutil.Setup(t)
     env.SeedAdmin(t)
 
     conn := env.DialWS(t)
     env.Login(t, conn)
 
-    // Set a value first
-    settings := map[string]interface{}{
-        "globalENV": "MY_VAR=hello",
+    resp := env.SendAndReceive(t, conn, "saveStackWebhook", map[string]interface{}{
+        "stackName": "test-stack", "url": srv.URL, "events": []string{"sometimes"},
+    })
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Fatalf("expected an unknown event to be rejected: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "saveStackWebhook", map[string]interface{}{
+        "stackName": "test-stack", "url": srv.URL, "events": []string{"deployed"}, "template": `{"text": {{.Stack}}}`,
+    })
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Fatalf("expected a template rendering invalid JSON to be rejected: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "saveStackWebhook", map[string]interface{}{
+        "stackName": "test-stack", "url": srv.URL, "events": []string{"deployed", "failed"}, "enabled": true,
+    })
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("saveStackWebhook failed: %v", resp)
     }
-    env.SendAndReceive(t, conn, "setSettings", settings, "")
 
-    // Set to empty — should delete file
-    settings = map[string]interface{}{
-        "globalENV": "",
+    resp = env.SendAndReceive(t, conn, "getStackWebhooks", "test-stack")
+    hooks, _ := resp["webhooks"].([]interface{})
+    if len(hooks) != 1 {
+        t.Fatalf("expected one webhook: %v", resp)
     }
-    resp := env.SendAndReceive(t, conn, "setSettings", settings, "")
-    ok, _ := resp["ok"].(bool)
-    if !ok {
-        t.Fatalf("setSettings failed: %v", resp)
+    hook := hooks[0].(map[string]interface{})
+    if secret, _ := hook["secret"].(string); len(secret) == 0 || hook["createdBy"] != "admin" {
+        t.Fatalf("expected a generated secret: %v", hook)
     }
 
-    globalEnvPath := filepath.Join(env.StacksDir, "global.env")
-    if _, err := os.Stat(globalEnvPath); !os.IsNotExist(err) {
-        t.Error("expected global.env to be deleted when set to empty string")
+    resp = env.SendAndReceive(t, conn, "testStackWebhook", hook["id"])
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("testStackWebhook failed: %v", resp)
+    }
+    select {
+    case r := <-received:
+        if r.Header.Get("X-Dockge-Event") != "test" || r.Header.Get("X-Dockge-Signature-256") == "" {
+            t.Errorf("unexpected delivery headers: %v", r.Header)
+        }
+    case <-time.After(5 * time.Second):
+        t.Fatal("no delivery")
+    }
+
+    resp = env.SendAndReceive(t, conn, "deleteStackWebhook", hook["id"])
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("deleteStackWebhook failed: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "getStackWebhooks", "test-stack")
+    if hooks, _ := resp["webhooks"].([]interface{}); len(hooks) != 0 {
+        t.Errorf("expected the webhook deleted: %v", resp)
     }
 }
 
-func TestGlobalENVDefaultOnMissing(t *testing.T) {
+func TestStackLogCapture(t *testing.T) {
     env := testutil.Setup(t)
     env.SeedAdmin(t)
 
     conn := env.DialWS(t)
     env.Login(t, conn)
 
-    // getSettings without any global.env file should return the default placeholder
-    resp := env.SendAndReceive(t, conn, "getSettings")
-    respData, _ := resp["data"].(map[string]interface{})
-    globalENV, _ := respData["globalENV"].(string)
-    if globalENV != "# VARIABLE=value #comment" {
-        t.Errorf("expected default globalENV placeholder, got %q", globalENV)
+    resp := env.SendAndReceive(t, conn, "getStackLogCapture", "test-stack")
+    if ok, _ := resp["ok"].(bool); !ok || resp["capture"] != nil {
+        t.Fatalf("expected capture off: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "setStackLogCapture", "test-stack", map[string]interface{}{
+        "enabled":  true,
+        "services": []string{"web", " ", "web"},
+    })
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("setStackLogCapture failed: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "getStackLogCapture", "test-stack")
+    capture, _ := resp["capture"].(map[string]interface{})
+    if capture == nil || capture["enabledBy"] != "admin" {
+        t.Fatalf("expected capture on: %v", resp)
+    }
+    if services, _ := capture["services"].([]interface{}); len(services) != 1 || services[0] != "web" {
+        t.Errorf("expected only web captured: %v", capture["services"])
+    }
+    if segments, ok := resp["segments"].([]interface{}); !ok || len(segments) != 0 {
+        t.Errorf("expected no log files yet: %v", resp["segments"])
     }
-}
 
-// --- Stack name validation ---
+    resp = env.SendAndReceive(t, conn, "downloadCapturedLog", "test-stack", "../../db/dockge.db")
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Error("expected a path outside the stack's logs to be rejected")
+    }
 
-func TestSaveStackInvalidName(t *testing.T) {
+    resp = env.SendAndReceive(t, conn, "queryCapturedLogs", "test-stack", map[string]interface{}{"since": "0"})
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("queryCapturedLogs failed: %v", resp)
+    }
+    if _, ok := resp["lines"].([]interface{}); !ok {
+        t.Errorf("expected a lines array: %v", resp["lines"])
+    }
+    resp = env.SendAndReceive(t, conn, "queryCapturedLogs", "test-stack", map[string]interface{}{"since": "yesterday"})
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Error("expected an invalid since time to be rejected")
+    }
+}
+
+func TestFollowStackLogs(t *testing.T) {
     env := testutil.Setup(t)
     env.SeedAdmin(t)
+    env.SetStackRunning(t, "test-stack")
 
     conn := env.DialWS(t)
     env.Login(t, conn)
 
-    yaml := "services:\n  app:\n    image: alpine:3.19\n"
+    resp := env.SendAndReceive(t, conn, "followStackLogs", "test-stack", map[string]interface{}{
+        "services": []string{"web"},
+        "tail":     10,
+    })
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("followStackLogs failed: %v", resp)
+    }
+    sessionID, _ := resp["sessionId"].(float64)
 
-    tests := []struct {
+    data := env.WaitForBinary(t, conn)
+    if gotSession := int(data[0])<<8 | int(data[1]); gotSession != int(sessionID) {
+        t.Errorf("binary frame session ID = %d, want %d", gotSession, int(sessionID))
+    }
+    if out := string(data[2:]); !strings.Contains(out, "web") || strings.Contains(out, "redis |") {
+        t.Errorf("expected only web's prefixed lines, got %q", out)
+    }
+
+    resp = env.SendAndReceive(t, conn, "terminalLeave", map[string]interface{}{"sessionId": sessionID})
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("terminalLeave failed: %v", resp)
+    }
+}
+
+func TestEventsFeed(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    resp := env.SendAndReceive(t, conn, "subscribeEventsFeed", "test-stack")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("subscribeEventsFeed failed: %v", resp)
+    }
+    if _, ok := resp["events"].([]interface{}); !ok {
+        t.Errorf("expected an events array, got %v", resp["events"])
+    }
+
+    resp = env.SendAndReceive(t, conn, "subscribeEventsFeed", "../etc")
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Error("subscribeEventsFeed accepted an invalid stack name")
+    }
+
+    resp = env.SendAndReceive(t, conn, "unsubscribeEventsFeed")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("unsubscribeEventsFeed failed: %v", resp)
+    }
+}
+
+func TestNotificationSettings(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    resp := env.SendAndReceive(t, conn, "testNotification")
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Error("testNotification succeeded without providers")
+    }
+
+    resp = env.SendAndReceive(t, conn, "setSettings", map[string]interface{}{"notifyWebhookUrl": "not a url"}, "")
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Error("setSettings accepted an invalid webhook URL")
+    }
+
+    resp = env.SendAndReceive(t, conn, "setSettings", map[string]interface{}{"notifyTelegramToken": "123:secret"}, "")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("setSettings failed: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "getSettings")
+    data, _ := resp["data"].(map[string]interface{})
+    if data["notifyTelegramToken"] != "********" {
+        t.Errorf("expected the token to be masked, got %v", data["notifyTelegramToken"])
+    }
+
+    // Saving the mask back keeps the token
+    resp = env.SendAndReceive(t, conn, "setSettings", map[string]interface{}{"notifyTelegramToken": "********"}, "")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("setSettings failed: %v", resp)
+    }
+    if v, _ := env.App.Settings.Get("notifyTelegramToken"); v != "123:secret" {
+        t.Errorf("token = %q, want it kept", v)
+    }
+}
+
+// --- Global .env settings ---
+
+func TestGlobalENVRoundTrip(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    // Set globalENV
+    settings := map[string]interface{}{
+        "globalENV": "MY_VAR=hello\nOTHER_VAR=world",
+    }
+    resp := env.SendAndReceive(t, conn, "setSettings", settings, "")
+    ok, _ := resp["ok"].(bool)
+    if !ok {
+        t.Fatalf("setSettings failed: %v", resp)
+    }
+
+    // Verify file on disk
+    globalEnvPath := filepath.Join(env.StacksDir, "global.env")
+    data, err := os.ReadFile(globalEnvPath)
+    if err != nil {
+        t.Fatal("expected global.env on disk:", err)
+    }
+    if string(data) != "MY_VAR=hello\nOTHER_VAR=world" {
+        t.Errorf("global.env content = %q, want %q", string(data), "MY_VAR=hello\nOTHER_VAR=world")
+    }
+
+    // Get settings and verify globalENV is returned
+    resp = env.SendAndReceive(t, conn, "getSettings")
+    respData, _ := resp["data"].(map[string]interface{})
+    globalENV, _ := respData["globalENV"].(string)
+    if globalENV != "MY_VAR=hello\nOTHER_VAR=world" {
+        t.Errorf("globalENV = %q, want %q", globalENV, "MY_VAR=hello\nOTHER_VAR=world")
+    }
+}
+
+func TestGlobalENVDefaultDeletes(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    // First set a real value
+    settings := map[string]interface{}{
+        "globalENV": "MY_VAR=hello",
+    }
+    env.SendAndReceive(t, conn, "setSettings", settings, "")
+
+    // Verify file exists
+    globalEnvPath := filepath.Join(env.StacksDir, "global.env")
+    if _, err := os.Stat(globalEnvPath); err != nil {
+        t.Fatal("expected global.env to exist after set")
+    }
+
+    // Set to default content — should delete file
+    settings = map[string]interface{}{
+        "globalENV": "# VARIABLE=value #comment",
+    }
+    resp := env.SendAndReceive(t, conn, "setSettings", settings, "")
+    ok, _ := resp["ok"].(bool)
+    if !ok {
+        t.Fatalf("setSettings failed: %v", resp)
+    }
+
+    // File should be deleted
+    if _, err := os.Stat(globalEnvPath); !os.IsNotExist(err) {
+        t.Error("expected global.env to be deleted when set to default content")
+    }
+}
+
+func TestGlobalENVNotInBoltDB(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    settings := map[string]interface{}{
+        "globalENV":       "MY_VAR=hello",
+        "primaryHostname": "test.example.com",
+    }
+    resp := env.SendAndReceive(t, conn, "setSettings", settings, "")
+    ok, _ := resp["ok"].(bool)
+    if !ok {
+        t.Fatalf("setSettings failed: %v", resp)
+    }
+
+    // globalENV should NOT be stored in BoltDB
+    val, err := env.App.Settings.Get("globalENV")
+    if err == nil && val != "" {
+        t.Errorf("globalENV should NOT be in BoltDB, but got %q", val)
+    }
+
+    // primaryHostname SHOULD be in BoltDB
+    val, err = env.App.Settings.Get("primaryHostname")
+    if err != nil || val != "test.example.com" {
+        t.Errorf("primaryHostname should be in BoltDB, got %q, err=%v", val, err)
+    }
+}
+
+func TestGlobalENVEmptyDeletes(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    // Set a value first
+    settings := map[string]interface{}{
+        "globalENV": "MY_VAR=hello",
+    }
+    env.SendAndReceive(t, conn, "setSettings", settings, "")
+
+    // Set to empty — should delete file
+    settings = map[string]interface{}{
+        "globalENV": "",
+    }
+    resp := env.SendAndReceive(t, conn, "setSettings", settings, "")
+    ok, _ := resp["ok"].(bool)
+    if !ok {
+        t.Fatalf("setSettings failed: %v", resp)
+    }
+
+    globalEnvPath := filepath.Join(env.StacksDir, "global.env")
+    if _, err := os.Stat(globalEnvPath); !os.IsNotExist(err) {
+        t.Error("expected global.env to be deleted when set to empty string")
+    }
+}
+
+func TestGlobalENVDefaultOnMissing(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    // getSettings without any global.env file should return the default placeholder
+    resp := env.SendAndReceive(t, conn, "getSettings")
+    respData, _ := resp["data"].(map[string]interface{})
+    globalENV, _ := respData["globalENV"].(string)
+    if globalENV != "# VARIABLE=value #comment" {
+        t.Errorf("expected default globalENV placeholder, got %q", globalENV)
+    }
+}
+
+func TestSetSettingsAdminOnly(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+    if _, err := env.App.Users.CreateWithRole("operator", "oppass123", models.RoleOperator); err != nil {
+        t.Fatal(err)
+    }
+
+    conn := env.DialWS(t)
+    resp := env.SendAndReceive(t, conn, "login", "operator", "oppass123", "", "")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("operator login failed: %v", resp)
+    }
+
+    // Operators can't turn approvals off or change what every stack gets
+    for _, settings := range []map[string]interface{}{
+        {"requireApproval": "0"},
+        {"globalENV": "INJECTED=1"},
+        {"notifyWebhookUrl": "http://127.0.0.1:8080/hook"},
+    } {
+        resp = env.SendAndReceive(t, conn, "setSettings", settings, "")
+        if ok, _ := resp["ok"].(bool); ok {
+            t.Errorf("operator saved %v", settings)
+        }
+    }
+    if _, err := os.Stat(filepath.Join(env.App.StacksDir, "global.env")); !os.IsNotExist(err) {
+        t.Errorf("global.env written: %v", err)
+    }
+}
+
+// --- Stack name validation ---
+
+func TestSaveStackInvalidName(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    yaml := "services:\n  app:\n    image: alpine:3.19\n"
+
+    tests := []struct {
         name      string
         stackName string
     }{
@@ -1134,62 +1807,253 @@ func TestGetDockerVolumeList(t *testing.T) {
     }
 }
 
-func TestVolumeInspect(t *testing.T) {
+func TestRequestDiskUsage(t *testing.T) {
     env := testutil.Setup(t)
     env.SeedAdmin(t)
 
     conn := env.DialWS(t)
     env.Login(t, conn)
 
-    // First get the list to find a real volume name
-    listResp := env.SendAndReceive(t, conn, "getDockerVolumeList")
-    volumes, _ := listResp["dockerVolumeList"].([]interface{})
-    if len(volumes) == 0 {
-        t.Skip("no volumes available in mock daemon")
-    }
-
-    vol, _ := volumes[0].(map[string]interface{})
-    volName, _ := vol["name"].(string)
-    if volName == "" {
-        t.Fatal("expected volume name in first volume")
+    resp := env.SendAndReceive(t, conn, "requestDiskUsage")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("requestDiskUsage failed: %v", resp)
     }
-
-    resp := env.SendAndReceive(t, conn, "volumeInspect", volName)
-    ok, _ := resp["ok"].(bool)
-    if !ok {
-        t.Fatalf("volumeInspect failed: %v", resp)
+    usage, _ := resp["diskUsage"].(map[string]interface{})
+    for _, kind := range []string{"images", "containers", "volumes", "buildCache"} {
+        sum, _ := usage[kind].(map[string]interface{})
+        if sum == nil {
+            t.Fatalf("expected %s in diskUsage: %v", kind, usage)
+        }
+        size, _ := sum["sizeBytes"].(float64)
+        reclaimable, _ := sum["reclaimableBytes"].(float64)
+        if reclaimable < 0 || reclaimable > size {
+            t.Errorf("%s: reclaimable %v of %v", kind, reclaimable, size)
+        }
     }
-
-    detail, _ := resp["volumeDetail"].(map[string]interface{})
-    if detail == nil {
-        t.Fatal("expected volumeDetail in response")
+    if images := usage["images"].(map[string]interface{}); images["total"].(float64) == 0 {
+        t.Errorf("expected images in the mock daemon: %v", images)
     }
 }
 
-func TestNetworkInspect(t *testing.T) {
+func TestPrune(t *testing.T) {
     env := testutil.Setup(t)
     env.SeedAdmin(t)
 
     conn := env.DialWS(t)
     env.Login(t, conn)
 
-    // Get the network list first to find a real network name
-    listResp := env.SendAndReceive(t, conn, "getDockerNetworkList")
-    networks, _ := listResp["dockerNetworkList"].([]interface{})
-    if len(networks) == 0 {
-        t.Fatal("expected at least one network")
+    for _, event := range []string{"pruneImages", "pruneNetworks", "pruneBuildCache"} {
+        resp := env.SendAndReceive(t, conn, event, map[string]interface{}{"all": false})
+        if ok, _ := resp["ok"].(bool); !ok {
+            t.Fatalf("%s failed: %v", event, resp)
+        }
+        report, _ := resp["report"].(map[string]interface{})
+        if _, ok := report["deleted"].([]interface{}); !ok || report["spaceReclaimed"] == nil {
+            t.Errorf("%s: unexpected report %v", event, report)
+        }
     }
 
-    net, _ := networks[0].(map[string]interface{})
-    netName, _ := net["name"].(string)
-    if netName == "" {
-        t.Fatal("expected network name in first network")
+    // Pruned volumes lose their data, so it takes sudo
+    resp := env.SendAndReceive(t, conn, "pruneVolumes", map[string]interface{}{"all": true})
+    if msg, _ := resp["msg"].(string); msg != "sudoRequired" {
+        t.Fatalf("expected pruneVolumes to require sudo, got %v", resp)
     }
-
-    resp := env.SendAndReceive(t, conn, "networkInspect", netName)
-    ok, _ := resp["ok"].(bool)
-    if !ok {
-        t.Fatalf("networkInspect failed: %v", resp)
+    resp = env.SendAndReceive(t, conn, "sudo", "testpass123")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("sudo failed: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "pruneVolumes", map[string]interface{}{"all": false})
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("pruneVolumes failed: %v", resp)
+    }
+}
+
+func TestNetworkManagement(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    resp := env.SendAndReceive(t, conn, "createNetwork", map[string]interface{}{"name": "backend", "subnet": "10.5.1.0/24", "gateway": "10.6.0.1"})
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Fatalf("expected a gateway outside the subnet to be rejected: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "createNetwork", map[string]interface{}{"name": "backend", "subnet": "10.5.1.0/24", "internal": true})
+    if ok, _ := resp["ok"].(bool); !ok || resp["id"] == "" {
+        t.Fatalf("createNetwork failed: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "createNetwork", map[string]interface{}{"name": "backend"})
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Fatalf("expected a taken name to be rejected: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "networkInspect", "backend")
+    detail, _ := resp["networkDetail"].(map[string]interface{})
+    if detail == nil || detail["internal"] != true {
+        t.Fatalf("expected an internal network: %v", resp)
+    }
+
+    resp = env.SendAndReceive(t, conn, "connectNetwork", "backend", "test-stack-web-1")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("connectNetwork failed: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "connectNetwork", "backend", "test-stack-web-1")
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Errorf("expected connecting twice to fail: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "disconnectNetwork", "backend", "test-stack-web-1")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("disconnectNetwork failed: %v", resp)
+    }
+
+    resp = env.SendAndReceive(t, conn, "removeNetwork", "backend")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("removeNetwork failed: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "networkInspect", "backend")
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Errorf("expected the network removed: %v", resp)
+    }
+}
+
+func TestContainerActions(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    for _, event := range []string{"pauseContainer", "unpauseContainer"} {
+        resp := env.SendAndReceive(t, conn, event, "test-stack-web-1")
+        if ok, _ := resp["ok"].(bool); !ok {
+            t.Fatalf("%s failed: %v", event, resp)
+        }
+    }
+    resp := env.SendAndReceive(t, conn, "unpauseContainer", "test-stack-web-1")
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Errorf("expected unpausing a running container to fail: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "killContainer", "test-stack-web-1", "SIGBOGUS")
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Fatalf("expected an unknown signal to be rejected: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "killContainer", "test-stack-web-1", "sigterm")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("killContainer failed: %v", resp)
+    }
+
+    resp = env.SendAndReceive(t, conn, "renameContainer", "test-stack-web-1", "bad name")
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Fatalf("expected an invalid name to be rejected: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "renameContainer", "test-stack-web-1", "web-renamed")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("renameContainer failed: %v", resp)
+    }
+
+    // Removing its volumes loses their data, so it takes sudo
+    resp = env.SendAndReceive(t, conn, "removeContainer", "web-renamed", map[string]interface{}{"volumes": true})
+    if msg, _ := resp["msg"].(string); msg != "sudoRequired" {
+        t.Fatalf("expected removing volumes to require sudo, got %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "removeContainer", "web-renamed", map[string]interface{}{"force": true})
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("removeContainer failed: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "containerInspect", "web-renamed")
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Errorf("expected the container removed: %v", resp)
+    }
+}
+
+func TestBrowseVolume(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    resp := env.SendAndReceive(t, conn, "browseVolume", "no-such-volume", "/")
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Error("browseVolume succeeded for a missing volume")
+    }
+    resp = env.SendAndReceive(t, conn, "browseVolume", "no-such-volume", "a\x00b")
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Error("browseVolume accepted a path with a NUL byte")
+    }
+
+    // Downloads need sudo
+    resp = env.SendAndReceive(t, conn, "downloadVolumeFile", "no-such-volume", "config.yml")
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Error("downloadVolumeFile succeeded without sudo")
+    }
+
+    res, err := http.Get(env.Server.URL + "/api/volume-files/unknown")
+    if err != nil {
+        t.Fatal(err)
+    }
+    res.Body.Close()
+    if res.StatusCode != http.StatusNotFound {
+        t.Errorf("unknown download link: got %d, want 404", res.StatusCode)
+    }
+}
+
+func TestVolumeInspect(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    // First get the list to find a real volume name
+    listResp := env.SendAndReceive(t, conn, "getDockerVolumeList")
+    volumes, _ := listResp["dockerVolumeList"].([]interface{})
+    if len(volumes) == 0 {
+        t.Skip("no volumes available in mock daemon")
+    }
+
+    vol, _ := volumes[0].(map[string]interface{})
+    volName, _ := vol["name"].(string)
+    if volName == "" {
+        t.Fatal("expected volume name in first volume")
+    }
+
+    resp := env.SendAndReceive(t, conn, "volumeInspect", volName)
+    ok, _ := resp["ok"].(bool)
+    if !ok {
+        t.Fatalf("volumeInspect failed: %v", resp)
+    }
+
+    detail, _ := resp["volumeDetail"].(map[string]interface{})
+    if detail == nil {
+        t.Fatal("expected volumeDetail in response")
+    }
+}
+
+func TestNetworkInspect(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    // Get the network list first to find a real network name
+    listResp := env.SendAndReceive(t, conn, "getDockerNetworkList")
+    networks, _ := listResp["dockerNetworkList"].([]interface{})
+    if len(networks) == 0 {
+        t.Fatal("expected at least one network")
+    }
+
+    net, _ := networks[0].(map[string]interface{})
+    netName, _ := net["name"].(string)
+    if netName == "" {
+        t.Fatal("expected network name in first network")
+    }
+
+    resp := env.SendAndReceive(t, conn, "networkInspect", netName)
+    ok, _ := resp["ok"].(bool)
+    if !ok {
+        t.Fatalf("networkInspect failed: %v", resp)
     }
 
     detail, _ := resp["networkDetail"].(map[string]interface{})
@@ -1235,7 +2099,21 @@ func TestForceDeleteStack(t *testing.T) {
     conn := env.DialWS(t)
     env.Login(t, conn)
 
+    // Destructive action requires sudo mode
     resp := env.SendAndReceive(t, conn, "forceDeleteStack", "force-delete-me")
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Fatal("expected forceDeleteStack to require sudo")
+    }
+    if msg, _ := resp["msg"].(string); msg != "sudoRequired" {
+        t.Fatalf("expected sudoRequired, got %v", resp)
+    }
+
+    resp = env.SendAndReceive(t, conn, "sudo", "testpass123")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("sudo failed: %v", resp)
+    }
+
+    resp = env.SendAndReceive(t, conn, "forceDeleteStack", "force-delete-me")
     ok, _ := resp["ok"].(bool)
     if !ok {
         t.Fatalf("forceDeleteStack failed: %v", resp)
@@ -1540,3 +2418,1305 @@ func TestConcurrentStackOperations(t *testing.T) {
         }
     }
 }
+
+func TestSaveStackReportsMissingExternal(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    yaml := "services:\n  app:\n    image: nginx:latest\n    networks:\n      - shared-net\nnetworks:\n  shared-net:\n    external: true\n"
+    resp := env.SendAndReceive(t, conn, "saveStack", "ext-stack", yaml, "", "", true)
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("saveStack failed: %v", resp)
+    }
+    missing, _ := resp["missingExternal"].([]interface{})
+    if len(missing) != 1 {
+        t.Fatalf("expected 1 missing external resource, got %v", resp["missingExternal"])
+    }
+    if m, _ := missing[0].(map[string]interface{}); m["kind"] != "network" || m["name"] != "shared-net" {
+        t.Errorf("unexpected missing resource %v", missing[0])
+    }
+
+    // Deploying is refused until the network exists
+    resp = env.SendAndReceive(t, conn, "deployStack", "ext-stack", yaml, "", "", false)
+    if ok, _ := resp["ok"].(bool); ok || resp["msg"] != "missingExternalResources" {
+        t.Fatalf("expected deploy to be refused, got %v", resp)
+    }
+
+    resp = env.SendAndReceive(t, conn, "createExternalResource", map[string]string{"kind": "network", "name": "shared-net"})
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("createExternalResource failed: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "saveStack", "ext-stack", yaml, "", "", false)
+    if _, ok := resp["missingExternal"]; ok {
+        t.Errorf("network still reported missing: %v", resp)
+    }
+
+    resp = env.SendAndReceive(t, conn, "createExternalResource", map[string]string{"kind": "network", "name": "-bad"})
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Error("expected invalid name to be rejected")
+    }
+}
+
+func TestSaveStackRejectsInvalidCompose(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    yaml := "services:\n  app:\n    restart: sometimes\n"
+    resp := env.SendAndReceive(t, conn, "saveStack", "lint-stack", yaml, "", "", true)
+    if ok, _ := resp["ok"].(bool); ok || resp["msg"] != "composeInvalid" {
+        t.Fatalf("expected save to be refused, got %v", resp)
+    }
+    diags, _ := resp["diagnostics"].([]interface{})
+    if len(diags) != 2 {
+        t.Fatalf("expected 2 diagnostics, got %v", resp["diagnostics"])
+    }
+    if d, _ := diags[0].(map[string]interface{}); d["file"] != "compose" || d["line"] != float64(2) || d["severity"] != "error" {
+        t.Errorf("unexpected diagnostic %v", diags[0])
+    }
+    if _, err := os.Stat(filepath.Join(env.StacksDir, "lint-stack")); !os.IsNotExist(err) {
+        t.Error("invalid stack was written to disk")
+    }
+
+    // Warnings don't block saving
+    yaml = "services:\n  app:\n    image: alpine:3.19\n    prots:\n      - 80:80\n"
+    resp = env.SendAndReceive(t, conn, "saveStack", "lint-stack", yaml, "", "", true)
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("saveStack failed: %v", resp)
+    }
+    if diags, _ := resp["diagnostics"].([]interface{}); len(diags) != 1 {
+        t.Errorf("expected 1 warning, got %v", resp["diagnostics"])
+    }
+
+    resp = env.SendAndReceive(t, conn, "validateCompose", "services:\n  app:\n    image: nginx\n    image: httpd\n", false)
+    if diags, _ := resp["diagnostics"].([]interface{}); len(diags) != 1 {
+        t.Errorf("expected duplicate key error, got %v", resp)
+    }
+}
+
+func TestConvertDockerRun(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    resp := env.SendAndReceive(t, conn, "convertDockerRun", "docker run -d --name web -p 8080:80 --link db nginx:latest")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("convertDockerRun failed: %v", resp)
+    }
+    if resp["service"] != "web" || !strings.Contains(resp["yaml"].(string), `- "8080:80"`) {
+        t.Errorf("unexpected conversion: %v", resp)
+    }
+    if warnings, _ := resp["warnings"].([]interface{}); len(warnings) != 1 {
+        t.Errorf("expected a warning for --link, got %v", resp["warnings"])
+    }
+
+    resp = env.SendAndReceive(t, conn, "convertDockerRun", "docker run --no-such-flag nginx")
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Errorf("expected an unknown flag to fail: %v", resp)
+    }
+}
+
+func TestAdoptStack(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+    env.SetStackRunning(t, "test-stack")
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    for _, args := range []map[string]interface{}{
+        {},
+        {"project": "test-stack", "containers": []string{"test-stack-web-1"}},
+        // Already managed
+        {"project": "test-stack", "name": "adopted", "preview": true},
+        // Part of a stack, not standalone
+        {"containers": []string{"test-stack-web-1"}, "name": "adopted", "preview": true},
+    } {
+        resp := env.SendAndReceive(t, conn, "adoptStack", args)
+        if ok, _ := resp["ok"].(bool); ok {
+            t.Errorf("adoptStack %v: expected failure, got %v", args, resp)
+        }
+    }
+}
+
+func TestStackTerminalAccess(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+    for _, name := range []string{"alice", "bob"} {
+        if _, err := env.App.Users.CreateWithRole(name, "oppass123", models.RoleOperator); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+    resp := env.SendAndReceive(t, conn, "sudo", "testpass123")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("sudo failed: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "setStackTerminalAccess", "test-stack", map[string]interface{}{
+        "restricted": true,
+        "usernames":  []string{"alice"},
+    })
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("setStackTerminalAccess failed: %v", resp)
+    }
+
+    bobConn := env.DialWS(t)
+    resp = env.SendAndReceive(t, bobConn, "login", "bob", "oppass123", "", "")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("bob login failed: %v", resp)
+    }
+
+    // Bob can still view the stack and read its logs, but not get a shell
+    resp = env.SendAndReceive(t, bobConn, "getStack", "test-stack")
+    if ok, _ := resp["ok"].(bool); !ok || resp["canOpenTerminal"] != false || resp["terminalRestricted"] != true {
+        t.Fatalf("unexpected getStack response: %v", resp)
+    }
+    resp = env.SendAndReceive(t, bobConn, "terminalJoin", map[string]interface{}{"type": "combined", "stack": "test-stack"})
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Errorf("combined logs should stay available: %v", resp)
+    }
+    for _, args := range []map[string]interface{}{
+        {"type": "exec", "stack": "test-stack", "service": "app"},
+        {"type": "console"},
+    } {
+        resp = env.SendAndReceive(t, bobConn, "terminalJoin", args)
+        if ok, _ := resp["ok"].(bool); ok {
+            t.Errorf("expected %v to be denied for bob", args["type"])
+        }
+    }
+
+    // Only admins manage the restriction
+    resp = env.SendAndReceive(t, bobConn, "setStackTerminalAccess", "test-stack", map[string]interface{}{"restricted": false})
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Error("operator lifted the restriction")
+    }
+
+    aliceConn := env.DialWS(t)
+    resp = env.SendAndReceive(t, aliceConn, "login", "alice", "oppass123", "", "")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("alice login failed: %v", resp)
+    }
+    resp = env.SendAndReceive(t, aliceConn, "getStack", "test-stack")
+    if resp["canOpenTerminal"] != true {
+        t.Errorf("alice should have terminal access: %v", resp)
+    }
+}
+
+func TestStackTerminalEnv(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    resp := env.SendAndReceive(t, conn, "setStackTerminalEnv", "test-stack", map[string]interface{}{
+        "lang":   "C.UTF-8",
+        "shells": "ash sh",
+    })
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("setStackTerminalEnv failed: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "getStackTerminalEnv", "test-stack")
+    saved, _ := resp["env"].(map[string]interface{})
+    if saved == nil || saved["lang"] != "C.UTF-8" || len(saved["shells"].([]interface{})) != 2 {
+        t.Fatalf("unexpected getStackTerminalEnv response: %v", resp)
+    }
+
+    // Shell candidates are run through sh -c, so anything needing quotes is rejected
+    resp = env.SendAndReceive(t, conn, "setStackTerminalEnv", "test-stack", map[string]interface{}{"shells": "bash;reboot"})
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Error("expected an unsafe shell to be rejected")
+    }
+
+    // The exec terminal starts with the stack's candidates
+    resp = env.SendAndReceive(t, conn, "terminalJoin", map[string]interface{}{"type": "exec", "stack": "test-stack", "service": "web"})
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Errorf("exec terminalJoin failed: %v", resp)
+    }
+}
+
+func TestExecOptions(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    resp := env.SendAndReceive(t, conn, "terminalJoin", map[string]interface{}{
+        "type": "exec", "stack": "test-stack", "service": "web",
+        "exec": map[string]interface{}{"user": "node", "workdir": "/app", "env": []string{"DEBUG=1"}},
+    })
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("exec terminalJoin failed: %v", resp)
+    }
+
+    // The options are remembered for shells opened without any
+    resp = env.SendAndReceive(t, conn, "getExecDefaults")
+    defaults, _ := resp["defaults"].(map[string]interface{})
+    if defaults == nil || defaults["user"] != "node" || defaults["workdir"] != "/app" {
+        t.Fatalf("unexpected getExecDefaults response: %v", resp)
+    }
+
+    resp = env.SendAndReceive(t, conn, "terminalJoin", map[string]interface{}{
+        "type": "exec", "stack": "test-stack", "service": "web",
+        "exec": map[string]interface{}{"workdir": "relative"},
+    })
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Error("expected a relative workdir to be rejected")
+    }
+}
+
+func TestExecCommandExitCode(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    resp := env.SendAndReceive(t, conn, "terminalJoin", map[string]interface{}{
+        "type": "exec", "stack": "test-stack", "service": "web",
+        "exec": map[string]interface{}{"cmd": []string{"nosuchcmd", "--version"}},
+    })
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("exec terminalJoin failed: %v", resp)
+    }
+    exited := env.WaitForEvent(t, conn, "terminalExited")
+    if code, _ := exited["exitCode"].(float64); code != 127 {
+        t.Errorf("expected exit code 127: %v", exited)
+    }
+
+    // The command isn't remembered with the other options
+    resp = env.SendAndReceive(t, conn, "getExecDefaults")
+    if defaults, _ := resp["defaults"].(map[string]interface{}); defaults != nil && defaults["cmd"] != nil {
+        t.Errorf("expected the command not to be remembered: %v", resp)
+    }
+}
+
+func TestUpdateServiceToTag(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    resp := env.SendAndReceive(t, conn, "updateServiceToTag", "test-stack", "redis", "7.4")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("updateServiceToTag failed: %v", resp)
+    }
+    data, err := os.ReadFile(filepath.Join(env.StacksDir, "test-stack", "compose.yaml"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !strings.Contains(string(data), "image: redis:7.4\n") || !strings.Contains(string(data), "image: nginx:latest\n") {
+        t.Errorf("unexpected compose file after tag update:\n%s", data)
+    }
+
+    for _, tag := range []string{"", "7.4 --pull", ":latest"} {
+        resp = env.SendAndReceive(t, conn, "updateServiceToTag", "test-stack", "redis", tag)
+        if ok, _ := resp["ok"].(bool); ok {
+            t.Errorf("expected tag %q to be rejected", tag)
+        }
+    }
+}
+
+func TestCreateStackFromTemplate(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    resp := env.SendAndReceive(t, conn, "listTemplates")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("listTemplates failed: %v", resp)
+    }
+    found := false
+    for _, tmpl := range resp["templates"].([]interface{}) {
+        if tmpl.(map[string]interface{})["id"] == "postgres" {
+            found = true
+        }
+    }
+    if !found {
+        t.Fatalf("built-in postgres template not listed: %v", resp["templates"])
+    }
+
+    resp = env.SendAndReceive(t, conn, "createStackFromTemplate", map[string]interface{}{
+        "template":  "postgres",
+        "stackName": "tmpl-db",
+        "variables": map[string]string{"POSTGRES_DB": "shop", "PORT": "15432"},
+    })
+    if ok, _ := resp["ok"].(bool); !ok || resp["stackName"] != "tmpl-db" {
+        t.Fatalf("createStackFromTemplate failed: %v", resp)
+    }
+    composeYAML, err := os.ReadFile(filepath.Join(env.StacksDir, "tmpl-db", "compose.yaml"))
+    if err != nil || !strings.Contains(string(composeYAML), `"15432:5432"`) {
+        t.Errorf("unexpected compose.yaml %q, %v", composeYAML, err)
+    }
+    envFile, err := os.ReadFile(filepath.Join(env.StacksDir, "tmpl-db", ".env"))
+    if err != nil || !strings.Contains(string(envFile), "POSTGRES_DB=shop\n") || strings.Contains(string(envFile), "{{") {
+        t.Errorf("unexpected .env %q, %v", envFile, err)
+    }
+
+    // Existing stacks are never overwritten
+    resp = env.SendAndReceive(t, conn, "createStackFromTemplate", map[string]interface{}{
+        "template":  "redis",
+        "stackName": "tmpl-db",
+    })
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Error("template overwrote an existing stack")
+    }
+}
+
+func TestCreateStackFromURL(t *testing.T) {
+    // The test server is on loopback, which the client rejects
+    defer safehttp.AllowLoopback()()
+
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    composeYAML := "services:\n  whoami:\n    image: traefik/whoami\n"
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        switch r.URL.Path {
+        case "/whoami/compose.yaml":
+            w.Write([]byte(composeYAML))
+        case "/whoami/.env.example":
+            w.Write([]byte("PORT=8080\n"))
+        default:
+            http.NotFound(w, r)
+        }
+    }))
+    defer srv.Close()
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    args := map[string]interface{}{"url": srv.URL + "/whoami/compose.yaml", "envUrl": srv.URL + "/whoami/.env.example"}
+    resp := env.SendAndReceive(t, conn, "previewStackFromURL", args)
+    if ok, _ := resp["ok"].(bool); !ok || resp["stackName"] != "whoami" || resp["composeYAML"] != composeYAML {
+        t.Fatalf("previewStackFromURL failed: %v", resp)
+    }
+
+    // The files changed after the preview
+    args["stackName"] = "whoami"
+    args["digest"] = resp["digest"]
+    composeYAML = "services:\n  whoami:\n    image: traefik/whoami:v1.10\n"
+    resp = env.SendAndReceive(t, conn, "createStackFromURL", args)
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Fatal("created a stack from files that changed since the preview")
+    }
+
+    delete(args, "digest")
+    resp = env.SendAndReceive(t, conn, "createStackFromURL", args)
+    if ok, _ := resp["ok"].(bool); !ok || resp["stackName"] != "whoami" {
+        t.Fatalf("createStackFromURL failed: %v", resp)
+    }
+    data, err := os.ReadFile(filepath.Join(env.StacksDir, "whoami", "compose.yaml"))
+    if err != nil || string(data) != composeYAML {
+        t.Errorf("unexpected compose.yaml %q, %v", data, err)
+    }
+    data, err = os.ReadFile(filepath.Join(env.StacksDir, "whoami", ".env"))
+    if err != nil || string(data) != "PORT=8080\n" {
+        t.Errorf("unexpected .env %q, %v", data, err)
+    }
+
+    // Existing stacks are never overwritten
+    resp = env.SendAndReceive(t, conn, "createStackFromURL", args)
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Error("created a stack over an existing one")
+    }
+}
+
+func TestPreviewInterpolation(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    composeYAML := "services:\n  web:\n    image: nginx:${TAG:-latest}\n    environment:\n      DB_PASSWORD: ${DB_PASSWORD}\n"
+    resp := env.SendAndReceive(t, conn, "previewInterpolation", "test-stack", composeYAML, "DB_PASSWORD=hunter2\n", "")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("previewInterpolation failed: %v", resp)
+    }
+    resolved, _ := resp["compose"].(map[string]interface{})["resolved"].(string)
+    if !strings.Contains(resolved, "image: nginx:latest") || !strings.Contains(resolved, "DB_PASSWORD: hunter2") {
+        t.Errorf("unexpected resolved compose file %q", resolved)
+    }
+
+    // Admins always see secrets, even with masking on
+    env.SendAndReceive(t, conn, "setSettings", map[string]interface{}{"maskEnvSecrets": "1"})
+    resp = env.SendAndReceive(t, conn, "getStack", "test-stack")
+    if masked, _ := resp["envMasked"].(bool); masked {
+        t.Errorf("secrets masked for an admin: %v", resp)
+    }
+}
+
+func TestStackBackupRoundTrip(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+    resp := env.SendAndReceive(t, conn, "sudo", "testpass123")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("sudo failed: %v", resp)
+    }
+
+    resp = env.SendAndReceive(t, conn, "exportStack", "test-stack", map[string]interface{}{})
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("exportStack failed: %v", resp)
+    }
+    res, err := http.Get(env.Server.URL + resp["url"].(string))
+    if err != nil {
+        t.Fatal(err)
+    }
+    backup, _ := io.ReadAll(res.Body)
+    res.Body.Close()
+    if res.StatusCode != http.StatusOK {
+        t.Fatalf("download: %d %s", res.StatusCode, backup)
+    }
+
+    // Download links work once
+    res, err = http.Get(env.Server.URL + resp["url"].(string))
+    if err != nil {
+        t.Fatal(err)
+    }
+    res.Body.Close()
+    if res.StatusCode != http.StatusNotFound {
+        t.Errorf("second download: got %d, want 404", res.StatusCode)
+    }
+
+    upload := func(name string) *http.Response {
+        resp := env.SendAndReceive(t, conn, "importStack", map[string]interface{}{"stackName": name})
+        if ok, _ := resp["ok"].(bool); !ok {
+            t.Fatalf("importStack failed: %v", resp)
+        }
+        res, err := http.Post(env.Server.URL+resp["url"].(string), "application/gzip", bytes.NewReader(backup))
+        if err != nil {
+            t.Fatal(err)
+        }
+        return res
+    }
+
+    res = upload("restored-stack")
+    body, _ := io.ReadAll(res.Body)
+    res.Body.Close()
+    if res.StatusCode != http.StatusOK {
+        t.Fatalf("import: %d %s", res.StatusCode, body)
+    }
+    want, _ := os.ReadFile(filepath.Join(env.StacksDir, "test-stack", "compose.yaml"))
+    got, err := os.ReadFile(filepath.Join(env.StacksDir, "restored-stack", "compose.yaml"))
+    if err != nil || !bytes.Equal(got, want) {
+        t.Errorf("restored compose.yaml = %q, %v; want %q", got, err, want)
+    }
+
+    // An existing stack is never overwritten
+    res = upload("test-stack")
+    res.Body.Close()
+    if res.StatusCode != http.StatusConflict {
+        t.Errorf("import over existing stack: got %d, want 409", res.StatusCode)
+    }
+}
+
+func TestConfigBackupStagesRestore(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+    resp := env.SendAndReceive(t, conn, "sudo", "testpass123")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("sudo failed: %v", resp)
+    }
+
+    resp = env.SendAndReceive(t, conn, "createConfigBackup")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("createConfigBackup failed: %v", resp)
+    }
+    res, err := http.Get(env.Server.URL + resp["url"].(string))
+    if err != nil {
+        t.Fatal(err)
+    }
+    archive, _ := io.ReadAll(res.Body)
+    res.Body.Close()
+    if res.StatusCode != http.StatusOK {
+        t.Fatalf("download: %d %s", res.StatusCode, archive)
+    }
+
+    resp = env.SendAndReceive(t, conn, "restoreConfigBackup")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("restoreConfigBackup failed: %v", resp)
+    }
+    res, err = http.Post(env.Server.URL+resp["url"].(string), "application/gzip", bytes.NewReader(archive))
+    if err != nil {
+        t.Fatal(err)
+    }
+    body, _ := io.ReadAll(res.Body)
+    res.Body.Close()
+    if res.StatusCode != http.StatusOK {
+        t.Fatalf("upload: %d %s", res.StatusCode, body)
+    }
+
+    resp = env.SendAndReceive(t, conn, "getConfigRestore")
+    if resp["pending"] == nil {
+        t.Fatalf("no pending restore: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "cancelConfigRestore")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("cancelConfigRestore failed: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "getConfigRestore")
+    if resp["pending"] != nil {
+        t.Errorf("restore still pending after cancel: %v", resp)
+    }
+}
+
+func TestGetDaemonInfo(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    resp := env.SendAndReceive(t, conn, "getDaemonInfo")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("getDaemonInfo failed: %v", resp)
+    }
+    info, _ := resp["info"].(map[string]interface{})
+    if info["rootless"] != false || info["serverVersion"] == "" {
+        t.Errorf("unexpected daemon info %v", info)
+    }
+    if resp["statsSupported"] != true {
+        t.Errorf("expected stats to be supported, got %v", resp)
+    }
+    if guidance, _ := resp["guidance"].([]interface{}); len(guidance) != 0 {
+        t.Errorf("expected no rootless guidance, got %v", guidance)
+    }
+}
+
+func TestAuditLogRecordsActions(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    yaml := "services:\n  app:\n    image: nginx:latest\n"
+    resp := env.SendAndReceive(t, conn, "saveStack", "audited", yaml, "", "", true)
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("saveStack failed: %v", resp)
+    }
+    env.SendAndReceive(t, conn, "getStack", "audited")
+
+    resp = env.SendAndReceive(t, conn, "getAuditLog", map[string]interface{}{"stack": "audited"})
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("getAuditLog failed: %v", resp)
+    }
+    entries, _ := resp["entries"].([]interface{})
+    if len(entries) != 1 {
+        t.Fatalf("expected only saveStack to be recorded, got %v", entries)
+    }
+    e, _ := entries[0].(map[string]interface{})
+    if e["action"] != "saveStack" || e["user"] != "admin" || e["success"] != true {
+        t.Errorf("unexpected entry %v", e)
+    }
+    args, _ := e["args"].([]interface{})
+    if len(args) == 0 || args[0] != `"audited"` || args[1] == yaml {
+        t.Errorf("unexpected args summary %v", args)
+    }
+}
+
+func TestBuildStack(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    yaml := "services:\n  app:\n    build: ./app\n"
+    resp := env.SendAndReceive(t, conn, "saveStack", "builder", yaml, "", "", true)
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("saveStack failed: %v", resp)
+    }
+
+    // The build context doesn't exist yet
+    resp = env.SendAndReceive(t, conn, "buildStack", "builder")
+    if ok, _ := resp["ok"].(bool); ok || resp["msg"] != "buildFailed" {
+        t.Fatalf("expected a build failure, got %v", resp)
+    }
+
+    if err := os.MkdirAll(filepath.Join(env.StacksDir, "builder", "app"), 0o755); err != nil {
+        t.Fatal(err)
+    }
+    resp = env.SendAndReceive(t, conn, "buildStack", "builder", map[string]interface{}{"pull": true})
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("buildStack failed: %v", resp)
+    }
+}
+
+func TestNewStackNameValidation(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    if err := os.MkdirAll(filepath.Join(env.StacksDir, "Shop"), 0755); err != nil {
+        t.Fatal(err)
+    }
+    yaml := "services:\n  app:\n    image: alpine:3.19\n"
+
+    tests := []struct {
+        name  string
+        code  string
+        param string
+    }{
+        {"dockge", "stackNameReserved", "dockge"},
+        {"shop", "stackNameExists", "Shop"},
+        {"_app", "stackNameInvalidStart", "_"},
+    }
+    for _, tt := range tests {
+        resp := env.SendAndReceive(t, conn, "saveStack", tt.name, yaml, "", "", true)
+        if ok, _ := resp["ok"].(bool); ok || resp["code"] != tt.code || resp["msgi18n"] != true {
+            t.Errorf("saveStack %q: expected %s, got %v", tt.name, tt.code, resp)
+            continue
+        }
+        msg, _ := resp["msg"].(map[string]interface{})
+        values, _ := msg["values"].(map[string]interface{})
+        if msg["key"] != tt.code || values["param"] != tt.param {
+            t.Errorf("saveStack %q: unexpected msg %v", tt.name, resp["msg"])
+        }
+    }
+    if _, err := os.Stat(filepath.Join(env.StacksDir, "dockge")); !os.IsNotExist(err) {
+        t.Error("reserved stack was written to disk")
+    }
+
+    resp := env.SendAndReceive(t, conn, "checkStackName", "fresh")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Errorf("checkStackName fresh: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "checkStackName", "SHOP")
+    if ok, _ := resp["ok"].(bool); ok || resp["code"] != "stackNameInvalidChar" {
+        t.Errorf("checkStackName SHOP: %v", resp)
+    }
+
+    // Once created, a stack is saved as an existing one
+    resp = env.SendAndReceive(t, conn, "saveStack", "fresh", yaml, "", "", true)
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("saveStack fresh: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "saveStack", "fresh", yaml, "", "", true)
+    if ok, _ := resp["ok"].(bool); ok || resp["code"] != "stackNameExists" {
+        t.Errorf("second add of fresh: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "saveStack", "fresh", yaml, "", "", false)
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Errorf("saveStack existing fresh: %v", resp)
+    }
+}
+
+func TestHostTerminal(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+    if _, err := env.App.Users.CreateWithRole("operator", "oppass123", models.RoleOperator); err != nil {
+        t.Fatal(err)
+    }
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    // Disabled unless configured
+    resp := env.SendAndReceive(t, conn, "hostTerminal", map[string]interface{}{})
+    if ok, _ := resp["ok"].(bool); ok || resp["msg"] != "hostTerminalDisabled" {
+        t.Fatalf("expected host terminal to be disabled: %v", resp)
+    }
+    // The console is a host shell too
+    resp = env.SendAndReceive(t, conn, "terminalJoin", map[string]interface{}{"type": "console"})
+    if ok, _ := resp["ok"].(bool); ok || resp["msg"] != "hostTerminalDisabled" {
+        t.Fatalf("expected console to be disabled: %v", resp)
+    }
+
+    env.App.HostTerminal = "local"
+    opConn := env.DialWS(t)
+    resp = env.SendAndReceive(t, opConn, "login", "operator", "oppass123", "", "")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("operator login failed: %v", resp)
+    }
+    resp = env.SendAndReceive(t, opConn, "hostTerminal", map[string]interface{}{})
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Fatalf("operator opened a host terminal: %v", resp)
+    }
+    resp = env.SendAndReceive(t, opConn, "terminalJoin", map[string]interface{}{"type": "console"})
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Fatalf("operator opened the console: %v", resp)
+    }
+
+    resp = env.SendAndReceive(t, conn, "hostTerminal", map[string]interface{}{"shell": "a;b"})
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Fatalf("expected invalid shell to be refused: %v", resp)
+    }
+
+    // Opening a shell needs sudo
+    resp = env.SendAndReceive(t, conn, "hostTerminal", map[string]interface{}{"shell": "sh"})
+    if ok, _ := resp["ok"].(bool); ok || resp["msg"] != "sudoRequired" {
+        t.Fatalf("expected sudoRequired: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "terminalJoin", map[string]interface{}{"type": "console"})
+    if ok, _ := resp["ok"].(bool); ok || resp["msg"] != "sudoRequired" {
+        t.Fatalf("expected sudoRequired for the console: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "sudo", "testpass123")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("sudo failed: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "hostTerminal", map[string]interface{}{"shell": "sh"})
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("hostTerminal failed: %v", resp)
+    }
+    sessionID := int(resp["sessionId"].(float64))
+    frame := append([]byte{byte(sessionID >> 8), byte(sessionID), ws.OpInput}, "exit 3\n"...)
+    if err := conn.Write(context.Background(), websocket.MessageBinary, frame); err != nil {
+        t.Fatal(err)
+    }
+    exited := env.WaitForEvent(t, conn, "terminalExited")
+    if exited["sessionId"] != resp["sessionId"] || exited["exitCode"] != float64(3) {
+        t.Errorf("unexpected terminalExited %v for %v", exited, resp)
+    }
+}
+
+func TestOrphans(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    ctx := context.Background()
+    for name, project := range map[string]string{"ghost_default": "ghost", "test-stack_extra": "test-stack"} {
+        if _, err := env.App.Docker.NetworkCreate(ctx, docker.NetworkCreateOptions{
+            Name:   name,
+            Labels: map[string]string{"com.docker.compose.project": project},
+        }); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    resp := env.SendAndReceive(t, conn, "getOrphans")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("getOrphans failed: %v", resp)
+    }
+    var names []string
+    networks, _ := resp["networks"].([]interface{})
+    for _, n := range networks {
+        names = append(names, n.(map[string]interface{})["name"].(string))
+    }
+    if !slices.Contains(names, "ghost_default") || slices.Contains(names, "test-stack_extra") {
+        t.Errorf("unexpected orphaned networks %v", names)
+    }
+
+    resp = env.SendAndReceive(t, conn, "removeOrphans", map[string]interface{}{
+        "networks": []string{"ghost_default", "test-stack_extra"},
+    })
+    results, _ := resp["results"].([]interface{})
+    if ok, _ := resp["ok"].(bool); !ok || len(results) != 2 {
+        t.Fatalf("removeOrphans failed: %v", resp)
+    }
+    if r := results[0].(map[string]interface{}); r["error"] != nil {
+        t.Errorf("ghost_default not removed: %v", r)
+    }
+    if r := results[1].(map[string]interface{}); r["error"] != "not orphaned" {
+        t.Errorf("test-stack_extra should have been skipped: %v", r)
+    }
+    if list, _ := env.App.Docker.NetworkList(ctx); slices.ContainsFunc(list, func(n docker.NetworkSummary) bool { return n.Name == "ghost_default" }) {
+        t.Error("ghost_default still exists")
+    }
+}
+
+func TestStackDependencyOrder(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    stacks := map[string]string{
+        "order-db":  "services:\n  db:\n    image: postgres:16\n",
+        "order-app": "services:\n  app:\n    image: nginx:latest\n    labels:\n      dockge.depends_on: order-db, order-missing\n",
+    }
+    for name, yaml := range stacks {
+        dir := filepath.Join(env.StacksDir, name)
+        if err := os.MkdirAll(dir, 0755); err != nil {
+            t.Fatal(err)
+        }
+        if err := os.WriteFile(filepath.Join(dir, "compose.yaml"), []byte(yaml), 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    resp := env.SendAndReceive(t, conn, "getStackDependencies")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("getStackDependencies failed: %v", resp)
+    }
+    missing, _ := resp["missing"].(map[string]interface{})
+    if m, _ := missing["order-app"].([]interface{}); len(m) != 1 || m[0] != "order-missing" {
+        t.Errorf("missing = %v, want order-app -> [order-missing]", missing)
+    }
+    waveOf := func(name string) int {
+        waves, _ := resp["waves"].([]interface{})
+        for i, w := range waves {
+            for _, n := range w.([]interface{}) {
+                if n == name {
+                    return i
+                }
+            }
+        }
+        t.Fatalf("%s not in waves %v", name, resp["waves"])
+        return -1
+    }
+    if waveOf("order-db") >= waveOf("order-app") {
+        t.Errorf("order-db should come before order-app: %v", resp["waves"])
+    }
+
+    // Stop everything but the two stacks: the dependent stops first
+    var exclude []string
+    entries, _ := os.ReadDir(env.StacksDir)
+    for _, e := range entries {
+        if e.IsDir() && e.Name() != "order-db" && e.Name() != "order-app" {
+            exclude = append(exclude, e.Name())
+        }
+    }
+    resp = env.SendAndReceive(t, conn, "stopAllStacks", map[string]interface{}{"exclude": exclude})
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("stopAllStacks failed: %v", resp)
+    }
+
+    done := env.WaitForEvent(t, conn, "bulkStacksDone")
+    var order []string
+    results, _ := done["results"].([]interface{})
+    for _, r := range results {
+        r := r.(map[string]interface{})
+        if r["status"] == "skipped" {
+            continue
+        }
+        if r["status"] != "done" {
+            t.Errorf("%v: %v", r["stackName"], r)
+        }
+        order = append(order, r["stackName"].(string))
+    }
+    if !slices.Equal(order, []string{"order-app", "order-db"}) {
+        t.Errorf("stop order = %v, want [order-app order-db]", order)
+    }
+}
+
+func TestWSStats(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+    env.SendAndReceive(t, conn, "getSettings")
+
+    resp := env.SendAndReceive(t, conn, "getWSStats")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("getWSStats failed: %v", resp)
+    }
+    self, _ := resp["self"].(string)
+    var found bool
+    conns, _ := resp["connections"].([]interface{})
+    for _, c := range conns {
+        c := c.(map[string]interface{})
+        if c["id"] != self {
+            continue
+        }
+        found = true
+        if c["username"] != "admin" {
+            t.Errorf("username = %v, want admin", c["username"])
+        }
+        if n, _ := c["messagesIn"].(float64); n < 3 {
+            t.Errorf("messagesIn = %v, want at least 3", c["messagesIn"])
+        }
+    }
+    if !found {
+        t.Errorf("own connection %q not listed in %v", self, conns)
+    }
+    handlers, _ := resp["handlers"].(map[string]interface{})
+    if h, _ := handlers["login"].(map[string]interface{}); h == nil || h["count"] != float64(1) {
+        t.Errorf("login handler timing = %v", handlers["login"])
+    }
+}
+
+func TestTerminalAdmin(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    term := env.App.Terms.Create("compose-leaked", terminal.TypePipe)
+    term.Write([]byte("hello"))
+
+    resp := env.SendAndReceive(t, conn, "getTerminals")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("getTerminals failed: %v", resp)
+    }
+    var found bool
+    terms, _ := resp["terminals"].([]interface{})
+    for _, info := range terms {
+        info := info.(map[string]interface{})
+        if info["name"] != "compose-leaked" {
+            continue
+        }
+        found = true
+        if info["bytesWritten"] != float64(5) {
+            t.Errorf("bytesWritten = %v, want 5", info["bytesWritten"])
+        }
+        if info["policy"] != "compose-" {
+            t.Errorf("policy = %v, want compose-", info["policy"])
+        }
+    }
+    if !found {
+        t.Fatalf("compose-leaked not listed in %v", terms)
+    }
+    if policies, _ := resp["policies"].([]interface{}); len(policies) == 0 {
+        t.Error("expected cleanup policies")
+    }
+
+    resp = env.SendAndReceive(t, conn, "closeTerminal", "compose-leaked")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("closeTerminal failed: %v", resp)
+    }
+    if env.App.Terms.Get("compose-leaked") != nil {
+        t.Error("terminal still registered after closeTerminal")
+    }
+
+    resp = env.SendAndReceive(t, conn, "closeTerminal", "compose-leaked")
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Error("closing a missing terminal should fail")
+    }
+}
+
+func TestStackGroups(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    create := func(name string) int {
+        t.Helper()
+        resp := env.SendAndReceive(t, conn, "createStackGroup", name)
+        if ok, _ := resp["ok"].(bool); !ok {
+            t.Fatalf("createStackGroup(%s) failed: %v", name, resp)
+        }
+        return int(resp["group"].(map[string]interface{})["id"].(float64))
+    }
+    apps := create("Apps")
+    other := create("Other")
+    if resp := env.SendAndReceive(t, conn, "createStackGroup", "apps"); resp["ok"] == true {
+        t.Error("duplicate group name accepted")
+    }
+
+    resp := env.SendAndReceive(t, conn, "updateStackGroup", other, map[string]interface{}{
+        "name": "Other", "stacks": []string{"test-stack"},
+    })
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("updateStackGroup failed: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "updateStackGroup", apps, map[string]interface{}{
+        "name": "Apps", "stacks": []string{"test-stack"},
+    })
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("updateStackGroup failed: %v", resp)
+    }
+    env.SendAndReceive(t, conn, "reorderStackGroups", []int{other, apps})
+
+    resp = env.SendAndReceive(t, conn, "getStackGroups")
+    groups, _ := resp["groups"].([]interface{})
+    if len(groups) != 2 {
+        t.Fatalf("groups = %v", resp)
+    }
+    first, second := groups[0].(map[string]interface{}), groups[1].(map[string]interface{})
+    if first["name"] != "Other" || len(first["stacks"].([]interface{})) != 0 {
+        t.Errorf("first group = %v, want Other without stacks", first)
+    }
+    if second["name"] != "Apps" || len(second["stacks"].([]interface{})) != 1 {
+        t.Errorf("second group = %v, want Apps with test-stack", second)
+    }
+
+    resp = env.SendAndReceive(t, conn, "stackGroupAction", apps, "stop")
+    plan, _ := resp["plan"].([]interface{})
+    if ok, _ := resp["ok"].(bool); !ok || len(plan) != 1 {
+        t.Fatalf("stackGroupAction failed: %v", resp)
+    }
+    done := env.WaitForEvent(t, conn, "bulkStacksDone")
+    results, _ := done["results"].([]interface{})
+    if done["group"] != float64(apps) || len(results) != 1 || results[0].(map[string]interface{})["status"] != "done" {
+        t.Errorf("bulkStacksDone = %v", done)
+    }
+
+    env.SendAndReceive(t, conn, "deleteStackGroup", apps)
+    resp = env.SendAndReceive(t, conn, "getStackGroups")
+    if groups, _ := resp["groups"].([]interface{}); len(groups) != 1 {
+        t.Errorf("groups after delete = %v", resp)
+    }
+}
+
+func TestGetResolvedCompose(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    resp := env.SendAndReceive(t, conn, "getResolvedCompose", "test-stack", []string{"*"})
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("getResolvedCompose failed: %v", resp)
+    }
+    yaml, _ := resp["yaml"].(string)
+    if !strings.Contains(yaml, "services:") {
+        t.Errorf("expected the rendered services, got %q", yaml)
+    }
+    if _, ok := resp["warnings"].([]interface{}); !ok {
+        t.Errorf("expected a warnings list: %v", resp)
+    }
+
+    resp = env.SendAndReceive(t, conn, "getResolvedCompose", "../etc")
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Errorf("expected an invalid stack name to be rejected: %v", resp)
+    }
+}
+
+func TestStackJobs(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    dir := filepath.Join(env.StacksDir, "job-stack")
+    if err := os.MkdirAll(dir, 0755); err != nil {
+        t.Fatal(err)
+    }
+    composeYAML := "services:\n  web:\n    image: nginx:latest\n  backup:\n    image: alpine:latest\n    restart: \"no\"\n"
+    if err := os.WriteFile(filepath.Join(dir, "compose.yaml"), []byte(composeYAML), 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    resp := env.SendAndReceive(t, conn, "getStackJobs", "job-stack")
+    jobs, _ := resp["jobs"].([]interface{})
+    if ok, _ := resp["ok"].(bool); !ok || len(jobs) != 1 {
+        t.Fatalf("expected one job: %v", resp)
+    }
+    if job, _ := jobs[0].(map[string]interface{}); job["service"] != "backup" || job["container"] != nil {
+        t.Errorf("expected the backup job, never run: %v", jobs[0])
+    }
+
+    resp = env.SendAndReceive(t, conn, "runJobService", "job-stack", "web")
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Errorf("expected a non-job service to be rejected: %v", resp)
+    }
+}
+
+func TestStartServices(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    resp := env.SendAndReceive(t, conn, "getServiceSelection", "test-stack")
+    services, _ := resp["services"].([]interface{})
+    if ok, _ := resp["ok"].(bool); !ok || len(services) != 2 || services[0] != "redis" || resp["selection"] != nil {
+        t.Fatalf("expected both services and no selection: %v", resp)
+    }
+
+    resp = env.SendAndReceive(t, conn, "startServices", "test-stack", []string{"web", "exporter"})
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Errorf("expected an unknown service to be rejected: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "startServices", "test-stack", []string{})
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Errorf("expected an empty selection to be rejected: %v", resp)
+    }
+
+    resp = env.SendAndReceive(t, conn, "startServices", "test-stack", []string{"web"})
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("startServices failed: %v", resp)
+    }
+
+    resp = env.SendAndReceive(t, conn, "getServiceSelection", "test-stack")
+    sel, _ := resp["selection"].(map[string]interface{})
+    selected, _ := sel["services"].([]interface{})
+    if len(selected) != 1 || selected[0] != "web" {
+        t.Errorf("expected the selection to be remembered: %v", resp)
+    }
+}
+
+func TestGetRebootReport(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    resp := env.SendAndReceive(t, conn, "getRebootReport")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("getRebootReport failed: %v", resp)
+    }
+    if resp["report"] != nil || resp["pending"] != false {
+        t.Errorf("expected no report before the first snapshot, got %v", resp)
+    }
+
+    if err := env.App.HostState.SetRebootReport(&models.RebootReport{Rebooted: true, Failed: 1, Stacks: []models.StackRecovery{{Stack: "test-stack", Status: "down", Missing: []string{"web"}}}}); err != nil {
+        t.Fatal(err)
+    }
+    resp = env.SendAndReceive(t, conn, "getRebootReport")
+    report, _ := resp["report"].(map[string]any)
+    if report == nil || report["rebooted"] != true || report["failed"] != float64(1) {
+        t.Errorf("report = %v", resp["report"])
+    }
+}
+
+func TestStackFiles(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    resp := env.SendAndReceive(t, conn, "saveStackFile", "test-stack", "conf/nginx.conf", "server {}\n")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("saveStackFile failed: %v", resp)
+    }
+    data, err := os.ReadFile(filepath.Join(env.StacksDir, "test-stack", "conf", "nginx.conf"))
+    if err != nil || string(data) != "server {}\n" {
+        t.Fatalf("nginx.conf = %q, %v", data, err)
+    }
+
+    resp = env.SendAndReceive(t, conn, "listStackFiles", "test-stack", "conf")
+    entries, _ := resp["entries"].([]any)
+    if len(entries) != 1 {
+        t.Fatalf("entries = %v", resp)
+    }
+
+    resp = env.SendAndReceive(t, conn, "renameStackFile", "test-stack", "conf/nginx.conf", "conf/default.conf")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("renameStackFile failed: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "getStackFile", "test-stack", "conf/default.conf")
+    if resp["content"] != "server {}\n" {
+        t.Errorf("getStackFile = %v", resp)
+    }
+
+    // Paths can't climb out of the stack, and the compose file is left to saveStack
+    resp = env.SendAndReceive(t, conn, "getStackFile", "test-stack", "../test-stack/compose.yaml")
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Error("expected getStackFile of the compose file to fail")
+    }
+    resp = env.SendAndReceive(t, conn, "saveStackFile", "test-stack", "../escape.conf", "x")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("saveStackFile failed: %v", resp)
+    }
+    if _, err := os.Stat(filepath.Join(env.StacksDir, "escape.conf")); err == nil {
+        t.Error("file written outside the stack directory")
+    }
+
+    resp = env.SendAndReceive(t, conn, "deleteStackFile", "test-stack", "conf/default.conf")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("deleteStackFile failed: %v", resp)
+    }
+    if _, err := os.Stat(filepath.Join(env.StacksDir, "test-stack", "conf", "default.conf")); !os.IsNotExist(err) {
+        t.Errorf("expected the file to be deleted, got %v", err)
+    }
+}
+
+func TestGraphQLAPI(t *testing.T) {
+    env := testutil.Setup(t)
+    env.SeedAdmin(t)
+
+    conn := env.DialWS(t)
+    env.Login(t, conn)
+
+    query := func(token string) *http.Response {
+        t.Helper()
+        req, err := http.NewRequest(http.MethodPost, env.Server.URL+"/api/graphql", strings.NewReader(`{"query":"{ stacks { name } }"}`))
+        if err != nil {
+            t.Fatal(err)
+        }
+        req.Header.Set("Content-Type", "application/json")
+        if token != "" {
+            req.Header.Set("Authorization", "Bearer "+token)
+        }
+        res, err := http.DefaultClient.Do(req)
+        if err != nil {
+            t.Fatal(err)
+        }
+        t.Cleanup(func() { res.Body.Close() })
+        return res
+    }
+
+    // Off until enabled
+    if res := query(""); res.StatusCode != http.StatusNotFound {
+        t.Errorf("disabled API: got %d, want 404", res.StatusCode)
+    }
+    if err := env.App.Settings.Set("graphqlEnabled", "1"); err != nil {
+        t.Fatal(err)
+    }
+    if res := query("dkg_wrong"); res.StatusCode != http.StatusUnauthorized {
+        t.Errorf("wrong token: got %d, want 401", res.StatusCode)
+    }
+
+    // Tokens are issued with sudo
+    resp := env.SendAndReceive(t, conn, "createAPIToken", map[string]any{"name": "dashboard", "scopes": []string{"stacks"}})
+    if msg, _ := resp["msg"].(string); msg != "sudoRequired" {
+        t.Fatalf("expected createAPIToken to require sudo, got %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "sudo", "testpass123")
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("sudo failed: %v", resp)
+    }
+    resp = env.SendAndReceive(t, conn, "createAPIToken", map[string]any{"name": "dashboard", "scopes": []string{"stacks", "volumes"}})
+    if ok, _ := resp["ok"].(bool); ok {
+        t.Error("createAPIToken accepted an unknown scope")
+    }
+    resp = env.SendAndReceive(t, conn, "createAPIToken", map[string]any{"name": "dashboard", "scopes": []string{"stacks"}})
+    token, _ := resp["token"].(string)
+    if ok, _ := resp["ok"].(bool); !ok || token == "" {
+        t.Fatalf("createAPIToken failed: %v", resp)
+    }
+
+    res := query(token)
+    var body struct {
+        Data struct {
+            Stacks []struct {
+                Name string `json:"name"`
+            } `json:"stacks"`
+        } `json:"data"`
+        Errors []any `json:"errors"`
+    }
+    if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+        t.Fatal(err)
+    }
+    if res.StatusCode != http.StatusOK || len(body.Errors) > 0 {
+        t.Errorf("query: got %d, %+v", res.StatusCode, body)
+    }
+
+    resp = env.SendAndReceive(t, conn, "getAPITokenList")
+    tokens, _ := resp["tokens"].([]any)
+    if len(tokens) != 1 {
+        t.Fatalf("getAPITokenList = %v", resp)
+    }
+    id := tokens[0].(map[string]any)["id"]
+    resp = env.SendAndReceive(t, conn, "deleteAPIToken", id)
+    if ok, _ := resp["ok"].(bool); !ok {
+        t.Fatalf("deleteAPIToken failed: %v", resp)
+    }
+    if res := query(token); res.StatusCode != http.StatusUnauthorized {
+        t.Errorf("deleted token: got %d, want 401", res.StatusCode)
+    }
+}
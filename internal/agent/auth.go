@@ -0,0 +1,311 @@
+package agent
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+// The link is authenticated both ways without the token crossing the wire.
+// Both ends key it with KeyOf(token): the agent knows the token, the
+// controller stores its hash. The agent's link request carries a nonce, a
+// timestamp and a proof of the key; the controller answers with its own
+// nonce and proof. Each direction then signs its messages with a key derived
+// from the two nonces, so a recorded link can't be replayed or spliced.
+const (
+	headerNonce           = "X-Dockge-Agent-Nonce"
+	headerTime            = "X-Dockge-Agent-Time"
+	headerProof           = "X-Dockge-Agent-Proof"
+	headerControllerNonce = "X-Dockge-Controller-Nonce"
+	headerControllerProof = "X-Dockge-Controller-Proof"
+
+	// helloMaxSkew bounds how old (or early) a link request may be.
+	helloMaxSkew = 5 * time.Minute
+
+	nonceLength = 16
+
+	// sealOverhead is what signing adds to a message: its type and
+	// sequence number in front, the MAC behind.
+	sealOverhead = 1 + 8 + sha256.Size
+)
+
+var (
+	// ErrBadHello is returned for a link request without a valid handshake.
+	ErrBadHello = errors.New("agent: malformed or stale handshake")
+	// ErrReplayed is returned for a link request whose nonce was seen before.
+	ErrReplayed = errors.New("agent: replayed handshake")
+	// ErrBadSignature ends a link on a message that fails verification.
+	ErrBadSignature = errors.New("agent: bad message signature")
+)
+
+// KeyOf returns the link key of an agent token. It's the hash the
+// controller stores for the token.
+func KeyOf(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func mac(key string, parts ...string) []byte {
+	m := hmac.New(sha256.New, []byte(key))
+	for _, p := range parts {
+		m.Write([]byte(p))
+		m.Write([]byte{'\n'})
+	}
+	return m.Sum(nil)
+}
+
+func newNonce() (string, error) {
+	b := make([]byte, nonceLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Hello is the handshake of an agent's link request.
+type Hello struct {
+	nonce string
+	time  string
+	proof []byte
+}
+
+// ReadHello parses the handshake of a link request. It fails if the request
+// is malformed or outside the allowed clock skew.
+func ReadHello(r *http.Request) (*Hello, error) {
+	h := &Hello{nonce: r.Header.Get(headerNonce), time: r.Header.Get(headerTime)}
+	proof, err := hex.DecodeString(r.Header.Get(headerProof))
+	if err != nil || len(proof) != sha256.Size || len(h.nonce) != 2*nonceLength {
+		return nil, ErrBadHello
+	}
+	ts, err := strconv.ParseInt(h.time, 10, 64)
+	if err != nil {
+		return nil, ErrBadHello
+	}
+	if skew := time.Since(time.Unix(ts, 0)); skew > helloMaxSkew || skew < -helloMaxSkew {
+		return nil, ErrBadHello
+	}
+	h.proof = proof
+	return h, nil
+}
+
+// Verify reports whether the agent proved it holds key.
+func (h *Hello) Verify(key string) bool {
+	return hmac.Equal(h.proof, mac(key, "agent", h.nonce, h.time))
+}
+
+// nonceCache remembers handshake nonces for as long as they'd be accepted.
+type nonceCache struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// fresh records a nonce and reports whether it's new.
+func (n *nonceCache) fresh(nonce string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	now := time.Now()
+	for k, at := range n.seen {
+		if now.Sub(at) > 2*helloMaxSkew {
+			delete(n.seen, k)
+		}
+	}
+	if _, ok := n.seen[nonce]; ok {
+		return false
+	}
+	if n.seen == nil {
+		n.seen = make(map[string]time.Time)
+	}
+	n.seen[nonce] = now
+	return true
+}
+
+// Accept completes the handshake of a link request verified with key and
+// upgrades it to a signed link.
+func (h *Hub) Accept(w http.ResponseWriter, r *http.Request, hello *Hello, key string) (*Conn, error) {
+	if !h.nonces.fresh(hello.nonce) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil, ErrReplayed
+	}
+	nonce, err := newNonce()
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return nil, err
+	}
+	w.Header().Set(headerControllerNonce, nonce)
+	w.Header().Set(headerControllerProof, hex.EncodeToString(mac(key, "controller", hello.nonce, nonce)))
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return nil, err
+	}
+	return newConn(conn, key, hello.nonce, nonce, false), nil
+}
+
+// dial opens a signed link to the controller.
+func dial(ctx context.Context, cfg *Config) (*Conn, error) {
+	client, err := cfg.httpClient()
+	if err != nil {
+		return nil, err
+	}
+	key := KeyOf(cfg.Token)
+	nonce, err := newNonce()
+	if err != nil {
+		return nil, err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	header := http.Header{}
+	header.Set(headerNonce, nonce)
+	header.Set(headerTime, ts)
+	header.Set(headerProof, hex.EncodeToString(mac(key, "agent", nonce, ts)))
+
+	conn, resp, err := websocket.Dial(ctx, cfg.ControllerURL, &websocket.DialOptions{
+		HTTPClient: client,
+		HTTPHeader: header,
+	})
+	if err != nil {
+		return nil, err
+	}
+	theirs := resp.Header.Get(headerControllerNonce)
+	proof, _ := hex.DecodeString(resp.Header.Get(headerControllerProof))
+	if len(theirs) != 2*nonceLength || !hmac.Equal(proof, mac(key, "controller", nonce, theirs)) {
+		conn.Close(websocket.StatusPolicyViolation, "")
+		return nil, errors.New("agent: controller failed to prove the key")
+	}
+	return newConn(conn, key, nonce, theirs, true), nil
+}
+
+// Conn is a link whose messages are signed. Every message goes out as a
+// binary WebSocket message: its type, a sequence number, the payload and an
+// HMAC over all three. Messages out of sequence or with a bad MAC end the
+// link.
+type Conn struct {
+	ws   *websocket.Conn
+	key  string // the link key, e.g. to tell which token an agent used
+	send []byte
+	recv []byte
+	seal []byte // encrypts rekeyed tokens
+
+	mu      sync.Mutex // orders sequence numbers with writes
+	sendSeq uint64
+	recvSeq uint64 // only touched by the reader
+}
+
+func newConn(c *websocket.Conn, key, agentNonce, controllerNonce string, agent bool) *Conn {
+	toController := mac(key, "agent->controller", agentNonce, controllerNonce)
+	toAgent := mac(key, "controller->agent", agentNonce, controllerNonce)
+	s := &Conn{ws: c, key: key, seal: mac(key, "rekey", agentNonce, controllerNonce)}
+	if agent {
+		s.send, s.recv = toController, toAgent
+	} else {
+		s.send, s.recv = toAgent, toController
+	}
+	c.SetReadLimit(linkReadLimit + sealOverhead)
+	return s
+}
+
+// Key returns the key the link was authenticated with.
+func (s *Conn) Key() string { return s.key }
+
+func sign(key []byte, typ byte, seq uint64, data []byte) []byte {
+	m := hmac.New(sha256.New, key)
+	var head [9]byte
+	head[0] = typ
+	binary.BigEndian.PutUint64(head[1:], seq)
+	m.Write(head[:])
+	m.Write(data)
+	return m.Sum(nil)
+}
+
+// Write signs and sends a message.
+func (s *Conn) Write(ctx context.Context, typ websocket.MessageType, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sendSeq++
+	buf := make([]byte, 0, len(data)+sealOverhead)
+	buf = append(buf, byte(typ))
+	buf = binary.BigEndian.AppendUint64(buf, s.sendSeq)
+	buf = append(buf, data...)
+	buf = append(buf, sign(s.send, byte(typ), s.sendSeq, data)...)
+	return s.ws.Write(ctx, websocket.MessageBinary, buf)
+}
+
+// Read returns the next verified message.
+func (s *Conn) Read(ctx context.Context) (websocket.MessageType, []byte, error) {
+	_, buf, err := s.ws.Read(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(buf) < sealOverhead {
+		return 0, nil, s.reject()
+	}
+	typ := buf[0]
+	seq := binary.BigEndian.Uint64(buf[1:9])
+	data := buf[9 : len(buf)-sha256.Size]
+	if seq != s.recvSeq+1 || !hmac.Equal(buf[len(buf)-sha256.Size:], sign(s.recv, typ, seq, data)) {
+		return 0, nil, s.reject()
+	}
+	s.recvSeq = seq
+	return websocket.MessageType(typ), data, nil
+}
+
+func (s *Conn) reject() error {
+	s.ws.Close(websocket.StatusPolicyViolation, "bad signature")
+	return ErrBadSignature
+}
+
+// Close ends the link.
+func (s *Conn) Close(code websocket.StatusCode, reason string) error {
+	return s.ws.Close(code, reason)
+}
+
+// CloseNow ends the link without a closing handshake.
+func (s *Conn) CloseNow() error { return s.ws.CloseNow() }
+
+// sealToken encrypts a rotated token for the other end of the link.
+func (s *Conn) sealToken(token string) ([]byte, error) {
+	aead, err := s.aead()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, []byte(token), nil), nil
+}
+
+// openToken decrypts a token sealed by the other end of the link.
+func (s *Conn) openToken(sealed []byte) (string, error) {
+	aead, err := s.aead()
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("sealed token too short")
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+func (s *Conn) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(s.seal)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
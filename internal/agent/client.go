@@ -0,0 +1,317 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// Backoff between attempts to reach the controller.
+const (
+	minRetry = time.Second
+	maxRetry = 30 * time.Second
+)
+
+// Config configures agent mode.
+type Config struct {
+	ControllerURL string // ws(s)://controller/agent
+	Token         string // issued by the controller when the agent was added
+
+	// TokenFile keeps the token the controller rotated to, which replaces
+	// Token for as long as Token is the one it was rotated from. "" keeps
+	// rotated tokens in memory only.
+	TokenFile string
+
+	// Fingerprint pins the controller's TLS certificate: the hex SHA-256
+	// of its DER encoding, colons allowed. A pinned certificate is trusted
+	// without a CA, so self-signed controllers work.
+	Fingerprint string
+
+	// CertFile and KeyFile are a client certificate for mutual TLS, e.g.
+	// when a proxy in front of the controller requires one.
+	CertFile string
+	KeyFile  string
+}
+
+// httpClient returns the client that dials the controller, or nil for the
+// default one.
+func (cfg *Config) httpClient() (*http.Client, error) {
+	if cfg.Fingerprint == "" && cfg.CertFile == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(cfg.ControllerURL, "wss://") {
+		return nil, errors.New("agent: certificate pinning and client certificates need a wss:// controller URL")
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("agent: load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if cfg.Fingerprint != "" {
+		pin, err := hex.DecodeString(strings.ReplaceAll(cfg.Fingerprint, ":", ""))
+		if err != nil || len(pin) != sha256.Size {
+			return nil, errors.New("agent: controller fingerprint must be a hex SHA-256")
+		}
+		// The pin replaces chain verification, which would reject
+		// self-signed certificates before VerifyConnection runs
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+			return verifyPin(cs.PeerCertificates, pin)
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport}, nil
+}
+
+// verifyPin checks the leaf certificate against a pinned SHA-256.
+func verifyPin(certs []*x509.Certificate, pin []byte) error {
+	if len(certs) == 0 {
+		return errors.New("agent: controller presented no certificate")
+	}
+	sum := sha256.Sum256(certs[0].Raw)
+	if !bytes.Equal(sum[:], pin) {
+		return fmt.Errorf("agent: controller certificate %x doesn't match the pinned fingerprint", sum)
+	}
+	return nil
+}
+
+// storedToken is the content of Config.TokenFile.
+type storedToken struct {
+	From  string `json:"from"` // KeyOf the configured token it replaces
+	Token string `json:"token"`
+}
+
+// LoadToken returns the token to dial with: the rotated one in
+// cfg.TokenFile if it was rotated from cfg.Token, else cfg.Token. A new
+// token from the controller's admin thus overrides an old rotation.
+func LoadToken(cfg Config) string {
+	if cfg.TokenFile == "" {
+		return cfg.Token
+	}
+	data, err := os.ReadFile(cfg.TokenFile)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("agent: read token file", "err", err)
+		}
+		return cfg.Token
+	}
+	var stored storedToken
+	if err := json.Unmarshal(data, &stored); err != nil || stored.Token == "" {
+		slog.Warn("agent: bad token file", "err", err, "path", cfg.TokenFile)
+		return cfg.Token
+	}
+	if stored.From != KeyOf(cfg.Token) {
+		return cfg.Token
+	}
+	return stored.Token
+}
+
+// saveToken persists a rotated token, replacing the file atomically.
+func saveToken(path, configured, token string) error {
+	data, err := json.Marshal(storedToken{From: KeyOf(configured), Token: token})
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".agent-token-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// OpenFunc prepares the connection of a new channel for a controller user:
+// authenticating it and sending the initial state.
+type OpenFunc func(c *ws.Conn, user string)
+
+// Run keeps the agent connected to its controller until ctx is cancelled,
+// reconnecting with backoff. Each channel the controller opens becomes a
+// connection attached to server.
+func Run(ctx context.Context, cfg Config, server *ws.Server, open OpenFunc) {
+	// The token the admin configured; cfg.Token follows rotations
+	configured := cfg.Token
+	cfg.Token = LoadToken(cfg)
+	delay := minRetry
+	for {
+		start := time.Now()
+		err := serveController(ctx, &cfg, configured, server, open)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(start) > maxRetry {
+			delay = minRetry
+		}
+		slog.Warn("agent: controller link lost", "err", err, "url", cfg.ControllerURL, "retry", delay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRetry)
+	}
+}
+
+// serveController runs one link to the controller until it fails.
+func serveController(ctx context.Context, cfg *Config, configured string, server *ws.Server, open OpenFunc) error {
+	conn, err := dial(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer conn.CloseNow()
+	slog.Info("agent: connected to controller", "url", cfg.ControllerURL)
+
+	s := &agentLink{conn: conn, cfg: cfg, configured: configured, server: server, open: open, chans: make(map[uint32]*ws.Conn)}
+	defer s.closeAll()
+	for {
+		typ, data, err := conn.Read(ctx)
+		if err != nil {
+			return err
+		}
+		s.handle(ctx, typ, data)
+	}
+}
+
+// agentLink is the agent's end of a link.
+type agentLink struct {
+	conn       *Conn
+	cfg        *Config
+	configured string // the token from the command line or environment
+	server     *ws.Server
+	open       OpenFunc
+
+	mu    sync.Mutex
+	chans map[uint32]*ws.Conn
+}
+
+func (s *agentLink) handle(ctx context.Context, typ websocket.MessageType, data []byte) {
+	if typ == websocket.MessageBinary {
+		ch, inner, ok := splitBinary(data)
+		if c := s.get(ch); ok && c != nil {
+			c.Receive(websocket.MessageBinary, inner)
+		}
+		return
+	}
+
+	var f frame
+	if err := json.Unmarshal(data, &f); err != nil {
+		slog.Warn("agent: bad frame", "err", err)
+		return
+	}
+	switch f.Op {
+	case opOpen:
+		s.openChannel(f.Ch, f.User)
+	case opMsg:
+		c := s.get(f.Ch)
+		if c == nil {
+			// The channel ended here first; let the controller know
+			writeFrame(ctx, s.conn, frame{Ch: f.Ch, Op: opClose})
+			return
+		}
+		// The controller checked the user's sudo mode; mirror it
+		var until time.Time
+		if f.Sudo > 0 {
+			until = time.Now().Add(time.Duration(f.Sudo) * time.Millisecond)
+		}
+		c.Elevate(until)
+		c.Receive(websocket.MessageText, f.Msg)
+	case opClose:
+		if c := s.get(f.Ch); c != nil {
+			c.Close()
+		}
+	case opRekey:
+		s.rekey(ctx, f.Key)
+	}
+}
+
+// rekey switches to a token rotated by the controller. The controller drops
+// the link once the token is stored, and the next one uses it.
+func (s *agentLink) rekey(ctx context.Context, sealed []byte) {
+	reply := frame{Op: opRekeyed}
+	token, err := s.conn.openToken(sealed)
+	if err == nil && s.cfg.TokenFile == "" {
+		// A token only held in memory would be lost on restart
+		err = errors.New("agent has no token file")
+	}
+	if err == nil {
+		err = saveToken(s.cfg.TokenFile, s.configured, token)
+	}
+	if err != nil {
+		slog.Error("agent: rotate token", "err", err)
+		reply.Err = err.Error()
+	} else {
+		s.cfg.Token = token
+		slog.Info("agent: token rotated")
+	}
+	writeFrame(ctx, s.conn, reply)
+}
+
+func (s *agentLink) openChannel(ch uint32, user string) {
+	if s.get(ch) != nil {
+		return
+	}
+	var c *ws.Conn
+	c = s.server.Attach(func(ctx context.Context, typ websocket.MessageType, data []byte) error {
+		if typ == websocket.MessageBinary {
+			return writeBinary(ctx, s.conn, ch, data)
+		}
+		return writeFrame(ctx, s.conn, frame{Ch: ch, Op: opMsg, Msg: data})
+	}, func() {
+		s.mu.Lock()
+		if s.chans[ch] == c {
+			delete(s.chans, ch)
+		}
+		s.mu.Unlock()
+		writeFrame(context.Background(), s.conn, frame{Ch: ch, Op: opClose})
+	})
+	s.mu.Lock()
+	s.chans[ch] = c
+	s.mu.Unlock()
+	slog.Debug("agent: channel opened", "ch", ch, "user", user)
+	s.open(c, user)
+}
+
+func (s *agentLink) get(ch uint32) *ws.Conn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.chans[ch]
+}
+
+func (s *agentLink) closeAll() {
+	s.mu.Lock()
+	conns := make([]*ws.Conn, 0, len(s.chans))
+	for _, c := range s.chans {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+	for _, c := range conns {
+		c.Close()
+	}
+}
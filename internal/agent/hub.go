@@ -0,0 +1,449 @@
+package agent
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// ErrLinkClosed is returned when forwarding to an agent that disconnected.
+var ErrLinkClosed = errors.New("agent disconnected")
+
+// Event is a push from an agent, relayed to the client as an "agent" event
+// so its data (e.g. the stack list) stays namespaced by endpoint.
+type Event struct {
+	Endpoint string          `json:"endpoint"`
+	Event    string          `json:"event"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// Hub tracks the agents connected to this controller.
+type Hub struct {
+	mu    sync.RWMutex
+	links map[string]*Link
+
+	// onChange is called when an agent connects or disconnects.
+	onChange func(endpoint string, online bool)
+
+	nonces nonceCache
+}
+
+// NewHub returns an empty hub. onChange may be nil.
+func NewHub(onChange func(endpoint string, online bool)) *Hub {
+	return &Hub{links: make(map[string]*Link), onChange: onChange}
+}
+
+// Serve runs an accepted agent link until it ends. A new link for an
+// endpoint replaces the previous one.
+func (h *Hub) Serve(ctx context.Context, endpoint string, conn *Conn) error {
+	l := &Link{
+		endpoint: endpoint,
+		conn:     conn,
+		byConn:   make(map[*ws.Conn]*channel),
+		byID:     make(map[uint32]*channel),
+		rekeyed:  make(chan string, 1),
+	}
+
+	h.mu.Lock()
+	old := h.links[endpoint]
+	h.links[endpoint] = l
+	h.mu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+	slog.Info("agent connected", "endpoint", endpoint)
+	h.notify(endpoint, true)
+
+	err := l.run(ctx)
+
+	h.mu.Lock()
+	current := h.links[endpoint] == l
+	if current {
+		delete(h.links, endpoint)
+	}
+	h.mu.Unlock()
+	l.shutdown()
+	if current {
+		slog.Info("agent disconnected", "endpoint", endpoint, "err", err)
+		h.notify(endpoint, false)
+	}
+	return err
+}
+
+func (h *Hub) notify(endpoint string, online bool) {
+	if h.onChange != nil {
+		h.onChange(endpoint, online)
+	}
+}
+
+// Link returns the link of a connected agent, or nil.
+func (h *Hub) Link(endpoint string) *Link {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.links[endpoint]
+}
+
+// Endpoints returns the connected agents, sorted.
+func (h *Hub) Endpoints() []string {
+	h.mu.RLock()
+	endpoints := make([]string, 0, len(h.links))
+	for endpoint := range h.links {
+		endpoints = append(endpoints, endpoint)
+	}
+	h.mu.RUnlock()
+	sort.Strings(endpoints)
+	return endpoints
+}
+
+// Disconnect closes an agent's link, e.g. after the agent was removed.
+func (h *Hub) Disconnect(endpoint string) {
+	if l := h.Link(endpoint); l != nil {
+		l.Close()
+	}
+}
+
+// Drop closes the channels of a client that disconnected.
+func (h *Hub) Drop(c *ws.Conn) {
+	h.mu.RLock()
+	links := make([]*Link, 0, len(h.links))
+	for _, l := range h.links {
+		links = append(links, l)
+	}
+	h.mu.RUnlock()
+	for _, l := range links {
+		l.Drop(c)
+	}
+}
+
+// Link is the controller's end of a connected agent. Each client talking to
+// the agent gets its own channel, i.e. its own connection on the agent.
+type Link struct {
+	endpoint string
+	conn     *Conn
+	rekeyed  chan string // the agent's answer to a rekey: "" or an error
+
+	mu     sync.Mutex
+	nextCh uint32
+	byConn map[*ws.Conn]*channel
+	byID   map[uint32]*channel
+	closed bool
+}
+
+// channel is a client's session on the agent. Terminal sessions are
+// allocated on both ends; the client only ever sees its local IDs.
+type channel struct {
+	id     uint32
+	client *ws.Conn
+
+	mu     sync.Mutex
+	joins  map[int64]bool    // pending terminalJoin and followStackLogs request IDs
+	remote map[uint16]uint16 // agent session ID → client session ID
+	local  map[uint16]uint16 // client session ID → agent session ID
+}
+
+// Endpoint returns the agent's endpoint.
+func (l *Link) Endpoint() string { return l.endpoint }
+
+// Key returns the key the agent authenticated the link with.
+func (l *Link) Key() string { return l.conn.Key() }
+
+// Close ends the link.
+func (l *Link) Close() {
+	l.conn.Close(websocket.StatusNormalClosure, "")
+}
+
+// Rekey hands the agent a new token and waits until it stored it. The link
+// keeps its current keys; the agent uses the token from its next link on.
+func (l *Link) Rekey(ctx context.Context, token string) error {
+	sealed, err := l.conn.sealToken(token)
+	if err != nil {
+		return err
+	}
+	// Drop a late answer to an earlier attempt
+	select {
+	case <-l.rekeyed:
+	default:
+	}
+	if err := writeFrame(ctx, l.conn, frame{Op: opRekey, Key: sealed}); err != nil {
+		return err
+	}
+	select {
+	case msg := <-l.rekeyed:
+		if msg != "" {
+			return errors.New("agent: " + msg)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Forward sends a client's request to the agent, opening the client's
+// channel first if needed. The agent answers with an ack to the same request
+// ID. sudo is how much longer the client's sudo mode lasts.
+func (l *Link) Forward(c *ws.Conn, user string, sudo time.Duration, msg *ws.ClientMessage) error {
+	ch, err := l.channel(c, user)
+	if err != nil {
+		return err
+	}
+
+	switch msg.Event {
+	case "terminalJoin", "followStackLogs":
+		if msg.ID != nil {
+			ch.mu.Lock()
+			ch.joins[*msg.ID] = true
+			ch.mu.Unlock()
+		}
+	case "terminalLeave":
+		var args []ws.TerminalLeaveArgs
+		if err := json.Unmarshal(msg.Args, &args); err != nil || len(args) == 0 {
+			break
+		}
+		c.RemoveSession(args[0].SessionID)
+		ch.mu.Lock()
+		remoteID, ok := ch.local[args[0].SessionID]
+		delete(ch.local, args[0].SessionID)
+		delete(ch.remote, remoteID)
+		ch.mu.Unlock()
+		if !ok {
+			if msg.ID != nil {
+				ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true})
+			}
+			return nil
+		}
+		args[0].SessionID = remoteID
+		forwarded := *msg
+		forwarded.Args, _ = json.Marshal(args)
+		msg = &forwarded
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return writeFrame(context.Background(), l.conn, frame{
+		Ch:   ch.id,
+		Op:   opMsg,
+		Sudo: max(sudo.Milliseconds(), 0),
+		Msg:  data,
+	})
+}
+
+// Open opens the client's channel, on which the agent sends its initial
+// state. Forward opens it implicitly.
+func (l *Link) Open(c *ws.Conn, user string) error {
+	_, err := l.channel(c, user)
+	return err
+}
+
+// channel returns the client's channel, opening it if needed.
+func (l *Link) channel(c *ws.Conn, user string) (*channel, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil, ErrLinkClosed
+	}
+	if ch := l.byConn[c]; ch != nil {
+		return ch, nil
+	}
+	l.nextCh++
+	ch := &channel{
+		id:     l.nextCh,
+		client: c,
+		joins:  make(map[int64]bool),
+		remote: make(map[uint16]uint16),
+		local:  make(map[uint16]uint16),
+	}
+	if err := writeFrame(context.Background(), l.conn, frame{Ch: ch.id, Op: opOpen, User: user}); err != nil {
+		return nil, err
+	}
+	l.byConn[c] = ch
+	l.byID[ch.id] = ch
+	return ch, nil
+}
+
+// Drop closes a client's channel.
+func (l *Link) Drop(c *ws.Conn) {
+	l.mu.Lock()
+	ch := l.byConn[c]
+	if ch != nil {
+		l.remove(ch)
+	}
+	l.mu.Unlock()
+	if ch != nil {
+		writeFrame(context.Background(), l.conn, frame{Ch: ch.id, Op: opClose})
+	}
+}
+
+// remove forgets a channel and ends its terminal sessions on the client.
+// Called with l.mu held.
+func (l *Link) remove(ch *channel) {
+	delete(l.byConn, ch.client)
+	delete(l.byID, ch.id)
+	ch.mu.Lock()
+	local := ch.local
+	ch.local = make(map[uint16]uint16)
+	ch.remote = make(map[uint16]uint16)
+	ch.mu.Unlock()
+	for id := range local {
+		ch.client.RemoveSession(id)
+		ws.SendEvent(ch.client, "terminalExited", ws.TerminalExitedData{SessionID: id})
+	}
+}
+
+func (l *Link) get(id uint32) *channel {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.byID[id]
+}
+
+// run reads the link until it fails.
+func (l *Link) run(ctx context.Context) error {
+	for {
+		typ, data, err := l.conn.Read(ctx)
+		if err != nil {
+			return err
+		}
+		if typ == websocket.MessageBinary {
+			id, inner, ok := splitBinary(data)
+			if ch := l.get(id); ok && ch != nil {
+				l.relayBinary(ch, inner)
+			}
+			continue
+		}
+
+		var f frame
+		if err := json.Unmarshal(data, &f); err != nil {
+			slog.Warn("agent link: bad frame", "err", err, "endpoint", l.endpoint)
+			continue
+		}
+		if f.Op == opRekeyed {
+			select {
+			case l.rekeyed <- f.Err:
+			default:
+			}
+			continue
+		}
+		ch := l.get(f.Ch)
+		if ch == nil {
+			continue
+		}
+		switch f.Op {
+		case opMsg:
+			l.relay(ch, f.Msg)
+		case opClose:
+			l.mu.Lock()
+			l.remove(ch)
+			l.mu.Unlock()
+		}
+	}
+}
+
+// shutdown closes all channels after the link ended.
+func (l *Link) shutdown() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+	for _, ch := range l.byID {
+		l.remove(ch)
+	}
+}
+
+// relay passes a message from the agent to the client.
+func (l *Link) relay(ch *channel, raw json.RawMessage) {
+	var msg struct {
+		ID    *int64          `json:"id"`
+		Event string          `json:"event"`
+		Data  json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		slog.Warn("agent link: bad message", "err", err, "endpoint", l.endpoint)
+		return
+	}
+
+	switch {
+	case msg.Event == "" && msg.ID != nil:
+		ch.mu.Lock()
+		join := ch.joins[*msg.ID]
+		delete(ch.joins, *msg.ID)
+		ch.mu.Unlock()
+		data := msg.Data
+		if join {
+			data = l.mapJoin(ch, data)
+		}
+		ws.SendAck(ch.client, *msg.ID, data)
+	case msg.Event == "terminalExited":
+		var exited ws.TerminalExitedData
+		if json.Unmarshal(msg.Data, &exited) != nil {
+			return
+		}
+		ch.mu.Lock()
+		id, ok := ch.remote[exited.SessionID]
+		delete(ch.remote, exited.SessionID)
+		delete(ch.local, id)
+		ch.mu.Unlock()
+		if ok {
+			ch.client.RemoveSession(id)
+			ws.SendEvent(ch.client, "terminalExited", ws.TerminalExitedData{SessionID: id, ExitCode: exited.ExitCode})
+		}
+	case msg.Event != "":
+		ws.SendEvent(ch.client, "agent", Event{Endpoint: l.endpoint, Event: msg.Event, Data: msg.Data})
+	}
+}
+
+// mapJoin allocates a client session for a terminal joined on the agent and
+// rewrites the ack to carry it.
+func (l *Link) mapJoin(ch *channel, data json.RawMessage) json.RawMessage {
+	var res ws.TerminalJoinResponse
+	if err := json.Unmarshal(data, &res); err != nil || !res.OK {
+		return data
+	}
+	remoteID := res.SessionID
+	id := ch.client.AllocSession(&ws.TermSession{
+		TermName:    "agent:" + l.endpoint,
+		Interactive: true,
+		Forward: func(frame []byte) {
+			buf := make([]byte, 2+len(frame))
+			binary.BigEndian.PutUint16(buf, remoteID)
+			copy(buf[2:], frame)
+			writeBinary(context.Background(), l.conn, ch.id, buf)
+		},
+	})
+	ch.mu.Lock()
+	ch.remote[remoteID] = id
+	ch.local[id] = remoteID
+	ch.mu.Unlock()
+
+	res.SessionID = id
+	mapped, err := json.Marshal(&res)
+	if err != nil {
+		return data
+	}
+	return mapped
+}
+
+// relayBinary passes terminal output from the agent to the client.
+func (l *Link) relayBinary(ch *channel, data []byte) {
+	if len(data) < 2 {
+		return
+	}
+	ch.mu.Lock()
+	id, ok := ch.remote[binary.BigEndian.Uint16(data)]
+	ch.mu.Unlock()
+	if !ok {
+		return
+	}
+	buf := make([]byte, len(data))
+	binary.BigEndian.PutUint16(buf, id)
+	copy(buf[2:], data[2:])
+	ch.client.WriteBinary(buf)
+}
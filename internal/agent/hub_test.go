@@ -0,0 +1,407 @@
+package agent
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// captured is a frame sent to a fake controller client.
+type captured struct {
+	typ  websocket.MessageType
+	data []byte
+}
+
+// newAgentServer returns an agent-side server with a few test handlers.
+func newAgentServer() *ws.Server {
+	srv := ws.NewServer(false)
+	srv.Handle("whoami", func(c *ws.Conn, msg *ws.ClientMessage) {
+		ws.SendAck(c, *msg.ID, map[string]any{"uid": c.UserID(), "sudo": c.Elevated()})
+	})
+	srv.Handle("terminalJoin", func(c *ws.Conn, msg *ws.ClientMessage) {
+		id := c.AllocSession(&ws.TermSession{TermName: "t", Interactive: true})
+		ws.SendAck(c, *msg.ID, ws.TerminalJoinResponse{OK: true, SessionID: id})
+	})
+	srv.Handle("terminalLeave", func(c *ws.Conn, msg *ws.ClientMessage) {
+		var args []ws.TerminalLeaveArgs
+		json.Unmarshal(msg.Args, &args)
+		ws.SendAck(c, *msg.ID, args[0])
+	})
+	// Echo terminal input back as output
+	srv.OnBinary(func(c *ws.Conn, session *ws.TermSession, data []byte) {
+		buf := make([]byte, 2+len(data)-1)
+		binary.BigEndian.PutUint16(buf, 0)
+		copy(buf[2:], data[1:])
+		c.WriteBinary(buf)
+	})
+	return srv
+}
+
+func TestLink(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	online := make(chan bool, 4)
+	hub := NewHub(func(endpoint string, up bool) {
+		if endpoint == "nas" {
+			online <- up
+		}
+	})
+	ts := newController(hub, "secret")
+	defer ts.Close()
+
+	users := make(chan string, 1)
+	go Run(ctx, Config{ControllerURL: "ws" + strings.TrimPrefix(ts.URL, "http"), Token: "secret"}, newAgentServer(),
+		func(c *ws.Conn, user string) {
+			c.SetUser(1)
+			users <- user
+			ws.SendEvent(c, "stacks", map[string]int{"web": 1})
+		})
+	select {
+	case up := <-online:
+		if !up {
+			t.Fatal("expected agent online")
+		}
+	case <-ctx.Done():
+		t.Fatal("agent never connected")
+	}
+
+	// A controller client, with a local terminal session so local and
+	// agent session IDs differ
+	frames := make(chan captured, 16)
+	client := ws.NewServer(false).Attach(func(_ context.Context, typ websocket.MessageType, data []byte) error {
+		frames <- captured{typ, append([]byte(nil), data...)}
+		return nil
+	}, nil)
+	client.AllocSession(&ws.TermSession{TermName: "local"})
+
+	link := hub.Link("nas")
+	if link == nil {
+		t.Fatal("no link for nas")
+	}
+	next := func() captured {
+		t.Helper()
+		select {
+		case f := <-frames:
+			return f
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for a frame")
+			return captured{}
+		}
+	}
+	request := func(id int64, event, args string) map[string]json.RawMessage {
+		t.Helper()
+		if err := link.Forward(client, "alice", time.Minute, &ws.ClientMessage{ID: &id, Event: event, Args: json.RawMessage(args)}); err != nil {
+			t.Fatal(err)
+		}
+		for {
+			f := next()
+			var msg map[string]json.RawMessage
+			json.Unmarshal(f.data, &msg)
+			if string(msg["id"]) == itoa(id) {
+				var data map[string]json.RawMessage
+				json.Unmarshal(msg["data"], &data)
+				return data
+			}
+		}
+	}
+
+	// The first request opens the channel; the agent's pushes are relayed
+	// namespaced, its acks as they are
+	res := request(1, "whoami", "[]")
+	if got := <-users; got != "alice" {
+		t.Errorf("channel opened for %q", got)
+	}
+	if string(res["uid"]) != "1" || string(res["sudo"]) != "true" {
+		t.Errorf("whoami = %s", res)
+	}
+
+	// Terminal sessions get a client-side ID; frames are mapped both ways
+	res = request(2, "terminalJoin", `[{"type":"combined","stack":"web"}]`)
+	if string(res["ok"]) != "true" || string(res["sessionId"]) != "1" {
+		t.Fatalf("terminalJoin = %s", res)
+	}
+	client.Receive(websocket.MessageBinary, []byte{0, 1, 0x00, 'h', 'i'})
+	for {
+		f := next()
+		if f.typ == websocket.MessageBinary {
+			if string(f.data) != "\x00\x01hi" {
+				t.Errorf("terminal output = %q", f.data)
+			}
+			break
+		}
+	}
+	res = request(3, "terminalLeave", `[{"sessionId":1}]`)
+	if string(res["sessionId"]) != "0" {
+		t.Errorf("agent got terminalLeave for session %s", res["sessionId"])
+	}
+	if client.GetSession(1) != nil {
+		t.Error("client session not removed")
+	}
+
+	link.Close()
+	select {
+	case up := <-online:
+		if up {
+			t.Fatal("expected agent offline")
+		}
+	case <-ctx.Done():
+		t.Fatal("link never closed")
+	}
+	if err := link.Forward(client, "alice", 0, &ws.ClientMessage{Event: "whoami"}); err != ErrLinkClosed {
+		t.Errorf("Forward after close = %v", err)
+	}
+}
+
+// newController returns a controller serving the link of agent "nas",
+// which holds one of tokens.
+func newController(hub *Hub, tokens ...string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hello, err := ReadHello(r)
+		if err != nil {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		for _, token := range tokens {
+			if hello.Verify(KeyOf(token)) {
+				conn, err := hub.Accept(w, r, hello, KeyOf(token))
+				if err != nil {
+					return
+				}
+				hub.Serve(r.Context(), "nas", conn)
+				return
+			}
+		}
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	}))
+}
+
+func TestRekey(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	links := make(chan *Link, 4)
+	var hub *Hub
+	hub = NewHub(func(endpoint string, up bool) {
+		if up {
+			links <- hub.Link(endpoint)
+		}
+	})
+	ts := newController(hub, "old", "new")
+	defer ts.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "agent-token")
+	cfg := Config{ControllerURL: "ws" + strings.TrimPrefix(ts.URL, "http"), Token: "old", TokenFile: tokenFile}
+	go Run(ctx, cfg, newAgentServer(), func(*ws.Conn, string) {})
+
+	next := func() *Link {
+		t.Helper()
+		select {
+		case l := <-links:
+			return l
+		case <-ctx.Done():
+			t.Fatal("agent never connected")
+			return nil
+		}
+	}
+	link := next()
+	if link.Key() != KeyOf("old") {
+		t.Fatal("link not keyed with the configured token")
+	}
+	if err := link.Rekey(ctx, "new"); err != nil {
+		t.Fatal(err)
+	}
+	if got := LoadToken(cfg); got != "new" {
+		t.Errorf("LoadToken after rekey = %q", got)
+	}
+
+	// The next link uses the new token
+	link.Close()
+	if link := next(); link.Key() != KeyOf("new") {
+		t.Error("reconnected with the old token")
+	}
+
+	// A token file rotated from another token is ignored
+	if got := LoadToken(Config{Token: "other", TokenFile: tokenFile}); got != "other" {
+		t.Errorf("LoadToken with a new configured token = %q", got)
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	hub := NewHub(nil)
+	ts := newController(hub, "secret")
+	defer ts.Close()
+	url := "ws" + strings.TrimPrefix(ts.URL, "http")
+
+	if _, err := dial(ctx, &Config{ControllerURL: url, Token: "wrong"}); err == nil {
+		t.Error("dial with a wrong token succeeded")
+	}
+
+	// The controller must prove it knows the key too
+	impostor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hello, err := ReadHello(r)
+		if err != nil {
+			return
+		}
+		if conn, err := NewHub(nil).Accept(w, r, hello, KeyOf("guess")); err == nil {
+			conn.CloseNow()
+		}
+	}))
+	defer impostor.Close()
+	if _, err := dial(ctx, &Config{ControllerURL: "ws" + strings.TrimPrefix(impostor.URL, "http"), Token: "secret"}); err == nil {
+		t.Error("dial accepted a controller without the key")
+	}
+
+	// A replayed hello is refused
+	r := httptest.NewRequest("GET", "/agent", nil)
+	r.Header.Set(headerNonce, strings.Repeat("ab", nonceLength))
+	r.Header.Set(headerTime, strconv.FormatInt(time.Now().Unix(), 10))
+	r.Header.Set(headerProof, hex.EncodeToString(mac(KeyOf("secret"), "agent", r.Header.Get(headerNonce), r.Header.Get(headerTime))))
+	hello, err := ReadHello(r)
+	if err != nil || !hello.Verify(KeyOf("secret")) {
+		t.Fatalf("ReadHello = %v", err)
+	}
+	if !hub.nonces.fresh(hello.nonce) || hub.nonces.fresh(hello.nonce) {
+		t.Error("nonce not remembered")
+	}
+
+	// Stale hellos are refused
+	r.Header.Set(headerTime, strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10))
+	if _, err := ReadHello(r); err != ErrBadHello {
+		t.Errorf("stale ReadHello = %v", err)
+	}
+}
+
+func TestConnRejectsTampering(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	received := make(chan error, 4)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		c := newConn(conn, "key", "a", "c", false)
+		for {
+			_, data, err := c.Read(r.Context())
+			if err != nil {
+				received <- err
+				return
+			}
+			if string(data) != "hello" {
+				received <- fmt.Errorf("got %q", data)
+			} else {
+				received <- nil
+			}
+		}
+	}))
+	defer ts.Close()
+
+	raw, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.CloseNow()
+	c := newConn(raw, "key", "a", "c", true)
+	if err := c.Write(ctx, websocket.MessageText, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-received; err != nil {
+		t.Fatal(err)
+	}
+
+	// Flip a payload byte of an otherwise valid message
+	c.sendSeq++
+	msg := append([]byte{byte(websocket.MessageText)}, binary.BigEndian.AppendUint64(nil, c.sendSeq)...)
+	msg = append(msg, []byte("hellO")...)
+	msg = append(msg, sign(c.send, byte(websocket.MessageText), c.sendSeq, []byte("hello"))...)
+	raw.Write(ctx, websocket.MessageBinary, msg)
+	if err := <-received; err != ErrBadSignature {
+		t.Errorf("tampered message: %v", err)
+	}
+}
+
+func TestVerifyPin(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewTLSServer(http.NotFoundHandler())
+	defer ts.Close()
+	cert := ts.Certificate()
+	sum := sha256.Sum256(cert.Raw)
+	if err := verifyPin([]*x509.Certificate{cert}, sum[:]); err != nil {
+		t.Errorf("matching pin: %v", err)
+	}
+	sum[0] ^= 1
+	if err := verifyPin([]*x509.Certificate{cert}, sum[:]); err == nil {
+		t.Error("mismatched pin accepted")
+	}
+
+	if _, err := (&Config{ControllerURL: "ws://x/agent", Fingerprint: "00"}).httpClient(); err == nil {
+		t.Error("pinning accepted for ws://")
+	}
+	if _, err := (&Config{ControllerURL: "wss://x/agent", Fingerprint: "zz"}).httpClient(); err == nil {
+		t.Error("bad fingerprint accepted")
+	}
+}
+
+func TestLinkRelaysPushes(t *testing.T) {
+	t.Parallel()
+	frames := make(chan captured, 4)
+	client := ws.NewServer(false).Attach(func(_ context.Context, typ websocket.MessageType, data []byte) error {
+		frames <- captured{typ, data}
+		return nil
+	}, nil)
+	l := &Link{endpoint: "nas", byConn: map[*ws.Conn]*channel{}, byID: map[uint32]*channel{}}
+	ch := &channel{id: 1, client: client, joins: map[int64]bool{}, remote: map[uint16]uint16{}, local: map[uint16]uint16{}}
+
+	l.relay(ch, json.RawMessage(`{"event":"stacks","data":{"web":{"name":"web"}}}`))
+	f := <-frames
+	want := `{"event":"agent","data":{"endpoint":"nas","event":"stacks","data":{"web":{"name":"web"}}}}`
+	if string(f.data) != want {
+		t.Errorf("relayed push = %s, want %s", f.data, want)
+	}
+
+	// terminalExited for sessions the client doesn't know is dropped
+	l.relay(ch, json.RawMessage(`{"event":"terminalExited","data":{"sessionId":3}}`))
+	select {
+	case f := <-frames:
+		t.Errorf("unexpected frame %s", f.data)
+	default:
+	}
+
+	// and for known ones it's mapped to the local session, with the exit code
+	ch.remote[4] = 7
+	ch.local[7] = 4
+	l.relay(ch, json.RawMessage(`{"event":"terminalExited","data":{"sessionId":4,"exitCode":2}}`))
+	f = <-frames
+	if want := `{"event":"terminalExited","data":{"sessionId":7,"exitCode":2}}`; string(f.data) != want {
+		t.Errorf("relayed exit = %s, want %s", f.data, want)
+	}
+}
+
+func itoa(n int64) string {
+	b, _ := json.Marshal(n)
+	return string(b)
+}
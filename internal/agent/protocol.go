@@ -0,0 +1,77 @@
+// Package agent connects Dockge instances. An instance in agent mode dials
+// its controller over WebSocket, and the controller drives the agent's
+// stacks and terminals on behalf of its own users.
+//
+// The link carries one channel per controller client. Text frames are JSON
+// frames; binary frames are a 4-byte big-endian channel ID followed by a
+// regular terminal frame. On the agent each channel is a ws.Conn attached to
+// its server, so handlers see an ordinary, separately authenticated
+// connection. Underneath, every message of the link is signed (see Conn).
+package agent
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+// Frame operations.
+const (
+	opOpen  = "open"  // controller → agent: start a channel for User
+	opMsg   = "msg"   // either way: a WebSocket message of the channel
+	opClose = "close" // either way: the channel ended
+
+	// Link-level operations use channel 0.
+	opRekey   = "rekey"   // controller → agent: switch to the sealed token in Key
+	opRekeyed = "rekeyed" // agent → controller: the new token is stored, or Err
+)
+
+const (
+	// linkReadLimit bounds one frame of the link. It carries the broadcasts
+	// of every channel, which are larger than a browser's requests.
+	linkReadLimit = 16 << 20
+
+	// writeTimeout bounds writing one frame to the link.
+	writeTimeout = 10 * time.Second
+)
+
+// frame is a text frame of the link.
+type frame struct {
+	Ch   uint32          `json:"ch"`
+	Op   string          `json:"op"`
+	User string          `json:"user,omitempty"` // open: the controller user
+	Sudo int64           `json:"sudo,omitempty"` // msg to the agent: ms left of the user's sudo mode
+	Msg  json.RawMessage `json:"msg,omitempty"`
+	Key  []byte          `json:"key,omitempty"` // rekey: the token, sealed with the link's key
+	Err  string          `json:"err,omitempty"` // rekeyed: why the agent couldn't switch
+}
+
+func writeFrame(ctx context.Context, conn *Conn, f frame) error {
+	data, err := json.Marshal(&f)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+	return conn.Write(ctx, websocket.MessageText, data)
+}
+
+func writeBinary(ctx context.Context, conn *Conn, ch uint32, data []byte) error {
+	buf := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(buf, ch)
+	copy(buf[4:], data)
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+	return conn.Write(ctx, websocket.MessageBinary, buf)
+}
+
+// splitBinary splits a binary link frame into its channel and inner frame.
+func splitBinary(data []byte) (uint32, []byte, bool) {
+	if len(data) < 4 {
+		return 0, nil, false
+	}
+	return binary.BigEndian.Uint32(data), data[4:], true
+}
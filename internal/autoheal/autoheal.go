@@ -0,0 +1,177 @@
+// Package autoheal restarts containers whose healthcheck keeps failing.
+//
+// A container that turns unhealthy is restarted once it has stayed
+// unhealthy for a grace period, unless it used up its restart budget.
+// Containers opt in with the Label label or through their stack.
+package autoheal
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/docker"
+)
+
+// Label opts a container in ("true") or out ("false") of autohealing,
+// whatever its stack's setting.
+const Label = "dockge.autoheal"
+
+// Window is the period the restart budget of a container covers.
+const Window = time.Hour
+
+// restartTimeout bounds one restart.
+const restartTimeout = 2 * time.Minute
+
+// Config is which containers are healed and how.
+type Config struct {
+	Stacks      map[string]bool // stacks whose containers are healed without the label
+	Grace       time.Duration   // how long a container stays unhealthy before it's restarted
+	MaxRestarts int             // restarts of one container per Window
+}
+
+// Action is what the watchdog did about an unhealthy container.
+type Action struct {
+	Stack       string
+	Service     string
+	Container   string
+	ContainerID string
+	Restarted   bool  // false if the restart budget is used up
+	Err         error // of the restart
+}
+
+// Watchdog heals containers as their events come in.
+type Watchdog struct {
+	config  func() Config
+	restart func(ctx context.Context, containerID string) error
+	report  func(Action)
+	now     func() time.Time
+
+	mu       sync.Mutex
+	ctx      context.Context
+	pending  map[string]*time.Timer // container ID → restart after the grace period
+	restarts map[string][]time.Time // container ID → restarts within Window
+}
+
+// New creates a watchdog. config is read for every event, so settings
+// changes apply at once. restart restarts a container and report is told
+// about every restart or skipped one. Events are handled once Start is
+// called.
+func New(config func() Config, restart func(ctx context.Context, containerID string) error, report func(Action)) *Watchdog {
+	return &Watchdog{
+		config:   config,
+		restart:  restart,
+		report:   report,
+		now:      time.Now,
+		pending:  make(map[string]*time.Timer),
+		restarts: make(map[string][]time.Time),
+	}
+}
+
+// Start handles events from events until ctx is cancelled.
+func (w *Watchdog) Start(ctx context.Context, events <-chan docker.DockerEvent) {
+	w.mu.Lock()
+	w.ctx = ctx
+	w.mu.Unlock()
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				w.mu.Lock()
+				for id, t := range w.pending {
+					t.Stop()
+					delete(w.pending, id)
+				}
+				w.mu.Unlock()
+				return
+			case evt := <-events:
+				w.Handle(evt)
+			}
+		}
+	}()
+}
+
+// Handle reacts to a Docker event: an opted-in container turning
+// unhealthy is restarted after the grace period, unless it recovers,
+// stops or goes away first.
+func (w *Watchdog) Handle(evt docker.DockerEvent) {
+	if evt.Type != "container" || evt.ContainerID == "" {
+		return
+	}
+	switch evt.Action {
+	case "health_status: unhealthy":
+		cfg := w.config()
+		if !enabled(cfg, evt) {
+			return
+		}
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if w.ctx == nil || w.pending[evt.ContainerID] != nil {
+			return
+		}
+		w.pending[evt.ContainerID] = time.AfterFunc(cfg.Grace, func() { w.heal(evt) })
+	case "health_status: healthy", "die", "destroy":
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if t := w.pending[evt.ContainerID]; t != nil {
+			t.Stop()
+			delete(w.pending, evt.ContainerID)
+		}
+		if evt.Action == "destroy" {
+			delete(w.restarts, evt.ContainerID)
+		}
+	}
+}
+
+// enabled reports whether the container of evt is healed.
+func enabled(cfg Config, evt docker.DockerEvent) bool {
+	if v, ok := evt.Labels[Label]; ok {
+		on, err := strconv.ParseBool(v)
+		return err == nil && on
+	}
+	return cfg.Stacks[evt.Project]
+}
+
+// heal restarts the container of evt if its budget allows.
+func (w *Watchdog) heal(evt docker.DockerEvent) {
+	cfg := w.config()
+	now := w.now()
+
+	w.mu.Lock()
+	if _, ok := w.pending[evt.ContainerID]; !ok {
+		w.mu.Unlock()
+		return // recovered meanwhile
+	}
+	delete(w.pending, evt.ContainerID)
+	ctx := w.ctx
+	recent := w.restarts[evt.ContainerID][:0]
+	for _, t := range w.restarts[evt.ContainerID] {
+		if now.Sub(t) < Window {
+			recent = append(recent, t)
+		}
+	}
+	allowed := len(recent) < cfg.MaxRestarts
+	if allowed {
+		recent = append(recent, now)
+	}
+	w.restarts[evt.ContainerID] = recent
+	w.mu.Unlock()
+
+	if ctx.Err() != nil {
+		return
+	}
+	action := Action{
+		Stack:       evt.Project,
+		Service:     evt.Service,
+		Container:   evt.Name,
+		ContainerID: evt.ContainerID,
+		Restarted:   allowed,
+	}
+	if allowed {
+		rctx, cancel := context.WithTimeout(ctx, restartTimeout)
+		action.Err = w.restart(rctx, evt.ContainerID)
+		cancel()
+	}
+	w.report(action)
+}
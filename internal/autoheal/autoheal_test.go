@@ -0,0 +1,124 @@
+package autoheal
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/docker"
+)
+
+type healLog struct {
+	mu        sync.Mutex
+	restarted []string
+	actions   []Action
+}
+
+func (l *healLog) restart(_ context.Context, id string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.restarted = append(l.restarted, id)
+	if id == "broken" {
+		return errors.New("no such container")
+	}
+	return nil
+}
+
+func (l *healLog) report(a Action) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.actions = append(l.actions, a)
+}
+
+func (l *healLog) waitActions(t *testing.T, n int) []Action {
+	t.Helper()
+	for range 200 {
+		l.mu.Lock()
+		got := append([]Action(nil), l.actions...)
+		l.mu.Unlock()
+		if len(got) >= n {
+			return got
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("expected %d actions", n)
+	return nil
+}
+
+func event(id, project, action string, labels map[string]string) docker.DockerEvent {
+	return docker.DockerEvent{Type: "container", Action: action, ContainerID: id, Name: project + "-" + id, Project: project, Labels: labels}
+}
+
+func newTestWatchdog(t *testing.T, cfg Config) (*Watchdog, *healLog) {
+	t.Helper()
+	log := &healLog{}
+	w := New(func() Config { return cfg }, log.restart, log.report)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	w.Start(ctx, make(chan docker.DockerEvent))
+	return w, log
+}
+
+func TestWatchdogRestartsAfterGrace(t *testing.T) {
+	t.Parallel()
+	w, log := newTestWatchdog(t, Config{Stacks: map[string]bool{"web": true}, Grace: 20 * time.Millisecond, MaxRestarts: 3})
+
+	w.Handle(event("a", "web", "health_status: unhealthy", nil))
+	w.Handle(event("a", "web", "health_status: unhealthy", nil)) // already pending
+	w.Handle(event("b", "db", "health_status: unhealthy", nil))  // stack not opted in
+	w.Handle(event("c", "db", "health_status: unhealthy", map[string]string{Label: "true"}))
+	w.Handle(event("d", "web", "health_status: unhealthy", map[string]string{Label: "false"}))
+	// Recovers within the grace period
+	w.Handle(event("e", "web", "health_status: unhealthy", nil))
+	w.Handle(event("e", "web", "health_status: healthy", nil))
+
+	actions := log.waitActions(t, 2)
+	time.Sleep(50 * time.Millisecond)
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	if len(log.restarted) != 2 {
+		t.Fatalf("restarted %v, want a and c", log.restarted)
+	}
+	for _, a := range actions {
+		if !a.Restarted || a.Err != nil || (a.ContainerID != "a" && a.ContainerID != "c") {
+			t.Errorf("unexpected action %+v", a)
+		}
+	}
+}
+
+func TestWatchdogRestartBudget(t *testing.T) {
+	t.Parallel()
+	w, log := newTestWatchdog(t, Config{Stacks: map[string]bool{"web": true}, MaxRestarts: 2})
+	now := time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC)
+	w.mu.Lock()
+	w.now = func() time.Time { return now }
+	w.mu.Unlock()
+
+	for i := 1; i <= 3; i++ {
+		w.Handle(event("a", "web", "health_status: unhealthy", nil))
+		log.waitActions(t, i)
+	}
+	actions := log.waitActions(t, 3)
+	if !actions[0].Restarted || !actions[1].Restarted || actions[2].Restarted {
+		t.Errorf("expected two restarts, then none: %+v", actions)
+	}
+
+	// The budget frees up as restarts leave the window
+	now = now.Add(Window)
+	w.Handle(event("a", "web", "health_status: unhealthy", nil))
+	if actions := log.waitActions(t, 4); !actions[3].Restarted {
+		t.Errorf("expected a restart after the window: %+v", actions[3])
+	}
+}
+
+func TestWatchdogReportsRestartErrors(t *testing.T) {
+	t.Parallel()
+	w, log := newTestWatchdog(t, Config{Stacks: map[string]bool{"web": true}, MaxRestarts: 1})
+
+	w.Handle(event("broken", "web", "health_status: unhealthy", nil))
+	if a := log.waitActions(t, 1)[0]; !a.Restarted || a.Err == nil {
+		t.Errorf("expected a failed restart, got %+v", a)
+	}
+}
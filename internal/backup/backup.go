@@ -0,0 +1,337 @@
+// Package backup saves and restores a whole Dockge install: the database
+// (users, settings, agents, caches) and every stack's files.
+//
+// A restore can't replace the database while it's open, so it happens in
+// two steps: Stage extracts and validates a backup into the data directory,
+// and ApplyPending moves it into place on the next start, before the
+// database is opened.
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/cfilipov/dockge/internal/db"
+	"github.com/cfilipov/dockge/internal/stack"
+)
+
+// Layout of a backup, a tar.gz:
+//
+//	manifest.json     Manifest
+//	dockge.db         the database
+//	stacks/...        the stacks directory
+const (
+	manifestName = "manifest.json"
+	dbName       = "dockge.db"
+	stacksPrefix = "stacks/"
+	version      = 1
+)
+
+// PendingDir is where a staged restore waits in the data directory.
+const PendingDir = "restore-pending"
+
+// Manifest describes a backup.
+type Manifest struct {
+	Version       int       `json:"version"`
+	DockgeVersion string    `json:"dockgeVersion"`
+	CreatedAt     time.Time `json:"createdAt"`
+	Stacks        []string  `json:"stacks"` // top-level entries of the stacks directory
+}
+
+// Write writes a backup to w. The database is copied in one read
+// transaction, so it's consistent while Dockge keeps running. Hidden
+// entries of the stacks directory are left out, as are files that can't be
+// read; the latter are returned.
+func Write(w io.Writer, database *bolt.DB, stacksDir, dockgeVersion string) (skipped []string, err error) {
+	entries, err := os.ReadDir(stacksDir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), ".") {
+			names = append(names, e.Name())
+		}
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	manifest, err := json.MarshalIndent(Manifest{
+		Version:       version,
+		DockgeVersion: dockgeVersion,
+		CreatedAt:     time.Now().UTC(),
+		Stacks:        names,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := stack.WriteTarBytes(tw, manifestName, manifest); err != nil {
+		return nil, err
+	}
+
+	err = database.View(func(tx *bolt.Tx) error {
+		if err := tw.WriteHeader(&tar.Header{Name: dbName, Mode: 0600, Size: tx.Size(), ModTime: time.Now()}); err != nil {
+			return err
+		}
+		_, err := tx.WriteTo(tw)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("copy database: %w", err)
+	}
+
+	for _, name := range names {
+		// Stacks may be symlinks to directories elsewhere
+		p, err := filepath.EvalSymlinks(filepath.Join(stacksDir, name))
+		if err != nil {
+			skipped = append(skipped, name)
+			continue
+		}
+		info, err := os.Stat(p)
+		switch {
+		case err != nil:
+			skipped = append(skipped, name)
+		case info.IsDir():
+			if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: stacksPrefix + name + "/", Mode: int64(info.Mode().Perm()), ModTime: info.ModTime()}); err != nil {
+				return nil, err
+			}
+			s, err := stack.WriteTree(tw, p, stacksPrefix+name+"/")
+			if err != nil {
+				return nil, fmt.Errorf("stack %s: %w", name, err)
+			}
+			for _, rel := range s {
+				skipped = append(skipped, filepath.Join(name, rel))
+			}
+		case info.Mode().IsRegular(): // global.env
+			if err := stack.WriteTarFile(tw, stacksPrefix+name, p); err != nil {
+				skipped = append(skipped, name)
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return skipped, gz.Close()
+}
+
+// Stage extracts a backup into dir, which must not exist, and validates it.
+// Nothing is left behind if it fails.
+func Stage(r io.Reader, dir string) (_ *Manifest, err error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a Dockge backup: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	if err := os.Mkdir(dir, 0700); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(dir)
+		}
+	}()
+	if err := os.Mkdir(filepath.Join(dir, "stacks"), 0755); err != nil {
+		return nil, err
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read backup: %w", err)
+		}
+		switch {
+		case hdr.Name == manifestName, hdr.Name == dbName:
+			if err := stack.ExtractTarEntry(tr, hdr, dir, hdr.Name); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(hdr.Name, stacksPrefix):
+			if err := stack.ExtractTarEntry(tr, hdr, filepath.Join(dir, "stacks"), strings.TrimPrefix(hdr.Name, stacksPrefix)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return Validate(dir)
+}
+
+// Validate checks a staged backup: its manifest, and that the database is
+// intact and is a Dockge database.
+func Validate(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestName))
+	if err != nil {
+		return nil, errors.New("not a Dockge backup: no manifest")
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("read backup manifest: %w", err)
+	}
+	if m.Version != version {
+		return nil, fmt.Errorf("unsupported backup version %d", m.Version)
+	}
+
+	dbPath := filepath.Join(dir, dbName)
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, errors.New("backup has no database")
+	}
+	database, err := bolt.Open(dbPath, 0600, &bolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open backup database: %w", err)
+	}
+	defer database.Close()
+	err = database.View(func(tx *bolt.Tx) error {
+		// Drain every error; Check's goroutine reads tx until it's done
+		var damaged error
+		for err := range tx.Check() {
+			if damaged == nil {
+				damaged = err
+			}
+		}
+		if damaged != nil {
+			return fmt.Errorf("backup database is damaged: %w", damaged)
+		}
+		for _, b := range [][]byte{db.BucketSettings, db.BucketUsers} {
+			if tx.Bucket(b) == nil {
+				return fmt.Errorf("backup database has no %s", b)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// ApplyPending restores the backup staged in dataDir, if there is one, and
+// returns its manifest. It must run before the database is opened.
+//
+// The database is replaced, and so is each stack in the backup. Stacks that
+// aren't in it are kept. What's replaced is moved to
+// <data-dir>/pre-restore-<time> rather than deleted.
+func ApplyPending(dataDir, stacksDir string) (*Manifest, error) {
+	pending := filepath.Join(dataDir, PendingDir)
+	if _, err := os.Stat(pending); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	m, err := Validate(pending)
+	if err != nil {
+		return nil, fmt.Errorf("staged restore in %s: %w", pending, err)
+	}
+
+	saved := filepath.Join(dataDir, "pre-restore-"+time.Now().Format("20060102-150405"))
+	if err := os.MkdirAll(filepath.Join(saved, "stacks"), 0700); err != nil {
+		return nil, err
+	}
+	dbPath := filepath.Join(dataDir, db.FileName)
+	if _, err := os.Stat(dbPath); err == nil {
+		if err := move(dbPath, filepath.Join(saved, db.FileName)); err != nil {
+			return nil, fmt.Errorf("save current database: %w", err)
+		}
+	}
+	if err := move(filepath.Join(pending, dbName), dbPath); err != nil {
+		return nil, fmt.Errorf("restore database: %w", err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(pending, "stacks"))
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(stacksDir, 0755); err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		target := filepath.Join(stacksDir, e.Name())
+		if _, err := os.Lstat(target); err == nil {
+			if err := move(target, filepath.Join(saved, "stacks", e.Name())); err != nil {
+				return nil, fmt.Errorf("save current stack %s: %w", e.Name(), err)
+			}
+		}
+		if err := move(filepath.Join(pending, "stacks", e.Name()), target); err != nil {
+			return nil, fmt.Errorf("restore stack %s: %w", e.Name(), err)
+		}
+	}
+	return m, os.RemoveAll(pending)
+}
+
+// move renames src to dst, copying when they're on different filesystems.
+func move(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	if err := copyTree(src, dst); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// copyTree copies a file, or a directory with its directories, regular
+// files and symlinks.
+func copyTree(src, dst string) error {
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, p)
+		target := filepath.Join(dst, rel)
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			return copyFile(p, target, info.Mode().Perm())
+		}
+		return nil
+	})
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// StageFile stages the backup in file for ApplyPending.
+func StageFile(file, dataDir string) (*Manifest, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, err
+	}
+	return Stage(f, filepath.Join(dataDir, PendingDir))
+}
@@ -0,0 +1,110 @@
+package backup
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cfilipov/dockge/internal/db"
+	"github.com/cfilipov/dockge/internal/models"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestBackupRestore(t *testing.T) {
+	base := t.TempDir()
+	dataDir := filepath.Join(base, "data")
+	stacksDir := filepath.Join(base, "stacks")
+	writeFile(t, filepath.Join(stacksDir, "web", "compose.yaml"), "services:\n  web:\n    image: nginx\n")
+	writeFile(t, filepath.Join(stacksDir, "global.env"), "TZ=UTC\n")
+	writeFile(t, filepath.Join(stacksDir, ".import-123", "files", "compose.yaml"), "ignored")
+
+	database, err := db.Open(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := models.NewSettingStore(database).Set("primaryHostname", "backed-up"); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	skipped, err := Write(&buf, database, stacksDir, "1.2.3")
+	if err != nil || len(skipped) > 0 {
+		t.Fatalf("Write: skipped %v, %v", skipped, err)
+	}
+
+	// Change everything after the backup
+	models.NewSettingStore(database).Set("primaryHostname", "changed")
+	database.Close()
+	writeFile(t, filepath.Join(stacksDir, "web", "compose.yaml"), "changed")
+	writeFile(t, filepath.Join(stacksDir, "other", "compose.yaml"), "kept")
+
+	m, err := Stage(&buf, filepath.Join(dataDir, PendingDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.DockgeVersion != "1.2.3" || len(m.Stacks) != 2 {
+		t.Errorf("unexpected manifest %+v", m)
+	}
+
+	if m, err = ApplyPending(dataDir, stacksDir); err != nil || m == nil {
+		t.Fatalf("ApplyPending: %v, %v", m, err)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, PendingDir)); !os.IsNotExist(err) {
+		t.Errorf("staged restore left behind: %v", err)
+	}
+	if got := readFile(t, filepath.Join(stacksDir, "web", "compose.yaml")); got != "services:\n  web:\n    image: nginx\n" {
+		t.Errorf("web not restored: %q", got)
+	}
+	if got := readFile(t, filepath.Join(stacksDir, "global.env")); got != "TZ=UTC\n" {
+		t.Errorf("global.env not restored: %q", got)
+	}
+	if got := readFile(t, filepath.Join(stacksDir, "other", "compose.yaml")); got != "kept" {
+		t.Errorf("stack outside the backup changed: %q", got)
+	}
+	saved, _ := filepath.Glob(filepath.Join(dataDir, "pre-restore-*", "stacks", "web", "compose.yaml"))
+	if len(saved) != 1 || readFile(t, saved[0]) != "changed" {
+		t.Errorf("replaced stack not saved: %v", saved)
+	}
+
+	database, err = db.Open(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	if got, _ := models.NewSettingStore(database).Get("primaryHostname"); got != "backed-up" {
+		t.Errorf("setting = %q, want the backed-up value", got)
+	}
+
+	// Nothing pending now
+	if m, err := ApplyPending(dataDir, stacksDir); m != nil || err != nil {
+		t.Errorf("second ApplyPending = %v, %v", m, err)
+	}
+}
+
+func TestStageRejectsNonBackup(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), PendingDir)
+	if _, err := Stage(bytes.NewReader([]byte("not a backup")), dir); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("failed stage left %s behind", dir)
+	}
+}
@@ -0,0 +1,592 @@
+package compose
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AdoptSource is a container to adopt into a stack: its inspect output and
+// the defaults of its image, which are left out of the service.
+type AdoptSource struct {
+	Inspect json.RawMessage
+	Image   ImageDefaults
+}
+
+// ImageDefaults are the settings a container inherits from its image.
+type ImageDefaults struct {
+	Env        []string
+	Cmd        []string
+	Entrypoint []string
+	User       string
+	WorkingDir string
+	Labels     map[string]string
+	Volumes    []string // anonymous volumes the image declares
+}
+
+// Adoption is a compose file reconstructed from existing containers, with
+// what it couldn't carry over.
+type Adoption struct {
+	YAML     string   `json:"yaml"`
+	Services []string `json:"services"`
+	Warnings []string `json:"warnings"`
+}
+
+// adoptInspect is the subset of container inspect data a service is
+// reconstructed from.
+type adoptInspect struct {
+	ID     string `json:"Id"`
+	Name   string `json:"Name"`
+	Config struct {
+		Hostname   string            `json:"Hostname"`
+		Domainname string            `json:"Domainname"`
+		User       string            `json:"User"`
+		WorkingDir string            `json:"WorkingDir"`
+		Image      string            `json:"Image"`
+		Env        []string          `json:"Env"`
+		Cmd        []string          `json:"Cmd"`
+		Entrypoint []string          `json:"Entrypoint"`
+		Labels     map[string]string `json:"Labels"`
+		Tty        bool              `json:"Tty"`
+		OpenStdin  bool              `json:"OpenStdin"`
+		StopSignal string            `json:"StopSignal"`
+	} `json:"Config"`
+	HostConfig struct {
+		NetworkMode  string `json:"NetworkMode"`
+		PortBindings map[string][]struct {
+			HostIP   string `json:"HostIp"`
+			HostPort string `json:"HostPort"`
+		} `json:"PortBindings"`
+		RestartPolicy struct {
+			Name              string `json:"Name"`
+			MaximumRetryCount int    `json:"MaximumRetryCount"`
+		} `json:"RestartPolicy"`
+		CapAdd         []string          `json:"CapAdd"`
+		CapDrop        []string          `json:"CapDrop"`
+		DNS            []string          `json:"Dns"`
+		DNSSearch      []string          `json:"DnsSearch"`
+		ExtraHosts     []string          `json:"ExtraHosts"`
+		GroupAdd       []string          `json:"GroupAdd"`
+		SecurityOpt    []string          `json:"SecurityOpt"`
+		Privileged     bool              `json:"Privileged"`
+		Init           *bool             `json:"Init"`
+		ReadonlyRootfs bool              `json:"ReadonlyRootfs"`
+		Tmpfs          map[string]string `json:"Tmpfs"`
+		Sysctls        map[string]string `json:"Sysctls"`
+		ShmSize        int64             `json:"ShmSize"`
+		Memory         int64             `json:"Memory"`
+		NanoCPUs       int64             `json:"NanoCpus"`
+		PidMode        string            `json:"PidMode"`
+		IpcMode        string            `json:"IpcMode"`
+		LogConfig      struct {
+			Type   string            `json:"Type"`
+			Config map[string]string `json:"Config"`
+		} `json:"LogConfig"`
+		Devices []struct {
+			PathOnHost        string `json:"PathOnHost"`
+			PathInContainer   string `json:"PathInContainer"`
+			CgroupPermissions string `json:"CgroupPermissions"`
+		} `json:"Devices"`
+		DeviceRequests []struct {
+			Driver       string     `json:"Driver"`
+			Count        int        `json:"Count"`
+			DeviceIDs    []string   `json:"DeviceIDs"`
+			Capabilities [][]string `json:"Capabilities"`
+		} `json:"DeviceRequests"`
+	} `json:"HostConfig"`
+	Mounts []struct {
+		Type        string `json:"Type"`
+		Name        string `json:"Name"`
+		Source      string `json:"Source"`
+		Destination string `json:"Destination"`
+		RW          bool   `json:"RW"`
+	} `json:"Mounts"`
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAMConfig *struct {
+				IPv4Address string `json:"IPv4Address"`
+				IPv6Address string `json:"IPv6Address"`
+			} `json:"IPAMConfig"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+// defaultShmSize is the /dev/shm size the daemon gives containers.
+const defaultShmSize = 64 << 20
+
+var anonymousVolumeName = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// adoption accumulates the compose file of adopted containers.
+type adoption struct {
+	project  string
+	volumes  map[string]string // key in the compose file → volume name
+	networks map[string]string // key in the compose file → network name
+	warnings []string
+}
+
+func (a *adoption) warnf(format string, args ...any) {
+	a.warnings = append(a.warnings, fmt.Sprintf(format, args...))
+}
+
+// resourceKey is the key a volume or network gets in the compose file: its
+// name without the prefix compose gave it in the project.
+func (a *adoption) resourceKey(name string) string {
+	if a.project != "" {
+		if key, ok := strings.CutPrefix(name, a.project+"_"); ok && key != "" {
+			return key
+		}
+	}
+	return name
+}
+
+// AdoptContainers reconstructs a compose file from the inspect output of
+// existing containers: those of an external compose project, or standalone
+// ones if project is "". Settings the containers share with their image are
+// left out, and named volumes and networks are referenced as external so
+// the stack keeps using them whatever its name.
+func AdoptContainers(project string, sources []AdoptSource) (*Adoption, error) {
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no containers to adopt")
+	}
+	type parsed struct {
+		inspect adoptInspect
+		image   ImageDefaults
+		service string
+	}
+	var containers []parsed
+	for _, src := range sources {
+		var p parsed
+		if err := json.Unmarshal(src.Inspect, &p.inspect); err != nil {
+			return nil, fmt.Errorf("parse inspect: %w", err)
+		}
+		p.image = src.Image
+		p.inspect.Name = strings.TrimPrefix(p.inspect.Name, "/")
+		p.service = p.inspect.Config.Labels["com.docker.compose.service"]
+		if p.service == "" {
+			p.service = sanitizeServiceName(p.inspect.Name)
+		}
+		containers = append(containers, p)
+	}
+	sort.SliceStable(containers, func(i, j int) bool {
+		if containers[i].service != containers[j].service {
+			return containers[i].service < containers[j].service
+		}
+		return containers[i].inspect.Name < containers[j].inspect.Name
+	})
+
+	a := &adoption{project: project, volumes: map[string]string{}, networks: map[string]string{}}
+	services := mapping()
+	var names []string
+	for i, c := range containers {
+		if i > 0 && c.service == containers[i-1].service {
+			if project != "" {
+				// Replicas of a scaled service share its definition
+				a.warnf("%s is another replica of %s and was left out; set scale to keep it", c.inspect.Name, c.service)
+				continue
+			}
+			c.service = uniqueServiceName(c.service, names)
+		}
+		names = append(names, c.service)
+		services.Content = append(services.Content, plain(c.service), a.service(&c.inspect, &c.image, c.service).node())
+	}
+
+	doc := mapping(plain("services"), services)
+	for _, top := range []struct {
+		key   string
+		names map[string]string
+	}{{"volumes", a.volumes}, {"networks", a.networks}} {
+		if len(top.names) == 0 {
+			continue
+		}
+		keys := make([]string, 0, len(top.names))
+		for k := range top.names {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		m := mapping()
+		for _, k := range keys {
+			m.Content = append(m.Content, quoted(k),
+				mapping(plain("name"), quoted(top.names[k]), plain("external"), plain("true")))
+		}
+		doc.Content = append(doc.Content, plain(top.key), m)
+	}
+
+	out, err := encodeCompose(doc)
+	if err != nil {
+		return nil, err
+	}
+	warnings := a.warnings
+	if warnings == nil {
+		warnings = []string{}
+	}
+	return &Adoption{YAML: out, Services: names, Warnings: warnings}, nil
+}
+
+func uniqueServiceName(name string, taken []string) string {
+	for n := 2; ; n++ {
+		candidate := name + "-" + strconv.Itoa(n)
+		if !slices.Contains(taken, candidate) {
+			return candidate
+		}
+	}
+}
+
+// isDefaultContainerName reports whether compose would give the container
+// this name anyway: project-service-N, or project_service_N before v2.
+func (a *adoption) isDefaultContainerName(name, service string) bool {
+	if a.project == "" {
+		return false
+	}
+	for _, sep := range []string{"-", "_"} {
+		if n, ok := strings.CutPrefix(name, a.project+sep+service+sep); ok {
+			if _, err := strconv.Atoi(n); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// service reconstructs one service from a container.
+func (a *adoption) service(c *adoptInspect, img *ImageDefaults, name string) *serviceBuilder {
+	b := &serviceBuilder{}
+	cfg, host := &c.Config, &c.HostConfig
+
+	b.set("image", lit(cfg.Image))
+	if strings.HasPrefix(cfg.Image, "sha256:") {
+		a.warnf("%s was created from an image ID; set the image name of %s", c.Name, name)
+	}
+	if !a.isDefaultContainerName(c.Name, name) {
+		b.set("container_name", lit(c.Name))
+	}
+	hostMode := host.NetworkMode == "host"
+	if cfg.Hostname != "" && !hostMode && !strings.HasPrefix(c.ID, cfg.Hostname) {
+		b.set("hostname", lit(cfg.Hostname))
+	}
+	if cfg.Domainname != "" {
+		b.set("domainname", lit(cfg.Domainname))
+	}
+	if len(cfg.Entrypoint) > 0 && !slices.Equal(cfg.Entrypoint, img.Entrypoint) {
+		b.set("entrypoint", litSeq(cfg.Entrypoint))
+	}
+	if len(cfg.Cmd) > 0 && !slices.Equal(cfg.Cmd, img.Cmd) {
+		b.set("command", litSeq(cfg.Cmd))
+	}
+	switch policy := host.RestartPolicy; policy.Name {
+	case "", "no":
+	case "on-failure":
+		if policy.MaximumRetryCount > 0 {
+			b.set("restart", quoted("on-failure:"+strconv.Itoa(policy.MaximumRetryCount)))
+		} else {
+			b.set("restart", quoted(policy.Name))
+		}
+	default:
+		b.set("restart", quoted(policy.Name))
+	}
+	if cfg.User != "" && cfg.User != img.User {
+		b.set("user", lit(cfg.User))
+	}
+	if cfg.WorkingDir != "" && cfg.WorkingDir != img.WorkingDir {
+		b.set("working_dir", lit(cfg.WorkingDir))
+	}
+
+	if ports := portMappings(host.PortBindings); len(ports) > 0 {
+		b.set("ports", litSeq(ports))
+	}
+	var env []string
+	for _, e := range cfg.Env {
+		if !slices.Contains(img.Env, e) {
+			env = append(env, e)
+		}
+	}
+	if len(env) > 0 {
+		b.set("environment", litSeq(env))
+	}
+
+	a.addMounts(b, c, img)
+
+	switch mode := host.NetworkMode; {
+	case mode == "host" || mode == "none":
+		b.set("network_mode", quoted(mode))
+	case strings.HasPrefix(mode, "container:"):
+		a.warnf("%s shares the network of another container; set network_mode of %s to its service", c.Name, name)
+	default:
+		a.addNetworks(b, c)
+	}
+
+	var labels []string
+	for k, v := range cfg.Labels {
+		if strings.HasPrefix(k, "com.docker.compose.") {
+			continue
+		}
+		if iv, ok := img.Labels[k]; ok && iv == v {
+			continue
+		}
+		labels = append(labels, k+"="+v)
+	}
+	sort.Strings(labels)
+	if len(labels) > 0 {
+		b.set("labels", litSeq(labels))
+	}
+
+	for _, l := range []struct {
+		key    string
+		values []string
+	}{
+		{"cap_add", host.CapAdd}, {"cap_drop", host.CapDrop}, {"dns", host.DNS},
+		{"dns_search", host.DNSSearch}, {"extra_hosts", host.ExtraHosts},
+		{"group_add", host.GroupAdd}, {"security_opt", host.SecurityOpt},
+	} {
+		if len(l.values) > 0 {
+			b.set(l.key, litSeq(l.values))
+		}
+	}
+	for _, d := range host.Devices {
+		dev := d.PathOnHost + ":" + d.PathInContainer
+		if d.CgroupPermissions != "" && d.CgroupPermissions != "rwm" {
+			dev += ":" + d.CgroupPermissions
+		}
+		b.appendTo("devices", lit(dev))
+	}
+	if len(host.Sysctls) > 0 {
+		keys := make([]string, 0, len(host.Sysctls))
+		for k := range host.Sysctls {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			b.appendTo("sysctls", lit(k+"="+host.Sysctls[k]))
+		}
+	}
+
+	for _, flag := range []struct {
+		key string
+		on  bool
+	}{
+		{"privileged", host.Privileged}, {"init", host.Init != nil && *host.Init},
+		{"read_only", host.ReadonlyRootfs}, {"tty", cfg.Tty}, {"stdin_open", cfg.OpenStdin},
+	} {
+		if flag.on {
+			b.set(flag.key, plain("true"))
+		}
+	}
+	if host.ShmSize > 0 && host.ShmSize != defaultShmSize {
+		b.set("shm_size", plain(strconv.FormatInt(host.ShmSize, 10)))
+	}
+	if host.Memory > 0 {
+		b.set("mem_limit", plain(strconv.FormatInt(host.Memory, 10)))
+	}
+	if host.NanoCPUs > 0 {
+		b.set("cpus", plain(strconv.FormatFloat(float64(host.NanoCPUs)/1e9, 'f', -1, 64)))
+	}
+	if host.PidMode != "" {
+		b.set("pid", lit(host.PidMode))
+	}
+	if host.IpcMode != "" && host.IpcMode != "private" && host.IpcMode != "shareable" {
+		b.set("ipc", lit(host.IpcMode))
+	}
+	if cfg.StopSignal != "" {
+		b.set("stop_signal", quoted(cfg.StopSignal))
+	}
+	if lc := host.LogConfig; (lc.Type != "" && lc.Type != "json-file") || len(lc.Config) > 0 {
+		logging := mapping(plain("driver"), quoted(lc.Type))
+		if len(lc.Config) > 0 {
+			keys := make([]string, 0, len(lc.Config))
+			for k := range lc.Config {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			opts := mapping()
+			for _, k := range keys {
+				opts.Content = append(opts.Content, quoted(k), lit(lc.Config[k]))
+			}
+			logging.Content = append(logging.Content, plain("options"), opts)
+		}
+		b.set("logging", logging)
+	}
+	if len(host.DeviceRequests) > 0 {
+		req := host.DeviceRequests[0]
+		dev := mapping()
+		if req.Driver != "" {
+			dev.Content = append(dev.Content, plain("driver"), quoted(req.Driver))
+		}
+		switch {
+		case len(req.DeviceIDs) > 0:
+			dev.Content = append(dev.Content, plain("device_ids"), litSeq(req.DeviceIDs))
+		case req.Count < 0:
+			dev.Content = append(dev.Content, plain("count"), plain("all"))
+		default:
+			dev.Content = append(dev.Content, plain("count"), plain(strconv.Itoa(req.Count)))
+		}
+		if len(req.Capabilities) > 0 {
+			dev.Content = append(dev.Content, plain("capabilities"), litSeq(req.Capabilities[0]))
+		}
+		b.set("deploy", deviceReservation(dev))
+		if len(host.DeviceRequests) > 1 {
+			a.warnf("%s reserves several kinds of devices; only the first was kept", c.Name)
+		}
+	}
+	return b
+}
+
+// portMappings turns port bindings into the short port syntax, sorted by
+// container port.
+func portMappings(bindings map[string][]struct {
+	HostIP   string `json:"HostIp"`
+	HostPort string `json:"HostPort"`
+}) []string {
+	keys := make([]string, 0, len(bindings))
+	for k := range bindings {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		pi, _ := strconv.Atoi(strings.Split(keys[i], "/")[0])
+		pj, _ := strconv.Atoi(strings.Split(keys[j], "/")[0])
+		if pi != pj {
+			return pi < pj
+		}
+		return keys[i] < keys[j]
+	})
+	var ports []string
+	for _, k := range keys {
+		target := strings.TrimSuffix(k, "/tcp")
+		for _, b := range bindings[k] {
+			p := target
+			if b.HostPort != "" {
+				p = b.HostPort + ":" + p
+			}
+			switch ip := b.HostIP; {
+			case ip == "" || ip == "0.0.0.0" || ip == "::":
+			case strings.Contains(ip, ":"):
+				p = "[" + ip + "]:" + p
+			default:
+				p = ip + ":" + p
+			}
+			ports = append(ports, p)
+		}
+	}
+	return ports
+}
+
+// addMounts records the volumes and tmpfs mounts of a container.
+func (a *adoption) addMounts(b *serviceBuilder, c *adoptInspect, img *ImageDefaults) {
+	mounts := c.Mounts
+	sort.SliceStable(mounts, func(i, j int) bool { return mounts[i].Destination < mounts[j].Destination })
+	for _, m := range mounts {
+		ro := ""
+		if !m.RW {
+			ro = ":ro"
+		}
+		switch m.Type {
+		case "bind":
+			b.appendTo("volumes", lit(m.Source+":"+m.Destination+ro))
+		case "volume":
+			if anonymousVolumeName.MatchString(m.Name) {
+				if !slices.Contains(img.Volumes, m.Destination) {
+					b.appendTo("volumes", lit(m.Destination))
+				}
+				a.warnf("the anonymous volume of %s at %s isn't carried over to recreated containers; copy its data to a named volume to keep it",
+					c.Name, m.Destination)
+				continue
+			}
+			key := a.resourceKey(m.Name)
+			if name, ok := a.volumes[key]; ok && name != m.Name {
+				key = m.Name
+			}
+			a.volumes[key] = m.Name
+			b.appendTo("volumes", lit(key+":"+m.Destination+ro))
+		case "tmpfs":
+			if _, ok := c.HostConfig.Tmpfs[m.Destination]; !ok {
+				b.appendTo("tmpfs", lit(m.Destination))
+			}
+		default:
+			a.warnf("the %s mount of %s at %s isn't supported and was left out", m.Type, c.Name, m.Destination)
+		}
+	}
+	if len(c.HostConfig.Tmpfs) > 0 {
+		paths := make([]string, 0, len(c.HostConfig.Tmpfs))
+		for p := range c.HostConfig.Tmpfs {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+		for _, p := range paths {
+			if opts := c.HostConfig.Tmpfs[p]; opts != "" {
+				p += ":" + opts
+			}
+			b.appendTo("tmpfs", lit(p))
+		}
+	}
+}
+
+// addNetworks records the networks a container is connected to. The default
+// network of the project, or the default bridge, is what the service joins
+// without a networks key.
+func (a *adoption) addNetworks(b *serviceBuilder, c *adoptInspect) {
+	names := make([]string, 0, len(c.NetworkSettings.Networks))
+	for n := range c.NetworkSettings.Networks {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	networks := mapping()
+	custom := false
+	for _, n := range names {
+		if n == "bridge" || (a.project != "" && n == a.project+"_default") {
+			networks.Content = append(networks.Content, plain("default"), mapping())
+			continue
+		}
+		custom = true
+		key := a.resourceKey(n)
+		if name, ok := a.networks[key]; (ok && name != n) || key == "default" {
+			key = n
+		}
+		a.networks[key] = n
+		settings := mapping()
+		if ipam := c.NetworkSettings.Networks[n].IPAMConfig; ipam != nil {
+			if ipam.IPv4Address != "" {
+				settings.Content = append(settings.Content, plain("ipv4_address"), quoted(ipam.IPv4Address))
+			}
+			if ipam.IPv6Address != "" {
+				settings.Content = append(settings.Content, plain("ipv6_address"), quoted(ipam.IPv6Address))
+			}
+		}
+		networks.Content = append(networks.Content, quoted(key), settings)
+	}
+	if !custom {
+		return
+	}
+	// A list is enough without per network settings
+	plainList := true
+	for i := 1; i < len(networks.Content); i += 2 {
+		plainList = plainList && len(networks.Content[i].Content) == 0
+	}
+	if plainList {
+		list := seq()
+		for i := 0; i < len(networks.Content); i += 2 {
+			list.Content = append(list.Content, networks.Content[i])
+		}
+		b.set("networks", list)
+		return
+	}
+	b.set("networks", networks)
+}
+
+// lit is a string taken from the daemon, with dollars escaped from compose
+// interpolation.
+func lit(s string) *yaml.Node {
+	return quoted(strings.ReplaceAll(s, "$", "$$"))
+}
+
+func litSeq(values []string) *yaml.Node {
+	list := seq()
+	for _, v := range values {
+		list.Content = append(list.Content, lit(v))
+	}
+	return list
+}
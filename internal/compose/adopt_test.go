@@ -0,0 +1,241 @@
+package compose
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestAdoptContainersProject(t *testing.T) {
+	t.Parallel()
+	web := `{
+		"Id": "4f2a9c1b7e3d0000000000000000000000000000000000000000000000000000",
+		"Name": "/blog-web-1",
+		"Config": {
+			"Hostname": "4f2a9c1b7e3d",
+			"Image": "ghost:5",
+			"Env": ["url=https://blog.example.com", "PASS=a$b", "PATH=/usr/local/bin:/usr/bin", "NODE_VERSION=20"],
+			"Cmd": ["node", "current/index.js"],
+			"Entrypoint": ["docker-entrypoint.sh"],
+			"WorkingDir": "/var/lib/ghost",
+			"Labels": {
+				"com.docker.compose.project": "blog",
+				"com.docker.compose.service": "web",
+				"org.opencontainers.image.version": "5",
+				"traefik.enable": "true"
+			}
+		},
+		"HostConfig": {
+			"NetworkMode": "blog_default",
+			"PortBindings": {"2368/tcp": [{"HostIp": "127.0.0.1", "HostPort": "8080"}], "53/udp": [{"HostIp": "", "HostPort": "53"}]},
+			"RestartPolicy": {"Name": "unless-stopped", "MaximumRetryCount": 0},
+			"ShmSize": 67108864,
+			"LogConfig": {"Type": "json-file", "Config": {}}
+		},
+		"Mounts": [
+			{"Type": "volume", "Name": "blog_content", "Source": "/var/lib/docker/volumes/blog_content/_data", "Destination": "/var/lib/ghost/content", "RW": true},
+			{"Type": "bind", "Source": "/srv/blog/config.json", "Destination": "/var/lib/ghost/config.production.json", "RW": false}
+		],
+		"NetworkSettings": {"Networks": {"blog_default": {}, "proxy": {}}}
+	}`
+	db := `{
+		"Id": "9b1c000000000000000000000000000000000000000000000000000000000000",
+		"Name": "/blog_db_1",
+		"Config": {
+			"Hostname": "db",
+			"Image": "mysql:8",
+			"Env": ["MYSQL_ROOT_PASSWORD=secret", "PATH=/usr/bin"],
+			"Cmd": ["mysqld"],
+			"Labels": {"com.docker.compose.project": "blog", "com.docker.compose.service": "db"}
+		},
+		"HostConfig": {
+			"NetworkMode": "blog_default",
+			"RestartPolicy": {"Name": "on-failure", "MaximumRetryCount": 3},
+			"LogConfig": {"Type": "json-file", "Config": {"max-size": "10m"}}
+		},
+		"Mounts": [
+			{"Type": "volume", "Name": "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", "Destination": "/var/lib/mysql", "RW": true}
+		],
+		"NetworkSettings": {"Networks": {"blog_default": {"IPAMConfig": {"IPv4Address": "172.20.0.5"}}}}
+	}`
+	got, err := AdoptContainers("blog", []AdoptSource{
+		{Inspect: json.RawMessage(web), Image: ImageDefaults{
+			Env:        []string{"PATH=/usr/local/bin:/usr/bin", "NODE_VERSION=20"},
+			Cmd:        []string{"node", "current/index.js"},
+			Entrypoint: []string{"docker-entrypoint.sh"},
+			WorkingDir: "/var/lib/ghost",
+			Labels:     map[string]string{"org.opencontainers.image.version": "5"},
+		}},
+		{Inspect: json.RawMessage(db), Image: ImageDefaults{
+			Env:     []string{"PATH=/usr/bin"},
+			Cmd:     []string{"mysqld"},
+			Volumes: []string{"/var/lib/mysql"},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `services:
+  db:
+    image: mysql:8
+    hostname: db
+    restart: on-failure:3
+    environment:
+      - MYSQL_ROOT_PASSWORD=secret
+    logging:
+      driver: json-file
+      options:
+        max-size: 10m
+  web:
+    image: ghost:5
+    restart: unless-stopped
+    ports:
+      - "53:53/udp"
+      - "127.0.0.1:8080:2368"
+    environment:
+      - url=https://blog.example.com
+      - PASS=a$$b
+    volumes:
+      - /srv/blog/config.json:/var/lib/ghost/config.production.json:ro
+      - content:/var/lib/ghost/content
+    networks:
+      - default
+      - proxy
+    labels:
+      - traefik.enable=true
+volumes:
+  content:
+    name: blog_content
+    external: true
+networks:
+  proxy:
+    name: proxy
+    external: true
+`
+	if got.YAML != want {
+		t.Errorf("yaml:\n%s\nwant:\n%s", got.YAML, want)
+	}
+	if strings.Join(got.Services, ",") != "db,web" {
+		t.Errorf("services = %v", got.Services)
+	}
+	// The anonymous mysql volume is the one to worry about
+	if len(got.Warnings) != 1 || !strings.Contains(got.Warnings[0], "/var/lib/mysql") {
+		t.Errorf("warnings = %v", got.Warnings)
+	}
+}
+
+func TestAdoptContainersStandalone(t *testing.T) {
+	t.Parallel()
+	plex := `{
+		"Id": "aa00000000000000000000000000000000000000000000000000000000000000",
+		"Name": "/plex",
+		"Config": {
+			"Hostname": "media",
+			"Image": "plexinc/pms-docker",
+			"Env": ["TZ=UTC"],
+			"Tty": true,
+			"Labels": {}
+		},
+		"HostConfig": {
+			"NetworkMode": "host",
+			"RestartPolicy": {"Name": "always"},
+			"Devices": [{"PathOnHost": "/dev/dri", "PathInContainer": "/dev/dri", "CgroupPermissions": "rwm"}],
+			"DeviceRequests": [{"Driver": "nvidia", "Count": -1, "Capabilities": [["gpu"]]}],
+			"CapAdd": ["NET_ADMIN"],
+			"Tmpfs": {"/transcode": "size=1g"},
+			"Memory": 2147483648,
+			"NanoCpus": 1500000000
+		},
+		"Mounts": [{"Type": "bind", "Source": "/mnt/media", "Destination": "/data", "RW": true}]
+	}`
+	sidecar := `{
+		"Id": "bb00000000000000000000000000000000000000000000000000000000000000",
+		"Name": "/Plex.Sidecar",
+		"Config": {"Image": "sha256:deadbeef"},
+		"HostConfig": {"NetworkMode": "container:aa00"}
+	}`
+	got, err := AdoptContainers("", []AdoptSource{{Inspect: json.RawMessage(plex)}, {Inspect: json.RawMessage(sidecar)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `services:
+  plex:
+    image: plexinc/pms-docker
+    container_name: plex
+    restart: always
+    environment:
+      - TZ=UTC
+    volumes:
+      - /mnt/media:/data
+    tmpfs:
+      - /transcode:size=1g
+    network_mode: host
+    cap_add:
+      - NET_ADMIN
+    devices:
+      - /dev/dri:/dev/dri
+    tty: true
+    mem_limit: 2147483648
+    cpus: 1.5
+    deploy:
+      resources:
+        reservations:
+          devices:
+            - driver: nvidia
+              count: all
+              capabilities:
+                - gpu
+  plex-sidecar:
+    image: sha256:deadbeef
+    container_name: Plex.Sidecar
+`
+	if got.YAML != want {
+		t.Errorf("yaml:\n%s\nwant:\n%s", got.YAML, want)
+	}
+	// Image ID and shared network namespace
+	if len(got.Warnings) != 2 {
+		t.Errorf("warnings = %v", got.Warnings)
+	}
+}
+
+func TestAdoptContainersNames(t *testing.T) {
+	t.Parallel()
+	ctr := func(name, service, network string) AdoptSource {
+		labels := map[string]string{}
+		if service != "" {
+			labels["com.docker.compose.service"] = service
+		}
+		raw, _ := json.Marshal(map[string]any{
+			"Name":            "/" + name,
+			"Config":          map[string]any{"Image": "alpine", "Labels": labels},
+			"NetworkSettings": map[string]any{"Networks": map[string]any{network: map[string]any{"IPAMConfig": map[string]any{"IPv4Address": "10.0.0.2"}}}},
+		})
+		return AdoptSource{Inspect: raw}
+	}
+
+	// Replicas of a scaled service become one service
+	got, err := AdoptContainers("app", []AdoptSource{ctr("app-worker-2", "worker", "app_back"), ctr("app-worker-1", "worker", "app_back")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Services) != 1 || len(got.Warnings) != 1 || !strings.Contains(got.Warnings[0], "app-worker-2") {
+		t.Errorf("replicas: %v %v", got.Services, got.Warnings)
+	}
+	if !strings.Contains(got.YAML, "    networks:\n      back:\n        ipv4_address: 10.0.0.2\n") ||
+		!strings.Contains(got.YAML, "networks:\n  back:\n    name: app_back\n    external: true\n") {
+		t.Errorf("networks:\n%s", got.YAML)
+	}
+
+	// Standalone containers whose names sanitize alike stay apart
+	got, err = AdoptContainers("", []AdoptSource{ctr("Web-1", "", "bridge"), ctr("web-1", "", "bridge")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got.Services, ",") != "web-1,web-1-2" {
+		t.Errorf("services = %v", got.Services)
+	}
+
+	if _, err := AdoptContainers("", nil); err == nil {
+		t.Error("expected an error without containers")
+	}
+}
@@ -0,0 +1,165 @@
+package compose
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Compose files that share config through anchors (x-common: &common),
+// aliases (*common) and merge keys (<<: *common) can't be read line by
+// line: a service's image or labels may be defined in another block, and
+// "app: &app" doesn't look like a service name. Files using them are
+// decoded with yaml.v3, which resolves all three; the rest keep the fast
+// line scanners.
+
+// yamlRefRe matches an anchor or alias token, or a merge key. A match in a
+// quoted string only costs a full parse.
+var yamlRefRe = regexp.MustCompile(`(?m)(^|[\s\[{,:-])[&*][A-Za-z0-9_.-]+|<<\s*:`)
+
+// usesYAMLReferences reports whether yaml has anchors, aliases or merge
+// keys.
+func usesYAMLReferences(yaml string) bool {
+	return yamlRefRe.MatchString(yaml)
+}
+
+// composeDoc is the part of a compose file the cache and checks read.
+type composeDoc struct {
+	Services map[string]composeDocService  `yaml:"services"`
+	Networks map[string]composeDocResource `yaml:"networks"`
+	Volumes  map[string]composeDocResource `yaml:"volumes"`
+}
+
+type composeDocService struct {
+	Image     string    `yaml:"image"`
+	Restart   string    `yaml:"restart"`
+	Labels    any       `yaml:"labels"` // map or list of "key=value"
+	DependsOn yaml.Node `yaml:"depends_on"`
+}
+
+type composeDocResource struct {
+	Name     string `yaml:"name"`
+	External any    `yaml:"external"`
+}
+
+// decodeCompose fully parses compose YAML, resolving anchors, aliases and
+// merge keys.
+func decodeCompose(data string) (*composeDoc, error) {
+	var doc composeDoc
+	if err := yaml.Unmarshal([]byte(data), &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// serviceDataFromDoc extracts the same data as parseScanner from a decoded
+// file.
+func serviceDataFromDoc(doc *composeDoc) map[string]ServiceData {
+	result := make(map[string]ServiceData, len(doc.Services))
+	for name, svc := range doc.Services {
+		sd := ServiceData{Image: svc.Image, ImageUpdatesCheck: true, Restart: svc.Restart}
+		for key, val := range labelMap(svc.Labels) {
+			switch key {
+			case "dockge.status.ignore":
+				sd.StatusIgnore = val == "true"
+			case "dockge.imageupdates.check":
+				sd.ImageUpdatesCheck = val != "false"
+			case "dockge.imageupdates.ignore":
+				sd.ImageUpdatesIgnore = val
+			case "dockge.depends_on":
+				sd.DependsOnStacks = val
+			case "dockge.job":
+				sd.JobLabel = val
+			}
+		}
+		result[name] = sd
+	}
+	return result
+}
+
+// labelMap normalizes the map and "key=value" list forms of labels:.
+func labelMap(labels any) map[string]string {
+	result := make(map[string]string)
+	switch l := labels.(type) {
+	case map[string]any:
+		for k, v := range l {
+			if v != nil {
+				result[k] = fmt.Sprint(v)
+			}
+		}
+	case []any:
+		for _, item := range l {
+			k, v, _ := strings.Cut(fmt.Sprint(item), "=")
+			result[k] = v
+		}
+	}
+	return result
+}
+
+// dependenciesFromDoc extracts depends_on entries like ParseDependencies.
+// Node order is kept so entries stay in file order.
+func dependenciesFromDoc(doc *composeDoc) map[string][]Dependency {
+	result := make(map[string][]Dependency)
+	for name, svc := range doc.Services {
+		n := resolveAlias(&svc.DependsOn)
+		switch n.Kind {
+		case yaml.SequenceNode:
+			for _, item := range n.Content {
+				if item = resolveAlias(item); item.Value != "" {
+					result[name] = append(result[name], Dependency{Service: item.Value, Condition: DependsStarted})
+				}
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				dep := Dependency{Service: n.Content[i].Value, Condition: DependsStarted}
+				var opts struct {
+					Condition string `yaml:"condition"`
+				}
+				if resolveAlias(n.Content[i+1]).Decode(&opts) == nil && opts.Condition != "" {
+					dep.Condition = opts.Condition
+				}
+				result[name] = append(result[name], dep)
+			}
+		}
+	}
+	return result
+}
+
+// externalFromDoc extracts the external networks and volumes like
+// ParseExternalResources, sorted by key.
+func externalFromDoc(doc *composeDoc) ExternalResources {
+	var res ExternalResources
+	collect := func(resources map[string]composeDocResource) []string {
+		keys := make([]string, 0, len(resources))
+		for k := range resources {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var refs []string
+		for _, k := range keys {
+			r := resources[k]
+			if external, _ := r.External.(bool); !external {
+				continue
+			}
+			if r.Name != "" {
+				k = r.Name
+			}
+			refs = append(refs, k)
+		}
+		return refs
+	}
+	res.Networks = collect(doc.Networks)
+	res.Volumes = collect(doc.Volumes)
+	return res
+}
+
+// resolveAlias follows an alias node to the node it refers to.
+func resolveAlias(n *yaml.Node) *yaml.Node {
+	for n.Kind == yaml.AliasNode && n.Alias != nil {
+		n = n.Alias
+	}
+	return n
+}
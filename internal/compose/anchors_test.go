@@ -0,0 +1,86 @@
+package compose
+
+import (
+	"reflect"
+	"testing"
+)
+
+const anchoredCompose = `x-common: &common
+  image: myapp:v3
+  restart: unless-stopped
+  labels: &labels
+    dockge.imageupdates.check: "false"
+  depends_on:
+    db:
+      condition: service_healthy
+
+services:
+  web: &web
+    <<: *common
+  worker:
+    <<: *web
+    image: myapp-worker:v3
+    labels:
+      - dockge.status.ignore=true
+  db:
+    image: postgres:16
+    labels: *labels
+
+networks:
+  shared: &ext
+    external: true
+volumes:
+  media:
+    <<: *ext
+    name: nas_media
+`
+
+func TestParseYAMLAnchors(t *testing.T) {
+	t.Parallel()
+	got := ParseYAML(anchoredCompose)
+	want := map[string]ServiceData{
+		"web":    {Image: "myapp:v3", Restart: "unless-stopped"},
+		"worker": {Image: "myapp-worker:v3", StatusIgnore: true, ImageUpdatesCheck: true, Restart: "unless-stopped"},
+		"db":     {Image: "postgres:16"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseYAML() = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseDependenciesAnchors(t *testing.T) {
+	t.Parallel()
+	got := ParseDependencies(anchoredCompose)
+	want := map[string][]Dependency{
+		"web":    {{Service: "db", Condition: DependsHealthy}},
+		"worker": {{Service: "db", Condition: DependsHealthy}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseDependencies() = %v, want %v", got, want)
+	}
+}
+
+func TestParseExternalResourcesAnchors(t *testing.T) {
+	t.Parallel()
+	got := ParseExternalResources(anchoredCompose)
+	want := ExternalResources{Networks: []string{"shared"}, Volumes: []string{"nas_media"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseExternalResources() = %+v, want %+v", got, want)
+	}
+}
+
+func TestUsesYAMLReferences(t *testing.T) {
+	t.Parallel()
+	tests := map[string]bool{
+		"services:\n  app:\n    image: nginx\n":                false,
+		"services:\n  app:\n    image: registry.io/img#sha\n":  false,
+		"services:\n  app: &app\n    image: nginx\n":           true,
+		"services:\n  app:\n    <<: *common\n":                 true,
+		"services:\n  app:\n    environment:\n      - A=*b\n": false,
+	}
+	for yaml, want := range tests {
+		if got := usesYAMLReferences(yaml); got != want {
+			t.Errorf("usesYAMLReferences(%q) = %v, want %v", yaml, got, want)
+		}
+	}
+}
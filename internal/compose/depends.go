@@ -0,0 +1,100 @@
+package compose
+
+import (
+	"bufio"
+	"strings"
+)
+
+// depends_on conditions. The short list syntax means DependsStarted.
+const (
+	DependsStarted   = "service_started"
+	DependsHealthy   = "service_healthy"
+	DependsCompleted = "service_completed_successfully"
+)
+
+// Dependency is one depends_on entry of a service.
+type Dependency struct {
+	Service   string `json:"service"`
+	Condition string `json:"condition"`
+}
+
+// ParseDependencies extracts each service's depends_on entries from compose
+// YAML, in file order. Both the short list form (block or flow) and the
+// long map form with condition: are recognized. Same line-scanning
+// assumptions as parseScanner, except that files with anchors, aliases or
+// merge keys are fully decoded.
+func ParseDependencies(yaml string) map[string][]Dependency {
+	if usesYAMLReferences(yaml) {
+		if doc, err := decodeCompose(yaml); err == nil {
+			return dependenciesFromDoc(doc)
+		}
+	}
+	result := make(map[string][]Dependency)
+	scanner := bufio.NewScanner(strings.NewReader(yaml))
+
+	inServices := false
+	current := ""
+	inDepends := false
+	dependsIndent := 0
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "services:" {
+			inServices = true
+			continue
+		}
+		if !inServices || line == "" || strings.TrimSpace(line)[0] == '#' {
+			continue
+		}
+		if line[0] != ' ' {
+			break
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		stripped := stripInlineComment(strings.TrimSpace(line))
+
+		if indent == 2 && strings.HasSuffix(stripped, ":") {
+			current = strings.TrimSuffix(stripped, ":")
+			inDepends = false
+			continue
+		}
+		if current == "" {
+			continue
+		}
+
+		if inDepends && indent > dependsIndent {
+			deps := result[current]
+			switch {
+			case strings.HasPrefix(stripped, "- "):
+				// Short syntax: "- db"
+				deps = append(deps, Dependency{Service: unquote(strings.TrimPrefix(stripped, "- ")), Condition: DependsStarted})
+			case indent == dependsIndent+2 && strings.HasSuffix(stripped, ":"):
+				// Long syntax: "db:" followed by its options
+				deps = append(deps, Dependency{Service: unquote(strings.TrimSuffix(stripped, ":")), Condition: DependsStarted})
+			case strings.HasPrefix(stripped, "condition:") && len(deps) > 0:
+				deps[len(deps)-1].Condition = unquote(strings.TrimSpace(strings.TrimPrefix(stripped, "condition:")))
+			}
+			result[current] = deps
+			continue
+		}
+		inDepends = false
+
+		if stripped == "depends_on:" {
+			inDepends = true
+			dependsIndent = indent
+			continue
+		}
+		if value, ok := strings.CutPrefix(stripped, "depends_on:"); ok {
+			// Flow syntax: "depends_on: [db, cache]"
+			value = strings.Trim(strings.TrimSpace(value), "[]")
+			for _, name := range strings.Split(value, ",") {
+				if name = unquote(strings.TrimSpace(name)); name != "" {
+					result[current] = append(result[current], Dependency{Service: name, Condition: DependsStarted})
+				}
+			}
+		}
+	}
+	return result
+}
+
+func unquote(s string) string {
+	return strings.Trim(s, "\"'")
+}
@@ -0,0 +1,50 @@
+package compose
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDependencies(t *testing.T) {
+	t.Parallel()
+	yaml := `services:
+  web:
+    image: nginx
+    depends_on:
+      app:
+        condition: service_healthy
+        restart: true
+      "cache":
+        condition: service_started # default
+    ports:
+      - 80:80
+  app:
+    depends_on:
+      - db
+      - migrate
+  worker:
+    depends_on: [db, "app"]
+  db:
+    image: postgres
+volumes:
+  data:
+`
+	got := ParseDependencies(yaml)
+	want := map[string][]Dependency{
+		"web": {
+			{Service: "app", Condition: DependsHealthy},
+			{Service: "cache", Condition: DependsStarted},
+		},
+		"app": {
+			{Service: "db", Condition: DependsStarted},
+			{Service: "migrate", Condition: DependsStarted},
+		},
+		"worker": {
+			{Service: "db", Condition: DependsStarted},
+			{Service: "app", Condition: DependsStarted},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %+v\nwant %+v", got, want)
+	}
+}
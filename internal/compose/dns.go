@@ -0,0 +1,168 @@
+package compose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// DNSServers maps each custom dns: server in the project to the services
+// that use it.
+func (p *ResolvedProject) DNSServers() map[string][]string {
+	servers := make(map[string][]string)
+	for _, name := range sortedKeys(p.Services) {
+		for _, server := range p.Services[name].DNS {
+			servers[server] = append(servers[server], name)
+		}
+	}
+	return servers
+}
+
+// CheckDNSServers probes every custom DNS server in the project and returns
+// one problem per server that didn't answer. probe is ProbeDNSServer outside
+// of tests.
+func (p *ResolvedProject) CheckDNSServers(ctx context.Context, probe func(context.Context, string) error) []string {
+	servers := p.DNSServers()
+	var problems []string
+	for _, server := range sortedKeys(servers) {
+		if err := probe(ctx, server); err != nil {
+			problems = append(problems, fmt.Sprintf("DNS server %s (used by %s) is not reachable: %v", server, strings.Join(servers[server], ", "), err))
+		}
+	}
+	return problems
+}
+
+// ProbeDNSServer sends a query for the root NS records straight to server
+// (port 53 unless given). Any DNS answer, including NXDOMAIN or REFUSED for
+// the query itself, counts as reachable; only timeouts and network errors
+// don't.
+func ProbeDNSServer(ctx context.Context, server string) error {
+	addr := server
+	if net.ParseIP(server) != nil {
+		addr = net.JoinHostPort(server, "53")
+	} else if _, _, err := net.SplitHostPort(server); err != nil {
+		return fmt.Errorf("invalid address %q", server)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+	_, err := resolver.LookupNS(ctx, ".")
+	var dnsErr *net.DNSError
+	if err == nil || (errors.As(err, &dnsErr) && !dnsErr.IsTimeout && dnsErr.IsNotFound) {
+		return nil
+	}
+	if errors.As(err, &dnsErr) && !dnsErr.IsTimeout && strings.Contains(dnsErr.Err, "server misbehaving") {
+		// SERVFAIL/REFUSED: something answered.
+		return nil
+	}
+	return err
+}
+
+// SetServiceList replaces a list-valued service key (dns, dns_search,
+// extra_hosts, ...) in compose YAML with a block list of values, keeping
+// everything else byte-for-byte. Scalar, flow and block forms of the old
+// value are all replaced; empty values remove the key. A missing key is
+// added right after the service name. Uses the same line-scanning
+// assumptions as SetServiceImage. Returns false if the service isn't found.
+func SetServiceList(yaml, service, key string, values []string) (string, bool) {
+	lines := strings.Split(yaml, "\n")
+	inServices := false
+	current := ""
+	header := -1
+	keyIndent := 4
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimRight(line, " \t\r")
+		if trimmed == "services:" {
+			inServices = true
+			continue
+		}
+		if !inServices || trimmed == "" || strings.TrimSpace(trimmed)[0] == '#' {
+			continue
+		}
+		if trimmed[0] != ' ' {
+			break
+		}
+		if len(line) > 2 && line[:2] == "  " && line[2] != ' ' && strings.HasSuffix(trimmed, ":") {
+			if current == service {
+				break
+			}
+			current = strings.TrimSpace(strings.TrimSuffix(trimmed, ":"))
+			if current == service {
+				header = i
+			}
+			continue
+		}
+		if current != service {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		stripped := strings.TrimSpace(trimmed)
+		if indent < 4 || indent >= 6 {
+			continue
+		}
+		keyIndent = indent
+		if stripped != key+":" && !strings.HasPrefix(stripped, key+": ") {
+			continue
+		}
+
+		// Found the key: drop it along with its nested value lines.
+		end := i + 1
+		for end < len(lines) {
+			next := strings.TrimRight(lines[end], " \t\r")
+			nextIndent := len(next) - len(strings.TrimLeft(next, " "))
+			// Block sequences may sit at the same indent as their key.
+			if next != "" && nextIndent < indent || (nextIndent == indent && !strings.HasPrefix(strings.TrimSpace(next), "- ")) {
+				break
+			}
+			end++
+		}
+		// Keep trailing blank lines that separate this key from the next.
+		for end > i+1 && strings.TrimSpace(lines[end-1]) == "" {
+			end--
+		}
+		block := serviceListLines(indent, key, values)
+		lines = append(lines[:i], append(block, lines[end:]...)...)
+		return strings.Join(lines, "\n"), true
+	}
+	if header < 0 {
+		return yaml, false
+	}
+	if len(values) > 0 {
+		block := serviceListLines(keyIndent, key, values)
+		lines = append(lines[:header+1], append(block, lines[header+1:]...)...)
+	}
+	return strings.Join(lines, "\n"), true
+}
+
+func serviceListLines(indent int, key string, values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	pad := strings.Repeat(" ", indent)
+	lines := []string{pad + key + ":"}
+	for _, v := range values {
+		lines = append(lines, pad+"  - "+yamlScalar(v))
+	}
+	return lines
+}
+
+// yamlScalar quotes v when it wouldn't survive as a plain YAML scalar.
+func yamlScalar(v string) string {
+	if v == "" || strings.ContainsAny(v[:1], "!&*?|>'\"%@`#{}[],-:") ||
+		strings.Contains(v, ": ") || strings.Contains(v, " #") ||
+		strings.HasSuffix(v, ":") || strings.TrimSpace(v) != v {
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+	}
+	return v
+}
@@ -0,0 +1,87 @@
+package compose
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheckDNSServers(t *testing.T) {
+	t.Parallel()
+	p, err := ParseResolvedConfig([]byte(`{
+	  "name": "dns",
+	  "services": {
+	    "a": {"image": "x", "dns": ["1.1.1.1", "10.0.0.53"]},
+	    "b": {"image": "x", "dns": ["10.0.0.53"]},
+	    "c": {"image": "x"}
+	  }
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	probe := func(_ context.Context, server string) error {
+		if server == "10.0.0.53" {
+			return errors.New("i/o timeout")
+		}
+		return nil
+	}
+	problems := p.CheckDNSServers(context.Background(), probe)
+	if len(problems) != 1 || !strings.Contains(problems[0], "10.0.0.53 (used by a, b)") {
+		t.Errorf("got %q", problems)
+	}
+}
+
+func TestSetServiceList(t *testing.T) {
+	t.Parallel()
+	yaml := "services:\n" +
+		"  web:\n" +
+		"    image: nginx\n" +
+		"    dns: 8.8.8.8 # google\n" +
+		"    extra_hosts:\n" +
+		"      - \"db.local:10.0.0.5\"\n" +
+		"      - cache.local:10.0.0.6\n" +
+		"\n" +
+		"    restart: always\n" +
+		"  db:\n" +
+		"    image: postgres\n"
+
+	tests := []struct {
+		name    string
+		service string
+		key     string
+		values  []string
+		want    string
+	}{
+		{
+			name: "replace scalar", service: "web", key: "dns", values: []string{"1.1.1.1", "9.9.9.9"},
+			want: "    image: nginx\n    dns:\n      - 1.1.1.1\n      - 9.9.9.9\n    extra_hosts:\n",
+		},
+		{
+			name: "replace block", service: "web", key: "extra_hosts", values: []string{"host.docker.internal:host-gateway"},
+			want: "    extra_hosts:\n      - host.docker.internal:host-gateway\n\n    restart: always\n",
+		},
+		{
+			name: "remove", service: "web", key: "extra_hosts", values: nil,
+			want: "    dns: 8.8.8.8 # google\n\n    restart: always\n",
+		},
+		{
+			name: "insert", service: "db", key: "dns_search", values: []string{"lan", "::1"},
+			want: "  db:\n    dns_search:\n      - lan\n      - \"::1\"\n    image: postgres\n",
+		},
+	}
+	for _, tt := range tests {
+		got, ok := SetServiceList(yaml, tt.service, tt.key, tt.values)
+		if !ok {
+			t.Errorf("%s: service not found", tt.name)
+			continue
+		}
+		if !strings.Contains(got, tt.want) {
+			t.Errorf("%s: got:\n%s\nwant substring:\n%s", tt.name, got, tt.want)
+		}
+	}
+
+	if _, ok := SetServiceList(yaml, "cache", "dns", []string{"1.1.1.1"}); ok {
+		t.Error("expected missing service to report false")
+	}
+}
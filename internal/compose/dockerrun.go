@@ -0,0 +1,855 @@
+package compose
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DockerRunConversion is a compose file generated from a docker run
+// command, with the parts of the command it couldn't carry over.
+type DockerRunConversion struct {
+	YAML     string   `json:"yaml"`
+	Service  string   `json:"service"`
+	Warnings []string `json:"warnings"`
+}
+
+// runFlag describes a docker run flag: whether it takes a value and how it
+// carries over to the service.
+type runFlag struct {
+	name  string // long name without the dashes
+	value bool   // takes a value
+	// apply records the flag in the service; nil for flags that have no
+	// compose equivalent and are dropped with a warning
+	apply func(r *runConversion, val string) error
+	// quiet drops a flag without apply silently: it only matters to an
+	// interactive docker run, e.g. --detach
+	quiet bool
+}
+
+// runShortFlags maps the short flags of docker run to their long names.
+var runShortFlags = map[byte]string{
+	'a': "attach", 'c': "cpu-shares", 'd': "detach", 'e': "env", 'h': "hostname",
+	'i': "interactive", 'l': "label", 'm': "memory", 'p': "publish", 'P': "publish-all",
+	'q': "quiet", 't': "tty", 'u': "user", 'v': "volume", 'w': "workdir",
+}
+
+// runFlags lists the docker run flags the converter understands.
+var runFlags = map[string]*runFlag{}
+
+func init() {
+	str := func(key string) func(r *runConversion, val string) error {
+		return func(r *runConversion, val string) error {
+			r.set(key, quoted(val))
+			return nil
+		}
+	}
+	list := func(key string) func(r *runConversion, val string) error {
+		return func(r *runConversion, val string) error {
+			r.appendTo(key, quoted(val))
+			return nil
+		}
+	}
+	boolean := func(key string) func(r *runConversion, val string) error {
+		return func(r *runConversion, _ string) error {
+			r.set(key, plain("true"))
+			return nil
+		}
+	}
+	number := func(key string) func(r *runConversion, val string) error {
+		return func(r *runConversion, val string) error {
+			if _, err := strconv.ParseFloat(val, 64); err != nil {
+				return fmt.Errorf("--%s: %q is not a number", key, val)
+			}
+			r.set(key, plain(val))
+			return nil
+		}
+	}
+	for _, f := range []*runFlag{
+		{name: "name", value: true, apply: func(r *runConversion, val string) error {
+			r.name = val
+			r.set("container_name", quoted(val))
+			return nil
+		}},
+		{name: "publish", value: true, apply: list("ports")},
+		{name: "expose", value: true, apply: list("expose")},
+		{name: "volume", value: true, apply: (*runConversion).addVolume},
+		{name: "mount", value: true, apply: (*runConversion).addMount},
+		{name: "tmpfs", value: true, apply: list("tmpfs")},
+		{name: "env", value: true, apply: list("environment")},
+		{name: "env-file", value: true, apply: list("env_file")},
+		{name: "label", value: true, apply: list("labels")},
+		{name: "restart", value: true, apply: str("restart")},
+		{name: "network", value: true, apply: (*runConversion).setNetwork},
+		{name: "net", value: true, apply: (*runConversion).setNetwork},
+		{name: "network-alias", value: true, apply: func(r *runConversion, val string) error {
+			r.aliases = append(r.aliases, val)
+			return nil
+		}},
+		{name: "net-alias", value: true, apply: func(r *runConversion, val string) error {
+			r.aliases = append(r.aliases, val)
+			return nil
+		}},
+		{name: "ip", value: true, apply: func(r *runConversion, val string) error {
+			r.ipv4 = val
+			return nil
+		}},
+		{name: "ip6", value: true, apply: func(r *runConversion, val string) error {
+			r.ipv6 = val
+			return nil
+		}},
+		{name: "gpus", value: true, apply: (*runConversion).setGPUs},
+		{name: "device", value: true, apply: list("devices")},
+		{name: "runtime", value: true, apply: str("runtime")},
+		{name: "privileged", apply: boolean("privileged")},
+		{name: "cap-add", value: true, apply: list("cap_add")},
+		{name: "cap-drop", value: true, apply: list("cap_drop")},
+		{name: "security-opt", value: true, apply: list("security_opt")},
+		{name: "workdir", value: true, apply: str("working_dir")},
+		{name: "user", value: true, apply: str("user")},
+		{name: "hostname", value: true, apply: str("hostname")},
+		{name: "domainname", value: true, apply: str("domainname")},
+		{name: "mac-address", value: true, apply: str("mac_address")},
+		{name: "entrypoint", value: true, apply: func(r *runConversion, val string) error {
+			r.entrypoint = val
+			return nil
+		}},
+		{name: "interactive", apply: boolean("stdin_open")},
+		{name: "tty", apply: boolean("tty")},
+		{name: "init", apply: boolean("init")},
+		{name: "read-only", apply: boolean("read_only")},
+		{name: "oom-kill-disable", apply: boolean("oom_kill_disable")},
+		{name: "dns", value: true, apply: list("dns")},
+		{name: "dns-search", value: true, apply: list("dns_search")},
+		{name: "dns-option", value: true, apply: list("dns_opt")},
+		{name: "add-host", value: true, apply: list("extra_hosts")},
+		{name: "group-add", value: true, apply: list("group_add")},
+		{name: "sysctl", value: true, apply: list("sysctls")},
+		{name: "ulimit", value: true, apply: (*runConversion).addUlimit},
+		{name: "log-driver", value: true, apply: func(r *runConversion, val string) error {
+			r.logDriver = val
+			return nil
+		}},
+		{name: "log-opt", value: true, apply: func(r *runConversion, val string) error {
+			k, v, _ := strings.Cut(val, "=")
+			r.logOpts = append(r.logOpts, [2]string{k, v})
+			return nil
+		}},
+		{name: "health-cmd", value: true, apply: func(r *runConversion, val string) error {
+			r.health("test", seq(quoted("CMD-SHELL"), quoted(val)))
+			return nil
+		}},
+		{name: "health-interval", value: true, apply: func(r *runConversion, val string) error {
+			r.health("interval", quoted(val))
+			return nil
+		}},
+		{name: "health-timeout", value: true, apply: func(r *runConversion, val string) error {
+			r.health("timeout", quoted(val))
+			return nil
+		}},
+		{name: "health-start-period", value: true, apply: func(r *runConversion, val string) error {
+			r.health("start_period", quoted(val))
+			return nil
+		}},
+		{name: "health-retries", value: true, apply: func(r *runConversion, val string) error {
+			if _, err := strconv.Atoi(val); err != nil {
+				return fmt.Errorf("--health-retries: %q is not a number", val)
+			}
+			r.health("retries", plain(val))
+			return nil
+		}},
+		{name: "no-healthcheck", apply: func(r *runConversion, _ string) error {
+			r.health("disable", plain("true"))
+			return nil
+		}},
+		{name: "memory", value: true, apply: str("mem_limit")},
+		{name: "memory-reservation", value: true, apply: str("mem_reservation")},
+		{name: "memory-swap", value: true, apply: str("memswap_limit")},
+		{name: "shm-size", value: true, apply: str("shm_size")},
+		{name: "cpus", value: true, apply: number("cpus")},
+		{name: "cpu-shares", value: true, apply: number("cpu_shares")},
+		{name: "cpuset-cpus", value: true, apply: str("cpuset")},
+		{name: "pids-limit", value: true, apply: number("pids_limit")},
+		{name: "pid", value: true, apply: str("pid")},
+		{name: "ipc", value: true, apply: str("ipc")},
+		{name: "uts", value: true, apply: str("uts")},
+		{name: "userns", value: true, apply: str("userns_mode")},
+		{name: "cgroupns", value: true, apply: str("cgroupns_mode")},
+		{name: "cgroup-parent", value: true, apply: str("cgroup_parent")},
+		{name: "stop-signal", value: true, apply: str("stop_signal")},
+		{name: "stop-timeout", value: true, apply: func(r *runConversion, val string) error {
+			if _, err := strconv.Atoi(val); err != nil {
+				return fmt.Errorf("--stop-timeout: %q is not a number of seconds", val)
+			}
+			r.set("stop_grace_period", quoted(val+"s"))
+			return nil
+		}},
+		{name: "platform", value: true, apply: str("platform")},
+		{name: "pull", value: true, apply: str("pull_policy")},
+		{name: "storage-opt", value: true, apply: func(r *runConversion, val string) error {
+			k, v, _ := strings.Cut(val, "=")
+			r.setIn("storage_opt", k, quoted(v))
+			return nil
+		}},
+
+		// Only matter to an interactive docker run
+		{name: "detach", quiet: true},
+		{name: "rm", quiet: true},
+		{name: "quiet", quiet: true},
+		{name: "sig-proxy", value: true, quiet: true},
+		{name: "detach-keys", value: true, quiet: true},
+		{name: "attach", value: true, quiet: true},
+
+		// No compose equivalent
+		{name: "publish-all"},
+		{name: "cidfile", value: true},
+		{name: "label-file", value: true},
+		{name: "link", value: true},
+		{name: "volumes-from", value: true},
+		{name: "disable-content-trust"},
+	} {
+		runFlags[f.name] = f
+	}
+}
+
+// runConversion accumulates the service a docker run command describes.
+type runConversion struct {
+	name       string
+	image      string
+	entrypoint string
+	command    []string
+
+	serviceBuilder
+
+	networkMode string
+	network     string
+	aliases     []string
+	ipv4, ipv6  string
+
+	logDriver string
+	logOpts   [][2]string
+
+	volumes  []string // named volumes to declare
+	warnings []string
+}
+
+// serviceBuilder collects the keys of a generated service.
+type serviceBuilder struct {
+	keys   []string // in the order they were first set
+	values map[string]*yaml.Node
+}
+
+func (b *serviceBuilder) set(key string, val *yaml.Node) {
+	if b.values == nil {
+		b.values = map[string]*yaml.Node{}
+	}
+	if _, ok := b.values[key]; !ok {
+		b.keys = append(b.keys, key)
+	}
+	b.values[key] = val
+}
+
+func (b *serviceBuilder) appendTo(key string, val *yaml.Node) {
+	list, ok := b.values[key]
+	if !ok {
+		list = seq()
+		b.set(key, list)
+	}
+	list.Content = append(list.Content, val)
+}
+
+// setIn sets a key of a mapping of the service, e.g. healthcheck.
+func (b *serviceBuilder) setIn(key, sub string, val *yaml.Node) {
+	m, ok := b.values[key]
+	if !ok {
+		m = mapping()
+		b.set(key, m)
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == sub {
+			m.Content[i+1] = val
+			return
+		}
+	}
+	m.Content = append(m.Content, plain(sub), val)
+}
+
+// node returns the service with its keys in serviceKeyOrder, then in the
+// order they were set.
+func (b *serviceBuilder) node() *yaml.Node {
+	svc := mapping()
+	done := map[string]bool{}
+	for _, key := range append(serviceKeyOrder, b.keys...) {
+		if val, ok := b.values[key]; ok && !done[key] {
+			svc.Content = append(svc.Content, plain(key), val)
+			done[key] = true
+		}
+	}
+	return svc
+}
+
+func (r *runConversion) health(key string, val *yaml.Node) {
+	r.setIn("healthcheck", key, val)
+}
+
+func (r *runConversion) declareVolume(name string) {
+	for _, v := range r.volumes {
+		if v == name {
+			return
+		}
+	}
+	r.volumes = append(r.volumes, name)
+}
+
+// addVolume records a -v flag. Named volumes, whose source isn't a path,
+// are declared at the top level too.
+func (r *runConversion) addVolume(val string) error {
+	if val == "" {
+		return errors.New("--volume: empty value")
+	}
+	if src, _, ok := strings.Cut(val, ":"); ok && isNamedVolume(src) {
+		r.declareVolume(src)
+	}
+	r.appendTo("volumes", quoted(val))
+	return nil
+}
+
+func isNamedVolume(src string) bool {
+	return src != "" && !strings.HasPrefix(src, "/") && !strings.HasPrefix(src, ".") &&
+		!strings.HasPrefix(src, "~") && !strings.HasPrefix(src, "$")
+}
+
+// addMount records a --mount flag in the long volume syntax.
+func (r *runConversion) addMount(val string) error {
+	m := mapping()
+	typ := "volume"
+	var source, target string
+	var bind, vol, tmpfs []*yaml.Node
+	for _, field := range strings.Split(val, ",") {
+		k, v, hasVal := strings.Cut(field, "=")
+		switch k {
+		case "type":
+			typ = v
+		case "source", "src":
+			source = v
+		case "target", "destination", "dst":
+			target = v
+		case "readonly", "ro":
+			if !hasVal || v == "true" || v == "1" {
+				m.Content = append(m.Content, plain("read_only"), plain("true"))
+			}
+		case "bind-propagation":
+			bind = append(bind, plain("propagation"), quoted(v))
+		case "volume-nocopy":
+			if !hasVal || v == "true" || v == "1" {
+				vol = append(vol, plain("nocopy"), plain("true"))
+			}
+		case "tmpfs-size":
+			tmpfs = append(tmpfs, plain("size"), quoted(v))
+		case "tmpfs-mode":
+			tmpfs = append(tmpfs, plain("mode"), quoted(v))
+		case "consistency":
+			m.Content = append(m.Content, plain("consistency"), quoted(v))
+		default:
+			r.warnf("--mount option %s isn't supported and was dropped", k)
+		}
+	}
+	if target == "" {
+		return fmt.Errorf("--mount %s: target required", val)
+	}
+	head := []*yaml.Node{plain("type"), quoted(typ)}
+	if source != "" {
+		head = append(head, plain("source"), quoted(source))
+		if typ == "volume" {
+			r.declareVolume(source)
+		}
+	}
+	head = append(head, plain("target"), quoted(target))
+	m.Content = append(head, m.Content...)
+	for _, sub := range []struct {
+		key     string
+		content []*yaml.Node
+	}{{"bind", bind}, {"volume", vol}, {"tmpfs", tmpfs}} {
+		if len(sub.content) > 0 {
+			m.Content = append(m.Content, plain(sub.key), mapping(sub.content...))
+		}
+	}
+	r.appendTo("volumes", m)
+	return nil
+}
+
+// setNetwork records --network: the modes compose sets with network_mode,
+// or a network to join, which is taken to exist already.
+func (r *runConversion) setNetwork(val string) error {
+	switch {
+	case val == "host" || val == "none" || val == "bridge" || strings.HasPrefix(val, "container:"):
+		r.networkMode = val
+	case val == "":
+		return errors.New("--network: empty value")
+	default:
+		if r.network != "" && r.network != val {
+			r.warnf("only the first network, %s, was kept; connect %s in the compose file", r.network, val)
+			return nil
+		}
+		r.network = val
+	}
+	return nil
+}
+
+// setGPUs records --gpus as a device reservation: "all", a count, or
+// "device=0,1".
+func (r *runConversion) setGPUs(val string) error {
+	dev := mapping(plain("driver"), plain("nvidia"))
+	for _, field := range splitGPUOptions(val) {
+		k, v, hasVal := strings.Cut(field, "=")
+		switch {
+		case !hasVal && k == "all":
+			dev.Content = append(dev.Content, plain("count"), plain("all"))
+		case !hasVal:
+			if _, err := strconv.Atoi(k); err != nil {
+				return fmt.Errorf("--gpus: unknown value %q", field)
+			}
+			dev.Content = append(dev.Content, plain("count"), plain(k))
+		case k == "count":
+			dev.Content = append(dev.Content, plain("count"), plain(v))
+		case k == "device":
+			ids := seq()
+			for _, id := range strings.Split(v, ",") {
+				ids.Content = append(ids.Content, quoted(strings.TrimSpace(id)))
+			}
+			dev.Content = append(dev.Content, plain("device_ids"), ids)
+		case k == "driver":
+			dev.Content[1] = plain(v)
+		case k == "capabilities":
+			caps := seq()
+			for _, c := range strings.Split(v, ",") {
+				caps.Content = append(caps.Content, plain(strings.TrimSpace(c)))
+			}
+			dev.Content = append(dev.Content, plain("capabilities"), caps)
+		default:
+			return fmt.Errorf("--gpus: unknown option %q", k)
+		}
+	}
+	hasCaps := false
+	for i := 0; i < len(dev.Content); i += 2 {
+		hasCaps = hasCaps || dev.Content[i].Value == "capabilities"
+	}
+	if !hasCaps {
+		dev.Content = append(dev.Content, plain("capabilities"), seq(plain("gpu")))
+	}
+	r.set("deploy", deviceReservation(dev))
+	return nil
+}
+
+// deviceReservation is the deploy key reserving a device, e.g. GPUs.
+func deviceReservation(dev *yaml.Node) *yaml.Node {
+	return mapping(plain("resources"), mapping(plain("reservations"), mapping(plain("devices"), seq(dev))))
+}
+
+// splitGPUOptions splits the value of --gpus on commas outside the quotes
+// device lists come in: --gpus '"device=0,1"'.
+func splitGPUOptions(val string) []string {
+	var fields []string
+	var cur strings.Builder
+	inQuote := false
+	for _, c := range val {
+		switch {
+		case c == '"':
+			inQuote = !inQuote
+		case c == ',' && !inQuote:
+			fields = append(fields, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteRune(c)
+		}
+	}
+	return append(fields, cur.String())
+}
+
+// addUlimit records --ulimit name=soft[:hard].
+func (r *runConversion) addUlimit(val string) error {
+	name, limits, ok := strings.Cut(val, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("--ulimit %s: expected name=soft[:hard]", val)
+	}
+	soft, hard, hasHard := strings.Cut(limits, ":")
+	nums := []string{soft}
+	if hasHard {
+		nums = append(nums, hard)
+	}
+	for _, n := range nums {
+		if _, err := strconv.ParseInt(n, 10, 64); err != nil {
+			return fmt.Errorf("--ulimit %s: %q is not a number", val, n)
+		}
+	}
+	if hasHard {
+		r.setIn("ulimits", name, mapping(plain("soft"), plain(soft), plain("hard"), plain(hard)))
+	} else {
+		r.setIn("ulimits", name, plain(soft))
+	}
+	return nil
+}
+
+func (r *runConversion) warnf(format string, args ...any) {
+	r.warnings = append(r.warnings, fmt.Sprintf(format, args...))
+}
+
+// ConvertDockerRun turns a docker run command line into a compose file
+// with one service. Flags without a compose equivalent are dropped and
+// listed in the warnings; unknown flags are an error, since it can't tell
+// whether they take a value.
+func ConvertDockerRun(cmdline string) (*DockerRunConversion, error) {
+	words, err := splitShellWords(cmdline)
+	if err != nil {
+		return nil, err
+	}
+	if len(words) > 0 && words[0] == "sudo" {
+		words = words[1:]
+	}
+	switch {
+	case len(words) >= 2 && path.Base(words[0]) == "docker" && words[1] == "run":
+		words = words[2:]
+	case len(words) >= 3 && path.Base(words[0]) == "docker" && words[1] == "container" && words[2] == "run":
+		words = words[3:]
+	default:
+		return nil, errors.New("expected a command starting with docker run")
+	}
+
+	r := &runConversion{}
+	for i := 0; i < len(words); i++ {
+		w := words[i]
+		if w == "--" {
+			i++
+			if i < len(words) {
+				r.image, r.command = words[i], words[i+1:]
+			}
+			break
+		}
+		if !strings.HasPrefix(w, "-") || w == "-" {
+			r.image, r.command = w, words[i+1:]
+			break
+		}
+		flags, err := parseRunFlag(w)
+		if err != nil {
+			return nil, err
+		}
+		for _, pf := range flags {
+			val := pf.val
+			if pf.flag.value && !pf.hasVal {
+				if i+1 >= len(words) {
+					return nil, fmt.Errorf("flag %s needs a value", w)
+				}
+				i++
+				val = words[i]
+			}
+			switch {
+			case pf.flag.apply != nil:
+				if err := pf.flag.apply(r, val); err != nil {
+					return nil, err
+				}
+			case !pf.flag.quiet:
+				r.warnf("--%s has no compose equivalent and was dropped", pf.flag.name)
+			}
+		}
+	}
+	if r.image == "" {
+		return nil, errors.New("no image given")
+	}
+	return r.render()
+}
+
+// parsedRunFlag is a flag of a command line with its value, if given in
+// the same word.
+type parsedRunFlag struct {
+	flag   *runFlag
+	val    string
+	hasVal bool
+}
+
+// parseRunFlag parses a word starting with a dash: --flag, --flag=value,
+// -f, -fvalue or bundled short flags like -it.
+func parseRunFlag(w string) ([]parsedRunFlag, error) {
+	if long, ok := strings.CutPrefix(w, "--"); ok {
+		name, val, hasVal := strings.Cut(long, "=")
+		f, ok := runFlags[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown flag --%s", name)
+		}
+		if !f.value && hasVal {
+			// --privileged=false and friends turn a boolean off
+			if b, err := strconv.ParseBool(val); err != nil {
+				return nil, fmt.Errorf("--%s: %q is not a boolean", name, val)
+			} else if !b {
+				return nil, nil
+			}
+		}
+		return []parsedRunFlag{{flag: f, val: val, hasVal: hasVal}}, nil
+	}
+	var flags []parsedRunFlag
+	short := w[1:]
+	for j := 0; j < len(short); j++ {
+		name, ok := runShortFlags[short[j]]
+		if !ok {
+			return nil, fmt.Errorf("unknown flag -%c", short[j])
+		}
+		f := runFlags[name]
+		if f.value {
+			// The rest of the word is the value: -p80:80, -e=A=1
+			rest := strings.TrimPrefix(short[j+1:], "=")
+			flags = append(flags, parsedRunFlag{flag: f, val: rest, hasVal: j+1 < len(short)})
+			break
+		}
+		flags = append(flags, parsedRunFlag{flag: f})
+	}
+	return flags, nil
+}
+
+// serviceKeyOrder is the order keys are written in; keys not listed follow
+// in the order they were set.
+var serviceKeyOrder = []string{
+	"image", "container_name", "hostname", "domainname", "entrypoint", "command",
+	"restart", "user", "working_dir", "ports", "expose", "environment", "env_file",
+	"volumes", "tmpfs", "network_mode", "networks", "labels",
+}
+
+var serviceNameUnsafe = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+// serviceName derives a service name from the container name or the image.
+func (r *runConversion) serviceName() string {
+	name := r.name
+	if name == "" {
+		name = r.image
+		if i := strings.LastIndex(name, "@"); i >= 0 {
+			name = name[:i]
+		}
+		name = path.Base(name)
+		if i := strings.LastIndex(name, ":"); i >= 0 {
+			name = name[:i]
+		}
+	}
+	return sanitizeServiceName(name)
+}
+
+// sanitizeServiceName makes a container or image name a service name.
+func sanitizeServiceName(name string) string {
+	name = strings.Trim(serviceNameUnsafe.ReplaceAllString(strings.ToLower(name), "-"), "-_")
+	if name == "" {
+		return "app"
+	}
+	return name
+}
+
+func (r *runConversion) render() (*DockerRunConversion, error) {
+	r.set("image", quoted(r.image))
+	if r.entrypoint != "" {
+		r.set("entrypoint", quoted(r.entrypoint))
+	}
+	if len(r.command) > 0 {
+		cmd := seq()
+		for _, arg := range r.command {
+			cmd.Content = append(cmd.Content, quoted(arg))
+		}
+		r.set("command", cmd)
+	}
+	if r.networkMode != "" {
+		r.set("network_mode", quoted(r.networkMode))
+		if r.network != "" || len(r.aliases) > 0 || r.ipv4 != "" || r.ipv6 != "" {
+			r.warnf("network_mode %s can't be combined with networks; the network settings were dropped", r.networkMode)
+		}
+	} else if r.network != "" {
+		var settings []*yaml.Node
+		if len(r.aliases) > 0 {
+			aliases := seq()
+			for _, a := range r.aliases {
+				aliases.Content = append(aliases.Content, quoted(a))
+			}
+			settings = append(settings, plain("aliases"), aliases)
+		}
+		if r.ipv4 != "" {
+			settings = append(settings, plain("ipv4_address"), quoted(r.ipv4))
+		}
+		if r.ipv6 != "" {
+			settings = append(settings, plain("ipv6_address"), quoted(r.ipv6))
+		}
+		if len(settings) > 0 {
+			r.set("networks", mapping(quoted(r.network), mapping(settings...)))
+		} else {
+			r.set("networks", seq(quoted(r.network)))
+		}
+	} else if len(r.aliases) > 0 || r.ipv4 != "" || r.ipv6 != "" {
+		r.warnf("network aliases and addresses need a user-defined --network; they were dropped")
+	}
+	if r.logDriver != "" || len(r.logOpts) > 0 {
+		logging := mapping()
+		if r.logDriver != "" {
+			logging.Content = append(logging.Content, plain("driver"), quoted(r.logDriver))
+		}
+		if len(r.logOpts) > 0 {
+			opts := mapping()
+			for _, o := range r.logOpts {
+				opts.Content = append(opts.Content, quoted(o[0]), quoted(o[1]))
+			}
+			logging.Content = append(logging.Content, plain("options"), opts)
+		}
+		r.set("logging", logging)
+	}
+
+	name := r.serviceName()
+	doc := mapping(plain("services"), mapping(plain(name), r.node()))
+	if len(r.volumes) > 0 {
+		vols := mapping()
+		for _, v := range r.volumes {
+			vols.Content = append(vols.Content, quoted(v), mapping())
+		}
+		doc.Content = append(doc.Content, plain("volumes"), vols)
+	}
+	if r.network != "" && r.networkMode == "" {
+		// docker run only joins networks that exist
+		doc.Content = append(doc.Content, plain("networks"),
+			mapping(quoted(r.network), mapping(plain("external"), plain("true"))))
+	}
+
+	out, err := encodeCompose(doc)
+	if err != nil {
+		return nil, err
+	}
+	warnings := r.warnings
+	if warnings == nil {
+		warnings = []string{}
+	}
+	return &DockerRunConversion{YAML: out, Service: name, Warnings: warnings}, nil
+}
+
+// encodeCompose writes a generated compose file with two space indents.
+func encodeCompose(doc *yaml.Node) (string, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return "", fmt.Errorf("encode compose: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return "", fmt.Errorf("encode compose: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// plain is a scalar left for YAML to resolve: keys, booleans and numbers.
+func plain(s string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Value: s}
+}
+
+// quoted is a string scalar. The encoder already quotes strings that would
+// read as another type; this also quotes what YAML 1.1 parsers, which
+// compose used to be, read as booleans or base 60 numbers, e.g. 22:22.
+func quoted(s string) *yaml.Node {
+	n := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s}
+	if needsQuotes(s) {
+		n.Style = yaml.DoubleQuotedStyle
+	}
+	return n
+}
+
+func needsQuotes(s string) bool {
+	switch strings.ToLower(s) {
+	case "yes", "no", "on", "off", "y", "n":
+		return true
+	}
+	return s != "" && s[0] >= '0' && s[0] <= '9' && strings.Contains(s, ":")
+}
+
+func mapping(content ...*yaml.Node) *yaml.Node {
+	return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: content}
+}
+
+func seq(items ...*yaml.Node) *yaml.Node {
+	return &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: items}
+}
+
+// splitShellWords splits a command line the way a POSIX shell would,
+// without expansions: quotes group words, backslashes escape, and
+// backslash-newline continues the line. A $ that is literal to the shell,
+// in single quotes or escaped, is doubled, since compose would interpolate
+// it.
+func splitShellWords(s string) ([]string, error) {
+	var words []string
+	var cur strings.Builder
+	inWord := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\':
+			if i+1 >= len(s) {
+				return nil, errors.New("command ends with a backslash")
+			}
+			i++
+			switch s[i] {
+			case '\n':
+				continue
+			case '\r':
+				if i+1 < len(s) && s[i+1] == '\n' {
+					i++
+				}
+				continue
+			case '$':
+				cur.WriteString("$$")
+			default:
+				cur.WriteByte(s[i])
+			}
+			inWord = true
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			cur.WriteString(strings.ReplaceAll(s[i+1:i+1+end], "$", "$$"))
+			i += end + 1
+			inWord = true
+		case c == '"':
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("\"\\$`\n", s[i+1]) >= 0 {
+					i++
+					switch s[i] {
+					case '\n':
+						continue
+					case '$':
+						cur.WriteString("$$")
+						continue
+					}
+				}
+				cur.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, errors.New("unterminated double quote")
+			}
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		case c == '#' && !inWord:
+			// A comment runs to the end of the line
+			for i+1 < len(s) && s[i+1] != '\n' {
+				i++
+			}
+		case c == ';' || c == '|' || c == '&':
+			return nil, fmt.Errorf("only a single docker run command can be converted, found %q", string(c))
+		default:
+			cur.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, cur.String())
+	}
+	return words, nil
+}
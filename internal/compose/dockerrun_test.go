@@ -0,0 +1,298 @@
+package compose
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestConvertDockerRun(t *testing.T) {
+	t.Parallel()
+	cmd := `sudo docker run -d --name my-app \
+  -p 8080:80 -p "127.0.0.1:443:443/tcp" \
+  -v data:/var/lib/app -v ./conf:/etc/app:ro \
+  -e TZ=Europe/Berlin -e 'PASSWORD=pa$$w0rd' --env-file .env \
+  --restart unless-stopped \
+  -l traefik.enable=true \
+  --gpus all \
+  nginx:1.27 nginx -g 'daemon off;'`
+	got, err := ConvertDockerRun(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `services:
+  my-app:
+    image: nginx:1.27
+    container_name: my-app
+    command:
+      - nginx
+      - -g
+      - daemon off;
+    restart: unless-stopped
+    ports:
+      - "8080:80"
+      - "127.0.0.1:443:443/tcp"
+    environment:
+      - TZ=Europe/Berlin
+      - PASSWORD=pa$$$$w0rd
+    env_file:
+      - .env
+    volumes:
+      - data:/var/lib/app
+      - ./conf:/etc/app:ro
+    labels:
+      - traefik.enable=true
+    deploy:
+      resources:
+        reservations:
+          devices:
+            - driver: nvidia
+              count: all
+              capabilities:
+                - gpu
+volumes:
+  data: {}
+`
+	if got.YAML != want {
+		t.Errorf("yaml:\n%s\nwant:\n%s", got.YAML, want)
+	}
+	if got.Service != "my-app" {
+		t.Errorf("service = %q", got.Service)
+	}
+	if len(got.Warnings) != 0 {
+		t.Errorf("warnings = %v", got.Warnings)
+	}
+}
+
+// service converts a command and decodes its only service.
+func service(t *testing.T, cmd string) (map[string]any, map[string]any, *DockerRunConversion) {
+	t.Helper()
+	conv, err := ConvertDockerRun(cmd)
+	if err != nil {
+		t.Fatalf("%s: %v", cmd, err)
+	}
+	var doc map[string]any
+	if err := yaml.Unmarshal([]byte(conv.YAML), &doc); err != nil {
+		t.Fatalf("%s: output isn't YAML: %v\n%s", cmd, err, conv.YAML)
+	}
+	services := doc["services"].(map[string]any)
+	if len(services) != 1 {
+		t.Fatalf("%s: %d services", cmd, len(services))
+	}
+	return services[conv.Service].(map[string]any), doc, conv
+}
+
+func TestConvertDockerRunFlags(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		cmd  string
+		key  string
+		want any
+	}{
+		// Short flags bundled, and with attached values
+		{"docker run -it alpine", "tty", true},
+		{"docker run -it alpine", "stdin_open", true},
+		{"docker run -p80:80 alpine", "ports", []any{"80:80"}},
+		{"docker run -eA=1 alpine", "environment", []any{"A=1"}},
+		{"docker run --publish=53:53/udp alpine", "ports", []any{"53:53/udp"}},
+		{"docker container run -u 1000:1000 alpine", "user", "1000:1000"},
+		{"/usr/bin/docker run -w /src alpine", "working_dir", "/src"},
+		{"docker run -h box alpine", "hostname", "box"},
+		{"docker run --expose 9000 alpine", "expose", []any{"9000"}},
+		{"docker run --tmpfs /run alpine", "tmpfs", []any{"/run"}},
+		{"docker run --privileged alpine", "privileged", true},
+		{"docker run --privileged=false alpine", "privileged", nil},
+		{"docker run --cap-add NET_ADMIN --cap-drop ALL alpine", "cap_add", []any{"NET_ADMIN"}},
+		{"docker run --cap-add NET_ADMIN --cap-drop ALL alpine", "cap_drop", []any{"ALL"}},
+		{"docker run --security-opt no-new-privileges alpine", "security_opt", []any{"no-new-privileges"}},
+		{"docker run --device /dev/dri:/dev/dri alpine", "devices", []any{"/dev/dri:/dev/dri"}},
+		{"docker run --dns 1.1.1.1 alpine", "dns", []any{"1.1.1.1"}},
+		{"docker run --add-host host.docker.internal:host-gateway alpine", "extra_hosts", []any{"host.docker.internal:host-gateway"}},
+		{"docker run --sysctl net.ipv4.ip_forward=1 alpine", "sysctls", []any{"net.ipv4.ip_forward=1"}},
+		{"docker run --shm-size 1g alpine", "shm_size", "1g"},
+		{"docker run -m 512m --cpus 1.5 alpine", "mem_limit", "512m"},
+		{"docker run -m 512m --cpus 1.5 alpine", "cpus", 1.5},
+		{"docker run --init --read-only alpine", "init", true},
+		{"docker run --init --read-only alpine", "read_only", true},
+		{"docker run --pid host alpine", "pid", "host"},
+		{"docker run --stop-timeout 30 alpine", "stop_grace_period", "30s"},
+		{"docker run --stop-signal SIGINT alpine", "stop_signal", "SIGINT"},
+		{"docker run --pull always alpine", "pull_policy", "always"},
+		{"docker run --platform linux/arm64 alpine", "platform", "linux/arm64"},
+		{"docker run --runtime nvidia alpine", "runtime", "nvidia"},
+		{"docker run --entrypoint /bin/sh alpine -c 'echo hi'", "entrypoint", "/bin/sh"},
+		{"docker run --entrypoint /bin/sh alpine -c 'echo hi'", "command", []any{"-c", "echo hi"}},
+		{"docker run --ulimit nofile=1024:2048 --ulimit nproc=65535 alpine", "ulimits",
+			map[string]any{"nofile": map[string]any{"soft": 1024, "hard": 2048}, "nproc": 65535}},
+		{"docker run --log-driver json-file --log-opt max-size=10m alpine", "logging",
+			map[string]any{"driver": "json-file", "options": map[string]any{"max-size": "10m"}}},
+		{"docker run --health-cmd 'curl -f localhost' --health-interval 30s --health-retries 3 alpine", "healthcheck",
+			map[string]any{"test": []any{"CMD-SHELL", "curl -f localhost"}, "interval": "30s", "retries": 3}},
+		// Values yaml would read as something else stay strings
+		{"docker run -l enabled=yes -l on -l 1 alpine", "labels", []any{"enabled=yes", "on", "1"}},
+		{"docker run -p 22:22 alpine", "ports", []any{"22:22"}},
+		// Literal dollars are escaped from compose interpolation
+		{`docker run -e "A=\$HOME" -e 'B=$x' alpine`, "environment", []any{"A=$$HOME", "B=$$x"}},
+		// Ignored
+		{"docker run --rm -d alpine", "restart", nil},
+	}
+	for _, tt := range tests {
+		svc, _, _ := service(t, tt.cmd)
+		if got := svc[tt.key]; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: %s = %#v, want %#v", tt.cmd, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestConvertDockerRunNetworks(t *testing.T) {
+	t.Parallel()
+	svc, doc, _ := service(t, "docker run --network host alpine")
+	if svc["network_mode"] != "host" || doc["networks"] != nil {
+		t.Errorf("host: %v %v", svc, doc["networks"])
+	}
+
+	svc, doc, _ = service(t, "docker run --net=container:vpn alpine")
+	if svc["network_mode"] != "container:vpn" {
+		t.Errorf("container: %v", svc)
+	}
+
+	svc, doc, _ = service(t, "docker run --network proxy alpine")
+	if !reflect.DeepEqual(svc["networks"], []any{"proxy"}) {
+		t.Errorf("networks = %#v", svc["networks"])
+	}
+	if want := map[string]any{"proxy": map[string]any{"external": true}}; !reflect.DeepEqual(doc["networks"], want) {
+		t.Errorf("top-level networks = %#v", doc["networks"])
+	}
+
+	svc, _, _ = service(t, "docker run --network proxy --network-alias web --ip 10.0.0.5 alpine")
+	want := map[string]any{"proxy": map[string]any{"aliases": []any{"web"}, "ipv4_address": "10.0.0.5"}}
+	if !reflect.DeepEqual(svc["networks"], want) {
+		t.Errorf("networks = %#v", svc["networks"])
+	}
+
+	_, _, conv := service(t, "docker run --network host --network-alias web alpine")
+	if len(conv.Warnings) != 1 {
+		t.Errorf("alias with host network: warnings = %v", conv.Warnings)
+	}
+}
+
+func TestConvertDockerRunGPUs(t *testing.T) {
+	t.Parallel()
+	device := func(cmd string) map[string]any {
+		svc, _, _ := service(t, cmd)
+		deploy := svc["deploy"].(map[string]any)
+		devices := deploy["resources"].(map[string]any)["reservations"].(map[string]any)["devices"].([]any)
+		return devices[0].(map[string]any)
+	}
+	if got := device("docker run --gpus 2 alpine"); got["count"] != 2 || got["driver"] != "nvidia" {
+		t.Errorf("count: %v", got)
+	}
+	got := device(`docker run --gpus '"device=0,2"' alpine`)
+	if !reflect.DeepEqual(got["device_ids"], []any{"0", "2"}) || !reflect.DeepEqual(got["capabilities"], []any{"gpu"}) {
+		t.Errorf("device ids: %v", got)
+	}
+	got = device(`docker run --gpus 'all,"capabilities=compute,utility"' alpine`)
+	if !reflect.DeepEqual(got["capabilities"], []any{"compute", "utility"}) {
+		t.Errorf("capabilities: %v", got)
+	}
+}
+
+func TestConvertDockerRunVolumes(t *testing.T) {
+	t.Parallel()
+	svc, doc, _ := service(t, "docker run -v /srv:/srv -v ~/x:/x -v cache:/cache -v cache:/cache2 "+
+		"--mount type=volume,source=db,target=/db,readonly "+
+		"--mount type=bind,src=/etc/tz,dst=/etc/tz,bind-propagation=rslave alpine")
+	if want := map[string]any{"cache": map[string]any{}, "db": map[string]any{}}; !reflect.DeepEqual(doc["volumes"], want) {
+		t.Errorf("top-level volumes = %#v", doc["volumes"])
+	}
+	vols := svc["volumes"].([]any)
+	if len(vols) != 6 {
+		t.Fatalf("volumes = %#v", vols)
+	}
+	if want := map[string]any{"type": "volume", "source": "db", "target": "/db", "read_only": true}; !reflect.DeepEqual(vols[4], want) {
+		t.Errorf("mount = %#v", vols[4])
+	}
+	want := map[string]any{"type": "bind", "source": "/etc/tz", "target": "/etc/tz", "bind": map[string]any{"propagation": "rslave"}}
+	if !reflect.DeepEqual(vols[5], want) {
+		t.Errorf("bind mount = %#v", vols[5])
+	}
+}
+
+func TestConvertDockerRunServiceName(t *testing.T) {
+	t.Parallel()
+	for cmd, want := range map[string]string{
+		"docker run ghcr.io/home-assistant/home-assistant:stable": "home-assistant",
+		"docker run lscr.io/linuxserver/plex@sha256:abc":          "plex",
+		"docker run --name My.App alpine":                         "my-app",
+		"docker run localhost:5000/tool":                          "tool",
+	} {
+		_, _, conv := service(t, cmd)
+		if conv.Service != want {
+			t.Errorf("%s: service = %q, want %q", cmd, conv.Service, want)
+		}
+	}
+}
+
+func TestConvertDockerRunWarnings(t *testing.T) {
+	t.Parallel()
+	_, _, conv := service(t, "docker run --link db:db -P --cidfile /tmp/id alpine")
+	if len(conv.Warnings) != 3 {
+		t.Errorf("warnings = %v", conv.Warnings)
+	}
+}
+
+func TestConvertDockerRunErrors(t *testing.T) {
+	t.Parallel()
+	for _, cmd := range []string{
+		"",
+		"podman run alpine",
+		"docker ps",
+		"docker run -d",
+		"docker run --bogus alpine",
+		"docker run -Z alpine",
+		"docker run --name",
+		"docker run 'alpine",
+		`docker run "alpine`,
+		"docker run alpine; rm -rf /",
+		"docker run alpine | cat",
+		"docker run --cpus lots alpine",
+		"docker run --ulimit nofile alpine",
+		"docker run --mount type=bind,source=/x alpine",
+		"docker run --gpus bogus alpine",
+	} {
+		if _, err := ConvertDockerRun(cmd); err == nil {
+			t.Errorf("%q: expected an error", cmd)
+		}
+	}
+}
+
+func TestSplitShellWords(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"a  b\tc", []string{"a", "b", "c"}},
+		{`a "b c" 'd e'`, []string{"a", "b c", "d e"}},
+		{`a\ b "x\"y" 'it'\''s'`, []string{"a b", `x"y`, "it's"}},
+		{"a \\\n  b \\\r\n c", []string{"a", "b", "c"}},
+		{`x="" ''`, []string{"x=", ""}},
+		{"a # comment\nb", []string{"a", "b"}},
+		{"a#b", []string{"a#b"}},
+	}
+	for _, tt := range tests {
+		got, err := splitShellWords(tt.in)
+		if err != nil {
+			t.Errorf("%q: %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+	if _, err := splitShellWords(`a \`); err == nil || !strings.Contains(err.Error(), "backslash") {
+		t.Errorf("trailing backslash: %v", err)
+	}
+}
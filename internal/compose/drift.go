@@ -0,0 +1,298 @@
+package compose
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cfilipov/dockge/internal/secrets"
+)
+
+// ResolvedProject is the subset of `docker compose config --format json`
+// output needed for drift detection and pre-deploy checks. Variables are
+// already interpolated and paths made absolute by compose.
+type ResolvedProject struct {
+	Name     string                     `json:"name"`
+	Services map[string]ResolvedService `json:"services"`
+	Volumes  map[string]struct {
+		Name     string `json:"name"`
+		External bool   `json:"external"`
+	} `json:"volumes"`
+	Networks map[string]ResolvedNetwork `json:"networks"`
+}
+
+// ResolvedService is one service from the resolved compose config.
+type ResolvedService struct {
+	Image       string             `json:"image"`
+	Environment map[string]*string `json:"environment"` // nil value = unset passthrough
+	Labels      map[string]string  `json:"labels"`
+	Ports       []ResolvedPort     `json:"ports"`
+	Volumes     []ResolvedVolume   `json:"volumes"`
+	DNS         []string           `json:"dns"`
+	DNSSearch   []string           `json:"dns_search"`
+}
+
+// ResolvedPort is a long-syntax port mapping.
+type ResolvedPort struct {
+	HostIP    string `json:"host_ip"`
+	Target    int    `json:"target"`
+	Published string `json:"published"`
+	Protocol  string `json:"protocol"`
+}
+
+// ResolvedVolume is a long-syntax service volume.
+type ResolvedVolume struct {
+	Type     string `json:"type"` // bind, volume, tmpfs, ...
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	ReadOnly bool   `json:"read_only"`
+}
+
+// ResolveConfig runs `docker compose config --format json` in the stack
+// directory and parses the result.
+func ResolveConfig(ctx context.Context, stacksDir, stackName string) (*ResolvedProject, error) {
+	return ResolveConfigWithEnv(ctx, stacksDir, stackName, GlobalEnvArgs(stacksDir, stackName))
+}
+
+// ResolveConfigWithEnv is ResolveConfig with the given --env-file flags,
+// e.g. env files whose secrets were resolved.
+func ResolveConfigWithEnv(ctx context.Context, stacksDir, stackName string, envArgs []string) (*ResolvedProject, error) {
+	args := []string{"compose"}
+	args = append(args, envArgs...)
+	args = append(args, "config", "--format", "json")
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Dir = filepath.Join(stacksDir, stackName)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("compose config: %s", msg)
+		}
+		return nil, fmt.Errorf("compose config: %w", err)
+	}
+	return ParseResolvedConfig(out)
+}
+
+// ParseResolvedConfig parses `docker compose config --format json` output.
+func ParseResolvedConfig(data []byte) (*ResolvedProject, error) {
+	var p ResolvedProject
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("parse compose config: %w", err)
+	}
+	if len(p.Services) == 0 {
+		return nil, fmt.Errorf("parse compose config: no services")
+	}
+	return &p, nil
+}
+
+// inspectState is the subset of container inspect data compared against the
+// compose config.
+type inspectState struct {
+	Config struct {
+		Image  string            `json:"Image"`
+		Env    []string          `json:"Env"`
+		Labels map[string]string `json:"Labels"`
+	} `json:"Config"`
+	HostConfig struct {
+		PortBindings map[string][]struct {
+			HostIP   string `json:"HostIp"`
+			HostPort string `json:"HostPort"`
+		} `json:"PortBindings"`
+	} `json:"HostConfig"`
+	Mounts []struct {
+		Type        string `json:"Type"`
+		Name        string `json:"Name"`
+		Source      string `json:"Source"`
+		Destination string `json:"Destination"`
+		RW          bool   `json:"RW"`
+	} `json:"Mounts"`
+}
+
+// ServiceDrift lists the reasons a container no longer matches its service
+// definition, i.e. why `up -d` would recreate it. Only keys and targets are
+// reported, never values, since environment often holds secrets.
+func (p *ResolvedProject) ServiceDrift(service string, inspect json.RawMessage) ([]string, error) {
+	svc, ok := p.Services[service]
+	if !ok {
+		return []string{"service is not in the compose file (will be removed)"}, nil
+	}
+	var st inspectState
+	if err := json.Unmarshal(inspect, &st); err != nil {
+		return nil, fmt.Errorf("parse inspect: %w", err)
+	}
+
+	var reasons []string
+	if svc.Image != "" && st.Config.Image != "" && svc.Image != st.Config.Image {
+		reasons = append(reasons, fmt.Sprintf("image: %s → %s", st.Config.Image, svc.Image))
+	}
+	reasons = append(reasons, envDrift(svc.Environment, st.Config.Env)...)
+	reasons = append(reasons, labelDrift(svc.Labels, st.Config.Labels)...)
+	reasons = append(reasons, p.portDrift(svc.Ports, &st)...)
+	reasons = append(reasons, p.volumeDrift(svc.Volumes, &st)...)
+	return reasons, nil
+}
+
+// envDrift reports compose variables that are missing or differ in the
+// container. Extra container variables are ignored: they usually come from
+// the image. So are changes of variables set to a secret reference.
+func envDrift(want map[string]*string, have []string) []string {
+	current := make(map[string]string, len(have))
+	for _, kv := range have {
+		k, v, _ := strings.Cut(kv, "=")
+		current[k] = v
+	}
+	var reasons []string
+	for _, k := range sortedKeys(want) {
+		v := want[k]
+		if v == nil {
+			continue
+		}
+		got, ok := current[k]
+		switch {
+		case !ok:
+			reasons = append(reasons, "environment: "+k+" added")
+		case got != *v && !secrets.IsReference(*v):
+			reasons = append(reasons, "environment: "+k+" changed")
+		}
+	}
+	return reasons
+}
+
+// labelDrift reports compose labels that are missing or differ. Extra labels
+// come from the image or compose itself and are ignored.
+func labelDrift(want, have map[string]string) []string {
+	var reasons []string
+	for _, k := range sortedKeys(want) {
+		got, ok := have[k]
+		switch {
+		case !ok:
+			reasons = append(reasons, "label: "+k+" added")
+		case got != want[k]:
+			reasons = append(reasons, "label: "+k+" changed")
+		}
+	}
+	return reasons
+}
+
+func (p *ResolvedProject) portDrift(want []ResolvedPort, st *inspectState) []string {
+	wantSet := make(map[string]bool)
+	for _, port := range want {
+		// Published ranges are assigned per container; can't compare them.
+		if strings.Contains(port.Published, "-") {
+			return nil
+		}
+		proto := port.Protocol
+		if proto == "" {
+			proto = "tcp"
+		}
+		wantSet[formatPort(port.HostIP, port.Published, fmt.Sprintf("%d/%s", port.Target, proto))] = true
+	}
+	haveSet := make(map[string]bool)
+	for target, bindings := range st.HostConfig.PortBindings {
+		for _, b := range bindings {
+			haveSet[formatPort(b.HostIP, b.HostPort, target)] = true
+		}
+	}
+	return setDrift("port", wantSet, haveSet)
+}
+
+func formatPort(hostIP, published, target string) string {
+	if published == "" {
+		return target
+	}
+	if hostIP == "" || hostIP == "0.0.0.0" {
+		return published + ":" + target
+	}
+	return hostIP + ":" + published + ":" + target
+}
+
+// volumeDrift compares bind mounts and named volumes by target path. Anonymous
+// and tmpfs volumes are not compared.
+func (p *ResolvedProject) volumeDrift(want []ResolvedVolume, st *inspectState) []string {
+	wantSet := make(map[string]bool)
+	for _, v := range want {
+		source := v.Source
+		switch v.Type {
+		case "bind":
+		case "volume":
+			if source == "" {
+				continue
+			}
+			if named, ok := p.Volumes[source]; ok && named.Name != "" {
+				source = named.Name
+			}
+		default:
+			continue
+		}
+		wantSet[formatMount(source, v.Target, v.ReadOnly)] = true
+	}
+
+	prefix := p.Name + "_"
+	haveSet := make(map[string]bool)
+	for _, m := range st.Mounts {
+		source := m.Source
+		switch m.Type {
+		case "bind":
+		case "volume":
+			// Volumes not owned by this project are anonymous or image-defined
+			// unless compose asked for them by name.
+			if !strings.HasPrefix(m.Name, prefix) && !p.hasVolumeName(m.Name) {
+				continue
+			}
+			source = m.Name
+		default:
+			continue
+		}
+		haveSet[formatMount(source, m.Destination, !m.RW)] = true
+	}
+	return setDrift("volume", wantSet, haveSet)
+}
+
+func (p *ResolvedProject) hasVolumeName(name string) bool {
+	for _, v := range p.Volumes {
+		if v.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func formatMount(source, target string, readOnly bool) string {
+	s := source + ":" + target
+	if readOnly {
+		s += ":ro"
+	}
+	return s
+}
+
+// setDrift reports entries in want but not have as added and the reverse as
+// removed.
+func setDrift(kind string, want, have map[string]bool) []string {
+	var reasons []string
+	for _, k := range sortedKeys(want) {
+		if !have[k] {
+			reasons = append(reasons, kind+": "+k+" added")
+		}
+	}
+	for _, k := range sortedKeys(have) {
+		if !want[k] {
+			reasons = append(reasons, kind+": "+k+" removed")
+		}
+	}
+	return reasons
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,111 @@
+package compose
+
+import (
+	"reflect"
+	"testing"
+)
+
+const driftConfig = `{
+  "name": "web",
+  "services": {
+    "app": {
+      "image": "nginx:1.27",
+      "environment": {"MODE": "prod", "TOKEN": "s3cret", "PASSTHROUGH": null},
+      "labels": {"team": "infra"},
+      "ports": [{"target": 80, "published": "8080", "protocol": "tcp"}],
+      "volumes": [
+        {"type": "bind", "source": "/srv/web/html", "target": "/usr/share/nginx/html", "read_only": true},
+        {"type": "volume", "source": "data", "target": "/data"}
+      ]
+    }
+  },
+  "volumes": {"data": {"name": "web_data"}}
+}`
+
+func TestServiceDrift(t *testing.T) {
+	t.Parallel()
+	p, err := ParseResolvedConfig([]byte(driftConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("in sync", func(t *testing.T) {
+		t.Parallel()
+		inspect := `{
+		  "Config": {
+		    "Image": "nginx:1.27",
+		    "Env": ["PATH=/usr/bin", "MODE=prod", "TOKEN=s3cret"],
+		    "Labels": {"team": "infra", "com.docker.compose.project": "web"}
+		  },
+		  "HostConfig": {"PortBindings": {"80/tcp": [{"HostIp": "", "HostPort": "8080"}]}},
+		  "Mounts": [
+		    {"Type": "bind", "Source": "/srv/web/html", "Destination": "/usr/share/nginx/html", "RW": false},
+		    {"Type": "volume", "Name": "web_data", "Destination": "/data", "RW": true},
+		    {"Type": "volume", "Name": "3f1c0a9e", "Destination": "/var/cache", "RW": true}
+		  ]
+		}`
+		reasons, err := p.ServiceDrift("app", []byte(inspect))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(reasons) != 0 {
+			t.Errorf("expected no drift, got %v", reasons)
+		}
+	})
+
+	t.Run("drifted", func(t *testing.T) {
+		t.Parallel()
+		inspect := `{
+		  "Config": {
+		    "Image": "nginx:1.25",
+		    "Env": ["MODE=dev"],
+		    "Labels": {"team": "web"}
+		  },
+		  "HostConfig": {"PortBindings": {"80/tcp": [{"HostIp": "", "HostPort": "8081"}]}},
+		  "Mounts": [
+		    {"Type": "bind", "Source": "/srv/web/html", "Destination": "/usr/share/nginx/html", "RW": true}
+		  ]
+		}`
+		reasons, err := p.ServiceDrift("app", []byte(inspect))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{
+			"image: nginx:1.25 → nginx:1.27",
+			"environment: MODE changed",
+			"environment: TOKEN added",
+			"label: team changed",
+			"port: 8080:80/tcp added",
+			"port: 8081:80/tcp removed",
+			"volume: /srv/web/html:/usr/share/nginx/html:ro added",
+			"volume: web_data:/data added",
+			"volume: /srv/web/html:/usr/share/nginx/html removed",
+		}
+		if !reflect.DeepEqual(reasons, want) {
+			t.Errorf("got  %q\nwant %q", reasons, want)
+		}
+	})
+
+	t.Run("orphan", func(t *testing.T) {
+		t.Parallel()
+		reasons, err := p.ServiceDrift("old", []byte(`{}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(reasons) != 1 {
+			t.Errorf("expected orphan reason, got %v", reasons)
+		}
+	})
+}
+
+func TestEnvDriftSecretReference(t *testing.T) {
+	t.Parallel()
+	ref, sops := "vault:secret/db#password", "ENC[AES256_GCM,data:abc]"
+	want := map[string]*string{"DB_PASSWORD": &ref, "API_KEY": &sops}
+	if reasons := envDrift(want, []string{"DB_PASSWORD=s3cret", "API_KEY=k"}); len(reasons) != 0 {
+		t.Errorf("expected resolved secrets not to drift, got %v", reasons)
+	}
+	if reasons := envDrift(want, nil); len(reasons) != 2 {
+		t.Errorf("expected missing secrets to drift, got %v", reasons)
+	}
+}
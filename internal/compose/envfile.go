@@ -0,0 +1,98 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvFile is one env_file entry of a service.
+type EnvFile struct {
+	Path     string `json:"path"`     // as written; relative paths are from the stack directory
+	Required bool   `json:"required"` // false only with the long syntax's required: false
+}
+
+// ParseEnvFiles returns each service's env_file entries in file order. The
+// short syntax (a path or a list of paths) and the long syntax (path and
+// required) are recognized. env_file has too many shapes for the line
+// scanners, and is read rarely, so the file is always fully decoded.
+func ParseEnvFiles(data string) map[string][]EnvFile {
+	var doc struct {
+		Services map[string]struct {
+			EnvFile yaml.Node `yaml:"env_file"`
+		} `yaml:"services"`
+	}
+	result := make(map[string][]EnvFile)
+	if err := yaml.Unmarshal([]byte(data), &doc); err != nil {
+		return result
+	}
+	for name, svc := range doc.Services {
+		n := resolveAlias(&svc.EnvFile)
+		items := []*yaml.Node{n}
+		if n.Kind == yaml.SequenceNode {
+			items = n.Content
+		}
+		for _, item := range items {
+			item = resolveAlias(item)
+			f := EnvFile{Required: true}
+			switch item.Kind {
+			case yaml.ScalarNode:
+				f.Path = item.Value
+			case yaml.MappingNode:
+				var long struct {
+					Path     string `yaml:"path"`
+					Required *bool  `yaml:"required"`
+				}
+				if item.Decode(&long) != nil {
+					continue
+				}
+				f.Path = long.Path
+				if long.Required != nil {
+					f.Required = *long.Required
+				}
+			}
+			if f.Path != "" {
+				result[name] = append(result[name], f)
+			}
+		}
+	}
+	return result
+}
+
+// EnvFilePaths returns the absolute paths of the env files a stack's compose
+// and override files refer to, sorted and without duplicates. Paths using
+// variable interpolation are skipped, as they can't be resolved here.
+func EnvFilePaths(stacksDir, stackName string) []string {
+	dir := filepath.Join(stacksDir, stackName)
+	seen := make(map[string]bool)
+	var paths []string
+	for _, names := range [][]string{acceptedComposeFileNames, acceptedComposeOverrideFileNames} {
+		for _, fname := range names {
+			data, err := os.ReadFile(filepath.Join(dir, fname))
+			if err != nil {
+				continue
+			}
+			for _, files := range ParseEnvFiles(string(data)) {
+				for _, f := range files {
+					if strings.Contains(f.Path, "$") {
+						continue
+					}
+					path := f.Path
+					if !filepath.IsAbs(path) {
+						path = filepath.Join(dir, path)
+					}
+					if path = filepath.Clean(path); !seen[path] {
+						seen[path] = true
+						paths = append(paths, path)
+					}
+				}
+			}
+			break
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
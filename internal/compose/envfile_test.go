@@ -0,0 +1,61 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseEnvFiles(t *testing.T) {
+	t.Parallel()
+	yaml := `services:
+  web:
+    image: nginx
+    env_file: web.env
+  app:
+    image: myapp
+    env_file:
+      - ./common.env
+      - path: ./app.env
+        required: false
+      - ../shared/app.env
+  db:
+    image: postgres
+`
+	got := ParseEnvFiles(yaml)
+	want := map[string][]EnvFile{
+		"web": {{Path: "web.env", Required: true}},
+		"app": {
+			{Path: "./common.env", Required: true},
+			{Path: "./app.env", Required: false},
+			{Path: "../shared/app.env", Required: true},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseEnvFiles() = %v, want %v", got, want)
+	}
+}
+
+func TestEnvFilePaths(t *testing.T) {
+	t.Parallel()
+	stacksDir := t.TempDir()
+	dir := filepath.Join(stacksDir, "app")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	composeYAML := "services:\n  app:\n    image: myapp\n    env_file: [config/app.env, ../shared.env, \"${CONF}/x.env\"]\n"
+	override := "services:\n  app:\n    env_file: /etc/app.env\n  worker:\n    env_file: ./config/app.env\n"
+	os.WriteFile(filepath.Join(dir, "compose.yaml"), []byte(composeYAML), 0o644)
+	os.WriteFile(filepath.Join(dir, "compose.override.yaml"), []byte(override), 0o644)
+
+	got := EnvFilePaths(stacksDir, "app")
+	want := []string{
+		"/etc/app.env",
+		filepath.Join(dir, "config", "app.env"),
+		filepath.Join(stacksDir, "shared.env"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("EnvFilePaths() = %v, want %v", got, want)
+	}
+}
@@ -5,16 +5,18 @@ import (
 	"path/filepath"
 )
 
-// GlobalEnvArgs returns --env-file flags to prepend to compose args
-// when global.env exists in the stacks directory. If the stack also has
-// a local .env, it is re-added explicitly (--env-file overrides the
-// default .env loading). Returns nil when no global.env exists.
+// GlobalEnvArgs returns the global flags to prepend to compose args: the
+// -f flags of a stack with additional compose files (see FileArgs), and
+// --env-file flags when global.env exists in the stacks directory. If the
+// stack also has a local .env, it is re-added explicitly (--env-file
+// overrides the default .env loading). Returns nil when neither applies.
 func GlobalEnvArgs(stacksDir, stackName string) []string {
+	args := FileArgs(stacksDir, stackName)
 	globalPath := filepath.Join(stacksDir, "global.env")
 	if _, err := os.Stat(globalPath); err != nil {
-		return nil
+		return args
 	}
-	args := []string{"--env-file", "../global.env"}
+	args = append(args, "--env-file", "../global.env")
 	localEnv := filepath.Join(stacksDir, stackName, ".env")
 	if _, err := os.Stat(localEnv); err == nil {
 		args = append(args, "--env-file", "./.env")
@@ -0,0 +1,88 @@
+package compose
+
+import (
+	"bufio"
+	"strings"
+)
+
+// ExternalResources are the top-level networks and volumes a compose file
+// declares external: true, by the Docker name they refer to.
+type ExternalResources struct {
+	Networks []string
+	Volumes  []string
+}
+
+// ParseExternalResources extracts the external networks and volumes from
+// compose YAML. An entry refers to its name: if set, else to its key.
+// Same line-scanning assumptions as parseScanner, except that files with
+// anchors, aliases or merge keys are fully decoded.
+func ParseExternalResources(yaml string) ExternalResources {
+	if usesYAMLReferences(yaml) {
+		if doc, err := decodeCompose(yaml); err == nil {
+			return externalFromDoc(doc)
+		}
+	}
+	var res ExternalResources
+	scanner := bufio.NewScanner(strings.NewReader(yaml))
+
+	section := ""
+	key, name := "", ""
+	external := false
+	flush := func() {
+		if key != "" && external {
+			ref := name
+			if ref == "" {
+				ref = key
+			}
+			switch section {
+			case "networks":
+				res.Networks = append(res.Networks, ref)
+			case "volumes":
+				res.Volumes = append(res.Volumes, ref)
+			}
+		}
+		key, name, external = "", "", false
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.TrimSpace(line)[0] == '#' {
+			continue
+		}
+		if line[0] != ' ' {
+			flush()
+			section = ""
+			if line == "networks:" || line == "volumes:" {
+				section = strings.TrimSuffix(line, ":")
+			}
+			continue
+		}
+		if section == "" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		stripped := stripInlineComment(strings.TrimSpace(line))
+
+		if indent == 2 {
+			flush()
+			k, _, _ := strings.Cut(stripped, ":")
+			key = unquote(strings.TrimSpace(k))
+			continue
+		}
+		if indent != 4 || key == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(stripped, ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(k) {
+		case "external":
+			external = strings.TrimSpace(v) == "true"
+		case "name":
+			name = unquote(strings.TrimSpace(v))
+		}
+	}
+	flush()
+	return res
+}
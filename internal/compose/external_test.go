@@ -0,0 +1,47 @@
+package compose
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseExternalResources(t *testing.T) {
+	t.Parallel()
+	yaml := `services:
+  app:
+    image: nginx
+    networks:
+      - proxy
+    volumes:
+      - media:/media
+
+networks:
+  proxy:
+    external: true
+  backend:
+    driver: bridge
+  legacy:
+    name: "db_internal" # created by the db stack
+    external: true
+
+volumes:
+  media:
+    external: true
+    name: nas_media
+  cache: {}
+  scratch:
+    external: false
+`
+	got := ParseExternalResources(yaml)
+	want := ExternalResources{
+		Networks: []string{"proxy", "db_internal"},
+		Volumes:  []string{"nas_media"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	if got := ParseExternalResources("services:\n  app:\n    image: nginx\n"); got.Networks != nil || got.Volumes != nil {
+		t.Errorf("expected no external resources, got %+v", got)
+	}
+}
@@ -0,0 +1,150 @@
+package compose
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ComposeFilesList is the file in a stack directory that lists the stack's
+// additional compose files, one name per line, relative to the stack
+// directory. Compose merges them after the compose and override files.
+const ComposeFilesList = ".compose-files"
+
+// ExtraComposeFiles returns the additional compose files listed for a
+// stack, in merge order. Blank lines and # comments are skipped, and so
+// are names ValidateExtraFileName rejects.
+func ExtraComposeFiles(stacksDir, stackName string) []string {
+	data, err := os.ReadFile(filepath.Join(stacksDir, stackName, ComposeFilesList))
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || ValidateExtraFileName(line) != nil {
+			continue
+		}
+		names = append(names, line)
+	}
+	return names
+}
+
+// WriteExtraComposeFiles persists the additional compose files of a stack.
+// An empty list removes the list file.
+func WriteExtraComposeFiles(stacksDir, stackName string, names []string) error {
+	listPath := filepath.Join(stacksDir, stackName, ComposeFilesList)
+	if len(names) == 0 {
+		if err := os.Remove(listPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("remove compose file list: %w", err)
+		}
+		return nil
+	}
+	if err := os.WriteFile(listPath, []byte(strings.Join(names, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("write compose file list: %w", err)
+	}
+	return nil
+}
+
+// ValidateExtraFileName checks that name can be an additional compose file:
+// a .yaml or .yml file inside the stack directory that isn't one of the
+// names compose loads by itself.
+func ValidateExtraFileName(name string) error {
+	switch {
+	case name == "":
+		return errors.New("compose file name required")
+	case filepath.IsAbs(name) || strings.Contains(name, `\`):
+		return fmt.Errorf("compose file %s: must be a relative path", name)
+	case path.Clean(name) != name || name == ".." || strings.HasPrefix(name, "../"):
+		return fmt.Errorf("compose file %s: must be inside the stack directory", name)
+	case !strings.HasSuffix(name, ".yaml") && !strings.HasSuffix(name, ".yml"):
+		return fmt.Errorf("compose file %s: must end in .yaml or .yml", name)
+	}
+	for _, names := range [][]string{acceptedComposeFileNames, acceptedComposeOverrideFileNames} {
+		for _, n := range names {
+			if name == n {
+				return fmt.Errorf("compose file %s: already loaded by compose", name)
+			}
+		}
+	}
+	return nil
+}
+
+// FileArgs returns the -f flags of a stack with additional compose files:
+// the compose file, the override file (compose stops loading it by itself
+// once files are given), and the additional files. Returns nil when the
+// stack has none, leaving file discovery to compose.
+func FileArgs(stacksDir, stackName string) []string {
+	extra := ExtraComposeFiles(stacksDir, stackName)
+	if len(extra) == 0 {
+		return nil
+	}
+	main := FindComposeFile(stacksDir, stackName)
+	if main == "" {
+		return nil
+	}
+	args := []string{"-f", filepath.Base(main)}
+	dir := filepath.Join(stacksDir, stackName)
+	for _, name := range acceptedComposeOverrideFileNames {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			args = append(args, "-f", name)
+			break
+		}
+	}
+	for _, name := range extra {
+		args = append(args, "-f", name)
+	}
+	return args
+}
+
+// IncludedFiles returns the files a compose file pulls in with the include
+// directive that live inside the stack directory, in order. Remote
+// includes and files outside the stack directory are skipped.
+func IncludedFiles(composeYAML string) []string {
+	var doc struct {
+		Include []yaml.Node `yaml:"include"`
+	}
+	if err := yaml.Unmarshal([]byte(composeYAML), &doc); err != nil {
+		return nil
+	}
+	var files []string
+	seen := map[string]bool{}
+	add := func(p string) {
+		if strings.Contains(p, "://") || strings.HasPrefix(p, "git@") {
+			return
+		}
+		p = path.Clean(strings.TrimPrefix(p, "./"))
+		if filepath.IsAbs(p) || p == ".." || strings.HasPrefix(p, "../") || seen[p] {
+			return
+		}
+		seen[p] = true
+		files = append(files, p)
+	}
+	for _, item := range doc.Include {
+		switch item.Kind {
+		case yaml.ScalarNode:
+			add(item.Value)
+		case yaml.MappingNode:
+			var entry struct {
+				Path yaml.Node `yaml:"path"`
+			}
+			if item.Decode(&entry) != nil {
+				continue
+			}
+			switch entry.Path.Kind {
+			case yaml.ScalarNode:
+				add(entry.Path.Value)
+			case yaml.SequenceNode:
+				for _, p := range entry.Path.Content {
+					add(p.Value)
+				}
+			}
+		}
+	}
+	return files
+}
@@ -0,0 +1,85 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFileArgs(t *testing.T) {
+	dir := t.TempDir()
+	stackDir := filepath.Join(dir, "mystack")
+	os.MkdirAll(stackDir, 0755)
+	os.WriteFile(filepath.Join(stackDir, "docker-compose.yml"), []byte("services: {}\n"), 0644)
+
+	if args := FileArgs(dir, "mystack"); args != nil {
+		t.Errorf("without extra files: %v, want nil", args)
+	}
+
+	os.WriteFile(filepath.Join(stackDir, "compose.override.yaml"), []byte("services: {}\n"), 0644)
+	os.WriteFile(filepath.Join(stackDir, ComposeFilesList), []byte("# monitoring\ncompose.monitoring.yaml\n\n../escape.yaml\nextra/db.yml\n"), 0644)
+
+	want := []string{"-f", "docker-compose.yml", "-f", "compose.override.yaml", "-f", "compose.monitoring.yaml", "-f", "extra/db.yml"}
+	if args := FileArgs(dir, "mystack"); !reflect.DeepEqual(args, want) {
+		t.Errorf("FileArgs = %v, want %v", args, want)
+	}
+
+	// -f flags come before the env files
+	os.WriteFile(filepath.Join(dir, "global.env"), []byte("FOO=bar"), 0644)
+	want = append(want, "--env-file", "../global.env")
+	if args := GlobalEnvArgs(dir, "mystack"); !reflect.DeepEqual(args, want) {
+		t.Errorf("GlobalEnvArgs = %v, want %v", args, want)
+	}
+
+	if err := WriteExtraComposeFiles(dir, "mystack", nil); err != nil {
+		t.Fatal(err)
+	}
+	if names := ExtraComposeFiles(dir, "mystack"); names != nil {
+		t.Errorf("after clearing the list: %v", names)
+	}
+}
+
+func TestValidateExtraFileName(t *testing.T) {
+	t.Parallel()
+	for name, valid := range map[string]bool{
+		"compose.monitoring.yaml": true,
+		"extra/db.yml":            true,
+		"":                        false,
+		"/etc/compose.yaml":       false,
+		"../other/compose.yaml":   false,
+		"extra/../db.yaml":        false,
+		"notes.txt":               false,
+		"compose.yaml":            false,
+		"compose.override.yml":    false,
+	} {
+		if err := ValidateExtraFileName(name); (err == nil) != valid {
+			t.Errorf("ValidateExtraFileName(%q) = %v, want valid %v", name, err, valid)
+		}
+	}
+}
+
+func TestIncludedFiles(t *testing.T) {
+	t.Parallel()
+	yaml := `include:
+  - ./db/compose.yaml
+  - path: monitoring.yaml
+  - path:
+      - proxy.yaml
+      - proxy.override.yaml
+    project_directory: proxy
+  - ../shared/compose.yaml
+  - oci://registry.example.com/stack:latest
+  - db/compose.yaml
+services:
+  web:
+    image: nginx
+`
+	want := []string{"db/compose.yaml", "monitoring.yaml", "proxy.yaml", "proxy.override.yaml"}
+	if files := IncludedFiles(yaml); !reflect.DeepEqual(files, want) {
+		t.Errorf("IncludedFiles = %v, want %v", files, want)
+	}
+	if files := IncludedFiles("services:\n  web:\n    image: nginx\n"); files != nil {
+		t.Errorf("without include: %v", files)
+	}
+}
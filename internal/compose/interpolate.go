@@ -0,0 +1,294 @@
+package compose
+
+import (
+	"slices"
+	"strings"
+)
+
+// VarSource tells where an interpolated variable's value came from.
+const (
+	VarFromEnv     = "env"     // the stack's .env or global.env
+	VarFromDefault = "default" // ${VAR:-default} and friends
+	VarUnset       = "unset"   // no value; substituted with ""
+)
+
+// VarUse is a variable referenced by a compose file.
+type VarUse struct {
+	Name     string   `json:"name"`
+	Value    string   `json:"value"`
+	Source   string   `json:"source"`
+	Lines    []int    `json:"lines"`              // 1-based, in order
+	Services []string `json:"services,omitempty"` // services whose definition uses it
+}
+
+// InterpolationError is a ${VAR:?message} whose variable is missing, or a
+// malformed reference.
+type InterpolationError struct {
+	Line    int    `json:"line"` // 1-based
+	Message string `json:"message"`
+}
+
+// Interpolation is the result of substituting variables into a compose
+// file the way docker compose does.
+type Interpolation struct {
+	Resolved string               `json:"resolved"`
+	Vars     []VarUse             `json:"vars"`
+	Errors   []InterpolationError `json:"errors,omitempty"`
+}
+
+// Interpolate substitutes $VAR and ${VAR...} references in a compose file.
+// lookup returns a variable's value and whether it's set. It supports the
+// compose forms: ${VAR:-default}, ${VAR-default}, ${VAR:?err}, ${VAR?err},
+// ${VAR:+alt}, ${VAR+alt}, nested references in defaults and $$ escapes.
+// Comment lines are left alone.
+func Interpolate(yaml string, lookup func(name string) (string, bool)) *Interpolation {
+	in := &interpolator{lookup: lookup, uses: make(map[string]*VarUse)}
+	lines := strings.Split(yaml, "\n")
+	services := serviceOfLines(lines)
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		in.line, in.service = i+1, services[i]
+		lines[i] = in.expand(line)
+	}
+
+	result := &Interpolation{Resolved: strings.Join(lines, "\n"), Vars: []VarUse{}, Errors: in.errors}
+	for _, name := range in.order {
+		result.Vars = append(result.Vars, *in.uses[name])
+	}
+	return result
+}
+
+type interpolator struct {
+	lookup  func(string) (string, bool)
+	line    int
+	service string
+	uses    map[string]*VarUse
+	order   []string
+	errors  []InterpolationError
+}
+
+// expand substitutes the references in s.
+func (in *interpolator) expand(s string) string {
+	if !strings.Contains(s, "$") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		switch next := s[i+1]; {
+		case next == '$':
+			b.WriteByte('$')
+			i++
+		case next == '{':
+			end := matchBrace(s, i+1)
+			if end < 0 {
+				in.fail("unterminated ${ reference")
+				b.WriteString(s[i:])
+				return b.String()
+			}
+			b.WriteString(in.braced(s[i+2 : end]))
+			i = end
+		case isNameStart(next):
+			j := i + 1
+			for j < len(s) && isNameChar(s[j]) {
+				j++
+			}
+			value, _ := in.resolve(s[i+1 : j])
+			b.WriteString(value)
+			i = j - 1
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String()
+}
+
+// braced substitutes the inside of a ${...} reference.
+func (in *interpolator) braced(expr string) string {
+	n := 0
+	for n < len(expr) && isNameChar(expr[n]) {
+		n++
+	}
+	name, rest := expr[:n], expr[n:]
+	if name == "" || !isNameStart(name[0]) {
+		in.fail("invalid reference ${" + expr + "}")
+		return ""
+	}
+	if rest == "" {
+		value, _ := in.resolve(name)
+		return value
+	}
+
+	op, arg := rest[:1], rest[1:]
+	emptyIsUnset := false
+	if op == ":" && len(rest) > 1 {
+		op, arg, emptyIsUnset = rest[1:2], rest[2:], true
+	}
+	value, set := in.lookup(name)
+	if emptyIsUnset && value == "" {
+		set = false
+	}
+	switch op {
+	case "-":
+		if set {
+			return in.use(name, value, VarFromEnv)
+		}
+		return in.use(name, in.expand(arg), VarFromDefault)
+	case "?":
+		if set {
+			return in.use(name, value, VarFromEnv)
+		}
+		msg := in.expand(arg)
+		if msg == "" {
+			msg = "required variable " + name + " is missing a value"
+		}
+		in.fail(name + ": " + msg)
+		return in.use(name, "", VarUnset)
+	case "+":
+		if set {
+			in.use(name, value, VarFromEnv)
+			return in.expand(arg)
+		}
+		return in.use(name, "", VarUnset)
+	}
+	in.fail("invalid reference ${" + expr + "}")
+	return ""
+}
+
+// resolve returns a variable's value and records its use.
+func (in *interpolator) resolve(name string) (string, bool) {
+	value, ok := in.lookup(name)
+	if !ok {
+		return in.use(name, "", VarUnset), false
+	}
+	return in.use(name, value, VarFromEnv), true
+}
+
+// use records a variable's use on the current line and returns its value.
+// The first use of a variable decides its reported value and source.
+func (in *interpolator) use(name, value, source string) string {
+	u := in.uses[name]
+	if u == nil {
+		u = &VarUse{Name: name, Value: value, Source: source}
+		in.uses[name] = u
+		in.order = append(in.order, name)
+	}
+	if len(u.Lines) == 0 || u.Lines[len(u.Lines)-1] != in.line {
+		u.Lines = append(u.Lines, in.line)
+	}
+	if in.service != "" && !slices.Contains(u.Services, in.service) {
+		u.Services = append(u.Services, in.service)
+	}
+	return value
+}
+
+func (in *interpolator) fail(msg string) {
+	in.errors = append(in.errors, InterpolationError{Line: in.line, Message: msg})
+}
+
+// matchBrace returns the index of the } closing the { at open, or -1.
+func matchBrace(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
+
+// serviceOfLines returns, for each line, the service whose definition it's
+// part of ("" outside services:).
+func serviceOfLines(lines []string) []string {
+	result := make([]string, len(lines))
+	inServices := false
+	serviceIndent := -1
+	current := ""
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed[0] == '#' {
+			result[i] = current
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if indent == 0 {
+			inServices = stripYAMLComment(trimmed) == "services:"
+			serviceIndent, current = -1, ""
+			continue
+		}
+		if inServices {
+			if serviceIndent < 0 {
+				serviceIndent = indent
+			}
+			if indent == serviceIndent {
+				if key, ok := yamlLineKey(trimmed); ok {
+					current = key
+				}
+			}
+		}
+		result[i] = current
+	}
+	return result
+}
+
+// yamlLineKey returns the key of a "key: value" line. Flow collections and
+// plain scalars such as URLs are not keys.
+func yamlLineKey(text string) (string, bool) {
+	if text == "" || text[0] == '[' || text[0] == '{' || text[0] == '?' {
+		return "", false
+	}
+	if q := text[0]; q == '"' || q == '\'' {
+		closing := strings.IndexByte(text[1:], q)
+		if closing < 0 || closing+2 >= len(text) || text[closing+2] != ':' {
+			return "", false
+		}
+		return text[1 : closing+1], true
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i == len(text)-1 || text[i+1] == ' ') {
+			if i == 0 {
+				return "", false
+			}
+			return strings.TrimRight(text[:i], " "), true
+		}
+	}
+	return "", false
+}
+
+// stripYAMLComment removes a " #" comment that isn't inside quotes.
+func stripYAMLComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && (i == 0 || s[i-1] == ' ' || s[i-1] == '[' || s[i-1] == '{' || s[i-1] == ','):
+			quote = c
+		case c == '#' && i > 0 && (s[i-1] == ' ' || s[i-1] == '\t'):
+			return strings.TrimRight(s[:i], " \t")
+		}
+	}
+	return s
+}
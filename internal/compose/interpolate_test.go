@@ -0,0 +1,77 @@
+package compose
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	t.Parallel()
+	env := map[string]string{"TAG": "1.25", "PORT": "", "HOST": "example.com"}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	yaml := strings.Join([]string{
+		"services:",
+		"  web:",
+		"    image: nginx:${TAG}",
+		"    ports:",
+		`      - "${PORT:-8080}:80"`,
+		"    # ${IGNORED}",
+		"    command: echo $$HOME $HOST",
+		"  db:",
+		"    image: postgres:${PG_TAG-${TAG}}",
+		"    environment:",
+		"      PASSWORD: ${DB_PASSWORD:?set a password}",
+		"      EXTRA: ${HOST:+set}${MISSING:+never}",
+	}, "\n")
+	got := Interpolate(yaml, lookup)
+
+	want := strings.Join([]string{
+		"services:",
+		"  web:",
+		"    image: nginx:1.25",
+		"    ports:",
+		`      - "8080:80"`,
+		"    # ${IGNORED}",
+		"    command: echo $HOME example.com",
+		"  db:",
+		"    image: postgres:1.25",
+		"    environment:",
+		"      PASSWORD: ",
+		"      EXTRA: set",
+	}, "\n")
+	if got.Resolved != want {
+		t.Errorf("resolved:\n%s\nwant:\n%s", got.Resolved, want)
+	}
+
+	vars := make(map[string]VarUse)
+	for _, v := range got.Vars {
+		vars[v.Name] = v
+	}
+	if v := vars["TAG"]; v.Source != VarFromEnv || len(v.Lines) != 2 || strings.Join(v.Services, ",") != "web,db" {
+		t.Errorf("TAG = %+v", v)
+	}
+	if v := vars["PORT"]; v.Source != VarFromDefault || v.Value != "8080" {
+		t.Errorf("PORT = %+v", v)
+	}
+	if v := vars["PG_TAG"]; v.Source != VarFromDefault || v.Value != "1.25" {
+		t.Errorf("PG_TAG = %+v", v)
+	}
+	if v := vars["DB_PASSWORD"]; v.Source != VarUnset {
+		t.Errorf("DB_PASSWORD = %+v", v)
+	}
+	if _, ok := vars["IGNORED"]; ok {
+		t.Error("variable in a comment was interpolated")
+	}
+	if len(got.Errors) != 1 || got.Errors[0].Line != 11 || !strings.Contains(got.Errors[0].Message, "set a password") {
+		t.Errorf("errors = %+v", got.Errors)
+	}
+
+	bad := Interpolate("image: ${TAG", lookup)
+	if len(bad.Errors) != 1 || bad.Resolved != "image: ${TAG" {
+		t.Errorf("unterminated reference: %+v", bad)
+	}
+}
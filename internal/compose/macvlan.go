@@ -0,0 +1,188 @@
+package compose
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// MacvlanOptions describes a macvlan/ipvlan network to generate. Subnet is
+// required; Gateway defaults to the first address in the subnet and IPRange
+// to the largest block that avoids the gateway, HostAddr, and the DHCP pool.
+type MacvlanOptions struct {
+	Name      string `json:"name"`   // compose network key, default "lan"
+	Driver    string `json:"driver"` // "macvlan" (default) or "ipvlan"
+	Parent    string `json:"parent"`
+	Subnet    string `json:"subnet"`
+	Gateway   string `json:"gateway"`
+	IPRange   string `json:"ipRange"`
+	HostAddr  string `json:"hostAddr"`  // the host's own address on the parent, kept out of the range
+	DHCPStart string `json:"dhcpStart"` // router's DHCP pool, kept out of the range
+	DHCPEnd   string `json:"dhcpEnd"`
+}
+
+// Macvlan is a generated network definition.
+type Macvlan struct {
+	Subnet  string `json:"subnet"`
+	Gateway string `json:"gateway"`
+	IPRange string `json:"ipRange"`
+	Snippet string `json:"snippet"` // top-level networks: YAML for a compose file
+}
+
+// minIPRangeBits is the smallest ip_range generated (a /29 holds 8 addresses).
+const minIPRangeBits = 29
+
+// GenerateMacvlan validates opts, fills in defaults, and renders a compose
+// networks: snippet. IPv4 only.
+func GenerateMacvlan(opts MacvlanOptions) (*Macvlan, error) {
+	if opts.Name == "" {
+		opts.Name = "lan"
+	}
+	if opts.Driver == "" {
+		opts.Driver = "macvlan"
+	}
+	if opts.Driver != "macvlan" && opts.Driver != "ipvlan" {
+		return nil, fmt.Errorf("driver must be macvlan or ipvlan")
+	}
+	if opts.Parent == "" {
+		return nil, fmt.Errorf("parent interface is required")
+	}
+
+	subnet, err := netip.ParsePrefix(opts.Subnet)
+	if err != nil || !subnet.Addr().Is4() {
+		return nil, fmt.Errorf("invalid IPv4 subnet %q", opts.Subnet)
+	}
+	subnet = subnet.Masked()
+	if subnet.Bits() > minIPRangeBits-1 {
+		return nil, fmt.Errorf("subnet %s is too small", subnet)
+	}
+
+	gateway := subnet.Addr().Next()
+	if opts.Gateway != "" {
+		if gateway, err = parseAddrIn(opts.Gateway, subnet); err != nil {
+			return nil, fmt.Errorf("gateway: %w", err)
+		}
+	}
+
+	reserved := []addrRange{{gateway, gateway}}
+	if opts.HostAddr != "" {
+		host, err := parseAddrIn(opts.HostAddr, subnet)
+		if err != nil {
+			return nil, fmt.Errorf("host address: %w", err)
+		}
+		reserved = append(reserved, addrRange{host, host})
+	}
+	if opts.DHCPStart != "" || opts.DHCPEnd != "" {
+		start, err := parseAddrIn(opts.DHCPStart, subnet)
+		if err != nil {
+			return nil, fmt.Errorf("DHCP start: %w", err)
+		}
+		end, err := parseAddrIn(opts.DHCPEnd, subnet)
+		if err != nil {
+			return nil, fmt.Errorf("DHCP end: %w", err)
+		}
+		if end.Less(start) {
+			return nil, fmt.Errorf("DHCP pool end is before its start")
+		}
+		reserved = append(reserved, addrRange{start, end})
+	}
+
+	var ipRange netip.Prefix
+	if opts.IPRange != "" {
+		if ipRange, err = netip.ParsePrefix(opts.IPRange); err != nil || !subnet.Contains(ipRange.Addr()) || ipRange.Bits() < subnet.Bits() {
+			return nil, fmt.Errorf("ip range %q is not inside %s", opts.IPRange, subnet)
+		}
+		ipRange = ipRange.Masked()
+		for _, r := range reserved {
+			if r.overlaps(ipRange) {
+				return nil, fmt.Errorf("ip range %s overlaps %s", ipRange, r)
+			}
+		}
+	} else if ipRange, err = largestFreeBlock(subnet, reserved); err != nil {
+		return nil, err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "networks:\n")
+	fmt.Fprintf(&sb, "  %s:\n", opts.Name)
+	fmt.Fprintf(&sb, "    # The host itself can't reach containers on this network\n")
+	fmt.Fprintf(&sb, "    # without an extra %s shim interface.\n", opts.Driver)
+	fmt.Fprintf(&sb, "    driver: %s\n", opts.Driver)
+	fmt.Fprintf(&sb, "    driver_opts:\n")
+	fmt.Fprintf(&sb, "      parent: %s\n", opts.Parent)
+	fmt.Fprintf(&sb, "    ipam:\n")
+	fmt.Fprintf(&sb, "      config:\n")
+	fmt.Fprintf(&sb, "        - subnet: %s\n", subnet)
+	fmt.Fprintf(&sb, "          gateway: %s\n", gateway)
+	fmt.Fprintf(&sb, "          ip_range: %s\n", ipRange)
+
+	return &Macvlan{
+		Subnet:  subnet.String(),
+		Gateway: gateway.String(),
+		IPRange: ipRange.String(),
+		Snippet: sb.String(),
+	}, nil
+}
+
+func parseAddrIn(s string, subnet netip.Prefix) (netip.Addr, error) {
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("invalid address %q", s)
+	}
+	if !subnet.Contains(addr) {
+		return netip.Addr{}, fmt.Errorf("%s is not inside %s", addr, subnet)
+	}
+	return addr, nil
+}
+
+// addrRange is an inclusive range of addresses.
+type addrRange struct {
+	from, to netip.Addr
+}
+
+func (r addrRange) String() string {
+	if r.from == r.to {
+		return r.from.String()
+	}
+	return r.from.String() + "-" + r.to.String()
+}
+
+// overlaps reports whether any address of r falls inside p.
+func (r addrRange) overlaps(p netip.Prefix) bool {
+	first, last := p.Addr(), lastAddr(p)
+	return !(r.to.Less(first) || last.Less(r.from))
+}
+
+func lastAddr(p netip.Prefix) netip.Addr {
+	a := p.Addr().As4()
+	hostBits := 32 - p.Bits()
+	v := uint32(a[0])<<24 | uint32(a[1])<<16 | uint32(a[2])<<8 | uint32(a[3])
+	v |= uint32(1)<<hostBits - 1
+	return netip.AddrFrom4([4]byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)})
+}
+
+// largestFreeBlock returns the largest aligned block inside subnet, smaller
+// than the subnet itself, that overlaps none of the reserved ranges.
+func largestFreeBlock(subnet netip.Prefix, reserved []addrRange) (netip.Prefix, error) {
+	for bits := subnet.Bits() + 1; bits <= minIPRangeBits; bits++ {
+		for block := netip.PrefixFrom(subnet.Addr(), bits); subnet.Contains(block.Addr()); {
+			// Docker's IPAM never hands out the subnet's network and
+			// broadcast addresses, so blocks at the edges are fine.
+			free := true
+			for _, r := range reserved {
+				if free && r.overlaps(block) {
+					free = false
+				}
+			}
+			if free {
+				return block, nil
+			}
+			next := lastAddr(block).Next()
+			if !next.IsValid() {
+				break
+			}
+			block = netip.PrefixFrom(next, bits)
+		}
+	}
+	return netip.Prefix{}, fmt.Errorf("no free address block of at least /%d in %s", minIPRangeBits, subnet)
+}
@@ -0,0 +1,64 @@
+package compose
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateMacvlan(t *testing.T) {
+	t.Parallel()
+
+	t.Run("range avoids gateway, host and DHCP pool", func(t *testing.T) {
+		t.Parallel()
+		m, err := GenerateMacvlan(MacvlanOptions{
+			Parent:    "eth0",
+			Subnet:    "192.168.1.0/24",
+			HostAddr:  "192.168.1.40",
+			DHCPStart: "192.168.1.100",
+			DHCPEnd:   "192.168.1.200",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Gateway != "192.168.1.1" {
+			t.Errorf("gateway = %s", m.Gateway)
+		}
+		// .0/27 has the gateway, .32/27 the host, .64/27 is free
+		if m.IPRange != "192.168.1.64/27" {
+			t.Errorf("ip range = %s", m.IPRange)
+		}
+		for _, want := range []string{"  lan:\n", "    driver: macvlan\n", "      parent: eth0\n", "          ip_range: 192.168.1.64/27\n"} {
+			if !strings.Contains(m.Snippet, want) {
+				t.Errorf("snippet missing %q:\n%s", want, m.Snippet)
+			}
+		}
+	})
+
+	t.Run("explicit range overlapping pool", func(t *testing.T) {
+		t.Parallel()
+		_, err := GenerateMacvlan(MacvlanOptions{
+			Parent:    "eth0",
+			Subnet:    "10.0.0.0/24",
+			IPRange:   "10.0.0.128/26",
+			DHCPStart: "10.0.0.150",
+			DHCPEnd:   "10.0.0.160",
+		})
+		if err == nil {
+			t.Error("expected overlap error")
+		}
+	})
+
+	t.Run("invalid input", func(t *testing.T) {
+		t.Parallel()
+		for _, opts := range []MacvlanOptions{
+			{Subnet: "192.168.1.0/24"},
+			{Parent: "eth0", Subnet: "fd00::/64"},
+			{Parent: "eth0", Subnet: "192.168.1.0/24", Gateway: "10.0.0.1"},
+			{Parent: "eth0", Subnet: "192.168.1.0/24", Driver: "bridge"},
+		} {
+			if _, err := GenerateMacvlan(opts); err == nil {
+				t.Errorf("expected error for %+v", opts)
+			}
+		}
+	})
+}
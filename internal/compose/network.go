@@ -0,0 +1,64 @@
+package compose
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// ResolvedNetwork is a top-level network from the resolved compose config.
+type ResolvedNetwork struct {
+	Name       string            `json:"name"`
+	Driver     string            `json:"driver"`
+	DriverOpts map[string]string `json:"driver_opts"`
+	External   bool              `json:"external"`
+}
+
+// CheckNetworkParents verifies that every macvlan/ipvlan network created by
+// the project names a parent interface that exists. VLAN sub-interfaces
+// ("eth0.10") only need their base interface, since Docker creates the VLAN
+// link itself. ifaceExists is HostInterfaceExists outside of tests.
+func (p *ResolvedProject) CheckNetworkParents(ifaceExists func(string) bool) []string {
+	var problems []string
+	for _, key := range sortedKeys(p.Networks) {
+		n := p.Networks[key]
+		if n.External || (n.Driver != "macvlan" && n.Driver != "ipvlan") {
+			continue
+		}
+		parent := n.DriverOpts["parent"]
+		if parent == "" {
+			// Docker falls back to a dummy interface: containers get no
+			// outside connectivity, which is rarely what was intended.
+			problems = append(problems, fmt.Sprintf("network %q (%s) has no driver_opts.parent; containers will be isolated from the LAN", key, n.Driver))
+			continue
+		}
+		base, _, _ := strings.Cut(parent, ".")
+		if !ifaceExists(base) {
+			problems = append(problems, fmt.Sprintf("network %q (%s): parent interface %q does not exist on this host (available: %s)", key, n.Driver, base, strings.Join(hostInterfaceNames(), ", ")))
+		}
+	}
+	return problems
+}
+
+// HostInterfaceExists reports whether a network interface with the given
+// name is visible to this process.
+func HostInterfaceExists(name string) bool {
+	_, err := net.InterfaceByName(name)
+	return err == nil
+}
+
+func hostInterfaceNames() []string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(ifaces))
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback == 0 {
+			names = append(names, iface.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
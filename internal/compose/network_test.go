@@ -0,0 +1,36 @@
+package compose
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckNetworkParents(t *testing.T) {
+	t.Parallel()
+	p, err := ParseResolvedConfig([]byte(`{
+	  "name": "lan",
+	  "services": {"app": {"image": "nginx"}},
+	  "networks": {
+	    "default": {"name": "lan_default", "driver": "bridge"},
+	    "vlan10": {"name": "lan_vlan10", "driver": "macvlan", "driver_opts": {"parent": "eth0.10"}},
+	    "wrong": {"name": "lan_wrong", "driver": "ipvlan", "driver_opts": {"parent": "enp9s0"}},
+	    "noparent": {"name": "lan_noparent", "driver": "macvlan"},
+	    "shared": {"name": "shared", "driver": "macvlan", "external": true}
+	  }
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exists := func(name string) bool { return name == "eth0" }
+	problems := p.CheckNetworkParents(exists)
+	if len(problems) != 2 {
+		t.Fatalf("expected 2 problems, got %d: %v", len(problems), problems)
+	}
+	if !strings.Contains(problems[0], `"noparent"`) {
+		t.Errorf("problem 0 = %q", problems[0])
+	}
+	if !strings.Contains(problems[1], `"enp9s0" does not exist`) {
+		t.Errorf("problem 1 = %q", problems[1])
+	}
+}
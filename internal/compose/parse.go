@@ -3,28 +3,70 @@ package compose
 import (
     "bufio"
     "os"
+    "sort"
     "strings"
 )
 
 // ServiceData holds the extracted per-service data from a compose file.
 type ServiceData struct {
-    Image             string // e.g. "nginx:latest"
-    StatusIgnore      bool   // dockge.status.ignore == "true"
-    ImageUpdatesCheck bool   // dockge.imageupdates.check != "false" (default: true)
+    Image              string // e.g. "nginx:latest"
+    StatusIgnore       bool   // dockge.status.ignore == "true"
+    ImageUpdatesCheck  bool   // dockge.imageupdates.check != "false" (default: true)
+    ImageUpdatesIgnore string // dockge.imageupdates.ignore: remote digest to skip, or "true" for any
+    DependsOnStacks    string // dockge.depends_on: comma-separated stacks to start first
+    Restart            string // restart policy; "" = not set
+    JobLabel           string // dockge.job: "true" or "false" overrides job detection
+}
+
+// IsJob reports whether a service is a one-shot or cron style job that
+// exits once done: its restart policy is explicitly "no", or it is labeled
+// dockge.job: "true". An exited job is a finished run, not a failure.
+func (sd ServiceData) IsJob() bool {
+    switch sd.JobLabel {
+    case "true":
+        return true
+    case "false":
+        return false
+    }
+    return sd.Restart == "no"
+}
+
+// StackDependencies returns the stacks a stack's services declare with
+// dockge.depends_on, sorted and deduplicated. Any service may carry the
+// label; the stack depends on the union.
+func StackDependencies(services map[string]ServiceData) []string {
+    seen := make(map[string]bool)
+    var deps []string
+    for _, sd := range services {
+        for _, name := range strings.Split(sd.DependsOnStacks, ",") {
+            if name = strings.TrimSpace(name); name != "" && !seen[name] {
+                seen[name] = true
+                deps = append(deps, name)
+            }
+        }
+    }
+    sort.Strings(deps)
+    return deps
 }
 
 // ParseFile reads a compose file from disk and extracts service data.
 func ParseFile(path string) map[string]ServiceData {
-    f, err := os.Open(path)
+    data, err := os.ReadFile(path)
     if err != nil {
         return nil
     }
-    defer f.Close()
-    return parseScanner(bufio.NewScanner(f))
+    return ParseYAML(string(data))
 }
 
 // ParseYAML parses compose YAML from a string and extracts service data.
+// Files with anchors, aliases or merge keys are fully decoded; the rest
+// go through the line scanner.
 func ParseYAML(yaml string) map[string]ServiceData {
+    if usesYAMLReferences(yaml) {
+        if doc, err := decodeCompose(yaml); err == nil {
+            return serviceDataFromDoc(doc)
+        }
+    }
     return parseScanner(bufio.NewScanner(strings.NewReader(yaml)))
 }
 
@@ -42,7 +84,8 @@ func ParseYAML(yaml string) map[string]ServiceData {
 //   - Label entries are at 6+ space indent.
 //   - Inline YAML comments (# after whitespace) are stripped from values.
 //   - Only the first "services:" block is parsed; subsequent ones are ignored.
-//   - Anchors, aliases, and flow mappings ({}) are not supported.
+//   - Flow mappings ({}) are not supported. Files with anchors, aliases, or
+//     merge keys never reach the scanner (see ParseYAML).
 //
 // These trade-offs are intentional: full YAML parsing (via gopkg.in/yaml.v3)
 // is ~100x slower and allocates heavily. Since compose files follow a strict
@@ -121,6 +164,15 @@ func parseScanner(scanner *bufio.Scanner) map[string]ServiceData {
                 continue
             }
 
+            // restart: policy
+            if strings.HasPrefix(stripped, "restart:") {
+                sd := result[currentService]
+                sd.Restart = strings.Trim(stripInlineComment(strings.TrimSpace(strings.TrimPrefix(stripped, "restart:"))), "\"'")
+                result[currentService] = sd
+                inLabels = false
+                continue
+            }
+
             // labels: block
             if stripped == "labels:" {
                 inLabels = true
@@ -157,6 +209,12 @@ func parseScanner(scanner *bufio.Scanner) map[string]ServiceData {
                 sd.StatusIgnore = val == "true"
             case "dockge.imageupdates.check":
                 sd.ImageUpdatesCheck = val != "false"
+            case "dockge.imageupdates.ignore":
+                sd.ImageUpdatesIgnore = val
+            case "dockge.depends_on":
+                sd.DependsOnStacks = val
+            case "dockge.job":
+                sd.JobLabel = val
             }
             result[currentService] = sd
         }
@@ -3,6 +3,7 @@ package compose
 import (
     "os"
     "path/filepath"
+    "strings"
     "testing"
 )
 
@@ -69,6 +70,8 @@ func TestParseYAML(t *testing.T) {
       dockge.imageupdates.check: "false"
   db:
     image: postgres:16
+    labels:
+      dockge.imageupdates.ignore: "sha256:abc"
 `
     data := ParseYAML(yaml)
 
@@ -93,6 +96,9 @@ func TestParseYAML(t *testing.T) {
     if !db.ImageUpdatesCheck {
         t.Error("db.ImageUpdatesCheck should be true (default)")
     }
+    if db.ImageUpdatesIgnore != "sha256:abc" {
+        t.Errorf("db.ImageUpdatesIgnore = %q", db.ImageUpdatesIgnore)
+    }
 }
 
 func TestParseYAMLLabelsBeforeImage(t *testing.T) {
@@ -235,6 +241,70 @@ func TestParseYAMLQuotedLabelValues(t *testing.T) {
     }
 }
 
+func TestParseYAMLJobs(t *testing.T) {
+    t.Parallel()
+    yaml := `services:
+  web:
+    image: nginx
+    restart: unless-stopped
+  backup:
+    image: restic
+    restart: "no" # runs from cron
+  migrate:
+    image: myapp:v1
+    labels:
+      dockge.job: "true"
+  oneshot:
+    image: alpine
+    restart: 'no'
+    labels:
+      dockge.job: "false"
+  plain:
+    image: alpine
+`
+    want := map[string]bool{"web": false, "backup": true, "migrate": true, "oneshot": false, "plain": false}
+    // Same services through the anchor-aware decoder
+    anchored := strings.Replace(yaml, "services:", "x-base: &base\n  image: alpine\nservices:", 1)
+    for name, data := range map[string]map[string]ServiceData{"scanner": ParseYAML(yaml), "decoder": ParseYAML(anchored)} {
+        for svc, job := range want {
+            if got := data[svc].IsJob(); got != job {
+                t.Errorf("%s: %s.IsJob() = %v, want %v", name, svc, got, job)
+            }
+        }
+    }
+    if r := ParseYAML(yaml)["backup"].Restart; r != "no" {
+        t.Errorf("backup.Restart = %q, want no", r)
+    }
+}
+
+func TestStackDependencies(t *testing.T) {
+    t.Parallel()
+    yaml := `services:
+  app:
+    image: myapp:v1
+    labels:
+      dockge.depends_on: "proxy, db"
+  worker:
+    image: myapp:v1
+    labels:
+      dockge.depends_on: db,queue # shared
+  cron:
+    image: alpine
+`
+    data := ParseYAML(yaml)
+    if data["app"].DependsOnStacks != "proxy, db" {
+        t.Errorf("app.DependsOnStacks = %q", data["app"].DependsOnStacks)
+    }
+    got := StackDependencies(data)
+    want := []string{"db", "proxy", "queue"}
+    if strings.Join(got, ",") != strings.Join(want, ",") {
+        t.Errorf("StackDependencies() = %v, want %v", got, want)
+    }
+    if deps := StackDependencies(ParseYAML("services:\n  web:\n    image: nginx\n")); len(deps) != 0 {
+        t.Errorf("expected no dependencies, got %v", deps)
+    }
+}
+
 func TestParseYAMLCommentsAndBlankLines(t *testing.T) {
     t.Parallel()
     yaml := `# Top comment
@@ -0,0 +1,106 @@
+package compose
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Image pinning states reported by ImagePinStatus.
+const (
+	PinLatest     = "latest"     // ":latest" or no tag at all
+	PinFloating   = "floating"   // major or major.minor tag, or a channel like "stable"
+	PinDigestOnly = "digestOnly" // "repo@sha256:…" with no human-readable tag
+)
+
+// floatingTagRe matches tags that track a moving release line: "16",
+// "1.25", "v2", "16-alpine". A full "x.y.z" is treated as pinned.
+var floatingTagRe = regexp.MustCompile(`^v?\d+(\.\d+)?([-_].*)?$`)
+
+// floatingChannels are tags that are moved on every release.
+var floatingChannels = map[string]bool{
+	"stable": true, "lts": true, "edge": true, "mainline": true,
+	"nightly": true, "dev": true, "main": true, "master": true,
+}
+
+// SplitImageRef splits an image reference into repository, tag, and digest.
+// A registry port ("host:5000/app") is not mistaken for a tag.
+func SplitImageRef(ref string) (repo, tag, digest string) {
+	ref = strings.Trim(ref, "\"'")
+	if i := strings.Index(ref, "@"); i >= 0 {
+		ref, digest = ref[:i], ref[i+1:]
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		return ref[:i], ref[i+1:], digest
+	}
+	return ref, "", digest
+}
+
+// ImagePinStatus classifies how reproducible an image reference is. Returns
+// "" for references that are pinned (a full version tag, or tag plus digest)
+// or can't be judged (unresolved ${VARS}).
+func ImagePinStatus(ref string) string {
+	if ref == "" || strings.Contains(ref, "${") {
+		return ""
+	}
+	_, tag, digest := SplitImageRef(ref)
+	switch {
+	case digest != "" && tag == "":
+		return PinDigestOnly
+	case digest != "":
+		return ""
+	case tag == "" || tag == "latest" || strings.HasPrefix(tag, "latest-"):
+		return PinLatest
+	case floatingTagRe.MatchString(tag) || floatingChannels[tag]:
+		return PinFloating
+	}
+	return ""
+}
+
+// SetServiceImage replaces the image: value of a service in compose YAML,
+// keeping indentation, quoting, and any inline comment. Uses the same
+// line-scanning assumptions as parseScanner. Returns false if the service
+// has no image line.
+func SetServiceImage(yaml, service, image string) (string, bool) {
+	lines := strings.Split(yaml, "\n")
+	inServices := false
+	current := ""
+	for i, line := range lines {
+		trimmed := strings.TrimRight(line, " \t\r")
+		if trimmed == "services:" {
+			inServices = true
+			continue
+		}
+		if !inServices || trimmed == "" || strings.TrimSpace(trimmed)[0] == '#' {
+			continue
+		}
+		if trimmed[0] != ' ' {
+			break
+		}
+		if len(line) > 2 && line[:2] == "  " && line[2] != ' ' && strings.HasSuffix(trimmed, ":") {
+			current = strings.TrimSpace(strings.TrimSuffix(trimmed, ":"))
+			continue
+		}
+		if current != service {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		stripped := strings.TrimSpace(trimmed)
+		if indent < 4 || indent >= 6 || !strings.HasPrefix(stripped, "image:") {
+			continue
+		}
+
+		value := strings.TrimSpace(strings.TrimPrefix(stripped, "image:"))
+		comment := ""
+		if v := stripInlineComment(value); v != value {
+			comment = value[len(v):]
+			value = v
+		}
+		quote := ""
+		if value != "" && (value[0] == '"' || value[0] == '\'') {
+			quote = value[:1]
+		}
+		lines[i] = line[:indent] + "image: " + quote + image + quote + comment
+		return strings.Join(lines, "\n"), true
+	}
+	return yaml, false
+}
@@ -0,0 +1,72 @@
+package compose
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestImagePinStatus(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"nginx", PinLatest},
+		{"nginx:latest", PinLatest},
+		{"registry.local:5000/app", PinLatest},
+		{"postgres:16", PinFloating},
+		{"postgres:16-alpine", PinFloating},
+		{"nginx:1.25", PinFloating},
+		{"traefik:v3", PinFloating},
+		{"node:lts", PinFloating},
+		{"nginx:1.25.3", ""},
+		{"registry.local:5000/app:2.4.1", ""},
+		{"redis@sha256:abc", PinDigestOnly},
+		{"redis:7@sha256:abc", ""},
+		{"${IMAGE}", ""},
+		{`"nginx:latest"`, PinLatest},
+	}
+	for _, tt := range tests {
+		if got := ImagePinStatus(tt.ref); got != tt.want {
+			t.Errorf("ImagePinStatus(%q) = %q, want %q", tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestSetServiceImage(t *testing.T) {
+	t.Parallel()
+	yaml := "services:\n" +
+		"  web:\n" +
+		"    image: \"nginx:latest\" # front\n" +
+		"    ports:\n" +
+		"      - 80:80\n" +
+		"  db:\n" +
+		"    image: postgres:16\n"
+
+	got, ok := SetServiceImage(yaml, "db", "postgres:16.4@sha256:abc")
+	if !ok {
+		t.Fatal("db image not found")
+	}
+	want := "services:\n" +
+		"  web:\n" +
+		"    image: \"nginx:latest\" # front\n" +
+		"    ports:\n" +
+		"      - 80:80\n" +
+		"  db:\n" +
+		"    image: postgres:16.4@sha256:abc\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	got, ok = SetServiceImage(yaml, "web", "nginx:1.27.2")
+	if !ok {
+		t.Fatal("web image not found")
+	}
+	if want := "    image: \"nginx:1.27.2\" # front\n"; !strings.Contains(got, want) {
+		t.Errorf("quoting/comment not preserved:\n%s", got)
+	}
+
+	if _, ok := SetServiceImage(yaml, "cache", "redis:7"); ok {
+		t.Error("expected missing service to report false")
+	}
+}
@@ -0,0 +1,79 @@
+package compose
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// ProgressEvent is one line of `docker compose --progress json` output: the
+// state of a single resource (network, volume, container, image, layer).
+// Layers of an image pull have ParentID set to the image.
+type ProgressEvent struct {
+	ID       string `json:"id"`
+	ParentID string `json:"parent_id,omitempty"`
+	Status   string `json:"status,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Details  string `json:"details,omitempty"`
+	Current  int64  `json:"current,omitempty"`
+	Total    int64  `json:"total,omitempty"`
+	Percent  int    `json:"percent,omitempty"`
+	Tail     bool   `json:"tail,omitempty"` // container output attached to the event stream
+}
+
+// ParseProgressLine parses a JSON progress line. Returns false for anything
+// else (plain output, errors printed by compose itself).
+func ParseProgressLine(line []byte) (ProgressEvent, bool) {
+	var ev ProgressEvent
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 || line[0] != '{' || json.Unmarshal(line, &ev) != nil || ev.ID == "" && !ev.Tail {
+		return ProgressEvent{}, false
+	}
+	return ev, true
+}
+
+// String renders the event the way the plain progress writer would, for
+// the terminal stream.
+func (ev ProgressEvent) String() string {
+	if ev.Tail {
+		return ev.Text
+	}
+	text := ev.Text
+	if text == "" {
+		text = ev.Status
+	}
+	s := ev.ID + "  " + text
+	if ev.Details != "" {
+		s += "  " + ev.Details
+	}
+	if ev.ParentID != "" {
+		s = "  " + s
+	}
+	return s
+}
+
+var (
+	jsonProgressOnce      sync.Once
+	jsonProgressSupported bool
+)
+
+// SupportsJSONProgress reports whether the installed compose accepts
+// `--progress json`. Checked once per process from the --help output.
+func SupportsJSONProgress(ctx context.Context) bool {
+	jsonProgressOnce.Do(func() {
+		out, err := exec.CommandContext(ctx, "docker", "compose", "--help").Output()
+		if err != nil {
+			return
+		}
+		for _, line := range strings.Split(string(out), "\n") {
+			if strings.Contains(line, "--progress") && strings.Contains(line, "json") {
+				jsonProgressSupported = true
+				return
+			}
+		}
+	})
+	return jsonProgressSupported
+}
@@ -0,0 +1,35 @@
+package compose
+
+import "testing"
+
+func TestParseProgressLine(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		line string
+		ok   bool
+		want string
+	}{
+		{`{"id":"Container web-1","status":"Working","text":"Starting"}`, true, "Container web-1  Starting"},
+		{`{"id":"a1b2c3","parent_id":"Image nginx","status":"Working","text":"Downloading","details":"[==>  ] 2MB/8MB","current":2000000,"total":8000000,"percent":25}`, true, "  a1b2c3  Downloading  [==>  ] 2MB/8MB"},
+		{`{"id":"Network web_default","status":"Done"}`, true, "Network web_default  Done"},
+		{`{"tail":true,"text":"web-1  | ready"}`, true, "web-1  | ready"},
+		{`Error response from daemon: port is already allocated`, false, ""},
+		{`{"unrelated":1}`, false, ""},
+		{``, false, ""},
+	}
+	for _, tt := range tests {
+		ev, ok := ParseProgressLine([]byte(tt.line))
+		if ok != tt.ok {
+			t.Errorf("ParseProgressLine(%q) ok = %v, want %v", tt.line, ok, tt.ok)
+			continue
+		}
+		if ok && ev.String() != tt.want {
+			t.Errorf("ParseProgressLine(%q) = %q, want %q", tt.line, ev.String(), tt.want)
+		}
+	}
+
+	ev, _ := ParseProgressLine([]byte(`{"id":"x","parent_id":"Image nginx","current":5,"total":10,"percent":50}`))
+	if ev.ParentID != "Image nginx" || ev.Current != 5 || ev.Total != 10 || ev.Percent != 50 {
+		t.Errorf("fields not parsed: %+v", ev)
+	}
+}
@@ -0,0 +1,83 @@
+package compose
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ProjectName returns the compose project name docker compose uses when run
+// in dir without -p: COMPOSE_PROJECT_NAME from dir/.env, else the top-level
+// name: of the compose file, else the directory name. Values using variable
+// interpolation are skipped, as they can't be resolved here.
+func ProjectName(dir string) string {
+	if name := NormalizeProjectName(envFileValue(filepath.Join(dir, ".env"), "COMPOSE_PROJECT_NAME")); name != "" {
+		return name
+	}
+	for _, fname := range acceptedComposeFileNames {
+		if name, ok := topLevelName(filepath.Join(dir, fname)); ok {
+			if name = NormalizeProjectName(name); name != "" {
+				return name
+			}
+			break
+		}
+	}
+	return NormalizeProjectName(filepath.Base(dir))
+}
+
+// NormalizeProjectName applies docker compose's project name rules:
+// lowercase, only letters, digits, '-' and '_', starting with a letter or
+// digit. Returns "" for names with interpolation or nothing usable left.
+func NormalizeProjectName(name string) string {
+	if strings.Contains(name, "$") {
+		return ""
+	}
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
+			b.WriteRune(r)
+		}
+	}
+	return strings.TrimLeft(b.String(), "-_")
+}
+
+// topLevelName returns the value of the top-level name: key of a compose
+// file. ok is false if the file can't be read.
+func topLevelName(path string) (name string, ok bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "name:") {
+			continue
+		}
+		return unquote(stripInlineComment(strings.TrimSpace(strings.TrimPrefix(line, "name:")))), true
+	}
+	return "", true
+}
+
+// envFileValue returns a key's value from a .env file, or "".
+func envFileValue(path, key string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	value := ""
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		line = strings.TrimPrefix(line, "export ")
+		k, v, found := strings.Cut(line, "=")
+		if found && strings.TrimSpace(k) == key {
+			// The last assignment wins, as in compose
+			value = unquote(strings.TrimSpace(v))
+		}
+	}
+	return value
+}
@@ -0,0 +1,41 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProjectName(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		compose string
+		env     string
+		want    string
+	}{
+		{"plain", "services:\n  web:\n    image: nginx\n", "", "plain"},
+		{"named", "name: My.App # custom\nservices: {}\n", "", "myapp"},
+		{"quoted", "name: \"other\"\nservices: {}\n", "", "other"},
+		{"nested", "services:\n  web:\n    name: nope\n", "", "nested"},
+		{"interp", "name: ${PROJECT}\nservices: {}\n", "", "interp"},
+		{"envwins", "name: fromfile\n", "A=1\nCOMPOSE_PROJECT_NAME='fromenv'\n", "fromenv"},
+		{"Upper_Dir", "services: {}\n", "", "upper_dir"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir := filepath.Join(t.TempDir(), tt.name)
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			os.WriteFile(filepath.Join(dir, "compose.yaml"), []byte(tt.compose), 0644)
+			if tt.env != "" {
+				os.WriteFile(filepath.Join(dir, ".env"), []byte(tt.env), 0644)
+			}
+			if got := ProjectName(dir); got != tt.want {
+				t.Errorf("ProjectName = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
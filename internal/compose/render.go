@@ -0,0 +1,71 @@
+package compose
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RenderedConfig is the canonical YAML of `docker compose config`: the
+// compose file merged with its override file, variables interpolated and
+// profiles applied, with the warnings compose printed along the way.
+type RenderedConfig struct {
+	YAML     string   `json:"yaml"`
+	Warnings []string `json:"warnings"`
+}
+
+// RenderConfig runs `docker compose config` in the stack directory with the
+// given --env-file flags and profiles ("*" enables all of them).
+func RenderConfig(ctx context.Context, stacksDir, stackName string, envArgs, profiles []string) (*RenderedConfig, error) {
+	args := []string{"compose"}
+	args = append(args, envArgs...)
+	for _, p := range profiles {
+		args = append(args, "--profile", p)
+	}
+	args = append(args, "config")
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Dir = filepath.Join(stacksDir, stackName)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("compose config: %s", msg)
+		}
+		return nil, fmt.Errorf("compose config: %w", err)
+	}
+	return &RenderedConfig{YAML: string(out), Warnings: parseConfigWarnings(stderr.String())}, nil
+}
+
+// composeLogMsg matches the message of a logfmt log line, e.g.
+// time="..." level=warning msg="The \"TAG\" variable is not set."
+var composeLogMsg = regexp.MustCompile(`\bmsg=("(?:[^"\\]|\\.)*"|\S+)`)
+
+// parseConfigWarnings extracts the messages from compose's stderr, which
+// logs them either in logfmt or as WARN[0000] lines depending on the
+// version and whether stderr is a terminal.
+func parseConfigWarnings(stderr string) []string {
+	warnings := []string{}
+	for line := range strings.SplitSeq(stderr, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if m := composeLogMsg.FindStringSubmatch(line); m != nil {
+			msg := m[1]
+			if s, err := strconv.Unquote(msg); err == nil {
+				msg = s
+			}
+			line = msg
+		} else if _, rest, ok := strings.Cut(line, "] "); ok && strings.HasPrefix(line, "WARN[") {
+			line = rest
+		}
+		warnings = append(warnings, line)
+	}
+	return warnings
+}
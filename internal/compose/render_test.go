@@ -0,0 +1,28 @@
+package compose
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseConfigWarnings(t *testing.T) {
+	t.Parallel()
+	stderr := `time="2026-01-02T03:04:05Z" level=warning msg="The \"TAG\" variable is not set. Defaulting to a blank string."
+WARN[0000] /stacks/web/compose.yaml: the attribute ` + "`version`" + ` is obsolete
+level=warning msg=unquoted
+
+something else
+`
+	want := []string{
+		`The "TAG" variable is not set. Defaulting to a blank string.`,
+		"/stacks/web/compose.yaml: the attribute `version` is obsolete",
+		"unquoted",
+		"something else",
+	}
+	if got := parseConfigWarnings(stderr); !slices.Equal(got, want) {
+		t.Errorf("parseConfigWarnings =\n%q\nwant\n%q", got, want)
+	}
+	if got := parseConfigWarnings(""); got == nil || len(got) != 0 {
+		t.Errorf("parseConfigWarnings(\"\") = %#v, want empty", got)
+	}
+}
@@ -14,6 +14,14 @@ var acceptedComposeFileNames = []string{
     "compose.yml",
 }
 
+// Accepted compose override file names (checked in order).
+var acceptedComposeOverrideFileNames = []string{
+    "compose.override.yaml",
+    "compose.override.yml",
+    "docker-compose.override.yaml",
+    "docker-compose.override.yml",
+}
+
 // FindComposeFile returns the full path to the compose file for a stack,
 // checking accepted file names in order. Returns empty string if none found.
 func FindComposeFile(stacksDir, stackName string) string {
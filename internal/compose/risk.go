@@ -0,0 +1,254 @@
+package compose
+
+import (
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Risk levels, most severe first. Critical and high findings hand the
+// container the host or most of it.
+const (
+	RiskCritical = "critical"
+	RiskHigh     = "high"
+	RiskMedium   = "medium"
+)
+
+// Checks that produce risk findings.
+const (
+	RiskPrivileged     = "privileged"
+	RiskHostRoot       = "hostRoot"       // the host's / bind-mounted
+	RiskDockerSocket   = "dockerSocket"   // the Docker socket or its directory bind-mounted
+	RiskSensitiveMount = "sensitiveMount" // host system directories bind-mounted
+	RiskHostNetwork    = "hostNetwork"
+	RiskHostPID        = "hostPid"
+	RiskHostIPC        = "hostIpc"
+	RiskHostUserns     = "hostUserns"
+	RiskCapability     = "capability"
+	RiskUnconfined     = "unconfined" // seccomp or AppArmor off
+	RiskDevices        = "devices"
+	RiskUnresolved     = "unresolved" // a risky setting from a variable without a value
+)
+
+var riskRank = map[string]int{RiskCritical: 3, RiskHigh: 2, RiskMedium: 1}
+
+// dangerousCaps are capabilities that give a container's root most of the
+// host's; riskyCaps widen what it can do to the host's network or processes.
+var (
+	dangerousCaps = []string{"ALL", "SYS_ADMIN", "SYS_MODULE", "SYS_RAWIO", "DAC_READ_SEARCH"}
+	riskyCaps     = []string{"NET_ADMIN", "SYS_PTRACE", "SYS_TIME", "BPF", "PERFMON"}
+)
+
+// sensitiveHostPaths are host directories whose contents control the host.
+var sensitiveHostPaths = []string{"/boot", "/dev", "/etc", "/lib/modules", "/proc", "/root", "/sys", "/usr", "/var/lib/docker"}
+
+// RiskFinding is one setting of a service that weakens its isolation from
+// the host.
+type RiskFinding struct {
+	Service string `json:"service"`
+	Check   string `json:"check"`
+	Level   string `json:"level"`
+	Message string `json:"message"`
+	Line    int    `json:"line"`
+}
+
+// RiskReport is the result of AnalyzeRisks. Level is that of the most
+// severe finding, "" if there are none.
+type RiskReport struct {
+	Level    string        `json:"level"`
+	Findings []RiskFinding `json:"findings"`
+}
+
+// Blocking reports whether the report has critical or high findings, which
+// someone should accept before the compose file is deployed.
+func (r *RiskReport) Blocking() bool {
+	return riskRank[r.Level] >= riskRank[RiskHigh]
+}
+
+// AnalyzeRisks statically inspects a compose file for settings that give
+// its containers control of the host: privileged mode, bind mounts of / or
+// the Docker socket, host namespaces and dangerous capabilities. It's meant
+// for compose files from untrusted sources, before they're deployed.
+// Variables are substituted with lookup (nil for none) the way docker
+// compose does; a risky setting with a variable lookup can't resolve is
+// reported, since it can turn into anything at deploy. Services that can't
+// be parsed are skipped. Anchors and merge keys are resolved; a finding
+// from an anchor is on the anchor's line. Findings are in file order.
+func AnalyzeRisks(src string, lookup func(name string) (string, bool)) RiskReport {
+	report := RiskReport{Findings: []RiskFinding{}}
+	root, _ := parseYAMLNode(src)
+	if lookup == nil {
+		lookup = func(string) (string, bool) { return "", false }
+	}
+	unresolved := make(map[*yaml.Node][]string)
+	if root != nil {
+		interpolateNodes(root, lookup, unresolved)
+	}
+	for _, svc := range mappingEntries(yamlGet(root, "services")) {
+		if svc.Value.Kind != yaml.MappingNode || strings.HasPrefix(svc.Key, "x-") {
+			continue
+		}
+		report.Findings = append(report.Findings, serviceRisks(svc, unresolved)...)
+	}
+	slices.SortStableFunc(report.Findings, func(a, b RiskFinding) int { return a.Line - b.Line })
+	for _, f := range report.Findings {
+		if riskRank[f.Level] > riskRank[report.Level] {
+			report.Level = f.Level
+		}
+	}
+	return report
+}
+
+// interpolateNodes substitutes variables in the scalar values under n in
+// place, as docker compose does after parsing, and records the variables
+// lookup can't resolve by node. Aliases aren't followed: the nodes they
+// point to are substituted where they're defined.
+func interpolateNodes(n *yaml.Node, lookup func(string) (string, bool), unresolved map[*yaml.Node][]string) {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		if !strings.Contains(n.Value, "$") {
+			return
+		}
+		in := Interpolate(n.Value, lookup)
+		n.Value = in.Resolved
+		for _, v := range in.Vars {
+			if v.Source == VarUnset {
+				unresolved[n] = append(unresolved[n], v.Name)
+			}
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(n.Content); i += 2 {
+			interpolateNodes(n.Content[i], lookup, unresolved)
+		}
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, c := range n.Content {
+			interpolateNodes(c, lookup, unresolved)
+		}
+	}
+}
+
+func serviceRisks(svc yamlEntry, unresolved map[*yaml.Node][]string) []RiskFinding {
+	var findings []RiskFinding
+	n := svc.Value
+	add := func(node *yaml.Node, check, level, msg string) {
+		line := svc.Line
+		if node != nil {
+			line = node.Line
+		}
+		findings = append(findings, RiskFinding{Service: svc.Key, Check: check, Level: level, Message: msg, Line: line})
+	}
+	// known reports whether a setting's value is known, and if it isn't,
+	// reports it at the level the setting could reach
+	known := func(node *yaml.Node, setting, level string) bool {
+		names := unresolved[node]
+		if len(names) == 0 {
+			return true
+		}
+		add(node, RiskUnresolved, level, setting+": set by $"+strings.Join(names, ", $")+
+			", which has no value, so it can't be checked before deploy")
+		return false
+	}
+
+	if v := yamlGet(n, "privileged"); known(v, "privileged", RiskCritical) && isTrue(scalarText(v)) {
+		add(v, RiskPrivileged, RiskCritical, "privileged: the container has every capability and access to all host devices")
+	}
+	for _, ns := range []struct{ key, check, level, msg string }{
+		{"network_mode", RiskHostNetwork, RiskHigh, "network_mode host: the container shares the host's network stack and can reach services bound to localhost"},
+		{"pid", RiskHostPID, RiskHigh, "pid host: the container sees every process on the host"},
+		{"ipc", RiskHostIPC, RiskMedium, "ipc host: the container shares the host's shared memory"},
+		{"userns_mode", RiskHostUserns, RiskMedium, "userns_mode host: root in the container is root on the host"},
+	} {
+		if v := yamlGet(n, ns.key); known(v, ns.key, ns.level) && strings.EqualFold(scalarText(v), "host") {
+			add(v, ns.check, ns.level, ns.msg)
+		}
+	}
+
+	for _, c := range yamlItems(yamlGet(n, "cap_add")) {
+		if !known(c, "cap_add", RiskHigh) {
+			continue
+		}
+		name := strings.TrimPrefix(strings.ToUpper(scalarText(c)), "CAP_")
+		switch {
+		case slices.Contains(dangerousCaps, name):
+			add(c, RiskCapability, RiskHigh, "cap_add "+name+": the container's root can take over the host")
+		case slices.Contains(riskyCaps, name):
+			add(c, RiskCapability, RiskMedium, "cap_add "+name+": the container can change the host's network, clock or processes")
+		}
+	}
+	for _, o := range yamlItems(yamlGet(n, "security_opt")) {
+		if isUnconfined(scalarText(o)) {
+			add(o, RiskUnconfined, RiskMedium, "security_opt "+o.Value+": the container runs without that protection")
+		}
+	}
+	// Any device is reported, so a variable in one needs no finding of its own
+	if d := yamlGet(n, "devices"); len(yamlItems(d)) > 0 {
+		add(d, RiskDevices, RiskMedium, "devices: the container gets direct access to host devices")
+	}
+
+	for _, v := range yamlItems(yamlGet(n, "volumes")) {
+		if v.Kind == yaml.MappingNode {
+			if !known(yamlGet(v, "source"), "volumes", RiskCritical) {
+				continue
+			}
+		} else if !known(v, "volumes", RiskCritical) {
+			continue
+		}
+		source, ok := bindSource(v)
+		if !ok {
+			continue
+		}
+		switch {
+		case source == "/":
+			add(v, RiskHostRoot, RiskCritical, "bind mount of /: the container can read and change every file on the host")
+		case path.Base(source) == "docker.sock" || source == "/var/run" || source == "/run":
+			add(v, RiskDockerSocket, RiskCritical, "bind mount of "+source+": the container controls Docker, and through it the host")
+		default:
+			for _, p := range sensitiveHostPaths {
+				if source == p || strings.HasPrefix(source, p+"/") {
+					add(v, RiskSensitiveMount, RiskHigh, "bind mount of "+source+": the container can read or change host system files")
+					break
+				}
+			}
+		}
+	}
+	return findings
+}
+
+// isTrue reports whether a boolean setting is on. Compose reads booleans
+// case-insensitively and, for older files, as yes/on too.
+func isTrue(s string) bool {
+	if b, err := strconv.ParseBool(s); err == nil {
+		return b
+	}
+	return strings.EqualFold(s, "yes") || strings.EqualFold(s, "on")
+}
+
+// bindSource returns the cleaned host path of a volumes: entry that
+// bind-mounts an absolute host path, in short ("/src:/dst:ro") or long
+// syntax.
+func bindSource(v *yaml.Node) (string, bool) {
+	var source string
+	switch v.Kind {
+	case yaml.ScalarNode:
+		src, _, ok := strings.Cut(scalarText(v), ":")
+		if !ok {
+			return "", false // anonymous volume
+		}
+		source = src
+	case yaml.MappingNode:
+		t, src := yamlGet(v, "type"), scalarText(yamlGet(v, "source"))
+		if src == "" || (t != nil && t.Value != "bind") {
+			return "", false
+		}
+		source = src
+	default:
+		return "", false
+	}
+	if !strings.HasPrefix(source, "/") {
+		return "", false // named volume or path relative to the stack
+	}
+	return path.Clean(source), true
+}
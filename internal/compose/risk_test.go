@@ -0,0 +1,134 @@
+package compose
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeRisks(t *testing.T) {
+	t.Parallel()
+	report := AnalyzeRisks(`services:
+  agent:
+    image: portainer/agent
+    privileged: true
+    volumes:
+      - /var/run/docker.sock:/var/run/docker.sock
+      - /:/host:ro
+      - data:/data
+      - ./config:/config
+  vpn:
+    image: wireguard
+    network_mode: host
+    cap_add:
+      - NET_ADMIN
+      - CAP_SYS_ADMIN
+    volumes:
+      - type: bind
+        source: /etc/wireguard/
+        target: /config
+      - type: volume
+        source: /etc
+        target: /other
+  web:
+    image: nginx
+    privileged: ${PRIVILEGED}
+    volumes:
+      - ${SOCK}:/var/run/docker.sock
+    security_opt:
+      - seccomp:unconfined
+x-common:
+  privileged: true
+`, nil)
+	want := []struct {
+		service, check, level string
+		line                  int
+	}{
+		{"agent", RiskPrivileged, RiskCritical, 4},
+		{"agent", RiskDockerSocket, RiskCritical, 6},
+		{"agent", RiskHostRoot, RiskCritical, 7},
+		{"vpn", RiskHostNetwork, RiskHigh, 12},
+		{"vpn", RiskCapability, RiskMedium, 14},
+		{"vpn", RiskCapability, RiskHigh, 15},
+		{"vpn", RiskSensitiveMount, RiskHigh, 17},
+		{"web", RiskUnresolved, RiskCritical, 25},
+		{"web", RiskUnresolved, RiskCritical, 27},
+		{"web", RiskUnconfined, RiskMedium, 29},
+	}
+	if len(report.Findings) != len(want) {
+		t.Fatalf("got %d findings, want %d: %+v", len(report.Findings), len(want), report.Findings)
+	}
+	for i, w := range want {
+		f := report.Findings[i]
+		if f.Service != w.service || f.Check != w.check || f.Level != w.level || f.Line != w.line {
+			t.Errorf("finding %d = %+v, want %+v", i, f, w)
+		}
+	}
+	if report.Level != RiskCritical || !report.Blocking() {
+		t.Errorf("Level = %q, Blocking = %v", report.Level, report.Blocking())
+	}
+
+	medium := AnalyzeRisks("services:\n  app:\n    image: app\n    devices:\n      - /dev/dri:/dev/dri\n", nil)
+	if medium.Level != RiskMedium || medium.Blocking() || len(medium.Findings) != 1 || medium.Findings[0].Check != RiskDevices {
+		t.Errorf("devices report = %+v", medium)
+	}
+
+	safe := AnalyzeRisks("services:\n  web:\n    image: nginx\n    volumes:\n      - /srv/www:/usr/share/nginx/html:ro\n", nil)
+	if safe.Level != "" || safe.Blocking() || safe.Findings == nil || len(safe.Findings) != 0 {
+		t.Errorf("safe report = %+v", safe)
+	}
+}
+
+func TestAnalyzeRisksResolvesAnchorsAndVariables(t *testing.T) {
+	t.Parallel()
+	env := map[string]string{"PRIVILEGED": "TRUE", "ROOT": "/"}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+	report := AnalyzeRisks(`x-common: &common
+  privileged: true
+  volumes: ["/:/host"]
+services:
+  merged:
+    <<: *common
+    image: app
+  cased:
+    image: app
+    privileged: True
+    network_mode: HOST
+  vars:
+    image: app
+    privileged: ${PRIVILEGED}
+    volumes:
+      - ${ROOT}:/host
+      - ${DATA:-./data}:/data
+    pid: ${PID_MODE}
+    cap_add:
+      - $EXTRA_CAP
+`, lookup)
+	want := []struct {
+		service, check string
+		line           int
+	}{
+		{"merged", RiskPrivileged, 2},
+		{"merged", RiskHostRoot, 3},
+		{"cased", RiskPrivileged, 10},
+		{"cased", RiskHostNetwork, 11},
+		{"vars", RiskPrivileged, 14},
+		{"vars", RiskHostRoot, 16},
+		{"vars", RiskUnresolved, 18},
+		{"vars", RiskUnresolved, 20},
+	}
+	if len(report.Findings) != len(want) {
+		t.Fatalf("got %d findings, want %d: %+v", len(report.Findings), len(want), report.Findings)
+	}
+	for i, w := range want {
+		f := report.Findings[i]
+		if f.Service != w.service || f.Check != w.check || f.Line != w.line {
+			t.Errorf("finding %d = %+v, want %+v", i, f, w)
+		}
+	}
+	if f := report.Findings[6]; f.Level != RiskHigh || !strings.Contains(f.Message, "$PID_MODE") {
+		t.Errorf("unresolved pid = %+v", f)
+	}
+}
@@ -0,0 +1,51 @@
+package compose
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// unprivilegedPortStartFile holds the lowest port an unprivileged process
+// may bind.
+const unprivilegedPortStartFile = "/proc/sys/net/ipv4/ip_unprivileged_port_start"
+
+// UnprivilegedPortStart returns the lowest port a rootless daemon may
+// publish, or the kernel default of 1024 if it can't be read.
+func UnprivilegedPortStart() int {
+	data, err := os.ReadFile(unprivilegedPortStartFile)
+	if err != nil {
+		return 1024
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 1024
+	}
+	return n
+}
+
+// CheckPrivilegedPorts returns one problem per published port below start,
+// which a rootless daemon can't bind.
+func (p *ResolvedProject) CheckPrivilegedPorts(start int) []string {
+	names := make([]string, 0, len(p.Services))
+	for name := range p.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []string
+	for _, name := range names {
+		for _, port := range p.Services[name].Ports {
+			// Published is a port or a range ("8000-8010"); "" = random
+			low, _, _ := strings.Cut(port.Published, "-")
+			n, err := strconv.Atoi(low)
+			if err != nil || n == 0 || n >= start {
+				continue
+			}
+			problems = append(problems, fmt.Sprintf("service %q publishes port %s, but a rootless daemon can only publish ports from %d (lower net.ipv4.ip_unprivileged_port_start to allow it)", name, port.Published, start))
+		}
+	}
+	return problems
+}
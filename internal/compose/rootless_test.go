@@ -0,0 +1,33 @@
+package compose
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckPrivilegedPorts(t *testing.T) {
+	p := &ResolvedProject{Services: map[string]ResolvedService{
+		"web": {Ports: []ResolvedPort{
+			{Target: 80, Published: "80"},
+			{Target: 443, Published: "8443"},
+		}},
+		"dns":   {Ports: []ResolvedPort{{Target: 53, Published: "53-54", Protocol: "udp"}}},
+		"dyn":   {Ports: []ResolvedPort{{Target: 9000}}},
+		"proxy": {Ports: []ResolvedPort{{Target: 80, Published: "1080"}}},
+	}}
+
+	problems := p.CheckPrivilegedPorts(1024)
+	if len(problems) != 2 {
+		t.Fatalf("got %d problems, want 2: %v", len(problems), problems)
+	}
+	if !strings.Contains(problems[0], `"dns"`) || !strings.Contains(problems[0], "53-54") {
+		t.Errorf("unexpected problem %q", problems[0])
+	}
+	if !strings.Contains(problems[1], `"web"`) || !strings.Contains(problems[1], "port 80,") {
+		t.Errorf("unexpected problem %q", problems[1])
+	}
+
+	if problems := p.CheckPrivilegedPorts(0); len(problems) != 0 {
+		t.Errorf("ports allowed from 0 should pass, got %v", problems)
+	}
+}
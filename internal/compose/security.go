@@ -0,0 +1,172 @@
+package compose
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SecurityProfile is the privilege-related configuration of a service or
+// container: what it may do beyond an ordinary unprivileged container.
+type SecurityProfile struct {
+	Privileged  bool     `json:"privileged"`
+	ReadOnly    bool     `json:"readOnly"`
+	CapAdd      []string `json:"capAdd,omitempty"`
+	CapDrop     []string `json:"capDrop,omitempty"`
+	SecurityOpt []string `json:"securityOpt,omitempty"`
+	UsernsMode  string   `json:"usernsMode,omitempty"`
+	NetworkMode string   `json:"networkMode,omitempty"`
+	PidMode     string   `json:"pidMode,omitempty"`
+	Risks       []string `json:"risks,omitempty"` // see risks
+}
+
+// risks returns warnings for combinations that give a container control of
+// the host. Each is risky on its own terms, but together they remove the
+// isolation that's left.
+func (p *SecurityProfile) risks() []string {
+	var risks []string
+	hostNet := p.NetworkMode == "host"
+	if p.Privileged && hostNet {
+		risks = append(risks, "privileged with host network: the container has full control of the host and its network")
+	}
+	if p.Privileged && p.PidMode == "host" {
+		risks = append(risks, "privileged with host PID namespace: the container can control every process on the host")
+	}
+	if !p.Privileged && hostNet {
+		for _, c := range []string{"ALL", "SYS_ADMIN", "NET_ADMIN"} {
+			if p.hasCap(c) {
+				risks = append(risks, "cap_add "+c+" with host network: the container can reconfigure the host's network")
+				break
+			}
+		}
+	}
+	for _, opt := range p.SecurityOpt {
+		if p.Privileged || !isUnconfined(opt) {
+			continue
+		}
+		if p.hasCap("ALL") || p.hasCap("SYS_ADMIN") {
+			risks = append(risks, opt+" with cap_add SYS_ADMIN: nothing restricts what the container's root can do")
+			break
+		}
+	}
+	return risks
+}
+
+func (p *SecurityProfile) hasCap(name string) bool {
+	return slices.ContainsFunc(p.CapAdd, func(c string) bool {
+		return strings.TrimPrefix(strings.ToUpper(c), "CAP_") == name
+	})
+}
+
+// isUnconfined reports whether a security_opt entry turns off seccomp or
+// AppArmor ("seccomp:unconfined", "apparmor=unconfined").
+func isUnconfined(opt string) bool {
+	kind, value, ok := strings.Cut(opt, ":")
+	if !ok {
+		kind, value, _ = strings.Cut(opt, "=")
+	}
+	return (kind == "seccomp" || kind == "apparmor") && value == "unconfined"
+}
+
+// ServiceSecurity returns the security profile of each service in a
+// compose file, as written: variables aren't substituted, but anchors and
+// merge keys are resolved. Services that can't be parsed are left out.
+func ServiceSecurity(src string) map[string]SecurityProfile {
+	root, _ := parseYAMLNode(src)
+	services := mappingEntries(yamlGet(root, "services"))
+	if len(services) == 0 {
+		return nil
+	}
+	result := make(map[string]SecurityProfile, len(services))
+	for _, svc := range services {
+		if svc.Value.Kind != yaml.MappingNode || strings.HasPrefix(svc.Key, "x-") {
+			continue
+		}
+		result[svc.Key] = serviceSecurity(svc.Value)
+	}
+	return result
+}
+
+func serviceSecurity(n *yaml.Node) SecurityProfile {
+	p := SecurityProfile{
+		Privileged:  isTrue(scalarText(yamlGet(n, "privileged"))),
+		ReadOnly:    isTrue(scalarText(yamlGet(n, "read_only"))),
+		CapAdd:      scalarItems(yamlGet(n, "cap_add")),
+		CapDrop:     scalarItems(yamlGet(n, "cap_drop")),
+		SecurityOpt: scalarItems(yamlGet(n, "security_opt")),
+		UsernsMode:  scalarText(yamlGet(n, "userns_mode")),
+		NetworkMode: scalarText(yamlGet(n, "network_mode")),
+		PidMode:     scalarText(yamlGet(n, "pid")),
+	}
+	p.Risks = p.risks()
+	return p
+}
+
+// scalarItems returns the scalar items of a sequence.
+func scalarItems(n *yaml.Node) []string {
+	var items []string
+	for _, item := range yamlItems(n) {
+		if v := scalarText(item); v != "" {
+			items = append(items, v)
+		}
+	}
+	return items
+}
+
+// InspectSecurity returns the security profile of a running container from
+// its inspect data: an object, or the CLI's one-element array.
+func InspectSecurity(inspect json.RawMessage) (*SecurityProfile, error) {
+	if trimmed := bytes.TrimSpace(inspect); len(trimmed) > 0 && trimmed[0] == '[' {
+		var items []json.RawMessage
+		if err := json.Unmarshal(trimmed, &items); err != nil || len(items) == 0 {
+			return nil, fmt.Errorf("parse inspect: no container")
+		}
+		inspect = items[0]
+	}
+	var st struct {
+		HostConfig struct {
+			Privileged     bool     `json:"Privileged"`
+			ReadonlyRootfs bool     `json:"ReadonlyRootfs"`
+			CapAdd         []string `json:"CapAdd"`
+			CapDrop        []string `json:"CapDrop"`
+			SecurityOpt    []string `json:"SecurityOpt"`
+			UsernsMode     string   `json:"UsernsMode"`
+			NetworkMode    string   `json:"NetworkMode"`
+			PidMode        string   `json:"PidMode"`
+		} `json:"HostConfig"`
+	}
+	if err := json.Unmarshal(inspect, &st); err != nil {
+		return nil, fmt.Errorf("parse inspect: %w", err)
+	}
+	hc := st.HostConfig
+	p := &SecurityProfile{
+		Privileged:  hc.Privileged,
+		ReadOnly:    hc.ReadonlyRootfs,
+		CapAdd:      hc.CapAdd,
+		CapDrop:     hc.CapDrop,
+		SecurityOpt: hc.SecurityOpt,
+		UsernsMode:  hc.UsernsMode,
+		NetworkMode: hc.NetworkMode,
+		PidMode:     hc.PidMode,
+	}
+	p.Risks = p.risks()
+	return p, nil
+}
+
+// checkSecurity warns about risky privilege combinations in a service.
+// Values with variables are skipped since they aren't known until deploy.
+func (v *validator) checkSecurity(svc yamlEntry, n *yaml.Node) {
+	for _, key := range []string{"privileged", "network_mode", "pid"} {
+		if f := yamlGet(n, key); f != nil && !isPlainScalar(f) {
+			return
+		}
+	}
+	p := serviceSecurity(n)
+	for _, risk := range p.Risks {
+		v.add(svc.Line, svc.Col, SeverityWarning, "service %q: %s", svc.Key, risk)
+	}
+}
@@ -0,0 +1,81 @@
+package compose
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestServiceSecurity(t *testing.T) {
+	t.Parallel()
+	got := ServiceSecurity(`services:
+  vpn:
+    image: wireguard
+    network_mode: host
+    cap_add:
+      - NET_ADMIN
+      - SYS_MODULE
+    read_only: true
+  agent:
+    image: portainer/agent
+    privileged: true
+    network_mode: host
+    pid: host
+    security_opt:
+      - no-new-privileges:true
+  web:
+    image: nginx
+    cap_drop: [ALL]
+    userns_mode: host
+`)
+	want := map[string]SecurityProfile{
+		"vpn": {
+			ReadOnly: true, CapAdd: []string{"NET_ADMIN", "SYS_MODULE"}, NetworkMode: "host",
+			Risks: []string{"cap_add NET_ADMIN with host network: the container can reconfigure the host's network"},
+		},
+		"agent": {
+			Privileged: true, SecurityOpt: []string{"no-new-privileges:true"}, NetworkMode: "host", PidMode: "host",
+			Risks: []string{
+				"privileged with host network: the container has full control of the host and its network",
+				"privileged with host PID namespace: the container can control every process on the host",
+			},
+		},
+		"web": {CapDrop: []string{"ALL"}, UsernsMode: "host"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ServiceSecurity() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestInspectSecurity(t *testing.T) {
+	t.Parallel()
+	p, err := InspectSecurity([]byte(`{"HostConfig": {"Privileged": false, "ReadonlyRootfs": true,
+		"CapAdd": ["CAP_SYS_ADMIN"], "SecurityOpt": ["seccomp=unconfined"], "NetworkMode": "bridge"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.ReadOnly || p.NetworkMode != "bridge" || len(p.Risks) != 1 || !strings.HasPrefix(p.Risks[0], "seccomp=unconfined with cap_add SYS_ADMIN") {
+		t.Errorf("unexpected profile %+v", p)
+	}
+}
+
+func TestValidateSecurityRisks(t *testing.T) {
+	t.Parallel()
+	diags := Validate(`services:
+  agent:
+    image: portainer/agent
+    privileged: true
+    network_mode: host
+  tuned:
+    image: tuned
+    privileged: ${PRIVILEGED}
+    network_mode: host
+`, false)
+	want := []Diagnostic{{
+		Line: 2, Column: 3, Severity: SeverityWarning,
+		Message: `service "agent": privileged with host network: the container has full control of the host and its network`,
+	}}
+	if !reflect.DeepEqual(diags, want) {
+		t.Errorf("Validate() = %+v, want %+v", diags, want)
+	}
+}
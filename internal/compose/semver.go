@@ -0,0 +1,162 @@
+package compose
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Release update kinds reported by ClassifyTagUpdate.
+const (
+	ReleasePatch = "patch"
+	ReleaseMinor = "minor"
+	ReleaseMajor = "major"
+)
+
+// semverTagRe matches version tags: "1", "v1.2", "1.2.3", "16.2-alpine".
+// Components longer than 4 digits are rejected so date tags like
+// "20240101" aren't read as huge major versions.
+var semverTagRe = regexp.MustCompile(`^(v?)(\d{1,4})(?:\.(\d{1,4}))?(?:\.(\d{1,4}))?([-_].+)?$`)
+
+// semverTag is a parsed version tag. Tags are only compared with tags of
+// the same shape: same "v" prefix, component count and variant suffix.
+type semverTag struct {
+	tag     string
+	shape   string // prefix, component count and suffix
+	version [3]int
+}
+
+func parseSemverTag(tag string) (semverTag, bool) {
+	m := semverTagRe.FindStringSubmatch(tag)
+	if m == nil {
+		return semverTag{}, false
+	}
+	st := semverTag{tag: tag}
+	parts := 0
+	for i, s := range m[2:5] {
+		if s == "" {
+			break
+		}
+		st.version[i], _ = strconv.Atoi(s)
+		parts++
+	}
+	st.shape = m[1] + strconv.Itoa(parts) + m[5]
+	return st, true
+}
+
+// IsVersionTag reports whether tag looks like a version ClassifyTagUpdate
+// can compare.
+func IsVersionTag(tag string) bool {
+	return semverTagRe.MatchString(tag)
+}
+
+// ReleaseUpdate classifies the newer releases available for a version tag.
+// Patch, Minor and Major hold the newest tag at each level ("" if none);
+// Kind is the most significant level with a newer tag.
+type ReleaseUpdate struct {
+	Kind  string `json:"kind"`
+	Patch string `json:"patch,omitempty"`
+	Minor string `json:"minor,omitempty"`
+	Major string `json:"major,omitempty"`
+}
+
+// ClassifyTagUpdate compares current with a registry's tags and reports the
+// newest patch, minor and major release. Only tags of the same shape count,
+// so "1.2.3" is compared with "1.2.4" but not "1.3" or "1.2.4-alpine".
+// Returns a zero ReleaseUpdate when current isn't a version tag or nothing
+// newer exists.
+func ClassifyTagUpdate(current string, tags []string) ReleaseUpdate {
+	cur, ok := parseSemverTag(current)
+	if !ok {
+		return ReleaseUpdate{}
+	}
+	var newest [3]*semverTag // by level: patch, minor, major
+	for _, tag := range tags {
+		st, ok := parseSemverTag(tag)
+		if !ok || st.shape != cur.shape {
+			continue
+		}
+		level := -1
+		switch {
+		case st.version[0] != cur.version[0]:
+			if st.version[0] > cur.version[0] {
+				level = 2
+			}
+		case st.version[1] != cur.version[1]:
+			if st.version[1] > cur.version[1] {
+				level = 1
+			}
+		case st.version[2] > cur.version[2]:
+			level = 0
+		}
+		if level < 0 {
+			continue
+		}
+		if n := newest[level]; n == nil || versionLess(n.version, st.version) {
+			newest[level] = &st
+		}
+	}
+
+	ru := ReleaseUpdate{Patch: newest[0].name(), Minor: newest[1].name(), Major: newest[2].name()}
+	switch {
+	case ru.Major != "":
+		ru.Kind = ReleaseMajor
+	case ru.Minor != "":
+		ru.Kind = ReleaseMinor
+	case ru.Patch != "":
+		ru.Kind = ReleasePatch
+	}
+	return ru
+}
+
+// NewerVersionTags returns the tags newer than current of the same shape
+// as current, newest first and at most limit of them (0 = all). Returns nil
+// when current isn't a version tag.
+func NewerVersionTags(current string, tags []string, limit int) []string {
+	cur, ok := parseSemverTag(current)
+	if !ok {
+		return nil
+	}
+	var newer []semverTag
+	for _, tag := range tags {
+		st, ok := parseSemverTag(tag)
+		if ok && st.shape == cur.shape && versionLess(cur.version, st.version) {
+			newer = append(newer, st)
+		}
+	}
+	slices.SortFunc(newer, func(a, b semverTag) int {
+		switch {
+		case versionLess(b.version, a.version):
+			return -1
+		case versionLess(a.version, b.version):
+			return 1
+		}
+		return strings.Compare(a.tag, b.tag)
+	})
+	if limit > 0 && len(newer) > limit {
+		newer = newer[:limit]
+	}
+	names := make([]string, len(newer))
+	for i, st := range newer {
+		names[i] = st.tag
+	}
+	return names
+}
+
+// name returns the tag, or "" for nil.
+func (st *semverTag) name() string {
+	if st == nil {
+		return ""
+	}
+	return st.tag
+}
+
+func versionLess(a, b [3]int) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package compose
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestClassifyTagUpdate(t *testing.T) {
+	t.Parallel()
+	tags := []string{
+		"latest", "1", "1.2", "1.3", "2.0",
+		"1.2.3", "1.2.4", "1.2.10", "1.3.0", "1.4.1", "2.0.0", "2.1.0",
+		"1.2.5-alpine", "1.2.3-alpine", "v1.2.9", "20240101",
+	}
+	tests := []struct {
+		current string
+		want    ReleaseUpdate
+	}{
+		{"1.2.3", ReleaseUpdate{Kind: ReleaseMajor, Patch: "1.2.10", Minor: "1.4.1", Major: "2.1.0"}},
+		{"1.4.1", ReleaseUpdate{Kind: ReleaseMajor, Major: "2.1.0"}},
+		{"2.0.0", ReleaseUpdate{Kind: ReleaseMinor, Minor: "2.1.0"}},
+		{"2.1.0", ReleaseUpdate{}},
+		{"1.2", ReleaseUpdate{Kind: ReleaseMajor, Minor: "1.3", Major: "2.0"}},
+		{"1.2.3-alpine", ReleaseUpdate{Kind: ReleasePatch, Patch: "1.2.5-alpine"}},
+		{"v1.2.3", ReleaseUpdate{Kind: ReleasePatch, Patch: "v1.2.9"}},
+		{"latest", ReleaseUpdate{}},
+		{"20231201", ReleaseUpdate{}},
+	}
+	for _, tt := range tests {
+		if got := ClassifyTagUpdate(tt.current, tags); got != tt.want {
+			t.Errorf("ClassifyTagUpdate(%q) = %+v, want %+v", tt.current, got, tt.want)
+		}
+	}
+}
+
+func TestNewerVersionTags(t *testing.T) {
+	t.Parallel()
+	tags := []string{"latest", "1.2.3", "1.2.10", "1.10.0", "1.9.1", "2.0.0", "2.0.0-alpine", "1.2", "v2.1.0", "1.2.4"}
+	tests := []struct {
+		current string
+		limit   int
+		want    []string
+	}{
+		{"1.2.3", 0, []string{"2.0.0", "1.10.0", "1.9.1", "1.2.10", "1.2.4"}},
+		{"1.2.3", 2, []string{"2.0.0", "1.10.0"}},
+		{"2.0.0", 0, []string{}},
+		{"latest", 0, nil},
+	}
+	for _, tt := range tests {
+		got := NewerVersionTags(tt.current, tags, tt.limit)
+		if !slices.Equal(got, tt.want) || (tt.want == nil) != (got == nil) {
+			t.Errorf("NewerVersionTags(%q, %d) = %v, want %v", tt.current, tt.limit, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package compose
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StartStage is a group of services a staged startup starts together.
+type StartStage struct {
+	Delay    time.Duration `json:"delay"` // after the startup began
+	Services []string      `json:"services"`
+}
+
+// StartStages groups a compose file's services by their
+// x-dockge.start_delay, for apps that crash when started before a service
+// they need is ready and can't wait for it with depends_on conditions:
+//
+//	services:
+//	  app:
+//	    x-dockge:
+//	      start_delay: 20s
+//
+// A delay is a Go duration or a number of seconds. Stages are sorted by
+// delay; services without one form the first stage. Returns nil if no
+// service sets a delay, i.e. the stack starts in one go.
+func StartStages(composeYAML string) ([]StartStage, error) {
+	var doc struct {
+		Services map[string]struct {
+			XDockge struct {
+				StartDelay any `yaml:"start_delay"`
+			} `yaml:"x-dockge"`
+		} `yaml:"services"`
+	}
+	if err := yaml.Unmarshal([]byte(composeYAML), &doc); err != nil {
+		return nil, fmt.Errorf("parse compose file: %w", err)
+	}
+
+	byDelay := make(map[time.Duration][]string)
+	staged := false
+	for name, svc := range doc.Services {
+		delay, err := parseStartDelay(svc.XDockge.StartDelay)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: x-dockge.start_delay: %w", name, err)
+		}
+		staged = staged || delay > 0
+		byDelay[delay] = append(byDelay[delay], name)
+	}
+	if !staged {
+		return nil, nil
+	}
+
+	stages := make([]StartStage, 0, len(byDelay))
+	for delay, services := range byDelay {
+		sort.Strings(services)
+		stages = append(stages, StartStage{Delay: delay, Services: services})
+	}
+	sort.Slice(stages, func(i, j int) bool { return stages[i].Delay < stages[j].Delay })
+	return stages, nil
+}
+
+// parseStartDelay reads a duration ("1m30s") or a number of seconds.
+func parseStartDelay(v any) (time.Duration, error) {
+	var d time.Duration
+	switch v := v.(type) {
+	case nil:
+		return 0, nil
+	case int:
+		d = time.Duration(v) * time.Second
+	case float64:
+		d = time.Duration(v * float64(time.Second))
+	case string:
+		s := strings.TrimSpace(v)
+		if secs, err := strconv.ParseFloat(s, 64); err == nil {
+			d = time.Duration(secs * float64(time.Second))
+			break
+		}
+		var err error
+		if d, err = time.ParseDuration(s); err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+	default:
+		return 0, fmt.Errorf("invalid duration %v", v)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("negative duration %v", d)
+	}
+	return d, nil
+}
@@ -0,0 +1,52 @@
+package compose
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStartStages(t *testing.T) {
+	t.Parallel()
+	yaml := `x-delayed: &delayed
+  x-dockge:
+    start_delay: 30s
+services:
+  db:
+    image: postgres:16
+  cache:
+    image: redis:7
+  app:
+    image: myapp
+    x-dockge:
+      start_delay: 10
+  worker:
+    image: myapp
+    x-dockge:
+      start_delay: "10s"
+  report:
+    <<: *delayed
+    image: myapp
+`
+	stages, err := StartStages(yaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []StartStage{
+		{Delay: 0, Services: []string{"cache", "db"}},
+		{Delay: 10 * time.Second, Services: []string{"app", "worker"}},
+		{Delay: 30 * time.Second, Services: []string{"report"}},
+	}
+	if !reflect.DeepEqual(stages, want) {
+		t.Errorf("StartStages = %+v, want %+v", stages, want)
+	}
+
+	if stages, err := StartStages("services:\n  web:\n    image: nginx\n"); err != nil || stages != nil {
+		t.Errorf("without delays: %+v, %v", stages, err)
+	}
+	for _, delay := range []string{"soon", "-5s"} {
+		if _, err := StartStages("services:\n  web:\n    x-dockge:\n      start_delay: " + delay + "\n"); err == nil {
+			t.Errorf("start_delay %s: expected an error", delay)
+		}
+	}
+}
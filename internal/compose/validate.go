@@ -0,0 +1,350 @@
+package compose
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Diagnostic severities.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Diagnostic is a problem found in a compose file. Line and Column are
+// 1-based.
+type Diagnostic struct {
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+func keySet(keys ...string) map[string]bool {
+	m := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		m[k] = true
+	}
+	return m
+}
+
+// Allowed keys, from the compose specification.
+var (
+	topLevelKeys = keySet("version", "name", "services", "networks", "volumes", "configs", "secrets", "include", "models")
+
+	serviceKeys = keySet("annotations", "attach", "blkio_config", "build", "cap_add", "cap_drop", "cgroup",
+		"cgroup_parent", "command", "configs", "container_name", "cpu_count", "cpu_percent", "cpu_period",
+		"cpu_quota", "cpu_rt_period", "cpu_rt_runtime", "cpu_shares", "cpus", "cpuset", "credential_spec",
+		"depends_on", "deploy", "develop", "device_cgroup_rules", "devices", "dns", "dns_opt", "dns_search",
+		"domainname", "driver_opts", "entrypoint", "env_file", "environment", "expose", "extends",
+		"external_links", "extra_hosts", "gpus", "group_add", "healthcheck", "hostname", "image", "init", "ipc",
+		"isolation", "label_file", "labels", "links", "logging", "mac_address", "mem_limit", "mem_reservation",
+		"mem_swappiness", "memswap_limit", "models", "network_mode", "networks", "oom_kill_disable",
+		"oom_score_adj", "pid", "pids_limit", "platform", "ports", "post_start", "pre_stop", "privileged",
+		"profiles", "provider", "pull_policy", "read_only", "restart", "runtime", "scale", "secrets",
+		"security_opt", "shm_size", "stdin_open", "stop_grace_period", "stop_signal", "storage_opt", "sysctls",
+		"tmpfs", "tty", "ulimits", "use_api_socket", "user", "userns_mode", "uts", "volumes", "volumes_from",
+		"working_dir")
+
+	buildKeys = keySet("context", "dockerfile", "dockerfile_inline", "args", "ssh", "cache_from", "cache_to",
+		"additional_contexts", "entitlements", "extra_hosts", "isolation", "labels", "network", "no_cache",
+		"platforms", "privileged", "provenance", "pull", "sbom", "secrets", "shm_size", "tags", "target", "ulimits")
+
+	deployKeys        = keySet("mode", "replicas", "labels", "endpoint_mode", "placement", "resources", "restart_policy", "rollback_config", "update_config")
+	restartPolicyKeys = keySet("condition", "delay", "max_attempts", "window")
+	healthcheckKeys   = keySet("test", "interval", "timeout", "retries", "start_period", "start_interval", "disable")
+	loggingKeys       = keySet("driver", "options")
+
+	networkKeys = keySet("name", "driver", "driver_opts", "ipam", "external", "internal", "enable_ipv4", "enable_ipv6", "attachable", "labels")
+	volumeKeys  = keySet("name", "driver", "driver_opts", "external", "labels")
+	configKeys  = keySet("name", "file", "environment", "content", "external", "labels", "template_driver")
+	secretKeys  = keySet("name", "file", "environment", "external", "labels", "driver", "driver_opts", "template_driver")
+)
+
+var restartOnFailure = regexp.MustCompile(`^on-failure(:[0-9]+)?$`)
+
+// Validate checks a compose file and returns its problems sorted by
+// position. Syntax errors, services without an image or build, invalid
+// restart policies and host ports published twice are errors; unknown keys
+// are warnings since compose may be newer than this list, and so are risky
+// privilege combinations such as privileged with the host network. An override file
+// only adds to the main one, so its services need no image. Anchors,
+// aliases and merge keys are resolved; a problem in an anchor merged into
+// several services is reported once, where the anchor defines it.
+func Validate(yaml string, override bool) []Diagnostic {
+	root, diags := parseYAMLNode(yaml)
+	v := &validator{diags: diags, override: override}
+	if len(diags) == 0 || root != nil {
+		v.validate(root)
+	}
+	sort.SliceStable(v.diags, func(i, j int) bool {
+		if v.diags[i].Line != v.diags[j].Line {
+			return v.diags[i].Line < v.diags[j].Line
+		}
+		return v.diags[i].Column < v.diags[j].Column
+	})
+	return slices.Compact(v.diags)
+}
+
+type validator struct {
+	diags     []Diagnostic
+	override  bool
+	published map[string][]publishedPort // by "port/proto"
+}
+
+type publishedPort struct {
+	service string
+	hostIP  string
+	line    int
+}
+
+func (v *validator) add(line, col int, severity, format string, args ...any) {
+	v.diags = append(v.diags, Diagnostic{Line: line, Column: col, Severity: severity, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) validate(root *yaml.Node) {
+	if root == nil {
+		if !v.override {
+			v.add(1, 1, SeverityError, "compose file is empty")
+		}
+		return
+	}
+	root = resolveAlias(root)
+	if root.Kind != yaml.MappingNode {
+		v.add(root.Line, root.Column, SeverityError, "compose file must be a mapping")
+		return
+	}
+	v.checkKeys(root, topLevelKeys, "top-level")
+
+	for _, e := range mappingEntries(root) {
+		switch e.Key {
+		case "services":
+			v.checkServices(e)
+		case "networks":
+			v.checkSection(e, networkKeys, "network")
+		case "volumes":
+			v.checkSection(e, volumeKeys, "volume")
+		case "configs":
+			v.checkSection(e, configKeys, "config")
+		case "secrets":
+			v.checkSection(e, secretKeys, "secret")
+		}
+	}
+}
+
+// checkKeys warns about keys that aren't in allowed. Extension keys (x-*)
+// and merge keys are always allowed.
+func (v *validator) checkKeys(n *yaml.Node, allowed map[string]bool, what string) {
+	for _, e := range mappingEntries(n) {
+		if allowed[e.Key] || strings.HasPrefix(e.Key, "x-") {
+			continue
+		}
+		v.add(e.Line, e.Col, SeverityWarning, "unknown %s key %q", what, e.Key)
+	}
+}
+
+// isMappingOrNull reports whether n is a mapping or a null value.
+func isMappingOrNull(n *yaml.Node) bool {
+	return n == nil || n.Kind == yaml.MappingNode || isYAMLNull(n)
+}
+
+func (v *validator) checkSection(e yamlEntry, allowed map[string]bool, what string) {
+	if !isMappingOrNull(e.Value) {
+		v.add(e.Value.Line, e.Value.Column, SeverityError, "%q must be a mapping of %s names", e.Key, what)
+		return
+	}
+	for _, item := range mappingEntries(e.Value) {
+		if strings.HasPrefix(item.Key, "x-") {
+			continue
+		}
+		if !isMappingOrNull(item.Value) {
+			v.add(item.Value.Line, item.Value.Column, SeverityError, "%s %q must be a mapping", what, item.Key)
+			continue
+		}
+		v.checkKeys(item.Value, allowed, what)
+	}
+}
+
+func (v *validator) checkServices(e yamlEntry) {
+	if !isMappingOrNull(e.Value) {
+		v.add(e.Value.Line, e.Value.Column, SeverityError, `"services" must be a mapping of service names`)
+		return
+	}
+	for _, svc := range mappingEntries(e.Value) {
+		if strings.HasPrefix(svc.Key, "x-") {
+			continue
+		}
+		v.checkService(svc)
+	}
+}
+
+func (v *validator) checkService(svc yamlEntry) {
+	n := svc.Value
+	if n.Kind != yaml.MappingNode {
+		if isYAMLNull(n) && v.override {
+			return
+		}
+		if !isYAMLNull(n) {
+			v.add(n.Line, n.Column, SeverityError, "service %q must be a mapping", svc.Key)
+			return
+		}
+		n = &yaml.Node{Kind: yaml.MappingNode}
+	}
+	v.checkKeys(n, serviceKeys, "service")
+
+	// extends can supply the image
+	if !v.override && !yamlHas(n, "image") && !yamlHas(n, "build") && !yamlHas(n, "extends") {
+		v.add(svc.Line, svc.Col, SeverityError, "service %q has neither an image nor a build section", svc.Key)
+	}
+	if img := yamlGet(n, "image"); img != nil && img.Kind == yaml.ScalarNode && strings.TrimSpace(scalarText(img)) == "" {
+		v.add(img.Line, img.Column, SeverityError, "service %q has an empty image", svc.Key)
+	}
+
+	if build := yamlGet(n, "build"); build != nil {
+		v.checkKeys(build, buildKeys, "build")
+	}
+	if hc := yamlGet(n, "healthcheck"); hc != nil {
+		v.checkKeys(hc, healthcheckKeys, "healthcheck")
+	}
+	if logging := yamlGet(n, "logging"); logging != nil {
+		v.checkKeys(logging, loggingKeys, "logging")
+	}
+	if deploy := yamlGet(n, "deploy"); deploy != nil {
+		v.checkKeys(deploy, deployKeys, "deploy")
+		if rp := yamlGet(deploy, "restart_policy"); rp != nil {
+			v.checkKeys(rp, restartPolicyKeys, "restart_policy")
+			if cond := yamlGet(rp, "condition"); isPlainScalar(cond) {
+				switch cond.Value {
+				case "none", "on-failure", "any":
+				default:
+					v.add(cond.Line, cond.Column, SeverityError, "invalid restart_policy condition %q; expected none, on-failure or any", cond.Value)
+				}
+			}
+		}
+	}
+
+	if restart := yamlGet(n, "restart"); isPlainScalar(restart) {
+		switch restart.Value {
+		case "no", "always", "unless-stopped":
+		default:
+			if !restartOnFailure.MatchString(restart.Value) {
+				v.add(restart.Line, restart.Column, SeverityError, "invalid restart policy %q; expected no, always, on-failure[:max-retries] or unless-stopped", restart.Value)
+			}
+		}
+	}
+
+	for _, item := range yamlItems(yamlGet(n, "ports")) {
+		v.checkPort(svc.Key, item)
+	}
+	v.checkSecurity(svc, n)
+}
+
+// isPlainScalar reports whether n is a known scalar without interpolation.
+func isPlainScalar(n *yaml.Node) bool {
+	return scalarText(n) != "" && !strings.Contains(n.Value, "$")
+}
+
+// checkPort records the host ports an entry of ports: publishes and reports
+// the ones another entry already published on an overlapping address.
+func (v *validator) checkPort(service string, item *yaml.Node) {
+	var hostIP, published, proto string
+	switch item.Kind {
+	case yaml.ScalarNode:
+		if strings.Contains(item.Value, "$") {
+			return
+		}
+		hostIP, published, proto = parseShortPort(scalarText(item))
+	case yaml.MappingNode:
+		for _, key := range []string{"published", "host_ip", "protocol"} {
+			if strings.Contains(scalarText(yamlGet(item, key)), "$") {
+				return
+			}
+		}
+		published = scalarText(yamlGet(item, "published"))
+		hostIP = scalarText(yamlGet(item, "host_ip"))
+		proto = scalarText(yamlGet(item, "protocol"))
+	default:
+		return
+	}
+	if published == "" {
+		return
+	}
+	if proto == "" {
+		proto = "tcp"
+	}
+	switch hostIP {
+	case "0.0.0.0", "::", "[::]":
+		hostIP = ""
+	}
+
+	first, last, ok := parsePortRange(published)
+	if !ok {
+		v.add(item.Line, item.Column, SeverityError, "invalid published port %q", published)
+		return
+	}
+	if v.published == nil {
+		v.published = make(map[string][]publishedPort)
+	}
+	for port := first; port <= last; port++ {
+		key := strconv.Itoa(port) + "/" + proto
+		for _, prev := range v.published[key] {
+			if prev.hostIP == hostIP || prev.hostIP == "" || hostIP == "" {
+				v.add(item.Line, item.Column, SeverityError, "host port %s is already published by service %q on line %d", key, prev.service, prev.line)
+				return
+			}
+		}
+		v.published[key] = append(v.published[key], publishedPort{service: service, hostIP: hostIP, line: item.Line})
+	}
+}
+
+// parseShortPort splits "[host_ip:][published:]target[/protocol]". The
+// published part is empty when the port isn't published on the host or is
+// published on a random port.
+func parseShortPort(s string) (hostIP, published, proto string) {
+	if i := strings.LastIndexByte(s, '/'); i >= 0 {
+		s, proto = s[:i], s[i+1:]
+	}
+	if strings.HasPrefix(s, "[") {
+		end := strings.Index(s, "]:")
+		if end < 0 {
+			return "", "", proto
+		}
+		hostIP, s = s[1:end], s[end+2:]
+		published, _, _ = strings.Cut(s, ":")
+		if !strings.Contains(s, ":") {
+			published = ""
+		}
+		return hostIP, published, proto
+	}
+	parts := strings.Split(s, ":")
+	switch len(parts) {
+	case 2:
+		return "", parts[0], proto
+	case 3:
+		return parts[0], parts[1], proto
+	}
+	return "", "", proto
+}
+
+// parsePortRange parses "8080" or "8000-8010".
+func parsePortRange(s string) (first, last int, ok bool) {
+	lo, hi, isRange := strings.Cut(s, "-")
+	first, err := strconv.Atoi(lo)
+	if err != nil || first < 1 || first > 65535 {
+		return 0, 0, false
+	}
+	if !isRange {
+		return first, first, true
+	}
+	last, err = strconv.Atoi(hi)
+	if err != nil || last < first || last > 65535 {
+		return 0, 0, false
+	}
+	return first, last, true
+}
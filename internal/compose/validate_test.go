@@ -0,0 +1,127 @@
+package compose
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		yaml     string
+		override bool
+		want     []Diagnostic
+	}{
+		{
+			name: "valid file with anchors, flow and block scalars",
+			yaml: `x-common: &common
+  restart: unless-stopped
+services:
+  web:
+    <<: *common
+    image: nginx:1.27 # pinned
+    ports:
+      - "8080:80"
+      - target: 443
+        published: 8443
+    healthcheck:
+      test:
+        [
+          "CMD", "curl", "-f", "http://localhost",
+        ]
+    command: |
+      nginx -g 'daemon off;'
+  worker:
+    build: ./worker
+    restart: on-failure:3
+    ports: ["127.0.0.1:9000:9000", "9001"]
+volumes:
+  data: null
+`,
+		},
+		{
+			name: "schema problems",
+			yaml: `services:
+  web:
+    image: nginx
+    restart: sometimes
+    prots:
+      - 80:80
+  db:
+    environment:
+      A: b
+    deploy:
+      restart_policy:
+        condition: never
+`,
+			want: []Diagnostic{
+				{Line: 4, Column: 14, Severity: SeverityError, Message: `invalid restart policy "sometimes"; expected no, always, on-failure[:max-retries] or unless-stopped`},
+				{Line: 5, Column: 5, Severity: SeverityWarning, Message: `unknown service key "prots"`},
+				{Line: 7, Column: 3, Severity: SeverityError, Message: `service "db" has neither an image nor a build section`},
+				{Line: 12, Column: 20, Severity: SeverityError, Message: `invalid restart_policy condition "never"; expected none, on-failure or any`},
+			},
+		},
+		{
+			name: "duplicate host ports",
+			yaml: `services:
+  a:
+    image: a
+    ports:
+      - "8080:80"
+      - 127.0.0.1:53:53/udp
+  b:
+    image: b
+    ports:
+      - 127.0.0.1:8080:80
+      - 53:53
+      - published: 8000-8001
+        target: 80
+  c:
+    image: c
+    ports:
+      - 8001:80
+`,
+			want: []Diagnostic{
+				{Line: 10, Column: 9, Severity: SeverityError, Message: `host port 8080/tcp is already published by service "a" on line 5`},
+				{Line: 17, Column: 9, Severity: SeverityError, Message: `host port 8001/tcp is already published by service "b" on line 12`},
+			},
+		},
+		{
+			name: "duplicate keys",
+			yaml: "services:\n  web:\n    image: nginx\n    image: httpd\n",
+			want: []Diagnostic{
+				{Line: 4, Column: 5, Severity: SeverityError, Message: `duplicate key "image" (first defined on line 3)`},
+			},
+		},
+		{
+			name: "syntax errors",
+			yaml: "services:\n  web:\n    image: nginx\n\tports: []\n",
+			want: []Diagnostic{
+				{Line: 3, Column: 1, Severity: SeverityError, Message: "found a tab character that violates indentation"},
+			},
+		},
+		{
+			name: "merge keys",
+			yaml: "x-base: &base\n  image: nginx\n  ports:\n    - \"80:80\"\n  restart: sometimes\nservices:\n  web:\n    <<: *base\n  api:\n    <<: *base\n    restart: always\n",
+			want: []Diagnostic{
+				{Line: 4, Column: 7, Severity: SeverityError, Message: `host port 80/tcp is already published by service "web" on line 4`},
+				{Line: 5, Column: 12, Severity: SeverityError, Message: `invalid restart policy "sometimes"; expected no, always, on-failure[:max-retries] or unless-stopped`},
+			},
+		},
+		{
+			name:     "override services need no image",
+			yaml:     "services:\n  web:\n    environment:\n      - DEBUG=1\n",
+			override: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := Validate(tt.yaml, tt.override)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
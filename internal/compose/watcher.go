@@ -70,17 +70,47 @@ func runWatcher(ctx context.Context, stacksDir string, onChange func(stackName s
 		return fmt.Errorf("watch stacks dir: %w", err)
 	}
 
+	// env_file paths outside the stack directory (or in subdirectories of
+	// it) are watched through their directory: path → stacks using it
+	envFileStacks := make(map[string]map[string]bool)
+	envDirs := make(map[string]bool)
+	trackEnvFiles := func(stackName string) {
+		for path, stacks := range envFileStacks {
+			delete(stacks, stackName)
+			if len(stacks) == 0 {
+				delete(envFileStacks, path)
+			}
+		}
+		for _, path := range EnvFilePaths(stacksDir, stackName) {
+			if envFileStacks[path] == nil {
+				envFileStacks[path] = make(map[string]bool)
+			}
+			envFileStacks[path][stackName] = true
+			dir := filepath.Dir(path)
+			if envDirs[dir] || filepath.Dir(dir) == stacksDir {
+				continue // stack directories are already watched
+			}
+			if err := watcher.Add(dir); err != nil {
+				slog.Debug("compose watcher: add env_file dir", "err", err, "dir", dir)
+				continue
+			}
+			envDirs[dir] = true
+		}
+	}
+
 	// Watch each existing stack subdirectory
 	entries, err := os.ReadDir(stacksDir)
 	if err != nil {
 		return fmt.Errorf("read stacks dir: %w", err)
 	}
 	for _, entry := range entries {
-		if entry.IsDir() {
-			subdir := filepath.Join(stacksDir, entry.Name())
+		subdir := filepath.Join(stacksDir, entry.Name())
+		// Stat rather than entry.IsDir() so linked stack directories are watched too
+		if info, err := os.Stat(subdir); err == nil && info.IsDir() {
 			if err := watcher.Add(subdir); err != nil {
 				slog.Warn("compose watcher: add subdir", "err", err, "dir", subdir)
 			}
+			trackEnvFiles(entry.Name())
 		}
 	}
 
@@ -133,6 +163,13 @@ func runWatcher(ctx context.Context, stacksDir string, onChange func(stackName s
 			name := filepath.Base(event.Name)
 			dir := filepath.Dir(event.Name)
 
+			// An env_file of one or more stacks
+			if stacks, ok := envFileStacks[filepath.Clean(event.Name)]; ok && event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 {
+				for stackName := range stacks {
+					triggerUpdate(stackName)
+				}
+			}
+
 			// Case 1: Event in the stacks directory itself (new/removed subdirs)
 			if dir == stacksDir {
 				if event.Op&(fsnotify.Create|fsnotify.Rename) != 0 {
@@ -162,13 +199,17 @@ func runWatcher(ctx context.Context, stacksDir string, onChange func(stackName s
 				continue
 			}
 
-			// Only react to compose file changes
-			if !isComposeFile(name) {
+			// Only react to compose file changes, and .env which can set
+			// COMPOSE_PROJECT_NAME. env_files were handled above.
+			if !isComposeFile(name) && !isComposeOverrideFile(name) && name != ".env" {
 				continue
 			}
 
 			// Handle write, create, remove, rename events
 			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 {
+				if name != ".env" {
+					trackEnvFiles(stackName) // env_file entries may have changed
+				}
 				triggerUpdate(stackName)
 			}
 
@@ -182,6 +223,17 @@ func runWatcher(ctx context.Context, stacksDir string, onChange func(stackName s
 	}
 }
 
+// isComposeOverrideFile checks if a filename matches any accepted compose
+// override file name.
+func isComposeOverrideFile(name string) bool {
+	for _, accepted := range acceptedComposeOverrideFileNames {
+		if name == accepted {
+			return true
+		}
+	}
+	return false
+}
+
 // isComposeFile checks if a filename matches any accepted compose file name.
 func isComposeFile(name string) bool {
 	for _, accepted := range acceptedComposeFileNames {
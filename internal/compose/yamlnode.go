@@ -0,0 +1,168 @@
+package compose
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The validator and the security and risk checks need positions and
+// nesting, which the line scanners in this package don't keep. They walk
+// the yaml.v3 node tree, with aliases and merge keys resolved, so a service
+// that takes its settings from an anchor is checked like one that spells
+// them out.
+
+// yamlEntry is a mapping key and its value. Line and Col are 1-based and
+// point at the key; merged entries point where the anchor defines them.
+type yamlEntry struct {
+	Key   string
+	Line  int
+	Col   int
+	Value *yaml.Node
+}
+
+// yamlErrorRe matches the position yaml.v3 puts in its error messages.
+var yamlErrorRe = regexp.MustCompile(`^yaml: line (\d+): `)
+
+// parseYAMLNode parses yaml and returns the root node (nil for an empty
+// document) with its syntax errors and duplicate keys.
+func parseYAMLNode(src string) (*yaml.Node, []Diagnostic) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(src), &doc); err != nil {
+		msg, line := err.Error(), 1
+		if m := yamlErrorRe.FindStringSubmatch(msg); m != nil {
+			line, _ = strconv.Atoi(m[1])
+			msg = msg[len(m[0]):]
+		}
+		msg = strings.TrimPrefix(msg, "yaml: ")
+		return nil, []Diagnostic{{Line: line, Column: 1, Severity: SeverityError, Message: msg}}
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	root := doc.Content[0]
+	if root.Kind == yaml.ScalarNode && root.Tag == "!!null" {
+		return nil, nil
+	}
+	return root, duplicateKeys(root, nil)
+}
+
+// duplicateKeys reports keys defined twice in the same mapping. yaml.v3
+// only rejects them when decoding into a Go value.
+func duplicateKeys(n *yaml.Node, diags []Diagnostic) []Diagnostic {
+	if n.Kind == yaml.MappingNode {
+		first := make(map[string]int, len(n.Content)/2)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k := n.Content[i]
+			if k.Value == "<<" {
+				continue
+			}
+			if line, ok := first[k.Value]; ok {
+				diags = append(diags, Diagnostic{Line: k.Line, Column: k.Column, Severity: SeverityError,
+					Message: fmt.Sprintf("duplicate key %q (first defined on line %d)", k.Value, line)})
+				continue
+			}
+			first[k.Value] = k.Line
+		}
+	}
+	// Aliases aren't followed: their target is checked where it's defined
+	for _, c := range n.Content {
+		diags = duplicateKeys(c, diags)
+	}
+	return diags
+}
+
+// yamlResolve follows aliases; it returns nil for nil.
+func yamlResolve(n *yaml.Node) *yaml.Node {
+	if n == nil {
+		return nil
+	}
+	return resolveAlias(n)
+}
+
+// mappingEntries returns the entries of a mapping in file order, followed
+// by the ones its merge keys add. Keys written out win over merged ones,
+// and earlier merge sources over later ones, as in YAML.
+func mappingEntries(n *yaml.Node) []yamlEntry {
+	n = yamlResolve(n)
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	var entries, merged []yamlEntry
+	seen := make(map[string]bool, len(n.Content)/2)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		if k.Value == "<<" && k.Tag != "!!str" {
+			src := yamlResolve(v)
+			sources := []*yaml.Node{src}
+			if src.Kind == yaml.SequenceNode {
+				sources = src.Content
+			}
+			for _, s := range sources {
+				merged = append(merged, mappingEntries(s)...)
+			}
+			continue
+		}
+		if seen[k.Value] {
+			continue
+		}
+		seen[k.Value] = true
+		entries = append(entries, yamlEntry{Key: k.Value, Line: k.Line, Col: k.Column, Value: yamlResolve(v)})
+	}
+	for _, e := range merged {
+		if !seen[e.Key] {
+			seen[e.Key] = true
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// yamlGet returns the value of key in a mapping, or nil.
+func yamlGet(n *yaml.Node, key string) *yaml.Node {
+	for _, e := range mappingEntries(n) {
+		if e.Key == key {
+			return e.Value
+		}
+	}
+	return nil
+}
+
+// yamlHas reports whether a mapping has key, even with a null value.
+func yamlHas(n *yaml.Node, key string) bool {
+	for _, e := range mappingEntries(n) {
+		if e.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// yamlItems returns the items of a sequence, or nil.
+func yamlItems(n *yaml.Node) []*yaml.Node {
+	n = yamlResolve(n)
+	if n == nil || n.Kind != yaml.SequenceNode {
+		return nil
+	}
+	items := make([]*yaml.Node, len(n.Content))
+	for i, item := range n.Content {
+		items[i] = resolveAlias(item)
+	}
+	return items
+}
+
+// isYAMLNull reports whether n is a null scalar ("", "~" or "null").
+func isYAMLNull(n *yaml.Node) bool {
+	return n != nil && n.Kind == yaml.ScalarNode && n.Tag == "!!null"
+}
+
+// scalarText returns the text of a scalar, "" for null and collections.
+func scalarText(n *yaml.Node) string {
+	if n == nil || n.Kind != yaml.ScalarNode || n.Tag == "!!null" {
+		return ""
+	}
+	return n.Value
+}
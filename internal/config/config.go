@@ -4,6 +4,7 @@ import (
     "flag"
     "log/slog"
     "os"
+    "path/filepath"
     "strconv"
     "strings"
 )
@@ -17,12 +18,63 @@ type Config struct {
     NoAuth    bool       // Skip authentication (all endpoints open)
     Pprof     bool       // Enable /debug/pprof/ endpoints
     MaxProcs  int        // GOMAXPROCS override (default 1)
+
+    // Profile watchdog: capture heap/goroutine profiles to <data-dir>/profiles
+    // when a threshold is crossed. 0 disables the corresponding trigger.
+    ProfileHeapMB     int // HeapAlloc threshold in MiB
+    ProfileGoroutines int // goroutine count threshold
+    ProfileKeep       int // profiles kept per kind
+
+    // CORS and framing. Both take comma-separated lists.
+    CORSOrigins    []string // extra origins allowed to call the API
+    WSOrigins      []string // extra origins allowed to open the WebSocket; defaults to CORSOrigins
+    FrameAncestors []string // origins allowed to embed the UI in an iframe
+
+    // Agent mode: connect to a controller instead of only serving the UI.
+    ControllerURL string // ws(s)://controller/agent ("" = not an agent)
+    AgentToken    string // token issued by the controller
+
+    // Agent link hardening (see agent.Config).
+    ControllerFingerprint string // pinned SHA-256 of the controller's TLS certificate
+    AgentCert             string // client certificate for mutual TLS
+    AgentKey              string // its private key
+
+    // Stack templates beyond the built-in ones. Both take comma-separated
+    // lists; <data-dir>/templates is always searched.
+    TemplateDirs     []string // directories of templates
+    TemplateCatalogs []string // URLs of remote JSON catalogs
+
+    // AuditLogFile receives every audit log entry as a JSON line, in
+    // addition to the database ("" = disabled).
+    AuditLogFile string
+
+    // RestoreFile is a full backup restored at startup, replacing the
+    // database and the stacks in it ("" = none).
+    RestoreFile string
+
+    // HostTerminal lets admins open a shell on the Docker host: "local"
+    // runs it as a child of Dockge, "container" in a privileged helper
+    // container that enters the host's namespaces ("" = disabled).
+    HostTerminal      string
+    HostTerminalImage string // image of the helper container
+
+    // Vault resolves vault:mount/path#key placeholders in env files at
+    // deploy time ("" = not configured). VAULT_ADDR and VAULT_TOKEN are
+    // used if the Dockge options aren't set.
+    VaultAddr  string
+    VaultToken string
 }
 
+// Host terminal modes.
+const (
+    HostTerminalLocal     = "local"
+    HostTerminalContainer = "container"
+)
+
 func Parse() *Config {
     cfg := &Config{}
 
-    var logLevel string
+    var logLevel, corsOrigins, wsOrigins, frameAncestors, templateDirs, templateCatalogs string
     flag.IntVar(&cfg.Port, "port", 5001, "HTTP server port")
     flag.StringVar(&cfg.StacksDir, "stacks-dir", "/opt/stacks", "Path to stacks directory")
     flag.StringVar(&cfg.DataDir, "data-dir", "./data", "Path to data directory (SQLite DB)")
@@ -30,6 +82,25 @@ func Parse() *Config {
     flag.StringVar(&logLevel, "log-level", "info", "Log level (debug, info, warn, error)")
     flag.BoolVar(&cfg.NoAuth, "no-auth", false, "Disable authentication (all endpoints open)")
     flag.IntVar(&cfg.MaxProcs, "max-procs", 1, "GOMAXPROCS limit (0 = use Go default)")
+    flag.IntVar(&cfg.ProfileHeapMB, "profile-heap-mb", 28, "Capture profiles when heap exceeds this many MiB (0 = disabled)")
+    flag.IntVar(&cfg.ProfileGoroutines, "profile-goroutines", 1000, "Capture profiles when goroutine count exceeds this (0 = disabled)")
+    flag.IntVar(&cfg.ProfileKeep, "profile-keep", 10, "Number of captured profiles kept per kind")
+    flag.StringVar(&corsOrigins, "cors-origins", "", "Comma-separated origin patterns allowed cross-origin access (e.g. dash.example.com,*.home.lan)")
+    flag.StringVar(&wsOrigins, "ws-origins", "", "Comma-separated origin patterns allowed to open the WebSocket (default: --cors-origins)")
+    flag.StringVar(&frameAncestors, "frame-ancestors", "", "Comma-separated origins allowed to embed the UI in an iframe")
+    flag.StringVar(&cfg.ControllerURL, "controller-url", "", "Run in agent mode, connecting to this controller (e.g. wss://dockge.example.com/agent)")
+    flag.StringVar(&cfg.AgentToken, "agent-token", "", "Token issued by the controller when the agent was added")
+    flag.StringVar(&cfg.ControllerFingerprint, "controller-fingerprint", "", "Pin the controller's TLS certificate to this SHA-256 fingerprint (hex)")
+    flag.StringVar(&cfg.AgentCert, "agent-cert", "", "Client certificate (PEM) presented to the controller for mutual TLS")
+    flag.StringVar(&cfg.AgentKey, "agent-key", "", "Private key (PEM) of the agent client certificate")
+    flag.StringVar(&templateDirs, "template-dirs", "", "Comma-separated directories of stack templates (in addition to <data-dir>/templates)")
+    flag.StringVar(&templateCatalogs, "template-catalogs", "", "Comma-separated URLs of remote stack template catalogs (JSON)")
+    flag.StringVar(&cfg.AuditLogFile, "audit-log-file", "", "Also append audit log entries to this file as JSON lines")
+    flag.StringVar(&cfg.RestoreFile, "restore", "", "Restore this Dockge backup (.tar.gz) at startup, replacing the database and the stacks in it")
+    flag.StringVar(&cfg.HostTerminal, "host-terminal", "", "Let admins open a shell on the host: local (as Dockge's child) or container (privileged helper container); disabled if empty")
+    flag.StringVar(&cfg.HostTerminalImage, "host-terminal-image", "alpine:3", "Image of the host terminal helper container")
+    flag.StringVar(&cfg.VaultAddr, "vault-addr", "", "Address of the HashiCorp Vault server resolving vault: placeholders in env files (default: $VAULT_ADDR)")
+    flag.StringVar(&cfg.VaultToken, "vault-token", "", "Vault token (default: $VAULT_TOKEN)")
     flag.Parse()
 
     // Env vars override flags (if set)
@@ -59,7 +130,87 @@ func Parse() *Config {
         }
     }
 
+    if v := os.Getenv("DOCKGE_PROFILE_HEAP_MB"); v != "" {
+        if n, err := strconv.Atoi(v); err == nil {
+            cfg.ProfileHeapMB = n
+        }
+    }
+    if v := os.Getenv("DOCKGE_PROFILE_GOROUTINES"); v != "" {
+        if n, err := strconv.Atoi(v); err == nil {
+            cfg.ProfileGoroutines = n
+        }
+    }
+    if v := os.Getenv("DOCKGE_PROFILE_KEEP"); v != "" {
+        if n, err := strconv.Atoi(v); err == nil {
+            cfg.ProfileKeep = n
+        }
+    }
+
+    if v := os.Getenv("DOCKGE_CORS_ORIGINS"); v != "" {
+        corsOrigins = v
+    }
+    if v := os.Getenv("DOCKGE_WS_ORIGINS"); v != "" {
+        wsOrigins = v
+    }
+    if v := os.Getenv("DOCKGE_FRAME_ANCESTORS"); v != "" {
+        frameAncestors = v
+    }
+    if v := os.Getenv("DOCKGE_CONTROLLER_URL"); v != "" {
+        cfg.ControllerURL = v
+    }
+    if v := os.Getenv("DOCKGE_AGENT_TOKEN"); v != "" {
+        cfg.AgentToken = v
+    }
+    if v := os.Getenv("DOCKGE_CONTROLLER_FINGERPRINT"); v != "" {
+        cfg.ControllerFingerprint = v
+    }
+    if v := os.Getenv("DOCKGE_AGENT_CERT"); v != "" {
+        cfg.AgentCert = v
+    }
+    if v := os.Getenv("DOCKGE_AGENT_KEY"); v != "" {
+        cfg.AgentKey = v
+    }
+    if v := os.Getenv("DOCKGE_TEMPLATE_DIRS"); v != "" {
+        templateDirs = v
+    }
+    if v := os.Getenv("DOCKGE_TEMPLATE_CATALOGS"); v != "" {
+        templateCatalogs = v
+    }
+    if v := os.Getenv("DOCKGE_AUDIT_LOG_FILE"); v != "" {
+        cfg.AuditLogFile = v
+    }
+    if v := os.Getenv("DOCKGE_RESTORE"); v != "" {
+        cfg.RestoreFile = v
+    }
+    if v := os.Getenv("DOCKGE_HOST_TERMINAL"); v != "" {
+        cfg.HostTerminal = v
+    }
+    if v := os.Getenv("DOCKGE_HOST_TERMINAL_IMAGE"); v != "" {
+        cfg.HostTerminalImage = v
+    }
+    if v := os.Getenv("DOCKGE_VAULT_ADDR"); v != "" {
+        cfg.VaultAddr = v
+    }
+    if v := os.Getenv("DOCKGE_VAULT_TOKEN"); v != "" {
+        cfg.VaultToken = v
+    }
+    if cfg.VaultAddr == "" {
+        cfg.VaultAddr = os.Getenv("VAULT_ADDR")
+    }
+    if cfg.VaultToken == "" {
+        cfg.VaultToken = os.Getenv("VAULT_TOKEN")
+    }
+
     cfg.LogLevel = parseLogLevel(logLevel)
+    cfg.CORSOrigins = splitList(corsOrigins)
+    cfg.WSOrigins = cfg.CORSOrigins
+    if wsOrigins != "" {
+        cfg.WSOrigins = splitList(wsOrigins)
+    }
+    cfg.FrameAncestors = splitList(frameAncestors)
+    cfg.TemplateDirs = append([]string{filepath.Join(cfg.DataDir, "templates")}, splitList(templateDirs)...)
+    cfg.TemplateCatalogs = splitList(templateCatalogs)
+    cfg.HostTerminal = parseHostTerminal(cfg.HostTerminal)
 
     return cfg
 }
@@ -76,3 +227,29 @@ func parseLogLevel(s string) slog.Level {
         return slog.LevelInfo
     }
 }
+
+// parseHostTerminal returns the host terminal mode s names, or "" if it
+// disables the host terminal or is unknown.
+func parseHostTerminal(s string) string {
+    switch mode := strings.ToLower(strings.TrimSpace(s)); mode {
+    case HostTerminalLocal, HostTerminalContainer:
+        return mode
+    case "", "off", "false", "0":
+        return ""
+    default:
+        slog.Warn("unknown host terminal mode, host terminal disabled", "mode", s)
+        return ""
+    }
+}
+
+// splitList splits a comma-separated list, trimming whitespace and dropping
+// empty entries.
+func splitList(s string) []string {
+    var result []string
+    for _, part := range strings.Split(s, ",") {
+        if part = strings.TrimSpace(part); part != "" {
+            result = append(result, part)
+        }
+    }
+    return result
+}
@@ -12,19 +12,43 @@ import (
 
 // Bucket names used throughout the application.
 var (
-    BucketSettings     = []byte("settings")
-    BucketUsers        = []byte("users")
-    BucketUsersByID    = []byte("users_by_id")
-    BucketAgents       = []byte("agents")
-    BucketImageUpdates = []byte("image_updates")
+    BucketSettings       = []byte("settings")
+    BucketUsers          = []byte("users")
+    BucketUsersByID      = []byte("users_by_id")
+    BucketAgents         = []byte("agents")
+    BucketImageUpdates   = []byte("image_updates")
+    BucketPendingChanges = []byte("pending_changes")
+    BucketStackBudgets   = []byte("stack_budgets")
+    BucketOperations     = []byte("operations")
+    BucketUpdateIgnores  = []byte("update_ignores")
+    BucketStackNotes     = []byte("stack_notes")
+    BucketArchivedStacks = []byte("archived_stacks")
+    BucketStackSchedules = []byte("stack_schedules")
+    BucketTerminalAccess = []byte("stack_terminal_access")
+    BucketAuditLog       = []byte("audit_log")
+    BucketStackEvents    = []byte("stack_events")
+    BucketTerminalEnv    = []byte("stack_terminal_env")
+    BucketExecDefaults   = []byte("exec_defaults")
+    BucketLogCapture     = []byte("stack_log_capture")
+    BucketIdempotency    = []byte("idempotency_keys")
+    BucketPullPolicy     = []byte("stack_pull_policy")
+    BucketStackWebhooks  = []byte("stack_webhooks")
+    BucketDeployedCompose = []byte("stack_deployed_compose")
+    BucketStackGroups    = []byte("stack_groups")
+    BucketServiceSelection = []byte("stack_service_selection")
+    BucketHostState      = []byte("host_state")
+    BucketAPITokens      = []byte("api_tokens")
 )
 
+// FileName is the name of the database file in the data directory.
+const FileName = "dockge-bolt.db"
+
 func Open(dataDir string) (*bolt.DB, error) {
     if err := os.MkdirAll(dataDir, 0755); err != nil {
         return nil, fmt.Errorf("create data dir: %w", err)
     }
 
-    dbPath := filepath.Join(dataDir, "dockge-bolt.db")
+    dbPath := filepath.Join(dataDir, FileName)
     db, err := bolt.Open(dbPath, 0600, &bolt.Options{Timeout: 1 * time.Second})
     if err != nil {
         return nil, fmt.Errorf("open bbolt: %w", err)
@@ -38,6 +62,27 @@ func Open(dataDir string) (*bolt.DB, error) {
             BucketUsersByID,
             BucketAgents,
             BucketImageUpdates,
+            BucketPendingChanges,
+            BucketStackBudgets,
+            BucketOperations,
+            BucketUpdateIgnores,
+            BucketStackNotes,
+            BucketArchivedStacks,
+            BucketStackSchedules,
+            BucketTerminalAccess,
+            BucketAuditLog,
+            BucketStackEvents,
+            BucketTerminalEnv,
+            BucketExecDefaults,
+            BucketLogCapture,
+            BucketIdempotency,
+            BucketPullPolicy,
+            BucketStackWebhooks,
+            BucketDeployedCompose,
+            BucketStackGroups,
+            BucketServiceSelection,
+            BucketHostState,
+            BucketAPITokens,
         } {
             if _, err := tx.CreateBucketIfNotExists(name); err != nil {
                 return fmt.Errorf("create bucket %s: %w", name, err)
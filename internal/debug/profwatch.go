@@ -0,0 +1,278 @@
+package debug
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Profile kinds captured by the watchdog. Each kind maps to a named
+// runtime/pprof profile.
+const (
+	ProfileHeap      = "heap"
+	ProfileGoroutine = "goroutine"
+)
+
+// profileSuffix is appended to every captured file name. Both kinds are
+// written in the gzipped protobuf format that `go tool pprof` reads directly.
+const profileSuffix = ".pb.gz"
+
+// profileTimeFormat is used in file names so lexicographic order matches
+// capture order.
+const profileTimeFormat = "20060102-150405.000"
+
+// WatchdogConfig controls when the ProfileWatchdog captures profiles.
+type WatchdogConfig struct {
+	Dir                string        // directory profiles are written to (created on demand)
+	Interval           time.Duration // how often runtime stats are sampled
+	HeapThreshold      uint64        // HeapAlloc bytes that trigger a capture (0 = disabled)
+	GoroutineThreshold int           // goroutine count that triggers a capture (0 = disabled)
+	MaxProfiles        int           // files kept per kind; oldest are deleted first
+	Cooldown           time.Duration // minimum time between automatic captures
+}
+
+// ProfileInfo describes a captured profile file on disk.
+type ProfileInfo struct {
+	Name    string `json:"name"`
+	Kind    string `json:"kind"`
+	Size    int64  `json:"size"`
+	Created int64  `json:"created"` // Unix seconds
+}
+
+// ProfileWatchdog samples heap and goroutine counts and writes heap and
+// goroutine profiles to disk when either crosses its threshold. This lets
+// users attach profiles to leak reports without running pprof themselves.
+type ProfileWatchdog struct {
+	cfg WatchdogConfig
+
+	mu          sync.Mutex
+	lastCapture time.Time
+}
+
+// NewProfileWatchdog starts a background goroutine that samples runtime stats
+// every cfg.Interval. The goroutine stops when ctx is cancelled. If neither
+// threshold is set, no sampler is started but manual captures still work.
+func NewProfileWatchdog(ctx context.Context, cfg WatchdogConfig) *ProfileWatchdog {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 30 * time.Second
+	}
+	if cfg.MaxProfiles <= 0 {
+		cfg.MaxProfiles = 10
+	}
+	w := &ProfileWatchdog{cfg: cfg}
+
+	if cfg.HeapThreshold == 0 && cfg.GoroutineThreshold == 0 {
+		return w
+	}
+
+	go func() {
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				w.check()
+			}
+		}
+	}()
+
+	return w
+}
+
+// check samples runtime stats and captures profiles if a threshold is exceeded
+// and the cooldown has elapsed.
+func (w *ProfileWatchdog) check() {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	goroutines := runtime.NumGoroutine()
+
+	var reason string
+	switch {
+	case w.cfg.HeapThreshold > 0 && m.HeapAlloc >= w.cfg.HeapThreshold:
+		reason = fmt.Sprintf("heapAlloc %d >= %d", m.HeapAlloc, w.cfg.HeapThreshold)
+	case w.cfg.GoroutineThreshold > 0 && goroutines >= w.cfg.GoroutineThreshold:
+		reason = fmt.Sprintf("goroutines %d >= %d", goroutines, w.cfg.GoroutineThreshold)
+	default:
+		return
+	}
+
+	w.mu.Lock()
+	if !w.lastCapture.IsZero() && time.Since(w.lastCapture) < w.cfg.Cooldown {
+		w.mu.Unlock()
+		return
+	}
+	w.mu.Unlock()
+
+	if _, err := w.Capture(); err != nil {
+		slog.Warn("profile watchdog: capture failed", "reason", reason, "err", err)
+		return
+	}
+	slog.Warn("profile watchdog: captured profiles", "reason", reason, "dir", w.cfg.Dir)
+}
+
+// Capture writes a heap and a goroutine profile to the profile directory and
+// prunes old files beyond the retention limit. Returns the written file names.
+func (w *ProfileWatchdog) Capture() ([]string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := os.MkdirAll(w.cfg.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("create profile dir: %w", err)
+	}
+
+	now := time.Now().UTC()
+	stamp := now.Format(profileTimeFormat)
+	var names []string
+	for _, kind := range []string{ProfileHeap, ProfileGoroutine} {
+		name := kind + "-" + stamp + profileSuffix
+		if err := writeProfile(filepath.Join(w.cfg.Dir, name), kind); err != nil {
+			return names, err
+		}
+		names = append(names, name)
+		w.pruneLocked(kind)
+	}
+	w.lastCapture = now
+	return names, nil
+}
+
+// writeProfile writes the named runtime profile to path (gzipped protobuf).
+func writeProfile(path, kind string) error {
+	p := pprof.Lookup(kind)
+	if p == nil {
+		return fmt.Errorf("unknown profile %q", kind)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("create %s profile: %w", kind, err)
+	}
+	if err := p.WriteTo(f, 0); err != nil {
+		f.Close()
+		os.Remove(path)
+		return fmt.Errorf("write %s profile: %w", kind, err)
+	}
+	return f.Close()
+}
+
+// pruneLocked deletes the oldest profiles of a kind beyond MaxProfiles.
+// Must be called with w.mu held.
+func (w *ProfileWatchdog) pruneLocked(kind string) {
+	profiles, err := w.list()
+	if err != nil {
+		return
+	}
+	var ofKind []ProfileInfo
+	for _, p := range profiles {
+		if p.Kind == kind {
+			ofKind = append(ofKind, p)
+		}
+	}
+	// list() returns newest first
+	for i := w.cfg.MaxProfiles; i < len(ofKind); i++ {
+		if err := os.Remove(filepath.Join(w.cfg.Dir, ofKind[i].Name)); err != nil {
+			slog.Debug("profile watchdog: prune", "name", ofKind[i].Name, "err", err)
+		}
+	}
+}
+
+// PruneBefore deletes profiles captured before the cutoff, regardless of
+// MaxProfiles. Returns how many files were removed and their total size.
+func (w *ProfileWatchdog) PruneBefore(cutoff time.Time) (removed int, freed int64, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	profiles, err := w.list()
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, p := range profiles {
+		if p.Created >= cutoff.Unix() {
+			continue
+		}
+		if err := os.Remove(filepath.Join(w.cfg.Dir, p.Name)); err != nil {
+			return removed, freed, fmt.Errorf("remove profile: %w", err)
+		}
+		removed++
+		freed += p.Size
+	}
+	return removed, freed, nil
+}
+
+// List returns captured profiles, newest first.
+func (w *ProfileWatchdog) List() ([]ProfileInfo, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.list()
+}
+
+func (w *ProfileWatchdog) list() ([]ProfileInfo, error) {
+	entries, err := os.ReadDir(w.cfg.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []ProfileInfo{}, nil
+		}
+		return nil, fmt.Errorf("read profile dir: %w", err)
+	}
+
+	result := make([]ProfileInfo, 0, len(entries))
+	for _, e := range entries {
+		kind, ok := profileKind(e.Name())
+		if !ok || e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		result = append(result, ProfileInfo{
+			Name:    e.Name(),
+			Kind:    kind,
+			Size:    info.Size(),
+			Created: info.ModTime().Unix(),
+		})
+	}
+
+	// File names embed the capture time, so name order is capture order.
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name > result[j].Name
+	})
+	return result, nil
+}
+
+// Read returns the contents of a captured profile. The name must be a bare
+// file name produced by Capture — anything else is rejected so callers can
+// pass client-supplied names straight through.
+func (w *ProfileWatchdog) Read(name string) ([]byte, error) {
+	if _, ok := profileKind(name); !ok {
+		return nil, fmt.Errorf("invalid profile name %q", name)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	data, err := os.ReadFile(filepath.Join(w.cfg.Dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("read profile: %w", err)
+	}
+	return data, nil
+}
+
+// profileKind returns the kind encoded in a profile file name and whether the
+// name is a valid watchdog profile name.
+func profileKind(name string) (string, bool) {
+	if name != filepath.Base(name) || !strings.HasSuffix(name, profileSuffix) {
+		return "", false
+	}
+	for _, kind := range []string{ProfileHeap, ProfileGoroutine} {
+		if strings.HasPrefix(name, kind+"-") {
+			return kind, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,101 @@
+package debug
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestProfileWatchdogCaptureAndList(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	w := NewProfileWatchdog(context.Background(), WatchdogConfig{Dir: dir, MaxProfiles: 2})
+
+	names, err := w.Capture()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 {
+		t.Fatalf("expected 2 profiles, got %v", names)
+	}
+
+	profiles, err := w.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 2 {
+		t.Fatalf("expected 2 listed profiles, got %d", len(profiles))
+	}
+	for _, p := range profiles {
+		if p.Size == 0 {
+			t.Errorf("profile %s is empty", p.Name)
+		}
+		if _, err := w.Read(p.Name); err != nil {
+			t.Errorf("read %s: %v", p.Name, err)
+		}
+	}
+}
+
+func TestProfileWatchdogRetention(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	w := NewProfileWatchdog(context.Background(), WatchdogConfig{Dir: dir, MaxProfiles: 2})
+
+	for i := 0; i < 4; i++ {
+		if _, err := w.Capture(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	profiles, err := w.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]int{}
+	for _, p := range profiles {
+		counts[p.Kind]++
+	}
+	if counts[ProfileHeap] != 2 || counts[ProfileGoroutine] != 2 {
+		t.Errorf("expected 2 profiles per kind, got %v", counts)
+	}
+}
+
+func TestProfileWatchdogReadRejectsBadNames(t *testing.T) {
+	t.Parallel()
+	w := NewProfileWatchdog(context.Background(), WatchdogConfig{Dir: t.TempDir()})
+
+	for _, name := range []string{
+		"",
+		"../dockge-bolt.db",
+		"heap-../../etc/passwd.pb.gz",
+		"cpu-20260101-000000.000.pb.gz",
+		"heap-20260101-000000.000.txt",
+	} {
+		if _, err := w.Read(name); err == nil {
+			t.Errorf("expected error for %q", name)
+		}
+	}
+}
+
+func TestProfileWatchdogPruneBefore(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	w := NewProfileWatchdog(context.Background(), WatchdogConfig{Dir: dir})
+
+	if _, err := w.Capture(); err != nil {
+		t.Fatal(err)
+	}
+	if removed, _, err := w.PruneBefore(time.Now().Add(-time.Hour)); err != nil || removed != 0 {
+		t.Fatalf("recent profiles pruned: removed=%d err=%v", removed, err)
+	}
+	removed, freed, err := w.PruneBefore(time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 || freed == 0 {
+		t.Errorf("PruneBefore = (%d, %d), want 2 profiles removed", removed, freed)
+	}
+	if profiles, _ := w.List(); len(profiles) != 0 {
+		t.Errorf("expected no profiles left, got %d", len(profiles))
+	}
+}
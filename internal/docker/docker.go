@@ -7,7 +7,7 @@ import (
     "time"
 )
 
-// Client abstracts Docker daemon queries (reads only).
+// Client abstracts Docker daemon queries (reads only) and exec terminals.
 // Write operations (up, down, stop, restart, pull) remain as CLI shell-outs
 // via exec.Command("docker", ...).
 type Client interface {
@@ -36,19 +36,62 @@ type Client interface {
     // The channel closes when ctx is cancelled or the stream ends.
     ContainerStatStream(ctx context.Context, containerName string) (<-chan ContainerStat, error)
 
+    // ContainerStatsOnce returns a single numeric usage sample for a container.
+    ContainerStatsOnce(ctx context.Context, id string) (ContainerUsage, error)
+
+    // Info returns what the daemon reports about itself that changes what
+    // Dockge can do, such as whether it runs rootless.
+    Info(ctx context.Context) (DaemonInfo, error)
+
+    // DiskUsage returns the space images, containers, volumes and the build
+    // cache take, as `docker system df` reports it.
+    DiskUsage(ctx context.Context) (DiskUsage, error)
+
     // ContainerStart starts a stopped container.
-    // Only used in tests to transition mock containers from exited → running.
     ContainerStart(ctx context.Context, containerID string) error
 
+    // ContainerStop stops a container, killing it after its stop timeout.
+    ContainerStop(ctx context.Context, containerID string) error
+
+    // ContainerRestart restarts a container, stopping it with its stop
+    // timeout first.
+    ContainerRestart(ctx context.Context, containerID string) error
+
+    // ContainerKill sends a signal to a container's main process; "" sends
+    // SIGKILL.
+    ContainerKill(ctx context.Context, containerID, signal string) error
+
+    // ContainerPause freezes a container's processes.
+    ContainerPause(ctx context.Context, containerID string) error
+
+    // ContainerUnpause resumes a paused container.
+    ContainerUnpause(ctx context.Context, containerID string) error
+
+    // ContainerRemove removes a container. force removes it while running,
+    // volumes removes its anonymous volumes too.
+    ContainerRemove(ctx context.Context, containerID string, force, volumes bool) error
+
+    // ContainerRename renames a container.
+    ContainerRename(ctx context.Context, containerID, newName string) error
+
     // ContainerStartedAt returns when the container was last started.
     // Returns zero time if the container has never started or info is unavailable.
     ContainerStartedAt(ctx context.Context, containerID string) (time.Time, error)
 
+    // ContainerExec starts an interactive exec with a TTY in a running
+    // container and attaches to it, without going through the docker CLI.
+    // The caller must close the returned session.
+    ContainerExec(ctx context.Context, containerID string, opts ExecOptions) (ExecSession, error)
+
     // ContainerLogs opens a log stream for a container.
     // Returns the stream, whether the container uses a TTY, and any error.
     // The caller must close the returned ReadCloser.
     ContainerLogs(ctx context.Context, containerID string, tail string, follow bool, timestamps bool) (io.ReadCloser, bool, error)
 
+    // ContainerLogsWithOptions is ContainerLogs with a time window, a single
+    // stream or the stream frames kept (see LogOptions).
+    ContainerLogsWithOptions(ctx context.Context, containerID string, opts LogOptions) (io.ReadCloser, bool, error)
+
     // ImageInspect returns the RepoDigests for a local image.
     // Returns nil if the image is not found locally.
     ImageInspect(ctx context.Context, imageRef string) ([]string, error)
@@ -90,8 +133,17 @@ type Client interface {
     // including layers.
     ImageInspectDetail(ctx context.Context, imageRef string) (*ImageDetail, error)
 
-    // ImagePrune removes unused images. Returns human-readable reclaimed space string.
-    ImagePrune(ctx context.Context, all bool) (string, error)
+    // ImagePrune removes unused images: dangling ones only, or every image
+    // without a container if all is set.
+    ImagePrune(ctx context.Context, all bool) (PruneReport, error)
+
+    // ContainerCommit snapshots a container's filesystem and config into a
+    // local image tagged ref, adding labels. Returns the new image ID.
+    ContainerCommit(ctx context.Context, containerID string, opts CommitOptions) (string, error)
+
+    // ImageSave streams a tar archive of the images, as `docker save` does.
+    // The caller must close the returned ReadCloser.
+    ImageSave(ctx context.Context, imageRefs []string) (io.ReadCloser, error)
 
     // VolumeList returns summary info for all Docker volumes.
     VolumeList(ctx context.Context) ([]VolumeSummary, error)
@@ -107,6 +159,38 @@ type Client interface {
     // VolumeInspect returns detailed info for a single Docker volume.
     VolumeInspect(ctx context.Context, volumeName string) (*VolumeDetail, error)
 
+    // VolumeCreate creates a volume with the default driver.
+    VolumeCreate(ctx context.Context, volumeName string) error
+
+    // VolumeRemove removes a volume. The daemon refuses volumes that
+    // containers use.
+    VolumeRemove(ctx context.Context, volumeName string) error
+
+    // VolumePrune removes volumes no container uses: anonymous ones only,
+    // or named ones too if all is set.
+    VolumePrune(ctx context.Context, all bool) (PruneReport, error)
+
+    // NetworkCreate creates a network and returns its ID.
+    NetworkCreate(ctx context.Context, opts NetworkCreateOptions) (string, error)
+
+    // NetworkRemove removes a network. The daemon refuses networks that
+    // containers are connected to.
+    NetworkRemove(ctx context.Context, networkID string) error
+
+    // NetworkConnect connects a container to a network.
+    NetworkConnect(ctx context.Context, networkID, containerID string) error
+
+    // NetworkDisconnect disconnects a container from a network; force
+    // does so even if the container is gone.
+    NetworkDisconnect(ctx context.Context, networkID, containerID string, force bool) error
+
+    // NetworkPrune removes custom networks no container is connected to.
+    NetworkPrune(ctx context.Context) (PruneReport, error)
+
+    // BuildCachePrune removes dangling build cache, or all of it that isn't
+    // in use if all is set.
+    BuildCachePrune(ctx context.Context, all bool) (PruneReport, error)
+
     // Events returns a channel of Docker resource lifecycle events and an error channel.
     // Subscribes to container, network, image, and volume events.
     // The channels are closed when the context is cancelled.
@@ -115,3 +199,14 @@ type Client interface {
     // Close releases any resources held by the client.
     Close() error
 }
+
+// ProjectResolver maps compose project names to stack names and back, for
+// stacks whose project name differs from their directory name.
+type ProjectResolver interface {
+    // StackName returns the stack of a project, given the project and
+    // working directory labels of one of its containers.
+    StackName(project, workingDir string) string
+
+    // ProjectName returns the compose project name of a stack.
+    ProjectName(stackName string) string
+}
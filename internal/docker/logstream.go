@@ -0,0 +1,164 @@
+package docker
+
+import (
+    "bytes"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "io"
+)
+
+// Streams of a container's log.
+const (
+    LogStdout = "stdout"
+    LogStderr = "stderr"
+)
+
+// defaultMaxLogLine is the longest line a LogScanner returns whole; longer
+// ones are split.
+const defaultMaxLogLine = 64 * 1024
+
+// LogLine is a line of a container's log and the stream it was written to.
+type LogLine struct {
+    Stream string // LogStdout or LogStderr
+    Text   []byte // without the newline
+}
+
+// LogScanner splits a container log opened with LogOptions.Framed into
+// lines, keeping track of the stream of each. The log of a non-TTY
+// container comes in frames with an 8-byte header naming their stream;
+// a line may span frames and the frames of both streams interleave, so
+// partial lines are kept per stream. A TTY container has a single raw
+// stream, reported as stdout.
+type LogScanner struct {
+    r       io.Reader
+    tty     bool
+    maxLine int
+    header  [8]byte
+    payload []byte
+    partial [2][]byte // stdout, stderr
+    lines   []LogLine
+    line    LogLine
+    err     error
+    done    bool
+}
+
+// NewLogScanner returns a scanner reading r, the log of a container that
+// uses a TTY if tty is set.
+func NewLogScanner(r io.Reader, tty bool) *LogScanner {
+    return &LogScanner{r: r, tty: tty, maxLine: defaultMaxLogLine}
+}
+
+// Buffer sets the longest line returned whole; longer lines are split at
+// max bytes.
+func (s *LogScanner) Buffer(max int) {
+    s.maxLine = max
+}
+
+// Scan advances to the next line, returning false at the end of the log
+// or on an error. A last line without a newline is returned too.
+func (s *LogScanner) Scan() bool {
+    for len(s.lines) == 0 {
+        if s.done {
+            return false
+        }
+        if err := s.read(); err != nil {
+            s.done = true
+            if !errors.Is(err, io.EOF) {
+                s.err = err
+            }
+            s.flush(0)
+            s.flush(1)
+        }
+    }
+    s.line, s.lines = s.lines[0], s.lines[1:]
+    return true
+}
+
+// Line returns the line Scan advanced to. Its Text stays valid after the
+// next call to Scan.
+func (s *LogScanner) Line() LogLine { return s.line }
+
+// Err returns the error that ended the scan, nil at the end of the log.
+func (s *LogScanner) Err() error { return s.err }
+
+// read reads one frame, or one chunk of a TTY log.
+func (s *LogScanner) read() error {
+    if s.tty {
+        if s.payload == nil {
+            s.payload = make([]byte, 32*1024)
+        }
+        n, err := s.r.Read(s.payload)
+        s.feed(0, s.payload[:n])
+        return err
+    }
+
+    if _, err := io.ReadFull(s.r, s.header[:]); err != nil {
+        if errors.Is(err, io.ErrUnexpectedEOF) {
+            return errors.New("log stream: truncated frame header")
+        }
+        return err
+    }
+    size := int(binary.BigEndian.Uint32(s.header[4:]))
+    if cap(s.payload) < size {
+        s.payload = make([]byte, size)
+    }
+    payload := s.payload[:size]
+    if _, err := io.ReadFull(s.r, payload); err != nil {
+        if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+            return errors.New("log stream: truncated frame")
+        }
+        return err
+    }
+    switch s.header[0] {
+    case 0, 1: // stdin (only ever in attach streams), stdout
+        s.feed(0, payload)
+    case 2:
+        s.feed(1, payload)
+    case 3: // systemerr
+        return fmt.Errorf("error from daemon in stream: %s", payload)
+    default:
+        return fmt.Errorf("log stream: unknown stream type %d", s.header[0])
+    }
+    return nil
+}
+
+// feed appends data to the partial line of a stream, queueing the lines it
+// completes.
+func (s *LogScanner) feed(stream int, data []byte) {
+    buf := append(s.partial[stream], data...)
+    for {
+        i := bytes.IndexByte(buf, '\n')
+        if i < 0 {
+            break
+        }
+        s.emit(stream, buf[:i])
+        buf = buf[i+1:]
+    }
+    for s.maxLine > 0 && len(buf) > s.maxLine {
+        s.emit(stream, buf[:s.maxLine])
+        buf = buf[s.maxLine:]
+    }
+    s.partial[stream] = append(s.partial[stream][:0], buf...)
+}
+
+// flush queues the partial line of a stream at the end of the log.
+func (s *LogScanner) flush(stream int) {
+    if len(s.partial[stream]) > 0 {
+        s.emit(stream, s.partial[stream])
+        s.partial[stream] = s.partial[stream][:0]
+    }
+}
+
+// emit queues a complete line, split if it's longer than maxLine.
+func (s *LogScanner) emit(stream int, text []byte) {
+    name := LogStdout
+    if stream == 1 {
+        name = LogStderr
+    }
+    for s.maxLine > 0 && len(text) > s.maxLine {
+        s.lines = append(s.lines, LogLine{Stream: name, Text: bytes.Clone(text[:s.maxLine])})
+        text = text[s.maxLine:]
+    }
+    s.lines = append(s.lines, LogLine{Stream: name, Text: bytes.Clone(text)})
+}
@@ -0,0 +1,83 @@
+package docker
+
+import (
+    "bytes"
+    "encoding/binary"
+    "strings"
+    "testing"
+)
+
+func frame(stream byte, payload string) []byte {
+    header := make([]byte, 8)
+    header[0] = stream
+    binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+    return append(header, payload...)
+}
+
+func scanAll(t *testing.T, s *LogScanner) []string {
+    t.Helper()
+    var got []string
+    for s.Scan() {
+        l := s.Line()
+        got = append(got, l.Stream+": "+string(l.Text))
+    }
+    return got
+}
+
+func TestLogScannerFrames(t *testing.T) {
+    t.Parallel()
+    var log bytes.Buffer
+    log.Write(frame(1, "starting\nlisten"))
+    log.Write(frame(2, "warn: low"))
+    log.Write(frame(1, "ing on :80\n"))
+    log.Write(frame(2, " memory\nfatal: out of memory"))
+
+    s := NewLogScanner(&log, false)
+    got := scanAll(t, s)
+    want := []string{
+        "stdout: starting",
+        "stdout: listening on :80",
+        "stderr: warn: low memory",
+        "stderr: fatal: out of memory",
+    }
+    if strings.Join(got, "\n") != strings.Join(want, "\n") {
+        t.Errorf("got %q, want %q", got, want)
+    }
+    if err := s.Err(); err != nil {
+        t.Errorf("unexpected error: %v", err)
+    }
+}
+
+func TestLogScannerTTY(t *testing.T) {
+    t.Parallel()
+    s := NewLogScanner(strings.NewReader("one\ntwo\n"), true)
+    got := scanAll(t, s)
+    if len(got) != 2 || got[0] != "stdout: one" || got[1] != "stdout: two" {
+        t.Errorf("got %q", got)
+    }
+}
+
+func TestLogScannerLongLines(t *testing.T) {
+    t.Parallel()
+    s := NewLogScanner(bytes.NewReader(frame(2, "abcdefgh\n")), false)
+    s.Buffer(3)
+    got := scanAll(t, s)
+    if strings.Join(got, ",") != "stderr: abc,stderr: def,stderr: gh" {
+        t.Errorf("got %q", got)
+    }
+}
+
+func TestLogScannerErrors(t *testing.T) {
+    t.Parallel()
+    truncated := frame(1, "complete\n")
+    truncated = append(truncated, frame(1, "cut off")[:10]...)
+    s := NewLogScanner(bytes.NewReader(truncated), false)
+    if got := scanAll(t, s); len(got) != 1 || s.Err() == nil {
+        t.Errorf("expected one line and an error, got %q, %v", got, s.Err())
+    }
+
+    s = NewLogScanner(bytes.NewReader(frame(3, "boom")), false)
+    if scanAll(t, s); s.Err() == nil || !strings.Contains(s.Err().Error(), "boom") {
+        t.Errorf("expected the daemon's error, got %v", s.Err())
+    }
+}
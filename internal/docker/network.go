@@ -0,0 +1,16 @@
+package docker
+
+import "strconv"
+
+// mtuOption is the driver option bridge and overlay networks read the MTU from.
+const mtuOption = "com.docker.network.driver.mtu"
+
+// NetworkMTU returns the MTU set in a network's driver options, or 0 if the
+// network uses the daemon default.
+func NetworkMTU(options map[string]string) int {
+	mtu, err := strconv.Atoi(options[mtuOption])
+	if err != nil || mtu < 0 {
+		return 0
+	}
+	return mtu
+}
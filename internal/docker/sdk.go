@@ -12,6 +12,8 @@ import (
 
     "time"
 
+    "github.com/docker/docker/api/types"
+    "github.com/docker/docker/api/types/build"
     "github.com/docker/docker/api/types/container"
     "github.com/docker/docker/api/types/events"
     "github.com/docker/docker/api/types/filters"
@@ -44,7 +46,31 @@ func parseHealthFromStatus(state, status string) string {
 
 // SDKClient implements Client using the Docker Engine SDK.
 type SDKClient struct {
-    cli *client.Client
+    cli      *client.Client
+    projects ProjectResolver // nil = project names are stack names
+}
+
+// SetProjectResolver makes the client report stack names instead of compose
+// project names, and filter by a stack's project name. Call before use.
+func (s *SDKClient) SetProjectResolver(r ProjectResolver) {
+    s.projects = r
+}
+
+// stackName returns the stack of a compose-labelled resource.
+func (s *SDKClient) stackName(labels map[string]string) string {
+    project := labels["com.docker.compose.project"]
+    if s.projects == nil {
+        return project
+    }
+    return s.projects.StackName(project, labels["com.docker.compose.project.working_dir"])
+}
+
+// projectName returns the compose project name of a stack.
+func (s *SDKClient) projectName(stackName string) string {
+    if s.projects == nil {
+        return stackName
+    }
+    return s.projects.ProjectName(stackName)
 }
 
 // NewSDKClient creates an SDKClient that connects to the Docker daemon
@@ -71,7 +97,7 @@ func (s *SDKClient) ContainerList(ctx context.Context, all bool, projectFilter s
     opts := container.ListOptions{All: all}
     if projectFilter != "" {
         opts.Filters = filters.NewArgs(
-            filters.Arg("label", "com.docker.compose.project="+projectFilter),
+            filters.Arg("label", "com.docker.compose.project="+s.projectName(projectFilter)),
         )
     }
 
@@ -90,13 +116,16 @@ func (s *SDKClient) ContainerList(ctx context.Context, all bool, projectFilter s
         health := parseHealthFromStatus(c.State, c.Status)
 
         result = append(result, Container{
-            ID:      c.ID,
-            Name:    name,
-            Project: c.Labels["com.docker.compose.project"],
-            Service: c.Labels["com.docker.compose.service"],
-            Image:   c.Image,
-            State:   c.State,
-            Health:  health,
+            ID:          c.ID,
+            Name:        name,
+            Project:     s.stackName(c.Labels),
+            Service:     c.Labels["com.docker.compose.service"],
+            WorkingDir:  c.Labels["com.docker.compose.project.working_dir"],
+            ConfigFiles: c.Labels["com.docker.compose.project.config_files"],
+            Image:       c.Image,
+            State:       c.State,
+            Health:      health,
+            Labels:      c.Labels,
         })
     }
     return result, nil
@@ -164,13 +193,11 @@ func (s *SDKClient) containerListDetailedWithOpts(ctx context.Context, opts cont
         }
 
         svc := c.Labels["com.docker.compose.service"]
-        project := c.Labels["com.docker.compose.project"]
-
         result = append(result, ContainerBroadcast{
             Name:        name,
             ContainerID: c.ID,
             ServiceName: svc,
-            StackName:   project,
+            StackName:   s.stackName(c.Labels),
             State:       strings.ToLower(c.State),
             Health:      strings.ToLower(health),
             Image:       c.Image,
@@ -178,6 +205,7 @@ func (s *SDKClient) containerListDetailedWithOpts(ctx context.Context, opts cont
             Networks:    networks,
             Mounts:      mounts,
             Ports:       ports,
+            Labels:      c.Labels,
         })
     }
 
@@ -254,18 +282,146 @@ func (s *SDKClient) ContainerStatStream(ctx context.Context, containerName strin
     return out, nil
 }
 
-// parseStatsResponse converts a raw Docker StatsResponse into a ContainerStat.
-func parseStatsResponse(stats *container.StatsResponse, name string) ContainerStat {
-    // Calculate CPU percentage
+// Info returns the daemon's version, cgroup setup and security options.
+func (s *SDKClient) Info(ctx context.Context) (DaemonInfo, error) {
+    info, err := s.cli.Info(ctx)
+    if err != nil {
+        return DaemonInfo{}, fmt.Errorf("daemon info: %w", err)
+    }
+    d := DaemonInfo{
+        ServerVersion:   info.ServerVersion,
+        OperatingSystem: info.OperatingSystem,
+        CgroupDriver:    info.CgroupDriver,
+        CgroupVersion:   info.CgroupVersion,
+        SecurityOptions: info.SecurityOptions,
+    }
+    // Options look like "name=seccomp,profile=builtin"
+    for _, opt := range info.SecurityOptions {
+        for _, field := range strings.Split(opt, ",") {
+            if field == "name=rootless" {
+                d.Rootless = true
+            }
+        }
+    }
+    return d, nil
+}
+
+// DiskUsage sums up the daemon's disk usage report the way the docker CLI
+// does. Sizes the daemon couldn't compute (-1) are left out.
+func (s *SDKClient) DiskUsage(ctx context.Context) (DiskUsage, error) {
+    du, err := s.cli.DiskUsage(ctx, types.DiskUsageOptions{})
+    if err != nil {
+        return DiskUsage{}, fmt.Errorf("disk usage: %w", err)
+    }
+    return summarizeDiskUsage(du), nil
+}
+
+func summarizeDiskUsage(du types.DiskUsage) DiskUsage {
+    var out DiskUsage
+
+    // Images share layers, so their total is that of the layers, and
+    // what's reclaimable is what images with containers don't use.
+    var usedByActive int64
+    for _, img := range du.Images {
+        out.Images.Total++
+        if img.Containers > 0 {
+            out.Images.Active++
+            if img.Size != -1 && img.SharedSize != -1 {
+                usedByActive += img.Size - img.SharedSize
+            }
+        }
+    }
+    out.Images.SizeBytes = du.LayersSize
+    out.Images.ReclaimableBytes = max(du.LayersSize-usedByActive, 0)
+
+    for _, c := range du.Containers {
+        out.Containers.Total++
+        out.Containers.SizeBytes += c.SizeRw
+        if c.State == container.StateRunning || c.State == container.StatePaused || c.State == container.StateRestarting {
+            out.Containers.Active++
+        } else {
+            out.Containers.ReclaimableBytes += c.SizeRw
+        }
+    }
+
+    for _, v := range du.Volumes {
+        out.Volumes.Total++
+        if v.UsageData == nil {
+            continue
+        }
+        if v.UsageData.RefCount > 0 {
+            out.Volumes.Active++
+        }
+        if v.UsageData.Size == -1 {
+            continue
+        }
+        out.Volumes.SizeBytes += v.UsageData.Size
+        if v.UsageData.RefCount == 0 {
+            out.Volumes.ReclaimableBytes += v.UsageData.Size
+        }
+    }
+
+    for _, rec := range du.BuildCache {
+        out.BuildCache.Total++
+        if rec.InUse {
+            out.BuildCache.Active++
+        }
+        if rec.Shared {
+            continue
+        }
+        out.BuildCache.SizeBytes += rec.Size
+        if !rec.InUse {
+            out.BuildCache.ReclaimableBytes += rec.Size
+        }
+    }
+
+    for _, sum := range []*DiskUsageSummary{&out.Images, &out.Containers, &out.Volumes, &out.BuildCache} {
+        sum.Size = formatBytes(uint64(sum.SizeBytes))
+        sum.Reclaimable = formatBytes(uint64(sum.ReclaimableBytes))
+    }
+    return out
+}
+
+// ContainerStatsOnce takes a single stats sample. The daemon waits for a
+// second sample internally so the CPU figure is meaningful.
+func (s *SDKClient) ContainerStatsOnce(ctx context.Context, id string) (ContainerUsage, error) {
+    statsResp, err := s.cli.ContainerStats(ctx, id, false)
+    if err != nil {
+        return ContainerUsage{}, fmt.Errorf("container stats: %w", err)
+    }
+    defer statsResp.Body.Close()
+
+    var stats container.StatsResponse
+    if err := json.NewDecoder(statsResp.Body).Decode(&stats); err != nil {
+        return ContainerUsage{}, fmt.Errorf("decode container stats: %w", err)
+    }
+    return ContainerUsage{
+        CPUPercent: statsCPUPercent(&stats),
+        MemBytes:   statsMemUsage(&stats),
+    }, nil
+}
+
+// statsCPUPercent returns CPU usage as a percentage of one CPU.
+func statsCPUPercent(stats *container.StatsResponse) float64 {
     cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage - stats.PreCPUStats.CPUUsage.TotalUsage)
     systemDelta := float64(stats.CPUStats.SystemUsage - stats.PreCPUStats.SystemUsage)
-    cpuPerc := 0.0
     if systemDelta > 0 && cpuDelta > 0 {
-        cpuPerc = (cpuDelta / systemDelta) * float64(stats.CPUStats.OnlineCPUs) * 100.0
+        return (cpuDelta / systemDelta) * float64(stats.CPUStats.OnlineCPUs) * 100.0
     }
+    return 0
+}
+
+// statsMemUsage returns memory usage excluding page cache, matching docker stats.
+func statsMemUsage(stats *container.StatsResponse) uint64 {
+    return stats.MemoryStats.Usage - stats.MemoryStats.Stats["cache"]
+}
+
+// parseStatsResponse converts a raw Docker StatsResponse into a ContainerStat.
+func parseStatsResponse(stats *container.StatsResponse, name string) ContainerStat {
+    cpuPerc := statsCPUPercent(stats)
 
     // Memory usage
-    memUsage := stats.MemoryStats.Usage - stats.MemoryStats.Stats["cache"]
+    memUsage := statsMemUsage(stats)
     memLimit := stats.MemoryStats.Limit
     memPerc := 0.0
     if memLimit > 0 {
@@ -320,10 +476,58 @@ func (s *SDKClient) ContainerTop(ctx context.Context, id string) ([]string, [][]
     return resp.Titles, resp.Processes, nil
 }
 
-// ContainerStart starts a stopped container.
-// Only used in tests to transition mock containers from exited → running.
 func (s *SDKClient) ContainerStart(ctx context.Context, containerID string) error {
-    return s.cli.ContainerStart(ctx, containerID, container.StartOptions{})
+    if err := s.cli.ContainerStart(ctx, containerID, container.StartOptions{}); err != nil {
+        return fmt.Errorf("container start: %w", err)
+    }
+    return nil
+}
+
+func (s *SDKClient) ContainerStop(ctx context.Context, containerID string) error {
+    if err := s.cli.ContainerStop(ctx, containerID, container.StopOptions{}); err != nil {
+        return fmt.Errorf("container stop: %w", err)
+    }
+    return nil
+}
+
+func (s *SDKClient) ContainerRestart(ctx context.Context, containerID string) error {
+    return s.cli.ContainerRestart(ctx, containerID, container.StopOptions{})
+}
+
+func (s *SDKClient) ContainerKill(ctx context.Context, containerID, signal string) error {
+    if err := s.cli.ContainerKill(ctx, containerID, signal); err != nil {
+        return fmt.Errorf("container kill: %w", err)
+    }
+    return nil
+}
+
+func (s *SDKClient) ContainerPause(ctx context.Context, containerID string) error {
+    if err := s.cli.ContainerPause(ctx, containerID); err != nil {
+        return fmt.Errorf("container pause: %w", err)
+    }
+    return nil
+}
+
+func (s *SDKClient) ContainerUnpause(ctx context.Context, containerID string) error {
+    if err := s.cli.ContainerUnpause(ctx, containerID); err != nil {
+        return fmt.Errorf("container unpause: %w", err)
+    }
+    return nil
+}
+
+func (s *SDKClient) ContainerRemove(ctx context.Context, containerID string, force, volumes bool) error {
+    err := s.cli.ContainerRemove(ctx, containerID, container.RemoveOptions{Force: force, RemoveVolumes: volumes})
+    if err != nil {
+        return fmt.Errorf("container remove: %w", err)
+    }
+    return nil
+}
+
+func (s *SDKClient) ContainerRename(ctx context.Context, containerID, newName string) error {
+    if err := s.cli.ContainerRename(ctx, containerID, newName); err != nil {
+        return fmt.Errorf("container rename: %w", err)
+    }
+    return nil
 }
 
 func (s *SDKClient) ContainerStartedAt(ctx context.Context, containerID string) (time.Time, error) {
@@ -341,7 +545,77 @@ func (s *SDKClient) ContainerStartedAt(ctx context.Context, containerID string)
     return t, nil
 }
 
+func (s *SDKClient) ContainerExec(ctx context.Context, containerID string, opts ExecOptions) (ExecSession, error) {
+    size := &[2]uint{uint(opts.Rows), uint(opts.Cols)}
+    created, err := s.cli.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+        Cmd:          opts.Cmd,
+        User:         opts.User,
+        WorkingDir:   opts.WorkingDir,
+        Env:          opts.Env,
+        Tty:          true,
+        ConsoleSize:  size,
+        AttachStdin:  true,
+        AttachStdout: true,
+        AttachStderr: true,
+    })
+    if err != nil {
+        return nil, fmt.Errorf("exec create: %w", err)
+    }
+    resp, err := s.cli.ContainerExecAttach(ctx, created.ID, container.ExecAttachOptions{Tty: true, ConsoleSize: size})
+    if err != nil {
+        return nil, fmt.Errorf("exec attach: %w", err)
+    }
+    return &sdkExecSession{cli: s.cli, id: created.ID, resp: resp}, nil
+}
+
+// sdkExecSession is an exec attached over a hijacked API connection. With
+// a TTY the stream is raw, not multiplexed, so it's passed through as is.
+type sdkExecSession struct {
+    cli  *client.Client
+    id   string
+    resp types.HijackedResponse
+}
+
+func (e *sdkExecSession) Read(p []byte) (int, error)  { return e.resp.Reader.Read(p) }
+func (e *sdkExecSession) Write(p []byte) (int, error) { return e.resp.Conn.Write(p) }
+
+func (e *sdkExecSession) Close() error {
+    e.resp.Close()
+    return nil
+}
+
+func (e *sdkExecSession) Resize(rows, cols uint16) error {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+    return e.cli.ContainerExecResize(ctx, e.id, container.ResizeOptions{Height: uint(rows), Width: uint(cols)})
+}
+
+// ExitCode inspects the exec. The daemon may still report it running for
+// a moment after its output ended, so it's polled briefly.
+func (e *sdkExecSession) ExitCode() (int, error) {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+    for {
+        inspect, err := e.cli.ContainerExecInspect(ctx, e.id)
+        if err != nil {
+            return 0, fmt.Errorf("exec inspect: %w", err)
+        }
+        if !inspect.Running {
+            return inspect.ExitCode, nil
+        }
+        select {
+        case <-ctx.Done():
+            return 0, fmt.Errorf("exec still running: %w", ctx.Err())
+        case <-time.After(100 * time.Millisecond):
+        }
+    }
+}
+
 func (s *SDKClient) ContainerLogs(ctx context.Context, containerID string, tail string, follow bool, timestamps bool) (io.ReadCloser, bool, error) {
+    return s.ContainerLogsWithOptions(ctx, containerID, LogOptions{Tail: tail, Follow: follow, Timestamps: timestamps})
+}
+
+func (s *SDKClient) ContainerLogsWithOptions(ctx context.Context, containerID string, o LogOptions) (io.ReadCloser, bool, error) {
     // Check if container uses TTY
     inspect, err := s.cli.ContainerInspect(ctx, containerID)
     if err != nil {
@@ -350,11 +624,17 @@ func (s *SDKClient) ContainerLogs(ctx context.Context, containerID string, tail
     isTTY := inspect.Config.Tty
 
     opts := container.LogsOptions{
-        ShowStdout: true,
-        ShowStderr: true,
-        Follow:     follow,
-        Tail:       tail,
-        Timestamps: timestamps,
+        ShowStdout: o.Stream != LogStderr,
+        ShowStderr: o.Stream != LogStdout,
+        Follow:     o.Follow,
+        Tail:       o.Tail,
+        Timestamps: o.Timestamps,
+    }
+    if !o.Since.IsZero() {
+        opts.Since = o.Since.Format(time.RFC3339Nano)
+    }
+    if !o.Until.IsZero() {
+        opts.Until = o.Until.Format(time.RFC3339Nano)
     }
 
     stream, err := s.cli.ContainerLogs(ctx, containerID, opts)
@@ -362,9 +642,9 @@ func (s *SDKClient) ContainerLogs(ctx context.Context, containerID string, tail
         return nil, false, fmt.Errorf("container logs: %w", err)
     }
 
-    if isTTY {
+    if isTTY || o.Framed {
         // TTY containers: raw stream, no multiplexing
-        return stream, true, nil
+        return stream, isTTY, nil
     }
 
     // Non-TTY containers: Docker multiplexes stdout/stderr with 8-byte headers.
@@ -425,11 +705,12 @@ func (s *SDKClient) imageListWithOpts(ctx context.Context, opts image.ListOption
         }
 
         result = append(result, ImageSummary{
-            ID:       img.ID,
-            RepoTags: tags,
-            Size:     formatBytes(uint64(img.Size)),
-            Created:  time.Unix(img.Created, 0).UTC().Format(time.RFC3339),
-            Dangling: len(tags) == 0,
+            ID:        img.ID,
+            RepoTags:  tags,
+            Size:      formatBytes(uint64(img.Size)),
+            SizeBytes: img.Size,
+            Created:   time.Unix(img.Created, 0).UTC().Format(time.RFC3339),
+            Dangling:  len(tags) == 0,
         })
     }
 
@@ -488,37 +769,169 @@ func (s *SDKClient) ImageInspectDetail(ctx context.Context, imageRef string) (*I
     }
 
     workingDir := ""
+    var config ImageConfig
     if resp.Config != nil {
         workingDir = resp.Config.WorkingDir
+        config = ImageConfig{
+            Env:        resp.Config.Env,
+            Cmd:        resp.Config.Cmd,
+            Entrypoint: resp.Config.Entrypoint,
+            User:       resp.Config.User,
+            Labels:     resp.Config.Labels,
+        }
+        for v := range resp.Config.Volumes {
+            config.Volumes = append(config.Volumes, v)
+        }
+        sort.Strings(config.Volumes)
     }
 
     return &ImageDetail{
         ImageSummary: ImageSummary{
-            ID:       resp.ID,
-            RepoTags: tags,
-            Size:     formatBytes(uint64(resp.Size)),
-            Created:  resp.Created,
-            Dangling: len(tags) == 0,
+            ID:        resp.ID,
+            RepoTags:  tags,
+            Size:      formatBytes(uint64(resp.Size)),
+            SizeBytes: resp.Size,
+            Created:   resp.Created,
+            Dangling:  len(tags) == 0,
         },
         ImageDetailData: ImageDetailData{
             Architecture: resp.Architecture,
             OS:           resp.Os,
             WorkingDir:   workingDir,
             Layers:       layers,
+            Config:       config,
         },
     }, nil
 }
 
-func (s *SDKClient) ImagePrune(ctx context.Context, all bool) (string, error) {
+func (s *SDKClient) ImagePrune(ctx context.Context, all bool) (PruneReport, error) {
+    // The daemon prunes dangling images only unless told otherwise
+    pruneFilters := filters.NewArgs(filters.Arg("dangling", strconv.FormatBool(!all)))
+    report, err := s.cli.ImagesPrune(ctx, pruneFilters)
+    if err != nil {
+        return PruneReport{}, fmt.Errorf("image prune: %w", err)
+    }
+    var deleted []string
+    for _, d := range report.ImagesDeleted {
+        if d.Deleted != "" {
+            deleted = append(deleted, d.Deleted)
+        }
+    }
+    return newPruneReport(deleted, report.SpaceReclaimed), nil
+}
+
+func (s *SDKClient) VolumeCreate(ctx context.Context, volumeName string) error {
+    if _, err := s.cli.VolumeCreate(ctx, volume.CreateOptions{Name: volumeName}); err != nil {
+        return fmt.Errorf("volume create: %w", err)
+    }
+    return nil
+}
+
+func (s *SDKClient) VolumeRemove(ctx context.Context, volumeName string) error {
+    if err := s.cli.VolumeRemove(ctx, volumeName, false); err != nil {
+        return fmt.Errorf("volume remove: %w", err)
+    }
+    return nil
+}
+
+func (s *SDKClient) VolumePrune(ctx context.Context, all bool) (PruneReport, error) {
     pruneFilters := filters.NewArgs()
-    if !all {
-        pruneFilters.Add("dangling", "true")
+    if all {
+        pruneFilters.Add("all", "true")
     }
-    report, err := s.cli.ImagesPrune(ctx, pruneFilters)
+    report, err := s.cli.VolumesPrune(ctx, pruneFilters)
+    if err != nil {
+        return PruneReport{}, fmt.Errorf("volume prune: %w", err)
+    }
+    return newPruneReport(report.VolumesDeleted, report.SpaceReclaimed), nil
+}
+
+func (s *SDKClient) NetworkCreate(ctx context.Context, opts NetworkCreateOptions) (string, error) {
+    create := network.CreateOptions{
+        Driver:     opts.Driver,
+        Internal:   opts.Internal,
+        Attachable: opts.Attachable,
+        Labels:     opts.Labels,
+    }
+    if opts.Subnet != "" {
+        create.IPAM = &network.IPAM{Config: []network.IPAMConfig{{Subnet: opts.Subnet, Gateway: opts.Gateway}}}
+    }
+    resp, err := s.cli.NetworkCreate(ctx, opts.Name, create)
+    if err != nil {
+        return "", fmt.Errorf("network create: %w", err)
+    }
+    return resp.ID, nil
+}
+
+func (s *SDKClient) NetworkRemove(ctx context.Context, networkID string) error {
+    if err := s.cli.NetworkRemove(ctx, networkID); err != nil {
+        return fmt.Errorf("network remove: %w", err)
+    }
+    return nil
+}
+
+func (s *SDKClient) NetworkConnect(ctx context.Context, networkID, containerID string) error {
+    if err := s.cli.NetworkConnect(ctx, networkID, containerID, nil); err != nil {
+        return fmt.Errorf("network connect: %w", err)
+    }
+    return nil
+}
+
+func (s *SDKClient) NetworkDisconnect(ctx context.Context, networkID, containerID string, force bool) error {
+    if err := s.cli.NetworkDisconnect(ctx, networkID, containerID, force); err != nil {
+        return fmt.Errorf("network disconnect: %w", err)
+    }
+    return nil
+}
+
+func (s *SDKClient) NetworkPrune(ctx context.Context) (PruneReport, error) {
+    report, err := s.cli.NetworksPrune(ctx, filters.NewArgs())
     if err != nil {
-        return "", fmt.Errorf("image prune: %w", err)
+        return PruneReport{}, fmt.Errorf("network prune: %w", err)
     }
-    return "Total reclaimed space: " + formatBytes(report.SpaceReclaimed), nil
+    return newPruneReport(report.NetworksDeleted, 0), nil
+}
+
+func (s *SDKClient) BuildCachePrune(ctx context.Context, all bool) (PruneReport, error) {
+    report, err := s.cli.BuildCachePrune(ctx, build.CachePruneOptions{All: all})
+    if err != nil {
+        return PruneReport{}, fmt.Errorf("build cache prune: %w", err)
+    }
+    return newPruneReport(report.CachesDeleted, report.SpaceReclaimed), nil
+}
+
+func newPruneReport(deleted []string, reclaimed uint64) PruneReport {
+    if deleted == nil {
+        deleted = []string{}
+    }
+    return PruneReport{
+        Deleted:             deleted,
+        SpaceReclaimed:      formatBytes(reclaimed),
+        SpaceReclaimedBytes: int64(reclaimed),
+    }
+}
+
+func (s *SDKClient) ContainerCommit(ctx context.Context, containerID string, opts CommitOptions) (string, error) {
+    resp, err := s.cli.ContainerCommit(ctx, containerID, container.CommitOptions{
+        Reference: opts.Reference,
+        Comment:   opts.Comment,
+        Author:    opts.Author,
+        Pause:     opts.Pause,
+        // The daemon merges the rest of the container's config into this
+        Config: &container.Config{Labels: opts.Labels},
+    })
+    if err != nil {
+        return "", fmt.Errorf("container commit: %w", err)
+    }
+    return resp.ID, nil
+}
+
+func (s *SDKClient) ImageSave(ctx context.Context, imageRefs []string) (io.ReadCloser, error) {
+    rc, err := s.cli.ImageSave(ctx, imageRefs)
+    if err != nil {
+        return nil, fmt.Errorf("image save: %w", err)
+    }
+    return rc, nil
 }
 
 func (s *SDKClient) NetworkList(ctx context.Context) ([]NetworkSummary, error) {
@@ -578,11 +991,18 @@ func (s *SDKClient) NetworkInspect(ctx context.Context, networkID string) (*Netw
     ipam := make([]NetworkIPAM, 0, len(raw.IPAM.Config))
     for _, cfg := range raw.IPAM.Config {
         ipam = append(ipam, NetworkIPAM{
-            Subnet:  cfg.Subnet,
-            Gateway: cfg.Gateway,
+            Subnet:       cfg.Subnet,
+            Gateway:      cfg.Gateway,
+            IPRange:      cfg.IPRange,
+            AuxAddresses: cfg.AuxAddress,
         })
     }
 
+    options := raw.Options
+    if options == nil {
+        options = map[string]string{}
+    }
+
     containers := make([]NetworkContainerDetail, 0, len(raw.Containers))
     for id, ep := range raw.Containers {
         containers = append(containers, NetworkContainerDetail{
@@ -609,10 +1029,15 @@ func (s *SDKClient) NetworkInspect(ctx context.Context, networkID string) (*Netw
             Labels:     raw.Labels,
         },
         NetworkDetailData: NetworkDetailData{
-            IPv6:       raw.EnableIPv6,
-            Created:    raw.Created.Format("2006-01-02T15:04:05Z"),
-            IPAM:       ipam,
-            Containers: containers,
+            IPv6:        raw.EnableIPv6,
+            Created:     raw.Created.Format("2006-01-02T15:04:05Z"),
+            IPAM:        ipam,
+            IPAMDriver:  raw.IPAM.Driver,
+            IPAMOptions: raw.IPAM.Options,
+            Options:     options,
+            MTU:         NetworkMTU(options),
+            Parent:      options["parent"],
+            Containers:  containers,
         },
     }, nil
 }
@@ -715,8 +1140,8 @@ func (s *SDKClient) Events(ctx context.Context) (<-chan DockerEvent, <-chan erro
                 switch msg.Type {
                 case events.ContainerEventType:
                     switch msg.Action {
-                    case events.ActionStart, events.ActionStop, events.ActionDie,
-                        events.ActionPause, events.ActionUnPause,
+                    case events.ActionStart, events.ActionStop, events.ActionDie, events.ActionRestart,
+                        events.ActionPause, events.ActionUnPause, events.ActionOOM, events.ActionKill,
                         events.ActionDestroy, events.ActionCreate:
                         // ok
                     default:
@@ -739,6 +1164,7 @@ func (s *SDKClient) Events(ctx context.Context) (<-chan DockerEvent, <-chan erro
                     Action:  action,
                     Name:    msg.Actor.Attributes["name"],
                     ActorID: msg.Actor.ID,
+                    Time:    time.Unix(0, msg.TimeNano),
                     Raw:     msg,
                 }
                 // Extract project/service/container from actor attributes.
@@ -747,11 +1173,14 @@ func (s *SDKClient) Events(ctx context.Context) (<-chan DockerEvent, <-chan erro
                 switch msg.Type {
                 case events.ContainerEventType:
                     evt.ContainerID = msg.Actor.ID
-                    evt.Project = msg.Actor.Attributes["com.docker.compose.project"]
+                    evt.Project = s.stackName(msg.Actor.Attributes)
                     evt.Service = msg.Actor.Attributes["com.docker.compose.service"]
+                    // Besides name and image, a container's attributes are its labels
+                    evt.Labels = msg.Actor.Attributes
+                    evt.ExitCode = msg.Actor.Attributes["exitCode"]
                 case events.NetworkEventType:
                     evt.ContainerID = msg.Actor.Attributes["container"]
-                    evt.Project = msg.Actor.Attributes["com.docker.compose.project"]
+                    evt.Project = s.stackName(msg.Actor.Attributes)
                     evt.Service = msg.Actor.Attributes["com.docker.compose.service"]
                 case events.VolumeEventType:
                     evt.ContainerID = msg.Actor.Attributes["container"]
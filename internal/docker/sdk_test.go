@@ -0,0 +1,56 @@
+package docker
+
+import (
+    "testing"
+
+    "github.com/docker/docker/api/types"
+    "github.com/docker/docker/api/types/build"
+    "github.com/docker/docker/api/types/container"
+    "github.com/docker/docker/api/types/image"
+    "github.com/docker/docker/api/types/volume"
+)
+
+func TestSummarizeDiskUsage(t *testing.T) {
+    du := types.DiskUsage{
+        LayersSize: 1000,
+        Images: []*image.Summary{
+            {Size: 600, SharedSize: 100, Containers: 2}, // 500 of its own in use
+            {Size: 300, SharedSize: 100, Containers: 0},
+            {Size: -1, SharedSize: -1, Containers: 1},
+        },
+        Containers: []*container.Summary{
+            {State: container.StateRunning, SizeRw: 10},
+            {State: container.StateExited, SizeRw: 20},
+            {State: container.StatePaused, SizeRw: 5},
+        },
+        Volumes: []*volume.Volume{
+            {UsageData: &volume.UsageData{Size: 400, RefCount: 1}},
+            {UsageData: &volume.UsageData{Size: 100, RefCount: 0}},
+            {UsageData: &volume.UsageData{Size: -1, RefCount: 0}},
+            {},
+        },
+        BuildCache: []*build.CacheRecord{
+            {Size: 50, InUse: true},
+            {Size: 70},
+            {Size: 30, Shared: true},
+        },
+    }
+    got := summarizeDiskUsage(du)
+
+    for _, tt := range []struct {
+        name string
+        got  DiskUsageSummary
+        want DiskUsageSummary
+    }{
+        {"images", got.Images, DiskUsageSummary{Total: 3, Active: 2, SizeBytes: 1000, ReclaimableBytes: 500}},
+        {"containers", got.Containers, DiskUsageSummary{Total: 3, Active: 2, SizeBytes: 35, ReclaimableBytes: 20}},
+        {"volumes", got.Volumes, DiskUsageSummary{Total: 4, Active: 1, SizeBytes: 500, ReclaimableBytes: 100}},
+        {"buildCache", got.BuildCache, DiskUsageSummary{Total: 3, Active: 1, SizeBytes: 120, ReclaimableBytes: 70}},
+    } {
+        tt.want.Size = formatBytes(uint64(tt.want.SizeBytes))
+        tt.want.Reclaimable = formatBytes(uint64(tt.want.ReclaimableBytes))
+        if tt.got != tt.want {
+            t.Errorf("%s = %+v, want %+v", tt.name, tt.got, tt.want)
+        }
+    }
+}
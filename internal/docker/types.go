@@ -1,14 +1,71 @@
 package docker
 
+import (
+    "io"
+    "time"
+)
+
 // Container holds the fields needed by handlers from a running or stopped container.
 type Container struct {
-    ID      string
-    Name    string
-    Project string // com.docker.compose.project
-    Service string // com.docker.compose.service
-    Image   string // image reference the container was created from
-    State   string // running, exited, created, paused, dead, ...
-    Health  string // healthy, unhealthy, starting, or "" (no healthcheck)
+    ID          string
+    Name        string
+    Project     string // stack name; com.docker.compose.project unless a ProjectResolver maps it
+    Service     string // com.docker.compose.service
+    WorkingDir  string // com.docker.compose.project.working_dir
+    ConfigFiles string // com.docker.compose.project.config_files (comma-separated)
+    Image       string // image reference the container was created from
+    State       string // running, exited, created, paused, dead, ...
+    Health      string // healthy, unhealthy, starting, or "" (no healthcheck)
+    Labels      map[string]string
+}
+
+// CommitOptions controls ContainerCommit.
+type CommitOptions struct {
+    Reference string            // repository:tag of the new image
+    Comment   string            // commit message shown by `docker history`
+    Author    string
+    Labels    map[string]string // added to the labels inherited from the container
+    Pause     bool              // pause the container while committing
+}
+
+// NetworkCreateOptions configures NetworkCreate.
+type NetworkCreateOptions struct {
+    Name       string
+    Driver     string            // bridge, overlay, macvlan, ...; "" for bridge
+    Subnet     string            // CIDR; "" lets the daemon pick one
+    Gateway    string            // "" for the daemon's pick in Subnet
+    Internal   bool              // no route to the outside
+    Attachable bool              // standalone containers may join (overlay)
+    Labels     map[string]string
+}
+
+// ExecOptions configures an interactive exec in a container.
+type ExecOptions struct {
+    Cmd        []string
+    User       string   // user[:group], "" for the image's user
+    WorkingDir string   // "" for the image's workdir
+    Env        []string // KEY=VALUE, added to the container's environment
+    Rows, Cols uint16   // initial TTY size
+}
+
+// ExecSession is an exec attached with a TTY. Reads return its output and
+// writes go to its input; Close detaches, which ends the shell.
+type ExecSession interface {
+    io.ReadWriteCloser
+    Resize(rows, cols uint16) error
+    // ExitCode returns the exit code of the exec once its output ended.
+    ExitCode() (int, error)
+}
+
+// LogOptions selects the part of a container's log to read.
+type LogOptions struct {
+    Tail       string    // number of lines from the end, "all" or "" for everything
+    Since      time.Time // zero for the start of the log
+    Until      time.Time // zero for the end of the log
+    Follow     bool
+    Timestamps bool   // prefix each line with its RFC3339Nano timestamp
+    Stream     string // LogStdout or LogStderr for that stream only, "" for both
+    Framed     bool   // keep the frames of a non-TTY log, for a LogScanner to tell its streams apart
 }
 
 // ContainerBroadcast is the enriched container type sent to the frontend via
@@ -26,6 +83,7 @@ type ContainerBroadcast struct {
     Networks    map[string]ContainerNetwork `json:"networks"`
     Mounts      []ContainerMount            `json:"mounts"`
     Ports       []ContainerPort             `json:"ports"`
+    Labels      map[string]string           `json:"-"` // for the ignore list, not sent
 }
 
 // ContainerNetwork holds network endpoint info for a container.
@@ -48,6 +106,58 @@ type ContainerPort struct {
     Protocol      string `json:"protocol"` // "tcp", "udp"
 }
 
+// DaemonInfo is the part of the daemon's system info Dockge adapts to.
+type DaemonInfo struct {
+    ServerVersion   string   `json:"serverVersion"`
+    OperatingSystem string   `json:"operatingSystem"`
+    CgroupDriver    string   `json:"cgroupDriver"` // "none" when containers get no cgroup
+    CgroupVersion   string   `json:"cgroupVersion"`
+    SecurityOptions []string `json:"securityOptions"`
+    Rootless        bool     `json:"rootless"`
+}
+
+// StatsSupported reports whether the daemon can report container resource
+// usage. A rootless daemon without cgroup v2 delegation runs containers
+// without cgroups, so it has nothing to read.
+func (i DaemonInfo) StatsSupported() bool {
+    return i.CgroupDriver != "none"
+}
+
+// DiskUsage is what the daemon's disk usage report (`docker system df`)
+// sums up to: the space each kind of object takes and how much of it
+// pruning would free.
+type DiskUsage struct {
+    Images     DiskUsageSummary `json:"images"`
+    Containers DiskUsageSummary `json:"containers"`
+    Volumes    DiskUsageSummary `json:"volumes"`
+    BuildCache DiskUsageSummary `json:"buildCache"`
+}
+
+// DiskUsageSummary is the disk usage of one kind of object. Active ones are
+// in use: images with containers, running containers, volumes mounted by a
+// container and build cache records in use.
+type DiskUsageSummary struct {
+    Total            int    `json:"total"`
+    Active           int    `json:"active"`
+    Size             string `json:"size"`
+    SizeBytes        int64  `json:"sizeBytes"`
+    Reclaimable      string `json:"reclaimable"`
+    ReclaimableBytes int64  `json:"reclaimableBytes"`
+}
+
+// PruneReport is what a prune removed and the space it freed.
+type PruneReport struct {
+    Deleted             []string `json:"deleted"` // IDs or names of the removed objects
+    SpaceReclaimed      string   `json:"spaceReclaimed"`
+    SpaceReclaimedBytes int64    `json:"spaceReclaimedBytes"`
+}
+
+// ContainerUsage holds numeric resource usage for budget accounting.
+type ContainerUsage struct {
+    CPUPercent float64 // percent of one CPU (200 = two full cores)
+    MemBytes   uint64  // excluding page cache
+}
+
 // ContainerStat holds formatted resource-usage strings matching the Node.js frontend expectations.
 type ContainerStat struct {
     Name     string `json:"Name"`
@@ -65,7 +175,7 @@ type DockerEvent struct {
     Type   string // "container", "network", "image", "volume"
     Action string // start, stop, die, create, destroy, connect, disconnect, pull, tag, ...
     // Container-specific fields (empty for non-container events)
-    Project     string // from com.docker.compose.project label
+    Project     string // stack name from the com.docker.compose.project label
     Service     string // from com.docker.compose.service label
     ContainerID string
     // Name is the resource name from Actor.Attributes["name"].
@@ -75,6 +185,12 @@ type DockerEvent struct {
     // For containers it equals ContainerID; for networks/images/volumes
     // it's the resource's Docker ID.
     ActorID string
+    // Labels are the container's labels, for container events (nil otherwise).
+    Labels map[string]string
+    // ExitCode is the container's exit code, for die events.
+    ExitCode string
+    // Time is when Docker reported the event.
+    Time time.Time
     // Raw holds the original Docker API event message (JSON-serializable).
     // Used for dev inspection — broadcast as-is to WebSocket clients.
     Raw any `json:"-"`
@@ -86,10 +202,15 @@ type ContainerEvent = DockerEvent
 
 // NetworkDetailData holds inspect-level data not in NetworkSummary.
 type NetworkDetailData struct {
-    IPv6       bool                     `json:"ipv6"`
-    Created    string                   `json:"created"`
-    IPAM       []NetworkIPAM            `json:"ipam"`
-    Containers []NetworkContainerDetail `json:"containers"`
+    IPv6        bool                     `json:"ipv6"`
+    Created     string                   `json:"created"`
+    IPAM        []NetworkIPAM            `json:"ipam"`
+    IPAMDriver  string                   `json:"ipamDriver"`
+    IPAMOptions map[string]string        `json:"ipamOptions,omitempty"`
+    Options     map[string]string        `json:"options"` // driver options, as passed via driver_opts
+    MTU         int                      `json:"mtu,omitempty"`    // from the driver's MTU option; 0 = daemon default
+    Parent      string                   `json:"parent,omitempty"` // macvlan/ipvlan parent interface
+    Containers  []NetworkContainerDetail `json:"containers"`
 }
 
 // NetworkSummary holds basic info for network list display.
@@ -112,8 +233,10 @@ type NetworkDetail struct {
 
 // NetworkIPAM holds IPAM configuration for a network.
 type NetworkIPAM struct {
-    Subnet  string `json:"subnet"`
-    Gateway string `json:"gateway"`
+    Subnet       string            `json:"subnet"`
+    Gateway      string            `json:"gateway"`
+    IPRange      string            `json:"ipRange,omitempty"`
+    AuxAddresses map[string]string `json:"auxAddresses,omitempty"`
 }
 
 // NetworkContainerDetail holds info about a container connected to a network.
@@ -132,15 +255,27 @@ type ImageDetailData struct {
     OS           string       `json:"os"`
     WorkingDir   string       `json:"workingDir"`
     Layers       []ImageLayer `json:"layers"`
+    Config       ImageConfig  `json:"config"`
+}
+
+// ImageConfig holds the defaults an image gives its containers.
+type ImageConfig struct {
+    Env        []string          `json:"env"`
+    Cmd        []string          `json:"cmd"`
+    Entrypoint []string          `json:"entrypoint"`
+    User       string            `json:"user"`
+    Labels     map[string]string `json:"labels"`
+    Volumes    []string          `json:"volumes"`
 }
 
 // ImageSummary holds basic info for image list display.
 type ImageSummary struct {
-    ID       string   `json:"id"`
-    RepoTags []string `json:"repoTags"`
-    Size     string   `json:"size"`
-    Created  string   `json:"created"`
-    Dangling bool     `json:"dangling"`
+    ID        string   `json:"id"`
+    RepoTags  []string `json:"repoTags"`
+    Size      string   `json:"size"`
+    SizeBytes int64    `json:"sizeBytes"`
+    Created   string   `json:"created"`
+    Dangling  bool     `json:"dangling"`
 }
 
 // ImageDetail holds inspect-level data for the image detail page.
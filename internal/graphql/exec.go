@@ -0,0 +1,489 @@
+// Package graphql executes read-only GraphQL queries against a schema of
+// Go resolvers. It covers what dashboards need from a query language —
+// fields, aliases, arguments, variables, fragments and the @skip and
+// @include directives — and nothing that changes state: mutations and
+// subscriptions are rejected, and there's no introspection beyond
+// __typename.
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+const (
+	// maxDepth bounds how deep selections nest.
+	maxDepth = 12
+	// maxSelections bounds the selections a query expands to, fragments
+	// included, so that a few bytes of nested spreads can't fan out.
+	maxSelections = 5000
+)
+
+// Schema is the query type of a schema.
+type Schema struct {
+	Query *Object
+}
+
+// Object is an object type and the fields it resolves.
+type Object struct {
+	Name   string
+	Fields map[string]*Field
+}
+
+// Field is a field of an object type.
+type Field struct {
+	// Type is the object type of the field's value, or of its items for a
+	// list; nil for scalars and lists of scalars
+	Type *Object
+	// Args are the names of the arguments the field takes
+	Args []string
+	// Resolve returns the value of the field on source, the value of the
+	// parent object. A nil Resolve reads the source's map entry or the
+	// struct field with the field's JSON name.
+	Resolve func(source any, args map[string]any) (any, error)
+}
+
+// Request is a GraphQL request as sent over HTTP.
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// Response is the result of a request. Data is missing when the request
+// failed before execution.
+type Response struct {
+	Data   any      `json:"data,omitempty"`
+	Errors []*Error `json:"errors,omitempty"`
+}
+
+// Error is a GraphQL error, with the location in the query it was found at
+// or the path of the field that failed.
+type Error struct {
+	Message   string     `json:"message"`
+	Locations []Location `json:"locations,omitempty"`
+	Path      []any      `json:"path,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Location is a 1-based position in a query.
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func errorAt(src string, pos int, msg string) *Error {
+	line, col := 1, 1
+	for _, r := range src[:min(pos, len(src))] {
+		if r == '\n' {
+			line, col = line+1, 1
+		} else {
+			col++
+		}
+	}
+	return &Error{Message: msg, Locations: []Location{{Line: line, Column: col}}}
+}
+
+// Execute runs a query against schema. Errors of fields are reported
+// alongside the data, with the field set to null.
+func Execute(schema *Schema, req Request) *Response {
+	doc, perr := parse(req.Query)
+	if perr != nil {
+		return &Response{Errors: []*Error{perr}}
+	}
+	op, err := selectOperation(doc, req.OperationName)
+	if err != nil {
+		return &Response{Errors: []*Error{err}}
+	}
+	if op.kind != "query" {
+		return &Response{Errors: []*Error{{Message: fmt.Sprintf("Only queries are supported, not %ss.", op.kind)}}}
+	}
+	vars, errs := coerceVariables(op, req.Variables)
+	if len(errs) > 0 {
+		return &Response{Errors: errs}
+	}
+	v := &validator{src: req.Query, doc: doc, defined: map[string]bool{}}
+	for _, def := range op.variables {
+		v.defined[def.name] = true
+	}
+	v.selections(schema.Query, op.selections, 1)
+	if len(v.errs) > 0 {
+		return &Response{Errors: v.errs}
+	}
+	e := &executor{doc: doc, vars: vars}
+	data := e.selections(schema.Query, nil, op.selections, nil)
+	return &Response{Data: data, Errors: e.errs}
+}
+
+func selectOperation(doc *document, name string) (*operation, *Error) {
+	if name == "" {
+		switch len(doc.operations) {
+		case 0:
+			return nil, &Error{Message: "Must provide an operation."}
+		case 1:
+			return doc.operations[0], nil
+		}
+		return nil, &Error{Message: "Must provide operation name if query contains multiple operations."}
+	}
+	for _, op := range doc.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, &Error{Message: fmt.Sprintf("Unknown operation named %q.", name)}
+}
+
+// coerceVariables returns the values of an operation's variables: the ones
+// given, else their defaults. Types aren't checked beyond required ones
+// being set; resolvers check the values they get.
+func coerceVariables(op *operation, given map[string]any) (map[string]any, []*Error) {
+	vars := map[string]any{}
+	var errs []*Error
+	for _, def := range op.variables {
+		val, ok := given[def.name]
+		if !ok && def.hasDef {
+			val, ok = def.def, true
+		}
+		if strings.HasSuffix(def.typ, "!") && (!ok || val == nil) {
+			errs = append(errs, &Error{Message: fmt.Sprintf("Variable \"$%s\" of required type %q was not provided.", def.name, def.typ)})
+			continue
+		}
+		if ok {
+			vars[def.name] = val
+		}
+	}
+	return vars, errs
+}
+
+// validator checks a query against the schema before it runs.
+type validator struct {
+	src      string
+	doc      *document
+	defined  map[string]bool // variables of the operation
+	visiting []string        // fragments being expanded, to catch cycles
+	count    int
+	errs     []*Error
+}
+
+func (v *validator) fail(pos int, format string, args ...any) {
+	v.errs = append(v.errs, errorAt(v.src, pos, fmt.Sprintf(format, args...)))
+}
+
+func (v *validator) selections(obj *Object, sels []selection, depth int) {
+	if depth > maxDepth {
+		v.fail(0, "Query is nested deeper than %d levels.", maxDepth)
+		return
+	}
+	for _, sel := range sels {
+		if len(v.errs) > 0 {
+			return
+		}
+		if v.count++; v.count > maxSelections {
+			v.fail(0, "Query has more than %d selections.", maxSelections)
+			return
+		}
+		switch s := sel.(type) {
+		case *field:
+			v.directives(s.position, s.directives)
+			v.field(obj, s, depth)
+		case *inlineFragment:
+			v.directives(s.position, s.directives)
+			if s.typeCondition != "" && s.typeCondition != obj.Name {
+				v.fail(s.position, "Fragment cannot be spread here as objects of type %q can never be of type %q.", obj.Name, s.typeCondition)
+				return
+			}
+			v.selections(obj, s.selections, depth)
+		case *fragmentSpread:
+			v.directives(s.position, s.directives)
+			f, ok := v.doc.fragments[s.name]
+			if !ok {
+				v.fail(s.position, "Unknown fragment %q.", s.name)
+				return
+			}
+			for _, name := range v.visiting {
+				if name == s.name {
+					v.fail(s.position, "Cannot spread fragment %q within itself.", s.name)
+					return
+				}
+			}
+			if f.typeCondition != obj.Name {
+				v.fail(s.position, "Fragment %q cannot be spread here as objects of type %q can never be of type %q.", s.name, obj.Name, f.typeCondition)
+				return
+			}
+			v.visiting = append(v.visiting, s.name)
+			v.selections(obj, f.selections, depth)
+			v.visiting = v.visiting[:len(v.visiting)-1]
+		}
+	}
+}
+
+func (v *validator) field(obj *Object, f *field, depth int) {
+	if f.name == "__typename" {
+		if len(f.arguments) > 0 || len(f.selections) > 0 {
+			v.fail(f.position, "Field \"__typename\" takes no arguments or selections.")
+		}
+		return
+	}
+	def, ok := obj.Fields[f.name]
+	if !ok {
+		v.fail(f.position, "Cannot query field %q on type %q.", f.name, obj.Name)
+		return
+	}
+	for _, name := range f.argOrder {
+		known := false
+		for _, a := range def.Args {
+			known = known || a == name
+		}
+		if !known {
+			v.fail(f.position, "Unknown argument %q on field \"%s.%s\".", name, obj.Name, f.name)
+			return
+		}
+		v.value(f.position, f.arguments[name])
+	}
+	switch {
+	case def.Type == nil && len(f.selections) > 0:
+		v.fail(f.position, "Field %q must not have a selection since it has no subfields.", f.name)
+	case def.Type != nil && len(f.selections) == 0:
+		v.fail(f.position, "Field %q of type %q must have a selection of subfields.", f.name, def.Type.Name)
+	case def.Type != nil:
+		v.selections(def.Type, f.selections, depth+1)
+	}
+}
+
+func (v *validator) directives(pos int, dirs []*directive) {
+	for _, d := range dirs {
+		if d.name != "skip" && d.name != "include" {
+			v.fail(pos, "Unknown directive \"@%s\".", d.name)
+			return
+		}
+		if _, ok := d.arguments["if"]; !ok || len(d.arguments) != 1 {
+			v.fail(pos, "Directive \"@%s\" takes a single argument \"if\".", d.name)
+			return
+		}
+		v.value(pos, d.arguments["if"])
+	}
+}
+
+// value checks that the variables an argument value uses are defined.
+func (v *validator) value(pos int, val any) {
+	switch x := val.(type) {
+	case variable:
+		if !v.defined[string(x)] {
+			v.fail(pos, "Variable \"$%s\" is not defined.", x)
+		}
+	case []any:
+		for _, item := range x {
+			v.value(pos, item)
+		}
+	case map[string]any:
+		for _, item := range x {
+			v.value(pos, item)
+		}
+	}
+}
+
+// executor resolves the fields of a validated query.
+type executor struct {
+	doc  *document
+	vars map[string]any
+	errs []*Error
+}
+
+// selections resolves a selection set on source, a value of type obj.
+func (e *executor) selections(obj *Object, source any, sels []selection, path []any) *orderedMap {
+	out := &orderedMap{values: map[string]any{}}
+	keys, fields := e.collectFields(obj, sels, map[string]bool{}, nil, map[string][]*field{})
+	for _, key := range keys {
+		out.set(key, e.field(obj, source, fields[key], append(path[:len(path):len(path)], key)))
+	}
+	return out
+}
+
+// collectFields groups the fields of a selection set by response key,
+// expanding fragments and dropping skipped selections.
+func (e *executor) collectFields(obj *Object, sels []selection, visited map[string]bool, keys []string, fields map[string][]*field) ([]string, map[string][]*field) {
+	for _, sel := range sels {
+		switch s := sel.(type) {
+		case *field:
+			if !e.include(s.directives) {
+				continue
+			}
+			key := s.responseKey()
+			if _, ok := fields[key]; !ok {
+				keys = append(keys, key)
+			}
+			fields[key] = append(fields[key], s)
+		case *inlineFragment:
+			if e.include(s.directives) {
+				keys, fields = e.collectFields(obj, s.selections, visited, keys, fields)
+			}
+		case *fragmentSpread:
+			if visited[s.name] || !e.include(s.directives) {
+				continue
+			}
+			visited[s.name] = true
+			keys, fields = e.collectFields(obj, e.doc.fragments[s.name].selections, visited, keys, fields)
+		}
+	}
+	return keys, fields
+}
+
+func (e *executor) include(dirs []*directive) bool {
+	for _, d := range dirs {
+		cond, _ := e.resolve(d.arguments["if"]).(bool)
+		if (d.name == "skip") == cond {
+			return false
+		}
+	}
+	return true
+}
+
+// field resolves the fields sharing a response key. The name and arguments
+// are the first one's; the selections of all of them are merged.
+func (e *executor) field(obj *Object, source any, fields []*field, path []any) any {
+	f := fields[0]
+	if f.name == "__typename" {
+		return obj.Name
+	}
+	def := obj.Fields[f.name]
+	args := make(map[string]any, len(f.arguments))
+	for name, val := range f.arguments {
+		args[name] = e.resolve(val)
+	}
+	var val any
+	if def.Resolve != nil {
+		var err error
+		if val, err = def.Resolve(source, args); err != nil {
+			e.errs = append(e.errs, &Error{Message: err.Error(), Path: path})
+			return nil
+		}
+	} else {
+		val = defaultResolve(source, f.name)
+	}
+	if def.Type == nil {
+		return val
+	}
+	var sels []selection
+	for _, f := range fields {
+		sels = append(sels, f.selections...)
+	}
+	return e.complete(def.Type, val, sels, path)
+}
+
+// complete resolves the selections of an object value, or of each item of
+// a list of them.
+func (e *executor) complete(obj *Object, val any, sels []selection, path []any) any {
+	rv := reflect.ValueOf(val)
+	if !rv.IsValid() || ((rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Map) && rv.IsNil()) {
+		return nil
+	}
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		list := make([]any, rv.Len())
+		for i := range list {
+			list[i] = e.complete(obj, rv.Index(i).Interface(), sels, append(path[:len(path):len(path)], i))
+		}
+		return list
+	}
+	return e.selections(obj, val, sels, path)
+}
+
+// resolve substitutes the variables of an argument value and turns enum
+// literals into strings.
+func (e *executor) resolve(val any) any {
+	switch x := val.(type) {
+	case variable:
+		return e.vars[string(x)]
+	case enumValue:
+		return string(x)
+	case []any:
+		list := make([]any, len(x))
+		for i, item := range x {
+			list[i] = e.resolve(item)
+		}
+		return list
+	case map[string]any:
+		obj := make(map[string]any, len(x))
+		for k, item := range x {
+			obj[k] = e.resolve(item)
+		}
+		return obj
+	}
+	return val
+}
+
+// defaultResolve reads the map entry or the struct field (by JSON name) of
+// source called name.
+func defaultResolve(source any, name string) any {
+	v := reflect.ValueOf(source)
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		if item := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key())); item.IsValid() {
+			return item.Interface()
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if !sf.IsExported() {
+				continue
+			}
+			tag, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+			if tag == name || (tag == "" && sf.Name == name) {
+				return v.Field(i).Interface()
+			}
+		}
+	}
+	return nil
+}
+
+// orderedMap is a JSON object that keeps the order of its keys: GraphQL
+// responses list fields in the order they were asked for.
+type orderedMap struct {
+	keys   []string
+	values map[string]any
+}
+
+func (m *orderedMap) set(key string, val any) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = val
+}
+
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,214 @@
+package graphql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type testItem struct {
+	Name  string   `json:"name"`
+	Tags  []string `json:"tags"`
+	Count int      `json:"count"`
+}
+
+func testSchema() *Schema {
+	item := &Object{Name: "Item", Fields: map[string]*Field{
+		"name":  {},
+		"tags":  {},
+		"count": {},
+	}}
+	items := []testItem{
+		{Name: "a", Tags: []string{"x"}, Count: 1},
+		{Name: "b", Count: 2},
+	}
+	item.Fields["related"] = &Field{Type: item, Resolve: func(source any, _ map[string]any) (any, error) {
+		if source.(testItem).Name == "a" {
+			return []testItem{items[1]}, nil
+		}
+		return nil, nil
+	}}
+	return &Schema{Query: &Object{Name: "Query", Fields: map[string]*Field{
+		"items": {Type: item, Args: []string{"name"}, Resolve: func(_ any, args map[string]any) (any, error) {
+			name, _ := args["name"].(string)
+			var out []testItem
+			for _, it := range items {
+				if name == "" || it.Name == name {
+					out = append(out, it)
+				}
+			}
+			return out, nil
+		}},
+		"item": {Type: item, Args: []string{"name"}, Resolve: func(_ any, args map[string]any) (any, error) {
+			for _, it := range items {
+				if it.Name == args["name"] {
+					return &it, nil
+				}
+			}
+			return nil, nil
+		}},
+		"version": {Resolve: func(any, map[string]any) (any, error) { return "1.0", nil }},
+		"broken":  {Resolve: func(any, map[string]any) (any, error) { return nil, errors.New("no data") }},
+	}}}
+}
+
+func run(t *testing.T, req Request) string {
+	t.Helper()
+	out, err := json.Marshal(Execute(testSchema(), req))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		name string
+		req  Request
+		want string
+	}{
+		{
+			name: "fields keep their order",
+			req:  Request{Query: `{ version items { count name } }`},
+			want: `{"data":{"version":"1.0","items":[{"count":1,"name":"a"},{"count":2,"name":"b"}]}}`,
+		},
+		{
+			name: "aliases and arguments",
+			req:  Request{Query: `query { first: item(name: "a") { name tags } none: item(name: "z") { name } }`},
+			want: `{"data":{"first":{"name":"a","tags":["x"]},"none":null}}`,
+		},
+		{
+			name: "variables and defaults",
+			req:  Request{Query: `query Q($n: String = "b") { items(name: $n) { name } }`},
+			want: `{"data":{"items":[{"name":"b"}]}}`,
+		},
+		{
+			name: "given variables",
+			req:  Request{Query: `query Q($n: String!) { items(name: $n) { name } }`, Variables: map[string]any{"n": "a"}},
+			want: `{"data":{"items":[{"name":"a"}]}}`,
+		},
+		{
+			name: "fragments merge",
+			req: Request{Query: `
+				{ items { ...F ... on Item { count } name } }
+				fragment F on Item { name related { name } }`},
+			want: `{"data":{"items":[{"name":"a","related":[{"name":"b"}],"count":1},{"name":"b","related":null,"count":2}]}}`,
+		},
+		{
+			name: "skip and include",
+			req:  Request{Query: `query($s: Boolean) { items { name @skip(if: $s) count @include(if: false) } }`, Variables: map[string]any{"s": true}},
+			want: `{"data":{"items":[{},{}]}}`,
+		},
+		{
+			name: "typename",
+			req:  Request{Query: `{ __typename item(name: "a") { __typename } }`},
+			want: `{"data":{"__typename":"Query","item":{"__typename":"Item"}}}`,
+		},
+		{
+			name: "field errors null the field",
+			req:  Request{Query: `{ version broken }`},
+			want: `{"data":{"version":"1.0","broken":null},"errors":[{"message":"no data","path":["broken"]}]}`,
+		},
+		{
+			name: "operation by name",
+			req:  Request{Query: `query A { version } query B { item(name: "b") { count } }`, OperationName: "B"},
+			want: `{"data":{"item":{"count":2}}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := run(t, tt.req); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		req  Request
+		want string
+	}{
+		{"syntax", Request{Query: `{ items { name }`}, `Syntax Error: Expected Name, found <EOF>.`},
+		{"empty", Request{Query: ` `}, `Syntax Error`},
+		{"mutation", Request{Query: `mutation { version }`}, `Only queries are supported`},
+		{"subscription", Request{Query: `subscription { version }`}, `Only queries are supported`},
+		{"unknown field", Request{Query: `{ nope }`}, `Cannot query field "nope" on type "Query".`},
+		{"unknown argument", Request{Query: `{ item(id: 1) { name } }`}, `Unknown argument "id"`},
+		{"missing selection", Request{Query: `{ items }`}, `must have a selection of subfields`},
+		{"scalar selection", Request{Query: `{ version { name } }`}, `must not have a selection`},
+		{"undefined variable", Request{Query: `{ item(name: $x) { name } }`}, `Variable "$x" is not defined.`},
+		{"missing variable", Request{Query: `query($x: String!) { item(name: $x) { name } }`}, `was not provided`},
+		{"unknown fragment", Request{Query: `{ items { ...F } }`}, `Unknown fragment "F".`},
+		{"fragment cycle", Request{Query: `{ items { ...F } } fragment F on Item { related { ...F } }`}, `within itself`},
+		{"wrong type condition", Request{Query: `{ items { ... on Query { version } } }`}, `can never be of type "Query"`},
+		{"unknown directive", Request{Query: `{ version @cached }`}, `Unknown directive "@cached"`},
+		{"multiple operations", Request{Query: `query A { version } query B { version }`}, `Must provide operation name`},
+		{"unknown operation", Request{Query: `query A { version }`, OperationName: "B"}, `Unknown operation named "B".`},
+		{"too deep", Request{Query: `{ items { related { related { related { related { related { related { related { related { related { related { related { related { name } } } } } } } } } } } } } }`}, `nested deeper`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := Execute(testSchema(), tt.req)
+			if resp.Data != nil {
+				t.Errorf("data = %v, want none", resp.Data)
+			}
+			if len(resp.Errors) == 0 || !strings.Contains(resp.Errors[0].Message, tt.want) {
+				t.Errorf("errors = %v, want %q", resp.Errors, tt.want)
+			}
+		})
+	}
+}
+
+func TestFragmentFanOutIsBounded(t *testing.T) {
+	// Each fragment spreads the next one twice: 2^20 expansions
+	var b strings.Builder
+	b.WriteString("{ items { ...F0 } }\n")
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&b, "fragment F%d on Item { name ...F%d ...F%d }\n", i, i+1, i+1)
+	}
+	b.WriteString("fragment F20 on Item { name }\n")
+	resp := Execute(testSchema(), Request{Query: b.String()})
+	if len(resp.Errors) == 0 || !strings.Contains(resp.Errors[0].Message, "more than") {
+		t.Errorf("errors = %v, want the query rejected", resp.Errors)
+	}
+}
+
+func TestErrorLocation(t *testing.T) {
+	resp := Execute(testSchema(), Request{Query: "{\n  version\n  nope\n}"})
+	if len(resp.Errors) != 1 {
+		t.Fatalf("errors = %v", resp.Errors)
+	}
+	if got := resp.Errors[0].Locations; len(got) != 1 || got[0] != (Location{Line: 3, Column: 3}) {
+		t.Errorf("locations = %v, want 3:3", got)
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	doc, err := parse(`{ f(a: -1.5e2, b: [1, "two", ENUM, null], c: {d: true}, e: """
+		block
+		  text
+	""", g: "é\n") }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := doc.operations[0].selections[0].(*field).arguments
+	if args["a"] != -150.0 {
+		t.Errorf("a = %v", args["a"])
+	}
+	if list := args["b"].([]any); len(list) != 4 || list[0] != 1 || list[1] != "two" || list[2] != enumValue("ENUM") || list[3] != nil {
+		t.Errorf("b = %v", list)
+	}
+	if obj := args["c"].(map[string]any); obj["d"] != true {
+		t.Errorf("c = %v", obj)
+	}
+	if args["e"] != "block\n  text" {
+		t.Errorf("e = %q", args["e"])
+	}
+	if args["g"] != "é\n" {
+		t.Errorf("g = %q", args["g"])
+	}
+}
@@ -0,0 +1,588 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// document is a parsed request: its operations and named fragments.
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+// operation is a query, mutation or subscription of a document.
+type operation struct {
+	kind       string // query, mutation or subscription
+	name       string
+	variables  []*variableDef
+	selections []selection
+}
+
+// variableDef declares a variable of an operation.
+type variableDef struct {
+	name     string
+	typ      string // as written, e.g. "[String!]!"
+	def      any    // default value, nil if none
+	hasDef   bool
+	position int
+}
+
+// fragment is a named fragment definition.
+type fragment struct {
+	name          string
+	typeCondition string
+	selections    []selection
+	position      int
+}
+
+// selection is a *field, *fragmentSpread or *inlineFragment.
+type selection any
+
+type field struct {
+	alias      string
+	name       string
+	arguments  map[string]any
+	argOrder   []string
+	directives []*directive
+	selections []selection
+	position   int
+}
+
+// responseKey is the name a field's value is returned under.
+func (f *field) responseKey() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+type fragmentSpread struct {
+	name       string
+	directives []*directive
+	position   int
+}
+
+type inlineFragment struct {
+	typeCondition string // "" when the fragment has none
+	directives    []*directive
+	selections    []selection
+	position      int
+}
+
+type directive struct {
+	name      string
+	arguments map[string]any
+}
+
+// variable is a reference to a variable in an argument value.
+type variable string
+
+// enumValue is an enum literal in an argument value.
+type enumValue string
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+type token struct {
+	kind tokenKind
+	val  string
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "<EOF>"
+	case tokName:
+		return fmt.Sprintf("Name %q", t.val)
+	case tokString:
+		return "String"
+	case tokInt, tokFloat:
+		return fmt.Sprintf("Number %s", t.val)
+	}
+	return fmt.Sprintf("%q", t.val)
+}
+
+// syntaxError is raised by the lexer and parser and recovered by parse.
+type syntaxError struct {
+	msg string
+	pos int
+}
+
+// lex splits a document into tokens, dropping whitespace, commas and
+// comments.
+func lex(src string) []token {
+	var toks []token
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+		case strings.HasPrefix(src[i:], "\ufeff"):
+			i += len("\ufeff")
+		case c == '#':
+			for i < len(src) && src[i] != '\n' && src[i] != '\r' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "..."):
+			toks = append(toks, token{tokPunct, "...", i})
+			i += 3
+		case strings.IndexByte("!$&()[]{}:=@|", c) >= 0:
+			toks = append(toks, token{tokPunct, string(c), i})
+			i++
+		case isNameStart(c):
+			start := i
+			for i < len(src) && (isNameStart(src[i]) || isDigit(src[i])) {
+				i++
+			}
+			toks = append(toks, token{tokName, src[start:i], start})
+		case c == '-' || isDigit(c):
+			tok, n := lexNumber(src, i)
+			toks = append(toks, tok)
+			i = n
+		case strings.HasPrefix(src[i:], `"""`):
+			val, n := lexBlockString(src, i)
+			toks = append(toks, token{tokString, val, i})
+			i = n
+		case c == '"':
+			val, n := lexString(src, i)
+			toks = append(toks, token{tokString, val, i})
+			i = n
+		default:
+			r, _ := utf8.DecodeRuneInString(src[i:])
+			panic(syntaxError{fmt.Sprintf("Unexpected character %q.", r), i})
+		}
+	}
+	return append(toks, token{tokEOF, "", len(src)})
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func lexNumber(src string, start int) (token, int) {
+	i := start
+	if src[i] == '-' {
+		i++
+	}
+	digits := func() {
+		n := i
+		for i < len(src) && isDigit(src[i]) {
+			i++
+		}
+		if i == n {
+			panic(syntaxError{"Invalid number, expected digit.", i})
+		}
+	}
+	digits()
+	kind := tokInt
+	if i < len(src) && src[i] == '.' {
+		kind = tokFloat
+		i++
+		digits()
+	}
+	if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+		kind = tokFloat
+		i++
+		if i < len(src) && (src[i] == '+' || src[i] == '-') {
+			i++
+		}
+		digits()
+	}
+	if i < len(src) && (isNameStart(src[i]) || src[i] == '.') {
+		panic(syntaxError{"Invalid number.", i})
+	}
+	return token{kind, src[start:i], start}, i
+}
+
+func lexString(src string, start int) (string, int) {
+	var b strings.Builder
+	i := start + 1
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == '"':
+			return b.String(), i + 1
+		case c == '\n' || c == '\r':
+			panic(syntaxError{"Unterminated string.", i})
+		case c == '\\':
+			if i+1 >= len(src) {
+				panic(syntaxError{"Unterminated string.", i})
+			}
+			switch e := src[i+1]; e {
+			case '"', '\\', '/':
+				b.WriteByte(e)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				if i+6 > len(src) {
+					panic(syntaxError{"Invalid Unicode escape sequence.", i})
+				}
+				n, err := strconv.ParseUint(src[i+2:i+6], 16, 32)
+				if err != nil {
+					panic(syntaxError{"Invalid Unicode escape sequence.", i})
+				}
+				b.WriteRune(rune(n))
+				i += 4
+			default:
+				panic(syntaxError{fmt.Sprintf("Invalid character escape sequence \\%c.", e), i})
+			}
+			i += 2
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	panic(syntaxError{"Unterminated string.", len(src)})
+}
+
+// lexBlockString reads a """ string, removing the common indentation of
+// its lines and the blank lines around it.
+func lexBlockString(src string, start int) (string, int) {
+	i := start + 3
+	for i < len(src) {
+		switch {
+		case strings.HasPrefix(src[i:], `\"""`):
+			i += 4
+		case strings.HasPrefix(src[i:], `"""`):
+			raw := strings.ReplaceAll(src[start+3:i], `\"""`, `"""`)
+			return blockStringValue(raw), i + 3
+		default:
+			i++
+		}
+	}
+	panic(syntaxError{"Unterminated string.", len(src)})
+}
+
+func blockStringValue(raw string) string {
+	lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" {
+			continue
+		}
+		if n := len(line) - len(trimmed); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	if indent > 0 {
+		for i := 1; i < len(lines); i++ {
+			if len(lines[i]) >= indent {
+				lines[i] = lines[i][indent:]
+			} else {
+				lines[i] = strings.TrimLeft(lines[i], " \t")
+			}
+		}
+	}
+	for len(lines) > 0 && strings.TrimLeft(lines[0], " \t") == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimLeft(lines[len(lines)-1], " \t") == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parser is a recursive descent parser over the tokens of a document.
+type parser struct {
+	toks []token
+	i    int
+}
+
+// parse parses a request document. Errors carry the location they were
+// found at.
+func parse(src string) (doc *document, err *Error) {
+	defer func() {
+		if r := recover(); r != nil {
+			se, ok := r.(syntaxError)
+			if !ok {
+				panic(r)
+			}
+			doc, err = nil, errorAt(src, se.pos, "Syntax Error: "+se.msg)
+		}
+	}()
+	p := &parser{toks: lex(src)}
+	return p.document(), nil
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) isPunct(s string) bool {
+	t := p.peek()
+	return t.kind == tokPunct && t.val == s
+}
+
+func (p *parser) skipPunct(s string) bool {
+	if p.isPunct(s) {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) {
+	if !p.skipPunct(s) {
+		p.unexpected(fmt.Sprintf("Expected %q", s))
+	}
+}
+
+func (p *parser) unexpected(expected string) {
+	t := p.peek()
+	panic(syntaxError{fmt.Sprintf("%s, found %s.", expected, t), t.pos})
+}
+
+func (p *parser) name() string {
+	t := p.peek()
+	if t.kind != tokName {
+		p.unexpected("Expected Name")
+	}
+	p.i++
+	return t.val
+}
+
+func (p *parser) document() *document {
+	doc := &document{fragments: map[string]*fragment{}}
+	if p.peek().kind == tokEOF {
+		p.unexpected("Unexpected <EOF>; expected a query")
+	}
+	for p.peek().kind != tokEOF {
+		t := p.peek()
+		switch {
+		case t.kind == tokPunct && t.val == "{":
+			doc.operations = append(doc.operations, &operation{kind: "query", selections: p.selectionSet()})
+		case t.kind == tokName && (t.val == "query" || t.val == "mutation" || t.val == "subscription"):
+			doc.operations = append(doc.operations, p.operation())
+		case t.kind == tokName && t.val == "fragment":
+			f := p.fragmentDefinition()
+			if _, ok := doc.fragments[f.name]; ok {
+				panic(syntaxError{fmt.Sprintf("There can be only one fragment named %q.", f.name), f.position})
+			}
+			doc.fragments[f.name] = f
+		default:
+			p.unexpected("Unexpected token")
+		}
+	}
+	return doc
+}
+
+func (p *parser) operation() *operation {
+	op := &operation{kind: p.next().val}
+	if p.peek().kind == tokName {
+		op.name = p.name()
+	}
+	if p.skipPunct("(") {
+		for !p.skipPunct(")") {
+			pos := p.peek().pos
+			p.expect("$")
+			def := &variableDef{name: p.name(), position: pos}
+			p.expect(":")
+			def.typ = p.typeRef()
+			if p.skipPunct("=") {
+				def.def, def.hasDef = p.value(true), true
+			}
+			p.directives()
+			op.variables = append(op.variables, def)
+		}
+	}
+	p.directives()
+	op.selections = p.selectionSet()
+	return op
+}
+
+func (p *parser) typeRef() string {
+	var typ string
+	if p.skipPunct("[") {
+		typ = "[" + p.typeRef() + "]"
+		p.expect("]")
+	} else {
+		typ = p.name()
+	}
+	if p.skipPunct("!") {
+		typ += "!"
+	}
+	return typ
+}
+
+func (p *parser) fragmentDefinition() *fragment {
+	pos := p.next().pos
+	name := p.name()
+	if name == "on" {
+		panic(syntaxError{`Unexpected Name "on".`, pos})
+	}
+	if t := p.next(); t.kind != tokName || t.val != "on" {
+		panic(syntaxError{fmt.Sprintf(`Expected "on", found %s.`, t), t.pos})
+	}
+	f := &fragment{name: name, typeCondition: p.name(), position: pos}
+	p.directives()
+	f.selections = p.selectionSet()
+	return f
+}
+
+func (p *parser) selectionSet() []selection {
+	p.expect("{")
+	var sels []selection
+	for !p.skipPunct("}") {
+		sels = append(sels, p.selection())
+	}
+	if len(sels) == 0 {
+		p.i--
+		p.unexpected("Expected Name")
+	}
+	return sels
+}
+
+func (p *parser) selection() selection {
+	pos := p.peek().pos
+	if p.skipPunct("...") {
+		t := p.peek()
+		if t.kind == tokName && t.val != "on" {
+			p.i++
+			return &fragmentSpread{name: t.val, directives: p.directives(), position: pos}
+		}
+		f := &inlineFragment{position: pos}
+		if t.kind == tokName {
+			p.i++
+			f.typeCondition = p.name()
+		}
+		f.directives = p.directives()
+		f.selections = p.selectionSet()
+		return f
+	}
+	f := &field{name: p.name(), position: pos}
+	if p.skipPunct(":") {
+		f.alias, f.name = f.name, p.name()
+	}
+	if p.isPunct("(") {
+		f.arguments, f.argOrder = p.arguments()
+	}
+	f.directives = p.directives()
+	if p.isPunct("{") {
+		f.selections = p.selectionSet()
+	}
+	return f
+}
+
+func (p *parser) arguments() (map[string]any, []string) {
+	p.expect("(")
+	args := map[string]any{}
+	var order []string
+	for !p.skipPunct(")") {
+		t := p.peek()
+		name := p.name()
+		if _, ok := args[name]; ok {
+			panic(syntaxError{fmt.Sprintf("There can be only one argument named %q.", name), t.pos})
+		}
+		p.expect(":")
+		args[name] = p.value(false)
+		order = append(order, name)
+	}
+	if len(order) == 0 {
+		p.i--
+		p.unexpected("Expected Name")
+	}
+	return args, order
+}
+
+func (p *parser) directives() []*directive {
+	var dirs []*directive
+	for p.skipPunct("@") {
+		d := &directive{name: p.name()}
+		if p.isPunct("(") {
+			d.arguments, _ = p.arguments()
+		}
+		dirs = append(dirs, d)
+	}
+	return dirs
+}
+
+// value parses an argument value. Constant values, the defaults of
+// variables, can't reference variables.
+func (p *parser) value(constant bool) any {
+	t := p.next()
+	switch t.kind {
+	case tokPunct:
+		switch t.val {
+		case "$":
+			if constant {
+				break
+			}
+			return variable(p.name())
+		case "[":
+			list := []any{}
+			for !p.skipPunct("]") {
+				list = append(list, p.value(constant))
+			}
+			return list
+		case "{":
+			obj := map[string]any{}
+			for !p.skipPunct("}") {
+				name := p.name()
+				p.expect(":")
+				obj[name] = p.value(constant)
+			}
+			return obj
+		}
+	case tokInt:
+		n, err := strconv.ParseInt(t.val, 10, 32)
+		if err != nil {
+			panic(syntaxError{fmt.Sprintf("Int cannot represent non 32-bit signed integer value: %s", t.val), t.pos})
+		}
+		return int(n)
+	case tokFloat:
+		f, err := strconv.ParseFloat(t.val, 64)
+		if err != nil {
+			panic(syntaxError{fmt.Sprintf("Invalid number %s.", t.val), t.pos})
+		}
+		return f
+	case tokString:
+		return t.val
+	case tokName:
+		switch t.val {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null":
+			return nil
+		}
+		return enumValue(t.val)
+	}
+	p.i--
+	p.unexpected("Unexpected token")
+	return nil
+}
@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/compose"
+	"github.com/cfilipov/dockge/internal/docker"
+	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// adoptedSuffix is added to the names of the containers an adopted stack
+// replaces, while its own are deployed.
+const adoptedSuffix = "-pre-adopt"
+
+// adoptRequest is the argument of adoptStack.
+type adoptRequest struct {
+	Project    string   `json:"project"`    // external stack to adopt
+	Containers []string `json:"containers"` // or standalone containers
+	Name       string   `json:"name"`       // stack to create, "" for the project name
+	Preview    bool     `json:"preview"`    // only return the compose file
+	Recreate   bool     `json:"recreate"`   // replace the containers with the stack's
+}
+
+// handleAdoptStack reconstructs a compose file from the containers of an
+// external stack, or from standalone containers, and writes it into the
+// stacks directory so Dockge manages them. With recreate, the containers
+// are then replaced by the stack's; that requires sudo.
+// Args: {project, containers, name, preview, recreate}.
+func (app *App) handleAdoptStack(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	var req adoptRequest
+	argObject(parseArgs(msg), 0, &req)
+	reject := func(text string) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: text})
+		}
+	}
+	if (req.Project == "") == (len(req.Containers) == 0) {
+		reject("Give either a stack or containers to adopt")
+		return
+	}
+	if req.Project != "" {
+		if err := stack.ValidateStackName(req.Project); err != nil {
+			reject(err.Error())
+			return
+		}
+		if app.isStackManaged(req.Project) {
+			reject("Stack " + req.Project + " is already managed by Dockge")
+			return
+		}
+	}
+	if !req.Preview && req.Recreate && !app.requireSudo(c, msg) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	refs, err := app.adoptedContainers(ctx, req)
+	if err != nil {
+		cancel()
+		reject(err.Error())
+		return
+	}
+	sources := make([]compose.AdoptSource, 0, len(refs))
+	for _, ref := range refs {
+		src, err := app.adoptSource(ctx, ref.ID)
+		if err != nil {
+			cancel()
+			slog.Warn("adopt: inspect", "container", ref.Name, "err", err)
+			reject(err.Error())
+			return
+		}
+		sources = append(sources, src)
+	}
+	cancel()
+	adoption, err := compose.AdoptContainers(req.Project, sources)
+	if err != nil {
+		reject(err.Error())
+		return
+	}
+	if req.Preview {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, struct {
+				OK bool `json:"ok"`
+				*compose.Adoption
+			}{OK: true, Adoption: adoption})
+		}
+		return
+	}
+
+	if req.Name == "" {
+		req.Name = req.Project
+		if req.Name == "" {
+			req.Name = adoption.Services[0]
+		}
+	}
+	if app.rejectNewStackName(c, msg, req.Name) {
+		return
+	}
+	s := &stack.Stack{Name: req.Name, ComposeYAML: adoption.YAML}
+	if user, ok := app.approvalRequired(c); ok {
+		if req.Recreate {
+			reject("Recreating the containers needs an admin while changes require approval")
+			return
+		}
+		app.submitPendingChange(c, msg, user, models.PendingActionSave, s, "")
+		return
+	}
+
+	app.StackLocks.Lock(req.Name)
+	if err := s.SaveToDisk(app.StacksDir); err != nil {
+		app.StackLocks.Unlock(req.Name)
+		slog.Error("adopt: save stack", "err", err, "stack", req.Name)
+		reject(err.Error())
+		return
+	}
+	app.handleComposeYAMLSave(req.Name, adoption.YAML)
+	by := ""
+	if user := app.currentUser(c); user != nil {
+		by = user.Username
+	}
+	slog.Info("stack adopted", "stack", req.Name, "project", req.Project, "containers", len(refs), "recreate", req.Recreate, "by", by)
+
+	ack := func(err error) {
+		if msg.ID == nil {
+			return
+		}
+		if err != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+			return
+		}
+		ws.SendAck(c, *msg.ID, struct {
+			OK       bool     `json:"ok"`
+			Msg      string   `json:"msg"`
+			MsgI18n  bool     `json:"msgi18n"`
+			Name     string   `json:"name"`
+			Warnings []string `json:"warnings"`
+		}{OK: true, Msg: "stackAdopted", MsgI18n: true, Name: req.Name, Warnings: adoption.Warnings})
+	}
+	if !req.Recreate {
+		app.StackLocks.Unlock(req.Name)
+		app.TriggerStacksBroadcast()
+		ack(nil)
+		return
+	}
+	go func() {
+		defer app.StackLocks.Unlock(req.Name)
+		// Compose takes over the containers of its own project; others
+		// would clash with the stack's names and ports
+		if req.Project == req.Name {
+			refs = nil
+		}
+		err := app.replaceAdoptedContainers(req.Name, refs)
+		app.TriggerStacksBroadcast()
+		ack(err)
+	}()
+}
+
+// adoptedContainers lists the containers an adopt request refers to: all of
+// the project's, or the standalone containers given.
+func (app *App) adoptedContainers(ctx context.Context, req adoptRequest) ([]containerRef, error) {
+	var refs []containerRef
+	if req.Project != "" {
+		list, err := app.Docker.ContainerList(ctx, true, req.Project)
+		if err != nil {
+			return nil, err
+		}
+		for _, ctr := range list {
+			refs = append(refs, containerRef{ID: ctr.ID, Name: ctr.Name, Stack: req.Project})
+		}
+		if len(refs) == 0 {
+			return nil, fmt.Errorf("stack %s has no containers", req.Project)
+		}
+		return refs, nil
+	}
+	for _, name := range req.Containers {
+		ref, err := app.lookupContainer(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if ref.Stack != "" {
+			return nil, fmt.Errorf("%s belongs to stack %s; adopt the stack instead", ref.Name, ref.Stack)
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// adoptSource inspects a container and its image. Without the image, its
+// defaults end up in the service too, which is harmless.
+func (app *App) adoptSource(ctx context.Context, id string) (compose.AdoptSource, error) {
+	raw, err := app.Docker.ContainerInspect(ctx, id)
+	if err != nil {
+		return compose.AdoptSource{}, err
+	}
+	src := compose.AdoptSource{Inspect: raw}
+	var inspect struct {
+		Image string `json:"Image"` // image ID
+	}
+	if err := json.Unmarshal(raw, &inspect); err != nil {
+		return src, fmt.Errorf("decode container inspect: %w", err)
+	}
+	detail, err := app.Docker.ImageInspectDetail(ctx, inspect.Image)
+	if err != nil {
+		slog.Warn("adopt: inspect image", "image", inspect.Image, "err", err)
+		return src, nil
+	}
+	src.Image = imageDefaults(detail)
+	return src, nil
+}
+
+func imageDefaults(detail *docker.ImageDetail) compose.ImageDefaults {
+	return compose.ImageDefaults{
+		Env:        detail.Config.Env,
+		Cmd:        detail.Config.Cmd,
+		Entrypoint: detail.Config.Entrypoint,
+		User:       detail.Config.User,
+		WorkingDir: detail.WorkingDir,
+		Labels:     detail.Config.Labels,
+		Volumes:    detail.Config.Volumes,
+	}
+}
+
+// replaceAdoptedContainers deploys an adopted stack in place of the
+// containers it was made from. They are stopped and renamed out of the way
+// first, removed once the stack is up, and put back if it fails to deploy.
+// The caller holds the stack lock.
+func (app *App) replaceAdoptedContainers(stackName string, old []containerRef) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	// The deploy may outlast ctx; what follows it gets a new one
+	freshCtx := func() (context.Context, context.CancelFunc) {
+		return context.WithTimeout(context.Background(), 2*time.Minute)
+	}
+
+	// Stopped containers, and whether they were renamed yet
+	var moved []containerRef
+	renamed := map[string]bool{}
+	restore := func() {
+		ctx, cancel := freshCtx()
+		defer cancel()
+		for _, ref := range moved {
+			if renamed[ref.ID] {
+				if err := app.Docker.ContainerRename(ctx, ref.ID, ref.Name); err != nil {
+					slog.Error("adopt: restore container name", "container", ref.Name, "err", err)
+					continue
+				}
+			}
+			if err := app.Docker.ContainerStart(ctx, ref.ID); err != nil {
+				slog.Error("adopt: restart container", "container", ref.Name, "err", err)
+			}
+		}
+	}
+	for _, ref := range old {
+		if err := app.Docker.ContainerStop(ctx, ref.ID); err != nil {
+			restore()
+			return fmt.Errorf("stop %s: %w", ref.Name, err)
+		}
+		moved = append(moved, ref)
+		if err := app.Docker.ContainerRename(ctx, ref.ID, ref.Name+adoptedSuffix); err != nil {
+			restore()
+			return fmt.Errorf("rename %s: %w", ref.Name, err)
+		}
+		renamed[ref.ID] = true
+	}
+
+	if err := app.runDeployWithValidation(stackName, "Adopted into Dockge", false, ""); err != nil {
+		slog.Warn("adopt: deploy", "stack", stackName, "err", err)
+		restore()
+		return fmt.Errorf("deploy %s: %w; the original containers were restored", stackName, err)
+	}
+	ctx, cancel = freshCtx()
+	defer cancel()
+	for _, ref := range moved {
+		if err := app.Docker.ContainerRemove(ctx, ref.ID, true, false); err != nil {
+			slog.Warn("adopt: remove replaced container", "container", ref.Name+adoptedSuffix, "err", err)
+		}
+	}
+	return nil
+}
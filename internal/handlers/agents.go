@@ -0,0 +1,430 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/agent"
+	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// agentSessionUID is the user ID of connections a controller opens on this
+// instance in agent mode. The controller authorizes its users itself.
+const agentSessionUID = -1
+
+// agentLocalEvents concern the controller's own sessions, users and
+// settings, and are never forwarded to an agent.
+var agentLocalEvents = map[string]bool{
+	"login":                        true,
+	"loginByToken":                 true,
+	"logout":                       true,
+	"setup":                        true,
+	"needSetup":                    true,
+	"changePassword":               true,
+	"getTurnstileSiteKey":          true,
+	"sudo":                         true,
+	"getSudoStatus":                true,
+	"prepare2FA":                   true,
+	"save2FA":                      true,
+	"disable2FA":                   true,
+	"verifyToken":                  true,
+	"twoFAStatus":                  true,
+	"getUserList":                  true,
+	"addUser":                      true,
+	"setUserRole":                  true,
+	"getSettings":                  true,
+	"setSettings":                  true,
+	"disconnectOtherSocketClients": true,
+	"agent":                        true,
+	"agentConnect":                 true,
+	"getAgentList":                 true,
+	"addAgent":                     true,
+	"removeAgent":                  true,
+	"rotateAgentKey":               true,
+}
+
+// RegisterAgentHandlers registers agent management and the "agent" event
+// that proxies a request to an agent.
+func RegisterAgentHandlers(app *App) {
+	app.agentHub = agent.NewHub(func(string, bool) {
+		app.broadcastAgentList()
+	})
+	app.WS.Handle("getAgentList", app.handleGetAgentList)
+	app.WS.Handle("addAgent", app.handleAddAgent)
+	app.WS.Handle("removeAgent", app.handleRemoveAgent)
+	app.WS.Handle("rotateAgentKey", app.handleRotateAgentKey)
+	app.WS.Handle("agentConnect", app.handleAgentConnect)
+	app.WS.Handle("agent", app.handleAgent)
+}
+
+// StartAgent runs this instance in agent mode: it stays connected to the
+// controller, which opens a connection here for each of its clients.
+func (app *App) StartAgent(ctx context.Context, cfg agent.Config) {
+	slog.Info("agent mode", "controller", cfg.ControllerURL)
+	go agent.Run(ctx, cfg, app.WS, func(c *ws.Conn, user string) {
+		app.agentSessions.Store(c.ID(), user)
+		c.SetUser(agentSessionUID)
+		app.AfterLogin(c)
+	})
+}
+
+// agentSessionUser returns the controller user of a connection opened over
+// the agent link, or nil.
+func (app *App) agentSessionUser(c *ws.Conn) *models.User {
+	user, ok := app.agentSessions.Load(c.ID())
+	if !ok {
+		return nil
+	}
+	return &models.User{
+		ID:       agentSessionUID,
+		Username: user.(string) + "@controller",
+		Role:     models.RoleAdmin,
+		Active:   true,
+	}
+}
+
+// DropAgentChannels cleans up after a disconnected client: its channels to
+// agents (as a controller) and its agent session (as an agent).
+func (app *App) DropAgentChannels(c *ws.Conn) {
+	if app.agentHub != nil {
+		app.agentHub.Drop(c)
+	}
+	app.agentSessions.Delete(c.ID())
+}
+
+// ServeAgentLink accepts the WebSocket link of an agent, which proves it
+// holds the token issued when it was added (or its rotated successor)
+// without sending it.
+func (app *App) ServeAgentLink(w http.ResponseWriter, r *http.Request) {
+	if app.Agents == nil || app.agentHub == nil {
+		http.NotFound(w, r)
+		return
+	}
+	hello, err := agent.ReadHello(r)
+	if err != nil {
+		slog.Warn("agent link: bad handshake", "remote", r.RemoteAddr)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	a, key, err := app.Agents.Identify(hello.Verify)
+	if err != nil {
+		slog.Error("agent link: authenticate", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if a == nil {
+		slog.Warn("agent link: invalid token", "remote", r.RemoteAddr)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	conn, err := app.agentHub.Accept(w, r, hello, key)
+	if err != nil {
+		slog.Warn("agent link: accept", "err", err, "endpoint", a.Endpoint)
+		return
+	}
+	if err := app.Agents.Confirm(a.Endpoint, key); err != nil {
+		slog.Warn("agent link: confirm rotation", "err", err)
+	}
+	if err := app.Agents.Touch(a.Endpoint); err != nil {
+		slog.Warn("agent link: touch", "err", err)
+	}
+	app.agentHub.Serve(r.Context(), a.Endpoint, conn)
+	if err := app.Agents.Touch(a.Endpoint); err != nil {
+		slog.Warn("agent link: touch", "err", err)
+	}
+	app.broadcastAgentList()
+}
+
+// agentInfo is the client-facing view of an agent (no token hash).
+type agentInfo struct {
+	Endpoint  string `json:"endpoint"`
+	Name      string `json:"name"`
+	CreatedBy string `json:"createdBy,omitempty"`
+	CreatedAt int64  `json:"createdAt"`
+	LastSeen  int64  `json:"lastSeen"`
+	Online    bool   `json:"online"`
+	RotatedAt int64  `json:"rotatedAt,omitempty"`
+	// RotationPending is set until the agent connects with its new token.
+	RotationPending bool `json:"rotationPending,omitempty"`
+}
+
+func (app *App) agentList() ([]agentInfo, error) {
+	agents, err := app.Agents.List()
+	if err != nil {
+		return nil, err
+	}
+	online := make(map[string]bool)
+	for _, endpoint := range app.agentHub.Endpoints() {
+		online[endpoint] = true
+	}
+	list := make([]agentInfo, 0, len(agents))
+	for _, a := range agents {
+		list = append(list, agentInfo{
+			Endpoint:  a.Endpoint,
+			Name:      a.Name,
+			CreatedBy: a.CreatedBy,
+			CreatedAt: a.CreatedAt,
+			LastSeen:  a.LastSeen,
+			Online:    online[a.Endpoint],
+			RotatedAt: a.RotatedAt,
+
+			RotationPending: a.PrevTokenHash != "",
+		})
+	}
+	return list, nil
+}
+
+// broadcastAgentList pushes the agents and their status to all clients.
+func (app *App) broadcastAgentList() {
+	if app.Agents == nil {
+		return
+	}
+	list, err := app.agentList()
+	if err != nil {
+		slog.Warn("broadcast agent list", "err", err)
+		return
+	}
+	ws.BroadcastAuthenticated(app.WS, "agentList", list)
+}
+
+func (app *App) handleGetAgentList(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	if app.Agents == nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Agents are not available"})
+		}
+		return
+	}
+	list, err := app.agentList()
+	if err != nil {
+		slog.Error("get agent list", "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK     bool        `json:"ok"`
+			Agents []agentInfo `json:"agents"`
+		}{OK: true, Agents: list})
+	}
+}
+
+// handleAddAgent registers an agent and returns its token, which is shown
+// once. Admin only and requires sudo. Args: {endpoint, name}.
+func (app *App) handleAddAgent(c *ws.Conn, msg *ws.ClientMessage) {
+	admin := app.checkAdmin(c, msg)
+	if admin == nil || !app.requireSudo(c, msg) {
+		return
+	}
+	args := parseArgs(msg)
+	var data struct {
+		Endpoint string `json:"endpoint"`
+		Name     string `json:"name"`
+	}
+	argObject(args, 0, &data)
+	data.Endpoint = strings.TrimSpace(data.Endpoint)
+	data.Name = strings.TrimSpace(data.Name)
+	if !validAgentEndpoint(data.Endpoint) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Endpoint must be 1-64 lowercase letters, digits, '-' or '_'"})
+		}
+		return
+	}
+	if data.Name == "" {
+		data.Name = data.Endpoint
+	}
+
+	token, err := app.Agents.Create(models.Agent{Endpoint: data.Endpoint, Name: data.Name, CreatedBy: admin.Username})
+	if err != nil {
+		text := "Internal error"
+		if errors.Is(err, models.ErrAgentExists) {
+			text = "An agent with this endpoint already exists"
+		} else {
+			slog.Error("add agent", "err", err)
+		}
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: text})
+		}
+		return
+	}
+	slog.Info("agent added", "endpoint", data.Endpoint, "by", admin.Username)
+	app.broadcastAgentList()
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK       bool   `json:"ok"`
+			Msg      string `json:"msg"`
+			MsgI18n  bool   `json:"msgi18n"`
+			Endpoint string `json:"endpoint"`
+			Token    string `json:"token"`
+		}{OK: true, Msg: "agentAddedSuccessfully", MsgI18n: true, Endpoint: data.Endpoint, Token: token})
+	}
+}
+
+// handleRemoveAgent deletes an agent and drops its link. Admin only and
+// requires sudo. Args: endpoint.
+func (app *App) handleRemoveAgent(c *ws.Conn, msg *ws.ClientMessage) {
+	admin := app.checkAdmin(c, msg)
+	if admin == nil || !app.requireSudo(c, msg) {
+		return
+	}
+	endpoint := argString(parseArgs(msg), 0)
+	if err := app.Agents.Delete(endpoint); err != nil {
+		slog.Error("remove agent", "err", err, "endpoint", endpoint)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+	app.agentHub.Disconnect(endpoint)
+	slog.Info("agent removed", "endpoint", endpoint, "by", admin.Username)
+	app.broadcastAgentList()
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "agentRemovedSuccessfully"})
+	}
+}
+
+// agentRekeyTimeout bounds waiting for an agent to store its new token.
+const agentRekeyTimeout = 15 * time.Second
+
+// handleRotateAgentKey issues a new token to an online agent over its link
+// and reconnects it with the token. The old token stays valid until the
+// agent has connected with the new one. Admin only and requires sudo.
+// Args: endpoint.
+func (app *App) handleRotateAgentKey(c *ws.Conn, msg *ws.ClientMessage) {
+	endpoint := argString(parseArgs(msg), 0)
+	admin, link := app.agentLink(c, msg, endpoint)
+	if link == nil || !app.requireSudo(c, msg) {
+		return
+	}
+	token, err := app.Agents.Rotate(endpoint, link.Key())
+	if err != nil {
+		slog.Error("rotate agent key", "err", err, "endpoint", endpoint)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), agentRekeyTimeout)
+	err = link.Rekey(ctx, token)
+	cancel()
+	if err != nil {
+		// Both tokens stay valid, so the agent works with whichever it has
+		slog.Warn("rotate agent key", "err", err, "endpoint", endpoint)
+		app.broadcastAgentList()
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Agent didn't take the new key: " + err.Error()})
+		}
+		return
+	}
+	slog.Info("agent key rotated", "endpoint", endpoint, "by", admin.Username)
+	// The agent reconnects with the new token, which completes the rotation
+	link.Close()
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "agentKeyRotated"})
+	}
+}
+
+// agentLink returns the link of an online agent for an admin's request, or
+// sends an error ack and returns nil.
+func (app *App) agentLink(c *ws.Conn, msg *ws.ClientMessage, endpoint string) (*models.User, *agent.Link) {
+	user := app.checkAdmin(c, msg)
+	if user == nil {
+		return nil, nil
+	}
+	var link *agent.Link
+	if app.agentHub != nil {
+		link = app.agentHub.Link(endpoint)
+	}
+	if link == nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Agent " + endpoint + " is offline"})
+		}
+		return nil, nil
+	}
+	return user, link
+}
+
+// handleAgentConnect opens the client's session on an agent, which then
+// pushes its stacks and resources as "agent" events. Args: endpoint.
+func (app *App) handleAgentConnect(c *ws.Conn, msg *ws.ClientMessage) {
+	endpoint := argString(parseArgs(msg), 0)
+	user, link := app.agentLink(c, msg, endpoint)
+	if link == nil {
+		return
+	}
+	if err := link.Open(c, user.Username); err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true})
+	}
+}
+
+// handleAgent forwards a request to an agent, which acks it directly.
+// Admin only; the agent runs it as the admin. Args: endpoint, event,
+// event args...
+func (app *App) handleAgent(c *ws.Conn, msg *ws.ClientMessage) {
+	args := parseArgs(msg)
+	endpoint := argString(args, 0)
+	event := argString(args, 1)
+	if event == "" || agentLocalEvents[event] {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Event can't be sent to an agent: " + event})
+		}
+		return
+	}
+	user, link := app.agentLink(c, msg, endpoint)
+	if link == nil {
+		return
+	}
+
+	inner := []json.RawMessage{}
+	if len(args) > 2 {
+		inner = args[2:]
+	}
+	innerArgs, err := json.Marshal(inner)
+	if err != nil {
+		return
+	}
+	sudo := time.Until(c.SudoUntil())
+	if app.NoAuth {
+		sudo = sudoDuration
+	}
+	err = link.Forward(c, user.Username, sudo, &ws.ClientMessage{ID: msg.ID, Event: event, Args: innerArgs})
+	if err != nil {
+		slog.Warn("forward to agent", "err", err, "endpoint", endpoint, "event", event)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+	}
+}
+
+// validAgentEndpoint reports whether s is usable as an agent endpoint.
+func validAgentEndpoint(s string) bool {
+	if s == "" || len(s) > 64 {
+		return false
+	}
+	for _, r := range s {
+		if !((r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
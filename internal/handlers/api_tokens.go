@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// apiTokenInfo is an API token as listed to admins, without its hash.
+type apiTokenInfo struct {
+	ID        int      `json:"id"`
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	Stacks    []string `json:"stacks"`
+	CreatedBy string   `json:"createdBy,omitempty"`
+	CreatedAt int64    `json:"createdAt"`
+	LastUsed  int64    `json:"lastUsed"`
+}
+
+func RegisterAPITokenHandlers(app *App) {
+	app.WS.Handle("getAPITokenList", app.handleGetAPITokenList)
+	app.WS.Handle("createAPIToken", app.handleCreateAPIToken)
+	app.WS.Handle("deleteAPIToken", app.handleDeleteAPIToken)
+}
+
+// handleGetAPITokenList lists the GraphQL API tokens. Admin only.
+func (app *App) handleGetAPITokenList(c *ws.Conn, msg *ws.ClientMessage) {
+	if app.checkAdmin(c, msg) == nil {
+		return
+	}
+	tokens, err := app.APITokens.List()
+	if err != nil {
+		slog.Error("get api token list", "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+	list := make([]apiTokenInfo, 0, len(tokens))
+	for _, t := range tokens {
+		list = append(list, apiTokenInfo{
+			ID:        t.ID,
+			Name:      t.Name,
+			Scopes:    t.Scopes,
+			Stacks:    append([]string{}, t.Stacks...),
+			CreatedBy: t.CreatedBy,
+			CreatedAt: t.CreatedAt,
+			LastUsed:  t.LastUsed,
+		})
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK     bool           `json:"ok"`
+			Tokens []apiTokenInfo `json:"tokens"`
+		}{OK: true, Tokens: list})
+	}
+}
+
+// handleCreateAPIToken issues an API token and returns it, which is shown
+// once. Admin only and requires sudo.
+// Args: {name, scopes, stacks}; empty stacks = all stacks.
+func (app *App) handleCreateAPIToken(c *ws.Conn, msg *ws.ClientMessage) {
+	admin := app.checkAdmin(c, msg)
+	if admin == nil || !app.requireSudo(c, msg) {
+		return
+	}
+	var data struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+		Stacks []string `json:"stacks"`
+	}
+	argObject(parseArgs(msg), 0, &data)
+	data.Name = strings.TrimSpace(data.Name)
+	reject := func(text string) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: text})
+		}
+	}
+	if data.Name == "" || len(data.Name) > 100 {
+		reject("Token name must be 1-100 characters")
+		return
+	}
+	if len(data.Scopes) == 0 {
+		reject("Select what the token can read")
+		return
+	}
+	for _, s := range data.Scopes {
+		if !slices.Contains(models.APITokenScopes, s) {
+			reject("Unknown scope " + s)
+			return
+		}
+	}
+	scopes := []string{}
+	for _, s := range models.APITokenScopes {
+		if slices.Contains(data.Scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	stacks := []string{}
+	for _, name := range data.Stacks {
+		name = strings.TrimSpace(name)
+		if name == "" || slices.Contains(stacks, name) {
+			continue
+		}
+		if err := stack.ValidateStackName(name); err != nil {
+			reject(err.Error())
+			return
+		}
+		stacks = append(stacks, name)
+	}
+
+	tok := &models.APIToken{Name: data.Name, Scopes: scopes, Stacks: stacks, CreatedBy: admin.Username}
+	secret, err := app.APITokens.Create(tok)
+	if err != nil {
+		slog.Error("create api token", "err", err)
+		reject("Internal error")
+		return
+	}
+	slog.Info("api token created", "id", tok.ID, "name", tok.Name, "scopes", scopes, "by", admin.Username)
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK      bool   `json:"ok"`
+			Msg     string `json:"msg"`
+			MsgI18n bool   `json:"msgi18n"`
+			ID      int    `json:"id"`
+			Token   string `json:"token"`
+		}{OK: true, Msg: "apiTokenCreated", MsgI18n: true, ID: tok.ID, Token: secret})
+	}
+}
+
+// handleDeleteAPIToken revokes an API token. Admin only and requires sudo.
+// Args: token ID.
+func (app *App) handleDeleteAPIToken(c *ws.Conn, msg *ws.ClientMessage) {
+	admin := app.checkAdmin(c, msg)
+	if admin == nil || !app.requireSudo(c, msg) {
+		return
+	}
+	id := argInt(parseArgs(msg), 0)
+	if err := app.APITokens.Delete(id); err != nil {
+		slog.Error("delete api token", "err", err, "id", id)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+	slog.Info("api token deleted", "id", id, "by", admin.Username)
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: true, Msg: "apiTokenDeleted", MsgI18n: true})
+	}
+}
@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+
+	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// settingRequireApproval is the settings key that enables the two-person
+// rule: when "1", saves and deploys by operators become pending changes that
+// an admin must approve.
+const settingRequireApproval = "requireApproval"
+
+// RegisterApprovalHandlers registers handlers for reviewing pending changes.
+func RegisterApprovalHandlers(app *App) {
+	app.WS.Handle("getPendingChangeList", app.handleGetPendingChangeList)
+	app.WS.Handle("approvePendingChange", app.handleApprovePendingChange)
+	app.WS.Handle("rejectPendingChange", app.handleRejectPendingChange)
+}
+
+// approvalRequired reports whether a save/deploy from this connection must
+// go through approval. Admins never need approval.
+func (app *App) approvalRequired(c *ws.Conn) (*models.User, bool) {
+	if app.PendingChanges == nil {
+		return nil, false
+	}
+	if v, _ := app.Settings.Get(settingRequireApproval); v != "1" {
+		return nil, false
+	}
+	user := app.currentUser(c)
+	if user == nil || user.IsAdmin() {
+		return user, false
+	}
+	return user, true
+}
+
+// submitPendingChange records s as a pending change instead of writing it to
+// disk, notifies connected clients, and acks the request with the change ID.
+// note is the deploy note, kept for the operation history once approved.
+func (app *App) submitPendingChange(c *ws.Conn, msg *ws.ClientMessage, user *models.User, action string, s *stack.Stack, note string) {
+	current := &stack.Stack{Name: s.Name}
+	current.LoadFromDisk(app.StacksDir)
+
+	pc := &models.PendingChange{
+		StackName:           s.Name,
+		Action:              action,
+		RequestedBy:         user.Username,
+		RequesterID:         user.ID,
+		ComposeYAML:         s.ComposeYAML,
+		ComposeENV:          s.ComposeENV,
+		ComposeOverrideYAML: s.ComposeOverrideYAML,
+		BaseHash:            stackFilesHash(current),
+		Diff:                stackFilesDiff(current, s),
+		Note:                note,
+	}
+	if err := app.PendingChanges.Create(pc); err != nil {
+		slog.Error("create pending change", "err", err, "stack", s.Name)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Failed to submit change for approval"})
+		}
+		return
+	}
+	slog.Info("pending change submitted", "id", pc.ID, "stack", s.Name, "action", action, "by", user.Username)
+
+	ws.BroadcastAuthenticated(app.WS, "pendingChangeRequested", pendingChangeSummary(pc))
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK       bool   `json:"ok"`
+			Msg      string `json:"msg"`
+			Pending  bool   `json:"pending"`
+			ChangeID int    `json:"changeID"`
+		}{
+			OK:       true,
+			Msg:      "Submitted for approval",
+			Pending:  true,
+			ChangeID: pc.ID,
+		})
+	}
+}
+
+// pendingChangeEvent is the push payload for pending change notifications.
+type pendingChangeEvent struct {
+	ID          int    `json:"id"`
+	StackName   string `json:"stackName"`
+	Action      string `json:"action"`
+	RequestedBy string `json:"requestedBy"`
+	Status      string `json:"status"`
+	ReviewedBy  string `json:"reviewedBy,omitempty"`
+}
+
+func pendingChangeSummary(pc *models.PendingChange) pendingChangeEvent {
+	return pendingChangeEvent{
+		ID:          pc.ID,
+		StackName:   pc.StackName,
+		Action:      pc.Action,
+		RequestedBy: pc.RequestedBy,
+		Status:      pc.Status,
+		ReviewedBy:  pc.ReviewedBy,
+	}
+}
+
+// stackFilesHash fingerprints a stack's compose, .env, and override files.
+func stackFilesHash(s *stack.Stack) string {
+	h := sha256.New()
+	h.Write([]byte(s.ComposeYAML))
+	h.Write([]byte{0})
+	h.Write([]byte(s.ComposeENV))
+	h.Write([]byte{0})
+	h.Write([]byte(s.ComposeOverrideYAML))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// stackFilesDiff returns a unified diff of all stack files from current to
+// proposed.
+func stackFilesDiff(current, proposed *stack.Stack) string {
+	composeName := current.ComposeFileName
+	if composeName == "" {
+		composeName = "compose.yaml"
+	}
+	overrideName := current.ComposeOverrideFileName
+	if overrideName == "" {
+		overrideName = "compose.override.yaml"
+	}
+	return stack.UnifiedDiff(composeName, current.ComposeYAML, proposed.ComposeYAML) +
+		stack.UnifiedDiff(".env", current.ComposeENV, proposed.ComposeENV) +
+		stack.UnifiedDiff(overrideName, current.ComposeOverrideYAML, proposed.ComposeOverrideYAML)
+}
+
+// handleGetPendingChangeList lists pending changes, all or those with the
+// given status. Secret .env values are masked for users masksEnvSecrets
+// applies to. Args: status ("" = all).
+func (app *App) handleGetPendingChangeList(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	if app.PendingChanges == nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Approvals are not available"})
+		}
+		return
+	}
+	args := parseArgs(msg)
+	status := argString(args, 0) // "" = all
+
+	changes, err := app.PendingChanges.List(status)
+	if err != nil {
+		slog.Error("list pending changes", "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+	if app.masksEnvSecrets(c) {
+		for i := range changes {
+			changes[i].ComposeENV = stack.MaskDotEnv(changes[i].ComposeENV)
+			changes[i].Diff = stack.MaskDotEnvDiff(changes[i].Diff)
+		}
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK      bool                   `json:"ok"`
+			Changes []models.PendingChange `json:"changes"`
+		}{
+			OK:      true,
+			Changes: changes,
+		})
+	}
+}
+
+func (app *App) handleApprovePendingChange(c *ws.Conn, msg *ws.ClientMessage) {
+	admin := app.checkAdmin(c, msg)
+	if admin == nil {
+		return
+	}
+	pc, ok := app.reviewablePendingChange(c, msg, admin)
+	if !ok {
+		return
+	}
+
+	if pc.Action == models.PendingActionDeploy && app.rejectArchived(c, msg, pc.StackName) {
+		return
+	}
+
+	app.StackLocks.Lock(pc.StackName)
+
+	// Refuse to apply on top of edits made after the change was requested.
+	current := &stack.Stack{Name: pc.StackName}
+	current.LoadFromDisk(app.StacksDir)
+	if stackFilesHash(current) != pc.BaseHash {
+		app.StackLocks.Unlock(pc.StackName)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Stack was modified after this change was requested. Reject it and ask for a new one."})
+		}
+		return
+	}
+
+	resolved, err := app.PendingChanges.Resolve(pc.ID, models.PendingStatusApproved, admin.Username, "")
+	if err != nil {
+		app.StackLocks.Unlock(pc.StackName)
+		app.sendResolveError(c, msg, err)
+		return
+	}
+
+	s := &stack.Stack{
+		Name:                    pc.StackName,
+		ComposeYAML:             pc.ComposeYAML,
+		ComposeENV:              pc.ComposeENV,
+		ComposeOverrideYAML:     pc.ComposeOverrideYAML,
+		ComposeFileName:         current.ComposeFileName,
+		ComposeOverrideFileName: current.ComposeOverrideFileName,
+	}
+	if err := s.SaveToDisk(app.StacksDir); err != nil {
+		app.StackLocks.Unlock(pc.StackName)
+		slog.Error("apply pending change", "err", err, "id", pc.ID, "stack", pc.StackName)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	app.handleComposeYAMLSave(pc.StackName, pc.ComposeYAML)
+	slog.Info("pending change approved", "id", pc.ID, "stack", pc.StackName, "by", admin.Username)
+
+	ws.BroadcastAuthenticated(app.WS, "pendingChangeResolved", pendingChangeSummary(resolved))
+
+	if pc.Action == models.PendingActionDeploy {
+		go func() {
+			defer app.StackLocks.Unlock(pc.StackName)
+			app.runDeployWithValidation(pc.StackName, pc.Note, false, "")
+		}()
+	} else {
+		app.StackLocks.Unlock(pc.StackName)
+	}
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Approved"})
+	}
+}
+
+func (app *App) handleRejectPendingChange(c *ws.Conn, msg *ws.ClientMessage) {
+	admin := app.checkAdmin(c, msg)
+	if admin == nil {
+		return
+	}
+	pc, ok := app.reviewablePendingChange(c, msg, admin)
+	if !ok {
+		return
+	}
+	args := parseArgs(msg)
+	reason := argString(args, 1)
+
+	resolved, err := app.PendingChanges.Resolve(pc.ID, models.PendingStatusRejected, admin.Username, reason)
+	if err != nil {
+		app.sendResolveError(c, msg, err)
+		return
+	}
+	slog.Info("pending change rejected", "id", pc.ID, "stack", pc.StackName, "by", admin.Username)
+
+	ws.BroadcastAuthenticated(app.WS, "pendingChangeResolved", pendingChangeSummary(resolved))
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Rejected"})
+	}
+}
+
+// reviewablePendingChange loads the change named by args[0] and checks that
+// admin may review it. Sends an error ack and returns false otherwise.
+func (app *App) reviewablePendingChange(c *ws.Conn, msg *ws.ClientMessage, admin *models.User) (*models.PendingChange, bool) {
+	fail := func(text string) (*models.PendingChange, bool) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: text})
+		}
+		return nil, false
+	}
+	if app.PendingChanges == nil {
+		return fail("Approvals are not available")
+	}
+	args := parseArgs(msg)
+	id := argInt(args, 0)
+	if id == 0 {
+		return fail("Change ID required")
+	}
+	pc, err := app.PendingChanges.Get(id)
+	if err != nil {
+		slog.Error("get pending change", "err", err, "id", id)
+		return fail("Internal error")
+	}
+	if pc == nil {
+		return fail("Change not found")
+	}
+	if pc.Status != models.PendingStatusPending {
+		return fail(models.ErrChangeNotPending.Error())
+	}
+	// Two-person rule: the requester can't review their own change.
+	if pc.RequesterID == admin.ID {
+		return fail("You cannot review your own change")
+	}
+	return pc, true
+}
+
+func (app *App) sendResolveError(c *ws.Conn, msg *ws.ClientMessage, err error) {
+	text := "Internal error"
+	if errors.Is(err, models.ErrChangeNotPending) {
+		text = err.Error()
+	} else {
+		slog.Error("resolve pending change", "err", err)
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: text})
+	}
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"log/slog"
+
+	"github.com/cfilipov/dockge/internal/compose"
+	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// RegisterStackArchiveHandlers registers the stack archive handlers.
+func RegisterStackArchiveHandlers(app *App) {
+	app.WS.Handle("archiveStack", app.handleArchiveStack)
+	app.WS.Handle("unarchiveStack", app.handleUnarchiveStack)
+}
+
+// archivedStacks returns the archived stack names, or nil when archiving is
+// disabled or the store can't be read.
+func (app *App) archivedStacks() map[string]bool {
+	if app.StackArchive == nil {
+		return nil
+	}
+	names, err := app.StackArchive.Names()
+	if err != nil {
+		slog.Warn("list archived stacks", "err", err)
+		return nil
+	}
+	return names
+}
+
+// stackArchive returns a stack's archive record, or nil if it isn't archived.
+func (app *App) stackArchive(stackName string) *models.ArchivedStack {
+	if app.StackArchive == nil {
+		return nil
+	}
+	a, err := app.StackArchive.Get(stackName)
+	if err != nil {
+		slog.Warn("get archived stack", "err", err, "stack", stackName)
+		return nil
+	}
+	return a
+}
+
+// unarchiveDeletedStack drops the archive mark of a stack whose files were
+// removed, so a new stack with the same name starts out active.
+func (app *App) unarchiveDeletedStack(stackName string) {
+	if app.StackArchive == nil {
+		return
+	}
+	if err := app.StackArchive.Unarchive(stackName); err != nil {
+		slog.Warn("unarchive deleted stack", "err", err, "stack", stackName)
+	}
+}
+
+// rejectArchived acks an error and returns true if the stack is archived.
+// Used by every action that would start containers.
+func (app *App) rejectArchived(c *ws.Conn, msg *ws.ClientMessage, stackName string) bool {
+	if app.stackArchive(stackName) == nil {
+		return false
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Stack is archived. Unarchive it first."})
+	}
+	return true
+}
+
+// handleArchiveStack takes a stack down and archives it; its compose files
+// are kept. Args: stack name.
+func (app *App) handleArchiveStack(c *ws.Conn, msg *ws.ClientMessage) {
+	stackName, ok := app.archiveArgs(c, msg)
+	if !ok {
+		return
+	}
+	if compose.FindComposeFile(app.StacksDir, stackName) == "" {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Only stacks with a compose file can be archived"})
+		}
+		return
+	}
+
+	a := models.ArchivedStack{StackName: stackName}
+	if user := app.currentUser(c); user != nil {
+		a.ArchivedBy = user.Username
+	}
+
+	app.StackLocks.Lock(stackName)
+	if err := app.StackArchive.Archive(a); err != nil {
+		app.StackLocks.Unlock(stackName)
+		slog.Error("archive stack", "err", err, "stack", stackName)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+	slog.Info("stack archived", "stack", stackName, "by", a.ArchivedBy)
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Archived"})
+	}
+
+	go func() {
+		defer app.StackLocks.Unlock(stackName)
+
+		// Down via terminal manager so users see progress output
+		app.runComposeAction(stackName, "down", "down", "--remove-orphans")
+
+		// Archived stacks aren't checked, so drop their stale update state
+		if err := app.ImageUpdates.DeleteForStack(stackName); err != nil {
+			slog.Warn("clear image update cache", "stack", stackName, "err", err)
+		}
+		app.TriggerUpdatesBroadcast()
+		app.TriggerStacksBroadcast()
+	}()
+}
+
+// handleUnarchiveStack returns an archived stack to the stack list. It is
+// not started. Args: stack name.
+func (app *App) handleUnarchiveStack(c *ws.Conn, msg *ws.ClientMessage) {
+	stackName, ok := app.archiveArgs(c, msg)
+	if !ok {
+		return
+	}
+	if err := app.StackArchive.Unarchive(stackName); err != nil {
+		slog.Error("unarchive stack", "err", err, "stack", stackName)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+	slog.Info("stack unarchived", "stack", stackName)
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Unarchived"})
+	}
+
+	app.TriggerStacksBroadcast()
+	go func() {
+		app.checkImageUpdatesForStack(stackName)
+		app.TriggerUpdatesBroadcast()
+	}()
+}
+
+// archiveArgs checks login and reads the stack name argument, acking on error.
+func (app *App) archiveArgs(c *ws.Conn, msg *ws.ClientMessage) (string, bool) {
+	if checkLogin(c, msg) == 0 {
+		return "", false
+	}
+	fail := func(text string) (string, bool) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: text})
+		}
+		return "", false
+	}
+	if app.StackArchive == nil {
+		return fail("Stack archive is not available")
+	}
+	stackName := argString(parseArgs(msg), 0)
+	if err := stack.ValidateStackName(stackName); err != nil {
+		return fail(err.Error())
+	}
+	return stackName, true
+}
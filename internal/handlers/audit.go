@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// auditReadPrefixes mark events that only read state. Every other event is
+// recorded in the audit log.
+var auditReadPrefixes = []string{
+	"get", "list", "check", "preview", "validate", "search", "subscribe",
+	"unsubscribe", "request", "monitor", "need", "verify",
+}
+
+// auditReadEvents are the read-only events not covered by a prefix.
+var auditReadEvents = map[string]bool{
+	"twoFAStatus":             true,
+	"prepare2FA":              true,
+	"serviceStatusList":       true,
+	"containerInspect":        true,
+	"imageInspect":            true,
+	"networkInspect":          true,
+	"volumeInspect":           true,
+	"terminalJoin":            true,
+	"terminalLeave":           true,
+	"discoverComposeProjects": true,
+}
+
+// auditSecretEvents carry passwords, tokens, 2FA codes or environment
+// values in their arguments; none of their arguments are recorded.
+var auditSecretEvents = map[string]bool{
+	"login":                true,
+	"loginByToken":         true,
+	"setup":                true,
+	"sudo":                 true,
+	"changePassword":       true,
+	"save2FA":              true,
+	"disable2FA":           true,
+	"addUser":              true,
+	"applyStackEnvReplace": true,
+	"setStackTerminalEnv":  true,
+	"saveStackWebhook":     true,
+	"testNotification":     true,
+	"hostTerminal":         true,
+}
+
+// auditSafeKeys are the object fields whose string values name things
+// rather than carry them. Strings under any other key are redacted, so a
+// new handler can't leak a secret by forgetting to list it.
+var auditSafeKeys = map[string]bool{
+	"action":      true,
+	"container":   true,
+	"endpoint":    true,
+	"file":        true,
+	"group":       true,
+	"id":          true,
+	"image":       true,
+	"kind":        true,
+	"name":        true,
+	"network":     true,
+	"policy":      true,
+	"project":     true,
+	"reference":   true,
+	"role":        true,
+	"service":     true,
+	"serviceName": true,
+	"stack":       true,
+	"stackName":   true,
+	"tag":         true,
+	"type":        true,
+	"username":    true,
+}
+
+// maxAuditArgLen is the longest string argument recorded as is; longer
+// ones (compose files, .env contents) are recorded by length only.
+const maxAuditArgLen = 64
+
+// RegisterAuditHandlers registers the audit log handler and starts recording
+// every state-changing request.
+func RegisterAuditHandlers(app *App) {
+	app.WS.Handle("getAuditLog", app.handleGetAuditLog)
+	if app.Audit != nil {
+		app.WS.ObserveAcks(auditedEvent, app.recordAudit)
+	}
+}
+
+// auditedEvent reports whether an event changes state.
+func auditedEvent(event string) bool {
+	if auditReadEvents[event] || strings.HasPrefix(event, "__") {
+		return false
+	}
+	for _, p := range auditReadPrefixes {
+		if strings.HasPrefix(event, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// recordAudit appends a request and its outcome to the audit log, and to
+// the JSON lines file if one is configured.
+func (app *App) recordAudit(c *ws.Conn, msg *ws.ClientMessage, ack []byte) {
+	args := parseArgs(msg)
+	e := &models.AuditEntry{Action: msg.Event, Acked: ack != nil}
+	if msg.Event == "agent" {
+		// Recorded as the event it carries, which may only read
+		e.Endpoint = argString(args, 0)
+		e.Action = argString(args, 1)
+		if !auditedEvent(e.Action) {
+			return
+		}
+		if len(args) > 2 {
+			args = args[2:]
+		} else {
+			args = nil
+		}
+	}
+
+	if user := app.currentUser(c); user != nil {
+		e.User = user.Username
+	} else if e.Action == "login" {
+		e.User = loginUsername(args) // a failed login
+	}
+	if strings.Contains(e.Action, "Stack") || strings.Contains(e.Action, "Service") {
+		if name := argString(args, 0); stack.ValidateStackName(name) == nil {
+			e.Stack = name
+		}
+	}
+	e.Args = summarizeAuditArgs(e.Action, args)
+
+	e.Success = true
+	if ack != nil {
+		var result struct {
+			Data struct {
+				OK  *bool  `json:"ok"`
+				Msg string `json:"msg"`
+			} `json:"data"`
+		}
+		if json.Unmarshal(ack, &result) == nil && result.Data.OK != nil && !*result.Data.OK {
+			e.Success = false
+			e.Error = result.Data.Msg
+		}
+	}
+
+	app.appendAudit(e)
+}
+
+// appendAudit stores an entry in the audit log, and in the JSON lines
+// file if one is configured.
+func (app *App) appendAudit(e *models.AuditEntry) {
+	if app.Audit == nil {
+		return
+	}
+	if err := app.Audit.Append(e); err != nil {
+		slog.Error("audit log", "action", e.Action, "err", err)
+		return
+	}
+	if app.AuditLogFile != "" {
+		app.appendAuditFile(e)
+	}
+}
+
+// appendAuditFile writes an entry as one JSON line. The file is opened per
+// entry so it can be rotated externally.
+func (app *App) appendAuditFile(e *models.AuditEntry) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	f, err := os.OpenFile(app.AuditLogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		slog.Warn("audit log file", "err", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		slog.Warn("audit log file", "err", err)
+	}
+}
+
+// loginUsername returns the username of a login request, which is either
+// positional or an object.
+func loginUsername(args []json.RawMessage) string {
+	if name := argString(args, 0); name != "" {
+		return name
+	}
+	var data struct {
+		Username string `json:"username"`
+	}
+	argObject(args, 0, &data)
+	return data.Username
+}
+
+// summarizeAuditArgs describes a request's arguments without recording
+// secrets or file contents: short strings, numbers and booleans as is,
+// long strings by length, and objects by their keys, with strings shown
+// only for the fields in auditSafeKeys.
+func summarizeAuditArgs(event string, args []json.RawMessage) []string {
+	summary := make([]string, 0, len(args))
+	for _, raw := range args {
+		if auditSecretEvents[event] {
+			summary = append(summary, "<redacted>")
+			continue
+		}
+		summary = append(summary, summarizeAuditValue(raw, true))
+	}
+	return summary
+}
+
+func summarizeAuditValue(raw json.RawMessage, nested bool) string {
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return "<invalid>"
+	}
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		if len(v) > maxAuditArgLen || strings.ContainsAny(v, "\r\n") {
+			return "<" + strconv.Itoa(len(v)) + " chars>"
+		}
+		return strconv.Quote(v)
+	case []any:
+		return fmt.Sprintf("<%d items>", len(v))
+	case map[string]any:
+		if !nested {
+			return "{…}"
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var obj map[string]json.RawMessage
+		json.Unmarshal(raw, &obj)
+		fields := make([]string, 0, len(keys))
+		for _, k := range keys {
+			value := "<redacted>"
+			if auditSafeKeys[k] || !auditSecretKey(k) && !isJSONString(obj[k]) {
+				value = summarizeAuditValue(obj[k], false)
+			}
+			fields = append(fields, k+": "+value)
+		}
+		return "{" + strings.Join(fields, ", ") + "}"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// isJSONString reports whether raw is a JSON string.
+func isJSONString(raw json.RawMessage) bool {
+	return len(raw) > 0 && raw[0] == '"'
+}
+
+// auditSecretKey reports whether an object key looks like it holds a
+// secret. JSON keys are camelCase, so words are split before matching.
+func auditSecretKey(key string) bool {
+	var b strings.Builder
+	for i, r := range key {
+		if i > 0 && r >= 'A' && r <= 'Z' {
+			b.WriteByte('_')
+		}
+		b.WriteRune(r)
+	}
+	return stack.IsSecretKey(b.String())
+}
+
+// handleGetAuditLog returns a page of the audit log, newest first. Admin
+// only. Args: {before, limit, user, action, stack}; before is the ID to
+// continue from (0 = newest), limit defaults to 50.
+func (app *App) handleGetAuditLog(c *ws.Conn, msg *ws.ClientMessage) {
+	if app.checkAdmin(c, msg) == nil {
+		return
+	}
+	if app.Audit == nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Audit log is not available"})
+		}
+		return
+	}
+
+	args := parseArgs(msg)
+	var opts struct {
+		models.AuditFilter
+		Before uint64 `json:"before"`
+		Limit  int    `json:"limit"`
+	}
+	argObject(args, 0, &opts)
+	if opts.Limit <= 0 || opts.Limit > 500 {
+		opts.Limit = 50
+	}
+
+	entries, next, err := app.Audit.List(opts.AuditFilter, opts.Before, opts.Limit)
+	if err != nil {
+		slog.Error("list audit log", "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK      bool                `json:"ok"`
+			Entries []models.AuditEntry `json:"entries"`
+			Next    uint64              `json:"next"` // 0 = no older entries
+		}{OK: true, Entries: entries, Next: next})
+	}
+}
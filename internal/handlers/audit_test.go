@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/coder/websocket"
+
+	"github.com/cfilipov/dockge/internal/db"
+	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+func TestAuditedEvent(t *testing.T) {
+	t.Parallel()
+	for event, want := range map[string]bool{
+		"deployStack":       true,
+		"login":             true,
+		"setSettings":       true,
+		"getStack":          false,
+		"listTemplates":     false,
+		"subscribeStats":    false,
+		"serviceStatusList": false,
+		"__connect":         false,
+	} {
+		if got := auditedEvent(event); got != want {
+			t.Errorf("auditedEvent(%q) = %v, want %v", event, got, want)
+		}
+	}
+}
+
+func TestSummarizeAuditArgs(t *testing.T) {
+	t.Parallel()
+	raw := func(s string) json.RawMessage { return json.RawMessage(s) }
+	args := []json.RawMessage{
+		raw(`"web"`),
+		raw(`"services:\n  app:\n    image: nginx\n"`),
+		raw(`{"cpus": 2, "apiToken": "abc", "tags": ["a", "b"], "opts": {"x": 1}, "service": "app", "note": "x"}`),
+		raw(`true`),
+	}
+	got := summarizeAuditArgs("deployStack", args)
+	want := []string{
+		`"web"`,
+		"<34 chars>",
+		`{apiToken: <redacted>, cpus: 2, note: <redacted>, opts: {…}, service: "app", tags: <2 items>}`,
+		"true",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("summarizeAuditArgs() = %q, want %q", got, want)
+	}
+
+	got = summarizeAuditArgs("login", []json.RawMessage{raw(`"alice"`), raw(`"hunter2"`)})
+	if !reflect.DeepEqual(got, []string{"<redacted>", "<redacted>"}) {
+		t.Errorf("login args not redacted: %q", got)
+	}
+}
+
+func TestRecordAuditRedactsSecrets(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	database, err := db.Open(filepath.Join(dir, "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	app := &App{
+		WS:           ws.NewServer(true),
+		NoAuth:       true,
+		Audit:        models.NewAuditStore(database),
+		AuditLogFile: filepath.Join(dir, "audit.jsonl"),
+	}
+	send := func(ctx context.Context, typ websocket.MessageType, data []byte) error { return nil }
+	c := app.WS.Attach(send, nil)
+
+	// A rotated secret, sent by the replace handler and by one that isn't
+	// listed as carrying secrets
+	rotate := `{"key": "DB_PASSWORD", "value": "old-s3cret", "newValue": "new-s3cret"}`
+	for _, event := range []string{"applyStackEnvReplace", "rotateStackValue"} {
+		msg := &ws.ClientMessage{Event: event, Args: json.RawMessage(`[` + rotate + `]`)}
+		app.recordAudit(c, msg, []byte(`{"data":{"ok":true}}`))
+	}
+
+	entries, _, err := app.Audit.List(models.AuditFilter{}, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	stored, _ := json.Marshal(entries)
+	file, err := os.ReadFile(app.AuditLogFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, log := range []string{string(stored), string(file)} {
+		if strings.Contains(log, "s3cret") {
+			t.Errorf("secret reached the audit log: %s", log)
+		}
+	}
+}
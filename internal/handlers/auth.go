@@ -3,7 +3,6 @@ package handlers
 import (
     "context"
     "log/slog"
-    "sort"
     "time"
 
     "github.com/cfilipov/dockge/internal/docker"
@@ -19,6 +18,8 @@ func RegisterAuthHandlers(app *App) {
     app.WS.Handle("changePassword", app.handleChangePassword)
     app.WS.Handle("getTurnstileSiteKey", app.handleGetTurnstileSiteKey)
     app.WS.Handle("needSetup", app.handleNeedSetup)
+    app.WS.Handle("sudo", app.handleSudo)
+    app.WS.Handle("getSudoStatus", app.handleGetSudoStatus)
 
     // 2FA stubs — not implemented yet
     app.WS.Handle("prepare2FA", app.handleStub2FA)
@@ -35,6 +36,7 @@ func RegisterAuthHandlers(app *App) {
             "isContainer":   true,
             "dev":           app.Dev,
             "backend":       "go",
+            "hostTerminal":  app.HostTerminal != "",
         })
 
         // If no users exist, tell the client to show the setup page
@@ -336,16 +338,39 @@ func (app *App) handleTwoFAStatus(c *ws.Conn, msg *ws.ClientMessage) {
 }
 
 // AfterLogin sends initial data to a freshly authenticated connection.
-// All 6 broadcast channels fire as independent goroutines — each sends to the
-// connection as soon as its data is ready, with no channel waiting on any other.
+// The last broadcast state of each resource channel is replayed right away,
+// so the dashboard isn't blank while Docker is queried. Then all 6 broadcast
+// channels fire as independent goroutines — each sends to the connection as
+// soon as its data is ready, with no channel waiting on any other.
 // Data is sent as maps (Record<string, T>) matching the format used for event updates.
 func (app *App) AfterLogin(c *ws.Conn) {
     // NOTE: Do NOT send "info" here — it's already sent on connect (before auth).
     // NOTE: Do NOT send "autoLogin" here. That event is only for when auth is
     // disabled (every connection is auto-authenticated).
 
+    replayed := app.replay.snapshot()
+    for channel, items := range replayed {
+        sendToConn(c, channel, items)
+    }
+    // sendFresh sends a channel's queried state, removing replayed items that
+    // are gone. On a failed query the replayed state is left in place.
+    sendFresh := func(channel string, items map[string]any, err error) {
+        if err != nil {
+            if _, ok := replayed[channel]; ok {
+                return
+            }
+        } else {
+            app.replay.replace(channel, items)
+            items = withRemovals(items, replayed[channel])
+        }
+        sendToConn(c, channel, items)
+    }
+
     go func() {
-        sendToConn(c, chanStacks, stacksToMap(buildStackBroadcast(app.StacksDir)))
+        // Changes since the last broadcast go to every client; this one
+        // then gets the whole list, numbered for the deltas that follow
+        app.refreshStackList()
+        app.sendFullStackList(c)
     }()
     go func() {
         ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
@@ -355,7 +380,7 @@ func (app *App) AfterLogin(c *ws.Conn) {
             slog.Warn("afterLogin: containers", "err", err)
             containers = []docker.ContainerBroadcast{}
         }
-        sendToConn(c, chanContainers, containersToMap(containers))
+        sendFresh(chanContainers, containersToMap(containers), err)
     }()
     go func() {
         ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
@@ -365,7 +390,7 @@ func (app *App) AfterLogin(c *ws.Conn) {
             slog.Warn("afterLogin: networks", "err", err)
             networks = []docker.NetworkSummary{}
         }
-        sendToConn(c, chanNetworks, networksToMap(networks))
+        sendFresh(chanNetworks, networksToMap(networks), err)
     }()
     go func() {
         ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
@@ -375,7 +400,7 @@ func (app *App) AfterLogin(c *ws.Conn) {
             slog.Warn("afterLogin: images", "err", err)
             images = []docker.ImageSummary{}
         }
-        sendToConn(c, chanImages, imagesToMap(images))
+        sendFresh(chanImages, imagesToMap(images), err)
     }()
     go func() {
         ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
@@ -385,17 +410,12 @@ func (app *App) AfterLogin(c *ws.Conn) {
             slog.Warn("afterLogin: volumes", "err", err)
             volumes = []docker.VolumeSummary{}
         }
-        sendToConn(c, chanVolumes, volumesToMap(volumes))
+        sendFresh(chanVolumes, volumesToMap(volumes), err)
     }()
     go func() {
-        svcUpdates, _ := app.ImageUpdates.AllServiceUpdates()
-        updated := make([]string, 0, len(svcUpdates))
-        for key, hasUpdate := range svcUpdates {
-            if hasUpdate {
-                updated = append(updated, key)
-            }
-        }
-        sort.Strings(updated)
-        sendToConn(c, chanUpdates, updated)
+        sendToConn(c, chanUpdates, app.buildUpdatesPayload())
+    }()
+    go func() {
+        ws.SendEvent(c, "stackGroups", stackGroupsPayload{app.stackGroups()})
     }()
 }
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/autoheal"
+	"github.com/cfilipov/dockge/internal/models"
+)
+
+// Settings keys of autohealing. Containers opt in by stack here or with
+// the autoheal.Label label.
+const (
+	settingAutohealStacks      = "autohealStacks"       // stack names, one per line or comma-separated
+	settingAutohealGrace       = "autohealGraceSeconds" // defaultAutohealGrace if unset
+	settingAutohealMaxRestarts = "autohealMaxRestarts"  // per container per hour, defaultAutohealMaxRestarts if unset
+)
+
+const (
+	defaultAutohealGrace       = 30 * time.Second
+	defaultAutohealMaxRestarts = 3
+)
+
+// StartAutoheal restarts opted-in containers that stay unhealthy. Needs
+// InitBroadcast to have run.
+func (app *App) StartAutoheal(ctx context.Context) {
+	if app.EventBus == nil {
+		return
+	}
+	events, unsub := app.EventBus.Subscribe(64)
+	go func() {
+		<-ctx.Done()
+		unsub()
+	}()
+	w := autoheal.New(func() autoheal.Config {
+		return autohealConfigFrom(app.settingValue)
+	}, app.Docker.ContainerRestart, app.reportAutoheal)
+	w.Start(ctx, events)
+}
+
+// autohealConfigFrom builds the autoheal config from settings read with
+// get.
+func autohealConfigFrom(get func(key string) string) autoheal.Config {
+	cfg := autoheal.Config{
+		Stacks:      make(map[string]bool),
+		Grace:       defaultAutohealGrace,
+		MaxRestarts: defaultAutohealMaxRestarts,
+	}
+	for _, line := range strings.Split(get(settingAutohealStacks), "\n") {
+		for _, name := range splitList(line) {
+			cfg.Stacks[name] = true
+		}
+	}
+	if n, err := strconv.Atoi(get(settingAutohealGrace)); err == nil && n >= 0 {
+		cfg.Grace = time.Duration(n) * time.Second
+	}
+	if n, err := strconv.Atoi(get(settingAutohealMaxRestarts)); err == nil && n >= 0 {
+		cfg.MaxRestarts = n
+	}
+	return cfg
+}
+
+// reportAutoheal logs what the watchdog did and records it in the audit
+// log.
+func (app *App) reportAutoheal(a autoheal.Action) {
+	e := &models.AuditEntry{
+		Action:  "autoheal",
+		Stack:   a.Stack,
+		Args:    []string{a.Container},
+		Success: a.Restarted && a.Err == nil,
+		Acked:   true,
+	}
+	switch {
+	case !a.Restarted:
+		e.Error = fmt.Sprintf("not restarted: restart budget used up (%d per hour)", autohealConfigFrom(app.settingValue).MaxRestarts)
+		slog.Warn("autoheal: restart budget used up", "stack", a.Stack, "container", a.Container)
+	case a.Err != nil:
+		e.Error = a.Err.Error()
+		slog.Error("autoheal: restart", "stack", a.Stack, "container", a.Container, "err", a.Err)
+	default:
+		slog.Info("autoheal: restarted unhealthy container", "stack", a.Stack, "container", a.Container)
+	}
+	app.appendAudit(e)
+}
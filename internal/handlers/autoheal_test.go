@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAutohealConfigFrom(t *testing.T) {
+	t.Parallel()
+	settings := map[string]string{
+		settingAutohealStacks:      "web, db\n\ncache\n",
+		settingAutohealGrace:       "90",
+		settingAutohealMaxRestarts: "0",
+	}
+	cfg := autohealConfigFrom(func(key string) string { return settings[key] })
+	if len(cfg.Stacks) != 3 || !cfg.Stacks["web"] || !cfg.Stacks["db"] || !cfg.Stacks["cache"] {
+		t.Errorf("Stacks = %v", cfg.Stacks)
+	}
+	if cfg.Grace != 90*time.Second || cfg.MaxRestarts != 0 {
+		t.Errorf("Grace = %v, MaxRestarts = %d", cfg.Grace, cfg.MaxRestarts)
+	}
+
+	defaults := autohealConfigFrom(func(string) string { return "" })
+	if len(defaults.Stacks) != 0 || defaults.Grace != defaultAutohealGrace || defaults.MaxRestarts != defaultAutohealMaxRestarts {
+		t.Errorf("defaults = %+v", defaults)
+	}
+}
@@ -0,0 +1,471 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/backup"
+	"github.com/cfilipov/dockge/internal/compose"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// backupTimeout bounds exporting or restoring a stack with large volumes.
+const backupTimeout = 30 * time.Minute
+
+// backupLinkTTL is how long a download or upload link stays valid.
+const backupLinkTTL = 10 * time.Minute
+
+// maxBackupUpload bounds the size of an uploaded stack backup.
+const maxBackupUpload = 10 << 30
+
+// volumeHelperImage runs tar against a volume's contents.
+const volumeHelperImage = "busybox:stable"
+
+// backupLinkPath prefixes the URL of a backup link; ServeBackup is mounted
+// at backupLinkPath + "{token}".
+const backupLinkPath = "/api/backups/"
+
+// RegisterStackBackupHandlers registers the stack backup handlers. The
+// archives themselves go over HTTP, see ServeBackup.
+func RegisterStackBackupHandlers(app *App) {
+	app.WS.Handle("exportStack", app.handleExportStack)
+	app.WS.Handle("importStack", app.handleImportStack)
+}
+
+// backupLink is a one-time link to download an exported backup or upload
+// one to import.
+type backupLink struct {
+	file          string           // export to download
+	restore       *importStackArgs // import options, for a stack upload
+	restoreConfig bool             // a full backup upload, see handleRestoreConfigBackup
+	volumeFile    *volumeFileRef   // a file in a volume to download, see ServeVolumeFile
+	user          string
+	expires       time.Time
+}
+
+// backupLinks holds the links issued by exportStack and importStack.
+type backupLinks struct {
+	mu    sync.Mutex
+	links map[string]*backupLink
+}
+
+// issue stores l and returns its token.
+func (b *backupLinks) issue(l *backupLink) string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	token := hex.EncodeToString(buf)
+	l.expires = time.Now().Add(backupLinkTTL)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.links == nil {
+		b.links = make(map[string]*backupLink)
+	}
+	for t, old := range b.links {
+		if time.Now().After(old.expires) {
+			delete(b.links, t)
+		}
+	}
+	b.links[token] = l
+	return token
+}
+
+// take removes and returns the link for token, or nil if there is none or
+// it expired.
+func (b *backupLinks) take(token string) *backupLink {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	l := b.links[token]
+	delete(b.links, token)
+	if l == nil || time.Now().After(l.expires) {
+		return nil
+	}
+	return l
+}
+
+// exportStackArgs are the options of an exportStack request.
+type exportStackArgs struct {
+	Volumes bool `json:"volumes"` // include the data of the stack's named volumes
+}
+
+// handleExportStack writes a backup of a stack to ExportDir and returns a
+// one-time link to download it. Admin only and requires sudo.
+// Args: stack name, options.
+func (app *App) handleExportStack(c *ws.Conn, msg *ws.ClientMessage) {
+	user := app.checkAdmin(c, msg)
+	if user == nil || !app.requireSudo(c, msg) {
+		return
+	}
+	args := parseArgs(msg)
+	stackName := argString(args, 0)
+	var opts exportStackArgs
+	argObject(args, 1, &opts)
+
+	fail := func(text string) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: text})
+		}
+	}
+	if err := stack.ValidateStackName(stackName); err != nil {
+		fail(err.Error())
+		return
+	}
+	if app.ExportDir == "" {
+		fail("Stack export is not available")
+		return
+	}
+	if !stack.ComposeFileExists(app.StacksDir, stackName) {
+		fail("Stack not found")
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), backupTimeout)
+		defer cancel()
+
+		path, skipped, err := app.exportStack(ctx, stackName, opts.Volumes)
+		if err != nil {
+			slog.Error("export stack", "err", err, "stack", stackName)
+			fail(err.Error())
+			return
+		}
+		slog.Info("stack exported", "stack", stackName, "path", path, "volumes", opts.Volumes, "by", user.Username)
+
+		token := app.backupLinks.issue(&backupLink{file: path, user: user.Username})
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, struct {
+				OK      bool     `json:"ok"`
+				Path    string   `json:"path"`
+				URL     string   `json:"url"`
+				Skipped []string `json:"skipped,omitempty"`
+			}{OK: true, Path: path, URL: backupLinkPath + token, Skipped: skipped})
+		}
+	}()
+}
+
+// exportStack writes a backup of a stack to ExportDir and returns its path
+// and the files left out. A partial file is removed on failure.
+func (app *App) exportStack(ctx context.Context, stackName string, withVolumes bool) (string, []string, error) {
+	if err := os.MkdirAll(app.ExportDir, 0700); err != nil {
+		return "", nil, fmt.Errorf("create export dir: %w", err)
+	}
+	var volumes []stack.BackupVolume
+	if withVolumes {
+		tmp, err := os.MkdirTemp(app.ExportDir, ".volumes-")
+		if err != nil {
+			return "", nil, err
+		}
+		defer os.RemoveAll(tmp)
+		if volumes, err = app.exportStackVolumes(ctx, stackName, tmp); err != nil {
+			return "", nil, err
+		}
+	}
+
+	path := filepath.Join(app.ExportDir, stackName+"-"+time.Now().Format("20060102-150405")+".tar.gz")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", nil, fmt.Errorf("create export file: %w", err)
+	}
+	skipped, err := stack.WriteBackup(f, app.StacksDir, stackName, volumes)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", nil, fmt.Errorf("write export file: %w", err)
+	}
+	return path, skipped, nil
+}
+
+// exportStackVolumes tars the contents of each of a stack's own named
+// volumes into dir with a helper container. External volumes belong to
+// something else, and volumes that don't exist yet have nothing to save.
+func (app *App) exportStackVolumes(ctx context.Context, stackName, dir string) ([]stack.BackupVolume, error) {
+	project, err := compose.ResolveConfig(ctx, app.StacksDir, stackName)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(project.Volumes))
+	for key := range project.Volumes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var volumes []stack.BackupVolume
+	for _, key := range keys {
+		v := project.Volumes[key]
+		if v.External || v.Name == "" {
+			continue
+		}
+		exists, err := app.volumeExists(ctx, v.Name)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+		file := filepath.Join(dir, key+".tar")
+		if err := runVolumeHelper(ctx, v.Name+":/volume:ro", nil, file, "tar", "-C", "/volume", "-cf", "-", "."); err != nil {
+			return nil, fmt.Errorf("volume %s: %w", v.Name, err)
+		}
+		volumes = append(volumes, stack.BackupVolume{Key: key, Name: v.Name, File: file})
+	}
+	return volumes, nil
+}
+
+// volumeExists reports whether a volume with exactly this name exists. The
+// daemon's name filter also matches substrings.
+func (app *App) volumeExists(ctx context.Context, name string) (bool, error) {
+	vols, err := app.Docker.VolumeListByName(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	for _, v := range vols {
+		if v.Name == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// runVolumeHelper runs a command in a throwaway helper container with a
+// volume mounted, feeding it stdin and writing its output to outFile if
+// they're set.
+func runVolumeHelper(ctx context.Context, mount string, stdin io.Reader, outFile string, command ...string) error {
+	args := []string{"run", "--rm", "--network", "none", "-v", mount}
+	if stdin != nil {
+		args = append(args, "-i")
+	}
+	args = append(args, volumeHelperImage)
+	cmd := exec.CommandContext(ctx, "docker", append(args, command...)...)
+	cmd.Stdin = stdin
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if outFile != "" {
+		f, err := os.OpenFile(outFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		cmd.Stdout = f
+	}
+	if err := cmd.Run(); err != nil {
+		if text := strings.TrimSpace(stderr.String()); text != "" {
+			return errors.New(text)
+		}
+		return err
+	}
+	return nil
+}
+
+// importStackArgs are the options of an importStack request.
+type importStackArgs struct {
+	StackName string `json:"stackName"` // default: the name in the backup
+	Volumes   bool   `json:"volumes"`   // restore volume data included in the backup
+}
+
+// handleImportStack returns a one-time link to upload a stack backup to.
+// The upload creates the stack; see ServeBackup. Admin only and
+// requires sudo. Args: options.
+func (app *App) handleImportStack(c *ws.Conn, msg *ws.ClientMessage) {
+	user := app.checkAdmin(c, msg)
+	if user == nil || !app.requireSudo(c, msg) {
+		return
+	}
+	args := parseArgs(msg)
+	var opts importStackArgs
+	argObject(args, 0, &opts)
+
+	if opts.StackName != "" {
+		if err := stack.ValidateStackName(opts.StackName); err != nil {
+			if msg.ID != nil {
+				ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+			}
+			return
+		}
+	}
+
+	token := app.backupLinks.issue(&backupLink{restore: &opts, user: user.Username})
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK  bool   `json:"ok"`
+			URL string `json:"url"`
+		}{OK: true, URL: backupLinkPath + token})
+	}
+}
+
+// ServeBackup serves the links issued by the backup handlers: GET downloads
+// an export, POST uploads a stack to import or a full backup to restore.
+// Each link works once; the token is the credential, as the WS handler
+// checked the user.
+func (app *App) ServeBackup(w http.ResponseWriter, r *http.Request) {
+	l := app.backupLinks.take(r.PathValue("token"))
+	switch {
+	case l == nil:
+		http.Error(w, "link not found or expired", http.StatusNotFound)
+	case r.Method == http.MethodGet && l.file != "":
+		f, err := os.Open(l.file)
+		if err != nil {
+			http.Error(w, "backup not found", http.StatusNotFound)
+			return
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(l.file)))
+		http.ServeContent(w, r, filepath.Base(l.file), info.ModTime(), f)
+	case r.Method == http.MethodPost && l.restore != nil:
+		ctx, cancel := context.WithTimeout(r.Context(), backupTimeout)
+		defer cancel()
+		body := http.MaxBytesReader(w, r.Body, maxBackupUpload)
+		name, volumes, status, err := app.importStack(ctx, body, l.restore)
+		w.Header().Set("Content-Type", "application/json")
+		if err != nil {
+			slog.Warn("import stack", "err", err, "stack", name, "by", l.user)
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(ws.ErrorResponse{OK: false, Msg: err.Error()})
+			return
+		}
+		slog.Info("stack imported", "stack", name, "volumes", volumes, "by", l.user)
+		json.NewEncoder(w).Encode(struct {
+			OK        bool     `json:"ok"`
+			Msg       string   `json:"msg"`
+			StackName string   `json:"stackName"`
+			Volumes   []string `json:"volumes,omitempty"`
+		}{OK: true, Msg: "Imported", StackName: name, Volumes: volumes})
+	case r.Method == http.MethodPost && l.restoreConfig:
+		body := http.MaxBytesReader(w, r.Body, maxBackupUpload)
+		m, status, err := app.stageConfigRestore(body)
+		w.Header().Set("Content-Type", "application/json")
+		if err != nil {
+			slog.Warn("stage restore", "err", err, "by", l.user)
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(ws.ErrorResponse{OK: false, Msg: err.Error()})
+			return
+		}
+		slog.Warn("restore staged; applied at the next start", "created", m.CreatedAt, "stacks", len(m.Stacks), "by", l.user)
+		json.NewEncoder(w).Encode(struct {
+			OK       bool             `json:"ok"`
+			Msg      string           `json:"msg"`
+			Manifest *backup.Manifest `json:"manifest"`
+		}{OK: true, Msg: "Backup validated. Restart Dockge to restore it.", Manifest: m})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// importStack creates a stack from a backup read from body and, if asked,
+// restores its volumes. It returns the stack name, the restored volumes and
+// the HTTP status for an error. Nothing is left behind if the backup can't
+// be read, and existing stacks and volumes are never overwritten.
+func (app *App) importStack(ctx context.Context, body io.Reader, opts *importStackArgs) (string, []string, int, error) {
+	// Extract next to the stacks so the final rename stays on one filesystem
+	tmp, err := os.MkdirTemp(app.StacksDir, ".import-")
+	if err != nil {
+		return "", nil, http.StatusInternalServerError, err
+	}
+	defer os.RemoveAll(tmp)
+
+	files := filepath.Join(tmp, "files")
+	manifest, err := stack.ReadBackup(body, files, filepath.Join(tmp, "volumes"))
+	if err != nil {
+		return "", nil, http.StatusBadRequest, err
+	}
+	name := opts.StackName
+	if name == "" {
+		name = manifest.Stack
+	}
+	if err := stack.ValidateStackName(name); err != nil {
+		return name, nil, http.StatusBadRequest, err
+	}
+	if compose.FindComposeFile(tmp, "files") == "" {
+		return name, nil, http.StatusBadRequest, errors.New("backup has no compose file")
+	}
+
+	app.StackLocks.Lock(name)
+	dir := filepath.Join(app.StacksDir, name)
+	if _, err := os.Lstat(dir); err == nil {
+		app.StackLocks.Unlock(name)
+		return name, nil, http.StatusConflict, fmt.Errorf("stack %q already exists", name)
+	}
+	err = os.Rename(files, dir)
+	app.StackLocks.Unlock(name)
+	if err != nil {
+		return name, nil, http.StatusInternalServerError, err
+	}
+	app.TriggerStacksBroadcast()
+
+	var restored []string
+	if opts.Volumes && len(manifest.Volumes) > 0 {
+		restored, err = app.restoreStackVolumes(ctx, name, manifest.Volumes)
+		if err != nil {
+			app.TriggerVolumesBroadcast()
+			return name, restored, http.StatusInternalServerError, fmt.Errorf("stack %s was created, but restoring volumes failed: %w", name, err)
+		}
+		app.TriggerVolumesBroadcast()
+	}
+	return name, restored, http.StatusOK, nil
+}
+
+// restoreStackVolumes creates the imported stack's volumes, labelled as
+// compose would, and fills them from the backup. Volumes the stack no
+// longer declares as its own are skipped; volumes that already exist are an
+// error, since their data would be mixed with the backup's.
+func (app *App) restoreStackVolumes(ctx context.Context, stackName string, volumes []stack.BackupVolume) ([]string, error) {
+	project, err := compose.ResolveConfig(ctx, app.StacksDir, stackName)
+	if err != nil {
+		return nil, err
+	}
+	var restored []string
+	for _, bv := range volumes {
+		v, ok := project.Volumes[bv.Key]
+		if !ok || v.External || v.Name == "" {
+			continue
+		}
+		exists, err := app.volumeExists(ctx, v.Name)
+		if err != nil {
+			return restored, err
+		}
+		if exists {
+			return restored, fmt.Errorf("volume %s already exists", v.Name)
+		}
+		create := exec.CommandContext(ctx, "docker", "volume", "create",
+			"--label", "com.docker.compose.project="+project.Name,
+			"--label", "com.docker.compose.volume="+bv.Key,
+			v.Name)
+		if out, err := create.CombinedOutput(); err != nil {
+			return restored, fmt.Errorf("create volume %s: %s", v.Name, strings.TrimSpace(string(out)))
+		}
+		f, err := os.Open(bv.File)
+		if err != nil {
+			return restored, err
+		}
+		err = runVolumeHelper(ctx, v.Name+":/volume", f, "", "tar", "-C", "/volume", "-xf", "-")
+		f.Close()
+		if err != nil {
+			return restored, fmt.Errorf("volume %s: %w", v.Name, err)
+		}
+		restored = append(restored, v.Name)
+	}
+	return restored, nil
+}
@@ -7,11 +7,14 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/cfilipov/dockge/internal/compose"
 	"github.com/cfilipov/dockge/internal/docker"
+	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/stack"
 	"github.com/cfilipov/dockge/internal/ws"
 )
 
@@ -32,8 +35,16 @@ type StackBroadcastEntry struct {
 	Name            string                       `json:"name"`
 	ComposeFileName string                       `json:"composeFileName"`
 	IgnoreStatus    map[string]bool              `json:"ignoreStatus,omitempty"`
+	// Jobs are the one-shot services, whose exited containers are
+	// finished runs rather than failures
+	Jobs            map[string]bool              `json:"jobs,omitempty"`
 	Images          map[string]string            `json:"images"`
 	IsManagedByDockge bool                       `json:"isManagedByDockge"`
+	OverBudget      bool                         `json:"overBudget,omitempty"`
+	Archived        bool                         `json:"archived,omitempty"`
+	// ProjectConflict lists the other stacks with the same compose
+	// project name; compose would mix up their containers
+	ProjectConflict []string `json:"projectConflict,omitempty"`
 }
 
 // dispatchWork is sent through the dispatch channel to the worker goroutine.
@@ -46,6 +57,7 @@ type dispatchWork struct {
 type BroadcastMetrics struct {
 	mu       sync.Mutex
 	counters map[string]*ChannelMetrics
+	events   eventMetrics
 }
 
 // ChannelMetrics holds counters for a single broadcast channel.
@@ -100,6 +112,12 @@ func (bm *BroadcastMetrics) Snapshot() map[string]*ChannelMetrics {
 // Events are sent separately on the "resourceEvent" channel.
 type ChannelBroadcast struct {
 	Items map[string]any `json:"items"`
+	// Revision numbers the states of the stacks channel. A stacks
+	// broadcast holds only the stacks changed since revision Since
+	// (nil = removed), unless Full is set.
+	Revision uint64 `json:"revision,omitempty"`
+	Since    uint64 `json:"since,omitempty"`
+	Full     bool   `json:"full,omitempty"`
 }
 
 // ResourceEvent describes a Docker event that triggered a broadcast.
@@ -126,12 +144,28 @@ func toResourceEvent(evt docker.DockerEvent) ResourceEvent {
 	}
 }
 
-// broadcastChannel sends a ChannelBroadcast on the given channel.
+// broadcastChannel sends a partial ChannelBroadcast on the given channel.
 func (app *App) broadcastChannel(channel string, items map[string]any) {
+	app.replay.merge(channel, items)
 	ws.BroadcastAuthenticated(app.WS, channel, ChannelBroadcast{
 		Items: items,
 	})
 	app.BcastMetrics.recordSent(channel)
+	if channel == chanContainers {
+		app.refreshStackListSubs() // stack status filters
+	}
+}
+
+// broadcastChannelFull sends a ChannelBroadcast holding a channel's full state.
+func (app *App) broadcastChannelFull(channel string, items map[string]any) {
+	app.replay.replace(channel, items)
+	ws.BroadcastAuthenticated(app.WS, channel, ChannelBroadcast{
+		Items: items,
+	})
+	app.BcastMetrics.recordSent(channel)
+	if channel == chanContainers {
+		app.refreshStackListSubs() // stack status filters
+	}
 }
 
 // sendToConn sends channel data to a single connection (used for initial connect).
@@ -196,13 +230,14 @@ func stacksToMap(stacks []StackBroadcastEntry) map[string]any {
 
 // --- Full-list broadcast functions (for initial load + Trigger methods) ---
 
-// broadcastStacksMap queries stacks and broadcasts as a full-replace map.
+// broadcastStacksMap rebuilds the stacks and broadcasts the ones that
+// changed since the last broadcast.
 func (app *App) broadcastStacksMap() {
 	if !app.WS.HasAuthenticatedConns() {
+		app.replay.invalidate(chanStacks)
 		return
 	}
-	entries := buildStackBroadcast(app.StacksDir)
-	app.broadcastChannel(chanStacks, stacksToMap(entries))
+	app.refreshStackList()
 }
 
 // broadcastContainersMap queries Docker for all containers and broadcasts as a full-replace map.
@@ -217,7 +252,8 @@ func (app *App) broadcastContainersMap() {
 		slog.Warn("broadcastContainersMap", "err", err)
 		containers = []docker.ContainerBroadcast{}
 	}
-	app.broadcastChannel(chanContainers, containersToMap(containers))
+	containers = app.ignoreList().filterContainers(containers)
+	app.broadcastChannelFull(chanContainers, containersToMap(containers))
 }
 
 // broadcastNetworksMap queries Docker for all networks and broadcasts as a full-replace map.
@@ -232,7 +268,7 @@ func (app *App) broadcastNetworksMap() {
 		slog.Warn("broadcastNetworksMap", "err", err)
 		networks = []docker.NetworkSummary{}
 	}
-	app.broadcastChannel(chanNetworks, networksToMap(networks))
+	app.broadcastChannelFull(chanNetworks, networksToMap(networks))
 }
 
 // broadcastImagesMap queries Docker for all images and broadcasts as a full-replace map.
@@ -247,7 +283,7 @@ func (app *App) broadcastImagesMap() {
 		slog.Warn("broadcastImagesMap", "err", err)
 		images = []docker.ImageSummary{}
 	}
-	app.broadcastChannel(chanImages, imagesToMap(images))
+	app.broadcastChannelFull(chanImages, imagesToMap(images))
 }
 
 // broadcastVolumesMap queries Docker for all volumes and broadcasts as a full-replace map.
@@ -262,7 +298,7 @@ func (app *App) broadcastVolumesMap() {
 		slog.Warn("broadcastVolumesMap", "err", err)
 		volumes = []docker.VolumeSummary{}
 	}
-	app.broadcastChannel(chanVolumes, volumesToMap(volumes))
+	app.broadcastChannelFull(chanVolumes, volumesToMap(volumes))
 }
 
 // broadcastContainersByIDs queries Docker for specific containers using batched
@@ -286,7 +322,7 @@ func (app *App) broadcastContainersByIDs(ids map[string]bool, destroyed []string
 		slog.Warn("broadcastContainersByIDs", "err", err)
 		return
 	}
-	m := containersToMap(containers)
+	m := containersToMap(app.ignoreList().filterContainers(containers))
 	for _, name := range destroyed {
 		if _, exists := m[name]; !exists {
 			m[name] = nil
@@ -297,6 +333,28 @@ func (app *App) broadcastContainersByIDs(ids map[string]bool, destroyed []string
 	}
 }
 
+// refreshProjectContainers re-broadcasts a compose project's containers, so
+// the stack view reflects an action without waiting on Docker events.
+func (app *App) refreshProjectContainers(stackName string) {
+	if !app.WS.HasAuthenticatedConns() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	containers, err := app.Docker.ContainerList(ctx, true, stackName)
+	if err != nil {
+		slog.Warn("refreshProjectContainers", "stack", stackName, "err", err)
+		return
+	}
+	ids := make(map[string]bool, len(containers))
+	for _, c := range containers {
+		ids[c.ID] = true
+	}
+	if len(ids) > 0 {
+		app.broadcastContainersByIDs(ids, nil)
+	}
+}
+
 // broadcastNetworksByIDs queries Docker for specific networks using batched
 // list call and broadcasts a partial map. Falls back to full list if >25 IDs.
 func (app *App) broadcastNetworksByIDs(ids map[string]bool, destroyed []string) {
@@ -393,8 +451,17 @@ func (app *App) broadcastVolumesByNames(names map[string]bool, destroyed []strin
 	}
 }
 
-// broadcastUpdates reads BoltDB image update cache and broadcasts container names with updates.
-func (app *App) broadcastUpdates() {
+// updatesPayload is the "updates" channel payload: "stack/service" keys with
+// image updates, and the release classification of services with newer
+// version tags.
+type updatesPayload struct {
+	Keys     []string                        `json:"keys"`
+	Releases map[string]models.ReleaseUpdate `json:"releases"`
+}
+
+// buildUpdatesPayload reads the BoltDB image update cache, and keeps the
+// keys for the dashboard summary.
+func (app *App) buildUpdatesPayload() updatesPayload {
 	svcUpdates, err := app.ImageUpdates.AllServiceUpdates()
 	if err != nil {
 		slog.Warn("broadcastUpdates", "err", err)
@@ -408,9 +475,54 @@ func (app *App) broadcastUpdates() {
 		}
 	}
 	sort.Strings(updated)
+	app.summary.setUpdates(updated)
 
-	ws.BroadcastAuthenticated(app.WS, chanUpdates, updated)
+	releases, err := app.ImageUpdates.AllReleases()
+	if err != nil {
+		slog.Warn("broadcastUpdates releases", "err", err)
+		releases = map[string]models.ReleaseUpdate{}
+	}
+	return updatesPayload{Keys: updated, Releases: releases}
+}
+
+// broadcastUpdates broadcasts services with image updates and release classifications.
+func (app *App) broadcastUpdates() {
+	ws.BroadcastAuthenticated(app.WS, chanUpdates, app.buildUpdatesPayload())
 	app.BcastMetrics.recordSent(chanUpdates)
+	app.refreshStackListSubs() // has-updates filters
+}
+
+// stackBroadcastEntries builds the stacks payload and flags stacks over
+// their resource budget.
+func (app *App) stackBroadcastEntries() []StackBroadcastEntry {
+	entries := buildStackBroadcast(app.StacksDir)
+	if ignore := app.ignoreList(); ignore != nil {
+		kept := entries[:0]
+		for _, e := range entries {
+			if !ignore.ignoresProject(e.Name) {
+				kept = append(kept, e)
+			}
+		}
+		entries = kept
+	}
+	if over := app.overBudgetStacks(); len(over) > 0 {
+		for i := range entries {
+			entries[i].OverBudget = over[entries[i].Name]
+		}
+	}
+	if archived := app.archivedStacks(); len(archived) > 0 {
+		for i := range entries {
+			entries[i].Archived = archived[entries[i].Name]
+		}
+	}
+	if app.Projects != nil {
+		if conflicts := app.Projects.Conflicts(); len(conflicts) > 0 {
+			for i := range entries {
+				entries[i].ProjectConflict = conflicts[entries[i].Name]
+			}
+		}
+	}
+	return entries
 }
 
 // buildStackBroadcast scans the stacks directory and builds the broadcast payload.
@@ -423,7 +535,7 @@ func buildStackBroadcast(stacksDir string) []StackBroadcastEntry {
 
 	result := make([]StackBroadcastEntry, 0, len(entries))
 	for _, entry := range entries {
-		if !entry.IsDir() {
+		if !stack.IsDirEntry(stacksDir, entry) {
 			continue
 		}
 		name := entry.Name()
@@ -434,7 +546,7 @@ func buildStackBroadcast(stacksDir string) []StackBroadcastEntry {
 
 		services := compose.ParseFile(composeFile)
 		images := make(map[string]string, len(services))
-		var ignoreStatus map[string]bool
+		var ignoreStatus, jobs map[string]bool
 		for svc, sd := range services {
 			if sd.Image != "" {
 				images[svc] = sd.Image
@@ -445,12 +557,19 @@ func buildStackBroadcast(stacksDir string) []StackBroadcastEntry {
 				}
 				ignoreStatus[svc] = true
 			}
+			if sd.IsJob() {
+				if jobs == nil {
+					jobs = make(map[string]bool)
+				}
+				jobs[svc] = true
+			}
 		}
 
 		result = append(result, StackBroadcastEntry{
 			Name:              name,
 			ComposeFileName:   filepath.Base(composeFile),
 			IgnoreStatus:      ignoreStatus,
+			Jobs:              jobs,
 			Images:            images,
 			IsManagedByDockge: true,
 		})
@@ -470,6 +589,7 @@ func (app *App) InitBroadcast() {
 	app.dispatchCh = make(chan dispatchWork, 64)
 	app.BcastMetrics = newBroadcastMetrics()
 	app.EventBus = NewEventBus()
+	app.Followers = NewFollowerRegistry()
 }
 
 // StartBroadcastWatcher starts the event-driven broadcast system.
@@ -478,9 +598,11 @@ func (app *App) StartBroadcastWatcher(ctx context.Context) {
 	slog.Info("broadcast watcher started")
 	go app.runDispatchWorker(ctx)
 	go app.runBroadcastWatcherLoop(ctx)
+	go app.runFallbackRefresh(ctx)
 }
 
-// Coalescing parameters for the dispatch worker.
+// Default coalescing parameters for the dispatch worker, overridden by the
+// eventDebounceMs and eventMaxBatchMs settings (see broadcastTuning).
 const (
 	// dispatchQuietPeriod is how long to wait for more events before
 	// processing a batch. Resets on each new event.
@@ -515,8 +637,9 @@ func (app *App) runDispatchWorker(ctx context.Context) {
 			events = append(events, first.evt)
 		}
 
-		quiet := time.NewTimer(dispatchQuietPeriod)
-		deadline := time.NewTimer(dispatchMaxBatch)
+		tuning := app.broadcastTuning()
+		quiet := time.NewTimer(tuning.QuietPeriod)
+		deadline := time.NewTimer(tuning.MaxBatch)
 
 	collect:
 		for {
@@ -538,7 +661,7 @@ func (app *App) runDispatchWorker(ctx context.Context) {
 					default:
 					}
 				}
-				quiet.Reset(dispatchQuietPeriod)
+				quiet.Reset(tuning.QuietPeriod)
 			case <-quiet.C:
 				break collect
 			case <-deadline.C:
@@ -548,6 +671,7 @@ func (app *App) runDispatchWorker(ctx context.Context) {
 
 		quiet.Stop()
 		deadline.Stop()
+		app.BcastMetrics.recordBatch(len(events))
 
 		// Process: track specific resource IDs to query (deduped via map).
 		// Full syncs override filtered queries for that channel.
@@ -556,6 +680,9 @@ func (app *App) runDispatchWorker(ctx context.Context) {
 		affectedImageIDs := make(map[string]bool)
 		affectedVolumeNames := make(map[string]bool)
 
+		// Projects with a container whose health changed
+		healthProjects := make(map[string]bool)
+
 		var destroyedContainers []string
 		var destroyedNetworks []string
 		var destroyedImages []string
@@ -564,6 +691,12 @@ func (app *App) runDispatchWorker(ctx context.Context) {
 		for _, evt := range events {
 			switch evt.Type {
 			case "container":
+				// A health change refreshes the container's whole project,
+				// so the stack's status is derived from all of it at once
+				if strings.HasPrefix(evt.Action, "health_status") && evt.Project != "" {
+					healthProjects[evt.Project] = true
+					continue
+				}
 				if evt.ContainerID != "" {
 					affectedContainerIDs[evt.ContainerID] = true
 				}
@@ -631,6 +764,12 @@ func (app *App) runDispatchWorker(ctx context.Context) {
 			app.BcastMetrics.recordTriggered(chanContainers)
 			app.broadcastContainersMap()
 		}
+		if !fullSyncs[chanContainers] {
+			for project := range healthProjects {
+				app.BcastMetrics.recordTriggered(chanContainers)
+				app.refreshProjectContainers(project)
+			}
+		}
 
 		if len(affectedNetworkIDs) > 0 || len(destroyedNetworks) > 0 {
 			app.BcastMetrics.recordTriggered(chanNetworks)
@@ -760,19 +899,41 @@ func (app *App) consumeBroadcastEvents(ctx context.Context, eventCh <-chan docke
 
 			// Fan out to per-terminal subscribers
 			app.EventBus.Publish(evt)
+			app.recordStackEvent(evt)
+
+			// Ignored projects and containers never reach the clients
+			if app.ignoreList().ignores(evt.Project, evt.Labels) {
+				app.BcastMetrics.recordEvent(evt, false)
+				continue
+			}
+			app.recordFeedEvent(evt)
+			app.notifyDockerEvent(evt)
 
 			if !app.WS.HasAuthenticatedConns() {
+				app.BcastMetrics.recordEvent(evt, false)
+				// Nobody is listening, so nothing is broadcast: cached
+				// state may no longer match Docker.
+				app.replay.invalidate(chanContainers, chanNetworks, chanImages, chanVolumes)
 				continue
 			}
 
 			// Broadcast the event on the dedicated resourceEvent channel
 			resEvt := toResourceEvent(evt)
 			ws.BroadcastAuthenticated(app.WS, chanResourceEvent, resEvt)
+			if evt.Type == "container" && evt.Action == "health_status: unhealthy" {
+				ws.BroadcastAuthenticated(app.WS, "stackUnhealthy", struct {
+					StackName   string `json:"stackName"`
+					ServiceName string `json:"serviceName"`
+					Container   string `json:"container"`
+				}{evt.Project, evt.Service, evt.Name})
+			}
 
 			// Send to dispatch channel for authoritative list-based broadcast
 			select {
 			case app.dispatchCh <- dispatchWork{evt: evt}:
+				app.BcastMetrics.recordEvent(evt, false)
 			default:
+				app.BcastMetrics.recordEvent(evt, true)
 				slog.Warn("dispatch channel full, dropping event", "type", evt.Type, "action", evt.Action)
 			}
 
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/docker"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// Settings keys that tune how hard Docker events hit the server, for busy
+// hosts such as CI runners that create containers constantly.
+const (
+	settingEventDebounce        = "eventDebounceMs"      // quiet period before a batch of events is processed
+	settingEventMaxBatch        = "eventMaxBatchMs"      // longest a batch collects events while they keep coming
+	settingStackRefreshInterval = "stackRefreshInterval" // seconds between fallback full refreshes, 0 = off
+)
+
+// defaultStackRefreshInterval is how often stacks and containers are fully
+// re-listed in case an event was missed or dropped.
+const defaultStackRefreshInterval = 60 * time.Second
+
+// Bounds of the tuning settings. Values outside them are clamped.
+const (
+	minEventDebounce        = 10 * time.Millisecond
+	maxEventDebounce        = 10 * time.Second
+	maxEventMaxBatch        = 30 * time.Second
+	minStackRefreshInterval = 10 * time.Second
+	maxStackRefreshInterval = 24 * time.Hour
+)
+
+// broadcastTuning is the current event handling configuration.
+type broadcastTuning struct {
+	QuietPeriod     time.Duration
+	MaxBatch        time.Duration
+	RefreshInterval time.Duration // 0 = no fallback refresh
+}
+
+// settingMillis reads a setting in milliseconds, or def if unset or invalid.
+func (app *App) settingMillis(key string, def time.Duration) time.Duration {
+	val, err := app.Settings.Get(key)
+	if err != nil || val == "" {
+		return def
+	}
+	ms, err := strconv.Atoi(val)
+	if err != nil || ms <= 0 {
+		return def
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// broadcastTuning reads the tuning settings, re-read on every batch and
+// refresh so changes apply without a restart.
+func (app *App) broadcastTuning() broadcastTuning {
+	t := broadcastTuning{
+		QuietPeriod:     app.settingMillis(settingEventDebounce, dispatchQuietPeriod),
+		MaxBatch:        app.settingMillis(settingEventMaxBatch, dispatchMaxBatch),
+		RefreshInterval: defaultStackRefreshInterval,
+	}
+	t.QuietPeriod = min(max(t.QuietPeriod, minEventDebounce), maxEventDebounce)
+	t.MaxBatch = min(max(t.MaxBatch, t.QuietPeriod), maxEventMaxBatch)
+
+	if val, err := app.Settings.Get(settingStackRefreshInterval); err == nil && val != "" {
+		if secs, err := strconv.Atoi(val); err == nil && secs >= 0 {
+			t.RefreshInterval = time.Duration(secs) * time.Second
+			if secs > 0 {
+				t.RefreshInterval = min(max(t.RefreshInterval, minStackRefreshInterval), maxStackRefreshInterval)
+			}
+		}
+	}
+	return t
+}
+
+// runFallbackRefresh fully re-broadcasts stacks and containers every
+// stackRefreshInterval, to recover from Docker events that were missed
+// (event stream reconnects) or dropped (dispatch channel full).
+func (app *App) runFallbackRefresh(ctx context.Context) {
+	for {
+		interval := app.broadcastTuning().RefreshInterval
+		wait := interval
+		if wait == 0 {
+			wait = time.Minute // off: check again for the setting to change
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		if interval == 0 || !app.WS.HasAuthenticatedConns() {
+			continue
+		}
+		app.BcastMetrics.recordFallbackRefresh()
+		app.TriggerStacksBroadcast()
+		app.TriggerContainersBroadcast()
+	}
+}
+
+// eventRateWindow is how far back BroadcastMetrics computes event rates.
+const eventRateWindow = 60
+
+// eventMetrics counts Docker events and dispatch batches.
+type eventMetrics struct {
+	mu                sync.Mutex
+	total             int64
+	dropped           int64
+	byType            map[string]int64
+	batches           int64
+	batchedEvents     int64
+	fallbackRefreshes int64
+	// Per-second event counts of the last eventRateWindow seconds, indexed
+	// by Unix second modulo the window.
+	perSecond [eventRateWindow]int64
+	seconds   [eventRateWindow]int64
+}
+
+// EventStats is a snapshot of the event metrics.
+type EventStats struct {
+	Total             int64            `json:"total"`
+	Dropped           int64            `json:"dropped"` // dispatch channel was full
+	ByType            map[string]int64 `json:"byType"`
+	PerMinute         int64            `json:"perMinute"` // events in the last minute
+	Batches           int64            `json:"batches"`
+	AvgBatchSize      float64          `json:"avgBatchSize"`
+	FallbackRefreshes int64            `json:"fallbackRefreshes"`
+}
+
+func (bm *BroadcastMetrics) recordEvent(evt docker.DockerEvent, dropped bool) {
+	now := time.Now().Unix()
+	em := &bm.events
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	em.total++
+	if dropped {
+		em.dropped++
+	}
+	if em.byType == nil {
+		em.byType = make(map[string]int64)
+	}
+	em.byType[evt.Type]++
+	i := now % eventRateWindow
+	if em.seconds[i] != now {
+		em.seconds[i] = now
+		em.perSecond[i] = 0
+	}
+	em.perSecond[i]++
+}
+
+func (bm *BroadcastMetrics) recordBatch(events int) {
+	bm.events.mu.Lock()
+	bm.events.batches++
+	bm.events.batchedEvents += int64(events)
+	bm.events.mu.Unlock()
+}
+
+func (bm *BroadcastMetrics) recordFallbackRefresh() {
+	bm.events.mu.Lock()
+	bm.events.fallbackRefreshes++
+	bm.events.mu.Unlock()
+}
+
+// EventSnapshot returns the event metrics.
+func (bm *BroadcastMetrics) EventSnapshot() EventStats {
+	now := time.Now().Unix()
+	em := &bm.events
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	s := EventStats{
+		Total:             em.total,
+		Dropped:           em.dropped,
+		ByType:            make(map[string]int64, len(em.byType)),
+		Batches:           em.batches,
+		FallbackRefreshes: em.fallbackRefreshes,
+	}
+	for k, v := range em.byType {
+		s.ByType[k] = v
+	}
+	for i := range em.seconds {
+		if now-em.seconds[i] < eventRateWindow {
+			s.PerMinute += em.perSecond[i]
+		}
+	}
+	if em.batches > 0 {
+		s.AvgBatchSize = float64(em.batchedEvents) / float64(em.batches)
+	}
+	return s
+}
+
+// handleGetBroadcastStats reports the event and broadcast metrics with the
+// tuning in effect, to tune the settings against.
+func (app *App) handleGetBroadcastStats(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	t := app.broadcastTuning()
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK       bool                       `json:"ok"`
+			Events   EventStats                 `json:"events"`
+			Channels map[string]*ChannelMetrics `json:"channels"`
+			Tuning   map[string]int64           `json:"tuning"`
+		}{
+			OK:       true,
+			Events:   app.BcastMetrics.EventSnapshot(),
+			Channels: app.BcastMetrics.Snapshot(),
+			Tuning: map[string]int64{
+				settingEventDebounce:        t.QuietPeriod.Milliseconds(),
+				settingEventMaxBatch:        t.MaxBatch.Milliseconds(),
+				settingStackRefreshInterval: int64(t.RefreshInterval / time.Second),
+			},
+		})
+	}
+}
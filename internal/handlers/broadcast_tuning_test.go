@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/db"
+	"github.com/cfilipov/dockge/internal/docker"
+	"github.com/cfilipov/dockge/internal/models"
+)
+
+func TestBroadcastTuning(t *testing.T) {
+	t.Parallel()
+	database, err := db.Open(filepath.Join(t.TempDir(), "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	app := &App{Settings: models.NewSettingStore(database)}
+
+	want := broadcastTuning{QuietPeriod: dispatchQuietPeriod, MaxBatch: dispatchMaxBatch, RefreshInterval: defaultStackRefreshInterval}
+	if got := app.broadcastTuning(); got != want {
+		t.Errorf("defaults = %+v, want %+v", got, want)
+	}
+
+	app.Settings.Set(settingEventDebounce, "500")
+	app.Settings.Set(settingEventMaxBatch, "100") // below the debounce: raised to it
+	app.Settings.Set(settingStackRefreshInterval, "0")
+	want = broadcastTuning{QuietPeriod: 500 * time.Millisecond, MaxBatch: 500 * time.Millisecond}
+	if got := app.broadcastTuning(); got != want {
+		t.Errorf("tuned = %+v, want %+v", got, want)
+	}
+
+	app.Settings.Set(settingEventDebounce, "1")
+	app.Settings.Set(settingEventMaxBatch, "junk")
+	app.Settings.Set(settingStackRefreshInterval, "2")
+	want = broadcastTuning{QuietPeriod: minEventDebounce, MaxBatch: dispatchMaxBatch, RefreshInterval: minStackRefreshInterval}
+	if got := app.broadcastTuning(); got != want {
+		t.Errorf("clamped = %+v, want %+v", got, want)
+	}
+}
+
+func TestEventMetrics(t *testing.T) {
+	t.Parallel()
+	bm := newBroadcastMetrics()
+	bm.recordEvent(docker.DockerEvent{Type: "container"}, false)
+	bm.recordEvent(docker.DockerEvent{Type: "container"}, false)
+	bm.recordEvent(docker.DockerEvent{Type: "network"}, true)
+	bm.recordBatch(2)
+	bm.recordFallbackRefresh()
+
+	s := bm.EventSnapshot()
+	if s.Total != 3 || s.Dropped != 1 || s.PerMinute != 3 || s.ByType["container"] != 2 || s.ByType["network"] != 1 {
+		t.Errorf("event counts = %+v", s)
+	}
+	if s.Batches != 1 || s.AvgBatchSize != 2 || s.FallbackRefreshes != 1 {
+		t.Errorf("batch counts = %+v", s)
+	}
+}
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+const (
+	// budgetCheckInterval is how often running stacks with a budget are sampled.
+	budgetCheckInterval = time.Minute
+
+	// budgetStatsConcurrency bounds concurrent stats samples; each one makes
+	// the daemon wait for a second CPU reading.
+	budgetStatsConcurrency = 4
+)
+
+// StackUsage is the aggregated resource usage of a stack's running containers.
+type StackUsage struct {
+	CPUs       float64 `json:"cpus"`   // cores
+	Memory     uint64  `json:"memory"` // bytes
+	Containers int     `json:"containers"`
+	OverBudget bool    `json:"overBudget"`
+	CheckedAt  int64   `json:"checkedAt"` // Unix seconds
+}
+
+// budgetState holds the latest usage sample per budgeted stack.
+type budgetState struct {
+	mu    sync.Mutex
+	usage map[string]StackUsage
+}
+
+// RegisterBudgetHandlers registers stack resource budget handlers.
+func RegisterBudgetHandlers(app *App) {
+	app.WS.Handle("getStackBudgets", app.handleGetStackBudgets)
+	app.WS.Handle("setStackBudget", app.handleSetStackBudget)
+}
+
+func (app *App) handleGetStackBudgets(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	if app.Budgets == nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Budgets are not available"})
+		}
+		return
+	}
+	budgets, err := app.Budgets.List()
+	if err != nil {
+		slog.Error("list stack budgets", "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+
+	app.budgetState.mu.Lock()
+	usage := make(map[string]StackUsage, len(app.budgetState.usage))
+	for k, v := range app.budgetState.usage {
+		usage[k] = v
+	}
+	app.budgetState.mu.Unlock()
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK      bool                  `json:"ok"`
+			Budgets []models.StackBudget  `json:"budgets"`
+			Usage   map[string]StackUsage `json:"usage"`
+		}{
+			OK:      true,
+			Budgets: budgets,
+			Usage:   usage,
+		})
+	}
+}
+
+func (app *App) handleSetStackBudget(c *ws.Conn, msg *ws.ClientMessage) {
+	if app.checkAdmin(c, msg) == nil {
+		return
+	}
+	if app.Budgets == nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Budgets are not available"})
+		}
+		return
+	}
+	args := parseArgs(msg)
+	stackName := argString(args, 0)
+	var data struct {
+		MaxMemory uint64  `json:"maxMemory"`
+		MaxCPUs   float64 `json:"maxCPUs"`
+	}
+	if err := stack.ValidateStackName(stackName); err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	if !argObject(args, 1, &data) || data.MaxCPUs < 0 {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Invalid budget"})
+		}
+		return
+	}
+
+	budget := models.StackBudget{StackName: stackName, MaxMemory: data.MaxMemory, MaxCPUs: data.MaxCPUs}
+	if err := app.Budgets.Set(budget); err != nil {
+		slog.Error("set stack budget", "err", err, "stack", stackName)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+	slog.Info("stack budget set", "stack", stackName, "maxMemory", data.MaxMemory, "maxCPUs", data.MaxCPUs)
+
+	// Re-evaluate right away so the flag doesn't lag a minute behind.
+	go app.checkBudgets(context.Background())
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Saved"})
+	}
+}
+
+// StartBudgetMonitor periodically samples stacks that have a budget and
+// flags the ones over it.
+func (app *App) StartBudgetMonitor(ctx context.Context) {
+	if app.Budgets == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(budgetCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				app.checkBudgets(ctx)
+			}
+		}
+	}()
+}
+
+// checkBudgets samples every budgeted stack and records its usage. Stacks
+// that newly exceed their budget raise a "stackBudgetExceeded" event; any
+// change in the over-budget set triggers a stacks broadcast.
+func (app *App) checkBudgets(ctx context.Context) {
+	if !app.statsSupported(ctx) {
+		return // nothing to sample; see getDaemonInfo
+	}
+	budgets, err := app.Budgets.List()
+	if err != nil {
+		slog.Warn("budget check: list", "err", err)
+		return
+	}
+
+	usage := make(map[string]StackUsage, len(budgets))
+	for _, b := range budgets {
+		u, err := app.sampleStackUsage(ctx, b.StackName)
+		if err != nil {
+			slog.Warn("budget check: sample", "stack", b.StackName, "err", err)
+			continue
+		}
+		u.OverBudget = b.Exceeded(u.CPUs, u.Memory)
+		usage[b.StackName] = u
+	}
+
+	app.budgetState.mu.Lock()
+	prev := app.budgetState.usage
+	app.budgetState.usage = usage
+	app.budgetState.mu.Unlock()
+
+	changed := len(prev) != len(usage)
+	for _, b := range budgets {
+		u, ok := usage[b.StackName]
+		if !ok {
+			continue
+		}
+		was := prev[b.StackName].OverBudget
+		if u.OverBudget != was {
+			changed = true
+		}
+		if u.OverBudget && !was {
+			slog.Warn("stack over budget", "stack", b.StackName,
+				"cpus", u.CPUs, "maxCPUs", b.MaxCPUs, "memory", u.Memory, "maxMemory", b.MaxMemory)
+			ws.BroadcastAuthenticated(app.WS, "stackBudgetExceeded", struct {
+				StackName string             `json:"stackName"`
+				Usage     StackUsage         `json:"usage"`
+				Budget    models.StackBudget `json:"budget"`
+			}{b.StackName, u, b})
+		}
+	}
+	if changed {
+		app.TriggerStacksBroadcast()
+	}
+}
+
+// sampleStackUsage sums CPU and memory across a stack's running containers.
+func (app *App) sampleStackUsage(ctx context.Context, stackName string) (StackUsage, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	containers, err := app.Docker.ContainerList(ctx, false, stackName)
+	if err != nil {
+		return StackUsage{}, err
+	}
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, budgetStatsConcurrency)
+		u   = StackUsage{CheckedAt: time.Now().Unix()}
+	)
+	for _, ctr := range containers {
+		if ctr.State != "running" {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(id string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			s, err := app.Docker.ContainerStatsOnce(ctx, id)
+			if err != nil {
+				slog.Debug("budget check: stats", "container", id, "err", err)
+				return
+			}
+			mu.Lock()
+			u.CPUs += s.CPUPercent / 100
+			u.Memory += s.MemBytes
+			u.Containers++
+			mu.Unlock()
+		}(ctr.ID)
+	}
+	wg.Wait()
+	return u, nil
+}
+
+// overBudgetStacks returns the names of stacks currently over budget.
+func (app *App) overBudgetStacks() map[string]bool {
+	app.budgetState.mu.Lock()
+	defer app.budgetState.mu.Unlock()
+	over := make(map[string]bool)
+	for name, u := range app.budgetState.usage {
+		if u.OverBudget {
+			over[name] = true
+		}
+	}
+	return over
+}
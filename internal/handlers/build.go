@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/terminal"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// buildTimeout bounds building a stack's images, which takes much longer
+// than starting containers.
+const buildTimeout = 30 * time.Minute
+
+// buildOptions are the options of a stack build.
+type buildOptions struct {
+	Pull    bool `json:"pull"`    // pull newer base images
+	NoCache bool `json:"noCache"` // don't use the build cache
+}
+
+// args returns the compose arguments for the build.
+func (o buildOptions) args() []string {
+	args := []string{"build"}
+	if o.Pull {
+		args = append(args, "--pull")
+	}
+	if o.NoCache {
+		args = append(args, "--no-cache")
+	}
+	return args
+}
+
+// buildError marks a failure to build a stack's images, as opposed to a
+// failure to start its containers.
+type buildError struct {
+	err error
+}
+
+func (e *buildError) Error() string { return "build failed: " + e.err.Error() }
+func (e *buildError) Unwrap() error { return e.err }
+
+// isBuildError reports whether err is a failed image build.
+func isBuildError(err error) bool {
+	var be *buildError
+	return errors.As(err, &be)
+}
+
+// handleBuildStack builds the images of a stack's services with build:
+// contexts, without starting anything. The ack is sent once the build is
+// done. Args: stackName, {pull, noCache}.
+func (app *App) handleBuildStack(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	args := parseArgs(msg)
+	stackName := argString(args, 0)
+	if err := stack.ValidateStackName(stackName); err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	if app.rejectArchived(c, msg, stackName) {
+		return
+	}
+	if !app.isStackManaged(stackName) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Cannot build: stack is not managed by Dockge"})
+		}
+		return
+	}
+	var opts buildOptions
+	argObject(args, 1, &opts)
+
+	go func() {
+		app.StackLocks.Lock(stackName)
+		defer app.StackLocks.Unlock(stackName)
+		err := app.runStackBuild(stackName, opts)
+		if msg.ID == nil {
+			return
+		}
+		if err != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "buildFailed", MsgI18n: true})
+			return
+		}
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Built"})
+	}()
+}
+
+// runStackBuild runs `docker compose build` on the stack's compose terminal
+// and records it in the operation history. The caller holds the stack lock.
+func (app *App) runStackBuild(stackName string, opts buildOptions) error {
+	termName := "compose-" + stackName
+
+	ctx, cancel := context.WithTimeout(context.Background(), buildTimeout)
+	defer cancel()
+
+	term := app.Terms.Recreate(termName, terminal.TypePTY)
+	op := app.beginOperation(stackName, "build", "")
+	// Build args can come from the env files, so their secrets are resolved
+	envArgs, cleanup, err := app.composeEnvArgs(ctx, stackName)
+	if err != nil {
+		term.Write([]byte("[Error] " + err.Error() + "\r\n"))
+		slog.Warn("build secrets", "stack", stackName, "err", err)
+		app.endOperation(op, err)
+		app.Terms.RemoveAfter(termName, 30*time.Second)
+		return err
+	}
+	defer cleanup()
+	err = app.runBuildStep(ctx, term, stackName, envArgs, opts)
+	if err == nil {
+		term.Write([]byte("\r\n[Done]\r\n"))
+	}
+	app.endOperation(op, err)
+
+	app.Terms.RemoveAfter(termName, 30*time.Second)
+	return err
+}
+
+// runBuildStep builds the stack's images on term. A failure is returned as
+// a *buildError and reported on the terminal as a build failure.
+func (app *App) runBuildStep(ctx context.Context, term *terminal.Terminal, stackName string, envArgs []string, opts buildOptions) error {
+	buildArgs := opts.args()
+	term.Write([]byte("$ docker " + strings.Join(composeEnvDisplay(append([]string{"compose"}, buildArgs...), envArgs), " ") + "\r\n"))
+	dir := filepath.Join(app.StacksDir, stackName)
+	err := app.runCompose(ctx, term, stackName, "build", dir, envArgs, buildArgs, nil)
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() == nil {
+		term.Write([]byte("\r\n[Build Failed] " + err.Error() + "\r\n"))
+		slog.Warn("stack build failed", "stack", stackName, "err", err)
+	}
+	return &buildError{err: err}
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/cfilipov/dockge/internal/compose"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// rejectInvalidComposeFiles acks an error and returns true if the
+// additional compose files of a save or deploy have an invalid or
+// duplicate name. files is nil when the request doesn't touch them.
+func rejectInvalidComposeFiles(c *ws.Conn, msg *ws.ClientMessage, files *[]stack.ComposeFile) bool {
+	if files == nil {
+		return false
+	}
+	seen := map[string]bool{}
+	for _, f := range *files {
+		err := compose.ValidateExtraFileName(f.Name)
+		if err == nil && seen[f.Name] {
+			err = fmt.Errorf("compose file %s: listed twice", f.Name)
+		}
+		if err != nil {
+			if msg.ID != nil {
+				ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+			}
+			return true
+		}
+		seen[f.Name] = true
+	}
+	return false
+}
+
+// rejectComposeFilesApproval acks an error and returns true if a change that
+// needs approval edits the additional compose files: pending changes only
+// carry the compose, .env and override files.
+func (app *App) rejectComposeFilesApproval(c *ws.Conn, msg *ws.ClientMessage, stackName string, files *[]stack.ComposeFile) bool {
+	if files == nil {
+		return false
+	}
+	current := &stack.Stack{Name: stackName}
+	current.LoadFromDisk(app.StacksDir)
+	if slices.Equal(current.ExtraFiles, *files) {
+		return false
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Changes to additional compose files can't be submitted for approval"})
+	}
+	return true
+}
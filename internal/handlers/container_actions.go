@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// containerActionTimeout bounds a kill, pause, remove or rename.
+const containerActionTimeout = 30 * time.Second
+
+// containerNameRe is the daemon's rule for container names.
+var containerNameRe = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+
+// killSignals are the signals killContainer may send.
+var killSignals = map[string]bool{
+	"SIGKILL": true, "SIGTERM": true, "SIGINT": true, "SIGHUP": true,
+	"SIGQUIT": true, "SIGUSR1": true, "SIGUSR2": true,
+}
+
+// containerRef identifies the container of an action.
+type containerRef struct {
+	ID    string
+	Name  string
+	Stack string // compose project, "" for a standalone container
+}
+
+// lookupContainer resolves a container name or ID.
+func (app *App) lookupContainer(ctx context.Context, nameOrID string) (containerRef, error) {
+	raw, err := app.Docker.ContainerInspect(ctx, nameOrID)
+	if err != nil {
+		return containerRef{}, err
+	}
+	var inspect struct {
+		ID     string `json:"Id"`
+		Name   string `json:"Name"`
+		Config struct {
+			Labels map[string]string `json:"Labels"`
+		} `json:"Config"`
+	}
+	if err := json.Unmarshal(raw, &inspect); err != nil {
+		return containerRef{}, fmt.Errorf("decode container inspect: %w", err)
+	}
+	return containerRef{
+		ID:    inspect.ID,
+		Name:  strings.TrimPrefix(inspect.Name, "/"),
+		Stack: inspect.Config.Labels["com.docker.compose.project"],
+	}, nil
+}
+
+// refreshAfterContainerAction broadcasts the state of a container after an
+// action, and of its stack's other containers. gone lists names the
+// container no longer has: its old name, or its name once removed.
+func (app *App) refreshAfterContainerAction(ref containerRef, removed bool, gone ...string) {
+	ids := map[string]bool{}
+	if !removed {
+		ids[ref.ID] = true
+	}
+	if len(ids) > 0 {
+		app.broadcastContainersByIDs(ids, gone)
+	} else if len(gone) > 0 && app.WS.HasAuthenticatedConns() {
+		m := make(map[string]any, len(gone))
+		for _, name := range gone {
+			m[name] = nil
+		}
+		app.broadcastChannel(chanContainers, m)
+	}
+	if ref.Stack != "" {
+		app.refreshProjectContainers(ref.Stack)
+		app.TriggerStacksBroadcast()
+	}
+}
+
+// containerAction runs an SDK action on a container and acks its outcome.
+// Returns the container, or false if the lookup or the action failed.
+func (app *App) containerAction(c *ws.Conn, msg *ws.ClientMessage, containerName, action string, run func(ctx context.Context, id string) error) (containerRef, bool) {
+	fail := func(err error) {
+		slog.Warn("container action", "action", action, "container", containerName, "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), containerActionTimeout)
+	defer cancel()
+	ref, err := app.lookupContainer(ctx, containerName)
+	if err != nil {
+		fail(err)
+		return ref, false
+	}
+	if err := run(ctx, ref.ID); err != nil {
+		fail(err)
+		return ref, false
+	}
+	if user := app.currentUser(c); user != nil {
+		slog.Info("container "+action, "container", ref.Name, "stack", ref.Stack, "by", user.Username)
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true})
+	}
+	return ref, true
+}
+
+// containerNameArg reads the container name argument, acking an error if
+// it's missing.
+func containerNameArg(c *ws.Conn, msg *ws.ClientMessage, args []json.RawMessage) (string, bool) {
+	containerName := argString(args, 0)
+	if containerName == "" {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Container name required"})
+		}
+		return "", false
+	}
+	return containerName, true
+}
+
+// handleKillContainer sends a signal to a container. Args: container name,
+// signal ("" for SIGKILL).
+func (app *App) handleKillContainer(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	args := parseArgs(msg)
+	containerName, ok := containerNameArg(c, msg, args)
+	if !ok {
+		return
+	}
+	signal := strings.ToUpper(argString(args, 1))
+	if signal == "" {
+		signal = "SIGKILL"
+	}
+	if !killSignals[signal] {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Unsupported signal " + signal})
+		}
+		return
+	}
+	ref, ok := app.containerAction(c, msg, containerName, "kill", func(ctx context.Context, id string) error {
+		return app.Docker.ContainerKill(ctx, id, signal)
+	})
+	if ok {
+		go app.refreshAfterContainerAction(ref, false)
+	}
+}
+
+// handlePauseContainer freezes a container. Args: container name.
+func (app *App) handlePauseContainer(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	containerName, ok := containerNameArg(c, msg, parseArgs(msg))
+	if !ok {
+		return
+	}
+	if ref, ok := app.containerAction(c, msg, containerName, "pause", app.Docker.ContainerPause); ok {
+		go app.refreshAfterContainerAction(ref, false)
+	}
+}
+
+// handleUnpauseContainer resumes a paused container. Args: container name.
+func (app *App) handleUnpauseContainer(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	containerName, ok := containerNameArg(c, msg, parseArgs(msg))
+	if !ok {
+		return
+	}
+	if ref, ok := app.containerAction(c, msg, containerName, "unpause", app.Docker.ContainerUnpause); ok {
+		go app.refreshAfterContainerAction(ref, false)
+	}
+}
+
+// handleRemoveContainer removes a container; force removes a running one.
+// Removing its anonymous volumes too loses their data, so that requires
+// sudo. Args: container name, {force, volumes}.
+func (app *App) handleRemoveContainer(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	args := parseArgs(msg)
+	containerName, ok := containerNameArg(c, msg, args)
+	if !ok {
+		return
+	}
+	var opts struct {
+		Force   bool `json:"force"`
+		Volumes bool `json:"volumes"`
+	}
+	argObject(args, 1, &opts)
+	if opts.Volumes && !app.requireSudo(c, msg) {
+		return
+	}
+	ref, ok := app.containerAction(c, msg, containerName, "remove", func(ctx context.Context, id string) error {
+		return app.Docker.ContainerRemove(ctx, id, opts.Force, opts.Volumes)
+	})
+	if ok {
+		go app.refreshAfterContainerAction(ref, true, ref.Name)
+	}
+}
+
+// handleRenameContainer renames a container. Args: container name, new name.
+func (app *App) handleRenameContainer(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	args := parseArgs(msg)
+	containerName, ok := containerNameArg(c, msg, args)
+	if !ok {
+		return
+	}
+	newName := strings.TrimPrefix(argString(args, 1), "/")
+	if !containerNameRe.MatchString(newName) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: fmt.Sprintf("Invalid container name %q", newName)})
+		}
+		return
+	}
+	ref, ok := app.containerAction(c, msg, containerName, "rename", func(ctx context.Context, id string) error {
+		return app.Docker.ContainerRename(ctx, id, newName)
+	})
+	if ok && ref.Name != newName {
+		go app.refreshAfterContainerAction(ref, false, ref.Name)
+	}
+}
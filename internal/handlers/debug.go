@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"log/slog"
+
+	"github.com/cfilipov/dockge/internal/debug"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// RegisterDebugHandlers registers handlers for listing, downloading, and
+// manually capturing watchdog profiles, for resource lifecycle stats, for
+// WebSocket server stats, and for the daemon info.
+func RegisterDebugHandlers(app *App) {
+	app.WS.Handle("getDebugProfileList", app.handleGetDebugProfileList)
+	app.WS.Handle("getDebugProfile", app.handleGetDebugProfile)
+	app.WS.Handle("captureDebugProfile", app.handleCaptureDebugProfile)
+	app.WS.Handle("getLifecycleStats", app.handleGetLifecycleStats)
+	app.WS.Handle("getBroadcastStats", app.handleGetBroadcastStats)
+	app.WS.Handle("getWSStats", app.handleGetWSStats)
+	app.WS.Handle("getDaemonInfo", app.handleGetDaemonInfo)
+}
+
+func (app *App) handleGetDebugProfileList(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	if app.Profiles == nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Profile watchdog is not enabled"})
+		}
+		return
+	}
+
+	profiles, err := app.Profiles.List()
+	if err != nil {
+		slog.Warn("list debug profiles", "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK       bool                `json:"ok"`
+			Profiles []debug.ProfileInfo `json:"profiles"`
+		}{
+			OK:       true,
+			Profiles: profiles,
+		})
+	}
+}
+
+// handleGetDebugProfile returns a captured profile base64-encoded so the
+// frontend can offer it as a file download over the existing WebSocket.
+func (app *App) handleGetDebugProfile(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	args := parseArgs(msg)
+	name := argString(args, 0)
+	if name == "" {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Profile name required"})
+		}
+		return
+	}
+	if app.Profiles == nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Profile watchdog is not enabled"})
+		}
+		return
+	}
+
+	data, err := app.Profiles.Read(name)
+	if err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK   bool   `json:"ok"`
+			Name string `json:"name"`
+			Data string `json:"data"` // base64
+		}{
+			OK:   true,
+			Name: name,
+			Data: base64.StdEncoding.EncodeToString(data),
+		})
+	}
+}
+
+func (app *App) handleCaptureDebugProfile(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	if app.Profiles == nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Profile watchdog is not enabled"})
+		}
+		return
+	}
+
+	names, err := app.Profiles.Capture()
+	if err != nil {
+		slog.Error("capture debug profile", "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK    bool     `json:"ok"`
+			Names []string `json:"names"`
+		}{
+			OK:    true,
+			Names: names,
+		})
+	}
+}
+
+// handleGetLifecycleStats reports running log followers, EventBus
+// subscriptions, and terminals with their ages, for diagnosing leaks.
+func (app *App) handleGetLifecycleStats(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK bool `json:"ok"`
+			LifecycleSnapshot
+		}{
+			OK:                true,
+			LifecycleSnapshot: app.LifecycleSnapshot(),
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/compose"
+	"github.com/cfilipov/dockge/internal/docker"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+const composeProjectLabel = "com.docker.compose.project"
+
+// stackDependent is another stack that uses a network or volume created by
+// the stack being deleted.
+type stackDependent struct {
+	Stack string `json:"stack"`
+	Kind  string `json:"kind"` // "network" or "volume"
+	Name  string `json:"name"`
+}
+
+// stackDependents lists the stacks that would break if stackName were taken
+// down: those with containers attached to its networks, or whose compose
+// file references them as external. Volumes are only considered with
+// withVolumes, since a plain `down` keeps them.
+func (app *App) stackDependents(stackName string, withVolumes bool) []stackDependent {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Resources the stack's compose project created
+	project := compose.ProjectName(filepath.Join(app.StacksDir, stackName))
+	networks := make(map[string]bool)
+	volumes := make(map[string]bool)
+	if list, err := app.Docker.NetworkList(ctx); err == nil {
+		for _, n := range list {
+			if n.Labels[composeProjectLabel] == project {
+				networks[n.Name] = true
+			}
+		}
+	} else {
+		slog.Warn("stack dependents: list networks", "stack", stackName, "err", err)
+	}
+	if withVolumes {
+		if list, err := app.Docker.VolumeList(ctx); err == nil {
+			for _, v := range list {
+				if v.Labels[composeProjectLabel] == project {
+					volumes[v.Name] = true
+				}
+			}
+		} else {
+			slog.Warn("stack dependents: list volumes", "stack", stackName, "err", err)
+		}
+	}
+	if len(networks) == 0 && len(volumes) == 0 {
+		return nil
+	}
+
+	containers, err := app.Docker.ContainerListDetailed(ctx)
+	if err != nil {
+		slog.Warn("stack dependents: list containers", "stack", stackName, "err", err)
+	}
+	external := make(map[string]compose.ExternalResources)
+	if entries, err := os.ReadDir(app.StacksDir); err == nil {
+		for _, entry := range entries {
+			if entry.Name() == stackName || !stack.IsDirEntry(app.StacksDir, entry) {
+				continue
+			}
+			if path := compose.FindComposeFile(app.StacksDir, entry.Name()); path != "" {
+				if data, err := os.ReadFile(path); err == nil {
+					external[entry.Name()] = compose.ParseExternalResources(string(data))
+				}
+			}
+		}
+	}
+	return findDependents(stackName, networks, volumes, containers, external)
+}
+
+// rejectDependents acks the request with the stack's dependents and returns
+// true if there are any, so the client can confirm the delete and retry with
+// ignoreDependents.
+func (app *App) rejectDependents(c *ws.Conn, msg *ws.ClientMessage, stackName string, withVolumes bool) bool {
+	deps := app.stackDependents(stackName, withVolumes)
+	if len(deps) == 0 {
+		return false
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK         bool             `json:"ok"`
+			Msg        string           `json:"msg"`
+			MsgI18n    bool             `json:"msgi18n"`
+			Dependents []stackDependent `json:"dependents"`
+		}{OK: false, Msg: "stackHasDependents", MsgI18n: true, Dependents: deps})
+	}
+	return true
+}
+
+// findDependents matches the given networks and volumes of stackName against
+// other stacks' containers and external references, sorted and deduplicated.
+func findDependents(stackName string, networks, volumes map[string]bool, containers []docker.ContainerBroadcast, external map[string]compose.ExternalResources) []stackDependent {
+	seen := make(map[stackDependent]bool)
+	add := func(d stackDependent) {
+		if d.Stack != "" && d.Stack != stackName {
+			seen[d] = true
+		}
+	}
+	for _, ctr := range containers {
+		for name := range ctr.Networks {
+			if networks[name] {
+				add(stackDependent{Stack: ctr.StackName, Kind: "network", Name: name})
+			}
+		}
+		for _, m := range ctr.Mounts {
+			if m.Type == "volume" && volumes[m.Name] {
+				add(stackDependent{Stack: ctr.StackName, Kind: "volume", Name: m.Name})
+			}
+		}
+	}
+	for other, res := range external {
+		for _, name := range res.Networks {
+			if networks[name] {
+				add(stackDependent{Stack: other, Kind: "network", Name: name})
+			}
+		}
+		for _, name := range res.Volumes {
+			if volumes[name] {
+				add(stackDependent{Stack: other, Kind: "volume", Name: name})
+			}
+		}
+	}
+
+	deps := make([]stackDependent, 0, len(seen))
+	for d := range seen {
+		deps = append(deps, d)
+	}
+	sort.Slice(deps, func(i, j int) bool {
+		if deps[i].Stack != deps[j].Stack {
+			return deps[i].Stack < deps[j].Stack
+		}
+		if deps[i].Kind != deps[j].Kind {
+			return deps[i].Kind < deps[j].Kind
+		}
+		return deps[i].Name < deps[j].Name
+	})
+	return deps
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cfilipov/dockge/internal/compose"
+	"github.com/cfilipov/dockge/internal/docker"
+)
+
+func TestFindDependents(t *testing.T) {
+	t.Parallel()
+	networks := map[string]bool{"proxy": true, "proxy_default": true}
+	volumes := map[string]bool{"proxy_certs": true}
+	containers := []docker.ContainerBroadcast{
+		// The stack's own containers don't count
+		{StackName: "proxy", Networks: map[string]docker.ContainerNetwork{"proxy": {}}},
+		{StackName: "blog", Networks: map[string]docker.ContainerNetwork{"proxy": {}, "blog_default": {}}},
+		{StackName: "blog", Networks: map[string]docker.ContainerNetwork{"proxy": {}}},
+		{StackName: "backup", Mounts: []docker.ContainerMount{{Name: "proxy_certs", Type: "volume"}, {Name: "/srv", Type: "bind"}}},
+		// Unmanaged containers have no stack to warn about
+		{Networks: map[string]docker.ContainerNetwork{"proxy": {}}},
+	}
+	external := map[string]compose.ExternalResources{
+		"wiki": {Networks: []string{"proxy", "other"}},
+		"blog": {Networks: []string{"proxy"}},
+	}
+
+	got := findDependents("proxy", networks, volumes, containers, external)
+	want := []stackDependent{
+		{Stack: "backup", Kind: "volume", Name: "proxy_certs"},
+		{Stack: "blog", Kind: "network", Name: "proxy"},
+		{Stack: "wiki", Kind: "network", Name: "proxy"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"sort"
+
+	"github.com/cfilipov/dockge/internal/compose"
+	"github.com/cfilipov/dockge/internal/docker"
+)
+
+// serviceDependency is one depends_on edge with the live state of the
+// dependency, so the UI can explain why `up` is waiting on a service.
+type serviceDependency struct {
+	Service   string `json:"service"`   // the dependent service
+	DependsOn string `json:"dependsOn"` // the service it waits for
+	Condition string `json:"condition"`
+	State     string `json:"state"`  // dependency container state, "" if none
+	Health    string `json:"health"` // healthy, unhealthy, starting, or ""
+	Satisfied bool   `json:"satisfied"`
+}
+
+// dependencyGraph joins the depends_on entries of composeYAML with the
+// stack's containers. With several replicas, the first one that satisfies
+// the condition is reported.
+func dependencyGraph(composeYAML string, containers []docker.Container) []serviceDependency {
+	byService := make(map[string][]docker.Container)
+	for _, ctr := range containers {
+		byService[ctr.Service] = append(byService[ctr.Service], ctr)
+	}
+
+	deps := compose.ParseDependencies(composeYAML)
+	services := make([]string, 0, len(deps))
+	for service := range deps {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+
+	graph := []serviceDependency{}
+	for _, service := range services {
+		for _, dep := range deps[service] {
+			edge := serviceDependency{Service: service, DependsOn: dep.Service, Condition: dep.Condition}
+			for _, ctr := range byService[dep.Service] {
+				edge.State, edge.Health = ctr.State, ctr.Health
+				if edge.Satisfied = dependencySatisfied(dep.Condition, ctr); edge.Satisfied {
+					break
+				}
+			}
+			graph = append(graph, edge)
+		}
+	}
+	return graph
+}
+
+// dependencySatisfied reports whether ctr meets condition. The container
+// list has no exit code, so service_completed_successfully is approximated
+// by "exited".
+func dependencySatisfied(condition string, ctr docker.Container) bool {
+	switch condition {
+	case compose.DependsHealthy:
+		return ctr.State == "running" && ctr.Health == "healthy"
+	case compose.DependsCompleted:
+		return ctr.State == "exited"
+	default:
+		return ctr.State == "running"
+	}
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cfilipov/dockge/internal/docker"
+)
+
+func TestDependencyGraph(t *testing.T) {
+	t.Parallel()
+	yaml := `services:
+  web:
+    depends_on:
+      app:
+        condition: service_healthy
+      migrate:
+        condition: service_completed_successfully
+  app:
+    depends_on:
+      - db
+`
+	containers := []docker.Container{
+		{Service: "app", State: "running", Health: "starting"},
+		{Service: "db", State: "running"},
+		{Service: "migrate", State: "exited"},
+	}
+	got := dependencyGraph(yaml, containers)
+	want := []serviceDependency{
+		{Service: "app", DependsOn: "db", Condition: "service_started", State: "running", Satisfied: true},
+		{Service: "web", DependsOn: "app", Condition: "service_healthy", State: "running", Health: "starting"},
+		{Service: "web", DependsOn: "migrate", Condition: "service_completed_successfully", State: "exited", Satisfied: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %+v\nwant %+v", got, want)
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"log/slog"
+
+	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// RegisterDeployedComposeHandlers registers the handlers comparing a
+// stack's files with what was last deployed.
+func RegisterDeployedComposeHandlers(app *App) {
+	app.WS.Handle("getDeployedComposeDiff", app.handleGetDeployedComposeDiff)
+}
+
+// loadStackFiles reads a stack's compose, .env and override files.
+func (app *App) loadStackFiles(stackName string) *stack.Stack {
+	s := &stack.Stack{Name: stackName}
+	s.LoadFromDisk(app.StacksDir)
+	return s
+}
+
+// recordDeployedCompose keeps s, read before a deploy or start, as the
+// stack's deployed files. Call it once the action succeeded.
+func (app *App) recordDeployedCompose(s *stack.Stack, action string) {
+	if app.DeployedCompose == nil || s.ComposeYAML == "" {
+		return
+	}
+	err := app.DeployedCompose.Set(models.DeployedCompose{
+		StackName:           s.Name,
+		ComposeFileName:     s.ComposeFileName,
+		ComposeYAML:         s.ComposeYAML,
+		ComposeENV:          s.ComposeENV,
+		OverrideFileName:    s.ComposeOverrideFileName,
+		ComposeOverrideYAML: s.ComposeOverrideYAML,
+		Hash:                stackFilesHash(s),
+		Action:              action,
+	})
+	if err != nil {
+		slog.Warn("record deployed compose", "err", err, "stack", s.Name)
+	}
+}
+
+// deleteDeployedCompose drops the deployed files of a deleted stack.
+func (app *App) deleteDeployedCompose(stackName string) {
+	if app.DeployedCompose == nil {
+		return
+	}
+	if err := app.DeployedCompose.Delete(stackName); err != nil {
+		slog.Warn("delete deployed compose", "err", err, "stack", stackName)
+	}
+}
+
+// handleGetDeployedComposeDiff returns a unified diff from the files of a
+// stack's last successful deploy to its files on disk. deployed is false
+// when no deploy was recorded; changed is whether the files differ. Secret
+// .env values are masked on both sides for users masksEnvSecrets applies to.
+// Args: stack name.
+func (app *App) handleGetDeployedComposeDiff(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	stackName := argString(parseArgs(msg), 0)
+	if err := stack.ValidateStackName(stackName); err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+
+	type response struct {
+		OK         bool   `json:"ok"`
+		Deployed   bool   `json:"deployed"`
+		DeployedAt int64  `json:"deployedAt,omitempty"`
+		Changed    bool   `json:"changed"`
+		Diff       string `json:"diff"`
+	}
+	var snap *models.DeployedCompose
+	if app.DeployedCompose != nil {
+		var err error
+		if snap, err = app.DeployedCompose.Get(stackName); err != nil {
+			slog.Error("get deployed compose", "err", err, "stack", stackName)
+			if msg.ID != nil {
+				ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+			}
+			return
+		}
+	}
+	if snap == nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, response{OK: true})
+		}
+		return
+	}
+
+	deployed := &stack.Stack{
+		Name:                    stackName,
+		ComposeFileName:         snap.ComposeFileName,
+		ComposeYAML:             snap.ComposeYAML,
+		ComposeENV:              snap.ComposeENV,
+		ComposeOverrideFileName: snap.OverrideFileName,
+		ComposeOverrideYAML:     snap.ComposeOverrideYAML,
+	}
+	current := app.loadStackFiles(stackName)
+	changed := stackFilesHash(current) != snap.Hash
+	if app.masksEnvSecrets(c) {
+		deployed.ComposeENV = stack.MaskDotEnv(deployed.ComposeENV)
+		current.ComposeENV = stack.MaskDotEnv(current.ComposeENV)
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, response{
+			OK:         true,
+			Deployed:   true,
+			DeployedAt: snap.DeployedAt,
+			Changed:    changed,
+			Diff:       stackFilesDiff(deployed, current),
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// Adoption modes for adoptComposeProject.
+const (
+	adoptModeCopy = "copy" // copy compose files into the stacks directory
+	adoptModeLink = "link" // symlink the project directory as the stack
+)
+
+// RegisterDiscoveryHandlers registers the compose project discovery handlers.
+func RegisterDiscoveryHandlers(app *App) {
+	app.WS.Handle("discoverComposeProjects", app.handleDiscoverComposeProjects)
+	app.WS.Handle("adoptComposeProject", app.handleAdoptComposeProject)
+}
+
+// discoveryPaths reads the "composeDiscoveryPaths" setting, one host path
+// per line.
+func (app *App) discoveryPaths() []string {
+	val, err := app.Settings.Get("composeDiscoveryPaths")
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	for _, line := range strings.Split(val, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			paths = append(paths, line)
+		}
+	}
+	return paths
+}
+
+// discoverComposeProjects scans the configured paths and the compose labels
+// of all containers for projects outside the stacks directory, leaving out
+// those on the ignore list.
+func (app *App) discoverComposeProjects() []stack.DiscoveredProject {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	containers, err := app.Docker.ContainerList(ctx, true, "")
+	if err != nil {
+		// Path scanning still works without the daemon
+		slog.Warn("discover compose projects: list containers", "err", err)
+	}
+	ignore := app.ignoreList()
+	if ignore == nil {
+		return stack.DiscoverProjects(app.StacksDir, app.discoveryPaths(), containers)
+	}
+	kept := containers[:0]
+	for _, c := range containers {
+		if !ignore.ignores(c.Project, c.Labels) {
+			kept = append(kept, c)
+		}
+	}
+	projects := stack.DiscoverProjects(app.StacksDir, app.discoveryPaths(), kept)
+	result := projects[:0]
+	for _, p := range projects {
+		if !ignore.ignoresProject(p.Project) && !ignore.ignoresProject(p.Name) {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// handleDiscoverComposeProjects lists compose projects that can be adopted.
+func (app *App) handleDiscoverComposeProjects(c *ws.Conn, msg *ws.ClientMessage) {
+	if app.checkAdmin(c, msg) == nil {
+		return
+	}
+	projects := app.discoverComposeProjects()
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK       bool                      `json:"ok"`
+			Projects []stack.DiscoveredProject `json:"projects"`
+		}{OK: true, Projects: projects})
+	}
+}
+
+// handleAdoptComposeProject makes a discovered project a stack.
+// Args: project dir, stack name, mode ("copy" or "link").
+func (app *App) handleAdoptComposeProject(c *ws.Conn, msg *ws.ClientMessage) {
+	user := app.checkAdmin(c, msg)
+	if user == nil {
+		return
+	}
+	args := parseArgs(msg)
+	dir := argString(args, 0)
+	stackName := argString(args, 1)
+	mode := argString(args, 2)
+
+	fail := func(text string) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: text})
+		}
+	}
+	if mode != adoptModeCopy && mode != adoptModeLink {
+		fail("Invalid adoption mode")
+		return
+	}
+	if err := stack.ValidateStackName(stackName); err != nil {
+		fail(err.Error())
+		return
+	}
+
+	// Only directories discovery reports may be adopted, so arbitrary
+	// host paths can't be linked in
+	var project *stack.DiscoveredProject
+	for _, p := range app.discoverComposeProjects() {
+		if p.Dir == dir {
+			project = &p
+			break
+		}
+	}
+	if project == nil {
+		fail("Project not found. Scan again.")
+		return
+	}
+
+	app.StackLocks.Lock(stackName)
+	err := stack.AdoptProject(app.StacksDir, *project, stackName, mode == adoptModeLink)
+	app.StackLocks.Unlock(stackName)
+	if err != nil {
+		slog.Warn("adopt compose project", "err", err, "dir", dir, "stack", stackName)
+		fail(err.Error())
+		return
+	}
+	slog.Info("compose project adopted", "dir", dir, "stack", stackName, "mode", mode, "by", user.Username)
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Adopted"})
+	}
+
+	app.TriggerStacksBroadcast()
+	go func() {
+		app.checkImageUpdatesForStack(stackName)
+		app.TriggerUpdatesBroadcast()
+	}()
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package handlers
+
+import "errors"
+
+// diskSpace isn't supported here; disk space isn't monitored.
+func diskSpace(path string) (free, total uint64, err error) {
+	return 0, 0, errors.ErrUnsupported
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"log/slog"
+
+	"github.com/cfilipov/dockge/internal/compose"
+	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// serviceDNS is the DNS and hosts configuration of one service. A nil list
+// leaves that key untouched; an empty one removes it.
+type serviceDNS struct {
+	DNS        []string `json:"dns"`
+	DNSSearch  []string `json:"dnsSearch"`
+	ExtraHosts []string `json:"extraHosts"`
+}
+
+// handleSetServiceDNS rewrites a service's dns:, dns_search: and
+// extra_hosts: in compose.yaml without touching the rest of the file.
+// Goes through approval like any other save when required.
+func (app *App) handleSetServiceDNS(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+
+	args := parseArgs(msg)
+	stackName := argString(args, 0)
+	service := argString(args, 1)
+	var cfg serviceDNS
+	if service == "" || !argObject(args, 2, &cfg) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Service and DNS settings required"})
+		}
+		return
+	}
+	if err := stack.ValidateStackName(stackName); err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+
+	app.StackLocks.Lock(stackName)
+	defer app.StackLocks.Unlock(stackName)
+
+	s := &stack.Stack{Name: stackName}
+	s.LoadFromDisk(app.StacksDir)
+	yaml := s.ComposeYAML
+	for _, kv := range []struct {
+		key    string
+		values []string
+	}{
+		{"dns", cfg.DNS},
+		{"dns_search", cfg.DNSSearch},
+		{"extra_hosts", cfg.ExtraHosts},
+	} {
+		if kv.values == nil {
+			continue
+		}
+		updated, ok := compose.SetServiceList(yaml, service, kv.key, kv.values)
+		if !ok {
+			if msg.ID != nil {
+				ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Service not found in compose file"})
+			}
+			return
+		}
+		yaml = updated
+	}
+	s.ComposeYAML = yaml
+
+	if user, ok := app.approvalRequired(c); ok {
+		app.submitPendingChange(c, msg, user, models.PendingActionSave, s, "")
+		return
+	}
+
+	if err := s.SaveToDisk(app.StacksDir); err != nil {
+		slog.Error("set service dns", "err", err, "stack", stackName)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	app.handleComposeYAMLSave(stackName, s.ComposeYAML)
+	slog.Info("service dns updated", "stack", stackName, "service", service)
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Saved"})
+	}
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/cfilipov/dockge/internal/compose"
 	"github.com/cfilipov/dockge/internal/docker"
+	"github.com/cfilipov/dockge/internal/models"
 	"github.com/cfilipov/dockge/internal/ws"
 )
 
@@ -22,12 +23,17 @@ func RegisterDockerHandlers(app *App) {
 	app.WS.Handle("subscribeTop", app.handleSubscribeTop)
 	app.WS.Handle("unsubscribeTop", app.handleUnsubscribeTop)
 	app.WS.Handle("containerInspect", app.handleContainerInspect)
+	app.WS.Handle("searchContainerLogs", app.handleSearchContainerLogs)
 	app.WS.Handle("getDockerNetworkList", app.handleGetDockerNetworkList)
 	app.WS.Handle("networkInspect", app.handleNetworkInspect)
+	app.WS.Handle("getHostInterfaces", app.handleGetHostInterfaces)
+	app.WS.Handle("generateMacvlanNetwork", app.handleGenerateMacvlanNetwork)
+	app.WS.Handle("checkHostPort", app.handleCheckHostPort)
 	app.WS.Handle("getDockerImageList", app.handleGetDockerImageList)
 	app.WS.Handle("imageInspect", app.handleImageInspect)
 	app.WS.Handle("getDockerVolumeList", app.handleGetDockerVolumeList)
 	app.WS.Handle("volumeInspect", app.handleVolumeInspect)
+	app.WS.Handle("requestDiskUsage", app.handleRequestDiskUsage)
 }
 
 // ServiceEntry represents a single container's status within a service.
@@ -39,10 +45,11 @@ type ServiceEntry struct {
 
 // serviceStatusResponse is the typed response for serviceStatusList.
 type serviceStatusResponse struct {
-	OK                    bool                      `json:"ok"`
-	ServiceStatusList     map[string][]ServiceEntry `json:"serviceStatusList"`
-	ServiceUpdateStatus   map[string]bool           `json:"serviceUpdateStatus"`
-	ServiceRecreateStatus map[string]bool           `json:"serviceRecreateStatus"`
+	OK                    bool                            `json:"ok"`
+	ServiceStatusList     map[string][]ServiceEntry       `json:"serviceStatusList"`
+	ServiceUpdateStatus   map[string]bool                 `json:"serviceUpdateStatus"`
+	ServiceRecreateStatus map[string]bool                 `json:"serviceRecreateStatus"`
+	ServiceReleases       map[string]models.ReleaseUpdate `json:"serviceReleases"` // newer version tags per service
 }
 
 // handleServiceStatusList returns per-service status by querying Docker directly.
@@ -102,6 +109,10 @@ func (app *App) handleServiceStatusList(c *ws.Conn, msg *ws.ClientMessage) {
 	if svcUpdates, err := app.ImageUpdates.ServiceUpdatesForStack(stackName); err == nil {
 		serviceUpdateStatus = svcUpdates
 	}
+	serviceReleases, err := app.ImageUpdates.ReleasesForStack(stackName)
+	if err != nil {
+		serviceReleases = map[string]models.ReleaseUpdate{}
+	}
 
 	if msg.ID != nil {
 		ws.SendAck(c, *msg.ID, serviceStatusResponse{
@@ -109,6 +120,7 @@ func (app *App) handleServiceStatusList(c *ws.Conn, msg *ws.ClientMessage) {
 			ServiceStatusList:     serviceStatusList,
 			ServiceUpdateStatus:   serviceUpdateStatus,
 			ServiceRecreateStatus: serviceRecreateStatus,
+			ServiceReleases:       serviceReleases,
 		})
 	}
 }
@@ -189,13 +201,21 @@ func (app *App) handleContainerInspect(c *ws.Conn, msg *ws.ClientMessage) {
 		return
 	}
 
+	security, err := compose.InspectSecurity(inspectData)
+	if err != nil {
+		slog.Warn("containerInspect: security", "err", err, "container", containerName)
+	}
+
 	if msg.ID != nil {
 		ws.SendAck(c, *msg.ID, struct {
 			OK          bool   `json:"ok"`
 			InspectData json.RawMessage `json:"inspectData"`
+			// Privilege settings and risky combinations of them
+			Security *compose.SecurityProfile `json:"security,omitempty"`
 		}{
 			OK:          true,
 			InspectData: inspectData,
+			Security:    security,
 		})
 	}
 }
@@ -377,6 +397,10 @@ func (app *App) streamStats(ctx context.Context, c *ws.Conn, containerName strin
 	if containerName == "" {
 		return
 	}
+	if !app.statsSupported(ctx) {
+		ws.SendEvent(c, "dockerStatsError", ws.ErrorResponse{OK: false, Msg: "statsUnsupported", MsgI18n: true})
+		return
+	}
 
 	statsCh, err := app.Docker.ContainerStatStream(ctx, containerName)
 	if err != nil {
@@ -588,6 +612,40 @@ func (app *App) handleGetDockerVolumeList(c *ws.Conn, msg *ws.ClientMessage) {
 	}
 }
 
+// diskUsageTimeout bounds the daemon's disk usage report, which sizes
+// every volume and can take a while.
+const diskUsageTimeout = time.Minute
+
+// handleRequestDiskUsage reports the space images, containers, volumes and
+// the build cache take, and how much of it is reclaimable.
+func (app *App) handleRequestDiskUsage(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), diskUsageTimeout)
+	defer cancel()
+
+	usage, err := app.Docker.DiskUsage(ctx)
+	if err != nil {
+		slog.Warn("requestDiskUsage", "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Failed to get disk usage: " + err.Error()})
+		}
+		return
+	}
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK        bool             `json:"ok"`
+			DiskUsage docker.DiskUsage `json:"diskUsage"`
+		}{
+			OK:        true,
+			DiskUsage: usage,
+		})
+	}
+}
+
 // handleVolumeInspect returns detailed info for a single Docker volume.
 func (app *App) handleVolumeInspect(c *ws.Conn, msg *ws.ClientMessage) {
 	if checkLogin(c, msg) == 0 {
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"github.com/cfilipov/dockge/internal/compose"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// handleConvertDockerRun turns a docker run command into a compose file
+// for a new stack. Args: (command).
+func (app *App) handleConvertDockerRun(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	conv, err := compose.ConvertDockerRun(argString(parseArgs(msg), 0))
+	if err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK bool `json:"ok"`
+			*compose.DockerRunConversion
+		}{OK: true, DockerRunConversion: conv})
+	}
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/cfilipov/dockge/internal/compose"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// settingMaskEnvSecrets is the settings key that, when "1", hides the
+// values of secret-looking variables (passwords, tokens, keys) in .env files
+// from non-admin users. They can still edit the files: a masked value that's
+// saved unchanged keeps the value on disk.
+const settingMaskEnvSecrets = "maskEnvSecrets"
+
+// RegisterDotEnvHandlers registers the .env interpolation preview.
+func RegisterDotEnvHandlers(app *App) {
+	app.WS.Handle("previewInterpolation", app.handlePreviewInterpolation)
+}
+
+// masksEnvSecrets reports whether secret .env values are hidden from the
+// user of this connection.
+func (app *App) masksEnvSecrets(c *ws.Conn) bool {
+	if v, _ := app.Settings.Get(settingMaskEnvSecrets); v != "1" {
+		return false
+	}
+	user := app.currentUser(c)
+	return user == nil || !user.IsAdmin()
+}
+
+// unmaskStackEnv restores the secrets a masked user saved unchanged in a
+// stack's .env from the file on disk.
+func (app *App) unmaskStackEnv(c *ws.Conn, stackName, composeENV string) string {
+	if !app.masksEnvSecrets(c) {
+		return composeENV
+	}
+	current := &stack.Stack{Name: stackName}
+	current.LoadFromDisk(app.StacksDir)
+	return stack.UnmaskDotEnv(composeENV, current.ComposeENV)
+}
+
+// stackEnvLookup returns the variables compose interpolates a stack's files
+// with: global.env, overridden by the stack's .env. With mask set, secret
+// values read as stack.SecretMask.
+func (app *App) stackEnvLookup(composeENV string, mask bool) func(string) (string, bool) {
+	values := make(map[string]string)
+	if data, err := os.ReadFile(filepath.Join(app.StacksDir, "global.env")); err == nil {
+		values = stack.DotEnvValues(string(data))
+	}
+	for k, v := range stack.DotEnvValues(composeENV) {
+		values[k] = v
+	}
+	return func(name string) (string, bool) {
+		v, ok := values[name]
+		if ok && mask && v != "" && stack.IsSecretKey(name) {
+			return stack.SecretMask, true
+		}
+		return v, ok
+	}
+}
+
+// handlePreviewInterpolation substitutes variables into a stack's compose
+// files and returns the resolved files with the variables each uses, so
+// edits can be checked before saving. It also returns what each service's
+// env_file entries set. Files that aren't passed are read from disk.
+// Args: stackName, composeYAML, composeENV, composeOverrideYAML.
+func (app *App) handlePreviewInterpolation(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	args := parseArgs(msg)
+	stackName := argString(args, 0)
+	if err := stack.ValidateStackName(stackName); err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+
+	s := &stack.Stack{Name: stackName}
+	s.LoadFromDisk(app.StacksDir)
+	composeYAML, overrideYAML := s.ComposeYAML, s.ComposeOverrideYAML
+	composeENV := s.ComposeENV
+	if len(args) > 1 {
+		composeYAML = argString(args, 1)
+	}
+	if len(args) > 2 {
+		composeENV = app.unmaskStackEnv(c, stackName, argString(args, 2))
+	}
+	if len(args) > 3 {
+		overrideYAML = argString(args, 3)
+	}
+
+	mask := app.masksEnvSecrets(c)
+	lookup := app.stackEnvLookup(composeENV, mask)
+	var override *compose.Interpolation
+	envFiles := compose.ParseEnvFiles(composeYAML)
+	if overrideYAML != "" {
+		override = compose.Interpolate(overrideYAML, lookup)
+		for service, files := range compose.ParseEnvFiles(overrideYAML) {
+			envFiles[service] = append(envFiles[service], files...)
+		}
+	}
+	serviceEnv := stack.LoadServiceEnv(filepath.Join(app.StacksDir, stackName), envFiles)
+	if mask {
+		stack.MaskServiceEnv(serviceEnv)
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK         bool                        `json:"ok"`
+			Compose    *compose.Interpolation      `json:"compose"`
+			Override   *compose.Interpolation      `json:"override,omitempty"`
+			ServiceEnv map[string]stack.ServiceEnv `json:"serviceEnv"`
+		}{OK: true, Compose: compose.Interpolate(composeYAML, lookup), Override: override, ServiceEnv: serviceEnv})
+	}
+}
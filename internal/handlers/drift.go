@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/compose"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// serviceDrift explains why `up -d` would recreate one container.
+type serviceDrift struct {
+	Service   string   `json:"service"`
+	Container string   `json:"container,omitempty"`
+	Reasons   []string `json:"reasons"`
+}
+
+// handleGetStackDrift compares the resolved compose config with each
+// container's inspect data and returns per-service drift. Only drifted
+// services (or services with no container) are listed.
+func (app *App) handleGetStackDrift(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+
+	args := parseArgs(msg)
+	stackName := argString(args, 0)
+	if err := stack.ValidateStackName(stackName); err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	project, err := compose.ResolveConfig(ctx, app.StacksDir, stackName)
+	if err != nil {
+		slog.Warn("drift: resolve config", "stack", stackName, "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	containers, err := app.Docker.ContainerList(ctx, true, stackName)
+	if err != nil {
+		slog.Warn("drift: list containers", "stack", stackName, "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+
+	envFromFiles := app.envFileSources(stackName, project)
+	drift := []serviceDrift{}
+	seen := make(map[string]bool)
+	for _, ctr := range containers {
+		if ctr.Service == "" {
+			continue
+		}
+		seen[ctr.Service] = true
+		inspect, err := app.Docker.ContainerInspect(ctx, ctr.ID)
+		if err != nil {
+			slog.Warn("drift: inspect", "container", ctr.Name, "err", err)
+			continue
+		}
+		reasons, err := project.ServiceDrift(ctr.Service, inspect)
+		if err != nil {
+			slog.Warn("drift: compare", "container", ctr.Name, "err", err)
+			continue
+		}
+		if len(reasons) > 0 {
+			annotateEnvFileDrift(reasons, envFromFiles[ctr.Service])
+			drift = append(drift, serviceDrift{Service: ctr.Service, Container: ctr.Name, Reasons: reasons})
+		}
+	}
+	for name := range project.Services {
+		if !seen[name] {
+			drift = append(drift, serviceDrift{Service: name, Reasons: []string{"no container (will be created)"}})
+		}
+	}
+	sort.Slice(drift, func(i, j int) bool {
+		if drift[i].Service != drift[j].Service {
+			return drift[i].Service < drift[j].Service
+		}
+		return drift[i].Container < drift[j].Container
+	})
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK    bool           `json:"ok"`
+			Drift []serviceDrift `json:"drift"`
+		}{
+			OK:    true,
+			Drift: drift,
+		})
+	}
+}
+
+// envFileSources returns, per service, the env_file each variable's
+// resolved value comes from. A variable also set under environment: has
+// a different resolved value and isn't listed.
+func (app *App) envFileSources(stackName string, project *compose.ResolvedProject) map[string]map[string]string {
+	s := &stack.Stack{Name: stackName}
+	s.LoadFromDisk(app.StacksDir)
+	envFiles := compose.ParseEnvFiles(s.ComposeYAML)
+	for service, files := range compose.ParseEnvFiles(s.ComposeOverrideYAML) {
+		envFiles[service] = append(envFiles[service], files...)
+	}
+
+	result := make(map[string]map[string]string)
+	for service, env := range stack.LoadServiceEnv(s.Path, envFiles) {
+		resolved := project.Services[service].Environment
+		for _, v := range env.Vars {
+			if r := resolved[v.Name]; r != nil && *r == v.Value {
+				if result[service] == nil {
+					result[service] = make(map[string]string)
+				}
+				result[service][v.Name] = v.File
+			}
+		}
+	}
+	return result
+}
+
+// annotateEnvFileDrift adds the env_file to environment drift reasons for
+// variables set there, since the change is in that file rather than the
+// compose file.
+func annotateEnvFileDrift(reasons []string, files map[string]string) {
+	for i, r := range reasons {
+		rest, ok := strings.CutPrefix(r, "environment: ")
+		if !ok {
+			continue
+		}
+		key, _, _ := strings.Cut(rest, " ")
+		if file, ok := files[key]; ok {
+			reasons[i] = r + " (from " + file + ")"
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// RegisterEnvReplaceHandlers registers the cross-stack environment variable
+// search and bulk replace handlers.
+func RegisterEnvReplaceHandlers(app *App) {
+	app.WS.Handle("searchStackEnv", app.handleSearchStackEnv)
+	app.WS.Handle("previewStackEnvReplace", app.handlePreviewStackEnvReplace)
+	app.WS.Handle("applyStackEnvReplace", app.handleApplyStackEnvReplace)
+}
+
+// envQuery selects assignments by key and/or value. NewValue is only used
+// by replace.
+type envQuery struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	NewValue string `json:"newValue"`
+}
+
+func (q envQuery) valid() error {
+	if q.Key == "" && q.Value == "" {
+		return errors.New("Key or value required")
+	}
+	return nil
+}
+
+// stackEnvMatches are the matching assignments in one file of a stack.
+type stackEnvMatches struct {
+	StackName string           `json:"stackName"`
+	File      string           `json:"file"`
+	Matches   []stack.EnvMatch `json:"matches"`
+}
+
+// stackEnvPreview is the replace proposed for one stack. Hash fingerprints
+// the files it was computed from; apply refuses stacks changed since.
+type stackEnvPreview struct {
+	StackName    string `json:"stackName"`
+	Replacements int    `json:"replacements"`
+	Diff         string `json:"diff"`
+	Hash         string `json:"hash"`
+}
+
+// stackEnvResult is the outcome of applying a replace to one stack.
+type stackEnvResult struct {
+	StackName string `json:"stackName"`
+	OK        bool   `json:"ok"`
+	Error     string `json:"error,omitempty"`
+}
+
+// loadAllStacks reads the files of every stack that has a compose file,
+// sorted by name.
+func (app *App) loadAllStacks() ([]*stack.Stack, error) {
+	entries, err := os.ReadDir(app.StacksDir)
+	if err != nil {
+		return nil, err
+	}
+	var stacks []*stack.Stack
+	for _, entry := range entries {
+		if !stack.IsDirEntry(app.StacksDir, entry) || !stack.ComposeFileExists(app.StacksDir, entry.Name()) {
+			continue
+		}
+		s := &stack.Stack{Name: entry.Name()}
+		s.LoadFromDisk(app.StacksDir)
+		stacks = append(stacks, s)
+	}
+	sort.Slice(stacks, func(i, j int) bool { return stacks[i].Name < stacks[j].Name })
+	return stacks, nil
+}
+
+// replaceStackEnv returns s with the query's replace applied to all of its
+// files, and the number of replacements.
+func replaceStackEnv(s *stack.Stack, q envQuery) (*stack.Stack, int, error) {
+	proposed := *s
+	total := 0
+	for _, f := range []struct {
+		text    *string
+		compose bool
+	}{
+		{&proposed.ComposeYAML, true},
+		{&proposed.ComposeENV, false},
+		{&proposed.ComposeOverrideYAML, true},
+	} {
+		replaced, n, err := stack.ReplaceEnv(*f.text, f.compose, q.Key, q.Value, q.NewValue)
+		if err != nil {
+			return nil, 0, err
+		}
+		*f.text = replaced
+		total += n
+	}
+	return &proposed, total, nil
+}
+
+// handleSearchStackEnv lists the environment assignments matching a key
+// and/or value across all stacks' compose and .env files. Admin only.
+// Args: {key, value}.
+func (app *App) handleSearchStackEnv(c *ws.Conn, msg *ws.ClientMessage) {
+	if app.checkAdmin(c, msg) == nil {
+		return
+	}
+	var q envQuery
+	argObject(parseArgs(msg), 0, &q)
+	if err := q.valid(); err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+
+	stacks, err := app.loadAllStacks()
+	if err != nil {
+		slog.Warn("env search: read stacks dir", "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+	results := []stackEnvMatches{}
+	for _, s := range stacks {
+		for _, f := range []struct {
+			name, text string
+			compose    bool
+		}{
+			{s.ComposeFileName, s.ComposeYAML, true},
+			{".env", s.ComposeENV, false},
+			{s.ComposeOverrideFileName, s.ComposeOverrideYAML, true},
+		} {
+			if matches := stack.FindEnv(f.text, f.compose, q.Key, q.Value); len(matches) > 0 {
+				results = append(results, stackEnvMatches{StackName: s.Name, File: f.name, Matches: matches})
+			}
+		}
+	}
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK      bool              `json:"ok"`
+			Results []stackEnvMatches `json:"results"`
+		}{OK: true, Results: results})
+	}
+}
+
+// handlePreviewStackEnvReplace returns a per-stack diff of a bulk replace
+// without writing anything. Admin only. Args: {key, value, newValue}.
+func (app *App) handlePreviewStackEnvReplace(c *ws.Conn, msg *ws.ClientMessage) {
+	if app.checkAdmin(c, msg) == nil {
+		return
+	}
+	var q envQuery
+	argObject(parseArgs(msg), 0, &q)
+	err := q.valid()
+	if err == nil {
+		err = stack.ValidateEnvValue(q.NewValue)
+	}
+	if err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+
+	stacks, err := app.loadAllStacks()
+	if err != nil {
+		slog.Warn("env replace preview: read stacks dir", "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+	previews := []stackEnvPreview{}
+	for _, s := range stacks {
+		proposed, n, err := replaceStackEnv(s, q)
+		if err != nil || n == 0 {
+			continue
+		}
+		previews = append(previews, stackEnvPreview{
+			StackName:    s.Name,
+			Replacements: n,
+			Diff:         stackFilesDiff(s, proposed),
+			Hash:         stackFilesHash(s),
+		})
+	}
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK       bool              `json:"ok"`
+			Previews []stackEnvPreview `json:"previews"`
+		}{OK: true, Previews: previews})
+	}
+}
+
+// handleApplyStackEnvReplace writes a previewed bulk replace. Only the given
+// stacks are changed, and only if their files still match the preview's
+// hash. Each stack gets an operation history entry; running stacks keep the
+// old values until redeployed. Admin only, requires sudo.
+// Args: {key, value, newValue, stacks: {stackName: hash}}.
+func (app *App) handleApplyStackEnvReplace(c *ws.Conn, msg *ws.ClientMessage) {
+	admin := app.checkAdmin(c, msg)
+	if admin == nil || !app.requireSudo(c, msg) {
+		return
+	}
+	var req struct {
+		envQuery
+		Stacks map[string]string `json:"stacks"`
+	}
+	argObject(parseArgs(msg), 0, &req)
+	err := req.valid()
+	if err == nil {
+		err = stack.ValidateEnvValue(req.NewValue)
+	}
+	if err == nil && len(req.Stacks) == 0 {
+		err = errors.New("No stacks selected")
+	}
+	if err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+
+	names := make([]string, 0, len(req.Stacks))
+	for name := range req.Stacks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// The values may be secrets; only the key goes into logs and history
+	note := "Bulk environment replace"
+	if req.Key != "" {
+		note = fmt.Sprintf("Bulk replace of %s", req.Key)
+	}
+	results := make([]stackEnvResult, 0, len(names))
+	for _, name := range names {
+		err := app.applyStackEnvReplace(name, req.Stacks[name], req.envQuery, note)
+		r := stackEnvResult{StackName: name, OK: err == nil}
+		if err != nil {
+			r.Error = err.Error()
+		}
+		results = append(results, r)
+	}
+	slog.Info("bulk env replace", "key", req.Key, "stacks", len(names), "by", admin.Username)
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK      bool             `json:"ok"`
+			Results []stackEnvResult `json:"results"`
+		}{OK: true, Results: results})
+	}
+}
+
+// applyStackEnvReplace replaces in one stack under its lock, if its files
+// still hash to hash.
+func (app *App) applyStackEnvReplace(stackName, hash string, q envQuery, note string) error {
+	if err := stack.ValidateStackName(stackName); err != nil {
+		return err
+	}
+	app.StackLocks.Lock(stackName)
+	defer app.StackLocks.Unlock(stackName)
+
+	current := &stack.Stack{Name: stackName}
+	current.LoadFromDisk(app.StacksDir)
+	if current.ComposeYAML == "" {
+		return errors.New("stack has no compose file")
+	}
+	if stackFilesHash(current) != hash {
+		return errors.New("stack was modified after the preview")
+	}
+	proposed, n, err := replaceStackEnv(current, q)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+
+	op := app.beginOperation(stackName, "envReplace", note)
+	err = proposed.SaveToDisk(app.StacksDir)
+	app.endOperation(op, err)
+	if err != nil {
+		slog.Error("bulk env replace: save", "stack", stackName, "err", err)
+		return err
+	}
+	app.handleComposeYAMLSave(stackName, proposed.ComposeYAML)
+	return nil
+}
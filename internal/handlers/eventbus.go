@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/cfilipov/dockge/internal/docker"
 )
@@ -12,26 +15,44 @@ import (
 // HTTP streaming connection to the Docker daemon.
 type EventBus struct {
 	mu     sync.RWMutex
-	subs   map[uint64]chan docker.DockerEvent
+	subs   map[uint64]*eventSub
 	nextID uint64
 }
 
+type eventSub struct {
+	ch      chan docker.DockerEvent
+	created time.Time
+	dropped atomic.Uint64
+}
+
+// EventSubInfo describes a live EventBus subscription for debug output.
+type EventSubInfo struct {
+	ID         uint64  `json:"id"`
+	Buffered   int     `json:"buffered"`
+	Capacity   int     `json:"capacity"`
+	Dropped    uint64  `json:"dropped"`
+	AgeSeconds float64 `json:"ageSeconds"`
+}
+
 // NewEventBus creates an EventBus ready for use.
 func NewEventBus() *EventBus {
 	return &EventBus{
-		subs: make(map[uint64]chan docker.DockerEvent),
+		subs: make(map[uint64]*eventSub),
 	}
 }
 
 // Subscribe returns a buffered channel that receives Docker events and an
 // unsubscribe function. The caller must call unsub when done to avoid leaks.
 func (eb *EventBus) Subscribe(bufSize int) (<-chan docker.DockerEvent, func()) {
-	ch := make(chan docker.DockerEvent, bufSize)
+	sub := &eventSub{
+		ch:      make(chan docker.DockerEvent, bufSize),
+		created: time.Now(),
+	}
 
 	eb.mu.Lock()
 	id := eb.nextID
 	eb.nextID++
-	eb.subs[id] = ch
+	eb.subs[id] = sub
 	eb.mu.Unlock()
 
 	unsub := func() {
@@ -40,7 +61,33 @@ func (eb *EventBus) Subscribe(bufSize int) (<-chan docker.DockerEvent, func()) {
 		eb.mu.Unlock()
 	}
 
-	return ch, unsub
+	return sub.ch, unsub
+}
+
+// Count returns the number of live subscriptions.
+func (eb *EventBus) Count() int {
+	eb.mu.RLock()
+	defer eb.mu.RUnlock()
+	return len(eb.subs)
+}
+
+// Snapshot returns info for every live subscription, oldest first.
+func (eb *EventBus) Snapshot() []EventSubInfo {
+	now := time.Now()
+	eb.mu.RLock()
+	result := make([]EventSubInfo, 0, len(eb.subs))
+	for id, sub := range eb.subs {
+		result = append(result, EventSubInfo{
+			ID:         id,
+			Buffered:   len(sub.ch),
+			Capacity:   cap(sub.ch),
+			Dropped:    sub.dropped.Load(),
+			AgeSeconds: now.Sub(sub.created).Seconds(),
+		})
+	}
+	eb.mu.RUnlock()
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
 }
 
 // Publish sends an event to all subscribers using non-blocking sends.
@@ -50,11 +97,12 @@ func (eb *EventBus) Publish(evt docker.DockerEvent) {
 	eb.mu.RLock()
 	defer eb.mu.RUnlock()
 
-	for _, ch := range eb.subs {
+	for _, sub := range eb.subs {
 		select {
-		case ch <- evt:
+		case sub.ch <- evt:
 		default:
 			// Subscriber buffer full — drop event to avoid blocking
+			sub.dropped.Add(1)
 		}
 	}
 }
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/docker"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// eventsFeedHistory is how many feed events are kept in memory for the
+// page to show on load.
+const eventsFeedHistory = 500
+
+// FeedEvent is a container lifecycle event as shown in the events feed.
+type FeedEvent struct {
+	Time      int64  `json:"time"` // Unix milliseconds
+	Stack     string `json:"stack,omitempty"`
+	Service   string `json:"service,omitempty"`
+	Container string `json:"container"`
+	Action    string `json:"action"`           // start, stop, die, oom or health
+	Detail    string `json:"detail,omitempty"` // exit code of a die, status of a health change
+}
+
+// eventsFeedState keeps the recent feed events and the connections
+// subscribed to new ones.
+type eventsFeedState struct {
+	mu      sync.Mutex
+	history []FeedEvent // oldest first
+	subs    map[string]eventsFeedSub
+}
+
+// eventsFeedSub is a connection following the feed, of one stack or all.
+type eventsFeedSub struct {
+	conn  *ws.Conn
+	stack string // "" = all stacks
+}
+
+// RegisterEventsFeedHandlers registers the events feed WS events.
+func RegisterEventsFeedHandlers(app *App) {
+	app.WS.Handle("subscribeEventsFeed", app.handleSubscribeEventsFeed)
+	app.WS.Handle("unsubscribeEventsFeed", app.handleUnsubscribeEventsFeed)
+}
+
+// toFeedEvent converts a Docker event to a feed event, or reports false
+// for events the feed doesn't show.
+func toFeedEvent(evt docker.DockerEvent) (FeedEvent, bool) {
+	if evt.Type != "container" {
+		return FeedEvent{}, false
+	}
+	fe := FeedEvent{
+		Stack:     evt.Project,
+		Service:   evt.Service,
+		Container: evt.Name,
+		Action:    evt.Action,
+	}
+	switch {
+	case evt.Action == "start", evt.Action == "stop", evt.Action == "oom":
+	case evt.Action == "die":
+		fe.Detail = evt.ExitCode
+	case strings.HasPrefix(evt.Action, "health_status: "):
+		fe.Action = "health"
+		fe.Detail = strings.TrimPrefix(evt.Action, "health_status: ")
+	default:
+		return FeedEvent{}, false
+	}
+	t := evt.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	fe.Time = t.UnixMilli()
+	return fe, true
+}
+
+// recordFeedEvent adds a Docker event to the feed history and pushes it to
+// the subscribed connections, if the feed shows it.
+func (app *App) recordFeedEvent(evt docker.DockerEvent) {
+	fe, ok := toFeedEvent(evt)
+	if !ok {
+		return
+	}
+
+	st := &app.eventsFeed
+	st.mu.Lock()
+	if len(st.history) >= eventsFeedHistory {
+		copy(st.history, st.history[1:])
+		st.history = st.history[:len(st.history)-1]
+	}
+	st.history = append(st.history, fe)
+	var conns []*ws.Conn
+	for _, sub := range st.subs {
+		if sub.stack == "" || sub.stack == fe.Stack {
+			conns = append(conns, sub.conn)
+		}
+	}
+	st.mu.Unlock()
+
+	for _, c := range conns {
+		ws.SendEvent(c, "eventsFeed", fe)
+	}
+}
+
+// historyOf returns the recent feed events of a stack, or of all stacks if
+// stackName is empty, oldest first. The caller holds st.mu.
+func (st *eventsFeedState) historyOf(stackName string) []FeedEvent {
+	events := []FeedEvent{}
+	for _, fe := range st.history {
+		if stackName == "" || fe.Stack == stackName {
+			events = append(events, fe)
+		}
+	}
+	return events
+}
+
+// handleSubscribeEventsFeed acks with the recent events and pushes new
+// ones as "eventsFeed" events until unsubscribed. Replaces any earlier
+// subscription of the connection.
+// Args: [stackName?]
+func (app *App) handleSubscribeEventsFeed(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	args := parseArgs(msg)
+	stackName := argString(args, 0)
+	if stackName != "" {
+		if err := stack.ValidateStackName(stackName); err != nil {
+			if msg.ID != nil {
+				ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+			}
+			return
+		}
+	}
+
+	// Subscribing and reading the history together, every event is either
+	// in the history or pushed, never both; pushes may arrive before the ack.
+	st := &app.eventsFeed
+	st.mu.Lock()
+	if st.subs == nil {
+		st.subs = make(map[string]eventsFeedSub)
+	}
+	st.subs[c.ID()] = eventsFeedSub{conn: c, stack: stackName}
+	history := st.historyOf(stackName)
+	st.mu.Unlock()
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK     bool        `json:"ok"`
+			Events []FeedEvent `json:"events"`
+		}{
+			OK:     true,
+			Events: history,
+		})
+	}
+}
+
+// handleUnsubscribeEventsFeed stops pushing feed events to the connection.
+func (app *App) handleUnsubscribeEventsFeed(c *ws.Conn, msg *ws.ClientMessage) {
+	app.CancelEventsFeedSub(c.ID())
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true})
+	}
+}
+
+// CancelEventsFeedSub drops the feed subscription of a connection, for
+// disconnect callbacks.
+func (app *App) CancelEventsFeedSub(connID string) {
+	st := &app.eventsFeed
+	st.mu.Lock()
+	delete(st.subs, connID)
+	st.mu.Unlock()
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/docker"
+)
+
+func TestToFeedEvent(t *testing.T) {
+	t.Parallel()
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		evt    docker.DockerEvent
+		action string
+		detail string
+		ok     bool
+	}{
+		{docker.DockerEvent{Type: "container", Action: "start"}, "start", "", true},
+		{docker.DockerEvent{Type: "container", Action: "die", ExitCode: "137"}, "die", "137", true},
+		{docker.DockerEvent{Type: "container", Action: "oom"}, "oom", "", true},
+		{docker.DockerEvent{Type: "container", Action: "health_status: unhealthy"}, "health", "unhealthy", true},
+		{docker.DockerEvent{Type: "container", Action: "create"}, "", "", false},
+		{docker.DockerEvent{Type: "network", Action: "start"}, "", "", false},
+	}
+	for _, tt := range tests {
+		tt.evt.Name, tt.evt.Project, tt.evt.Time = "web-app-1", "web", at
+		fe, ok := toFeedEvent(tt.evt)
+		if ok != tt.ok {
+			t.Errorf("%s %s: ok = %v, want %v", tt.evt.Type, tt.evt.Action, ok, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		want := FeedEvent{Time: at.UnixMilli(), Stack: "web", Container: "web-app-1", Action: tt.action, Detail: tt.detail}
+		if fe != want {
+			t.Errorf("%s: %+v, want %+v", tt.evt.Action, fe, want)
+		}
+	}
+}
+
+func TestFeedHistory(t *testing.T) {
+	t.Parallel()
+	app := &App{}
+	for i := range eventsFeedHistory + 10 {
+		project := "web"
+		if i%2 == 1 {
+			project = "db"
+		}
+		app.recordFeedEvent(docker.DockerEvent{Type: "container", Action: "start", Project: project, Time: time.UnixMilli(int64(i))})
+	}
+	app.recordFeedEvent(docker.DockerEvent{Type: "container", Action: "create", Project: "web"})
+
+	all := app.eventsFeed.historyOf("")
+	if len(all) != eventsFeedHistory {
+		t.Fatalf("kept %d events, want %d", len(all), eventsFeedHistory)
+	}
+	if all[0].Time != 10 || all[len(all)-1].Time != eventsFeedHistory+9 {
+		t.Errorf("kept %d..%d, want the newest", all[0].Time, all[len(all)-1].Time)
+	}
+	for _, fe := range app.eventsFeed.historyOf("db") {
+		if fe.Stack != "db" {
+			t.Fatalf("db history has %+v", fe)
+		}
+	}
+	if n := len(app.eventsFeed.historyOf("none")); n != 0 {
+		t.Errorf("unknown stack has %d events", n)
+	}
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/docker"
+	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/terminal"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+const (
+	// maxExecEnv bounds the extra variables of an exec terminal.
+	maxExecEnv = 32
+	// maxExecArgs bounds the arguments of an exec terminal's command.
+	maxExecArgs = 64
+	// execTimeout bounds finding a container and starting an exec in it.
+	execTimeout = 10 * time.Second
+)
+
+var (
+	// execUserRe matches docker exec's user[:group], by name or id.
+	execUserRe   = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*(:[A-Za-z0-9_][A-Za-z0-9_.-]*)?$`)
+	execEnvKeyRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// validateExecOptions checks exec options before they reach docker exec.
+func validateExecOptions(o *ws.ExecOptions) error {
+	if o.User != "" && !execUserRe.MatchString(o.User) {
+		return errors.New("invalid exec user: " + o.User)
+	}
+	if o.Workdir != "" && (!strings.HasPrefix(o.Workdir, "/") || strings.ContainsFunc(o.Workdir, isControl)) {
+		return errors.New("exec workdir must be an absolute path")
+	}
+	if len(o.Env) > maxExecEnv {
+		return errors.New("too many exec environment variables")
+	}
+	for _, kv := range o.Env {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || !execEnvKeyRe.MatchString(key) || strings.ContainsFunc(value, isControl) {
+			return errors.New("invalid exec environment variable: " + key)
+		}
+	}
+	if len(o.Cmd) > maxExecArgs {
+		return errors.New("too many exec command arguments")
+	}
+	if len(o.Cmd) > 0 && strings.TrimSpace(o.Cmd[0]) == "" {
+		return errors.New("exec command is empty")
+	}
+	for _, arg := range o.Cmd {
+		if strings.ContainsRune(arg, 0) {
+			return errors.New("invalid exec command argument")
+		}
+	}
+	return nil
+}
+
+func isControl(r rune) bool { return r < 0x20 || r == 0x7f }
+
+// execConfig returns the Docker exec of a shell, or of the command of the
+// user's exec options, with a stack's terminal env and the user's exec
+// options, whose variables win over TERM and LANG.
+func execConfig(env execEnv, shell string, o *ws.ExecOptions) docker.ExecOptions {
+	cfg := docker.ExecOptions{Cmd: env.shellCommand(shell), Env: env.vars(), Rows: 24, Cols: 80}
+	if o != nil {
+		cfg.User = o.User
+		cfg.WorkingDir = o.Workdir
+		cfg.Env = append(cfg.Env, o.Env...)
+		if len(o.Cmd) > 0 {
+			cfg.Cmd = o.Cmd
+		}
+	}
+	return cfg
+}
+
+// startExec starts a shell in a container through the Docker API and
+// attaches it to term.
+func (app *App) startExec(term *terminal.Terminal, container, stackName, shell string, o *ws.ExecOptions) (docker.ExecSession, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), execTimeout)
+	defer cancel()
+	s, err := app.Docker.ContainerExec(ctx, container, execConfig(app.terminalEnv(stackName), shell, o))
+	if err != nil {
+		return nil, err
+	}
+	term.StartStream(s)
+	return s, nil
+}
+
+// execExitedData returns the terminalExited event of an exec that ended,
+// with its exit code if the daemon reports one.
+func execExitedData(sessionID uint16, s docker.ExecSession) ws.TerminalExitedData {
+	data := ws.TerminalExitedData{SessionID: sessionID}
+	code, err := s.ExitCode()
+	if err != nil {
+		slog.Debug("exec exit code", "err", err)
+		return data
+	}
+	data.ExitCode = &code
+	return data
+}
+
+// serviceContainer returns the ID of a running container of a stack's
+// service, the first replica if it's scaled.
+func (app *App) serviceContainer(stackName, service string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), execTimeout)
+	defer cancel()
+	list, err := app.Docker.ContainerList(ctx, false, stackName)
+	if err != nil {
+		return "", err
+	}
+	var first *docker.Container
+	for i, ct := range list {
+		if ct.Service == service && (first == nil || ct.Name < first.Name) {
+			first = &list[i]
+		}
+	}
+	if first == nil {
+		return "", fmt.Errorf("service %s has no running container", service)
+	}
+	return first.ID, nil
+}
+
+// execOptions returns the exec options of a join: the ones it asks for,
+// which become the user's defaults, or the user's remembered defaults.
+func (app *App) execOptions(c *ws.Conn, args *ws.TerminalJoinArgs) (*ws.ExecOptions, error) {
+	user := app.currentUser(c)
+	if args.Exec == nil {
+		return app.execDefaults(user), nil
+	}
+	if err := validateExecOptions(args.Exec); err != nil {
+		return nil, err
+	}
+	if app.ExecDefaults != nil && user != nil {
+		d := models.ExecDefaults{Username: user.Username, User: args.Exec.User, Workdir: args.Exec.Workdir, Env: args.Exec.Env}
+		if err := app.ExecDefaults.Set(d); err != nil {
+			slog.Warn("remember exec defaults", "err", err, "user", user.Username)
+		}
+	}
+	return args.Exec, nil
+}
+
+// execDefaults returns a user's remembered exec options, or nil if they
+// have none.
+func (app *App) execDefaults(user *models.User) *ws.ExecOptions {
+	if app.ExecDefaults == nil || user == nil {
+		return nil
+	}
+	d, err := app.ExecDefaults.Get(user.Username)
+	if err != nil {
+		slog.Warn("get exec defaults", "err", err, "user", user.Username)
+		return nil
+	}
+	if d == nil {
+		return nil
+	}
+	return &ws.ExecOptions{User: d.User, Workdir: d.Workdir, Env: d.Env}
+}
+
+// handleGetExecDefaults returns the exec options the user's shells open
+// with when they don't choose any (null for docker's defaults).
+func (app *App) handleGetExecDefaults(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK       bool            `json:"ok"`
+			Defaults *ws.ExecOptions `json:"defaults"`
+		}{OK: true, Defaults: app.execDefaults(app.currentUser(c))})
+	}
+}
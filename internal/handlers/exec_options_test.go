@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+func TestValidateExecOptions(t *testing.T) {
+	t.Parallel()
+	valid := []ws.ExecOptions{
+		{},
+		{User: "www-data"},
+		{User: "1000:1000", Workdir: "/var/www/html", Env: []string{"DEBUG=1", "EMPTY="}},
+		{Cmd: []string{"php", "artisan", "migrate", "--force"}},
+	}
+	for _, o := range valid {
+		if err := validateExecOptions(&o); err != nil {
+			t.Errorf("%+v: unexpected error %v", o, err)
+		}
+	}
+	invalid := []ws.ExecOptions{
+		{User: "-u"},
+		{User: "root:wheel:x"},
+		{Workdir: "app"},
+		{Workdir: "/app\n"},
+		{Env: []string{"NOVALUE"}},
+		{Env: []string{"1KEY=x"}},
+		{Env: []string{"KEY=line\nbreak"}},
+		{Cmd: []string{" "}},
+		{Cmd: []string{"echo", "nul\x00"}},
+		{Cmd: make([]string, maxExecArgs+1)},
+	}
+	for _, o := range invalid {
+		if err := validateExecOptions(&o); err == nil {
+			t.Errorf("%+v: expected an error", o)
+		}
+	}
+}
+
+func TestExecConfig(t *testing.T) {
+	t.Parallel()
+	env := execEnv{Term: "xterm", Shells: []string{"sh"}}
+	got := execConfig(env, "", &ws.ExecOptions{User: "node", Workdir: "/app", Env: []string{"A=1", "TERM=dumb"}})
+	if got.User != "node" || got.WorkingDir != "/app" || !slices.Equal(got.Cmd, []string{"sh"}) {
+		t.Errorf("execConfig = %+v", got)
+	}
+	// Later variables win, so the user's TERM overrides the stack's
+	if want := []string{"TERM=xterm", "A=1", "TERM=dumb"}; !slices.Equal(got.Env, want) {
+		t.Errorf("Env = %v, want %v", got.Env, want)
+	}
+	if got := execConfig(env, "sh", nil); got.User != "" || !slices.Equal(got.Env, []string{"TERM=xterm"}) || !slices.Equal(got.Cmd, []string{"sh"}) {
+		t.Errorf("nil options = %+v", got)
+	}
+	// A command replaces the shell
+	if got := execConfig(env, "bash", &ws.ExecOptions{Cmd: []string{"ls", "-la"}}); !slices.Equal(got.Cmd, []string{"ls", "-la"}) {
+		t.Errorf("command = %v", got.Cmd)
+	}
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/compose"
+	"github.com/cfilipov/dockge/internal/docker"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// dockerObjectName matches the names Docker accepts for networks and volumes.
+var dockerObjectName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// missingExternal is an external network or volume that a compose file
+// refers to but that doesn't exist on the daemon.
+type missingExternal struct {
+	Kind string `json:"kind"` // "network" or "volume"
+	Name string `json:"name"`
+}
+
+// missingExternalResources checks the external networks and volumes of the
+// given compose files against the daemon. A kind that can't be listed is
+// skipped rather than reported as missing.
+func (app *App) missingExternalResources(yamls ...string) []missingExternal {
+	var refs compose.ExternalResources
+	for _, yaml := range yamls {
+		res := compose.ParseExternalResources(yaml)
+		refs.Networks = append(refs.Networks, res.Networks...)
+		refs.Volumes = append(refs.Volumes, res.Volumes...)
+	}
+	if len(refs.Networks) == 0 && len(refs.Volumes) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var missing []missingExternal
+	seen := make(map[missingExternal]bool)
+	report := func(m missingExternal) {
+		if !seen[m] {
+			seen[m] = true
+			missing = append(missing, m)
+		}
+	}
+	if len(refs.Networks) > 0 {
+		if list, err := app.Docker.NetworkList(ctx); err == nil {
+			existing := make(map[string]bool, len(list))
+			for _, n := range list {
+				existing[n.Name] = true
+			}
+			for _, name := range refs.Networks {
+				if !existing[name] {
+					report(missingExternal{Kind: "network", Name: name})
+				}
+			}
+		} else {
+			slog.Warn("external check: list networks", "err", err)
+		}
+	}
+	if len(refs.Volumes) > 0 {
+		if list, err := app.Docker.VolumeList(ctx); err == nil {
+			existing := make(map[string]bool, len(list))
+			for _, v := range list {
+				existing[v.Name] = true
+			}
+			for _, name := range refs.Volumes {
+				if !existing[name] {
+					report(missingExternal{Kind: "volume", Name: name})
+				}
+			}
+		} else {
+			slog.Warn("external check: list volumes", "err", err)
+		}
+	}
+	return missing
+}
+
+// handleCreateExternalResource creates a network or volume that a compose
+// file declares external, with default options. Args: {kind, name}.
+func (app *App) handleCreateExternalResource(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	args := parseArgs(msg)
+	var res missingExternal
+	argObject(args, 0, &res)
+
+	if res.Kind != "network" && res.Kind != "volume" {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Kind must be network or volume"})
+		}
+		return
+	}
+	if !dockerObjectName.MatchString(res.Name) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Invalid " + res.Kind + " name"})
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	var err error
+	if res.Kind == "network" {
+		_, err = app.Docker.NetworkCreate(ctx, docker.NetworkCreateOptions{Name: res.Name})
+	} else {
+		err = app.Docker.VolumeCreate(ctx, res.Name)
+	}
+	if err != nil {
+		slog.Warn("create external resource", "kind", res.Kind, "name", res.Name, "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	slog.Info("external resource created", "kind", res.Kind, "name", res.Name)
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Created"})
+	}
+}
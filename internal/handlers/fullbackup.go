@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/backup"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// RegisterConfigBackupHandlers registers the full backup handlers: the
+// database and all stacks in one archive. Downloads and uploads go through
+// ServeBackup.
+func RegisterConfigBackupHandlers(app *App) {
+	app.WS.Handle("createConfigBackup", app.handleCreateConfigBackup)
+	app.WS.Handle("restoreConfigBackup", app.handleRestoreConfigBackup)
+	app.WS.Handle("getConfigRestore", app.handleGetConfigRestore)
+	app.WS.Handle("cancelConfigRestore", app.handleCancelConfigRestore)
+}
+
+// handleCreateConfigBackup writes a full backup to ExportDir and returns a
+// one-time link to download it. Admin only and requires sudo, as the
+// backup holds password hashes and the JWT secret.
+func (app *App) handleCreateConfigBackup(c *ws.Conn, msg *ws.ClientMessage) {
+	user := app.checkAdmin(c, msg)
+	if user == nil || !app.requireSudo(c, msg) {
+		return
+	}
+	fail := func(text string) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: text})
+		}
+	}
+	if app.DB == nil || app.ExportDir == "" {
+		fail("Backups are not available")
+		return
+	}
+
+	go func() {
+		path, skipped, err := app.writeConfigBackup()
+		if err != nil {
+			slog.Error("create backup", "err", err)
+			fail(err.Error())
+			return
+		}
+		slog.Info("backup created", "path", path, "by", user.Username)
+
+		token := app.backupLinks.issue(&backupLink{file: path, user: user.Username})
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, struct {
+				OK      bool     `json:"ok"`
+				Path    string   `json:"path"`
+				URL     string   `json:"url"`
+				Skipped []string `json:"skipped,omitempty"`
+			}{OK: true, Path: path, URL: backupLinkPath + token, Skipped: skipped})
+		}
+	}()
+}
+
+// writeConfigBackup writes a full backup to ExportDir and returns its path
+// and the files left out. A partial file is removed on failure.
+func (app *App) writeConfigBackup() (string, []string, error) {
+	if err := os.MkdirAll(app.ExportDir, 0700); err != nil {
+		return "", nil, fmt.Errorf("create export dir: %w", err)
+	}
+	path := filepath.Join(app.ExportDir, "dockge-backup-"+time.Now().Format("20060102-150405")+".tar.gz")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", nil, fmt.Errorf("create backup file: %w", err)
+	}
+	skipped, err := backup.Write(f, app.DB, app.StacksDir, app.Version)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", nil, fmt.Errorf("write backup file: %w", err)
+	}
+	return path, skipped, nil
+}
+
+// handleRestoreConfigBackup returns a one-time link to upload a full backup
+// to. The upload is validated and staged, and replaces the database and
+// its stacks at the next start. Admin only and requires sudo.
+func (app *App) handleRestoreConfigBackup(c *ws.Conn, msg *ws.ClientMessage) {
+	user := app.checkAdmin(c, msg)
+	if user == nil || !app.requireSudo(c, msg) {
+		return
+	}
+	if app.DataDir == "" {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Backups are not available"})
+		}
+		return
+	}
+	token := app.backupLinks.issue(&backupLink{restoreConfig: true, user: user.Username})
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK  bool   `json:"ok"`
+			URL string `json:"url"`
+		}{OK: true, URL: backupLinkPath + token})
+	}
+}
+
+// stageConfigRestore extracts and validates an uploaded full backup into
+// the pending restore directory. It returns the HTTP status for an error.
+func (app *App) stageConfigRestore(body io.Reader) (*backup.Manifest, int, error) {
+	if app.DataDir == "" {
+		return nil, http.StatusNotFound, errors.New("backups are not available")
+	}
+	pending := filepath.Join(app.DataDir, backup.PendingDir)
+	if _, err := os.Stat(pending); err == nil {
+		return nil, http.StatusConflict, errors.New("a restore is already pending; cancel it first")
+	}
+
+	// Stage beside the pending directory, so a half-read upload is never
+	// mistaken for a restore
+	tmp, err := os.MkdirTemp(app.DataDir, ".restore-")
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	defer os.RemoveAll(tmp)
+	staged := filepath.Join(tmp, "backup")
+	m, err := backup.Stage(body, staged)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if err := os.Rename(staged, pending); err != nil {
+		return nil, http.StatusConflict, fmt.Errorf("stage restore: %w", err)
+	}
+	return m, http.StatusOK, nil
+}
+
+// handleGetConfigRestore returns the manifest of the staged restore, or
+// none. Admin only.
+func (app *App) handleGetConfigRestore(c *ws.Conn, msg *ws.ClientMessage) {
+	if app.checkAdmin(c, msg) == nil {
+		return
+	}
+	var m *backup.Manifest
+	if app.DataDir != "" {
+		pending := filepath.Join(app.DataDir, backup.PendingDir)
+		if _, err := os.Stat(pending); err == nil {
+			var verr error
+			if m, verr = backup.Validate(pending); verr != nil {
+				slog.Warn("staged restore", "err", verr)
+			}
+		}
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK      bool             `json:"ok"`
+			Pending *backup.Manifest `json:"pending"`
+		}{OK: true, Pending: m})
+	}
+}
+
+// handleCancelConfigRestore discards the staged restore. Admin only and
+// requires sudo.
+func (app *App) handleCancelConfigRestore(c *ws.Conn, msg *ws.ClientMessage) {
+	user := app.checkAdmin(c, msg)
+	if user == nil || !app.requireSudo(c, msg) {
+		return
+	}
+	if app.DataDir != "" {
+		if err := os.RemoveAll(filepath.Join(app.DataDir, backup.PendingDir)); err != nil {
+			slog.Error("cancel restore", "err", err)
+			if msg.ID != nil {
+				ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+			}
+			return
+		}
+	}
+	slog.Info("staged restore cancelled", "by", user.Username)
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Restore cancelled"})
+	}
+}
@@ -0,0 +1,323 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/cfilipov/dockge/internal/docker"
+	"github.com/cfilipov/dockge/internal/graphql"
+	"github.com/cfilipov/dockge/internal/models"
+)
+
+// settingGraphQLEnabled is the settings key that, when "1", serves the
+// read-only GraphQL API at /api/graphql.
+const settingGraphQLEnabled = "graphqlEnabled"
+
+// maxGraphQLRequest bounds the body of a GraphQL request.
+const maxGraphQLRequest = 64 << 10
+
+// graphqlData is what a GraphQL request reads: a snapshot of the caches the
+// broadcasts keep, taken once per request. Nothing here queries the daemon.
+type graphqlData struct {
+	token      *models.APIToken
+	stacks     map[string]StackBroadcastEntry
+	statuses   map[string]string
+	updates    map[string]bool // "stack/service" → has update
+	containers []docker.ContainerBroadcast
+	images     []docker.ImageSummary
+}
+
+// gqlStack, gqlService and gqlNetwork are the shapes of the cached data
+// the schema exposes that aren't a broadcast type as is.
+type gqlStack struct {
+	Name            string       `json:"name"`
+	Status          string       `json:"status"`
+	ComposeFileName string       `json:"composeFileName"`
+	Managed         bool         `json:"managed"`
+	Archived        bool         `json:"archived"`
+	HasUpdates      bool         `json:"hasUpdates"`
+	Services        []gqlService `json:"services"`
+}
+
+type gqlService struct {
+	Name      string `json:"name"`
+	Stack     string `json:"stack"`
+	Image     string `json:"image"`
+	Job       bool   `json:"job"`
+	HasUpdate bool   `json:"hasUpdate"`
+}
+
+type gqlNetwork struct {
+	Name string `json:"name"`
+	IPv4 string `json:"ipv4"`
+	IPv6 string `json:"ipv6"`
+	MAC  string `json:"mac"`
+}
+
+// graphqlSnapshot gathers the cached data a token can see.
+func (app *App) graphqlSnapshot(token *models.APIToken) *graphqlData {
+	entries, _, statuses, _, _ := app.stackListInputs()
+	d := &graphqlData{token: token, stacks: map[string]StackBroadcastEntry{}, statuses: statuses}
+	for name, e := range entries {
+		if token.CanSeeStack(name) {
+			d.stacks[name] = e
+		}
+	}
+	d.updates, _ = app.ImageUpdates.AllServiceUpdates()
+	replay := app.replay.snapshot()
+	for _, v := range replay[chanContainers] {
+		if c, ok := v.(docker.ContainerBroadcast); ok && (len(token.Stacks) == 0 || (c.StackName != "" && token.CanSeeStack(c.StackName))) {
+			d.containers = append(d.containers, c)
+		}
+	}
+	sort.Slice(d.containers, func(i, j int) bool { return d.containers[i].Name < d.containers[j].Name })
+	for _, v := range replay[chanImages] {
+		if img, ok := v.(docker.ImageSummary); ok {
+			d.images = append(d.images, img)
+		}
+	}
+	sort.Slice(d.images, func(i, j int) bool { return d.images[i].ID < d.images[j].ID })
+	return d
+}
+
+// requireScope returns an error if the request's token can't read a kind of
+// data.
+func (d *graphqlData) requireScope(scope string) error {
+	if !d.token.HasScope(scope) {
+		return fmt.Errorf("token is not allowed to read %s", scope)
+	}
+	return nil
+}
+
+// stack returns a stack the token can see, or nil.
+func (d *graphqlData) stack(name string) *gqlStack {
+	e, ok := d.stacks[name]
+	if !ok {
+		// Stacks of containers that aren't in the list, e.g. ignored ones,
+		// are only visible as the containers' stackName
+		return nil
+	}
+	s := &gqlStack{
+		Name:            e.Name,
+		Status:          d.statuses[name],
+		ComposeFileName: e.ComposeFileName,
+		Managed:         e.IsManagedByDockge,
+		Archived:        e.Archived,
+		Services:        []gqlService{},
+	}
+	for svc, image := range e.Images {
+		hasUpdate := d.updates[name+"/"+svc]
+		s.HasUpdates = s.HasUpdates || hasUpdate
+		s.Services = append(s.Services, gqlService{Name: svc, Stack: name, Image: image, Job: e.Jobs[svc], HasUpdate: hasUpdate})
+	}
+	sort.Slice(s.Services, func(i, j int) bool { return s.Services[i].Name < s.Services[j].Name })
+	return s
+}
+
+func (d *graphqlData) containersWhere(keep func(c docker.ContainerBroadcast) bool) []docker.ContainerBroadcast {
+	out := []docker.ContainerBroadcast{}
+	for _, c := range d.containers {
+		if keep(c) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// graphqlSchema builds the schema over a request's snapshot.
+func graphqlSchema(d *graphqlData) *graphql.Schema {
+	port := &graphql.Object{Name: "Port", Fields: map[string]*graphql.Field{
+		"hostPort": {}, "containerPort": {}, "protocol": {},
+	}}
+	mount := &graphql.Object{Name: "Mount", Fields: map[string]*graphql.Field{
+		"name": {}, "type": {},
+	}}
+	network := &graphql.Object{Name: "ContainerNetwork", Fields: map[string]*graphql.Field{
+		"name": {}, "ipv4": {}, "ipv6": {}, "mac": {},
+	}}
+	container := &graphql.Object{Name: "Container", Fields: map[string]*graphql.Field{
+		"name": {}, "containerId": {}, "serviceName": {}, "stackName": {},
+		"state": {}, "health": {}, "image": {}, "imageId": {},
+		"ports":  {Type: port},
+		"mounts": {Type: mount},
+		"networks": {Type: network, Resolve: func(source any, _ map[string]any) (any, error) {
+			c := source.(docker.ContainerBroadcast)
+			list := make([]gqlNetwork, 0, len(c.Networks))
+			for name, n := range c.Networks {
+				list = append(list, gqlNetwork{Name: name, IPv4: n.IPv4, IPv6: n.IPv6, MAC: n.MAC})
+			}
+			sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+			return list, nil
+		}},
+	}}
+	service := &graphql.Object{Name: "Service", Fields: map[string]*graphql.Field{
+		"name": {}, "image": {}, "job": {}, "hasUpdate": {},
+		"containers": {Type: container, Resolve: func(source any, _ map[string]any) (any, error) {
+			if err := d.requireScope(models.ScopeContainers); err != nil {
+				return nil, err
+			}
+			svc := source.(gqlService)
+			return d.containersWhere(func(c docker.ContainerBroadcast) bool {
+				return c.StackName == svc.Stack && c.ServiceName == svc.Name
+			}), nil
+		}},
+	}}
+	stackObj := &graphql.Object{Name: "Stack", Fields: map[string]*graphql.Field{
+		"name": {}, "status": {}, "composeFileName": {}, "managed": {}, "archived": {}, "hasUpdates": {},
+		"services": {Type: service},
+		"containers": {Type: container, Resolve: func(source any, _ map[string]any) (any, error) {
+			if err := d.requireScope(models.ScopeContainers); err != nil {
+				return nil, err
+			}
+			name := source.(*gqlStack).Name
+			return d.containersWhere(func(c docker.ContainerBroadcast) bool { return c.StackName == name }), nil
+		}},
+	}}
+	container.Fields["stack"] = &graphql.Field{Type: stackObj, Resolve: func(source any, _ map[string]any) (any, error) {
+		if err := d.requireScope(models.ScopeStacks); err != nil {
+			return nil, err
+		}
+		return d.stack(source.(docker.ContainerBroadcast).StackName), nil
+	}}
+	image := &graphql.Object{Name: "Image", Fields: map[string]*graphql.Field{
+		"id": {}, "repoTags": {}, "size": {}, "sizeBytes": {}, "created": {}, "dangling": {},
+		"containers": {Type: container, Resolve: func(source any, _ map[string]any) (any, error) {
+			if err := d.requireScope(models.ScopeContainers); err != nil {
+				return nil, err
+			}
+			id := source.(docker.ImageSummary).ID
+			return d.containersWhere(func(c docker.ContainerBroadcast) bool { return c.ImageID == id }), nil
+		}},
+	}}
+
+	query := &graphql.Object{Name: "Query", Fields: map[string]*graphql.Field{
+		"stacks": {Type: stackObj, Args: []string{"status", "hasUpdates"}, Resolve: func(_ any, args map[string]any) (any, error) {
+			if err := d.requireScope(models.ScopeStacks); err != nil {
+				return nil, err
+			}
+			status, _ := args["status"].(string)
+			hasUpdates, filterUpdates := args["hasUpdates"].(bool)
+			names := make([]string, 0, len(d.stacks))
+			for name := range d.stacks {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			list := []*gqlStack{}
+			for _, name := range names {
+				s := d.stack(name)
+				if (status == "" || s.Status == status) && (!filterUpdates || s.HasUpdates == hasUpdates) {
+					list = append(list, s)
+				}
+			}
+			return list, nil
+		}},
+		"stack": {Type: stackObj, Args: []string{"name"}, Resolve: func(_ any, args map[string]any) (any, error) {
+			if err := d.requireScope(models.ScopeStacks); err != nil {
+				return nil, err
+			}
+			name, _ := args["name"].(string)
+			return d.stack(name), nil
+		}},
+		"containers": {Type: container, Args: []string{"stack", "state"}, Resolve: func(_ any, args map[string]any) (any, error) {
+			if err := d.requireScope(models.ScopeContainers); err != nil {
+				return nil, err
+			}
+			stackName, _ := args["stack"].(string)
+			state, _ := args["state"].(string)
+			return d.containersWhere(func(c docker.ContainerBroadcast) bool {
+				return (stackName == "" || c.StackName == stackName) && (state == "" || c.State == state)
+			}), nil
+		}},
+		"container": {Type: container, Args: []string{"name"}, Resolve: func(_ any, args map[string]any) (any, error) {
+			if err := d.requireScope(models.ScopeContainers); err != nil {
+				return nil, err
+			}
+			name, _ := args["name"].(string)
+			for _, c := range d.containers {
+				if c.Name == name {
+					return c, nil
+				}
+			}
+			return nil, nil
+		}},
+		"images": {Type: image, Args: []string{"dangling"}, Resolve: func(_ any, args map[string]any) (any, error) {
+			if err := d.requireScope(models.ScopeImages); err != nil {
+				return nil, err
+			}
+			dangling, filter := args["dangling"].(bool)
+			list := []docker.ImageSummary{}
+			for _, img := range d.images {
+				if !filter || img.Dangling == dangling {
+					list = append(list, img)
+				}
+			}
+			return list, nil
+		}},
+	}}
+	return &graphql.Schema{Query: query}
+}
+
+// errGraphQLToken is the error of requests without a valid token.
+var errGraphQLToken = errors.New("a valid API token is required")
+
+// ServeGraphQL answers read-only GraphQL queries over the cached stack,
+// container and image data, for custom dashboards. Requests authenticate
+// with an API token as a Bearer token, and see what its scopes allow.
+// GET takes the query in the URL, POST as JSON.
+func (app *App) ServeGraphQL(w http.ResponseWriter, r *http.Request) {
+	if v, _ := app.Settings.Get(settingGraphQLEnabled); v != "1" || app.APITokens == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeErr := func(status int, err error) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(graphql.Response{Errors: []*graphql.Error{{Message: err.Error()}}})
+	}
+
+	secret, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	token, err := app.APITokens.Authenticate(strings.TrimSpace(secret))
+	if err != nil {
+		slog.Error("graphql token", "err", err)
+		writeErr(http.StatusInternalServerError, errors.New("internal error"))
+		return
+	}
+	if !ok || token == nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="dockge"`)
+		writeErr(http.StatusUnauthorized, errGraphQLToken)
+		return
+	}
+
+	var req graphql.Request
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		req.Query, req.OperationName = q.Get("query"), q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				writeErr(http.StatusBadRequest, errors.New("variables must be a JSON object"))
+				return
+			}
+		}
+	default:
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxGraphQLRequest)).Decode(&req); err != nil {
+			writeErr(http.StatusBadRequest, errors.New("body must be a JSON GraphQL request"))
+			return
+		}
+	}
+	if len(req.Query) > maxGraphQLRequest {
+		writeErr(http.StatusBadRequest, errors.New("query is too long"))
+		return
+	}
+
+	resp := graphql.Execute(graphqlSchema(app.graphqlSnapshot(token)), req)
+	if resp.Data == nil {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/cfilipov/dockge/internal/docker"
+	"github.com/cfilipov/dockge/internal/graphql"
+	"github.com/cfilipov/dockge/internal/models"
+)
+
+func testGraphQLData(token *models.APIToken) *graphqlData {
+	containers := []docker.ContainerBroadcast{
+		{Name: "db-1", StackName: "db", ServiceName: "postgres", State: "running", ImageID: "sha256:pg"},
+		{Name: "web-app-1", StackName: "web", ServiceName: "app", State: "running", ImageID: "sha256:app",
+			Networks: map[string]docker.ContainerNetwork{"web_default": {IPv4: "172.18.0.2"}}},
+		{Name: "web-cron-1", StackName: "web", ServiceName: "cron", State: "exited", ImageID: "sha256:app"},
+	}
+	d := &graphqlData{
+		token: token,
+		stacks: map[string]StackBroadcastEntry{
+			"db":  {Name: "db", ComposeFileName: "compose.yaml", Images: map[string]string{"postgres": "postgres:16"}, IsManagedByDockge: true},
+			"web": {Name: "web", ComposeFileName: "compose.yaml", Images: map[string]string{"app": "app:1", "cron": "app:1"}, Jobs: map[string]bool{"cron": true}, IsManagedByDockge: true},
+		},
+		statuses: map[string]string{"db": "running", "web": "running"},
+		updates:  map[string]bool{"web/app": true},
+		images: []docker.ImageSummary{
+			{ID: "sha256:app", RepoTags: []string{"app:1"}},
+			{ID: "sha256:old", Dangling: true},
+		},
+	}
+	for name := range d.stacks {
+		if !token.CanSeeStack(name) {
+			delete(d.stacks, name)
+		}
+	}
+	for _, c := range containers {
+		if token.CanSeeStack(c.StackName) {
+			d.containers = append(d.containers, c)
+		}
+	}
+	return d
+}
+
+func runGraphQL(t *testing.T, token *models.APIToken, query string) string {
+	t.Helper()
+	out, err := json.Marshal(graphql.Execute(graphqlSchema(testGraphQLData(token)), graphql.Request{Query: query}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestGraphQLSchema(t *testing.T) {
+	all := &models.APIToken{Scopes: models.APITokenScopes}
+
+	got := runGraphQL(t, all, `{ stacks(hasUpdates: true) { name hasUpdates services { name job hasUpdate containers { name state } } } }`)
+	want := `{"data":{"stacks":[{"name":"web","hasUpdates":true,"services":[` +
+		`{"name":"app","job":false,"hasUpdate":true,"containers":[{"name":"web-app-1","state":"running"}]},` +
+		`{"name":"cron","job":true,"hasUpdate":false,"containers":[{"name":"web-cron-1","state":"exited"}]}]}]}}`
+	if got != want {
+		t.Errorf("stacks:\ngot  %s\nwant %s", got, want)
+	}
+
+	got = runGraphQL(t, all, `{ container(name: "web-app-1") { networks { name ipv4 } stack { name status } } }`)
+	want = `{"data":{"container":{"networks":[{"name":"web_default","ipv4":"172.18.0.2"}],"stack":{"name":"web","status":"running"}}}}`
+	if got != want {
+		t.Errorf("container:\ngot  %s\nwant %s", got, want)
+	}
+
+	got = runGraphQL(t, all, `{ images(dangling: false) { id containers { name } } }`)
+	want = `{"data":{"images":[{"id":"sha256:app","containers":[{"name":"web-app-1"},{"name":"web-cron-1"}]}]}}`
+	if got != want {
+		t.Errorf("images:\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestGraphQLTokenScopes(t *testing.T) {
+	// Only stacks, and only the db stack
+	token := &models.APIToken{Scopes: []string{models.ScopeStacks}, Stacks: []string{"db"}}
+
+	got := runGraphQL(t, token, `{ stacks { name } stack(name: "web") { name } }`)
+	if want := `{"data":{"stacks":[{"name":"db"}],"stack":null}}`; got != want {
+		t.Errorf("stacks:\ngot  %s\nwant %s", got, want)
+	}
+
+	for _, query := range []string{
+		`{ containers { name } }`,
+		`{ images { id } }`,
+		`{ stack(name: "db") { containers { name } } }`,
+	} {
+		got := runGraphQL(t, token, query)
+		if !strings.Contains(got, "token is not allowed to read") {
+			t.Errorf("%s: expected a scope error, got %s", query, got)
+		}
+	}
+
+	// Containers of stacks outside the restriction are hidden
+	token = &models.APIToken{Scopes: []string{models.ScopeContainers}, Stacks: []string{"db"}}
+	got = runGraphQL(t, token, `{ containers { name } web: containers(stack: "web") { name } }`)
+	if want := `{"data":{"containers":[{"name":"db-1"}],"web":[]}}`; got != want {
+		t.Errorf("containers:\ngot  %s\nwant %s", got, want)
+	}
+}
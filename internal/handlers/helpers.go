@@ -5,10 +5,22 @@ import (
 	"encoding/json"
 	"log/slog"
 	"sync"
+	"sync/atomic"
 
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/cfilipov/dockge/internal/agent"
+	"github.com/cfilipov/dockge/internal/debug"
 	"github.com/cfilipov/dockge/internal/docker"
+	"github.com/cfilipov/dockge/internal/logcapture"
+	"github.com/cfilipov/dockge/internal/metrics"
 	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/notify"
+	"github.com/cfilipov/dockge/internal/registry"
+	"github.com/cfilipov/dockge/internal/scheduler"
+	"github.com/cfilipov/dockge/internal/secrets"
 	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/templates"
 	"github.com/cfilipov/dockge/internal/terminal"
 	"github.com/cfilipov/dockge/internal/ws"
 )
@@ -22,6 +34,8 @@ type App struct {
 	Docker       docker.Client
 	Terms        *terminal.Manager
 	StackLocks   *stack.NamedMutex // per-stack mutex for write serialization
+	Projects     *stack.ProjectMap // compose project names of stacks (nil = not checked for conflicts)
+	DB           *bolt.DB          // the database, copied by full backups (nil = disabled)
 	NoAuth       bool             // Skip authentication checks (all endpoints open)
 	Dev          bool             // Development mode (enables mock reset proxy, etc.)
 
@@ -29,19 +43,160 @@ type App struct {
 	NeedSetup        bool
 	Version          string
 	StacksDir        string
+	ExportDir        string // container snapshot exports are written here ("" = disabled)
+	DataDir          string // full backup restores are staged here ("" = disabled)
 	MainTerminalName string // tracked for checkMainTerminal
 
 	// Dispatch channel for event-driven broadcasts (1+1 goroutine model)
 	dispatchCh   chan dispatchWork
 	BcastMetrics *BroadcastMetrics
 
+	// replay holds the latest resource channel state for new connections
+	replay replayCache
+	// summary caches the dashboard header counts computed from replay
+	summary summaryState
+
 	// EventBus fans out Docker events from the single broadcast watcher
 	// to per-terminal subscribers, replacing per-terminal Events() calls.
 	EventBus *EventBus
 
+	// Followers tracks running log-follow goroutines for leak detection
+	// and reaping.
+	Followers *FollowerRegistry
+
 	// Login rate limiter: prevents brute-force password guessing
 	LoginLimiter *LoginRateLimiter
 
+	// PendingChanges stores operator changes awaiting admin approval
+	PendingChanges *models.PendingChangeStore
+
+	// Budgets stores per-stack resource budgets (nil = disabled)
+	Budgets     *models.StackBudgetStore
+	budgetState budgetState
+
+	// Operations stores the history of stack operations (nil = disabled)
+	Operations *models.OperationStore
+
+	// Audit records every state-changing request (nil = disabled)
+	Audit        *models.AuditStore
+	AuditLogFile string // each entry is also appended here as a JSON line ("" = disabled)
+
+	// UpdateIgnores lists images and services whose updates are skipped (nil = disabled)
+	UpdateIgnores *models.UpdateIgnoreStore
+
+	// StackNotes stores free-form notes per stack (nil = disabled)
+	StackNotes *models.StackNoteStore
+
+	// StackEvents counts restarts and health flaps per stack (nil = disabled)
+	StackEvents *models.StackEventStore
+	stackEvents stackEventState
+
+	// StackArchive marks stacks hidden and kept from starting (nil = disabled)
+	StackArchive *models.StackArchiveStore
+
+	// TerminalAccess restricts exec terminals per stack (nil = unrestricted)
+	TerminalAccess *models.StackTerminalAccessStore
+
+	// TerminalEnv overrides TERM, LANG and shells of exec terminals per stack (nil = global settings only)
+	TerminalEnv *models.StackTerminalEnvStore
+
+	// ExecDefaults remembers each user's exec user, workdir and env (nil = not remembered)
+	ExecDefaults *models.ExecDefaultsStore
+
+	// LogCapture lists the stacks whose logs are written to disk (nil = disabled)
+	LogCapture *models.StackLogCaptureStore
+
+	// PullPolicies sets the default pull policy of starts and deploys per stack (nil = compose default)
+	PullPolicies *models.StackPullPolicyStore
+	// ServiceSelections remembers the services of each stack's last partial start (nil = not remembered)
+	ServiceSelections *models.StackServiceSelectionStore
+	// StackWebhooks posts stack lifecycle events to outbound webhooks (nil = disabled)
+	StackWebhooks *models.StackWebhookStore
+	// DeployedCompose keeps the files of each stack's last deploy (nil = not kept)
+	DeployedCompose *models.DeployedComposeStore
+	// StackGroups sorts stacks into folders in the stack list (nil = disabled)
+	StackGroups *models.StackGroupStore
+	// HostState keeps the container snapshot and reboot report (nil = no reboot reports)
+	HostState *models.HostStateStore
+	// APITokens authenticate requests to the GraphQL API (nil = no API)
+	APITokens *models.APITokenStore
+
+	// HostTerminal is config.HostTerminalLocal or config.HostTerminalContainer
+	// to let admins open a shell on the host ("" = disabled)
+	HostTerminal      string
+	HostTerminalImage string // image of the helper container
+
+	// Idempotency remembers the keys of recent mutating requests, so
+	// retries don't run them twice (nil = keys are ignored)
+	Idempotency *models.IdempotencyStore
+
+	// Schedules stores cron schedules of stack actions (nil = disabled)
+	Schedules *models.StackScheduleStore
+
+	// Registry lists image tags to classify updates as patch/minor/major (nil = disabled)
+	Registry *registry.Client
+
+	// Secrets resolves Vault placeholders and SOPS-encrypted env files
+	// before containers are created (nil = env files are used as they are)
+	Secrets *secrets.Resolver
+
+	// updateAllRunning is set while an "update all stacks" batch runs
+	updateAllRunning atomic.Bool
+
+	// bulkStacksRunning is set while a "start all" or "stop all" batch runs
+	bulkStacksRunning atomic.Bool
+
+	// Profiles captures heap/goroutine profiles on high load (nil = disabled)
+	Profiles *debug.ProfileWatchdog
+
+	// housekeeping serializes retention runs and keeps the last report
+	housekeeping housekeepingState
+
+	// scheduler runs Schedules; created by RegisterScheduleHandlers
+	scheduler *scheduler.Scheduler
+
+	// metrics keeps per-service usage history; created by RegisterMetricsHandlers
+	metrics *metrics.Collector
+
+	// logCapture writes the logs of LogCapture's stacks to DataDir; created
+	// by RegisterLogCaptureHandlers
+	logCapture *logcapture.Capturer
+
+	// daemon caches the daemon info (rootless, cgroup support)
+	daemon daemonState
+
+	// ignore caches the parsed ignore list of projects and containers
+	ignore ignoreState
+
+	// Agents stores the remote agents this controller manages (nil = disabled)
+	Agents *models.AgentStore
+
+	// Templates is the catalog new stacks can be created from (nil = disabled)
+	Templates *templates.Catalog
+
+	// backupLinks are the one-time stack backup download and upload links
+	backupLinks backupLinks
+
+	// logLinks are the one-time captured log download links
+	logLinks backupLinks
+
+	// volumeFileLinks are the one-time volume file download links
+	volumeFileLinks backupLinks
+
+	// notifier sends notifications; created by RegisterNotifyHandlers
+	notifier    *notify.Notifier
+	notifyState notifyState
+
+	// rebootPending is set until the reboot report of this start is done
+	rebootPending atomic.Bool
+
+	// agentHub tracks connected agents; created by RegisterAgentHandlers
+	agentHub *agent.Hub
+
+	// agentSessions maps connections a controller opened on this agent to
+	// the controller user: connID → username
+	agentSessions sync.Map
+
 	// Stats streaming subscriptions: connID → active subscription
 	statsSubs   map[string]*statsSubscription
 	statsSubsMu sync.Mutex
@@ -49,6 +204,16 @@ type App struct {
 	// Top (process list) streaming subscriptions: connID → active subscription
 	topSubs   map[string]*topSubscription
 	topSubsMu sync.Mutex
+
+	// Recent container events and the connections following them
+	eventsFeed eventsFeedState
+
+	// stackList numbers the stacks channel's states and keeps stack list
+	// query subscriptions
+	stackList stackListState
+
+	// quickAction calls made with an idempotency key
+	quickActions quickActionState
 }
 
 // statsSubscription tracks an active stats streaming goroutine for a connection.
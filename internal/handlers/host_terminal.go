@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"log/slog"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/config"
+	"github.com/cfilipov/dockge/internal/terminal"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// hostShellScript starts the first login shell of the host, like
+// exec terminals do in containers.
+const hostShellScript = "if command -v bash >/dev/null 2>&1; then exec bash -l; fi; exec sh -l"
+
+// RegisterHostTerminalHandlers registers hostTerminal. Input, resize and
+// terminalLeave work as for the terminalJoin sessions.
+func RegisterHostTerminalHandlers(app *App) {
+	app.WS.Handle("hostTerminal", app.handleHostTerminal)
+}
+
+// handleHostTerminal opens a shell on the Docker host, or rejoins the one
+// the admin already has open. It's disabled unless --host-terminal is set,
+// since it gives root on the host to anyone with an admin login, and
+// opening a shell needs sudo mode.
+// Args: {shell?, streamId?, offset?, flowControl?}.
+func (app *App) handleHostTerminal(c *ws.Conn, msg *ws.ClientMessage) {
+	admin := app.checkAdmin(c, msg)
+	if admin == nil {
+		return
+	}
+	if app.HostTerminal == "" {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "hostTerminalDisabled", MsgI18n: true})
+		}
+		return
+	}
+	var args ws.TerminalJoinArgs
+	argObject(parseArgs(msg), 0, &args)
+	if args.Shell != "" && !terminalShellRe.MatchString(args.Shell) {
+		sendJoinError(c, msg, "invalid shell "+args.Shell)
+		return
+	}
+
+	termName := "host-terminal-" + strconv.Itoa(admin.ID)
+	if existing := app.Terms.Get(termName); existing != nil && existing.IsRunning() {
+		app.allocJoinAndReplay(c, msg, termName, true, existing, &args)
+		return
+	}
+	if !app.requireSudo(c, msg) {
+		return
+	}
+
+	term := app.Terms.Recreate(termName, terminal.TypePTY)
+	session := &ws.TermSession{
+		TermName:    termName,
+		Interactive: true,
+		FlowControl: args.FlowControl,
+	}
+	sessionID := c.AllocSession(session)
+	term.AddWriter(session.WriterKey, sessionBinaryWriter(c, sessionID))
+
+	cmd, cleanup := app.hostShellCommand(termName, args.Shell)
+	// Registered first: a shell that fails right away may exit before
+	// StartPTY returns.
+	term.OnExit(func() {
+		app.Terms.RemoveAfter(termName, 30*time.Second)
+		exitCode := cmd.ProcessState.ExitCode()
+		ws.SendEvent(c, "terminalExited", ws.TerminalExitedData{SessionID: sessionID, ExitCode: &exitCode})
+	})
+	if err := term.StartPTY(cmd); err != nil {
+		slog.Error("host terminal start", "err", err, "mode", app.HostTerminal)
+		app.Terms.Remove(termName)
+		c.RemoveSession(sessionID)
+		sendJoinError(c, msg, "failed to start terminal: "+err.Error())
+		return
+	}
+	if cleanup != nil {
+		term.SetCancel(cleanup)
+	}
+	slog.Info("host terminal opened", "mode", app.HostTerminal, "by", admin.Username)
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.TerminalJoinResponse{OK: true, SessionID: sessionID, StreamID: term.StreamID()})
+	}
+}
+
+// hostShellCommand returns the command of a host terminal and, in
+// container mode, a cleanup removing its helper container should the
+// terminal be closed before the shell exits.
+func (app *App) hostShellCommand(termName, shell string) (*exec.Cmd, func()) {
+	if app.HostTerminal == config.HostTerminalLocal {
+		cmd := exec.Command("sh", "-c", hostShellScript)
+		if shell != "" {
+			cmd = exec.Command(shell, "-l")
+		}
+		cmd.Env = append(os.Environ(), "TERM=xterm-256color")
+		if home, err := os.UserHomeDir(); err == nil {
+			cmd.Dir = home
+		}
+		return cmd, nil
+	}
+
+	// The helper shares the host's PID namespace and enters the
+	// namespaces of its init, so the shell sees the host's filesystem,
+	// network and processes.
+	name := "dockge-" + termName
+	shellArgs := []string{"sh", "-c", hostShellScript}
+	if shell != "" {
+		shellArgs = []string{shell, "-l"}
+	}
+	args := append([]string{
+		"run", "--rm", "-it", "--name", name,
+		"--privileged", "--pid=host", "--network=host", "--ipc=host", "--uts=host",
+		"-e", "TERM=xterm-256color",
+		app.HostTerminalImage,
+		"nsenter", "-t", "1", "-m", "-u", "-i", "-n", "-p", "--",
+	}, shellArgs...)
+	cmd := exec.Command("docker", args...)
+	cmd.Env = os.Environ()
+	return cmd, func() {
+		go func() {
+			if out, err := exec.Command("docker", "rm", "-f", name).CombinedOutput(); err != nil {
+				slog.Debug("host terminal cleanup", "err", err, "out", string(out), "container", name)
+			}
+		}()
+	}
+}
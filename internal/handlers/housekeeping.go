@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+const (
+	// housekeepingInterval is how often old data is pruned.
+	housekeepingInterval = 24 * time.Hour
+
+	// housekeepingStartDelay defers the first run past startup work.
+	housekeepingStartDelay = 5 * time.Minute
+)
+
+// Retention categories. Each has a "<category>RetentionDays" setting; 0
+// keeps the data forever.
+const (
+	retentionOperations = "operations" // finished stack operations
+	retentionApprovals  = "approvals"  // approved/rejected change requests
+	retentionProfiles   = "profiles"   // debug profiles in the data dir
+)
+
+// defaultRetentionDays applies when a category's setting is unset or invalid.
+var defaultRetentionDays = map[string]int{
+	retentionOperations: 90,
+	retentionApprovals:  180,
+	retentionProfiles:   30,
+}
+
+// HousekeepingResult is what one retention category pruned.
+type HousekeepingResult struct {
+	Category      string `json:"category"`
+	RetentionDays int    `json:"retentionDays"` // 0 = kept forever, nothing pruned
+	Removed       int    `json:"removed"`
+	FreedBytes    int64  `json:"freedBytes"`
+	Error         string `json:"error,omitempty"`
+}
+
+// HousekeepingReport summarizes a housekeeping run. Freed bytes of database
+// records are reused by BoltDB rather than returned to the filesystem.
+type HousekeepingReport struct {
+	RanAt      int64                `json:"ranAt"` // Unix seconds
+	Results    []HousekeepingResult `json:"results"`
+	Removed    int                  `json:"removed"`
+	FreedBytes int64                `json:"freedBytes"`
+}
+
+type housekeepingState struct {
+	run  sync.Mutex // serializes runs
+	mu   sync.Mutex
+	last *HousekeepingReport
+}
+
+// RegisterHousekeepingHandlers registers the data retention handlers.
+func RegisterHousekeepingHandlers(app *App) {
+	app.WS.Handle("getHousekeepingReport", app.handleGetHousekeepingReport)
+	app.WS.Handle("runHousekeeping", app.handleRunHousekeeping)
+}
+
+// StartHousekeeping prunes old data once a day according to the retention
+// settings, re-reading them on each run.
+func (app *App) StartHousekeeping(ctx context.Context) {
+	go func() {
+		delay := housekeepingStartDelay
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+				app.runHousekeeping()
+			}
+			delay = housekeepingInterval
+		}
+	}()
+}
+
+// retentionDays reads a category's retention setting.
+func (app *App) retentionDays(category string) int {
+	val, err := app.Settings.Get(category + "RetentionDays")
+	if err != nil || val == "" {
+		return defaultRetentionDays[category]
+	}
+	days, err := strconv.Atoi(val)
+	if err != nil || days < 0 {
+		return defaultRetentionDays[category]
+	}
+	return days
+}
+
+// runHousekeeping prunes every category past its retention and records the
+// report for getHousekeepingReport.
+func (app *App) runHousekeeping() *HousekeepingReport {
+	app.housekeeping.run.Lock()
+	defer app.housekeeping.run.Unlock()
+
+	now := time.Now()
+	report := &HousekeepingReport{RanAt: now.Unix(), Results: []HousekeepingResult{}}
+	prune := func(category string, fn func(cutoff time.Time) (int, int64, error)) {
+		res := HousekeepingResult{Category: category, RetentionDays: app.retentionDays(category)}
+		if res.RetentionDays > 0 {
+			cutoff := now.AddDate(0, 0, -res.RetentionDays)
+			removed, freed, err := fn(cutoff)
+			res.Removed, res.FreedBytes = removed, freed
+			if err != nil {
+				slog.Warn("housekeeping", "category", category, "err", err)
+				res.Error = err.Error()
+			}
+		}
+		report.Results = append(report.Results, res)
+		report.Removed += res.Removed
+		report.FreedBytes += res.FreedBytes
+	}
+
+	if app.Operations != nil {
+		prune(retentionOperations, func(cutoff time.Time) (int, int64, error) {
+			return app.Operations.Prune(cutoff.Unix())
+		})
+	}
+	if app.PendingChanges != nil {
+		prune(retentionApprovals, func(cutoff time.Time) (int, int64, error) {
+			return app.PendingChanges.PruneResolved(cutoff.Unix())
+		})
+	}
+	if app.Profiles != nil {
+		prune(retentionProfiles, app.Profiles.PruneBefore)
+	}
+
+	slog.Info("housekeeping done", "removed", report.Removed, "freedBytes", report.FreedBytes)
+
+	app.housekeeping.mu.Lock()
+	app.housekeeping.last = report
+	app.housekeeping.mu.Unlock()
+	return report
+}
+
+func (app *App) handleGetHousekeepingReport(c *ws.Conn, msg *ws.ClientMessage) {
+	if app.checkAdmin(c, msg) == nil {
+		return
+	}
+	app.housekeeping.mu.Lock()
+	last := app.housekeeping.last
+	app.housekeeping.mu.Unlock()
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK     bool                `json:"ok"`
+			Report *HousekeepingReport `json:"report"` // nil until the first run
+		}{OK: true, Report: last})
+	}
+}
+
+// handleRunHousekeeping prunes old data now and returns the report.
+func (app *App) handleRunHousekeeping(c *ws.Conn, msg *ws.ClientMessage) {
+	user := app.checkAdmin(c, msg)
+	if user == nil {
+		return
+	}
+	slog.Info("housekeeping requested", "user", user.Username)
+	report := app.runHousekeeping()
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK     bool                `json:"ok"`
+			Report *HousekeepingReport `json:"report"`
+		}{OK: true, Report: report})
+	}
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// idempotentEvents are the events wrapped with idempotent.
+var idempotentEvents = map[string]bool{
+	"deployStack": true,
+	"updateStack": true,
+	"deleteStack": true,
+}
+
+func idempotentEvent(event string) bool {
+	return idempotentEvents[event]
+}
+
+// idempotent makes requests that carry a key run once per key: a retry
+// gets the ack of the first request instead of running again. Keys are
+// per user.
+func (app *App) idempotent(h ws.HandlerFunc) ws.HandlerFunc {
+	return func(c *ws.Conn, msg *ws.ClientMessage) {
+		uid := c.UserID()
+		if msg.Key == "" || app.Idempotency == nil || uid == 0 {
+			h(c, msg)
+			return
+		}
+		prev, err := app.Idempotency.Claim(idempotencyKey(uid, msg.Key), requestFingerprint(msg), time.Now())
+		if err != nil {
+			// Keys are best effort; don't fail the request over them
+			slog.Warn("idempotency key", "event", msg.Event, "err", err)
+			h(c, msg)
+			return
+		}
+		if prev == nil {
+			h(c, msg)
+			return
+		}
+
+		if msg.ID == nil {
+			return
+		}
+		switch {
+		case prev.Fingerprint != requestFingerprint(msg):
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Idempotency key was used for a different request"})
+		case prev.Ack == nil:
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "A request with this key is still being handled"})
+		default:
+			ws.SendAck(c, *msg.ID, prev.Ack)
+		}
+	}
+}
+
+// completeIdempotent stores the ack of a keyed request for its retries.
+func (app *App) completeIdempotent(c *ws.Conn, msg *ws.ClientMessage, ack []byte) {
+	uid := c.UserID()
+	if msg.Key == "" || uid == 0 {
+		return
+	}
+	data := json.RawMessage("null") // the request wasn't sent with an ID
+	if ack != nil {
+		var sent struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(ack, &sent); err != nil {
+			slog.Warn("idempotency ack", "event", msg.Event, "err", err)
+			return
+		}
+		data = sent.Data
+	}
+	if err := app.Idempotency.Complete(idempotencyKey(uid, msg.Key), data); err != nil {
+		slog.Warn("idempotency ack", "event", msg.Event, "err", err)
+	}
+}
+
+func idempotencyKey(uid int, key string) string {
+	return strconv.Itoa(uid) + "/" + key
+}
+
+// requestFingerprint identifies a request by its event and args.
+func requestFingerprint(msg *ws.ClientMessage) string {
+	sum := sha256.Sum256(append([]byte(msg.Event+"\x00"), msg.Args...))
+	return hex.EncodeToString(sum[:])
+}
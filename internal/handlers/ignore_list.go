@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/cfilipov/dockge/internal/docker"
+)
+
+// Settings keys of the ignore list: compose projects and containers left
+// out of the dashboard, discovery and update checks, such as throwaway CI
+// projects or buildx and devcontainer tooling.
+const (
+	settingIgnoreProjects = "ignoreProjects" // project name globs, one per line
+	settingIgnoreLabels   = "ignoreLabels"   // label selectors (key or key=glob), one per line
+)
+
+// labelSelector matches containers that have a label, with a value
+// matching a glob if one is given.
+type labelSelector struct {
+	key   string
+	value string // glob the value must match
+	has   bool   // unset for any value
+}
+
+func (s labelSelector) matches(labels map[string]string) bool {
+	v, ok := labels[s.key]
+	if !ok {
+		return false
+	}
+	if !s.has {
+		return true
+	}
+	m, _ := path.Match(s.value, v)
+	return m
+}
+
+// ignoreList is a parsed ignore list. A nil list ignores nothing.
+type ignoreList struct {
+	projects []string
+	labels   []labelSelector
+}
+
+// ignoreLines returns the non-empty, non-comment lines of a setting.
+func ignoreLines(val string) []string {
+	var lines []string
+	for _, line := range strings.Split(val, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// parseIgnoreList parses the ignore list settings, or returns nil if both
+// are empty.
+func parseIgnoreList(projects, labels string) (*ignoreList, error) {
+	l := &ignoreList{}
+	for _, p := range ignoreLines(projects) {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid project pattern %q", p)
+		}
+		l.projects = append(l.projects, p)
+	}
+	for _, line := range ignoreLines(labels) {
+		key, value, has := strings.Cut(line, "=")
+		s := labelSelector{key: strings.TrimSpace(key), value: strings.TrimSpace(value), has: has}
+		if s.key == "" {
+			return nil, fmt.Errorf("invalid label selector %q", line)
+		}
+		if _, err := path.Match(s.value, ""); err != nil {
+			return nil, fmt.Errorf("invalid label selector %q", line)
+		}
+		l.labels = append(l.labels, s)
+	}
+	if len(l.projects) == 0 && len(l.labels) == 0 {
+		return nil, nil
+	}
+	return l, nil
+}
+
+// ignoresProject reports whether a compose project is ignored by name.
+func (l *ignoreList) ignoresProject(name string) bool {
+	if l == nil || name == "" {
+		return false
+	}
+	for _, p := range l.projects {
+		if m, _ := path.Match(p, name); m {
+			return true
+		}
+	}
+	return false
+}
+
+// ignores reports whether a container is ignored, by its project or its
+// labels.
+func (l *ignoreList) ignores(project string, labels map[string]string) bool {
+	if l == nil {
+		return false
+	}
+	if l.ignoresProject(project) {
+		return true
+	}
+	for _, s := range l.labels {
+		if s.matches(labels) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterContainers returns the containers that aren't ignored, in place.
+func (l *ignoreList) filterContainers(containers []docker.ContainerBroadcast) []docker.ContainerBroadcast {
+	if l == nil {
+		return containers
+	}
+	kept := containers[:0]
+	for _, c := range containers {
+		if !l.ignores(c.StackName, c.Labels) {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+// ignoreState caches the parsed ignore list until the settings change.
+type ignoreState struct {
+	mu       sync.Mutex
+	projects string
+	labels   string
+	list     *ignoreList
+}
+
+// ignoreList returns the current ignore list, nil if it's empty.
+func (app *App) ignoreList() *ignoreList {
+	projects, _ := app.Settings.Get(settingIgnoreProjects)
+	labels, _ := app.Settings.Get(settingIgnoreLabels)
+
+	st := &app.ignore
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if projects == st.projects && labels == st.labels {
+		return st.list
+	}
+	list, err := parseIgnoreList(projects, labels)
+	if err != nil {
+		// Saved before validation existed; ignore nothing rather than guess
+		slog.Warn("ignore list", "err", err)
+	}
+	st.projects, st.labels, st.list = projects, labels, list
+	return list
+}
+
+// validateIgnoreSettings checks the ignore list settings among settings
+// about to be saved, and reports whether they're among them.
+func validateIgnoreSettings(data map[string]interface{}) (changed bool, err error) {
+	projects, hasProjects := data[settingIgnoreProjects].(string)
+	labels, hasLabels := data[settingIgnoreLabels].(string)
+	if !hasProjects && !hasLabels {
+		return false, nil
+	}
+	_, err = parseIgnoreList(projects, labels)
+	return true, err
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/cfilipov/dockge/internal/db"
+	"github.com/cfilipov/dockge/internal/docker"
+	"github.com/cfilipov/dockge/internal/models"
+)
+
+func TestParseIgnoreList(t *testing.T) {
+	t.Parallel()
+	l, err := parseIgnoreList("# CI runs\nci-*\n\n  buildx_buildkit  \n", "devcontainer.local_folder\ncom.example.role = tool*\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]bool{
+		"ci-1234":         true,
+		"buildx_buildkit": true,
+		"web":             false,
+		"":                false,
+		"my-ci-1":         false,
+	} {
+		if got := l.ignoresProject(name); got != want {
+			t.Errorf("ignoresProject(%q) = %v, want %v", name, got, want)
+		}
+	}
+
+	tests := []struct {
+		labels map[string]string
+		want   bool
+	}{
+		{map[string]string{"devcontainer.local_folder": "/src"}, true},
+		{map[string]string{"devcontainer.local_folder": ""}, true},
+		{map[string]string{"com.example.role": "tooling"}, true},
+		{map[string]string{"com.example.role": "app"}, false},
+		{map[string]string{"other": "x"}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := l.ignores("web", tt.labels); got != tt.want {
+			t.Errorf("ignores(web, %v) = %v, want %v", tt.labels, got, tt.want)
+		}
+	}
+	if !l.ignores("ci-7", nil) {
+		t.Error("ignores didn't match the project")
+	}
+
+	if l, err := parseIgnoreList(" \n# nothing\n", ""); l != nil || err != nil {
+		t.Errorf("empty settings = %v, %v, want nil", l, err)
+	}
+	for _, bad := range [][2]string{{"ci-[", ""}, {"", "=value"}, {"", "key=[x"}} {
+		if _, err := parseIgnoreList(bad[0], bad[1]); err == nil {
+			t.Errorf("parseIgnoreList(%q, %q) accepted", bad[0], bad[1])
+		}
+	}
+}
+
+func TestIgnoreListNil(t *testing.T) {
+	t.Parallel()
+	var l *ignoreList
+	if l.ignoresProject("web") || l.ignores("web", map[string]string{"a": "b"}) {
+		t.Error("nil list ignored something")
+	}
+	containers := []docker.ContainerBroadcast{{Name: "web-app-1", StackName: "web"}}
+	if got := l.filterContainers(containers); len(got) != 1 {
+		t.Errorf("nil list filtered %v", got)
+	}
+}
+
+func TestIgnoreListFilterContainers(t *testing.T) {
+	t.Parallel()
+	database, err := db.Open(filepath.Join(t.TempDir(), "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	app := &App{Settings: models.NewSettingStore(database)}
+
+	containers := []docker.ContainerBroadcast{
+		{Name: "web-app-1", StackName: "web"},
+		{Name: "ci-42-test-1", StackName: "ci-42"},
+		{Name: "buildx_buildkit_default", Labels: map[string]string{"com.docker.buildx": "1"}},
+	}
+	if got := app.ignoreList().filterContainers(append([]docker.ContainerBroadcast(nil), containers...)); len(got) != 3 {
+		t.Errorf("without settings kept %d, want 3", len(got))
+	}
+
+	app.Settings.Set(settingIgnoreProjects, "ci-*")
+	app.Settings.Set(settingIgnoreLabels, "com.docker.buildx")
+	got := app.ignoreList().filterContainers(containers)
+	if len(got) != 1 || got[0].Name != "web-app-1" {
+		t.Errorf("kept %v, want web-app-1", got)
+	}
+
+	// A pattern saved before validation ignores nothing
+	app.Settings.Set(settingIgnoreProjects, "ci-[")
+	if l := app.ignoreList(); l != nil {
+		t.Errorf("invalid settings = %v, want nil", l)
+	}
+}
+
+func TestValidateIgnoreSettings(t *testing.T) {
+	t.Parallel()
+	if changed, err := validateIgnoreSettings(map[string]interface{}{"primaryHostname": "x"}); changed || err != nil {
+		t.Errorf("other settings = %v, %v", changed, err)
+	}
+	if changed, err := validateIgnoreSettings(map[string]interface{}{settingIgnoreLabels: "a=b"}); !changed || err != nil {
+		t.Errorf("valid labels = %v, %v", changed, err)
+	}
+	if _, err := validateIgnoreSettings(map[string]interface{}{settingIgnoreProjects: "[", settingIgnoreLabels: ""}); err == nil {
+		t.Error("invalid pattern accepted")
+	}
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/compose"
+	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// maxTagCandidates bounds the newer tags listed per service.
+const maxTagCandidates = 20
+
+// imageTagRe is the tag grammar of the Docker reference spec.
+var imageTagRe = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+
+// serviceTagCandidates are the newer version tags of a service's image.
+type serviceTagCandidates struct {
+	Service    string   `json:"service"`
+	Image      string   `json:"image"`
+	Tag        string   `json:"tag"`
+	Pinned     bool     `json:"pinned"`     // the image reference carries a digest
+	Candidates []string `json:"candidates"` // newest first
+	Error      string   `json:"error,omitempty"`
+}
+
+// handleGetServiceTagCandidates lists, for each version-tagged service of a
+// stack, the newer tags of the same shape in its registry.
+// Args: stack name.
+func (app *App) handleGetServiceTagCandidates(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	stackName := argString(parseArgs(msg), 0)
+	if err := stack.ValidateStackName(stackName); err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	if app.Registry == nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Registry lookups are not available"})
+		}
+		return
+	}
+	path := compose.FindComposeFile(app.StacksDir, stackName)
+	if path == "" {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Stack not found"})
+		}
+		return
+	}
+
+	go func() {
+		services := []serviceTagCandidates{}
+		for svc, sd := range compose.ParseFile(path) {
+			_, tag, digest := compose.SplitImageRef(sd.Image)
+			if !compose.IsVersionTag(tag) {
+				continue
+			}
+			e := serviceTagCandidates{Service: svc, Image: sd.Image, Tag: tag, Pinned: digest != ""}
+			ctx, cancel := context.WithTimeout(context.Background(), perImageCheckTimeout)
+			tags, err := app.Registry.Tags(ctx, sd.Image)
+			cancel()
+			if err != nil {
+				slog.Debug("list image tags", "err", err, "image", sd.Image)
+				e.Error = err.Error()
+			}
+			e.Candidates = compose.NewerVersionTags(tag, tags, maxTagCandidates)
+			if e.Candidates == nil {
+				e.Candidates = []string{}
+			}
+			services = append(services, e)
+		}
+		sort.Slice(services, func(i, j int) bool { return services[i].Service < services[j].Service })
+
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, struct {
+				OK       bool                   `json:"ok"`
+				Services []serviceTagCandidates `json:"services"`
+			}{OK: true, Services: services})
+		}
+	}()
+}
+
+// handleUpdateServiceToTag rewrites a service's image to another tag of the
+// same repository in compose.yaml and redeploys the stack. The change is
+// recorded on the deploy's operation history entry with the previous image.
+// A digest-pinned image stays pinned, to the digest of the new tag, and
+// {pin: true} pins one that wasn't. Goes through approval when required,
+// without the pinning. Args: stack name, service, tag, {pin}.
+func (app *App) handleUpdateServiceToTag(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	stackName, service, ok := serviceActionArgs(c, msg)
+	if !ok {
+		return
+	}
+	args := parseArgs(msg)
+	tag := argString(args, 2)
+	var opts struct {
+		Pin bool `json:"pin"`
+	}
+	argObject(args, 3, &opts)
+	if !imageTagRe.MatchString(tag) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Invalid image tag"})
+		}
+		return
+	}
+	if app.rejectArchived(c, msg, stackName) {
+		return
+	}
+
+	app.StackLocks.Lock(stackName)
+
+	s := &stack.Stack{Name: stackName}
+	s.LoadFromDisk(app.StacksDir)
+	current := compose.ParseYAML(s.ComposeYAML)[service].Image
+	repo, _, digest := compose.SplitImageRef(current)
+	image := repo + ":" + tag
+	updated, found := compose.SetServiceImage(s.ComposeYAML, service, image)
+	if current == "" || !found {
+		app.StackLocks.Unlock(stackName)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Service image not found in compose file"})
+		}
+		return
+	}
+	if strings.Contains(current, "$") {
+		app.StackLocks.Unlock(stackName)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Service image is set from a variable"})
+		}
+		return
+	}
+	s.ComposeYAML = updated
+	note := service + ": " + current + " → " + image
+
+	if user, ok := app.approvalRequired(c); ok {
+		app.StackLocks.Unlock(stackName)
+		app.submitPendingChange(c, msg, user, models.PendingActionDeploy, s, note)
+		return
+	}
+
+	if err := s.SaveToDisk(app.StacksDir); err != nil {
+		app.StackLocks.Unlock(stackName)
+		slog.Error("update service tag", "err", err, "stack", stackName)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	app.handleComposeYAMLSave(stackName, s.ComposeYAML)
+	slog.Info("service image tag changed", "stack", stackName, "service", service, "from", current, "to", image)
+
+	go func() {
+		defer app.StackLocks.Unlock(stackName)
+		err := app.runDeployWithValidation(stackName, note, false, "")
+		if err == nil && (opts.Pin || digest != "") {
+			app.pinDeployedImage(stackName, service, image)
+		}
+		if msg.ID == nil {
+			return
+		}
+		if err != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+			return
+		}
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Deployed"})
+	}()
+}
+
+// pinDeployedImage rewrites a service's image to image@digest, with the
+// digest of the image the deploy just pulled. The caller holds the stack
+// lock.
+func (app *App) pinDeployedImage(stackName, service, image string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	d := imageDigest(ctx, app, image)
+	cancel()
+	if d == "" {
+		slog.Warn("pin updated image: no digest", "stack", stackName, "image", image)
+		return
+	}
+	s := &stack.Stack{Name: stackName}
+	s.LoadFromDisk(app.StacksDir)
+	updated, ok := compose.SetServiceImage(s.ComposeYAML, service, image+"@"+d)
+	if !ok {
+		return
+	}
+	s.ComposeYAML = updated
+	if err := s.SaveToDisk(app.StacksDir); err != nil {
+		slog.Error("pin updated image", "err", err, "stack", stackName)
+		return
+	}
+	app.handleComposeYAMLSave(stackName, s.ComposeYAML)
+}
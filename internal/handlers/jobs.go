@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/compose"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// stackJob is the last run of a job service (see compose.ServiceData.IsJob).
+// Container is empty if the job never ran.
+type stackJob struct {
+	Service    string `json:"service"`
+	Container  string `json:"container,omitempty"`
+	State      string `json:"state,omitempty"`
+	ExitCode   int    `json:"exitCode"`
+	StartedAt  string `json:"startedAt,omitempty"`
+	FinishedAt string `json:"finishedAt,omitempty"`
+}
+
+// stackJobServices returns the job services of a managed stack.
+func (app *App) stackJobServices(stackName string) map[string]bool {
+	path := compose.FindComposeFile(app.StacksDir, stackName)
+	if path == "" {
+		return nil
+	}
+	jobs := make(map[string]bool)
+	for svc, sd := range compose.ParseFile(path) {
+		if sd.IsJob() {
+			jobs[svc] = true
+		}
+	}
+	return jobs
+}
+
+// isJobService reports whether a service of a managed stack is a job.
+func (app *App) isJobService(stackName, serviceName string) bool {
+	if stackName == "" || serviceName == "" || stack.ValidateStackName(stackName) != nil {
+		return false
+	}
+	return app.stackJobServices(stackName)[serviceName]
+}
+
+// handleGetStackJobs returns the last run of each job service of a stack:
+// state, exit code and start and finish times. Args: stack name.
+func (app *App) handleGetStackJobs(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	stackName := argString(parseArgs(msg), 0)
+	if err := stack.ValidateStackName(stackName); err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	services := app.stackJobServices(stackName)
+	jobs := make(map[string]*stackJob, len(services))
+	for svc := range services {
+		jobs[svc] = &stackJob{Service: svc}
+	}
+	if len(jobs) > 0 {
+		containers, err := app.Docker.ContainerList(ctx, true, stackName)
+		if err != nil {
+			slog.Warn("jobs: list containers", "stack", stackName, "err", err)
+			if msg.ID != nil {
+				ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+			}
+			return
+		}
+		for _, ctr := range containers {
+			job := jobs[ctr.Service]
+			if job == nil {
+				continue
+			}
+			job.Container, job.State = ctr.Name, ctr.State
+			raw, err := app.Docker.ContainerInspect(ctx, ctr.ID)
+			if err != nil {
+				slog.Warn("jobs: inspect", "container", ctr.Name, "err", err)
+				continue
+			}
+			var inspect struct {
+				State struct {
+					ExitCode   int    `json:"ExitCode"`
+					StartedAt  string `json:"StartedAt"`
+					FinishedAt string `json:"FinishedAt"`
+				} `json:"State"`
+			}
+			if err := json.Unmarshal(raw, &inspect); err == nil {
+				job.ExitCode = inspect.State.ExitCode
+				job.StartedAt = dockerTime(inspect.State.StartedAt)
+				job.FinishedAt = dockerTime(inspect.State.FinishedAt)
+			}
+		}
+	}
+
+	list := make([]stackJob, 0, len(jobs))
+	for _, job := range jobs {
+		list = append(list, *job)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Service < list[j].Service })
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK   bool       `json:"ok"`
+			Jobs []stackJob `json:"jobs"`
+		}{true, list})
+	}
+}
+
+// dockerTime drops Docker's zero time, which it reports for containers
+// that never started or finished.
+func dockerTime(t string) string {
+	if strings.HasPrefix(t, "0001-") {
+		return ""
+	}
+	return t
+}
+
+// handleRunJobService runs a job service on demand, creating its container
+// if needed. Args: (stackName, serviceName).
+func (app *App) handleRunJobService(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	stackName, serviceName, ok := serviceActionArgs(c, msg)
+	if !ok {
+		return
+	}
+	if app.rejectArchived(c, msg, stackName) {
+		return
+	}
+	if !app.isJobService(stackName, serviceName) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Service " + serviceName + " is not a job (restart: \"no\" or label dockge.job)"})
+		}
+		return
+	}
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true})
+	}
+
+	go app.runServiceAction(stackName, serviceName, "job", "up", "-d", "--no-deps", serviceName)
+}
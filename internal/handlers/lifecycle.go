@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/terminal"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// Follower kinds tracked by the FollowerRegistry.
+const (
+	followerContainerLog       = "containerLog"
+	followerContainerLogByName = "containerLogByName"
+	followerCombinedLog        = "combinedLog"
+	followerStackLogs          = "stackLogs"
+)
+
+const (
+	// followerReapInterval is how often the reaper scans for orphaned followers.
+	followerReapInterval = 60 * time.Second
+
+	// followerReapGrace is the minimum follower age before it can be reaped.
+	// Covers the window between starting a follower and attaching its first
+	// writer, and brief reconnects.
+	followerReapGrace = 2 * time.Minute
+)
+
+// FollowerRegistry tracks running log-follow goroutines so leaks are visible
+// and orphaned followers (whose WebSocket clients are gone) can be reaped.
+type FollowerRegistry struct {
+	mu      sync.Mutex
+	entries map[uint64]*followerEntry
+	nextID  uint64
+	started uint64
+	reaped  uint64
+}
+
+type followerEntry struct {
+	kind     string
+	termName string
+	term     *terminal.Terminal
+	cancel   context.CancelFunc
+	created  time.Time
+}
+
+// FollowerInfo describes a running follower for debug output.
+type FollowerInfo struct {
+	ID         uint64  `json:"id"`
+	Kind       string  `json:"kind"`
+	Terminal   string  `json:"terminal"`
+	Writers    int     `json:"writers"`
+	AgeSeconds float64 `json:"ageSeconds"`
+}
+
+// NewFollowerRegistry creates an empty FollowerRegistry.
+func NewFollowerRegistry() *FollowerRegistry {
+	return &FollowerRegistry{
+		entries: make(map[uint64]*followerEntry),
+	}
+}
+
+// Add registers a follower streaming into term. The returned func must be
+// called when the follower goroutine exits.
+func (r *FollowerRegistry) Add(kind string, term *terminal.Terminal, cancel context.CancelFunc) func() {
+	r.mu.Lock()
+	id := r.nextID
+	r.nextID++
+	r.started++
+	r.entries[id] = &followerEntry{
+		kind:     kind,
+		termName: term.Name,
+		term:     term,
+		cancel:   cancel,
+		created:  time.Now(),
+	}
+	r.mu.Unlock()
+
+	return func() {
+		r.mu.Lock()
+		delete(r.entries, id)
+		r.mu.Unlock()
+	}
+}
+
+// Count returns the number of running followers.
+func (r *FollowerRegistry) Count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.entries)
+}
+
+// Snapshot returns info for every running follower, oldest first.
+func (r *FollowerRegistry) Snapshot() []FollowerInfo {
+	now := time.Now()
+	r.mu.Lock()
+	result := make([]FollowerInfo, 0, len(r.entries))
+	for id, e := range r.entries {
+		result = append(result, FollowerInfo{
+			ID:         id,
+			Kind:       e.kind,
+			Terminal:   e.termName,
+			Writers:    e.term.WriterCount(),
+			AgeSeconds: now.Sub(e.created).Seconds(),
+		})
+	}
+	r.mu.Unlock()
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+// reap cancels followers older than grace whose terminal has no writers
+// belonging to a live connection. Writers for dead connections are dropped
+// first, so a follower kept alive only by a vanished client is reaped too.
+// Returns the number of followers cancelled.
+func (r *FollowerRegistry) reap(terms *terminal.Manager, liveConns map[string]bool, grace time.Duration) int {
+	now := time.Now()
+	r.mu.Lock()
+	var victims []*followerEntry
+	for _, e := range r.entries {
+		if now.Sub(e.created) >= grace {
+			victims = append(victims, e)
+		}
+	}
+	r.mu.Unlock()
+
+	reaped := 0
+	for _, e := range victims {
+		for _, key := range e.term.WriterKeys() {
+			connID, _, _ := strings.Cut(key, ":")
+			if !liveConns[connID] {
+				e.term.RemoveWriter(key)
+			}
+		}
+		if e.term.WriterCount() > 0 {
+			continue
+		}
+		slog.Info("reaping orphaned log follower", "kind", e.kind, "terminal", e.termName,
+			"age", now.Sub(e.created).Round(time.Second))
+		e.cancel()
+		// Only remove the terminal if it hasn't been recreated since.
+		if terms.Get(e.termName) == e.term {
+			terms.Remove(e.termName)
+		}
+		reaped++
+	}
+
+	if reaped > 0 {
+		r.mu.Lock()
+		r.reaped += uint64(reaped)
+		r.mu.Unlock()
+	}
+	return reaped
+}
+
+// trackFollower registers a follower and runs fn in a new goroutine,
+// unregistering it when fn returns.
+func (app *App) trackFollower(kind string, term *terminal.Terminal, cancel context.CancelFunc, fn func()) {
+	done := app.Followers.Add(kind, term, cancel)
+	go func() {
+		defer done()
+		fn()
+	}()
+}
+
+// StartFollowerReaper runs a background goroutine that periodically cancels
+// log followers whose WebSocket clients have all disconnected.
+func (app *App) StartFollowerReaper(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(followerReapInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				live := make(map[string]bool)
+				app.WS.ForEachConn(func(c *ws.Conn) {
+					live[c.ID()] = true
+				})
+				if n := app.Followers.reap(app.Terms, live, followerReapGrace); n > 0 {
+					slog.Debug("follower reaper", "reaped", n)
+				}
+			}
+		}
+	}()
+}
+
+// LifecycleCounts summarizes long-lived resources for metrics.
+type LifecycleCounts struct {
+	Goroutines         int    `json:"goroutines"`
+	Terminals          int    `json:"terminals"`
+	Followers          int    `json:"followers"`
+	FollowersStarted   uint64 `json:"followersStarted"`
+	FollowersReaped    uint64 `json:"followersReaped"`
+	EventSubscriptions int    `json:"eventSubscriptions"`
+	StatsSubscriptions int    `json:"statsSubscriptions"`
+	TopSubscriptions   int    `json:"topSubscriptions"`
+	Connections        int    `json:"connections"`
+}
+
+// LifecycleSnapshot is the full debug view of tracked resources.
+type LifecycleSnapshot struct {
+	Counts             LifecycleCounts         `json:"counts"`
+	Followers          []FollowerInfo          `json:"followers"`
+	EventSubscriptions []EventSubInfo          `json:"eventSubscriptions"`
+	Terminals          []terminal.TerminalInfo `json:"terminals"`
+}
+
+// LifecycleCounts returns resource counts without per-entry detail.
+func (app *App) LifecycleCounts() LifecycleCounts {
+	app.Followers.mu.Lock()
+	counts := LifecycleCounts{
+		Followers:        len(app.Followers.entries),
+		FollowersStarted: app.Followers.started,
+		FollowersReaped:  app.Followers.reaped,
+	}
+	app.Followers.mu.Unlock()
+
+	counts.Goroutines = runtime.NumGoroutine()
+	counts.Terminals = app.Terms.Count()
+	counts.EventSubscriptions = app.EventBus.Count()
+	counts.Connections = app.WS.ConnectionCount()
+
+	app.statsSubsMu.Lock()
+	counts.StatsSubscriptions = len(app.statsSubs)
+	app.statsSubsMu.Unlock()
+	app.topSubsMu.Lock()
+	counts.TopSubscriptions = len(app.topSubs)
+	app.topSubsMu.Unlock()
+
+	return counts
+}
+
+// LifecycleSnapshot returns counts plus per-entry detail for followers,
+// event subscriptions, and terminals.
+func (app *App) LifecycleSnapshot() LifecycleSnapshot {
+	return LifecycleSnapshot{
+		Counts:             app.LifecycleCounts(),
+		Followers:          app.Followers.Snapshot(),
+		EventSubscriptions: app.EventBus.Snapshot(),
+		Terminals:          app.Terms.Snapshot(),
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cfilipov/dockge/internal/terminal"
+)
+
+func TestFollowerRegistryReapsOrphans(t *testing.T) {
+	t.Parallel()
+	terms := terminal.NewManager()
+	r := NewFollowerRegistry()
+
+	orphan := terms.Create("container-log-gone", terminal.TypePipe)
+	orphan.AddWriter("dead:s1", func(string) {})
+	orphanCtx, orphanCancel := context.WithCancel(context.Background())
+	defer r.Add(followerContainerLog, orphan, orphanCancel)()
+
+	live := terms.Create("container-log-live", terminal.TypePipe)
+	live.AddWriter("alive:s1", func(string) {})
+	liveCtx, liveCancel := context.WithCancel(context.Background())
+	defer liveCancel()
+	defer r.Add(followerContainerLog, live, liveCancel)()
+
+	if n := r.reap(terms, map[string]bool{"alive": true}, 0); n != 1 {
+		t.Fatalf("expected 1 reaped follower, got %d", n)
+	}
+	if orphanCtx.Err() == nil {
+		t.Error("orphaned follower was not cancelled")
+	}
+	if liveCtx.Err() != nil {
+		t.Error("live follower was cancelled")
+	}
+	if terms.Get("container-log-gone") != nil {
+		t.Error("orphaned terminal was not removed")
+	}
+	if terms.Get("container-log-live") == nil {
+		t.Error("live terminal was removed")
+	}
+}
+
+func TestFollowerRegistryGracePeriod(t *testing.T) {
+	t.Parallel()
+	terms := terminal.NewManager()
+	r := NewFollowerRegistry()
+
+	term := terms.Create("combined-web", terminal.TypePipe)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := r.Add(followerCombinedLog, term, cancel)
+
+	if n := r.reap(terms, nil, followerReapGrace); n != 0 {
+		t.Fatalf("expected young follower to survive, reaped %d", n)
+	}
+	if ctx.Err() != nil {
+		t.Error("young follower was cancelled")
+	}
+
+	done()
+	if r.Count() != 0 {
+		t.Errorf("expected 0 followers after done, got %d", r.Count())
+	}
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"github.com/cfilipov/dockge/internal/compose"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// composeDiagnostic is a compose.Diagnostic with the editor it belongs to.
+type composeDiagnostic struct {
+	File string `json:"file"` // "compose" or "override"
+	compose.Diagnostic
+}
+
+// lintStackFiles validates a stack's compose file and, if not empty, its
+// override file.
+func lintStackFiles(composeYAML, overrideYAML string) []composeDiagnostic {
+	var diags []composeDiagnostic
+	for _, d := range compose.Validate(composeYAML, false) {
+		diags = append(diags, composeDiagnostic{File: "compose", Diagnostic: d})
+	}
+	if overrideYAML != "" {
+		for _, d := range compose.Validate(overrideYAML, true) {
+			diags = append(diags, composeDiagnostic{File: "override", Diagnostic: d})
+		}
+	}
+	return diags
+}
+
+func hasLintErrors(diags []composeDiagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == compose.SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// rejectInvalidCompose acks with the diagnostics and returns true if they
+// contain errors.
+func rejectInvalidCompose(c *ws.Conn, msg *ws.ClientMessage, diags []composeDiagnostic) bool {
+	if !hasLintErrors(diags) {
+		return false
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK          bool                `json:"ok"`
+			Msg         string              `json:"msg"`
+			MsgI18n     bool                `json:"msgi18n"`
+			Diagnostics []composeDiagnostic `json:"diagnostics"`
+		}{OK: false, Msg: "composeInvalid", MsgI18n: true, Diagnostics: diags})
+	}
+	return true
+}
+
+// handleValidateCompose lints one compose file for the editor without
+// saving it. Args: (yaml, isOverride).
+func (app *App) handleValidateCompose(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	args := parseArgs(msg)
+	yaml := argString(args, 0)
+	override := argBool(args, 1)
+
+	diags := []compose.Diagnostic{}
+	if yaml != "" || !override {
+		diags = append(diags, compose.Validate(yaml, override)...)
+	}
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK          bool                 `json:"ok"`
+			Diagnostics []compose.Diagnostic `json:"diagnostics"`
+		}{OK: true, Diagnostics: diags})
+	}
+}
@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/cfilipov/dockge/internal/logcapture"
+	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// logLinkPath prefixes the URL of a captured log download; ServeCapturedLog
+// is mounted at logLinkPath + "{token}".
+const logLinkPath = "/api/logs/"
+
+// RegisterLogCaptureHandlers registers the handlers of per-stack log
+// capture and creates the capturer. It captures once StartLogCapture is
+// called.
+func RegisterLogCaptureHandlers(app *App) {
+	if app.Docker != nil && app.DataDir != "" && app.LogCapture != nil {
+		app.logCapture = logcapture.New(app.Docker, filepath.Join(app.DataDir, "logs"), logcapture.DefaultConfig, app.logCaptureStacks)
+	}
+	app.WS.Handle("getStackLogCapture", app.handleGetStackLogCapture)
+	app.WS.Handle("setStackLogCapture", app.handleSetStackLogCapture)
+	app.WS.Handle("downloadCapturedLog", app.handleDownloadCapturedLog)
+	app.WS.Handle("queryCapturedLogs", app.handleQueryCapturedLogs)
+}
+
+// StartLogCapture follows the logs of the stacks with capture on.
+func (app *App) StartLogCapture(ctx context.Context) {
+	if app.logCapture != nil {
+		app.logCapture.Start(ctx)
+	}
+}
+
+// logCaptureStacks returns the stacks and services with log capture on.
+func (app *App) logCaptureStacks() logcapture.Selection {
+	list, err := app.LogCapture.List()
+	if err != nil {
+		slog.Warn("list log capture", "err", err)
+		return nil
+	}
+	sel := make(logcapture.Selection, len(list))
+	for _, lc := range list {
+		sel[lc.StackName] = lc.Services
+	}
+	return sel
+}
+
+// logCaptureUnavailable acks that log capture is off for this server and
+// returns true if it is.
+func (app *App) logCaptureUnavailable(c *ws.Conn, msg *ws.ClientMessage) bool {
+	if app.logCapture != nil {
+		return false
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Log capture is not available"})
+	}
+	return true
+}
+
+// handleGetStackLogCapture returns whether a stack's logs are captured,
+// how many of its containers are followed and its log files.
+// Args: stack name.
+func (app *App) handleGetStackLogCapture(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 || app.logCaptureUnavailable(c, msg) {
+		return
+	}
+	stackName := argString(parseArgs(msg), 0)
+	if err := stack.ValidateStackName(stackName); err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	lc, err := app.LogCapture.Get(stackName)
+	if err != nil {
+		slog.Error("get log capture", "err", err, "stack", stackName)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+	segments, err := app.logCapture.Segments(stackName)
+	if err != nil {
+		slog.Error("list captured logs", "err", err, "stack", stackName)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK        bool                    `json:"ok"`
+			Capture   *models.StackLogCapture `json:"capture"` // null if off
+			Capturing int                     `json:"capturing"`
+			Segments  []logcapture.Segment    `json:"segments"`
+		}{OK: true, Capture: lc, Capturing: app.logCapture.Capturing(stackName), Segments: segments})
+	}
+}
+
+// handleSetStackLogCapture turns log capture on or off for a stack, for
+// all its services or the ones listed. Files already captured are kept
+// when it's turned off. Admin only.
+// Args: stack name, {enabled, services}.
+func (app *App) handleSetStackLogCapture(c *ws.Conn, msg *ws.ClientMessage) {
+	admin := app.checkAdmin(c, msg)
+	if admin == nil || app.logCaptureUnavailable(c, msg) {
+		return
+	}
+	args := parseArgs(msg)
+	stackName := argString(args, 0)
+	var req struct {
+		Enabled  bool     `json:"enabled"`
+		Services []string `json:"services"`
+	}
+	argObject(args, 1, &req)
+	if err := stack.ValidateStackName(stackName); err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+
+	var err error
+	if req.Enabled {
+		var services []string
+		for _, svc := range req.Services {
+			if svc = strings.TrimSpace(svc); svc != "" && !slices.Contains(services, svc) {
+				services = append(services, svc)
+			}
+		}
+		err = app.LogCapture.Enable(models.StackLogCapture{StackName: stackName, Services: services, EnabledBy: admin.Username})
+	} else {
+		err = app.LogCapture.Disable(stackName)
+	}
+	if err != nil {
+		slog.Error("set log capture", "err", err, "stack", stackName)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+	app.logCapture.Sync()
+	slog.Info("stack log capture changed", "stack", stackName, "enabled", req.Enabled, "services", req.Services, "by", admin.Username)
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Saved"})
+	}
+}
+
+// handleDownloadCapturedLog returns a one-time link to download a captured
+// log file, served by ServeCapturedLog. Args: stack name, file name.
+func (app *App) handleDownloadCapturedLog(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 || app.logCaptureUnavailable(c, msg) {
+		return
+	}
+	args := parseArgs(msg)
+	stackName := argString(args, 0)
+	name := argString(args, 1)
+	if err := stack.ValidateStackName(stackName); err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	path, err := app.logCapture.Path(stackName, name)
+	if err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+
+	var username string
+	if user := app.currentUser(c); user != nil {
+		username = user.Username
+	}
+	token := app.logLinks.issue(&backupLink{file: path, user: username})
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK  bool   `json:"ok"`
+			URL string `json:"url"`
+		}{OK: true, URL: logLinkPath + token})
+	}
+}
+
+// capturedLogsQuery is the filter of a captured log query. Since and until
+// take RFC3339 times or Unix seconds.
+type capturedLogsQuery struct {
+	Container string `json:"container"` // "" for all the stack's containers
+	Since     string `json:"since"`
+	Until     string `json:"until"`
+	Limit     int    `json:"limit"` // max lines, newest kept
+}
+
+// handleQueryCapturedLogs returns the captured log lines of a stack in a
+// time range, from the files on disk, so lines docker already dropped can
+// still be read. Args: stack name, capturedLogsQuery.
+func (app *App) handleQueryCapturedLogs(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 || app.logCaptureUnavailable(c, msg) {
+		return
+	}
+	args := parseArgs(msg)
+	stackName := argString(args, 0)
+	var q capturedLogsQuery
+	argObject(args, 1, &q)
+
+	fail := func(errMsg string) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: errMsg})
+		}
+	}
+	if err := stack.ValidateStackName(stackName); err != nil {
+		fail(err.Error())
+		return
+	}
+	since, err := parseLogTime(q.Since)
+	if err != nil {
+		fail("Invalid since time: " + q.Since)
+		return
+	}
+	until, err := parseLogTime(q.Until)
+	if err != nil {
+		fail("Invalid until time: " + q.Until)
+		return
+	}
+	if q.Limit <= 0 {
+		q.Limit = defaultLogSearchLimit
+	}
+	q.Limit = min(q.Limit, maxLogSearchLimit)
+
+	go func() {
+		lines, truncated, err := app.logCapture.Query(stackName, logcapture.Query{
+			Container: q.Container,
+			Since:     since,
+			Until:     until,
+			Limit:     q.Limit,
+		})
+		if err != nil {
+			slog.Warn("query captured logs", "err", err, "stack", stackName)
+			fail(err.Error())
+			return
+		}
+		if lines == nil {
+			lines = []logcapture.Line{}
+		}
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, struct {
+				OK        bool              `json:"ok"`
+				Lines     []logcapture.Line `json:"lines"`
+				Truncated bool              `json:"truncated"`
+			}{OK: true, Lines: lines, Truncated: truncated})
+		}
+	}()
+}
+
+// ServeCapturedLog serves the links issued by downloadCapturedLog. Each
+// link works once; the token is the credential, as the WS handler checked
+// the user.
+func (app *App) ServeCapturedLog(w http.ResponseWriter, r *http.Request) {
+	l := app.logLinks.take(r.PathValue("token"))
+	if l == nil {
+		http.Error(w, "link not found or expired", http.StatusNotFound)
+		return
+	}
+	f, err := os.Open(l.file)
+	if err != nil {
+		http.Error(w, "log file not found", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	name := filepath.Base(l.file)
+	if strings.HasSuffix(name, ".gz") {
+		w.Header().Set("Content-Type", "application/gzip")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(filepath.Dir(l.file))+"-"+name))
+	http.ServeContent(w, r, name, info.ModTime(), f)
+}
@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/docker"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// Bounds of a log search, so one request can't make the server scan or
+// send an unbounded amount of log.
+const (
+	logSearchTimeout      = 30 * time.Second
+	defaultLogSearchLimit = 200
+	maxLogSearchLimit     = 1000
+	maxLogSearchContext   = 20
+	maxLogSearchQuery     = 512
+)
+
+// Normalized log levels, most to least severe.
+const (
+	logLevelFatal = "fatal"
+	logLevelError = "error"
+	logLevelWarn  = "warn"
+	logLevelInfo  = "info"
+	logLevelDebug = "debug"
+	logLevelTrace = "trace"
+)
+
+var (
+	// logLevelKeyRe finds structured levels: level=warn, "level":"warn",
+	// severity: ERROR.
+	logLevelKeyRe = regexp.MustCompile(`(?i)\b(?:level|lvl|severity|loglevel)"?\s*[=:]\s*"?([a-z]+)`)
+	// logLevelWordRe finds bare levels, upper case ("ERROR ...") or marked
+	// as one ("[warn]", "<info>", "debug:"), so that the word "error" in a
+	// message isn't taken for a level.
+	logLevelWordRe = regexp.MustCompile(`\b(TRACE|DEBUG|INFO|NOTICE|WARN|WARNING|ERROR|ERR|FATAL|PANIC|CRIT|CRITICAL)\b|[\[<](?i:(trace|debug|info|notice|warn|warning|error|err|fatal|panic|crit|critical))[\]>]|^(?i:(trace|debug|info|notice|warn|warning|error|err|fatal|panic|crit|critical)):`)
+)
+
+// normalizeLogLevel maps the spellings of a level to one of the logLevel
+// constants, or "" for a word that isn't a level.
+func normalizeLogLevel(word string) string {
+	switch strings.ToLower(word) {
+	case "fatal", "panic", "crit", "critical", "emerg", "alert":
+		return logLevelFatal
+	case "error", "err", "eror":
+		return logLevelError
+	case "warn", "warning":
+		return logLevelWarn
+	case "info", "notice", "information":
+		return logLevelInfo
+	case "debug", "dbg":
+		return logLevelDebug
+	case "trace":
+		return logLevelTrace
+	}
+	return ""
+}
+
+// detectLogLevel guesses the level of a log line, or "" if it has none.
+// Structured fields win over level words.
+func detectLogLevel(line string) string {
+	if m := logLevelKeyRe.FindStringSubmatch(line); m != nil {
+		if lvl := normalizeLogLevel(m[1]); lvl != "" {
+			return lvl
+		}
+	}
+	if m := logLevelWordRe.FindStringSubmatch(line); m != nil {
+		for _, w := range m[1:] {
+			if w != "" {
+				return normalizeLogLevel(w)
+			}
+		}
+	}
+	return ""
+}
+
+// logSearchQuery is the filter of a log search. Since and until take
+// RFC3339 times or Unix seconds.
+type logSearchQuery struct {
+	Since         string   `json:"since"`
+	Until         string   `json:"until"`
+	Tail          int      `json:"tail"`  // lines from the end to search, 0 for all
+	Query         string   `json:"query"` // substring, or a regexp with regex set
+	Regex         bool     `json:"regex"`
+	CaseSensitive bool     `json:"caseSensitive"`
+	Levels        []string `json:"levels"`  // keep only lines of these levels
+	Context       int      `json:"context"` // lines before and after each match
+	Limit         int      `json:"limit"`   // max matches, newest kept
+	Stream        string   `json:"stream"`  // docker.LogStdout or docker.LogStderr to search that stream only
+}
+
+// logLine is a line of a search result.
+type logLine struct {
+	N      int    `json:"n"` // line number in the searched range, from 1
+	Time   string `json:"time,omitempty"`
+	Text   string `json:"text"`
+	Level  string `json:"level,omitempty"`
+	Stream string `json:"stream"` // docker.LogStdout or docker.LogStderr
+}
+
+// logMatch is a matching line with the lines around it.
+type logMatch struct {
+	logLine
+	Before []logLine `json:"before"`
+	After  []logLine `json:"after"`
+}
+
+// logMatcher applies a search's text and level filters to lines.
+type logMatcher struct {
+	re     *regexp.Regexp // nil for a substring search
+	substr string
+	fold   bool
+	levels map[string]bool // nil for any level
+}
+
+func newLogMatcher(q logSearchQuery) (*logMatcher, error) {
+	m := &logMatcher{substr: q.Query, fold: !q.CaseSensitive}
+	if q.Regex && q.Query != "" {
+		expr := q.Query
+		if m.fold {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		m.re = re
+	}
+	if m.fold {
+		m.substr = strings.ToLower(m.substr)
+	}
+	for _, l := range q.Levels {
+		if lvl := normalizeLogLevel(l); lvl != "" {
+			if m.levels == nil {
+				m.levels = map[string]bool{}
+			}
+			m.levels[lvl] = true
+		}
+	}
+	return m, nil
+}
+
+func (m *logMatcher) match(l logLine) bool {
+	if m.levels != nil && !m.levels[l.Level] {
+		return false
+	}
+	switch {
+	case m.re != nil:
+		return m.re.MatchString(l.Text)
+	case m.fold:
+		return strings.Contains(strings.ToLower(l.Text), m.substr)
+	}
+	return strings.Contains(l.Text, m.substr)
+}
+
+// searchLogLines scans timestamped log lines and returns the last limit
+// matches with up to around lines on each side, the number of lines
+// scanned and whether older matches were dropped.
+func searchLogLines(sc *docker.LogScanner, m *logMatcher, around, limit int) (matches []logMatch, scanned int, truncated bool) {
+	var recent []logLine // the last around lines, for the next match's Before
+	var open []int       // indexes of matches still collecting After
+	for sc.Scan() {
+		scanned++
+		line := sc.Line()
+		ts, text := splitTimestamp(string(line.Text))
+		l := logLine{N: scanned, Time: ts, Text: strings.TrimRight(text, "\r"), Level: detectLogLevel(text), Stream: line.Stream}
+
+		still := open[:0]
+		for _, i := range open {
+			matches[i].After = append(matches[i].After, l)
+			if len(matches[i].After) < around {
+				still = append(still, i)
+			}
+		}
+		open = still
+
+		if m.match(l) {
+			matches = append(matches, logMatch{logLine: l, Before: append([]logLine{}, recent...), After: []logLine{}})
+			if around > 0 {
+				open = append(open, len(matches)-1)
+			}
+			if len(matches) > limit {
+				matches = matches[1:]
+				truncated = true
+				still := open[:0]
+				for _, i := range open {
+					if i > 0 {
+						still = append(still, i-1)
+					}
+				}
+				open = still
+			}
+		}
+
+		if around > 0 {
+			if len(recent) == around {
+				recent = recent[1:]
+			}
+			recent = append(recent, l)
+		}
+	}
+	return matches, scanned, truncated
+}
+
+// parseLogTime parses an RFC3339 time or Unix seconds; "" is the zero time.
+func parseLogTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Unix(0, int64(secs*float64(time.Second))), nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// handleSearchContainerLogs searches a container's log on the server and
+// returns the matching lines with context, instead of streaming the whole
+// log to the browser. Args: container name or ID, logSearchQuery.
+func (app *App) handleSearchContainerLogs(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	args := parseArgs(msg)
+	container := argString(args, 0)
+	var q logSearchQuery
+	argObject(args, 1, &q)
+
+	fail := func(errMsg string) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: errMsg})
+		}
+	}
+	if container == "" {
+		fail("Container name required")
+		return
+	}
+	if len(q.Query) > maxLogSearchQuery {
+		fail("Search query is too long")
+		return
+	}
+	since, err := parseLogTime(q.Since)
+	if err != nil {
+		fail("Invalid since time: " + q.Since)
+		return
+	}
+	until, err := parseLogTime(q.Until)
+	if err != nil {
+		fail("Invalid until time: " + q.Until)
+		return
+	}
+	if q.Stream != "" && q.Stream != docker.LogStdout && q.Stream != docker.LogStderr {
+		fail("Invalid stream: " + q.Stream)
+		return
+	}
+	m, err := newLogMatcher(q)
+	if err != nil {
+		fail("Invalid regular expression: " + err.Error())
+		return
+	}
+	q.Context = min(max(q.Context, 0), maxLogSearchContext)
+	if q.Limit <= 0 {
+		q.Limit = defaultLogSearchLimit
+	}
+	q.Limit = min(q.Limit, maxLogSearchLimit)
+	tail := "all"
+	if q.Tail > 0 {
+		tail = strconv.Itoa(q.Tail)
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), logSearchTimeout)
+		defer cancel()
+		stream, tty, err := app.Docker.ContainerLogsWithOptions(ctx, container, docker.LogOptions{
+			Tail:       tail,
+			Since:      since,
+			Until:      until,
+			Timestamps: true,
+			Stream:     q.Stream,
+			Framed:     true,
+		})
+		if err != nil {
+			fail(err.Error())
+			return
+		}
+		defer stream.Close()
+
+		sc := docker.NewLogScanner(stream, tty)
+		sc.Buffer(1024 * 1024)
+		matches, scanned, truncated := searchLogLines(sc, m, q.Context, q.Limit)
+		if err := sc.Err(); err != nil {
+			fail("Reading logs: " + err.Error())
+			return
+		}
+		if matches == nil {
+			matches = []logMatch{}
+		}
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, struct {
+				OK        bool       `json:"ok"`
+				Matches   []logMatch `json:"matches"`
+				Scanned   int        `json:"scanned"`
+				Truncated bool       `json:"truncated"`
+			}{OK: true, Matches: matches, Scanned: scanned, Truncated: truncated})
+		}
+	}()
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/cfilipov/dockge/internal/docker"
+)
+
+func TestDetectLogLevel(t *testing.T) {
+	t.Parallel()
+	cases := map[string]string{
+		`time=2024-01-01 level=warn msg="disk almost full"`: logLevelWarn,
+		`{"level":"ERROR","msg":"boom"}`:                    logLevelError,
+		`2024/01/01 12:00:00 [notice] 1#1: start worker`:    logLevelInfo,
+		`1:M 01 Jan 2024 FATAL could not bind`:              logLevelFatal,
+		`debug: cache miss`:                                 logLevelDebug,
+		`GET /favicon.ico 404 error page served`:            "",
+		`plain line`:                                        "",
+	}
+	for line, want := range cases {
+		if got := detectLogLevel(line); got != want {
+			t.Errorf("detectLogLevel(%q) = %q, want %q", line, got, want)
+		}
+	}
+}
+
+func TestSearchLogLines(t *testing.T) {
+	t.Parallel()
+	log := strings.Join([]string{
+		"2024-01-01T00:00:01Z start",
+		"2024-01-01T00:00:02Z level=error msg=first",
+		"2024-01-01T00:00:03Z ok",
+		"2024-01-01T00:00:04Z ok",
+		"2024-01-01T00:00:05Z level=error msg=second",
+		"2024-01-01T00:00:06Z done",
+	}, "\n")
+	search := func(q logSearchQuery, around, limit int) ([]logMatch, int, bool) {
+		m, err := newLogMatcher(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return searchLogLines(docker.NewLogScanner(strings.NewReader(log), true), m, around, limit)
+	}
+
+	matches, scanned, truncated := search(logSearchQuery{Query: "MSG="}, 1, 10)
+	if scanned != 6 || truncated || len(matches) != 2 {
+		t.Fatalf("scanned %d, truncated %v, %d matches", scanned, truncated, len(matches))
+	}
+	first := matches[0]
+	if first.N != 2 || first.Time != "2024-01-01T00:00:02Z" || first.Level != logLevelError {
+		t.Errorf("first match = %+v", first.logLine)
+	}
+	if len(first.Before) != 1 || first.Before[0].Text != "start" || len(first.After) != 1 || first.After[0].Text != "ok" {
+		t.Errorf("first context = %+v / %+v", first.Before, first.After)
+	}
+
+	// The newest matches are kept, with their context still collected
+	matches, _, truncated = search(logSearchQuery{Levels: []string{"ERR"}}, 1, 1)
+	if !truncated || len(matches) != 1 || matches[0].Text != "level=error msg=second" || len(matches[0].After) != 1 {
+		t.Errorf("limited matches = %+v, truncated %v", matches, truncated)
+	}
+
+	if matches, _, _ = search(logSearchQuery{Query: `^o.$`, Regex: true, CaseSensitive: true}, 0, 10); len(matches) != 2 || matches[1].N != 4 {
+		t.Errorf("regex matches = %+v", matches)
+	}
+	if _, err := newLogMatcher(logSearchQuery{Query: "(", Regex: true}); err == nil {
+		t.Error("expected an invalid regexp error")
+	}
+}
+
+func TestSearchLogLinesStreams(t *testing.T) {
+	t.Parallel()
+	var log bytes.Buffer
+	for _, f := range []struct {
+		stream byte
+		text   string
+	}{
+		{1, "2024-01-01T00:00:01Z listening\n"},
+		{2, "2024-01-01T00:00:02Z level=warn msg=slow\n"},
+		{1, "2024-01-01T00:00:03Z request done\n"},
+	} {
+		header := make([]byte, 8)
+		header[0] = f.stream
+		binary.BigEndian.PutUint32(header[4:], uint32(len(f.text)))
+		log.Write(header)
+		log.WriteString(f.text)
+	}
+	m, err := newLogMatcher(logSearchQuery{Query: "slow"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches, scanned, _ := searchLogLines(docker.NewLogScanner(&log, false), m, 1, 10)
+	if scanned != 3 || len(matches) != 1 {
+		t.Fatalf("scanned %d, %d matches", scanned, len(matches))
+	}
+	got := matches[0]
+	if got.Stream != docker.LogStderr || got.Before[0].Stream != docker.LogStdout || got.After[0].Stream != docker.LogStdout {
+		t.Errorf("streams = %q, before %q, after %q", got.Stream, got.Before[0].Stream, got.After[0].Stream)
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/metrics"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// RegisterMetricsHandlers registers the usage history handler and creates
+// the collector. It samples once StartMetricsCollector is called.
+func RegisterMetricsHandlers(app *App) {
+	if app.Docker != nil {
+		app.metrics = metrics.New(app.Docker, metrics.DefaultInterval, metrics.DefaultRetention)
+	}
+	app.WS.Handle("requestStackMetrics", app.handleRequestStackMetrics)
+}
+
+// StartMetricsCollector samples container usage every minute.
+func (app *App) StartMetricsCollector(ctx context.Context) {
+	if app.metrics != nil {
+		app.metrics.Start(ctx)
+	}
+}
+
+// handleRequestStackMetrics returns the CPU and memory history of a stack's
+// services. Args: stackName, {since} (Unix seconds, default: all history).
+func (app *App) handleRequestStackMetrics(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	args := parseArgs(msg)
+	stackName := argString(args, 0)
+	var opts struct {
+		Since int64 `json:"since"`
+	}
+	argObject(args, 1, &opts)
+
+	if err := stack.ValidateStackName(stackName); err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	if app.metrics == nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Metrics are not available"})
+		}
+		return
+	}
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK       bool                        `json:"ok"`
+			Interval int64                       `json:"interval"` // seconds between samples
+			Services map[string][]metrics.Sample `json:"services"`
+		}{
+			OK:       true,
+			Interval: int64(app.metrics.Interval() / time.Second),
+			Services: app.metrics.Stack(stackName, time.Unix(opts.Since, 0)),
+		})
+	}
+}
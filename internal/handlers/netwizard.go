@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"net"
+	"os"
+	"sort"
+
+	"github.com/cfilipov/dockge/internal/compose"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// hostInterface describes a host network interface for the macvlan wizard.
+type hostInterface struct {
+	Name      string   `json:"name"`
+	MTU       int      `json:"mtu"`
+	Up        bool     `json:"up"`
+	MAC       string   `json:"mac,omitempty"`
+	Addresses []string `json:"addresses"` // CIDR notation, e.g. 192.168.1.40/24
+}
+
+// handleGetHostInterfaces lists non-loopback interfaces with their addresses
+// and MTU. Containerized reports whether Dockge runs in a container, in which
+// case these are the container's interfaces unless it uses host networking.
+func (app *App) handleGetHostInterfaces(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+
+	list := make([]hostInterface, 0, len(ifaces))
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		hi := hostInterface{
+			Name:      iface.Name,
+			MTU:       iface.MTU,
+			Up:        iface.Flags&net.FlagUp != 0,
+			MAC:       iface.HardwareAddr.String(),
+			Addresses: []string{},
+		}
+		if addrs, err := iface.Addrs(); err == nil {
+			for _, a := range addrs {
+				hi.Addresses = append(hi.Addresses, a.String())
+			}
+		}
+		list = append(list, hi)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	_, statErr := os.Stat("/.dockerenv")
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK            bool            `json:"ok"`
+			Interfaces    []hostInterface `json:"interfaces"`
+			Containerized bool            `json:"containerized"`
+		}{
+			OK:            true,
+			Interfaces:    list,
+			Containerized: statErr == nil,
+		})
+	}
+}
+
+// handleGenerateMacvlanNetwork renders a macvlan/ipvlan networks: snippet
+// from the wizard's answers. If no subnet is given, it is taken from the
+// parent interface's first IPv4 address, which also becomes the host address
+// kept out of the generated ip_range.
+func (app *App) handleGenerateMacvlanNetwork(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	args := parseArgs(msg)
+	var opts compose.MacvlanOptions
+	if !argObject(args, 0, &opts) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Invalid arguments"})
+		}
+		return
+	}
+
+	if opts.Subnet == "" && opts.Parent != "" {
+		if iface, err := net.InterfaceByName(opts.Parent); err == nil {
+			if addrs, err := iface.Addrs(); err == nil {
+				for _, a := range addrs {
+					ipnet, ok := a.(*net.IPNet)
+					if !ok || ipnet.IP.To4() == nil {
+						continue
+					}
+					opts.Subnet = ipnet.String()
+					if opts.HostAddr == "" {
+						opts.HostAddr = ipnet.IP.String()
+					}
+					break
+				}
+			}
+		}
+	}
+
+	result, err := compose.GenerateMacvlan(opts)
+	if err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK      bool             `json:"ok"`
+			Network *compose.Macvlan `json:"network"`
+		}{
+			OK:      true,
+			Network: result,
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"regexp"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/docker"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// networkTimeout bounds a network change; the daemon may have to set up
+// bridges or iptables rules.
+const networkTimeout = 30 * time.Second
+
+// networkNameRe is the daemon's rule for network names.
+var networkNameRe = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// networkDrivers are the drivers a network can be created with.
+var networkDrivers = map[string]bool{"": true, "bridge": true, "overlay": true, "macvlan": true, "ipvlan": true}
+
+// RegisterNetworkHandlers registers the handlers that create and remove
+// networks and connect containers to them. Listing and inspecting
+// networks are docker handlers.
+func RegisterNetworkHandlers(app *App) {
+	app.WS.Handle("createNetwork", app.handleCreateNetwork)
+	app.WS.Handle("removeNetwork", app.handleRemoveNetwork)
+	app.WS.Handle("connectNetwork", app.handleConnectNetwork)
+	app.WS.Handle("disconnectNetwork", app.handleDisconnectNetwork)
+}
+
+// createNetworkOptions are the arguments of createNetwork.
+type createNetworkOptions struct {
+	Name       string `json:"name"`
+	Driver     string `json:"driver"`
+	Subnet     string `json:"subnet"`
+	Gateway    string `json:"gateway"`
+	Internal   bool   `json:"internal"`
+	Attachable bool   `json:"attachable"`
+}
+
+// validate checks the options and returns them as client options.
+func (o createNetworkOptions) validate() (docker.NetworkCreateOptions, error) {
+	opts := docker.NetworkCreateOptions{
+		Name:       o.Name,
+		Driver:     o.Driver,
+		Internal:   o.Internal,
+		Attachable: o.Attachable,
+	}
+	if !networkNameRe.MatchString(o.Name) {
+		return opts, fmt.Errorf("invalid network name %q", o.Name)
+	}
+	if !networkDrivers[o.Driver] {
+		return opts, fmt.Errorf("unsupported network driver %q", o.Driver)
+	}
+	if o.Subnet == "" {
+		if o.Gateway != "" {
+			return opts, errors.New("a gateway needs a subnet")
+		}
+		return opts, nil
+	}
+	prefix, err := netip.ParsePrefix(o.Subnet)
+	if err != nil {
+		return opts, fmt.Errorf("invalid subnet %q", o.Subnet)
+	}
+	opts.Subnet = prefix.Masked().String()
+	if o.Gateway != "" {
+		gw, err := netip.ParseAddr(o.Gateway)
+		if err != nil || !prefix.Contains(gw) {
+			return opts, fmt.Errorf("gateway %q is not in subnet %s", o.Gateway, opts.Subnet)
+		}
+		opts.Gateway = gw.String()
+	}
+	return opts, nil
+}
+
+// handleCreateNetwork creates a network. Admin only.
+// Args: {name, driver, subnet, gateway, internal, attachable}.
+func (app *App) handleCreateNetwork(c *ws.Conn, msg *ws.ClientMessage) {
+	admin := app.checkAdmin(c, msg)
+	if admin == nil {
+		return
+	}
+	var data createNetworkOptions
+	if !argObject(parseArgs(msg), 0, &data) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Invalid arguments"})
+		}
+		return
+	}
+	opts, err := data.validate()
+	if err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), networkTimeout)
+	defer cancel()
+	id, err := app.Docker.NetworkCreate(ctx, opts)
+	if err != nil {
+		slog.Warn("createNetwork", "err", err, "network", opts.Name)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	slog.Info("network created", "network", opts.Name, "driver", opts.Driver, "by", admin.Username)
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK  bool   `json:"ok"`
+			Msg string `json:"msg"`
+			ID  string `json:"id"`
+		}{OK: true, Msg: "Created", ID: id})
+	}
+}
+
+// handleRemoveNetwork removes a network no container is connected to.
+// Admin only. Args: network name or ID.
+func (app *App) handleRemoveNetwork(c *ws.Conn, msg *ws.ClientMessage) {
+	admin := app.checkAdmin(c, msg)
+	if admin == nil {
+		return
+	}
+	networkName := argString(parseArgs(msg), 0)
+	if networkName == "" {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Network name required"})
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), networkTimeout)
+	defer cancel()
+	if err := app.Docker.NetworkRemove(ctx, networkName); err != nil {
+		slog.Warn("removeNetwork", "err", err, "network", networkName)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	slog.Info("network removed", "network", networkName, "by", admin.Username)
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Deleted"})
+	}
+}
+
+// handleConnectNetwork connects a container to a network. Admin only.
+// Args: network name or ID, container name or ID.
+func (app *App) handleConnectNetwork(c *ws.Conn, msg *ws.ClientMessage) {
+	app.changeNetworkEndpoint(c, msg, true)
+}
+
+// handleDisconnectNetwork disconnects a container from a network. Admin
+// only. Args: network name or ID, container name or ID, {force}.
+func (app *App) handleDisconnectNetwork(c *ws.Conn, msg *ws.ClientMessage) {
+	app.changeNetworkEndpoint(c, msg, false)
+}
+
+// changeNetworkEndpoint connects or disconnects the container of a
+// connectNetwork or disconnectNetwork message.
+func (app *App) changeNetworkEndpoint(c *ws.Conn, msg *ws.ClientMessage, connect bool) {
+	admin := app.checkAdmin(c, msg)
+	if admin == nil {
+		return
+	}
+	args := parseArgs(msg)
+	networkName := argString(args, 0)
+	containerName := argString(args, 1)
+	if networkName == "" || containerName == "" {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Network and container required"})
+		}
+		return
+	}
+	var opts struct {
+		Force bool `json:"force"`
+	}
+	argObject(args, 2, &opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), networkTimeout)
+	defer cancel()
+	action := "connected"
+	var err error
+	if connect {
+		err = app.Docker.NetworkConnect(ctx, networkName, containerName)
+	} else {
+		action = "disconnected"
+		err = app.Docker.NetworkDisconnect(ctx, networkName, containerName, opts.Force)
+	}
+	if err != nil {
+		slog.Warn("network endpoint", "err", err, "network", networkName, "container", containerName, "connect", connect)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	slog.Info("container "+action, "network", networkName, "container", containerName, "by", admin.Username)
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true})
+	}
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import "testing"
+
+func TestCreateNetworkOptionsValidate(t *testing.T) {
+	t.Parallel()
+	opts, err := createNetworkOptions{Name: "backend", Subnet: "10.5.1.7/24", Gateway: "10.5.1.1", Internal: true}.validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Subnet != "10.5.1.0/24" || opts.Gateway != "10.5.1.1" || !opts.Internal {
+		t.Errorf("unexpected options: %+v", opts)
+	}
+	if _, err := (createNetworkOptions{Name: "plain"}).validate(); err != nil {
+		t.Errorf("expected a network without IPAM to be valid: %v", err)
+	}
+
+	for name, o := range map[string]createNetworkOptions{
+		"empty name":        {},
+		"bad name":          {Name: "-net"},
+		"unknown driver":    {Name: "net", Driver: "host"},
+		"bad subnet":        {Name: "net", Subnet: "10.5.1.0"},
+		"gateway outside":   {Name: "net", Subnet: "10.5.1.0/24", Gateway: "10.6.0.1"},
+		"gateway no subnet": {Name: "net", Gateway: "10.5.1.1"},
+	} {
+		if _, err := o.validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"log/slog"
+
+	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// maxStackNoteLen bounds a stack note; notes are for context, not documents.
+const maxStackNoteLen = 10000
+
+// RegisterStackNoteHandlers registers the stack note handler.
+func RegisterStackNoteHandlers(app *App) {
+	app.WS.Handle("setStackNote", app.handleSetStackNote)
+}
+
+// stackNote returns a stack's note, or nil if it has none or notes are disabled.
+func (app *App) stackNote(stackName string) *models.StackNote {
+	if app.StackNotes == nil {
+		return nil
+	}
+	n, err := app.StackNotes.Get(stackName)
+	if err != nil {
+		slog.Warn("get stack note", "err", err, "stack", stackName)
+		return nil
+	}
+	return n
+}
+
+// deleteStackNote drops the note of a stack whose files were removed.
+func (app *App) deleteStackNote(stackName string) {
+	if app.StackNotes == nil {
+		return
+	}
+	if err := app.StackNotes.Delete(stackName); err != nil {
+		slog.Warn("delete stack note", "err", err, "stack", stackName)
+	}
+}
+
+// handleSetStackNote replaces a stack's note; empty text removes it.
+// Args: stack name, text.
+func (app *App) handleSetStackNote(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	if app.StackNotes == nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Stack notes are not available"})
+		}
+		return
+	}
+	args := parseArgs(msg)
+	stackName := argString(args, 0)
+	text := argString(args, 1)
+	if err := stack.ValidateStackName(stackName); err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	if len(text) > maxStackNoteLen {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Note is too long"})
+		}
+		return
+	}
+
+	note := models.StackNote{StackName: stackName, Text: text}
+	if user := app.currentUser(c); user != nil {
+		note.UpdatedBy = user.Username
+	}
+	if err := app.StackNotes.Set(note); err != nil {
+		slog.Error("set stack note", "err", err, "stack", stackName)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+
+	saved := app.stackNote(stackName)
+	ws.BroadcastAuthenticated(app.WS, "stackNoteChanged", struct {
+		StackName string            `json:"stackName"`
+		Note      *models.StackNote `json:"note"`
+	}{stackName, saved})
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Saved"})
+	}
+}
@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/docker"
+	"github.com/cfilipov/dockge/internal/notify"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// Settings keys of notifications. A provider is on once its required
+// settings are set.
+const (
+	settingNotifyEvents        = "notifyEvents"         // comma-separated kinds, all if unset
+	settingNotifyWebhookURL    = "notifyWebhookUrl"     // JSON POST of each event
+	settingNotifySlackURL      = "notifySlackUrl"       // Slack incoming webhook
+	settingNotifyTelegramToken = "notifyTelegramToken"  // bot token, secret
+	settingNotifyTelegramChat  = "notifyTelegramChatId" // chat to send to
+	settingNotifySMTPHost      = "notifySmtpHost"
+	settingNotifySMTPPort      = "notifySmtpPort" // 587 if unset
+	settingNotifySMTPUsername  = "notifySmtpUsername"
+	settingNotifySMTPPassword  = "notifySmtpPassword" // secret
+	settingNotifySMTPFrom      = "notifySmtpFrom"
+	settingNotifySMTPTo        = "notifySmtpTo"        // comma-separated
+	settingNotifyDiskThreshold = "notifyDiskThreshold" // percent used that warns, 0 = off
+)
+
+// notifySecretSettings are never sent to clients; getSettings shows
+// notifySecretMask in their place when they're set.
+var notifySecretSettings = []string{settingNotifyTelegramToken, settingNotifySMTPPassword}
+
+// notifySecretMask stands for a secret setting that is set. Saving it
+// keeps the secret.
+const notifySecretMask = "********"
+
+// Disk space monitoring of the stacks and data dirs.
+const (
+	diskCheckInterval    = 10 * time.Minute
+	defaultDiskThreshold = 90
+)
+
+// notifyState remembers what's needed to tell notable events apart.
+type notifyState struct {
+	mu     sync.Mutex
+	killed map[string]bool // container IDs killed on purpose, until they die
+	oom    map[string]bool // container IDs that ran out of memory, until they die
+	lowFS  map[string]bool // paths whose disk is over the threshold
+}
+
+// RegisterNotifyHandlers creates the notifier and registers the
+// testNotification handler. Events are sent once StartNotifier is called.
+func RegisterNotifyHandlers(app *App) {
+	app.notifier = notify.New(func() notify.Config {
+		return notifyConfigFrom(app.settingValue)
+	})
+	app.WS.Handle("testNotification", app.handleTestNotification)
+}
+
+// StartNotifier delivers notifications and watches disk space.
+func (app *App) StartNotifier(ctx context.Context) {
+	if app.notifier == nil {
+		return
+	}
+	app.notifier.Start(ctx)
+	go func() {
+		ticker := time.NewTicker(diskCheckInterval)
+		defer ticker.Stop()
+		for {
+			app.checkDiskSpace()
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// settingValue reads a setting, "" if unset.
+func (app *App) settingValue(key string) string {
+	v, _ := app.Settings.Get(key)
+	return v
+}
+
+// notifyConfigFrom builds the notification config from settings read with
+// get.
+func notifyConfigFrom(get func(key string) string) notify.Config {
+	cfg := notify.Config{Kinds: map[notify.Kind]bool{}, Host: get("primaryHostname")}
+	if cfg.Host == "" {
+		cfg.Host, _ = os.Hostname()
+	}
+
+	if events := strings.TrimSpace(get(settingNotifyEvents)); events == "" {
+		for _, k := range notify.Kinds {
+			cfg.Kinds[k] = true
+		}
+	} else {
+		for _, k := range strings.Split(events, ",") {
+			cfg.Kinds[notify.Kind(strings.TrimSpace(k))] = true
+		}
+	}
+
+	if u := get(settingNotifyWebhookURL); u != "" {
+		cfg.Providers = append(cfg.Providers, &notify.Webhook{URL: u})
+	}
+	if u := get(settingNotifySlackURL); u != "" {
+		cfg.Providers = append(cfg.Providers, &notify.Slack{URL: u})
+	}
+	if token, chat := get(settingNotifyTelegramToken), get(settingNotifyTelegramChat); token != "" && chat != "" {
+		cfg.Providers = append(cfg.Providers, &notify.Telegram{Token: token, ChatID: chat})
+	}
+	if host, to := get(settingNotifySMTPHost), splitList(get(settingNotifySMTPTo)); host != "" && len(to) > 0 {
+		port, err := strconv.Atoi(get(settingNotifySMTPPort))
+		if err != nil || port <= 0 {
+			port = 587
+		}
+		from := get(settingNotifySMTPFrom)
+		if from == "" {
+			from = get(settingNotifySMTPUsername)
+		}
+		cfg.Providers = append(cfg.Providers, &notify.Email{
+			Host:     host,
+			Port:     port,
+			Username: get(settingNotifySMTPUsername),
+			Password: get(settingNotifySMTPPassword),
+			From:     from,
+			To:       to,
+		})
+	}
+	return cfg
+}
+
+// splitList splits a comma-separated setting, dropping blanks.
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// validateNotifySettings checks the notification settings among settings
+// about to be saved, and drops secrets saved as notifySecretMask so the
+// stored ones are kept.
+func validateNotifySettings(data map[string]interface{}) error {
+	for _, key := range notifySecretSettings {
+		if data[key] == notifySecretMask {
+			delete(data, key)
+		}
+	}
+	for _, key := range []string{settingNotifyWebhookURL, settingNotifySlackURL} {
+		if u, _ := data[key].(string); u != "" {
+			if err := notify.ValidURL(u); err != nil {
+				return err
+			}
+		}
+	}
+	if events, ok := data[settingNotifyEvents].(string); ok {
+		for _, k := range splitList(events) {
+			if !isNotifyKind(notify.Kind(k)) {
+				return fmt.Errorf("unknown notification event %q", k)
+			}
+		}
+	}
+	return nil
+}
+
+func isNotifyKind(k notify.Kind) bool {
+	for _, known := range notify.Kinds {
+		if k == known {
+			return true
+		}
+	}
+	return false
+}
+
+// maskNotifySecrets replaces the notification secrets of settings about
+// to be sent to a client.
+func maskNotifySecrets(settings map[string]string) {
+	for _, key := range notifySecretSettings {
+		if settings[key] != "" {
+			settings[key] = notifySecretMask
+		}
+	}
+}
+
+// handleTestNotification sends a test notification to every provider, with
+// the saved settings overridden by the ones given, so they can be tried
+// before saving. Admin only. Args: [settings?]
+func (app *App) handleTestNotification(c *ws.Conn, msg *ws.ClientMessage) {
+	if app.checkAdmin(c, msg) == nil {
+		return
+	}
+	var overrides map[string]interface{}
+	argObject(parseArgs(msg), 0, &overrides)
+	if err := validateNotifySettings(overrides); err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	get := func(key string) string {
+		if v, ok := overrides[key].(string); ok {
+			return v
+		}
+		if v, ok := overrides[key].(float64); ok {
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		return app.settingValue(key)
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		err := notify.New(func() notify.Config { return notifyConfigFrom(get) }).Test(ctx)
+		if msg.ID == nil {
+			return
+		}
+		if err != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+			return
+		}
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Sent"})
+	}()
+}
+
+// notifyDockerEvent notifies of containers that die without being stopped
+// or killed, and of health checks turning unhealthy.
+func (app *App) notifyDockerEvent(evt docker.DockerEvent) {
+	if app.notifier == nil || evt.Type != "container" {
+		return
+	}
+	st := &app.notifyState
+	switch evt.Action {
+	case "kill", "oom":
+		st.mu.Lock()
+		if st.killed == nil {
+			st.killed, st.oom = make(map[string]bool), make(map[string]bool)
+		}
+		if evt.Action == "kill" {
+			st.killed[evt.ContainerID] = true
+		} else {
+			st.oom[evt.ContainerID] = true
+		}
+		st.mu.Unlock()
+	case "die":
+		st.mu.Lock()
+		killed, oom := st.killed[evt.ContainerID], st.oom[evt.ContainerID]
+		delete(st.killed, evt.ContainerID)
+		delete(st.oom, evt.ContainerID)
+		st.mu.Unlock()
+		if killed && !oom {
+			return // stopped, restarted or killed on purpose
+		}
+		if evt.ExitCode == "0" && app.isJobService(evt.Project, evt.Service) {
+			return // a job run that finished
+		}
+		message := "Exit code " + evt.ExitCode
+		if oom {
+			message += ", out of memory"
+		}
+		app.notifier.Notify(notify.Event{
+			Kind:      notify.KindContainerDie,
+			Title:     "Container " + evt.Name + " died",
+			Message:   message,
+			Stack:     evt.Project,
+			Container: evt.Name,
+			Time:      evt.Time,
+		})
+	case "destroy":
+		st.mu.Lock()
+		delete(st.killed, evt.ContainerID)
+		delete(st.oom, evt.ContainerID)
+		st.mu.Unlock()
+	case "health_status: unhealthy":
+		app.notifier.Notify(notify.Event{
+			Kind:      notify.KindContainerUnhealthy,
+			Title:     "Container " + evt.Name + " is unhealthy",
+			Stack:     evt.Project,
+			Container: evt.Name,
+			Time:      evt.Time,
+		})
+	}
+}
+
+// notifyDeploy notifies of a finished deploy.
+func (app *App) notifyDeploy(stackName string, err error) {
+	e := notify.Event{Kind: notify.KindDeploySuccess, Title: "Stack " + stackName + " deployed", Stack: stackName}
+	if err != nil {
+		e.Kind = notify.KindDeployFailure
+		e.Title = "Deploying stack " + stackName + " failed"
+		e.Message = err.Error()
+	}
+	app.notifier.Notify(e)
+}
+
+// notifyImageUpdates notifies of services of a stack that have an image
+// update they didn't have at the last check.
+func (app *App) notifyImageUpdates(stackName string, services []string) {
+	if len(services) == 0 {
+		return
+	}
+	app.notifier.Notify(notify.Event{
+		Kind:    notify.KindImageUpdate,
+		Title:   "Image updates for stack " + stackName,
+		Message: strings.Join(services, "\n"),
+		Stack:   stackName,
+	})
+}
+
+// checkDiskSpace warns once when the disk of the stacks or data dir gets
+// fuller than the threshold, and again only after it got below.
+func (app *App) checkDiskSpace() {
+	threshold := defaultDiskThreshold
+	if v := app.settingValue(settingNotifyDiskThreshold); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 && n <= 100 {
+			threshold = n
+		}
+	}
+	if threshold == 0 {
+		return
+	}
+
+	st := &app.notifyState
+	for _, path := range []string{app.StacksDir, app.DataDir} {
+		if path == "" {
+			continue
+		}
+		free, total, err := diskSpace(path)
+		if err != nil || total == 0 {
+			continue
+		}
+		used := int(100 - free*100/total)
+		low := used >= threshold
+
+		st.mu.Lock()
+		if st.lowFS == nil {
+			st.lowFS = make(map[string]bool)
+		}
+		was := st.lowFS[path]
+		st.lowFS[path] = low
+		st.mu.Unlock()
+
+		if low && !was {
+			slog.Warn("disk space low", "path", path, "usedPercent", used)
+			app.notifier.Notify(notify.Event{
+				Kind:      notify.KindDiskSpace,
+				Title:     fmt.Sprintf("Disk %d%% full", used),
+				Message:   fmt.Sprintf("%s has %.1f GiB free of %.1f GiB", path, float64(free)/(1<<30), float64(total)/(1<<30)),
+				Container: path, // one warning per path
+			})
+		}
+	}
+}
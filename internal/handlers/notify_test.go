@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/docker"
+	"github.com/cfilipov/dockge/internal/notify"
+)
+
+type sentNotifications struct {
+	mu     sync.Mutex
+	events []notify.Event
+}
+
+func (s *sentNotifications) Name() string { return "test" }
+
+func (s *sentNotifications) Send(_ context.Context, e notify.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, e)
+	return nil
+}
+
+func TestNotifyConfigFrom(t *testing.T) {
+	t.Parallel()
+	settings := map[string]string{
+		"primaryHostname":          "box",
+		settingNotifyEvents:        "containerDie, deployFailure",
+		settingNotifySlackURL:      "https://hooks.slack.com/services/x",
+		settingNotifyTelegramToken: "token", // no chat: off
+		settingNotifySMTPHost:      "smtp.example.com",
+		settingNotifySMTPUsername:  "me@example.com",
+		settingNotifySMTPTo:        "a@example.com, ,b@example.com",
+	}
+	cfg := notifyConfigFrom(func(key string) string { return settings[key] })
+
+	if cfg.Host != "box" {
+		t.Errorf("Host = %q", cfg.Host)
+	}
+	if len(cfg.Kinds) != 2 || !cfg.Kinds[notify.KindContainerDie] || !cfg.Kinds[notify.KindDeployFailure] {
+		t.Errorf("Kinds = %v", cfg.Kinds)
+	}
+	if len(cfg.Providers) != 2 {
+		t.Fatalf("got %d providers, want slack and email", len(cfg.Providers))
+	}
+	m, ok := cfg.Providers[1].(*notify.Email)
+	if !ok || m.Port != 587 || m.From != "me@example.com" || len(m.To) != 2 {
+		t.Errorf("email = %+v", cfg.Providers[1])
+	}
+
+	all := notifyConfigFrom(func(string) string { return "" })
+	if len(all.Kinds) != len(notify.Kinds) || len(all.Providers) != 0 {
+		t.Errorf("defaults = %+v", all)
+	}
+}
+
+func TestValidateNotifySettings(t *testing.T) {
+	t.Parallel()
+	data := map[string]interface{}{
+		settingNotifyTelegramToken: notifySecretMask,
+		settingNotifySMTPPassword:  "new",
+		settingNotifyWebhookURL:    "https://example.com/hook",
+		settingNotifyEvents:        "containerDie,diskSpace",
+	}
+	if err := validateNotifySettings(data); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := data[settingNotifyTelegramToken]; ok {
+		t.Error("masked secret not dropped")
+	}
+	if data[settingNotifySMTPPassword] != "new" {
+		t.Error("changed secret dropped")
+	}
+
+	for _, bad := range []map[string]interface{}{
+		{settingNotifySlackURL: "hooks.slack.com/x"},
+		{settingNotifyEvents: "containerDie,reboot"},
+	} {
+		if validateNotifySettings(bad) == nil {
+			t.Errorf("%v accepted", bad)
+		}
+	}
+
+	settings := map[string]string{settingNotifyTelegramToken: "token", settingNotifySMTPPassword: ""}
+	maskNotifySecrets(settings)
+	if settings[settingNotifyTelegramToken] != notifySecretMask || settings[settingNotifySMTPPassword] != "" {
+		t.Errorf("masked = %v", settings)
+	}
+}
+
+func TestNotifyDockerEvent(t *testing.T) {
+	t.Parallel()
+	sent := &sentNotifications{}
+	app := &App{notifier: notify.New(func() notify.Config {
+		return notify.Config{Providers: []notify.Provider{sent}, Kinds: map[notify.Kind]bool{
+			notify.KindContainerDie:       true,
+			notify.KindContainerUnhealthy: true,
+		}}
+	})}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	app.notifier.Start(ctx)
+
+	evt := func(id, name, action, exitCode string) docker.DockerEvent {
+		return docker.DockerEvent{Type: "container", Action: action, ContainerID: id, Name: name, Project: "web", ExitCode: exitCode}
+	}
+	for _, e := range []docker.DockerEvent{
+		// docker stop: no notification
+		evt("1", "web-app-1", "kill", ""),
+		evt("1", "web-app-1", "die", "143"),
+		// crash
+		evt("2", "web-db-1", "die", "1"),
+		// killed for running out of memory
+		evt("3", "web-worker-1", "oom", ""),
+		evt("3", "web-worker-1", "kill", ""),
+		evt("3", "web-worker-1", "die", "137"),
+		evt("4", "web-cache-1", "health_status: unhealthy", ""),
+	} {
+		app.notifyDockerEvent(e)
+	}
+
+	want := []string{
+		"Container web-db-1 died: Exit code 1",
+		"Container web-worker-1 died: Exit code 137, out of memory",
+		"Container web-cache-1 is unhealthy: ",
+	}
+	var got []string
+	for range 100 {
+		sent.mu.Lock()
+		got = got[:0]
+		for _, e := range sent.events {
+			got = append(got, e.Title+": "+e.Message)
+		}
+		sent.mu.Unlock()
+		if len(got) >= len(want) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("sent %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("notification %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+	if len(app.notifyState.killed) != 0 || len(app.notifyState.oom) != 0 {
+		t.Errorf("state not cleaned up: killed %v, oom %v", app.notifyState.killed, app.notifyState.oom)
+	}
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// RegisterOperationHandlers registers the operation history handler.
+func RegisterOperationHandlers(app *App) {
+	app.WS.Handle("getOperationHistory", app.handleGetOperationHistory)
+}
+
+// handleGetOperationHistory returns recent operations, newest first.
+// Args: stack name ("" for all stacks), limit (default 50).
+func (app *App) handleGetOperationHistory(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	if app.Operations == nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Operation history is not available"})
+		}
+		return
+	}
+
+	args := parseArgs(msg)
+	stackName := argString(args, 0)
+	limit := argInt(args, 1)
+	if limit <= 0 {
+		limit = 50
+	}
+	if stackName != "" {
+		if err := stack.ValidateStackName(stackName); err != nil {
+			if msg.ID != nil {
+				ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+			}
+			return
+		}
+	}
+
+	ops, err := app.Operations.List(stackName, limit)
+	if err != nil {
+		slog.Error("list operations", "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK         bool               `json:"ok"`
+			Operations []models.Operation `json:"operations"`
+		}{OK: true, Operations: ops})
+	}
+}
+
+// beginOperation records the start of a stack operation with an optional
+// note. Returns nil when there is no store or the write failed; endOperation
+// accepts nil.
+func (app *App) beginOperation(stackName, action, note string) *models.Operation {
+	if app.Operations == nil {
+		return nil
+	}
+	op := &models.Operation{StackName: stackName, Action: action, Note: note}
+	if err := app.Operations.Create(op); err != nil {
+		slog.Warn("record operation", "stack", stackName, "action", action, "err", err)
+		return nil
+	}
+	return op
+}
+
+// endOperation records the outcome of an operation started with
+// beginOperation.
+func (app *App) endOperation(op *models.Operation, err error) {
+	if op == nil {
+		return
+	}
+	op.FinishedAt = time.Now().Unix()
+	op.Success = err == nil
+	if err != nil {
+		op.Error = err.Error()
+	}
+	if err := app.Operations.Update(op); err != nil {
+		slog.Warn("record operation result", "stack", op.StackName, "action", op.Action, "err", err)
+	}
+}
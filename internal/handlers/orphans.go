@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/compose"
+	"github.com/cfilipov/dockge/internal/docker"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// orphansTimeout bounds listing or removing orphaned resources.
+const orphansTimeout = 2 * time.Minute
+
+// RegisterOrphanHandlers registers the handlers that report and remove the
+// networks and volumes of compose projects that are gone, such as stacks
+// deleted outside Dockge.
+func RegisterOrphanHandlers(app *App) {
+	app.WS.Handle("getOrphans", app.handleGetOrphans)
+	app.WS.Handle("removeOrphans", app.handleRemoveOrphans)
+}
+
+// orphanedResource is a network or volume of a project that's gone.
+type orphanedResource struct {
+	Name    string `json:"name"`
+	Project string `json:"project"`
+	Driver  string `json:"driver"`
+}
+
+// orphansReport lists the orphaned resources by kind.
+type orphansReport struct {
+	Networks []orphanedResource `json:"networks"`
+	Volumes  []orphanedResource `json:"volumes"`
+}
+
+// findOrphans returns the networks and volumes labeled with a compose
+// project that isn't in live, the projects of stack directories and
+// containers. Each kind is sorted by project, then name.
+func findOrphans(networks []docker.NetworkSummary, volumes []docker.VolumeSummary, live map[string]bool) orphansReport {
+	report := orphansReport{Networks: []orphanedResource{}, Volumes: []orphanedResource{}}
+	for _, n := range networks {
+		if p := n.Labels[composeProjectLabel]; p != "" && !live[p] {
+			report.Networks = append(report.Networks, orphanedResource{Name: n.Name, Project: p, Driver: n.Driver})
+		}
+	}
+	for _, v := range volumes {
+		if p := v.Labels[composeProjectLabel]; p != "" && !live[p] {
+			report.Volumes = append(report.Volumes, orphanedResource{Name: v.Name, Project: p, Driver: v.Driver})
+		}
+	}
+	byProject := func(a, b orphanedResource) int {
+		return cmp.Or(strings.Compare(a.Project, b.Project), strings.Compare(a.Name, b.Name))
+	}
+	slices.SortFunc(report.Networks, byProject)
+	slices.SortFunc(report.Volumes, byProject)
+	return report
+}
+
+// liveProjects returns the compose projects that still have a stack
+// directory or a container, running or not. A directory keeps both its
+// own name and the project name its compose file resolves to.
+func (app *App) liveProjects(ctx context.Context) (map[string]bool, error) {
+	entries, err := os.ReadDir(app.StacksDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read stacks directory: %w", err)
+	}
+	live := make(map[string]bool)
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		live[compose.NormalizeProjectName(e.Name())] = true
+		if compose.FindComposeFile(app.StacksDir, e.Name()) == "" {
+			continue
+		}
+		if app.Projects != nil {
+			live[app.Projects.ProjectName(e.Name())] = true
+		} else {
+			live[compose.ProjectName(filepath.Join(app.StacksDir, e.Name()))] = true
+		}
+	}
+	containers, err := app.Docker.ContainerList(ctx, true, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range containers {
+		if p := c.Labels[composeProjectLabel]; p != "" {
+			live[p] = true
+		}
+	}
+	return live, nil
+}
+
+// orphans lists the orphaned networks and volumes.
+func (app *App) orphans(ctx context.Context) (orphansReport, error) {
+	live, err := app.liveProjects(ctx)
+	if err != nil {
+		return orphansReport{}, err
+	}
+	networks, err := app.Docker.NetworkList(ctx)
+	if err != nil {
+		return orphansReport{}, err
+	}
+	volumes, err := app.Docker.VolumeList(ctx)
+	if err != nil {
+		return orphansReport{}, err
+	}
+	return findOrphans(networks, volumes, live), nil
+}
+
+// handleGetOrphans reports the networks and volumes whose compose project
+// has neither a stack directory nor containers anymore. Admin only.
+func (app *App) handleGetOrphans(c *ws.Conn, msg *ws.ClientMessage) {
+	if app.checkAdmin(c, msg) == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), orphansTimeout)
+	defer cancel()
+	report, err := app.orphans(ctx)
+	if err != nil {
+		slog.Warn("getOrphans", "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK bool `json:"ok"`
+			orphansReport
+		}{OK: true, orphansReport: report})
+	}
+}
+
+// orphanRemoval is the outcome of removing one orphaned resource.
+type orphanRemoval struct {
+	Kind  string `json:"kind"` // network or volume
+	Name  string `json:"name"`
+	Error string `json:"error,omitempty"`
+}
+
+// handleRemoveOrphans removes the given orphaned networks and volumes.
+// They're checked again first: anything that isn't orphaned anymore, say
+// because its stack was redeployed since the report, is skipped. Admin
+// only, and removing volumes loses their data, so that requires sudo.
+// Args: {networks: [name], volumes: [name]}.
+func (app *App) handleRemoveOrphans(c *ws.Conn, msg *ws.ClientMessage) {
+	admin := app.checkAdmin(c, msg)
+	if admin == nil {
+		return
+	}
+	var req struct {
+		Networks []string `json:"networks"`
+		Volumes  []string `json:"volumes"`
+	}
+	argObject(parseArgs(msg), 0, &req)
+	if len(req.Volumes) > 0 && !app.requireSudo(c, msg) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), orphansTimeout)
+	defer cancel()
+	report, err := app.orphans(ctx)
+	if err != nil {
+		slog.Warn("removeOrphans", "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	isOrphan := func(list []orphanedResource, name string) bool {
+		return slices.ContainsFunc(list, func(r orphanedResource) bool { return r.Name == name })
+	}
+
+	results := []orphanRemoval{}
+	remove := func(kind, name string, orphaned bool, rm func(context.Context, string) error) {
+		r := orphanRemoval{Kind: kind, Name: name}
+		if !orphaned {
+			r.Error = "not orphaned"
+		} else if err := rm(ctx, name); err != nil {
+			r.Error = err.Error()
+		} else {
+			slog.Info("orphaned "+kind+" removed", kind, name, "by", admin.Username)
+		}
+		results = append(results, r)
+	}
+	for _, name := range req.Networks {
+		remove("network", name, isOrphan(report.Networks, name), app.Docker.NetworkRemove)
+	}
+	for _, name := range req.Volumes {
+		remove("volume", name, isOrphan(report.Volumes, name), app.Docker.VolumeRemove)
+	}
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK      bool            `json:"ok"`
+			Results []orphanRemoval `json:"results"`
+		}{OK: true, Results: results})
+	}
+}
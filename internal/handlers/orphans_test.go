@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cfilipov/dockge/internal/docker"
+)
+
+func TestFindOrphans(t *testing.T) {
+	t.Parallel()
+	project := func(p string) map[string]string { return map[string]string{composeProjectLabel: p} }
+	networks := []docker.NetworkSummary{
+		{Name: "bridge", Driver: "bridge"},
+		{Name: "web_default", Driver: "bridge", Labels: project("web")},
+		{Name: "old_default", Driver: "bridge", Labels: project("old")},
+		{Name: "gone_backend", Driver: "overlay", Labels: project("gone")},
+	}
+	volumes := []docker.VolumeSummary{
+		{Name: "manual", Driver: "local"},
+		{Name: "web_data", Driver: "local", Labels: project("web")},
+		{Name: "old_db", Driver: "local", Labels: project("old")},
+		{Name: "old_cache", Driver: "local", Labels: project("old")},
+	}
+
+	report := findOrphans(networks, volumes, map[string]bool{"web": true})
+	want := orphansReport{
+		Networks: []orphanedResource{
+			{Name: "gone_backend", Project: "gone", Driver: "overlay"},
+			{Name: "old_default", Project: "old", Driver: "bridge"},
+		},
+		Volumes: []orphanedResource{
+			{Name: "old_cache", Project: "old", Driver: "local"},
+			{Name: "old_db", Project: "old", Driver: "local"},
+		},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("findOrphans = %+v, want %+v", report, want)
+	}
+
+	report = findOrphans(networks, volumes, map[string]bool{"web": true, "old": true, "gone": true})
+	if len(report.Networks) != 0 || len(report.Volumes) != 0 {
+		t.Errorf("expected no orphans, got %+v", report)
+	}
+}
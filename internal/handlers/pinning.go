@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/compose"
+	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// RegisterPinningHandlers registers the image pinning report handlers and
+// the handlers that move a service to another tag.
+func RegisterPinningHandlers(app *App) {
+	app.WS.Handle("getImagePinReport", app.handleGetImagePinReport)
+	app.WS.Handle("pinServiceImage", app.handlePinServiceImage)
+	app.WS.Handle("getServiceTagCandidates", app.handleGetServiceTagCandidates)
+	app.WS.Handle("updateServiceToTag", app.handleUpdateServiceToTag)
+}
+
+// imagePinEntry is one service whose image reference isn't reproducible.
+type imagePinEntry struct {
+	StackName  string `json:"stackName"`
+	Service    string `json:"service"`
+	Image      string `json:"image"`
+	Status     string `json:"status"`               // compose.PinLatest, PinFloating, PinDigestOnly
+	Suggestion string `json:"suggestion,omitempty"` // tag@digest of the running image
+}
+
+// handleGetImagePinReport lists services across all stacks that use
+// latest/untagged, floating, or digest-only image references, with a pinned
+// reference built from the image the service is currently running.
+func (app *App) handleGetImagePinReport(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+
+	entries, err := os.ReadDir(app.StacksDir)
+	if err != nil {
+		slog.Warn("pin report: read stacks dir", "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Image ID each service is running, keyed by "stack/service"
+	imageIDs := make(map[string]string)
+	if containers, err := app.Docker.ContainerListDetailed(ctx); err == nil {
+		for _, ctr := range containers {
+			if ctr.StackName != "" && ctr.ServiceName != "" && ctr.ImageID != "" {
+				imageIDs[ctr.StackName+"/"+ctr.ServiceName] = ctr.ImageID
+			}
+		}
+	} else {
+		slog.Warn("pin report: list containers", "err", err)
+	}
+
+	report := []imagePinEntry{}
+	for _, entry := range entries {
+		if !stack.IsDirEntry(app.StacksDir, entry) {
+			continue
+		}
+		path := compose.FindComposeFile(app.StacksDir, entry.Name())
+		if path == "" {
+			continue
+		}
+		for svc, sd := range compose.ParseFile(path) {
+			status := compose.ImagePinStatus(sd.Image)
+			if status == "" {
+				continue
+			}
+			e := imagePinEntry{StackName: entry.Name(), Service: svc, Image: sd.Image, Status: status}
+			if id := imageIDs[e.StackName+"/"+svc]; id != "" {
+				e.Suggestion = app.pinSuggestion(ctx, sd.Image, id)
+			}
+			report = append(report, e)
+		}
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].StackName != report[j].StackName {
+			return report[i].StackName < report[j].StackName
+		}
+		return report[i].Service < report[j].Service
+	})
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK     bool            `json:"ok"`
+			Report []imagePinEntry `json:"report"`
+		}{
+			OK:     true,
+			Report: report,
+		})
+	}
+}
+
+// pinSuggestion builds "repo:tag@digest" for the local image imageID. The
+// most specific local tag of the same repository is preferred over the one
+// in the compose file, so "nginx:latest" can become "nginx:1.27.2@sha256:…".
+func (app *App) pinSuggestion(ctx context.Context, ref, imageID string) string {
+	repo, tag, _ := compose.SplitImageRef(ref)
+
+	digests, err := app.Docker.ImageInspect(ctx, imageID)
+	if err != nil || len(digests) == 0 {
+		return ""
+	}
+	digest := ""
+	for _, d := range digests {
+		r, dg, ok := strings.Cut(d, "@")
+		if !ok {
+			continue
+		}
+		if digest == "" || sameRepo(r, repo) {
+			digest = dg
+		}
+	}
+	if digest == "" {
+		return ""
+	}
+
+	if images, err := app.Docker.ImageListByID(ctx, imageID); err == nil {
+		for _, img := range images {
+			for _, rt := range img.RepoTags {
+				r, t, _ := compose.SplitImageRef(rt)
+				if sameRepo(r, repo) && compose.ImagePinStatus(rt) == "" {
+					tag = t
+				}
+			}
+		}
+	}
+	if tag == "" {
+		tag = "latest"
+	}
+	return repo + ":" + tag + "@" + digest
+}
+
+// sameRepo compares repository names, ignoring the implicit Docker Hub
+// "docker.io/" and "library/" prefixes.
+func sameRepo(a, b string) bool {
+	norm := func(s string) string {
+		s = strings.TrimPrefix(s, "docker.io/")
+		return strings.TrimPrefix(s, "library/")
+	}
+	return norm(a) == norm(b)
+}
+
+// handlePinServiceImage rewrites a service's image: line in compose.yaml.
+// Goes through approval like any other save when required.
+func (app *App) handlePinServiceImage(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+
+	args := parseArgs(msg)
+	stackName := argString(args, 0)
+	service := argString(args, 1)
+	image := argString(args, 2)
+	if service == "" || image == "" {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Service and image required"})
+		}
+		return
+	}
+	if err := stack.ValidateStackName(stackName); err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+
+	app.StackLocks.Lock(stackName)
+	defer app.StackLocks.Unlock(stackName)
+
+	s := &stack.Stack{Name: stackName}
+	s.LoadFromDisk(app.StacksDir)
+	updated, ok := compose.SetServiceImage(s.ComposeYAML, service, image)
+	if !ok {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Service image not found in compose file"})
+		}
+		return
+	}
+	s.ComposeYAML = updated
+
+	if user, ok := app.approvalRequired(c); ok {
+		app.submitPendingChange(c, msg, user, models.PendingActionSave, s, "")
+		return
+	}
+
+	if err := s.SaveToDisk(app.StacksDir); err != nil {
+		slog.Error("pin service image", "err", err, "stack", stackName)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	app.handleComposeYAMLSave(stackName, s.ComposeYAML)
+	slog.Info("service image pinned", "stack", stackName, "service", service, "image", image)
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Saved"})
+	}
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// portUser is something already holding a host port.
+type portUser struct {
+	Source    string `json:"source"` // "container", "process"
+	Container string `json:"container,omitempty"`
+	StackName string `json:"stackName,omitempty"`
+}
+
+// procNetFiles lists the kernel socket tables scanned per protocol.
+var procNetFiles = map[string][]string{
+	"tcp": {"/proc/net/tcp", "/proc/net/tcp6"},
+	"udp": {"/proc/net/udp", "/proc/net/udp6"},
+}
+
+// handleCheckHostPort reports whether a host port is free: Docker-published
+// ports are matched against containers, anything else is found with a bind
+// test, falling back to the /proc socket tables when binding isn't
+// permitted (ports below 1024 without root).
+//
+// Args: port, protocol ("tcp" default or "udp"), host IP ("" = all).
+func (app *App) handleCheckHostPort(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	args := parseArgs(msg)
+	port := argInt(args, 0)
+	proto := argString(args, 1)
+	hostIP := argString(args, 2)
+	if proto == "" {
+		proto = "tcp"
+	}
+	if port < 1 || port > 65535 || (proto != "tcp" && proto != "udp") || (hostIP != "" && net.ParseIP(hostIP) == nil) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Invalid port, protocol or host IP"})
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	users := []portUser{}
+	if containers, err := app.Docker.ContainerListDetailed(ctx); err == nil {
+		for _, ctr := range containers {
+			for _, p := range ctr.Ports {
+				if int(p.HostPort) == port && p.Protocol == proto {
+					users = append(users, portUser{Source: "container", Container: ctr.Name, StackName: ctr.StackName})
+					break
+				}
+			}
+		}
+	}
+
+	method := "bind"
+	busy, err := bindTest(proto, hostIP, port)
+	if err != nil {
+		method = "proc"
+		busy = procPortInUse(procNetFiles[proto], port)
+	}
+	if busy && len(users) == 0 {
+		users = append(users, portUser{Source: "process"})
+	}
+
+	_, statErr := os.Stat("/.dockerenv")
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK            bool       `json:"ok"`
+			Free          bool       `json:"free"`
+			UsedBy        []portUser `json:"usedBy"`
+			Method        string     `json:"method"`
+			Containerized bool       `json:"containerized"` // OS checks only see Dockge's own network namespace
+		}{
+			OK:            true,
+			Free:          len(users) == 0,
+			UsedBy:        users,
+			Method:        method,
+			Containerized: statErr == nil,
+		})
+	}
+}
+
+// bindTest tries to listen on the port. Returns busy=true if the address is
+// in use, or an error if the test itself couldn't run (e.g. permission).
+func bindTest(proto, hostIP string, port int) (bool, error) {
+	addr := net.JoinHostPort(hostIP, strconv.Itoa(port))
+	var err error
+	if proto == "udp" {
+		var pc net.PacketConn
+		if pc, err = net.ListenPacket("udp", addr); err == nil {
+			pc.Close()
+			return false, nil
+		}
+	} else {
+		var l net.Listener
+		if l, err = net.Listen("tcp", addr); err == nil {
+			l.Close()
+			return false, nil
+		}
+	}
+	if errors.Is(err, syscall.EADDRINUSE) {
+		return true, nil
+	}
+	return false, err
+}
+
+// procPortInUse scans /proc/net/{tcp,udp}[6] for a socket bound to port.
+// TCP sockets count only when listening (state 0A); any bound UDP socket
+// counts.
+func procPortInUse(files []string, port int) bool {
+	for _, path := range files {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Scan() // header
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 4 {
+				continue
+			}
+			// local_address is "HEXIP:HEXPORT"
+			_, hexPort, ok := strings.Cut(fields[1], ":")
+			if !ok {
+				continue
+			}
+			p, err := strconv.ParseUint(hexPort, 16, 16)
+			if err != nil || int(p) != port {
+				continue
+			}
+			tcp := strings.Contains(path, "tcp")
+			if !tcp || fields[3] == "0A" {
+				f.Close()
+				return true
+			}
+		}
+		f.Close()
+	}
+	return false
+}
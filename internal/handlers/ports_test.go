@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProcPortInUse(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	tcp := filepath.Join(dir, "tcp")
+	udp := filepath.Join(dir, "udp")
+	// 0x1F90 = 8080 listening, 0x0050 = 80 established only
+	os.WriteFile(tcp, []byte(
+		"  sl  local_address rem_address   st tx_queue rx_queue\n"+
+			"   0: 00000000:1F90 00000000:0000 0A 00000000:00000000\n"+
+			"   1: 0100007F:0050 0100007F:C350 01 00000000:00000000\n"), 0644)
+	os.WriteFile(udp, []byte(
+		"  sl  local_address rem_address   st tx_queue rx_queue\n"+
+			"   0: 00000000:0035 00000000:0000 07 00000000:00000000\n"), 0644)
+
+	if !procPortInUse([]string{tcp}, 8080) {
+		t.Error("8080/tcp should be in use")
+	}
+	if procPortInUse([]string{tcp}, 80) {
+		t.Error("80/tcp is not listening")
+	}
+	if !procPortInUse([]string{udp}, 53) {
+		t.Error("53/udp should be in use")
+	}
+	if procPortInUse([]string{filepath.Join(dir, "missing")}, 53) {
+		t.Error("missing file should report free")
+	}
+}
+
+func TestBindTest(t *testing.T) {
+	t.Parallel()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("cannot listen:", err)
+	}
+	defer l.Close()
+	port := l.Addr().(*net.TCPAddr).Port
+
+	busy, err := bindTest("tcp", "127.0.0.1", port)
+	if err != nil || !busy {
+		t.Errorf("bindTest on held port = %v, %v", busy, err)
+	}
+}
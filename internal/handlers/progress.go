@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"os/exec"
+
+	"github.com/cfilipov/dockge/internal/compose"
+	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/terminal"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// composeProgress is the "composeProgress" event: one resource update from
+// a running compose command, or Done once the command has exited.
+type composeProgress struct {
+	StackName string `json:"stackName"`
+	Action    string `json:"action"`
+	compose.ProgressEvent
+	Done bool `json:"done,omitempty"`
+}
+
+// runCompose runs `docker compose <globalArgs> <composeArgs>` in dir on
+// term. If compose supports --progress json, progress is parsed into
+// "composeProgress" events (also passed to onEvent, if set) and rendered as
+// plain lines on the terminal; otherwise the command runs on a PTY as
+// before.
+func (app *App) runCompose(ctx context.Context, term *terminal.Terminal, stackName, action, dir string, globalArgs, composeArgs []string, onEvent func(compose.ProgressEvent)) error {
+	args := []string{"compose"}
+	args = append(args, globalArgs...)
+	if !compose.SupportsJSONProgress(ctx) {
+		args = append(args, composeArgs...)
+		cmd := exec.CommandContext(ctx, "docker", args...)
+		cmd.Dir = dir
+		return term.RunPTY(cmd)
+	}
+
+	args = append(args, "--progress", "json")
+	args = append(args, composeArgs...)
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Dir = dir
+	err := term.RunLines(cmd, func(line []byte) []byte {
+		ev, ok := compose.ParseProgressLine(line)
+		if !ok {
+			return line
+		}
+		ws.BroadcastAuthenticated(app.WS, "composeProgress", composeProgress{StackName: stackName, Action: action, ProgressEvent: ev})
+		if onEvent != nil {
+			onEvent(ev)
+		}
+		return []byte(ev.String())
+	})
+	ws.BroadcastAuthenticated(app.WS, "composeProgress", composeProgress{StackName: stackName, Action: action, Done: true})
+	return err
+}
+
+// pullAggregator folds the per-layer events of a `compose pull` into one
+// models.PullProgress for the whole stack.
+type pullAggregator struct {
+	images map[string]bool
+	layers map[string]pullLayer // parent/id → layer
+	last   models.PullProgress
+}
+
+type pullLayer struct {
+	current, total int64
+	done           bool
+}
+
+func newPullAggregator() *pullAggregator {
+	return &pullAggregator{images: map[string]bool{}, layers: map[string]pullLayer{}}
+}
+
+// Add records ev and reports whether the summary changed.
+func (a *pullAggregator) Add(ev compose.ProgressEvent) bool {
+	if ev.Tail || ev.ID == "" {
+		return false
+	}
+	if ev.ParentID == "" {
+		a.images[ev.ID] = true
+	} else {
+		a.images[ev.ParentID] = true
+		key := ev.ParentID + "/" + ev.ID
+		l := a.layers[key]
+		if ev.Total > 0 {
+			l.current, l.total = ev.Current, ev.Total
+		}
+		if ev.Status == "Done" || ev.Text == "Pull complete" || ev.Text == "Already exists" {
+			l.done = true
+		}
+		a.layers[key] = l
+	}
+
+	next := a.Summary()
+	if next == a.last {
+		return false
+	}
+	a.last = next
+	return true
+}
+
+// Summary returns the aggregated progress so far.
+func (a *pullAggregator) Summary() models.PullProgress {
+	p := models.PullProgress{Images: len(a.images), Layers: len(a.layers)}
+	for _, l := range a.layers {
+		if l.done {
+			p.LayersDone++
+			p.Current += l.total
+		} else {
+			p.Current += l.current
+		}
+		p.Total += l.total
+	}
+	switch {
+	case p.Layers > 0 && p.LayersDone == p.Layers:
+		p.Percent = 100
+	case p.Total > 0:
+		p.Percent = int(p.Current * 100 / p.Total)
+	case p.Layers > 0:
+		p.Percent = p.LayersDone * 100 / p.Layers
+	}
+	return p
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/cfilipov/dockge/internal/compose"
+	"github.com/cfilipov/dockge/internal/models"
+)
+
+func TestPullAggregator(t *testing.T) {
+	t.Parallel()
+	a := newPullAggregator()
+	events := []compose.ProgressEvent{
+		{ID: "web", Text: "Pulling"},
+		{ID: "db", Text: "Pulling"},
+		{ID: "l1", ParentID: "web", Text: "Downloading", Current: 50, Total: 100},
+		{ID: "l2", ParentID: "web", Text: "Already exists"},
+		{ID: "l3", ParentID: "db", Text: "Downloading", Current: 100, Total: 300},
+	}
+	for _, ev := range events {
+		a.Add(ev)
+	}
+	want := models.PullProgress{Images: 2, Layers: 3, LayersDone: 1, Current: 150, Total: 400, Percent: 37}
+	if got := a.Summary(); got != want {
+		t.Errorf("Summary() = %+v, want %+v", got, want)
+	}
+
+	if a.Add(compose.ProgressEvent{ID: "l1", ParentID: "web", Text: "Downloading", Current: 50, Total: 100}) {
+		t.Error("repeated event should not change the summary")
+	}
+
+	a.Add(compose.ProgressEvent{ID: "l1", ParentID: "web", Text: "Pull complete"})
+	a.Add(compose.ProgressEvent{ID: "l3", ParentID: "db", Status: "Done"})
+	if got := a.Summary(); got.Percent != 100 || got.LayersDone != 3 || got.Current != 400 {
+		t.Errorf("after completion: %+v", got)
+	}
+}
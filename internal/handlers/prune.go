@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/docker"
+	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// pruneTimeout bounds a prune, which may delete many images or volumes.
+const pruneTimeout = 10 * time.Minute
+
+// RegisterPruneHandlers registers the handlers that prune unused images,
+// volumes, networks and build cache. The disk usage report
+// (requestDiskUsage) shows what each would free.
+func RegisterPruneHandlers(app *App) {
+	app.WS.Handle("pruneImages", app.handlePruneImages)
+	app.WS.Handle("pruneVolumes", app.handlePruneVolumes)
+	app.WS.Handle("pruneNetworks", app.handlePruneNetworks)
+	app.WS.Handle("pruneBuildCache", app.handlePruneBuildCache)
+}
+
+// pruneOptions are the options of every prune.
+type pruneOptions struct {
+	All bool `json:"all"`
+}
+
+// runPrune runs a prune for admin in the background and acks its report.
+func (app *App) runPrune(c *ws.Conn, msg *ws.ClientMessage, admin *models.User, what string, prune func(ctx context.Context) (docker.PruneReport, error)) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), pruneTimeout)
+		defer cancel()
+		report, err := prune(ctx)
+		if err != nil {
+			slog.Warn("prune", "what", what, "err", err)
+			if msg.ID != nil {
+				ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+			}
+			return
+		}
+		slog.Info("pruned", "what", what, "deleted", len(report.Deleted), "reclaimed", report.SpaceReclaimed, "by", admin.Username)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, struct {
+				OK     bool               `json:"ok"`
+				Report docker.PruneReport `json:"report"`
+			}{OK: true, Report: report})
+		}
+	}()
+}
+
+// handlePruneImages removes dangling images, or every image without a
+// container if all is set. Admin only. Args: {all}.
+func (app *App) handlePruneImages(c *ws.Conn, msg *ws.ClientMessage) {
+	admin := app.checkAdmin(c, msg)
+	if admin == nil {
+		return
+	}
+	var opts pruneOptions
+	argObject(parseArgs(msg), 0, &opts)
+	app.runPrune(c, msg, admin, "images", func(ctx context.Context) (docker.PruneReport, error) {
+		return app.Docker.ImagePrune(ctx, opts.All)
+	})
+}
+
+// handlePruneVolumes removes anonymous volumes no container uses, or
+// named ones too if all is set. Admin only, and as their data is lost it
+// requires sudo. Args: {all}.
+func (app *App) handlePruneVolumes(c *ws.Conn, msg *ws.ClientMessage) {
+	admin := app.checkAdmin(c, msg)
+	if admin == nil || !app.requireSudo(c, msg) {
+		return
+	}
+	var opts pruneOptions
+	argObject(parseArgs(msg), 0, &opts)
+	app.runPrune(c, msg, admin, "volumes", func(ctx context.Context) (docker.PruneReport, error) {
+		return app.Docker.VolumePrune(ctx, opts.All)
+	})
+}
+
+// handlePruneNetworks removes custom networks no container is connected
+// to. Admin only.
+func (app *App) handlePruneNetworks(c *ws.Conn, msg *ws.ClientMessage) {
+	admin := app.checkAdmin(c, msg)
+	if admin == nil {
+		return
+	}
+	app.runPrune(c, msg, admin, "networks", app.Docker.NetworkPrune)
+}
+
+// handlePruneBuildCache removes dangling build cache, or all of it that
+// isn't in use if all is set. Admin only. Args: {all}.
+func (app *App) handlePruneBuildCache(c *ws.Conn, msg *ws.ClientMessage) {
+	admin := app.checkAdmin(c, msg)
+	if admin == nil {
+		return
+	}
+	var opts pruneOptions
+	argObject(parseArgs(msg), 0, &opts)
+	app.runPrune(c, msg, admin, "build cache", func(ctx context.Context) (docker.PruneReport, error) {
+		return app.Docker.BuildCachePrune(ctx, opts.All)
+	})
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"log/slog"
+
+	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// Pull policies of compose up --pull. Without one, compose pulls the
+// images that are missing or whose service sets pull_policy: always.
+const (
+	pullAlways  = "always"
+	pullMissing = "missing"
+	pullNever   = "never"
+)
+
+// validPullPolicy reports whether p is a pull policy, or "" for none.
+func validPullPolicy(p string) bool {
+	switch p {
+	case "", pullAlways, pullMissing, pullNever:
+		return true
+	}
+	return false
+}
+
+// RegisterPullPolicyHandlers registers the handlers of per-stack pull
+// policies.
+func RegisterPullPolicyHandlers(app *App) {
+	app.WS.Handle("getStackPullPolicy", app.handleGetStackPullPolicy)
+	app.WS.Handle("setStackPullPolicy", app.handleSetStackPullPolicy)
+}
+
+// stackPullPolicy returns a stack's pull policy, or nil if it has none.
+func (app *App) stackPullPolicy(stackName string) *models.StackPullPolicy {
+	if app.PullPolicies == nil {
+		return nil
+	}
+	p, err := app.PullPolicies.Get(stackName)
+	if err != nil {
+		slog.Warn("get pull policy", "err", err, "stack", stackName)
+		return nil
+	}
+	return p
+}
+
+// deletePullPolicy drops the pull policy of a stack whose files were
+// removed.
+func (app *App) deletePullPolicy(stackName string) {
+	if app.PullPolicies == nil {
+		return
+	}
+	if err := app.PullPolicies.Delete(stackName); err != nil {
+		slog.Warn("delete pull policy", "err", err, "stack", stackName)
+	}
+}
+
+// upArgs returns the compose arguments that start a stack. pull is the
+// policy picked for this action; "" falls back to the stack's own.
+func (app *App) upArgs(stackName, pull string) []string {
+	args := []string{"up", "-d", "--remove-orphans"}
+	if pull == "" {
+		if p := app.stackPullPolicy(stackName); p != nil {
+			pull = p.Policy
+		}
+	}
+	if pull != "" {
+		args = append(args, "--pull", pull)
+	}
+	return args
+}
+
+// handleGetStackPullPolicy returns a stack's pull policy (null if it has
+// none). Args: stack name.
+func (app *App) handleGetStackPullPolicy(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	stackName := argString(parseArgs(msg), 0)
+	if err := stack.ValidateStackName(stackName); err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK     bool                    `json:"ok"`
+			Policy *models.StackPullPolicy `json:"policy"`
+		}{OK: true, Policy: app.stackPullPolicy(stackName)})
+	}
+}
+
+// handleSetStackPullPolicy sets the pull policy a stack's starts and
+// deploys use by default; "" removes it. Admin only.
+// Args: stack name, policy.
+func (app *App) handleSetStackPullPolicy(c *ws.Conn, msg *ws.ClientMessage) {
+	admin := app.checkAdmin(c, msg)
+	if admin == nil {
+		return
+	}
+	if app.PullPolicies == nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Pull policies are not available"})
+		}
+		return
+	}
+	args := parseArgs(msg)
+	stackName := argString(args, 0)
+	policy := argString(args, 1)
+	if err := stack.ValidateStackName(stackName); err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	if !validPullPolicy(policy) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "invalidPullPolicy", MsgI18n: true})
+		}
+		return
+	}
+
+	if err := app.PullPolicies.Set(models.StackPullPolicy{StackName: stackName, Policy: policy, UpdatedBy: admin.Username}); err != nil {
+		slog.Error("set pull policy", "err", err, "stack", stackName)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+	slog.Info("stack pull policy changed", "stack", stackName, "policy", policy, "by", admin.Username)
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Saved"})
+	}
+}
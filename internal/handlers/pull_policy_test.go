@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/cfilipov/dockge/internal/db"
+	"github.com/cfilipov/dockge/internal/models"
+)
+
+func TestUpArgs(t *testing.T) {
+	t.Parallel()
+	database, err := db.Open(filepath.Join(t.TempDir(), "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	app := &App{PullPolicies: models.NewStackPullPolicyStore(database)}
+
+	if got := app.upArgs("web", ""); !slices.Equal(got, []string{"up", "-d", "--remove-orphans"}) {
+		t.Errorf("without a policy: %v", got)
+	}
+	if got := app.upArgs("web", pullAlways); !slices.Equal(got, []string{"up", "-d", "--remove-orphans", "--pull", "always"}) {
+		t.Errorf("picked policy: %v", got)
+	}
+
+	if err := app.PullPolicies.Set(models.StackPullPolicy{StackName: "web", Policy: pullNever}); err != nil {
+		t.Fatal(err)
+	}
+	if got := app.upArgs("web", ""); !slices.Equal(got, []string{"up", "-d", "--remove-orphans", "--pull", "never"}) {
+		t.Errorf("stack policy: %v", got)
+	}
+	if got := app.upArgs("web", pullMissing); got[len(got)-1] != pullMissing {
+		t.Errorf("picked policy should win over the stack's: %v", got)
+	}
+	if got := app.upArgs("db", ""); len(got) != 3 {
+		t.Errorf("other stack: %v", got)
+	}
+
+	if (&App{}).upArgs("web", "")[2] != "--remove-orphans" {
+		t.Error("expected no store to mean no policy")
+	}
+	if validPullPolicy("sometimes") {
+		t.Error("expected an unknown policy to be rejected")
+	}
+}
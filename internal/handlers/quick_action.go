@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// Quick action statuses.
+const (
+	quickActionRunning  = "running"
+	quickActionDone     = "done"
+	quickActionFailed   = "failed"
+	quickActionRejected = "rejected"
+)
+
+// Codes of rejected quick actions, for clients to branch on instead of
+// parsing messages.
+const (
+	quickCodeNoStack       = "stackRequired"
+	quickCodeInvalidStack  = "invalidStack"
+	quickCodeUnknownAction = "unknownAction"
+	quickCodeArchived      = "archived"
+	quickCodeKeyConflict   = "keyConflict"
+)
+
+// quickActionKeyTTL is how long an idempotency key is remembered after its
+// action finished.
+const quickActionKeyTTL = 10 * time.Minute
+
+// quickActions are the verbs quickAction runs, and whether they need the
+// stack to be unarchived.
+var quickActions = map[string]bool{
+	"start":   true,
+	"stop":    false,
+	"restart": true,
+	"update":  true,
+	"down":    false,
+	"pause":   false,
+	"resume":  true,
+}
+
+// quickActionAck is the ack of every quickAction call, accepted or not.
+// A retry with the same key gets the state of the first call, with
+// Duplicate set.
+type quickActionAck struct {
+	OK        bool   `json:"ok"`
+	Stack     string `json:"stack"`
+	Action    string `json:"action"`
+	Key       string `json:"key,omitempty"`
+	Status    string `json:"status"`
+	Duplicate bool   `json:"duplicate,omitempty"`
+	Code      string `json:"code,omitempty"`
+	Msg       string `json:"msg,omitempty"`
+}
+
+// quickActionRun is a quickAction call made with an idempotency key.
+type quickActionRun struct {
+	ack      quickActionAck
+	finished time.Time
+}
+
+// quickActionState remembers keyed quickAction calls, by user and key.
+type quickActionState struct {
+	mu   sync.Mutex
+	runs map[string]*quickActionRun
+}
+
+// claim records a keyed call unless the key is known, in which case the
+// known call is returned.
+func (s *quickActionState) claim(key string, ack quickActionAck, now time.Time) (quickActionAck, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.runs == nil {
+		s.runs = make(map[string]*quickActionRun)
+	}
+	for k, r := range s.runs {
+		if !r.finished.IsZero() && now.Sub(r.finished) > quickActionKeyTTL {
+			delete(s.runs, k)
+		}
+	}
+	if r, ok := s.runs[key]; ok {
+		return r.ack, false
+	}
+	s.runs[key] = &quickActionRun{ack: ack}
+	return ack, true
+}
+
+// finish records the outcome of a keyed call.
+func (s *quickActionState) finish(key string, err error, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.runs[key]
+	if !ok {
+		return
+	}
+	r.finished = now
+	r.ack.Status = quickActionDone
+	if err != nil {
+		r.ack.OK = false
+		r.ack.Status = quickActionFailed
+		r.ack.Msg = err.Error()
+	}
+}
+
+// handleQuickAction runs a common stack action with a compact, uniform ack,
+// for keyboard shortcuts and automations. A retry with the same key doesn't
+// run the action again. Args: [stackName, action, key?]
+func (app *App) handleQuickAction(c *ws.Conn, msg *ws.ClientMessage) {
+	uid := checkLogin(c, msg)
+	if uid == 0 {
+		return
+	}
+	args := parseArgs(msg)
+	ack := quickActionAck{
+		OK:     true,
+		Stack:  argString(args, 0),
+		Action: argString(args, 1),
+		Key:    argString(args, 2),
+		Status: quickActionRunning,
+	}
+	reply := func(a quickActionAck) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, a)
+		}
+	}
+	reject := func(code, text string) {
+		ack.OK, ack.Status, ack.Code, ack.Msg = false, quickActionRejected, code, text
+		reply(ack)
+	}
+
+	needsActive, known := quickActions[ack.Action]
+	switch {
+	case ack.Stack == "":
+		reject(quickCodeNoStack, "Stack name required")
+		return
+	case !known:
+		reject(quickCodeUnknownAction, "Unknown action "+strconv.Quote(ack.Action))
+		return
+	}
+	if err := stack.ValidateStackName(ack.Stack); err != nil {
+		reject(quickCodeInvalidStack, err.Error())
+		return
+	}
+	if needsActive && app.stackArchive(ack.Stack) != nil {
+		reject(quickCodeArchived, "Stack is archived. Unarchive it first.")
+		return
+	}
+
+	runKey := ""
+	if ack.Key != "" {
+		runKey = strconv.Itoa(uid) + "/" + ack.Key
+		prev, first := app.quickActions.claim(runKey, ack, time.Now())
+		if !first {
+			if prev.Stack != ack.Stack || prev.Action != ack.Action {
+				reject(quickCodeKeyConflict, "Key already used for "+prev.Action+" of "+prev.Stack)
+				return
+			}
+			prev.Duplicate = true
+			reply(prev)
+			return
+		}
+	}
+	reply(ack)
+
+	go func() {
+		err := app.runQuickAction(ack.Stack, ack.Action)
+		if runKey != "" {
+			app.quickActions.finish(runKey, err, time.Now())
+		}
+	}()
+}
+
+// runQuickAction runs a quick action under the stack lock, the same way
+// its dedicated handler does.
+func (app *App) runQuickAction(stackName, action string) error {
+	app.StackLocks.Lock(stackName)
+	defer app.StackLocks.Unlock(stackName)
+
+	if action == "update" {
+		return app.runStackUpdate(stackName)
+	}
+	if app.isStackManaged(stackName) {
+		switch action {
+		case "start":
+			return app.runComposeAction(stackName, "up", app.upArgs(stackName, "")...)
+		case "pause":
+			return app.runComposeAction(stackName, "pause", "pause")
+		case "resume":
+			return app.runComposeAction(stackName, "unpause", "unpause")
+		}
+		return app.runComposeAction(stackName, action, action)
+	}
+	if action == "resume" {
+		return app.runUnmanagedStackAction(stackName, "unpause", "unpause")
+	}
+	return app.runUnmanagedStackAction(stackName, action, action)
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestQuickActionState(t *testing.T) {
+	t.Parallel()
+	var s quickActionState
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	ack := quickActionAck{OK: true, Stack: "web", Action: "restart", Key: "k1", Status: quickActionRunning}
+
+	if _, first := s.claim("1/k1", ack, now); !first {
+		t.Fatal("first claim not first")
+	}
+	prev, first := s.claim("1/k1", ack, now)
+	if first || prev.Status != quickActionRunning {
+		t.Fatalf("retry while running = %+v, %v", prev, first)
+	}
+	if _, first := s.claim("2/k1", ack, now); !first {
+		t.Error("keys of other users collide")
+	}
+
+	s.finish("1/k1", errors.New("exit status 1"), now)
+	prev, _ = s.claim("1/k1", ack, now.Add(time.Minute))
+	if prev.OK || prev.Status != quickActionFailed || prev.Msg != "exit status 1" {
+		t.Errorf("retry after failure = %+v", prev)
+	}
+
+	s.finish("2/k1", nil, now)
+	prev, _ = s.claim("2/k1", ack, now.Add(time.Minute))
+	if !prev.OK || prev.Status != quickActionDone {
+		t.Errorf("retry after success = %+v", prev)
+	}
+
+	// Finished keys are forgotten after the TTL
+	if _, first := s.claim("1/k1", ack, now.Add(quickActionKeyTTL+time.Second)); !first {
+		t.Error("expired key still claimed")
+	}
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/docker"
+	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/notify"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+const (
+	// hostSnapshotInterval is how often the running containers are
+	// recorded. There's no shutdown hook to rely on after a power loss.
+	hostSnapshotInterval = time.Minute
+
+	// rebootSettleDelay gives restart policies and autostarts time to bring
+	// containers back before they are compared with the snapshot.
+	rebootSettleDelay = 3 * time.Minute
+)
+
+// bootIDPath holds an ID the kernel picks at each boot; containers share
+// the host's.
+const bootIDPath = "/proc/sys/kernel/random/boot_id"
+
+// RegisterRebootReportHandlers registers the reboot report handler.
+func RegisterRebootReportHandlers(app *App) {
+	app.WS.Handle("getRebootReport", app.handleGetRebootReport)
+}
+
+// StartRebootReport compares the containers running before Dockge started,
+// as of the last snapshot, with the ones running once the host settled, and
+// notifies of stacks that didn't come back after a reboot. Then it keeps
+// the snapshot up to date for the next start.
+func (app *App) StartRebootReport(ctx context.Context) {
+	if app.HostState == nil {
+		return
+	}
+	before, err := app.HostState.Snapshot()
+	if err != nil {
+		slog.Warn("reboot report", "err", err)
+	}
+	app.rebootPending.Store(before != nil)
+	go func() {
+		if before != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(rebootSettleDelay):
+			}
+			app.checkReboot(ctx, before)
+			app.rebootPending.Store(false)
+		}
+		ticker := time.NewTicker(hostSnapshotInterval)
+		defer ticker.Stop()
+		for {
+			app.takeHostSnapshot(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// takeHostSnapshot records the running containers.
+func (app *App) takeHostSnapshot(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	containers, err := app.Docker.ContainerList(ctx, true, "")
+	if err != nil {
+		slog.Debug("host snapshot", "err", err)
+		return
+	}
+	snap := &models.ContainerSnapshot{BootID: bootID(), TakenAt: time.Now().Unix(), Containers: []models.SnapshotContainer{}}
+	for _, c := range containers {
+		if c.State != "running" {
+			continue
+		}
+		snap.Containers = append(snap.Containers, models.SnapshotContainer{Name: c.Name, Stack: c.Project, Service: c.Service, Health: c.Health})
+	}
+	if err := app.HostState.SetSnapshot(snap); err != nil {
+		slog.Warn("host snapshot", "err", err)
+	}
+}
+
+// checkReboot builds, stores and notifies the reboot report.
+func (app *App) checkReboot(ctx context.Context, before *models.ContainerSnapshot) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	containers, err := app.Docker.ContainerList(ctx, true, "")
+	if err != nil {
+		slog.Warn("reboot report", "err", err)
+		return
+	}
+	report := compareHostSnapshot(before, containers)
+	id := bootID()
+	report.Rebooted = before.BootID != "" && id != "" && before.BootID != id
+	if err := app.HostState.SetRebootReport(report); err != nil {
+		slog.Warn("reboot report", "err", err)
+	}
+	slog.Info("reboot report", "rebooted", report.Rebooted, "stacks", len(report.Stacks), "failed", report.Failed, "containers", len(report.Containers))
+	if report.Rebooted || report.Failed > 0 || len(report.Containers) > 0 {
+		app.notifyRebootReport(report)
+	}
+}
+
+// compareHostSnapshot reports which stacks and standalone containers running
+// in before aren't running again in containers.
+func compareHostSnapshot(before *models.ContainerSnapshot, containers []docker.Container) *models.RebootReport {
+	report := &models.RebootReport{SnapshotAt: before.TakenAt, CheckedAt: time.Now().Unix(), Stacks: []models.StackRecovery{}, Containers: []string{}}
+
+	// Health of the services running now by stack, and standalone names
+	running := map[string]map[string]string{}
+	standalone := map[string]bool{}
+	for _, c := range containers {
+		if c.State != "running" {
+			continue
+		}
+		if c.Project == "" || c.Service == "" {
+			standalone[c.Name] = true
+			continue
+		}
+		if running[c.Project] == nil {
+			running[c.Project] = map[string]string{}
+		}
+		if h := running[c.Project][c.Service]; h != "unhealthy" {
+			running[c.Project][c.Service] = c.Health
+		}
+	}
+
+	stacks := map[string][]string{}
+	for _, c := range before.Containers {
+		if c.Stack == "" || c.Service == "" {
+			if !standalone[c.Name] {
+				report.Containers = append(report.Containers, c.Name)
+			}
+			continue
+		}
+		stacks[c.Stack] = append(stacks[c.Stack], c.Service)
+	}
+	for name, services := range stacks {
+		sort.Strings(services)
+		rec := models.StackRecovery{Stack: name, Status: "up"}
+		for i, svc := range services {
+			if i > 0 && svc == services[i-1] {
+				continue
+			}
+			health, ok := running[name][svc]
+			switch {
+			case !ok:
+				rec.Missing = append(rec.Missing, svc)
+			case health == "unhealthy":
+				rec.Unhealthy = append(rec.Unhealthy, svc)
+			}
+		}
+		if len(rec.Missing) > 0 || len(rec.Unhealthy) > 0 {
+			rec.Status = "partial"
+			if len(running[name]) == 0 {
+				rec.Status = "down"
+			}
+			report.Failed++
+		}
+		report.Stacks = append(report.Stacks, rec)
+	}
+	sort.Slice(report.Stacks, func(i, j int) bool { return report.Stacks[i].Stack < report.Stacks[j].Stack })
+	sort.Strings(report.Containers)
+	return report
+}
+
+// notifyRebootReport notifies of the stacks and containers that didn't
+// come back.
+func (app *App) notifyRebootReport(report *models.RebootReport) {
+	what := "Dockge restarted"
+	if report.Rebooted {
+		what = "Host rebooted"
+	}
+	e := notify.Event{Kind: notify.KindHostReboot, Title: what + ": all stacks came back"}
+	if n := report.Failed + len(report.Containers); n > 0 {
+		e.Title = fmt.Sprintf("%s: %d of %d stacks and containers didn't come back", what, n, len(report.Stacks)+len(report.Containers))
+	}
+	var lines []string
+	for _, rec := range report.Stacks {
+		if rec.Status == "up" {
+			continue
+		}
+		line := rec.Stack + ": " + rec.Status
+		if len(rec.Missing) > 0 {
+			line += ", not running: " + strings.Join(rec.Missing, ", ")
+		}
+		if len(rec.Unhealthy) > 0 {
+			line += ", unhealthy: " + strings.Join(rec.Unhealthy, ", ")
+		}
+		lines = append(lines, line)
+	}
+	for _, name := range report.Containers {
+		lines = append(lines, name+": not running")
+	}
+	e.Message = strings.Join(lines, "\n")
+	app.notifier.Notify(e)
+}
+
+// bootID returns the kernel's boot ID, "" where there is none.
+func bootID() string {
+	data, err := os.ReadFile(bootIDPath)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// handleGetRebootReport returns the last reboot report (null if there is
+// none), and whether the one of this start is still being waited for.
+func (app *App) handleGetRebootReport(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	var report *models.RebootReport
+	if app.HostState != nil {
+		var err error
+		if report, err = app.HostState.RebootReport(); err != nil {
+			slog.Warn("get reboot report", "err", err)
+		}
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK      bool                 `json:"ok"`
+			Report  *models.RebootReport `json:"report"`
+			Pending bool                 `json:"pending"`
+		}{OK: true, Report: report, Pending: app.rebootPending.Load()})
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cfilipov/dockge/internal/docker"
+	"github.com/cfilipov/dockge/internal/models"
+)
+
+func TestCompareHostSnapshot(t *testing.T) {
+	t.Parallel()
+	before := &models.ContainerSnapshot{TakenAt: 100, Containers: []models.SnapshotContainer{
+		{Name: "web-app-1", Stack: "web", Service: "app"},
+		{Name: "web-db-1", Stack: "web", Service: "db"},
+		{Name: "blog-wp-1", Stack: "blog", Service: "wp"},
+		{Name: "blog-wp-2", Stack: "blog", Service: "wp"},
+		{Name: "media-plex-1", Stack: "media", Service: "plex"},
+		{Name: "auth-api-1", Stack: "auth", Service: "api"},
+		{Name: "portainer"},
+		{Name: "watchtower"},
+	}}
+	containers := []docker.Container{
+		{Name: "web-app-1", Project: "web", Service: "app", State: "running"},
+		{Name: "web-db-1", Project: "web", Service: "db", State: "exited"},
+		{Name: "blog-wp-1", Project: "blog", Service: "wp", State: "running"},
+		{Name: "media-plex-1", Project: "media", Service: "plex", State: "exited"},
+		{Name: "auth-api-1", Project: "auth", Service: "api", State: "running", Health: "unhealthy"},
+		{Name: "portainer", State: "running"},
+	}
+
+	report := compareHostSnapshot(before, containers)
+	want := []models.StackRecovery{
+		{Stack: "auth", Status: "partial", Unhealthy: []string{"api"}},
+		{Stack: "blog", Status: "up"},
+		{Stack: "media", Status: "down", Missing: []string{"plex"}},
+		{Stack: "web", Status: "partial", Missing: []string{"db"}},
+	}
+	if !reflect.DeepEqual(report.Stacks, want) {
+		t.Errorf("Stacks = %+v, want %+v", report.Stacks, want)
+	}
+	if report.Failed != 3 || report.SnapshotAt != 100 {
+		t.Errorf("Failed = %d, SnapshotAt = %d", report.Failed, report.SnapshotAt)
+	}
+	if !reflect.DeepEqual(report.Containers, []string{"watchtower"}) {
+		t.Errorf("Containers = %v", report.Containers)
+	}
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"maps"
+	"sync"
+)
+
+// replayCache keeps the latest state of each resource broadcast channel so a
+// freshly authenticated connection can be sent data immediately, instead of
+// waiting for its own Docker queries. Full broadcasts replace a channel's
+// state; partial broadcasts merge into it (nil items are deletions).
+// The zero value is ready to use.
+type replayCache struct {
+	mu       sync.Mutex
+	channels map[string]map[string]any
+	version  uint64 // bumped on every change
+}
+
+// replace records a full-state broadcast of a channel.
+func (r *replayCache) replace(channel string, items map[string]any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.channels == nil {
+		r.channels = make(map[string]map[string]any)
+	}
+	r.version++
+	state := make(map[string]any, len(items))
+	for k, v := range items {
+		if v != nil {
+			state[k] = v
+		}
+	}
+	r.channels[channel] = state
+}
+
+// merge applies a partial broadcast. Channels without a full state yet are
+// left alone: a partial update alone isn't worth replaying.
+func (r *replayCache) merge(channel string, items map[string]any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state, ok := r.channels[channel]
+	if !ok {
+		return
+	}
+	r.version++
+	for k, v := range items {
+		if v == nil {
+			delete(state, k)
+		} else {
+			state[k] = v
+		}
+	}
+}
+
+// invalidate drops the state of channels that may have missed changes.
+func (r *replayCache) invalidate(channels ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.version++
+	for _, ch := range channels {
+		delete(r.channels, ch)
+	}
+}
+
+// has reports whether a channel's state is cached.
+func (r *replayCache) has(channel string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.channels[channel]
+	return ok
+}
+
+// currentVersion returns a counter that changes whenever the cached state does.
+func (r *replayCache) currentVersion() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.version
+}
+
+// snapshot returns a copy of every cached channel's state.
+func (r *replayCache) snapshot() map[string]map[string]any {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make(map[string]map[string]any, len(r.channels))
+	for ch, state := range r.channels {
+		out[ch] = maps.Clone(state)
+	}
+	return out
+}
+
+// withRemovals marks keys a connection was sent from the replay cache that
+// are missing from fresh as deleted, so the client's merge drops them.
+func withRemovals(fresh, replayed map[string]any) map[string]any {
+	for k := range replayed {
+		if _, ok := fresh[k]; !ok {
+			fresh[k] = nil
+		}
+	}
+	return fresh
+}
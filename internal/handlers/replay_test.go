@@ -0,0 +1,45 @@
+package handlers
+
+import "testing"
+
+func TestReplayCache(t *testing.T) {
+	t.Parallel()
+	var r replayCache
+
+	// Partial updates before any full state are not cached
+	r.merge(chanContainers, map[string]any{"web": 1})
+	if len(r.snapshot()) != 0 {
+		t.Fatal("partial update alone should not be cached")
+	}
+
+	r.replace(chanContainers, map[string]any{"web": 1, "db": 2, "gone": nil})
+	r.merge(chanContainers, map[string]any{"web": 3, "db": nil, "cache": 4})
+	got := r.snapshot()[chanContainers]
+	if len(got) != 2 || got["web"] != 3 || got["cache"] != 4 {
+		t.Errorf("merged state = %v", got)
+	}
+
+	// Snapshots are copies
+	got["web"] = 99
+	if r.snapshot()[chanContainers]["web"] != 3 {
+		t.Error("snapshot should not alias cached state")
+	}
+
+	r.replace(chanStacks, map[string]any{"app": 1})
+	r.invalidate(chanContainers)
+	snap := r.snapshot()
+	if _, ok := snap[chanContainers]; ok || len(snap[chanStacks]) != 1 {
+		t.Errorf("after invalidate: %v", snap)
+	}
+}
+
+func TestWithRemovals(t *testing.T) {
+	t.Parallel()
+	fresh := withRemovals(map[string]any{"web": 1}, map[string]any{"web": 1, "old": 2})
+	if v, ok := fresh["old"]; !ok || v != nil {
+		t.Errorf("expected old marked deleted, got %v", fresh)
+	}
+	if fresh["web"] != 1 {
+		t.Errorf("fresh item changed: %v", fresh)
+	}
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/compose"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+	"gopkg.in/yaml.v3"
+)
+
+// handleGetResolvedCompose returns what a deploy would use: the stack's
+// saved compose and override files merged, interpolated with its env files
+// and filtered by profile, as compose renders it, plus compose's warnings.
+// Secrets are resolved as for a deploy, but shown as their placeholders, and
+// secret variables are masked for users masksEnvSecrets applies to.
+// Args: (stackName, profiles []string; "*" = all profiles).
+func (app *App) handleGetResolvedCompose(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	args := parseArgs(msg)
+	stackName := argString(args, 0)
+	if err := stack.ValidateStackName(stackName); err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	var profiles []string
+	argObject(args, 1, &profiles)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	// Rendered with the env files deploys use, then with the secrets they
+	// resolve put back as placeholders
+	envArgs, cleanup, resolvedSecrets, err := app.resolveComposeEnv(ctx, stackName)
+	if err != nil {
+		slog.Debug("resolved compose secrets", "stack", stackName, "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	defer cleanup()
+	rendered, err := compose.RenderConfig(ctx, app.StacksDir, stackName, envArgs, profiles)
+	if err != nil {
+		slog.Debug("resolved compose", "stack", stackName, "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	rendered.YAML, err = maskRenderedEnv(rendered.YAML, resolvedSecrets, app.masksEnvSecrets(c))
+	if err != nil {
+		slog.Debug("resolved compose mask", "stack", stackName, "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK bool `json:"ok"`
+			*compose.RenderedConfig
+		}{true, rendered})
+	}
+}
+
+// maskRenderedEnv rewrites the services' environment values in rendered
+// compose YAML: a value resolved from a secret shows its placeholder again,
+// and with maskSecrets, a secret variable shows SecretMask. Nothing outside
+// environment is touched, so a short or common value can't be replaced
+// where it happens to appear elsewhere.
+func maskRenderedEnv(text string, resolvedSecrets map[string]string, maskSecrets bool) (string, error) {
+	if len(resolvedSecrets) == 0 && !maskSecrets {
+		return text, nil
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(text), &doc); err != nil {
+		return "", err
+	}
+	mask := func(key, value string) string {
+		if placeholder, ok := resolvedSecrets[value]; ok {
+			value = placeholder
+		}
+		if maskSecrets && value != "" && stack.IsSecretKey(key) {
+			value = stack.SecretMask
+		}
+		return value
+	}
+	changed := false
+	for _, service := range mappingValues(mappingValue(documentRoot(&doc), "services")) {
+		env := mappingValue(service, "environment")
+		if env == nil {
+			continue
+		}
+		switch env.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(env.Content); i += 2 {
+				value := env.Content[i+1]
+				if value.Kind != yaml.ScalarNode {
+					continue
+				}
+				if masked := mask(env.Content[i].Value, value.Value); masked != value.Value {
+					value.Value = masked
+					changed = true
+				}
+			}
+		case yaml.SequenceNode:
+			for _, item := range env.Content {
+				key, value, ok := strings.Cut(item.Value, "=")
+				if item.Kind != yaml.ScalarNode || !ok {
+					continue
+				}
+				if masked := mask(key, value); masked != value {
+					item.Value = key + "=" + masked
+					changed = true
+				}
+			}
+		}
+	}
+	if !changed {
+		return text, nil
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// documentRoot returns the top-level node of a parsed YAML document.
+func documentRoot(doc *yaml.Node) *yaml.Node {
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		return doc.Content[0]
+	}
+	return doc
+}
+
+// mappingValue returns the value of key in a mapping node, or nil.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// mappingValues returns the values of a mapping node, in order.
+func mappingValues(node *yaml.Node) []*yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	values := make([]*yaml.Node, 0, len(node.Content)/2)
+	for i := 1; i < len(node.Content); i += 2 {
+		values = append(values, node.Content[i])
+	}
+	return values
+}
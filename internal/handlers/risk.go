@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/cfilipov/dockge/internal/compose"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// composeRiskFinding is a compose.RiskFinding with the file it's in.
+type composeRiskFinding struct {
+	File string `json:"file"` // "compose" or "override"
+	compose.RiskFinding
+}
+
+// stackRiskReport is the compose.RiskReport of a stack's compose and
+// override files together.
+type stackRiskReport struct {
+	Level    string               `json:"level"`
+	Blocking bool                 `json:"blocking"` // needs accepting before deploy
+	Findings []composeRiskFinding `json:"findings"`
+}
+
+// analyzeStackRisks reports the risky settings of a stack's compose file
+// and, if not empty, its override file, with variables substituted by
+// lookup.
+func analyzeStackRisks(composeYAML, overrideYAML string, lookup func(string) (string, bool)) stackRiskReport {
+	report := stackRiskReport{Findings: []composeRiskFinding{}}
+	levels := make(map[string]bool)
+	add := func(file, yaml string) {
+		r := compose.AnalyzeRisks(yaml, lookup)
+		for _, f := range r.Findings {
+			report.Findings = append(report.Findings, composeRiskFinding{File: file, RiskFinding: f})
+		}
+		report.Blocking = report.Blocking || r.Blocking()
+		levels[r.Level] = true
+	}
+	add("compose", composeYAML)
+	if overrideYAML != "" {
+		add("override", overrideYAML)
+	}
+	for _, level := range []string{compose.RiskCritical, compose.RiskHigh, compose.RiskMedium} {
+		if levels[level] {
+			report.Level = level
+			break
+		}
+	}
+	return report
+}
+
+// rejectUnacceptedRisks acks with the risk report and returns true if the
+// files of a new stack give its containers control of the host and the
+// user hasn't accepted that. Stacks that exist were reviewed when they
+// were added, so editing them isn't held up.
+func (app *App) rejectUnacceptedRisks(c *ws.Conn, msg *ws.ClientMessage, stackName, composeYAML, composeENV, overrideYAML string, accepted bool) bool {
+	if accepted {
+		return false
+	}
+	if _, err := os.Stat(filepath.Join(app.StacksDir, stackName)); !errors.Is(err, os.ErrNotExist) {
+		return false
+	}
+	report := analyzeStackRisks(composeYAML, overrideYAML, app.stackEnvLookup(composeENV, false))
+	if !report.Blocking {
+		return false
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK         bool            `json:"ok"`
+			Msg        string          `json:"msg"`
+			MsgI18n    bool            `json:"msgi18n"`
+			RiskReport stackRiskReport `json:"riskReport"`
+		}{OK: false, Msg: "composeRisky", MsgI18n: true, RiskReport: report})
+	}
+	return true
+}
+
+// handleAnalyzeComposeRisks statically inspects compose files, e.g. pasted
+// from the internet, for settings that give containers control of the
+// host, without saving or deploying anything. Variables come from the
+// .env passed and global.env. Args: (yaml, overrideYaml?, env?)
+func (app *App) handleAnalyzeComposeRisks(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	args := parseArgs(msg)
+	report := analyzeStackRisks(argString(args, 0), argString(args, 1), app.stackEnvLookup(argString(args, 2), false))
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK         bool            `json:"ok"`
+			RiskReport stackRiskReport `json:"riskReport"`
+		}{OK: true, RiskReport: report})
+	}
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/cfilipov/dockge/internal/compose"
+)
+
+func TestAnalyzeStackRisks(t *testing.T) {
+	t.Parallel()
+	report := analyzeStackRisks(
+		"services:\n  app:\n    image: app\n    devices:\n      - /dev/fuse\n",
+		"services:\n  app:\n    network_mode: host\n",
+		nil,
+	)
+	if report.Level != compose.RiskHigh || !report.Blocking || len(report.Findings) != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+	if report.Findings[0].File != "compose" || report.Findings[1].File != "override" || report.Findings[1].Check != compose.RiskHostNetwork {
+		t.Errorf("findings = %+v", report.Findings)
+	}
+
+	safe := analyzeStackRisks("services:\n  app:\n    image: app\n", "", nil)
+	if safe.Level != "" || safe.Blocking || len(safe.Findings) != 0 {
+		t.Errorf("safe report = %+v", safe)
+	}
+
+	// A .env fetched with the compose file decides what its variables are
+	app := &App{StacksDir: t.TempDir()}
+	env := analyzeStackRisks("services:\n  app:\n    image: app\n    privileged: ${PRIV}\n", "", app.stackEnvLookup("PRIV=True\n", false))
+	if len(env.Findings) != 1 || env.Findings[0].Check != compose.RiskPrivileged {
+		t.Errorf("env report = %+v", env)
+	}
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/compose"
+	"github.com/cfilipov/dockge/internal/docker"
+	"github.com/cfilipov/dockge/internal/terminal"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// daemonState caches the daemon info, which doesn't change while the
+// daemon runs. A failed lookup isn't cached so it's retried next time.
+type daemonState struct {
+	mu      sync.Mutex
+	info    docker.DaemonInfo
+	fetched bool
+}
+
+// daemonInfo returns the cached daemon info, asking the daemon on first use.
+func (app *App) daemonInfo(ctx context.Context) (docker.DaemonInfo, error) {
+	app.daemon.mu.Lock()
+	defer app.daemon.mu.Unlock()
+	if app.daemon.fetched {
+		return app.daemon.info, nil
+	}
+	info, err := app.Docker.Info(ctx)
+	if err != nil {
+		return docker.DaemonInfo{}, err
+	}
+	app.daemon.info = info
+	app.daemon.fetched = true
+	if info.Rootless {
+		slog.Info("docker daemon is rootless", "cgroupDriver", info.CgroupDriver, "cgroupVersion", info.CgroupVersion)
+	}
+	return info, nil
+}
+
+// statsSupported reports whether container usage can be sampled. If the
+// daemon can't be asked, stats are assumed to work and fail per container.
+func (app *App) statsSupported(ctx context.Context) bool {
+	info, err := app.daemonInfo(ctx)
+	return err != nil || info.StatsSupported()
+}
+
+// rootlessGuidance explains what works differently on a rootless daemon.
+func rootlessGuidance(info docker.DaemonInfo, portStart int) []string {
+	if !info.Rootless {
+		return []string{}
+	}
+	guidance := []string{}
+	if portStart > 0 {
+		guidance = append(guidance, fmt.Sprintf("Ports below %d can't be published. Set net.ipv4.ip_unprivileged_port_start to allow lower ports.", portStart))
+	}
+	if !info.StatsSupported() {
+		guidance = append(guidance, "Containers run without cgroups, so CPU and memory usage, budgets and usage history are unavailable. Enable cgroup v2 delegation for the daemon's user to restore them.")
+	} else if info.CgroupVersion == "1" {
+		guidance = append(guidance, "Resource limits are not applied on cgroup v1. Switch the host to cgroup v2 to enforce them.")
+	}
+	guidance = append(guidance, "Bind mounts are owned by the daemon's user mapping; files created by containers may not be writable from the host.")
+	return guidance
+}
+
+// handleGetDaemonInfo reports the daemon version and whether it's rootless,
+// with guidance for the features that behave differently there.
+func (app *App) handleGetDaemonInfo(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	info, err := app.daemonInfo(ctx)
+	if err != nil {
+		slog.Warn("get daemon info", "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	portStart := 0
+	if info.Rootless {
+		portStart = compose.UnprivilegedPortStart()
+	}
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK                    bool              `json:"ok"`
+			Info                  docker.DaemonInfo `json:"info"`
+			StatsSupported        bool              `json:"statsSupported"`
+			UnprivilegedPortStart int               `json:"unprivilegedPortStart,omitempty"` // rootless only
+			Guidance              []string          `json:"guidance"`
+		}{
+			OK:                    true,
+			Info:                  info,
+			StatsSupported:        info.StatsSupported(),
+			UnprivilegedPortStart: portStart,
+			Guidance:              rootlessGuidance(info, portStart),
+		})
+	}
+}
+
+// checkPrivilegedPorts warns about published ports a rootless daemon can't
+// bind. The port start is read from Dockge's own view of the kernel, which
+// may differ from the daemon's, so this never blocks the deploy.
+func (app *App) checkPrivilegedPorts(ctx context.Context, term *terminal.Terminal, project *compose.ResolvedProject) {
+	info, err := app.daemonInfo(ctx)
+	if err != nil || !info.Rootless {
+		return
+	}
+	for _, p := range project.CheckPrivilegedPorts(compose.UnprivilegedPortStart()) {
+		term.Write([]byte("\r\n[Warning] " + p + "\r\n"))
+	}
+}
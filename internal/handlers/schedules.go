@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/cfilipov/dockge/internal/compose"
+	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/scheduler"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// RegisterScheduleHandlers registers the stack schedule handlers and
+// creates the scheduler. It runs once StartScheduler is called.
+func RegisterScheduleHandlers(app *App) {
+	if app.Schedules != nil {
+		app.scheduler = scheduler.New(app.Schedules, app.runScheduledAction, app.broadcastSchedules)
+	}
+	app.WS.Handle("getStackSchedules", app.handleGetStackSchedules)
+	app.WS.Handle("saveStackSchedule", app.handleSaveStackSchedule)
+	app.WS.Handle("deleteStackSchedule", app.handleDeleteStackSchedule)
+}
+
+// StartScheduler runs due stack schedules at every minute boundary.
+func (app *App) StartScheduler(ctx context.Context) {
+	if app.scheduler != nil {
+		app.scheduler.Start(ctx)
+	}
+}
+
+// runScheduledAction runs a schedule's compose action under the stack lock.
+func (app *App) runScheduledAction(sch models.StackSchedule) error {
+	if compose.FindComposeFile(app.StacksDir, sch.StackName) == "" {
+		return errors.New("stack has no compose file")
+	}
+	switch sch.Action {
+	case scheduler.ActionRestart, scheduler.ActionUpdate, scheduler.ActionStart:
+		if app.stackArchive(sch.StackName) != nil {
+			return errors.New("stack is archived")
+		}
+	}
+
+	app.StackLocks.Lock(sch.StackName)
+	defer app.StackLocks.Unlock(sch.StackName)
+	switch sch.Action {
+	case scheduler.ActionRestart:
+		return app.runComposeAction(sch.StackName, "restart", "restart")
+	case scheduler.ActionUpdate:
+		return app.runStackUpdate(sch.StackName)
+	case scheduler.ActionStart:
+		return app.runComposeAction(sch.StackName, "up", app.upArgs(sch.StackName, "")...)
+	case scheduler.ActionStop:
+		return app.runComposeAction(sch.StackName, "stop", "stop")
+	case scheduler.ActionDown:
+		return app.runComposeAction(sch.StackName, "down", "down")
+	}
+	return errors.New("unknown action " + sch.Action)
+}
+
+// scheduleStatuses returns all schedules with their run state.
+func (app *App) scheduleStatuses() ([]scheduler.Status, error) {
+	if app.scheduler == nil {
+		return []scheduler.Status{}, nil
+	}
+	return app.scheduler.Statuses()
+}
+
+// broadcastSchedules sends every schedule with its last and next run to
+// all authenticated clients.
+func (app *App) broadcastSchedules() {
+	statuses, err := app.scheduleStatuses()
+	if err != nil {
+		slog.Warn("broadcast schedules", "err", err)
+		return
+	}
+	ws.BroadcastAuthenticated(app.WS, "stackSchedules", statuses)
+}
+
+// deleteStackSchedules drops the schedules of a deleted stack.
+func (app *App) deleteStackSchedules(stackName string) {
+	if app.Schedules == nil {
+		return
+	}
+	if err := app.Schedules.DeleteForStack(stackName); err != nil {
+		slog.Warn("delete stack schedules", "err", err, "stack", stackName)
+		return
+	}
+	app.broadcastSchedules()
+}
+
+func (app *App) handleGetStackSchedules(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	statuses, err := app.scheduleStatuses()
+	if err != nil {
+		slog.Error("list stack schedules", "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK        bool               `json:"ok"`
+			Schedules []scheduler.Status `json:"schedules"`
+		}{OK: true, Schedules: statuses})
+	}
+}
+
+// handleSaveStackSchedule creates a schedule, or updates one when the id is
+// set. Args: {id, stackName, action, cron, enabled}.
+func (app *App) handleSaveStackSchedule(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	fail := func(text string) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: text})
+		}
+	}
+	if app.Schedules == nil {
+		fail("Schedules are not available")
+		return
+	}
+
+	var data models.StackSchedule
+	if !argObject(parseArgs(msg), 0, &data) {
+		fail("Invalid schedule")
+		return
+	}
+	if err := stack.ValidateStackName(data.StackName); err != nil {
+		fail(err.Error())
+		return
+	}
+	if compose.FindComposeFile(app.StacksDir, data.StackName) == "" {
+		fail("Only stacks with a compose file can be scheduled")
+		return
+	}
+	if !scheduler.ValidAction(data.Action) {
+		fail("Invalid action")
+		return
+	}
+	if _, err := scheduler.Parse(data.Cron); err != nil {
+		fail("Invalid cron expression: " + err.Error())
+		return
+	}
+
+	var err error
+	if data.ID == 0 {
+		sch := &models.StackSchedule{StackName: data.StackName, Action: data.Action, Cron: data.Cron, Enabled: data.Enabled}
+		if user := app.currentUser(c); user != nil {
+			sch.CreatedBy = user.Username
+		}
+		err = app.Schedules.Create(sch)
+	} else {
+		var sch *models.StackSchedule
+		sch, err = app.Schedules.Get(data.ID)
+		if err == nil && sch == nil {
+			fail("Schedule not found")
+			return
+		}
+		if err == nil {
+			sch.StackName, sch.Action, sch.Cron, sch.Enabled = data.StackName, data.Action, data.Cron, data.Enabled
+			err = app.Schedules.Update(sch)
+		}
+	}
+	if err != nil {
+		slog.Error("save stack schedule", "err", err, "stack", data.StackName)
+		fail("Internal error")
+		return
+	}
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Saved"})
+	}
+	app.broadcastSchedules()
+}
+
+// handleDeleteStackSchedule removes a schedule. Args: schedule id.
+func (app *App) handleDeleteStackSchedule(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	if app.Schedules == nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Schedules are not available"})
+		}
+		return
+	}
+	id := argInt(parseArgs(msg), 0)
+	if err := app.Schedules.Delete(id); err != nil {
+		slog.Error("delete stack schedule", "err", err, "id", id)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Deleted"})
+	}
+	app.broadcastSchedules()
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cfilipov/dockge/internal/compose"
+	"github.com/cfilipov/dockge/internal/stack"
+)
+
+// composeEnvArgs is compose.GlobalEnvArgs for commands that create
+// containers. An env file (global.env or the stack's .env) that is
+// SOPS-encrypted or holds secret placeholders is resolved into a transient
+// file only Dockge can read, outside the stacks directory, and passed in
+// its place. cleanup removes the transient files once compose is done; it
+// is never nil.
+func (app *App) composeEnvArgs(ctx context.Context, stackName string) (args []string, cleanup func(), err error) {
+	args, cleanup, _, err = app.resolveComposeEnv(ctx, stackName)
+	return args, cleanup, err
+}
+
+// resolveComposeEnv is composeEnvArgs, and also returns each secret value
+// it resolved mapped to the placeholder or ciphertext it replaced.
+func (app *App) resolveComposeEnv(ctx context.Context, stackName string) (args []string, cleanup func(), resolvedSecrets map[string]string, err error) {
+	noop := func() {}
+	if app.Secrets == nil {
+		return compose.GlobalEnvArgs(app.StacksDir, stackName), noop, nil, nil
+	}
+
+	args = compose.FileArgs(app.StacksDir, stackName)
+	var transient []string
+	cleanup = func() {
+		for _, path := range transient {
+			os.Remove(path)
+		}
+	}
+	resolvedSecrets = make(map[string]string)
+	for _, f := range []struct{ path, arg string }{
+		{filepath.Join(app.StacksDir, "global.env"), "../global.env"},
+		{filepath.Join(app.StacksDir, stackName, ".env"), "./.env"},
+	} {
+		if _, err := os.Stat(f.path); err != nil {
+			continue
+		}
+		data, changed, err := app.Secrets.ResolveFile(ctx, f.path)
+		if err != nil {
+			cleanup()
+			return nil, noop, nil, fmt.Errorf("resolve secrets of %s: %w", filepath.Base(f.path), err)
+		}
+		if !changed {
+			args = append(args, "--env-file", f.arg)
+			continue
+		}
+		path, err := writeTransientEnv(data)
+		if err != nil {
+			cleanup()
+			return nil, noop, nil, err
+		}
+		transient = append(transient, path)
+		args = append(args, "--env-file", path)
+		original, _ := os.ReadFile(f.path)
+		placeholders := stack.DotEnvValues(string(original))
+		for k, v := range stack.DotEnvValues(string(data)) {
+			if v != "" && v != placeholders[k] {
+				resolvedSecrets[v] = placeholders[k]
+			}
+		}
+	}
+	if len(transient) == 0 {
+		// Keep compose's own .env loading when there's no global.env
+		return compose.GlobalEnvArgs(app.StacksDir, stackName), noop, nil, nil
+	}
+	return args, cleanup, resolvedSecrets, nil
+}
+
+// actionEnvArgs returns the --env-file flags of a compose action: with
+// secrets resolved for up, which creates containers, and as they are for
+// actions like stop that don't need them, so an unreachable secret store
+// doesn't keep stacks from being stopped.
+func (app *App) actionEnvArgs(ctx context.Context, stackName string, composeArgs []string) ([]string, func(), error) {
+	if len(composeArgs) > 0 && composeArgs[0] == "up" {
+		return app.composeEnvArgs(ctx, stackName)
+	}
+	return compose.GlobalEnvArgs(app.StacksDir, stackName), func() {}, nil
+}
+
+// writeTransientEnv writes resolved env file contents to a new temporary
+// file with mode 0600.
+func writeTransientEnv(data []byte) (string, error) {
+	f, err := os.CreateTemp("", "dockge-env-*")
+	if err != nil {
+		return "", fmt.Errorf("create resolved env file: %w", err)
+	}
+	if _, err = f.Write(data); err == nil {
+		err = f.Chmod(0o600)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("write resolved env file: %w", err)
+	}
+	return f.Name(), nil
+}
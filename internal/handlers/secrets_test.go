@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/cfilipov/dockge/internal/secrets"
+	"github.com/cfilipov/dockge/internal/stack"
+)
+
+type fakeSecrets map[string]string
+
+func (f fakeSecrets) Resolve(_ context.Context, ref string) (string, error) {
+	if v, ok := f[ref]; ok {
+		return v, nil
+	}
+	return "", errors.New("no secret " + ref)
+}
+
+func TestComposeEnvArgs(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "web"), 0o755)
+	os.MkdirAll(filepath.Join(dir, "db"), 0o755)
+	os.WriteFile(filepath.Join(dir, "global.env"), []byte("TZ=UTC\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "web", ".env"), []byte("PORT=80\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "db", ".env"), []byte("DB_PASSWORD=vault:secret/db#password\n"), 0o644)
+
+	app := &App{
+		StacksDir: dir,
+		Secrets:   &secrets.Resolver{Providers: map[string]secrets.Provider{"vault": fakeSecrets{"secret/db#password": "s3cret"}}},
+	}
+	ctx := context.Background()
+
+	args, cleanup, err := app.composeEnvArgs(ctx, "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleanup()
+	if want := []string{"--env-file", "../global.env", "--env-file", "./.env"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("plain env files: args = %v, want %v", args, want)
+	}
+
+	args, cleanup, err = app.composeEnvArgs(ctx, "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(args) != 4 || args[1] != "../global.env" || !filepath.IsAbs(args[3]) {
+		t.Fatalf("resolved env file: args = %v", args)
+	}
+	resolved := args[3]
+	data, err := os.ReadFile(resolved)
+	if err != nil || string(data) != "DB_PASSWORD='s3cret'\n" {
+		t.Errorf("resolved env file = %q, %v", data, err)
+	}
+	if info, err := os.Stat(resolved); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("resolved env file mode = %v, %v", info.Mode(), err)
+	}
+	cleanup()
+	if _, err := os.Stat(resolved); !os.IsNotExist(err) {
+		t.Errorf("resolved env file not removed: %v", err)
+	}
+
+	// Output rendered with the resolved file shows the placeholder again
+	_, cleanup, resolvedSecrets, err := app.resolveComposeEnv(ctx, "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleanup()
+	rendered := "services:\n  db:\n    command: s3cret\n    environment:\n      PASSWORD: s3cret\n"
+	if got, err := maskRenderedEnv(rendered, resolvedSecrets, false); err != nil || got != "services:\n  db:\n    command: s3cret\n    environment:\n      PASSWORD: vault:secret/db#password\n" {
+		t.Errorf("masked = %q, %v", got, err)
+	}
+
+	// Masked users see secret variables masked, in either environment form
+	rendered = "services:\n  db:\n    environment:\n      DB_PASSWORD: hunter2\n      DB_USER: app\n  web:\n    environment:\n      - API_KEY=abc\n      - PORT=80\n"
+	want := "services:\n  db:\n    environment:\n      DB_PASSWORD: " + stack.SecretMask + "\n      DB_USER: app\n  web:\n    environment:\n      - API_KEY=" + stack.SecretMask + "\n      - PORT=80\n"
+	if got, err := maskRenderedEnv(rendered, nil, true); err != nil || got != want {
+		t.Errorf("masked = %q, %v", got, err)
+	}
+	if got, _ := maskRenderedEnv(rendered, nil, false); got != rendered {
+		t.Errorf("unmasked = %q", got)
+	}
+
+	// Stops don't resolve, so they work while the secret store is down
+	os.WriteFile(filepath.Join(dir, "db", ".env"), []byte("DB_PASSWORD=vault:secret/gone#password\n"), 0o644)
+	if _, _, err := app.composeEnvArgs(ctx, "db"); err == nil {
+		t.Error("expected an error for a missing secret")
+	}
+	if _, _, err := app.actionEnvArgs(ctx, "db", []string{"stop"}); err != nil {
+		t.Errorf("stop: %v", err)
+	}
+}
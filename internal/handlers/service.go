@@ -7,10 +7,12 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode"
 
 	"github.com/cfilipov/dockge/internal/compose"
 	"github.com/cfilipov/dockge/internal/models"
@@ -20,8 +22,20 @@ import (
 )
 
 const (
-	defaultImageUpdateInterval = 6 * time.Hour
-	imageCheckConcurrency      = 3
+	defaultImageUpdateInterval   = 6 * time.Hour
+	defaultImageCheckConcurrency = 3
+	maxImageCheckConcurrency     = 16
+)
+
+// Image update check settings, besides imageUpdateCheckEnabled and
+// imageUpdateCheckInterval.
+const (
+	// settingImageCheckConcurrency is how many stacks are checked against
+	// registries at once.
+	settingImageCheckConcurrency = "imageUpdateCheckConcurrency"
+	// settingImageCheckSkip lists stacks ("web") and services ("web/db"),
+	// separated by commas or whitespace, that scheduled checks skip.
+	settingImageCheckSkip = "imageUpdateCheckSkip"
 )
 
 func RegisterServiceHandlers(app *App) {
@@ -30,31 +44,59 @@ func RegisterServiceHandlers(app *App) {
 	app.WS.Handle("restartService", app.handleRestartService)
 	app.WS.Handle("recreateService", app.handleRecreateService)
 	app.WS.Handle("updateService", app.handleUpdateService)
+	app.WS.Handle("getStackJobs", app.handleGetStackJobs)
+	app.WS.Handle("runJobService", app.handleRunJobService)
 	app.WS.Handle("checkImageUpdates", app.handleCheckImageUpdates)
+	app.WS.Handle("checkUpdatesNow", app.handleCheckUpdatesNow)
 
 	// Standalone container actions (no compose project label)
 	app.WS.Handle("startContainer", app.handleStartContainer)
 	app.WS.Handle("stopContainer", app.handleStopContainer)
 	app.WS.Handle("restartContainer", app.handleRestartContainer)
+
+	// Actions on a single container of a stack or a standalone one, via
+	// the SDK (container_actions.go)
+	app.WS.Handle("killContainer", app.handleKillContainer)
+	app.WS.Handle("pauseContainer", app.handlePauseContainer)
+	app.WS.Handle("unpauseContainer", app.handleUnpauseContainer)
+	app.WS.Handle("removeContainer", app.handleRemoveContainer)
+	app.WS.Handle("renameContainer", app.handleRenameContainer)
 }
 
-func (app *App) handleStartService(c *ws.Conn, msg *ws.ClientMessage) {
-	if checkLogin(c, msg) == 0 {
-		return
-	}
+// serviceActionArgs reads and validates the stack and service name
+// arguments of a per-service action, acking an error if they're invalid.
+func serviceActionArgs(c *ws.Conn, msg *ws.ClientMessage) (stackName, serviceName string, ok bool) {
 	args := parseArgs(msg)
-	stackName := argString(args, 0)
-	serviceName := argString(args, 1)
+	stackName = argString(args, 0)
+	serviceName = argString(args, 1)
 	if stackName == "" || serviceName == "" {
 		if msg.ID != nil {
 			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Stack and service name required"})
 		}
-		return
+		return "", "", false
 	}
-	if err := stack.ValidateStackName(stackName); err != nil {
+	err := stack.ValidateStackName(stackName)
+	if err == nil {
+		err = stack.ValidateServiceName(serviceName)
+	}
+	if err != nil {
 		if msg.ID != nil {
 			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
 		}
+		return "", "", false
+	}
+	return stackName, serviceName, true
+}
+
+func (app *App) handleStartService(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	stackName, serviceName, ok := serviceActionArgs(c, msg)
+	if !ok {
+		return
+	}
+	if app.rejectArchived(c, msg, stackName) {
 		return
 	}
 
@@ -75,19 +117,8 @@ func (app *App) handleStopService(c *ws.Conn, msg *ws.ClientMessage) {
 	if checkLogin(c, msg) == 0 {
 		return
 	}
-	args := parseArgs(msg)
-	stackName := argString(args, 0)
-	serviceName := argString(args, 1)
-	if stackName == "" || serviceName == "" {
-		if msg.ID != nil {
-			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Stack and service name required"})
-		}
-		return
-	}
-	if err := stack.ValidateStackName(stackName); err != nil {
-		if msg.ID != nil {
-			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
-		}
+	stackName, serviceName, ok := serviceActionArgs(c, msg)
+	if !ok {
 		return
 	}
 
@@ -108,19 +139,11 @@ func (app *App) handleRestartService(c *ws.Conn, msg *ws.ClientMessage) {
 	if checkLogin(c, msg) == 0 {
 		return
 	}
-	args := parseArgs(msg)
-	stackName := argString(args, 0)
-	serviceName := argString(args, 1)
-	if stackName == "" || serviceName == "" {
-		if msg.ID != nil {
-			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Stack and service name required"})
-		}
+	stackName, serviceName, ok := serviceActionArgs(c, msg)
+	if !ok {
 		return
 	}
-	if err := stack.ValidateStackName(stackName); err != nil {
-		if msg.ID != nil {
-			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
-		}
+	if app.rejectArchived(c, msg, stackName) {
 		return
 	}
 
@@ -141,26 +164,13 @@ func (app *App) handleRecreateService(c *ws.Conn, msg *ws.ClientMessage) {
 	if checkLogin(c, msg) == 0 {
 		return
 	}
-	args := parseArgs(msg)
-	stackName := argString(args, 0)
-	serviceName := argString(args, 1)
-	if stackName == "" || serviceName == "" {
-		if msg.ID != nil {
-			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Stack and service name required"})
-		}
+	stackName, serviceName, ok := serviceActionArgs(c, msg)
+	if !ok {
 		return
 	}
-	if err := stack.ValidateStackName(stackName); err != nil {
-		if msg.ID != nil {
-			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
-		}
+	if app.rejectArchived(c, msg, stackName) {
 		return
 	}
-
-	if msg.ID != nil {
-		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true})
-	}
-
 	if !app.isStackManaged(stackName) {
 		if msg.ID != nil {
 			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Cannot recreate: stack is not managed by Dockge"})
@@ -168,6 +178,10 @@ func (app *App) handleRecreateService(c *ws.Conn, msg *ws.ClientMessage) {
 		return
 	}
 
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true})
+	}
+
 	go app.runServiceAction(stackName, serviceName, "recreate", "up", "-d", "--force-recreate", serviceName)
 }
 
@@ -175,26 +189,13 @@ func (app *App) handleUpdateService(c *ws.Conn, msg *ws.ClientMessage) {
 	if checkLogin(c, msg) == 0 {
 		return
 	}
-	args := parseArgs(msg)
-	stackName := argString(args, 0)
-	serviceName := argString(args, 1)
-	if stackName == "" || serviceName == "" {
-		if msg.ID != nil {
-			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Stack and service name required"})
-		}
+	stackName, serviceName, ok := serviceActionArgs(c, msg)
+	if !ok {
 		return
 	}
-	if err := stack.ValidateStackName(stackName); err != nil {
-		if msg.ID != nil {
-			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
-		}
+	if app.rejectArchived(c, msg, stackName) {
 		return
 	}
-
-	if msg.ID != nil {
-		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true})
-	}
-
 	if !app.isStackManaged(stackName) {
 		if msg.ID != nil {
 			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Cannot update: stack is not managed by Dockge"})
@@ -202,6 +203,10 @@ func (app *App) handleUpdateService(c *ws.Conn, msg *ws.ClientMessage) {
 		return
 	}
 
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true})
+	}
+
 	go func() {
 		app.runServiceAction(stackName, serviceName, "pull", "pull", serviceName)
 		app.runServiceAction(stackName, serviceName, "up", "up", "-d", "--force-recreate", serviceName)
@@ -213,29 +218,32 @@ func (app *App) handleUpdateService(c *ws.Conn, msg *ws.ClientMessage) {
 	}()
 }
 
-// runServiceAction runs a per-service compose command, streaming output to the
-// stack's compose terminal (same terminal used by stack-level actions).
+// runServiceAction runs a per-service compose command under the stack lock,
+// streaming output to the stack's compose terminal (same terminal used by
+// stack-level actions), and refreshes the project's containers when done.
 // In mock mode, exec.Command resolves to the mock docker binary via PATH.
-func (app *App) runServiceAction(stackName, serviceName, action string, composeArgs ...string) {
+func (app *App) runServiceAction(stackName, serviceName, action string, composeArgs ...string) error {
+	app.StackLocks.Lock(stackName)
+	defer app.StackLocks.Unlock(stackName)
+
 	termName := "compose-" + stackName
-	envArgs := compose.GlobalEnvArgs(app.StacksDir, stackName)
-	displayParts := append(envArgs, composeArgs...)
-	cmdDisplay := fmt.Sprintf("$ docker compose %s\r\n", strings.Join(displayParts, " "))
 
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 	defer cancel()
 
 	term := app.Terms.Recreate(termName, terminal.TypePTY)
-	term.Write([]byte(cmdDisplay))
+	envArgs, cleanup, envErr := app.actionEnvArgs(ctx, stackName, composeArgs)
+	defer cleanup()
+	displayParts := append(envArgs, composeArgs...)
+	term.Write([]byte(fmt.Sprintf("$ docker compose %s\r\n", strings.Join(displayParts, " "))))
 
+	op := app.beginOperation(stackName, action, "service "+serviceName)
 	dir := filepath.Join(app.StacksDir, stackName)
-	cmdArgs := []string{"compose"}
-	cmdArgs = append(cmdArgs, envArgs...)
-	cmdArgs = append(cmdArgs, composeArgs...)
-	cmd := exec.CommandContext(ctx, "docker", cmdArgs...)
-	cmd.Dir = dir
-
-	if err := term.RunPTY(cmd); err != nil {
+	err := envErr
+	if err == nil {
+		err = app.runCompose(ctx, term, stackName, action, dir, envArgs, composeArgs, nil)
+	}
+	if err != nil {
 		if ctx.Err() == nil {
 			errMsg := fmt.Sprintf("\r\n[Error] %s\r\n", err.Error())
 			term.Write([]byte(errMsg))
@@ -244,9 +252,12 @@ func (app *App) runServiceAction(stackName, serviceName, action string, composeA
 	} else {
 		term.Write([]byte("\r\n[Done]\r\n"))
 	}
+	app.endOperation(op, err)
+	app.refreshProjectContainers(stackName)
 
 	// Schedule terminal cleanup after a grace period
 	app.Terms.RemoveAfter(termName, 30*time.Second)
+	return err
 }
 
 // isStackManaged returns true if the stack has a compose file in the stacks directory.
@@ -277,6 +288,7 @@ func (app *App) runContainerActionForStack(stackName, containerName, action stri
 	} else {
 		term.Write([]byte("\r\n[Done]\r\n"))
 	}
+	app.refreshProjectContainers(stackName)
 
 	app.Terms.RemoveAfter(termName, 30*time.Second)
 }
@@ -404,33 +416,129 @@ func (app *App) handleCheckImageUpdates(c *ws.Conn, msg *ws.ClientMessage) {
 	}
 }
 
+// imageCheckProgress is sent to the requesting connection as each service
+// of a checkUpdatesNow is checked.
+type imageCheckProgress struct {
+	StackName string `json:"stackName"`
+	Service   string `json:"service"`
+	Image     string `json:"image"`
+	Done      int    `json:"done"`
+	Total     int    `json:"total"`
+	Failed    bool   `json:"failed"`
+	HasUpdate bool   `json:"hasUpdate"`
+}
+
+// imageCheckResult counts the outcome of checking a stack.
+type imageCheckResult struct {
+	Checked int `json:"checked"`
+	Failed  int `json:"failed"`
+	Updates int `json:"updates"`
+}
+
+// handleCheckUpdatesNow checks one stack for image updates right away, even
+// when scheduled checks are disabled or skip the stack. Progress is sent as
+// imageUpdateCheckProgress events, and the ack once the check is done.
+// Args: stackName.
+func (app *App) handleCheckUpdatesNow(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	stackName := argString(parseArgs(msg), 0)
+	if err := stack.ValidateStackName(stackName); err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	if !app.isStackManaged(stackName) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Stack not found"})
+		}
+		return
+	}
+
+	go func() {
+		result := app.checkStackImageUpdates(stackName, true, func(p imageCheckProgress) {
+			ws.SendEvent(c, "imageUpdateCheckProgress", p)
+		})
+		app.TriggerUpdatesBroadcast()
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, struct {
+				OK bool `json:"ok"`
+				imageCheckResult
+			}{OK: true, imageCheckResult: result})
+		}
+	}()
+}
+
 // Per-image timeout for digest lookups. Each image gets its own timeout
 // so a slow/unreachable registry doesn't block checks for other images.
 const perImageCheckTimeout = 30 * time.Second
 
-// checkImageUpdatesForStack checks all services in a single stack for image updates.
-// Reads compose data from disk (no cache). Respects dockge.imageupdates.check labels.
-// Each image gets its own timeout so a slow registry doesn't block others.
+// checkImageUpdatesForStack checks all services in a single stack for image
+// updates, unless the imageUpdateCheckSkip setting skips it.
 func (app *App) checkImageUpdatesForStack(stackName string) {
+	app.checkStackImageUpdates(stackName, false, nil)
+}
+
+// checkStackImageUpdates checks all services in a single stack for image updates.
+// Reads compose data from disk (no cache). Respects dockge.imageupdates.check labels
+// and the services listed in the imageUpdateCheckSkip setting; the stacks listed
+// there are skipped too unless force is set.
+// Ignored updates (dockge.imageupdates.ignore label or the ignore list) are still
+// checked but stored without hasUpdate, so badges and auto-update skip them.
+// Each image gets its own timeout so a slow registry doesn't block others.
+// progress, if set, is called after each service is checked.
+func (app *App) checkStackImageUpdates(stackName string, force bool, progress func(imageCheckProgress)) imageCheckResult {
+	var result imageCheckResult
+	// Archived stacks are never started, so their updates don't matter
+	if app.stackArchive(stackName) != nil {
+		return result
+	}
+
 	// Parse compose file from disk
 	path := compose.FindComposeFile(app.StacksDir, stackName)
 	if path == "" {
-		return
+		return result
 	}
 	serviceData := compose.ParseFile(path)
 	if len(serviceData) == 0 {
-		return
+		return result
+	}
+
+	skip := app.imageCheckSkips()
+	if skip[stackName] && !force {
+		// Clear stale entries, as for services with checks disabled
+		if err := app.ImageUpdates.DeleteForStack(stackName); err != nil {
+			slog.Warn("delete skipped stack update entries", "err", err, "stack", stackName)
+		}
+		return result
+	}
+
+	ignored := app.updateIgnoreMatcher()
+	// Services with an update at the last check, to notify of new ones only
+	hadUpdate, _ := app.ImageUpdates.ServiceUpdatesForStack(stackName)
+	var newUpdates []string
+
+	wanted := func(svc string, sd compose.ServiceData) bool {
+		return sd.Image != "" && sd.ImageUpdatesCheck && !skip[stackName+"/"+svc]
+	}
+	total := 0
+	for svc, sd := range serviceData {
+		if wanted(svc, sd) {
+			total++
+		}
 	}
 
 	anyUpdate := false
-	var failed int
+	var failed, done int
 	for svc, sd := range serviceData {
 		if sd.Image == "" {
 			continue
 		}
 
 		// Skip services with image update checking disabled
-		if !sd.ImageUpdatesCheck {
+		if !wanted(svc, sd) {
 			// Clear any stale BBolt entry
 			if err := app.ImageUpdates.DeleteService(stackName, svc); err != nil {
 				slog.Warn("delete disabled service update entry", "err", err, "stack", stackName, "svc", svc)
@@ -457,16 +565,67 @@ func (app *App) checkImageUpdatesForStack(stackName string) {
 		}
 
 		hasUpdate := checkStatus == models.CheckStatusOK && localDigest != remoteDigest
+		if hasUpdate && (labelIgnoresUpdate(sd.ImageUpdatesIgnore, remoteDigest) || ignored(stackName, svc, imageRef, remoteDigest)) {
+			slog.Debug("image update ignored", "stack", stackName, "svc", svc, "image", imageRef)
+			hasUpdate = false
+		}
 		if hasUpdate {
 			anyUpdate = true
+			if !hadUpdate[svc] {
+				newUpdates = append(newUpdates, svc+" ("+imageRef+")")
+			}
 		}
 
 		if err := app.ImageUpdates.Upsert(stackName, svc, imageRef, localDigest, remoteDigest, hasUpdate, checkStatus); err != nil {
 			slog.Error("checkImageUpdates upsert", "err", err, "stack", stackName, "svc", svc)
 		}
+		app.classifyRelease(stackName, svc, imageRef)
+
+		done++
+		result.Checked++
+		if hasUpdate {
+			result.Updates++
+		}
+		if progress != nil {
+			progress(imageCheckProgress{
+				StackName: stackName, Service: svc, Image: imageRef, Done: done, Total: total,
+				Failed: checkStatus == models.CheckStatusFailed, HasUpdate: hasUpdate,
+			})
+		}
 	}
+	result.Failed = failed
+	sort.Strings(newUpdates)
+	app.notifyImageUpdates(stackName, newUpdates)
 
 	slog.Debug("image update check complete", "stack", stackName, "anyUpdate", anyUpdate, "failed", failed)
+	return result
+}
+
+// classifyRelease records whether newer patch, minor or major tags exist
+// for a service's version-tagged image. Skipped without a registry client.
+func (app *App) classifyRelease(stackName, svc, imageRef string) {
+	if app.Registry == nil {
+		return
+	}
+	_, tag, _ := compose.SplitImageRef(imageRef)
+	if !compose.IsVersionTag(tag) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), perImageCheckTimeout)
+	tags, err := app.Registry.Tags(ctx, imageRef)
+	cancel()
+	if err != nil {
+		slog.Debug("list image tags", "err", err, "image", imageRef)
+		return
+	}
+	var release *models.ReleaseUpdate
+	if ru := compose.ClassifyTagUpdate(tag, tags); ru.Kind != "" {
+		r := models.ReleaseUpdate(ru)
+		release = &r
+	}
+	if err := app.ImageUpdates.SetRelease(stackName, svc, release); err != nil {
+		slog.Warn("set release update", "err", err, "stack", stackName, "svc", svc)
+	}
 }
 
 // imageDigest returns the local digest for an image using the Docker client.
@@ -507,6 +666,31 @@ func (app *App) getImageUpdateInterval() time.Duration {
 	return time.Duration(hours * float64(time.Hour))
 }
 
+// getImageCheckConcurrency reads how many stacks are checked at once from
+// settings, falling back to defaultImageCheckConcurrency.
+func (app *App) getImageCheckConcurrency() int {
+	val, err := app.Settings.Get(settingImageCheckConcurrency)
+	if err != nil || val == "" {
+		return defaultImageCheckConcurrency
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil || n <= 0 {
+		return defaultImageCheckConcurrency
+	}
+	return min(n, maxImageCheckConcurrency)
+}
+
+// imageCheckSkips returns the stacks and "stack/service" keys listed in
+// the imageUpdateCheckSkip setting.
+func (app *App) imageCheckSkips() map[string]bool {
+	val, _ := app.Settings.Get(settingImageCheckSkip)
+	skips := make(map[string]bool)
+	for _, entry := range strings.FieldsFunc(val, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+		skips[entry] = true
+	}
+	return skips
+}
+
 // isImageUpdateCheckEnabled reads the enabled flag from settings.
 // Defaults to true if not set.
 func (app *App) isImageUpdateCheckEnabled() bool {
@@ -578,12 +762,16 @@ func (app *App) checkAllImageUpdates() {
 	}
 
 	// Collect stack names that have compose files
+	ignore := app.ignoreList()
 	var stackNames []string
 	for _, entry := range entries {
-		if !entry.IsDir() {
+		if !stack.IsDirEntry(app.StacksDir, entry) {
 			continue
 		}
 		name := entry.Name()
+		if ignore.ignoresProject(name) {
+			continue
+		}
 		if compose.FindComposeFile(app.StacksDir, name) != "" {
 			stackNames = append(stackNames, name)
 		}
@@ -595,7 +783,7 @@ func (app *App) checkAllImageUpdates() {
 
 	slog.Info("background image update check starting", "stacks", len(stackNames))
 
-	sem := make(chan struct{}, imageCheckConcurrency)
+	sem := make(chan struct{}, app.getImageCheckConcurrency())
 	var wg sync.WaitGroup
 
 	for _, name := range stackNames {
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+const (
+	// sudoDuration is how long a password re-entry unlocks sensitive actions.
+	sudoDuration = 5 * time.Minute
+
+	// idleCheckInterval is how often connections are checked for idleness.
+	idleCheckInterval = 30 * time.Second
+
+	// settingIdleTimeout is the settings key for the idle session timeout in
+	// minutes. Empty or "0" disables it.
+	settingIdleTimeout = "idleTimeout"
+)
+
+// requireSudo checks that the connection has re-entered its password
+// recently. Sends a "sudoRequired" error ack and returns false otherwise.
+// Call after checkLogin in handlers for destructive actions. With --no-auth
+// there is no password to re-enter, so this always passes.
+func (app *App) requireSudo(c *ws.Conn, msg *ws.ClientMessage) bool {
+	if app.NoAuth || c.Elevated() {
+		return true
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "sudoRequired", MsgI18n: true})
+	}
+	return false
+}
+
+// handleSudo verifies the current user's password and elevates the
+// connection for sudoDuration.
+func (app *App) handleSudo(c *ws.Conn, msg *ws.ClientMessage) {
+	uid := checkLogin(c, msg)
+	if uid == 0 {
+		return
+	}
+	args := parseArgs(msg)
+	password := argString(args, 0)
+
+	user, err := app.Users.FindByID(uid)
+	if err != nil || user == nil {
+		slog.Error("sudo user lookup", "err", err, "uid", uid)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+
+	// Share the login rate limiter so sudo can't be used to brute-force
+	if app.LoginLimiter != nil && !app.LoginLimiter.Allow(user.Username) {
+		slog.Warn("sudo rate limited", "username", user.Username)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Too many login attempts. Please try again later."})
+		}
+		return
+	}
+
+	if password == "" || !models.VerifyPassword(password, user.Password) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "authIncorrectCreds", MsgI18n: true})
+		}
+		return
+	}
+	if app.LoginLimiter != nil {
+		app.LoginLimiter.Reset(user.Username)
+	}
+
+	until := time.Now().Add(sudoDuration)
+	c.Elevate(until)
+	slog.Info("sudo mode enabled", "username", user.Username)
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, sudoStatusResponse{OK: true, Elevated: true, ExpiresAt: until.Unix()})
+	}
+}
+
+// handleGetSudoStatus reports whether the connection is in sudo mode, so the
+// frontend can prompt for the password before sending a destructive action.
+func (app *App) handleGetSudoStatus(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	if msg.ID == nil {
+		return
+	}
+	resp := sudoStatusResponse{OK: true, Elevated: app.NoAuth || c.Elevated()}
+	if until := c.SudoUntil(); resp.Elevated && !until.IsZero() {
+		resp.ExpiresAt = until.Unix()
+	}
+	ws.SendAck(c, *msg.ID, resp)
+}
+
+type sudoStatusResponse struct {
+	OK        bool  `json:"ok"`
+	Elevated  bool  `json:"elevated"`
+	ExpiresAt int64 `json:"expiresAt,omitempty"` // Unix seconds
+}
+
+// idleTimeout returns the configured idle session timeout, or 0 if disabled.
+func (app *App) idleTimeout() time.Duration {
+	v, err := app.Settings.Get(settingIdleTimeout)
+	if err != nil || v == "" {
+		return 0
+	}
+	minutes, err := strconv.Atoi(v)
+	if err != nil || minutes <= 0 {
+		return 0
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// StartIdleSessionReaper runs a background goroutine that logs out
+// authenticated connections that have sent nothing for longer than the
+// idleTimeout setting. The client is told via a "sessionExpired" event (so
+// it drops its stored token) and the connection is closed, which releases
+// its terminal sessions and subscriptions through the normal disconnect path.
+func (app *App) StartIdleSessionReaper(ctx context.Context) {
+	if app.NoAuth {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(idleCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				timeout := app.idleTimeout()
+				if timeout == 0 {
+					continue
+				}
+				for _, c := range app.idleConns(timeout) {
+					slog.Info("idle session expired", "conn", c.ID(), "idle", time.Since(c.LastActive()).Round(time.Second))
+					ws.SendEvent[any](c, "sessionExpired", nil)
+					c.Close()
+				}
+			}
+		}
+	}()
+}
+
+// idleConns returns authenticated connections idle for longer than timeout.
+func (app *App) idleConns(timeout time.Duration) []*ws.Conn {
+	cutoff := time.Now().Add(-timeout)
+	var idle []*ws.Conn
+	app.WS.ForEachConn(func(c *ws.Conn) {
+		if c.UserID() != 0 && c.LastActive().Before(cutoff) {
+			idle = append(idle, c)
+		}
+	})
+	return idle
+}
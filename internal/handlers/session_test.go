@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+func TestIdleConnsIgnoresPolling(t *testing.T) {
+	app := &App{WS: ws.NewServer(true)}
+	app.WS.Handle("getStackMetrics", func(c *ws.Conn, msg *ws.ClientMessage) {})
+	send := func(ctx context.Context, typ websocket.MessageType, data []byte) error { return nil }
+
+	polling := app.WS.Attach(send, nil)
+	polling.SetUser(1)
+	active := app.WS.Attach(send, nil)
+	active.SetUser(1)
+
+	// A page left open keeps polling, and its terminal keeps acking
+	for i := 0; i < 10; i++ {
+		time.Sleep(10 * time.Millisecond)
+		polling.Receive(websocket.MessageText, []byte(`{"event":"getStackMetrics","background":true}`))
+		polling.Receive(websocket.MessageBinary, []byte{0, 1, ws.OpAck, 0, 0, 0, 1})
+	}
+	active.Receive(websocket.MessageText, []byte(`{"event":"getStackMetrics"}`))
+
+	idle := app.idleConns(50 * time.Millisecond)
+	if len(idle) != 1 || idle[0] != polling {
+		t.Errorf("idle = %v, want only the polling connection", idle)
+	}
+}
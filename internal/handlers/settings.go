@@ -6,6 +6,7 @@ import (
     "os"
     "path/filepath"
 
+    "github.com/cfilipov/dockge/internal/stack"
     "github.com/cfilipov/dockge/internal/ws"
 )
 
@@ -34,6 +35,7 @@ func (app *App) handleGetSettings(c *ws.Conn, msg *ws.ClientMessage) {
 
     // Filter out sensitive settings
     delete(settings, "jwtSecret")
+    maskNotifySecrets(settings)
 
     // globalENV is file-based, not stored in BoltDB
     globalEnvPath := filepath.Join(app.StacksDir, "global.env")
@@ -42,6 +44,9 @@ func (app *App) handleGetSettings(c *ws.Conn, msg *ws.ClientMessage) {
     } else {
         settings["globalENV"] = "# VARIABLE=value #comment"
     }
+    if app.masksEnvSecrets(c) {
+        settings["globalENV"] = stack.MaskDotEnv(settings["globalENV"])
+    }
 
     if msg.ID != nil {
         ws.SendAck(c, *msg.ID, map[string]interface{}{
@@ -51,8 +56,10 @@ func (app *App) handleGetSettings(c *ws.Conn, msg *ws.ClientMessage) {
     }
 }
 
+// handleSetSettings saves settings. Admin only: they include the approval
+// rule, global.env, which every stack gets, and where notifications go.
 func (app *App) handleSetSettings(c *ws.Conn, msg *ws.ClientMessage) {
-    if checkLogin(c, msg) == 0 {
+    if app.checkAdmin(c, msg) == nil {
         return
     }
 
@@ -88,6 +95,17 @@ func (app *App) handleSetSettings(c *ws.Conn, msg *ws.ClientMessage) {
         delete(data, "globalENV")
     }
 
+    ignoreChanged, err := validateIgnoreSettings(data)
+    if err == nil {
+        err = validateNotifySettings(data)
+    }
+    if err != nil {
+        if msg.ID != nil {
+            ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+        }
+        return
+    }
+
     for key, val := range data {
         // Don't allow overwriting jwtSecret via settings
         if key == "jwtSecret" {
@@ -119,6 +137,12 @@ func (app *App) handleSetSettings(c *ws.Conn, msg *ws.ClientMessage) {
 
     app.Settings.InvalidateCache()
 
+    // Show or hide what the ignore list now covers
+    if ignoreChanged {
+        app.TriggerStacksBroadcast()
+        app.TriggerContainersBroadcast()
+    }
+
     if msg.ID != nil {
         ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Saved"})
     }
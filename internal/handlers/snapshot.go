@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/docker"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// snapshotRepository prefixes the default reference of committed containers.
+const snapshotRepository = "dockge-snapshot"
+
+// snapshotTimeout bounds a commit plus optional export of a large container.
+const snapshotTimeout = 30 * time.Minute
+
+// Labels recording where a snapshot image came from.
+const (
+	labelSnapshotContainer   = "dockge.snapshot.container"
+	labelSnapshotContainerID = "dockge.snapshot.container-id"
+	labelSnapshotImage       = "dockge.snapshot.image"
+	labelSnapshotStack       = "dockge.snapshot.stack"
+	labelSnapshotService     = "dockge.snapshot.service"
+	labelSnapshotCreatedBy   = "dockge.snapshot.created-by"
+)
+
+// RegisterSnapshotHandlers registers the container snapshot handler.
+func RegisterSnapshotHandlers(app *App) {
+	app.WS.Handle("commitContainer", app.handleCommitContainer)
+}
+
+// commitContainerArgs are the options of a commitContainer request.
+type commitContainerArgs struct {
+	Reference string `json:"reference"` // default dockge-snapshot/<container>:<time>
+	Comment   string `json:"comment"`
+	NoPause   bool   `json:"noPause"` // commit without pausing the container
+	Export    bool   `json:"export"`  // also save the image as a tar in ExportDir
+}
+
+// handleCommitContainer snapshots a container into a local image, and
+// optionally exports it, so its state survives a destructive restart.
+// Admin only and requires sudo. Args: container name, options.
+func (app *App) handleCommitContainer(c *ws.Conn, msg *ws.ClientMessage) {
+	user := app.checkAdmin(c, msg)
+	if user == nil || !app.requireSudo(c, msg) {
+		return
+	}
+	args := parseArgs(msg)
+	containerName := argString(args, 0)
+	var opts commitContainerArgs
+	argObject(args, 1, &opts)
+
+	fail := func(text string) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: text})
+		}
+	}
+	if containerName == "" {
+		fail("Container name required")
+		return
+	}
+	if opts.Export && app.ExportDir == "" {
+		fail("Image export is not available")
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), snapshotTimeout)
+		defer cancel()
+
+		ctr, err := app.findContainer(ctx, containerName)
+		if err != nil {
+			slog.Warn("commit container: list containers", "err", err)
+			fail("Internal error")
+			return
+		}
+		if ctr == nil {
+			fail("Container not found")
+			return
+		}
+
+		now := time.Now()
+		ref := strings.TrimSpace(opts.Reference)
+		if ref == "" {
+			ref = snapshotRepository + "/" + snapshotRepoName(ctr.Name) + ":" + now.Format("20060102-150405")
+		}
+		labels := map[string]string{
+			labelSnapshotContainer:   ctr.Name,
+			labelSnapshotContainerID: ctr.ID,
+			labelSnapshotImage:       ctr.Image,
+			labelSnapshotCreatedBy:   user.Username,
+		}
+		if ctr.Project != "" {
+			labels[labelSnapshotStack] = ctr.Project
+			labels[labelSnapshotService] = ctr.Service
+		}
+
+		imageID, err := app.Docker.ContainerCommit(ctx, ctr.ID, docker.CommitOptions{
+			Reference: ref,
+			Comment:   opts.Comment,
+			Author:    user.Username,
+			Labels:    labels,
+			Pause:     !opts.NoPause,
+		})
+		if err != nil {
+			slog.Error("commit container", "err", err, "container", containerName)
+			fail(err.Error())
+			return
+		}
+		slog.Info("container committed", "container", containerName, "image", ref, "id", imageID, "by", user.Username)
+		app.TriggerImagesBroadcast()
+
+		exportPath := ""
+		if opts.Export {
+			exportPath, err = app.exportImage(ctx, ref)
+			if err != nil {
+				// The image itself was created; report both
+				slog.Error("export snapshot image", "err", err, "image", ref)
+				fail(fmt.Sprintf("Committed %s, but export failed: %v", ref, err))
+				return
+			}
+			slog.Info("snapshot image exported", "image", ref, "path", exportPath)
+		}
+
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, struct {
+				OK         bool   `json:"ok"`
+				ImageID    string `json:"imageId"`
+				Reference  string `json:"reference"`
+				ExportPath string `json:"exportPath,omitempty"`
+			}{OK: true, ImageID: imageID, Reference: ref, ExportPath: exportPath})
+		}
+	}()
+}
+
+// findContainer returns the container with the given name, or nil.
+func (app *App) findContainer(ctx context.Context, name string) (*docker.Container, error) {
+	containers, err := app.Docker.ContainerList(ctx, true, "")
+	if err != nil {
+		return nil, err
+	}
+	for i := range containers {
+		if containers[i].Name == name {
+			return &containers[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// exportImage saves an image as a tar in ExportDir and returns its path.
+// A partial file is removed on failure.
+func (app *App) exportImage(ctx context.Context, ref string) (string, error) {
+	if err := os.MkdirAll(app.ExportDir, 0700); err != nil {
+		return "", fmt.Errorf("create export dir: %w", err)
+	}
+	name := strings.NewReplacer("/", "_", ":", "_", "@", "_").Replace(ref) + ".tar"
+	path := filepath.Join(app.ExportDir, name)
+
+	rc, err := app.Docker.ImageSave(ctx, []string{ref})
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", fmt.Errorf("create export file: %w", err)
+	}
+	if _, err := io.Copy(f, rc); err != nil {
+		f.Close()
+		os.Remove(path)
+		return "", fmt.Errorf("write export file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("write export file: %w", err)
+	}
+	return path, nil
+}
+
+// snapshotRepoName maps a container name to a valid repository path
+// component: lowercase letters, digits and single separators.
+func snapshotRepoName(name string) string {
+	var b strings.Builder
+	sep := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			sep = false
+		} else if !sep && b.Len() > 0 {
+			b.WriteByte('-')
+			sep = true
+		}
+	}
+	repo := strings.TrimRight(b.String(), "-")
+	if repo == "" {
+		return "container"
+	}
+	return repo
+}
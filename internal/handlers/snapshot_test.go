@@ -0,0 +1,20 @@
+package handlers
+
+import "testing"
+
+func TestSnapshotRepoName(t *testing.T) {
+	t.Parallel()
+	tests := map[string]string{
+		"web-app-1":     "web-app-1",
+		"My_Container":  "my-container",
+		"a..b--c":       "a-b-c",
+		"-lead.trail-":  "lead-trail",
+		"__":            "container",
+		"nginx.1.abcde": "nginx-1-abcde",
+	}
+	for in, want := range tests {
+		if got := snapshotRepoName(in); got != want {
+			t.Errorf("snapshotRepoName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/cfilipov/dockge/internal/compose"
 	"github.com/cfilipov/dockge/internal/docker"
+	"github.com/cfilipov/dockge/internal/models"
 	"github.com/cfilipov/dockge/internal/stack"
 	"github.com/cfilipov/dockge/internal/terminal"
 	"github.com/cfilipov/dockge/internal/ws"
@@ -19,17 +21,32 @@ import (
 
 func RegisterStackHandlers(app *App) {
 	app.WS.Handle("getStack", app.handleGetStack)
+	app.WS.Handle("getStackDrift", app.handleGetStackDrift)
+	app.WS.Handle("getResolvedCompose", app.handleGetResolvedCompose)
+	app.WS.Handle("setServiceDNS", app.handleSetServiceDNS)
 	app.WS.Handle("saveStack", app.handleSaveStack)
-	app.WS.Handle("deployStack", app.handleDeployStack)
+	app.WS.Handle("checkStackName", app.handleCheckStackName)
+	app.WS.Handle("deployStack", app.idempotent(app.handleDeployStack))
+	app.WS.Handle("buildStack", app.handleBuildStack)
+	app.WS.Handle("createExternalResource", app.handleCreateExternalResource)
+	app.WS.Handle("validateCompose", app.handleValidateCompose)
+	app.WS.Handle("analyzeComposeRisks", app.handleAnalyzeComposeRisks)
+	app.WS.Handle("convertDockerRun", app.handleConvertDockerRun)
+	app.WS.Handle("adoptStack", app.handleAdoptStack)
 	app.WS.Handle("startStack", app.handleStartStack)
 	app.WS.Handle("stopStack", app.handleStopStack)
 	app.WS.Handle("restartStack", app.handleRestartStack)
 	app.WS.Handle("downStack", app.handleDownStack)
-	app.WS.Handle("updateStack", app.handleUpdateStack)
-	app.WS.Handle("deleteStack", app.handleDeleteStack)
+	app.WS.Handle("updateStack", app.idempotent(app.handleUpdateStack))
+	app.WS.Handle("deleteStack", app.idempotent(app.handleDeleteStack))
 	app.WS.Handle("forceDeleteStack", app.handleForceDeleteStack)
 	app.WS.Handle("pauseStack", app.handlePauseStack)
 	app.WS.Handle("resumeStack", app.handleResumeStack)
+	app.WS.Handle("quickAction", app.handleQuickAction)
+	if app.Idempotency != nil {
+		app.WS.ObserveAcks(idempotentEvent, app.completeIdempotent)
+	}
+	app.WS.Handle("getSummary", app.handleGetSummary)
 }
 
 // parseComposeDataForStack parses compose data for a single stack,
@@ -145,10 +162,17 @@ func (app *App) handleGetStack(c *ws.Conn, msg *ws.ClientMessage) {
 		s.IsManagedByDockge = cached.IsManagedByDockge
 		s.ComposeFileName = cached.ComposeFileName
 		s.ComposeOverrideFileName = cached.ComposeOverrideFileName
+		s.ServiceHealth = cached.ServiceHealth
 	}
 
 	// Load YAML content from disk (fast — local file I/O)
 	s.LoadFromDisk(app.StacksDir)
+	env := stack.ParseDotEnv(s.ComposeENV)
+	envMasked := app.masksEnvSecrets(c)
+	if envMasked {
+		s.ComposeENV = stack.MaskDotEnv(s.ComposeENV)
+		env = stack.MaskEntries(env)
+	}
 
 	hostname := "localhost"
 	if h, err := app.Settings.Get("primaryHostname"); err == nil && h != "" {
@@ -163,11 +187,32 @@ func (app *App) handleGetStack(c *ws.Conn, msg *ws.ClientMessage) {
 
 	if msg.ID != nil {
 		ws.SendAck(c, *msg.ID, struct {
-			OK    bool               `json:"ok"`
-			Stack stack.StackFullJSON `json:"stack"`
+			OK           bool                  `json:"ok"`
+			Stack        stack.StackFullJSON   `json:"stack"`
+			Dependencies []serviceDependency   `json:"dependencies"`
+			Note         *models.StackNote     `json:"note"`
+			Archive      *models.ArchivedStack `json:"archive"` // nil unless archived
+			// Hourly restarts and health flaps over the last day
+			Events []models.StackEventHour `json:"events"`
+			// Whether this user may open exec terminals in the stack, and
+			// whether that's limited to admins and chosen users
+			CanOpenTerminal    bool `json:"canOpenTerminal"`
+			TerminalRestricted bool `json:"terminalRestricted"`
+			// The .env file's variables and comments; secret values are
+			// masked when EnvMasked is set
+			Env       []stack.EnvEntry `json:"env"`
+			EnvMasked bool             `json:"envMasked"`
 		}{
-			OK:    true,
-			Stack: s.ToJSON("", hostname, updateMap[stackName], recreateMap[stackName]),
+			OK:                 true,
+			Stack:              s.ToJSON("", hostname, updateMap[stackName], recreateMap[stackName]),
+			Dependencies:       dependencyGraph(s.ComposeYAML, containers),
+			Note:               app.stackNote(stackName),
+			Archive:            app.stackArchive(stackName),
+			Events:             app.stackEventHistogram(stackName),
+			CanOpenTerminal:    app.canOpenStackTerminal(app.currentUser(c), stackName),
+			TerminalRestricted: app.terminalAccess(stackName) != nil,
+			Env:                env,
+			EnvMasked:          envMasked,
 		})
 	}
 }
@@ -182,7 +227,12 @@ func (app *App) handleSaveStack(c *ws.Conn, msg *ws.ClientMessage) {
 	composeYAML := argString(args, 1)
 	composeENV := argString(args, 2)
 	composeOverrideYAML := argString(args, 3)
-	// isAdd := argBool(args, 4)
+	isAdd := argBool(args, 4)
+	var opts struct {
+		AcceptRisks  bool                 `json:"acceptRisks"`  // deploy a new stack despite its risk report
+		ComposeFiles *[]stack.ComposeFile `json:"composeFiles"` // additional compose files, nil to leave them
+	}
+	argObject(args, 5, &opts)
 
 	if stackName == "" || composeYAML == "" {
 		if msg.ID != nil {
@@ -196,16 +246,40 @@ func (app *App) handleSaveStack(c *ws.Conn, msg *ws.ClientMessage) {
 		}
 		return
 	}
-
-	app.StackLocks.Lock(stackName)
-	defer app.StackLocks.Unlock(stackName)
+	if isAdd && app.rejectNewStackName(c, msg, stackName) {
+		return
+	}
+	diags := lintStackFiles(composeYAML, composeOverrideYAML)
+	if rejectInvalidCompose(c, msg, diags) {
+		return
+	}
+	if app.rejectUnacceptedRisks(c, msg, stackName, composeYAML, composeENV, composeOverrideYAML, opts.AcceptRisks) {
+		return
+	}
+	if rejectInvalidComposeFiles(c, msg, opts.ComposeFiles) {
+		return
+	}
 
 	s := &stack.Stack{
 		Name:                stackName,
 		ComposeYAML:         composeYAML,
-		ComposeENV:          composeENV,
+		ComposeENV:          app.unmaskStackEnv(c, stackName, composeENV),
 		ComposeOverrideYAML: composeOverrideYAML,
 	}
+	if opts.ComposeFiles != nil {
+		s.ExtraFiles = *opts.ComposeFiles
+	}
+
+	if user, ok := app.approvalRequired(c); ok {
+		if app.rejectComposeFilesApproval(c, msg, stackName, opts.ComposeFiles) {
+			return
+		}
+		app.submitPendingChange(c, msg, user, models.PendingActionSave, s, "")
+		return
+	}
+
+	app.StackLocks.Lock(stackName)
+	defer app.StackLocks.Unlock(stackName)
 
 	if err := s.SaveToDisk(app.StacksDir); err != nil {
 		slog.Error("save stack", "err", err, "stack", stackName)
@@ -218,8 +292,15 @@ func (app *App) handleSaveStack(c *ws.Conn, msg *ws.ClientMessage) {
 	// Handle imageupdates.check transitions
 	app.handleComposeYAMLSave(stackName, composeYAML)
 
+	// Saving still succeeds; missing external resources and lint warnings
+	// are reported so they can be dealt with before the stack is started
 	if msg.ID != nil {
-		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Saved"})
+		ws.SendAck(c, *msg.ID, struct {
+			OK              bool                `json:"ok"`
+			Msg             string              `json:"msg"`
+			MissingExternal []missingExternal   `json:"missingExternal,omitempty"`
+			Diagnostics     []composeDiagnostic `json:"diagnostics,omitempty"`
+		}{OK: true, Msg: "Saved", MissingExternal: app.missingExternalResources(composeYAML, composeOverrideYAML), Diagnostics: diags})
 	}
 }
 
@@ -234,6 +315,15 @@ func (app *App) handleDeployStack(c *ws.Conn, msg *ws.ClientMessage) {
 	composeENV := argString(args, 2)
 	composeOverrideYAML := argString(args, 3)
 	// isAdd := argBool(args, 4)
+	note := strings.TrimSpace(argString(args, 5)) // why the change was made
+	var opts struct {
+		Build       bool   `json:"build"`       // build images from build: contexts first
+		AcceptRisks bool   `json:"acceptRisks"` // deploy a new stack despite its risk report
+		Pull        string `json:"pull"`        // pull policy, "" for the stack's own
+		// Additional compose files, nil to leave them
+		ComposeFiles *[]stack.ComposeFile `json:"composeFiles"`
+	}
+	argObject(args, 6, &opts)
 
 	if stackName == "" || composeYAML == "" {
 		if msg.ID != nil {
@@ -247,15 +337,44 @@ func (app *App) handleDeployStack(c *ws.Conn, msg *ws.ClientMessage) {
 		}
 		return
 	}
-
-	app.StackLocks.Lock(stackName)
+	if !validPullPolicy(opts.Pull) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "invalidPullPolicy", MsgI18n: true})
+		}
+		return
+	}
+	if app.rejectArchived(c, msg, stackName) {
+		return
+	}
+	if rejectInvalidCompose(c, msg, lintStackFiles(composeYAML, composeOverrideYAML)) {
+		return
+	}
+	if app.rejectUnacceptedRisks(c, msg, stackName, composeYAML, composeENV, composeOverrideYAML, opts.AcceptRisks) {
+		return
+	}
+	if rejectInvalidComposeFiles(c, msg, opts.ComposeFiles) {
+		return
+	}
 
 	s := &stack.Stack{
 		Name:                stackName,
 		ComposeYAML:         composeYAML,
-		ComposeENV:          composeENV,
+		ComposeENV:          app.unmaskStackEnv(c, stackName, composeENV),
 		ComposeOverrideYAML: composeOverrideYAML,
 	}
+	if opts.ComposeFiles != nil {
+		s.ExtraFiles = *opts.ComposeFiles
+	}
+
+	if user, ok := app.approvalRequired(c); ok {
+		if app.rejectComposeFilesApproval(c, msg, stackName, opts.ComposeFiles) {
+			return
+		}
+		app.submitPendingChange(c, msg, user, models.PendingActionDeploy, s, note)
+		return
+	}
+
+	app.StackLocks.Lock(stackName)
 
 	if err := s.SaveToDisk(app.StacksDir); err != nil {
 		app.StackLocks.Unlock(stackName)
@@ -269,23 +388,49 @@ func (app *App) handleDeployStack(c *ws.Conn, msg *ws.ClientMessage) {
 	// Handle imageupdates.check transitions
 	app.handleComposeYAMLSave(stackName, composeYAML)
 
+	// up would fail on a missing external network or volume; report them
+	// so they can be created instead of deploying
+	if missing := app.missingExternalResources(composeYAML, composeOverrideYAML); len(missing) > 0 {
+		app.StackLocks.Unlock(stackName)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, struct {
+				OK              bool              `json:"ok"`
+				Msg             string            `json:"msg"`
+				MsgI18n         bool              `json:"msgi18n"`
+				MissingExternal []missingExternal `json:"missingExternal"`
+			}{OK: false, Msg: "missingExternalResources", MsgI18n: true, MissingExternal: missing})
+		}
+		return
+	}
+
 	// Validate then deploy in background; ack after completion so the
 	// frontend stays on the current page showing progress output.
 	go func() {
 		defer app.StackLocks.Unlock(stackName)
-		app.runDeployWithValidation(stackName)
-		if msg.ID != nil {
-			ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Deployed"})
+		err := app.runDeployWithValidation(stackName, note, opts.Build, opts.Pull)
+		if msg.ID == nil {
+			return
 		}
+		if isBuildError(err) {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "buildFailed", MsgI18n: true})
+			return
+		}
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Deployed"})
 	}()
 }
 
+// handleStartStack starts a stack. Args: stack name, {pull} where pull is
+// the pull policy of this start, "" for the stack's own.
 func (app *App) handleStartStack(c *ws.Conn, msg *ws.ClientMessage) {
 	if checkLogin(c, msg) == 0 {
 		return
 	}
 	args := parseArgs(msg)
 	stackName := argString(args, 0)
+	var opts struct {
+		Pull string `json:"pull"`
+	}
+	argObject(args, 1, &opts)
 	if stackName == "" {
 		if msg.ID != nil {
 			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Stack name required"})
@@ -298,13 +443,22 @@ func (app *App) handleStartStack(c *ws.Conn, msg *ws.ClientMessage) {
 		}
 		return
 	}
+	if !validPullPolicy(opts.Pull) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "invalidPullPolicy", MsgI18n: true})
+		}
+		return
+	}
+	if app.rejectArchived(c, msg, stackName) {
+		return
+	}
 
 	if msg.ID != nil {
 		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true})
 	}
 
 	if app.isStackManaged(stackName) {
-		go app.lockedRunComposeAction(stackName, "up", "up", "-d", "--remove-orphans")
+		go app.lockedRunComposeAction(stackName, "up", app.upArgs(stackName, opts.Pull)...)
 	} else {
 		// Unmanaged: use docker compose -p to start existing containers
 		go app.lockedRunUnmanagedStackAction(stackName, "start", "start")
@@ -359,6 +513,9 @@ func (app *App) handleRestartStack(c *ws.Conn, msg *ws.ClientMessage) {
 		}
 		return
 	}
+	if app.rejectArchived(c, msg, stackName) {
+		return
+	}
 
 	if msg.ID != nil {
 		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true})
@@ -419,6 +576,9 @@ func (app *App) handleUpdateStack(c *ws.Conn, msg *ws.ClientMessage) {
 		}
 		return
 	}
+	if app.rejectArchived(c, msg, stackName) {
+		return
+	}
 
 	if msg.ID != nil {
 		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true})
@@ -427,24 +587,47 @@ func (app *App) handleUpdateStack(c *ws.Conn, msg *ws.ClientMessage) {
 	go func() {
 		app.StackLocks.Lock(stackName)
 		defer app.StackLocks.Unlock(stackName)
+		app.runStackUpdate(stackName)
+	}()
+}
 
-		app.runDockerCommands(stackName, "update", [][]string{
-			{"compose", "pull"},
-			{"compose", "up", "-d", "--remove-orphans"},
-		})
-		// Prune dangling images via SDK (no docker CLI needed)
-		if msg, err := app.Docker.ImagePrune(context.Background(), true); err != nil {
-			slog.Warn("image prune after update", "stack", stackName, "err", err)
-		} else {
-			slog.Debug("image prune after update", "stack", stackName, "result", msg)
-		}
-		// Clear stale "update available" cache and re-check with new images
-		if err := app.ImageUpdates.DeleteForStack(stackName); err != nil {
-			slog.Warn("clear image update cache", "stack", stackName, "err", err)
+// runStackUpdate pulls and recreates a stack, then prunes dangling images
+// and refreshes its update status. The caller holds the stack lock.
+func (app *App) runStackUpdate(stackName string) error {
+	// Pull progress of all services is folded into one stream and
+	// kept with the operation.
+	op := app.beginOperation(stackName, "update", "")
+	pull := newPullAggregator()
+	err := app.runDockerCommands(stackName, "update", [][]string{
+		{"compose", "pull"},
+		{"compose", "up", "-d", "--remove-orphans"},
+	}, func(subcommand string, ev compose.ProgressEvent) {
+		if subcommand == "pull" && pull.Add(ev) {
+			ws.BroadcastAuthenticated(app.WS, "pullProgress", struct {
+				StackName string `json:"stackName"`
+				models.PullProgress
+			}{stackName, pull.Summary()})
 		}
-		app.checkImageUpdatesForStack(stackName)
-		app.TriggerUpdatesBroadcast()
-	}()
+	})
+	if op != nil && pull.Summary().Images > 0 {
+		summary := pull.Summary()
+		op.Pull = &summary
+	}
+	app.endOperation(op, err)
+	app.fireStackWebhooks(stackName, "update", err)
+	// Prune dangling images via SDK (no docker CLI needed)
+	if report, err := app.Docker.ImagePrune(context.Background(), false); err != nil {
+		slog.Warn("image prune after update", "stack", stackName, "err", err)
+	} else {
+		slog.Debug("image prune after update", "stack", stackName, "reclaimed", report.SpaceReclaimed)
+	}
+	// Clear stale "update available" cache and re-check with new images
+	if err := app.ImageUpdates.DeleteForStack(stackName); err != nil {
+		slog.Warn("clear image update cache", "stack", stackName, "err", err)
+	}
+	app.checkImageUpdatesForStack(stackName)
+	app.TriggerUpdatesBroadcast()
+	return err
 }
 
 func (app *App) handleDeleteStack(c *ws.Conn, msg *ws.ClientMessage) {
@@ -456,6 +639,7 @@ func (app *App) handleDeleteStack(c *ws.Conn, msg *ws.ClientMessage) {
 
 	var opts struct {
 		DeleteStackFiles bool `json:"deleteStackFiles"`
+		IgnoreDependents bool `json:"ignoreDependents"`
 	}
 	argObject(args, 1, &opts)
 
@@ -472,6 +656,11 @@ func (app *App) handleDeleteStack(c *ws.Conn, msg *ws.ClientMessage) {
 		return
 	}
 
+	// down removes the stack's networks; other stacks may still use them
+	if !opts.IgnoreDependents && app.rejectDependents(c, msg, stackName, false) {
+		return
+	}
+
 	if msg.ID != nil {
 		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true})
 	}
@@ -489,6 +678,17 @@ func (app *App) handleDeleteStack(c *ws.Conn, msg *ws.ClientMessage) {
 			if err := os.RemoveAll(dir); err != nil {
 				slog.Error("delete stack files", "err", err, "stack", stackName)
 			}
+			app.deleteStackNote(stackName)
+			app.deleteStackEvents(stackName)
+			app.deleteTerminalAccess(stackName)
+			app.deleteTerminalEnv(stackName)
+			app.deletePullPolicy(stackName)
+			app.deleteServiceSelection(stackName)
+			app.deleteStackWebhooks(stackName)
+			app.deleteDeployedCompose(stackName)
+			app.removeFromStackGroups(stackName)
+			app.unarchiveDeletedStack(stackName)
+			app.deleteStackSchedules(stackName)
 		}
 
 		slog.Info("stack deleted", "stack", stackName)
@@ -499,6 +699,9 @@ func (app *App) handleForceDeleteStack(c *ws.Conn, msg *ws.ClientMessage) {
 	if checkLogin(c, msg) == 0 {
 		return
 	}
+	if !app.requireSudo(c, msg) {
+		return
+	}
 	args := parseArgs(msg)
 	stackName := argString(args, 0)
 	if stackName == "" {
@@ -514,6 +717,16 @@ func (app *App) handleForceDeleteStack(c *ws.Conn, msg *ws.ClientMessage) {
 		return
 	}
 
+	var opts struct {
+		IgnoreDependents bool `json:"ignoreDependents"`
+	}
+	argObject(args, 1, &opts)
+
+	// down -v removes the stack's volumes as well as its networks
+	if !opts.IgnoreDependents && app.rejectDependents(c, msg, stackName, true) {
+		return
+	}
+
 	if msg.ID != nil {
 		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true})
 	}
@@ -529,6 +742,17 @@ func (app *App) handleForceDeleteStack(c *ws.Conn, msg *ws.ClientMessage) {
 		if err := os.RemoveAll(dir); err != nil {
 			slog.Error("force delete stack", "err", err, "stack", stackName)
 		}
+		app.deleteStackNote(stackName)
+		app.deleteStackEvents(stackName)
+		app.deleteTerminalAccess(stackName)
+		app.deleteTerminalEnv(stackName)
+		app.deletePullPolicy(stackName)
+		app.deleteServiceSelection(stackName)
+		app.deleteStackWebhooks(stackName)
+		app.deleteDeployedCompose(stackName)
+		app.removeFromStackGroups(stackName)
+		app.unarchiveDeletedStack(stackName)
+		app.deleteStackSchedules(stackName)
 
 		slog.Info("stack force deleted", "stack", stackName)
 	}()
@@ -578,6 +802,9 @@ func (app *App) handleResumeStack(c *ws.Conn, msg *ws.ClientMessage) {
 		}
 		return
 	}
+	if app.rejectArchived(c, msg, stackName) {
+		return
+	}
 
 	if msg.ID != nil {
 		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true})
@@ -601,28 +828,36 @@ func (app *App) lockedRunUnmanagedStackAction(stackName, action string, composeA
 }
 
 // runComposeAction runs a compose command in the background, streaming output
-// to a PTY terminal that fans out to WebSocket clients.
+// to a PTY terminal that fans out to WebSocket clients, and returns its error.
 // In mock mode, exec.Command resolves to the mock docker binary via PATH.
-func (app *App) runComposeAction(stackName, action string, composeArgs ...string) {
+func (app *App) runComposeAction(stackName, action string, composeArgs ...string) error {
 	termName := "compose-" + stackName
-	envArgs := compose.GlobalEnvArgs(app.StacksDir, stackName)
-	displayParts := append(envArgs, composeArgs...)
-	cmdDisplay := "$ docker compose " + strings.Join(displayParts, " ") + "\r\n"
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 
 	term := app.Terms.Recreate(termName, terminal.TypePTY)
-	term.Write([]byte(cmdDisplay))
 
-	dir := filepath.Join(app.StacksDir, stackName)
-	args := []string{"compose"}
-	args = append(args, envArgs...)
-	args = append(args, composeArgs...)
-	cmd := exec.CommandContext(ctx, "docker", args...)
-	cmd.Dir = dir
+	envArgs, cleanup, envErr := app.actionEnvArgs(ctx, stackName, composeArgs)
+	defer cleanup()
+	displayParts := append(envArgs, composeArgs...)
+	term.Write([]byte("$ docker compose " + strings.Join(displayParts, " ") + "\r\n"))
 
-	if err := term.RunPTY(cmd); err != nil {
+	// A start applies the files on disk, like a deploy
+	var files *stack.Stack
+	if action == "up" {
+		files = app.loadStackFiles(stackName)
+	}
+
+	op := app.beginOperation(stackName, action, "")
+	dir := filepath.Join(app.StacksDir, stackName)
+	err := envErr
+	if err == nil && action == "up" {
+		err = app.runUp(ctx, term, stackName, action, dir, envArgs, composeArgs)
+	} else if err == nil {
+		err = app.runCompose(ctx, term, stackName, action, dir, envArgs, composeArgs, nil)
+	}
+	if err != nil {
 		if ctx.Err() == nil {
 			errMsg := "\r\n[Error] " + err.Error() + "\r\n"
 			term.Write([]byte(errMsg))
@@ -631,16 +866,22 @@ func (app *App) runComposeAction(stackName, action string, composeArgs ...string
 	} else {
 		term.Write([]byte("\r\n[Done]\r\n"))
 	}
+	app.endOperation(op, err)
+	if files != nil && err == nil {
+		app.recordDeployedCompose(files, action)
+	}
+	app.fireStackWebhooks(stackName, action, err)
 
 	// Schedule terminal cleanup after a grace period
 	app.Terms.RemoveAfter(termName, 30*time.Second)
+	return err
 }
 
 // runUnmanagedStackAction runs a compose command for an unmanaged stack (no
 // compose file on disk) using "docker compose -p <project>". Docker Compose v2
 // discovers containers by their project label, so start/stop/restart/down work
-// without a compose file.
-func (app *App) runUnmanagedStackAction(stackName, action string, composeArgs ...string) {
+// without a compose file. Returns the command's error.
+func (app *App) runUnmanagedStackAction(stackName, action string, composeArgs ...string) error {
 	termName := "compose-" + stackName
 	cmdDisplay := fmt.Sprintf("$ docker compose -p %s %s\r\n", stackName, strings.Join(composeArgs, " "))
 
@@ -654,7 +895,8 @@ func (app *App) runUnmanagedStackAction(stackName, action string, composeArgs ..
 	cmdArgs = append(cmdArgs, composeArgs...)
 	cmd := exec.CommandContext(ctx, "docker", cmdArgs...)
 
-	if err := term.RunPTY(cmd); err != nil {
+	err := term.RunPTY(cmd)
+	if err != nil {
 		if ctx.Err() == nil {
 			errMsg := "\r\n[Error] " + err.Error() + "\r\n"
 			term.Write([]byte(errMsg))
@@ -666,23 +908,51 @@ func (app *App) runUnmanagedStackAction(stackName, action string, composeArgs ..
 
 	// Schedule terminal cleanup after a grace period
 	app.Terms.RemoveAfter(termName, 30*time.Second)
+	return err
 }
 
 // runDeployWithValidation validates the compose file via `docker compose config`
-// and then runs `docker compose up -d --remove-orphans`.
-func (app *App) runDeployWithValidation(stackName string) {
+// and then runs `docker compose up -d --remove-orphans`, building images
+// first if build is set. pull is the pull policy of up, "" for the stack's
+// own. note is recorded on the operation history entry.
+// Returns the error of the failed step; a failed build is a *buildError.
+// The outcome is notified.
+func (app *App) runDeployWithValidation(stackName, note string, build bool, pull string) (err error) {
+	files := app.loadStackFiles(stackName)
+	defer func() {
+		if err == nil {
+			app.recordDeployedCompose(files, "deploy")
+		}
+		app.notifyDeploy(stackName, err)
+		app.fireStackWebhooks(stackName, "deploy", err)
+	}()
+
 	termName := "compose-" + stackName
-	envArgs := compose.GlobalEnvArgs(app.StacksDir, stackName)
-	envDisplay := ""
-	if len(envArgs) > 0 {
-		envDisplay = strings.Join(envArgs, " ") + " "
-	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	timeout := 5 * time.Minute
+	if build {
+		timeout = buildTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
 	term := app.Terms.Recreate(termName, terminal.TypePTY)
 	dir := filepath.Join(app.StacksDir, stackName)
+	op := app.beginOperation(stackName, "deploy", note)
+
+	// Step 0: Resolve secrets of the env files
+	envArgs, cleanup, err := app.composeEnvArgs(ctx, stackName)
+	if err != nil {
+		term.Write([]byte("[Error] " + err.Error() + "\r\n"))
+		slog.Warn("deploy secrets", "stack", stackName, "err", err)
+		app.endOperation(op, err)
+		return err
+	}
+	defer cleanup()
+	envDisplay := ""
+	if len(envArgs) > 0 {
+		envDisplay = strings.Join(envArgs, " ") + " "
+	}
 
 	// Step 1: Validate
 	term.Write([]byte("$ docker compose " + envDisplay + "config --dry-run\r\n"))
@@ -697,19 +967,42 @@ func (app *App) runDeployWithValidation(stackName string) {
 			term.Write([]byte(errMsg))
 			slog.Warn("deploy validation failed", "stack", stackName, "err", err)
 		}
+		err = fmt.Errorf("validation failed: %w", err)
+		app.endOperation(op, err)
 		// The compose file was already saved to disk — fsnotify detects the
 		// new directory and triggers the stacks broadcast automatically.
-		return
+		return err
+	}
+
+	// Step 1b: macvlan/ipvlan parents must exist, or `up` fails with an
+	// opaque netlink error after pulling images. Custom DNS servers and
+	// ports a rootless daemon can't bind are only warned about.
+	if project, err := compose.ResolveConfigWithEnv(ctx, app.StacksDir, stackName, envArgs); err != nil {
+		// Validation already passed; don't block the deploy on these checks.
+		slog.Debug("pre-deploy checks: resolve config", "stack", stackName, "err", err)
+	} else {
+		if !app.checkNetworkParents(term, stackName, project) {
+			err := errors.New("network parent interface missing")
+			app.endOperation(op, err)
+			return err
+		}
+		checkDNSServers(ctx, term, project)
+		app.checkPrivilegedPorts(ctx, term, project)
 	}
 
-	// Step 2: Deploy
-	term.Write([]byte("$ docker compose " + envDisplay + "up -d --remove-orphans\r\n"))
-	upArgs := []string{"compose"}
-	upArgs = append(upArgs, envArgs...)
-	upArgs = append(upArgs, "up", "-d", "--remove-orphans")
-	upCmd := exec.CommandContext(ctx, "docker", upArgs...)
-	upCmd.Dir = dir
-	if err := term.RunPTY(upCmd); err != nil {
+	// Step 2: Build, so a failing build is told apart from failing containers
+	if build {
+		if err := app.runBuildStep(ctx, term, stackName, envArgs, buildOptions{}); err != nil {
+			app.endOperation(op, err)
+			return err
+		}
+	}
+
+	// Step 3: Deploy
+	upArgs := app.upArgs(stackName, pull)
+	term.Write([]byte("$ docker compose " + envDisplay + strings.Join(upArgs, " ") + "\r\n"))
+	err = app.runUp(ctx, term, stackName, "deploy", dir, envArgs, upArgs)
+	if err != nil {
 		if ctx.Err() == nil {
 			errMsg := "\r\n[Error] " + err.Error() + "\r\n"
 			term.Write([]byte(errMsg))
@@ -718,15 +1011,53 @@ func (app *App) runDeployWithValidation(stackName string) {
 	} else {
 		term.Write([]byte("\r\n[Done]\r\n"))
 	}
+	app.endOperation(op, err)
 
 	// Schedule terminal cleanup after a grace period
 	app.Terms.RemoveAfter(termName, 30*time.Second)
+	return err
+}
+
+// checkNetworkParents writes a warning or error to term for each macvlan or
+// ipvlan network whose parent interface is missing. Returns false if the
+// deploy should stop. Inside a container without host networking, Dockge
+// can't see host interfaces, so problems are only warnings there.
+func (app *App) checkNetworkParents(term *terminal.Terminal, stackName string, project *compose.ResolvedProject) bool {
+	problems := project.CheckNetworkParents(compose.HostInterfaceExists)
+	if len(problems) == 0 {
+		return true
+	}
+	_, statErr := os.Stat("/.dockerenv")
+	containerized := statErr == nil
+	for _, p := range problems {
+		if containerized {
+			term.Write([]byte("\r\n[Warning] " + p + "\r\n"))
+		} else {
+			term.Write([]byte("\r\n[Error] " + p + "\r\n"))
+		}
+	}
+	if containerized {
+		term.Write([]byte("[Warning] Dockge runs in a container and may not see host interfaces; continuing.\r\n"))
+		return true
+	}
+	slog.Warn("deploy blocked: invalid network parent", "stack", stackName, "problems", len(problems))
+	return false
 }
 
-// runDockerCommands runs multiple docker commands sequentially on the same terminal.
-func (app *App) runDockerCommands(stackName, action string, argSets [][]string) {
+// checkDNSServers warns about custom dns: servers that don't answer from
+// the host. Containers may still reach them (e.g. a resolver on an internal
+// network), so this never blocks the deploy.
+func checkDNSServers(ctx context.Context, term *terminal.Terminal, project *compose.ResolvedProject) {
+	for _, p := range project.CheckDNSServers(ctx, compose.ProbeDNSServer) {
+		term.Write([]byte("\r\n[Warning] " + p + "\r\n"))
+	}
+}
+
+// runDockerCommands runs multiple docker commands sequentially on the same
+// terminal, stopping at the first failure. Compose progress events are passed
+// to onEvent (if set) along with the compose subcommand that produced them.
+func (app *App) runDockerCommands(stackName, action string, argSets [][]string, onEvent func(subcommand string, ev compose.ProgressEvent)) error {
 	termName := "compose-" + stackName
-	envArgs := compose.GlobalEnvArgs(app.StacksDir, stackName)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
@@ -734,28 +1065,38 @@ func (app *App) runDockerCommands(stackName, action string, argSets [][]string)
 	term := app.Terms.Recreate(termName, terminal.TypePTY)
 	dir := filepath.Join(app.StacksDir, stackName)
 
+	envArgs, cleanup, err := app.composeEnvArgs(ctx, stackName)
+	if err != nil {
+		term.Write([]byte("[Error] " + err.Error() + "\r\n"))
+		slog.Error("compose action", "action", action, "stack", stackName, "err", err)
+		return err
+	}
+	defer cleanup()
+
 	for _, dockerArgs := range argSets {
 		cmdDisplay := "$ docker " + strings.Join(composeEnvDisplay(dockerArgs, envArgs), " ") + "\r\n"
 		term.Write([]byte(cmdDisplay))
 
-		var cmd *exec.Cmd
-		if len(dockerArgs) > 0 && dockerArgs[0] == "compose" && len(envArgs) > 0 {
-			args := []string{"compose"}
-			args = append(args, envArgs...)
-			args = append(args, dockerArgs[1:]...)
-			cmd = exec.CommandContext(ctx, "docker", args...)
+		var err error
+		if len(dockerArgs) > 1 && dockerArgs[0] == "compose" {
+			var observe func(compose.ProgressEvent)
+			if onEvent != nil {
+				subcommand := dockerArgs[1]
+				observe = func(ev compose.ProgressEvent) { onEvent(subcommand, ev) }
+			}
+			err = app.runCompose(ctx, term, stackName, action, dir, envArgs, dockerArgs[1:], observe)
 		} else {
-			cmd = exec.CommandContext(ctx, "docker", dockerArgs...)
+			cmd := exec.CommandContext(ctx, "docker", dockerArgs...)
+			cmd.Dir = dir
+			err = term.RunPTY(cmd)
 		}
-		cmd.Dir = dir
-
-		if err := term.RunPTY(cmd); err != nil {
+		if err != nil {
 			if ctx.Err() == nil {
 				errMsg := "\r\n[Error] " + err.Error() + "\r\n"
 				term.Write([]byte(errMsg))
 				slog.Error("compose action", "action", action, "stack", stackName, "err", err)
 			}
-			return
+			return err
 		}
 	}
 
@@ -763,6 +1104,7 @@ func (app *App) runDockerCommands(stackName, action string, argSets [][]string)
 
 	// Schedule terminal cleanup after a grace period
 	app.Terms.RemoveAfter(termName, 30*time.Second)
+	return nil
 }
 
 // handleComposeYAMLSave handles side effects of saving compose YAML:
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/docker"
+	"github.com/cfilipov/dockge/internal/models"
+)
+
+// stackEventState remembers which containers died without being stopped,
+// so the start that follows can be counted as a restart.
+type stackEventState struct {
+	mu   sync.Mutex
+	died map[string]bool // by container ID
+}
+
+// recordStackEvent counts the container events that point to an unstable
+// stack: restarts, by hand or by restart policy (a die followed by a start
+// with no stop in between), and health checks turning unhealthy.
+func (app *App) recordStackEvent(evt docker.DockerEvent) {
+	if app.StackEvents == nil || evt.Type != "container" || evt.Project == "" {
+		return
+	}
+
+	var kind models.StackEventKind
+	switch evt.Action {
+	case "restart":
+		kind = models.StackEventRestart
+	case "health_status: unhealthy":
+		kind = models.StackEventHealthFlap
+	case "die", "stop", "start", "destroy":
+		st := &app.stackEvents
+		st.mu.Lock()
+		restarted := evt.Action == "start" && st.died[evt.ContainerID]
+		switch evt.Action {
+		case "die":
+			if st.died == nil {
+				st.died = make(map[string]bool)
+			}
+			st.died[evt.ContainerID] = true
+		default:
+			delete(st.died, evt.ContainerID)
+		}
+		st.mu.Unlock()
+		if !restarted {
+			return
+		}
+		kind = models.StackEventRestart
+	default:
+		return
+	}
+
+	if err := app.StackEvents.Record(evt.Project, kind, time.Now()); err != nil {
+		slog.Warn("record stack event", "stack", evt.Project, "action", evt.Action, "err", err)
+	}
+}
+
+// stackEventHistogram returns a stack's hourly restart and health flap
+// counts over the last day, or nil if they aren't recorded.
+func (app *App) stackEventHistogram(stackName string) []models.StackEventHour {
+	if app.StackEvents == nil {
+		return nil
+	}
+	hours, err := app.StackEvents.Histogram(stackName, time.Now())
+	if err != nil {
+		slog.Warn("get stack events", "err", err, "stack", stackName)
+		return nil
+	}
+	return hours
+}
+
+// deleteStackEvents drops the event counts of a stack whose files were
+// removed.
+func (app *App) deleteStackEvents(stackName string) {
+	if app.StackEvents == nil {
+		return
+	}
+	if err := app.StackEvents.Delete(stackName); err != nil {
+		slog.Warn("delete stack events", "err", err, "stack", stackName)
+	}
+}
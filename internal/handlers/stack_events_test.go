@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/db"
+	"github.com/cfilipov/dockge/internal/docker"
+	"github.com/cfilipov/dockge/internal/models"
+)
+
+func TestRecordStackEvent(t *testing.T) {
+	t.Parallel()
+	database, err := db.Open(filepath.Join(t.TempDir(), "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	app := &App{StackEvents: models.NewStackEventStore(database)}
+
+	send := func(id string, actions ...string) {
+		for _, action := range actions {
+			app.recordStackEvent(docker.DockerEvent{Type: "container", Action: action, Project: "web", ContainerID: id})
+		}
+	}
+	send("a", "die", "start")                            // restarted by policy
+	send("b", "kill", "die", "stop", "start", "restart") // docker restart
+	send("c", "die", "stop", "start")                    // stopped, then started by hand
+	send("d", "health_status: healthy", "health_status: unhealthy")
+
+	hours, err := app.StackEvents.Histogram("web", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := hours[len(hours)-1]; last.Restarts != 2 || last.HealthFlaps != 1 {
+		t.Errorf("current hour = %+v, want 2 restarts and 1 health flap", last)
+	}
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// RegisterStackFileHandlers registers the handlers of the file manager of
+// stack directories, for the config files stacks bind-mount next to their
+// compose files. Paths are relative to the stack directory; the compose,
+// override and .env files are left to saveStack.
+func RegisterStackFileHandlers(app *App) {
+	app.WS.Handle("listStackFiles", app.handleListStackFiles)
+	app.WS.Handle("getStackFile", app.handleGetStackFile)
+	app.WS.Handle("saveStackFile", app.handleSaveStackFile)
+	app.WS.Handle("renameStackFile", app.handleRenameStackFile)
+	app.WS.Handle("deleteStackFile", app.handleDeleteStackFile)
+}
+
+// stackFileArgs reads the stack name and the cleaned paths at the given
+// argument positions, acking an error if one is invalid.
+func stackFileArgs(c *ws.Conn, msg *ws.ClientMessage, pathArgs ...int) (string, []string, bool) {
+	args := parseArgs(msg)
+	stackName := argString(args, 0)
+	fail := func(err error) (string, []string, bool) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return "", nil, false
+	}
+	if err := stack.ValidateStackName(stackName); err != nil {
+		return fail(err)
+	}
+	paths := make([]string, 0, len(pathArgs))
+	for _, i := range pathArgs {
+		p, err := stack.CleanFilePath(argString(args, i))
+		if err != nil {
+			return fail(err)
+		}
+		paths = append(paths, p)
+	}
+	return stackName, paths, true
+}
+
+// stackFileError acks the error of a file operation, with a plain message
+// for files that don't exist.
+func stackFileError(c *ws.Conn, msg *ws.ClientMessage, err error) {
+	text := err.Error()
+	if errors.Is(err, fs.ErrNotExist) {
+		text = "File not found"
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: text})
+	}
+}
+
+// rejectStackFileChange acks an error and returns true if the current user
+// can't change the files of a stack: it's archived, or their changes need
+// approval, which only covers the files saveStack writes.
+func (app *App) rejectStackFileChange(c *ws.Conn, msg *ws.ClientMessage, stackName string) bool {
+	if app.rejectArchived(c, msg, stackName) {
+		return true
+	}
+	if _, ok := app.approvalRequired(c); ok {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "File changes can't be submitted for approval"})
+		}
+		return true
+	}
+	return false
+}
+
+// handleListStackFiles lists a directory of a stack. Args: stack name,
+// path ("" for the stack directory).
+func (app *App) handleListStackFiles(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	stackName, paths, ok := stackFileArgs(c, msg, 1)
+	if !ok {
+		return
+	}
+	entries, truncated, err := stack.ListFiles(app.StacksDir, stackName, paths[0])
+	if err != nil {
+		stackFileError(c, msg, err)
+		return
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK        bool              `json:"ok"`
+			Path      string            `json:"path"`
+			Entries   []stack.FileEntry `json:"entries"`
+			Truncated bool              `json:"truncated"`
+		}{OK: true, Path: paths[0], Entries: entries, Truncated: truncated})
+	}
+}
+
+// handleGetStackFile returns the contents of a text file of a stack.
+// Args: stack name, path.
+func (app *App) handleGetStackFile(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	stackName, paths, ok := stackFileArgs(c, msg, 1)
+	if !ok {
+		return
+	}
+	content, err := stack.ReadTextFile(app.StacksDir, stackName, paths[0])
+	if err != nil {
+		stackFileError(c, msg, err)
+		return
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK      bool   `json:"ok"`
+			Path    string `json:"path"`
+			Content string `json:"content"`
+		}{OK: true, Path: paths[0], Content: content})
+	}
+}
+
+// handleSaveStackFile creates or overwrites a text file of a stack.
+// Args: stack name, path, content.
+func (app *App) handleSaveStackFile(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	stackName, paths, ok := stackFileArgs(c, msg, 1)
+	if !ok || app.rejectStackFileChange(c, msg, stackName) {
+		return
+	}
+	content := argString(parseArgs(msg), 2)
+
+	app.StackLocks.Lock(stackName)
+	err := stack.WriteTextFile(app.StacksDir, stackName, paths[0], content)
+	app.StackLocks.Unlock(stackName)
+	if err != nil {
+		slog.Warn("save stack file", "err", err, "stack", stackName, "path", paths[0])
+		stackFileError(c, msg, err)
+		return
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Saved"})
+	}
+}
+
+// handleRenameStackFile moves a file or directory of a stack.
+// Args: stack name, path, new path.
+func (app *App) handleRenameStackFile(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	stackName, paths, ok := stackFileArgs(c, msg, 1, 2)
+	if !ok || app.rejectStackFileChange(c, msg, stackName) {
+		return
+	}
+
+	app.StackLocks.Lock(stackName)
+	err := stack.RenameFile(app.StacksDir, stackName, paths[0], paths[1])
+	app.StackLocks.Unlock(stackName)
+	if err != nil {
+		slog.Warn("rename stack file", "err", err, "stack", stackName, "path", paths[0], "to", paths[1])
+		stackFileError(c, msg, err)
+		return
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Renamed"})
+	}
+}
+
+// handleDeleteStackFile removes a file or an empty directory of a stack.
+// Args: stack name, path.
+func (app *App) handleDeleteStackFile(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	stackName, paths, ok := stackFileArgs(c, msg, 1)
+	if !ok || app.rejectStackFileChange(c, msg, stackName) {
+		return
+	}
+
+	app.StackLocks.Lock(stackName)
+	err := stack.DeleteFile(app.StacksDir, stackName, paths[0])
+	app.StackLocks.Unlock(stackName)
+	if err != nil {
+		slog.Warn("delete stack file", "err", err, "stack", stackName, "path", paths[0])
+		stackFileError(c, msg, err)
+		return
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Deleted"})
+	}
+}
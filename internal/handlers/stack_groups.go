@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// maxStackGroupNameLen bounds a group name; it's a heading in the stack list.
+const maxStackGroupNameLen = 64
+
+// RegisterStackGroupHandlers registers the stack group handlers.
+func RegisterStackGroupHandlers(app *App) {
+	app.WS.Handle("getStackGroups", app.handleGetStackGroups)
+	app.WS.Handle("createStackGroup", app.handleCreateStackGroup)
+	app.WS.Handle("updateStackGroup", app.handleUpdateStackGroup)
+	app.WS.Handle("deleteStackGroup", app.handleDeleteStackGroup)
+	app.WS.Handle("reorderStackGroups", app.handleReorderStackGroups)
+	app.WS.Handle("stackGroupAction", app.handleStackGroupAction)
+}
+
+// stackGroups returns all groups in display order, or none if groups are
+// disabled.
+func (app *App) stackGroups() []models.StackGroup {
+	if app.StackGroups == nil {
+		return []models.StackGroup{}
+	}
+	groups, err := app.StackGroups.List()
+	if err != nil {
+		slog.Warn("list stack groups", "err", err)
+		return []models.StackGroup{}
+	}
+	return groups
+}
+
+// stackGroupsPayload is the "stackGroups" event.
+type stackGroupsPayload struct {
+	Groups []models.StackGroup `json:"groups"`
+}
+
+// broadcastStackGroups sends every group to all clients, so the stack list
+// is grouped the same in every browser.
+func (app *App) broadcastStackGroups() {
+	ws.BroadcastAuthenticated(app.WS, "stackGroups", stackGroupsPayload{app.stackGroups()})
+}
+
+// removeFromStackGroups takes a deleted stack out of its group.
+func (app *App) removeFromStackGroups(stackName string) {
+	if app.StackGroups == nil {
+		return
+	}
+	removed, err := app.StackGroups.RemoveStack(stackName)
+	if err != nil {
+		slog.Warn("remove stack from groups", "err", err, "stack", stackName)
+		return
+	}
+	if removed {
+		app.broadcastStackGroups()
+	}
+}
+
+// checkStackGroups verifies that stack groups are available.
+func (app *App) checkStackGroups(c *ws.Conn, msg *ws.ClientMessage) bool {
+	if app.StackGroups != nil {
+		return true
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Stack groups are not available"})
+	}
+	return false
+}
+
+// validateStackGroupName trims a group name and checks that it's set, not
+// too long, and not used by another group.
+func (app *App) validateStackGroupName(name string, id int) (string, error) {
+	name = strings.TrimSpace(name)
+	switch {
+	case name == "":
+		return "", errors.New("Group name required")
+	case len(name) > maxStackGroupNameLen:
+		return "", errors.New("Group name is too long")
+	}
+	for _, g := range app.stackGroups() {
+		if g.ID != id && strings.EqualFold(g.Name, name) {
+			return "", errors.New("A group with this name already exists")
+		}
+	}
+	return name, nil
+}
+
+func (app *App) handleGetStackGroups(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK bool `json:"ok"`
+			stackGroupsPayload
+		}{true, stackGroupsPayload{app.stackGroups()}})
+	}
+}
+
+// handleCreateStackGroup adds an empty group at the end of the list.
+// Args: name.
+func (app *App) handleCreateStackGroup(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 || !app.checkStackGroups(c, msg) {
+		return
+	}
+	name, err := app.validateStackGroupName(argString(parseArgs(msg), 0), 0)
+	if err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+
+	g, err := app.StackGroups.Create(name)
+	if err != nil {
+		slog.Error("create stack group", "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+	app.broadcastStackGroups()
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK    bool               `json:"ok"`
+			Group *models.StackGroup `json:"group"`
+		}{true, g})
+	}
+}
+
+// handleUpdateStackGroup renames a group and sets its stacks; stacks in
+// another group move to this one. Args: id, {name, stacks}.
+func (app *App) handleUpdateStackGroup(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 || !app.checkStackGroups(c, msg) {
+		return
+	}
+	args := parseArgs(msg)
+	id := argInt(args, 0)
+	var opts struct {
+		Name   string   `json:"name"`
+		Stacks []string `json:"stacks"`
+	}
+	argObject(args, 1, &opts)
+
+	name, err := app.validateStackGroupName(opts.Name, id)
+	if err == nil {
+		for _, s := range opts.Stacks {
+			if err = stack.ValidateStackName(s); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+
+	if err := app.StackGroups.Update(id, name, opts.Stacks); err != nil {
+		if errors.Is(err, models.ErrStackGroupNotFound) {
+			if msg.ID != nil {
+				ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Group not found"})
+			}
+			return
+		}
+		slog.Error("update stack group", "err", err, "group", id)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+	app.broadcastStackGroups()
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Saved"})
+	}
+}
+
+// handleDeleteStackGroup removes a group; its stacks become ungrouped.
+// Args: id.
+func (app *App) handleDeleteStackGroup(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 || !app.checkStackGroups(c, msg) {
+		return
+	}
+	id := argInt(parseArgs(msg), 0)
+	if err := app.StackGroups.Delete(id); err != nil {
+		slog.Error("delete stack group", "err", err, "group", id)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+	app.broadcastStackGroups()
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Deleted"})
+	}
+}
+
+// handleReorderStackGroups sets the display order of the groups.
+// Args: group IDs in order.
+func (app *App) handleReorderStackGroups(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 || !app.checkStackGroups(c, msg) {
+		return
+	}
+	var ids []int
+	argObject(parseArgs(msg), 0, &ids)
+	if err := app.StackGroups.Reorder(ids); err != nil {
+		slog.Error("reorder stack groups", "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+	app.broadcastStackGroups()
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true})
+	}
+}
+
+// handleStackGroupAction starts, stops or updates a group's stacks in
+// dependency order, like startAllStacks. Admin only.
+// Args: id, action ("start", "stop", or "update"), options {exclude, parallelism}.
+func (app *App) handleStackGroupAction(c *ws.Conn, msg *ws.ClientMessage) {
+	if app.checkAdmin(c, msg) == nil || !app.checkStackGroups(c, msg) {
+		return
+	}
+	args := parseArgs(msg)
+	id := argInt(args, 0)
+	action := argString(args, 1)
+	var opts updateAllOptions
+	argObject(args, 2, &opts)
+
+	if action != "start" && action != "stop" && action != "update" {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Unknown action " + action})
+		}
+		return
+	}
+	g, err := app.StackGroups.Get(id)
+	if err != nil || g == nil {
+		if err != nil {
+			slog.Error("get stack group", "err", err, "group", id)
+		}
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Group not found"})
+		}
+		return
+	}
+	app.launchBulkStacks(c, msg, action, g.ID, g.Stacks, opts)
+}
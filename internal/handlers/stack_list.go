@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cfilipov/dockge/internal/docker"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// Stack list page sizes.
+const (
+	defaultStackListPageSize = 50
+	maxStackListPageSize     = 500
+)
+
+// stackListState numbers the states of the stacks channel, so broadcasts
+// carry only the stacks that changed, and keeps the connections subscribed
+// to a filtered page of the stack list.
+type stackListState struct {
+	mu       sync.Mutex
+	revision uint64 // bumped on every change
+	entries  map[string]StackBroadcastEntry
+	subs     map[string]*stackListSub
+}
+
+// stackListSub is a connection following a page of the stack list.
+type stackListSub struct {
+	conn  *ws.Conn
+	query stackListQuery
+	last  []byte // the page last sent, to skip unchanged ones
+}
+
+// stackListQuery filters and pages the stack list.
+type stackListQuery struct {
+	Status     []string `json:"status"`     // status labels (active, partially, exited, unhealthy, down); empty = any
+	Prefix     string   `json:"prefix"`     // name prefix, case-insensitive
+	Group      *int     `json:"group"`      // stack group ID, 0 = ungrouped; nil = any
+	HasUpdates bool     `json:"hasUpdates"` // only stacks with image updates
+	PageSize   int      `json:"pageSize"`
+	Page       int      `json:"page"` // 0-based
+}
+
+// stackListItem is a stack of a stack list page, with what the frontend
+// would otherwise derive from the other channels.
+type stackListItem struct {
+	StackBroadcastEntry
+	Status     string `json:"status"`
+	HasUpdates bool   `json:"hasUpdates"`
+	Group      int    `json:"group,omitempty"`
+}
+
+// stackListPage is one page of the filtered stack list, sorted by name.
+type stackListPage struct {
+	Revision uint64          `json:"revision"`
+	Total    int             `json:"total"` // stacks matching the filter
+	Page     int             `json:"page"`
+	PageSize int             `json:"pageSize"`
+	Stacks   []stackListItem `json:"stacks"`
+}
+
+// RegisterStackListHandlers registers the stack list resync and query
+// subscription handlers.
+func RegisterStackListHandlers(app *App) {
+	app.WS.Handle("getStackList", app.handleGetStackList)
+	app.WS.Handle("subscribeStackList", app.handleSubscribeStackList)
+	app.WS.Handle("unsubscribeStackList", app.handleUnsubscribeStackList)
+}
+
+// update records the current stacks and returns the ones that changed
+// since the last state, with nil for removed ones, and the revisions
+// before and after. If nothing changed, changed is empty and the revision
+// stays.
+func (s *stackListState) update(entries []StackBroadcastEntry) (changed map[string]any, since, revision uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed = make(map[string]any)
+	current := make(map[string]StackBroadcastEntry, len(entries))
+	for _, e := range entries {
+		current[e.Name] = e
+		if old, ok := s.entries[e.Name]; !ok || !reflect.DeepEqual(old, e) {
+			changed[e.Name] = e
+		}
+	}
+	for name := range s.entries {
+		if _, ok := current[name]; !ok {
+			changed[name] = nil
+		}
+	}
+	since = s.revision
+	if len(changed) > 0 || s.entries == nil {
+		s.revision++
+	}
+	s.entries = current
+	return changed, since, s.revision
+}
+
+// seeded reports whether the stacks were built at least once.
+func (s *stackListState) seeded() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entries != nil
+}
+
+// full returns every stack of the current state and its revision.
+func (s *stackListState) full() (map[string]any, uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items := make(map[string]any, len(s.entries))
+	for name, e := range s.entries {
+		items[name] = e
+	}
+	return items, s.revision
+}
+
+// refreshStackList rebuilds the stacks and broadcasts the ones that
+// changed, then updates the stack list subscriptions.
+func (app *App) refreshStackList() {
+	entries := app.stackBroadcastEntries()
+	changed, since, revision := app.stackList.update(entries)
+	if len(changed) > 0 || !app.replay.has(chanStacks) {
+		app.replay.replace(chanStacks, stacksToMap(entries))
+	}
+	if len(changed) > 0 {
+		ws.BroadcastAuthenticated(app.WS, chanStacks, ChannelBroadcast{Items: changed, Revision: revision, Since: since})
+		app.BcastMetrics.recordSent(chanStacks)
+	}
+	app.refreshStackListSubs()
+}
+
+// sendFullStackList sends a connection every stack and the revision, for
+// it to replace its stack list with.
+func (app *App) sendFullStackList(c *ws.Conn) {
+	items, revision := app.stackList.full()
+	ws.SendEvent(c, chanStacks, ChannelBroadcast{Items: items, Revision: revision, Full: true})
+}
+
+// stackListInputs gathers what stack list queries filter on: the stacks,
+// their status labels, the stacks with image updates and each stack's group.
+func (app *App) stackListInputs() (entries map[string]StackBroadcastEntry, revision uint64, statuses map[string]string, updates map[string]bool, groups map[string]int) {
+	app.stackList.mu.Lock()
+	entries, revision = app.stackList.entries, app.stackList.revision
+	app.stackList.mu.Unlock()
+	statuses = stackStatusLabels(entries, app.replay.snapshot()[chanContainers])
+	groups = make(map[string]int)
+	for _, g := range app.stackGroups() {
+		for _, name := range g.Stacks {
+			groups[name] = g.ID
+		}
+	}
+	return entries, revision, statuses, app.GetImageUpdateMap(), groups
+}
+
+// queryStackList returns the page of the stacks matching q.
+func queryStackList(entries map[string]StackBroadcastEntry, revision uint64, statuses map[string]string, updates map[string]bool, groups map[string]int, q stackListQuery) stackListPage {
+	prefix := strings.ToLower(q.Prefix)
+	matched := []stackListItem{}
+	for name, e := range entries {
+		item := stackListItem{StackBroadcastEntry: e, Status: statuses[name], HasUpdates: updates[name], Group: groups[name]}
+		switch {
+		case len(q.Status) > 0 && !slices.Contains(q.Status, item.Status):
+		case prefix != "" && !strings.HasPrefix(strings.ToLower(name), prefix):
+		case q.Group != nil && item.Group != *q.Group:
+		case q.HasUpdates && !item.HasUpdates:
+		default:
+			matched = append(matched, item)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].Name < matched[j].Name })
+
+	page := stackListPage{Revision: revision, Total: len(matched), Page: q.Page, PageSize: q.PageSize}
+	start := min(q.Page*q.PageSize, len(matched))
+	page.Stacks = matched[start:min(start+q.PageSize, len(matched))]
+	return page
+}
+
+// fingerprint identifies the content of a page, but not the revision, which
+// also changes for stacks off the page.
+func (p stackListPage) fingerprint() []byte {
+	p.Revision = 0
+	data, _ := json.Marshal(p)
+	return data
+}
+
+// stackStatusLabels derives the status label of each stack from its
+// containers, the same way as the frontend's deriveStatus. Job containers
+// only count while running. Managed stacks without containers are down.
+func stackStatusLabels(managed map[string]StackBroadcastEntry, containers map[string]any) map[string]string {
+	byStack := make(map[string]*stackStateCount)
+	for _, v := range containers {
+		c, ok := v.(docker.ContainerBroadcast)
+		if !ok || c.StackName == "" {
+			continue
+		}
+		if _, ok := managed[c.StackName]; !ok && c.StackName == "dockge" {
+			continue
+		}
+		if e := managed[c.StackName]; e.IgnoreStatus[c.ServiceName] || (e.Jobs[c.ServiceName] && c.State != "running") {
+			continue
+		}
+		counts := byStack[c.StackName]
+		if counts == nil {
+			counts = &stackStateCount{}
+			byStack[c.StackName] = counts
+		}
+		counts.add(c)
+	}
+	labels := make(map[string]string, len(managed))
+	for name := range managed {
+		labels[name] = "down"
+	}
+	for name, counts := range byStack {
+		labels[name] = counts.label()
+	}
+	return labels
+}
+
+// refreshStackListSubs sends each subscribed connection its page, if it
+// changed since last sent.
+func (app *App) refreshStackListSubs() {
+	st := &app.stackList
+	st.mu.Lock()
+	subs := make([]*stackListSub, 0, len(st.subs))
+	for _, sub := range st.subs {
+		subs = append(subs, sub)
+	}
+	st.mu.Unlock()
+	if len(subs) == 0 {
+		return
+	}
+
+	entries, revision, statuses, updates, groups := app.stackListInputs()
+	for _, sub := range subs {
+		page := queryStackList(entries, revision, statuses, updates, groups, sub.query)
+		data := page.fingerprint()
+		st.mu.Lock()
+		unchanged := bytes.Equal(data, sub.last)
+		sub.last = data
+		current := st.subs[sub.conn.ID()] == sub
+		st.mu.Unlock()
+		if !unchanged && current {
+			ws.SendEvent(sub.conn, "stackListPage", page)
+		}
+	}
+}
+
+// handleGetStackList returns every stack and the revision, for clients
+// that missed a delta broadcast of the stacks channel.
+func (app *App) handleGetStackList(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	if !app.stackList.seeded() {
+		app.refreshStackList()
+	}
+	items, revision := app.stackList.full()
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK       bool           `json:"ok"`
+			Revision uint64         `json:"revision"`
+			Items    map[string]any `json:"items"`
+		}{true, revision, items})
+	}
+}
+
+// handleSubscribeStackList acks with a page of the stacks matching a filter
+// and pushes the page as "stackListPage" whenever it changes, until
+// unsubscribed. Subscribing again replaces the query, e.g. to turn pages.
+// Args: query {status, prefix, group, hasUpdates, pageSize, page}.
+func (app *App) handleSubscribeStackList(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	var q stackListQuery
+	argObject(parseArgs(msg), 0, &q)
+	if q.PageSize <= 0 {
+		q.PageSize = defaultStackListPageSize
+	}
+	q.PageSize = min(q.PageSize, maxStackListPageSize)
+	q.Page = max(q.Page, 0)
+
+	app.seedReplay()
+	if !app.stackList.seeded() {
+		app.refreshStackList()
+	}
+
+	entries, revision, statuses, updates, groups := app.stackListInputs()
+	page := queryStackList(entries, revision, statuses, updates, groups, q)
+	last := page.fingerprint()
+
+	st := &app.stackList
+	st.mu.Lock()
+	if st.subs == nil {
+		st.subs = make(map[string]*stackListSub)
+	}
+	st.subs[c.ID()] = &stackListSub{conn: c, query: q, last: last}
+	st.mu.Unlock()
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK bool `json:"ok"`
+			stackListPage
+		}{true, page})
+	}
+}
+
+// handleUnsubscribeStackList stops pushing stack list pages to the
+// connection.
+func (app *App) handleUnsubscribeStackList(c *ws.Conn, msg *ws.ClientMessage) {
+	app.CancelStackListSub(c.ID())
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true})
+	}
+}
+
+// CancelStackListSub drops the stack list subscription of a connection,
+// for disconnect callbacks.
+func (app *App) CancelStackListSub(connID string) {
+	st := &app.stackList
+	st.mu.Lock()
+	delete(st.subs, connID)
+	st.mu.Unlock()
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/cfilipov/dockge/internal/docker"
+)
+
+func TestStackListStateUpdate(t *testing.T) {
+	t.Parallel()
+	var s stackListState
+	web := StackBroadcastEntry{Name: "web", ComposeFileName: "compose.yaml", IsManagedByDockge: true}
+	db := StackBroadcastEntry{Name: "db", ComposeFileName: "compose.yaml", IsManagedByDockge: true}
+
+	changed, since, revision := s.update(nil)
+	if len(changed) != 0 || since != 0 || revision != 1 || !s.seeded() {
+		t.Fatalf("first empty build: changed = %v, since = %d, revision = %d", changed, since, revision)
+	}
+
+	changed, since, revision = s.update([]StackBroadcastEntry{web, db})
+	if len(changed) != 2 || since != 1 || revision != 2 {
+		t.Fatalf("added stacks: changed = %v, since = %d, revision = %d", changed, since, revision)
+	}
+
+	changed, _, revision = s.update([]StackBroadcastEntry{web, db})
+	if len(changed) != 0 || revision != 2 {
+		t.Errorf("unchanged stacks: changed = %v, revision = %d", changed, revision)
+	}
+
+	web.Archived = true
+	changed, since, revision = s.update([]StackBroadcastEntry{web})
+	if len(changed) != 2 || since != 2 || revision != 3 {
+		t.Fatalf("changed stacks: changed = %v, since = %d, revision = %d", changed, since, revision)
+	}
+	if e, ok := changed["web"].(StackBroadcastEntry); !ok || !e.Archived {
+		t.Errorf("changed[web] = %v", changed["web"])
+	}
+	if v, ok := changed["db"]; !ok || v != nil {
+		t.Errorf("removed stack: changed[db] = %v, %v, want nil", v, ok)
+	}
+
+	items, revision := s.full()
+	if len(items) != 1 || items["web"] == nil || revision != 3 {
+		t.Errorf("full = %v, %d", items, revision)
+	}
+}
+
+func TestQueryStackList(t *testing.T) {
+	t.Parallel()
+	entries := map[string]StackBroadcastEntry{}
+	for _, name := range []string{"app-web", "app-db", "Blog", "cache", "mail"} {
+		entries[name] = StackBroadcastEntry{Name: name}
+	}
+	statuses := map[string]string{"app-web": "active", "app-db": "exited", "Blog": "active", "cache": "down", "mail": "unhealthy"}
+	updates := map[string]bool{"app-web": true, "cache": true}
+	groups := map[string]int{"app-web": 1, "app-db": 1, "mail": 2}
+	one, none := 1, 0
+
+	names := func(p stackListPage) []string {
+		var out []string
+		for _, s := range p.Stacks {
+			out = append(out, s.Name)
+		}
+		return out
+	}
+
+	for _, tc := range []struct {
+		name  string
+		q     stackListQuery
+		want  []string
+		total int
+	}{
+		{"all", stackListQuery{PageSize: 10}, []string{"Blog", "app-db", "app-web", "cache", "mail"}, 5},
+		{"status", stackListQuery{Status: []string{"active", "unhealthy"}, PageSize: 10}, []string{"Blog", "app-web", "mail"}, 3},
+		{"prefix", stackListQuery{Prefix: "APP-", PageSize: 10}, []string{"app-db", "app-web"}, 2},
+		{"group", stackListQuery{Group: &one, PageSize: 10}, []string{"app-db", "app-web"}, 2},
+		{"ungrouped", stackListQuery{Group: &none, PageSize: 10}, []string{"Blog", "cache"}, 2},
+		{"updates", stackListQuery{HasUpdates: true, PageSize: 10}, []string{"app-web", "cache"}, 2},
+		{"combined", stackListQuery{Prefix: "app", HasUpdates: true, Status: []string{"active"}, PageSize: 10}, []string{"app-web"}, 1},
+		{"page", stackListQuery{PageSize: 2, Page: 1}, []string{"app-web", "cache"}, 5},
+		{"last page", stackListQuery{PageSize: 2, Page: 2}, []string{"mail"}, 5},
+		{"past the end", stackListQuery{PageSize: 2, Page: 5}, nil, 5},
+	} {
+		page := queryStackList(entries, 7, statuses, updates, groups, tc.q)
+		if got := names(page); page.Total != tc.total || !slices.Equal(got, tc.want) {
+			t.Errorf("%s: stacks = %v, total = %d, want %v, %d", tc.name, got, page.Total, tc.want, tc.total)
+		}
+		if page.Revision != 7 {
+			t.Errorf("%s: revision = %d, want 7", tc.name, page.Revision)
+		}
+	}
+
+	page := queryStackList(entries, 7, statuses, updates, groups, stackListQuery{Prefix: "app-web", PageSize: 10})
+	if item := page.Stacks[0]; item.Status != "active" || !item.HasUpdates || item.Group != 1 {
+		t.Errorf("item = %+v", item)
+	}
+	other := queryStackList(entries, 8, statuses, updates, groups, stackListQuery{Prefix: "app-web", PageSize: 10})
+	if string(page.fingerprint()) != string(other.fingerprint()) {
+		t.Error("fingerprint changed with only the revision")
+	}
+}
+
+func TestStackStatusLabelsJobs(t *testing.T) {
+	t.Parallel()
+	managed := map[string]StackBroadcastEntry{
+		"app":   {Name: "app", Jobs: map[string]bool{"backup": true}},
+		"batch": {Name: "batch", Jobs: map[string]bool{"import": true}},
+	}
+	containers := map[string]any{
+		"app-web-1":      docker.ContainerBroadcast{StackName: "app", ServiceName: "web", State: "running"},
+		"app-backup-1":   docker.ContainerBroadcast{StackName: "app", ServiceName: "backup", State: "exited"},
+		"batch-import-1": docker.ContainerBroadcast{StackName: "batch", ServiceName: "import", State: "running"},
+	}
+	labels := stackStatusLabels(managed, containers)
+	if labels["app"] != "active" {
+		t.Errorf("app with a finished job = %q, want active", labels["app"])
+	}
+	if labels["batch"] != "active" {
+		t.Errorf("batch with a running job = %q, want active", labels["batch"])
+	}
+
+	containers["batch-import-1"] = docker.ContainerBroadcast{StackName: "batch", ServiceName: "import", State: "exited"}
+	if got := stackStatusLabels(managed, containers)["batch"]; got != "down" {
+		t.Errorf("batch with only finished jobs = %q, want down", got)
+	}
+}
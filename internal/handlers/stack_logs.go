@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/cfilipov/dockge/internal/docker"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/terminal"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// maxStackLogsTail bounds the historical lines per container of a
+// followStackLogs stream.
+const maxStackLogsTail = 1000
+
+// followStackLogsArgs are the options of a followStackLogs request.
+type followStackLogsArgs struct {
+	Services    []string `json:"services"`    // services to show, empty for all
+	Tail        *int     `json:"tail"`        // historical lines per container, default 100
+	Stream      string   `json:"stream"`      // docker.LogStdout or docker.LogStderr for that stream only
+	FlowControl bool     `json:"flowControl"` // see ws.TermSession
+}
+
+// RegisterStackLogHandlers registers followStackLogs. The streams end with
+// terminalLeave, like terminal sessions.
+func RegisterStackLogHandlers(app *App) {
+	app.WS.Handle("followStackLogs", app.handleFollowStackLogs)
+}
+
+// handleFollowStackLogs streams the logs of a stack's containers as one
+// terminal session, each line prefixed with its colored service name, like
+// `docker compose logs -f`. Unlike the shared "combined" terminal, every
+// call gets its own log streams, so a client that falls behind only slows
+// its own: with flow control its reads of the Docker streams wait for its
+// acks, without it for its socket writes.
+// Args: stack name, followStackLogsArgs.
+func (app *App) handleFollowStackLogs(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	args := parseArgs(msg)
+	stackName := argString(args, 0)
+	var req followStackLogsArgs
+	argObject(args, 1, &req)
+	if err := stack.ValidateStackName(stackName); err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	if req.Stream != "" && req.Stream != docker.LogStdout && req.Stream != docker.LogStderr {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Invalid stream: " + req.Stream})
+		}
+		return
+	}
+
+	opts := defaultCombinedLogOptions
+	opts.Stream = req.Stream
+	if req.Tail != nil {
+		opts.Tail = min(max(*req.Tail, 0), maxStackLogsTail)
+	}
+	if len(req.Services) > 0 {
+		opts.Services = make(map[string]bool, len(req.Services))
+		for _, s := range req.Services {
+			opts.Services[s] = true
+		}
+	}
+
+	session := &ws.TermSession{FlowControl: req.FlowControl}
+	sessionID := c.AllocSession(session)
+	session.TermName = "stack-logs-" + stackName + "-" + session.WriterKey
+
+	term := app.Terms.Create(session.TermName, terminal.TypePipe)
+	ctx, cancel := context.WithCancel(context.Background())
+	term.SetCancel(cancel)
+	term.AddWriter(session.WriterKey, sessionBinaryWriter(c, sessionID))
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.TerminalJoinResponse{OK: true, SessionID: sessionID, StreamID: term.StreamID()})
+	}
+	app.trackFollower(followerStackLogs, term, cancel, func() {
+		app.runCombinedLogs(ctx, term, stackName, opts)
+	})
+}
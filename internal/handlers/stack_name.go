@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// stackNameErrorResponse acks a name that can't be used for a new stack.
+// Msg is an i18n key with values; Code is the same key for clients that
+// react to the reason.
+type stackNameErrorResponse struct {
+	OK      bool          `json:"ok"`
+	Msg     stackNameI18n `json:"msg"`
+	MsgI18n bool          `json:"msgi18n"`
+	Code    string        `json:"code"`
+}
+
+type stackNameI18n struct {
+	Key    string            `json:"key"`
+	Values map[string]string `json:"values"`
+}
+
+// checkNewStackName validates the name of a stack about to be created.
+// It returns nil if the name can be used, or the ack explaining why not.
+func (app *App) checkNewStackName(name string) any {
+	err := stack.ValidateNewStackName(app.StacksDir, name)
+	if err == nil {
+		return nil
+	}
+	var nameErr *stack.NameError
+	if !errors.As(err, &nameErr) {
+		slog.Error("validate new stack name", "err", err, "stack", name)
+		return ws.ErrorResponse{OK: false, Msg: "Internal error"}
+	}
+	return stackNameErrorResponse{
+		Msg:     stackNameI18n{Key: nameErr.Code, Values: map[string]string{"param": nameErr.Param}},
+		MsgI18n: true,
+		Code:    nameErr.Code,
+	}
+}
+
+// rejectNewStackName acks an error and returns true if name can't be used
+// for a new stack.
+func (app *App) rejectNewStackName(c *ws.Conn, msg *ws.ClientMessage, name string) bool {
+	res := app.checkNewStackName(name)
+	if res == nil {
+		return false
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, res)
+	}
+	return true
+}
+
+// handleCheckStackName tells whether a name can be used for a new stack,
+// so the add form can flag it before saving. Args: stack name.
+func (app *App) handleCheckStackName(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	name := argString(parseArgs(msg), 0)
+	if app.rejectNewStackName(c, msg, name) {
+		return
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true})
+	}
+}
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/safehttp"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+const (
+	// stackURLTimeout bounds fetching the files of a stack from a URL.
+	stackURLTimeout = 30 * time.Second
+
+	// maxStackURLSize bounds the download of one file.
+	maxStackURLSize = 1 << 20
+)
+
+// stackURLClient fetches the files; it only connects to public addresses.
+var stackURLClient = safehttp.NewClient(stackURLTimeout, safehttp.Public)
+
+// stackFromURL is what a URL offers for a new stack, as previewed.
+type stackFromURL struct {
+	URL         string              `json:"url"`              // compose file, as fetched
+	EnvURL      string              `json:"envUrl,omitempty"` // .env example, as fetched
+	StackName   string              `json:"stackName"`        // suggested, "" if none fits
+	ComposeYAML string              `json:"composeYAML"`
+	ComposeENV  string              `json:"composeENV"`
+	Digest      string              `json:"digest"`
+	Diagnostics []composeDiagnostic `json:"diagnostics,omitempty"`
+	RiskReport  stackRiskReport     `json:"riskReport"`
+}
+
+// stackURLArgs are the args of the URL handlers. Digest is that of the
+// preview the user confirmed, if any, and AcceptRisks whether they
+// accepted its risk report.
+type stackURLArgs struct {
+	URL         string `json:"url"`
+	EnvURL      string `json:"envUrl"`
+	StackName   string `json:"stackName"`
+	Digest      string `json:"digest"`
+	AcceptRisks bool   `json:"acceptRisks"`
+}
+
+// handlePreviewStackFromURL fetches a compose file and an optional .env
+// example for the user to review before creating a stack from them.
+// Args: {url, envUrl?}
+func (app *App) handlePreviewStackFromURL(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	var data stackURLArgs
+	argObject(parseArgs(msg), 0, &data)
+
+	preview, err := fetchStackFromURL(data.URL, data.EnvURL)
+	if err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	preview.RiskReport = analyzeStackRisks(preview.ComposeYAML, "", app.stackEnvLookup(preview.ComposeENV, false))
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK bool `json:"ok"`
+			*stackFromURL
+		}{OK: true, stackFromURL: preview})
+	}
+}
+
+// handleCreateStackFromURL creates a stack from a compose file and an
+// optional .env example fetched from URLs. With a digest, the files must
+// not have changed since they were previewed. A blocking risk report must
+// be accepted. Like saving, operators need approval when it's required.
+// Args: {url, envUrl?, stackName, digest?, acceptRisks?}
+func (app *App) handleCreateStackFromURL(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	var data stackURLArgs
+	argObject(parseArgs(msg), 0, &data)
+	fail := func(text string) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: text})
+		}
+	}
+	if app.rejectNewStackName(c, msg, data.StackName) {
+		return
+	}
+
+	fetched, err := fetchStackFromURL(data.URL, data.EnvURL)
+	if err != nil {
+		fail(err.Error())
+		return
+	}
+	if data.Digest != "" && data.Digest != fetched.Digest {
+		fail("The files changed since the preview. Preview them again.")
+		return
+	}
+	if rejectInvalidCompose(c, msg, fetched.Diagnostics) {
+		return
+	}
+	if app.rejectUnacceptedRisks(c, msg, data.StackName, fetched.ComposeYAML, fetched.ComposeENV, "", data.AcceptRisks) {
+		return
+	}
+
+	s := &stack.Stack{Name: data.StackName, ComposeYAML: fetched.ComposeYAML, ComposeENV: fetched.ComposeENV}
+	if !app.createNewStack(c, msg, s, fail) {
+		return
+	}
+	slog.Info("stack created from URL", "stack", data.StackName, "url", fetched.URL, "envUrl", fetched.EnvURL)
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK              bool                `json:"ok"`
+			Msg             string              `json:"msg"`
+			MsgI18n         bool                `json:"msgi18n"`
+			StackName       string              `json:"stackName"`
+			MissingExternal []missingExternal   `json:"missingExternal,omitempty"`
+			Diagnostics     []composeDiagnostic `json:"diagnostics,omitempty"`
+		}{OK: true, Msg: "stackCreatedFromURL", MsgI18n: true, StackName: data.StackName,
+			MissingExternal: app.missingExternalResources(fetched.ComposeYAML, ""), Diagnostics: fetched.Diagnostics})
+	}
+}
+
+// fetchStackFromURL downloads the compose file at composeURL and, if
+// envURL isn't empty, the .env example there. It lints the compose file;
+// its risks depend on global.env, so the caller reports them.
+func fetchStackFromURL(composeURL, envURL string) (*stackFromURL, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), stackURLTimeout)
+	defer cancel()
+
+	u, err := rawFileURL(composeURL)
+	if err != nil {
+		return nil, err
+	}
+	s := &stackFromURL{URL: u.String(), StackName: stackNameFromURL(u)}
+	if s.ComposeYAML, err = fetchRawFile(ctx, s.URL); err != nil {
+		return nil, fmt.Errorf("fetch compose file: %w", err)
+	}
+	if strings.TrimSpace(s.ComposeYAML) == "" {
+		return nil, errors.New("the compose file is empty")
+	}
+	if strings.TrimSpace(envURL) != "" {
+		eu, err := rawFileURL(envURL)
+		if err != nil {
+			return nil, err
+		}
+		s.EnvURL = eu.String()
+		if s.ComposeENV, err = fetchRawFile(ctx, s.EnvURL); err != nil {
+			return nil, fmt.Errorf("fetch .env file: %w", err)
+		}
+	}
+	sum := sha256.Sum256([]byte(s.ComposeYAML + "\x00" + s.ComposeENV))
+	s.Digest = hex.EncodeToString(sum[:])
+	s.Diagnostics = lintStackFiles(s.ComposeYAML, "")
+	return s, nil
+}
+
+// rawFileURL parses an http(s) URL, turning links to files on GitHub and
+// to gists into links to their raw content.
+func rawFileURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid URL %q: only http and https URLs are supported", rawURL)
+	}
+	u.Fragment = ""
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	switch strings.ToLower(u.Host) {
+	case "github.com":
+		// owner/repo/blob/ref/path... → raw.githubusercontent.com/owner/repo/ref/path...
+		if len(parts) >= 5 && (parts[2] == "blob" || parts[2] == "raw") {
+			u.Host = "raw.githubusercontent.com"
+			u.Path = "/" + path.Join(append(parts[:2:2], parts[3:]...)...)
+			u.RawQuery = ""
+		}
+	case "gist.github.com":
+		// user/id → gist.githubusercontent.com/user/id/raw (its only file)
+		if len(parts) == 2 {
+			u.Host = "gist.githubusercontent.com"
+			u.Path = "/" + path.Join(parts[0], parts[1], "raw")
+			u.RawQuery = ""
+		}
+	}
+	return u, nil
+}
+
+// stackNameFromURL suggests a stack name from the directory of the compose
+// file, or the repository on GitHub if the file is at its root. It returns
+// "" if no valid name comes out.
+func stackNameFromURL(u *url.URL) string {
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	var name string
+	switch strings.ToLower(u.Host) {
+	case "gist.githubusercontent.com":
+		return ""
+	case "raw.githubusercontent.com":
+		// owner/repo/ref/path...
+		if len(parts) < 4 {
+			return ""
+		}
+		name = parts[1]
+		if len(parts) > 4 {
+			name = parts[len(parts)-2]
+		}
+	default:
+		if len(parts) < 2 {
+			return ""
+		}
+		name = parts[len(parts)-2]
+	}
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		case (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '_':
+			return r
+		}
+		return '-'
+	}, name)
+	name = strings.Trim(name, "-")
+	if stack.ValidateStackName(name) != nil {
+		return ""
+	}
+	return name
+}
+
+// fetchRawFile downloads a text file of at most maxStackURLSize bytes.
+func fetchRawFile(ctx context.Context, rawURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := stackURLClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s", resp.Status)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == "text/html" {
+		return "", errors.New("the URL is a web page, not a raw file")
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxStackURLSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxStackURLSize {
+		return "", fmt.Errorf("file larger than %d bytes", maxStackURLSize)
+	}
+	return string(data), nil
+}
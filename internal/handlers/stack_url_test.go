@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cfilipov/dockge/internal/safehttp"
+)
+
+func TestRawFileURL(t *testing.T) {
+	t.Parallel()
+	for _, tt := range []struct{ in, want, name string }{
+		{"https://github.com/acme/shop/blob/main/deploy/compose.yaml", "https://raw.githubusercontent.com/acme/shop/main/deploy/compose.yaml", "deploy"},
+		{"https://github.com/acme/shop/raw/v1.2/compose.yaml?plain=1", "https://raw.githubusercontent.com/acme/shop/v1.2/compose.yaml", "shop"},
+		{"https://raw.githubusercontent.com/acme/Home_Lab/main/Immich/docker-compose.yml", "https://raw.githubusercontent.com/acme/Home_Lab/main/Immich/docker-compose.yml", "immich"},
+		{"https://gist.github.com/me/0123abcd", "https://gist.githubusercontent.com/me/0123abcd/raw", ""},
+		{"http://example.com/files/My%20App/compose.yaml#top", "http://example.com/files/My%20App/compose.yaml", "my-app"},
+		{"https://example.com/compose.yaml", "https://example.com/compose.yaml", ""},
+	} {
+		u, err := rawFileURL(tt.in)
+		if err != nil {
+			t.Errorf("%s: %v", tt.in, err)
+			continue
+		}
+		if u.String() != tt.want {
+			t.Errorf("rawFileURL(%s) = %s, want %s", tt.in, u, tt.want)
+		}
+		if name := stackNameFromURL(u); name != tt.name {
+			t.Errorf("stackNameFromURL(%s) = %q, want %q", tt.in, name, tt.name)
+		}
+	}
+
+	for _, bad := range []string{"", "file:///etc/passwd", "ftp://example.com/compose.yaml", "https://"} {
+		if _, err := rawFileURL(bad); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
+
+func TestFetchStackFromURL(t *testing.T) {
+	// Not parallel: the test server is on loopback, which the client rejects
+	defer safehttp.AllowLoopback()()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app/compose.yaml":
+			w.Write([]byte("services:\n  web:\n    image: nginx\n"))
+		case "/app/.env.example":
+			w.Write([]byte("PORT=8080\n"))
+		case "/page":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte("<html></html>"))
+		case "/big":
+			w.Write([]byte(strings.Repeat("#", maxStackURLSize+1)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	s, err := fetchStackFromURL(srv.URL+"/app/compose.yaml", srv.URL+"/app/.env.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.StackName != "app" || s.ComposeENV != "PORT=8080\n" || !strings.Contains(s.ComposeYAML, "nginx") || s.Digest == "" {
+		t.Errorf("unexpected stack %+v", s)
+	}
+	other, err := fetchStackFromURL(srv.URL+"/app/compose.yaml", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Digest == s.Digest || other.ComposeENV != "" {
+		t.Errorf("the .env file isn't part of the digest: %+v", other)
+	}
+
+	for _, path := range []string{"/missing", "/page", "/big"} {
+		if _, err := fetchStackFromURL(srv.URL+path, ""); err == nil {
+			t.Errorf("%s: no error", path)
+		}
+	}
+}
+
+func TestStackURLClientRejectsPrivateAddresses(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("services: {}\n"))
+	}))
+	defer srv.Close()
+
+	if _, err := fetchRawFile(context.Background(), srv.URL+"/compose.yaml"); err == nil || !strings.Contains(err.Error(), "not a public address") {
+		t.Errorf("loopback fetched: %v", err)
+	}
+}
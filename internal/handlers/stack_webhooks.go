@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/notify"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/webhook"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// webhookTimeout bounds one webhook delivery.
+const webhookTimeout = 15 * time.Second
+
+// RegisterStackWebhookHandlers registers the handlers of per-stack
+// outbound webhooks. Webhooks can carry secrets, so they're admin only.
+func RegisterStackWebhookHandlers(app *App) {
+	app.WS.Handle("getStackWebhooks", app.handleGetStackWebhooks)
+	app.WS.Handle("saveStackWebhook", app.handleSaveStackWebhook)
+	app.WS.Handle("deleteStackWebhook", app.handleDeleteStackWebhook)
+	app.WS.Handle("testStackWebhook", app.handleTestStackWebhook)
+}
+
+// webhookEvent returns the webhook event of a finished stack action, or ""
+// if the action has none.
+func webhookEvent(action string, err error) string {
+	switch action {
+	case "deploy", "up", "update", "stop", "down":
+	default:
+		return ""
+	}
+	if err != nil {
+		return webhook.EventFailed
+	}
+	switch action {
+	case "update":
+		return webhook.EventUpdated
+	case "stop", "down":
+		return webhook.EventStopped
+	}
+	return webhook.EventDeployed
+}
+
+// fireStackWebhooks sends the event of a finished stack action to the
+// stack's enabled webhooks subscribed to it. Deliveries run in the
+// background; their outcome is recorded on the webhook.
+func (app *App) fireStackWebhooks(stackName, action string, err error) {
+	if app.StackWebhooks == nil {
+		return
+	}
+	event := webhookEvent(action, err)
+	if event == "" {
+		return
+	}
+	hooks, lerr := app.StackWebhooks.ListForStack(stackName)
+	if lerr != nil {
+		slog.Warn("list stack webhooks", "err", lerr, "stack", stackName)
+		return
+	}
+	p := webhook.Payload{Event: event, Stack: stackName, Action: action, Success: err == nil, Host: app.webhookHost(), Time: time.Now().UTC()}
+	if err != nil {
+		p.Error = err.Error()
+	}
+	for _, wh := range hooks {
+		if !wh.Enabled || !slices.Contains(wh.Events, event) {
+			continue
+		}
+		go app.deliverStackWebhook(wh, p)
+	}
+}
+
+// webhookHost names this server in payloads, like notifications do.
+func (app *App) webhookHost() string {
+	if app.Settings != nil {
+		if host := app.settingValue("primaryHostname"); host != "" {
+			return host
+		}
+	}
+	host, _ := os.Hostname()
+	return host
+}
+
+// deliverStackWebhook renders and sends p to wh and records the outcome.
+func (app *App) deliverStackWebhook(wh models.StackWebhook, p webhook.Payload) error {
+	body, err := webhook.Render(wh.Template, p)
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+		err = webhook.Send(ctx, wh.URL, wh.Secret, p.Event, body)
+		cancel()
+	}
+	if err != nil {
+		slog.Warn("stack webhook", "err", err, "stack", wh.StackName, "id", wh.ID, "event", p.Event)
+	}
+
+	// Reload, so an edit made meanwhile isn't overwritten
+	stored, gerr := app.StackWebhooks.Get(wh.ID)
+	if gerr != nil || stored == nil {
+		return err
+	}
+	stored.LastSent = time.Now().Unix()
+	stored.LastStatus = p.Event
+	stored.LastError = ""
+	if err != nil {
+		stored.LastError = err.Error()
+	}
+	if uerr := app.StackWebhooks.Update(stored); uerr != nil {
+		slog.Warn("record stack webhook result", "err", uerr, "id", wh.ID)
+	}
+	return err
+}
+
+// deleteStackWebhooks drops the webhooks of a deleted stack.
+func (app *App) deleteStackWebhooks(stackName string) {
+	if app.StackWebhooks == nil {
+		return
+	}
+	if err := app.StackWebhooks.DeleteForStack(stackName); err != nil {
+		slog.Warn("delete stack webhooks", "err", err, "stack", stackName)
+	}
+}
+
+// newWebhookSecret returns a random secret for a webhook saved without one.
+func newWebhookSecret() string {
+	buf := make([]byte, 24)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// handleGetStackWebhooks lists a stack's webhooks. Admin only.
+// Args: stack name.
+func (app *App) handleGetStackWebhooks(c *ws.Conn, msg *ws.ClientMessage) {
+	if app.checkAdmin(c, msg) == nil {
+		return
+	}
+	stackName := argString(parseArgs(msg), 0)
+	if err := stack.ValidateStackName(stackName); err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	hooks := []models.StackWebhook{}
+	if app.StackWebhooks != nil {
+		var err error
+		if hooks, err = app.StackWebhooks.ListForStack(stackName); err != nil {
+			slog.Error("list stack webhooks", "err", err, "stack", stackName)
+			if msg.ID != nil {
+				ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+			}
+			return
+		}
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK       bool                  `json:"ok"`
+			Webhooks []models.StackWebhook `json:"webhooks"`
+			Events   []string              `json:"events"`
+		}{OK: true, Webhooks: hooks, Events: webhook.Events})
+	}
+}
+
+// handleSaveStackWebhook creates a webhook, or updates one when the id is
+// set. A webhook saved without a secret gets a random one. Admin only.
+// Args: {id, stackName, url, secret, events, template, enabled}.
+func (app *App) handleSaveStackWebhook(c *ws.Conn, msg *ws.ClientMessage) {
+	admin := app.checkAdmin(c, msg)
+	if admin == nil {
+		return
+	}
+	fail := func(text string) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: text})
+		}
+	}
+	if app.StackWebhooks == nil {
+		fail("Webhooks are not available")
+		return
+	}
+
+	var data models.StackWebhook
+	if !argObject(parseArgs(msg), 0, &data) {
+		fail("Invalid webhook")
+		return
+	}
+	if err := stack.ValidateStackName(data.StackName); err != nil {
+		fail(err.Error())
+		return
+	}
+	if err := notify.ValidURL(data.URL); err != nil {
+		fail(err.Error())
+		return
+	}
+	if len(data.Events) == 0 {
+		fail("Pick at least one event")
+		return
+	}
+	for _, e := range data.Events {
+		if !webhook.ValidEvent(e) {
+			fail("Invalid event " + e)
+			return
+		}
+	}
+	if err := webhook.CheckTemplate(data.Template); err != nil {
+		fail("Invalid payload template: " + err.Error())
+		return
+	}
+	if data.Secret == "" {
+		data.Secret = newWebhookSecret()
+	}
+
+	var err error
+	if data.ID == 0 {
+		wh := &models.StackWebhook{StackName: data.StackName, URL: data.URL, Secret: data.Secret, Events: data.Events, Template: data.Template, Enabled: data.Enabled, CreatedBy: admin.Username}
+		err = app.StackWebhooks.Create(wh)
+	} else {
+		var wh *models.StackWebhook
+		wh, err = app.StackWebhooks.Get(data.ID)
+		if err == nil && (wh == nil || wh.StackName != data.StackName) {
+			fail("Webhook not found")
+			return
+		}
+		if err == nil {
+			wh.URL, wh.Secret, wh.Events, wh.Template, wh.Enabled = data.URL, data.Secret, data.Events, data.Template, data.Enabled
+			err = app.StackWebhooks.Update(wh)
+		}
+	}
+	if err != nil {
+		slog.Error("save stack webhook", "err", err, "stack", data.StackName)
+		fail("Internal error")
+		return
+	}
+	slog.Info("stack webhook saved", "stack", data.StackName, "url", data.URL, "by", admin.Username)
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Saved"})
+	}
+}
+
+// handleDeleteStackWebhook removes a webhook. Admin only. Args: webhook id.
+func (app *App) handleDeleteStackWebhook(c *ws.Conn, msg *ws.ClientMessage) {
+	if app.checkAdmin(c, msg) == nil {
+		return
+	}
+	if app.StackWebhooks == nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Webhooks are not available"})
+		}
+		return
+	}
+	id := argInt(parseArgs(msg), 0)
+	if err := app.StackWebhooks.Delete(id); err != nil {
+		slog.Error("delete stack webhook", "err", err, "id", id)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Deleted"})
+	}
+}
+
+// handleTestStackWebhook sends a test event to a webhook, whatever events
+// it's subscribed to, and acks the outcome. Admin only. Args: webhook id.
+func (app *App) handleTestStackWebhook(c *ws.Conn, msg *ws.ClientMessage) {
+	if app.checkAdmin(c, msg) == nil {
+		return
+	}
+	fail := func(text string) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: text})
+		}
+	}
+	if app.StackWebhooks == nil {
+		fail("Webhooks are not available")
+		return
+	}
+	id := argInt(parseArgs(msg), 0)
+	wh, err := app.StackWebhooks.Get(id)
+	if err != nil {
+		slog.Error("get stack webhook", "err", err, "id", id)
+		fail("Internal error")
+		return
+	}
+	if wh == nil {
+		fail("Webhook not found")
+		return
+	}
+
+	go func() {
+		p := webhook.Payload{Event: webhook.EventTest, Stack: wh.StackName, Action: "test", Success: true, Host: app.webhookHost(), Time: time.Now().UTC()}
+		if err := app.deliverStackWebhook(*wh, p); err != nil {
+			fail(err.Error())
+			return
+		}
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Sent"})
+		}
+	}()
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cfilipov/dockge/internal/webhook"
+)
+
+func TestWebhookEvent(t *testing.T) {
+	t.Parallel()
+	failed := errors.New("exit status 1")
+	for _, tc := range []struct {
+		action string
+		err    error
+		want   string
+	}{
+		{"deploy", nil, webhook.EventDeployed},
+		{"up", nil, webhook.EventDeployed},
+		{"update", nil, webhook.EventUpdated},
+		{"stop", nil, webhook.EventStopped},
+		{"down", nil, webhook.EventStopped},
+		{"update", failed, webhook.EventFailed},
+		{"stop", failed, webhook.EventFailed},
+		{"restart", nil, ""},
+		{"pause", failed, ""},
+	} {
+		if got := webhookEvent(tc.action, tc.err); got != tc.want {
+			t.Errorf("webhookEvent(%q, %v) = %q, want %q", tc.action, tc.err, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/cfilipov/dockge/internal/compose"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// Reasons a stack is left out of a "start all" or "stop all" batch, besides
+// the update batch reasons it shares (excluded, locked).
+const (
+	bulkExcludedArchived = "archived"         // archived stacks aren't started
+	bulkExcludedCycle    = "cycle"            // part of, or depends on, a dependency cycle
+	bulkExcludedDepFail  = "dependencyFailed" // a stack it must wait for failed
+)
+
+// RegisterStackOrderHandlers registers the stack dependency and bulk
+// start/stop handlers.
+func RegisterStackOrderHandlers(app *App) {
+	app.WS.Handle("getStackDependencies", app.handleGetStackDependencies)
+	app.WS.Handle("startAllStacks", app.handleStartAllStacks)
+	app.WS.Handle("stopAllStacks", app.handleStopAllStacks)
+}
+
+// bulkPlanStack is one stack of a "start all" or "stop all" plan.
+type bulkPlanStack struct {
+	StackName string   `json:"stackName"`
+	Wave      int      `json:"wave"`
+	DependsOn []string `json:"dependsOn"`
+	Excluded  string   `json:"excluded,omitempty"`
+}
+
+// bulkResult is the outcome of one stack in a bulk start, stop or update.
+type bulkResult struct {
+	Action    string `json:"action"`          // "start", "stop", or "update"
+	Group     int    `json:"group,omitempty"` // stack group the batch was run for
+	StackName string `json:"stackName"`
+	Wave      int    `json:"wave"`
+	Status    string `json:"status"` // "running", "done", "failed", or "skipped"
+	Error     string `json:"error,omitempty"`
+}
+
+// stackDependencies maps each managed stack to the stacks its services
+// declare with dockge.depends_on. missing lists, per stack, dependencies
+// that aren't managed stacks; they're ignored when ordering.
+func (app *App) stackDependencies() (deps, missing map[string][]string, err error) {
+	entries, err := os.ReadDir(app.StacksDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	deps = make(map[string][]string)
+	for _, entry := range entries {
+		if !stack.IsDirEntry(app.StacksDir, entry) {
+			continue
+		}
+		path := compose.FindComposeFile(app.StacksDir, entry.Name())
+		if path == "" {
+			continue
+		}
+		deps[entry.Name()] = compose.StackDependencies(compose.ParseFile(path))
+	}
+	missing = make(map[string][]string)
+	for name, ds := range deps {
+		for _, d := range ds {
+			if _, ok := deps[d]; !ok {
+				missing[name] = append(missing[name], d)
+			}
+		}
+	}
+	return deps, missing, nil
+}
+
+// bulkPlan orders every managed stack into dependency waves for action
+// ("start", "stop", or "update"), marking the ones that would be skipped.
+// Stops run the waves in reverse, so dependents stop before their
+// dependencies. If only isn't nil, other stacks are left out of the plan.
+func (app *App) bulkPlan(action string, only, exclude []string) ([]bulkPlanStack, error) {
+	deps, _, err := app.stackDependencies()
+	if err != nil {
+		return nil, err
+	}
+	waves, err := stack.DependencyWaves(deps)
+	var cycle *stack.CycleError
+	if err != nil && !errors.As(err, &cycle) {
+		return nil, err
+	}
+	if action == "stop" {
+		slices.Reverse(waves)
+	}
+
+	plan := []bulkPlanStack{}
+	add := func(name string, wave int, excluded string) {
+		if only != nil && !slices.Contains(only, name) {
+			return
+		}
+		dependsOn := []string{}
+		for _, d := range deps[name] {
+			if _, ok := deps[d]; ok && d != name {
+				dependsOn = append(dependsOn, d)
+			}
+		}
+		switch {
+		case excluded != "":
+		case slices.Contains(exclude, name):
+			excluded = updateExcludedUser
+		case action != "stop" && app.stackArchive(name) != nil:
+			excluded = bulkExcludedArchived
+		case app.StackLocks.Locked(name):
+			excluded = updateExcludedLocked
+		}
+		plan = append(plan, bulkPlanStack{StackName: name, Wave: wave, DependsOn: dependsOn, Excluded: excluded})
+	}
+	for i, wave := range waves {
+		for _, name := range wave {
+			add(name, i, "")
+		}
+	}
+	if cycle != nil {
+		for _, name := range cycle.Stacks {
+			add(name, len(waves), bulkExcludedCycle)
+		}
+	}
+	return plan, nil
+}
+
+// handleGetStackDependencies returns each managed stack's declared
+// dependencies, the order "start all" would use, dependencies on stacks
+// that don't exist, and the stacks caught in a dependency cycle, if any.
+func (app *App) handleGetStackDependencies(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	deps, missing, err := app.stackDependencies()
+	if err != nil {
+		slog.Error("stack dependencies", "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+	waves, err := stack.DependencyWaves(deps)
+	cycle := []string{}
+	var cycleErr *stack.CycleError
+	if errors.As(err, &cycleErr) {
+		cycle = cycleErr.Stacks
+	}
+	if waves == nil {
+		waves = [][]string{}
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK           bool                `json:"ok"`
+			Dependencies map[string][]string `json:"dependencies"`
+			Missing      map[string][]string `json:"missing"`
+			Waves        [][]string          `json:"waves"`
+			Cycle        []string            `json:"cycle"`
+			Running      bool                `json:"running"`
+		}{OK: true, Dependencies: deps, Missing: missing, Waves: waves, Cycle: cycle, Running: app.bulkStacksRunning.Load()})
+	}
+}
+
+// handleStartAllStacks starts every managed stack that isn't excluded or
+// archived, dependencies first. Admin only. Args: options {exclude, parallelism}.
+func (app *App) handleStartAllStacks(c *ws.Conn, msg *ws.ClientMessage) {
+	app.handleBulkStacks(c, msg, "start")
+}
+
+// handleStopAllStacks stops every managed stack that isn't excluded,
+// dependents first. Admin only. Args: options {exclude, parallelism}.
+func (app *App) handleStopAllStacks(c *ws.Conn, msg *ws.ClientMessage) {
+	app.handleBulkStacks(c, msg, "stop")
+}
+
+// handleBulkStacks handles startAllStacks and stopAllStacks.
+func (app *App) handleBulkStacks(c *ws.Conn, msg *ws.ClientMessage, action string) {
+	if app.checkAdmin(c, msg) == nil {
+		return
+	}
+	var opts updateAllOptions
+	argObject(parseArgs(msg), 0, &opts)
+	app.launchBulkStacks(c, msg, action, 0, nil, opts)
+}
+
+// launchBulkStacks plans and starts a bulk start, stop or update of the
+// stacks in only (all if nil), which are those of a stack group if group
+// isn't 0. The ack carries the plan; progress is
+// pushed as "bulkStacksProgress" per stack and "bulkStacksDone" with the
+// results.
+func (app *App) launchBulkStacks(c *ws.Conn, msg *ws.ClientMessage, action string, group int, only []string, opts updateAllOptions) {
+	if opts.Parallelism <= 0 {
+		opts.Parallelism = 2
+	}
+	opts.Parallelism = min(opts.Parallelism, maxUpdateAllParallel)
+
+	plan, err := app.bulkPlan(action, only, opts.Exclude)
+	if err != nil {
+		slog.Error("bulk stacks plan", "action", action, "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+	if !app.bulkStacksRunning.CompareAndSwap(false, true) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "A bulk stack operation is already running"})
+		}
+		return
+	}
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK   bool            `json:"ok"`
+			Plan []bulkPlanStack `json:"plan"`
+		}{OK: true, Plan: plan})
+	}
+
+	go func() {
+		defer app.bulkStacksRunning.Store(false)
+		results := app.runBulkStacks(action, group, plan, opts.Parallelism)
+		ws.BroadcastAuthenticated(app.WS, "bulkStacksDone", struct {
+			Action  string       `json:"action"`
+			Group   int          `json:"group,omitempty"`
+			Results []bulkResult `json:"results"`
+		}{action, group, results})
+	}()
+}
+
+// runBulkStacks runs the plan one wave at a time, with bounded parallelism
+// inside a wave, and returns one result per stack in plan order. A stack
+// is skipped if one it waits for failed: for a stop its dependents, else
+// its dependencies.
+func (app *App) runBulkStacks(action string, group int, plan []bulkPlanStack, parallelism int) []bulkResult {
+	results := make([]bulkResult, len(plan))
+	var mu sync.Mutex
+	failed := make(map[string]bool) // failed, or skipped because of a failure
+	report := func(i int, r bulkResult) {
+		mu.Lock()
+		results[i] = r
+		if r.Status == "failed" || r.Error == bulkExcludedDepFail {
+			failed[r.StackName] = true
+		}
+		mu.Unlock()
+		ws.BroadcastAuthenticated(app.WS, "bulkStacksProgress", r)
+	}
+
+	// waitsFor lists the stacks that must succeed before a stack runs
+	waitsFor := make(map[string][]string, len(plan))
+	for _, ps := range plan {
+		if action != "stop" {
+			waitsFor[ps.StackName] = ps.DependsOn
+		} else {
+			for _, d := range ps.DependsOn {
+				waitsFor[d] = append(waitsFor[d], ps.StackName)
+			}
+		}
+	}
+
+	sem := make(chan struct{}, parallelism)
+	for start := 0; start < len(plan); {
+		end := start
+		for end < len(plan) && plan[end].Wave == plan[start].Wave {
+			end++
+		}
+
+		var wg sync.WaitGroup
+		for i := start; i < end; i++ {
+			ps := plan[i]
+			result := bulkResult{Action: action, Group: group, StackName: ps.StackName, Wave: ps.Wave}
+			if ps.Excluded != "" {
+				result.Status, result.Error = "skipped", ps.Excluded
+				report(i, result)
+				continue
+			}
+			mu.Lock()
+			blocked := slices.ContainsFunc(waitsFor[ps.StackName], func(name string) bool { return failed[name] })
+			mu.Unlock()
+			if blocked {
+				result.Status, result.Error = "skipped", bulkExcludedDepFail
+				report(i, result)
+				continue
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+
+				if !app.StackLocks.TryLock(ps.StackName) {
+					result.Status, result.Error = "skipped", updateExcludedLocked
+					report(i, result)
+					return
+				}
+				defer app.StackLocks.Unlock(ps.StackName)
+
+				result.Status = "running"
+				report(i, result)
+				var err error
+				switch action {
+				case "start":
+					err = app.runComposeAction(ps.StackName, "up", app.upArgs(ps.StackName, "")...)
+				case "stop":
+					err = app.runComposeAction(ps.StackName, "stop", "stop")
+				case "update":
+					err = app.runStackUpdate(ps.StackName)
+				}
+				if err != nil {
+					result.Status, result.Error = "failed", err.Error()
+				} else {
+					result.Status = "done"
+				}
+				report(i, result)
+			}()
+		}
+		wg.Wait()
+		start = end
+	}
+
+	var done, failedCount int
+	for _, r := range results {
+		switch r.Status {
+		case "done":
+			done++
+		case "failed":
+			failedCount++
+		}
+	}
+	slog.Info("bulk stacks", "action", action, "group", group, "stacks", len(plan), "done", done, "failed", failedCount)
+	return results
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/compose"
+	"github.com/cfilipov/dockge/internal/docker"
+	"github.com/cfilipov/dockge/internal/terminal"
+)
+
+// stageReadyTimeout bounds how long a staged startup waits for a stage's
+// containers before giving up.
+const stageReadyTimeout = 3 * time.Minute
+
+// runUp runs a compose up of a whole stack. If its services set
+// x-dockge.start_delay (see compose.StartStages), the stages are started
+// one after the other: each once its delay has passed since the startup
+// began and the services started before it are ready, i.e. running, and
+// healthy if they have a healthcheck. Finished jobs count as ready.
+// Starts of some services only (service arguments) always run in one go.
+func (app *App) runUp(ctx context.Context, term *terminal.Terminal, stackName, action, dir string, envArgs, upArgs []string) error {
+	var stages []compose.StartStage
+	if len(upServiceArgs(upArgs)) == 0 {
+		if path := compose.FindComposeFile(app.StacksDir, stackName); path != "" {
+			data, err := os.ReadFile(path)
+			if err == nil {
+				stages, err = compose.StartStages(string(data))
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if len(stages) == 0 {
+		return app.runCompose(ctx, term, stackName, action, dir, envArgs, upArgs, nil)
+	}
+
+	jobs := app.stackJobServices(stackName)
+	began := time.Now()
+	var started []string
+	for i, stage := range stages {
+		if i > 0 {
+			if err := app.waitStageReady(ctx, term, stackName, started, jobs); err != nil {
+				return err
+			}
+			if wait := stage.Delay - time.Since(began); wait > 0 {
+				term.Write([]byte(fmt.Sprintf("[Staged start] Waiting %s before starting %s\r\n", wait.Round(time.Second), strings.Join(stage.Services, ", "))))
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
+		term.Write([]byte(fmt.Sprintf("[Staged start] Stage %d/%d: %s\r\n", i+1, len(stages), strings.Join(stage.Services, ", "))))
+		args := append(slices.Clone(upArgs), stage.Services...)
+		if err := app.runCompose(ctx, term, stackName, action, dir, envArgs, args, nil); err != nil {
+			return err
+		}
+		started = append(started, stage.Services...)
+	}
+	return nil
+}
+
+// waitStageReady polls the stack's containers until those of services are
+// ready, or stageReadyTimeout passes.
+func (app *App) waitStageReady(ctx context.Context, term *terminal.Terminal, stackName string, services []string, jobs map[string]bool) error {
+	ctx, cancel := context.WithTimeout(ctx, stageReadyTimeout)
+	defer cancel()
+	var pending []string
+	for {
+		containers, err := app.Docker.ContainerList(ctx, true, stackName)
+		if err == nil {
+			if pending = stagePending(services, containers, jobs); len(pending) == 0 {
+				return nil
+			}
+		}
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			if len(pending) > 0 {
+				return fmt.Errorf("staged start: %s not ready after %s", strings.Join(pending, ", "), stageReadyTimeout)
+			}
+			return ctx.Err()
+		}
+	}
+}
+
+// stagePending returns the services whose containers aren't ready yet:
+// missing, not running, or not healthy yet if they have a healthcheck. A
+// job that exited successfully is ready.
+func stagePending(services []string, containers []docker.Container, jobs map[string]bool) []string {
+	var pending []string
+	for _, svc := range services {
+		found := false
+		ready := true
+		for _, ctr := range containers {
+			if ctr.Service != svc {
+				continue
+			}
+			found = true
+			switch {
+			case ctr.State == "running" && (ctr.Health == "" || ctr.Health == "healthy"):
+			case ctr.State == "exited" && jobs[svc]:
+			default:
+				ready = false
+			}
+		}
+		if !found || !ready {
+			pending = append(pending, svc)
+		}
+	}
+	return pending
+}
+
+// upServiceArgs returns the service arguments of compose up args, skipping
+// the flags and the values of the flags Dockge passes with one.
+func upServiceArgs(args []string) []string {
+	var services []string
+	for i := 1; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--pull" || arg == "--timeout" || arg == "-t" || arg == "--scale":
+			i++
+		case strings.HasPrefix(arg, "-"):
+		default:
+			services = append(services, arg)
+		}
+	}
+	return services
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/cfilipov/dockge/internal/docker"
+)
+
+func TestStagePending(t *testing.T) {
+	t.Parallel()
+	containers := []docker.Container{
+		{Service: "db", State: "running", Health: "starting"},
+		{Service: "cache", State: "running"},
+		{Service: "migrate", State: "exited"},
+		{Service: "web", State: "running", Health: "healthy"},
+		{Service: "web", State: "restarting"},
+		{Service: "broken", State: "exited"},
+	}
+	jobs := map[string]bool{"migrate": true}
+	got := stagePending([]string{"db", "cache", "migrate", "web", "broken", "missing"}, containers, jobs)
+	if want := []string{"db", "web", "broken", "missing"}; !slices.Equal(got, want) {
+		t.Errorf("stagePending = %v, want %v", got, want)
+	}
+
+	containers[0].Health = "healthy"
+	if got := stagePending([]string{"db", "cache", "migrate"}, containers, jobs); len(got) != 0 {
+		t.Errorf("stagePending = %v, want none", got)
+	}
+}
+
+func TestUpServiceArgs(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"up", "-d", "--remove-orphans"}, nil},
+		{[]string{"up", "-d", "--remove-orphans", "--pull", "always"}, nil},
+		{[]string{"up", "-d", "--pull", "never", "web", "db"}, []string{"web", "db"}},
+		{[]string{"up", "-d", "--no-deps", "backup"}, []string{"backup"}},
+	} {
+		if got := upServiceArgs(tc.args); !slices.Equal(got, tc.want) {
+			t.Errorf("upServiceArgs(%v) = %v, want %v", tc.args, got, tc.want)
+		}
+	}
+}
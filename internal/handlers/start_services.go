@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"log/slog"
+	"slices"
+	"sort"
+
+	"github.com/cfilipov/dockge/internal/compose"
+	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// RegisterStartServicesHandlers registers the handlers of partial stack
+// starts.
+func RegisterStartServicesHandlers(app *App) {
+	app.WS.Handle("getServiceSelection", app.handleGetServiceSelection)
+	app.WS.Handle("startServices", app.handleStartServices)
+}
+
+// stackServiceSelection returns the services last started on their own,
+// or nil if there are none.
+func (app *App) stackServiceSelection(stackName string) *models.StackServiceSelection {
+	if app.ServiceSelections == nil {
+		return nil
+	}
+	sel, err := app.ServiceSelections.Get(stackName)
+	if err != nil {
+		slog.Warn("get service selection", "err", err, "stack", stackName)
+		return nil
+	}
+	return sel
+}
+
+// deleteServiceSelection drops the service selection of a stack whose
+// files were removed.
+func (app *App) deleteServiceSelection(stackName string) {
+	if app.ServiceSelections == nil {
+		return
+	}
+	if err := app.ServiceSelections.Delete(stackName); err != nil {
+		slog.Warn("delete service selection", "err", err, "stack", stackName)
+	}
+}
+
+// handleGetServiceSelection returns a stack's services, sorted, and the
+// ones last started with startServices (null if none). Args: stack name.
+func (app *App) handleGetServiceSelection(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	stackName := argString(parseArgs(msg), 0)
+	if err := stack.ValidateStackName(stackName); err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	services := []string{}
+	if path := compose.FindComposeFile(app.StacksDir, stackName); path != "" {
+		for svc := range compose.ParseFile(path) {
+			services = append(services, svc)
+		}
+	}
+	sort.Strings(services)
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK        bool                          `json:"ok"`
+			Services  []string                      `json:"services"`
+			Selection *models.StackServiceSelection `json:"selection"`
+		}{OK: true, Services: services, Selection: app.stackServiceSelection(stackName)})
+	}
+}
+
+// handleStartServices starts only some services of a managed stack with
+// `up -d` (and whatever they depend on), and remembers them as the stack's
+// selection for next time. Args: (stackName, services []string, {pull}).
+func (app *App) handleStartServices(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	args := parseArgs(msg)
+	stackName := argString(args, 0)
+	var services []string
+	argObject(args, 1, &services)
+	var opts struct {
+		Pull string `json:"pull"`
+	}
+	argObject(args, 2, &opts)
+	if err := stack.ValidateStackName(stackName); err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	if len(services) == 0 {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Select at least one service"})
+		}
+		return
+	}
+	if !validPullPolicy(opts.Pull) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "invalidPullPolicy", MsgI18n: true})
+		}
+		return
+	}
+	path := compose.FindComposeFile(app.StacksDir, stackName)
+	if path == "" {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Cannot start services: stack is not managed by Dockge"})
+		}
+		return
+	}
+	defined := compose.ParseFile(path)
+	slices.Sort(services)
+	services = slices.Compact(services)
+	for _, svc := range services {
+		if _, ok := defined[svc]; !ok {
+			if msg.ID != nil {
+				ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "No service " + svc + " in stack " + stackName})
+			}
+			return
+		}
+	}
+	if app.rejectArchived(c, msg, stackName) {
+		return
+	}
+
+	if app.ServiceSelections != nil {
+		sel := models.StackServiceSelection{StackName: stackName, Services: services}
+		if user := app.currentUser(c); user != nil {
+			sel.UpdatedBy = user.Username
+		}
+		if err := app.ServiceSelections.Set(sel); err != nil {
+			slog.Warn("set service selection", "err", err, "stack", stackName)
+		}
+	}
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true})
+	}
+
+	upArgs := append(app.upArgs(stackName, opts.Pull), services...)
+	go app.lockedRunComposeAction(stackName, "up", upArgs...)
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/docker"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// Summary is the dashboard header's aggregate counts.
+type Summary struct {
+	Stacks     map[string]int `json:"stacks"`     // by status label: active, partially, exited, unhealthy, down
+	Containers map[string]int `json:"containers"` // by state
+	Unhealthy  int            `json:"unhealthy"`  // services with an unhealthy container
+	Updates    int            `json:"updates"`    // images with updates
+	Disk       DiskSummary    `json:"disk"`
+}
+
+// DiskSummary is the disk usage headline.
+type DiskSummary struct {
+	Images     int   `json:"images"`
+	Dangling   int   `json:"dangling"`
+	ImageBytes int64 `json:"imageBytes"` // summed image sizes; shared layers count once per image
+	Volumes    int   `json:"volumes"`
+}
+
+// summaryState caches the summary. It's recomputed only after the replay
+// cache or the image updates change, so requests don't walk the lists.
+type summaryState struct {
+	mu         sync.Mutex
+	summary    *Summary
+	version    uint64   // replay cache version the summary was computed from
+	updates    []string // "stack/service" keys with image updates (nil = not read yet)
+	updatesGen uint64   // bumped when updates change
+	computedAt uint64   // updatesGen the summary was computed from
+}
+
+// setUpdates records the services with image updates, as last broadcast.
+func (s *summaryState) setUpdates(keys []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.updates = keys
+	s.updatesGen++
+}
+
+// handleGetSummary returns aggregate counts for the dashboard header, so it
+// doesn't have to derive them from the full lists.
+func (app *App) handleGetSummary(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	summary := app.dashboardSummary()
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK bool `json:"ok"`
+			*Summary
+		}{OK: true, Summary: summary})
+	}
+}
+
+// dashboardSummary returns the cached summary, recomputing it if the
+// broadcast state changed since.
+func (app *App) dashboardSummary() *Summary {
+	app.summary.mu.Lock()
+	updatesRead := app.summary.updates != nil
+	app.summary.mu.Unlock()
+	if !updatesRead {
+		app.buildUpdatesPayload()
+	}
+	app.seedReplay()
+
+	version := app.replay.currentVersion()
+	app.summary.mu.Lock()
+	if s := app.summary.summary; s != nil && app.summary.version == version && app.summary.computedAt == app.summary.updatesGen {
+		app.summary.mu.Unlock()
+		return s
+	}
+	updates, updatesGen := app.summary.updates, app.summary.updatesGen
+	app.summary.mu.Unlock()
+
+	s := computeSummary(app.replay.snapshot(), updates)
+
+	app.summary.mu.Lock()
+	defer app.summary.mu.Unlock()
+	app.summary.summary = s
+	app.summary.version = version
+	app.summary.computedAt = updatesGen
+	return s
+}
+
+// seedReplay queries the channels the summary needs that haven't been
+// broadcast yet, and caches them as if they had been.
+func (app *App) seedReplay() {
+	if !app.replay.has(chanStacks) {
+		app.replay.replace(chanStacks, stacksToMap(app.stackBroadcastEntries()))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if !app.replay.has(chanContainers) {
+		if containers, err := app.Docker.ContainerListDetailed(ctx); err != nil {
+			slog.Warn("summary: containers", "err", err)
+		} else {
+			app.replay.replace(chanContainers, containersToMap(containers))
+		}
+	}
+	if !app.replay.has(chanImages) {
+		if images, err := app.Docker.ImageList(ctx); err != nil {
+			slog.Warn("summary: images", "err", err)
+		} else {
+			app.replay.replace(chanImages, imagesToMap(images))
+		}
+	}
+	if !app.replay.has(chanVolumes) {
+		if volumes, err := app.Docker.VolumeList(ctx); err != nil {
+			slog.Warn("summary: volumes", "err", err)
+		} else {
+			app.replay.replace(chanVolumes, volumesToMap(volumes))
+		}
+	}
+}
+
+// computeSummary counts the cached channel state. Stack status is derived
+// from containers the same way as the frontend's deriveStatus.
+func computeSummary(channels map[string]map[string]any, updates []string) *Summary {
+	s := &Summary{
+		Stacks:     map[string]int{"active": 0, "partially": 0, "exited": 0, "unhealthy": 0, "down": 0},
+		Containers: make(map[string]int),
+	}
+
+	managed := make(map[string]StackBroadcastEntry)
+	for _, v := range channels[chanStacks] {
+		if entry, ok := v.(StackBroadcastEntry); ok {
+			managed[entry.Name] = entry
+		}
+	}
+
+	unhealthy := make(map[string]bool)
+	for _, v := range channels[chanContainers] {
+		c, ok := v.(docker.ContainerBroadcast)
+		if !ok {
+			continue
+		}
+		s.Containers[c.State]++
+		if c.Health == "unhealthy" {
+			key := c.Name
+			if c.StackName != "" {
+				key = c.StackName + "/" + c.ServiceName
+			}
+			unhealthy[key] = true
+		}
+	}
+	s.Unhealthy = len(unhealthy)
+
+	for _, label := range stackStatusLabels(managed, channels[chanContainers]) {
+		s.Stacks[label]++
+	}
+
+	images := make(map[string]bool)
+	for _, key := range updates {
+		stackName, service, _ := strings.Cut(key, "/")
+		if image := managed[stackName].Images[service]; image != "" {
+			images[image] = true
+		} else {
+			images[key] = true
+		}
+	}
+	s.Updates = len(images)
+
+	for _, v := range channels[chanImages] {
+		if img, ok := v.(docker.ImageSummary); ok {
+			s.Disk.Images++
+			s.Disk.ImageBytes += img.SizeBytes
+			if img.Dangling {
+				s.Disk.Dangling++
+			}
+		}
+	}
+	s.Disk.Volumes = len(channels[chanVolumes])
+	return s
+}
+
+// stackStateCount tallies a stack's containers by state.
+type stackStateCount struct {
+	running, exited, created, paused, unhealthy int
+}
+
+func (sc *stackStateCount) add(c docker.ContainerBroadcast) {
+	if c.Health == "unhealthy" {
+		sc.unhealthy++
+		return
+	}
+	switch c.State {
+	case "running":
+		sc.running++
+	case "exited", "dead":
+		sc.exited++
+	case "created":
+		sc.created++
+	case "paused":
+		sc.paused++
+	}
+}
+
+// label returns the stack's status label, as in StackStatusInfo.
+func (sc *stackStateCount) label() string {
+	switch {
+	case sc.unhealthy > 0:
+		return "unhealthy"
+	case sc.running > 0 && sc.exited > 0:
+		return "partially"
+	case sc.running > 0:
+		return "active"
+	case sc.exited > 0:
+		return "exited"
+	case sc.created > 0:
+		return "down"
+	case sc.paused > 0:
+		return "active" // paused counts as running, as in the UI
+	default:
+		return "down"
+	}
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/cfilipov/dockge/internal/docker"
+)
+
+func TestComputeSummary(t *testing.T) {
+	t.Parallel()
+	ctr := func(name, stackName, service, state, health string) docker.ContainerBroadcast {
+		return docker.ContainerBroadcast{Name: name, StackName: stackName, ServiceName: service, State: state, Health: health}
+	}
+	channels := map[string]map[string]any{
+		chanStacks: stacksToMap([]StackBroadcastEntry{
+			{Name: "web", Images: map[string]string{"app": "nginx:1", "proxy": "nginx:1"}},
+			{Name: "db", Images: map[string]string{"pg": "postgres:16"}},
+			{Name: "jobs", IgnoreStatus: map[string]bool{"cron": true}},
+			{Name: "draft"},
+			{Name: "sick"},
+		}),
+		chanContainers: containersToMap([]docker.ContainerBroadcast{
+			ctr("web-app-1", "web", "app", "running", ""),
+			ctr("web-proxy-1", "web", "proxy", "exited", ""),
+			ctr("db-pg-1", "db", "pg", "running", "healthy"),
+			ctr("jobs-worker-1", "jobs", "worker", "running", ""),
+			ctr("jobs-cron-1", "jobs", "cron", "exited", ""),
+			ctr("sick-api-1", "sick", "api", "running", "unhealthy"),
+			ctr("sick-api-2", "sick", "api", "running", "unhealthy"),
+			ctr("other-x-1", "other", "x", "exited", ""),
+			ctr("dockge-dockge-1", "dockge", "dockge", "running", ""),
+			ctr("loose", "", "", "paused", ""),
+		}),
+		chanImages: imagesToMap([]docker.ImageSummary{
+			{ID: "sha256:a", SizeBytes: 100},
+			{ID: "sha256:b", SizeBytes: 50, Dangling: true},
+		}),
+		chanVolumes: volumesToMap([]docker.VolumeSummary{{Name: "data"}}),
+	}
+
+	s := computeSummary(channels, []string{"web/app", "web/proxy", "db/pg", "gone/svc"})
+
+	wantStacks := map[string]int{"active": 2, "partially": 1, "exited": 1, "unhealthy": 1, "down": 1}
+	for label, n := range wantStacks {
+		if s.Stacks[label] != n {
+			t.Errorf("stacks[%s] = %d, want %d (all: %v)", label, s.Stacks[label], n, s.Stacks)
+		}
+	}
+	if s.Containers["running"] != 6 || s.Containers["exited"] != 3 || s.Containers["paused"] != 1 {
+		t.Errorf("containers = %v", s.Containers)
+	}
+	if s.Unhealthy != 1 {
+		t.Errorf("unhealthy = %d, want 1 (one service)", s.Unhealthy)
+	}
+	// nginx:1 is shared by two services; unknown keys count on their own
+	if s.Updates != 3 {
+		t.Errorf("updates = %d, want 3", s.Updates)
+	}
+	if s.Disk != (DiskSummary{Images: 2, Dangling: 1, ImageBytes: 150, Volumes: 1}) {
+		t.Errorf("disk = %+v", s.Disk)
+	}
+}
+
+func TestDashboardSummaryCached(t *testing.T) {
+	t.Parallel()
+	app := &App{}
+	for _, ch := range []string{chanStacks, chanContainers, chanImages, chanVolumes} {
+		app.replay.replace(ch, map[string]any{})
+	}
+	app.summary.setUpdates([]string{})
+
+	first := app.dashboardSummary()
+	if app.dashboardSummary() != first {
+		t.Fatal("unchanged state should reuse the summary")
+	}
+
+	app.replay.merge(chanContainers, containersToMap([]docker.ContainerBroadcast{{Name: "a", State: "running"}}))
+	second := app.dashboardSummary()
+	if second == first || second.Containers["running"] != 1 {
+		t.Errorf("summary not recomputed after a broadcast: %+v", second)
+	}
+
+	app.summary.setUpdates([]string{"x/y"})
+	if app.dashboardSummary().Updates != 1 {
+		t.Error("summary not recomputed after updates changed")
+	}
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/templates"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// templateListTimeout bounds listing templates, which may fetch remote
+// catalogs.
+const templateListTimeout = 30 * time.Second
+
+// RegisterTemplateHandlers registers the stack template catalog handlers,
+// and those creating stacks from a URL.
+func RegisterTemplateHandlers(app *App) {
+	app.WS.Handle("listTemplates", app.handleListTemplates)
+	app.WS.Handle("createStackFromTemplate", app.handleCreateStackFromTemplate)
+	app.WS.Handle("previewStackFromURL", app.handlePreviewStackFromURL)
+	app.WS.Handle("createStackFromURL", app.handleCreateStackFromURL)
+}
+
+// handleListTemplates returns the template catalog.
+func (app *App) handleListTemplates(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	if app.Templates == nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Templates are not available"})
+		}
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), templateListTimeout)
+	defer cancel()
+	list := app.Templates.List(ctx)
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK        bool                 `json:"ok"`
+			Templates []templates.Template `json:"templates"`
+		}{OK: true, Templates: list})
+	}
+}
+
+// handleCreateStackFromTemplate renders a template into a new stack. Like
+// saving, operators need approval when it's required. Args: {template,
+// stackName, variables}.
+func (app *App) handleCreateStackFromTemplate(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	var data struct {
+		Template  string            `json:"template"`
+		StackName string            `json:"stackName"`
+		Variables map[string]string `json:"variables"`
+	}
+	argObject(parseArgs(msg), 0, &data)
+	fail := func(text string) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: text})
+		}
+	}
+	if app.Templates == nil {
+		fail("Templates are not available")
+		return
+	}
+	if app.rejectNewStackName(c, msg, data.StackName) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), templateListTimeout)
+	tmpl := app.Templates.Get(ctx, data.Template)
+	cancel()
+	if tmpl == nil {
+		fail("Template not found: " + data.Template)
+		return
+	}
+	composeYAML, composeENV, err := tmpl.Render(data.Variables)
+	if err != nil {
+		fail(err.Error())
+		return
+	}
+	diags := lintStackFiles(composeYAML, "")
+	if rejectInvalidCompose(c, msg, diags) {
+		return
+	}
+
+	s := &stack.Stack{Name: data.StackName, ComposeYAML: composeYAML, ComposeENV: composeENV}
+	if !app.createNewStack(c, msg, s, fail) {
+		return
+	}
+	slog.Info("stack created from template", "stack", data.StackName, "template", tmpl.ID, "source", tmpl.Source)
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK              bool                `json:"ok"`
+			Msg             string              `json:"msg"`
+			MsgI18n         bool                `json:"msgi18n"`
+			StackName       string              `json:"stackName"`
+			MissingExternal []missingExternal   `json:"missingExternal,omitempty"`
+			Diagnostics     []composeDiagnostic `json:"diagnostics,omitempty"`
+		}{OK: true, Msg: "stackCreatedFromTemplate", MsgI18n: true, StackName: data.StackName,
+			MissingExternal: app.missingExternalResources(composeYAML, ""), Diagnostics: diags})
+	}
+}
+
+// createNewStack saves s as a new stack. It reports whether the stack was
+// saved; if not, the client has been answered: the name can't be used, saving
+// failed or the change was submitted for approval, as saving requires.
+func (app *App) createNewStack(c *ws.Conn, msg *ws.ClientMessage, s *stack.Stack, fail func(text string)) bool {
+	app.StackLocks.Lock(s.Name)
+	defer app.StackLocks.Unlock(s.Name)
+
+	if app.rejectNewStackName(c, msg, s.Name) {
+		return false
+	}
+	if user, ok := app.approvalRequired(c); ok {
+		app.submitPendingChange(c, msg, user, models.PendingActionSave, s, "")
+		return false
+	}
+	if err := s.SaveToDisk(app.StacksDir); err != nil {
+		slog.Error("create stack", "err", err, "stack", s.Name)
+		fail(err.Error())
+		return false
+	}
+	app.handleComposeYAMLSave(s.Name, s.ComposeYAML)
+	return true
+}
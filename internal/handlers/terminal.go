@@ -1,16 +1,18 @@
 package handlers
 
 import (
-    "bufio"
     "bytes"
     "context"
     "fmt"
+    "io"
     "log/slog"
     "sort"
+    "strconv"
     "strings"
     "sync"
     "time"
 
+    "github.com/cfilipov/dockge/internal/docker"
     "github.com/cfilipov/dockge/internal/terminal"
 )
 
@@ -29,12 +31,35 @@ var logColors = [...]string{
 
 const colorReset = "\033[0m"
 
+// stderrColor flags the lines a container wrote to stderr.
+const stderrColor = "\033[31m"
+
+// stderrMarker starts the stderr lines of a single container's log.
+const stderrMarker = stderrColor + "\u2503" + colorReset + " "
+
 // coloredPrefix returns " serviceName | " with ANSI color, padded to maxLen.
 func coloredPrefix(service string, maxLen int, colorIdx int) string {
     color := logColors[colorIdx%len(logColors)]
     return fmt.Sprintf("%s%-*s |%s ", color, maxLen, service, colorReset)
 }
 
+// stderrPrefix is coloredPrefix for stderr lines, with a red bar.
+func stderrPrefix(service string, maxLen int, colorIdx int) string {
+    color := logColors[colorIdx%len(logColors)]
+    return fmt.Sprintf("%s%-*s %s|%s ", color, maxLen, service, stderrColor, colorReset)
+}
+
+// openLogLines opens a container's log to read line by line, telling its
+// stdout and stderr apart.
+func (app *App) openLogLines(ctx context.Context, containerID string, opts docker.LogOptions) (*docker.LogScanner, io.Closer, error) {
+    opts.Framed = true
+    stream, tty, err := app.Docker.ContainerLogsWithOptions(ctx, containerID, opts)
+    if err != nil {
+        return nil, nil, err
+    }
+    return docker.NewLogScanner(stream, tty), stream, nil
+}
+
 // runBanner returns a bold banner line marking a container start boundary.
 // Returns empty string if startedAt is zero (mock mode / unknown).
 func runBanner(service string, startedAt time.Time) string {
@@ -54,8 +79,8 @@ func stopBanner(service string) string {
 // runContainerLogLoop streams logs for a single container (by stack+service),
 // reconnecting after stop/start cycles. It watches Docker events via the shared
 // EventBus to inject start/stop banners and to re-open the log stream when
-// the container restarts.
-func (app *App) runContainerLogLoop(ctx context.Context, term *terminal.Terminal, termName, stackName, serviceName string) {
+// the container restarts. stream is as for streamContainerLogsToChannel.
+func (app *App) runContainerLogLoop(ctx context.Context, term *terminal.Terminal, termName, stackName, serviceName, stream string) {
     defer app.Terms.RemoveAfter(termName, 30*time.Second)
 
     containerID, err := app.findContainerID(ctx, stackName, serviceName)
@@ -74,7 +99,7 @@ func (app *App) runContainerLogLoop(ctx context.Context, term *terminal.Terminal
     tail := "100"
     for {
         // Stream logs until EOF (container stopped or stream closed)
-        app.streamContainerLogsToChannel(ctx, containerID, tail, lineCh)
+        app.streamContainerLogsToChannel(ctx, containerID, tail, stream, lineCh)
 
         // After the first stream, only fetch new lines on reconnect
         tail = "0"
@@ -120,7 +145,7 @@ func (app *App) runContainerLogLoop(ctx context.Context, term *terminal.Terminal
 // runContainerLogByNameLoop streams logs for a single container (by name),
 // reconnecting after stop/start cycles. Uses the shared EventBus instead of
 // opening a dedicated Docker Events connection.
-func (app *App) runContainerLogByNameLoop(ctx context.Context, term *terminal.Terminal, termName, containerName string) {
+func (app *App) runContainerLogByNameLoop(ctx context.Context, term *terminal.Terminal, termName, containerName, stream string) {
     defer app.Terms.RemoveAfter(termName, 30*time.Second)
 
     eventCh, unsub := app.EventBus.Subscribe(64)
@@ -131,7 +156,7 @@ func (app *App) runContainerLogByNameLoop(ctx context.Context, term *terminal.Te
 
     tail := "100"
     for {
-        app.streamContainerLogsToChannel(ctx, containerName, tail, lineCh)
+        app.streamContainerLogsToChannel(ctx, containerName, tail, stream, lineCh)
         tail = "0"
 
         for {
@@ -140,8 +165,9 @@ func (app *App) runContainerLogByNameLoop(ctx context.Context, term *terminal.Te
                 if !ok {
                     return
                 }
-                evtName := evt.Project + "-" + evt.Service + "-1"
-                if evtName != containerName {
+                // Project is the stack name, which differs from the
+                // container name prefix for custom project names
+                if evt.Name != containerName && evt.Project+"-"+evt.Service+"-1" != containerName {
                     continue
                 }
                 switch evt.Action {
@@ -172,8 +198,10 @@ func (app *App) runContainerLogByNameLoop(ctx context.Context, term *terminal.Te
 
 // streamContainerLogsToChannel opens a log stream for a container and sends
 // each line to lineCh (for batch flushing) until the stream ends or ctx is cancelled.
-func (app *App) streamContainerLogsToChannel(ctx context.Context, containerID, tail string, lineCh chan<- []byte) {
-    stream, _, err := app.Docker.ContainerLogs(ctx, containerID, tail, true, false)
+// Lines written to stderr start with stderrMarker. stream is docker.LogStdout
+// or docker.LogStderr to send that stream only, "" for both.
+func (app *App) streamContainerLogsToChannel(ctx context.Context, containerID, tail, stream string, lineCh chan<- []byte) {
+    scanner, closer, err := app.openLogLines(ctx, containerID, docker.LogOptions{Tail: tail, Follow: true, Stream: stream})
     if err != nil {
         if ctx.Err() == nil {
             slog.Warn("container log stream", "err", err, "container", containerID)
@@ -184,17 +212,19 @@ func (app *App) streamContainerLogsToChannel(ctx context.Context, containerID, t
         }
         return
     }
-    defer stream.Close()
+    defer closer.Close()
 
-    scanner := bufio.NewScanner(stream)
-    scanner.Buffer(make([]byte, 64*1024), 64*1024)
     for scanner.Scan() {
         if ctx.Err() != nil {
             return
         }
-        line := make([]byte, len(scanner.Bytes())+1)
-        copy(line, scanner.Bytes())
-        line[len(line)-1] = '\n'
+        l := scanner.Line()
+        line := make([]byte, 0, len(stderrMarker)+len(l.Text)+1)
+        if l.Stream == docker.LogStderr {
+            line = append(line, stderrMarker...)
+        }
+        line = append(line, l.Text...)
+        line = append(line, '\n')
 
         select {
         case lineCh <- line:
@@ -214,17 +244,37 @@ func (app *App) startCombinedLogs(termName, stackName string) *terminal.Terminal
     ctx, cancel := context.WithCancel(context.Background())
     term.SetCancel(cancel)
 
-    go app.runCombinedLogs(ctx, term, stackName)
+    app.trackFollower(followerCombinedLog, term, cancel, func() {
+        app.runCombinedLogs(ctx, term, stackName, defaultCombinedLogOptions)
+    })
 
     return term
 }
 
+// combinedLogOptions selects what a combined log shows.
+type combinedLogOptions struct {
+    Tail     int             // historical lines per container
+    Services map[string]bool // services to show, nil for all
+    Stream   string          // docker.LogStdout or docker.LogStderr for that stream only
+}
+
+// defaultCombinedLogOptions are those of the stack's shared combined log.
+var defaultCombinedLogOptions = combinedLogOptions{Tail: 100}
+
+func (o combinedLogOptions) shows(service string) bool {
+    return o.Services == nil || o.Services[service]
+}
+
 // runCombinedLogs orchestrates per-container log readers and a batched flusher.
 // It subscribes to Docker events to inject run-boundary banners on restarts and
 // spawn new readers when containers are recreated or added. Blocks until ctx is
 // cancelled.
-func (app *App) runCombinedLogs(ctx context.Context, term *terminal.Terminal, stackName string) {
-    containers, err := app.Docker.ContainerList(ctx, true, stackName)
+//
+// Output is never dropped: when term's writes block (a client behind on its
+// acks), the flusher stops draining and the readers stop reading their
+// Docker streams until it catches up.
+func (app *App) runCombinedLogs(ctx context.Context, term *terminal.Terminal, stackName string, opts combinedLogOptions) {
+    all, err := app.Docker.ContainerList(ctx, true, stackName)
     if err != nil {
         if ctx.Err() == nil {
             slog.Warn("combined logs: list containers", "err", err, "stack", stackName)
@@ -232,6 +282,12 @@ func (app *App) runCombinedLogs(ctx context.Context, term *terminal.Terminal, st
         }
         return
     }
+    containers := all[:0]
+    for _, c := range all {
+        if opts.shows(c.Service) {
+            containers = append(containers, c)
+        }
+    }
 
     if len(containers) == 0 {
         return
@@ -289,27 +345,28 @@ func (app *App) runCombinedLogs(ctx context.Context, term *terminal.Terminal, st
     var allHistorical []tsLine
 
     for _, c := range containers {
-        stream, _, err := app.Docker.ContainerLogs(ctx, c.ID, "100", false, true) // no follow, with timestamps
+        scanner, closer, err := app.openLogLines(ctx, c.ID, docker.LogOptions{Tail: strconv.Itoa(opts.Tail), Timestamps: true, Stream: opts.Stream})
         if err != nil {
             if ctx.Err() == nil {
                 slog.Warn("combined logs: historical fetch", "err", err, "container", c.ID)
             }
             continue
         }
-        prefix := coloredPrefix(c.Service, maxLen, colorMap[c.Service])
-        scanner := bufio.NewScanner(stream)
-        scanner.Buffer(make([]byte, 64*1024), 64*1024)
         for scanner.Scan() {
-            raw := scanner.Text()
+            l := scanner.Line()
+            prefix := coloredPrefix(c.Service, maxLen, colorMap[c.Service])
+            if l.Stream == docker.LogStderr {
+                prefix = stderrPrefix(c.Service, maxLen, colorMap[c.Service])
+            }
             // Docker timestamps format: "2024-01-15T10:30:00.123456789Z rest of line"
-            ts, line := splitTimestamp(raw)
+            ts, line := splitTimestamp(string(l.Text))
             display := make([]byte, 0, len(prefix)+len(line)+1)
             display = append(display, prefix...)
             display = append(display, line...)
             display = append(display, '\n')
             allHistorical = append(allHistorical, tsLine{ts: ts, display: display})
         }
-        stream.Close()
+        closer.Close()
     }
 
     // Sort by timestamp (RFC3339Nano strings sort lexicographically)
@@ -333,7 +390,7 @@ func (app *App) runCombinedLogs(ctx context.Context, term *terminal.Terminal, st
         activeReaders.Store(c.ID, struct{}{})
         go func(id, svc string, idx int, running bool) {
             defer activeReaders.Delete(id)
-            app.readContainerLogs(ctx, id, svc, maxLen, idx, "0", true, running, lineCh)
+            app.readContainerLogs(ctx, id, svc, maxLen, idx, "0", opts.Stream, running, lineCh)
         }(c.ID, c.Service, colorMap[c.Service], wasRunning)
     }
 
@@ -349,7 +406,7 @@ func (app *App) runCombinedLogs(ctx context.Context, term *terminal.Terminal, st
             if !ok {
                 return
             }
-            if evt.Project != stackName {
+            if evt.Project != stackName || !opts.shows(evt.Service) {
                 continue
             }
             switch evt.Action {
@@ -367,7 +424,7 @@ func (app *App) runCombinedLogs(ctx context.Context, term *terminal.Terminal, st
                 if _, loaded := activeReaders.LoadOrStore(evt.ContainerID, struct{}{}); !loaded {
                     go func(id, svc string, ci int) {
                         defer activeReaders.Delete(id)
-                        app.readContainerLogs(ctx, id, svc, maxLen, ci, "0", true, true, lineCh)
+                        app.readContainerLogs(ctx, id, svc, maxLen, ci, "0", opts.Stream, true, lineCh)
                     }(evt.ContainerID, evt.Service, idx)
                 }
             }
@@ -377,8 +434,10 @@ func (app *App) runCombinedLogs(ctx context.Context, term *terminal.Terminal, st
     }
 }
 
-// readContainerLogs streams logs for a single container, prefixing each line
-// with a colored service name. Runs until the stream closes or ctx is cancelled.
+// readContainerLogs follows the logs of a single container, prefixing each
+// line with a colored service name (stderrPrefix for stderr lines) and
+// sending only one stream if stream is set. Runs until the stream
+// closes or ctx is cancelled.
 // Start banners are injected by the caller. Stop banners are injected here
 // after the stream ends (ensuring they appear after all shutdown log output).
 // wasRunning indicates the container was running when the reader started — if
@@ -386,24 +445,28 @@ func (app *App) runCombinedLogs(ctx context.Context, term *terminal.Terminal, st
 // stopped). If false (container was already stopped), no banner is shown.
 // Use tail="100" for initial readers (show history) and tail="0" for
 // event-spawned readers (follow only).
-func (app *App) readContainerLogs(ctx context.Context, containerID, service string, maxLen, colorIdx int, tail string, follow, wasRunning bool, lineCh chan<- []byte) {
-    stream, _, err := app.Docker.ContainerLogs(ctx, containerID, tail, follow, false)
+func (app *App) readContainerLogs(ctx context.Context, containerID, service string, maxLen, colorIdx int, tail, stream string, wasRunning bool, lineCh chan<- []byte) {
+    scanner, closer, err := app.openLogLines(ctx, containerID, docker.LogOptions{Tail: tail, Follow: true, Stream: stream})
     if err != nil {
         if ctx.Err() == nil {
             slog.Warn("combined logs: container stream", "err", err, "container", containerID)
         }
         return
     }
-    defer stream.Close()
+    defer closer.Close()
 
     prefix := coloredPrefix(service, maxLen, colorIdx)
+    errPrefix := stderrPrefix(service, maxLen, colorIdx)
 
-    scanner := bufio.NewScanner(stream)
-    scanner.Buffer(make([]byte, 64*1024), 64*1024)
     for scanner.Scan() {
-        line := make([]byte, 0, len(prefix)+len(scanner.Bytes())+1)
-        line = append(line, prefix...)
-        line = append(line, scanner.Bytes()...)
+        l := scanner.Line()
+        p := prefix
+        if l.Stream == docker.LogStderr {
+            p = errPrefix
+        }
+        line := make([]byte, 0, len(p)+len(l.Text)+1)
+        line = append(line, p...)
+        line = append(line, l.Text...)
         line = append(line, '\n')
 
         select {
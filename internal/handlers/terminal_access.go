@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// RegisterTerminalAccessHandlers registers the handlers that restrict exec
+// terminals per stack.
+func RegisterTerminalAccessHandlers(app *App) {
+	app.WS.Handle("getStackTerminalAccess", app.handleGetStackTerminalAccess)
+	app.WS.Handle("setStackTerminalAccess", app.handleSetStackTerminalAccess)
+}
+
+// terminalAccess returns a stack's restriction, or nil if it has none.
+// A lookup error counts as restricted to admins rather than open.
+func (app *App) terminalAccess(stackName string) *models.StackTerminalAccess {
+	if app.TerminalAccess == nil {
+		return nil
+	}
+	a, err := app.TerminalAccess.Get(stackName)
+	if err != nil {
+		slog.Warn("get terminal access", "err", err, "stack", stackName)
+		return &models.StackTerminalAccess{StackName: stackName}
+	}
+	return a
+}
+
+// canOpenStackTerminal reports whether the user may exec into the
+// containers of a stack.
+func (app *App) canOpenStackTerminal(user *models.User, stackName string) bool {
+	if user == nil {
+		return false
+	}
+	return app.terminalAccess(stackName).Allows(user)
+}
+
+// canOpenConsole reports whether the user may open the console. It's a
+// shell on the host in the stacks directory, from which compose files can
+// be edited and deployed without approval, so only admins get it.
+func (app *App) canOpenConsole(user *models.User) bool {
+	return user != nil && user.IsAdmin()
+}
+
+// containerStack returns the stack a container belongs to, by name or ID,
+// or "" if it isn't part of one.
+func (app *App) containerStack(container string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	list, err := app.Docker.ContainerListDetailed(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, ct := range list {
+		if ct.Name == container || ct.ContainerID == container || (len(container) >= 12 && strings.HasPrefix(ct.ContainerID, container)) {
+			return ct.StackName, nil
+		}
+	}
+	return "", nil
+}
+
+// checkTerminalAccess enforces terminal access for the terminal types that
+// run a shell, sending a join error and returning false if it's denied.
+func (app *App) checkTerminalAccess(c *ws.Conn, msg *ws.ClientMessage, args *ws.TerminalJoinArgs) bool {
+	user := app.currentUser(c)
+	stackName := args.Stack
+	switch args.Type {
+	case "exec":
+	case "exec-by-name":
+		var err error
+		if stackName, err = app.containerStack(args.Container); err != nil {
+			slog.Warn("terminal access: find container stack", "err", err, "container", args.Container)
+			sendJoinError(c, msg, "Permission denied: could not check terminal access")
+			return false
+		}
+		if stackName == "" {
+			return true
+		}
+		// Lets the join apply the stack's terminal env
+		args.Stack = stackName
+	case "console":
+		if !app.canOpenConsole(user) {
+			sendJoinError(c, msg, "Permission denied: admin role required")
+			return false
+		}
+		return true
+	default:
+		return true
+	}
+	if !app.canOpenStackTerminal(user, stackName) {
+		sendJoinError(c, msg, "Permission denied: no terminal access to stack "+stackName)
+		return false
+	}
+	return true
+}
+
+// deleteTerminalAccess drops the restriction of a stack whose files were
+// removed.
+func (app *App) deleteTerminalAccess(stackName string) {
+	if app.TerminalAccess == nil {
+		return
+	}
+	if err := app.TerminalAccess.Delete(stackName); err != nil {
+		slog.Warn("delete terminal access", "err", err, "stack", stackName)
+	}
+}
+
+// handleGetStackTerminalAccess returns a stack's restriction (null if
+// unrestricted) and the non-admin users it can grant access to. Admin only.
+// Args: stack name.
+func (app *App) handleGetStackTerminalAccess(c *ws.Conn, msg *ws.ClientMessage) {
+	if app.checkAdmin(c, msg) == nil {
+		return
+	}
+	stackName := argString(parseArgs(msg), 0)
+	if err := stack.ValidateStackName(stackName); err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+
+	users, err := app.Users.List()
+	if err != nil {
+		slog.Error("list users", "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+	operators := []string{}
+	for _, u := range users {
+		if !u.IsAdmin() {
+			operators = append(operators, u.Username)
+		}
+	}
+	slices.Sort(operators)
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK        bool                        `json:"ok"`
+			Access    *models.StackTerminalAccess `json:"access"`
+			Operators []string                    `json:"operators"`
+		}{OK: true, Access: app.terminalAccess(stackName), Operators: operators})
+	}
+}
+
+// handleSetStackTerminalAccess restricts a stack's exec terminals to admins
+// and the given users, or lifts the restriction. Admin only, requires sudo.
+// Args: stack name, {restricted, usernames}.
+func (app *App) handleSetStackTerminalAccess(c *ws.Conn, msg *ws.ClientMessage) {
+	admin := app.checkAdmin(c, msg)
+	if admin == nil || !app.requireSudo(c, msg) {
+		return
+	}
+	if app.TerminalAccess == nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Terminal access control is not available"})
+		}
+		return
+	}
+	args := parseArgs(msg)
+	stackName := argString(args, 0)
+	var req struct {
+		Restricted bool     `json:"restricted"`
+		Usernames  []string `json:"usernames"`
+	}
+	argObject(args, 1, &req)
+	if err := stack.ValidateStackName(stackName); err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+
+	var err error
+	if req.Restricted {
+		usernames := slices.Compact(slices.Sorted(slices.Values(req.Usernames)))
+		err = app.TerminalAccess.Set(models.StackTerminalAccess{StackName: stackName, Usernames: usernames, UpdatedBy: admin.Username})
+	} else {
+		err = app.TerminalAccess.Delete(stackName)
+	}
+	if err != nil {
+		slog.Error("set terminal access", "err", err, "stack", stackName)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+	slog.Info("stack terminal access changed", "stack", stackName, "restricted", req.Restricted, "by", admin.Username)
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Saved"})
+	}
+}
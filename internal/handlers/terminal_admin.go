@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/terminal"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// terminalCleanupPolicies close terminals nobody has watched for a while,
+// by name prefix. Owners normally clean up after themselves (RemoveAfter,
+// the last writer leaving a log stream); these catch what they miss.
+var terminalCleanupPolicies = []terminal.CleanupPolicy{
+	{Prefix: "compose-", Idle: 15 * time.Minute},        // compose action output
+	{Prefix: "container-", Idle: 5 * time.Minute},       // service action output
+	{Prefix: "container-log-", Idle: 2 * time.Minute},   // log followers
+	{Prefix: "combined-", Idle: 2 * time.Minute},        // combined stack logs
+	{Prefix: "container-exec-", Idle: 30 * time.Minute}, // container shells
+	{Prefix: "console", Idle: 30 * time.Minute},         // Dockge console
+	{Prefix: "host-terminal-", Idle: 15 * time.Minute},  // host shells
+}
+
+// RegisterTerminalAdminHandlers installs the terminal cleanup policies and
+// registers the handlers listing and force-closing terminals.
+func RegisterTerminalAdminHandlers(app *App) {
+	app.Terms.SetCleanupPolicies(terminalCleanupPolicies)
+	app.WS.Handle("getTerminals", app.handleGetTerminals)
+	app.WS.Handle("closeTerminal", app.handleCloseTerminal)
+}
+
+// terminalPolicyInfo is a cleanup policy as the frontend shows it.
+type terminalPolicyInfo struct {
+	Prefix      string `json:"prefix"`
+	IdleSeconds int64  `json:"idleSeconds"`
+}
+
+// handleGetTerminals lists every live terminal with its writers, output
+// volume, age and idle time, and the cleanup policies. Admin only.
+func (app *App) handleGetTerminals(c *ws.Conn, msg *ws.ClientMessage) {
+	if app.checkAdmin(c, msg) == nil {
+		return
+	}
+	policies := []terminalPolicyInfo{}
+	for _, p := range app.Terms.CleanupPolicies() {
+		policies = append(policies, terminalPolicyInfo{Prefix: p.Prefix, IdleSeconds: int64(p.Idle / time.Second)})
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK        bool                    `json:"ok"`
+			Terminals []terminal.TerminalInfo `json:"terminals"`
+			Policies  []terminalPolicyInfo    `json:"policies"`
+		}{true, app.Terms.Snapshot(), policies})
+	}
+}
+
+// handleCloseTerminal force-closes a terminal, killing its process or
+// stream, e.g. a leaked shell. Clients watching it are told it was closed.
+// Admin only. Args: terminal name.
+func (app *App) handleCloseTerminal(c *ws.Conn, msg *ws.ClientMessage) {
+	admin := app.checkAdmin(c, msg)
+	if admin == nil {
+		return
+	}
+	name := argString(parseArgs(msg), 0)
+	term := app.Terms.Get(name)
+	if term == nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Terminal not found"})
+		}
+		return
+	}
+
+	term.Write([]byte("\r\n[Closed by " + admin.Username + "]\r\n"))
+	app.Terms.Remove(name)
+	slog.Info("terminal force-closed", "name", name, "user", admin.Username)
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Closed"})
+	}
+}
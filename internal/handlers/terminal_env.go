@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// Settings keys of the global exec terminal environment. Stacks can
+// override each of them (see models.StackTerminalEnv).
+const (
+	settingTerminalTerm   = "terminalTerm"
+	settingTerminalLang   = "terminalLang"
+	settingTerminalShells = "terminalShells" // comma- or space-separated, in order of preference
+)
+
+// defaultTerminalTerm matches what xterm.js in the browser understands.
+const defaultTerminalTerm = "xterm-256color"
+
+// defaultTerminalShells is the fallback order of exec shells: bash where
+// it's installed, then the POSIX sh every image but distroless has, then
+// busybox ash for minimal images that lack an sh link.
+var defaultTerminalShells = []string{"bash", "sh", "ash"}
+
+var (
+	// terminalShellRe allows shell names and absolute paths. Candidates are
+	// pasted into an sh -c script, so nothing that needs quoting is allowed.
+	terminalShellRe = regexp.MustCompile(`^[A-Za-z0-9_./+-]+$`)
+	// terminalEnvValueRe covers TERM names and locales like en_US.UTF-8.
+	terminalEnvValueRe = regexp.MustCompile(`^[A-Za-z0-9_.@+-]*$`)
+)
+
+// RegisterTerminalEnvHandlers registers the handlers of per-stack terminal
+// environments.
+func RegisterTerminalEnvHandlers(app *App) {
+	app.WS.Handle("getStackTerminalEnv", app.handleGetStackTerminalEnv)
+	app.WS.Handle("setStackTerminalEnv", app.handleSetStackTerminalEnv)
+}
+
+// execEnv is the environment an exec terminal is started with.
+type execEnv struct {
+	Term   string
+	Lang   string   // "" leaves the image's locale alone
+	Shells []string // candidates, first one found in the container wins
+}
+
+// vars returns the environment variables of the exec.
+func (e execEnv) vars() []string {
+	var vars []string
+	if e.Term != "" {
+		vars = append(vars, "TERM="+e.Term)
+	}
+	if e.Lang != "" {
+		vars = append(vars, "LANG="+e.Lang)
+	}
+	return vars
+}
+
+// shellCommand returns the command to exec: a probe that execs the first
+// candidate installed in the container, trying the shell the client asked
+// for before the others. A requested shell that isn't safe to put in the
+// probe is exec'd as is, without fallbacks.
+func (e execEnv) shellCommand(requested string) []string {
+	shells := e.Shells
+	if requested != "" {
+		if !terminalShellRe.MatchString(requested) {
+			return []string{requested}
+		}
+		shells = append([]string{requested}, slices.DeleteFunc(slices.Clone(shells), func(s string) bool { return s == requested })...)
+	}
+	if len(shells) == 1 {
+		return []string{shells[0]}
+	}
+	names := strings.Join(shells, " ")
+	script := "for s in " + names + `; do command -v "$s" >/dev/null 2>&1 && exec "$s"; done; ` +
+		`echo "no shell found (tried: ` + names + `)" >&2; exit 127`
+	return []string{"sh", "-c", script}
+}
+
+// parseTerminalShells splits a shell list, dropping names that aren't
+// safe to run.
+func parseTerminalShells(val string) []string {
+	var shells []string
+	for _, s := range strings.FieldsFunc(val, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+		if !terminalShellRe.MatchString(s) {
+			slog.Warn("ignoring invalid terminal shell", "shell", s)
+			continue
+		}
+		shells = append(shells, s)
+	}
+	return shells
+}
+
+// terminalEnv returns the exec environment of a stack: its override over
+// the global settings over the defaults. stackName may be "" for
+// containers outside any stack.
+func (app *App) terminalEnv(stackName string) execEnv {
+	env := execEnv{Term: defaultTerminalTerm, Shells: defaultTerminalShells}
+	if v, _ := app.Settings.Get(settingTerminalTerm); v != "" && terminalEnvValueRe.MatchString(v) {
+		env.Term = v
+	}
+	if v, _ := app.Settings.Get(settingTerminalLang); terminalEnvValueRe.MatchString(v) {
+		env.Lang = v
+	}
+	if v, _ := app.Settings.Get(settingTerminalShells); v != "" {
+		if shells := parseTerminalShells(v); len(shells) > 0 {
+			env.Shells = shells
+		}
+	}
+
+	if o := app.stackTerminalEnv(stackName); o != nil {
+		if o.Term != "" {
+			env.Term = o.Term
+		}
+		if o.Lang != "" {
+			env.Lang = o.Lang
+		}
+		if len(o.Shells) > 0 {
+			env.Shells = o.Shells
+		}
+	}
+	return env
+}
+
+// stackTerminalEnv returns a stack's override, or nil if it has none.
+func (app *App) stackTerminalEnv(stackName string) *models.StackTerminalEnv {
+	if app.TerminalEnv == nil || stackName == "" {
+		return nil
+	}
+	e, err := app.TerminalEnv.Get(stackName)
+	if err != nil {
+		slog.Warn("get terminal env", "err", err, "stack", stackName)
+		return nil
+	}
+	return e
+}
+
+// deleteTerminalEnv drops the override of a stack whose files were removed.
+func (app *App) deleteTerminalEnv(stackName string) {
+	if app.TerminalEnv == nil {
+		return
+	}
+	if err := app.TerminalEnv.Delete(stackName); err != nil {
+		slog.Warn("delete terminal env", "err", err, "stack", stackName)
+	}
+}
+
+// handleGetStackTerminalEnv returns a stack's override (null if it has
+// none). Admin only. Args: stack name.
+func (app *App) handleGetStackTerminalEnv(c *ws.Conn, msg *ws.ClientMessage) {
+	if app.checkAdmin(c, msg) == nil {
+		return
+	}
+	stackName := argString(parseArgs(msg), 0)
+	if err := stack.ValidateStackName(stackName); err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK  bool                     `json:"ok"`
+			Env *models.StackTerminalEnv `json:"env"`
+		}{OK: true, Env: app.stackTerminalEnv(stackName)})
+	}
+}
+
+// handleSetStackTerminalEnv replaces a stack's override; all fields empty
+// removes it. Admin only. Args: stack name, {term, lang, shells}.
+func (app *App) handleSetStackTerminalEnv(c *ws.Conn, msg *ws.ClientMessage) {
+	admin := app.checkAdmin(c, msg)
+	if admin == nil {
+		return
+	}
+	if app.TerminalEnv == nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Terminal settings are not available"})
+		}
+		return
+	}
+	args := parseArgs(msg)
+	stackName := argString(args, 0)
+	var req struct {
+		Term   string `json:"term"`
+		Lang   string `json:"lang"`
+		Shells string `json:"shells"`
+	}
+	argObject(args, 1, &req)
+	if err := stack.ValidateStackName(stackName); err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+
+	env := models.StackTerminalEnv{
+		StackName: stackName,
+		Term:      strings.TrimSpace(req.Term),
+		Lang:      strings.TrimSpace(req.Lang),
+		UpdatedBy: admin.Username,
+	}
+	invalid := !terminalEnvValueRe.MatchString(env.Term) || !terminalEnvValueRe.MatchString(env.Lang)
+	for _, s := range strings.FieldsFunc(req.Shells, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+		invalid = invalid || !terminalShellRe.MatchString(s)
+		env.Shells = append(env.Shells, s)
+	}
+	if invalid {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "invalidTerminalEnv", MsgI18n: true})
+		}
+		return
+	}
+
+	if err := app.TerminalEnv.Set(env); err != nil {
+		slog.Error("set terminal env", "err", err, "stack", stackName)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Saved"})
+	}
+}
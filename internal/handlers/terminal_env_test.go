@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/cfilipov/dockge/internal/db"
+	"github.com/cfilipov/dockge/internal/models"
+)
+
+func TestTerminalEnv(t *testing.T) {
+	t.Parallel()
+	database, err := db.Open(filepath.Join(t.TempDir(), "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	app := &App{
+		Settings:    models.NewSettingStore(database),
+		TerminalEnv: models.NewStackTerminalEnvStore(database),
+	}
+
+	env := app.terminalEnv("web")
+	if env.Term != defaultTerminalTerm || env.Lang != "" || !slices.Equal(env.Shells, defaultTerminalShells) {
+		t.Errorf("defaults = %+v", env)
+	}
+
+	app.Settings.Set(settingTerminalLang, "C.UTF-8")
+	app.Settings.Set(settingTerminalShells, "zsh, bash;rm sh")
+	if err := app.TerminalEnv.Set(models.StackTerminalEnv{StackName: "web", Term: "xterm", Shells: []string{"ash"}}); err != nil {
+		t.Fatal(err)
+	}
+	if env := app.terminalEnv("db"); env.Lang != "C.UTF-8" || !slices.Equal(env.Shells, []string{"zsh", "sh"}) {
+		t.Errorf("global settings = %+v, want the invalid shell dropped", env)
+	}
+	env = app.terminalEnv("web")
+	if env.Term != "xterm" || env.Lang != "C.UTF-8" || !slices.Equal(env.Shells, []string{"ash"}) {
+		t.Errorf("stack override = %+v", env)
+	}
+	if got := env.vars(); !slices.Equal(got, []string{"TERM=xterm", "LANG=C.UTF-8"}) {
+		t.Errorf("vars = %v", got)
+	}
+}
+
+func TestExecShellCommand(t *testing.T) {
+	t.Parallel()
+	env := execEnv{Shells: []string{"bash", "sh", "ash"}}
+	// A requested shell is tried first, falling back to the others
+	got := env.shellCommand("sh")
+	if want := `for s in sh bash ash; do`; len(got) != 3 || got[2][:len(want)] != want {
+		t.Errorf("requested shell: %v", got)
+	}
+	if got := env.shellCommand("zsh;reboot"); !slices.Equal(got, []string{"zsh;reboot"}) {
+		t.Errorf("unsafe requested shell: %v", got)
+	}
+	if got := (execEnv{Shells: []string{"zsh"}}).shellCommand("zsh"); !slices.Equal(got, []string{"zsh"}) {
+		t.Errorf("requested single candidate: %v", got)
+	}
+	got = env.shellCommand("")
+	if len(got) != 3 || got[0] != "sh" || got[1] != "-c" {
+		t.Fatalf("probe = %v", got)
+	}
+	if want := `for s in bash sh ash; do command -v "$s" >/dev/null 2>&1 && exec "$s"; done;`; got[2][:len(want)] != want {
+		t.Errorf("probe script = %q", got[2])
+	}
+	if got := (execEnv{Shells: []string{"ash"}}).shellCommand(""); !slices.Equal(got, []string{"ash"}) {
+		t.Errorf("single candidate: %v", got)
+	}
+}
@@ -6,10 +6,9 @@ import (
 	"log/slog"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"time"
 
-	"github.com/cfilipov/dockge/internal/compose"
+	"github.com/cfilipov/dockge/internal/docker"
 	"github.com/cfilipov/dockge/internal/stack"
 	"github.com/cfilipov/dockge/internal/terminal"
 	"github.com/cfilipov/dockge/internal/ws"
@@ -30,13 +29,11 @@ func RegisterTerminalHandlers(app *App) {
 			}
 			return
 		}
-		if joinArgs.Shell == "" {
-			joinArgs.Shell = "bash"
-		}
-
 		app.handleTerminalJoin(c, msg, &joinArgs)
 	})
 
+	app.WS.Handle("getExecDefaults", app.handleGetExecDefaults)
+
 	app.WS.Handle("terminalLeave", func(c *ws.Conn, msg *ws.ClientMessage) {
 		if checkLogin(c, msg) == 0 {
 			return
@@ -78,11 +75,11 @@ func RegisterTerminalHandlers(app *App) {
 		}
 
 		switch data[0] {
-		case 0x00: // input
+		case ws.OpInput:
 			if len(data) > 1 {
 				term.Input(string(data[1:]))
 			}
-		case 0x01: // resize
+		case ws.OpResize:
 			if len(data) >= 5 {
 				rows := binary.BigEndian.Uint16(data[1:3])
 				cols := binary.BigEndian.Uint16(data[3:5])
@@ -94,16 +91,11 @@ func RegisterTerminalHandlers(app *App) {
 	})
 }
 
-// sessionBinaryWriter creates a terminal.WriteFunc that prepends the 2-byte
-// session ID header before sending binary frames on the main WS connection.
+// sessionBinaryWriter creates a terminal.WriteFunc that sends output to a
+// session as binary frames on the main WS connection.
 func sessionBinaryWriter(c *ws.Conn, sessionID uint16) terminal.WriteFunc {
-	header := make([]byte, 2)
-	binary.BigEndian.PutUint16(header, sessionID)
 	return func(data string) {
-		buf := make([]byte, 2+len(data))
-		copy(buf, header)
-		copy(buf[2:], data)
-		c.WriteBinary(buf)
+		c.WriteSession(sessionID, []byte(data))
 	}
 }
 
@@ -116,6 +108,13 @@ func (app *App) handleTerminalJoin(c *ws.Conn, msg *ws.ClientMessage, args *ws.T
 			return
 		}
 	}
+	if !app.checkTerminalAccess(c, msg, args) {
+		return
+	}
+	if args.Stream != "" && args.Stream != docker.LogStdout && args.Stream != docker.LogStderr {
+		sendJoinError(c, msg, "invalid stream "+args.Stream)
+		return
+	}
 
 	switch args.Type {
 	case "combined":
@@ -181,29 +180,35 @@ func sendJoinError(c *ws.Conn, msg *ws.ClientMessage, errMsg string) {
 	}
 }
 
-// allocJoinAndReplay atomically registers a writer via JoinAndGetBuffer and sends buffer replay.
-func (app *App) allocJoinAndReplay(c *ws.Conn, msg *ws.ClientMessage, termName string, interactive bool, term *terminal.Terminal) (uint16, string) {
+// allocJoinAndReplay atomically registers a writer via JoinFrom and sends buffer
+// replay. A client resuming the same stream only gets the output it missed.
+func (app *App) allocJoinAndReplay(c *ws.Conn, msg *ws.ClientMessage, termName string, interactive bool, term *terminal.Terminal, args *ws.TerminalJoinArgs) (uint16, string) {
 	session := &ws.TermSession{
 		TermName:    termName,
 		Interactive: interactive,
+		FlowControl: args.FlowControl,
 	}
 	sessionID := c.AllocSession(session)
 
 	writer := sessionBinaryWriter(c, sessionID)
-	buf := term.JoinAndGetBuffer(session.WriterKey, writer)
+	buf, offset, resumed := term.JoinFrom(session.WriterKey, writer, args.StreamID, args.Offset)
+	if resumed {
+		slog.Debug("terminalJoin resumed", "term", termName, "offset", offset, "replay", len(buf))
+	}
 
 	if msg.ID != nil {
-		ws.SendAck(c, *msg.ID, ws.TerminalJoinResponse{OK: true, SessionID: sessionID})
+		ws.SendAck(c, *msg.ID, ws.TerminalJoinResponse{
+			OK:        true,
+			SessionID: sessionID,
+			StreamID:  term.StreamID(),
+			Offset:    offset,
+			Resumed:   resumed,
+		})
 	}
 
 	// Send buffer replay as binary frames
 	if buf != "" {
-		header := make([]byte, 2)
-		binary.BigEndian.PutUint16(header, sessionID)
-		payload := make([]byte, 2+len(buf))
-		copy(payload, header)
-		copy(payload[2:], buf)
-		c.WriteBinary(payload)
+		c.WriteSession(sessionID, []byte(buf))
 	}
 
 	return sessionID, session.WriterKey
@@ -221,100 +226,86 @@ func (app *App) joinCombined(c *ws.Conn, msg *ws.ClientMessage, args *ws.Termina
 		return
 	}
 
-	app.allocJoinAndReplay(c, msg, termName, false, term)
+	app.allocJoinAndReplay(c, msg, termName, false, term, args)
 }
 
 func (app *App) joinContainerLog(c *ws.Conn, msg *ws.ClientMessage, args *ws.TerminalJoinArgs) {
-	termName := "container-log-" + args.Service
+	termName := "container-log-" + args.Service + streamSuffix(args.Stream)
+
+	// A reconnecting client resumes the running follower instead of
+	// restarting the log tail.
+	if existing := app.Terms.Get(termName); existing != nil && existing.CanResume(args.StreamID) && existing.HasCancel() {
+		app.allocJoinAndReplay(c, msg, termName, false, existing, args)
+		return
+	}
 
 	term := app.Terms.Recreate(termName, terminal.TypePipe)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	term.SetCancel(cancel)
 
-	go app.runContainerLogLoop(ctx, term, termName, args.Stack, args.Service)
-
-	session := &ws.TermSession{
-		TermName:    termName,
-		Interactive: false,
-	}
-	sessionID := c.AllocSession(session)
+	app.trackFollower(followerContainerLog, term, cancel, func() {
+		app.runContainerLogLoop(ctx, term, termName, args.Stack, args.Service, args.Stream)
+	})
 
-	writer := sessionBinaryWriter(c, sessionID)
-	term.AddWriter(session.WriterKey, writer)
+	app.allocJoinAndReplay(c, msg, termName, false, term, args)
+}
 
-	if msg.ID != nil {
-		ws.SendAck(c, *msg.ID, ws.TerminalJoinResponse{OK: true, SessionID: sessionID})
-	}
+func (app *App) joinContainerLogByName(c *ws.Conn, msg *ws.ClientMessage, args *ws.TerminalJoinArgs) {
+	termName := "container-log-by-name-" + args.Container + streamSuffix(args.Stream)
 
-	// Send buffer replay
-	buf := term.Buffer()
-	if buf != "" {
-		header := make([]byte, 2)
-		binary.BigEndian.PutUint16(header, sessionID)
-		payload := make([]byte, 2+len(buf))
-		copy(payload, header)
-		copy(payload[2:], buf)
-		c.WriteBinary(payload)
+	if existing := app.Terms.Get(termName); existing != nil && existing.CanResume(args.StreamID) && existing.HasCancel() {
+		app.allocJoinAndReplay(c, msg, termName, false, existing, args)
+		return
 	}
-}
 
-func (app *App) joinContainerLogByName(c *ws.Conn, msg *ws.ClientMessage, args *ws.TerminalJoinArgs) {
-	termName := "container-log-by-name-" + args.Container
 	term := app.Terms.Recreate(termName, terminal.TypePipe)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	term.SetCancel(cancel)
 
-	go app.runContainerLogByNameLoop(ctx, term, termName, args.Container)
-
-	session := &ws.TermSession{
-		TermName:    termName,
-		Interactive: false,
-	}
-	sessionID := c.AllocSession(session)
-
-	writer := sessionBinaryWriter(c, sessionID)
-	term.AddWriter(session.WriterKey, writer)
+	app.trackFollower(followerContainerLogByName, term, cancel, func() {
+		app.runContainerLogByNameLoop(ctx, term, termName, args.Container, args.Stream)
+	})
 
-	if msg.ID != nil {
-		ws.SendAck(c, *msg.ID, ws.TerminalJoinResponse{OK: true, SessionID: sessionID})
-	}
+	app.allocJoinAndReplay(c, msg, termName, false, term, args)
+}
 
-	buf := term.Buffer()
-	if buf != "" {
-		header := make([]byte, 2)
-		binary.BigEndian.PutUint16(header, sessionID)
-		payload := make([]byte, 2+len(buf))
-		copy(payload, header)
-		copy(payload[2:], buf)
-		c.WriteBinary(payload)
+// streamSuffix tells the terminals of one log stream from those of both.
+func streamSuffix(stream string) string {
+	if stream == "" {
+		return ""
 	}
+	return "-" + stream
 }
 
 func (app *App) joinExec(c *ws.Conn, msg *ws.ClientMessage, args *ws.TerminalJoinArgs) {
 	termName := "container-exec-" + args.Stack + "-" + args.Service + "-0"
 
+	opts, err := app.execOptions(c, args)
+	if err != nil {
+		sendJoinError(c, msg, err.Error())
+		return
+	}
+
 	term := app.Terms.Recreate(termName, terminal.TypePTY)
 
 	session := &ws.TermSession{
 		TermName:    termName,
 		Interactive: true,
+		FlowControl: args.FlowControl,
 	}
 	sessionID := c.AllocSession(session)
 
 	writer := sessionBinaryWriter(c, sessionID)
 	term.AddWriter(session.WriterKey, writer)
 
-	dir := filepath.Join(app.StacksDir, args.Stack)
-	execArgs := []string{"compose"}
-	execArgs = append(execArgs, compose.GlobalEnvArgs(app.StacksDir, args.Stack)...)
-	execArgs = append(execArgs, "exec", args.Service, args.Shell)
-	cmd := exec.Command("docker", execArgs...)
-	cmd.Dir = dir
-	cmd.Env = os.Environ()
-
-	if err := term.StartPTY(cmd); err != nil {
+	var s docker.ExecSession
+	containerID, err := app.serviceContainer(args.Stack, args.Service)
+	if err == nil {
+		s, err = app.startExec(term, containerID, args.Stack, args.Shell, opts)
+	}
+	if err != nil {
 		slog.Error("terminalJoin exec start", "err", err, "stack", args.Stack, "service", args.Service)
 		app.Terms.Remove(termName)
 		c.RemoveSession(sessionID)
@@ -324,51 +315,34 @@ func (app *App) joinExec(c *ws.Conn, msg *ws.ClientMessage, args *ws.TerminalJoi
 
 	term.OnExit(func() {
 		app.Terms.RemoveAfter(termName, 30*time.Second)
-		// Notify client that terminal exited
-		ws.SendEvent(c, "terminalExited", ws.TerminalExitedData{SessionID: sessionID})
+		// Notify client that terminal exited, and how
+		ws.SendEvent(c, "terminalExited", execExitedData(sessionID, s))
 	})
 
 	if msg.ID != nil {
-		ws.SendAck(c, *msg.ID, ws.TerminalJoinResponse{OK: true, SessionID: sessionID})
+		ws.SendAck(c, *msg.ID, ws.TerminalJoinResponse{OK: true, SessionID: sessionID, StreamID: term.StreamID()})
 	}
 
 	buf := term.Buffer()
 	if buf != "" {
-		header := make([]byte, 2)
-		binary.BigEndian.PutUint16(header, sessionID)
-		payload := make([]byte, 2+len(buf))
-		copy(payload, header)
-		copy(payload[2:], buf)
-		c.WriteBinary(payload)
+		c.WriteSession(sessionID, []byte(buf))
 	}
 }
 
 func (app *App) joinExecByName(c *ws.Conn, msg *ws.ClientMessage, args *ws.TerminalJoinArgs) {
 	termName := "container-exec-by-name-" + args.Container
 
-	// Check if already running
+	// Check if already running. Asking for exec options starts a new shell
+	// with them, unless it's a reconnect resuming the running one.
 	existing := app.Terms.Get(termName)
-	if existing != nil && existing.IsRunning() {
-		session := &ws.TermSession{
-			TermName:    termName,
-			Interactive: true,
-		}
-		sessionID := c.AllocSession(session)
-		writer := sessionBinaryWriter(c, sessionID)
-		existing.AddWriter(session.WriterKey, writer)
+	if existing != nil && existing.IsRunning() && (args.Exec == nil || args.StreamID != "") {
+		app.allocJoinAndReplay(c, msg, termName, true, existing, args)
+		return
+	}
 
-		if msg.ID != nil {
-			ws.SendAck(c, *msg.ID, ws.TerminalJoinResponse{OK: true, SessionID: sessionID})
-		}
-		buf := existing.Buffer()
-		if buf != "" {
-			header := make([]byte, 2)
-			binary.BigEndian.PutUint16(header, sessionID)
-			payload := make([]byte, 2+len(buf))
-			copy(payload, header)
-			copy(payload[2:], buf)
-			c.WriteBinary(payload)
-		}
+	opts, err := app.execOptions(c, args)
+	if err != nil {
+		sendJoinError(c, msg, err.Error())
 		return
 	}
 
@@ -377,15 +351,16 @@ func (app *App) joinExecByName(c *ws.Conn, msg *ws.ClientMessage, args *ws.Termi
 	session := &ws.TermSession{
 		TermName:    termName,
 		Interactive: true,
+		FlowControl: args.FlowControl,
 	}
 	sessionID := c.AllocSession(session)
 	writer := sessionBinaryWriter(c, sessionID)
 	term.AddWriter(session.WriterKey, writer)
 
-	cmd := exec.Command("docker", "exec", "-it", args.Container, args.Shell)
-	cmd.Env = os.Environ()
-
-	if err := term.StartPTY(cmd); err != nil {
+	// args.Stack was filled in from the container's labels by
+	// checkTerminalAccess
+	s, err := app.startExec(term, args.Container, args.Stack, args.Shell, opts)
+	if err != nil {
 		slog.Error("terminalJoin exec-by-name start", "err", err, "container", args.Container)
 		app.Terms.Remove(termName)
 		c.RemoveSession(sessionID)
@@ -395,50 +370,39 @@ func (app *App) joinExecByName(c *ws.Conn, msg *ws.ClientMessage, args *ws.Termi
 
 	term.OnExit(func() {
 		app.Terms.RemoveAfter(termName, 30*time.Second)
-		ws.SendEvent(c, "terminalExited", ws.TerminalExitedData{SessionID: sessionID})
+		ws.SendEvent(c, "terminalExited", execExitedData(sessionID, s))
 	})
 
 	if msg.ID != nil {
-		ws.SendAck(c, *msg.ID, ws.TerminalJoinResponse{OK: true, SessionID: sessionID})
+		ws.SendAck(c, *msg.ID, ws.TerminalJoinResponse{OK: true, SessionID: sessionID, StreamID: term.StreamID()})
 	}
 
 	buf := term.Buffer()
 	if buf != "" {
-		header := make([]byte, 2)
-		binary.BigEndian.PutUint16(header, sessionID)
-		payload := make([]byte, 2+len(buf))
-		copy(payload, header)
-		copy(payload[2:], buf)
-		c.WriteBinary(payload)
+		c.WriteSession(sessionID, []byte(buf))
 	}
 }
 
+// joinConsole opens the console, a shell in the stacks directory, or joins
+// it. Like the host terminal it's disabled unless --host-terminal is set,
+// and opening a shell needs sudo mode; checkTerminalAccess keeps it to
+// admins.
 func (app *App) joinConsole(c *ws.Conn, msg *ws.ClientMessage, args *ws.TerminalJoinArgs) {
 	termName := "console"
+	if app.HostTerminal == "" {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "hostTerminalDisabled", MsgI18n: true})
+		}
+		return
+	}
 
 	// Check if already running
 	existing := app.Terms.Get(termName)
 	if existing != nil && existing.IsRunning() {
-		session := &ws.TermSession{
-			TermName:    termName,
-			Interactive: true,
-		}
-		sessionID := c.AllocSession(session)
-		writer := sessionBinaryWriter(c, sessionID)
-		existing.AddWriter(session.WriterKey, writer)
-
-		if msg.ID != nil {
-			ws.SendAck(c, *msg.ID, ws.TerminalJoinResponse{OK: true, SessionID: sessionID})
-		}
-		buf := existing.Buffer()
-		if buf != "" {
-			header := make([]byte, 2)
-			binary.BigEndian.PutUint16(header, sessionID)
-			payload := make([]byte, 2+len(buf))
-			copy(payload, header)
-			copy(payload[2:], buf)
-			c.WriteBinary(payload)
-		}
+		app.allocJoinAndReplay(c, msg, termName, true, existing, args)
+		return
+	}
+	if !app.requireSudo(c, msg) {
 		return
 	}
 
@@ -447,12 +411,16 @@ func (app *App) joinConsole(c *ws.Conn, msg *ws.ClientMessage, args *ws.Terminal
 	session := &ws.TermSession{
 		TermName:    termName,
 		Interactive: true,
+		FlowControl: args.FlowControl,
 	}
 	sessionID := c.AllocSession(session)
 	writer := sessionBinaryWriter(c, sessionID)
 	term.AddWriter(session.WriterKey, writer)
 
 	shell := args.Shell
+	if shell == "" {
+		shell = "bash"
+	}
 	if _, err := exec.LookPath("bash"); err != nil {
 		shell = "sh"
 	}
@@ -473,18 +441,18 @@ func (app *App) joinConsole(c *ws.Conn, msg *ws.ClientMessage, args *ws.Terminal
 	mainTerminalMu.Unlock()
 
 	if msg.ID != nil {
-		ws.SendAck(c, *msg.ID, ws.TerminalJoinResponse{OK: true, SessionID: sessionID})
+		ws.SendAck(c, *msg.ID, ws.TerminalJoinResponse{OK: true, SessionID: sessionID, StreamID: term.StreamID()})
 	}
 }
 
 func (app *App) joinCompose(c *ws.Conn, msg *ws.ClientMessage, args *ws.TerminalJoinArgs) {
 	termName := "compose-" + args.Stack
 	term := app.Terms.GetOrCreate(termName)
-	app.allocJoinAndReplay(c, msg, termName, false, term)
+	app.allocJoinAndReplay(c, msg, termName, false, term, args)
 }
 
 func (app *App) joinContainerAction(c *ws.Conn, msg *ws.ClientMessage, args *ws.TerminalJoinArgs) {
 	termName := "container-" + args.Container
 	term := app.Terms.GetOrCreate(termName)
-	app.allocJoinAndReplay(c, msg, termName, false, term)
+	app.allocJoinAndReplay(c, msg, termName, false, term, args)
 }
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"log/slog"
+	"sort"
+
+	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// RegisterUpdateIgnoreHandlers registers the image update ignore list handlers.
+func RegisterUpdateIgnoreHandlers(app *App) {
+	app.WS.Handle("getUpdateIgnores", app.handleGetUpdateIgnores)
+	app.WS.Handle("addUpdateIgnore", app.handleAddUpdateIgnore)
+	app.WS.Handle("removeUpdateIgnore", app.handleRemoveUpdateIgnore)
+}
+
+// updateIgnoreMatcher returns the ignore list matcher, or one that ignores
+// nothing when the list is disabled or can't be read.
+func (app *App) updateIgnoreMatcher() func(stackName, serviceName, image, remoteDigest string) bool {
+	none := func(string, string, string, string) bool { return false }
+	if app.UpdateIgnores == nil {
+		return none
+	}
+	match, err := app.UpdateIgnores.Matcher()
+	if err != nil {
+		slog.Warn("load update ignores", "err", err)
+		return none
+	}
+	return match
+}
+
+// labelIgnoresUpdate reports whether a dockge.imageupdates.ignore label value
+// skips an update to remoteDigest: "true" skips any, a digest skips only that one.
+func labelIgnoresUpdate(label, remoteDigest string) bool {
+	return label == "true" || (label != "" && label == remoteDigest)
+}
+
+func (app *App) handleGetUpdateIgnores(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	if app.UpdateIgnores == nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Update ignores are not available"})
+		}
+		return
+	}
+	ignores, err := app.UpdateIgnores.List()
+	if err != nil {
+		slog.Error("list update ignores", "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK      bool                  `json:"ok"`
+			Ignores []models.UpdateIgnore `json:"ignores"`
+		}{OK: true, Ignores: ignores})
+	}
+}
+
+// handleAddUpdateIgnore adds or replaces an ignore.
+// Args: {image} or {stackName, serviceName}, plus optional digest and reason.
+// skipCurrent with a service ignores just the release found by the last check.
+func (app *App) handleAddUpdateIgnore(c *ws.Conn, msg *ws.ClientMessage) {
+	user := app.checkAdmin(c, msg)
+	if user == nil {
+		return
+	}
+	ig, ok := app.parseUpdateIgnore(c, msg)
+	if !ok {
+		return
+	}
+	var opts struct {
+		SkipCurrent bool `json:"skipCurrent"`
+	}
+	argObject(parseArgs(msg), 0, &opts)
+	if opts.SkipCurrent && ig.StackName != "" {
+		digest, err := app.ImageUpdates.RemoteDigest(ig.StackName, ig.ServiceName)
+		if err != nil || digest == "" {
+			if msg.ID != nil {
+				ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "No update found for this service"})
+			}
+			return
+		}
+		ig.Digest = digest
+	}
+	ig.CreatedBy = user.Username
+	if err := app.UpdateIgnores.Add(ig); err != nil {
+		slog.Error("add update ignore", "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+	slog.Info("update ignore added", "image", ig.Image, "stack", ig.StackName, "svc", ig.ServiceName, "digest", ig.Digest)
+	go app.recheckIgnoredStacks(ig)
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Saved"})
+	}
+}
+
+// handleRemoveUpdateIgnore removes an ignore.
+// Args: {image} or {stackName, serviceName}.
+func (app *App) handleRemoveUpdateIgnore(c *ws.Conn, msg *ws.ClientMessage) {
+	if app.checkAdmin(c, msg) == nil {
+		return
+	}
+	ig, ok := app.parseUpdateIgnore(c, msg)
+	if !ok {
+		return
+	}
+	if err := app.UpdateIgnores.Remove(ig); err != nil {
+		slog.Error("remove update ignore", "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+	slog.Info("update ignore removed", "image", ig.Image, "stack", ig.StackName, "svc", ig.ServiceName)
+	go app.recheckIgnoredStacks(ig)
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true, Msg: "Removed"})
+	}
+}
+
+// parseUpdateIgnore reads and validates the ignore argument, acking on error.
+func (app *App) parseUpdateIgnore(c *ws.Conn, msg *ws.ClientMessage) (models.UpdateIgnore, bool) {
+	var ig models.UpdateIgnore
+	fail := func(text string) (models.UpdateIgnore, bool) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: text})
+		}
+		return ig, false
+	}
+	if app.UpdateIgnores == nil {
+		return fail("Update ignores are not available")
+	}
+	if !argObject(parseArgs(msg), 0, &ig) {
+		return fail("Invalid ignore")
+	}
+	if _, err := ig.Key(); err != nil {
+		return fail(err.Error())
+	}
+	if ig.StackName != "" {
+		if err := stack.ValidateStackName(ig.StackName); err != nil {
+			return fail(err.Error())
+		}
+	}
+	return ig, true
+}
+
+// recheckIgnoredStacks re-runs the update check for stacks an ignore applies
+// to so the badges and update counts follow the change.
+func (app *App) recheckIgnoredStacks(ig models.UpdateIgnore) {
+	stacks := []string{ig.StackName}
+	if ig.Image != "" {
+		stacks = app.stacksUsingImage(ig.Image)
+	}
+	for _, name := range stacks {
+		app.checkImageUpdatesForStack(name)
+	}
+	app.TriggerUpdatesBroadcast()
+}
+
+// stacksUsingImage returns the checked stacks with a service running image.
+func (app *App) stacksUsingImage(image string) []string {
+	entries, err := app.ImageUpdates.GetAll()
+	if err != nil {
+		slog.Warn("list image updates", "err", err)
+		return nil
+	}
+	seen := make(map[string]bool)
+	var stacks []string
+	for _, e := range entries {
+		if seen[e.StackName] {
+			continue
+		}
+		seen[e.StackName] = true
+		_, imagesByStack := parseComposeDataForStack(app.StacksDir, e.StackName)
+		for _, img := range imagesByStack[e.StackName] {
+			if img == image {
+				stacks = append(stacks, e.StackName)
+				break
+			}
+		}
+	}
+	sort.Strings(stacks)
+	return stacks
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"log/slog"
+	"sort"
+	"sync"
+
+	"github.com/cfilipov/dockge/internal/compose"
+	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// Reasons a stack is left out of an "update all" batch.
+const (
+	updateExcludedUser   = "excluded" // deselected by the user
+	updateExcludedPinned = "pinned"   // every image with an update is pinned by digest
+	updateExcludedLocked = "locked"   // another operation is running on the stack
+)
+
+// maxUpdateAllParallel bounds how many stacks are pulled and recreated at
+// once.
+const maxUpdateAllParallel = 8
+
+// RegisterUpdateAllHandlers registers the batch stack update handlers.
+func RegisterUpdateAllHandlers(app *App) {
+	app.WS.Handle("getUpdateAllPlan", app.handleGetUpdateAllPlan)
+	app.WS.Handle("updateAllStacks", app.handleUpdateAllStacks)
+}
+
+// updatePlanImage is a service whose image has an update available.
+type updatePlanImage struct {
+	Service string                `json:"service"`
+	Image   string                `json:"image"`
+	Pinned  bool                  `json:"pinned"`            // image@digest: pull won't change it
+	Release *models.ReleaseUpdate `json:"release,omitempty"` // newer version tags, if any
+}
+
+// updatePlanStack is one stack of an "update all" plan.
+type updatePlanStack struct {
+	StackName string            `json:"stackName"`
+	Images    []updatePlanImage `json:"images"`
+	Excluded  string            `json:"excluded,omitempty"`
+}
+
+// updateAllResult is the outcome of one stack in a batch.
+type updateAllResult struct {
+	StackName string `json:"stackName"`
+	Status    string `json:"status"` // "running", "updated", "failed", or "skipped"
+	Error     string `json:"error,omitempty"`
+}
+
+// updateAllOptions are the arguments of updateAllStacks.
+type updateAllOptions struct {
+	Exclude     []string `json:"exclude"`
+	Parallelism int      `json:"parallelism"`
+}
+
+// updateAllPlan lists stacks with image updates available, sorted by name,
+// marking the ones that would be skipped.
+func (app *App) updateAllPlan(exclude []string) ([]updatePlanStack, error) {
+	entries, err := app.ImageUpdates.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	services := make(map[string][]string)
+	for _, e := range entries {
+		if e.HasUpdate {
+			services[e.StackName] = append(services[e.StackName], e.ServiceName)
+		}
+	}
+	excluded := make(map[string]bool, len(exclude))
+	for _, name := range exclude {
+		excluded[name] = true
+	}
+
+	plan := []updatePlanStack{}
+	for stackName, svcs := range services {
+		_, imagesByStack := parseComposeDataForStack(app.StacksDir, stackName)
+		images := imagesByStack[stackName]
+		releases, _ := app.ImageUpdates.ReleasesForStack(stackName)
+		sort.Strings(svcs)
+
+		ps := updatePlanStack{StackName: stackName, Images: []updatePlanImage{}}
+		allPinned := true
+		for _, svc := range svcs {
+			_, _, digest := compose.SplitImageRef(images[svc])
+			pinned := digest != ""
+			allPinned = allPinned && pinned
+			img := updatePlanImage{Service: svc, Image: images[svc], Pinned: pinned}
+			if r, ok := releases[svc]; ok {
+				img.Release = &r
+			}
+			ps.Images = append(ps.Images, img)
+		}
+		switch {
+		case excluded[stackName]:
+			ps.Excluded = updateExcludedUser
+		case allPinned:
+			ps.Excluded = updateExcludedPinned
+		case app.StackLocks.Locked(stackName):
+			ps.Excluded = updateExcludedLocked
+		}
+		plan = append(plan, ps)
+	}
+	sort.Slice(plan, func(i, j int) bool { return plan[i].StackName < plan[j].StackName })
+	return plan, nil
+}
+
+// handleGetUpdateAllPlan previews which stacks and images an "update all"
+// would touch. Args: options (only exclude is used).
+func (app *App) handleGetUpdateAllPlan(c *ws.Conn, msg *ws.ClientMessage) {
+	if checkLogin(c, msg) == 0 {
+		return
+	}
+	var opts updateAllOptions
+	argObject(parseArgs(msg), 0, &opts)
+
+	plan, err := app.updateAllPlan(opts.Exclude)
+	if err != nil {
+		slog.Error("update all plan", "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK      bool              `json:"ok"`
+			Plan    []updatePlanStack `json:"plan"`
+			Running bool              `json:"running"`
+		}{OK: true, Plan: plan, Running: app.updateAllRunning.Load()})
+	}
+}
+
+// handleUpdateAllStacks updates every stack in the plan that isn't
+// excluded, a few at a time. Progress is pushed as "updateAllProgress" per
+// stack and "updateAllDone" with the summary. Admin only.
+// Args: options {exclude, parallelism}.
+func (app *App) handleUpdateAllStacks(c *ws.Conn, msg *ws.ClientMessage) {
+	if app.checkAdmin(c, msg) == nil {
+		return
+	}
+	var opts updateAllOptions
+	argObject(parseArgs(msg), 0, &opts)
+	if opts.Parallelism <= 0 {
+		opts.Parallelism = 2
+	}
+	opts.Parallelism = min(opts.Parallelism, maxUpdateAllParallel)
+
+	plan, err := app.updateAllPlan(opts.Exclude)
+	if err != nil {
+		slog.Error("update all plan", "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	if !app.updateAllRunning.CompareAndSwap(false, true) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "An update of all stacks is already running"})
+		}
+		return
+	}
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK   bool              `json:"ok"`
+			Plan []updatePlanStack `json:"plan"`
+		}{OK: true, Plan: plan})
+	}
+
+	go func() {
+		defer app.updateAllRunning.Store(false)
+		results := app.runUpdateAll(plan, opts.Parallelism)
+		ws.BroadcastAuthenticated(app.WS, "updateAllDone", struct {
+			Results []updateAllResult `json:"results"`
+		}{results})
+	}()
+}
+
+// runUpdateAll updates the plan's stacks with bounded parallelism and
+// returns one result per stack, in plan order. A stack that became busy
+// since planning is skipped rather than waited for.
+func (app *App) runUpdateAll(plan []updatePlanStack, parallelism int) []updateAllResult {
+	results := make([]updateAllResult, len(plan))
+	report := func(i int, r updateAllResult) {
+		results[i] = r
+		ws.BroadcastAuthenticated(app.WS, "updateAllProgress", r)
+	}
+
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i, ps := range plan {
+		if ps.Excluded != "" {
+			report(i, updateAllResult{StackName: ps.StackName, Status: "skipped", Error: ps.Excluded})
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			if !app.StackLocks.TryLock(ps.StackName) {
+				report(i, updateAllResult{StackName: ps.StackName, Status: "skipped", Error: updateExcludedLocked})
+				return
+			}
+			defer app.StackLocks.Unlock(ps.StackName)
+
+			report(i, updateAllResult{StackName: ps.StackName, Status: "running"})
+			if err := app.runStackUpdate(ps.StackName); err != nil {
+				report(i, updateAllResult{StackName: ps.StackName, Status: "failed", Error: err.Error()})
+				return
+			}
+			report(i, updateAllResult{StackName: ps.StackName, Status: "updated"})
+		}()
+	}
+	wg.Wait()
+
+	var updated, failed int
+	for _, r := range results {
+		switch r.Status {
+		case "updated":
+			updated++
+		case "failed":
+			failed++
+		}
+	}
+	slog.Info("update all stacks", "stacks", len(plan), "updated", updated, "failed", failed)
+	return results
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"log/slog"
+
+	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// RegisterUserHandlers registers admin-only user management handlers.
+func RegisterUserHandlers(app *App) {
+	app.WS.Handle("getUserList", app.handleGetUserList)
+	app.WS.Handle("addUser", app.handleAddUser)
+	app.WS.Handle("setUserRole", app.handleSetUserRole)
+}
+
+// currentUser returns the user behind an authenticated connection, or nil.
+// With --no-auth there is no user record; a synthetic admin is returned.
+func (app *App) currentUser(c *ws.Conn) *models.User {
+	if app.NoAuth {
+		return &models.User{ID: c.UserID(), Username: "admin", Role: models.RoleAdmin, Active: true}
+	}
+	uid := c.UserID()
+	if uid == 0 {
+		return nil
+	}
+	if uid == agentSessionUID {
+		return app.agentSessionUser(c)
+	}
+	user, err := app.Users.FindByID(uid)
+	if err != nil {
+		slog.Error("current user lookup", "err", err, "uid", uid)
+		return nil
+	}
+	return user
+}
+
+// checkAdmin verifies that the connection is logged in as an admin.
+// Returns the user or sends an error ack and returns nil.
+func (app *App) checkAdmin(c *ws.Conn, msg *ws.ClientMessage) *models.User {
+	if checkLogin(c, msg) == 0 {
+		return nil
+	}
+	user := app.currentUser(c)
+	if user == nil || !user.IsAdmin() {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Permission denied: admin role required"})
+		}
+		return nil
+	}
+	return user
+}
+
+// userInfo is the client-facing view of a user (no password hash).
+type userInfo struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	Active   bool   `json:"active"`
+}
+
+func (app *App) handleGetUserList(c *ws.Conn, msg *ws.ClientMessage) {
+	if app.checkAdmin(c, msg) == nil {
+		return
+	}
+	users, err := app.Users.List()
+	if err != nil {
+		slog.Error("list users", "err", err)
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Internal error"})
+		}
+		return
+	}
+
+	list := make([]userInfo, 0, len(users))
+	for _, u := range users {
+		list = append(list, userInfo{ID: u.ID, Username: u.Username, Role: u.EffectiveRole(), Active: u.Active})
+	}
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK    bool       `json:"ok"`
+			Users []userInfo `json:"users"`
+		}{
+			OK:    true,
+			Users: list,
+		})
+	}
+}
+
+func (app *App) handleAddUser(c *ws.Conn, msg *ws.ClientMessage) {
+	admin := app.checkAdmin(c, msg)
+	if admin == nil || !app.requireSudo(c, msg) {
+		return
+	}
+	args := parseArgs(msg)
+	var data struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Role     string `json:"role"`
+	}
+	if !argObject(args, 0, &data) || data.Username == "" {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Username and password required"})
+		}
+		return
+	}
+	if len(data.Password) < 6 {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Password is too weak. It should be at least 6 characters."})
+		}
+		return
+	}
+	if data.Role == "" {
+		data.Role = models.RoleOperator
+	}
+	if !models.ValidRole(data.Role) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Invalid role"})
+		}
+		return
+	}
+
+	user, err := app.Users.CreateWithRole(data.Username, data.Password, data.Role)
+	if err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	slog.Info("user added", "username", user.Username, "role", user.Role, "by", admin.Username)
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK   bool     `json:"ok"`
+			User userInfo `json:"user"`
+		}{
+			OK:   true,
+			User: userInfo{ID: user.ID, Username: user.Username, Role: user.Role, Active: user.Active},
+		})
+	}
+}
+
+func (app *App) handleSetUserRole(c *ws.Conn, msg *ws.ClientMessage) {
+	admin := app.checkAdmin(c, msg)
+	if admin == nil || !app.requireSudo(c, msg) {
+		return
+	}
+	args := parseArgs(msg)
+	userID := argInt(args, 0)
+	role := argString(args, 1)
+	if userID == 0 || !models.ValidRole(role) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "Invalid arguments"})
+		}
+		return
+	}
+	// Demoting yourself could leave no admin to undo it
+	if userID == admin.ID && role != models.RoleAdmin {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: "You cannot remove your own admin role"})
+		}
+		return
+	}
+
+	if err := app.Users.SetRole(userID, role); err != nil {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: err.Error()})
+		}
+		return
+	}
+	slog.Info("user role changed", "userID", userID, "role", role, "by", admin.Username)
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, ws.OkResponse{OK: true})
+	}
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/volbrowse"
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// volumeFileLinkPath prefixes the URL of a volume file download;
+// ServeVolumeFile is mounted at volumeFileLinkPath + "{token}".
+const volumeFileLinkPath = "/api/volume-files/"
+
+const (
+	// volumeBrowseTimeout bounds listing a directory, which may start a
+	// helper container.
+	volumeBrowseTimeout = time.Minute
+
+	// volumeDownloadTimeout bounds streaming a file out of a volume.
+	volumeDownloadTimeout = 30 * time.Minute
+
+	// maxVolumeEntries bounds the entries of a directory listing.
+	maxVolumeEntries = 2000
+)
+
+// volumeFileRef is a file in a volume, as a download link points to it.
+type volumeFileRef struct {
+	Volume string
+	Path   string // as volbrowse.CleanPath returns it
+}
+
+// RegisterVolumeBrowserHandlers registers the volume browser handlers. File
+// contents go over HTTP, see ServeVolumeFile.
+func RegisterVolumeBrowserHandlers(app *App) {
+	app.WS.Handle("browseVolume", app.handleBrowseVolume)
+	app.WS.Handle("downloadVolumeFile", app.handleDownloadVolumeFile)
+}
+
+// volumeBrowser returns a browser of a named volume.
+func (app *App) volumeBrowser(ctx context.Context, volume string) (volbrowse.Browser, error) {
+	if volume == "" {
+		return nil, errors.New("Volume name required")
+	}
+	detail, err := app.Docker.VolumeInspect(ctx, volume)
+	if err != nil {
+		return nil, err
+	}
+	return volbrowse.New(detail.Name, detail.Mountpoint, volumeHelperImage), nil
+}
+
+// handleBrowseVolume lists a directory of a named volume. Admin only, since
+// volumes hold the data, and often the secrets, of every stack.
+// Args: volume name, path ("" or "/" for the root).
+func (app *App) handleBrowseVolume(c *ws.Conn, msg *ws.ClientMessage) {
+	if app.checkAdmin(c, msg) == nil {
+		return
+	}
+	args := parseArgs(msg)
+	volume := argString(args, 0)
+	fail := func(text string) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: text})
+		}
+	}
+	dir, err := volbrowse.CleanPath(argString(args, 1))
+	if err != nil {
+		fail(err.Error())
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), volumeBrowseTimeout)
+		defer cancel()
+		b, err := app.volumeBrowser(ctx, volume)
+		if err != nil {
+			fail(err.Error())
+			return
+		}
+		entries, truncated, err := b.List(ctx, dir, maxVolumeEntries)
+		if err != nil {
+			slog.Warn("browse volume", "err", err, "volume", volume, "path", dir)
+			fail(err.Error())
+			return
+		}
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, struct {
+				OK        bool              `json:"ok"`
+				Path      string            `json:"path"`
+				Entries   []volbrowse.Entry `json:"entries"`
+				Truncated bool              `json:"truncated"`
+				Direct    bool              `json:"direct"` // read from the mountpoint, not a helper container
+			}{OK: true, Path: path.Join("/", dir), Entries: entries, Truncated: truncated, Direct: b.Direct()})
+		}
+	}()
+}
+
+// handleDownloadVolumeFile returns a one-time link to download a regular
+// file of a named volume, served by ServeVolumeFile. Admin only and
+// requires sudo, like exporting a stack's volumes.
+// Args: volume name, path.
+func (app *App) handleDownloadVolumeFile(c *ws.Conn, msg *ws.ClientMessage) {
+	user := app.checkAdmin(c, msg)
+	if user == nil || !app.requireSudo(c, msg) {
+		return
+	}
+	args := parseArgs(msg)
+	volume := argString(args, 0)
+	fail := func(text string) {
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, ws.ErrorResponse{OK: false, Msg: text})
+		}
+	}
+	name, err := volbrowse.CleanPath(argString(args, 1))
+	if err != nil {
+		fail(err.Error())
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), volumeBrowseTimeout)
+		defer cancel()
+		b, err := app.volumeBrowser(ctx, volume)
+		if err != nil {
+			fail(err.Error())
+			return
+		}
+		e, err := b.Stat(ctx, name)
+		if err != nil {
+			fail(err.Error())
+			return
+		}
+		if e.Type != volbrowse.TypeFile {
+			fail("Only regular files can be downloaded")
+			return
+		}
+		token := app.volumeFileLinks.issue(&backupLink{volumeFile: &volumeFileRef{Volume: volume, Path: name}, user: user.Username})
+		if msg.ID != nil {
+			ws.SendAck(c, *msg.ID, struct {
+				OK   bool   `json:"ok"`
+				URL  string `json:"url"`
+				Size int64  `json:"size"`
+			}{OK: true, URL: volumeFileLinkPath + token, Size: e.Size})
+		}
+	}()
+}
+
+// ServeVolumeFile streams the file of a link issued by downloadVolumeFile.
+// Each link works once; the token is the credential, as the WS handler
+// checked the user.
+func (app *App) ServeVolumeFile(w http.ResponseWriter, r *http.Request) {
+	l := app.volumeFileLinks.take(r.PathValue("token"))
+	if l == nil || l.volumeFile == nil {
+		http.Error(w, "link not found or expired", http.StatusNotFound)
+		return
+	}
+	ref := l.volumeFile
+	ctx, cancel := context.WithTimeout(r.Context(), volumeDownloadTimeout)
+	defer cancel()
+
+	b, err := app.volumeBrowser(ctx, ref.Volume)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	f, err := b.Open(ctx, ref.Path)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, volbrowse.ErrNotFile) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", path.Base(ref.Path)))
+	n, err := io.Copy(w, f)
+	if err != nil {
+		slog.Warn("volume file download", "err", err, "volume", ref.Volume, "path", ref.Path, "by", l.user)
+		return
+	}
+	slog.Info("volume file downloaded", "volume", ref.Volume, "path", ref.Path, "bytes", n, "by", l.user)
+}
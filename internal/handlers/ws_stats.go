@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"github.com/cfilipov/dockge/internal/ws"
+)
+
+// wsConnStats is a WebSocket connection with the user it's logged in as
+// and the streams it subscribed to.
+type wsConnStats struct {
+	ws.ConnInfo
+	Username      string   `json:"username,omitempty"`
+	Subscriptions []string `json:"subscriptions"`
+}
+
+// connSubscriptions lists a connection's stats and process list streams,
+// as "stats:<container>" and "top:<container>".
+func (app *App) connSubscriptions(connID string) []string {
+	subs := []string{}
+	app.statsSubsMu.Lock()
+	if sub, ok := app.statsSubs[connID]; ok {
+		subs = append(subs, "stats:"+sub.container)
+	}
+	app.statsSubsMu.Unlock()
+	app.topSubsMu.Lock()
+	if sub, ok := app.topSubs[connID]; ok {
+		subs = append(subs, "top:"+sub.container)
+	}
+	app.topSubsMu.Unlock()
+	return subs
+}
+
+// handleGetWSStats reports every WebSocket connection (user, subscriptions,
+// terminals, queued messages, last activity) with handler call counts and
+// latencies and broadcast fan-out timings, for triaging a slow or stuck
+// server without a profiler. Admin only.
+func (app *App) handleGetWSStats(c *ws.Conn, msg *ws.ClientMessage) {
+	if app.checkAdmin(c, msg) == nil {
+		return
+	}
+
+	usernames := make(map[int]string)
+	conns := []wsConnStats{}
+	for _, info := range app.WS.ConnInfos() {
+		cs := wsConnStats{ConnInfo: info, Subscriptions: app.connSubscriptions(info.ID)}
+		if info.UserID != 0 {
+			name, ok := usernames[info.UserID]
+			if !ok {
+				if u, err := app.Users.FindByID(info.UserID); err == nil && u != nil {
+					name = u.Username
+				}
+				usernames[info.UserID] = name
+			}
+			cs.Username = name
+		}
+		conns = append(conns, cs)
+	}
+
+	if msg.ID != nil {
+		ws.SendAck(c, *msg.ID, struct {
+			OK          bool          `json:"ok"`
+			Self        string        `json:"self"`
+			Connections []wsConnStats `json:"connections"`
+			ws.ServerStats
+		}{
+			OK:          true,
+			Self:        c.ID(),
+			Connections: conns,
+			ServerStats: app.WS.Stats(),
+		})
+	}
+}
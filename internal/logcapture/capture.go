@@ -0,0 +1,357 @@
+// Package logcapture follows the logs of the containers of selected stacks
+// and writes them to rotated files under the data dir, so they outlive the
+// containers: docker drops a container's log when it's recreated, the
+// files here are kept per container name and continue across recreations.
+package logcapture
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/docker"
+)
+
+// syncInterval is how often the followed containers are reconciled with
+// the running containers of the enabled stacks.
+const syncInterval = 10 * time.Second
+
+// tailReadSize is how much of the end of a log file is read to find the
+// last captured line.
+const tailReadSize = 64 << 10
+
+// Source lists containers and follows their logs. docker.Client
+// satisfies it.
+type Source interface {
+	ContainerList(ctx context.Context, all bool, projectFilter string) ([]docker.Container, error)
+	ContainerLogsWithOptions(ctx context.Context, containerID string, opts docker.LogOptions) (io.ReadCloser, bool, error)
+}
+
+// Segment is a log file of a container: the one being written to, or a
+// rotated one.
+type Segment struct {
+	Name      string `json:"name"` // file name, the argument of Path
+	Container string `json:"container"`
+	Size      int64  `json:"size"`
+	ModTime   int64  `json:"modTime"` // Unix seconds
+	Current   bool   `json:"current"` // still being written to
+}
+
+// follower is a running log follow of one container.
+type follower struct {
+	stack   string
+	service string
+	cancel  context.CancelFunc
+}
+
+// Selection maps the stacks to capture to their services to capture; no
+// services captures all of a stack's.
+type Selection map[string][]string
+
+func (s Selection) has(stackName, service string) bool {
+	services, ok := s[stackName]
+	if !ok {
+		return false
+	}
+	return len(services) == 0 || slices.Contains(services, service)
+}
+
+// Capturer follows the running containers of the stacks enabled returns.
+type Capturer struct {
+	src     Source
+	dir     string
+	cfg     Config
+	enabled func() Selection
+
+	mu        sync.Mutex
+	followers map[string]*follower // by container ID
+	writers   map[string]*Writer   // by log file path
+	last      map[string]time.Time // time of the last captured line, by log file path
+
+	kick chan struct{}
+}
+
+// New creates a capturer writing under dir/{stack}/{container}.log. enabled
+// returns the stacks and services to capture; it's asked on every sync. It
+// captures once Start is called.
+func New(src Source, dir string, cfg Config, enabled func() Selection) *Capturer {
+	return &Capturer{
+		src:       src,
+		dir:       dir,
+		cfg:       cfg,
+		enabled:   enabled,
+		followers: make(map[string]*follower),
+		writers:   make(map[string]*Writer),
+		last:      make(map[string]time.Time),
+		kick:      make(chan struct{}, 1),
+	}
+}
+
+// Start syncs every syncInterval, and on Sync, until ctx is cancelled.
+func (c *Capturer) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(syncInterval)
+		defer ticker.Stop()
+		for {
+			c.sync(ctx)
+			select {
+			case <-ctx.Done():
+				c.stopAll()
+				return
+			case <-ticker.C:
+			case <-c.kick:
+			}
+		}
+	}()
+}
+
+// Sync asks for the followed containers to be reconciled now, after a
+// stack was enabled or disabled or a container started.
+func (c *Capturer) Sync() {
+	select {
+	case c.kick <- struct{}{}:
+	default:
+	}
+}
+
+// sync starts following the running containers of enabled services and
+// stops following the containers of services no longer enabled. Followers
+// of containers that stop end by themselves.
+func (c *Capturer) sync(ctx context.Context) {
+	want := c.enabled()
+
+	c.mu.Lock()
+	for id, f := range c.followers {
+		if !want.has(f.stack, f.service) {
+			f.cancel()
+			delete(c.followers, id)
+		}
+	}
+	c.mu.Unlock()
+
+	for stackName := range want {
+		listCtx, cancel := context.WithTimeout(ctx, syncInterval)
+		list, err := c.src.ContainerList(listCtx, false, stackName)
+		cancel()
+		if err != nil {
+			slog.Warn("logcapture: list containers", "err", err, "stack", stackName)
+			continue
+		}
+		for _, ct := range list {
+			if ct.State != "running" || !want.has(stackName, ct.Service) {
+				continue
+			}
+			c.mu.Lock()
+			if _, ok := c.followers[ct.ID]; !ok {
+				fctx, cancel := context.WithCancel(ctx)
+				c.followers[ct.ID] = &follower{stack: stackName, service: ct.Service, cancel: cancel}
+				go c.follow(fctx, stackName, ct)
+			}
+			c.mu.Unlock()
+		}
+	}
+}
+
+// follow appends a container's log to its file until the container stops
+// or ctx is cancelled. It resumes after the last line already captured, so
+// nothing is written twice.
+func (c *Capturer) follow(ctx context.Context, stackName string, ct docker.Container) {
+	defer func() {
+		c.mu.Lock()
+		if f := c.followers[ct.ID]; f != nil {
+			f.cancel()
+			delete(c.followers, ct.ID)
+		}
+		c.mu.Unlock()
+	}()
+
+	path := filepath.Join(c.dir, stackName, ct.Name+".log")
+	w, last, err := c.writer(path)
+	if err != nil {
+		slog.Warn("logcapture: open log file", "err", err, "container", ct.Name)
+		return
+	}
+
+	stream, _, err := c.src.ContainerLogsWithOptions(ctx, ct.ID, docker.LogOptions{
+		Since:      last,
+		Follow:     true,
+		Timestamps: true,
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Warn("logcapture: follow logs", "err", err, "container", ct.Name)
+		}
+		return
+	}
+	defer stream.Close()
+
+	sc := bufio.NewScanner(stream)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	var buf []byte
+	for sc.Scan() {
+		line := sc.Bytes()
+		ts, ok := lineTime(line)
+		// since is inclusive: skip what was captured before
+		if ok && !last.IsZero() && !ts.After(last) {
+			continue
+		}
+		buf = append(append(buf[:0], line...), '\n')
+		if _, err := w.Write(buf); err != nil {
+			slog.Warn("logcapture: write log", "err", err, "file", path)
+			return
+		}
+		if ok {
+			last = ts
+			c.mu.Lock()
+			c.last[path] = ts
+			c.mu.Unlock()
+		}
+	}
+}
+
+// writer returns the open writer of a log file and the time of the last
+// line captured in it, opening it and reading the time from its end the
+// first time.
+func (c *Capturer) writer(path string) (*Writer, time.Time, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if w := c.writers[path]; w != nil {
+		return w, c.last[path], nil
+	}
+	w, err := OpenWriter(path, c.cfg)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	c.writers[path] = w
+	c.last[path] = lastLineTime(path)
+	return w, c.last[path], nil
+}
+
+// stopAll cancels every follower and closes the log files.
+func (c *Capturer) stopAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, f := range c.followers {
+		f.cancel()
+		delete(c.followers, id)
+	}
+	for path, w := range c.writers {
+		w.Close()
+		delete(c.writers, path)
+	}
+}
+
+// lineTime parses the timestamp docker prefixes a line with.
+func lineTime(line []byte) (time.Time, bool) {
+	i := bytes.IndexByte(line, ' ')
+	if i <= 0 {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, string(line[:i]))
+	return t, err == nil
+}
+
+// lastLineTime returns the time of the last timestamped line of a log
+// file, or the zero time if there is none.
+func lastLineTime(path string) time.Time {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return time.Time{}
+	}
+	off := max(info.Size()-tailReadSize, 0)
+	buf := make([]byte, info.Size()-off)
+	if _, err := f.ReadAt(buf, off); err != nil && !errors.Is(err, io.EOF) {
+		return time.Time{}
+	}
+	lines := bytes.Split(bytes.TrimRight(buf, "\n"), []byte{'\n'})
+	for i := len(lines) - 1; i >= 0; i-- {
+		if t, ok := lineTime(lines[i]); ok {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Capturing reports how many containers of a stack are being followed.
+func (c *Capturer) Capturing(stackName string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, f := range c.followers {
+		if f.stack == stackName {
+			n++
+		}
+	}
+	return n
+}
+
+// Segments lists the log files of a stack, newest first per container.
+func (c *Capturer) Segments(stackName string) ([]Segment, error) {
+	entries, err := os.ReadDir(filepath.Join(c.dir, stackName))
+	if errors.Is(err, os.ErrNotExist) {
+		return []Segment{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list log files: %w", err)
+	}
+	segments := []Segment{}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.HasSuffix(name, ".tmp") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		s := Segment{Name: name, Size: info.Size(), ModTime: info.ModTime().Unix()}
+		if container, _, ok := strings.Cut(name, segmentSep); ok {
+			s.Container = container
+		} else if container, ok := strings.CutSuffix(name, ".log"); ok {
+			s.Container = container
+			s.Current = true
+		} else {
+			continue
+		}
+		segments = append(segments, s)
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		a, b := segments[i], segments[j]
+		if a.Container != b.Container {
+			return a.Container < b.Container
+		}
+		if a.Current != b.Current {
+			return a.Current
+		}
+		return a.Name > b.Name
+	})
+	return segments, nil
+}
+
+// Path returns the path of a log file of a stack listed by Segments.
+func (c *Capturer) Path(stackName, name string) (string, error) {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") || !strings.Contains(name, ".log") {
+		return "", errors.New("invalid log file name")
+	}
+	path := filepath.Join(c.dir, stackName, name)
+	if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+		return "", errors.New("log file not found")
+	}
+	return path, nil
+}
@@ -0,0 +1,218 @@
+package logcapture
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/docker"
+)
+
+// fakeSource serves fixed containers and logs, filtered by Since.
+type fakeSource struct {
+	mu         sync.Mutex
+	containers []docker.Container
+	logs       map[string][]string // by container ID, timestamped lines
+	since      []time.Time         // Since of each follow
+}
+
+func (f *fakeSource) ContainerList(_ context.Context, _ bool, project string) ([]docker.Container, error) {
+	var list []docker.Container
+	for _, c := range f.containers {
+		if c.Project == project {
+			list = append(list, c)
+		}
+	}
+	return list, nil
+}
+
+func (f *fakeSource) ContainerLogsWithOptions(_ context.Context, id string, opts docker.LogOptions) (io.ReadCloser, bool, error) {
+	f.mu.Lock()
+	f.since = append(f.since, opts.Since)
+	f.mu.Unlock()
+	var b strings.Builder
+	for _, l := range f.logs[id] {
+		if ts, ok := lineTime([]byte(l)); ok && ts.Before(opts.Since) {
+			continue
+		}
+		b.WriteString(l + "\n")
+	}
+	return io.NopCloser(strings.NewReader(b.String())), false, nil
+}
+
+// waitFollowers waits for the followers started by a sync to end, which
+// they do at the end of the fake logs.
+func waitFollowers(t *testing.T, c *Capturer) {
+	t.Helper()
+	for range 100 {
+		c.mu.Lock()
+		n := len(c.followers)
+		c.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("followers didn't finish")
+}
+
+func TestCapturerResumesAcrossRecreation(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	src := &fakeSource{
+		containers: []docker.Container{
+			{ID: "a1", Name: "web-app-1", Project: "web", Service: "app", State: "running"},
+			{ID: "x1", Name: "web-job-1", Project: "web", Service: "job", State: "exited"},
+			{ID: "o1", Name: "other-app-1", Project: "other", Service: "app", State: "running"},
+		},
+		logs: map[string][]string{
+			"a1": {
+				"2026-01-02T03:04:05.000000001Z first",
+				"2026-01-02T03:04:06.000000001Z second",
+			},
+			"o1": {"2026-01-02T03:04:05Z not captured"},
+		},
+	}
+	c := New(src, dir, DefaultConfig, func() Selection { return Selection{"web": nil} })
+	c.sync(context.Background())
+	waitFollowers(t, c)
+
+	// Recreated: new ID, same name. Docker's since is inclusive, so the
+	// last captured line comes back and must be skipped.
+	src.containers[0].ID = "a2"
+	src.logs["a2"] = append(src.logs["a1"], "2026-01-02T03:04:07Z third")
+	c.sync(context.Background())
+	waitFollowers(t, c)
+	c.stopAll()
+
+	data, err := os.ReadFile(filepath.Join(dir, "web", "web-app-1.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "2026-01-02T03:04:05.000000001Z first\n2026-01-02T03:04:06.000000001Z second\n2026-01-02T03:04:07Z third\n"
+	if string(data) != want {
+		t.Errorf("log file = %q, want %q", data, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "other")); !os.IsNotExist(err) {
+		t.Error("captured a stack that isn't enabled")
+	}
+
+	// A restart of Dockge resumes from the end of the file.
+	c2 := New(src, dir, DefaultConfig, func() Selection { return Selection{"web": nil} })
+	c2.sync(context.Background())
+	waitFollowers(t, c2)
+	c2.stopAll()
+	src.mu.Lock()
+	since := src.since[len(src.since)-1]
+	src.mu.Unlock()
+	if want := time.Date(2026, 1, 2, 3, 4, 7, 0, time.UTC); !since.Equal(want) {
+		t.Errorf("resumed since %v, want %v", since, want)
+	}
+	data, _ = os.ReadFile(filepath.Join(dir, "web", "web-app-1.log"))
+	if string(data) != want {
+		t.Errorf("resume duplicated lines: %q", data)
+	}
+}
+
+func TestCapturerSegments(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	stackDir := filepath.Join(dir, "web")
+	os.MkdirAll(stackDir, 0o755)
+	for _, name := range []string{
+		"web-app-1.log",
+		"web-app-1@20260101T000000.000Z.log.gz",
+		"web-app-1@20260102T000000.000Z.log.gz",
+		"web-app-1@20260103T000000.000Z.log.gz.tmp",
+		"web-db-1.log",
+	} {
+		os.WriteFile(filepath.Join(stackDir, name), []byte("x"), 0o644)
+	}
+	c := New(&fakeSource{}, dir, DefaultConfig, func() Selection { return nil })
+
+	segs, err := c.Segments("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range segs {
+		names = append(names, s.Name)
+	}
+	want := "web-app-1.log web-app-1@20260102T000000.000Z.log.gz web-app-1@20260101T000000.000Z.log.gz web-db-1.log"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("segments = %s, want %s", got, want)
+	}
+	if !segs[0].Current || segs[1].Current || segs[1].Container != "web-app-1" {
+		t.Errorf("segment fields = %+v", segs[:2])
+	}
+
+	if segs, err := c.Segments("none"); err != nil || len(segs) != 0 {
+		t.Errorf("stack without logs: %v, %v", segs, err)
+	}
+	if _, err := c.Path("web", "../web/web-app-1.log"); err == nil {
+		t.Error("Path accepted a path")
+	}
+	if _, err := c.Path("web", "missing.log"); err == nil {
+		t.Error("Path accepted a missing file")
+	}
+	if _, err := c.Path("web", "web-db-1.log"); err != nil {
+		t.Errorf("Path: %v", err)
+	}
+}
+
+func TestCapturerQuery(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	stackDir := filepath.Join(dir, "web")
+	os.MkdirAll(stackDir, 0o755)
+	writeGzip := func(name, data string) {
+		plain := filepath.Join(stackDir, strings.TrimSuffix(name, ".gz"))
+		os.WriteFile(plain, []byte(data), 0o644)
+		if err := compress(plain); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeGzip("web-app-1@20260101T010000.000Z.log.gz", "2026-01-01T00:10:00Z a1\n2026-01-01T00:50:00Z a2\n")
+	writeGzip("web-app-1@20260101T020000.000Z.log.gz", "2026-01-01T01:10:00Z a3\n")
+	os.WriteFile(filepath.Join(stackDir, "web-app-1.log"), []byte("2026-01-01T02:10:00Z a4\nno timestamp\n"), 0o644)
+	os.WriteFile(filepath.Join(stackDir, "web-db-1.log"), []byte("2026-01-01T00:30:00Z d1\n2026-01-01T01:30:00Z d2\n"), 0o644)
+	c := New(&fakeSource{}, dir, DefaultConfig, func() Selection { return nil })
+
+	texts := func(lines []Line) string {
+		var s []string
+		for _, l := range lines {
+			s = append(s, l.Text)
+		}
+		return strings.Join(s, " ")
+	}
+	at := func(h, m int) time.Time { return time.Date(2026, 1, 1, h, m, 0, 0, time.UTC) }
+
+	tests := []struct {
+		q         Query
+		want      string
+		truncated bool
+	}{
+		{Query{}, "a1 d1 a2 a3 d2 a4", false},
+		{Query{Container: "web-app-1"}, "a1 a2 a3 a4", false},
+		{Query{Since: at(1, 0), Until: at(2, 0)}, "a3 d2", false},
+		{Query{Since: at(0, 40)}, "a2 a3 d2 a4", false},
+		{Query{Limit: 2}, "d2 a4", true},
+	}
+	for _, tt := range tests {
+		lines, truncated, err := c.Query("web", tt.q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := texts(lines); got != tt.want || truncated != tt.truncated {
+			t.Errorf("Query(%+v) = %q, %v, want %q, %v", tt.q, got, truncated, tt.want, tt.truncated)
+		}
+	}
+	if lines, _, _ := c.Query("web", Query{Container: "web-db-1", Limit: 1}); len(lines) != 1 || lines[0].Time != "2026-01-01T01:30:00Z" || lines[0].Container != "web-db-1" {
+		t.Errorf("line = %+v", lines)
+	}
+}
@@ -0,0 +1,162 @@
+package logcapture
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Line is a captured log line.
+type Line struct {
+	Container string `json:"container"`
+	Time      string `json:"time,omitempty"` // RFC3339, as docker stamped it
+	Text      string `json:"text"`
+
+	t time.Time
+}
+
+// Query selects captured lines of a stack.
+type Query struct {
+	Container string    // "" for all containers of the stack
+	Since     time.Time // zero for no lower bound
+	Until     time.Time // zero for no upper bound
+	Limit     int       // max lines, newest kept
+}
+
+// Query returns the captured lines of a stack within the query's time
+// range, oldest first across containers and their rotated segments, and
+// whether older lines were dropped to stay within the limit.
+func (c *Capturer) Query(stackName string, q Query) ([]Line, bool, error) {
+	segments, err := c.Segments(stackName)
+	if err != nil {
+		return nil, false, err
+	}
+	files := map[string][]string{} // file names by container
+	for _, s := range segments {
+		if q.Container != "" && s.Container != q.Container {
+			continue
+		}
+		// A segment is rotated after its last line: one rotated before
+		// since has nothing in the range.
+		if !s.Current && !q.Since.IsZero() {
+			if rotated, ok := segmentTime(s.Name); ok && rotated.Before(q.Since) {
+				continue
+			}
+		}
+		files[s.Container] = append(files[s.Container], s.Name)
+	}
+
+	var lines []Line
+	truncated := false
+	for container, names := range files {
+		// Rotated segments oldest first, then the current file
+		sort.Slice(names, func(i, j int) bool {
+			ci, cj := !strings.Contains(names[i], segmentSep), !strings.Contains(names[j], segmentSep)
+			if ci != cj {
+				return cj
+			}
+			return names[i] < names[j]
+		})
+		got, dropped, err := c.queryContainer(stackName, container, names, q)
+		if err != nil {
+			return nil, false, err
+		}
+		lines = append(lines, got...)
+		truncated = truncated || dropped
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].t.Before(lines[j].t) })
+	if q.Limit > 0 && len(lines) > q.Limit {
+		lines = lines[len(lines)-q.Limit:]
+		truncated = true
+	}
+	return lines, truncated, nil
+}
+
+// queryContainer reads the files of one container in order, keeping the
+// last q.Limit lines in range.
+func (c *Capturer) queryContainer(stackName, container string, names []string, q Query) ([]Line, bool, error) {
+	var lines []Line
+	truncated := false
+	for _, name := range names {
+		past, err := scanLogFile(filepath.Join(c.dir, stackName, name), func(t time.Time, ts, text string) bool {
+			if !q.Since.IsZero() && t.Before(q.Since) {
+				return true
+			}
+			if !q.Until.IsZero() && t.After(q.Until) {
+				return false
+			}
+			lines = append(lines, Line{Container: container, Time: ts, Text: text, t: t})
+			if q.Limit > 0 && len(lines) > q.Limit {
+				lines = lines[1:]
+				truncated = true
+			}
+			return true
+		})
+		if err != nil {
+			return nil, false, err
+		}
+		if past {
+			break
+		}
+	}
+	return lines, truncated, nil
+}
+
+// scanLogFile calls fn with each timestamped line of a log file, gzipped
+// or not, until fn returns false, which scanLogFile reports as true.
+func scanLogFile(path string, fn func(t time.Time, ts, text string) bool) (bool, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return false, nil // pruned meanwhile
+	}
+	if err != nil {
+		return false, fmt.Errorf("open log file: %w", err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return false, fmt.Errorf("read %s: %w", filepath.Base(path), err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		t, ok := lineTime([]byte(line))
+		if !ok {
+			continue
+		}
+		ts, text, _ := strings.Cut(line, " ")
+		if !fn(t, ts, text) {
+			return true, nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return false, fmt.Errorf("read %s: %w", filepath.Base(path), err)
+	}
+	return false, nil
+}
+
+// segmentTime parses the rotation time of a segment from its name.
+func segmentTime(name string) (time.Time, bool) {
+	_, rest, ok := strings.Cut(name, segmentSep)
+	if !ok {
+		return time.Time{}, false
+	}
+	stamp, _, _ := strings.Cut(rest, ".log")
+	t, err := time.Parse(segmentTimeFormat, stamp)
+	return t, err == nil
+}
@@ -0,0 +1,182 @@
+package logcapture
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// segmentTimeFormat stamps rotated segments; it sorts in time order.
+const segmentTimeFormat = "20060102T150405.000Z"
+
+// segmentSep separates a container name from the rotation time in a
+// segment's file name. It can't appear in container names.
+const segmentSep = "@"
+
+// Config bounds the log files of a container.
+type Config struct {
+	MaxSize int64         // bytes after which the current file is rotated
+	MaxAge  time.Duration // time after which the current file is rotated, 0 = never
+	Keep    int           // rotated segments kept per container
+}
+
+// DefaultConfig rotates at 10 MiB or daily and keeps 10 segments.
+var DefaultConfig = Config{
+	MaxSize: 10 << 20,
+	MaxAge:  24 * time.Hour,
+	Keep:    10,
+}
+
+// Writer appends to a log file, rotating it into gzipped segments next to
+// it once it's too big or too old. Callers write whole lines so segments
+// never split one.
+type Writer struct {
+	path string
+	cfg  Config
+	now  func() time.Time
+
+	mu     sync.Mutex
+	f      *os.File
+	size   int64
+	opened time.Time
+}
+
+// OpenWriter opens or creates the log file at path, which must end in
+// ".log".
+func OpenWriter(path string, cfg Config) (*Writer, error) {
+	w := &Writer{path: path, cfg: cfg, now: time.Now}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create log dir: %w", err)
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) open() error {
+	f, err := os.OpenFile(w.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("stat log file: %w", err)
+	}
+	w.f = f
+	w.size = info.Size()
+	w.opened = w.now()
+	return nil
+}
+
+// Write appends p, rotating first if p would take the file past MaxSize
+// or the file has been written to for MaxAge.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return 0, os.ErrClosed
+	}
+	if w.size > 0 && (w.size+int64(len(p)) > w.cfg.MaxSize || (w.cfg.MaxAge > 0 && w.now().Sub(w.opened) >= w.cfg.MaxAge)) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.f.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Rotate moves the current file into a segment, if it has anything in it.
+func (w *Writer) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil || w.size == 0 {
+		return nil
+	}
+	return w.rotate()
+}
+
+func (w *Writer) rotate() error {
+	if err := w.f.Close(); err != nil {
+		return fmt.Errorf("close log file: %w", err)
+	}
+	w.f = nil
+
+	base := strings.TrimSuffix(w.path, ".log")
+	segment := base + segmentSep + w.now().UTC().Format(segmentTimeFormat) + ".log"
+	if err := os.Rename(w.path, segment); err != nil {
+		return fmt.Errorf("rotate log file: %w", err)
+	}
+	// A segment that can't be compressed is kept as is.
+	if err := compress(segment); err != nil {
+		slog.Warn("logcapture: compress segment", "err", err, "file", segment)
+	}
+	w.prune()
+	return w.open()
+}
+
+// compress gzips path to path.gz and removes path.
+func compress(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := path + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if cerr := gz.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path+".gz")
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Remove(path)
+}
+
+// prune removes the oldest segments beyond Keep.
+func (w *Writer) prune() {
+	segments, err := filepath.Glob(strings.TrimSuffix(w.path, ".log") + segmentSep + "*")
+	if err != nil || len(segments) <= w.cfg.Keep {
+		return
+	}
+	sort.Strings(segments)
+	for _, s := range segments[:len(segments)-w.cfg.Keep] {
+		if err := os.Remove(s); err != nil {
+			slog.Warn("logcapture: remove old segment", "err", err, "file", s)
+		}
+	}
+}
+
+// Close closes the current file.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Close()
+	w.f = nil
+	return err
+}
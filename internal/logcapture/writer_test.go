@@ -0,0 +1,133 @@
+package logcapture
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// segments returns the rotated segments next to path, oldest first.
+func segments(t *testing.T, path string) []string {
+	t.Helper()
+	list, err := filepath.Glob(strings.TrimSuffix(path, ".log") + segmentSep + "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func readGzip(t *testing.T, path string) string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestWriterRotatesBySize(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "web", "web-app-1.log")
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	w, err := OpenWriter(path, Config{MaxSize: 10, Keep: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.now = func() time.Time { now = now.Add(time.Second); return now }
+	defer w.Close()
+
+	for _, line := range []string{"aaaa\n", "bbbb\n", "cccc\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	segs := segments(t, path)
+	if len(segs) != 1 || !strings.HasSuffix(segs[0], ".log.gz") {
+		t.Fatalf("segments = %v, want one gzipped segment", segs)
+	}
+	if got := readGzip(t, segs[0]); got != "aaaa\nbbbb\n" {
+		t.Errorf("segment = %q", got)
+	}
+	cur, _ := os.ReadFile(path)
+	if string(cur) != "cccc\n" {
+		t.Errorf("current = %q", cur)
+	}
+}
+
+func TestWriterRotatesByAge(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "app.log")
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	w, err := OpenWriter(path, Config{MaxSize: 1 << 20, MaxAge: time.Hour, Keep: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.now = func() time.Time { return now }
+	w.opened = now
+	defer w.Close()
+
+	w.Write([]byte("old\n"))
+	now = now.Add(30 * time.Minute)
+	w.Write([]byte("still\n"))
+	if segs := segments(t, path); len(segs) != 0 {
+		t.Fatalf("rotated early: %v", segs)
+	}
+	now = now.Add(30 * time.Minute)
+	w.Write([]byte("new\n"))
+	segs := segments(t, path)
+	if len(segs) != 1 {
+		t.Fatalf("segments = %v, want 1", segs)
+	}
+	if got := readGzip(t, segs[0]); got != "old\nstill\n" {
+		t.Errorf("segment = %q", got)
+	}
+}
+
+func TestWriterKeepsNewestSegments(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "app.log")
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	w, err := OpenWriter(path, Config{MaxSize: 1 << 20, Keep: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.now = func() time.Time { now = now.Add(time.Second); return now }
+	defer w.Close()
+
+	for _, line := range []string{"1\n", "2\n", "3\n", "4\n"} {
+		w.Write([]byte(line))
+		if err := w.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	segs := segments(t, path)
+	if len(segs) != 2 {
+		t.Fatalf("segments = %v, want 2", segs)
+	}
+	if a, b := readGzip(t, segs[0]), readGzip(t, segs[1]); a != "3\n" || b != "4\n" {
+		t.Errorf("kept %q, %q, want the newest", a, b)
+	}
+	// Nothing to rotate
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(segments(t, path)); n != 2 {
+		t.Errorf("empty rotation made a segment: %d", n)
+	}
+}
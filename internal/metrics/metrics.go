@@ -0,0 +1,243 @@
+// Package metrics samples the resource usage of running containers at a
+// fixed interval and keeps a short per-service history in memory, so the UI
+// can show trends rather than only the current value. History is lost on
+// restart.
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/docker"
+)
+
+const (
+	// DefaultInterval is the time between samples.
+	DefaultInterval = time.Minute
+
+	// DefaultRetention is how much history is kept per service.
+	DefaultRetention = 24 * time.Hour
+
+	// statsConcurrency bounds concurrent stats requests; each one makes the
+	// daemon wait for a second CPU reading.
+	statsConcurrency = 4
+)
+
+// Source lists containers and samples their usage. docker.Client
+// satisfies it.
+type Source interface {
+	ContainerList(ctx context.Context, all bool, projectFilter string) ([]docker.Container, error)
+	ContainerStatsOnce(ctx context.Context, id string) (docker.ContainerUsage, error)
+	Info(ctx context.Context) (docker.DaemonInfo, error)
+}
+
+// Sample is the usage of a service at one point in time, summed over its
+// running replicas.
+type Sample struct {
+	Time       int64   `json:"t"`   // Unix seconds
+	CPUPercent float64 `json:"cpu"` // percent of one CPU (200 = two full cores)
+	MemBytes   uint64  `json:"mem"`
+	Replicas   int     `json:"replicas"`
+}
+
+// ring is a fixed-size buffer of samples, oldest first once full.
+type ring struct {
+	samples []Sample
+	next    int
+	full    bool
+}
+
+func (r *ring) add(s Sample) {
+	r.samples[r.next] = s
+	r.next = (r.next + 1) % len(r.samples)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// since returns the samples taken at or after t, oldest first.
+func (r *ring) since(t int64) []Sample {
+	var ordered []Sample
+	if r.full {
+		ordered = append(ordered, r.samples[r.next:]...)
+	}
+	ordered = append(ordered, r.samples[:r.next]...)
+	result := []Sample{}
+	for _, s := range ordered {
+		if s.Time >= t {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+func (r *ring) last() Sample {
+	return r.samples[(r.next-1+len(r.samples))%len(r.samples)]
+}
+
+// key identifies a service's series.
+type key struct {
+	stack, service string
+}
+
+// Collector samples the running containers and keeps their history.
+type Collector struct {
+	src       Source
+	interval  time.Duration
+	retention time.Duration
+
+	mu     sync.RWMutex
+	series map[key]*ring
+
+	// checkedInfo is set once the daemon has said whether it can report
+	// usage; if it can't (rootless without cgroups), unsupported skips
+	// collection from then on
+	checkedInfo bool
+	unsupported bool
+}
+
+// New creates a collector that keeps retention worth of samples taken every
+// interval. It samples once Start is called.
+func New(src Source, interval, retention time.Duration) *Collector {
+	return &Collector{
+		src:       src,
+		interval:  interval,
+		retention: retention,
+		series:    make(map[key]*ring),
+	}
+}
+
+// Interval returns the time between samples.
+func (c *Collector) Interval() time.Duration { return c.interval }
+
+// Start samples every interval until ctx is cancelled.
+func (c *Collector) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				c.Collect(ctx, now)
+			}
+		}
+	}()
+}
+
+// Collect samples every running container of a compose project once and
+// records the per-service totals at now.
+func (c *Collector) Collect(ctx context.Context, now time.Time) {
+	ctx, cancel := context.WithTimeout(ctx, c.interval)
+	defer cancel()
+
+	if !c.supported(ctx) {
+		return
+	}
+
+	containers, err := c.src.ContainerList(ctx, false, "")
+	if err != nil {
+		slog.Warn("metrics: list containers", "err", err)
+		return
+	}
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		sem    = make(chan struct{}, statsConcurrency)
+		totals = make(map[key]Sample)
+	)
+	for _, ctr := range containers {
+		if ctr.State != "running" || ctr.Project == "" {
+			continue
+		}
+		k := key{ctr.Project, ctr.Service}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(id string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			u, err := c.src.ContainerStatsOnce(ctx, id)
+			if err != nil {
+				slog.Debug("metrics: stats", "container", id, "err", err)
+				return
+			}
+			mu.Lock()
+			s := totals[k]
+			s.CPUPercent += u.CPUPercent
+			s.MemBytes += u.MemBytes
+			s.Replicas++
+			totals[k] = s
+			mu.Unlock()
+		}(ctr.ID)
+	}
+	wg.Wait()
+
+	c.record(now, totals)
+}
+
+// supported asks the daemon once whether it can report usage. If it can't
+// be asked, sampling is attempted and retried next time.
+func (c *Collector) supported(ctx context.Context) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.checkedInfo {
+		return !c.unsupported
+	}
+	info, err := c.src.Info(ctx)
+	if err != nil {
+		return true
+	}
+	c.checkedInfo = true
+	c.unsupported = !info.StatsSupported()
+	if c.unsupported {
+		slog.Info("metrics: daemon has no cgroups to read usage from; history disabled", "rootless", info.Rootless)
+	}
+	return !c.unsupported
+}
+
+// record adds a sample per service and drops series that have had no
+// samples for the whole retention period.
+func (c *Collector) record(now time.Time, totals map[key]Sample) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, s := range totals {
+		r := c.series[k]
+		if r == nil {
+			r = &ring{samples: make([]Sample, c.capacity())}
+			c.series[k] = r
+		}
+		s.Time = now.Unix()
+		r.add(s)
+	}
+	cutoff := now.Add(-c.retention).Unix()
+	for k, r := range c.series {
+		if r.last().Time < cutoff {
+			delete(c.series, k)
+		}
+	}
+}
+
+func (c *Collector) capacity() int {
+	return max(int(c.retention/c.interval), 1)
+}
+
+// Stack returns the history of a stack's services since the given time,
+// keyed by service name.
+func (c *Collector) Stack(stackName string, since time.Time) map[string][]Sample {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	result := make(map[string][]Sample)
+	for k, r := range c.series {
+		if k.stack != stackName {
+			continue
+		}
+		if samples := r.since(since.Unix()); len(samples) > 0 {
+			result[k.service] = samples
+		}
+	}
+	return result
+}
@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/docker"
+)
+
+// fakeSource serves a fixed container list and per-container usage.
+type fakeSource struct {
+	containers []docker.Container
+	usage      map[string]docker.ContainerUsage
+	info       docker.DaemonInfo
+}
+
+func (f *fakeSource) ContainerList(context.Context, bool, string) ([]docker.Container, error) {
+	return f.containers, nil
+}
+
+func (f *fakeSource) ContainerStatsOnce(_ context.Context, id string) (docker.ContainerUsage, error) {
+	u, ok := f.usage[id]
+	if !ok {
+		return docker.ContainerUsage{}, errors.New("no such container")
+	}
+	return u, nil
+}
+
+func (f *fakeSource) Info(context.Context) (docker.DaemonInfo, error) {
+	return f.info, nil
+}
+
+func TestCollector(t *testing.T) {
+	t.Parallel()
+	src := &fakeSource{
+		containers: []docker.Container{
+			{ID: "a1", Project: "web", Service: "app", State: "running"},
+			{ID: "a2", Project: "web", Service: "app", State: "running"},
+			{ID: "d1", Project: "web", Service: "db", State: "running"},
+			{ID: "x1", Project: "web", Service: "worker", State: "exited"},
+			{ID: "o1", Project: "other", Service: "app", State: "running"},
+			{ID: "n1", State: "running"}, // not a compose container
+		},
+		usage: map[string]docker.ContainerUsage{
+			"a1": {CPUPercent: 10, MemBytes: 100},
+			"a2": {CPUPercent: 30, MemBytes: 200},
+			"d1": {CPUPercent: 5, MemBytes: 1000},
+			"o1": {CPUPercent: 1, MemBytes: 1},
+		},
+	}
+	// Three samples of history
+	c := New(src, time.Minute, 3*time.Minute)
+	start := time.Unix(1_700_000_000, 0)
+	for i := range 4 {
+		src.usage["d1"] = docker.ContainerUsage{CPUPercent: float64(i), MemBytes: 1000}
+		c.Collect(context.Background(), start.Add(time.Duration(i)*time.Minute))
+	}
+
+	got := c.Stack("web", time.Time{})
+	if len(got) != 2 {
+		t.Fatalf("expected app and db series, got %v", got)
+	}
+	wantApp := Sample{Time: start.Add(3 * time.Minute).Unix(), CPUPercent: 40, MemBytes: 300, Replicas: 2}
+	if app := got["app"]; len(app) != 3 || app[2] != wantApp {
+		t.Errorf("app = %+v", app)
+	}
+	var cpu []float64
+	for _, s := range got["db"] {
+		cpu = append(cpu, s.CPUPercent)
+	}
+	if !reflect.DeepEqual(cpu, []float64{1, 2, 3}) {
+		t.Errorf("db cpu = %v, want oldest sample dropped", cpu)
+	}
+
+	if recent := c.Stack("web", start.Add(3*time.Minute)); len(recent["db"]) != 1 {
+		t.Errorf("since filter: %+v", recent)
+	}
+
+	// Series of services that stopped expire after the retention period
+	src.containers = src.containers[:2]
+	c.Collect(context.Background(), start.Add(10*time.Minute))
+	got = c.Stack("web", time.Time{})
+	if _, ok := got["db"]; ok {
+		t.Error("db series not expired")
+	}
+	if len(got["app"]) != 3 {
+		t.Errorf("app = %+v", got["app"])
+	}
+}
+
+func TestCollectorSkipsUnsupportedDaemon(t *testing.T) {
+	t.Parallel()
+	src := &fakeSource{
+		containers: []docker.Container{{ID: "a1", Project: "web", Service: "app", State: "running"}},
+		usage:      map[string]docker.ContainerUsage{"a1": {CPUPercent: 10, MemBytes: 100}},
+		info:       docker.DaemonInfo{Rootless: true, CgroupDriver: "none"},
+	}
+	c := New(src, time.Minute, time.Hour)
+	c.Collect(context.Background(), time.Unix(1_700_000_000, 0))
+	if got := c.Stack("web", time.Time{}); len(got) != 0 {
+		t.Errorf("expected no history without cgroups, got %v", got)
+	}
+}
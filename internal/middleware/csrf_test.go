@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCSRF(t *testing.T) {
+	t.Parallel()
+	h := CSRF(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+
+	// Safe requests get the token cookie
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	var token string
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == CSRFCookie {
+			token = c.Value
+		}
+	}
+	if rec.Code != http.StatusOK || token == "" {
+		t.Fatalf("GET: code=%d, token %q", rec.Code, token)
+	}
+
+	post := func(cookie, header, auth string) int {
+		req := httptest.NewRequest("POST", "/api/mock/reset", nil)
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: CSRFCookie, Value: cookie})
+		}
+		if header != "" {
+			req.Header.Set(CSRFHeader, header)
+		}
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	cases := []struct {
+		name                 string
+		cookie, header, auth string
+		want                 int
+	}{
+		{"no cookies", "", "", "", http.StatusOK},
+		{"matching token", token, token, "", http.StatusOK},
+		{"missing header", token, "", "", http.StatusForbidden},
+		{"wrong header", token, "forged", "", http.StatusForbidden},
+		{"bearer auth", token, "", "Bearer abc", http.StatusOK},
+	}
+	for _, tc := range cases {
+		if got := post(tc.cookie, tc.header, tc.auth); got != tc.want {
+			t.Errorf("%s: got %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
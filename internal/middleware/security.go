@@ -0,0 +1,125 @@
+// Package middleware provides HTTP middleware shared by the REST endpoints,
+// the WebSocket upgrade, and the SPA handler.
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// SecurityConfig controls CORS and the security headers added to every
+// response.
+type SecurityConfig struct {
+	// AllowedOrigins lists cross-origin callers allowed to use the API.
+	// Patterns use the same syntax as the WebSocket origin check: matched
+	// case-insensitively with path.Match against the origin host, or against
+	// "scheme://host" if the pattern contains "://". Same-origin requests are
+	// always allowed.
+	AllowedOrigins []string
+
+	// FrameAncestors lists origins allowed to embed Dockge in an iframe, in
+	// CSP source syntax (e.g. "https://dash.example.com"). Empty means
+	// same-origin only. "*" allows any site to frame the UI.
+	FrameAncestors []string
+}
+
+// contentSecurityPolicy is suitable for the bundled SPA: scripts only from
+// our origin, inline styles for Vue style bindings, images from anywhere
+// (service icons are often remote), and WebSocket connections back to us.
+const contentSecurityPolicy = "default-src 'self'; " +
+	"script-src 'self'; " +
+	"style-src 'self' 'unsafe-inline'; " +
+	"img-src 'self' data: https:; " +
+	"font-src 'self' data:; " +
+	"connect-src 'self' ws: wss:; " +
+	"object-src 'none'; " +
+	"base-uri 'self'; " +
+	"form-action 'self'"
+
+// Security wraps next with CORS handling and security headers.
+func Security(cfg SecurityConfig, next http.Handler) http.Handler {
+	csp := contentSecurityPolicy + "; frame-ancestors " + frameAncestors(cfg.FrameAncestors)
+	sameOriginFrames := len(cfg.FrameAncestors) == 0
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("Content-Security-Policy", csp)
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+		// X-Frame-Options can't express an allow-list, so it's only sent
+		// when embedding is restricted to same-origin. Browsers that
+		// understand frame-ancestors ignore it anyway.
+		if sameOriginFrames {
+			h.Set("X-Frame-Options", "SAMEORIGIN")
+		}
+
+		origin := r.Header.Get("Origin")
+		if origin == "" || isSameOrigin(r, origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h.Add("Vary", "Origin")
+		allowed := OriginAllowed(origin, cfg.AllowedOrigins)
+		if allowed {
+			h.Set("Access-Control-Allow-Origin", origin)
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		// Preflight: answer directly, never pass to the app handler.
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			if !allowed {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			h.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			h.Set("Access-Control-Allow-Headers", "Content-Type, Authorization, "+CSRFHeader)
+			h.Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// OriginAllowed reports whether origin matches any of the patterns. Invalid
+// origins and malformed patterns never match.
+func OriginAllowed(origin string, patterns []string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	host := strings.ToLower(u.Host)
+	full := strings.ToLower(u.Scheme) + "://" + host
+	for _, p := range patterns {
+		p = strings.ToLower(p)
+		target := host
+		if strings.Contains(p, "://") {
+			target = full
+		}
+		if ok, err := path.Match(p, target); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isSameOrigin reports whether the Origin header refers to the host the
+// request was sent to.
+func isSameOrigin(r *http.Request, origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+func frameAncestors(origins []string) string {
+	if len(origins) == 0 {
+		return "'self'"
+	}
+	return "'self' " + strings.Join(origins, " ")
+}
@@ -0,0 +1,218 @@
+package models
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/cfilipov/dockge/internal/db"
+)
+
+// ErrAgentExists is returned when registering an endpoint that's taken.
+var ErrAgentExists = errors.New("agent endpoint already exists")
+
+// Agent is a remote Dockge instance running in agent mode. The agent dials
+// the controller and authenticates with the token issued when it was added;
+// only a hash of the token is stored, which doubles as the key of the link.
+//
+// While a rotation is pending, i.e. until the agent first connects with its
+// new token, the previous token stays valid too.
+type Agent struct {
+	Endpoint      string `json:"endpoint"` // namespace of the agent's stacks, e.g. "nas"
+	Name          string `json:"name"`
+	TokenHash     string `json:"tokenHash"`
+	PrevTokenHash string `json:"prevTokenHash,omitempty"` // set while a rotation is pending
+	CreatedBy     string `json:"createdBy,omitempty"`
+	CreatedAt     int64  `json:"createdAt"`           // Unix seconds
+	LastSeen      int64  `json:"lastSeen"`            // Unix seconds, 0 if never connected
+	RotatedAt     int64  `json:"rotatedAt,omitempty"` // Unix seconds of the last completed rotation
+}
+
+// AgentStore persists agents in BoltDB, keyed by endpoint.
+type AgentStore struct {
+	db *bolt.DB
+}
+
+func NewAgentStore(database *bolt.DB) *AgentStore {
+	return &AgentStore{db: database}
+}
+
+// Create registers an agent and returns its token. The token is only
+// available here; afterwards the agent can't be recovered from the store.
+func (s *AgentStore) Create(a Agent) (string, error) {
+	token, err := GenSecret(secretLength)
+	if err != nil {
+		return "", fmt.Errorf("generate agent token: %w", err)
+	}
+	a.TokenHash = hashAgentToken(token)
+	a.CreatedAt = time.Now().Unix()
+	a.LastSeen = 0
+	data, err := json.Marshal(&a)
+	if err != nil {
+		return "", fmt.Errorf("marshal agent: %w", err)
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(db.BucketAgents)
+		if b.Get([]byte(a.Endpoint)) != nil {
+			return ErrAgentExists
+		}
+		return b.Put([]byte(a.Endpoint), data)
+	})
+	if err != nil {
+		return "", fmt.Errorf("create agent: %w", err)
+	}
+	return token, nil
+}
+
+// Get returns an agent by endpoint, or nil if it doesn't exist.
+func (s *AgentStore) Get(endpoint string) (*Agent, error) {
+	var a *Agent
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(db.BucketAgents).Get([]byte(endpoint))
+		if v == nil {
+			return nil
+		}
+		a = &Agent{}
+		return json.Unmarshal(v, a)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get agent: %w", err)
+	}
+	return a, nil
+}
+
+// List returns all agents ordered by endpoint.
+func (s *AgentStore) List() ([]Agent, error) {
+	agents := []Agent{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(db.BucketAgents).ForEach(func(_, v []byte) error {
+			var a Agent
+			if err := json.Unmarshal(v, &a); err != nil {
+				return err
+			}
+			agents = append(agents, a)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list agents: %w", err)
+	}
+	return agents, nil
+}
+
+// Authenticate returns the agent the token was issued to, or nil.
+func (s *AgentStore) Authenticate(token string) (*Agent, error) {
+	if token == "" {
+		return nil, nil
+	}
+	hash := []byte(hashAgentToken(token))
+	a, _, err := s.Identify(func(key string) bool {
+		return subtle.ConstantTimeCompare([]byte(key), hash) == 1
+	})
+	return a, err
+}
+
+// Identify returns the agent with a valid token hash that passes verify,
+// and the hash that did, or nil.
+func (s *AgentStore) Identify(verify func(tokenHash string) bool) (*Agent, string, error) {
+	agents, err := s.List()
+	if err != nil {
+		return nil, "", err
+	}
+	for i := range agents {
+		for _, hash := range []string{agents[i].TokenHash, agents[i].PrevTokenHash} {
+			if hash != "" && verify(hash) {
+				return &agents[i], hash, nil
+			}
+		}
+	}
+	return nil, "", nil
+}
+
+// Rotate issues a new token for an agent and returns it. current is the
+// hash the agent is connected with, which stays valid until Confirm.
+func (s *AgentStore) Rotate(endpoint, current string) (string, error) {
+	token, err := GenSecret(secretLength)
+	if err != nil {
+		return "", fmt.Errorf("generate agent token: %w", err)
+	}
+	err = s.update(endpoint, func(a *Agent) {
+		a.PrevTokenHash = current
+		a.TokenHash = hashAgentToken(token)
+	})
+	if err != nil {
+		return "", fmt.Errorf("rotate agent token: %w", err)
+	}
+	return token, nil
+}
+
+// Confirm completes a pending rotation once the agent connected with the
+// token hash. Confirming any other hash is a no-op.
+func (s *AgentStore) Confirm(endpoint, tokenHash string) error {
+	err := s.update(endpoint, func(a *Agent) {
+		if a.PrevTokenHash != "" && a.TokenHash == tokenHash {
+			a.PrevTokenHash = ""
+			a.RotatedAt = time.Now().Unix()
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("confirm agent token: %w", err)
+	}
+	return nil
+}
+
+// Touch records that an agent is connected now. A deleted agent is left
+// deleted.
+func (s *AgentStore) Touch(endpoint string) error {
+	err := s.update(endpoint, func(a *Agent) {
+		a.LastSeen = time.Now().Unix()
+	})
+	if err != nil {
+		return fmt.Errorf("touch agent: %w", err)
+	}
+	return nil
+}
+
+// update modifies an agent in place. A missing agent is left missing.
+func (s *AgentStore) update(endpoint string, fn func(*Agent)) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(db.BucketAgents)
+		v := b.Get([]byte(endpoint))
+		if v == nil {
+			return nil
+		}
+		var a Agent
+		if err := json.Unmarshal(v, &a); err != nil {
+			return err
+		}
+		fn(&a)
+		data, err := json.Marshal(&a)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(endpoint), data)
+	})
+}
+
+// Delete removes an agent. Deleting a missing agent is a no-op.
+func (s *AgentStore) Delete(endpoint string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(db.BucketAgents).Delete([]byte(endpoint))
+	})
+	if err != nil {
+		return fmt.Errorf("delete agent: %w", err)
+	}
+	return nil
+}
+
+// hashAgentToken matches agent.KeyOf, so the hash keys the agent link.
+func hashAgentToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,172 @@
+package models
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/cfilipov/dockge/internal/db"
+)
+
+// API token scopes: the data a token can read.
+const (
+	ScopeStacks     = "stacks"
+	ScopeContainers = "containers"
+	ScopeImages     = "images"
+)
+
+// APITokenScopes lists the valid scopes.
+var APITokenScopes = []string{ScopeStacks, ScopeContainers, ScopeImages}
+
+// apiTokenPrefix marks API tokens, so they're recognizable in configs.
+const apiTokenPrefix = "dkg_"
+
+// apiTokenTouchInterval limits how often LastUsed is written.
+const apiTokenTouchInterval = time.Minute
+
+// APIToken grants read access to the GraphQL API. Only a hash of the token
+// is stored.
+type APIToken struct {
+	ID        int      `json:"id"`
+	Name      string   `json:"name"`
+	TokenHash string   `json:"tokenHash"`
+	Scopes    []string `json:"scopes"`           // subset of APITokenScopes
+	Stacks    []string `json:"stacks,omitempty"` // stacks the token can see, empty = all
+	CreatedBy string   `json:"createdBy,omitempty"`
+	CreatedAt int64    `json:"createdAt"` // Unix seconds
+	LastUsed  int64    `json:"lastUsed"`  // Unix seconds, 0 = never used
+}
+
+// HasScope reports whether the token can read a kind of data.
+func (t *APIToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CanSeeStack reports whether the token's stack restriction lets it see a
+// stack.
+func (t *APIToken) CanSeeStack(name string) bool {
+	if len(t.Stacks) == 0 {
+		return true
+	}
+	for _, s := range t.Stacks {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// APITokenStore persists API tokens in BoltDB, keyed by a sequence.
+type APITokenStore struct {
+	db *bolt.DB
+}
+
+func NewAPITokenStore(database *bolt.DB) *APITokenStore {
+	return &APITokenStore{db: database}
+}
+
+// Create stores a token and returns its secret, which is only available
+// here. It assigns the token's ID and creation time.
+func (s *APITokenStore) Create(t *APIToken) (string, error) {
+	secret, err := GenSecret(secretLength)
+	if err != nil {
+		return "", fmt.Errorf("generate api token: %w", err)
+	}
+	token := apiTokenPrefix + secret
+	t.TokenHash = hashAgentToken(token)
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(db.BucketAPITokens)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return fmt.Errorf("next sequence: %w", err)
+		}
+		t.ID = int(seq)
+		t.CreatedAt = time.Now().Unix()
+		t.LastUsed = 0
+		return putAPIToken(b, t)
+	})
+	if err != nil {
+		return "", fmt.Errorf("create api token: %w", err)
+	}
+	return token, nil
+}
+
+func putAPIToken(b *bolt.Bucket, t *APIToken) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return b.Put(itob(uint64(t.ID)), data)
+}
+
+// List returns all tokens ordered by ID.
+func (s *APITokenStore) List() ([]APIToken, error) {
+	tokens := []APIToken{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(db.BucketAPITokens).ForEach(func(_, v []byte) error {
+			var t APIToken
+			if err := json.Unmarshal(v, &t); err != nil {
+				return err
+			}
+			tokens = append(tokens, t)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list api tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+// Authenticate returns the token with the given secret, or nil, and
+// records that it was used.
+func (s *APITokenStore) Authenticate(token string) (*APIToken, error) {
+	if token == "" {
+		return nil, nil
+	}
+	tokens, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	hash := []byte(hashAgentToken(token))
+	for i := range tokens {
+		t := &tokens[i]
+		if subtle.ConstantTimeCompare([]byte(t.TokenHash), hash) != 1 {
+			continue
+		}
+		if now := time.Now().Unix(); now-t.LastUsed >= int64(apiTokenTouchInterval/time.Second) {
+			t.LastUsed = now
+			err := s.db.Update(func(tx *bolt.Tx) error {
+				b := tx.Bucket(db.BucketAPITokens)
+				if b.Get(itob(uint64(t.ID))) == nil {
+					return nil // deleted meanwhile
+				}
+				return putAPIToken(b, t)
+			})
+			if err != nil {
+				return nil, fmt.Errorf("touch api token: %w", err)
+			}
+		}
+		return t, nil
+	}
+	return nil, nil
+}
+
+// Delete removes a token. Deleting a missing token is a no-op.
+func (s *APITokenStore) Delete(id int) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(db.BucketAPITokens).Delete(itob(uint64(id)))
+	})
+	if err != nil {
+		return fmt.Errorf("delete api token: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/cfilipov/dockge/internal/db"
+)
+
+// AuditEntry records one state-changing request made by a user.
+type AuditEntry struct {
+	ID       uint64   `json:"id"`
+	Time     int64    `json:"time"` // Unix seconds
+	User     string   `json:"user"` // "" if the request wasn't logged in
+	Action   string   `json:"action"`
+	Endpoint string   `json:"endpoint,omitempty"` // agent the request was sent to
+	Stack    string   `json:"stack,omitempty"`
+	Args     []string `json:"args"`            // summarized, secrets redacted
+	Success  bool     `json:"success"`
+	Error    string   `json:"error,omitempty"`
+	Acked    bool     `json:"acked"` // false if the request asked for no response
+}
+
+// AuditFilter narrows an audit log listing. Empty fields match everything.
+type AuditFilter struct {
+	User   string `json:"user"`
+	Action string `json:"action"`
+	Stack  string `json:"stack"`
+}
+
+func (f AuditFilter) matches(e *AuditEntry) bool {
+	return (f.User == "" || e.User == f.User) &&
+		(f.Action == "" || e.Action == f.Action) &&
+		(f.Stack == "" || e.Stack == f.Stack)
+}
+
+// AuditStore persists the audit log in BoltDB, keyed by a sequence so
+// entries iterate oldest first. Entries can only be appended.
+type AuditStore struct {
+	db *bolt.DB
+}
+
+func NewAuditStore(database *bolt.DB) *AuditStore {
+	return &AuditStore{db: database}
+}
+
+// Append stores an entry and assigns its ID, and its time if unset.
+func (s *AuditStore) Append(e *AuditEntry) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(db.BucketAuditLog)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return fmt.Errorf("next sequence: %w", err)
+		}
+		e.ID = seq
+		if e.Time == 0 {
+			e.Time = time.Now().Unix()
+		}
+		data, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("marshal audit entry: %w", err)
+		}
+		return b.Put(itob(seq), data)
+	})
+	if err != nil {
+		return fmt.Errorf("append audit entry: %w", err)
+	}
+	return nil
+}
+
+// List returns up to limit entries matching filter with an ID below before
+// (0 = from the newest), newest first; limit <= 0 means no limit. next is
+// the before value for the following page, or 0 if there are no older
+// entries.
+func (s *AuditStore) List(filter AuditFilter, before uint64, limit int) (entries []AuditEntry, next uint64, err error) {
+	entries = []AuditEntry{}
+	err = s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(db.BucketAuditLog).Cursor()
+		var k, v []byte
+		if before == 0 {
+			k, v = c.Last()
+		} else {
+			c.Seek(itob(before))
+			k, v = c.Prev()
+		}
+		for ; k != nil; k, v = c.Prev() {
+			var e AuditEntry
+			if err := json.Unmarshal(v, &e); err != nil {
+				return fmt.Errorf("unmarshal audit entry: %w", err)
+			}
+			if !filter.matches(&e) {
+				continue
+			}
+			if limit > 0 && len(entries) == limit {
+				next = entries[len(entries)-1].ID
+				break
+			}
+			entries = append(entries, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("list audit log: %w", err)
+	}
+	return entries, next, nil
+}
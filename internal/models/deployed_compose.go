@@ -0,0 +1,80 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/cfilipov/dockge/internal/db"
+)
+
+// DeployedCompose is a snapshot of a stack's files as of its last
+// successful deploy, to tell which on-disk changes aren't running yet.
+type DeployedCompose struct {
+	StackName           string `json:"stackName"`
+	ComposeFileName     string `json:"composeFileName"`
+	ComposeYAML         string `json:"composeYAML"`
+	ComposeENV          string `json:"composeENV"`
+	OverrideFileName    string `json:"overrideFileName,omitempty"`
+	ComposeOverrideYAML string `json:"composeOverrideYAML,omitempty"`
+	Hash                string `json:"hash"`       // fingerprint of the three files
+	Action              string `json:"action"`     // deploy or up
+	DeployedAt          int64  `json:"deployedAt"` // Unix seconds
+}
+
+// DeployedComposeStore persists the last deployed files of each stack in
+// BoltDB, keyed by stack name. Each deploy replaces the previous snapshot.
+type DeployedComposeStore struct {
+	db *bolt.DB
+}
+
+func NewDeployedComposeStore(database *bolt.DB) *DeployedComposeStore {
+	return &DeployedComposeStore{db: database}
+}
+
+// Get returns the snapshot of a stack, or nil if it was never deployed
+// since snapshots were kept.
+func (s *DeployedComposeStore) Get(stackName string) (*DeployedCompose, error) {
+	var d *DeployedCompose
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(db.BucketDeployedCompose).Get([]byte(stackName))
+		if v == nil {
+			return nil
+		}
+		d = &DeployedCompose{}
+		return json.Unmarshal(v, d)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get deployed compose: %w", err)
+	}
+	return d, nil
+}
+
+// Set stores the snapshot of a stack and stamps its deploy time.
+func (s *DeployedComposeStore) Set(d DeployedCompose) error {
+	d.DeployedAt = time.Now().Unix()
+	data, err := json.Marshal(&d)
+	if err != nil {
+		return fmt.Errorf("marshal deployed compose: %w", err)
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(db.BucketDeployedCompose).Put([]byte(d.StackName), data)
+	})
+	if err != nil {
+		return fmt.Errorf("set deployed compose: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the snapshot of a stack.
+func (s *DeployedComposeStore) Delete(stackName string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(db.BucketDeployedCompose).Delete([]byte(stackName))
+	})
+	if err != nil {
+		return fmt.Errorf("delete deployed compose: %w", err)
+	}
+	return nil
+}
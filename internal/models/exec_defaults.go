@@ -0,0 +1,73 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/cfilipov/dockge/internal/db"
+)
+
+// ExecDefaults are the exec terminal options a user last opened a shell
+// with, reused for the shells they open without choosing any.
+type ExecDefaults struct {
+	Username  string   `json:"username"`
+	User      string   `json:"user,omitempty"`
+	Workdir   string   `json:"workdir,omitempty"`
+	Env       []string `json:"env,omitempty"`
+	UpdatedAt int64    `json:"updatedAt"` // Unix seconds
+}
+
+// IsEmpty reports whether the defaults are docker's own.
+func (d *ExecDefaults) IsEmpty() bool {
+	return d.User == "" && d.Workdir == "" && len(d.Env) == 0
+}
+
+// ExecDefaultsStore persists exec defaults in BoltDB, keyed by username.
+type ExecDefaultsStore struct {
+	db *bolt.DB
+}
+
+func NewExecDefaultsStore(database *bolt.DB) *ExecDefaultsStore {
+	return &ExecDefaultsStore{db: database}
+}
+
+// Get returns the defaults of a user, or nil if they have none.
+func (s *ExecDefaultsStore) Get(username string) (*ExecDefaults, error) {
+	var d *ExecDefaults
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(db.BucketExecDefaults).Get([]byte(username))
+		if v == nil {
+			return nil
+		}
+		d = &ExecDefaults{}
+		return json.Unmarshal(v, d)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get exec defaults: %w", err)
+	}
+	return d, nil
+}
+
+// Set stores a user's defaults and stamps their update time. Empty
+// defaults are deleted.
+func (s *ExecDefaultsStore) Set(d ExecDefaults) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(db.BucketExecDefaults)
+		if d.IsEmpty() {
+			return bucket.Delete([]byte(d.Username))
+		}
+		d.UpdatedAt = time.Now().Unix()
+		data, err := json.Marshal(&d)
+		if err != nil {
+			return fmt.Errorf("marshal exec defaults: %w", err)
+		}
+		return bucket.Put([]byte(d.Username), data)
+	})
+	if err != nil {
+		return fmt.Errorf("set exec defaults: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/cfilipov/dockge/internal/db"
+)
+
+// Keys of the host state bucket.
+var (
+	hostStateSnapshot     = []byte("containerSnapshot")
+	hostStateRebootReport = []byte("rebootReport")
+)
+
+// ContainerSnapshot records the running containers of the host, taken
+// periodically so the state before a power loss is known after boot.
+type ContainerSnapshot struct {
+	BootID     string              `json:"bootID"`  // kernel boot ID when taken
+	TakenAt    int64               `json:"takenAt"` // Unix seconds
+	Containers []SnapshotContainer `json:"containers"`
+}
+
+// SnapshotContainer is a running container of a ContainerSnapshot.
+type SnapshotContainer struct {
+	Name    string `json:"name"`
+	Stack   string `json:"stack,omitempty"`
+	Service string `json:"service,omitempty"`
+	Health  string `json:"health,omitempty"`
+}
+
+// RebootReport compares the containers running before Dockge last started
+// with the ones running once the host settled after it.
+type RebootReport struct {
+	Rebooted   bool            `json:"rebooted"`   // the host rebooted, not just Dockge
+	SnapshotAt int64           `json:"snapshotAt"` // Unix seconds of the snapshot before
+	CheckedAt  int64           `json:"checkedAt"`  // Unix seconds
+	Stacks     []StackRecovery `json:"stacks"`     // stacks that ran before, by name
+	Failed     int             `json:"failed"`     // stacks not fully back
+	Containers []string        `json:"containers"` // non-stack containers not back
+}
+
+// StackRecovery is whether a stack that ran before came back.
+type StackRecovery struct {
+	Stack     string   `json:"stack"`
+	Status    string   `json:"status"`              // up, partial or down
+	Missing   []string `json:"missing,omitempty"`   // services not running again
+	Unhealthy []string `json:"unhealthy,omitempty"` // services running but unhealthy
+}
+
+// HostStateStore persists the container snapshot and the last reboot
+// report in BoltDB.
+type HostStateStore struct {
+	db *bolt.DB
+}
+
+func NewHostStateStore(database *bolt.DB) *HostStateStore {
+	return &HostStateStore{db: database}
+}
+
+// Snapshot returns the last container snapshot, or nil if none was taken.
+func (s *HostStateStore) Snapshot() (*ContainerSnapshot, error) {
+	var snap *ContainerSnapshot
+	if err := s.get(hostStateSnapshot, &snap); err != nil {
+		return nil, fmt.Errorf("get container snapshot: %w", err)
+	}
+	return snap, nil
+}
+
+// SetSnapshot replaces the container snapshot.
+func (s *HostStateStore) SetSnapshot(snap *ContainerSnapshot) error {
+	if err := s.put(hostStateSnapshot, snap); err != nil {
+		return fmt.Errorf("set container snapshot: %w", err)
+	}
+	return nil
+}
+
+// RebootReport returns the last reboot report, or nil if there is none.
+func (s *HostStateStore) RebootReport() (*RebootReport, error) {
+	var report *RebootReport
+	if err := s.get(hostStateRebootReport, &report); err != nil {
+		return nil, fmt.Errorf("get reboot report: %w", err)
+	}
+	return report, nil
+}
+
+// SetRebootReport replaces the last reboot report.
+func (s *HostStateStore) SetRebootReport(report *RebootReport) error {
+	if err := s.put(hostStateRebootReport, report); err != nil {
+		return fmt.Errorf("set reboot report: %w", err)
+	}
+	return nil
+}
+
+func (s *HostStateStore) get(key []byte, dst any) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(db.BucketHostState).Get(key)
+		if v == nil {
+			return nil
+		}
+		return json.Unmarshal(v, dst)
+	})
+}
+
+func (s *HostStateStore) put(key []byte, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(db.BucketHostState).Put(key, data)
+	})
+}
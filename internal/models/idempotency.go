@@ -0,0 +1,101 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/cfilipov/dockge/internal/db"
+)
+
+// IdempotencyTTL is how long a request's idempotency key is remembered.
+const IdempotencyTTL = 15 * time.Minute
+
+// IdempotentRequest is a request made with an idempotency key.
+type IdempotentRequest struct {
+	Fingerprint string          `json:"fingerprint"`   // hash of the request's event and args
+	Ack         json.RawMessage `json:"ack,omitempty"` // nil while the request is handled
+	CreatedAt   int64           `json:"createdAt"`     // Unix seconds
+}
+
+// IdempotencyStore persists the idempotency keys of recent requests in
+// BoltDB, so retries are recognized across reconnects and restarts.
+type IdempotencyStore struct {
+	db *bolt.DB
+}
+
+func NewIdempotencyStore(database *bolt.DB) *IdempotencyStore {
+	return &IdempotencyStore{db: database}
+}
+
+// Claim records a request under key and returns nil, unless a request was
+// recorded under key within IdempotencyTTL, which is returned instead.
+// Expired keys are dropped.
+func (s *IdempotencyStore) Claim(key, fingerprint string, now time.Time) (*IdempotentRequest, error) {
+	var prev *IdempotentRequest
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(db.BucketIdempotency)
+		cutoff := now.Add(-IdempotencyTTL).Unix()
+		var expired [][]byte
+		err := bucket.ForEach(func(k, v []byte) error {
+			var r IdempotentRequest
+			if json.Unmarshal(v, &r) != nil || r.CreatedAt < cutoff {
+				expired = append(expired, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		if v := bucket.Get([]byte(key)); v != nil {
+			prev = &IdempotentRequest{}
+			return json.Unmarshal(v, prev)
+		}
+		data, err := json.Marshal(IdempotentRequest{Fingerprint: fingerprint, CreatedAt: now.Unix()})
+		if err != nil {
+			return fmt.Errorf("marshal idempotent request: %w", err)
+		}
+		return bucket.Put([]byte(key), data)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("claim idempotency key: %w", err)
+	}
+	return prev, nil
+}
+
+// Complete stores the ack of a claimed request. The first ack stored is
+// kept.
+func (s *IdempotencyStore) Complete(key string, ack json.RawMessage) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(db.BucketIdempotency)
+		v := bucket.Get([]byte(key))
+		if v == nil {
+			return nil // expired meanwhile
+		}
+		var r IdempotentRequest
+		if err := json.Unmarshal(v, &r); err != nil {
+			return err
+		}
+		if r.Ack != nil {
+			return nil
+		}
+		r.Ack = ack
+		data, err := json.Marshal(&r)
+		if err != nil {
+			return fmt.Errorf("marshal idempotent request: %w", err)
+		}
+		return bucket.Put([]byte(key), data)
+	})
+	if err != nil {
+		return fmt.Errorf("complete idempotency key: %w", err)
+	}
+	return nil
+}
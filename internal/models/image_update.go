@@ -45,6 +45,18 @@ type imageUpdateRecord struct {
 	HasUpdate    bool   `json:"hasUpdate"`
 	CheckStatus  string `json:"checkStatus,omitempty"` // "ok" or "failed"
 	LastChecked  int64  `json:"lastChecked,omitempty"`
+
+	Release *ReleaseUpdate `json:"release,omitempty"`
+}
+
+// ReleaseUpdate is the semver classification of newer tags for a service's
+// image: the newest patch, minor and major tag, and the most significant
+// Kind ("patch", "minor" or "major").
+type ReleaseUpdate struct {
+	Kind  string `json:"kind"`
+	Patch string `json:"patch,omitempty"`
+	Minor string `json:"minor,omitempty"`
+	Major string `json:"major,omitempty"`
 }
 
 // compoundKey returns "stackName/serviceName" as the bbolt key.
@@ -93,6 +105,9 @@ func (s *ImageUpdateStore) GetAll() ([]ImageUpdateEntry, error) {
 // without full JSON unmarshal.
 var hasUpdateTrue = []byte(`"hasUpdate":true`)
 
+// releaseField pre-filters entries that carry a release classification.
+var releaseField = []byte(`"release":`)
+
 // StackHasUpdates returns a map of stack name → true if any service has an update.
 // Scans BoltDB directly (~0.5ms, memory-mapped). Uses byte-level pre-filter
 // to skip full JSON unmarshal for entries without updates.
@@ -158,6 +173,83 @@ func (s *ImageUpdateStore) Upsert(stackName, serviceName, imageRef, localDigest,
 	})
 }
 
+// SetRelease records the release classification of a checked service.
+// A nil release clears it. Does nothing if the service hasn't been checked.
+func (s *ImageUpdateStore) SetRelease(stackName, serviceName string, release *ReleaseUpdate) error {
+	key := compoundKey(stackName, serviceName)
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(db.BucketImageUpdates)
+		v := b.Get(key)
+		if v == nil {
+			return nil
+		}
+		var rec imageUpdateRecord
+		if err := json.Unmarshal(v, &rec); err != nil {
+			return fmt.Errorf("unmarshal image update: %w", err)
+		}
+		rec.Release = release
+		data, err := json.Marshal(&rec)
+		if err != nil {
+			return fmt.Errorf("marshal image update: %w", err)
+		}
+		return b.Put(key, data)
+	})
+}
+
+// AllReleases returns "stackName/serviceName" → release classification for
+// services with newer version tags available.
+func (s *ImageUpdateStore) AllReleases() (map[string]ReleaseUpdate, error) {
+	return s.releases(nil)
+}
+
+// ReleasesForStack returns service name → release classification for one stack.
+func (s *ImageUpdateStore) ReleasesForStack(stackName string) (map[string]ReleaseUpdate, error) {
+	return s.releases(stackPrefix(stackName))
+}
+
+// releases scans entries under prefix (nil = all) that carry a release.
+// Keys are trimmed of the prefix.
+func (s *ImageUpdateStore) releases(prefix []byte) (map[string]ReleaseUpdate, error) {
+	result := make(map[string]ReleaseUpdate)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(db.BucketImageUpdates).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			if !bytes.Contains(v, releaseField) {
+				continue
+			}
+			var rec imageUpdateRecord
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return fmt.Errorf("unmarshal image update: %w", err)
+			}
+			if rec.Release != nil {
+				result[string(k[len(prefix):])] = *rec.Release
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// RemoteDigest returns the registry digest recorded by the last check of a
+// service, or "" if it hasn't been checked.
+func (s *ImageUpdateStore) RemoteDigest(stackName, serviceName string) (string, error) {
+	var rec imageUpdateRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(db.BucketImageUpdates).Get(compoundKey(stackName, serviceName))
+		if v == nil {
+			return nil
+		}
+		return json.Unmarshal(v, &rec)
+	})
+	if err != nil {
+		return "", fmt.Errorf("get image update: %w", err)
+	}
+	return rec.RemoteDigest, nil
+}
+
 // DeleteForStack removes all cache entries for a stack.
 func (s *ImageUpdateStore) DeleteForStack(stackName string) error {
 	prefix := stackPrefix(stackName)
@@ -0,0 +1,144 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/cfilipov/dockge/internal/db"
+)
+
+// Operation is one compose action run against a stack (deploy, update,
+// start, ...), kept for later review.
+type Operation struct {
+	ID         int           `json:"id"`
+	StackName  string        `json:"stackName"`
+	Action     string        `json:"action"`
+	StartedAt  int64         `json:"startedAt"`  // Unix seconds
+	FinishedAt int64         `json:"finishedAt"` // 0 while running
+	Success    bool          `json:"success"`
+	Error      string        `json:"error,omitempty"`
+	Pull       *PullProgress `json:"pull,omitempty"` // image pulls done by the operation
+	Note       string        `json:"note,omitempty"` // why the change was made, given at deploy
+}
+
+// PullProgress aggregates image pull progress across all services of a
+// stack. Byte counts only cover layers whose size compose has reported.
+type PullProgress struct {
+	Images     int   `json:"images"`
+	Layers     int   `json:"layers"`
+	LayersDone int   `json:"layersDone"`
+	Current    int64 `json:"current"` // bytes
+	Total      int64 `json:"total"`   // bytes
+	Percent    int   `json:"percent"`
+}
+
+// OperationStore persists the operation history in BoltDB, keyed by a
+// sequence so entries iterate oldest first.
+type OperationStore struct {
+	db *bolt.DB
+}
+
+func NewOperationStore(database *bolt.DB) *OperationStore {
+	return &OperationStore{db: database}
+}
+
+// Create stores a new running operation and assigns its ID and start time.
+func (s *OperationStore) Create(op *Operation) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(db.BucketOperations)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return fmt.Errorf("next sequence: %w", err)
+		}
+		op.ID = int(seq)
+		op.StartedAt = time.Now().Unix()
+		return putOperation(b, op)
+	})
+	if err != nil {
+		return fmt.Errorf("create operation: %w", err)
+	}
+	return nil
+}
+
+// Update overwrites a stored operation, e.g. once it has finished.
+func (s *OperationStore) Update(op *Operation) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return putOperation(tx.Bucket(db.BucketOperations), op)
+	})
+	if err != nil {
+		return fmt.Errorf("update operation: %w", err)
+	}
+	return nil
+}
+
+func putOperation(b *bolt.Bucket, op *Operation) error {
+	data, err := json.Marshal(op)
+	if err != nil {
+		return fmt.Errorf("marshal operation: %w", err)
+	}
+	return b.Put(itob(uint64(op.ID)), data)
+}
+
+// List returns up to limit operations for a stack (all stacks if stackName
+// is ""), newest first. limit <= 0 means no limit.
+func (s *OperationStore) List(stackName string, limit int) ([]Operation, error) {
+	result := []Operation{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(db.BucketOperations).Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var op Operation
+			if err := json.Unmarshal(v, &op); err != nil {
+				return fmt.Errorf("unmarshal operation: %w", err)
+			}
+			if stackName != "" && op.StackName != stackName {
+				continue
+			}
+			result = append(result, op)
+			if limit > 0 && len(result) >= limit {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list operations: %w", err)
+	}
+	return result, nil
+}
+
+// Prune deletes finished operations that ended before the cutoff (Unix
+// seconds). Returns how many were removed and the bytes their records took.
+func (s *OperationStore) Prune(before int64) (removed int, freed int64, err error) {
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(db.BucketOperations)
+		var keys [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			var op Operation
+			if err := json.Unmarshal(v, &op); err != nil {
+				return fmt.Errorf("unmarshal operation: %w", err)
+			}
+			if op.FinishedAt != 0 && op.FinishedAt < before {
+				keys = append(keys, k)
+				freed += int64(len(k) + len(v))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		removed = len(keys)
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("prune operations: %w", err)
+	}
+	return removed, freed, nil
+}
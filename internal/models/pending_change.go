@@ -0,0 +1,203 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/cfilipov/dockge/internal/db"
+)
+
+// Pending change actions: what is applied once the change is approved.
+const (
+	PendingActionSave   = "save"
+	PendingActionDeploy = "deploy"
+)
+
+// Pending change states.
+const (
+	PendingStatusPending  = "pending"
+	PendingStatusApproved = "approved"
+	PendingStatusRejected = "rejected"
+)
+
+// ErrChangeNotPending is returned when resolving a change that was already
+// approved or rejected.
+var ErrChangeNotPending = errors.New("change is no longer pending")
+
+// PendingChange is a stack save/deploy submitted by an operator that waits
+// for an admin's approval before being written to disk.
+type PendingChange struct {
+	ID          int    `json:"id"`
+	StackName   string `json:"stackName"`
+	Action      string `json:"action"` // "save" or "deploy"
+	RequestedBy string `json:"requestedBy"`
+	RequesterID int    `json:"requesterID"`
+	RequestedAt int64  `json:"requestedAt"` // Unix seconds
+
+	ComposeYAML         string `json:"composeYAML"`
+	ComposeENV          string `json:"composeENV"`
+	ComposeOverrideYAML string `json:"composeOverrideYAML"`
+	Note                string `json:"note,omitempty"` // deploy note, recorded on approval
+
+	// BaseHash fingerprints the files on disk when the change was requested,
+	// so approval can refuse to clobber edits made in the meantime.
+	BaseHash string `json:"baseHash"`
+	Diff     string `json:"diff"` // unified diff against the files on disk
+
+	Status     string `json:"status"`
+	ReviewedBy string `json:"reviewedBy,omitempty"`
+	ReviewedAt int64  `json:"reviewedAt,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// PendingChangeStore persists pending stack changes in BoltDB.
+type PendingChangeStore struct {
+	db *bolt.DB
+}
+
+func NewPendingChangeStore(database *bolt.DB) *PendingChangeStore {
+	return &PendingChangeStore{db: database}
+}
+
+// Create stores a new pending change and assigns its ID, status, and
+// request time.
+func (s *PendingChangeStore) Create(pc *PendingChange) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(db.BucketPendingChanges)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return fmt.Errorf("next sequence: %w", err)
+		}
+		pc.ID = int(seq)
+		pc.Status = PendingStatusPending
+		pc.RequestedAt = time.Now().Unix()
+
+		data, err := json.Marshal(pc)
+		if err != nil {
+			return fmt.Errorf("marshal pending change: %w", err)
+		}
+		return b.Put(itob(seq), data)
+	})
+	if err != nil {
+		return fmt.Errorf("create pending change: %w", err)
+	}
+	return nil
+}
+
+// Get returns the change with the given ID, or nil if not found.
+func (s *PendingChangeStore) Get(id int) (*PendingChange, error) {
+	var pc *PendingChange
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(db.BucketPendingChanges).Get(itob(uint64(id)))
+		if v == nil {
+			return nil
+		}
+		pc = &PendingChange{}
+		return json.Unmarshal(v, pc)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get pending change: %w", err)
+	}
+	return pc, nil
+}
+
+// List returns changes with the given status (all if status is ""),
+// newest first.
+func (s *PendingChangeStore) List(status string) ([]PendingChange, error) {
+	result := []PendingChange{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(db.BucketPendingChanges).ForEach(func(_, v []byte) error {
+			var pc PendingChange
+			if err := json.Unmarshal(v, &pc); err != nil {
+				return fmt.Errorf("unmarshal pending change: %w", err)
+			}
+			if status == "" || pc.Status == status {
+				result = append(result, pc)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list pending changes: %w", err)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID > result[j].ID })
+	return result, nil
+}
+
+// Resolve marks a pending change as approved or rejected. Returns
+// ErrChangeNotPending if it was already resolved, so two admins acting at
+// once can't both apply it.
+func (s *PendingChangeStore) Resolve(id int, status, reviewer, reason string) (*PendingChange, error) {
+	if status != PendingStatusApproved && status != PendingStatusRejected {
+		return nil, fmt.Errorf("invalid status %q", status)
+	}
+	var pc PendingChange
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(db.BucketPendingChanges)
+		key := itob(uint64(id))
+		v := b.Get(key)
+		if v == nil {
+			return fmt.Errorf("pending change %d not found", id)
+		}
+		if err := json.Unmarshal(v, &pc); err != nil {
+			return fmt.Errorf("unmarshal pending change: %w", err)
+		}
+		if pc.Status != PendingStatusPending {
+			return ErrChangeNotPending
+		}
+		pc.Status = status
+		pc.ReviewedBy = reviewer
+		pc.ReviewedAt = time.Now().Unix()
+		pc.Reason = reason
+
+		data, err := json.Marshal(&pc)
+		if err != nil {
+			return fmt.Errorf("marshal pending change: %w", err)
+		}
+		return b.Put(key, data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pc, nil
+}
+
+// PruneResolved deletes approved and rejected changes reviewed before the
+// cutoff (Unix seconds). Pending changes are always kept. Returns how many
+// were removed and the bytes their records took.
+func (s *PendingChangeStore) PruneResolved(before int64) (removed int, freed int64, err error) {
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(db.BucketPendingChanges)
+		var keys [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			var pc PendingChange
+			if err := json.Unmarshal(v, &pc); err != nil {
+				return fmt.Errorf("unmarshal pending change: %w", err)
+			}
+			if pc.Status != PendingStatusPending && pc.ReviewedAt < before {
+				keys = append(keys, k)
+				freed += int64(len(k) + len(v))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		removed = len(keys)
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("prune pending changes: %w", err)
+	}
+	return removed, freed, nil
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/cfilipov/dockge/internal/db"
+)
+
+// ArchivedStack marks a stack whose compose files are kept but which is
+// hidden from the stack list, skipped by update checks and can't be started.
+type ArchivedStack struct {
+	StackName  string `json:"stackName"`
+	ArchivedBy string `json:"archivedBy,omitempty"`
+	ArchivedAt int64  `json:"archivedAt"` // Unix seconds
+}
+
+// StackArchiveStore persists archived stacks in BoltDB, keyed by stack name.
+type StackArchiveStore struct {
+	db *bolt.DB
+}
+
+func NewStackArchiveStore(database *bolt.DB) *StackArchiveStore {
+	return &StackArchiveStore{db: database}
+}
+
+// Archive marks a stack archived and stamps the archive time.
+func (s *StackArchiveStore) Archive(a ArchivedStack) error {
+	a.ArchivedAt = time.Now().Unix()
+	data, err := json.Marshal(&a)
+	if err != nil {
+		return fmt.Errorf("marshal archived stack: %w", err)
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(db.BucketArchivedStacks).Put([]byte(a.StackName), data)
+	})
+	if err != nil {
+		return fmt.Errorf("archive stack: %w", err)
+	}
+	return nil
+}
+
+// Unarchive clears a stack's archived mark. Unarchiving a stack that isn't
+// archived is a no-op.
+func (s *StackArchiveStore) Unarchive(stackName string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(db.BucketArchivedStacks).Delete([]byte(stackName))
+	})
+	if err != nil {
+		return fmt.Errorf("unarchive stack: %w", err)
+	}
+	return nil
+}
+
+// Get returns a stack's archive record, or nil if it isn't archived.
+func (s *StackArchiveStore) Get(stackName string) (*ArchivedStack, error) {
+	var a *ArchivedStack
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(db.BucketArchivedStacks).Get([]byte(stackName))
+		if v == nil {
+			return nil
+		}
+		a = &ArchivedStack{}
+		return json.Unmarshal(v, a)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get archived stack: %w", err)
+	}
+	return a, nil
+}
+
+// Names returns the set of archived stack names.
+func (s *StackArchiveStore) Names() (map[string]bool, error) {
+	names := make(map[string]bool)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(db.BucketArchivedStacks).ForEach(func(k, _ []byte) error {
+			names[string(k)] = true
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list archived stacks: %w", err)
+	}
+	return names, nil
+}
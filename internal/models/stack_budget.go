@@ -0,0 +1,87 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/cfilipov/dockge/internal/db"
+)
+
+// StackBudget is a resource budget for a stack: the most memory and CPU its
+// running containers should use together. Zero means no limit.
+type StackBudget struct {
+	StackName string  `json:"stackName"`
+	MaxMemory uint64  `json:"maxMemory"` // bytes
+	MaxCPUs   float64 `json:"maxCPUs"`   // cores, e.g. 1.5
+}
+
+// StackBudgetStore persists stack budgets in BoltDB, keyed by stack name.
+type StackBudgetStore struct {
+	db *bolt.DB
+}
+
+func NewStackBudgetStore(database *bolt.DB) *StackBudgetStore {
+	return &StackBudgetStore{db: database}
+}
+
+// Get returns the budget for a stack, or nil if none is set.
+func (s *StackBudgetStore) Get(stackName string) (*StackBudget, error) {
+	var b *StackBudget
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(db.BucketStackBudgets).Get([]byte(stackName))
+		if v == nil {
+			return nil
+		}
+		b = &StackBudget{}
+		return json.Unmarshal(v, b)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get stack budget: %w", err)
+	}
+	return b, nil
+}
+
+// List returns all budgets ordered by stack name.
+func (s *StackBudgetStore) List() ([]StackBudget, error) {
+	result := []StackBudget{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(db.BucketStackBudgets).ForEach(func(_, v []byte) error {
+			var b StackBudget
+			if err := json.Unmarshal(v, &b); err != nil {
+				return fmt.Errorf("unmarshal stack budget: %w", err)
+			}
+			result = append(result, b)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list stack budgets: %w", err)
+	}
+	return result, nil
+}
+
+// Set stores a budget. A budget with no limits deletes the entry.
+func (s *StackBudgetStore) Set(b StackBudget) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(db.BucketStackBudgets)
+		if b.MaxMemory == 0 && b.MaxCPUs == 0 {
+			return bucket.Delete([]byte(b.StackName))
+		}
+		data, err := json.Marshal(&b)
+		if err != nil {
+			return fmt.Errorf("marshal stack budget: %w", err)
+		}
+		return bucket.Put([]byte(b.StackName), data)
+	})
+	if err != nil {
+		return fmt.Errorf("set stack budget: %w", err)
+	}
+	return nil
+}
+
+// Exceeded reports whether usage is over any limit of the budget.
+func (b *StackBudget) Exceeded(cpus float64, memory uint64) bool {
+	return (b.MaxCPUs > 0 && cpus > b.MaxCPUs) || (b.MaxMemory > 0 && memory > b.MaxMemory)
+}
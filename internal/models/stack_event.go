@@ -0,0 +1,124 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/cfilipov/dockge/internal/db"
+)
+
+// StackEventHours is how many hours of stack events are kept.
+const StackEventHours = 24
+
+// StackEventKind is a kind of container event counted per stack.
+type StackEventKind int
+
+const (
+	StackEventRestart    StackEventKind = iota // a container restarted, by policy or by hand
+	StackEventHealthFlap                       // a container became unhealthy
+)
+
+// StackEventHour is the number of events of a stack in one hour.
+type StackEventHour struct {
+	Hour        int64 `json:"hour"` // Unix seconds at the start of the hour
+	Restarts    int   `json:"restarts"`
+	HealthFlaps int   `json:"healthFlaps"`
+}
+
+// StackEventStore persists hourly counts of container events per stack in
+// BoltDB, keyed by stack name. Only the last StackEventHours hours are kept.
+type StackEventStore struct {
+	db *bolt.DB
+}
+
+func NewStackEventStore(database *bolt.DB) *StackEventStore {
+	return &StackEventStore{db: database}
+}
+
+// Record counts an event of a stack at t.
+func (s *StackEventStore) Record(stackName string, kind StackEventKind, t time.Time) error {
+	hour := t.Truncate(time.Hour).Unix()
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(db.BucketStackEvents)
+		var hours []StackEventHour
+		if v := bucket.Get([]byte(stackName)); v != nil {
+			if err := json.Unmarshal(v, &hours); err != nil {
+				return fmt.Errorf("unmarshal stack events: %w", err)
+			}
+		}
+
+		// Drop hours that fell out of the window
+		oldest := hour - (StackEventHours-1)*3600
+		kept := hours[:0]
+		for _, h := range hours {
+			if h.Hour >= oldest {
+				kept = append(kept, h)
+			}
+		}
+		hours = kept
+		if len(hours) == 0 || hours[len(hours)-1].Hour != hour {
+			hours = append(hours, StackEventHour{Hour: hour})
+		}
+		switch kind {
+		case StackEventRestart:
+			hours[len(hours)-1].Restarts++
+		case StackEventHealthFlap:
+			hours[len(hours)-1].HealthFlaps++
+		}
+
+		data, err := json.Marshal(hours)
+		if err != nil {
+			return fmt.Errorf("marshal stack events: %w", err)
+		}
+		return bucket.Put([]byte(stackName), data)
+	})
+	if err != nil {
+		return fmt.Errorf("record stack event: %w", err)
+	}
+	return nil
+}
+
+// Histogram returns a stack's event counts for each of the last
+// StackEventHours hours up to now, oldest first. Hours without events are
+// included with zero counts.
+func (s *StackEventStore) Histogram(stackName string, now time.Time) ([]StackEventHour, error) {
+	var stored []StackEventHour
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(db.BucketStackEvents).Get([]byte(stackName))
+		if v == nil {
+			return nil
+		}
+		return json.Unmarshal(v, &stored)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get stack events: %w", err)
+	}
+
+	current := now.Truncate(time.Hour).Unix()
+	hours := make([]StackEventHour, StackEventHours)
+	for i := range hours {
+		hours[i].Hour = current - int64(StackEventHours-1-i)*3600
+	}
+	for _, h := range stored {
+		i := StackEventHours - 1 - int((current-h.Hour)/3600)
+		if i >= 0 && i < StackEventHours && hours[i].Hour == h.Hour {
+			hours[i].Restarts = h.Restarts
+			hours[i].HealthFlaps = h.HealthFlaps
+		}
+	}
+	return hours, nil
+}
+
+// Delete removes a stack's events.
+func (s *StackEventStore) Delete(stackName string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(db.BucketStackEvents).Delete([]byte(stackName))
+	})
+	if err != nil {
+		return fmt.Errorf("delete stack events: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/cfilipov/dockge/internal/db"
+)
+
+// ErrStackGroupNotFound is returned when a group was deleted meanwhile.
+var ErrStackGroupNotFound = errors.New("stack group not found")
+
+// StackGroup is a named folder of stacks in the stack list. A stack is in
+// at most one group.
+type StackGroup struct {
+	ID     int      `json:"id"`
+	Name   string   `json:"name"`
+	Stacks []string `json:"stacks"` // sorted
+	Order  int      `json:"order"`  // position in the stack list, ascending
+}
+
+// StackGroupStore persists stack groups in BoltDB, keyed by a sequence.
+type StackGroupStore struct {
+	db *bolt.DB
+}
+
+func NewStackGroupStore(database *bolt.DB) *StackGroupStore {
+	return &StackGroupStore{db: database}
+}
+
+// Create stores a new empty group after the existing ones and assigns its
+// ID.
+func (s *StackGroupStore) Create(name string) (*StackGroup, error) {
+	g := &StackGroup{Name: name, Stacks: []string{}}
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(db.BucketStackGroups)
+		groups, err := readStackGroups(b)
+		if err != nil {
+			return err
+		}
+		for _, other := range groups {
+			g.Order = max(g.Order, other.Order+1)
+		}
+		seq, err := b.NextSequence()
+		if err != nil {
+			return fmt.Errorf("next sequence: %w", err)
+		}
+		g.ID = int(seq)
+		return putStackGroup(b, g)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create stack group: %w", err)
+	}
+	return g, nil
+}
+
+// Update renames a group and replaces its stacks. Stacks that were in
+// another group are moved out of it.
+func (s *StackGroupStore) Update(id int, name string, stacks []string) error {
+	stacks = slices.Clone(stacks)
+	sort.Strings(stacks)
+	stacks = slices.Compact(stacks)
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(db.BucketStackGroups)
+		groups, err := readStackGroups(b)
+		if err != nil {
+			return err
+		}
+		i := slices.IndexFunc(groups, func(g StackGroup) bool { return g.ID == id })
+		if i < 0 {
+			return ErrStackGroupNotFound
+		}
+		for _, g := range groups {
+			if g.ID == id {
+				g.Name, g.Stacks = name, stacks
+			} else {
+				kept := slices.DeleteFunc(slices.Clone(g.Stacks), func(name string) bool {
+					_, found := slices.BinarySearch(stacks, name)
+					return found
+				})
+				if len(kept) == len(g.Stacks) {
+					continue
+				}
+				g.Stacks = kept
+			}
+			if err := putStackGroup(b, &g); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("update stack group: %w", err)
+	}
+	return nil
+}
+
+// Reorder sets the display order to the order of ids. Groups missing from
+// ids keep their relative order after the listed ones.
+func (s *StackGroupStore) Reorder(ids []int) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(db.BucketStackGroups)
+		groups, err := readStackGroups(b)
+		if err != nil {
+			return err
+		}
+		sort.SliceStable(groups, func(i, j int) bool {
+			pi, pj := slices.Index(ids, groups[i].ID), slices.Index(ids, groups[j].ID)
+			if pi < 0 || pj < 0 {
+				return pi >= 0 && pj < 0
+			}
+			return pi < pj
+		})
+		for i := range groups {
+			groups[i].Order = i
+			if err := putStackGroup(b, &groups[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("reorder stack groups: %w", err)
+	}
+	return nil
+}
+
+// Get returns a group by ID, or nil if it doesn't exist.
+func (s *StackGroupStore) Get(id int) (*StackGroup, error) {
+	var g *StackGroup
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(db.BucketStackGroups).Get(itob(uint64(id)))
+		if v == nil {
+			return nil
+		}
+		g = &StackGroup{}
+		return json.Unmarshal(v, g)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get stack group: %w", err)
+	}
+	return g, nil
+}
+
+// List returns all groups in display order.
+func (s *StackGroupStore) List() ([]StackGroup, error) {
+	var groups []StackGroup
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		groups, err = readStackGroups(tx.Bucket(db.BucketStackGroups))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list stack groups: %w", err)
+	}
+	return groups, nil
+}
+
+// Delete removes a group. Its stacks become ungrouped.
+func (s *StackGroupStore) Delete(id int) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(db.BucketStackGroups).Delete(itob(uint64(id)))
+	})
+	if err != nil {
+		return fmt.Errorf("delete stack group: %w", err)
+	}
+	return nil
+}
+
+// RemoveStack takes a stack out of its group, e.g. when it is deleted.
+// It reports whether the stack was in a group.
+func (s *StackGroupStore) RemoveStack(stackName string) (bool, error) {
+	removed := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(db.BucketStackGroups)
+		groups, err := readStackGroups(b)
+		if err != nil {
+			return err
+		}
+		for _, g := range groups {
+			if i := slices.Index(g.Stacks, stackName); i >= 0 {
+				g.Stacks = slices.Delete(g.Stacks, i, i+1)
+				removed = true
+				if err := putStackGroup(b, &g); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("remove stack from groups: %w", err)
+	}
+	return removed, nil
+}
+
+// readStackGroups returns the groups of b in display order.
+func readStackGroups(b *bolt.Bucket) ([]StackGroup, error) {
+	groups := []StackGroup{}
+	err := b.ForEach(func(_, v []byte) error {
+		var g StackGroup
+		if err := json.Unmarshal(v, &g); err != nil {
+			return fmt.Errorf("unmarshal stack group: %w", err)
+		}
+		if g.Stacks == nil {
+			g.Stacks = []string{}
+		}
+		groups = append(groups, g)
+		return nil
+	})
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].Order < groups[j].Order })
+	return groups, err
+}
+
+func putStackGroup(b *bolt.Bucket, g *StackGroup) error {
+	data, err := json.Marshal(g)
+	if err != nil {
+		return fmt.Errorf("marshal stack group: %w", err)
+	}
+	return b.Put(itob(uint64(g.ID)), data)
+}
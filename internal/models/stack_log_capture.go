@@ -0,0 +1,94 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/cfilipov/dockge/internal/db"
+)
+
+// StackLogCapture marks a stack whose container logs are written to disk,
+// so they're kept when its containers are recreated. Stacks without one
+// aren't captured.
+type StackLogCapture struct {
+	StackName string   `json:"stackName"`
+	Services  []string `json:"services,omitempty"` // services captured, none for all
+	EnabledBy string   `json:"enabledBy,omitempty"`
+	EnabledAt int64    `json:"enabledAt"` // Unix seconds
+}
+
+// StackLogCaptureStore persists the stacks with log capture in BoltDB,
+// keyed by stack name.
+type StackLogCaptureStore struct {
+	db *bolt.DB
+}
+
+func NewStackLogCaptureStore(database *bolt.DB) *StackLogCaptureStore {
+	return &StackLogCaptureStore{db: database}
+}
+
+// Get returns the log capture of a stack, or nil if it's off.
+func (s *StackLogCaptureStore) Get(stackName string) (*StackLogCapture, error) {
+	var lc *StackLogCapture
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(db.BucketLogCapture).Get([]byte(stackName))
+		if v == nil {
+			return nil
+		}
+		lc = &StackLogCapture{}
+		return json.Unmarshal(v, lc)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get log capture: %w", err)
+	}
+	return lc, nil
+}
+
+// List returns the stacks with log capture on.
+func (s *StackLogCaptureStore) List() ([]StackLogCapture, error) {
+	var list []StackLogCapture
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(db.BucketLogCapture).ForEach(func(_, v []byte) error {
+			var lc StackLogCapture
+			if err := json.Unmarshal(v, &lc); err != nil {
+				return err
+			}
+			list = append(list, lc)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list log capture: %w", err)
+	}
+	return list, nil
+}
+
+// Enable turns log capture on for a stack and stamps the time.
+func (s *StackLogCaptureStore) Enable(lc StackLogCapture) error {
+	lc.EnabledAt = time.Now().Unix()
+	data, err := json.Marshal(&lc)
+	if err != nil {
+		return fmt.Errorf("marshal log capture: %w", err)
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(db.BucketLogCapture).Put([]byte(lc.StackName), data)
+	})
+	if err != nil {
+		return fmt.Errorf("enable log capture: %w", err)
+	}
+	return nil
+}
+
+// Disable turns log capture off for a stack. Files already written stay.
+func (s *StackLogCaptureStore) Disable(stackName string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(db.BucketLogCapture).Delete([]byte(stackName))
+	})
+	if err != nil {
+		return fmt.Errorf("disable log capture: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/cfilipov/dockge/internal/db"
+)
+
+// StackNote is a free-form note attached to a stack, shown in stack detail.
+type StackNote struct {
+	StackName string `json:"stackName"`
+	Text      string `json:"text"`
+	UpdatedBy string `json:"updatedBy,omitempty"`
+	UpdatedAt int64  `json:"updatedAt"` // Unix seconds
+}
+
+// StackNoteStore persists stack notes in BoltDB, keyed by stack name.
+type StackNoteStore struct {
+	db *bolt.DB
+}
+
+func NewStackNoteStore(database *bolt.DB) *StackNoteStore {
+	return &StackNoteStore{db: database}
+}
+
+// Get returns the note for a stack, or nil if none is set.
+func (s *StackNoteStore) Get(stackName string) (*StackNote, error) {
+	var n *StackNote
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(db.BucketStackNotes).Get([]byte(stackName))
+		if v == nil {
+			return nil
+		}
+		n = &StackNote{}
+		return json.Unmarshal(v, n)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get stack note: %w", err)
+	}
+	return n, nil
+}
+
+// Set stores a note and stamps its update time. Blank text deletes it.
+func (s *StackNoteStore) Set(n StackNote) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(db.BucketStackNotes)
+		if strings.TrimSpace(n.Text) == "" {
+			return bucket.Delete([]byte(n.StackName))
+		}
+		n.UpdatedAt = time.Now().Unix()
+		data, err := json.Marshal(&n)
+		if err != nil {
+			return fmt.Errorf("marshal stack note: %w", err)
+		}
+		return bucket.Put([]byte(n.StackName), data)
+	})
+	if err != nil {
+		return fmt.Errorf("set stack note: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a stack's note.
+func (s *StackNoteStore) Delete(stackName string) error {
+	return s.Set(StackNote{StackName: stackName})
+}
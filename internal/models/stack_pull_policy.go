@@ -0,0 +1,73 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/cfilipov/dockge/internal/db"
+)
+
+// StackPullPolicy is the image pull policy a stack's starts and deploys
+// use when the user doesn't pick one (compose up --pull).
+type StackPullPolicy struct {
+	StackName string `json:"stackName"`
+	Policy    string `json:"policy"` // always, missing or never
+	UpdatedBy string `json:"updatedBy,omitempty"`
+	UpdatedAt int64  `json:"updatedAt"` // Unix seconds
+}
+
+// StackPullPolicyStore persists per-stack pull policies in BoltDB, keyed
+// by stack name.
+type StackPullPolicyStore struct {
+	db *bolt.DB
+}
+
+func NewStackPullPolicyStore(database *bolt.DB) *StackPullPolicyStore {
+	return &StackPullPolicyStore{db: database}
+}
+
+// Get returns the pull policy of a stack, or nil if it has none.
+func (s *StackPullPolicyStore) Get(stackName string) (*StackPullPolicy, error) {
+	var p *StackPullPolicy
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(db.BucketPullPolicy).Get([]byte(stackName))
+		if v == nil {
+			return nil
+		}
+		p = &StackPullPolicy{}
+		return json.Unmarshal(v, p)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get pull policy: %w", err)
+	}
+	return p, nil
+}
+
+// Set stores a stack's pull policy and stamps its update time. An empty
+// policy deletes it.
+func (s *StackPullPolicyStore) Set(p StackPullPolicy) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(db.BucketPullPolicy)
+		if p.Policy == "" {
+			return bucket.Delete([]byte(p.StackName))
+		}
+		p.UpdatedAt = time.Now().Unix()
+		data, err := json.Marshal(&p)
+		if err != nil {
+			return fmt.Errorf("marshal pull policy: %w", err)
+		}
+		return bucket.Put([]byte(p.StackName), data)
+	})
+	if err != nil {
+		return fmt.Errorf("set pull policy: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a stack's pull policy.
+func (s *StackPullPolicyStore) Delete(stackName string) error {
+	return s.Set(StackPullPolicy{StackName: stackName})
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/cfilipov/dockge/internal/db"
+)
+
+// StackSchedule runs a compose action on a stack whenever its cron
+// expression matches.
+type StackSchedule struct {
+	ID        int    `json:"id"`
+	StackName string `json:"stackName"`
+	Action    string `json:"action"` // restart, update, start, stop, down
+	Cron      string `json:"cron"`   // five-field cron expression or @daily style macro
+	Enabled   bool   `json:"enabled"`
+	CreatedBy string `json:"createdBy,omitempty"`
+	CreatedAt int64  `json:"createdAt"`           // Unix seconds
+	LastRun   int64  `json:"lastRun"`             // Unix seconds, 0 = never run
+	LastError string `json:"lastError,omitempty"` // error of the last run, "" on success
+}
+
+// StackScheduleStore persists stack schedules in BoltDB, keyed by a sequence.
+type StackScheduleStore struct {
+	db *bolt.DB
+}
+
+func NewStackScheduleStore(database *bolt.DB) *StackScheduleStore {
+	return &StackScheduleStore{db: database}
+}
+
+// Create stores a new schedule and assigns its ID and creation time.
+func (s *StackScheduleStore) Create(sch *StackSchedule) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(db.BucketStackSchedules)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return fmt.Errorf("next sequence: %w", err)
+		}
+		sch.ID = int(seq)
+		sch.CreatedAt = time.Now().Unix()
+		return putStackSchedule(b, sch)
+	})
+	if err != nil {
+		return fmt.Errorf("create stack schedule: %w", err)
+	}
+	return nil
+}
+
+// Update overwrites a stored schedule. It fails if the schedule was deleted.
+func (s *StackScheduleStore) Update(sch *StackSchedule) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(db.BucketStackSchedules)
+		if b.Get(itob(uint64(sch.ID))) == nil {
+			return fmt.Errorf("schedule %d not found", sch.ID)
+		}
+		return putStackSchedule(b, sch)
+	})
+	if err != nil {
+		return fmt.Errorf("update stack schedule: %w", err)
+	}
+	return nil
+}
+
+func putStackSchedule(b *bolt.Bucket, sch *StackSchedule) error {
+	data, err := json.Marshal(sch)
+	if err != nil {
+		return fmt.Errorf("marshal stack schedule: %w", err)
+	}
+	return b.Put(itob(uint64(sch.ID)), data)
+}
+
+// Get returns a schedule by ID, or nil if it doesn't exist.
+func (s *StackScheduleStore) Get(id int) (*StackSchedule, error) {
+	var sch *StackSchedule
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(db.BucketStackSchedules).Get(itob(uint64(id)))
+		if v == nil {
+			return nil
+		}
+		sch = &StackSchedule{}
+		return json.Unmarshal(v, sch)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get stack schedule: %w", err)
+	}
+	return sch, nil
+}
+
+// List returns all schedules in creation order.
+func (s *StackScheduleStore) List() ([]StackSchedule, error) {
+	result := []StackSchedule{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(db.BucketStackSchedules).ForEach(func(_, v []byte) error {
+			var sch StackSchedule
+			if err := json.Unmarshal(v, &sch); err != nil {
+				return fmt.Errorf("unmarshal stack schedule: %w", err)
+			}
+			result = append(result, sch)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list stack schedules: %w", err)
+	}
+	return result, nil
+}
+
+// Delete removes a schedule.
+func (s *StackScheduleStore) Delete(id int) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(db.BucketStackSchedules).Delete(itob(uint64(id)))
+	})
+	if err != nil {
+		return fmt.Errorf("delete stack schedule: %w", err)
+	}
+	return nil
+}
+
+// DeleteForStack removes all schedules of a stack, e.g. when it is deleted.
+func (s *StackScheduleStore) DeleteForStack(stackName string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(db.BucketStackSchedules)
+		var keys [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			var sch StackSchedule
+			if err := json.Unmarshal(v, &sch); err != nil {
+				return fmt.Errorf("unmarshal stack schedule: %w", err)
+			}
+			if sch.StackName == stackName {
+				keys = append(keys, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("delete stack schedules: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/cfilipov/dockge/internal/db"
+)
+
+// StackServiceSelection is the subset of a stack's services last started
+// on their own, offered again for the next partial start.
+type StackServiceSelection struct {
+	StackName string   `json:"stackName"`
+	Services  []string `json:"services"`
+	UpdatedBy string   `json:"updatedBy,omitempty"`
+	UpdatedAt int64    `json:"updatedAt"` // Unix seconds
+}
+
+// StackServiceSelectionStore persists per-stack service selections in
+// BoltDB, keyed by stack name.
+type StackServiceSelectionStore struct {
+	db *bolt.DB
+}
+
+func NewStackServiceSelectionStore(database *bolt.DB) *StackServiceSelectionStore {
+	return &StackServiceSelectionStore{db: database}
+}
+
+// Get returns the service selection of a stack, or nil if it has none.
+func (s *StackServiceSelectionStore) Get(stackName string) (*StackServiceSelection, error) {
+	var sel *StackServiceSelection
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(db.BucketServiceSelection).Get([]byte(stackName))
+		if v == nil {
+			return nil
+		}
+		sel = &StackServiceSelection{}
+		return json.Unmarshal(v, sel)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get service selection: %w", err)
+	}
+	return sel, nil
+}
+
+// Set stores a stack's service selection and stamps its update time. An
+// empty selection deletes it.
+func (s *StackServiceSelectionStore) Set(sel StackServiceSelection) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(db.BucketServiceSelection)
+		if len(sel.Services) == 0 {
+			return bucket.Delete([]byte(sel.StackName))
+		}
+		sel.UpdatedAt = time.Now().Unix()
+		data, err := json.Marshal(&sel)
+		if err != nil {
+			return fmt.Errorf("marshal service selection: %w", err)
+		}
+		return bucket.Put([]byte(sel.StackName), data)
+	})
+	if err != nil {
+		return fmt.Errorf("set service selection: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a stack's service selection.
+func (s *StackServiceSelectionStore) Delete(stackName string) error {
+	return s.Set(StackServiceSelection{StackName: stackName})
+}
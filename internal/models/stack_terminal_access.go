@@ -0,0 +1,107 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/cfilipov/dockge/internal/db"
+)
+
+// StackTerminalAccess restricts exec terminals in a stack's containers to
+// admins and the listed users. Stacks without one are unrestricted, so
+// any user who can manage the stack can also open shells in it.
+type StackTerminalAccess struct {
+	StackName string   `json:"stackName"`
+	Usernames []string `json:"usernames"`
+	UpdatedBy string   `json:"updatedBy,omitempty"`
+	UpdatedAt int64    `json:"updatedAt"` // Unix seconds
+}
+
+// Allows reports whether user may open terminals under this restriction.
+func (a *StackTerminalAccess) Allows(user *User) bool {
+	if a == nil || user.IsAdmin() {
+		return true
+	}
+	return slices.Contains(a.Usernames, user.Username)
+}
+
+// StackTerminalAccessStore persists terminal restrictions in BoltDB, keyed
+// by stack name.
+type StackTerminalAccessStore struct {
+	db *bolt.DB
+}
+
+func NewStackTerminalAccessStore(database *bolt.DB) *StackTerminalAccessStore {
+	return &StackTerminalAccessStore{db: database}
+}
+
+// Get returns the restriction of a stack, or nil if it has none.
+func (s *StackTerminalAccessStore) Get(stackName string) (*StackTerminalAccess, error) {
+	var a *StackTerminalAccess
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(db.BucketTerminalAccess).Get([]byte(stackName))
+		if v == nil {
+			return nil
+		}
+		a = &StackTerminalAccess{}
+		return json.Unmarshal(v, a)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get terminal access: %w", err)
+	}
+	return a, nil
+}
+
+// List returns all restrictions.
+func (s *StackTerminalAccessStore) List() ([]StackTerminalAccess, error) {
+	var list []StackTerminalAccess
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(db.BucketTerminalAccess).ForEach(func(_, v []byte) error {
+			var a StackTerminalAccess
+			if err := json.Unmarshal(v, &a); err != nil {
+				return err
+			}
+			list = append(list, a)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list terminal access: %w", err)
+	}
+	return list, nil
+}
+
+// Set restricts a stack's terminals and stamps the update time. An empty
+// user list still restricts them to admins.
+func (s *StackTerminalAccessStore) Set(a StackTerminalAccess) error {
+	a.UpdatedAt = time.Now().Unix()
+	if a.Usernames == nil {
+		a.Usernames = []string{}
+	}
+	data, err := json.Marshal(&a)
+	if err != nil {
+		return fmt.Errorf("marshal terminal access: %w", err)
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(db.BucketTerminalAccess).Put([]byte(a.StackName), data)
+	})
+	if err != nil {
+		return fmt.Errorf("set terminal access: %w", err)
+	}
+	return nil
+}
+
+// Delete lifts a stack's restriction.
+func (s *StackTerminalAccessStore) Delete(stackName string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(db.BucketTerminalAccess).Delete([]byte(stackName))
+	})
+	if err != nil {
+		return fmt.Errorf("delete terminal access: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/cfilipov/dockge/internal/db"
+)
+
+// StackTerminalEnv overrides the terminal settings of exec terminals in a
+// stack's containers. Empty fields fall back to the global settings.
+type StackTerminalEnv struct {
+	StackName string   `json:"stackName"`
+	Term      string   `json:"term"`
+	Lang      string   `json:"lang"`
+	Shells    []string `json:"shells"` // tried in order, first found is used
+	UpdatedBy string   `json:"updatedBy,omitempty"`
+	UpdatedAt int64    `json:"updatedAt"` // Unix seconds
+}
+
+// IsEmpty reports whether the override changes nothing.
+func (e *StackTerminalEnv) IsEmpty() bool {
+	return e.Term == "" && e.Lang == "" && len(e.Shells) == 0
+}
+
+// StackTerminalEnvStore persists terminal overrides in BoltDB, keyed by
+// stack name.
+type StackTerminalEnvStore struct {
+	db *bolt.DB
+}
+
+func NewStackTerminalEnvStore(database *bolt.DB) *StackTerminalEnvStore {
+	return &StackTerminalEnvStore{db: database}
+}
+
+// Get returns the override of a stack, or nil if it has none.
+func (s *StackTerminalEnvStore) Get(stackName string) (*StackTerminalEnv, error) {
+	var e *StackTerminalEnv
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(db.BucketTerminalEnv).Get([]byte(stackName))
+		if v == nil {
+			return nil
+		}
+		e = &StackTerminalEnv{}
+		return json.Unmarshal(v, e)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get terminal env: %w", err)
+	}
+	return e, nil
+}
+
+// Set stores an override and stamps its update time. An empty override
+// deletes it.
+func (s *StackTerminalEnvStore) Set(e StackTerminalEnv) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(db.BucketTerminalEnv)
+		if e.IsEmpty() {
+			return bucket.Delete([]byte(e.StackName))
+		}
+		e.UpdatedAt = time.Now().Unix()
+		data, err := json.Marshal(&e)
+		if err != nil {
+			return fmt.Errorf("marshal terminal env: %w", err)
+		}
+		return bucket.Put([]byte(e.StackName), data)
+	})
+	if err != nil {
+		return fmt.Errorf("set terminal env: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a stack's override.
+func (s *StackTerminalEnvStore) Delete(stackName string) error {
+	return s.Set(StackTerminalEnv{StackName: stackName})
+}
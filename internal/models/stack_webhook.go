@@ -0,0 +1,158 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/cfilipov/dockge/internal/db"
+)
+
+// StackWebhook is an outbound webhook of a stack: a URL that's posted to
+// when one of its events happens to the stack.
+type StackWebhook struct {
+	ID         int      `json:"id"`
+	StackName  string   `json:"stackName"`
+	URL        string   `json:"url"`
+	Secret     string   `json:"secret,omitempty"`   // HMAC key of the signature, "" = unsigned
+	Events     []string `json:"events"`             // deployed, updated, stopped, failed
+	Template   string   `json:"template,omitempty"` // text/template of the payload, "" = default payload
+	Enabled    bool     `json:"enabled"`
+	CreatedBy  string   `json:"createdBy,omitempty"`
+	CreatedAt  int64    `json:"createdAt"`            // Unix seconds
+	LastSent   int64    `json:"lastSent"`             // Unix seconds, 0 = never sent
+	LastStatus string   `json:"lastStatus,omitempty"` // event of the last delivery
+	LastError  string   `json:"lastError,omitempty"`  // error of the last delivery, "" on success
+}
+
+// StackWebhookStore persists stack webhooks in BoltDB, keyed by a sequence.
+type StackWebhookStore struct {
+	db *bolt.DB
+}
+
+func NewStackWebhookStore(database *bolt.DB) *StackWebhookStore {
+	return &StackWebhookStore{db: database}
+}
+
+// Create stores a new webhook and assigns its ID and creation time.
+func (s *StackWebhookStore) Create(wh *StackWebhook) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(db.BucketStackWebhooks)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return fmt.Errorf("next sequence: %w", err)
+		}
+		wh.ID = int(seq)
+		wh.CreatedAt = time.Now().Unix()
+		return putStackWebhook(b, wh)
+	})
+	if err != nil {
+		return fmt.Errorf("create stack webhook: %w", err)
+	}
+	return nil
+}
+
+// Update overwrites a stored webhook. It fails if the webhook was deleted.
+func (s *StackWebhookStore) Update(wh *StackWebhook) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(db.BucketStackWebhooks)
+		if b.Get(itob(uint64(wh.ID))) == nil {
+			return fmt.Errorf("webhook %d not found", wh.ID)
+		}
+		return putStackWebhook(b, wh)
+	})
+	if err != nil {
+		return fmt.Errorf("update stack webhook: %w", err)
+	}
+	return nil
+}
+
+func putStackWebhook(b *bolt.Bucket, wh *StackWebhook) error {
+	data, err := json.Marshal(wh)
+	if err != nil {
+		return fmt.Errorf("marshal stack webhook: %w", err)
+	}
+	return b.Put(itob(uint64(wh.ID)), data)
+}
+
+// Get returns a webhook by ID, or nil if it doesn't exist.
+func (s *StackWebhookStore) Get(id int) (*StackWebhook, error) {
+	var wh *StackWebhook
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(db.BucketStackWebhooks).Get(itob(uint64(id)))
+		if v == nil {
+			return nil
+		}
+		wh = &StackWebhook{}
+		return json.Unmarshal(v, wh)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get stack webhook: %w", err)
+	}
+	return wh, nil
+}
+
+// ListForStack returns the webhooks of a stack in creation order.
+func (s *StackWebhookStore) ListForStack(stackName string) ([]StackWebhook, error) {
+	result := []StackWebhook{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(db.BucketStackWebhooks).ForEach(func(_, v []byte) error {
+			var wh StackWebhook
+			if err := json.Unmarshal(v, &wh); err != nil {
+				return fmt.Errorf("unmarshal stack webhook: %w", err)
+			}
+			if wh.StackName == stackName {
+				result = append(result, wh)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list stack webhooks: %w", err)
+	}
+	return result, nil
+}
+
+// Delete removes a webhook.
+func (s *StackWebhookStore) Delete(id int) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(db.BucketStackWebhooks).Delete(itob(uint64(id)))
+	})
+	if err != nil {
+		return fmt.Errorf("delete stack webhook: %w", err)
+	}
+	return nil
+}
+
+// DeleteForStack removes all webhooks of a stack, e.g. when it is deleted.
+func (s *StackWebhookStore) DeleteForStack(stackName string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(db.BucketStackWebhooks)
+		var keys [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			var wh StackWebhook
+			if err := json.Unmarshal(v, &wh); err != nil {
+				return fmt.Errorf("unmarshal stack webhook: %w", err)
+			}
+			if wh.StackName == stackName {
+				keys = append(keys, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("delete stack webhooks: %w", err)
+	}
+	return nil
+}
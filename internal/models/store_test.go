@@ -1,9 +1,12 @@
 package models
 
 import (
+    "errors"
     "fmt"
     "path/filepath"
+    "strings"
     "testing"
+    "time"
 
     "github.com/cfilipov/dockge/internal/db"
 )
@@ -149,6 +152,116 @@ func TestUserStoreChangePassword(t *testing.T) {
     }
 }
 
+func TestUserStoreRoles(t *testing.T) {
+    t.Parallel()
+    store := openTestDB(t)
+
+    admin, err := store.Create("admin", "password")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !admin.IsAdmin() {
+        t.Error("Create should make an admin")
+    }
+    op, err := store.CreateWithRole("op", "password", RoleOperator)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if op.IsAdmin() {
+        t.Error("operator should not be admin")
+    }
+    if _, err := store.CreateWithRole("op", "password", RoleOperator); err == nil {
+        t.Error("expected duplicate username to fail")
+    }
+
+    if err := store.SetRole(op.ID, RoleAdmin); err != nil {
+        t.Fatal(err)
+    }
+    if err := store.SetRole(op.ID, "root"); err == nil {
+        t.Error("expected invalid role to fail")
+    }
+
+    users, err := store.List()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(users) != 2 || users[0].Username != "admin" || users[1].Username != "op" {
+        t.Fatalf("unexpected user list: %+v", users)
+    }
+    if !users[1].IsAdmin() {
+        t.Error("expected promoted operator to be admin")
+    }
+
+    // Legacy users without a role are admins
+    legacy := User{Username: "legacy"}
+    if !legacy.IsAdmin() || legacy.EffectiveRole() != RoleAdmin {
+        t.Error("empty role should be treated as admin")
+    }
+}
+
+// --- PendingChangeStore ---
+
+func TestPendingChangeStoreResolve(t *testing.T) {
+    t.Parallel()
+    dir := t.TempDir()
+    database, err := db.Open(filepath.Join(dir, "data"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { database.Close() })
+    store := NewPendingChangeStore(database)
+
+    pc := &PendingChange{StackName: "web", Action: PendingActionSave, RequestedBy: "op"}
+    if err := store.Create(pc); err != nil {
+        t.Fatal(err)
+    }
+    if pc.ID == 0 || pc.Status != PendingStatusPending {
+        t.Fatalf("unexpected created change: %+v", pc)
+    }
+
+    pending, err := store.List(PendingStatusPending)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(pending) != 1 {
+        t.Fatalf("expected 1 pending change, got %d", len(pending))
+    }
+
+    resolved, err := store.Resolve(pc.ID, PendingStatusRejected, "admin", "not now")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if resolved.Status != PendingStatusRejected || resolved.ReviewedBy != "admin" {
+        t.Errorf("unexpected resolved change: %+v", resolved)
+    }
+
+    // Second resolution must fail
+    if _, err := store.Resolve(pc.ID, PendingStatusApproved, "admin2", ""); err != ErrChangeNotPending {
+        t.Errorf("expected ErrChangeNotPending, got %v", err)
+    }
+
+    pending, _ = store.List(PendingStatusPending)
+    if len(pending) != 0 {
+        t.Errorf("expected no pending changes, got %d", len(pending))
+    }
+
+    // Pruning drops the resolved change but keeps pending ones
+    waiting := &PendingChange{StackName: "db", Action: PendingActionDeploy, RequestedBy: "op"}
+    if err := store.Create(waiting); err != nil {
+        t.Fatal(err)
+    }
+    removed, _, err := store.PruneResolved(resolved.ReviewedAt + 1)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if removed != 1 {
+        t.Errorf("expected 1 resolved change pruned, got %d", removed)
+    }
+    if all, _ := store.List(""); len(all) != 1 || all[0].ID != waiting.ID {
+        t.Errorf("expected only the pending change to remain, got %+v", all)
+    }
+}
+
 // --- SettingStore ---
 
 func TestSettingStoreGetSet(t *testing.T) {
@@ -450,4 +563,980 @@ func TestImageUpdateStoreUpsertOverwrite(t *testing.T) {
     if len(entries) != 1 {
         t.Errorf("expected 1 entry after upsert, got %d", len(entries))
     }
+
+    if d, _ := store.RemoteDigest("stack", "svc"); d != "new" {
+        t.Errorf("RemoteDigest = %q, want new", d)
+    }
+    if d, _ := store.RemoteDigest("stack", "other"); d != "" {
+        t.Errorf("RemoteDigest for unchecked service = %q", d)
+    }
+}
+
+func TestImageUpdateStoreReleases(t *testing.T) {
+    t.Parallel()
+    store := openTestImageUpdateStore(t)
+
+    store.Upsert("stack-a", "web", "nginx:1.25.3", "sha256:a", "sha256:a", false, CheckStatusOK)
+    store.Upsert("stack-a", "db", "postgres:16.1", "sha256:b", "sha256:b", false, CheckStatusOK)
+    store.Upsert("stack-b", "api", "node:20.1.0", "sha256:c", "sha256:c", false, CheckStatusOK)
+
+    patch := &ReleaseUpdate{Kind: "patch", Patch: "1.25.4"}
+    if err := store.SetRelease("stack-a", "web", patch); err != nil {
+        t.Fatal(err)
+    }
+    store.SetRelease("stack-b", "api", &ReleaseUpdate{Kind: "major", Major: "22.0.0"})
+    store.SetRelease("stack-c", "missing", patch) // unchecked: ignored
+
+    all, err := store.AllReleases()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(all) != 2 || all["stack-a/web"] != *patch || all["stack-b/api"].Kind != "major" {
+        t.Errorf("AllReleases = %+v", all)
+    }
+    forStack, _ := store.ReleasesForStack("stack-a")
+    if len(forStack) != 1 || forStack["web"] != *patch {
+        t.Errorf("ReleasesForStack = %+v", forStack)
+    }
+
+    // Clearing and re-checking both drop the classification
+    store.SetRelease("stack-a", "web", nil)
+    store.Upsert("stack-b", "api", "node:20.1.0", "sha256:c", "sha256:c", false, CheckStatusOK)
+    if all, _ := store.AllReleases(); len(all) != 0 {
+        t.Errorf("expected no releases, got %+v", all)
+    }
+}
+
+// --- StackBudgetStore ---
+
+func TestStackBudgetStore(t *testing.T) {
+    t.Parallel()
+    dir := t.TempDir()
+    database, err := db.Open(filepath.Join(dir, "data"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { database.Close() })
+    store := NewStackBudgetStore(database)
+
+    if err := store.Set(StackBudget{StackName: "web", MaxMemory: 512 << 20, MaxCPUs: 1.5}); err != nil {
+        t.Fatal(err)
+    }
+    b, err := store.Get("web")
+    if err != nil || b == nil {
+        t.Fatalf("get: %v %v", b, err)
+    }
+    if b.MaxCPUs != 1.5 || b.MaxMemory != 512<<20 {
+        t.Errorf("budget = %+v", b)
+    }
+    if !b.Exceeded(2, 0) || !b.Exceeded(0, 600<<20) || b.Exceeded(1, 100<<20) {
+        t.Error("Exceeded gave wrong result")
+    }
+
+    // Clearing all limits removes the budget
+    if err := store.Set(StackBudget{StackName: "web"}); err != nil {
+        t.Fatal(err)
+    }
+    list, _ := store.List()
+    if len(list) != 0 {
+        t.Errorf("expected no budgets, got %v", list)
+    }
+}
+
+// --- StackNoteStore ---
+
+func TestStackNoteStore(t *testing.T) {
+    t.Parallel()
+    dir := t.TempDir()
+    database, err := db.Open(filepath.Join(dir, "data"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { database.Close() })
+    store := NewStackNoteStore(database)
+
+    if n, err := store.Get("web"); err != nil || n != nil {
+        t.Fatalf("expected no note, got %+v, %v", n, err)
+    }
+    if err := store.Set(StackNote{StackName: "web", Text: "Held on nginx 1.24 until TLS fix", UpdatedBy: "alice"}); err != nil {
+        t.Fatal(err)
+    }
+    n, err := store.Get("web")
+    if err != nil || n == nil {
+        t.Fatalf("Get: %+v, %v", n, err)
+    }
+    if n.Text != "Held on nginx 1.24 until TLS fix" || n.UpdatedBy != "alice" || n.UpdatedAt == 0 {
+        t.Errorf("unexpected note %+v", n)
+    }
+
+    if err := store.Set(StackNote{StackName: "web", Text: "  "}); err != nil {
+        t.Fatal(err)
+    }
+    if n, _ := store.Get("web"); n != nil {
+        t.Errorf("blank text should delete the note, got %+v", n)
+    }
+}
+
+// --- OperationStore ---
+
+func TestOperationStore(t *testing.T) {
+    t.Parallel()
+    dir := t.TempDir()
+    database, err := db.Open(filepath.Join(dir, "data"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { database.Close() })
+    store := NewOperationStore(database)
+
+    for _, name := range []string{"web", "db", "web"} {
+        if err := store.Create(&Operation{StackName: name, Action: "deploy"}); err != nil {
+            t.Fatal(err)
+        }
+    }
+    op := &Operation{StackName: "web", Action: "update"}
+    if err := store.Create(op); err != nil {
+        t.Fatal(err)
+    }
+    op.Success = true
+    op.FinishedAt = op.StartedAt + 5
+    op.Pull = &PullProgress{Images: 1, Layers: 3, LayersDone: 3, Percent: 100}
+    if err := store.Update(op); err != nil {
+        t.Fatal(err)
+    }
+
+    list, err := store.List("web", 2)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(list) != 2 || list[0].ID != op.ID || list[1].ID != 3 {
+        t.Fatalf("expected newest web operations first, got %+v", list)
+    }
+    if !list[0].Success || list[0].Pull == nil || list[0].Pull.Layers != 3 {
+        t.Errorf("update not persisted: %+v", list[0])
+    }
+    if all, _ := store.List("", 0); len(all) != 4 {
+        t.Errorf("expected 4 operations, got %d", len(all))
+    }
+
+    // Only finished operations older than the cutoff are pruned
+    removed, freed, err := store.Prune(op.FinishedAt + 1)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if removed != 1 || freed == 0 {
+        t.Errorf("Prune = (%d, %d), want 1 operation removed", removed, freed)
+    }
+    if all, _ := store.List("", 0); len(all) != 3 {
+        t.Errorf("expected 3 running operations kept, got %d", len(all))
+    }
+}
+
+// --- StackArchiveStore ---
+
+func TestStackArchiveStore(t *testing.T) {
+    t.Parallel()
+    dir := t.TempDir()
+    database, err := db.Open(filepath.Join(dir, "data"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { database.Close() })
+    store := NewStackArchiveStore(database)
+
+    if a, err := store.Get("web"); err != nil || a != nil {
+        t.Fatalf("expected web not archived, got %+v, %v", a, err)
+    }
+    if err := store.Archive(ArchivedStack{StackName: "web", ArchivedBy: "alice"}); err != nil {
+        t.Fatal(err)
+    }
+    a, err := store.Get("web")
+    if err != nil || a == nil {
+        t.Fatalf("expected archived web, got %v", err)
+    }
+    if a.ArchivedBy != "alice" || a.ArchivedAt == 0 {
+        t.Errorf("unexpected archive record: %+v", a)
+    }
+    if names, _ := store.Names(); len(names) != 1 || !names["web"] {
+        t.Errorf("Names = %v", names)
+    }
+
+    if err := store.Unarchive("web"); err != nil {
+        t.Fatal(err)
+    }
+    if names, _ := store.Names(); len(names) != 0 {
+        t.Errorf("expected no archived stacks, got %v", names)
+    }
+}
+
+// --- StackScheduleStore ---
+
+func TestStackScheduleStore(t *testing.T) {
+    t.Parallel()
+    dir := t.TempDir()
+    database, err := db.Open(filepath.Join(dir, "data"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { database.Close() })
+    store := NewStackScheduleStore(database)
+
+    web := &StackSchedule{StackName: "web", Action: "restart", Cron: "0 3 * * *", Enabled: true}
+    db1 := &StackSchedule{StackName: "db", Action: "update", Cron: "@weekly"}
+    for _, sch := range []*StackSchedule{web, db1} {
+        if err := store.Create(sch); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if web.ID == 0 || db1.ID == web.ID || web.CreatedAt == 0 {
+        t.Fatalf("unexpected ids/timestamps: %+v %+v", web, db1)
+    }
+
+    web.LastRun = 123
+    web.LastError = "boom"
+    if err := store.Update(web); err != nil {
+        t.Fatal(err)
+    }
+    got, err := store.Get(web.ID)
+    if err != nil || got == nil || got.LastRun != 123 || got.LastError != "boom" {
+        t.Fatalf("Get = %+v, %v", got, err)
+    }
+
+    if err := store.DeleteForStack("web"); err != nil {
+        t.Fatal(err)
+    }
+    list, err := store.List()
+    if err != nil || len(list) != 1 || list[0].StackName != "db" {
+        t.Fatalf("List = %+v, %v", list, err)
+    }
+    // Updating a deleted schedule must not resurrect it
+    if err := store.Update(web); err == nil {
+        t.Error("expected error updating a deleted schedule")
+    }
+    if err := store.Delete(db1.ID); err != nil {
+        t.Fatal(err)
+    }
+    if list, _ := store.List(); len(list) != 0 {
+        t.Errorf("expected no schedules, got %+v", list)
+    }
+}
+
+// --- UpdateIgnoreStore ---
+
+func TestUpdateIgnoreStore(t *testing.T) {
+    t.Parallel()
+    dir := t.TempDir()
+    database, err := db.Open(filepath.Join(dir, "data"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { database.Close() })
+    store := NewUpdateIgnoreStore(database)
+
+    if err := store.Add(UpdateIgnore{StackName: "web"}); err == nil {
+        t.Error("expected error for ignore without service")
+    }
+    if err := store.Add(UpdateIgnore{StackName: "web", ServiceName: "app", Reason: "held back"}); err != nil {
+        t.Fatal(err)
+    }
+    if err := store.Add(UpdateIgnore{Image: "postgres:15"}); err != nil {
+        t.Fatal(err)
+    }
+    if err := store.Add(UpdateIgnore{StackName: "cache", ServiceName: "redis", Digest: "sha256:aaa"}); err != nil {
+        t.Fatal(err)
+    }
+
+    list, _ := store.List()
+    if len(list) != 3 || list[0].Image != "postgres:15" {
+        t.Fatalf("expected image ignore first, got %+v", list)
+    }
+
+    match, err := store.Matcher()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !match("web", "app", "nginx", "sha256:x") || !match("db", "pg", "postgres:15", "sha256:x") || match("db", "pg", "postgres:16", "sha256:x") {
+        t.Error("Matcher gave wrong result")
+    }
+    if !match("cache", "redis", "redis:7", "sha256:aaa") || match("cache", "redis", "redis:7", "sha256:bbb") {
+        t.Error("digest ignore should only match that digest")
+    }
+
+    if err := store.Remove(UpdateIgnore{StackName: "web", ServiceName: "app"}); err != nil {
+        t.Fatal(err)
+    }
+    if list, _ := store.List(); len(list) != 2 {
+        t.Errorf("expected 2 ignores after remove, got %d", len(list))
+    }
+}
+
+// --- AgentStore ---
+
+func TestAgentStore(t *testing.T) {
+    t.Parallel()
+    dir := t.TempDir()
+    database, err := db.Open(filepath.Join(dir, "data"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { database.Close() })
+    store := NewAgentStore(database)
+
+    token, err := store.Create(Agent{Endpoint: "nas", Name: "NAS", CreatedBy: "alice"})
+    if err != nil || token == "" {
+        t.Fatalf("Create: %q, %v", token, err)
+    }
+    if _, err := store.Create(Agent{Endpoint: "nas"}); err == nil {
+        t.Error("expected duplicate endpoint to fail")
+    }
+
+    a, err := store.Authenticate(token)
+    if err != nil || a == nil || a.Endpoint != "nas" {
+        t.Fatalf("Authenticate = %+v, %v", a, err)
+    }
+    if a.TokenHash == token {
+        t.Error("token stored in plain text")
+    }
+    if a, _ := store.Authenticate("wrong"); a != nil {
+        t.Errorf("wrong token authenticated as %q", a.Endpoint)
+    }
+    if a, _ := store.Authenticate(""); a != nil {
+        t.Error("empty token authenticated")
+    }
+
+    if err := store.Touch("nas"); err != nil {
+        t.Fatal(err)
+    }
+    if a, _ := store.Get("nas"); a == nil || a.LastSeen == 0 {
+        t.Errorf("expected LastSeen set, got %+v", a)
+    }
+
+    // Both tokens work while a rotation is pending
+    rotated, err := store.Rotate("nas", a.TokenHash)
+    if err != nil || rotated == "" || rotated == token {
+        t.Fatalf("Rotate: %q, %v", rotated, err)
+    }
+    for _, tok := range []string{token, rotated} {
+        if a, _ := store.Authenticate(tok); a == nil {
+            t.Errorf("token %q rejected during rotation", tok)
+        }
+    }
+    if err := store.Confirm("nas", a.TokenHash); err != nil {
+        t.Fatal(err)
+    }
+    if a, _ := store.Authenticate(token); a == nil {
+        t.Error("confirming the old token completed the rotation")
+    }
+    if err := store.Confirm("nas", hashAgentToken(rotated)); err != nil {
+        t.Fatal(err)
+    }
+    if a, _ := store.Authenticate(token); a != nil {
+        t.Error("old token valid after the rotation completed")
+    }
+    if a, _ := store.Get("nas"); a == nil || a.PrevTokenHash != "" || a.RotatedAt == 0 {
+        t.Errorf("expected completed rotation, got %+v", a)
+    }
+    token = rotated
+
+    if err := store.Delete("nas"); err != nil {
+        t.Fatal(err)
+    }
+    if err := store.Touch("nas"); err != nil {
+        t.Fatal(err)
+    }
+    if agents, _ := store.List(); len(agents) != 0 {
+        t.Errorf("expected no agents, got %+v", agents)
+    }
+    if a, _ := store.Authenticate(token); a != nil {
+        t.Error("deleted agent still authenticates")
+    }
+}
+
+// --- StackTerminalAccessStore ---
+
+func TestStackTerminalAccessStore(t *testing.T) {
+    t.Parallel()
+    dir := t.TempDir()
+    database, err := db.Open(filepath.Join(dir, "data"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { database.Close() })
+    store := NewStackTerminalAccessStore(database)
+
+    admin := &User{Username: "root", Role: RoleAdmin}
+    alice := &User{Username: "alice", Role: RoleOperator}
+    bob := &User{Username: "bob", Role: RoleOperator}
+
+    a, err := store.Get("web")
+    if err != nil || a != nil {
+        t.Fatalf("expected no restriction, got %+v, %v", a, err)
+    }
+    if !a.Allows(bob) {
+        t.Error("unrestricted stack should allow operators")
+    }
+
+    if err := store.Set(StackTerminalAccess{StackName: "web", Usernames: []string{"alice"}, UpdatedBy: "root"}); err != nil {
+        t.Fatal(err)
+    }
+    a, err = store.Get("web")
+    if err != nil || a == nil || a.UpdatedAt == 0 {
+        t.Fatalf("Get: %+v, %v", a, err)
+    }
+    if !a.Allows(admin) || !a.Allows(alice) || a.Allows(bob) {
+        t.Errorf("unexpected permissions for %+v", a)
+    }
+
+    if err := store.Set(StackTerminalAccess{StackName: "db"}); err != nil {
+        t.Fatal(err)
+    }
+    if list, _ := store.List(); len(list) != 2 {
+        t.Errorf("expected 2 restrictions, got %+v", list)
+    }
+    if a, _ := store.Get("db"); a == nil || a.Allows(alice) || !a.Allows(admin) {
+        t.Errorf("empty list should restrict to admins, got %+v", a)
+    }
+
+    if err := store.Delete("web"); err != nil {
+        t.Fatal(err)
+    }
+    if a, _ := store.Get("web"); a != nil {
+        t.Errorf("expected restriction lifted, got %+v", a)
+    }
+}
+
+func TestStackTerminalEnvStore(t *testing.T) {
+    t.Parallel()
+    dir := t.TempDir()
+    database, err := db.Open(filepath.Join(dir, "data"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { database.Close() })
+    store := NewStackTerminalEnvStore(database)
+
+    if e, err := store.Get("web"); err != nil || e != nil {
+        t.Fatalf("expected no override, got %+v, %v", e, err)
+    }
+    if err := store.Set(StackTerminalEnv{StackName: "web", Lang: "C.UTF-8", Shells: []string{"ash"}, UpdatedBy: "root"}); err != nil {
+        t.Fatal(err)
+    }
+    e, err := store.Get("web")
+    if err != nil || e == nil {
+        t.Fatalf("Get: %+v, %v", e, err)
+    }
+    if e.Lang != "C.UTF-8" || len(e.Shells) != 1 || e.UpdatedAt == 0 {
+        t.Errorf("unexpected override %+v", e)
+    }
+
+    if err := store.Set(StackTerminalEnv{StackName: "web"}); err != nil {
+        t.Fatal(err)
+    }
+    if e, _ := store.Get("web"); e != nil {
+        t.Errorf("empty override should delete it, got %+v", e)
+    }
+}
+
+func TestStackPullPolicyStore(t *testing.T) {
+    t.Parallel()
+    dir := t.TempDir()
+    database, err := db.Open(filepath.Join(dir, "data"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { database.Close() })
+    store := NewStackPullPolicyStore(database)
+
+    if p, err := store.Get("web"); err != nil || p != nil {
+        t.Fatalf("expected no policy, got %+v, %v", p, err)
+    }
+    if err := store.Set(StackPullPolicy{StackName: "web", Policy: "never", UpdatedBy: "root"}); err != nil {
+        t.Fatal(err)
+    }
+    p, err := store.Get("web")
+    if err != nil || p == nil || p.Policy != "never" || p.UpdatedAt == 0 {
+        t.Fatalf("Get: %+v, %v", p, err)
+    }
+
+    if err := store.Delete("web"); err != nil {
+        t.Fatal(err)
+    }
+    if p, _ := store.Get("web"); p != nil {
+        t.Errorf("expected the policy to be deleted, got %+v", p)
+    }
+}
+
+func TestStackServiceSelectionStore(t *testing.T) {
+    t.Parallel()
+    dir := t.TempDir()
+    database, err := db.Open(filepath.Join(dir, "data"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { database.Close() })
+    store := NewStackServiceSelectionStore(database)
+
+    if sel, err := store.Get("web"); err != nil || sel != nil {
+        t.Fatalf("expected no selection, got %+v, %v", sel, err)
+    }
+    if err := store.Set(StackServiceSelection{StackName: "web", Services: []string{"app", "db"}, UpdatedBy: "root"}); err != nil {
+        t.Fatal(err)
+    }
+    sel, err := store.Get("web")
+    if err != nil || sel == nil || len(sel.Services) != 2 || sel.Services[1] != "db" || sel.UpdatedAt == 0 {
+        t.Fatalf("Get: %+v, %v", sel, err)
+    }
+
+    if err := store.Delete("web"); err != nil {
+        t.Fatal(err)
+    }
+    if sel, _ := store.Get("web"); sel != nil {
+        t.Errorf("expected the selection to be deleted, got %+v", sel)
+    }
+}
+
+func TestHostStateStore(t *testing.T) {
+    t.Parallel()
+    dir := t.TempDir()
+    database, err := db.Open(filepath.Join(dir, "data"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { database.Close() })
+    store := NewHostStateStore(database)
+
+    if snap, err := store.Snapshot(); err != nil || snap != nil {
+        t.Fatalf("expected no snapshot, got %+v, %v", snap, err)
+    }
+    if err := store.SetSnapshot(&ContainerSnapshot{BootID: "b1", TakenAt: 100, Containers: []SnapshotContainer{{Name: "web-app-1", Stack: "web", Service: "app"}}}); err != nil {
+        t.Fatal(err)
+    }
+    snap, err := store.Snapshot()
+    if err != nil || snap == nil || snap.BootID != "b1" || len(snap.Containers) != 1 || snap.Containers[0].Stack != "web" {
+        t.Fatalf("Snapshot: %+v, %v", snap, err)
+    }
+
+    if report, err := store.RebootReport(); err != nil || report != nil {
+        t.Fatalf("expected no report, got %+v, %v", report, err)
+    }
+    if err := store.SetRebootReport(&RebootReport{Rebooted: true, Failed: 1, Stacks: []StackRecovery{{Stack: "web", Status: "down"}}}); err != nil {
+        t.Fatal(err)
+    }
+    report, err := store.RebootReport()
+    if err != nil || report == nil || !report.Rebooted || report.Stacks[0].Status != "down" {
+        t.Fatalf("RebootReport: %+v, %v", report, err)
+    }
+}
+
+func TestExecDefaultsStore(t *testing.T) {
+    t.Parallel()
+    dir := t.TempDir()
+    database, err := db.Open(filepath.Join(dir, "data"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { database.Close() })
+    store := NewExecDefaultsStore(database)
+
+    if d, err := store.Get("alice"); err != nil || d != nil {
+        t.Fatalf("expected no defaults, got %+v, %v", d, err)
+    }
+    if err := store.Set(ExecDefaults{Username: "alice", User: "node", Env: []string{"DEBUG=1"}}); err != nil {
+        t.Fatal(err)
+    }
+    d, err := store.Get("alice")
+    if err != nil || d == nil || d.User != "node" || len(d.Env) != 1 || d.UpdatedAt == 0 {
+        t.Fatalf("Get: %+v, %v", d, err)
+    }
+
+    if err := store.Set(ExecDefaults{Username: "alice"}); err != nil {
+        t.Fatal(err)
+    }
+    if d, _ := store.Get("alice"); d != nil {
+        t.Errorf("empty defaults should be deleted, got %+v", d)
+    }
+}
+
+func TestStackLogCaptureStore(t *testing.T) {
+    t.Parallel()
+    dir := t.TempDir()
+    database, err := db.Open(filepath.Join(dir, "data"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { database.Close() })
+    store := NewStackLogCaptureStore(database)
+
+    if lc, err := store.Get("web"); err != nil || lc != nil {
+        t.Fatalf("expected capture off, got %+v, %v", lc, err)
+    }
+    if err := store.Enable(StackLogCapture{StackName: "web", EnabledBy: "root"}); err != nil {
+        t.Fatal(err)
+    }
+    if err := store.Enable(StackLogCapture{StackName: "db", Services: []string{"postgres"}}); err != nil {
+        t.Fatal(err)
+    }
+    lc, err := store.Get("web")
+    if err != nil || lc == nil || lc.EnabledBy != "root" || lc.EnabledAt == 0 || lc.Services != nil {
+        t.Fatalf("Get: %+v, %v", lc, err)
+    }
+    if lc, _ := store.Get("db"); lc == nil || len(lc.Services) != 1 || lc.Services[0] != "postgres" {
+        t.Errorf("expected postgres only, got %+v", lc)
+    }
+    if list, _ := store.List(); len(list) != 2 {
+        t.Errorf("expected 2 stacks, got %+v", list)
+    }
+
+    if err := store.Disable("web"); err != nil {
+        t.Fatal(err)
+    }
+    if lc, _ := store.Get("web"); lc != nil {
+        t.Errorf("expected capture off, got %+v", lc)
+    }
+}
+
+func TestAuditStore(t *testing.T) {
+    t.Parallel()
+    dir := t.TempDir()
+    database, err := db.Open(filepath.Join(dir, "data"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { database.Close() })
+    store := NewAuditStore(database)
+
+    for i := range 5 {
+        e := &AuditEntry{User: "alice", Action: "startStack", Stack: fmt.Sprintf("s%d", i), Success: true}
+        if i%2 == 1 {
+            e.User = "bob"
+        }
+        if err := store.Append(e); err != nil {
+            t.Fatal(err)
+        }
+        if e.ID != uint64(i+1) || e.Time == 0 {
+            t.Fatalf("Append did not assign ID and time: %+v", e)
+        }
+    }
+
+    page, next, err := store.List(AuditFilter{}, 0, 2)
+    if err != nil || len(page) != 2 || page[0].ID != 5 || page[1].ID != 4 || next != 4 {
+        t.Fatalf("first page: %+v, next %d, %v", page, next, err)
+    }
+    page, next, _ = store.List(AuditFilter{}, next, 2)
+    if len(page) != 2 || page[0].ID != 3 || next != 2 {
+        t.Fatalf("second page: %+v, next %d", page, next)
+    }
+    page, next, _ = store.List(AuditFilter{}, next, 2)
+    if len(page) != 1 || page[0].ID != 1 || next != 0 {
+        t.Fatalf("last page: %+v, next %d", page, next)
+    }
+
+    page, next, _ = store.List(AuditFilter{User: "alice"}, 0, 0)
+    if len(page) != 3 || next != 0 {
+        t.Errorf("expected 3 entries by alice, got %+v", page)
+    }
+    if page, _, _ := store.List(AuditFilter{Stack: "s3"}, 0, 10); len(page) != 1 || page[0].User != "bob" {
+        t.Errorf("unexpected stack filter result %+v", page)
+    }
+}
+
+// --- StackEventStore ---
+
+func TestStackEventStore(t *testing.T) {
+    t.Parallel()
+    dir := t.TempDir()
+    database, err := db.Open(filepath.Join(dir, "data"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { database.Close() })
+    store := NewStackEventStore(database)
+
+    now := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+    record := func(kind StackEventKind, at time.Time) {
+        t.Helper()
+        if err := store.Record("web", kind, at); err != nil {
+            t.Fatal(err)
+        }
+    }
+    record(StackEventRestart, now.Add(-30*time.Hour)) // outside the window
+    record(StackEventRestart, now.Add(-23*time.Hour))
+    record(StackEventRestart, now)
+    record(StackEventRestart, now.Add(-10*time.Minute))
+    record(StackEventHealthFlap, now)
+
+    hours, err := store.Histogram("web", now)
+    if err != nil || len(hours) != StackEventHours {
+        t.Fatalf("Histogram: %d hours, %v", len(hours), err)
+    }
+    if hours[0].Hour != now.Add(-23*time.Hour).Truncate(time.Hour).Unix() || hours[0].Restarts != 1 {
+        t.Errorf("oldest hour = %+v", hours[0])
+    }
+    if last := hours[StackEventHours-1]; last.Restarts != 2 || last.HealthFlaps != 1 {
+        t.Errorf("current hour = %+v", last)
+    }
+    total := 0
+    for _, h := range hours {
+        total += h.Restarts
+    }
+    if total != 3 {
+        t.Errorf("expected 3 restarts in the window, got %d", total)
+    }
+
+    // A day later, everything has aged out
+    hours, _ = store.Histogram("web", now.Add(24*time.Hour))
+    for _, h := range hours {
+        if h.Restarts != 0 || h.HealthFlaps != 0 {
+            t.Fatalf("expected no events a day later, got %+v", h)
+        }
+    }
+
+    if err := store.Delete("web"); err != nil {
+        t.Fatal(err)
+    }
+    if hours, _ := store.Histogram("other", now); len(hours) != StackEventHours || hours[0].Restarts != 0 {
+        t.Errorf("unknown stack: %+v", hours)
+    }
+}
+
+func TestIdempotencyStore(t *testing.T) {
+    t.Parallel()
+    dir := t.TempDir()
+    database, err := db.Open(filepath.Join(dir, "data"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { database.Close() })
+    store := NewIdempotencyStore(database)
+    now := time.Unix(1700000000, 0)
+
+    if prev, err := store.Claim("1/a", "fp", now); err != nil || prev != nil {
+        t.Fatalf("first claim: %+v, %v", prev, err)
+    }
+    prev, err := store.Claim("1/a", "fp", now)
+    if err != nil || prev == nil || prev.Ack != nil || prev.Fingerprint != "fp" {
+        t.Fatalf("claim while pending: %+v, %v", prev, err)
+    }
+
+    if err := store.Complete("1/a", []byte(`{"ok":true}`)); err != nil {
+        t.Fatal(err)
+    }
+    if err := store.Complete("1/a", []byte(`{"ok":false}`)); err != nil {
+        t.Fatal(err)
+    }
+    if prev, _ := store.Claim("1/a", "fp", now.Add(time.Minute)); prev == nil || string(prev.Ack) != `{"ok":true}` {
+        t.Errorf("expected the first ack, got %+v", prev)
+    }
+    if err := store.Complete("1/unknown", []byte(`{}`)); err != nil {
+        t.Errorf("completing an unknown key: %v", err)
+    }
+
+    // Expired keys can be claimed again
+    if prev, _ := store.Claim("1/a", "fp", now.Add(IdempotencyTTL+time.Second)); prev != nil {
+        t.Errorf("expected the key to have expired, got %+v", prev)
+    }
+}
+
+// --- StackWebhookStore ---
+
+func TestStackWebhookStore(t *testing.T) {
+    t.Parallel()
+    dir := t.TempDir()
+    database, err := db.Open(filepath.Join(dir, "data"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { database.Close() })
+    store := NewStackWebhookStore(database)
+
+    web := &StackWebhook{StackName: "web", URL: "https://example.com/hook", Events: []string{"deployed"}, Enabled: true}
+    db1 := &StackWebhook{StackName: "db", URL: "https://example.com/db", Events: []string{"failed"}}
+    for _, wh := range []*StackWebhook{web, db1} {
+        if err := store.Create(wh); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if web.ID == 0 || db1.ID == web.ID || web.CreatedAt == 0 {
+        t.Fatalf("unexpected ids/timestamps: %+v %+v", web, db1)
+    }
+
+    web.LastSent = 123
+    web.LastError = "503 Service Unavailable"
+    if err := store.Update(web); err != nil {
+        t.Fatal(err)
+    }
+    got, err := store.Get(web.ID)
+    if err != nil || got == nil || got.LastSent != 123 || got.LastError != web.LastError {
+        t.Fatalf("Get = %+v, %v", got, err)
+    }
+    if list, err := store.ListForStack("web"); err != nil || len(list) != 1 || list[0].ID != web.ID {
+        t.Fatalf("ListForStack = %+v, %v", list, err)
+    }
+
+    if err := store.DeleteForStack("web"); err != nil {
+        t.Fatal(err)
+    }
+    if list, _ := store.ListForStack("web"); len(list) != 0 {
+        t.Errorf("expected no webhooks for web, got %+v", list)
+    }
+    // Updating a deleted webhook must not resurrect it
+    if err := store.Update(web); err == nil {
+        t.Error("expected error updating a deleted webhook")
+    }
+    if err := store.Delete(db1.ID); err != nil {
+        t.Fatal(err)
+    }
+    if got, _ := store.Get(db1.ID); got != nil {
+        t.Errorf("expected webhook deleted, got %+v", got)
+    }
+}
+
+// --- DeployedComposeStore ---
+
+func TestDeployedComposeStore(t *testing.T) {
+    t.Parallel()
+    dir := t.TempDir()
+    database, err := db.Open(filepath.Join(dir, "data"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { database.Close() })
+    store := NewDeployedComposeStore(database)
+
+    if d, err := store.Get("web"); err != nil || d != nil {
+        t.Fatalf("expected no snapshot, got %+v, %v", d, err)
+    }
+    if err := store.Set(DeployedCompose{StackName: "web", ComposeFileName: "compose.yaml", ComposeYAML: "services: {}\n", Hash: "a"}); err != nil {
+        t.Fatal(err)
+    }
+    if err := store.Set(DeployedCompose{StackName: "web", ComposeFileName: "compose.yaml", ComposeYAML: "services:\n  web: {}\n", Hash: "b"}); err != nil {
+        t.Fatal(err)
+    }
+    d, err := store.Get("web")
+    if err != nil || d == nil || d.Hash != "b" || d.DeployedAt == 0 {
+        t.Fatalf("expected the latest snapshot, got %+v, %v", d, err)
+    }
+
+    if err := store.Delete("web"); err != nil {
+        t.Fatal(err)
+    }
+    if d, _ := store.Get("web"); d != nil {
+        t.Errorf("expected the snapshot to be deleted, got %+v", d)
+    }
+}
+
+// --- StackGroupStore ---
+
+func TestStackGroupStore(t *testing.T) {
+    t.Parallel()
+    dir := t.TempDir()
+    database, err := db.Open(filepath.Join(dir, "data"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { database.Close() })
+    store := NewStackGroupStore(database)
+
+    media, err := store.Create("Media")
+    if err != nil {
+        t.Fatal(err)
+    }
+    infra, err := store.Create("Infra")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if media.ID == infra.ID || infra.Order <= media.Order {
+        t.Fatalf("unexpected ids/order: %+v %+v", media, infra)
+    }
+
+    if err := store.Update(media.ID, "Media", []string{"plex", "sonarr", "plex"}); err != nil {
+        t.Fatal(err)
+    }
+    // Moving a stack to another group takes it out of the first one
+    if err := store.Update(infra.ID, "Infrastructure", []string{"traefik", "sonarr"}); err != nil {
+        t.Fatal(err)
+    }
+    got, err := store.Get(media.ID)
+    if err != nil || got == nil || len(got.Stacks) != 1 || got.Stacks[0] != "plex" {
+        t.Fatalf("Get(media) = %+v, %v", got, err)
+    }
+    got, _ = store.Get(infra.ID)
+    if got.Name != "Infrastructure" || len(got.Stacks) != 2 || got.Stacks[0] != "sonarr" {
+        t.Fatalf("Get(infra) = %+v", got)
+    }
+
+    if err := store.Reorder([]int{infra.ID}); err != nil {
+        t.Fatal(err)
+    }
+    list, err := store.List()
+    if err != nil || len(list) != 2 || list[0].ID != infra.ID || list[1].ID != media.ID {
+        t.Fatalf("List after reorder = %+v, %v", list, err)
+    }
+
+    if removed, err := store.RemoveStack("plex"); err != nil || !removed {
+        t.Fatalf("RemoveStack = %v, %v", removed, err)
+    }
+    if removed, _ := store.RemoveStack("plex"); removed {
+        t.Error("plex removed twice")
+    }
+    if err := store.Delete(media.ID); err != nil {
+        t.Fatal(err)
+    }
+    if err := store.Update(media.ID, "Media", nil); !errors.Is(err, ErrStackGroupNotFound) {
+        t.Errorf("Update of a deleted group = %v", err)
+    }
+    if list, _ := store.List(); len(list) != 1 {
+        t.Errorf("expected one group, got %+v", list)
+    }
+}
+
+// --- APITokenStore ---
+
+func TestAPITokenStore(t *testing.T) {
+    t.Parallel()
+    dir := t.TempDir()
+    database, err := db.Open(filepath.Join(dir, "data"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { database.Close() })
+    store := NewAPITokenStore(database)
+
+    tok := &APIToken{Name: "dashboard", Scopes: []string{ScopeStacks}, Stacks: []string{"web"}, CreatedBy: "alice"}
+    secret, err := store.Create(tok)
+    if err != nil || !strings.HasPrefix(secret, "dkg_") || tok.ID == 0 {
+        t.Fatalf("Create: %q, %+v, %v", secret, tok, err)
+    }
+
+    got, err := store.Authenticate(secret)
+    if err != nil || got == nil || got.Name != "dashboard" {
+        t.Fatalf("Authenticate = %+v, %v", got, err)
+    }
+    if got.TokenHash == secret {
+        t.Error("token stored in plain text")
+    }
+    if !got.HasScope(ScopeStacks) || got.HasScope(ScopeImages) {
+        t.Errorf("scopes = %v", got.Scopes)
+    }
+    if !got.CanSeeStack("web") || got.CanSeeStack("db") {
+        t.Errorf("stacks = %v", got.Stacks)
+    }
+    if got, _ := store.Authenticate("dkg_wrong"); got != nil {
+        t.Error("wrong token authenticated")
+    }
+    if got, _ := store.Authenticate(""); got != nil {
+        t.Error("empty token authenticated")
+    }
+
+    list, err := store.List()
+    if err != nil || len(list) != 1 || list[0].LastUsed == 0 {
+        t.Fatalf("List = %+v, %v", list, err)
+    }
+
+    if err := store.Delete(tok.ID); err != nil {
+        t.Fatal(err)
+    }
+    if got, _ := store.Authenticate(secret); got != nil {
+        t.Error("deleted token authenticated")
+    }
 }
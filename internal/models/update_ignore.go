@@ -0,0 +1,127 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/cfilipov/dockge/internal/db"
+)
+
+// UpdateIgnore marks image updates to skip: either every service using an
+// exact image reference, or one stack/service pair. Exactly one of Image or
+// StackName+ServiceName is set. With Digest set, only that remote version
+// is skipped and the next release shows up again.
+type UpdateIgnore struct {
+	Image       string `json:"image,omitempty"`
+	StackName   string `json:"stackName,omitempty"`
+	ServiceName string `json:"serviceName,omitempty"`
+	Digest      string `json:"digest,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+	CreatedBy   string `json:"createdBy,omitempty"`
+	CreatedAt   int64  `json:"createdAt"` // Unix seconds
+}
+
+// Key returns the BoltDB key: "image:<ref>" or "service:<stack>/<service>".
+func (ig UpdateIgnore) Key() (string, error) {
+	switch {
+	case ig.Image != "" && ig.StackName == "" && ig.ServiceName == "":
+		return "image:" + ig.Image, nil
+	case ig.Image == "" && ig.StackName != "" && ig.ServiceName != "":
+		return "service:" + ig.StackName + "/" + ig.ServiceName, nil
+	}
+	return "", fmt.Errorf("an ignore needs either an image or a stack and service")
+}
+
+// UpdateIgnoreStore persists the image update ignore list in BoltDB.
+type UpdateIgnoreStore struct {
+	db *bolt.DB
+}
+
+func NewUpdateIgnoreStore(database *bolt.DB) *UpdateIgnoreStore {
+	return &UpdateIgnoreStore{db: database}
+}
+
+// Add stores an ignore, replacing any existing one with the same key.
+func (s *UpdateIgnoreStore) Add(ig UpdateIgnore) error {
+	ig.Image = strings.TrimSpace(ig.Image)
+	key, err := ig.Key()
+	if err != nil {
+		return err
+	}
+	ig.CreatedAt = time.Now().Unix()
+	data, err := json.Marshal(ig)
+	if err != nil {
+		return fmt.Errorf("marshal update ignore: %w", err)
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(db.BucketUpdateIgnores).Put([]byte(key), data)
+	})
+	if err != nil {
+		return fmt.Errorf("add update ignore: %w", err)
+	}
+	return nil
+}
+
+// Remove deletes the ignore matching ig's image or stack/service.
+func (s *UpdateIgnoreStore) Remove(ig UpdateIgnore) error {
+	key, err := ig.Key()
+	if err != nil {
+		return err
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(db.BucketUpdateIgnores).Delete([]byte(key))
+	})
+	if err != nil {
+		return fmt.Errorf("remove update ignore: %w", err)
+	}
+	return nil
+}
+
+// List returns all ignores, image ignores first, each group sorted.
+func (s *UpdateIgnoreStore) List() ([]UpdateIgnore, error) {
+	result := []UpdateIgnore{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(db.BucketUpdateIgnores).ForEach(func(_, v []byte) error {
+			var ig UpdateIgnore
+			if err := json.Unmarshal(v, &ig); err != nil {
+				return fmt.Errorf("unmarshal update ignore: %w", err)
+			}
+			result = append(result, ig)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list update ignores: %w", err)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return (result[i].Image != "") && (result[j].Image == "")
+	})
+	return result, nil
+}
+
+// Matcher loads the ignore list once and returns a function reporting
+// whether an update of a service's image to remoteDigest should be ignored.
+func (s *UpdateIgnoreStore) Matcher() (func(stackName, serviceName, image, remoteDigest string) bool, error) {
+	ignores, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	digests := make(map[string]string, len(ignores)) // key → digest ("" = any)
+	for _, ig := range ignores {
+		if key, err := ig.Key(); err == nil {
+			digests[key] = ig.Digest
+		}
+	}
+	matches := func(key, remoteDigest string) bool {
+		digest, ok := digests[key]
+		return ok && (digest == "" || digest == remoteDigest)
+	}
+	return func(stackName, serviceName, image, remoteDigest string) bool {
+		return matches("image:"+image, remoteDigest) || matches("service:"+stackName+"/"+serviceName, remoteDigest)
+	}, nil
+}
@@ -25,11 +25,37 @@ const (
     secretLength   = 64
 )
 
+// User roles. Users created before roles existed have an empty role and are
+// treated as admins.
+const (
+    RoleAdmin    = "admin"
+    RoleOperator = "operator"
+)
+
 type User struct {
     ID       int    `json:"id"`
     Username string `json:"username"`
     Password string `json:"password"`
     Active   bool   `json:"active"`
+    Role     string `json:"role,omitempty"`
+}
+
+// IsAdmin reports whether the user has the admin role.
+func (u *User) IsAdmin() bool {
+    return u.Role == "" || u.Role == RoleAdmin
+}
+
+// EffectiveRole returns the user's role, mapping the legacy empty role to admin.
+func (u *User) EffectiveRole() string {
+    if u.Role == "" {
+        return RoleAdmin
+    }
+    return u.Role
+}
+
+// ValidRole reports whether role is a known user role.
+func ValidRole(role string) bool {
+    return role == RoleAdmin || role == RoleOperator
 }
 
 type JWTClaims struct {
@@ -110,8 +136,14 @@ func (s *UserStore) Count() (int, error) {
     return count, err
 }
 
-// Create inserts a new user with a bcrypt-hashed password.
+// Create inserts a new admin user with a bcrypt-hashed password.
 func (s *UserStore) Create(username, password string) (*User, error) {
+    return s.CreateWithRole(username, password, RoleAdmin)
+}
+
+// CreateWithRole inserts a new user with the given role. Fails if the
+// username is already taken.
+func (s *UserStore) CreateWithRole(username, password, role string) (*User, error) {
     hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
     if err != nil {
         return nil, fmt.Errorf("hash password: %w", err)
@@ -119,6 +151,10 @@ func (s *UserStore) Create(username, password string) (*User, error) {
 
     var u *User
     err = s.db.Update(func(tx *bolt.Tx) error {
+        if tx.Bucket(db.BucketUsers).Get([]byte(username)) != nil {
+            return fmt.Errorf("username %q already exists", username)
+        }
+
         // Get next ID from the users_by_id bucket sequence
         idBucket := tx.Bucket(db.BucketUsersByID)
         seq, err := idBucket.NextSequence()
@@ -131,6 +167,7 @@ func (s *UserStore) Create(username, password string) (*User, error) {
             Username: username,
             Password: string(hash),
             Active:   true,
+            Role:     role,
         }
 
         data, err := json.Marshal(u)
@@ -172,6 +209,40 @@ func (s *UserStore) DeleteAll() error {
     })
 }
 
+// List returns all users ordered by ID.
+func (s *UserStore) List() ([]User, error) {
+    var result []User
+    err := s.db.View(func(tx *bolt.Tx) error {
+        byName := tx.Bucket(db.BucketUsers)
+        return tx.Bucket(db.BucketUsersByID).ForEach(func(_, username []byte) error {
+            v := byName.Get(username)
+            if v == nil {
+                return nil
+            }
+            var u User
+            if err := json.Unmarshal(v, &u); err != nil {
+                return fmt.Errorf("unmarshal user: %w", err)
+            }
+            result = append(result, u)
+            return nil
+        })
+    })
+    if err != nil {
+        return nil, fmt.Errorf("list users: %w", err)
+    }
+    return result, nil
+}
+
+// SetRole changes the user's role.
+func (s *UserStore) SetRole(userID int, role string) error {
+    if !ValidRole(role) {
+        return fmt.Errorf("invalid role %q", role)
+    }
+    return s.update(userID, func(u *User) {
+        u.Role = role
+    })
+}
+
 // ChangePassword updates the user's password.
 func (s *UserStore) ChangePassword(userID int, newPassword string) error {
     hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcryptCost)
@@ -179,6 +250,13 @@ func (s *UserStore) ChangePassword(userID int, newPassword string) error {
         return fmt.Errorf("hash password: %w", err)
     }
 
+    return s.update(userID, func(u *User) {
+        u.Password = string(hash)
+    })
+}
+
+// update applies fn to the stored user with the given ID.
+func (s *UserStore) update(userID int, fn func(u *User)) error {
     return s.db.Update(func(tx *bolt.Tx) error {
         // Look up username from ID
         idKey := itob(uint64(userID))
@@ -198,7 +276,7 @@ func (s *UserStore) ChangePassword(userID int, newPassword string) error {
             return fmt.Errorf("unmarshal user: %w", err)
         }
 
-        u.Password = string(hash)
+        fn(&u)
 
         data, err := json.Marshal(&u)
         if err != nil {
@@ -0,0 +1,195 @@
+// Package notify sends notifications of what happens to stacks, such as a
+// container dying or an image update, through webhooks, Slack, Telegram or
+// email.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kind is what a notification is about.
+type Kind string
+
+const (
+	KindContainerDie       Kind = "containerDie"
+	KindContainerUnhealthy Kind = "containerUnhealthy"
+	KindImageUpdate        Kind = "imageUpdate"
+	KindDeploySuccess      Kind = "deploySuccess"
+	KindDeployFailure      Kind = "deployFailure"
+	KindDiskSpace          Kind = "diskSpace"
+	KindHostReboot         Kind = "hostReboot"
+	KindTest               Kind = "test"
+)
+
+// Kinds lists the kinds that can be turned on or off.
+var Kinds = []Kind{
+	KindContainerDie,
+	KindContainerUnhealthy,
+	KindImageUpdate,
+	KindDeploySuccess,
+	KindDeployFailure,
+	KindDiskSpace,
+	KindHostReboot,
+}
+
+// Event is a notification.
+type Event struct {
+	Kind      Kind      `json:"kind"`
+	Title     string    `json:"title"`
+	Message   string    `json:"message"`
+	Stack     string    `json:"stack,omitempty"`
+	Container string    `json:"container,omitempty"`
+	Host      string    `json:"host,omitempty"` // the Dockge server, for setups with several
+	Time      time.Time `json:"time"`
+}
+
+// Text renders the event as plain text, for providers without structure.
+func (e Event) Text() string {
+	var b strings.Builder
+	b.WriteString(e.Title)
+	if e.Host != "" {
+		b.WriteString(" [" + e.Host + "]")
+	}
+	if e.Message != "" {
+		b.WriteString("\n" + e.Message)
+	}
+	return b.String()
+}
+
+// Provider delivers notifications to one destination.
+type Provider interface {
+	Name() string
+	Send(ctx context.Context, e Event) error
+}
+
+// Config is what to notify about and where.
+type Config struct {
+	Providers []Provider
+	Kinds     map[Kind]bool // kinds sent; test events always are
+	Host      string
+}
+
+// sendTimeout bounds the delivery of one event to one provider.
+const sendTimeout = 15 * time.Second
+
+// queueSize is how many events wait for delivery before new ones are
+// dropped.
+const queueSize = 64
+
+// Cooldown is how long a repeat of an event (same kind, stack and
+// container) is held back, so a container in a restart loop doesn't flood
+// the destinations.
+const Cooldown = 10 * time.Minute
+
+// Notifier delivers events in the background to the providers of the
+// current config.
+type Notifier struct {
+	config func() Config
+	queue  chan Event
+	now    func() time.Time
+
+	mu   sync.Mutex
+	last map[string]time.Time // by repeat key
+}
+
+// New creates a notifier. config is read for every event, so settings
+// changes apply at once. Events are delivered once Start is called.
+func New(config func() Config) *Notifier {
+	return &Notifier{
+		config: config,
+		queue:  make(chan Event, queueSize),
+		now:    time.Now,
+		last:   make(map[string]time.Time),
+	}
+}
+
+// Start delivers queued events until ctx is cancelled.
+func (n *Notifier) Start(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e := <-n.queue:
+				cfg := n.config()
+				if err := send(ctx, cfg, e); err != nil {
+					slog.Warn("notify", "kind", e.Kind, "err", err)
+				}
+			}
+		}
+	}()
+}
+
+// Notify queues an event if its kind is on and it isn't a repeat within
+// Cooldown. It never blocks: events are dropped when the queue is full.
+func (n *Notifier) Notify(e Event) {
+	if n == nil {
+		return
+	}
+	cfg := n.config()
+	if len(cfg.Providers) == 0 || !cfg.Kinds[e.Kind] {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = n.now()
+	}
+
+	key := string(e.Kind) + "/" + e.Stack + "/" + e.Container
+	n.mu.Lock()
+	if last, ok := n.last[key]; ok && e.Time.Sub(last) < Cooldown {
+		n.mu.Unlock()
+		return
+	}
+	n.last[key] = e.Time
+	for k, t := range n.last {
+		if e.Time.Sub(t) >= Cooldown {
+			delete(n.last, k)
+		}
+	}
+	n.mu.Unlock()
+
+	select {
+	case n.queue <- e:
+	default:
+		slog.Warn("notify: queue full, dropping event", "kind", e.Kind)
+	}
+}
+
+// Test sends a test event to every configured provider now and returns
+// their errors.
+func (n *Notifier) Test(ctx context.Context) error {
+	cfg := n.config()
+	if len(cfg.Providers) == 0 {
+		return errors.New("no notification providers are configured")
+	}
+	return send(ctx, cfg, Event{
+		Kind:    KindTest,
+		Title:   "Dockge test notification",
+		Message: "Notifications are set up.",
+		Time:    n.now(),
+	})
+}
+
+// send delivers an event to every provider of cfg, each with its own
+// timeout, and joins their errors.
+func send(ctx context.Context, cfg Config, e Event) error {
+	if e.Host == "" {
+		e.Host = cfg.Host
+	}
+	var errs []error
+	for _, p := range cfg.Providers {
+		pctx, cancel := context.WithTimeout(ctx, sendTimeout)
+		err := p.Send(pctx, e)
+		cancel()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
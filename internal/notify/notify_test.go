@@ -0,0 +1,154 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/safehttp"
+)
+
+// recorder is a provider that keeps what it's sent.
+type recorder struct {
+	mu     sync.Mutex
+	events []Event
+	err    error
+}
+
+func (r *recorder) Name() string { return "recorder" }
+
+func (r *recorder) Send(_ context.Context, e Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+	return r.err
+}
+
+func (r *recorder) titles() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var titles []string
+	for _, e := range r.events {
+		titles = append(titles, e.Title)
+	}
+	return titles
+}
+
+func TestNotifierFiltersAndHoldsBackRepeats(t *testing.T) {
+	t.Parallel()
+	rec := &recorder{}
+	n := New(func() Config {
+		return Config{Providers: []Provider{rec}, Kinds: map[Kind]bool{KindContainerDie: true}, Host: "box"}
+	})
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	n.now = func() time.Time { return now }
+
+	n.Notify(Event{Kind: KindContainerDie, Title: "a died", Stack: "web", Container: "a"})
+	n.Notify(Event{Kind: KindContainerDie, Title: "a died again", Stack: "web", Container: "a"})
+	n.Notify(Event{Kind: KindContainerDie, Title: "b died", Stack: "web", Container: "b"})
+	n.Notify(Event{Kind: KindImageUpdate, Title: "update"}) // kind off
+	now = now.Add(Cooldown)
+	n.Notify(Event{Kind: KindContainerDie, Title: "a died later", Stack: "web", Container: "a"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	n.Start(ctx)
+	want := "a died,b died,a died later"
+	for range 100 {
+		if strings.Join(rec.titles(), ",") == want {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := strings.Join(rec.titles(), ","); got != want {
+		t.Fatalf("sent %q, want %q", got, want)
+	}
+	if e := rec.events[0]; e.Host != "box" || !e.Time.Equal(now.Add(-Cooldown)) {
+		t.Errorf("event = %+v, want host and time filled in", e)
+	}
+}
+
+func TestNotifierTest(t *testing.T) {
+	t.Parallel()
+	if err := New(func() Config { return Config{} }).Test(context.Background()); err == nil {
+		t.Error("Test without providers succeeded")
+	}
+	ok, failing := &recorder{}, &recorder{err: errors.New("boom")}
+	err := New(func() Config { return Config{Providers: []Provider{ok, failing}} }).Test(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "recorder: boom") {
+		t.Errorf("err = %v", err)
+	}
+	if len(ok.events) != 1 || ok.events[0].Kind != KindTest {
+		t.Errorf("test event not sent: %+v", ok.events)
+	}
+}
+
+func TestHTTPProviders(t *testing.T) {
+	t.Parallel()
+	// The test server is on loopback, which the client rejects
+	defer safehttp.AllowLoopback()()
+	var mu sync.Mutex
+	got := map[string]map[string]any{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		got[r.URL.Path] = body
+		mu.Unlock()
+		if r.URL.Path == "/fail" {
+			http.Error(w, "nope", http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	e := Event{Kind: KindDeployFailure, Title: "Deploy failed", Message: "exit 1", Stack: "web", Host: "box"}
+	ctx := context.Background()
+	if err := (&Webhook{URL: srv.URL + "/hook"}).Send(ctx, e); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&Slack{URL: srv.URL + "/slack"}).Send(ctx, e); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&Telegram{Token: "s3cret", ChatID: "42", API: srv.URL}).Send(ctx, e); err != nil {
+		t.Fatal(err)
+	}
+
+	if h := got["/hook"]; h["kind"] != "deployFailure" || h["stack"] != "web" {
+		t.Errorf("webhook body = %v", h)
+	}
+	if s := got["/slack"]; s["text"] != "Deploy failed [box]\nexit 1" {
+		t.Errorf("slack body = %v", s)
+	}
+	if tg := got["/bots3cret/sendMessage"]; tg["chat_id"] != "42" || tg["text"] != "Deploy failed [box]\nexit 1" {
+		t.Errorf("telegram body = %v", tg)
+	}
+
+	err := (&Webhook{URL: srv.URL + "/fail"}).Send(ctx, e)
+	if err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("failing webhook: %v", err)
+	}
+	err = (&Telegram{Token: "s3cret", ChatID: "42", API: "http://127.0.0.1:1"}).Send(ctx, e)
+	if err == nil || strings.Contains(err.Error(), "s3cret") {
+		t.Errorf("telegram error leaks the token or is missing: %v", err)
+	}
+}
+
+func TestValidURL(t *testing.T) {
+	t.Parallel()
+	for _, u := range []string{"https://hooks.slack.com/services/x", "http://10.0.0.1:8080/hook"} {
+		if err := ValidURL(u); err != nil {
+			t.Errorf("ValidURL(%q) = %v", u, err)
+		}
+	}
+	for _, u := range []string{"", "hooks.slack.com", "ftp://x/y", "http://"} {
+		if ValidURL(u) == nil {
+			t.Errorf("ValidURL(%q) accepted", u)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/safehttp"
+)
+
+// httpClient sends the HTTP notifications; requests are bounded by their
+// context. It only connects to public addresses, so a notification URL
+// can't reach the host or its network.
+var httpClient = safehttp.NewClient(0, safehttp.Public)
+
+// postJSON posts body as JSON to url and fails on a non-2xx status.
+func postJSON(ctx context.Context, url string, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// Webhook posts events as JSON to a URL.
+type Webhook struct {
+	URL string
+}
+
+func (w *Webhook) Name() string { return "webhook" }
+
+func (w *Webhook) Send(ctx context.Context, e Event) error {
+	return postJSON(ctx, w.URL, e)
+}
+
+// Slack posts events to a Slack incoming webhook, or anything that takes
+// its {"text"} payload (Mattermost, Rocket.Chat).
+type Slack struct {
+	URL string
+}
+
+func (s *Slack) Name() string { return "slack" }
+
+func (s *Slack) Send(ctx context.Context, e Event) error {
+	return postJSON(ctx, s.URL, map[string]string{"text": e.Text()})
+}
+
+// telegramAPI is the Telegram Bot API base URL.
+const telegramAPI = "https://api.telegram.org"
+
+// Telegram sends events as messages of a bot to a chat.
+type Telegram struct {
+	Token  string
+	ChatID string
+	API    string // base URL, telegramAPI if empty
+}
+
+func (t *Telegram) Name() string { return "telegram" }
+
+func (t *Telegram) Send(ctx context.Context, e Event) error {
+	base := t.API
+	if base == "" {
+		base = telegramAPI
+	}
+	err := postJSON(ctx, base+"/bot"+t.Token+"/sendMessage", map[string]string{
+		"chat_id": t.ChatID,
+		"text":    e.Text(),
+	})
+	if err != nil {
+		// The URL holds the token; don't let it reach logs or the UI
+		return fmt.Errorf("%s", strings.ReplaceAll(err.Error(), t.Token, "***"))
+	}
+	return nil
+}
+
+// Email sends events by SMTP, with STARTTLS when the server offers it.
+type Email struct {
+	Host     string
+	Port     int
+	Username string // no auth if empty
+	Password string
+	From     string
+	To       []string
+}
+
+func (m *Email) Name() string { return "email" }
+
+func (m *Email) Send(ctx context.Context, e Event) error {
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", m.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(m.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", headerSafe(e.Title))
+	fmt.Fprintf(&msg, "Date: %s\r\n", e.Time.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(e.Text(), "\n", "\r\n"))
+	msg.WriteString("\r\n")
+
+	// smtp.SendMail takes no context; give up waiting when ctx is done
+	done := make(chan error, 1)
+	go func() { done <- smtp.SendMail(addr, auth, m.From, m.To, msg.Bytes()) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// headerSafe strips line breaks from a header value.
+func headerSafe(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
+
+// ValidURL checks that s is an http or https URL.
+func ValidURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid URL %q", s)
+	}
+	return nil
+}
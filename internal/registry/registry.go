@@ -0,0 +1,216 @@
+// Package registry lists image tags from OCI/Docker v2 registries.
+//
+// Only anonymous access is supported: public images on Docker Hub, GHCR
+// and similar registries work, private repositories return an error.
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	dockerHubRegistry = "registry-1.docker.io"
+
+	// tagCacheTTL keeps tag lists between update checks of stacks that
+	// share an image, sparing Docker Hub's anonymous rate limit.
+	tagCacheTTL = 10 * time.Minute
+
+	// maxTagPages bounds pagination for repositories with huge tag lists.
+	maxTagPages = 10
+)
+
+// Client lists repository tags. Safe for concurrent use.
+type Client struct {
+	http *http.Client
+
+	mu    sync.Mutex
+	cache map[string]cachedTags // "host/name" → tags
+}
+
+type cachedTags struct {
+	tags    []string
+	fetched time.Time
+}
+
+func NewClient() *Client {
+	return &Client{
+		http:  &http.Client{Timeout: 30 * time.Second},
+		cache: make(map[string]cachedTags),
+	}
+}
+
+// Tags returns the tags of the repository an image reference points at.
+// The reference's own tag and digest are ignored.
+func (c *Client) Tags(ctx context.Context, imageRef string) ([]string, error) {
+	host, name := ParseRepository(imageRef)
+	key := host + "/" + name
+
+	c.mu.Lock()
+	if e, ok := c.cache[key]; ok && time.Since(e.fetched) < tagCacheTTL {
+		c.mu.Unlock()
+		return e.tags, nil
+	}
+	c.mu.Unlock()
+
+	tags, err := c.fetchTags(ctx, host, name)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.cache[key] = cachedTags{tags: tags, fetched: time.Now()}
+	c.mu.Unlock()
+	return tags, nil
+}
+
+// ParseRepository splits an image reference into registry host and
+// repository name, applying Docker Hub's defaults: "nginx:1.25" is
+// ("registry-1.docker.io", "library/nginx").
+func ParseRepository(imageRef string) (host, name string) {
+	ref := imageRef
+	if i := strings.Index(ref, "@"); i >= 0 {
+		ref = ref[:i]
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref = ref[:i]
+	}
+	host = dockerHubRegistry
+	if i := strings.Index(ref, "/"); i >= 0 {
+		first := ref[:i]
+		if strings.ContainsAny(first, ".:") || first == "localhost" {
+			host, ref = first, ref[i+1:]
+		}
+	}
+	if host == "docker.io" || host == "index.docker.io" {
+		host = dockerHubRegistry
+	}
+	if host == dockerHubRegistry && !strings.Contains(ref, "/") {
+		ref = "library/" + ref
+	}
+	return host, ref
+}
+
+// fetchTags pages through /v2/<name>/tags/list, fetching an anonymous
+// bearer token the first time the registry asks for one.
+func (c *Client) fetchTags(ctx context.Context, host, name string) ([]string, error) {
+	next := "https://" + host + "/v2/" + name + "/tags/list?n=1000"
+	token := ""
+	var tags []string
+	for page := 0; next != "" && page < maxTagPages; page++ {
+		resp, err := c.get(ctx, next, token)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusUnauthorized && token == "" {
+			challenge := resp.Header.Get("WWW-Authenticate")
+			resp.Body.Close()
+			if token, err = c.fetchToken(ctx, challenge); err != nil {
+				return nil, err
+			}
+			if resp, err = c.get(ctx, next, token); err != nil {
+				return nil, err
+			}
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("list tags of %s/%s: %s", host, name, resp.Status)
+		}
+		var body struct {
+			Tags []string `json:"tags"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("decode tags of %s/%s: %w", host, name, err)
+		}
+		tags = append(tags, body.Tags...)
+		next = nextLink(next, resp.Header.Get("Link"))
+	}
+	return tags, nil
+}
+
+func (c *Client) get(ctx context.Context, rawURL, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("registry request: %w", err)
+	}
+	return resp, nil
+}
+
+var challengeParamRe = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// fetchToken answers a "Bearer realm=…,service=…,scope=…" challenge.
+func (c *Client) fetchToken(ctx context.Context, challenge string) (string, error) {
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		return "", errors.New("registry requires unsupported authentication")
+	}
+	params := map[string]string{}
+	for _, m := range challengeParamRe.FindAllStringSubmatch(challenge, -1) {
+		params[m[1]] = m[2]
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Scheme == "" {
+		return "", fmt.Errorf("invalid token realm %q", params["realm"])
+	}
+	q := realm.Query()
+	for _, k := range []string{"service", "scope"} {
+		if params[k] != "" {
+			q.Set(k, params[k])
+		}
+	}
+	realm.RawQuery = q.Encode()
+
+	resp, err := c.get(ctx, realm.String(), "")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("registry token: %s", resp.Status)
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("decode registry token: %w", err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
+
+var linkNextRe = regexp.MustCompile(`<([^>]+)>;\s*rel="?next"?`)
+
+// nextLink resolves a Link: <…>; rel="next" header against the current URL.
+func nextLink(current, header string) string {
+	m := linkNextRe.FindStringSubmatch(header)
+	if m == nil {
+		return ""
+	}
+	base, err := url.Parse(current)
+	if err != nil {
+		return ""
+	}
+	ref, err := url.Parse(m[1])
+	if err != nil {
+		return ""
+	}
+	return base.ResolveReference(ref).String()
+}
@@ -0,0 +1,66 @@
+package registry
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRepository(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		ref, host, name string
+	}{
+		{"nginx", dockerHubRegistry, "library/nginx"},
+		{"nginx:1.25@sha256:abc", dockerHubRegistry, "library/nginx"},
+		{"grafana/grafana:10.0.0", dockerHubRegistry, "grafana/grafana"},
+		{"docker.io/library/redis:7", dockerHubRegistry, "library/redis"},
+		{"ghcr.io/org/app:v1", "ghcr.io", "org/app"},
+		{"registry.local:5000/app:2.4.1", "registry.local:5000", "app"},
+		{"localhost/app", "localhost", "app"},
+	}
+	for _, tt := range tests {
+		host, name := ParseRepository(tt.ref)
+		if host != tt.host || name != tt.name {
+			t.Errorf("ParseRepository(%q) = %q, %q; want %q, %q", tt.ref, host, name, tt.host, tt.name)
+		}
+	}
+}
+
+func TestTagsTokenAndPagination(t *testing.T) {
+	t.Parallel()
+	var srv *httptest.Server
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			if r.URL.Query().Get("scope") != "repository:app:pull" {
+				http.Error(w, "bad scope", http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"token": "tok"})
+		case r.Header.Get("Authorization") != "Bearer tok":
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+srv.URL+`/token",service="test",scope="repository:app:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Query().Get("last") == "":
+			w.Header().Set("Link", `</v2/app/tags/list?n=1000&last=1.0.1>; rel="next"`)
+			json.NewEncoder(w).Encode(map[string][]string{"tags": {"1.0.0", "1.0.1"}})
+		default:
+			json.NewEncoder(w).Encode(map[string][]string{"tags": {"1.1.0"}})
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient()
+	c.http = srv.Client()
+	ref := strings.TrimPrefix(srv.URL, "https://") + "/app:1.0.0"
+	tags, err := c.Tags(t.Context(), ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"1.0.0", "1.0.1", "1.1.0"}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("Tags = %v, want %v", tags, want)
+	}
+}
@@ -0,0 +1,93 @@
+// Package safehttp makes HTTP clients for URLs users give, such as stack
+// files to fetch and webhooks to call, that only connect to public
+// addresses, so a URL can't be used to reach the host or its network.
+package safehttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// maxRedirects bounds the redirects followed for one request.
+const maxRedirects = 10
+
+// loopbackAllowed counts the AllowLoopback calls not yet restored.
+var loopbackAllowed atomic.Int32
+
+// NewClient returns a client that only connects to addresses allowed
+// accepts, checked after DNS resolution so a URL can't reach the host or
+// its network through a name, and checks every redirect again. It ignores
+// proxy settings, since a proxy would connect on its behalf. A zero timeout
+// leaves requests bounded by their context only.
+func NewClient(timeout time.Duration, allowed func(net.IP) bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !allowed(ip) {
+				return fmt.Errorf("%s is not a public address", host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return errors.New("too many redirects")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to %q: only http and https URLs are supported", req.URL.Redacted())
+			}
+			return CheckHost(req.Context(), req.URL.Hostname(), allowed)
+		},
+	}
+}
+
+// CheckHost resolves host and fails unless allowed accepts all of its
+// addresses.
+func CheckHost(ctx context.Context, host string, allowed func(net.IP) bool) error {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !allowed(addr.IP) {
+			return fmt.Errorf("%s resolves to %s, which is not a public address", host, addr.IP)
+		}
+	}
+	return nil
+}
+
+// IsPublicIP reports whether ip is neither loopback, private, link-local
+// nor unspecified.
+func IsPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast()
+}
+
+// Public is what clients of user-given URLs accept: public addresses, and
+// loopback ones too while AllowLoopback is in effect.
+func Public(ip net.IP) bool {
+	return IsPublicIP(ip) || (ip.IsLoopback() && loopbackAllowed.Load() > 0)
+}
+
+// AllowLoopback makes Public accept loopback addresses until the returned
+// func is called. It's for tests, whose servers listen on loopback.
+func AllowLoopback() (restore func()) {
+	loopbackAllowed.Add(1)
+	return func() { loopbackAllowed.Add(-1) }
+}
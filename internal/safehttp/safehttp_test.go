@@ -0,0 +1,64 @@
+package safehttp
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewClientRejectsPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/private":
+			http.Redirect(w, r, "http://10.0.0.1/compose.yaml", http.StatusFound)
+		case "/file":
+			http.Redirect(w, r, "file:///etc/passwd", http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+
+	if resp, err := NewClient(0, Public).Get(srv.URL); err == nil || !strings.Contains(err.Error(), "not a public address") {
+		if err == nil {
+			resp.Body.Close()
+		}
+		t.Errorf("loopback reached: %v", err)
+	}
+
+	// Loopback is allowed only until restored
+	restore := AllowLoopback()
+	resp, err := NewClient(0, Public).Get(srv.URL)
+	if err != nil {
+		t.Errorf("loopback with AllowLoopback: %v", err)
+	} else {
+		resp.Body.Close()
+	}
+	restore()
+	if Public(net.ParseIP("127.0.0.1")) {
+		t.Error("loopback still allowed after restore")
+	}
+
+	// Redirects are checked even when the first address was allowed
+	client := NewClient(0, net.IP.IsLoopback)
+	for _, path := range []string{"/private", "/file"} {
+		resp, err := client.Get(srv.URL + path)
+		if err == nil {
+			resp.Body.Close()
+			t.Errorf("%s: redirect followed", path)
+		}
+	}
+}
+
+func TestIsPublicIP(t *testing.T) {
+	t.Parallel()
+	for ip, want := range map[string]bool{
+		"93.184.216.34": true, "2606:4700::1111": true,
+		"127.0.0.1": false, "10.1.2.3": false, "192.168.1.1": false, "169.254.169.254": false,
+		"0.0.0.0": false, "::1": false, "fe80::1": false, "fd00::1": false, "::ffff:127.0.0.1": false,
+	} {
+		if got := IsPublicIP(net.ParseIP(ip)); got != want {
+			t.Errorf("IsPublicIP(%s) = %v, want %v", ip, got, want)
+		}
+	}
+}
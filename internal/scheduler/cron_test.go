@@ -0,0 +1,62 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q): expected error", expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	base := time.Date(2026, 3, 10, 14, 7, 30, 0, time.UTC) // Tuesday
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 3, 10, 14, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 3, 10, 14, 15, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2026, 3, 11, 3, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 3, 11, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 3, 10, 15, 0, 0, 0, time.UTC)},
+		{"30 4 * * sun", time.Date(2026, 3, 15, 4, 30, 0, 0, time.UTC)},
+		{"30 4 * * 7", time.Date(2026, 3, 15, 4, 30, 0, 0, time.UTC)},
+		{"0 22 * * mon-fri", time.Date(2026, 3, 10, 22, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 8-18/4 * * *", time.Date(2026, 3, 10, 16, 0, 0, 0, time.UTC)},
+		{"10,50 14 * * *", time.Date(2026, 3, 10, 14, 10, 0, 0, time.UTC)},
+		// Day of month OR day of week when both are restricted
+		{"0 0 20 * fri", time.Date(2026, 3, 13, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		c, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := c.Next(base); !got.Equal(tt.want) {
+			t.Errorf("Next(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+		if !tt.want.IsZero() && !c.Matches(tt.want) {
+			t.Errorf("%q does not match its own next run %v", tt.expr, tt.want)
+		}
+	}
+}
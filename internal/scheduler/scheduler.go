@@ -0,0 +1,166 @@
+// Package scheduler runs compose actions on stacks at times given by cron
+// expressions. Schedules are kept in a models.StackScheduleStore; the
+// actions themselves are run by a caller-supplied Runner.
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/models"
+)
+
+// Schedulable actions.
+const (
+	ActionRestart = "restart" // docker compose restart
+	ActionUpdate  = "update"  // pull, then up -d
+	ActionStart   = "start"   // up -d
+	ActionStop    = "stop"    // docker compose stop
+	ActionDown    = "down"    // docker compose down
+)
+
+// ValidAction reports whether action can be scheduled.
+func ValidAction(action string) bool {
+	switch action {
+	case ActionRestart, ActionUpdate, ActionStart, ActionStop, ActionDown:
+		return true
+	}
+	return false
+}
+
+// Runner runs a schedule's action and blocks until it finishes.
+type Runner func(sch models.StackSchedule) error
+
+// Status is a schedule with its run state, as shown in the UI.
+type Status struct {
+	models.StackSchedule
+	NextRun int64  `json:"nextRun"` // Unix seconds, 0 if disabled or never due
+	Running bool   `json:"running"`
+	Invalid string `json:"invalid,omitempty"` // cron parse error
+}
+
+// Scheduler checks the schedules at the start of every minute and runs the
+// due ones. Runs missed while Dockge was down are not caught up.
+type Scheduler struct {
+	store    *models.StackScheduleStore
+	run      Runner
+	onChange func() // called when a run starts or finishes
+
+	mu      sync.Mutex
+	running map[int]bool // schedule ID → run in progress
+}
+
+// New creates a scheduler. onChange may be nil.
+func New(store *models.StackScheduleStore, run Runner, onChange func()) *Scheduler {
+	if onChange == nil {
+		onChange = func() {}
+	}
+	return &Scheduler{store: store, run: run, onChange: onChange, running: make(map[int]bool)}
+}
+
+// Start checks schedules at every minute boundary until ctx is cancelled.
+func (s *Scheduler) Start(ctx context.Context) {
+	go func() {
+		for {
+			now := time.Now()
+			next := now.Truncate(time.Minute).Add(time.Minute)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(next.Sub(now)):
+				s.Tick(next)
+			}
+		}
+	}()
+}
+
+// Tick starts every enabled schedule that fires in now's minute and isn't
+// still running from an earlier tick.
+func (s *Scheduler) Tick(now time.Time) {
+	schedules, err := s.store.List()
+	if err != nil {
+		slog.Warn("scheduler: list schedules", "err", err)
+		return
+	}
+	for _, sch := range schedules {
+		if !sch.Enabled {
+			continue
+		}
+		c, err := Parse(sch.Cron)
+		if err != nil || !c.Matches(now) {
+			continue
+		}
+		s.RunNow(sch)
+	}
+}
+
+// RunNow runs a schedule in the background and records the outcome.
+// Returns false if the schedule is already running.
+func (s *Scheduler) RunNow(sch models.StackSchedule) bool {
+	s.mu.Lock()
+	if s.running[sch.ID] {
+		s.mu.Unlock()
+		slog.Warn("scheduler: previous run still in progress", "schedule", sch.ID, "stack", sch.StackName)
+		return false
+	}
+	s.running[sch.ID] = true
+	s.mu.Unlock()
+	s.onChange()
+
+	go func() {
+		started := time.Now()
+		slog.Info("scheduled action", "schedule", sch.ID, "stack", sch.StackName, "action", sch.Action)
+		runErr := s.run(sch)
+
+		s.mu.Lock()
+		delete(s.running, sch.ID)
+		s.mu.Unlock()
+
+		// Re-read so edits made during the run aren't overwritten
+		cur, err := s.store.Get(sch.ID)
+		if err == nil && cur != nil {
+			cur.LastRun = started.Unix()
+			cur.LastError = ""
+			if runErr != nil {
+				cur.LastError = runErr.Error()
+			}
+			err = s.store.Update(cur)
+		}
+		if err != nil {
+			slog.Warn("scheduler: record run", "schedule", sch.ID, "err", err)
+		}
+		if runErr != nil {
+			slog.Error("scheduled action failed", "schedule", sch.ID, "stack", sch.StackName, "action", sch.Action, "err", runErr)
+		}
+		s.onChange()
+	}()
+	return true
+}
+
+// Statuses returns every schedule with its next run time and whether it
+// is running.
+func (s *Scheduler) Statuses() ([]Status, error) {
+	schedules, err := s.store.List()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make([]Status, 0, len(schedules))
+	for _, sch := range schedules {
+		st := Status{StackSchedule: sch, Running: s.running[sch.ID]}
+		c, err := Parse(sch.Cron)
+		if err != nil {
+			st.Invalid = err.Error()
+		} else if sch.Enabled {
+			if next := c.Next(now); !next.IsZero() {
+				st.NextRun = next.Unix()
+			}
+		}
+		result = append(result, st)
+	}
+	return result, nil
+}
@@ -0,0 +1,135 @@
+package scheduler
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/db"
+	"github.com/cfilipov/dockge/internal/models"
+)
+
+func TestSchedulerTick(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	store := models.NewStackScheduleStore(database)
+
+	nightly := &models.StackSchedule{StackName: "web", Action: ActionRestart, Cron: "0 3 * * *", Enabled: true}
+	disabled := &models.StackSchedule{StackName: "db", Action: ActionStop, Cron: "0 3 * * *"}
+	failing := &models.StackSchedule{StackName: "app", Action: ActionUpdate, Cron: "0 3 * * *", Enabled: true}
+	hourly := &models.StackSchedule{StackName: "cache", Action: ActionRestart, Cron: "@hourly", Enabled: true}
+	for _, sch := range []*models.StackSchedule{nightly, disabled, failing, hourly} {
+		if err := store.Create(sch); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ran := make(chan string, 4)
+	changed := make(chan struct{}, 16)
+	s := New(store, func(sch models.StackSchedule) error {
+		ran <- sch.StackName
+		if sch.StackName == "app" {
+			return errors.New("pull failed")
+		}
+		return nil
+	}, func() { changed <- struct{}{} })
+
+	s.Tick(time.Date(2026, 3, 10, 3, 0, 0, 0, time.Local))
+
+	got := map[string]bool{}
+	for range 3 {
+		select {
+		case name := <-ran:
+			got[name] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out, ran %v", got)
+		}
+	}
+	if !got["web"] || !got["app"] || !got["cache"] {
+		t.Errorf("ran %v, want web, app and cache", got)
+	}
+
+	// Each run reports its start and finish
+	for range 6 {
+		select {
+		case <-changed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for run results")
+		}
+	}
+
+	sch, err := store.Get(failing.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sch.LastRun == 0 || sch.LastError != "pull failed" {
+		t.Errorf("failing schedule: lastRun=%d lastError=%q", sch.LastRun, sch.LastError)
+	}
+	sch, err = store.Get(disabled.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sch.LastRun != 0 {
+		t.Error("disabled schedule ran")
+	}
+
+	statuses, err := s.Statuses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, st := range statuses {
+		if st.Enabled && st.NextRun == 0 {
+			t.Errorf("schedule %d: no next run", st.ID)
+		}
+		if !st.Enabled && st.NextRun != 0 {
+			t.Errorf("disabled schedule %d has a next run", st.ID)
+		}
+	}
+}
+
+func TestSchedulerSkipsRunning(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	store := models.NewStackScheduleStore(database)
+	sch := &models.StackSchedule{StackName: "web", Action: ActionRestart, Cron: "* * * * *", Enabled: true}
+	if err := store.Create(sch); err != nil {
+		t.Fatal(err)
+	}
+
+	release := make(chan struct{})
+	changed := make(chan struct{}, 4)
+	s := New(store, func(models.StackSchedule) error {
+		<-release
+		return nil
+	}, func() { changed <- struct{}{} })
+
+	if !s.RunNow(*sch) {
+		t.Fatal("first run refused")
+	}
+	if s.RunNow(*sch) {
+		t.Error("second run started while the first is still running")
+	}
+	close(release)
+
+	// Started, then finished
+	for range 2 {
+		select {
+		case <-changed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("run did not finish")
+		}
+	}
+	if !s.RunNow(*sch) {
+		t.Error("run refused after the previous one finished")
+	}
+	for range 2 {
+		<-changed
+	}
+}
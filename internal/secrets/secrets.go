@@ -0,0 +1,158 @@
+// Package secrets resolves secrets that env files only refer to, so they
+// never live in plain text in the stacks directory: placeholders such as
+// vault:secret/app#password, and .env files encrypted with SOPS.
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// SchemeVault prefixes references to HashiCorp Vault secrets.
+const SchemeVault = "vault"
+
+// Provider looks up secrets of one reference scheme.
+type Provider interface {
+	// Resolve returns the secret ref points to; ref is the part of the
+	// placeholder after "scheme:".
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// Resolver turns env files holding secret references into plain env files.
+type Resolver struct {
+	Providers map[string]Provider // by scheme
+	SOPS      string              // sops binary; "" = "sops" from PATH
+}
+
+// NewResolver returns a resolver of Vault references and SOPS files.
+func NewResolver(vault *Vault) *Resolver {
+	return &Resolver{Providers: map[string]Provider{SchemeVault: vault}}
+}
+
+// IsReference reports whether an env value stands for a secret rather than
+// being one: a placeholder of a known scheme, or a SOPS-encrypted value.
+// Containers see something else than the env file says for these.
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, SchemeVault+":") || strings.HasPrefix(value, "ENC[")
+}
+
+// IsSOPS reports whether an env file was encrypted with SOPS, which adds
+// its metadata as sops_* variables.
+func IsSOPS(data []byte) bool {
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "sops_version=") {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveFile reads an env file, decrypting it if SOPS-encrypted and
+// replacing secret references. changed is false if the file holds no
+// secrets to resolve, in which case it can be used as is.
+func (r *Resolver) ResolveFile(ctx context.Context, path string) (data []byte, changed bool, err error) {
+	data, err = os.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	if IsSOPS(data) {
+		if data, err = r.decrypt(ctx, path); err != nil {
+			return nil, false, err
+		}
+		changed = true
+	}
+	text, resolved, err := r.ResolveEnv(ctx, string(data))
+	if err != nil {
+		return nil, false, err
+	}
+	return []byte(text), changed || resolved, nil
+}
+
+// decrypt runs sops on an encrypted env file.
+func (r *Resolver) decrypt(ctx context.Context, path string) ([]byte, error) {
+	bin := r.SOPS
+	if bin == "" {
+		bin = "sops"
+	}
+	cmd := exec.CommandContext(ctx, bin, "--decrypt", "--input-type", "dotenv", "--output-type", "dotenv", path)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, errors.New("the file is encrypted with SOPS, but sops is not installed")
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("sops: %s", msg)
+		}
+		return nil, fmt.Errorf("sops: %w", err)
+	}
+	return out, nil
+}
+
+// ResolveEnv replaces each variable of env file text whose value is a
+// placeholder of a known scheme with the secret, and reports whether any
+// was. Other lines are kept as they are.
+func (r *Resolver) ResolveEnv(ctx context.Context, text string) (string, bool, error) {
+	lines := strings.Split(text, "\n")
+	changed := false
+	for i, line := range lines {
+		key, value, ok := envLine(line)
+		if !ok {
+			continue
+		}
+		scheme, ref, ok := strings.Cut(value, ":")
+		if !ok {
+			continue
+		}
+		p := r.Providers[scheme]
+		if p == nil {
+			continue
+		}
+		secret, err := p.Resolve(ctx, ref)
+		if err != nil {
+			return "", false, fmt.Errorf("%s: %w", key, err)
+		}
+		lines[i] = key + "=" + quoteEnvValue(secret)
+		changed = true
+	}
+	return strings.Join(lines, "\n"), changed, nil
+}
+
+// envLine returns the name and unquoted value of a KEY=VALUE line, with an
+// optional "export " and an inline " # comment" after an unquoted value.
+func envLine(line string) (key, value string, ok bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", "", false
+	}
+	line = strings.TrimPrefix(line, "export ")
+	key, value, ok = strings.Cut(line, "=")
+	if !ok {
+		return "", "", false
+	}
+	key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return key, value[1 : len(value)-1], true
+	}
+	if i := strings.IndexAny(value, " \t"); i >= 0 && strings.HasPrefix(strings.TrimSpace(value[i:]), "#") {
+		value = value[:i]
+	}
+	return key, value, true
+}
+
+// quoteEnvValue quotes a secret for an env file so compose reads it back
+// verbatim: single quotes keep it literal; values holding one, or a line
+// break, are double-quoted with escapes.
+func quoteEnvValue(s string) string {
+	if !strings.ContainsAny(s, "'\n\r") {
+		return "'" + s + "'"
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "\n", `\n`, "\r", `\r`)
+	return `"` + r.Replace(s) + `"`
+}
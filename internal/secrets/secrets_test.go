@@ -0,0 +1,144 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type fakeProvider map[string]string
+
+func (f fakeProvider) Resolve(_ context.Context, ref string) (string, error) {
+	if v, ok := f[ref]; ok {
+		return v, nil
+	}
+	return "", errors.New("no secret " + ref)
+}
+
+func TestResolveEnv(t *testing.T) {
+	t.Parallel()
+	r := &Resolver{Providers: map[string]Provider{"vault": fakeProvider{
+		"secret/db#password": "s3cr3t",
+		"secret/db#quote":    "it's $HOME",
+		"secret/db#multi":    "a\nb",
+	}}}
+	in := "# database\n" +
+		"DB_USER=app\n" +
+		"DB_PASSWORD=vault:secret/db#password\n" +
+		"export QUOTED=\"vault:secret/db#quote\"\n" +
+		"MULTI=vault:secret/db#multi # comment\n" +
+		"URL=http://example.com\n"
+	want := "# database\n" +
+		"DB_USER=app\n" +
+		"DB_PASSWORD='s3cr3t'\n" +
+		"QUOTED=\"it's \\$HOME\"\n" +
+		"MULTI=\"a\\nb\"\n" +
+		"URL=http://example.com\n"
+
+	got, changed, err := r.ResolveEnv(context.Background(), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed || got != want {
+		t.Errorf("ResolveEnv = %q, %v\nwant %q, true", got, changed, want)
+	}
+
+	plain := "A=1\nB=two\n"
+	if got, changed, _ := r.ResolveEnv(context.Background(), plain); changed || got != plain {
+		t.Errorf("ResolveEnv(plain) = %q, %v", got, changed)
+	}
+
+	if _, _, err := r.ResolveEnv(context.Background(), "X=vault:secret/missing#k\n"); err == nil {
+		t.Error("expected an error for a missing secret")
+	}
+}
+
+func TestResolveFile(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	r := &Resolver{
+		Providers: map[string]Provider{"vault": fakeProvider{"kv/app#token": "abc"}},
+		SOPS:      filepath.Join(dir, "no-sops"),
+	}
+
+	path := filepath.Join(dir, ".env")
+	os.WriteFile(path, []byte("TOKEN=vault:kv/app#token\n"), 0o644)
+	data, changed, err := r.ResolveFile(context.Background(), path)
+	if err != nil || !changed || string(data) != "TOKEN='abc'\n" {
+		t.Errorf("ResolveFile = %q, %v, %v", data, changed, err)
+	}
+
+	os.WriteFile(path, []byte("TOKEN=ENC[AES256_GCM,data:x]\nsops_version=3.9.0\n"), 0o644)
+	if _, _, err := r.ResolveFile(context.Background(), path); err == nil {
+		t.Error("expected an error decrypting without sops")
+	}
+}
+
+func TestIsReference(t *testing.T) {
+	t.Parallel()
+	for value, want := range map[string]bool{
+		"vault:secret/app#key": true,
+		"ENC[AES256_GCM,data:": true,
+		"plain":                false,
+		"http://vault:8200":    false,
+	} {
+		if got := IsReference(value); got != want {
+			t.Errorf("IsReference(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestVault(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "tok" {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]any{"errors": []string{"permission denied"}})
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/app": // KV version 2
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{
+				"data":     map[string]any{"password": "v2pass", "port": 5432},
+				"metadata": map[string]any{"version": 3},
+			}})
+		case "/v1/kv/app": // KV version 1
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"password": "v1pass"}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer srv.Close()
+
+	v := &Vault{Addr: srv.URL, Token: "tok"}
+	ctx := context.Background()
+	for ref, want := range map[string]string{
+		"secret/app#password":      "v2pass",
+		"secret/data/app#password": "v2pass",
+		"secret/app#port":          "5432",
+		"kv/app#password":          "v1pass",
+	} {
+		got, err := v.Resolve(ctx, ref)
+		if err != nil || got != want {
+			t.Errorf("Resolve(%q) = %q, %v, want %q", ref, got, err, want)
+		}
+	}
+	for _, ref := range []string{"secret/app#missing", "secret/gone#key", "secret/app"} {
+		if _, err := v.Resolve(ctx, ref); err == nil {
+			t.Errorf("Resolve(%q): expected an error", ref)
+		}
+	}
+
+	if _, err := (&Vault{Addr: srv.URL, Token: "bad"}).Resolve(ctx, "secret/app#password"); err == nil {
+		t.Error("expected an error with a bad token")
+	}
+	if _, err := (&Vault{}).Resolve(ctx, "secret/app#password"); err == nil {
+		t.Error("expected an error without an address")
+	}
+}
@@ -0,0 +1,105 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Vault reads secrets from HashiCorp Vault's KV engine. A reference is
+// mount/path#key, e.g. secret/app#password; version 2 engines are tried
+// first (mount/data/path), then version 1.
+type Vault struct {
+	Addr  string // e.g. https://vault.example.com:8200; "" = not configured
+	Token string
+	// Client sends the requests; nil uses http.DefaultClient.
+	Client *http.Client
+}
+
+// errVaultNotFound is a missing secret path, for the version 1 fallback.
+var errVaultNotFound = errors.New("not found")
+
+// Resolve returns the key of a secret, for a mount/path#key reference.
+func (v *Vault) Resolve(ctx context.Context, ref string) (string, error) {
+	if v == nil || v.Addr == "" {
+		return "", errors.New("Vault is not configured (set --vault-addr and --vault-token)")
+	}
+	path, key, ok := strings.Cut(ref, "#")
+	path = strings.Trim(path, "/")
+	if !ok || key == "" || path == "" {
+		return "", fmt.Errorf("invalid Vault reference %q, want vault:mount/path#key", ref)
+	}
+
+	var data map[string]any
+	err := errVaultNotFound
+	if mount, rest, ok := strings.Cut(path, "/"); ok && !strings.HasPrefix(rest, "data/") {
+		var kv2 struct {
+			Data map[string]any `json:"data"`
+		}
+		if err = v.get(ctx, mount+"/data/"+rest, &kv2); err == nil {
+			data = kv2.Data
+		}
+	}
+	if errors.Is(err, errVaultNotFound) {
+		err = v.get(ctx, path, &data)
+		// A version 2 path given in full still nests the secret
+		if inner, ok := data["data"].(map[string]any); ok && strings.Contains(path, "/data/") {
+			data = inner
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("vault %s: %w", path, err)
+	}
+
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("vault %s: no key %q", path, key)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(value)
+	return string(b), err
+}
+
+// get reads a secret path and decodes its "data" into out.
+func (v *Vault) get(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(v.Addr, "/")+"/v1/"+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+	client := v.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return errVaultNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		var body struct {
+			Errors []string `json:"errors"`
+		}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&body)
+		if len(body.Errors) > 0 {
+			return fmt.Errorf("%s: %s", resp.Status, strings.Join(body.Errors, "; "))
+		}
+		return errors.New(resp.Status)
+	}
+	var body struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return json.Unmarshal(body.Data, out)
+}
@@ -0,0 +1,278 @@
+package stack
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Layout of a stack backup, a tar.gz:
+//
+//	manifest.json          BackupManifest
+//	files/...              the stack directory
+//	volumes/<key>.tar      contents of each named volume, if included
+const (
+	backupManifestName = "manifest.json"
+	backupFilesDir     = "files/"
+	backupVolumesDir   = "volumes/"
+	backupVersion      = 1
+)
+
+// BackupManifest describes a stack backup.
+type BackupManifest struct {
+	Version   int            `json:"version"`
+	Stack     string         `json:"stack"`
+	CreatedAt time.Time      `json:"createdAt"`
+	Volumes   []BackupVolume `json:"volumes,omitempty"`
+}
+
+// BackupVolume is a named volume whose contents are in a backup.
+type BackupVolume struct {
+	Key  string `json:"key"`  // name in the compose file
+	Name string `json:"name"` // Docker volume name when exported
+	File string `json:"-"`    // tar of its contents on the local disk
+}
+
+// WriteBackup writes a backup of a stack directory to w. The volumes' File
+// tars are added as they are. Files that can't be read, such as data owned
+// by a container's user, are left out and returned.
+func WriteBackup(w io.Writer, stacksDir, name string, volumes []BackupVolume) (skipped []string, err error) {
+	dir, err := filepath.EvalSymlinks(filepath.Join(stacksDir, name))
+	if err != nil {
+		return nil, err
+	}
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	manifest, err := json.MarshalIndent(BackupManifest{Version: backupVersion, Stack: name, CreatedAt: time.Now().UTC(), Volumes: volumes}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := WriteTarBytes(tw, backupManifestName, manifest); err != nil {
+		return nil, err
+	}
+
+	skipped, err = WriteTree(tw, dir, backupFilesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range volumes {
+		if err := WriteTarFile(tw, backupVolumesDir+v.Key+".tar", v.File); err != nil {
+			return nil, fmt.Errorf("volume %s: %w", v.Key, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return skipped, gz.Close()
+}
+
+// WriteTree adds the directories and regular files under dir to tw, named
+// with prefix. Symlinks and other special files are left out, as are files
+// that can't be read; the latter are returned.
+func WriteTree(tw *tar.Writer, dir, prefix string) (skipped []string, err error) {
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		rel, _ := filepath.Rel(dir, p)
+		if err != nil {
+			skipped = append(skipped, rel)
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if rel == "." || !(d.IsDir() || d.Type().IsRegular()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			skipped = append(skipped, rel)
+			return nil
+		}
+		hdr := &tar.Header{
+			Name:    prefix + filepath.ToSlash(rel),
+			Mode:    int64(info.Mode().Perm()),
+			ModTime: info.ModTime(),
+		}
+		if d.IsDir() {
+			hdr.Typeflag = tar.TypeDir
+			hdr.Name += "/"
+			return tw.WriteHeader(hdr)
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			skipped = append(skipped, rel)
+			return nil
+		}
+		defer f.Close()
+		hdr.Typeflag = tar.TypeReg
+		hdr.Size = info.Size()
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(tw, f, hdr.Size); err != nil {
+			return fmt.Errorf("%s: %w", rel, err)
+		}
+		return nil
+	})
+	return skipped, err
+}
+
+// WriteTarBytes adds a file with the given contents to tw.
+func WriteTarBytes(tw *tar.Writer, name string, data []byte) error {
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(data)), ModTime: time.Now()}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// WriteTarFile adds the contents of file to tw under name.
+func WriteTarFile(tw *tar.Writer, name, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: info.Size(), ModTime: info.ModTime()}); err != nil {
+		return err
+	}
+	_, err = io.CopyN(tw, f, info.Size())
+	return err
+}
+
+// ReadBackup extracts a stack backup: the stack's files into dir, which
+// must not exist, and each volume's tar into volumesDir, where the returned
+// manifest's File fields point. Entries that would escape their directory,
+// links and devices are rejected.
+func ReadBackup(r io.Reader, dir, volumesDir string) (*BackupManifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a stack backup: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return nil, err
+	}
+	var manifest *BackupManifest
+	volumeFiles := make(map[string]string)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read backup: %w", err)
+		}
+		name := hdr.Name
+		switch {
+		case name == backupManifestName:
+			manifest = &BackupManifest{}
+			if err := json.NewDecoder(io.LimitReader(tr, 1<<20)).Decode(manifest); err != nil {
+				return nil, fmt.Errorf("read backup manifest: %w", err)
+			}
+		case strings.HasPrefix(name, backupFilesDir):
+			if err := ExtractTarEntry(tr, hdr, dir, strings.TrimPrefix(name, backupFilesDir)); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(name, backupVolumesDir) && strings.HasSuffix(name, ".tar"):
+			key := strings.TrimSuffix(strings.TrimPrefix(name, backupVolumesDir), ".tar")
+			if key == "" || strings.ContainsAny(key, `/\`) || key == ".." {
+				return nil, fmt.Errorf("invalid volume in backup: %q", name)
+			}
+			if err := os.MkdirAll(volumesDir, 0700); err != nil {
+				return nil, err
+			}
+			file := filepath.Join(volumesDir, key+".tar")
+			if err := extractEntry(tr, hdr, file); err != nil {
+				return nil, err
+			}
+			volumeFiles[key] = file
+		}
+	}
+
+	if manifest == nil {
+		return nil, errors.New("not a stack backup: no manifest")
+	}
+	if manifest.Version != backupVersion {
+		return nil, fmt.Errorf("unsupported backup version %d", manifest.Version)
+	}
+	volumes := manifest.Volumes[:0]
+	for _, v := range manifest.Volumes {
+		if v.File = volumeFiles[v.Key]; v.File != "" {
+			volumes = append(volumes, v)
+		}
+	}
+	manifest.Volumes = volumes
+	return manifest, nil
+}
+
+// cleanBackupPath returns p as a local relative path, or false if it's
+// absolute or leaves its directory.
+func cleanBackupPath(p string) (string, bool) {
+	if p == "" {
+		return "", true
+	}
+	if strings.HasPrefix(p, "/") || strings.Contains(p, `\`) {
+		return "", false
+	}
+	clean := path.Clean(p)
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", false
+	}
+	if clean == "." {
+		return "", true
+	}
+	return filepath.FromSlash(clean), true
+}
+
+// ExtractTarEntry extracts the current entry of tr into dir as rel, a
+// slash-separated path. Paths that would escape dir, links and devices are
+// rejected.
+func ExtractTarEntry(tr *tar.Reader, hdr *tar.Header, dir, rel string) error {
+	clean, ok := cleanBackupPath(rel)
+	if !ok {
+		return fmt.Errorf("invalid path in backup: %q", hdr.Name)
+	}
+	if clean == "" {
+		return nil
+	}
+	return extractEntry(tr, hdr, filepath.Join(dir, clean))
+}
+
+func extractEntry(tr *tar.Reader, hdr *tar.Header, target string) error {
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		return os.MkdirAll(target, 0755)
+	case tar.TypeReg:
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		mode := os.FileMode(hdr.Mode).Perm() | 0600
+		f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, tr); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+	return fmt.Errorf("unsupported entry in backup: %q", hdr.Name)
+}
@@ -0,0 +1,69 @@
+package stack
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBackupRoundTrip(t *testing.T) {
+	t.Parallel()
+	base := t.TempDir()
+	stacksDir := filepath.Join(base, "stacks")
+	writeFile(t, filepath.Join(stacksDir, "web", "compose.yaml"), "services:\n  web:\n    image: nginx\n")
+	writeFile(t, filepath.Join(stacksDir, "web", ".env"), "TAG=1\n")
+	writeFile(t, filepath.Join(stacksDir, "web", "conf", "nginx.conf"), "worker_processes 1;\n")
+	volumeTar := filepath.Join(base, "data.tar")
+	writeFile(t, volumeTar, "volume contents")
+
+	var buf bytes.Buffer
+	skipped, err := WriteBackup(&buf, stacksDir, "web", []BackupVolume{{Key: "data", Name: "web_data", File: volumeTar}})
+	if err != nil || len(skipped) > 0 {
+		t.Fatalf("WriteBackup: skipped %v, %v", skipped, err)
+	}
+
+	dir := filepath.Join(base, "restored")
+	manifest, err := ReadBackup(&buf, dir, filepath.Join(base, "volumes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Stack != "web" || len(manifest.Volumes) != 1 || manifest.Volumes[0].Name != "web_data" {
+		t.Errorf("unexpected manifest %+v", manifest)
+	}
+	for file, want := range map[string]string{
+		"compose.yaml":    "services:\n  web:\n    image: nginx\n",
+		".env":            "TAG=1\n",
+		"conf/nginx.conf": "worker_processes 1;\n",
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil || string(got) != want {
+			t.Errorf("%s = %q, %v; want %q", file, got, err, want)
+		}
+	}
+	if got, _ := os.ReadFile(manifest.Volumes[0].File); string(got) != "volume contents" {
+		t.Errorf("volume tar = %q", got)
+	}
+}
+
+func TestReadBackupRejectsEscapes(t *testing.T) {
+	t.Parallel()
+	for _, name := range []string{"files/../../evil", "files//etc/passwd", "volumes/../x.tar"} {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gz)
+		WriteTarBytes(tw, backupManifestName, []byte(`{"version":1,"stack":"x"}`))
+		WriteTarBytes(tw, name, []byte("x"))
+		tw.Close()
+		gz.Close()
+
+		base := t.TempDir()
+		_, err := ReadBackup(&buf, filepath.Join(base, "x"), filepath.Join(base, "v"))
+		if err == nil || !strings.Contains(err.Error(), "invalid") {
+			t.Errorf("%s: expected rejection, got %v", name, err)
+		}
+	}
+}
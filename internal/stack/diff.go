@@ -0,0 +1,171 @@
+package stack
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// maxDiffLines caps the LCS table size. Inputs beyond this are reported as a
+// whole-file replacement rather than diffed line by line.
+const maxDiffLines = 5000
+
+// UnifiedDiff returns a unified diff of oldText → newText with the given file
+// name in the headers. Returns "" if the texts are identical.
+func UnifiedDiff(name, oldText, newText string) string {
+	if oldText == newText {
+		return ""
+	}
+	a := splitLines(oldText)
+	b := splitLines(newText)
+
+	var ops []diffOp
+	if len(a) > maxDiffLines || len(b) > maxDiffLines {
+		for i := range a {
+			ops = append(ops, diffOp{'-', a[i]})
+		}
+		for i := range b {
+			ops = append(ops, diffOp{'+', b[i]})
+		}
+	} else {
+		ops = lineDiff(a, b)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- a/%s\n+++ b/%s\n", name, name)
+	writeHunks(&sb, ops)
+	return sb.String()
+}
+
+type diffOp struct {
+	kind byte // ' ', '-', '+'
+	line string
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// lineDiff computes an edit script via the longest common subsequence.
+func lineDiff(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, n+m)
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
+
+// writeHunks groups ops into @@ hunks with diffContext lines of context.
+func writeHunks(sb *strings.Builder, ops []diffOp) {
+	// Line numbers (1-based) at the start of each op in old and new files.
+	oldLine := make([]int, len(ops)+1)
+	newLine := make([]int, len(ops)+1)
+	oldLine[0], newLine[0] = 1, 1
+	for k, op := range ops {
+		oldLine[k+1], newLine[k+1] = oldLine[k], newLine[k]
+		if op.kind != '+' {
+			oldLine[k+1]++
+		}
+		if op.kind != '-' {
+			newLine[k+1]++
+		}
+	}
+
+	for k := 0; k < len(ops); {
+		if ops[k].kind == ' ' {
+			k++
+			continue
+		}
+		start := max(k-diffContext, 0)
+		end := k
+		// Extend the hunk while changes are within 2*context of each other.
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			next := end
+			for next < len(ops) && ops[next].kind == ' ' {
+				next++
+			}
+			if next == len(ops) || next-end > 2*diffContext {
+				end = min(end+diffContext, len(ops))
+				break
+			}
+			end = next
+		}
+
+		oldStart, oldCount := oldLine[start], oldLine[end]-oldLine[start]
+		newStart, newCount := newLine[start], newLine[end]-newLine[start]
+		// An empty range is addressed by the line before it.
+		if oldCount == 0 {
+			oldStart--
+		}
+		if newCount == 0 {
+			newStart--
+		}
+		fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+		for _, op := range ops[start:end] {
+			sb.WriteByte(op.kind)
+			sb.WriteString(op.line)
+			sb.WriteByte('\n')
+		}
+		k = end
+	}
+}
+
+// MaskDotEnvDiff applies MaskDotEnv to the lines of the .env sections in
+// diff, a concatenation of UnifiedDiff outputs. Other files are left alone.
+func MaskDotEnvDiff(diff string) string {
+	lines := strings.Split(diff, "\n")
+	inDotEnv := false
+	for i, line := range lines {
+		if strings.HasPrefix(line, "--- a/") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ b/") {
+			inDotEnv = line == "--- a/.env"
+			continue
+		}
+		if !inDotEnv || line == "" || strings.HasPrefix(line, "+++ b/") || strings.HasPrefix(line, "@@ ") {
+			continue
+		}
+		lines[i] = line[:1] + strings.TrimSuffix(MaskDotEnv(line[1:]+"\n"), "\n")
+	}
+	return strings.Join(lines, "\n")
+}
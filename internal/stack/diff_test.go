@@ -0,0 +1,62 @@
+package stack
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	t.Parallel()
+
+	t.Run("identical", func(t *testing.T) {
+		t.Parallel()
+		if got := UnifiedDiff("compose.yaml", "a\nb\n", "a\nb\n"); got != "" {
+			t.Errorf("expected empty diff, got %q", got)
+		}
+	})
+
+	t.Run("single change with context", func(t *testing.T) {
+		t.Parallel()
+		old := "services:\n  web:\n    image: nginx:1.25\n    ports:\n      - 80:80\n"
+		new := "services:\n  web:\n    image: nginx:1.27\n    ports:\n      - 80:80\n"
+		want := "--- a/compose.yaml\n+++ b/compose.yaml\n" +
+			"@@ -1,5 +1,5 @@\n" +
+			" services:\n" +
+			"   web:\n" +
+			"-    image: nginx:1.25\n" +
+			"+    image: nginx:1.27\n" +
+			"     ports:\n" +
+			"       - 80:80\n"
+		if got := UnifiedDiff("compose.yaml", old, new); got != want {
+			t.Errorf("got:\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	t.Run("separate hunks", func(t *testing.T) {
+		t.Parallel()
+		old := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+		new := "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n"
+		want := "--- a/x\n+++ b/x\n" +
+			"@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n" +
+			"@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+twelve\n"
+		if got := UnifiedDiff("x", old, new); got != want {
+			t.Errorf("got:\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	t.Run("new file", func(t *testing.T) {
+		t.Parallel()
+		want := "--- a/.env\n+++ b/.env\n@@ -0,0 +1,1 @@\n+A=1\n"
+		if got := UnifiedDiff(".env", "", "A=1\n"); got != want {
+			t.Errorf("got:\n%s\nwant:\n%s", got, want)
+		}
+	})
+}
+
+func TestMaskDotEnvDiff(t *testing.T) {
+	t.Parallel()
+	diff := UnifiedDiff("compose.yaml", "x: 1\n", "x: DB_PASSWORD=a\n") +
+		UnifiedDiff(".env", "DB_PASSWORD=old\nPORT=80\n", "DB_PASSWORD=new\nPORT=81\n")
+	want := "--- a/compose.yaml\n+++ b/compose.yaml\n@@ -1,1 +1,1 @@\n-x: 1\n+x: DB_PASSWORD=a\n" +
+		"--- a/.env\n+++ b/.env\n@@ -1,2 +1,2 @@\n-DB_PASSWORD=" + SecretMask + "\n-PORT=80\n+DB_PASSWORD=" + SecretMask + "\n+PORT=81\n"
+	if got := MaskDotEnvDiff(diff); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
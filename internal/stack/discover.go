@@ -0,0 +1,297 @@
+package stack
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cfilipov/dockge/internal/docker"
+)
+
+// Discovery sources.
+const (
+	DiscoverSourcePath      = "path"      // found by scanning a configured directory
+	DiscoverSourceContainer = "container" // found via compose labels on a container
+)
+
+// discoverMaxDepth bounds how far below a configured path the scan descends.
+const discoverMaxDepth = 3
+
+// DiscoveredProject is a compose project outside the stacks directory that
+// can be adopted (copied in) or linked as a stack.
+type DiscoveredProject struct {
+	Dir         string `json:"dir"`
+	ComposeFile string `json:"composeFile"`
+	Source      string `json:"source"`
+	Project     string `json:"project,omitempty"` // compose project name of running containers
+	Name        string `json:"name"`              // suggested stack name
+	Exists      bool   `json:"exists"`            // a stack with the suggested name already exists
+	Readable    bool   `json:"readable"`          // compose file is readable from this process
+}
+
+// IsDirEntry reports whether entry (read from dir) is a directory, following
+// symlinks so linked stacks are listed like regular ones.
+func IsDirEntry(dir string, entry os.DirEntry) bool {
+	if entry.IsDir() {
+		return true
+	}
+	if entry.Type()&fs.ModeSymlink == 0 {
+		return false
+	}
+	info, err := os.Stat(filepath.Join(dir, entry.Name()))
+	return err == nil && info.IsDir()
+}
+
+// DiscoverProjects scans roots and container compose labels for compose
+// projects that aren't stacks yet. Projects inside stacksDir, or already
+// linked from it, are skipped. Results are sorted by directory.
+func DiscoverProjects(stacksDir string, roots []string, containers []docker.Container) []DiscoveredProject {
+	stacksReal := realPath(stacksDir)
+	linked := linkedDirs(stacksDir)
+	known := func(dir string) bool {
+		real := realPath(dir)
+		return isWithin(real, stacksReal) || linked[real]
+	}
+
+	found := make(map[string]*DiscoveredProject)
+	add := func(p DiscoveredProject) {
+		p.Dir = filepath.Clean(p.Dir)
+		if known(p.Dir) {
+			return
+		}
+		if prev, ok := found[p.Dir]; ok {
+			// A scanned directory with running containers keeps the project name
+			if prev.Project == "" {
+				prev.Project = p.Project
+			}
+			return
+		}
+		found[p.Dir] = &p
+	}
+
+	for _, root := range roots {
+		for _, p := range scanProjects(root) {
+			add(p)
+		}
+	}
+	for _, p := range containerProjects(containers) {
+		add(p)
+	}
+
+	result := make([]DiscoveredProject, 0, len(found))
+	for _, p := range found {
+		_, err := os.Stat(p.ComposeFile)
+		p.Readable = err == nil
+		if p.Project != "" && ValidateStackName(p.Project) == nil {
+			p.Name = p.Project
+		} else {
+			p.Name = SuggestStackName(filepath.Base(p.Dir))
+		}
+		if p.Name != "" {
+			_, err := os.Lstat(filepath.Join(stacksDir, p.Name))
+			p.Exists = err == nil
+		}
+		result = append(result, *p)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Dir < result[j].Dir })
+	return result
+}
+
+// scanProjects walks root up to discoverMaxDepth levels, returning directories
+// that contain an accepted compose file. Hidden directories are skipped and a
+// project's subdirectories are not searched.
+func scanProjects(root string) []DiscoveredProject {
+	root = filepath.Clean(root)
+	var result []DiscoveredProject
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil // unreadable subdirectory
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != root && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if file := findComposeFileIn(path); file != "" {
+			result = append(result, DiscoveredProject{Dir: path, ComposeFile: file, Source: DiscoverSourcePath})
+			return filepath.SkipDir
+		}
+		if rel, _ := filepath.Rel(root, path); rel != "." && strings.Count(rel, string(filepath.Separator))+1 >= discoverMaxDepth {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Warn("discover compose projects", "err", err, "dir", root)
+	}
+	return result
+}
+
+// containerProjects derives project directories from the compose labels of
+// containers, so projects started by hand with docker compose are found even
+// outside the configured paths.
+func containerProjects(containers []docker.Container) []DiscoveredProject {
+	seen := make(map[string]bool)
+	var result []DiscoveredProject
+	for _, c := range containers {
+		if c.Project == "" || c.ConfigFiles == "" || seen[c.Project] {
+			continue
+		}
+		seen[c.Project] = true
+
+		// Only the first config file is adopted; overrides are picked up by name
+		file, _, _ := strings.Cut(c.ConfigFiles, ",")
+		file = strings.TrimSpace(file)
+		dir := c.WorkingDir
+		if dir == "" {
+			dir = filepath.Dir(file)
+		}
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		result = append(result, DiscoveredProject{
+			Dir:         dir,
+			ComposeFile: file,
+			Source:      DiscoverSourceContainer,
+			Project:     c.Project,
+		})
+	}
+	return result
+}
+
+// SuggestStackName turns a directory name into a valid stack name, or ""
+// if nothing usable remains.
+func SuggestStackName(raw string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(raw) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('-')
+		}
+	}
+	name := strings.TrimLeft(b.String(), "-")
+	if len(name) > 255 {
+		name = name[:255]
+	}
+	return name
+}
+
+// AdoptProject turns a discovered project into the stack name. With link,
+// the stack directory is a symlink to the project, which stays where it is;
+// otherwise the compose, override and .env files are copied, and relative
+// paths in them (bind mounts, build contexts, env_file) must be fixed by hand.
+func AdoptProject(stacksDir string, p DiscoveredProject, name string, link bool) error {
+	if err := ValidateStackName(name); err != nil {
+		return err
+	}
+	target := filepath.Join(stacksDir, name)
+	if _, err := os.Lstat(target); err == nil {
+		return fmt.Errorf("stack %q already exists", name)
+	}
+	if _, err := os.Stat(p.ComposeFile); err != nil {
+		return fmt.Errorf("read compose file: %w", err)
+	}
+
+	if link {
+		if findComposeFileIn(p.Dir) == "" {
+			return errors.New("project has no standard compose file name; copy it instead")
+		}
+		if err := os.Symlink(p.Dir, target); err != nil {
+			return fmt.Errorf("link stack: %w", err)
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return fmt.Errorf("create stack dir: %w", err)
+	}
+	composeName := filepath.Base(p.ComposeFile)
+	if !isAcceptedComposeFileName(composeName) {
+		composeName = "compose.yaml"
+	}
+	files := map[string]string{p.ComposeFile: composeName}
+	for _, name := range append(acceptedComposeOverrideFileNames, ".env") {
+		src := filepath.Join(p.Dir, name)
+		if _, err := os.Stat(src); err == nil {
+			files[src] = name
+		}
+	}
+	for src, name := range files {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			os.RemoveAll(target)
+			return fmt.Errorf("read %s: %w", name, err)
+		}
+		if err := os.WriteFile(filepath.Join(target, name), data, 0644); err != nil {
+			os.RemoveAll(target)
+			return fmt.Errorf("write %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// findComposeFileIn returns the first accepted compose file in dir, or "".
+func findComposeFileIn(dir string) string {
+	for _, name := range acceptedComposeFileNames {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+func isAcceptedComposeFileName(name string) bool {
+	for _, n := range acceptedComposeFileNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// linkedDirs returns the resolved targets of symlinked stacks in stacksDir.
+func linkedDirs(stacksDir string) map[string]bool {
+	linked := make(map[string]bool)
+	entries, err := os.ReadDir(stacksDir)
+	if err != nil {
+		return linked
+	}
+	for _, entry := range entries {
+		if entry.Type()&fs.ModeSymlink == 0 {
+			continue
+		}
+		if real, err := filepath.EvalSymlinks(filepath.Join(stacksDir, entry.Name())); err == nil {
+			linked[real] = true
+		}
+	}
+	return linked
+}
+
+// realPath resolves symlinks in an absolute path, falling back to the
+// cleaned path when it can't be resolved (e.g. it doesn't exist here).
+func realPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		return real
+	}
+	return filepath.Clean(path)
+}
+
+// isWithin reports whether path is dir or below it.
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,124 @@
+package stack
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cfilipov/dockge/internal/docker"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiscoverProjects(t *testing.T) {
+	t.Parallel()
+	base := t.TempDir()
+	stacksDir := filepath.Join(base, "stacks")
+	hostDir := filepath.Join(base, "srv")
+
+	writeFile(t, filepath.Join(stacksDir, "managed", "compose.yaml"), "services: {}\n")
+	writeFile(t, filepath.Join(hostDir, "Web App", "docker-compose.yml"), "services: {}\n")
+	writeFile(t, filepath.Join(hostDir, "group", "db", "compose.yaml"), "services: {}\n")
+	writeFile(t, filepath.Join(hostDir, "a", "b", "c", "d", "compose.yaml"), "services: {}\n") // too deep
+	writeFile(t, filepath.Join(hostDir, ".hidden", "compose.yaml"), "services: {}\n")
+	writeFile(t, filepath.Join(hostDir, "linked", "compose.yaml"), "services: {}\n")
+	if err := os.Symlink(filepath.Join(hostDir, "linked"), filepath.Join(stacksDir, "linked")); err != nil {
+		t.Fatal(err)
+	}
+
+	containers := []docker.Container{
+		{Project: "db", WorkingDir: filepath.Join(hostDir, "group", "db"), ConfigFiles: filepath.Join(hostDir, "group", "db", "compose.yaml")},
+		{Project: "managed", ConfigFiles: filepath.Join(stacksDir, "managed", "compose.yaml")},
+		{Project: "remote", ConfigFiles: "/elsewhere/prod.yml,/elsewhere/prod.override.yml"},
+		{Name: "standalone"},
+	}
+
+	got := DiscoverProjects(stacksDir, []string{hostDir, filepath.Join(base, "missing")}, containers)
+	want := []DiscoveredProject{
+		{Dir: "/elsewhere", ComposeFile: "/elsewhere/prod.yml", Source: DiscoverSourceContainer, Project: "remote", Name: "remote"},
+		{Dir: filepath.Join(hostDir, "Web App"), ComposeFile: filepath.Join(hostDir, "Web App", "docker-compose.yml"), Source: DiscoverSourcePath, Name: "web-app", Readable: true},
+		{Dir: filepath.Join(hostDir, "group", "db"), ComposeFile: filepath.Join(hostDir, "group", "db", "compose.yaml"), Source: DiscoverSourcePath, Project: "db", Name: "db", Readable: true},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d projects, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("project %d:\n got  %+v\n want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestAdoptProject(t *testing.T) {
+	t.Parallel()
+	base := t.TempDir()
+	stacksDir := filepath.Join(base, "stacks")
+	projectDir := filepath.Join(base, "srv", "app")
+	writeFile(t, filepath.Join(projectDir, "prod.yml"), "services: {}\n")
+	writeFile(t, filepath.Join(projectDir, "compose.override.yaml"), "services: {}\n")
+	writeFile(t, filepath.Join(projectDir, ".env"), "A=1\n")
+	if err := os.MkdirAll(stacksDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	p := DiscoveredProject{Dir: projectDir, ComposeFile: filepath.Join(projectDir, "prod.yml")}
+
+	// Link needs a standard compose file name
+	if err := AdoptProject(stacksDir, p, "app", true); err == nil {
+		t.Fatal("expected link of non-standard compose file to fail")
+	}
+
+	if err := AdoptProject(stacksDir, p, "app", false); err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	for _, name := range []string{"compose.yaml", "compose.override.yaml", ".env"} {
+		if _, err := os.Stat(filepath.Join(stacksDir, "app", name)); err != nil {
+			t.Errorf("%s not copied: %v", name, err)
+		}
+	}
+	if err := AdoptProject(stacksDir, p, "app", false); err == nil {
+		t.Error("expected adopting onto an existing stack to fail")
+	}
+	if err := AdoptProject(stacksDir, p, "Bad Name", false); err == nil {
+		t.Error("expected invalid stack name to fail")
+	}
+
+	writeFile(t, filepath.Join(projectDir, "compose.yaml"), "services: {}\n")
+	if err := AdoptProject(stacksDir, p, "linked", true); err != nil {
+		t.Fatalf("link: %v", err)
+	}
+	entries, err := os.ReadDir(stacksDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if !IsDirEntry(stacksDir, entry) {
+			t.Errorf("%s: not listed as a directory", entry.Name())
+		}
+	}
+	if !ComposeFileExists(stacksDir, "linked") {
+		t.Error("linked stack has no compose file")
+	}
+}
+
+func TestSuggestStackName(t *testing.T) {
+	tests := map[string]string{
+		"My App":    "my-app",
+		"web_app":   "web_app",
+		"--x.y":     "x-y",
+		"...":       "",
+		"Nextcloud": "nextcloud",
+	}
+	for in, want := range tests {
+		if got := SuggestStackName(in); got != want {
+			t.Errorf("SuggestStackName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package stack
+
+import (
+	"strings"
+)
+
+// SecretMask replaces secret values shown to users who may not read them.
+// Saving a .env with the mask keeps the value on disk.
+const SecretMask = "••••••••"
+
+// secretWords mark a variable as secret when they appear as a word of its
+// name, e.g. DB_PASSWORD or GITHUB_TOKEN but not KEYCLOAK_URL.
+var secretWords = map[string]bool{
+	"PASSWORD":    true,
+	"PASSWD":      true,
+	"PASS":        true,
+	"PWD":         true,
+	"SECRET":      true,
+	"TOKEN":       true,
+	"KEY":         true,
+	"APIKEY":      true,
+	"CREDENTIALS": true,
+	"PRIVATE":     true,
+}
+
+// IsSecretKey reports whether a variable name looks like it holds a secret.
+func IsSecretKey(key string) bool {
+	for _, word := range strings.FieldsFunc(strings.ToUpper(key), func(r rune) bool {
+		return r == '_' || r == '.' || r == '-'
+	}) {
+		if secretWords[word] {
+			return true
+		}
+	}
+	return false
+}
+
+// EnvEntry is a line of a .env file: a variable, or a comment if Key is
+// empty. Blank lines are left out.
+type EnvEntry struct {
+	Line    int    `json:"line"` // 1-based
+	Key     string `json:"key,omitempty"`
+	Value   string `json:"value,omitempty"`
+	Comment string `json:"comment,omitempty"` // without "#"; inline for variables
+	Secret  bool   `json:"secret,omitempty"`
+}
+
+// ParseDotEnv returns the variables and comments of a .env file in line
+// order.
+func ParseDotEnv(text string) []EnvEntry {
+	assignments := make(map[int]envAssignment)
+	for _, a := range envAssignments(text, false) {
+		assignments[a.line] = a
+	}
+	entries := []EnvEntry{}
+	for i, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		if trimmed[0] == '#' {
+			entries = append(entries, EnvEntry{Line: i + 1, Comment: strings.TrimSpace(trimmed[1:])})
+			continue
+		}
+		a, ok := assignments[i]
+		if !ok {
+			continue
+		}
+		e := EnvEntry{Line: i + 1, Key: a.key, Value: a.value, Secret: IsSecretKey(a.key)}
+		if idx := strings.Index(line[a.end:], "#"); idx >= 0 {
+			e.Comment = strings.TrimSpace(line[a.end+idx+1:])
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// DotEnvValues returns the variables of a .env file; later assignments win.
+func DotEnvValues(text string) map[string]string {
+	values := make(map[string]string)
+	for _, a := range envAssignments(text, false) {
+		values[a.key] = a.value
+	}
+	return values
+}
+
+// MaskDotEnv replaces the values of secret variables with SecretMask.
+func MaskDotEnv(text string) string {
+	return rewriteDotEnv(text, func(a envAssignment) (string, bool) {
+		if a.value == "" || !IsSecretKey(a.key) {
+			return "", false
+		}
+		return SecretMask, true
+	})
+}
+
+// UnmaskDotEnv restores the secret values MaskDotEnv hid: every variable
+// set to SecretMask in masked gets its value from original. A variable
+// that's new gets an empty value rather than the mask.
+func UnmaskDotEnv(masked, original string) string {
+	if !strings.Contains(masked, SecretMask) {
+		return masked
+	}
+	values := DotEnvValues(original)
+	return rewriteDotEnv(masked, func(a envAssignment) (string, bool) {
+		if a.value != SecretMask {
+			return "", false
+		}
+		return values[a.key], true
+	})
+}
+
+// MaskEntries replaces the values of secret entries with SecretMask.
+func MaskEntries(entries []EnvEntry) []EnvEntry {
+	masked := make([]EnvEntry, len(entries))
+	for i, e := range entries {
+		if e.Secret && e.Value != "" {
+			e.Value = SecretMask
+		}
+		masked[i] = e
+	}
+	return masked
+}
+
+// rewriteDotEnv replaces the values fn returns a replacement for. Values
+// are written as they are; quoting is preserved.
+func rewriteDotEnv(text string, fn func(envAssignment) (string, bool)) string {
+	lines := strings.Split(text, "\n")
+	// Replace from the end so earlier offsets on a line stay valid
+	assignments := envAssignments(text, false)
+	for i := len(assignments) - 1; i >= 0; i-- {
+		a := assignments[i]
+		replacement, ok := fn(a)
+		if !ok {
+			continue
+		}
+		line := lines[a.line]
+		lines[a.line] = line[:a.start] + replacement + line[a.end:]
+	}
+	return strings.Join(lines, "\n")
+}
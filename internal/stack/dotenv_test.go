@@ -0,0 +1,65 @@
+package stack
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsSecretKey(t *testing.T) {
+	t.Parallel()
+	for key, want := range map[string]bool{
+		"DB_PASSWORD":      true,
+		"github_token":     true,
+		"API_KEY":          true,
+		"SECRET":           true,
+		"KEYCLOAK_URL":     false,
+		"PASSWORDLESS":     false,
+		"TZ":               false,
+		"smtp.credentials": true,
+	} {
+		if got := IsSecretKey(key); got != want {
+			t.Errorf("IsSecretKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestParseDotEnv(t *testing.T) {
+	t.Parallel()
+	text := "# Database\nDB_USER=app # the user\n\nexport DB_PASSWORD=\"s3cr#t\"\nbroken line\n"
+	got := ParseDotEnv(text)
+	want := []EnvEntry{
+		{Line: 1, Comment: "Database"},
+		{Line: 2, Key: "DB_USER", Value: "app", Comment: "the user"},
+		{Line: 4, Key: "DB_PASSWORD", Value: "s3cr#t", Secret: true},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ParseDotEnv = %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestMaskDotEnv(t *testing.T) {
+	t.Parallel()
+	original := "DB_USER=app\nDB_PASSWORD=\"hunter2\"\nAPI_TOKEN=abc # comment\nEMPTY_TOKEN=\n"
+	masked := MaskDotEnv(original)
+	if strings.Contains(masked, "hunter2") || strings.Contains(masked, "abc") {
+		t.Fatalf("secrets left in %q", masked)
+	}
+	if !strings.Contains(masked, "DB_USER=app\n") || !strings.Contains(masked, "# comment") {
+		t.Errorf("non-secret content changed: %q", masked)
+	}
+
+	// Saving the masked text keeps the secrets; edited values win
+	if got := UnmaskDotEnv(masked, original); got != original {
+		t.Errorf("UnmaskDotEnv = %q, want %q", got, original)
+	}
+	edited := strings.Replace(masked, "DB_USER=app", "DB_USER=other", 1) + "NEW_SECRET=" + SecretMask + "\n"
+	want := strings.Replace(original, "DB_USER=app", "DB_USER=other", 1) + "NEW_SECRET=\n"
+	if got := UnmaskDotEnv(edited, original); got != want {
+		t.Errorf("UnmaskDotEnv(edited) = %q, want %q", got, want)
+	}
+}
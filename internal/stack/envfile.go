@@ -0,0 +1,81 @@
+package stack
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cfilipov/dockge/internal/compose"
+)
+
+// ServiceEnvFile is an env_file entry of a service and whether it could be
+// read.
+type ServiceEnvFile struct {
+	compose.EnvFile
+	Missing bool `json:"missing"` // not found, or its path uses interpolation
+}
+
+// EnvFileVar is a variable a service gets from its env files.
+type EnvFileVar struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	File   string `json:"file"` // the last entry setting it, as written
+	Secret bool   `json:"secret"`
+}
+
+// ServiceEnv is what a service's env_file entries set.
+type ServiceEnv struct {
+	Files []ServiceEnvFile `json:"files"`
+	Vars  []EnvFileVar     `json:"vars"` // sorted by name
+}
+
+// LoadServiceEnv reads each service's env files, with relative paths taken
+// from dir. As in compose, a later file overrides an earlier one.
+func LoadServiceEnv(dir string, envFiles map[string][]compose.EnvFile) map[string]ServiceEnv {
+	result := make(map[string]ServiceEnv, len(envFiles))
+	for service, files := range envFiles {
+		env := ServiceEnv{Files: []ServiceEnvFile{}, Vars: []EnvFileVar{}}
+		vars := make(map[string]EnvFileVar)
+		for _, f := range files {
+			sf := ServiceEnvFile{EnvFile: f}
+			data, err := readEnvFile(dir, f.Path)
+			if err != nil {
+				sf.Missing = true
+				env.Files = append(env.Files, sf)
+				continue
+			}
+			env.Files = append(env.Files, sf)
+			for k, v := range DotEnvValues(string(data)) {
+				vars[k] = EnvFileVar{Name: k, Value: v, File: f.Path, Secret: IsSecretKey(k)}
+			}
+		}
+		for _, v := range vars {
+			env.Vars = append(env.Vars, v)
+		}
+		sort.Slice(env.Vars, func(i, j int) bool { return env.Vars[i].Name < env.Vars[j].Name })
+		result[service] = env
+	}
+	return result
+}
+
+// MaskServiceEnv replaces the values of secret variables with SecretMask.
+func MaskServiceEnv(envs map[string]ServiceEnv) {
+	for _, env := range envs {
+		for i, v := range env.Vars {
+			if v.Secret && v.Value != "" {
+				env.Vars[i].Value = SecretMask
+			}
+		}
+	}
+}
+
+func readEnvFile(dir, path string) ([]byte, error) {
+	if strings.Contains(path, "$") {
+		return nil, os.ErrNotExist
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	return os.ReadFile(path)
+}
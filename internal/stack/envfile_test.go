@@ -0,0 +1,47 @@
+package stack
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/cfilipov/dockge/internal/compose"
+)
+
+func TestLoadServiceEnv(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "common.env"), []byte("TZ=UTC\nLOG_LEVEL=info\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "app.env"), []byte("LOG_LEVEL=debug\nDB_PASSWORD=hunter2\n"), 0o644)
+
+	envs := LoadServiceEnv(dir, map[string][]compose.EnvFile{
+		"app": {
+			{Path: "common.env", Required: true},
+			{Path: "./app.env", Required: true},
+			{Path: "missing.env", Required: false},
+		},
+	})
+	app := envs["app"]
+	wantFiles := []ServiceEnvFile{
+		{EnvFile: compose.EnvFile{Path: "common.env", Required: true}},
+		{EnvFile: compose.EnvFile{Path: "./app.env", Required: true}},
+		{EnvFile: compose.EnvFile{Path: "missing.env", Required: false}, Missing: true},
+	}
+	if !reflect.DeepEqual(app.Files, wantFiles) {
+		t.Errorf("Files = %+v, want %+v", app.Files, wantFiles)
+	}
+	wantVars := []EnvFileVar{
+		{Name: "DB_PASSWORD", Value: "hunter2", File: "./app.env", Secret: true},
+		{Name: "LOG_LEVEL", Value: "debug", File: "./app.env"},
+		{Name: "TZ", Value: "UTC", File: "common.env"},
+	}
+	if !reflect.DeepEqual(app.Vars, wantVars) {
+		t.Errorf("Vars = %+v, want %+v", app.Vars, wantVars)
+	}
+
+	MaskServiceEnv(envs)
+	if v := envs["app"].Vars[0].Value; v != SecretMask {
+		t.Errorf("secret not masked: %q", v)
+	}
+}
@@ -0,0 +1,190 @@
+package stack
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// envKeyPattern matches variable names in .env files and environment: entries.
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// EnvMatch is a variable assignment found in a stack file.
+type EnvMatch struct {
+	Line  int    `json:"line"` // 1-based
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// envAssignment is an assignment with the position of its value in the line.
+type envAssignment struct {
+	line       int // 0-based
+	key, value string
+	start, end int  // value bytes within the line, quotes excluded
+	quoted     bool // value is in quotes
+	yamlMap    bool // KEY: value form
+}
+
+// FindEnv returns the assignments in text whose key and value equal the
+// given ones; an empty key or value matches any. With composeFile set, text
+// is compose YAML and only environment: entries are considered; otherwise
+// it is a .env file.
+func FindEnv(text string, composeFile bool, key, value string) []EnvMatch {
+	var matches []EnvMatch
+	for _, a := range envAssignments(text, composeFile) {
+		if envMatches(a, key, value) {
+			matches = append(matches, EnvMatch{Line: a.line + 1, Key: a.key, Value: a.value})
+		}
+	}
+	return matches
+}
+
+// ReplaceEnv sets every assignment FindEnv would match to newValue and
+// returns the new text with the number of replacements. Unquoted values are
+// quoted when newValue would otherwise change meaning.
+func ReplaceEnv(text string, composeFile bool, key, value, newValue string) (string, int, error) {
+	if err := ValidateEnvValue(newValue); err != nil {
+		return "", 0, err
+	}
+	lines := strings.Split(text, "\n")
+	n := 0
+	for _, a := range envAssignments(text, composeFile) {
+		if !envMatches(a, key, value) {
+			continue
+		}
+		replacement := newValue
+		if !a.quoted && needsQuotes(newValue, a.yamlMap) {
+			replacement = `"` + newValue + `"`
+		}
+		line := lines[a.line]
+		lines[a.line] = line[:a.start] + replacement + line[a.end:]
+		n++
+	}
+	return strings.Join(lines, "\n"), n, nil
+}
+
+// ValidateEnvValue rejects values that can't be written on one line of
+// either file format without escaping.
+func ValidateEnvValue(v string) error {
+	if strings.ContainsAny(v, "\"'\\") {
+		return errors.New("value must not contain quotes or backslashes")
+	}
+	for _, r := range v {
+		if r < 0x20 || r == 0x7f {
+			return errors.New("value must not contain control characters")
+		}
+	}
+	return nil
+}
+
+func envMatches(a envAssignment, key, value string) bool {
+	return (key == "" || a.key == key) && (value == "" || a.value == value)
+}
+
+func needsQuotes(v string, yamlMap bool) bool {
+	if v == "" || v != strings.TrimSpace(v) || strings.Contains(v, " #") || strings.HasPrefix(v, "#") {
+		return true
+	}
+	if yamlMap {
+		// YAML indicators and values that would parse as another type
+		if strings.ContainsAny(v[:1], "*&!|>%@`{[,?:-") || strings.Contains(v, ": ") {
+			return true
+		}
+		switch strings.ToLower(v) {
+		case "true", "false", "yes", "no", "on", "off", "null", "~":
+			return true
+		}
+	}
+	return false
+}
+
+// envAssignments lists the assignments in text in line order.
+func envAssignments(text string, composeFile bool) []envAssignment {
+	var result []envAssignment
+	envIndent := -1 // indent of the current environment: key, -1 outside one
+	for i, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed[0] == '#' {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+
+		if !composeFile {
+			rest := strings.TrimPrefix(trimmed, "export ")
+			if a, ok := parseAssignment(rest, "="); ok {
+				a.line = i
+				a.start += len(line) - len(rest)
+				a.end += len(line) - len(rest)
+				result = append(result, a)
+			}
+			continue
+		}
+
+		if envIndent >= 0 && indent <= envIndent {
+			envIndent = -1
+		}
+		if envIndent < 0 {
+			if stripInlineComment(trimmed) == "environment:" {
+				envIndent = indent
+			}
+			continue
+		}
+
+		offset := indent
+		item := trimmed
+		sep := ": "
+		if strings.HasPrefix(item, "- ") {
+			item = strings.TrimLeft(item[2:], " ")
+			offset = len(line) - len(item)
+			sep = "="
+			// - "KEY=value": the quotes wrap the whole entry
+			if q := item[0]; (q == '"' || q == '\'') && strings.HasSuffix(item, string(q)) && len(item) > 1 {
+				item = item[1 : len(item)-1]
+				offset++
+				if a, ok := parseAssignment(item, sep); ok {
+					a.line, a.start, a.end, a.quoted = i, a.start+offset, a.end+offset, true
+					result = append(result, a)
+				}
+				continue
+			}
+		}
+		if a, ok := parseAssignment(item, sep); ok {
+			a.line, a.start, a.end = i, a.start+offset, a.end+offset
+			a.yamlMap = sep == ": "
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+// parseAssignment splits "KEY<sep>value" and locates the value, without
+// surrounding quotes or an inline comment.
+func parseAssignment(s, sep string) (envAssignment, bool) {
+	key, rest, ok := strings.Cut(s, sep)
+	if !ok && sep == ": " && strings.HasSuffix(s, ":") {
+		key, rest, ok = strings.TrimSuffix(s, ":"), "", true
+	}
+	key = strings.TrimSpace(key)
+	if !ok || !envKeyPattern.MatchString(key) {
+		return envAssignment{}, false
+	}
+	start := len(s) - len(rest)
+	value := strings.TrimLeft(rest, " ")
+	start += len(rest) - len(value)
+
+	if value != "" && (value[0] == '"' || value[0] == '\'') {
+		if end := strings.IndexByte(value[1:], value[0]); end >= 0 {
+			return envAssignment{key: key, value: value[1 : end+1], start: start + 1, end: start + 1 + end, quoted: true}, true
+		}
+	}
+	value = stripInlineComment(value)
+	return envAssignment{key: key, value: value, start: start, end: start + len(value)}, true
+}
+
+// stripInlineComment removes a " #" comment from an unquoted value.
+func stripInlineComment(s string) string {
+	if idx := strings.Index(s, " #"); idx >= 0 {
+		return strings.TrimRight(s[:idx], " \t")
+	}
+	return strings.TrimRight(s, " \t")
+}
@@ -0,0 +1,110 @@
+package stack
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindEnv(t *testing.T) {
+	t.Parallel()
+	env := "# shared secrets\nAPI_KEY=abc123\nexport TOKEN=\"abc123\" \nOTHER=abc123 # same value\nEMPTY=\n"
+	got := FindEnv(env, false, "", "abc123")
+	want := []EnvMatch{
+		{Line: 2, Key: "API_KEY", Value: "abc123"},
+		{Line: 3, Key: "TOKEN", Value: "abc123"},
+		{Line: 4, Key: "OTHER", Value: "abc123"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("value search = %+v, want %+v", got, want)
+	}
+
+	yaml := `services:
+  app:
+    image: app:abc123
+    environment:
+      API_KEY: abc123
+      - "TOKEN=abc123"
+    labels:
+      API_KEY: abc123
+  worker:
+    environment:
+      - API_KEY=abc123
+      - MODE=fast
+`
+	got = FindEnv(yaml, true, "API_KEY", "")
+	want = []EnvMatch{
+		{Line: 5, Key: "API_KEY", Value: "abc123"},
+		{Line: 11, Key: "API_KEY", Value: "abc123"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("compose key search = %+v, want %+v", got, want)
+	}
+}
+
+func TestReplaceEnv(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		in          string
+		compose     bool
+		key, value  string
+		newValue    string
+		want        string
+		wantReplace int
+	}{
+		{
+			name: "env file keeps quotes and comments",
+			in:   "API_KEY=old # rotated yearly\nTOKEN='old'\nKEEP=other\n",
+			key:  "", value: "old", newValue: "n3w",
+			want:        "API_KEY=n3w # rotated yearly\nTOKEN='n3w'\nKEEP=other\n",
+			wantReplace: 2,
+		},
+		{
+			name: "env file quotes values with spaces",
+			in:   "GREETING=hi\n",
+			key:  "GREETING", newValue: "hello world #1",
+			want:        "GREETING=\"hello world #1\"\n",
+			wantReplace: 1,
+		},
+		{
+			name:    "compose list and map entries",
+			in:      "services:\n  app:\n    environment:\n      API_KEY: old\n      FLAG: \"old\"\n  web:\n    environment:\n      - API_KEY=old\n      - 'API_KEY2=old'\n",
+			compose: true,
+			key:     "", value: "old", newValue: "new",
+			want:        "services:\n  app:\n    environment:\n      API_KEY: new\n      FLAG: \"new\"\n  web:\n    environment:\n      - API_KEY=new\n      - 'API_KEY2=new'\n",
+			wantReplace: 4,
+		},
+		{
+			name:    "compose map values that YAML would retype are quoted",
+			in:      "services:\n  app:\n    environment:\n      DEBUG: \"1\"\n      MODE: dev\n",
+			compose: true,
+			key:     "MODE", newValue: "yes",
+			want:        "services:\n  app:\n    environment:\n      DEBUG: \"1\"\n      MODE: \"yes\"\n",
+			wantReplace: 1,
+		},
+		{
+			name:    "keys outside environment are left alone",
+			in:      "services:\n  app:\n    image: old\n    labels:\n      image: old\n",
+			compose: true,
+			key:     "image", newValue: "new",
+			want:        "services:\n  app:\n    image: old\n    labels:\n      image: old\n",
+			wantReplace: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, n, err := ReplaceEnv(tt.in, tt.compose, tt.key, tt.value, tt.newValue)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want || n != tt.wantReplace {
+				t.Errorf("ReplaceEnv() = %q (%d), want %q (%d)", got, n, tt.want, tt.wantReplace)
+			}
+		})
+	}
+
+	if _, _, err := ReplaceEnv("A=b\n", false, "A", "", "x\"y"); err == nil {
+		t.Error("expected quotes in the new value to be rejected")
+	}
+}
@@ -0,0 +1,305 @@
+package stack
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cfilipov/dockge/internal/compose"
+)
+
+const (
+	// MaxEditableFileSize bounds the files of a stack directory that can be
+	// read and written as text.
+	MaxEditableFileSize = 1 << 20
+
+	// maxFileEntries bounds the entries of a directory listing.
+	maxFileEntries = 2000
+)
+
+var (
+	// ErrManagedFile is returned for the files the stack editor saves.
+	ErrManagedFile = errors.New("this file is edited in the stack editor")
+	// ErrFileTooLarge is returned for files over MaxEditableFileSize.
+	ErrFileTooLarge = fmt.Errorf("file is larger than %d KiB", MaxEditableFileSize>>10)
+	// ErrBinaryFile is returned when reading a file that isn't text.
+	ErrBinaryFile = errors.New("binary files can't be edited")
+)
+
+// FileEntry is a file or directory of a stack directory. Symlinks aren't
+// followed.
+type FileEntry struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"` // file, dir, symlink or other
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"modTime"` // Unix seconds
+	// Managed is set on the compose, override, .env and env_file files,
+	// which are edited with the stack rather than on their own
+	Managed bool `json:"managed,omitempty"`
+}
+
+// CleanFilePath turns a path given by a client into one relative to the
+// stack directory, "." for the directory itself. Leading slashes and ..
+// components can't climb out of it.
+func CleanFilePath(p string) (string, error) {
+	if strings.ContainsRune(p, 0) || strings.Contains(p, `\`) {
+		return "", errors.New("invalid path")
+	}
+	rel := strings.TrimPrefix(path.Clean("/"+p), "/")
+	if rel == "" {
+		return ".", nil
+	}
+	return rel, nil
+}
+
+// IsManagedFile reports whether a cleaned path is one of the files of a
+// stack that are edited with it rather than on their own: see
+// managedFiles.
+func IsManagedFile(stacksDir, stackName, name string) bool {
+	return managedFiles(stacksDir, stackName)[name]
+}
+
+// holdsManagedFile reports whether a cleaned path is a managed file or a
+// directory with one under it, which moving or removing would take the
+// file along.
+func holdsManagedFile(stacksDir, stackName, name string) bool {
+	for m := range managedFiles(stacksDir, stackName) {
+		if m == name || strings.HasPrefix(m, name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// managedFiles returns the cleaned paths of the files saveStack writes,
+// the compose, override and .env files, the list of additional compose
+// files and the files it lists or the compose files include, and of the
+// env files the services read, which hold secrets the editor masks.
+func managedFiles(stacksDir, stackName string) map[string]bool {
+	managed := map[string]bool{".env": true, compose.ComposeFilesList: true}
+	for _, names := range [][]string{acceptedComposeFileNames, acceptedComposeOverrideFileNames} {
+		for _, n := range names {
+			managed[n] = true
+		}
+	}
+	s := &Stack{Name: stackName}
+	if ValidateStackName(stackName) != nil || s.LoadFromDisk(stacksDir) != nil {
+		return managed
+	}
+	// env_file paths are relative to the compose file that sets them,
+	// except in files merged with -f, which share the stack directory
+	addEnvFiles := func(dir, composeYAML string) {
+		for _, files := range compose.ParseEnvFiles(composeYAML) {
+			for _, f := range files {
+				if strings.Contains(f.Path, "$") || path.IsAbs(f.Path) {
+					continue
+				}
+				p := path.Join(dir, f.Path)
+				if p == ".." || strings.HasPrefix(p, "../") {
+					continue
+				}
+				managed[p] = true
+			}
+		}
+	}
+	addEnvFiles(".", s.ComposeYAML)
+	addEnvFiles(".", s.ComposeOverrideYAML)
+	for _, f := range s.ExtraFiles {
+		managed[path.Clean(f.Name)] = true
+		dir := "."
+		if f.Included {
+			dir = path.Dir(f.Name)
+		}
+		addEnvFiles(dir, f.YAML)
+	}
+	return managed
+}
+
+// openStackRoot opens a stack directory as an os.Root, which also keeps
+// symlinks from leading out of it.
+func openStackRoot(stacksDir, stackName string) (*os.Root, error) {
+	if err := ValidateStackName(stackName); err != nil {
+		return nil, err
+	}
+	return os.OpenRoot(filepath.Join(stacksDir, stackName))
+}
+
+// ListFiles returns the entries of a directory of a stack, directories
+// first, and whether there were more than could be returned.
+func ListFiles(stacksDir, stackName, dir string) ([]FileEntry, bool, error) {
+	root, err := openStackRoot(stacksDir, stackName)
+	if err != nil {
+		return nil, false, err
+	}
+	defer root.Close()
+	f, err := root.Open(dir)
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+	dirEntries, err := f.ReadDir(-1)
+	if err != nil {
+		return nil, false, err
+	}
+	managed := managedFiles(stacksDir, stackName)
+	entries := make([]FileEntry, 0, len(dirEntries))
+	for _, de := range dirEntries {
+		info, err := de.Info()
+		if err != nil {
+			continue // removed meanwhile
+		}
+		e := FileEntry{Name: de.Name(), Size: info.Size(), ModTime: info.ModTime().Unix()}
+		switch mode := info.Mode(); {
+		case mode.IsRegular():
+			e.Type = "file"
+		case mode.IsDir():
+			e.Type = "dir"
+		case mode&fs.ModeSymlink != 0:
+			e.Type = "symlink"
+		default:
+			e.Type = "other"
+		}
+		e.Managed = managed[path.Join(dir, e.Name)]
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if di, dj := entries[i].Type == "dir", entries[j].Type == "dir"; di != dj {
+			return di
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	if len(entries) > maxFileEntries {
+		return entries[:maxFileEntries], true, nil
+	}
+	return entries, false, nil
+}
+
+// ReadTextFile returns the contents of a text file of a stack directory.
+func ReadTextFile(stacksDir, stackName, name string) (string, error) {
+	if IsManagedFile(stacksDir, stackName, name) {
+		return "", ErrManagedFile
+	}
+	root, err := openStackRoot(stacksDir, stackName)
+	if err != nil {
+		return "", err
+	}
+	defer root.Close()
+	info, err := root.Lstat(name)
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", errors.New("not a regular file")
+	}
+	if info.Size() > MaxEditableFileSize {
+		return "", ErrFileTooLarge
+	}
+	f, err := root.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, MaxEditableFileSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > MaxEditableFileSize {
+		return "", ErrFileTooLarge
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return "", ErrBinaryFile
+	}
+	return string(data), nil
+}
+
+// WriteTextFile writes a file of a stack directory, creating it and its
+// parent directories as needed. An existing file keeps its permissions.
+func WriteTextFile(stacksDir, stackName, name, content string) error {
+	if name == "." {
+		return errors.New("file name required")
+	}
+	if IsManagedFile(stacksDir, stackName, name) {
+		return ErrManagedFile
+	}
+	if len(content) > MaxEditableFileSize {
+		return ErrFileTooLarge
+	}
+	root, err := openStackRoot(stacksDir, stackName)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+	perm := os.FileMode(0644)
+	if info, err := root.Lstat(name); err == nil {
+		if !info.Mode().IsRegular() {
+			return errors.New("not a regular file")
+		}
+		perm = info.Mode().Perm()
+	}
+	if dir := path.Dir(name); dir != "." {
+		if err := root.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	f, err := root.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// RenameFile moves a file or directory of a stack directory. It doesn't
+// replace an existing file. Managed files can't be moved, nor can the
+// directories holding them, and nothing can be moved onto where one goes.
+func RenameFile(stacksDir, stackName, from, to string) error {
+	if from == "." || to == "." {
+		return errors.New("file name required")
+	}
+	if holdsManagedFile(stacksDir, stackName, from) || holdsManagedFile(stacksDir, stackName, to) {
+		return ErrManagedFile
+	}
+	root, err := openStackRoot(stacksDir, stackName)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+	if _, err := root.Lstat(from); err != nil {
+		return err
+	}
+	if _, err := root.Lstat(to); err == nil {
+		return fmt.Errorf("%s already exists", to)
+	}
+	if dir := path.Dir(to); dir != "." {
+		if err := root.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	return root.Rename(from, to)
+}
+
+// DeleteFile removes a file or an empty directory of a stack directory.
+func DeleteFile(stacksDir, stackName, name string) error {
+	if name == "." {
+		return errors.New("file name required")
+	}
+	if holdsManagedFile(stacksDir, stackName, name) {
+		return ErrManagedFile
+	}
+	root, err := openStackRoot(stacksDir, stackName)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+	return root.Remove(name)
+}
@@ -0,0 +1,183 @@
+package stack
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCleanFilePath(t *testing.T) {
+	t.Parallel()
+	for in, want := range map[string]string{
+		"":                 ".",
+		"/":                ".",
+		"nginx.conf":       "nginx.conf",
+		"/conf/nginx.conf": "conf/nginx.conf",
+		"../../etc/passwd": "etc/passwd",
+		"conf/../a.txt":    "a.txt",
+	} {
+		if got, err := CleanFilePath(in); err != nil || got != want {
+			t.Errorf("CleanFilePath(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+	if _, err := CleanFilePath("a\x00b"); err == nil {
+		t.Error("expected an error for a NUL byte")
+	}
+}
+
+func TestStackFiles(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	stackDir := filepath.Join(dir, "web")
+	os.MkdirAll(stackDir, 0755)
+	os.WriteFile(filepath.Join(stackDir, "compose.yaml"), []byte("services: {}\n"), 0644)
+	os.WriteFile(filepath.Join(stackDir, ".env"), []byte("SECRET=x\n"), 0644)
+
+	if err := WriteTextFile(dir, "web", "conf/nginx.conf", "server {}\n"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadTextFile(dir, "web", "conf/nginx.conf")
+	if err != nil || got != "server {}\n" {
+		t.Fatalf("ReadTextFile = %q, %v", got, err)
+	}
+
+	entries, truncated, err := ListFiles(dir, "web", ".")
+	if err != nil || truncated {
+		t.Fatal(err, truncated)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name)
+		if managed := e.Name == "compose.yaml" || e.Name == ".env"; e.Managed != managed {
+			t.Errorf("%s: Managed = %v", e.Name, e.Managed)
+		}
+	}
+	if strings.Join(names, ",") != "conf,.env,compose.yaml" {
+		t.Errorf("entries = %v", names)
+	}
+
+	// The stack editor's files are left to it
+	for _, name := range []string{"compose.yaml", ".env"} {
+		if _, err := ReadTextFile(dir, "web", name); !errors.Is(err, ErrManagedFile) {
+			t.Errorf("read %s: %v", name, err)
+		}
+		if err := WriteTextFile(dir, "web", name, "x"); !errors.Is(err, ErrManagedFile) {
+			t.Errorf("write %s: %v", name, err)
+		}
+		if err := DeleteFile(dir, "web", name); !errors.Is(err, ErrManagedFile) {
+			t.Errorf("delete %s: %v", name, err)
+		}
+	}
+	if err := RenameFile(dir, "web", "conf/nginx.conf", "compose.yaml"); !errors.Is(err, ErrManagedFile) {
+		t.Errorf("rename onto compose.yaml: %v", err)
+	}
+
+	// Symlinks can't lead out of the stack directory
+	os.WriteFile(filepath.Join(dir, "outside.txt"), []byte("secret"), 0644)
+	os.Symlink("../outside.txt", filepath.Join(stackDir, "link.txt"))
+	os.Symlink("..", filepath.Join(stackDir, "up"))
+	if _, err := ReadTextFile(dir, "web", "link.txt"); err == nil {
+		t.Error("expected reading a symlink to fail")
+	}
+	if _, err := ReadTextFile(dir, "web", "up/outside.txt"); err == nil {
+		t.Error("expected reading through a symlinked directory to fail")
+	}
+	if err := WriteTextFile(dir, "web", "up/outside.txt", "x"); err == nil {
+		t.Error("expected writing through a symlinked directory to fail")
+	}
+
+	if err := WriteTextFile(dir, "web", "big.txt", strings.Repeat("x", MaxEditableFileSize+1)); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("write too large: %v", err)
+	}
+	os.WriteFile(filepath.Join(stackDir, "logo.png"), []byte{0x89, 'P', 'N', 'G', 0}, 0644)
+	if _, err := ReadTextFile(dir, "web", "logo.png"); !errors.Is(err, ErrBinaryFile) {
+		t.Errorf("read binary: %v", err)
+	}
+
+	if err := WriteTextFile(dir, "web", "other.conf", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := RenameFile(dir, "web", "conf/nginx.conf", "other.conf"); err == nil {
+		t.Error("expected renaming onto an existing file to fail")
+	}
+	if err := RenameFile(dir, "web", "conf/nginx.conf", "nginx/default.conf"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(stackDir, "nginx", "default.conf")); err != nil {
+		t.Error(err)
+	}
+	if err := DeleteFile(dir, "web", "nginx"); err == nil {
+		t.Error("expected deleting a non-empty directory to fail")
+	}
+	if err := DeleteFile(dir, "web", "nginx/default.conf"); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteFile(dir, "web", "nginx"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestManagedFiles(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	stackDir := filepath.Join(dir, "app")
+	os.MkdirAll(filepath.Join(stackDir, "db"), 0755)
+	for name, content := range map[string]string{
+		"compose.yaml":      "include:\n  - db/compose.yaml\nservices:\n  web:\n    image: nginx\n    env_file: ./web.env\n",
+		"compose.prod.yaml": "services:\n  web:\n    env_file:\n      - path: secrets/prod.env\n        required: false\n      - ${ENV_DIR}/x.env\n      - ../shared.env\n",
+		".compose-files":    "compose.prod.yaml\n",
+		"db/compose.yaml":   "services:\n  db:\n    image: postgres\n    env_file: db.env\n",
+		"notes.txt":         "",
+	} {
+		os.WriteFile(filepath.Join(stackDir, name), []byte(content), 0644)
+	}
+
+	for name, want := range map[string]bool{
+		"compose.yaml":      true,
+		".env":              true,
+		".compose-files":    true,
+		"compose.prod.yaml": true,
+		"db/compose.yaml":   true,
+		"web.env":           true,
+		"secrets/prod.env":  true,
+		"db/db.env":         true,
+		"db.env":            false,
+		"x.env":             false,
+		"shared.env":        false,
+		"notes.txt":         false,
+	} {
+		if got := IsManagedFile(dir, "app", name); got != want {
+			t.Errorf("IsManagedFile(%s) = %v, want %v", name, got, want)
+		}
+	}
+	if err := WriteTextFile(dir, "app", "secrets/prod.env", "TOKEN=x\n"); !errors.Is(err, ErrManagedFile) {
+		t.Errorf("write an env_file: %v", err)
+	}
+
+	// Nor can the directories holding them be moved to get at them
+	os.MkdirAll(filepath.Join(stackDir, "secrets"), 0755)
+	os.WriteFile(filepath.Join(stackDir, "secrets", "prod.env"), []byte("TOKEN=x\n"), 0644)
+	os.MkdirAll(filepath.Join(stackDir, "old", "secrets"), 0755)
+	for _, mv := range [][2]string{{"secrets", "open"}, {"db", "database"}, {"old", "moved"}} {
+		err := RenameFile(dir, "app", mv[0], mv[1])
+		if want := mv[0] != "old"; errors.Is(err, ErrManagedFile) != want {
+			t.Errorf("rename %s: %v", mv[0], err)
+		}
+	}
+	// Or moved onto where a managed file goes
+	for _, to := range []string{"web.env", "secrets"} {
+		if err := RenameFile(dir, "app", "moved/secrets", to); !errors.Is(err, ErrManagedFile) {
+			t.Errorf("rename onto %s: %v", to, err)
+		}
+	}
+	for _, name := range []string{"secrets", "db"} {
+		if err := DeleteFile(dir, "app", name); !errors.Is(err, ErrManagedFile) {
+			t.Errorf("delete %s: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(stackDir, "secrets", "prod.env")); err != nil {
+		t.Errorf("env_file gone: %v", err)
+	}
+}
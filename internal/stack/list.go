@@ -33,7 +33,7 @@ func GetStackListFromContainers(stacksDir string, containers []ContainerInfo, ig
     }
 
     for _, entry := range entries {
-        if !entry.IsDir() {
+        if !IsDirEntry(stacksDir, entry) {
             continue
         }
         name := entry.Name()
@@ -89,24 +89,34 @@ func GetStackListFromContainers(stacksDir string, containers []ContainerInfo, ig
         unhealthy int
     }
     projects := make(map[string]*projectState)
+    // Health of every service with a healthcheck, status-ignored ones too
+    health := make(map[string]map[string]string)
 
     for _, c := range containers {
         project := c.Project
         if project == "" {
             continue
         }
+        svc := c.Service
+        if svc == "" {
+            svc = extractServiceFromName(c.Name)
+        }
 
-        // Skip status-ignored services
-        if ignore != nil {
-            svc := c.Service
-            if svc == "" {
-                svc = extractServiceFromName(c.Name)
+        if h := strings.ToLower(c.Health); h != "" {
+            if health[project] == nil {
+                health[project] = make(map[string]string)
             }
-            if ignore[project] != nil && ignore[project][svc] {
-                continue
+            // Replicas report the worst health among them
+            if healthRank[h] > healthRank[health[project][svc]] {
+                health[project][svc] = h
             }
         }
 
+        // Skip status-ignored services
+        if ignore != nil && ignore[project] != nil && ignore[project][svc] {
+            continue
+        }
+
         ps, ok := projects[project]
         if !ok {
             ps = &projectState{}
@@ -144,6 +154,8 @@ func GetStackListFromContainers(stacksDir string, containers []ContainerInfo, ig
             stacks[project] = s
         }
 
+        s.ServiceHealth = health[project]
+
         // Derive status from container states
         if ps.unhealthy > 0 {
             s.Status = UNHEALTHY
@@ -163,6 +175,9 @@ func GetStackListFromContainers(stacksDir string, containers []ContainerInfo, ig
     return stacks
 }
 
+// healthRank orders health states from best to worst.
+var healthRank = map[string]int{"healthy": 1, "starting": 2, "unhealthy": 3}
+
 // extractServiceFromName extracts the service name from a Docker Compose container name.
 // Format: stackname-servicename-N (e.g., "web-app-nginx-1" -> "nginx").
 // Best-effort heuristic; the Service field on ContainerInfo is preferred.
@@ -187,7 +202,7 @@ func GetStackList(stacksDir string, composeLsOutput []byte) map[string]*Stack {
     }
 
     for _, entry := range entries {
-        if !entry.IsDir() {
+        if !IsDirEntry(stacksDir, entry) {
             continue
         }
         name := entry.Name()
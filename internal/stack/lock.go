@@ -35,6 +35,29 @@ func (nm *NamedMutex) Lock(name string) {
 	e.mu.Lock()
 }
 
+// TryLock acquires the mutex for the given name if it is free and reports
+// whether it did. Used by batch operations to skip busy stacks instead of
+// queueing behind them.
+func (nm *NamedMutex) TryLock(name string) bool {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	if _, busy := nm.locks[name]; busy {
+		return false
+	}
+	e := &lockEntry{waiters: 1}
+	e.mu.Lock()
+	nm.locks[name] = e
+	return true
+}
+
+// Locked reports whether the name is currently held or waited on.
+func (nm *NamedMutex) Locked(name string) bool {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	_, busy := nm.locks[name]
+	return busy
+}
+
 // Unlock releases the mutex for the given name. If no other goroutines
 // are waiting, the entry is cleaned up to avoid unbounded map growth.
 func (nm *NamedMutex) Unlock(name string) {
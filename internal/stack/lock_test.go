@@ -84,3 +84,26 @@ func TestNamedMutexCleanup(t *testing.T) {
 		t.Error("expected lock entry to be cleaned up after last unlock")
 	}
 }
+
+func TestNamedMutexTryLock(t *testing.T) {
+	nm := NewNamedMutex()
+
+	if !nm.TryLock("a") {
+		t.Fatal("expected TryLock on a free name to succeed")
+	}
+	if nm.TryLock("a") {
+		t.Error("expected TryLock on a held name to fail")
+	}
+	if !nm.Locked("a") || nm.Locked("c") {
+		t.Error("Locked gave wrong result")
+	}
+	if !nm.TryLock("b") {
+		t.Error("expected TryLock on another name to succeed")
+	}
+	nm.Unlock("a")
+	nm.Unlock("b")
+
+	// Lock after a successful TryLock/Unlock must not deadlock
+	nm.Lock("a")
+	nm.Unlock("a")
+}
@@ -0,0 +1,70 @@
+package stack
+
+import (
+	"sort"
+	"strings"
+)
+
+// CycleError is returned by DependencyWaves when stacks depend on each
+// other. Stacks lists the stacks that couldn't be ordered: those in a
+// cycle and those depending on one.
+type CycleError struct {
+	Stacks []string
+}
+
+func (e *CycleError) Error() string {
+	return "stack dependency cycle among " + strings.Join(e.Stacks, ", ")
+}
+
+// DependencyWaves orders stacks so that each comes after the stacks it
+// depends on. deps maps every stack to its dependencies; dependencies that
+// aren't keys of deps are ignored. Stacks in the same wave don't depend on
+// each other, so they can be started together. Each wave is sorted by name.
+func DependencyWaves(deps map[string][]string) ([][]string, error) {
+	pending := make(map[string]int, len(deps)) // unmet dependencies
+	dependents := make(map[string][]string)
+	for name := range deps {
+		pending[name] = 0
+	}
+	for name, ds := range deps {
+		for _, d := range ds {
+			if _, ok := deps[d]; !ok || d == name {
+				continue
+			}
+			pending[name]++
+			dependents[d] = append(dependents[d], name)
+		}
+	}
+
+	var waves [][]string
+	var wave []string
+	for name, n := range pending {
+		if n == 0 {
+			wave = append(wave, name)
+		}
+	}
+	for len(wave) > 0 {
+		sort.Strings(wave)
+		waves = append(waves, wave)
+		var next []string
+		for _, name := range wave {
+			delete(pending, name)
+			for _, dep := range dependents[name] {
+				if pending[dep]--; pending[dep] == 0 {
+					next = append(next, dep)
+				}
+			}
+		}
+		wave = next
+	}
+
+	if len(pending) > 0 {
+		stuck := make([]string, 0, len(pending))
+		for name := range pending {
+			stuck = append(stuck, name)
+		}
+		sort.Strings(stuck)
+		return waves, &CycleError{Stacks: stuck}
+	}
+	return waves, nil
+}
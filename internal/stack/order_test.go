@@ -0,0 +1,41 @@
+package stack
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDependencyWaves(t *testing.T) {
+	waves, err := DependencyWaves(map[string][]string{
+		"apps":    {"proxy", "db"},
+		"proxy":   nil,
+		"db":      {"unknown"},
+		"monitor": {"apps", "db"},
+		"blog":    {"blog"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"blog", "db", "proxy"}, {"apps"}, {"monitor"}}
+	if !reflect.DeepEqual(waves, want) {
+		t.Errorf("DependencyWaves() = %v, want %v", waves, want)
+	}
+
+	waves, err = DependencyWaves(map[string][]string{
+		"a":    {"b"},
+		"b":    {"a"},
+		"c":    {"a"},
+		"free": nil,
+	})
+	var cycle *CycleError
+	if !errors.As(err, &cycle) {
+		t.Fatalf("expected a CycleError, got %v", err)
+	}
+	if !reflect.DeepEqual(cycle.Stacks, []string{"a", "b", "c"}) {
+		t.Errorf("cycle stacks = %v", cycle.Stacks)
+	}
+	if !reflect.DeepEqual(waves, [][]string{{"free"}}) {
+		t.Errorf("waves before the cycle = %v", waves)
+	}
+}
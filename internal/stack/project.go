@@ -0,0 +1,128 @@
+package stack
+
+import (
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/cfilipov/dockge/internal/compose"
+)
+
+// ProjectMap maps between stack directory names and compose project names,
+// which differ when a stack sets name: in its compose file or
+// COMPOSE_PROJECT_NAME in its .env. Containers carry the project name, so
+// without the map they would be shown as a separate, unmanaged stack.
+// It implements docker.ProjectResolver.
+//
+// Two stacks can end up with the same project name, through name: or
+// directories that normalize alike. Compose then treats them as one
+// project, so their containers can't be told apart by project; they're
+// only mapped by working directory, and Conflicts reports the clash.
+type ProjectMap struct {
+	stacksDir string
+
+	mu        sync.RWMutex
+	byStack   map[string]string   // stack name → project name, only where they differ
+	byProject map[string]string   // project name → stack name, only where they differ
+	byDir     map[string]string   // stack directory (as given and resolved) → stack name
+	conflicts map[string][]string // stack name → other stacks with its project name
+}
+
+// NewProjectMap scans stacksDir and returns the resulting map.
+func NewProjectMap(stacksDir string) *ProjectMap {
+	m := &ProjectMap{stacksDir: stacksDir}
+	m.Refresh()
+	return m
+}
+
+// Refresh rescans the stacks directory. Call it when compose or .env files
+// change.
+func (m *ProjectMap) Refresh() {
+	byStack := make(map[string]string)
+	byProject := make(map[string]string)
+	byDir := make(map[string]string)
+	stacksOf := make(map[string][]string) // project name → stacks, of stacks with a compose file
+
+	entries, err := os.ReadDir(m.stacksDir)
+	if err != nil {
+		slog.Warn("project map: read stacks dir", "err", err, "dir", m.stacksDir)
+	}
+	for _, entry := range entries {
+		if !IsDirEntry(m.stacksDir, entry) {
+			continue
+		}
+		name := entry.Name()
+		dir := filepath.Join(m.stacksDir, name)
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		byDir[dir] = name
+		if real, err := filepath.EvalSymlinks(dir); err == nil {
+			byDir[real] = name // linked stacks started from their original path
+		}
+		project := compose.ProjectName(dir)
+		if project != "" && project != name {
+			byStack[name] = project
+			byProject[project] = name
+		}
+		if project != "" && ComposeFileExists(m.stacksDir, name) {
+			stacksOf[project] = append(stacksOf[project], name)
+		}
+	}
+
+	conflicts := make(map[string][]string)
+	for project, names := range stacksOf {
+		if len(names) < 2 {
+			continue
+		}
+		slog.Warn("stacks share a compose project name", "project", project, "stacks", names)
+		delete(byProject, project)
+		for _, name := range names {
+			conflicts[name] = slices.DeleteFunc(slices.Clone(names), func(other string) bool { return other == name })
+		}
+	}
+
+	m.mu.Lock()
+	m.byStack, m.byProject, m.byDir, m.conflicts = byStack, byProject, byDir, conflicts
+	m.mu.Unlock()
+}
+
+// Conflicts returns the stacks that share a compose project name with
+// another stack, each with the others, sorted by name.
+func (m *ProjectMap) Conflicts() map[string][]string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return maps.Clone(m.conflicts)
+}
+
+// StackName returns the stack a container belongs to, given its compose
+// project and working directory labels. The working directory identifies
+// the stack even if the project name changed since the container was
+// created. Projects not started from a stack keep their project name.
+func (m *ProjectMap) StackName(project, workingDir string) string {
+	if project == "" {
+		return ""
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if name, ok := m.byDir[filepath.Clean(workingDir)]; ok && workingDir != "" {
+		return name
+	}
+	if name, ok := m.byProject[project]; ok {
+		return name
+	}
+	return project
+}
+
+// ProjectName returns the compose project name of a stack.
+func (m *ProjectMap) ProjectName(stackName string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if project, ok := m.byStack[stackName]; ok {
+		return project
+	}
+	return stackName
+}
@@ -0,0 +1,96 @@
+package stack
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestProjectMap(t *testing.T) {
+	t.Parallel()
+	base := t.TempDir()
+	stacksDir := filepath.Join(base, "stacks")
+	writeFile(t, filepath.Join(stacksDir, "web", "compose.yaml"), "services: {}\n")
+	writeFile(t, filepath.Join(stacksDir, "blog", "compose.yaml"), "name: wordpress\nservices: {}\n")
+	writeFile(t, filepath.Join(base, "srv", "app", "compose.yaml"), "services: {}\n")
+	if err := os.Symlink(filepath.Join(base, "srv", "app"), filepath.Join(stacksDir, "linked")); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewProjectMap(stacksDir)
+	tests := []struct {
+		project, workingDir, want string
+	}{
+		{"web", filepath.Join(stacksDir, "web"), "web"},
+		{"wordpress", filepath.Join(stacksDir, "blog"), "blog"},
+		{"wordpress", "", "blog"},
+		{"oldname", filepath.Join(stacksDir, "blog"), "blog"}, // renamed project, same dir
+		{"app", filepath.Join(base, "srv", "app"), "linked"},  // started from the link target
+		{"external", "/opt/external", "external"},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		if got := m.StackName(tt.project, tt.workingDir); got != tt.want {
+			t.Errorf("StackName(%q, %q) = %q, want %q", tt.project, tt.workingDir, got, tt.want)
+		}
+	}
+	if got := m.ProjectName("blog"); got != "wordpress" {
+		t.Errorf("ProjectName(blog) = %q", got)
+	}
+	if got := m.ProjectName("web"); got != "web" {
+		t.Errorf("ProjectName(web) = %q", got)
+	}
+
+	// Dropping the custom name maps the stack back to its directory name
+	writeFile(t, filepath.Join(stacksDir, "blog", "compose.yaml"), "services: {}\n")
+	m.Refresh()
+	if got := m.ProjectName("blog"); got != "blog" {
+		t.Errorf("after refresh ProjectName(blog) = %q", got)
+	}
+}
+
+func TestProjectMapConflicts(t *testing.T) {
+	t.Parallel()
+	stacksDir := t.TempDir()
+	writeFile(t, filepath.Join(stacksDir, "shop", "compose.yaml"), "services: {}\n")
+	writeFile(t, filepath.Join(stacksDir, "shop-v2", "compose.yaml"), "name: shop\nservices: {}\n")
+	writeFile(t, filepath.Join(stacksDir, "My.App", "compose.yaml"), "services: {}\n")
+	writeFile(t, filepath.Join(stacksDir, "myapp", "compose.yaml"), "services: {}\n")
+	writeFile(t, filepath.Join(stacksDir, "web", "compose.yaml"), "services: {}\n")
+	if err := os.MkdirAll(filepath.Join(stacksDir, "Web"), 0755); err != nil { // no compose file
+		t.Fatal(err)
+	}
+
+	m := NewProjectMap(stacksDir)
+	got := m.Conflicts()
+	want := map[string][]string{
+		"shop":    {"shop-v2"},
+		"shop-v2": {"shop"},
+		"My.App":  {"myapp"},
+		"myapp":   {"My.App"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Conflicts() = %v, want %v", got, want)
+	}
+	for name, others := range want {
+		if !slices.Equal(got[name], others) {
+			t.Errorf("Conflicts()[%s] = %v, want %v", name, got[name], others)
+		}
+	}
+
+	// Containers of a shared project are only told apart by working dir
+	if got := m.StackName("shop", filepath.Join(stacksDir, "shop-v2")); got != "shop-v2" {
+		t.Errorf("StackName by dir = %q", got)
+	}
+	if got := m.StackName("shop", ""); got != "shop" {
+		t.Errorf("StackName without dir = %q, want the project name", got)
+	}
+
+	// Renaming the project resolves the conflict
+	writeFile(t, filepath.Join(stacksDir, "shop-v2", "compose.yaml"), "name: shop2\nservices: {}\n")
+	m.Refresh()
+	if got := m.Conflicts(); got["shop"] != nil || got["shop-v2"] != nil {
+		t.Errorf("after rename Conflicts() = %v", got)
+	}
+}
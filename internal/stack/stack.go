@@ -6,6 +6,8 @@ import (
     "os"
     "path/filepath"
     "strings"
+
+    "github.com/cfilipov/dockge/internal/compose"
 )
 
 // Status constants — must match common/util-common.ts
@@ -45,6 +47,22 @@ type Stack struct {
     ComposeENV              string
     ComposeOverrideYAML     string
     Path                    string // full path to stack directory
+    // ServiceHealth maps services with a healthcheck to their health:
+    // healthy, starting or unhealthy
+    ServiceHealth map[string]string
+    // ExtraFiles are the stack's additional compose files: the listed ones
+    // (see compose.ComposeFilesList) and the ones its compose files include.
+    // SaveToDisk leaves them alone when nil.
+    ExtraFiles []ComposeFile
+}
+
+// ComposeFile is an additional compose file of a stack.
+type ComposeFile struct {
+    Name string `json:"name"` // relative to the stack directory
+    YAML string `json:"yaml"`
+    // Included is set on files pulled in with the include directive rather
+    // than listed; they aren't passed to compose as -f flags.
+    Included bool `json:"included,omitempty"`
 }
 
 // IsStarted returns true if the stack has running containers.
@@ -65,6 +83,10 @@ type StackSimpleJSON struct {
     ComposeOverrideFileName string   `json:"composeOverrideFileName"`
     Endpoint                string   `json:"endpoint"`
     ImageUpdatesAvailable   bool     `json:"imageUpdatesAvailable"`
+    // Health by service, and whether any service is unhealthy, including
+    // services whose status is ignored
+    ServiceHealth map[string]string `json:"serviceHealth,omitempty"`
+    HasUnhealthy  bool              `json:"hasUnhealthy"`
 }
 
 // StackFullJSON is the JSON representation for getStack (includes YAML content).
@@ -72,8 +94,9 @@ type StackFullJSON struct {
     StackSimpleJSON
     ComposeYAML         string `json:"composeYAML"`
     ComposeENV          string `json:"composeENV"`
-    ComposeOverrideYAML string `json:"composeOverrideYAML"`
-    PrimaryHostname     string `json:"primaryHostname"`
+    ComposeOverrideYAML string        `json:"composeOverrideYAML"`
+    ComposeFiles        []ComposeFile `json:"composeFiles"`
+    PrimaryHostname     string        `json:"primaryHostname"`
 }
 
 // ToSimpleJSON returns the stack data for the stack list broadcast.
@@ -89,7 +112,19 @@ func (s *Stack) ToSimpleJSON(endpoint string, hasUpdates, recreateNecessary bool
         ComposeOverrideFileName: s.ComposeOverrideFileName,
         Endpoint:                endpoint,
         ImageUpdatesAvailable:   hasUpdates,
+        ServiceHealth:           s.ServiceHealth,
+        HasUnhealthy:            s.HasUnhealthy(),
+    }
+}
+
+// HasUnhealthy returns true if any service of the stack is unhealthy.
+func (s *Stack) HasUnhealthy() bool {
+    for _, h := range s.ServiceHealth {
+        if h == "unhealthy" {
+            return true
+        }
     }
+    return false
 }
 
 // ToJSON returns full stack data including YAML content (for getStack).
@@ -99,6 +134,7 @@ func (s *Stack) ToJSON(endpoint, primaryHostname string, hasUpdates, recreateNec
         ComposeYAML:         s.ComposeYAML,
         ComposeENV:          s.ComposeENV,
         ComposeOverrideYAML: s.ComposeOverrideYAML,
+        ComposeFiles:        s.ExtraFiles,
         PrimaryHostname:     primaryHostname,
     }
 }
@@ -133,9 +169,49 @@ func (s *Stack) LoadFromDisk(stacksDir string) error {
         s.ComposeENV = string(data)
     }
 
+    s.ExtraFiles = s.loadExtraFiles(stacksDir)
     return nil
 }
 
+// loadExtraFiles reads the listed additional compose files, then the files
+// included by any compose file of the stack. Missing files are skipped.
+func (s *Stack) loadExtraFiles(stacksDir string) []ComposeFile {
+    files := []ComposeFile{}
+    seen := map[string]bool{s.ComposeFileName: true, s.ComposeOverrideFileName: true}
+    add := func(name string, included bool) {
+        if seen[name] {
+            return
+        }
+        seen[name] = true
+        data, err := os.ReadFile(filepath.Join(s.Path, filepath.FromSlash(name)))
+        if err != nil {
+            return
+        }
+        files = append(files, ComposeFile{Name: name, YAML: string(data), Included: included})
+    }
+    for _, name := range compose.ExtraComposeFiles(stacksDir, s.Name) {
+        add(name, false)
+    }
+    queue := []string{s.ComposeYAML, s.ComposeOverrideYAML}
+    for i := 0; i < len(files); i++ {
+        queue = append(queue, files[i].YAML)
+    }
+    for len(queue) > 0 {
+        yaml := queue[0]
+        queue = queue[1:]
+        n := len(files)
+        for _, name := range compose.IncludedFiles(yaml) {
+            if compose.ValidateExtraFileName(name) == nil {
+                add(name, true)
+            }
+        }
+        for _, f := range files[n:] {
+            queue = append(queue, f.YAML)
+        }
+    }
+    return files
+}
+
 // SaveToDisk writes the compose files to the stack directory.
 func (s *Stack) SaveToDisk(stacksDir string) error {
     s.Path = filepath.Join(stacksDir, s.Name)
@@ -179,9 +255,35 @@ func (s *Stack) SaveToDisk(stacksDir string) error {
         }
     }
 
+    if s.ExtraFiles != nil {
+        return s.saveExtraFiles(stacksDir)
+    }
     return nil
 }
 
+// saveExtraFiles writes the additional compose files and lists the ones
+// not included by another file. Files dropped from the list are kept on
+// disk; compose just no longer loads them.
+func (s *Stack) saveExtraFiles(stacksDir string) error {
+    var listed []string
+    for _, f := range s.ExtraFiles {
+        if err := compose.ValidateExtraFileName(f.Name); err != nil {
+            return err
+        }
+        path := filepath.Join(s.Path, filepath.FromSlash(f.Name))
+        if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+            return fmt.Errorf("create dir of %s: %w", f.Name, err)
+        }
+        if err := os.WriteFile(path, []byte(f.YAML), 0644); err != nil {
+            return fmt.Errorf("write %s: %w", f.Name, err)
+        }
+        if !f.Included {
+            listed = append(listed, f.Name)
+        }
+    }
+    return compose.WriteExtraComposeFiles(stacksDir, s.Name, listed)
+}
+
 // ComposeFileExists checks if any accepted compose file exists for a stack.
 func ComposeFileExists(stacksDir, stackName string) bool {
     dir := filepath.Join(stacksDir, stackName)
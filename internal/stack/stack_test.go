@@ -3,7 +3,10 @@ package stack
 import (
     "os"
     "path/filepath"
+    "reflect"
     "testing"
+
+    "github.com/cfilipov/dockge/internal/compose"
 )
 
 func TestStatusConvert(t *testing.T) {
@@ -302,3 +305,90 @@ func TestSaveDefaultsComposeFileName(t *testing.T) {
         t.Error("expected compose.yaml to exist on disk")
     }
 }
+
+func TestStackServiceHealth(t *testing.T) {
+    t.Parallel()
+
+    dir := t.TempDir()
+    os.MkdirAll(filepath.Join(dir, "web"), 0755)
+    os.WriteFile(filepath.Join(dir, "web", "compose.yaml"), []byte("services: {}\n"), 0644)
+
+    containers := []ContainerInfo{
+        {Name: "web-app-1", Project: "web", Service: "app", State: "running", Health: "healthy"},
+        {Name: "web-app-2", Project: "web", Service: "app", State: "running", Health: "starting"},
+        {Name: "web-db-1", Project: "web", Service: "db", State: "running"},
+        {Name: "web-cron-1", Project: "web", Service: "cron", State: "running", Health: "unhealthy"},
+    }
+    ignore := IgnoreMap{"web": {"cron": true}}
+    s := GetStackListFromContainers(dir, containers, ignore)["web"]
+    if s == nil {
+        t.Fatal("stack web missing")
+    }
+
+    if len(s.ServiceHealth) != 2 || s.ServiceHealth["app"] != "starting" || s.ServiceHealth["cron"] != "unhealthy" {
+        t.Errorf("ServiceHealth = %v", s.ServiceHealth)
+    }
+    // The ignored service is unhealthy: flagged, but the status is kept
+    if s.Status != RUNNING {
+        t.Errorf("Status = %v, want RUNNING", s.Status)
+    }
+    result := s.ToSimpleJSON("", false, false)
+    if !result.HasUnhealthy || result.ServiceHealth["app"] != "starting" {
+        t.Errorf("ToSimpleJSON = %+v", result)
+    }
+
+    s = GetStackListFromContainers(dir, containers)["web"]
+    if s.Status != UNHEALTHY {
+        t.Errorf("Status without ignore = %v, want UNHEALTHY", s.Status)
+    }
+}
+
+func TestSaveAndLoadExtraFiles(t *testing.T) {
+    t.Parallel()
+
+    dir := t.TempDir()
+    s := &Stack{
+        Name:        "multi",
+        ComposeYAML: "include:\n  - db/compose.yaml\nservices:\n  app:\n    image: alpine\n",
+        ExtraFiles: []ComposeFile{
+            {Name: "compose.monitoring.yaml", YAML: "services:\n  grafana:\n    image: grafana/grafana\n"},
+            {Name: "db/compose.yaml", YAML: "services:\n  db:\n    image: postgres:16\n", Included: true},
+        },
+    }
+    if err := s.SaveToDisk(dir); err != nil {
+        t.Fatal(err)
+    }
+
+    loaded := &Stack{Name: "multi"}
+    if err := loaded.LoadFromDisk(dir); err != nil {
+        t.Fatal(err)
+    }
+    if !reflect.DeepEqual(loaded.ExtraFiles, s.ExtraFiles) {
+        t.Errorf("ExtraFiles = %+v, want %+v", loaded.ExtraFiles, s.ExtraFiles)
+    }
+    data, err := os.ReadFile(filepath.Join(dir, "multi", compose.ComposeFilesList))
+    if err != nil || string(data) != "compose.monitoring.yaml\n" {
+        t.Errorf("compose file list = %q, %v", data, err)
+    }
+
+    // nil leaves the files alone; an empty list unlists them
+    s.ExtraFiles = nil
+    if err := s.SaveToDisk(dir); err != nil {
+        t.Fatal(err)
+    }
+    if names := compose.ExtraComposeFiles(dir, "multi"); len(names) != 1 {
+        t.Errorf("after saving without files: %v", names)
+    }
+    s.ExtraFiles = []ComposeFile{}
+    if err := s.SaveToDisk(dir); err != nil {
+        t.Fatal(err)
+    }
+    if names := compose.ExtraComposeFiles(dir, "multi"); names != nil {
+        t.Errorf("after saving an empty list: %v", names)
+    }
+
+    s.ExtraFiles = []ComposeFile{{Name: "../escape.yaml"}}
+    if err := s.SaveToDisk(dir); err == nil {
+        t.Error("expected an error for a file outside the stack directory")
+    }
+}
@@ -3,7 +3,13 @@ package stack
 import (
 	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cfilipov/dockge/internal/compose"
 )
 
 // ValidateStackName checks that a stack name is safe for use as a
@@ -33,3 +39,106 @@ func ValidateStackName(name string) error {
 	}
 	return nil
 }
+
+// maxNewStackNameLen bounds the names of new stacks. Existing stacks may
+// have longer names; ValidateStackName still accepts them.
+const maxNewStackNameLen = 64
+
+// reservedStackNames can't be used for new stacks. dockge is the project
+// Dockge itself is usually deployed as.
+var reservedStackNames = []string{"dockge"}
+
+// Codes of a NameError. They double as i18n keys; Param fills {param}.
+const (
+	NameEmpty        = "stackNameEmpty"
+	NameTooLong      = "stackNameTooLong"      // Param: the limit
+	NameInvalidChar  = "stackNameInvalidChar"  // Param: the character
+	NameInvalidStart = "stackNameInvalidStart" // Param: the character
+	NameReserved     = "stackNameReserved"     // Param: the name
+	NameExists       = "stackNameExists"       // Param: the existing entry
+	NameProjectTaken = "stackNameProjectTaken" // Param: the stack using it
+)
+
+// NameError is why a name can't be used for a new stack.
+type NameError struct {
+	Code  string
+	Param string
+	msg   string
+}
+
+func (e *NameError) Error() string { return e.msg }
+
+func nameError(code, param, format string, a ...any) *NameError {
+	return &NameError{Code: code, Param: param, msg: fmt.Sprintf(format, a...)}
+}
+
+// ValidateNewStackName checks that name can be used for a new stack in
+// stacksDir. Besides ValidateStackName's rules, the name must start with a
+// letter or digit like compose requires, fit maxNewStackNameLen, not be
+// reserved, and not collide with an entry of stacksDir regardless of case,
+// so it's also refused where the filesystem is case-insensitive. Nor may it
+// be the project name another stack resolves to. Name problems are
+// returned as a *NameError; other errors come from reading stacksDir.
+func ValidateNewStackName(stacksDir, name string) error {
+	if name == "" {
+		return nameError(NameEmpty, "", "stack name must not be empty")
+	}
+	if len(name) > maxNewStackNameLen {
+		return nameError(NameTooLong, strconv.Itoa(maxNewStackNameLen),
+			"stack name must not exceed %d characters", maxNewStackNameLen)
+	}
+	for i, r := range name {
+		alnum := (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9')
+		if !alnum && r != '-' && r != '_' {
+			return nameError(NameInvalidChar, string(r), "stack name contains invalid character: %q", r)
+		}
+		if i == 0 && !alnum {
+			return nameError(NameInvalidStart, string(r), "stack name must start with a lowercase letter or digit")
+		}
+	}
+	if slices.Contains(reservedStackNames, name) {
+		return nameError(NameReserved, name, "stack name %q is reserved", name)
+	}
+
+	entries, err := os.ReadDir(stacksDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("read stacks directory: %w", err)
+	}
+	for _, e := range entries {
+		if strings.EqualFold(e.Name(), name) {
+			return nameError(NameExists, e.Name(), "%q already exists in the stacks directory", e.Name())
+		}
+	}
+	for _, e := range entries {
+		if !e.IsDir() || compose.FindComposeFile(stacksDir, e.Name()) == "" {
+			continue
+		}
+		if compose.ProjectName(filepath.Join(stacksDir, e.Name())) == name {
+			return nameError(NameProjectTaken, e.Name(), "stack %q already uses the compose project name %q", e.Name(), name)
+		}
+	}
+	return nil
+}
+
+// ValidateServiceName checks that a service name is one compose accepts, so
+// it can be passed to compose as an argument without being read as a flag.
+// Letters, digits, dots, hyphens and underscores are allowed, starting with
+// a letter or digit.
+func ValidateServiceName(name string) error {
+	if name == "" {
+		return errors.New("service name must not be empty")
+	}
+	for i, r := range name {
+		alnum := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+		if i == 0 && !alnum {
+			return fmt.Errorf("service name must start with a letter or digit: %q", name)
+		}
+		if !alnum && r != '.' && r != '-' && r != '_' {
+			return fmt.Errorf("service name contains invalid character: %q", r)
+		}
+	}
+	return nil
+}
@@ -1,6 +1,9 @@
 package stack
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -70,3 +73,73 @@ func TestValidateStackName(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateServiceName(t *testing.T) {
+	for _, name := range []string{"web", "Web_1", "api.v2", "db-primary", "0cache"} {
+		if err := ValidateServiceName(name); err != nil {
+			t.Errorf("ValidateServiceName(%q) = %v, want nil", name, err)
+		}
+	}
+	for _, name := range []string{"", "-d", "--force-recreate", ".hidden", "a b", "a;id", "a/b"} {
+		if ValidateServiceName(name) == nil {
+			t.Errorf("ValidateServiceName(%q) = nil, want error", name)
+		}
+	}
+}
+
+func TestValidateNewStackName(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"web", "MixedCase"} {
+		if err := os.MkdirAll(filepath.Join(dir, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "web", "compose.yaml"), []byte("name: shop\nservices: {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		code  string
+		param string
+	}{
+		{"blog", "", ""},
+		{"0cache", "", ""},
+		{"my_app-2", "", ""},
+		{"", NameEmpty, ""},
+		{strings.Repeat("a", 65), NameTooLong, "64"},
+		{"Blog", NameInvalidChar, "B"},
+		{"a.b", NameInvalidChar, "."},
+		{"_app", NameInvalidStart, "_"},
+		{"-app", NameInvalidStart, "-"},
+		{"dockge", NameReserved, "dockge"},
+		{"web", NameExists, "web"},
+		{"mixedcase", NameExists, "MixedCase"},
+		{"notes", NameExists, "notes"},
+		{"shop", NameProjectTaken, "web"},
+	}
+	for _, tt := range tests {
+		err := ValidateNewStackName(dir, tt.name)
+		if tt.code == "" {
+			if err != nil {
+				t.Errorf("ValidateNewStackName(%q) = %v, want nil", tt.name, err)
+			}
+			continue
+		}
+		var nameErr *NameError
+		if !errors.As(err, &nameErr) {
+			t.Errorf("ValidateNewStackName(%q) = %v, want a NameError", tt.name, err)
+			continue
+		}
+		if nameErr.Code != tt.code || nameErr.Param != tt.param {
+			t.Errorf("ValidateNewStackName(%q) = %s(%q), want %s(%q)", tt.name, nameErr.Code, nameErr.Param, tt.code, tt.param)
+		}
+	}
+
+	if err := ValidateNewStackName(filepath.Join(dir, "missing"), "blog"); err != nil {
+		t.Errorf("missing stacks dir: %v, want nil", err)
+	}
+}
@@ -0,0 +1,356 @@
+// Package templates is a catalog of compose templates that new stacks can
+// be created from.
+//
+// Templates come from three kinds of sources: the built-in ones, template
+// directories on disk and remote catalogs. A directory holds one
+// subdirectory per template with a template.json (the metadata), a
+// compose.yaml and an optional .env. A remote catalog is a JSON document
+// {"templates": [...]} of templates with their files inline.
+//
+// Files refer to variables as {{ NAME }}. Only declared variables are
+// replaced, so other braces (e.g. Go templates in labels) are left alone.
+package templates
+
+import (
+	"context"
+	"crypto/rand"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed all:builtin
+var builtinFS embed.FS
+
+const (
+	// catalogCacheTTL keeps a remote catalog between listings.
+	catalogCacheTTL = 10 * time.Minute
+
+	// maxCatalogSize bounds the download of a remote catalog.
+	maxCatalogSize = 4 << 20
+
+	// generatedLength is the length of generated secrets, in bytes.
+	generatedLength = 24
+)
+
+// Sources of templates.
+const (
+	SourceBuiltin = "builtin"
+	SourceDir     = "dir"
+	SourceRemote  = "remote"
+)
+
+var (
+	validID      = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+	validVarName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	placeholder  = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+)
+
+// Variable is a value the user supplies when creating a stack.
+type Variable struct {
+	Name        string `json:"name"`
+	Label       string `json:"label,omitempty"`
+	Description string `json:"description,omitempty"`
+	Default     string `json:"default,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	// Secret values are masked in the form; empty ones are generated.
+	Secret bool `json:"secret,omitempty"`
+}
+
+// Template is a compose stack with variables.
+type Template struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Categories  []string   `json:"categories,omitempty"`
+	Website     string     `json:"website,omitempty"`
+	Variables   []Variable `json:"variables,omitempty"`
+	Compose     string     `json:"compose"`
+	Env         string     `json:"env,omitempty"`
+
+	// Source and Origin tell where the template came from: its kind and
+	// the directory or catalog URL ("" for built-in ones).
+	Source string `json:"source"`
+	Origin string `json:"origin,omitempty"`
+}
+
+// validate checks a template loaded from a source.
+func (t *Template) validate() error {
+	if !validID.MatchString(t.ID) {
+		return fmt.Errorf("invalid template id %q", t.ID)
+	}
+	if strings.TrimSpace(t.Compose) == "" {
+		return fmt.Errorf("template %s: no compose file", t.ID)
+	}
+	if t.Name == "" {
+		t.Name = t.ID
+	}
+	seen := make(map[string]bool)
+	for _, v := range t.Variables {
+		if !validVarName.MatchString(v.Name) {
+			return fmt.Errorf("template %s: invalid variable name %q", t.ID, v.Name)
+		}
+		if seen[v.Name] {
+			return fmt.Errorf("template %s: duplicate variable %s", t.ID, v.Name)
+		}
+		seen[v.Name] = true
+	}
+	return nil
+}
+
+// Render returns the template's compose and .env files with values
+// substituted. Missing values fall back to the default; missing secrets are
+// generated. It fails if a required variable has no value or a value spans
+// lines, which could inject YAML.
+func (t *Template) Render(values map[string]string) (compose, env string, err error) {
+	resolved := make(map[string]string, len(t.Variables))
+	for _, v := range t.Variables {
+		value, ok := values[v.Name]
+		if !ok || value == "" {
+			value = v.Default
+		}
+		if value == "" && v.Secret {
+			if value, err = generateSecret(); err != nil {
+				return "", "", err
+			}
+		}
+		if value == "" && v.Required {
+			return "", "", fmt.Errorf("%s is required", v.label())
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return "", "", fmt.Errorf("%s must be a single line", v.label())
+		}
+		resolved[v.Name] = value
+	}
+	replace := func(s string) string {
+		return placeholder.ReplaceAllStringFunc(s, func(m string) string {
+			name := placeholder.FindStringSubmatch(m)[1]
+			if value, ok := resolved[name]; ok {
+				return value
+			}
+			return m
+		})
+	}
+	return replace(t.Compose), replace(t.Env), nil
+}
+
+func (v *Variable) label() string {
+	if v.Label != "" {
+		return v.Label
+	}
+	return v.Name
+}
+
+func generateSecret() (string, error) {
+	b := make([]byte, generatedLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Catalog lists templates from all configured sources. Safe for concurrent
+// use.
+type Catalog struct {
+	dirs []string
+	urls []string
+	http *http.Client
+
+	mu    sync.Mutex
+	cache map[string]cachedCatalog // URL → templates
+}
+
+type cachedCatalog struct {
+	templates []Template
+	fetched   time.Time
+}
+
+// NewCatalog returns a catalog of the built-in templates plus those in dirs
+// and at the remote catalog urls. Missing directories are skipped.
+func NewCatalog(dirs, urls []string) *Catalog {
+	return &Catalog{
+		dirs:  dirs,
+		urls:  urls,
+		http:  &http.Client{Timeout: 30 * time.Second},
+		cache: make(map[string]cachedCatalog),
+	}
+}
+
+// List returns all templates sorted by name. Where IDs collide, directories
+// override built-in templates and remote catalogs override both, in the
+// order they were configured. Sources that fail are logged and skipped.
+func (c *Catalog) List(ctx context.Context) []Template {
+	byID := make(map[string]Template)
+	add := func(list []Template) {
+		for _, t := range list {
+			byID[t.ID] = t
+		}
+	}
+
+	builtin, err := loadFS(builtinFS, "builtin", SourceBuiltin, "")
+	if err != nil {
+		slog.Error("templates: built-in", "err", err)
+	}
+	add(builtin)
+	for _, dir := range c.dirs {
+		list, err := loadFS(os.DirFS(dir), ".", SourceDir, dir)
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				slog.Warn("templates: load directory", "err", err, "dir", dir)
+			}
+			continue
+		}
+		add(list)
+	}
+	for _, url := range c.urls {
+		list, err := c.remote(ctx, url)
+		if err != nil {
+			slog.Warn("templates: fetch catalog", "err", err, "url", url)
+			continue
+		}
+		add(list)
+	}
+
+	templates := make([]Template, 0, len(byID))
+	for _, t := range byID {
+		templates = append(templates, t)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		if a, b := strings.ToLower(templates[i].Name), strings.ToLower(templates[j].Name); a != b {
+			return a < b
+		}
+		return templates[i].ID < templates[j].ID
+	})
+	return templates
+}
+
+// Get returns a template by ID, or nil.
+func (c *Catalog) Get(ctx context.Context, id string) *Template {
+	for _, t := range c.List(ctx) {
+		if t.ID == id {
+			return &t
+		}
+	}
+	return nil
+}
+
+// loadFS reads a template directory: one subdirectory per template.
+// Broken templates are logged and skipped.
+func loadFS(fsys fs.FS, root, source, origin string) ([]Template, error) {
+	entries, err := fs.ReadDir(fsys, root)
+	if err != nil {
+		return nil, err
+	}
+	var templates []Template
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		t, err := loadTemplate(fsys, path.Join(root, entry.Name()))
+		if err != nil {
+			slog.Warn("templates: skip", "err", err, "template", entry.Name(), "source", source, "origin", origin)
+			continue
+		}
+		t.ID = entry.Name()
+		t.Source, t.Origin = source, origin
+		if err := t.validate(); err != nil {
+			slog.Warn("templates: skip", "err", err, "source", source, "origin", origin)
+			continue
+		}
+		templates = append(templates, *t)
+	}
+	return templates, nil
+}
+
+func loadTemplate(fsys fs.FS, dir string) (*Template, error) {
+	t := &Template{}
+	data, err := fs.ReadFile(fsys, path.Join(dir, "template.json"))
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, t); err != nil {
+		return nil, fmt.Errorf("template.json: %w", err)
+	}
+	compose, err := fs.ReadFile(fsys, path.Join(dir, "compose.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	t.Compose = string(compose)
+	env, err := fs.ReadFile(fsys, path.Join(dir, ".env"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	t.Env = string(env)
+	return t, nil
+}
+
+// remote returns the templates of a remote catalog, cached for a while.
+func (c *Catalog) remote(ctx context.Context, url string) ([]Template, error) {
+	c.mu.Lock()
+	if e, ok := c.cache[url]; ok && time.Since(e.fetched) < catalogCacheTTL {
+		c.mu.Unlock()
+		return e.templates, nil
+	}
+	c.mu.Unlock()
+
+	templates, err := c.fetch(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.cache[url] = cachedCatalog{templates: templates, fetched: time.Now()}
+	c.mu.Unlock()
+	return templates, nil
+}
+
+func (c *Catalog) fetch(ctx context.Context, url string) ([]Template, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxCatalogSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxCatalogSize {
+		return nil, fmt.Errorf("catalog larger than %d bytes", maxCatalogSize)
+	}
+	var doc struct {
+		Templates []Template `json:"templates"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	templates := make([]Template, 0, len(doc.Templates))
+	for _, t := range doc.Templates {
+		t.Source, t.Origin = SourceRemote, url
+		if err := t.validate(); err != nil {
+			slog.Warn("templates: skip", "err", err, "origin", url)
+			continue
+		}
+		templates = append(templates, t)
+	}
+	return templates, nil
+}
@@ -0,0 +1,97 @@
+package templates
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	t.Parallel()
+	tmpl := &Template{
+		ID: "app",
+		Variables: []Variable{
+			{Name: "PORT", Default: "8080", Required: true},
+			{Name: "HOST", Required: true},
+			{Name: "PASSWORD", Secret: true},
+		},
+		Compose: "ports: [\"{{ PORT }}:80\"]\nlabels: [\"{{.Name}}\", \"{{ OTHER }}\", \"{{HOST}}\"]\n",
+		Env:     "PASSWORD={{ PASSWORD }}\n",
+	}
+
+	compose, env, err := tmpl.Render(map[string]string{"HOST": "example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "ports: [\"8080:80\"]\nlabels: [\"{{.Name}}\", \"{{ OTHER }}\", \"example.com\"]\n"
+	if compose != want {
+		t.Errorf("compose = %q, want %q", compose, want)
+	}
+	password := strings.TrimSuffix(strings.TrimPrefix(env, "PASSWORD="), "\n")
+	if len(password) != 2*generatedLength {
+		t.Errorf("generated password = %q", password)
+	}
+
+	if _, _, err := tmpl.Render(nil); err == nil || !strings.Contains(err.Error(), "HOST") {
+		t.Errorf("missing required variable: %v", err)
+	}
+	if _, _, err := tmpl.Render(map[string]string{"HOST": "a\nb: c"}); err == nil {
+		t.Error("multi-line value accepted")
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Overrides the built-in nginx template
+	write("nginx/template.json", `{"name": "My Nginx"}`)
+	write("nginx/compose.yaml", "services: {}\n")
+	write("broken/template.json", `{`)
+	write("broken/compose.yaml", "services: {}\n")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"templates": [
+			{"id": "whoami", "name": "Whoami", "compose": "services: {}\n"},
+			{"id": "Bad ID", "compose": "services: {}\n"}
+		]}`))
+	}))
+	defer ts.Close()
+
+	c := NewCatalog([]string{dir, filepath.Join(dir, "missing")}, []string{ts.URL})
+	byID := make(map[string]Template)
+	for _, tmpl := range c.List(context.Background()) {
+		byID[tmpl.ID] = tmpl
+	}
+	if tmpl := byID["nginx"]; tmpl.Name != "My Nginx" || tmpl.Source != SourceDir || tmpl.Origin != dir {
+		t.Errorf("nginx = %+v", tmpl)
+	}
+	if tmpl := byID["postgres"]; tmpl.Source != SourceBuiltin || !strings.Contains(tmpl.Env, "{{ POSTGRES_PASSWORD }}") {
+		t.Errorf("postgres = %+v", tmpl)
+	}
+	if tmpl := byID["whoami"]; tmpl.Source != SourceRemote || tmpl.Origin != ts.URL {
+		t.Errorf("whoami = %+v", tmpl)
+	}
+	if _, ok := byID["broken"]; ok {
+		t.Error("broken template listed")
+	}
+	if _, ok := byID["Bad ID"]; ok {
+		t.Error("template with an invalid ID listed")
+	}
+	if c.Get(context.Background(), "redis") == nil {
+		t.Error("Get(redis) = nil")
+	}
+}
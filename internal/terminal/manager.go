@@ -1,13 +1,20 @@
 package terminal
 
 import (
+    "bufio"
     "bytes"
     "context"
+    "crypto/rand"
+    "encoding/hex"
+    "io"
     "log/slog"
     "os"
     "os/exec"
+    "sort"
+    "strings"
     "sync"
     "time"
+    "unicode/utf8"
 
     "github.com/creack/pty"
 )
@@ -20,6 +27,14 @@ const (
     TypePTY                      // pseudo-terminal (interactive shells)
 )
 
+// Stream is an interactive session that isn't a local process, such as an
+// exec attached over the Docker API. Reads return its output and writes go
+// to its input.
+type Stream interface {
+    io.ReadWriteCloser
+    Resize(rows, cols uint16) error
+}
+
 // WriteFunc is a callback for streaming terminal output to a WebSocket client.
 type WriteFunc func(data string)
 
@@ -33,6 +48,15 @@ type Terminal struct {
     buffer  *bytes.Buffer
     writers map[string]WriteFunc // connID → writer
 
+    // Resumption: streamID identifies this terminal instance, written counts
+    // every byte ever buffered, so buffer holds offsets written-Len..written.
+    streamID string
+    written  int64
+
+    // partial holds the start of a multi-byte UTF-8 character split
+    // across writes, until the rest of it arrives.
+    partial []byte
+
     // Process tracking
     cmd    *exec.Cmd
     cancel func() // context cancel or custom cleanup
@@ -41,12 +65,32 @@ type Terminal struct {
     // PTY master fd (nil for pipe-based terminals)
     ptyFile *os.File
     closed  bool
+
+    // Attached session, instead of a local process (see StartStream).
+    // stream is nil again once it ends; attached stays set.
+    stream   Stream
+    attached bool
+
+    created time.Time
+    // lastActive is when output was written, input was sent, or a writer
+    // joined or left (see CleanupPolicy).
+    lastActive time.Time
+}
+
+// CleanupPolicy closes terminals whose names start with Prefix once nobody
+// has watched them, and nothing happened on them, for Idle. It backs up the
+// explicit cleanup of each terminal's owner (RemoveAfter, last writer
+// leaving), which misses terminals whose clients vanished mid-way.
+type CleanupPolicy struct {
+    Prefix string
+    Idle   time.Duration
 }
 
 // Manager tracks all active terminals.
 type Manager struct {
     mu        sync.RWMutex
     terminals map[string]*Terminal
+    policies  []CleanupPolicy
 }
 
 func NewManager() *Manager {
@@ -69,6 +113,7 @@ func (m *Manager) StartCleanupLoop(ctx context.Context) {
                 return
             case <-ticker.C:
                 m.cleanupCompleted()
+                m.cleanupIdle(time.Now())
             }
         }
     }()
@@ -93,6 +138,60 @@ func (m *Manager) cleanupCompleted() {
     }
 }
 
+// SetCleanupPolicies replaces the cleanup policies. A terminal follows the
+// policy with the longest matching prefix; terminals no policy matches are
+// only removed by cleanupCompleted.
+func (m *Manager) SetCleanupPolicies(policies []CleanupPolicy) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.policies = policies
+}
+
+// CleanupPolicies returns the cleanup policies in effect.
+func (m *Manager) CleanupPolicies() []CleanupPolicy {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    return append([]CleanupPolicy(nil), m.policies...)
+}
+
+// policyFor returns the policy a terminal name follows, or nil. The caller
+// holds m.mu.
+func (m *Manager) policyFor(name string) *CleanupPolicy {
+    var best *CleanupPolicy
+    for i, p := range m.policies {
+        if strings.HasPrefix(name, p.Prefix) && (best == nil || len(p.Prefix) > len(best.Prefix)) {
+            best = &m.policies[i]
+        }
+    }
+    return best
+}
+
+// cleanupIdle removes and closes terminals that have been unwatched and
+// idle for longer than their policy allows.
+func (m *Manager) cleanupIdle(now time.Time) {
+    m.mu.Lock()
+    var idle []*Terminal
+    for name, t := range m.terminals {
+        p := m.policyFor(name)
+        if p == nil || p.Idle <= 0 {
+            continue
+        }
+        t.mu.Lock()
+        expired := len(t.writers) == 0 && now.Sub(t.lastActive) >= p.Idle
+        t.mu.Unlock()
+        if expired {
+            delete(m.terminals, name)
+            idle = append(idle, t)
+        }
+    }
+    m.mu.Unlock()
+
+    for _, t := range idle {
+        slog.Info("terminal closed after idling unwatched", "name", t.Name)
+        t.Close()
+    }
+}
+
 // Count returns the number of terminals in the manager.
 func (m *Manager) Count() int {
     m.mu.RLock()
@@ -100,6 +199,60 @@ func (m *Manager) Count() int {
     return len(m.terminals)
 }
 
+// TerminalInfo is a point-in-time description of a terminal for debug output.
+type TerminalInfo struct {
+    Name         string  `json:"name"`
+    Type         string  `json:"type"` // "pipe" or "pty"
+    Writers      int     `json:"writers"`
+    Running      bool    `json:"running"`
+    Closed       bool    `json:"closed"`
+    BufferLen    int     `json:"bufferLen"`
+    BytesWritten int64   `json:"bytesWritten"` // all output since the terminal was created
+    AgeSeconds   float64 `json:"ageSeconds"`
+    IdleSeconds  float64 `json:"idleSeconds"`
+    // Policy is the prefix of the cleanup policy the terminal follows.
+    Policy string `json:"policy,omitempty"`
+}
+
+// Snapshot returns info for every terminal in the manager, sorted by name.
+func (m *Manager) Snapshot() []TerminalInfo {
+    m.mu.RLock()
+    terms := make([]*Terminal, 0, len(m.terminals))
+    policies := make(map[*Terminal]string, len(m.terminals))
+    for name, t := range m.terminals {
+        terms = append(terms, t)
+        if p := m.policyFor(name); p != nil {
+            policies[t] = p.Prefix
+        }
+    }
+    m.mu.RUnlock()
+
+    now := time.Now()
+    result := make([]TerminalInfo, 0, len(terms))
+    for _, t := range terms {
+        t.mu.Lock()
+        info := TerminalInfo{
+            Name:         t.Name,
+            Type:         "pipe",
+            Writers:      len(t.writers),
+            Running:      (t.cmd != nil || t.attached) && !t.closed,
+            Closed:       t.closed,
+            BufferLen:    t.buffer.Len(),
+            BytesWritten: t.written,
+            AgeSeconds:   now.Sub(t.created).Seconds(),
+            IdleSeconds:  now.Sub(t.lastActive).Seconds(),
+            Policy:       policies[t],
+        }
+        t.mu.Unlock()
+        if t.Type == TypePTY {
+            info.Type = "pty"
+        }
+        result = append(result, info)
+    }
+    sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+    return result
+}
+
 // Get returns a terminal by name, or nil if not found.
 func (m *Manager) Get(name string) *Terminal {
     m.mu.RLock()
@@ -267,14 +420,26 @@ func (m *Manager) RemoveWriterFromAll(id string) {
 }
 
 func newTerminal(name string, typ TerminalType) *Terminal {
+    now := time.Now()
     return &Terminal{
-        Name:    name,
-        Type:    typ,
-        buffer:  &bytes.Buffer{},
-        writers: make(map[string]WriteFunc),
+        Name:       name,
+        Type:       typ,
+        buffer:     &bytes.Buffer{},
+        writers:    make(map[string]WriteFunc),
+        streamID:   newStreamID(),
+        created:    now,
+        lastActive: now,
     }
 }
 
+// newStreamID returns a random ID that differs across terminal instances and
+// server restarts, so a client never resumes into an unrelated stream.
+func newStreamID() string {
+    b := make([]byte, 8)
+    rand.Read(b)
+    return hex.EncodeToString(b)
+}
+
 // Write appends data to the buffer and fans out to all connected writers.
 // Implements io.Writer.
 //
@@ -294,12 +459,33 @@ func (t *Terminal) Write(p []byte) (int, error) {
         data = normalizeLF(p)
     }
 
-    // Buffer output (cap at 64KB, keep last 32KB on overflow)
+    // Every chunk fanned out ends on a character boundary, so clients
+    // can decode frames on their own.
+    if len(t.partial) > 0 {
+        data = append(t.partial, data...)
+        t.partial = nil
+    }
+    if n := incompleteUTF8Suffix(data); n > 0 {
+        t.partial = append([]byte(nil), data[len(data)-n:]...)
+        data = data[:len(data)-n]
+    }
+    if len(data) == 0 {
+        return len(p), nil
+    }
+
+    // Buffer output (cap at 64KB, keep last 32KB on overflow, starting on
+    // a character boundary)
     t.buffer.Write(data)
+    t.written += int64(len(data))
+    t.lastActive = time.Now()
     if t.buffer.Len() > 65536 {
         b := t.buffer.Bytes()
+        cut := len(b) - 32768
+        for cut < len(b) && !utf8.RuneStart(b[cut]) {
+            cut++
+        }
         t.buffer.Reset()
-        t.buffer.Write(b[len(b)-32768:])
+        t.buffer.Write(b[cut:])
     }
 
     // Fan out to all connected writers
@@ -311,6 +497,21 @@ func (t *Terminal) Write(p []byte) (int, error) {
     return len(p), nil
 }
 
+// incompleteUTF8Suffix returns the length of the incomplete UTF-8
+// character at the end of p, or 0 if p ends on a character boundary.
+// Invalid bytes count as complete; they're passed on as they are.
+func incompleteUTF8Suffix(p []byte) int {
+    for i := len(p) - 1; i >= 0 && i > len(p)-utf8.UTFMax; i-- {
+        if utf8.RuneStart(p[i]) {
+            if utf8.FullRune(p[i:]) {
+                return 0
+            }
+            return len(p) - i
+        }
+    }
+    return 0
+}
+
 // normalizeLF replaces bare \n (not preceded by \r) with \r\n.
 func normalizeLF(p []byte) []byte {
     // Fast path: if no \n at all, return as-is
@@ -343,16 +544,53 @@ func (t *Terminal) JoinAndGetBuffer(id string, fn WriteFunc) string {
     defer t.mu.Unlock()
     if !t.closed {
         t.writers[id] = fn
+        t.lastActive = time.Now()
     }
     return t.buffer.String()
 }
 
+// StreamID returns the ID of this terminal instance. Recreate starts a new
+// stream; offsets from a previous stream are meaningless for it.
+func (t *Terminal) StreamID() string {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    return t.streamID
+}
+
+// CanResume reports whether a client that last saw streamID can resume this
+// terminal: it is the same stream and still open.
+func (t *Terminal) CanResume(streamID string) bool {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    return streamID != "" && streamID == t.streamID && !t.closed
+}
+
+// JoinFrom atomically registers a writer and returns the buffered output a
+// client needs to catch up. If streamID matches and offset is still buffered,
+// only the output after offset is returned and resumed is true. Otherwise the
+// whole buffer is returned. start is the stream offset of the returned data;
+// a client adds the bytes it receives to it to track its position.
+func (t *Terminal) JoinFrom(id string, fn WriteFunc, streamID string, offset int64) (data string, start int64, resumed bool) {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    if !t.closed {
+        t.writers[id] = fn
+        t.lastActive = time.Now()
+    }
+    start = t.written - int64(t.buffer.Len())
+    if streamID == t.streamID && offset >= start && offset <= t.written {
+        return string(t.buffer.Bytes()[offset-start:]), offset, true
+    }
+    return t.buffer.String(), start, false
+}
+
 // AddWriter registers a WebSocket client to receive terminal output.
 func (t *Terminal) AddWriter(id string, fn WriteFunc) {
     t.mu.Lock()
     defer t.mu.Unlock()
     if !t.closed {
         t.writers[id] = fn
+        t.lastActive = time.Now()
     }
 }
 
@@ -360,7 +598,21 @@ func (t *Terminal) AddWriter(id string, fn WriteFunc) {
 func (t *Terminal) RemoveWriter(id string) {
     t.mu.Lock()
     defer t.mu.Unlock()
-    delete(t.writers, id)
+    if _, ok := t.writers[id]; ok {
+        delete(t.writers, id)
+        t.lastActive = time.Now()
+    }
+}
+
+// WriterKeys returns the IDs of all registered writers.
+func (t *Terminal) WriterKeys() []string {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    keys := make([]string, 0, len(t.writers))
+    for k := range t.writers {
+        keys = append(keys, k)
+    }
+    return keys
 }
 
 // WriterCount returns the number of registered writers.
@@ -370,38 +622,48 @@ func (t *Terminal) WriterCount() int {
     return len(t.writers)
 }
 
-// Input writes data to the terminal's stdin (PTY master fd).
+// Input writes data to the terminal's stdin (PTY master fd or stream).
 // For pipe-based terminals this is a no-op.
 func (t *Terminal) Input(data string) error {
     t.mu.Lock()
     f := t.ptyFile
+    s := t.stream
+    t.lastActive = time.Now()
     t.mu.Unlock()
 
     if f != nil {
         _, err := f.WriteString(data)
         return err
     }
+    if s != nil {
+        _, err := io.WriteString(s, data)
+        return err
+    }
     return nil
 }
 
-// Resize changes the PTY window size.
+// Resize changes the PTY or stream window size.
 // For pipe-based terminals this is a no-op.
 func (t *Terminal) Resize(rows, cols uint16) error {
     t.mu.Lock()
     f := t.ptyFile
+    s := t.stream
     t.mu.Unlock()
 
     if f != nil {
         return pty.Setsize(f, &pty.Winsize{Rows: rows, Cols: cols})
     }
+    if s != nil {
+        return s.Resize(rows, cols)
+    }
     return nil
 }
 
-// IsRunning returns true if the terminal has a running process.
+// IsRunning returns true if the terminal has a running process or stream.
 func (t *Terminal) IsRunning() bool {
     t.mu.Lock()
     defer t.mu.Unlock()
-    return t.cmd != nil && !t.closed
+    return (t.cmd != nil || t.attached) && !t.closed
 }
 
 // StartPTY starts a command with a pseudo-terminal. The PTY output is
@@ -444,6 +706,39 @@ func (t *Terminal) StartPTY(cmd *exec.Cmd) error {
     return nil
 }
 
+// StartStream attaches an interactive session the way StartPTY starts a
+// command: its output is continuously read and written to the terminal
+// buffer/fan-out, and the OnExit callback runs when it ends.
+func (t *Terminal) StartStream(s Stream) {
+    t.mu.Lock()
+    t.stream = s
+    t.attached = true
+    t.mu.Unlock()
+
+    go func() {
+        buf := make([]byte, 4096)
+        for {
+            n, err := s.Read(buf)
+            if n > 0 {
+                t.Write(buf[:n])
+            }
+            if err != nil {
+                break
+            }
+        }
+        s.Close()
+
+        t.mu.Lock()
+        t.stream = nil
+        exitFn := t.onExit
+        t.mu.Unlock()
+
+        if exitFn != nil {
+            exitFn()
+        }
+    }()
+}
+
 // RunPTY starts a command with a pseudo-terminal and blocks until the command
 // exits. Output is streamed to the terminal buffer/fan-out in real time.
 // Unlike StartPTY, this is synchronous — use it for compose actions where you
@@ -480,6 +775,45 @@ func (t *Terminal) RunPTY(cmd *exec.Cmd) error {
     return waitErr
 }
 
+// RunLines runs a command with stdout and stderr piped (no PTY) and blocks
+// until it exits. Each output line is passed to fn, whose result is written
+// to the terminal followed by \r\n; a nil result drops the line. Use it when
+// the output is machine-readable, e.g. compose's --progress json.
+func (t *Terminal) RunLines(cmd *exec.Cmd, fn func(line []byte) []byte) error {
+    pr, pw := io.Pipe()
+    cmd.Stdout = pw
+    cmd.Stderr = pw
+    if err := cmd.Start(); err != nil {
+        return err
+    }
+
+    t.mu.Lock()
+    t.cmd = cmd
+    t.mu.Unlock()
+
+    waitErr := make(chan error, 1)
+    go func() {
+        err := cmd.Wait()
+        pw.Close()
+        waitErr <- err
+    }()
+
+    scanner := bufio.NewScanner(pr)
+    scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+    for scanner.Scan() {
+        if out := fn(scanner.Bytes()); out != nil {
+            // Copy: out may alias the scanner's buffer.
+            line := make([]byte, 0, len(out)+2)
+            t.Write(append(append(line, out...), '\r', '\n'))
+        }
+    }
+    // Keep draining if the scanner gave up on an oversized line, so the
+    // process doesn't block on a full pipe.
+    io.Copy(io.Discard, pr)
+
+    return <-waitErr
+}
+
 // SetCancel stores a cancel function called on Close.
 // Used for pipe-based terminals with long-running processes (e.g., log streaming).
 func (t *Terminal) SetCancel(fn func()) {
@@ -488,7 +822,8 @@ func (t *Terminal) SetCancel(fn func()) {
     t.cancel = fn
 }
 
-// OnExit registers a callback invoked when a StartPTY process exits.
+// OnExit registers a callback invoked when a StartPTY process or StartStream
+// session exits.
 func (t *Terminal) OnExit(fn func()) {
     t.mu.Lock()
     defer t.mu.Unlock()
@@ -520,5 +855,9 @@ func (t *Terminal) Close() {
         t.ptyFile.Close()
         t.ptyFile = nil
     }
+    if t.stream != nil {
+        t.stream.Close()
+        t.stream = nil
+    }
     t.writers = nil
 }
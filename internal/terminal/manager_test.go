@@ -1,10 +1,15 @@
 package terminal
 
 import (
+    "bytes"
+    "io"
+    "os/exec"
+    "slices"
     "strings"
     "sync"
     "testing"
     "time"
+    "unicode/utf8"
 )
 
 func TestManagerCreateGet(t *testing.T) {
@@ -233,6 +238,125 @@ func TestTerminalBufferOverflow(t *testing.T) {
     }
 }
 
+func TestTerminalWriteSplitRune(t *testing.T) {
+    t.Parallel()
+
+    term := newTerminal("test", TypePTY)
+    var chunks []string
+    term.AddWriter("c1", func(data string) { chunks = append(chunks, data) })
+
+    euro := []byte("€") // 3 bytes
+    term.Write(append([]byte("a"), euro[:1]...))
+    term.Write(euro[1:2])
+    term.Write(append(euro[2:], 'b'))
+
+    if want := []string{"a", "€b"}; !slices.Equal(chunks, want) {
+        t.Errorf("chunks = %q, want %q", chunks, want)
+    }
+    if buf := term.Buffer(); buf != "a€b" {
+        t.Errorf("Buffer() = %q", buf)
+    }
+
+    // Invalid bytes aren't held back
+    term.Write([]byte{0xff})
+    if last := chunks[len(chunks)-1]; last != "\xff" {
+        t.Errorf("invalid byte chunk = %q", last)
+    }
+}
+
+func TestTerminalBufferOverflowRuneBoundary(t *testing.T) {
+    t.Parallel()
+
+    term := newTerminal("test", TypePTY)
+    term.Write([]byte(strings.Repeat("€", 25000))) // 75000 bytes
+
+    buf := term.Buffer()
+    if !utf8.ValidString(buf) {
+        t.Errorf("trimmed buffer starts mid-character: % x", buf[:4])
+    }
+    if len(buf) > 32768 || len(buf) < 32766 {
+        t.Errorf("trimmed buffer len = %d", len(buf))
+    }
+}
+
+func TestTerminalJoinFrom(t *testing.T) {
+    t.Parallel()
+
+    term := newTerminal("test", TypePTY)
+    term.Write([]byte("hello"))
+    id := term.StreamID()
+
+    // Fresh join gets the whole buffer from offset 0
+    data, start, resumed := term.JoinFrom("a", func(string) {}, "", 0)
+    if data != "hello" || start != 0 || resumed {
+        t.Errorf("fresh join = (%q, %d, %v)", data, start, resumed)
+    }
+
+    term.Write([]byte(" world"))
+
+    // Resume gets only what was missed
+    data, start, resumed = term.JoinFrom("b", func(string) {}, id, 5)
+    if data != " world" || start != 5 || !resumed {
+        t.Errorf("resume = (%q, %d, %v)", data, start, resumed)
+    }
+
+    // Caught-up resume gets nothing
+    data, _, resumed = term.JoinFrom("c", func(string) {}, id, 11)
+    if data != "" || !resumed {
+        t.Errorf("caught-up resume = (%q, %v)", data, resumed)
+    }
+
+    // Another stream's offset falls back to a full replay
+    data, start, resumed = term.JoinFrom("d", func(string) {}, "other", 5)
+    if data != "hello world" || start != 0 || resumed {
+        t.Errorf("foreign stream = (%q, %d, %v)", data, start, resumed)
+    }
+    if term.WriterCount() != 4 {
+        t.Errorf("expected 4 writers, got %d", term.WriterCount())
+    }
+}
+
+func TestTerminalJoinFromTrimmedOffset(t *testing.T) {
+    t.Parallel()
+
+    term := newTerminal("test", TypePTY)
+    term.Write([]byte(strings.Repeat("x", 70000)))
+    id := term.StreamID()
+
+    // Offset 10 was dropped on overflow: full replay starting at the buffer
+    data, start, resumed := term.JoinFrom("a", func(string) {}, id, 10)
+    if resumed || start != 70000-32768 || len(data) != 32768 {
+        t.Errorf("trimmed resume = (len %d, %d, %v)", len(data), start, resumed)
+    }
+
+    // Offsets past the end are rejected too
+    if _, _, resumed := term.JoinFrom("b", func(string) {}, id, 80000); resumed {
+        t.Error("offset past end should not resume")
+    }
+}
+
+func TestManagerRecreateNewStream(t *testing.T) {
+    t.Parallel()
+
+    m := NewManager()
+    old := m.Create("test", TypePipe)
+    oldID := old.StreamID()
+    if !old.CanResume(oldID) {
+        t.Error("open terminal should be resumable")
+    }
+
+    term := m.Recreate("test", TypePipe)
+    if term.StreamID() == oldID {
+        t.Error("Recreate should start a new stream")
+    }
+    if term.CanResume(oldID) || old.CanResume(oldID) {
+        t.Error("old stream should not be resumable after Recreate")
+    }
+    if term.CanResume("") {
+        t.Error("empty stream ID should not be resumable")
+    }
+}
+
 func TestTerminalNormalizeLF(t *testing.T) {
     t.Parallel()
 
@@ -424,6 +548,70 @@ func TestTerminalResizeNoOp(t *testing.T) {
     }
 }
 
+// fakeStream is a Stream whose output is written by the test and whose
+// input and sizes are recorded.
+type fakeStream struct {
+    *io.PipeReader
+    out   *io.PipeWriter
+    mu    sync.Mutex
+    input bytes.Buffer
+    size  [2]uint16
+}
+
+func newFakeStream() *fakeStream {
+    r, w := io.Pipe()
+    return &fakeStream{PipeReader: r, out: w}
+}
+
+func (f *fakeStream) Write(p []byte) (int, error) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    return f.input.Write(p)
+}
+
+func (f *fakeStream) Resize(rows, cols uint16) error {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    f.size = [2]uint16{rows, cols}
+    return nil
+}
+
+func TestTerminalStartStream(t *testing.T) {
+    t.Parallel()
+
+    term := newTerminal("test", TypePTY)
+    s := newFakeStream()
+    exited := make(chan struct{})
+    term.OnExit(func() { close(exited) })
+    term.StartStream(s)
+    if !term.IsRunning() {
+        t.Error("terminal with a stream should be running")
+    }
+
+    s.out.Write([]byte("$ "))
+    if err := term.Input("ls\n"); err != nil {
+        t.Fatal(err)
+    }
+    if err := term.Resize(40, 120); err != nil {
+        t.Fatal(err)
+    }
+    s.out.Close()
+
+    select {
+    case <-exited:
+    case <-time.After(2 * time.Second):
+        t.Fatal("OnExit not called when the stream ended")
+    }
+    if got := term.Buffer(); got != "$ " {
+        t.Errorf("buffer = %q, want the stream output", got)
+    }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if s.input.String() != "ls\n" || s.size != [2]uint16{40, 120} {
+        t.Errorf("stream got input %q and size %v", s.input.String(), s.size)
+    }
+}
+
 func TestManagerCount(t *testing.T) {
     t.Parallel()
 
@@ -482,3 +670,67 @@ func TestCleanupCompleted(t *testing.T) {
         t.Error("idle (not closed) terminal should not be cleaned up")
     }
 }
+
+func TestCleanupIdle(t *testing.T) {
+    t.Parallel()
+    m := NewManager()
+    m.SetCleanupPolicies([]CleanupPolicy{
+        {Prefix: "container-", Idle: 5 * time.Minute},
+        {Prefix: "container-exec-", Idle: 30 * time.Minute},
+    })
+
+    logs := m.Create("container-web", TypePipe)
+    shell := m.Create("container-exec-web", TypePTY)
+    watched := m.Create("container-db", TypePipe)
+    watched.AddWriter("c1", func(string) {})
+    m.Create("console", TypePTY)
+
+    if p := m.policyFor("container-exec-web"); p == nil || p.Prefix != "container-exec-" {
+        t.Errorf("policyFor(container-exec-web) = %+v, want the longest prefix", p)
+    }
+
+    m.cleanupIdle(time.Now().Add(10 * time.Minute))
+    if m.Get("container-web") != nil || !logs.closed {
+        t.Error("idle unwatched log terminal should be closed and removed")
+    }
+    if m.Get("container-exec-web") == nil || shell.closed {
+        t.Error("shell terminal has a longer idle limit and should be kept")
+    }
+    if m.Get("container-db") == nil {
+        t.Error("watched terminal should be kept")
+    }
+    if m.Get("console") == nil {
+        t.Error("terminal without a policy should be kept")
+    }
+
+    m.cleanupIdle(time.Now().Add(time.Hour))
+    if m.Get("container-exec-web") != nil {
+        t.Error("shell terminal idle past its limit should be removed")
+    }
+
+    for _, info := range m.Snapshot() {
+        if info.Name == "container-db" && info.Policy != "container-" {
+            t.Errorf("container-db policy = %q", info.Policy)
+        }
+    }
+}
+
+func TestTerminalRunLines(t *testing.T) {
+    t.Parallel()
+
+    term := newTerminal("test", TypePTY)
+    cmd := exec.Command("sh", "-c", "echo keep; echo drop; echo err >&2")
+    err := term.RunLines(cmd, func(line []byte) []byte {
+        if bytes.Equal(line, []byte("drop")) {
+            return nil
+        }
+        return append([]byte("> "), line...)
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    buf := term.Buffer()
+    if !strings.Contains(buf, "> keep\r\n") || !strings.Contains(buf, "> err\r\n") || strings.Contains(buf, "drop") {
+        t.Errorf("Buffer() = %q", buf)
+    }
+}
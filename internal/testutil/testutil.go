@@ -3,7 +3,6 @@ package testutil
 import (
     "context"
     "encoding/json"
-    "fmt"
     "log"
     "net/http"
     "net/http/httptest"
@@ -18,7 +17,9 @@ import (
     "github.com/cfilipov/dockge/internal/docker"
     "github.com/cfilipov/dockge/internal/handlers"
     "github.com/cfilipov/dockge/internal/models"
+    "github.com/cfilipov/dockge/internal/secrets"
     "github.com/cfilipov/dockge/internal/stack"
+    "github.com/cfilipov/dockge/internal/templates"
     "github.com/cfilipov/dockge/internal/terminal"
     "github.com/cfilipov/dockge/internal/ws"
 
@@ -187,6 +188,8 @@ func setupWithStacks(t testing.TB, stackNames ...string) *TestEnv {
     if err != nil {
         t.Fatal("new sdk client:", err)
     }
+    projects := stack.NewProjectMap(stacksDir)
+    dockerClient.SetProjectResolver(projects)
 
     // Force API version negotiation before any concurrent use. The Docker SDK
     // client with WithAPIVersionNegotiation() lazily writes the negotiated
@@ -205,17 +208,45 @@ func setupWithStacks(t testing.TB, stackNames ...string) *TestEnv {
 
     // Assemble App
     app := &handlers.App{
-        Users:        users,
-        Settings:     settings,
-        ImageUpdates: imageUpdates,
-        WS:           wss,
-        Docker:       dockerClient,
-        Terms:        terms,
-        StackLocks:   stack.NewNamedMutex(),
-        JWTSecret:    jwtSecret,
-        NeedSetup:    userCount == 0,
-        Version:      "test",
-        StacksDir:    stacksDir,
+        Users:          users,
+        Settings:       settings,
+        ImageUpdates:   imageUpdates,
+        PendingChanges: models.NewPendingChangeStore(database),
+        Budgets:        models.NewStackBudgetStore(database),
+        Operations:     models.NewOperationStore(database),
+        Audit:          models.NewAuditStore(database),
+        UpdateIgnores:  models.NewUpdateIgnoreStore(database),
+        StackNotes:     models.NewStackNoteStore(database),
+        StackEvents:    models.NewStackEventStore(database),
+        StackArchive:   models.NewStackArchiveStore(database),
+        TerminalAccess: models.NewStackTerminalAccessStore(database),
+        TerminalEnv:    models.NewStackTerminalEnvStore(database),
+        ExecDefaults:   models.NewExecDefaultsStore(database),
+        LogCapture:     models.NewStackLogCaptureStore(database),
+        PullPolicies:   models.NewStackPullPolicyStore(database),
+        ServiceSelections: models.NewStackServiceSelectionStore(database),
+        StackWebhooks:  models.NewStackWebhookStore(database),
+        DeployedCompose: models.NewDeployedComposeStore(database),
+        StackGroups:    models.NewStackGroupStore(database),
+        HostState:      models.NewHostStateStore(database),
+        APITokens:      models.NewAPITokenStore(database),
+        Idempotency:    models.NewIdempotencyStore(database),
+        Schedules:      models.NewStackScheduleStore(database),
+        Agents:         models.NewAgentStore(database),
+        Templates:      templates.NewCatalog([]string{filepath.Join(dataDir, "templates")}, nil),
+        Secrets:        secrets.NewResolver(nil),
+        WS:             wss,
+        Docker:         dockerClient,
+        Terms:          terms,
+        StackLocks:     stack.NewNamedMutex(),
+        Projects:       projects,
+        JWTSecret:      jwtSecret,
+        NeedSetup:      userCount == 0,
+        Version:        "test",
+        StacksDir:      stacksDir,
+        ExportDir:      filepath.Join(dataDir, "exports"),
+        DataDir:        dataDir,
+        DB:             database,
     }
 
     // Register all handlers
@@ -225,18 +256,74 @@ func setupWithStacks(t testing.TB, stackNames ...string) *TestEnv {
     handlers.RegisterDockerHandlers(app)
     handlers.RegisterServiceHandlers(app)
     handlers.RegisterTerminalHandlers(app)
+    handlers.RegisterHostTerminalHandlers(app)
+    handlers.RegisterUserHandlers(app)
+    handlers.RegisterApprovalHandlers(app)
+    handlers.RegisterPinningHandlers(app)
+    handlers.RegisterBudgetHandlers(app)
+    handlers.RegisterOperationHandlers(app)
+    handlers.RegisterAuditHandlers(app)
+    handlers.RegisterUpdateAllHandlers(app)
+    handlers.RegisterUpdateIgnoreHandlers(app)
+    handlers.RegisterStackNoteHandlers(app)
+    handlers.RegisterStackArchiveHandlers(app)
+    handlers.RegisterHousekeepingHandlers(app)
+    handlers.RegisterDiscoveryHandlers(app)
+    handlers.RegisterScheduleHandlers(app)
+    handlers.RegisterSnapshotHandlers(app)
+    handlers.RegisterAgentHandlers(app)
+    handlers.RegisterMetricsHandlers(app)
+    handlers.RegisterEnvReplaceHandlers(app)
+    handlers.RegisterTerminalAccessHandlers(app)
+    handlers.RegisterTerminalEnvHandlers(app)
+    handlers.RegisterPullPolicyHandlers(app)
+    handlers.RegisterStartServicesHandlers(app)
+    handlers.RegisterRebootReportHandlers(app)
+    handlers.RegisterStackFileHandlers(app)
+    handlers.RegisterAPITokenHandlers(app)
+    handlers.RegisterDeployedComposeHandlers(app)
+    handlers.RegisterPruneHandlers(app)
+    handlers.RegisterStackWebhookHandlers(app)
+    handlers.RegisterNetworkHandlers(app)
+    handlers.RegisterOrphanHandlers(app)
+    handlers.RegisterStackOrderHandlers(app)
+    handlers.RegisterStackGroupHandlers(app)
+    handlers.RegisterTerminalAdminHandlers(app)
+    handlers.RegisterStackListHandlers(app)
+    handlers.RegisterTemplateHandlers(app)
+    handlers.RegisterStackBackupHandlers(app)
+    handlers.RegisterConfigBackupHandlers(app)
+    handlers.RegisterDotEnvHandlers(app)
+    handlers.RegisterLogCaptureHandlers(app)
+    handlers.RegisterVolumeBrowserHandlers(app)
+    handlers.RegisterStackLogHandlers(app)
+    handlers.RegisterEventsFeedHandlers(app)
+    handlers.RegisterNotifyHandlers(app)
+    handlers.RegisterDebugHandlers(app)
 
     // Wire disconnect cleanup
     wss.OnDisconnect(func(c *ws.Conn) {
         for _, s := range c.DrainSessions() {
-            terms.RemoveWriterAndCleanup(s.TermName, s.WriterKey)
+            if s.Forward == nil {
+                terms.RemoveWriterAndCleanup(s.TermName, s.WriterKey)
+            }
         }
         app.CancelStatsSub(c.ID())
+        app.CancelEventsFeedSub(c.ID())
+        app.CancelStackListSub(c.ID())
+        app.DropAgentChannels(c)
     })
 
     // HTTP mux with WS and health
     mux := http.NewServeMux()
     mux.Handle("/ws", wss.UpgradeHandler())
+    mux.HandleFunc("GET /agent", app.ServeAgentLink)
+    mux.HandleFunc("GET /api/backups/{token}", app.ServeBackup)
+    mux.HandleFunc("POST /api/backups/{token}", app.ServeBackup)
+    mux.HandleFunc("GET /api/logs/{token}", app.ServeCapturedLog)
+    mux.HandleFunc("GET /api/volume-files/{token}", app.ServeVolumeFile)
+    mux.HandleFunc("GET /api/graphql", app.ServeGraphQL)
+    mux.HandleFunc("POST /api/graphql", app.ServeGraphQL)
     mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
         w.WriteHeader(http.StatusOK)
         w.Write([]byte("ok"))
@@ -335,6 +422,17 @@ func (e *TestEnv) Login(t testing.TB, conn *websocket.Conn) string {
 // SendAndReceive sends a WS event with an ack ID and returns the parsed ack response.
 func (e *TestEnv) SendAndReceive(t testing.TB, conn *websocket.Conn, event string, args ...interface{}) map[string]interface{} {
     t.Helper()
+    return e.sendAndReceive(t, conn, event, "", args)
+}
+
+// SendAndReceiveWithKey is SendAndReceive with an idempotency key.
+func (e *TestEnv) SendAndReceiveWithKey(t testing.TB, conn *websocket.Conn, key, event string, args ...interface{}) map[string]interface{} {
+    t.Helper()
+    return e.sendAndReceive(t, conn, event, key, args)
+}
+
+func (e *TestEnv) sendAndReceive(t testing.TB, conn *websocket.Conn, event, key string, args []interface{}) map[string]interface{} {
+    t.Helper()
 
     id := atomic.AddInt64(&msgIDCounter, 1)
 
@@ -348,6 +446,9 @@ func (e *TestEnv) SendAndReceive(t testing.TB, conn *websocket.Conn, event strin
         "event": event,
         "args":  json.RawMessage(argsJSON),
     }
+    if key != "" {
+        msg["key"] = key
+    }
 
     ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
     defer cancel()
@@ -502,7 +603,6 @@ func findStacksDirFatal() string {
         }
         dir = parent
     }
-    panic("unreachable")
 }
 
 // fallbackStacksDir copies stacks from test-data into a temp dir for tests
@@ -552,7 +652,6 @@ func findTestdata(t testing.TB) string {
         }
         dir = parent
     }
-    panic(fmt.Sprintf("unreachable"))
 }
 
 // copyDir recursively copies src to dst.
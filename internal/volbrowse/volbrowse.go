@@ -0,0 +1,353 @@
+// Package volbrowse reads the files of Docker volumes: it lists their
+// directories and opens their files. It reads a volume straight from its
+// mountpoint when Dockge can (running as root on the host, or with the
+// volumes directory mounted), and otherwise through short-lived helper
+// containers that mount the volume read-only.
+package volbrowse
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry types.
+const (
+	TypeFile    = "file"
+	TypeDir     = "dir"
+	TypeSymlink = "symlink"
+	TypeOther   = "other" // sockets, devices, pipes
+)
+
+// ErrNotFile is returned by Open for anything but a regular file.
+var ErrNotFile = errors.New("not a regular file")
+
+// Entry is a file in a volume. Symlinks aren't followed.
+type Entry struct {
+	Name    string    `json:"name"`
+	Type    string    `json:"type"`
+	Size    int64     `json:"size"`
+	Mode    string    `json:"mode"` // permissions as ls shows them, "rwxr-xr-x"
+	ModTime time.Time `json:"modTime"`
+}
+
+// Browser reads the files of one volume. Paths are relative to the root of
+// the volume, as CleanPath returns them.
+type Browser interface {
+	// List returns the entries of a directory, directories first, at most
+	// max of them and whether there were more.
+	List(ctx context.Context, dir string, max int) ([]Entry, bool, error)
+
+	// Stat returns the entry of a file.
+	Stat(ctx context.Context, name string) (Entry, error)
+
+	// Open opens a regular file for reading. The caller must close it.
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+
+	// Direct reports whether the volume is read from its mountpoint rather
+	// than through helper containers.
+	Direct() bool
+}
+
+// New returns a Browser of a volume: one reading its mountpoint if Dockge
+// can read it, otherwise one running image in helper containers.
+func New(volume, mountpoint, image string) Browser {
+	if mountpoint != "" {
+		if f, err := os.Open(mountpoint); err == nil {
+			_, err = f.ReadDir(1)
+			f.Close()
+			if err == nil || errors.Is(err, io.EOF) {
+				return mountBrowser{mountpoint: mountpoint}
+			}
+		}
+	}
+	return helperBrowser{volume: volume, image: image}
+}
+
+// CleanPath turns a path inside a volume, as a client sends it, into one
+// relative to the volume's root: "." for the root itself. ".." can't climb
+// out of the volume.
+func CleanPath(p string) (string, error) {
+	if strings.ContainsRune(p, 0) {
+		return "", errors.New("invalid path")
+	}
+	rel := strings.TrimPrefix(path.Clean("/"+p), "/")
+	if rel == "" {
+		return ".", nil
+	}
+	return rel, nil
+}
+
+// sortEntries puts directories first, then sorts by name.
+func sortEntries(entries []Entry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if di, dj := entries[i].Type == TypeDir, entries[j].Type == TypeDir; di != dj {
+			return di
+		}
+		return entries[i].Name < entries[j].Name
+	})
+}
+
+func limitEntries(entries []Entry, max int) ([]Entry, bool) {
+	sortEntries(entries)
+	if max > 0 && len(entries) > max {
+		return entries[:max], true
+	}
+	return entries, false
+}
+
+func entryType(mode fs.FileMode) string {
+	switch {
+	case mode.IsRegular():
+		return TypeFile
+	case mode.IsDir():
+		return TypeDir
+	case mode&fs.ModeSymlink != 0:
+		return TypeSymlink
+	}
+	return TypeOther
+}
+
+func entryOf(name string, info fs.FileInfo) Entry {
+	return Entry{
+		Name:    name,
+		Type:    entryType(info.Mode()),
+		Size:    info.Size(),
+		Mode:    info.Mode().Perm().String()[1:],
+		ModTime: info.ModTime().UTC(),
+	}
+}
+
+// mountBrowser reads a volume from its mountpoint. Symlinks that point out
+// of the volume can't be followed, see os.Root.
+type mountBrowser struct {
+	mountpoint string
+}
+
+func (b mountBrowser) Direct() bool { return true }
+
+func (b mountBrowser) List(_ context.Context, dir string, max int) ([]Entry, bool, error) {
+	root, err := os.OpenRoot(b.mountpoint)
+	if err != nil {
+		return nil, false, err
+	}
+	defer root.Close()
+	f, err := root.Open(dir)
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+	dirEntries, err := f.ReadDir(-1)
+	if err != nil {
+		return nil, false, err
+	}
+	entries := make([]Entry, 0, len(dirEntries))
+	for _, de := range dirEntries {
+		info, err := de.Info()
+		if err != nil {
+			continue // removed meanwhile
+		}
+		entries = append(entries, entryOf(de.Name(), info))
+	}
+	entries, truncated := limitEntries(entries, max)
+	return entries, truncated, nil
+}
+
+func (b mountBrowser) Stat(_ context.Context, name string) (Entry, error) {
+	root, err := os.OpenRoot(b.mountpoint)
+	if err != nil {
+		return Entry{}, err
+	}
+	defer root.Close()
+	info, err := root.Lstat(name)
+	if err != nil {
+		return Entry{}, err
+	}
+	return entryOf(path.Base(name), info), nil
+}
+
+func (b mountBrowser) Open(_ context.Context, name string) (io.ReadCloser, error) {
+	root, err := os.OpenRoot(b.mountpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+	if info, err := root.Lstat(name); err != nil {
+		return nil, err
+	} else if !info.Mode().IsRegular() {
+		return nil, ErrNotFile
+	}
+	return root.Open(name)
+}
+
+// helperBrowser reads a volume through helper containers, which see it
+// read-only at /volume and have no network.
+type helperBrowser struct {
+	volume string
+	image  string
+}
+
+func (b helperBrowser) Direct() bool { return false }
+
+func (b helperBrowser) command(ctx context.Context, args ...string) *exec.Cmd {
+	run := []string{"run", "--rm", "--network", "none", "-v", b.volume + ":/volume:ro", b.image}
+	return exec.CommandContext(ctx, "docker", append(run, args...)...)
+}
+
+// statFormat makes stat print what parseStat reads: the raw mode in hex,
+// the size, the mtime in Unix seconds and the name.
+const statFormat = "%f %s %Y %n"
+
+// run runs a command in a helper container and returns its output, or its
+// stderr as the error.
+func (b helperBrowser) run(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := b.command(ctx, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if text := strings.TrimSpace(stderr.String()); text != "" {
+			return nil, errors.New(text)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+func (b helperBrowser) List(ctx context.Context, dir string, max int) ([]Entry, bool, error) {
+	script := `cd -- "$1" && find . -mindepth 1 -maxdepth 1 -exec stat -c '` + statFormat + `' {} +`
+	out, err := b.run(ctx, "sh", "-c", script, "sh", path.Join("/volume", dir))
+	if err != nil {
+		return nil, false, err
+	}
+	var entries []Entry
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		if e, ok := parseStat(sc.Text()); ok {
+			entries = append(entries, e)
+		}
+	}
+	if entries == nil {
+		entries = []Entry{}
+	}
+	entries, truncated := limitEntries(entries, max)
+	return entries, truncated, nil
+}
+
+func (b helperBrowser) Stat(ctx context.Context, name string) (Entry, error) {
+	out, err := b.run(ctx, "stat", "-c", statFormat, "--", path.Join("/volume", name))
+	if err != nil {
+		return Entry{}, err
+	}
+	e, ok := parseStat(strings.TrimRight(string(out), "\n"))
+	if !ok {
+		return Entry{}, fmt.Errorf("unexpected stat output %q", out)
+	}
+	return e, nil
+}
+
+func (b helperBrowser) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	e, err := b.Stat(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if e.Type != TypeFile {
+		return nil, ErrNotFile
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	cmd := b.command(ctx, "cat", "--", path.Join("/volume", name))
+	f := &helperFile{cmd: cmd, cancel: cancel}
+	cmd.Stderr = &f.stderr
+	if f.out, err = cmd.StdoutPipe(); err != nil {
+		cancel()
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, err
+	}
+	return f, nil
+}
+
+// helperFile is the output of cat in a helper container. An error of the
+// command is returned at the end of the output.
+type helperFile struct {
+	cmd    *exec.Cmd
+	cancel context.CancelFunc
+	out    io.ReadCloser
+	stderr bytes.Buffer
+
+	once    sync.Once
+	waitErr error
+}
+
+func (f *helperFile) Read(p []byte) (int, error) {
+	n, err := f.out.Read(p)
+	if errors.Is(err, io.EOF) {
+		if werr := f.wait(); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+func (f *helperFile) Close() error {
+	f.cancel()
+	f.wait()
+	return nil
+}
+
+func (f *helperFile) wait() error {
+	f.once.Do(func() {
+		if err := f.cmd.Wait(); err != nil {
+			if text := strings.TrimSpace(f.stderr.String()); text != "" {
+				err = errors.New(text)
+			}
+			f.waitErr = err
+		}
+	})
+	return f.waitErr
+}
+
+// parseStat parses a line of stat output in statFormat.
+func parseStat(line string) (Entry, bool) {
+	fields := strings.SplitN(line, " ", 4)
+	if len(fields) != 4 {
+		return Entry{}, false
+	}
+	raw, err1 := strconv.ParseUint(fields[0], 16, 32)
+	size, err2 := strconv.ParseInt(fields[1], 10, 64)
+	mtime, err3 := strconv.ParseInt(fields[2], 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return Entry{}, false
+	}
+	mode := fs.FileMode(raw & 0o777)
+	switch raw & 0o170000 {
+	case 0o100000:
+	case 0o040000:
+		mode |= fs.ModeDir
+	case 0o120000:
+		mode |= fs.ModeSymlink
+	default:
+		mode |= fs.ModeIrregular
+	}
+	return Entry{
+		Name:    path.Base(fields[3]),
+		Type:    entryType(mode),
+		Size:    size,
+		Mode:    mode.Perm().String()[1:],
+		ModTime: time.Unix(mtime, 0).UTC(),
+	}, true
+}
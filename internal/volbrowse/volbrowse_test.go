@@ -0,0 +1,115 @@
+package volbrowse
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCleanPath(t *testing.T) {
+	t.Parallel()
+	cases := map[string]string{
+		"":                ".",
+		"/":               ".",
+		"config":          "config",
+		"/config/app.yml": "config/app.yml",
+		"../../etc":       "etc",
+		"a/../../b/./c/":  "b/c",
+	}
+	for in, want := range cases {
+		if got, err := CleanPath(in); err != nil || got != want {
+			t.Errorf("CleanPath(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := CleanPath("a\x00b"); err == nil {
+		t.Error("expected a NUL byte to be rejected")
+	}
+}
+
+func TestMountBrowser(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "config"), 0o755)
+	os.WriteFile(filepath.Join(dir, "config", "app.yml"), []byte("port: 80\n"), 0o640)
+	os.WriteFile(filepath.Join(dir, "backup.tar"), []byte("data"), 0o600)
+	os.Symlink("/etc/passwd", filepath.Join(dir, "escape"))
+
+	b := New("data", dir, "busybox")
+	if !b.Direct() {
+		t.Fatal("expected a readable mountpoint to be read directly")
+	}
+	ctx := context.Background()
+
+	entries, truncated, err := b.List(ctx, ".", 10)
+	if err != nil || truncated {
+		t.Fatalf("List: %v, truncated %v", err, truncated)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name+":"+e.Type)
+	}
+	if got := len(names); got != 3 || names[0] != "config:dir" || names[1] != "backup.tar:file" || names[2] != "escape:symlink" {
+		t.Errorf("entries = %v", names)
+	}
+	if entries, truncated, _ := b.List(ctx, ".", 1); len(entries) != 1 || !truncated {
+		t.Errorf("expected a truncated listing, got %v, %v", entries, truncated)
+	}
+
+	e, err := b.Stat(ctx, "config/app.yml")
+	if err != nil || e.Name != "app.yml" || e.Size != 9 || e.Mode != "rw-r-----" {
+		t.Errorf("Stat = %+v, %v", e, err)
+	}
+
+	f, err := b.Open(ctx, "config/app.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(f)
+	f.Close()
+	if string(data) != "port: 80\n" {
+		t.Errorf("read %q", data)
+	}
+
+	if _, err := b.Open(ctx, "config"); !errors.Is(err, ErrNotFile) {
+		t.Errorf("Open of a directory: %v", err)
+	}
+	if _, err := b.Open(ctx, "escape"); !errors.Is(err, ErrNotFile) {
+		t.Errorf("Open of a symlink: %v", err)
+	}
+	if _, _, err := b.List(ctx, "escape", 10); err == nil {
+		t.Error("expected listing through a symlink out of the volume to fail")
+	}
+}
+
+func TestNewFallsBackToHelper(t *testing.T) {
+	t.Parallel()
+	if b := New("data", filepath.Join(t.TempDir(), "missing"), "busybox"); b.Direct() {
+		t.Error("expected a helper browser for an unreadable mountpoint")
+	}
+}
+
+func TestParseStat(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		line string
+		want Entry
+	}{
+		{"81a4 12 1700000000 ./my file.txt", Entry{Name: "my file.txt", Type: TypeFile, Size: 12, Mode: "rw-r--r--"}},
+		{"41ed 4096 1700000000 ./config", Entry{Name: "config", Type: TypeDir, Size: 4096, Mode: "rwxr-xr-x"}},
+		{"a1ff 11 1700000000 ./link", Entry{Name: "link", Type: TypeSymlink, Size: 11, Mode: "rwxrwxrwx"}},
+		{"c1ed 0 1700000000 ./sock", Entry{Name: "sock", Type: TypeOther, Size: 0, Mode: "rwxr-xr-x"}},
+	}
+	for _, c := range cases {
+		got, ok := parseStat(c.line)
+		c.want.ModTime = got.ModTime
+		if !ok || got != c.want || got.ModTime.Unix() != 1700000000 {
+			t.Errorf("parseStat(%q) = %+v, %v; want %+v", c.line, got, ok, c.want)
+		}
+	}
+	if _, ok := parseStat("garbage"); ok {
+		t.Error("expected garbage to be rejected")
+	}
+}
@@ -0,0 +1,140 @@
+// Package webhook delivers the outbound webhooks of stacks: a JSON payload
+// per lifecycle event, rendered from a template if the webhook has one and
+// signed with HMAC-SHA256 so receivers can check where it came from.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/safehttp"
+)
+
+// Events a webhook can subscribe to.
+const (
+	EventDeployed = "deployed"
+	EventUpdated  = "updated"
+	EventStopped  = "stopped"
+	EventFailed   = "failed"
+	EventTest     = "test" // sent on demand, to every webhook
+)
+
+// Events lists the events a webhook can subscribe to.
+var Events = []string{EventDeployed, EventUpdated, EventStopped, EventFailed}
+
+// ValidEvent reports whether e can be subscribed to.
+func ValidEvent(e string) bool {
+	return slices.Contains(Events, e)
+}
+
+// Headers of a delivery.
+const (
+	// SignatureHeader is "sha256=" and the hex HMAC-SHA256 of the body,
+	// keyed with the webhook's secret. Unsigned webhooks don't send it.
+	SignatureHeader = "X-Dockge-Signature-256"
+	EventHeader     = "X-Dockge-Event"
+)
+
+// Payload is what a delivery is about. Without a template it's sent as
+// is; templates render it.
+type Payload struct {
+	Event   string    `json:"event"`
+	Stack   string    `json:"stack"`
+	Action  string    `json:"action"` // what ran: deploy, update, stop, down, ...
+	Success bool      `json:"success"`
+	Error   string    `json:"error,omitempty"`
+	Host    string    `json:"host,omitempty"` // the Dockge server
+	Time    time.Time `json:"time"`
+}
+
+// funcs are the functions of templates. json quotes a value, so strings
+// from the payload can't break out of the JSON around them.
+var funcs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// Render returns the body of a delivery: the payload as JSON without a
+// template, or the template executed on it. The result must be JSON.
+func Render(tmpl string, p Payload) ([]byte, error) {
+	if strings.TrimSpace(tmpl) == "" {
+		return json.Marshal(p)
+	}
+	t, err := template.New("payload").Funcs(funcs).Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, p); err != nil {
+		return nil, err
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, errors.New("template does not render valid JSON")
+	}
+	return buf.Bytes(), nil
+}
+
+// CheckTemplate renders a template on a sample payload, to catch mistakes
+// before any event is sent.
+func CheckTemplate(tmpl string) error {
+	_, err := Render(tmpl, Payload{
+		Event:  EventFailed,
+		Stack:  "sample",
+		Action: "deploy",
+		Error:  `a "quoted" error`,
+		Host:   "dockge",
+		Time:   time.Unix(0, 0).UTC(),
+	})
+	return err
+}
+
+// Sign returns the signature header value of body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// httpClient sends the deliveries; requests are bounded by their context.
+// It only connects to public addresses, so a webhook URL can't reach the
+// host or its network.
+var httpClient = safehttp.NewClient(0, safehttp.Public)
+
+// Send posts body to rawURL, signed with secret unless it's empty, and
+// fails on a non-2xx status.
+func Send(ctx context.Context, rawURL, secret, event string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Dockge-Webhook")
+	req.Header.Set(EventHeader, event)
+	if secret != "" {
+		req.Header.Set(SignatureHeader, Sign(secret, body))
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cfilipov/dockge/internal/safehttp"
+)
+
+func TestRender(t *testing.T) {
+	t.Parallel()
+	p := Payload{Event: EventFailed, Stack: "web", Action: "deploy", Error: `port "80" taken`, Time: time.Unix(0, 0).UTC()}
+
+	body, err := Render("", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Payload
+	if err := json.Unmarshal(body, &got); err != nil || got != p {
+		t.Fatalf("default payload = %s, %v", body, err)
+	}
+
+	body, err = Render(`{"text": {{json (printf "%s: %s" .Stack .Error)}}}`, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != `{"text": "web: port \"80\" taken"}` {
+		t.Errorf("templated payload = %s", body)
+	}
+
+	for _, tmpl := range []string{`{"text": "{{.Error}}"}`, `{{.Nope}}`, `{{`} {
+		if _, err := Render(tmpl, p); err == nil {
+			t.Errorf("expected %q to fail", tmpl)
+		}
+	}
+	if err := CheckTemplate(`{"stack": {{json .Stack}}, "ok": {{.Success}}}`); err != nil {
+		t.Errorf("CheckTemplate: %v", err)
+	}
+}
+
+func TestSend(t *testing.T) {
+	t.Parallel()
+	// The test server is on loopback, which the client rejects
+	defer safehttp.AllowLoopback()()
+	var gotSig, gotEvent, gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSig, gotEvent = r.Header.Get(SignatureHeader), r.Header.Get(EventHeader)
+		data, _ := io.ReadAll(r.Body)
+		gotBody = string(data)
+		if strings.Contains(gotBody, "reject") {
+			http.Error(w, "nope", http.StatusForbidden)
+		}
+	}))
+	defer srv.Close()
+
+	body := []byte(`{"stack":"web"}`)
+	if err := Send(context.Background(), srv.URL, "s3cret", EventDeployed, body); err != nil {
+		t.Fatal(err)
+	}
+	if gotBody != string(body) || gotEvent != EventDeployed || gotSig != Sign("s3cret", body) {
+		t.Errorf("got body %q, event %q, signature %q", gotBody, gotEvent, gotSig)
+	}
+	// HMAC-SHA256 of the body keyed with the secret, as receivers compute it
+	if want := "sha256=24f1bb28f20a7da1b6339c13485a751dee731948a6b101c093463ad494542f41"; gotSig != want {
+		t.Errorf("signature = %q, want %q", gotSig, want)
+	}
+
+	if err := Send(context.Background(), srv.URL, "", EventDeployed, body); err != nil || gotSig != "" {
+		t.Errorf("expected no signature without a secret: %q, %v", gotSig, err)
+	}
+	err := Send(context.Background(), srv.URL, "", EventFailed, []byte(`{"reject":true}`))
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("expected the status in the error: %v", err)
+	}
+}
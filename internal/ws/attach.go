@@ -0,0 +1,49 @@
+package ws
+
+import (
+    "context"
+
+    "github.com/coder/websocket"
+)
+
+// SendFunc writes one frame of an attached connection.
+type SendFunc func(ctx context.Context, typ websocket.MessageType, data []byte) error
+
+// funcTransport adapts a SendFunc to a transport. closed runs once when the
+// connection is closed.
+type funcTransport struct {
+    send   SendFunc
+    closed func()
+}
+
+func (t *funcTransport) Write(ctx context.Context, typ websocket.MessageType, p []byte) error {
+    return t.send(ctx, typ, p)
+}
+
+func (t *funcTransport) Close(websocket.StatusCode, string) error {
+    t.closed()
+    return nil
+}
+
+// Attach registers a connection that has no WebSocket of its own, such as a
+// controller session tunnelled through an agent link. Frames sent to the
+// connection go to send; frames from the client are passed to Receive.
+// Closing the connection removes it from the server like a disconnect, then
+// calls onClose (which may be nil).
+func (s *Server) Attach(send SendFunc, onClose func()) *Conn {
+    t := &funcTransport{send: send}
+    c := newConn(t, s)
+    c.remoteAddr = "agent"
+    t.closed = func() {
+        // Close runs with the connection locked; disconnect callbacks may
+        // need the lock.
+        go func() {
+            s.remove(c)
+            if onClose != nil {
+                onClose()
+            }
+        }()
+    }
+    s.add(c)
+    return c
+}
@@ -5,6 +5,7 @@ import (
     "encoding/binary"
     "encoding/json"
     "log/slog"
+    "math"
     "strconv"
     "sync"
     "sync/atomic"
@@ -27,18 +28,52 @@ type TermSession struct {
     TermName    string
     WriterKey   string // connID + ":s" + sessionID
     Interactive bool
+
+    // Forward, if set, receives the session's binary frames (opcode and
+    // payload) instead of the binary handler. Used for terminals that live
+    // on an agent.
+    Forward func(data []byte)
+
+    // FlowControl makes WriteSession wait for the client's acks when it
+    // falls behind on the session's output.
+    FlowControl bool
+    flow        flowState
+}
+
+// transport carries a Conn's outgoing frames: the connection's own
+// WebSocket, or a channel tunnelled through an agent link (see Attach).
+type transport interface {
+    Write(ctx context.Context, typ websocket.MessageType, p []byte) error
+    Close(code websocket.StatusCode, reason string) error
 }
 
 // Conn wraps a single WebSocket connection.
 type Conn struct {
-    ws      *websocket.Conn
+    ws      transport
     server  *Server
     closeCh chan struct{}
 
-    mu     sync.Mutex
-    id     string
-    userID int // 0 = unauthenticated
-    closed bool
+    mu        sync.Mutex
+    id        string
+    userID    int // 0 = unauthenticated
+    closed    bool
+    sudoUntil time.Time // re-authenticated for sensitive actions until this time
+
+    // lastActive is the UnixNano time of the last frame the user caused
+    // (requests and terminal input, not polls or acks), used for idle
+    // session timeouts.
+    lastActive atomic.Int64
+
+    // Introspection (see Info)
+    remoteAddr  string
+    connectedAt time.Time
+    messagesIn  atomic.Int64
+    framesOut   atomic.Int64
+    queued      atomic.Int64
+
+    // pendingAcks are the observed messages still waiting for their ack
+    // (see Server.ObserveAcks): request ID → message
+    pendingAcks map[int64]*ClientMessage
 
     // Terminal session multiplexing
     termMu        sync.RWMutex
@@ -46,15 +81,18 @@ type Conn struct {
     nextSessionID uint16
 }
 
-func newConn(ws *websocket.Conn, server *Server) *Conn {
+func newConn(ws transport, server *Server) *Conn {
     id := atomic.AddUint64(&connIDCounter, 1)
-    return &Conn{
+    c := &Conn{
         id:           "c" + strconv.FormatUint(id, 10),
         ws:           ws,
         server:       server,
         closeCh:      make(chan struct{}),
         termSessions: make(map[uint16]*TermSession),
+        connectedAt:  time.Now(),
     }
+    c.lastActive.Store(time.Now().UnixNano())
+    return c
 }
 
 // ID returns a unique identifier for this connection.
@@ -62,11 +100,39 @@ func (c *Conn) ID() string {
     return c.id
 }
 
-// SetUser marks this connection as authenticated.
+// SetUser marks this connection as authenticated. Any sudo elevation from a
+// previous user is dropped.
 func (c *Conn) SetUser(userID int) {
     c.mu.Lock()
     c.userID = userID
+    c.sudoUntil = time.Time{}
     c.mu.Unlock()
+    c.lastActive.Store(time.Now().UnixNano())
+}
+
+// Elevate grants sudo mode (recent password re-entry) until the given time.
+func (c *Conn) Elevate(until time.Time) {
+    c.mu.Lock()
+    c.sudoUntil = until
+    c.mu.Unlock()
+}
+
+// SudoUntil returns when the current sudo elevation expires (zero if never
+// elevated).
+func (c *Conn) SudoUntil() time.Time {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return c.sudoUntil
+}
+
+// Elevated reports whether the connection is in sudo mode.
+func (c *Conn) Elevated() bool {
+    return time.Now().Before(c.SudoUntil())
+}
+
+// LastActive returns when the user last did something on the connection.
+func (c *Conn) LastActive() time.Time {
+    return time.Unix(0, c.lastActive.Load())
 }
 
 // UserID returns the authenticated user ID (0 if not authenticated).
@@ -79,7 +145,40 @@ func (c *Conn) UserID() int {
 // SendAck sends an ack response for a client request.
 // Generic to avoid interface boxing — json.Marshal sees the concrete type directly.
 func SendAck[T any](c *Conn, id int64, data T) {
-    writeJSON(c, AckMessage[T]{ID: id, Data: data})
+    payload, err := json.Marshal(AckMessage[T]{ID: id, Data: data})
+    if err != nil {
+        slog.Error("ws marshal", "err", err)
+        return
+    }
+    c.writeRaw(payload)
+    c.ackSent(id, payload)
+}
+
+// maxPendingAcks bounds the observed messages tracked per connection, in
+// case handlers never ack some of them.
+const maxPendingAcks = 256
+
+// awaitAck remembers an observed message until its ack is sent.
+func (c *Conn) awaitAck(id int64, msg *ClientMessage) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if c.pendingAcks == nil {
+        c.pendingAcks = make(map[int64]*ClientMessage)
+    }
+    if len(c.pendingAcks) < maxPendingAcks {
+        c.pendingAcks[id] = msg
+    }
+}
+
+// ackSent passes the ack of an observed message to the server's observers.
+func (c *Conn) ackSent(id int64, payload []byte) {
+    c.mu.Lock()
+    msg, ok := c.pendingAcks[id]
+    delete(c.pendingAcks, id)
+    c.mu.Unlock()
+    if ok && c.server != nil {
+        c.server.notifyAck(c, msg, payload)
+    }
 }
 
 // SendEvent sends a server push event with a single data payload.
@@ -112,6 +211,7 @@ func (c *Conn) writeRaw(data []byte) {
     ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
     defer cancel()
 
+    c.framesOut.Add(1)
     if err := c.ws.Write(ctx, websocket.MessageText, data); err != nil {
         slog.Debug("ws write raw", "err", err)
         c.closeLocked()
@@ -130,6 +230,7 @@ func (c *Conn) WriteBinary(data []byte) {
     ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
     defer cancel()
 
+    c.framesOut.Add(1)
     if err := c.ws.Write(ctx, websocket.MessageBinary, data); err != nil {
         slog.Debug("ws write binary", "err", err)
         c.closeLocked()
@@ -155,6 +256,9 @@ func (c *Conn) RemoveSession(id uint16) *TermSession {
 
     s := c.termSessions[id]
     delete(c.termSessions, id)
+    if s != nil {
+        s.flow.ack(math.MaxInt64) // release a write waiting for acks
+    }
     return s
 }
 
@@ -172,6 +276,7 @@ func (c *Conn) DrainSessions() []*TermSession {
 
     sessions := make([]*TermSession, 0, len(c.termSessions))
     for _, s := range c.termSessions {
+        s.flow.ack(math.MaxInt64)
         sessions = append(sessions, s)
     }
     c.termSessions = make(map[uint16]*TermSession)
@@ -179,50 +284,74 @@ func (c *Conn) DrainSessions() []*TermSession {
 }
 
 // readPump reads messages from the WebSocket and dispatches them.
-func (c *Conn) readPump(ctx context.Context) {
+func (c *Conn) readPump(ctx context.Context, wsc *websocket.Conn) {
     defer func() {
         c.server.remove(c)
         c.Close()
     }()
 
-    c.ws.SetReadLimit(maxMessageSize)
+    wsc.SetReadLimit(maxMessageSize)
 
     for {
-        msgType, data, err := c.ws.Read(ctx)
+        msgType, data, err := wsc.Read(ctx)
         if err != nil {
             slog.Debug("ws read", "err", err)
             return
         }
+        c.Receive(msgType, data)
+    }
+}
 
-        if msgType == websocket.MessageBinary {
-            // Binary frame: [2 bytes sessionID BE] [1 byte opcode] [N bytes payload]
-            if len(data) < 3 {
-                continue
-            }
-            sessionID := binary.BigEndian.Uint16(data[:2])
-            c.termMu.RLock()
-            session := c.termSessions[sessionID]
-            c.termMu.RUnlock()
-            if session == nil {
-                continue
-            }
-            // Binary handlers (terminal input/resize) are fast PTY fd
-            // writes — run inline to avoid unbounded goroutine spawning.
-            if h := c.server.binaryHandler; h != nil {
-                h(c, session, data[2:])
+// Receive handles a frame from the client. readPump calls it for frames read
+// from the WebSocket; attached connections are fed by their owner.
+func (c *Conn) Receive(msgType websocket.MessageType, data []byte) {
+    c.messagesIn.Add(1)
+
+    if msgType == websocket.MessageBinary {
+        // Binary frame: [2 bytes sessionID BE] [1 byte opcode] [N bytes payload]
+        if len(data) < 3 {
+            return
+        }
+        // Resizes and acks are sent by the terminal itself
+        if data[2] == OpInput {
+            c.lastActive.Store(time.Now().UnixNano())
+        }
+        sessionID := binary.BigEndian.Uint16(data[:2])
+        c.termMu.RLock()
+        session := c.termSessions[sessionID]
+        c.termMu.RUnlock()
+        if session == nil {
+            return
+        }
+        if session.Forward != nil {
+            session.Forward(data[2:])
+            return
+        }
+        if data[2] == OpAck {
+            if len(data) >= 7 {
+                session.flow.ack(int64(binary.BigEndian.Uint32(data[3:7])))
             }
-            continue
+            return
         }
-
-        // Text frame: JSON message
-        var msg ClientMessage
-        if err := json.Unmarshal(data, &msg); err != nil {
-            slog.Warn("ws unmarshal", "err", err)
-            continue
+        // Binary handlers (terminal input/resize) are fast PTY fd
+        // writes — run inline to avoid unbounded goroutine spawning.
+        if h := c.server.binaryHandler; h != nil {
+            h(c, session, data[2:])
         }
+        return
+    }
 
-        c.server.dispatch(c, &msg)
+    // Text frame: JSON message
+    var msg ClientMessage
+    if err := json.Unmarshal(data, &msg); err != nil {
+        slog.Warn("ws unmarshal", "err", err)
+        return
+    }
+    if !msg.Background {
+        c.lastActive.Store(time.Now().UnixNano())
     }
+
+    c.server.dispatch(c, &msg)
 }
 
 // Close shuts down the connection.
@@ -0,0 +1,95 @@
+package ws
+
+import (
+    "encoding/binary"
+    "sync"
+    "time"
+)
+
+// Opcodes of the binary terminal frames the client sends:
+// [2 bytes sessionID BE] [1 byte opcode] [payload].
+const (
+    OpInput  byte = 0x00 // payload: UTF-8 input bytes
+    OpResize byte = 0x01 // payload: rows, cols (uint16 BE each)
+    OpAck    byte = 0x02 // payload: bytes of output processed (uint32 BE)
+)
+
+// Flow control of terminal output. A session that asked for it stops
+// taking output once flowHighWater bytes are unacked, until the client
+// has caught up to flowLowWater. The terminal's writes block meanwhile,
+// which backs up into the process's output instead of dropping it or
+// timing out the connection. A client that stops acking altogether is
+// only waited for flowStallTimeout, then written to freely until its
+// next ack.
+const (
+    flowHighWater    = 256 << 10
+    flowLowWater     = 64 << 10
+    flowStallTimeout = 5 * time.Second
+)
+
+// flowState is the output flow control of a session.
+type flowState struct {
+    mu      sync.Mutex
+    unacked int64
+    stalled bool
+    acked   chan struct{} // closed and replaced on every ack
+}
+
+// ack records n bytes of output processed by the client.
+func (f *flowState) ack(n int64) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    f.unacked = max(f.unacked-n, 0)
+    f.stalled = false
+    if f.acked != nil {
+        close(f.acked)
+        f.acked = nil
+    }
+}
+
+// sent records n bytes of output and, past the high water mark, waits
+// for acks to bring the unacked output down to the low water mark.
+func (f *flowState) sent(n int64, done <-chan struct{}) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    f.unacked += n
+    if f.unacked <= flowHighWater {
+        return
+    }
+    for f.unacked > flowLowWater && !f.stalled {
+        if f.acked == nil {
+            f.acked = make(chan struct{})
+        }
+        acked := f.acked
+        f.mu.Unlock()
+        timer := time.NewTimer(flowStallTimeout)
+        select {
+        case <-acked:
+        case <-done:
+        case <-timer.C:
+        }
+        timer.Stop()
+        f.mu.Lock()
+        select {
+        case <-done:
+            return
+        case <-acked:
+        default:
+            f.stalled = true
+        }
+    }
+}
+
+// WriteSession sends terminal output to a session as a binary frame:
+// [2 bytes sessionID BE] [data]. If the session uses flow control, it
+// blocks while the client is too far behind (see flowHighWater).
+func (c *Conn) WriteSession(sessionID uint16, data []byte) {
+    buf := make([]byte, 2+len(data))
+    binary.BigEndian.PutUint16(buf, sessionID)
+    copy(buf[2:], data)
+    c.WriteBinary(buf)
+
+    if s := c.GetSession(sessionID); s != nil && s.FlowControl {
+        s.flow.sent(int64(len(data)), c.closeCh)
+    }
+}
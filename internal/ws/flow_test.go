@@ -0,0 +1,57 @@
+package ws
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFlowStateWaitsForAcks(t *testing.T) {
+	t.Parallel()
+
+	var f flowState
+	f.sent(flowHighWater, nil) // at the mark: doesn't wait
+
+	returned := make(chan struct{})
+	go func() {
+		f.sent(1, nil)
+		close(returned)
+	}()
+	select {
+	case <-returned:
+		t.Fatal("write past the high water mark didn't wait")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Not yet down to the low water mark
+	f.ack(flowHighWater - flowLowWater - 1)
+	select {
+	case <-returned:
+		t.Fatal("write resumed above the low water mark")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	f.ack(2)
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Fatal("write didn't resume after the client caught up")
+	}
+}
+
+func TestFlowStateReleasedOnClose(t *testing.T) {
+	t.Parallel()
+
+	var f flowState
+	done := make(chan struct{})
+	returned := make(chan struct{})
+	go func() {
+		f.sent(flowHighWater+1, done)
+		close(returned)
+	}()
+	close(done)
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Fatal("write kept waiting on a closed connection")
+	}
+}
@@ -0,0 +1,169 @@
+package ws
+
+import (
+    "sort"
+    "sync"
+    "time"
+)
+
+// Timing summarises how long an operation took over its runs.
+type Timing struct {
+    Count  int64   `json:"count"`
+    AvgMs  float64 `json:"avgMs"`
+    MaxMs  float64 `json:"maxMs"`
+    LastMs float64 `json:"lastMs"`
+}
+
+// timing accumulates durations for a Timing.
+type timing struct {
+    count     int64
+    total     time.Duration
+    max, last time.Duration
+}
+
+func (t *timing) add(d time.Duration) {
+    t.count++
+    t.total += d
+    t.max = max(t.max, d)
+    t.last = d
+}
+
+func (t *timing) snapshot() Timing {
+    ms := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+    s := Timing{Count: t.count, MaxMs: ms(t.max), LastMs: ms(t.last)}
+    if t.count > 0 {
+        s.AvgMs = ms(t.total / time.Duration(t.count))
+    }
+    return s
+}
+
+// FanoutTiming is a Timing of a broadcast event, with how many
+// connections its last broadcast went to.
+type FanoutTiming struct {
+    Timing
+    LastConns int `json:"lastConns"`
+}
+
+// serverMetrics counts handler invocations and broadcast fan-outs.
+type serverMetrics struct {
+    mu        sync.Mutex
+    handlers  map[string]*timing
+    fanouts   map[string]*timing
+    lastConns map[string]int
+}
+
+func newServerMetrics() *serverMetrics {
+    return &serverMetrics{
+        handlers:  make(map[string]*timing),
+        fanouts:   make(map[string]*timing),
+        lastConns: make(map[string]int),
+    }
+}
+
+// recordHandler records one run of an event's handler.
+func (m *serverMetrics) recordHandler(event string, d time.Duration) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    t := m.handlers[event]
+    if t == nil {
+        t = &timing{}
+        m.handlers[event] = t
+    }
+    t.add(d)
+}
+
+// recordFanout records one broadcast of event to conns connections.
+func (m *serverMetrics) recordFanout(event string, conns int, d time.Duration) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    t := m.fanouts[event]
+    if t == nil {
+        t = &timing{}
+        m.fanouts[event] = t
+    }
+    t.add(d)
+    m.lastConns[event] = conns
+}
+
+// ServerStats is a snapshot of the server's dispatch and broadcast
+// metrics. Handler timings cover the handler call only: work a handler
+// hands off to a goroutine isn't included.
+type ServerStats struct {
+    Connections      int                     `json:"connections"`
+    DispatchInFlight int                     `json:"dispatchInFlight"`
+    DispatchLimit    int                     `json:"dispatchLimit"`
+    Handlers         map[string]Timing       `json:"handlers"`
+    Broadcasts       map[string]FanoutTiming `json:"broadcasts"`
+}
+
+// Stats returns a snapshot of the server's metrics.
+func (s *Server) Stats() ServerStats {
+    stats := ServerStats{
+        Connections:      s.ConnectionCount(),
+        DispatchInFlight: len(s.dispatchSem),
+        DispatchLimit:    cap(s.dispatchSem),
+        Handlers:         make(map[string]Timing),
+        Broadcasts:       make(map[string]FanoutTiming),
+    }
+    m := s.metrics
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    for event, t := range m.handlers {
+        stats.Handlers[event] = t.snapshot()
+    }
+    for event, t := range m.fanouts {
+        stats.Broadcasts[event] = FanoutTiming{Timing: t.snapshot(), LastConns: m.lastConns[event]}
+    }
+    return stats
+}
+
+// ConnInfo describes a connection for introspection.
+type ConnInfo struct {
+    ID          string    `json:"id"`
+    UserID      int       `json:"userId"`
+    RemoteAddr  string    `json:"remoteAddr"`
+    ConnectedAt time.Time `json:"connectedAt"`
+    LastActive  time.Time `json:"lastActive"`
+    Elevated    bool      `json:"elevated"`
+    MessagesIn  int64     `json:"messagesIn"`
+    FramesOut   int64     `json:"framesOut"`
+    // Queued counts messages received but not yet handled: waiting for a
+    // dispatch slot or running.
+    Queued    int64    `json:"queued"`
+    Terminals []string `json:"terminals"`
+}
+
+// Info describes the connection.
+func (c *Conn) Info() ConnInfo {
+    info := ConnInfo{
+        ID:          c.id,
+        UserID:      c.UserID(),
+        RemoteAddr:  c.remoteAddr,
+        ConnectedAt: c.connectedAt,
+        LastActive:  c.LastActive(),
+        Elevated:    c.Elevated(),
+        MessagesIn:  c.messagesIn.Load(),
+        FramesOut:   c.framesOut.Load(),
+        Queued:      c.queued.Load(),
+        Terminals:   []string{},
+    }
+    c.termMu.RLock()
+    for _, s := range c.termSessions {
+        info.Terminals = append(info.Terminals, s.TermName)
+    }
+    c.termMu.RUnlock()
+    sort.Strings(info.Terminals)
+    return info
+}
+
+// ConnInfos describes every connection, oldest first.
+func (s *Server) ConnInfos() []ConnInfo {
+    var conns []*Conn
+    s.ForEachConn(func(c *Conn) { conns = append(conns, c) })
+    infos := make([]ConnInfo, 0, len(conns))
+    for _, c := range conns {
+        infos = append(infos, c.Info())
+    }
+    sort.Slice(infos, func(i, j int) bool { return infos[i].ConnectedAt.Before(infos[j].ConnectedAt) })
+    return infos
+}
@@ -0,0 +1,58 @@
+package ws
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+func TestServerStats(t *testing.T) {
+	srv := NewServer(true)
+	release := make(chan struct{})
+	handled := make(chan struct{})
+	srv.Handle("slow", func(c *Conn, msg *ClientMessage) {
+		<-release
+		close(handled)
+	})
+
+	send := func(ctx context.Context, typ websocket.MessageType, data []byte) error { return nil }
+	c := srv.Attach(send, nil)
+	c.SetUser(1)
+	srv.Attach(send, nil) // unauthenticated: left out of authenticated broadcasts
+
+	c.Receive(websocket.MessageText, []byte(`{"event":"slow","id":1}`))
+	if info := c.Info(); info.Queued != 1 || info.MessagesIn != 1 || info.RemoteAddr != "agent" {
+		t.Errorf("while handling: %+v", info)
+	}
+	close(release)
+	<-handled
+	deadline := time.Now().Add(5 * time.Second)
+	for c.Info().Queued != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if q := c.Info().Queued; q != 0 {
+		t.Errorf("queued = %d after the handler returned", q)
+	}
+
+	BroadcastAuthenticated(srv, "news", 1)
+	BroadcastAuthenticated(srv, "news", 2)
+
+	stats := srv.Stats()
+	if stats.Connections != 2 || stats.DispatchLimit != maxConcurrentDispatch {
+		t.Errorf("stats = %+v", stats)
+	}
+	if h := stats.Handlers["slow"]; h.Count != 1 {
+		t.Errorf("slow handler timing = %+v", h)
+	}
+	if b := stats.Broadcasts["news"]; b.Count != 2 || b.LastConns != 1 {
+		t.Errorf("news broadcast timing = %+v", b)
+	}
+	if out := c.Info().FramesOut; out != 2 {
+		t.Errorf("frames out = %d, want 2", out)
+	}
+	if infos := srv.ConnInfos(); len(infos) != 2 {
+		t.Errorf("conn infos = %+v", infos)
+	}
+}
@@ -8,6 +8,14 @@ type ClientMessage struct {
     ID    *int64          `json:"id,omitempty"`
     Event string          `json:"event"`
     Args  json.RawMessage `json:"args"`
+    // Key is an optional idempotency key of a mutating request: a retry
+    // with the same key gets the first request's ack instead of running
+    // again. Only some handlers honor it.
+    Key string `json:"key,omitempty"`
+    // Background marks a request the client sent on its own, such as a
+    // poll or a resync after reconnecting. It doesn't count as activity
+    // for idle session timeouts.
+    Background bool `json:"background,omitempty"`
 }
 
 // AckMessage is sent from the server to the client in response to a request with an ID.
@@ -44,6 +52,36 @@ type TerminalJoinArgs struct {
     Service   string `json:"service,omitempty"`
     Container string `json:"container,omitempty"`
     Shell     string `json:"shell,omitempty"`
+
+    // Stream is "stdout" or "stderr" to show only that stream of a
+    // container log terminal, "" for both.
+    Stream string `json:"stream,omitempty"`
+
+    // Exec sets the user, workdir and extra env of exec terminals. Nil
+    // uses the user's remembered defaults; anything else (even empty)
+    // replaces and remembers them.
+    Exec *ExecOptions `json:"exec,omitempty"`
+
+    // StreamID and Offset resume a stream after a reconnect: the server
+    // replays only output past Offset if the stream is still buffered.
+    StreamID string `json:"streamId,omitempty"`
+    Offset   int64  `json:"offset,omitempty"`
+
+    // FlowControl says the client acks the output it has processed
+    // (OpAck frames), so the server can hold output back while it's
+    // behind instead of flooding it.
+    FlowControl bool `json:"flowControl,omitempty"`
+}
+
+// ExecOptions are the docker exec options of an exec terminal.
+type ExecOptions struct {
+    User    string   `json:"user,omitempty"`    // name or uid, optionally :group
+    Workdir string   `json:"workdir,omitempty"` // absolute path in the container
+    Env     []string `json:"env,omitempty"`     // KEY=VALUE
+
+    // Cmd runs a command instead of a shell. It isn't remembered with the
+    // other options.
+    Cmd []string `json:"cmd,omitempty"`
 }
 
 // TerminalJoinResponse is the ack payload for "terminalJoin".
@@ -51,6 +89,13 @@ type TerminalJoinResponse struct {
     OK        bool   `json:"ok"`
     SessionID uint16 `json:"sessionId"`
     Msg       string `json:"msg,omitempty"`
+
+    // StreamID identifies the terminal instance and Offset is the stream
+    // offset of the first replayed byte. Resumed is false when the client
+    // must discard what it has and render the replay from scratch.
+    StreamID string `json:"streamId,omitempty"`
+    Offset   int64  `json:"offset"`
+    Resumed  bool   `json:"resumed"`
 }
 
 // TerminalLeaveArgs is the payload for "terminalLeave" events.
@@ -61,4 +106,5 @@ type TerminalLeaveArgs struct {
 // TerminalExitedData is the payload for "terminalExited" server push events.
 type TerminalExitedData struct {
     SessionID uint16 `json:"sessionId"`
+    ExitCode  *int   `json:"exitCode,omitempty"` // nil when unknown
 }
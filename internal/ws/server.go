@@ -4,8 +4,12 @@ import (
     "encoding/json"
     "log/slog"
     "net/http"
+    "net/url"
+    "strings"
     "sync"
+    "time"
 
+    "github.com/cfilipov/dockge/internal/middleware"
     "github.com/coder/websocket"
 )
 
@@ -34,9 +38,56 @@ type Server struct {
     dispatchSem chan struct{}
 
     // dev controls WebSocket origin checking. When true, all origins are
-    // accepted (InsecureSkipVerify). When false, the coder/websocket
-    // library enforces same-origin by checking Origin == Host.
+    // accepted. When false, same-origin is enforced by checking
+    // Origin == Host.
     dev bool
+
+    // originPatterns lists extra origins allowed to connect in production
+    // (see middleware.OriginAllowed).
+    originPatterns []string
+
+    // ackObservers are added by ObserveAcks.
+    ackObservers []ackObservation
+
+    // metrics times handler calls and broadcasts (see Stats).
+    metrics *serverMetrics
+}
+
+// ackObservation is an AckObserver and the events it observes.
+type ackObservation struct {
+    match func(event string) bool
+    fn    AckObserver
+}
+
+// AckObserver is told about an observed message once its handler acks it,
+// with the marshalled ack. Messages sent without an ID are reported with a
+// nil ack when their handler returns.
+type AckObserver func(c *Conn, msg *ClientMessage, ack []byte)
+
+// ObserveAcks calls fn for every dispatched message whose event match
+// accepts. Observers are called in the order they were added. Must be
+// called before the server starts accepting connections.
+func (s *Server) ObserveAcks(match func(event string) bool, fn AckObserver) {
+    s.ackObservers = append(s.ackObservers, ackObservation{match: match, fn: fn})
+}
+
+// observed reports whether any observer observes event.
+func (s *Server) observed(event string) bool {
+    for _, o := range s.ackObservers {
+        if o.match(event) {
+            return true
+        }
+    }
+    return false
+}
+
+// notifyAck passes a message and its ack to the observers of its event.
+func (s *Server) notifyAck(c *Conn, msg *ClientMessage, ack []byte) {
+    for _, o := range s.ackObservers {
+        if o.match(msg.Event) {
+            o.fn(c, msg, ack)
+        }
+    }
 }
 
 // NewServer creates a new WebSocket server. The dev parameter controls
@@ -48,9 +99,16 @@ func NewServer(dev bool) *Server {
         handlers:    make(map[string]HandlerFunc),
         dispatchSem: make(chan struct{}, maxConcurrentDispatch),
         dev:         dev,
+        metrics:     newServerMetrics(),
     }
 }
 
+// SetOriginPatterns allows cross-origin connections from origins matching
+// the given patterns. Same-origin connections are always accepted.
+func (s *Server) SetOriginPatterns(patterns []string) {
+    s.originPatterns = patterns
+}
+
 // Handle registers a handler for a named event.
 func (s *Server) Handle(event string, fn HandlerFunc) {
     s.handlers[event] = fn
@@ -63,13 +121,36 @@ func (s *Server) OnBinary(fn func(c *Conn, session *TermSession, data []byte)) {
     s.binaryHandler = fn
 }
 
+// originAllowed reports whether a browser on the request's Origin may open
+// a connection. Any page can point a WebSocket at us, and the browser sends
+// no CORS preflight, so this is the only thing that stops another site from
+// driving a logged-in user's session.
+func (s *Server) originAllowed(r *http.Request) bool {
+    origin := r.Header.Get("Origin")
+    // Browsers always send Origin on upgrades; its absence means a
+    // non-browser client, which can't be tricked into riding a session.
+    if s.dev || origin == "" {
+        return true
+    }
+    if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+        return true
+    }
+    return middleware.OriginAllowed(origin, s.originPatterns)
+}
+
 // ServeHTTP upgrades the HTTP request to a WebSocket connection.
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    // In dev mode, accept all origins (Vite runs on a different port). In
+    // production, the Origin must match the Host header or one of the
+    // configured --ws-origins patterns.
+    if !s.originAllowed(r) {
+        slog.Warn("ws origin rejected", "origin", r.Header.Get("Origin"), "host", r.Host, "remote", r.RemoteAddr)
+        http.Error(w, "origin not allowed", http.StatusForbidden)
+        return
+    }
     ws, err := websocket.Accept(w, r, &websocket.AcceptOptions{
-        // In dev mode, accept all origins (Vite runs on a different port).
-        // In production, enforce same-origin: the coder/websocket library
-        // checks that the Origin header matches the Host header.
-        InsecureSkipVerify: s.dev,
+        // Checked above, with the same pattern syntax as the CORS handling
+        InsecureSkipVerify: true,
     })
     if err != nil {
         slog.Error("ws accept", "err", err)
@@ -77,6 +158,7 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
     }
 
     c := newConn(ws, s)
+    c.remoteAddr = r.RemoteAddr
     s.add(c)
 
     slog.Debug("ws connected", "remote", r.RemoteAddr)
@@ -87,35 +169,42 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
     }
 
     // Block on the read pump — this goroutine is owned by net/http
-    c.readPump(r.Context())
+    c.readPump(r.Context(), ws)
 }
 
 // Broadcast sends a push event to all connected clients.
 func Broadcast[T any](s *Server, event string, data T) {
+    start := time.Now()
     s.mu.RLock()
     defer s.mu.RUnlock()
 
     for c := range s.conns {
         SendEvent(c, event, data)
     }
+    s.metrics.recordFanout(event, len(s.conns), time.Since(start))
 }
 
 // BroadcastAuthenticated sends a push event to all authenticated clients.
 func BroadcastAuthenticated[T any](s *Server, event string, data T) {
+    start := time.Now()
     s.mu.RLock()
     defer s.mu.RUnlock()
 
+    sent := 0
     for c := range s.conns {
         if c.UserID() != 0 {
             SendEvent(c, event, data)
+            sent++
         }
     }
+    s.metrics.recordFanout(event, sent, time.Since(start))
 }
 
 // BroadcastAuthenticatedRaw marshals the event payload once and sends the
 // pre-encoded bytes to all authenticated connections. For N connections this
 // saves (N-1) json.Marshal calls compared to BroadcastAuthenticated.
 func BroadcastAuthenticatedRaw[T any](s *Server, event string, data T) {
+    start := time.Now()
     payload, err := json.Marshal(ServerMessage[T]{Event: event, Data: data})
     if err != nil {
         slog.Error("ws marshal raw broadcast", "err", err)
@@ -125,11 +214,14 @@ func BroadcastAuthenticatedRaw[T any](s *Server, event string, data T) {
     s.mu.RLock()
     defer s.mu.RUnlock()
 
+    sent := 0
     for c := range s.conns {
         if c.UserID() != 0 {
             c.writeRaw(payload)
+            sent++
         }
     }
+    s.metrics.recordFanout(event, sent, time.Since(start))
 }
 
 // BroadcastAuthenticatedBytes sends pre-marshaled JSON bytes to all
@@ -207,12 +299,14 @@ func (s *Server) OnDisconnect(fn func(c *Conn)) {
 }
 
 func (s *Server) dispatch(c *Conn, msg *ClientMessage) {
+    c.queued.Add(1)
     // Acquire a slot from the bounded pool. This blocks the read pump if
     // all slots are in use, applying backpressure to the client rather
     // than spawning unbounded goroutines.
     s.dispatchSem <- struct{}{}
     go func() {
         defer func() { <-s.dispatchSem }()
+        defer c.queued.Add(-1)
         s.Dispatch(c, msg)
     }()
 }
@@ -228,7 +322,16 @@ func (s *Server) Dispatch(c *Conn, msg *ClientMessage) {
         }
         return
     }
+    if s.observed(msg.Event) {
+        if msg.ID == nil {
+            defer s.notifyAck(c, msg, nil)
+        } else {
+            c.awaitAck(*msg.ID, msg)
+        }
+    }
+    start := time.Now()
     h(c, msg)
+    s.metrics.recordHandler(msg.Event, time.Since(start))
 }
 
 // UpgradeHandler returns an http.Handler that upgrades to WebSocket.
@@ -74,3 +74,64 @@ func TestCORSAcceptsAnyOriginInDevMode(t *testing.T) {
 	}
 	conn.Close(websocket.StatusNormalClosure, "")
 }
+
+// TestCORSAcceptsConfiguredOriginPattern verifies that origins matching
+// SetOriginPatterns are accepted in production mode while others are not.
+func TestCORSAcceptsConfiguredOriginPattern(t *testing.T) {
+	t.Parallel()
+
+	srv := NewServer(false)
+	srv.SetOriginPatterns([]string{"*.example.com"})
+	srv.Handle("ping", func(c *Conn, msg *ClientMessage) {})
+
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, _, err := websocket.Dial(ctx, "ws"+ts.URL[4:], &websocket.DialOptions{
+		HTTPHeader: http.Header{
+			"Origin": {"https://dash.example.com"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("expected WebSocket dial from allowed origin to succeed: %v", err)
+	}
+	conn.Close(websocket.StatusNormalClosure, "")
+
+	_, _, err = websocket.Dial(ctx, "ws"+ts.URL[4:], &websocket.DialOptions{
+		HTTPHeader: http.Header{
+			"Origin": {"http://evil.com"},
+		},
+	})
+	if err == nil {
+		t.Fatal("expected WebSocket dial from unlisted origin to be rejected")
+	}
+}
+
+// TestRejectedOriginGets403 verifies that a cross-site upgrade is refused
+// before the handshake, while non-browser clients (no Origin) still connect.
+func TestRejectedOriginGets403(t *testing.T) {
+	t.Parallel()
+
+	srv := NewServer(false)
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, resp, err := websocket.Dial(ctx, "ws"+ts.URL[4:], &websocket.DialOptions{
+		HTTPHeader: http.Header{"Origin": {"null"}},
+	})
+	if err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for origin null, got resp=%v err=%v", resp, err)
+	}
+
+	conn, _, err := websocket.Dial(ctx, "ws"+ts.URL[4:], nil)
+	if err != nil {
+		t.Fatalf("expected dial without Origin to succeed: %v", err)
+	}
+	conn.Close(websocket.StatusNormalClosure, "")
+}
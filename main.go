@@ -21,14 +21,20 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/cfilipov/dockge/internal/agent"
+	"github.com/cfilipov/dockge/internal/backup"
 	"github.com/cfilipov/dockge/internal/compose"
 	"github.com/cfilipov/dockge/internal/config"
 	"github.com/cfilipov/dockge/internal/db"
 	dbgmem "github.com/cfilipov/dockge/internal/debug"
 	"github.com/cfilipov/dockge/internal/docker"
 	"github.com/cfilipov/dockge/internal/handlers"
+	"github.com/cfilipov/dockge/internal/middleware"
 	"github.com/cfilipov/dockge/internal/models"
+	"github.com/cfilipov/dockge/internal/registry"
+	"github.com/cfilipov/dockge/internal/secrets"
 	"github.com/cfilipov/dockge/internal/stack"
+	"github.com/cfilipov/dockge/internal/templates"
 	"github.com/cfilipov/dockge/internal/terminal"
 	"github.com/cfilipov/dockge/internal/ws"
 )
@@ -73,9 +79,28 @@ func main() {
 		"pprof", cfg.Dev || cfg.Pprof,
 		"logLevel", cfg.LogLevel,
 		"noAuth", cfg.NoAuth,
+		"corsOrigins", cfg.CORSOrigins,
+		"wsOrigins", cfg.WSOrigins,
+		"hostTerminal", cfg.HostTerminal,
+		"vault", cfg.VaultAddr != "",
 		"maxProcs", runtime.GOMAXPROCS(0),
 	)
 
+	// Restore a backup given with --restore, or uploaded before the last
+	// shutdown. It replaces the database, so it must happen before opening it.
+	if cfg.RestoreFile != "" {
+		if _, err := backup.StageFile(cfg.RestoreFile, cfg.DataDir); err != nil {
+			slog.Error("restore backup", "err", err, "file", cfg.RestoreFile)
+			os.Exit(1)
+		}
+	}
+	if m, err := backup.ApplyPending(cfg.DataDir, cfg.StacksDir); err != nil {
+		slog.Error("restore backup", "err", err)
+		os.Exit(1)
+	} else if m != nil {
+		slog.Warn("backup restored", "created", m.CreatedAt, "version", m.DockgeVersion, "stacks", len(m.Stacks))
+	}
+
 	// Open database
 	database, err := db.Open(cfg.DataDir)
 	if err != nil {
@@ -86,6 +111,7 @@ func main() {
 
 	// WebSocket server
 	wss := ws.NewServer(cfg.Dev)
+	wss.SetOriginPatterns(cfg.WSOrigins)
 
 	// HTTP mux
 	mux := http.NewServeMux()
@@ -170,28 +196,142 @@ func main() {
 	}
 	defer dockerClient.Close()
 
+	// Map stacks whose compose project name differs from their directory
+	// name, so their containers aren't listed as a separate stack
+	projects := stack.NewProjectMap(cfg.StacksDir)
+	dockerClient.SetProjectResolver(projects)
+
 	// Terminal manager
 	terms := terminal.NewManager()
 
 	// Image update cache
 	imageUpdates := models.NewImageUpdateStore(database)
 
+	// Operator changes awaiting approval (two-person rule)
+	pendingChanges := models.NewPendingChangeStore(database)
+
+	// Per-stack resource budgets
+	budgets := models.NewStackBudgetStore(database)
+
+	// History of compose operations run on stacks
+	operations := models.NewOperationStore(database)
+
+	// Append-only log of every state-changing request
+	audit := models.NewAuditStore(database)
+
+	// Images and services whose updates are intentionally held back
+	updateIgnores := models.NewUpdateIgnoreStore(database)
+
+	// Free-form notes per stack
+	stackNotes := models.NewStackNoteStore(database)
+
+	// Hourly restarts and health flaps per stack, shown in stack detail
+	stackEvents := models.NewStackEventStore(database)
+
+	// Archived stacks: compose files kept, hidden and never started
+	stackArchive := models.NewStackArchiveStore(database)
+
+	// Stacks whose exec terminals are limited to admins and chosen users
+	terminalAccess := models.NewStackTerminalAccessStore(database)
+
+	// Per-stack TERM, LANG and shell candidates of exec terminals
+	terminalEnv := models.NewStackTerminalEnvStore(database)
+
+	// Exec user, workdir and env each user last opened a shell with
+	execDefaults := models.NewExecDefaultsStore(database)
+
+	// Stacks whose container logs are written to rotated files in the data dir
+	logCapture := models.NewStackLogCaptureStore(database)
+
+	// Per-stack pull policy of starts and deploys (always, missing, never)
+	pullPolicies := models.NewStackPullPolicyStore(database)
+	serviceSelections := models.NewStackServiceSelectionStore(database)
+	stackWebhooks := models.NewStackWebhookStore(database)
+
+	// Files of each stack's last successful deploy, to diff with the disk
+	deployedCompose := models.NewDeployedComposeStore(database)
+
+	// Folders of stacks in the stack list, shared by all browsers
+	stackGroups := models.NewStackGroupStore(database)
+
+	// Running containers before shutdown, to report what didn't come back
+	hostState := models.NewHostStateStore(database)
+
+	// Tokens of the read-only GraphQL API, for custom dashboards
+	apiTokens := models.NewAPITokenStore(database)
+
+	// Idempotency keys of recent deploy, update and delete requests
+	idempotency := models.NewIdempotencyStore(database)
+
+	// Cron schedules of stack actions (restart nightly, update weekly, ...)
+	schedules := models.NewStackScheduleStore(database)
+
+	// Remote Dockge instances managed from this one
+	agents := models.NewAgentStore(database)
+
+	// Profile watchdog — writes heap/goroutine profiles to the data dir when
+	// memory or goroutine counts cross the configured thresholds, so users can
+	// attach them to leak reports without running pprof interactively.
+	profCtx, profCancel := context.WithCancel(context.Background())
+	defer profCancel()
+	profiles := dbgmem.NewProfileWatchdog(profCtx, dbgmem.WatchdogConfig{
+		Dir:                filepath.Join(cfg.DataDir, "profiles"),
+		Interval:           30 * time.Second,
+		HeapThreshold:      uint64(cfg.ProfileHeapMB) << 20,
+		GoroutineThreshold: cfg.ProfileGoroutines,
+		MaxProfiles:        cfg.ProfileKeep,
+		Cooldown:           10 * time.Minute,
+	})
+
 	// Wire up handlers
 	app := &handlers.App{
-		Users:        users,
-		Settings:     settings,
-		ImageUpdates: imageUpdates,
-		WS:           wss,
-		Docker:       dockerClient,
-		Terms:        terms,
-		StackLocks:   stack.NewNamedMutex(),
-		LoginLimiter: handlers.NewLoginRateLimiter(5, 15*time.Minute),
-		JWTSecret:    jwtSecret,
-		NeedSetup:    userCount == 0,
-		Version:      version,
-		StacksDir:    cfg.StacksDir,
-		NoAuth:       cfg.NoAuth,
-		Dev:          cfg.Dev,
+		Users:          users,
+		Settings:       settings,
+		ImageUpdates:   imageUpdates,
+		PendingChanges: pendingChanges,
+		Budgets:        budgets,
+		Operations:     operations,
+		Audit:          audit,
+		AuditLogFile:   cfg.AuditLogFile,
+		UpdateIgnores:  updateIgnores,
+		StackNotes:     stackNotes,
+		StackEvents:    stackEvents,
+		StackArchive:   stackArchive,
+		TerminalAccess: terminalAccess,
+		TerminalEnv:    terminalEnv,
+		ExecDefaults:   execDefaults,
+		LogCapture:     logCapture,
+		PullPolicies:   pullPolicies,
+		ServiceSelections: serviceSelections,
+		StackWebhooks:  stackWebhooks,
+		DeployedCompose: deployedCompose,
+		StackGroups:    stackGroups,
+		HostState:      hostState,
+		APITokens:      apiTokens,
+		Idempotency:    idempotency,
+		Schedules:      schedules,
+		Agents:         agents,
+		Templates:      templates.NewCatalog(cfg.TemplateDirs, cfg.TemplateCatalogs),
+		Registry:       registry.NewClient(),
+		Secrets:        secrets.NewResolver(&secrets.Vault{Addr: cfg.VaultAddr, Token: cfg.VaultToken}),
+		WS:             wss,
+		Docker:         dockerClient,
+		Terms:          terms,
+		StackLocks:     stack.NewNamedMutex(),
+		Projects:       projects,
+		LoginLimiter:   handlers.NewLoginRateLimiter(5, 15*time.Minute),
+		Profiles:       profiles,
+		JWTSecret:      jwtSecret,
+		NeedSetup:      userCount == 0,
+		Version:        version,
+		StacksDir:      cfg.StacksDir,
+		ExportDir:      filepath.Join(cfg.DataDir, "exports"),
+		DataDir:        cfg.DataDir,
+		DB:             database,
+		NoAuth:         cfg.NoAuth,
+		Dev:            cfg.Dev,
+		HostTerminal:   cfg.HostTerminal,
+		HostTerminalImage: cfg.HostTerminalImage,
 	}
 	handlers.RegisterAuthHandlers(app)
 	handlers.RegisterSettingsHandlers(app)
@@ -199,6 +339,67 @@ func main() {
 	handlers.RegisterDockerHandlers(app)
 	handlers.RegisterServiceHandlers(app)
 	handlers.RegisterTerminalHandlers(app)
+	handlers.RegisterHostTerminalHandlers(app)
+	handlers.RegisterDebugHandlers(app)
+	handlers.RegisterUserHandlers(app)
+	handlers.RegisterApprovalHandlers(app)
+	handlers.RegisterPinningHandlers(app)
+	handlers.RegisterBudgetHandlers(app)
+	handlers.RegisterOperationHandlers(app)
+	handlers.RegisterAuditHandlers(app)
+	handlers.RegisterUpdateAllHandlers(app)
+	handlers.RegisterUpdateIgnoreHandlers(app)
+	handlers.RegisterStackNoteHandlers(app)
+	handlers.RegisterStackArchiveHandlers(app)
+	handlers.RegisterHousekeepingHandlers(app)
+	handlers.RegisterDiscoveryHandlers(app)
+	handlers.RegisterScheduleHandlers(app)
+	handlers.RegisterSnapshotHandlers(app)
+	handlers.RegisterAgentHandlers(app)
+	handlers.RegisterMetricsHandlers(app)
+	handlers.RegisterEnvReplaceHandlers(app)
+	handlers.RegisterTerminalAccessHandlers(app)
+	handlers.RegisterTerminalEnvHandlers(app)
+	handlers.RegisterPullPolicyHandlers(app)
+	handlers.RegisterStartServicesHandlers(app)
+	handlers.RegisterRebootReportHandlers(app)
+	handlers.RegisterStackFileHandlers(app)
+	handlers.RegisterAPITokenHandlers(app)
+	handlers.RegisterDeployedComposeHandlers(app)
+	handlers.RegisterPruneHandlers(app)
+	handlers.RegisterStackWebhookHandlers(app)
+	handlers.RegisterNetworkHandlers(app)
+	handlers.RegisterOrphanHandlers(app)
+	handlers.RegisterStackOrderHandlers(app)
+	handlers.RegisterStackGroupHandlers(app)
+	handlers.RegisterTerminalAdminHandlers(app)
+	handlers.RegisterStackListHandlers(app)
+	handlers.RegisterTemplateHandlers(app)
+	handlers.RegisterStackBackupHandlers(app)
+	handlers.RegisterConfigBackupHandlers(app)
+	handlers.RegisterDotEnvHandlers(app)
+	handlers.RegisterLogCaptureHandlers(app)
+	handlers.RegisterVolumeBrowserHandlers(app)
+	handlers.RegisterStackLogHandlers(app)
+	handlers.RegisterEventsFeedHandlers(app)
+	handlers.RegisterNotifyHandlers(app)
+
+	// Agents connect here with the token issued when they were added
+	mux.HandleFunc("GET /agent", app.ServeAgentLink)
+
+	// Backup downloads and uploads, through links issued over WS
+	mux.HandleFunc("GET /api/backups/{token}", app.ServeBackup)
+	mux.HandleFunc("POST /api/backups/{token}", app.ServeBackup)
+
+	// Captured log downloads, through links issued over WS
+	mux.HandleFunc("GET /api/logs/{token}", app.ServeCapturedLog)
+
+	// Volume file downloads, through links issued over WS
+	mux.HandleFunc("GET /api/volume-files/{token}", app.ServeVolumeFile)
+
+	// Read-only GraphQL API over the cached data, with API tokens
+	mux.HandleFunc("GET /api/graphql", app.ServeGraphQL)
+	mux.HandleFunc("POST /api/graphql", app.ServeGraphQL)
 
 	// Dev mode: broadcast metrics and mock reset proxy endpoints.
 	if cfg.Dev {
@@ -208,6 +409,14 @@ func main() {
 		})
 	}
 
+	// Resource lifecycle metrics (followers, event subscriptions, terminals)
+	if cfg.Dev || cfg.Pprof {
+		mux.HandleFunc("GET /api/debug/lifecycle", func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(app.LifecycleSnapshot())
+		})
+	}
+
 	// Dev mode: mock reset proxy endpoint.
 	// Forwards POST /_mock/reset to the mock daemon over the DOCKER_HOST Unix socket,
 	// then triggers broadcasts so the frontend sees fresh state.
@@ -232,6 +441,37 @@ func main() {
 			w.Write([]byte("ok"))
 		})
 
+		// Dev mode: chaos proxy endpoint, for soak tests.
+		// Forwards to /_mock/chaos on the mock daemon; the events it causes
+		// reach the backend like any others.
+		mux.HandleFunc("/api/mock/chaos", func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodPost {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			client, err := mockDaemonClient()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			req, err := http.NewRequestWithContext(r.Context(), r.Method, "http://docker/_mock/chaos", r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := client.Do(req)
+			if err != nil {
+				slog.Error("mock chaos proxy", "err", err)
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			defer resp.Body.Close()
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(resp.StatusCode)
+			io.Copy(w, resp.Body)
+		})
+
 		// Dev mode: reset DB state (users, rate limiter) to pristine dev defaults.
 		// Separate from mock/reset which only resets Docker state.
 		mux.HandleFunc("POST /api/dev/reset-db", func(w http.ResponseWriter, _ *http.Request) {
@@ -282,10 +522,15 @@ func main() {
 	wss.OnDisconnect(func(c *ws.Conn) {
 		// Drain all terminal sessions and clean up each one
 		for _, s := range c.DrainSessions() {
-			terms.RemoveWriterAndCleanup(s.TermName, s.WriterKey)
+			if s.Forward == nil {
+				terms.RemoveWriterAndCleanup(s.TermName, s.WriterKey)
+			}
 		}
 		app.CancelStatsSub(c.ID())
 		app.CancelTopSub(c.ID())
+		app.CancelEventsFeedSub(c.ID())
+		app.CancelStackListSub(c.ID())
+		app.DropAgentChannels(c)
 	})
 
 	// Start background tasks
@@ -303,8 +548,15 @@ func main() {
 	// Start periodic terminal cleanup (removes completed terminals with no writers)
 	terms.StartCleanupLoop(ctx)
 
+	// Start periodic reaping of log followers whose clients have vanished
+	app.StartFollowerReaper(ctx)
+
+	// Log out sessions idle longer than the idleTimeout setting
+	app.StartIdleSessionReaper(ctx)
+
 	// Start compose file watcher (fsnotify) — triggers broadcast on file changes
 	if err := compose.StartWatcher(ctx, cfg.StacksDir, func(stackName string) {
+		projects.Refresh()
 		app.TriggerStacksBroadcast()
 	}); err != nil {
 		slog.Warn("compose file watcher failed to start", "err", err)
@@ -314,6 +566,26 @@ func main() {
 	// broadcast functions skip Docker API calls when no clients are connected.
 	app.StartBroadcastWatcher(ctx)
 	app.StartImageUpdateChecker(ctx)
+	app.StartBudgetMonitor(ctx)
+	app.StartHousekeeping(ctx)
+	app.StartScheduler(ctx)
+	app.StartMetricsCollector(ctx)
+	app.StartLogCapture(ctx)
+	app.StartNotifier(ctx)
+	app.StartAutoheal(ctx)
+	app.StartRebootReport(ctx)
+
+	// Agent mode: stay connected to the controller
+	if cfg.ControllerURL != "" {
+		app.StartAgent(ctx, agent.Config{
+			ControllerURL: cfg.ControllerURL,
+			Token:         cfg.AgentToken,
+			TokenFile:     filepath.Join(cfg.DataDir, "agent-token.json"),
+			Fingerprint:   cfg.ControllerFingerprint,
+			CertFile:      cfg.AgentCert,
+			KeyFile:       cfg.AgentKey,
+		})
+	}
 
 	// Periodically return unused memory to the OS. Go's runtime retains
 	// freed heap pages as RSS for future allocations; this nudges it to
@@ -347,9 +619,15 @@ func main() {
 
 	// Start HTTP server
 	addr := fmt.Sprintf(":%d", cfg.Port)
+	// CORS + security headers (CSP, X-Frame-Options, Referrer-Policy), and
+	// double-submit CSRF tokens for cookie-authenticated requests
+	handler := middleware.Security(middleware.SecurityConfig{
+		AllowedOrigins: cfg.CORSOrigins,
+		FrameAncestors: cfg.FrameAncestors,
+	}, middleware.CSRF(mux))
 	srv := &http.Server{
 		Addr:         addr,
-		Handler:      mux,
+		Handler:      handler,
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
@@ -374,23 +652,31 @@ func main() {
 	srv.Shutdown(shutdownCtx)
 }
 
-// resetViaDaemon sends POST /_mock/reset to the mock daemon over the DOCKER_HOST
-// Unix socket. Returns an error if DOCKER_HOST is not a Unix socket (i.e.,
-// running against a real Docker daemon).
-func resetViaDaemon() error {
+// mockDaemonClient returns an HTTP client for the mock daemon on the
+// DOCKER_HOST Unix socket. Returns an error if DOCKER_HOST is not a Unix
+// socket (i.e., running against a real Docker daemon).
+func mockDaemonClient() (*http.Client, error) {
 	dh := os.Getenv("DOCKER_HOST")
 	if !strings.HasPrefix(dh, "unix://") {
-		return fmt.Errorf("DOCKER_HOST is not a Unix socket (got %q)", dh)
+		return nil, fmt.Errorf("DOCKER_HOST is not a Unix socket (got %q)", dh)
 	}
 	sockPath := strings.TrimPrefix(dh, "unix://")
 
-	client := &http.Client{
+	return &http.Client{
 		Transport: &http.Transport{
 			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
 				return net.DialTimeout("unix", sockPath, 2*time.Second)
 			},
 		},
 		Timeout: 10 * time.Second,
+	}, nil
+}
+
+// resetViaDaemon sends POST /_mock/reset to the mock daemon.
+func resetViaDaemon() error {
+	client, err := mockDaemonClient()
+	if err != nil {
+		return err
 	}
 
 	resp, err := client.Post("http://docker/_mock/reset", "", nil)
@@ -1,17 +1,19 @@
-import { readFileSync } from "node:fs";
-import { basename, resolve, dirname } from "node:path";
+import { existsSync, readFileSync } from "node:fs";
+import { resolve, dirname } from "node:path";
 import { requestJSON, requestInteractive } from "./socket-client.js";
 import {
     renderProgress,
+    setProgressMode,
     composeUpTasks,
     composeStopTasks,
     composeDownTasks,
     composeRestartTasks,
     composePullTasks,
+    composeBuildTasks,
     composePauseTasks,
     composeUnpauseTasks,
 } from "./tty-output.js";
-import { parseCompose, findComposeFile } from "../src/compose-parser.js";
+import { parseCompose, findComposeFile, resolveProjectName } from "../src/compose-parser.js";
 import type { ParsedCompose, ParsedService, ParsedPort } from "../src/compose-parser.js";
 
 // ---------------------------------------------------------------------------
@@ -22,6 +24,7 @@ interface ComposeFlags {
     projectName: string;
     envFiles: string[];
     composeFile: string;
+    progress: string;
     subcmd: string;
     restArgs: string[];
 }
@@ -30,22 +33,39 @@ function parseComposeFlags(args: string[]): ComposeFlags {
     const envFiles: string[] = [];
     let projectName = "";
     let composeFile = "";
+    let progress = "";
     let idx = 0;
 
     while (idx < args.length) {
+        if (args[idx] === "--progress" && idx + 1 < args.length) {
+            progress = args[idx + 1];
+            idx += 2;
+            continue;
+        }
+        if (args[idx].startsWith("--progress=")) {
+            progress = args[idx].slice("--progress=".length);
+            idx++;
+            continue;
+        }
         if (args[idx] === "--env-file" && idx + 1 < args.length) {
             envFiles.push(args[idx + 1]);
             idx += 2;
             continue;
         }
+        // Profiles only matter to real compose; the mock runs every service
+        if (args[idx] === "--profile" && idx + 1 < args.length) {
+            idx += 2;
+            continue;
+        }
         if ((args[idx] === "-p" || args[idx] === "--project-name") && idx + 1 < args.length) {
             projectName = args[idx + 1];
             idx += 2;
             continue;
         }
-        // -f / --file with space-separated value
+        // -f / --file with space-separated value. The mock doesn't merge
+        // files; the first one (the stack's compose file) defines the services
         if ((args[idx] === "-f" || args[idx] === "--file") && idx + 1 < args.length) {
-            composeFile = resolve(process.cwd(), args[idx + 1]);
+            composeFile = composeFile || resolve(process.cwd(), args[idx + 1]);
             idx += 2;
             continue;
         }
@@ -70,13 +90,14 @@ function parseComposeFlags(args: string[]): ComposeFlags {
     }
 
     if (!projectName) {
-        projectName = basename(process.cwd());
+        projectName = resolveProjectName(composeFile ? dirname(composeFile) : process.cwd());
     }
 
     return {
         projectName,
         envFiles,
         composeFile,
+        progress,
         subcmd: args[idx],
         restArgs: args.slice(idx + 1),
     };
@@ -495,6 +516,26 @@ async function composePull(restArgs: string[], composeFilePath?: string): Promis
     // No actual pull — this is a mock
 }
 
+async function composeBuild(restArgs: string[], composeFilePath?: string): Promise<void> {
+    const { parsed } = loadCompose(composeFilePath);
+    const svcArg = findServiceArg(restArgs);
+    const serviceNames = Object.keys(parsed.services)
+        .filter((svc) => parsed.services[svc].build && (!svcArg || svc === svcArg));
+
+    // A missing build context fails the build, as in real compose
+    for (const svc of serviceNames) {
+        const context = resolve(process.cwd(), parsed.services[svc].build!.context);
+        if (!existsSync(context)) {
+            process.stderr.write(`unable to prepare context: path "${context}" not found\n`);
+            process.exit(1);
+        }
+    }
+
+    const tasks = composeBuildTasks(serviceNames);
+    await renderProgress("Building", tasks);
+    // No actual build — this is a mock
+}
+
 async function composeStart(
     socketPath: string,
     project: string,
@@ -547,7 +588,7 @@ async function composeUnpause(
     }
 }
 
-async function composeConfig(composeFilePath?: string): Promise<void> {
+async function composeConfig(project: string, restArgs: string[], composeFilePath?: string): Promise<void> {
     const composeFile = composeFilePath || findComposeFile(process.cwd());
     if (!composeFile) {
         process.stderr.write("no configuration file provided: not found\n");
@@ -559,8 +600,31 @@ async function composeConfig(composeFilePath?: string): Promise<void> {
         process.stderr.write("services must be a mapping\n");
         process.exit(1);
     }
-    // Config validated — real docker compose config just outputs the resolved YAML
-    // For mock purposes, success is enough
+    // Config validated — real docker compose config outputs the resolved YAML.
+    // `--format json` is parsed by the drift check; volumes are omitted there
+    // since mock bind sources aren't resolved.
+    const fi = restArgs.indexOf("--format");
+    if (fi >= 0 && restArgs[fi + 1] === "json") {
+        const parsed = parseCompose(content);
+        const services: Record<string, unknown> = {};
+        for (const [name, svc] of Object.entries(parsed.services)) {
+            services[name] = {
+                image: svc.image,
+                environment: svc.environment,
+                labels: svc.labels,
+                ports: svc.ports.map((p) => ({
+                    host_ip: p.hostIp,
+                    target: p.target,
+                    published: p.published !== undefined ? String(p.published) : "",
+                    protocol: p.protocol,
+                })),
+            };
+        }
+        process.stdout.write(JSON.stringify({ name: project, services }, null, 2) + "\n");
+    } else if (!hasFlag(restArgs, "--dry-run") && !hasFlag(restArgs, "-q") && !hasFlag(restArgs, "--quiet")) {
+        // The rendered view: the file as is, with the project name compose adds
+        process.stdout.write(`name: ${project}\n` + content.replace(/^name:.*\n/m, ""));
+    }
 }
 
 async function composeExec(
@@ -744,6 +808,27 @@ async function fetchServiceStartupLogs(
     return [`[INFO] ${service} started`];
 }
 
+// Subcommands that change containers, and so take a while on a busy host
+const SLOW_SUBCOMMANDS = new Set(["up", "start", "stop", "down", "restart", "pull", "build", "pause", "unpause"]);
+
+/**
+ * Stall for as long as the daemon's chaos settings ask (see /_mock/chaos).
+ */
+async function chaosDelay(socketPath: string): Promise<void> {
+    let delay = 0;
+    try {
+        const { statusCode, data } = await requestJSON<{ delay: number }>(socketPath, "POST", "/_mock/chaos/delay");
+        if (statusCode === 200 && data && typeof data.delay === "number") {
+            delay = data.delay;
+        }
+    } catch {
+        // No delay
+    }
+    if (delay > 0) {
+        await new Promise((resolve) => setTimeout(resolve, delay));
+    }
+}
+
 // ---------------------------------------------------------------------------
 // Main handler
 // ---------------------------------------------------------------------------
@@ -752,11 +837,20 @@ export async function handleCompose(
     socketPath: string,
     args: string[],
 ): Promise<void> {
-    const { projectName, envFiles, composeFile, subcmd, restArgs } = parseComposeFlags(args);
+    const { projectName, envFiles, composeFile, progress, subcmd, restArgs } = parseComposeFlags(args);
     const envOverrides = loadEnvFiles(envFiles);
     const cf = composeFile || undefined;
+    setProgressMode(progress);
+    if (SLOW_SUBCOMMANDS.has(subcmd)) {
+        await chaosDelay(socketPath);
+    }
 
     switch (subcmd) {
+        case "--help":
+            process.stdout.write("Usage:  docker compose [OPTIONS] COMMAND\n\n");
+            process.stdout.write("Options:\n");
+            process.stdout.write("      --progress string   Set type of progress output (auto, tty, plain, json, quiet)\n");
+            break;
         case "up": {
             const { parsed } = loadCompose(cf);
             await composeUp(socketPath, projectName, parsed, envOverrides, restArgs);
@@ -781,6 +875,9 @@ export async function handleCompose(
         case "pull":
             await composePull(restArgs, cf);
             break;
+        case "build":
+            await composeBuild(restArgs, cf);
+            break;
         case "pause":
             await composePause(socketPath, projectName);
             break;
@@ -788,7 +885,7 @@ export async function handleCompose(
             await composeUnpause(socketPath, projectName);
             break;
         case "config":
-            await composeConfig(cf);
+            await composeConfig(projectName, restArgs, cf);
             break;
         case "exec":
             await composeExec(socketPath, projectName, restArgs, cf);
@@ -42,7 +42,7 @@ async function dockerExec(
     socketPath: string,
     args: string[],
 ): Promise<void> {
-    // docker exec [-it] <container> <command> [args...]
+    // docker exec [-it] [-e KEY=VALUE] <container> <command> [args...]
     let interactive = false;
     let tty = false;
     const rest: string[] = [];
@@ -55,6 +55,10 @@ async function dockerExec(
         }
         if (a === "-i") { interactive = true; continue; }
         if (a === "-t") { tty = true; continue; }
+        if (["-u", "--user", "-e", "--env", "-w", "--workdir"].includes(a)) {
+            i++; // skip the value
+            continue;
+        }
         if (a.startsWith("-") && !a.startsWith("--")) continue;
         rest.push(a);
     }
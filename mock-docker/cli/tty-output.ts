@@ -43,6 +43,25 @@ function moveCursorUp(lines: number): void {
     }
 }
 
+let progressMode = "";
+
+/**
+ * Set the --progress mode ("json" switches to machine-readable events).
+ */
+export function setProgressMode(mode: string): void {
+    progressMode = mode;
+}
+
+/**
+ * Emit compose's `--progress json` event lines on stderr.
+ */
+function renderJSONProgress(tasks: ProgressTask[]): void {
+    for (const task of tasks) {
+        process.stderr.write(JSON.stringify({ id: task.name, status: "Working", text: task.action }) + "\n");
+        process.stderr.write(JSON.stringify({ id: task.name, status: "Done", text: task.done }) + "\n");
+    }
+}
+
 /**
  * Render Docker Compose v2-style animated progress.
  * In TTY mode: animated with spinners. In non-TTY mode: sequential output.
@@ -54,6 +73,11 @@ export async function renderProgress(
     const n = tasks.length;
     if (n === 0) return;
 
+    if (progressMode === "json") {
+        renderJSONProgress(tasks);
+        return;
+    }
+
     if (!process.stdout.isTTY) {
         // Non-TTY: simple sequential output
         for (const task of tasks) {
@@ -215,6 +239,17 @@ export function composePullTasks(services: string[]): ProgressTask[] {
     }));
 }
 
+/**
+ * Build progress tasks for compose build.
+ */
+export function composeBuildTasks(services: string[]): ProgressTask[] {
+    return services.map((svc) => ({
+        name: svc,
+        action: "Building",
+        done: "Built",
+    }));
+}
+
 /**
  * Build progress tasks for compose pause.
  */
@@ -17,7 +17,7 @@ import { parseFilters, applyContainerFilters } from "../filters.js";
 import { projectToContainerListEntry } from "../projections.js";
 import { resolveByIdOrName } from "../name-resolution.js";
 import type { ContainerInspect } from "../types.js";
-import { formatTimestamp } from "../logs.js";
+import { formatTimestamp, logStream } from "../logs.js";
 import type { LogEntry } from "../state.js";
 import { generateStats } from "../stats.js";
 import { generateTop } from "../top.js";
@@ -85,7 +85,8 @@ export const containerRoutes: Route[] = [
         pattern: "/containers/:id",
         handler: async ({ res, params, query, state, emitter, clock }) => {
             const force = query.force === "1" || query.force === "true";
-            const result = containerRemove(state, params.id, emitter, clock, { force });
+            const volumes = query.v === "1" || query.v === "true";
+            const result = containerRemove(state, params.id, emitter, clock, { force, volumes });
             handleMutationResult(res, result, 204);
         },
     },
@@ -181,12 +182,18 @@ export const containerRoutes: Route[] = [
             const until = query.until ? parseFloat(query.until) || undefined : undefined;
             const timestamps = query.timestamps === "1" || query.timestamps === "true";
             const isTty = container.Config.Tty || false;
+            // Both streams unless the client picks (the Docker CLI always does)
+            const picked = query.stdout !== undefined || query.stderr !== undefined;
+            const showStdout = !picked || query.stdout === "1" || query.stdout === "true";
+            const showStderr = !picked || query.stderr === "1" || query.stderr === "true";
+            // A TTY merges both streams into stdout
+            const shows = (line: string) => isTty ? showStdout : (logStream(line) === 1 ? showStdout : showStderr);
 
             // Read from the per-container log buffer
             const buf = state.logBuffers.get(container.Id) || [];
 
-            // Filter by since/until
-            let filtered: LogEntry[] = buf;
+            // Filter by stream and since/until
+            let filtered: LogEntry[] = buf.filter((e) => shows(e.line));
             if (since !== undefined) {
                 const sinceMs = since * 1000;
                 filtered = filtered.filter((e) => e.ts >= sinceMs);
@@ -206,19 +213,19 @@ export const containerRoutes: Route[] = [
             }
 
             // Format lines: optionally prepend timestamps
-            const lines = timestamps
-                ? filtered.map((e) => formatTimestamp(new Date(e.ts)) + " " + e.line)
-                : filtered.map((e) => e.line);
+            const format = (e: LogEntry) => timestamps
+                ? formatTimestamp(new Date(e.ts)) + " " + e.line
+                : e.line;
 
             if (isTty) {
                 res.writeHead(200, { "Content-Type": "application/vnd.docker.raw-stream" });
-                for (const line of lines) {
-                    res.write(line + "\n");
+                for (const e of filtered) {
+                    res.write(format(e) + "\n");
                 }
             } else {
                 res.writeHead(200, { "Content-Type": "application/vnd.docker.multiplexed-stream" });
-                for (const line of lines) {
-                    res.write(frameOutput(line));
+                for (const e of filtered) {
+                    res.write(frameOutput(format(e), logStream(e.line)));
                 }
             }
 
@@ -232,11 +239,14 @@ export const containerRoutes: Route[] = [
             let cursor = (state.logBuffers.get(container.Id) || []).length;
             let stopped = false;
 
-            const write = (line: string) => {
+            const write = (entry: LogEntry) => {
+                if (!shows(entry.line)) {
+                    return;
+                }
                 if (isTty) {
-                    res.write(line + "\n");
+                    res.write(format(entry) + "\n");
                 } else {
-                    res.write(frameOutput(line));
+                    res.write(frameOutput(format(entry), logStream(entry.line)));
                 }
             };
 
@@ -244,11 +254,7 @@ export const containerRoutes: Route[] = [
                 if (stopped || containerId !== container.Id) return;
                 const currentBuf = state.logBuffers.get(container.Id) || [];
                 while (cursor < currentBuf.length) {
-                    const entry = currentBuf[cursor++];
-                    const line = timestamps
-                        ? formatTimestamp(new Date(entry.ts)) + " " + entry.line
-                        : entry.line;
-                    write(line);
+                    write(currentBuf[cursor++]);
                 }
             };
             state.logEmitter.on("log", onLog);
@@ -264,11 +270,7 @@ export const containerRoutes: Route[] = [
                 // Flush any remaining buffered lines
                 const currentBuf = state.logBuffers.get(container.Id) || [];
                 while (cursor < currentBuf.length) {
-                    const entry = currentBuf[cursor++];
-                    const line = timestamps
-                        ? formatTimestamp(new Date(entry.ts)) + " " + entry.line
-                        : entry.line;
-                    write(line);
+                    write(currentBuf[cursor++]);
                 }
                 res.end();
             };
@@ -1,14 +1,18 @@
 import type { Route } from "../server.js";
-import { sendJSON, sendError } from "../server.js";
+import { sendJSON, sendError, sendNoContent } from "../server.js";
 import { execInspect } from "../mutations.js";
 import type { MockState } from "../state.js";
 import type { ExecInspect } from "../types.js";
-import { createShellSession, processCommand, getPrompt } from "../shell.js";
+import { createShellSession, processCommand, getPrompt, commandExitCode } from "../shell.js";
 import { frameOutput } from "../stream.js";
 
-const SHELL_COMMANDS = new Set(["/bin/sh", "/bin/bash", "sh", "bash"]);
+const SHELL_COMMANDS = new Set(["/bin/sh", "/bin/bash", "/bin/ash", "sh", "bash", "ash"]);
 
 function isShellCmd(cmd: string[]): boolean {
+    if (cmd.length === 3 && SHELL_COMMANDS.has(cmd[0]) && cmd[1] === "-c") {
+        // Dockge's shell probe: `sh -c 'for s in bash sh ash; do ... exec "$s"; done'`
+        return cmd[2].startsWith("for s in ") && cmd[2].includes(`exec "$s"`);
+    }
     return cmd.length === 1 && SHELL_COMMANDS.has(cmd[0]);
 }
 
@@ -67,9 +71,9 @@ export const execRoutes: Route[] = [
                 }
             };
 
-            const finishExec = () => {
+            const finishExec = (exitCode = 0) => {
                 exec.Running = false;
-                exec.ExitCode = 0;
+                exec.ExitCode = exitCode;
                 exec.Pid = 0;
                 cleanupExec(state, exec);
             };
@@ -137,12 +141,16 @@ export const execRoutes: Route[] = [
                     finishExec();
                 });
             } else {
-                // One-shot command: consume body first, then execute.
-                const bodyChunks: Buffer[] = [];
-                await new Promise<void>((resolve) => {
-                    req.on("data", (chunk: Buffer) => bodyChunks.push(chunk));
-                    req.on("end", () => resolve());
-                });
+                // One-shot command: consume body first, then execute. A TTY
+                // exec is attached over a hijacked connection whose body only
+                // ends when the client detaches, so it runs right away.
+                if (!tty) {
+                    const bodyChunks: Buffer[] = [];
+                    await new Promise<void>((resolve) => {
+                        req.on("data", (chunk: Buffer) => bodyChunks.push(chunk));
+                        req.on("end", () => resolve());
+                    });
+                }
 
                 const session = createShellSession(container, clock);
                 const fullCmd = cmd.join(" ");
@@ -150,11 +158,23 @@ export const execRoutes: Route[] = [
                 if (output !== null && output !== "") {
                     writeOutput(output);
                 }
-                finishExec();
+                finishExec(commandExitCode(output));
                 res.end();
             }
         },
     },
+    {
+        method: "POST",
+        pattern: "/exec/:id/resize",
+        handler: async ({ res, params, state }) => {
+            if (!state.execSessions.has(params.id)) {
+                sendError(res, 404, `No such exec instance: ${params.id}`);
+                return;
+            }
+            // Shell sessions have no terminal size to apply.
+            sendNoContent(res, 200);
+        },
+    },
     {
         method: "GET",
         pattern: "/exec/:id/json",
@@ -1,16 +1,20 @@
 import { readdirSync, rmSync } from "node:fs";
 import { join } from "node:path";
 import type { Route } from "../server.js";
-import { sendJSON, sendError } from "../server.js";
+import { sendJSON, sendError, readJSON } from "../server.js";
 import { initState } from "../init.js";
 import { FixedClock } from "../clock.js";
+import type { ChaosConfig } from "../chaos.js";
 
 export const mockRoutes: Route[] = [
     {
         method: "POST",
         pattern: "/_mock/reset",
-        handler: async ({ res, state, clock, initOpts }) => {
+        handler: async ({ res, state, clock, initOpts, chaos }) => {
             try {
+                // Step 0: Stop chaos, so nothing is killed while state is rebuilt
+                chaos.reset();
+
                 // Step 1: Clear stacks dir contents
                 const entries = readdirSync(initOpts.stacksDir);
                 for (const entry of entries) {
@@ -47,4 +51,38 @@ export const mockRoutes: Route[] = [
             }
         },
     },
+    {
+        method: "GET",
+        pattern: "/_mock/chaos",
+        handler: async ({ res, chaos }) => {
+            sendJSON(res, 200, chaos.status());
+        },
+    },
+    {
+        method: "POST",
+        pattern: "/_mock/chaos",
+        handler: async ({ req, res, chaos }) => {
+            let body: Partial<ChaosConfig> | null;
+            try {
+                body = await readJSON<Partial<ChaosConfig>>(req);
+            } catch (err) {
+                sendError(res, 400, err instanceof Error ? err.message : "invalid body");
+                return;
+            }
+            const error = chaos.configure(body ?? {});
+            if (error) {
+                sendError(res, 400, error);
+                return;
+            }
+            sendJSON(res, 200, chaos.status());
+        },
+    },
+    {
+        // The mock CLI asks before each compose command how long to stall
+        method: "POST",
+        pattern: "/_mock/chaos/delay",
+        handler: async ({ res, chaos }) => {
+            sendJSON(res, 200, { delay: chaos.composeDelay() });
+        },
+    },
 ];
@@ -1,6 +1,6 @@
 import type { Route } from "../server.js";
 import { sendJSON, sendError, readJSON, handleMutationResult } from "../server.js";
-import { networkCreate, networkRemove, networkConnect, networkDisconnect } from "../mutations.js";
+import { networkCreate, networkRemove, networkConnect, networkDisconnect, networkPrune } from "../mutations.js";
 import type { NetworkCreateConfig } from "../mutations.js";
 import { parseFilters, applyNetworkFilters } from "../filters.js";
 import { resolveByIdOrName } from "../name-resolution.js";
@@ -33,6 +33,14 @@ export const networkRoutes: Route[] = [
             sendJSON(res, 201, { Id: result.ok.Id });
         },
     },
+    {
+        method: "POST",
+        pattern: "/networks/prune",
+        handler: async ({ res, state, emitter, clock }) => {
+            const result = networkPrune(state, emitter, clock);
+            handleMutationResult(res, result, 200);
+        },
+    },
     {
         method: "GET",
         pattern: "/networks/:id",
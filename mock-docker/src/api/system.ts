@@ -2,6 +2,8 @@ import type { Route } from "../server.js";
 import { sendJSON, sendPlain, sendNoContent, sendError } from "../server.js";
 import { parseFilters, applyEventFilters } from "../filters.js";
 import type { DockerEvent } from "../list-types.js";
+import { projectToContainerListEntry, projectToImageListEntry } from "../projections.js";
+import { deterministicInt } from "../deterministic.js";
 
 const PING_HEADERS = {
     "API-Version": "1.47",
@@ -68,6 +70,9 @@ export const systemRoutes: Route[] = [
                 ContainersStopped: stopped,
                 Images: state.images.size,
                 Driver: "overlay2",
+                CgroupDriver: "systemd",
+                CgroupVersion: "2",
+                SecurityOptions: ["name=seccomp,profile=builtin", "name=cgroupns"],
                 DockerRootDir: "/var/lib/docker",
                 Name: "mock-docker",
                 ServerVersion: "27.5.1",
@@ -77,6 +82,56 @@ export const systemRoutes: Route[] = [
             });
         },
     },
+    {
+        method: "GET",
+        pattern: "/system/df",
+        handler: async ({ res, state, clock }) => {
+            // Images share no layers here, so the layers take what the images do
+            const images = [...state.images.values()].map((img) => ({
+                ...projectToImageListEntry(img, state.containers),
+                SharedSize: 0,
+            }));
+            const layersSize = images.reduce((sum, img) => sum + img.Size, 0);
+
+            const containers = [...state.containers.values()].map((c) => ({
+                ...projectToContainerListEntry(c, clock, true),
+                SizeRw: c.SizeRw ?? deterministicInt(c.Id + "size-rw", 0, 50_000_000),
+                SizeRootFs: c.SizeRootFs ?? (state.images.get(c.Image)?.Size ?? 0),
+            }));
+
+            const volumes = [...state.volumes.values()].map((v) => {
+                let refCount = 0;
+                for (const c of state.containers.values()) {
+                    if (c.Mounts.some((m) => m.Type === "volume" && m.Name === v.Name)) {
+                        refCount++;
+                    }
+                }
+                return {
+                    ...v,
+                    UsageData: v.UsageData ?? {
+                        Size: deterministicInt(v.Name + "size", 0, 2_000_000_000),
+                        RefCount: refCount,
+                    },
+                };
+            });
+
+            sendJSON(res, 200, {
+                LayersSize: layersSize,
+                Images: images,
+                Containers: containers,
+                Volumes: volumes,
+                BuildCache: [],
+            });
+        },
+    },
+    {
+        method: "POST",
+        pattern: "/build/prune",
+        handler: async ({ res }) => {
+            // The mock has no build cache (see BuildCache in /system/df)
+            sendJSON(res, 200, { CachesDeleted: [], SpaceReclaimed: 0 });
+        },
+    },
     {
         method: "GET",
         pattern: "/events",
@@ -1,6 +1,6 @@
 import type { Route } from "../server.js";
 import { sendJSON, sendError, readJSON, handleMutationResult } from "../server.js";
-import { volumeCreate, volumeRemove } from "../mutations.js";
+import { volumeCreate, volumeRemove, volumePrune } from "../mutations.js";
 import type { VolumeCreateConfig } from "../mutations.js";
 import { parseFilters, applyVolumeFilters } from "../filters.js";
 
@@ -31,6 +31,16 @@ export const volumeRoutes: Route[] = [
             sendJSON(res, 201, result.ok);
         },
     },
+    {
+        method: "POST",
+        pattern: "/volumes/prune",
+        handler: async ({ res, query, state, emitter, clock }) => {
+            const filters = parseFilters(query.filters);
+            const all = filters.get("all")?.some((v) => v === "true" || v === "1") ?? false;
+            const result = volumePrune(state, all, emitter, clock);
+            handleMutationResult(res, result, 200);
+        },
+    },
     {
         method: "GET",
         pattern: "/volumes/:name",
@@ -0,0 +1,192 @@
+import type { MockState } from "./state.js";
+import type { EventEmitter } from "./events.js";
+import type { Clock } from "./clock.js";
+import { containerKill, containerStart, containerSetHealth } from "./mutations.js";
+import { deterministicInt, hashToSeed } from "./deterministic.js";
+
+// ---------------------------------------------------------------------------
+// Types
+// ---------------------------------------------------------------------------
+
+export interface ChaosConfig {
+    /** 0 turns chaos off, 1 is the most disruptive. */
+    intensity: number;
+    /** Milliseconds between rounds. */
+    interval: number;
+    /** Seeds every roll, so a soak run can be replayed. */
+    seed: string;
+    /** Longest delay added to a compose command, at intensity 1. */
+    maxDelay: number;
+}
+
+export interface ChaosStats {
+    rounds: number;
+    kills: number;
+    restarts: number;
+    flaps: number;
+    delays: number;
+}
+
+export interface ChaosStatus {
+    running: boolean;
+    config: ChaosConfig;
+    stats: ChaosStats;
+}
+
+// At intensity 1, the chance per round that a running container is killed,
+// and that a container with a healthcheck flips its health status.
+const KILL_RATE = 0.1;
+const FLAP_RATE = 0.25;
+
+export const DEFAULT_CHAOS_CONFIG: ChaosConfig = {
+    intensity: 0,
+    interval: 2000,
+    seed: "chaos",
+    maxDelay: 5000,
+};
+
+function emptyStats(): ChaosStats {
+    return { rounds: 0, kills: 0, restarts: 0, flaps: 0, delays: 0 };
+}
+
+// ---------------------------------------------------------------------------
+// Chaos
+// ---------------------------------------------------------------------------
+
+/**
+ * Disturbs the mock the way a flaky host would: containers get killed and
+ * come back a few rounds later, health checks flap, and compose commands
+ * are slow. All of it goes through the mutation functions, so clients see
+ * the same events a real daemon would send.
+ *
+ * Rolls are derived from the seed, the round and the container, never from
+ * Math.random(): the same config against the same state disturbs the same
+ * containers in the same order.
+ */
+export class Chaos {
+    private config: ChaosConfig = { ...DEFAULT_CHAOS_CONFIG };
+    private stats: ChaosStats = emptyStats();
+    private timer: ReturnType<typeof setInterval> | null = null;
+    /** Restarts of killed containers, keyed by container ID. */
+    private restarts = new Map<string, ReturnType<typeof setTimeout>>();
+
+    constructor(
+        private state: MockState,
+        private emitter: EventEmitter,
+        private clock: Clock,
+        private e2eMode: boolean,
+    ) {}
+
+    /**
+     * Merge a config update and (re)start the rounds. Intensity 0 stops
+     * them and brings killed containers back. Returns an error message for
+     * an invalid update, leaving the config as it was.
+     */
+    configure(update: Partial<ChaosConfig>): string | null {
+        const next = { ...this.config, ...update };
+        if (typeof next.intensity !== "number" || !(next.intensity >= 0 && next.intensity <= 1)) {
+            return "intensity must be between 0 and 1";
+        }
+        if (typeof next.interval !== "number" || !(next.interval >= 100)) {
+            return "interval must be at least 100ms";
+        }
+        if (typeof next.maxDelay !== "number" || !(next.maxDelay >= 0)) {
+            return "maxDelay must not be negative";
+        }
+        if (typeof next.seed !== "string") {
+            return "seed must be a string";
+        }
+
+        this.config = next;
+        if (this.timer) {
+            clearInterval(this.timer);
+            this.timer = null;
+        }
+        if (next.intensity === 0) {
+            this.restoreKilled();
+            return null;
+        }
+        this.timer = setInterval(() => this.round(), next.interval);
+        return null;
+    }
+
+    /**
+     * Stop everything and forget the config. Pending restarts are dropped,
+     * not run: after a reset the IDs belong to freshly generated containers.
+     */
+    reset(): void {
+        if (this.timer) {
+            clearInterval(this.timer);
+            this.timer = null;
+        }
+        for (const timeout of this.restarts.values()) {
+            clearTimeout(timeout);
+        }
+        this.restarts.clear();
+        this.config = { ...DEFAULT_CHAOS_CONFIG };
+        this.stats = emptyStats();
+    }
+
+    status(): ChaosStatus {
+        return { running: this.timer !== null, config: { ...this.config }, stats: { ...this.stats } };
+    }
+
+    /** One round: roll for every running container. */
+    round(): void {
+        const round = ++this.stats.rounds;
+        const p = this.config.intensity;
+        for (const c of this.state.containers.values()) {
+            if (!c.State.Running || c.State.Paused) continue;
+            if (this.roll(round, c.Id, "kill") < p * KILL_RATE) {
+                this.kill(c.Id, round);
+                continue;
+            }
+            if (c.State.Health && this.roll(round, c.Id, "health") < p * FLAP_RATE) {
+                const status = c.State.Health.Status === "unhealthy" ? "healthy" : "unhealthy";
+                if ("ok" in containerSetHealth(this.state, c.Id, status, this.emitter, this.clock)) {
+                    this.stats.flaps++;
+                }
+            }
+        }
+    }
+
+    /**
+     * The delay for the next compose command: up to maxDelay scaled by the
+     * intensity, 0 while chaos is off.
+     */
+    composeDelay(): number {
+        if (this.config.intensity === 0) return 0;
+        const n = ++this.stats.delays;
+        return Math.round(this.roll(n, "", "delay") * this.config.intensity * this.config.maxDelay);
+    }
+
+    private kill(id: string, round: number): void {
+        if ("error" in containerKill(this.state, id, "SIGKILL", this.emitter, this.clock)) return;
+        this.stats.kills++;
+
+        // Back after one to three rounds, like a restart policy with backoff
+        const interval = this.config.interval;
+        const after = deterministicInt(hashToSeed([this.config.seed, String(round), id, "restart"]), interval, 3 * interval);
+        this.restarts.set(id, setTimeout(() => this.restart(id), after));
+    }
+
+    private restart(id: string): void {
+        this.restarts.delete(id);
+        // Someone may have started or removed it in the meantime
+        if ("ok" in containerStart(this.state, id, this.emitter, this.clock, { e2eMode: this.e2eMode })) {
+            this.stats.restarts++;
+        }
+    }
+
+    private restoreKilled(): void {
+        for (const [id, timeout] of [...this.restarts]) {
+            clearTimeout(timeout);
+            this.restart(id);
+        }
+    }
+
+    /** A roll in [0, 1). */
+    private roll(n: number, id: string, purpose: string): number {
+        return deterministicInt(hashToSeed([this.config.seed, String(n), id, purpose]), 0, 9999) / 10000;
+    }
+}
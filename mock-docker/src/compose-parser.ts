@@ -113,6 +113,8 @@ export interface ParsedVolume {
 }
 
 export interface ParsedCompose {
+    /** Top-level name: — the compose project name, if set. */
+    name?: string;
     services: Record<string, ParsedService>;
     networks: Record<string, ParsedNetwork>;
     volumes: Record<string, ParsedVolume>;
@@ -737,8 +739,8 @@ const COMPOSE_FILENAMES = [
     "docker-compose.yaml",
 ];
 
-import { existsSync } from "node:fs";
-import { join } from "node:path";
+import { existsSync, readFileSync } from "node:fs";
+import { basename, join } from "node:path";
 
 export function findComposeFile(dir: string): string | null {
     for (const name of COMPOSE_FILENAMES) {
@@ -748,6 +750,42 @@ export function findComposeFile(dir: string): string | null {
     return null;
 }
 
+/** Apply compose's project name rules; "" for interpolated or unusable names. */
+export function normalizeProjectName(name: string): string {
+    if (name.includes("$")) return "";
+    return name.toLowerCase().replace(/[^a-z0-9_-]/g, "").replace(/^[-_]+/, "");
+}
+
+/**
+ * Resolve the project name compose uses in dir without -p:
+ * COMPOSE_PROJECT_NAME from dir/.env, then the top-level name:, then the
+ * directory name.
+ */
+export function resolveProjectName(dir: string, parsed?: ParsedCompose): string {
+    try {
+        for (const line of readFileSync(join(dir, ".env"), "utf-8").split("\n")) {
+            const m = line.trim().match(/^(?:export\s+)?COMPOSE_PROJECT_NAME\s*=\s*(.*)$/);
+            if (!m) continue;
+            const name = normalizeProjectName(m[1].replace(/^(["'])(.*)\1$/, "$2"));
+            if (name) return name;
+        }
+    } catch {
+        // no .env
+    }
+    if (!parsed) {
+        const composeFile = findComposeFile(dir);
+        if (composeFile) {
+            try {
+                parsed = parseCompose(readFileSync(composeFile, "utf-8"));
+            } catch {
+                // unparseable compose file: fall back to the directory name
+            }
+        }
+    }
+    const name = parsed?.name ? normalizeProjectName(parsed.name) : "";
+    return name || normalizeProjectName(basename(dir));
+}
+
 // --- Main entry ---
 
 export function parseCompose(yamlContent: string): ParsedCompose {
@@ -763,6 +801,8 @@ export function parseCompose(yamlContent: string): ParsedCompose {
         volumes: {},
     };
 
+    if (typeof raw.name === "string" && raw.name) result.name = raw.name;
+
     // Parse services
     const rawServices = raw.services as Record<string, Record<string, unknown>> | undefined;
     if (rawServices && typeof rawServices === "object") {
@@ -2,7 +2,7 @@ import { readFileSync, readdirSync, statSync, cpSync, rmSync, existsSync } from
 import { join, resolve, dirname } from "node:path";
 import { fileURLToPath } from "node:url";
 import { MockState, appendLog } from "./state.js";
-import { parseCompose, findComposeFile } from "./compose-parser.js";
+import { parseCompose, findComposeFile, resolveProjectName } from "./compose-parser.js";
 import { parseStackMockConfig, parseGlobalMockConfig } from "./mock-config.js";
 import type { MockGlobalConfig, MockStandaloneContainer } from "./mock-config.js";
 import { generateStack } from "./generator.js";
@@ -154,7 +154,7 @@ export async function initState(opts: InitOptions): Promise<MockState> {
             }
 
             const generated = generateStack({
-                project: entry,
+                project: resolveProjectName(subdir, parsed),
                 stackDir: resolve(subdir),
                 composeFilePath: resolve(composeFilePath),
                 parsed,
@@ -109,6 +109,16 @@ export function generatePeriodicLogLine(
     return generateGenericPeriodicLogLine(container, lineNumber, clock);
 }
 
+// Lines at warning level or worse go to stderr, as most loggers send them,
+// so that clients see both streams.
+const STDERR_LEVEL_WORD = /\b(WARN|WARNING|ERROR|FATAL|PANIC|CRIT|CRITICAL)\b/;
+const STDERR_LEVEL_MARK = /\[(warn|error|crit|alert|emerg)\]|\blevel=(warn|warning|error|fatal)\b/i;
+
+/** The stream a log line is written to: 1 = stdout, 2 = stderr. */
+export function logStream(line: string): 1 | 2 {
+    return STDERR_LEVEL_WORD.test(line) || STDERR_LEVEL_MARK.test(line) ? 2 : 1;
+}
+
 export interface TimestampedLine {
     ts: number;
     line: string;
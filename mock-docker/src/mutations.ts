@@ -300,7 +300,7 @@ export function containerRemove(
     id: string,
     emitter: EventEmitter,
     clock: Clock,
-    opts: { force?: boolean } = {},
+    opts: { force?: boolean; volumes?: boolean } = {},
 ): MutationResult {
     const r = resolveContainer(state, id);
     if ("error" in r) return r;
@@ -322,6 +322,19 @@ export function containerRemove(
 
     emitter.emit(makeEvent(clock, "container", "destroy", c.Id, containerAttrs(c)));
 
+    // `v`: anonymous volumes go with the container, unless another uses them
+    if (opts.volumes) {
+        for (const m of c.Mounts) {
+            if (m.Type !== "volume" || !m.Name) continue;
+            const vol = state.volumes.get(m.Name);
+            if (!vol || vol.Labels?.[ANONYMOUS_VOLUME_LABEL] === undefined) continue;
+            const shared = [...state.containers.values()].some((o) => o.Mounts.some((om) => om.Name === m.Name));
+            if (shared) continue;
+            state.volumes.delete(vol.Name);
+            emitter.emit(makeEvent(clock, "volume", "destroy", vol.Name, { driver: vol.Driver }));
+        }
+    }
+
     return ok();
 }
 
@@ -453,6 +466,11 @@ export function containerRename(
 
     const oldName = c.Name.replace(/^\//, "");
     const newNameWithSlash = newName.startsWith("/") ? newName : `/${newName}`;
+    for (const other of state.containers.values()) {
+        if (other.Id !== c.Id && other.Name === newNameWithSlash) {
+            return fail(409, `Conflict. The container name "${newNameWithSlash}" is already in use by container "${other.Id}". You have to remove (or rename) that container to be able to reuse that name.`);
+        }
+    }
     c.Name = newNameWithSlash;
 
     // Update network container entries
@@ -512,6 +530,38 @@ export function containerKill(
     return ok();
 }
 
+/**
+ * Record a health check result, as the daemon does when a container's
+ * healthcheck changes state. Only running containers with a healthcheck
+ * have a health status to change.
+ */
+export function containerSetHealth(
+    state: MockState,
+    id: string,
+    status: "healthy" | "unhealthy",
+    emitter: EventEmitter,
+    clock: Clock,
+): MutationResult {
+    const r = resolveContainer(state, id);
+    if ("error" in r) return r;
+    const c = r.ok;
+
+    if (!c.State.Running || !c.State.Health) return fail(409, "container has no health status");
+    if (c.State.Health.Status === status) return fail(304, `container already ${status}`);
+
+    const now = clock.now().toISOString();
+    const healthy = status === "healthy";
+    c.State.Health.Status = status;
+    c.State.Health.FailingStreak = healthy ? 0 : (c.Config.Healthcheck?.Retries || 3);
+    c.State.Health.Log.push({ Start: now, End: now, ExitCode: healthy ? 0 : 1, Output: "" });
+    // Docker keeps the last five results
+    if (c.State.Health.Log.length > 5) c.State.Health.Log.splice(0, c.State.Health.Log.length - 5);
+
+    emitter.emit(makeEvent(clock, "container", `health_status: ${status}`, c.Id, containerAttrs(c)));
+
+    return ok();
+}
+
 // ---------------------------------------------------------------------------
 // Network mutations
 // ---------------------------------------------------------------------------
@@ -535,6 +585,10 @@ export function networkCreate(
     emitter: EventEmitter,
     clock: Clock,
 ): MutationResult<{ Id: string }> {
+    for (const net of state.networks.values()) {
+        if (net.Name === config.Name) return fail(409, `network with name ${config.Name} already exists`);
+    }
+
     const seed = networkSeed(config.Name);
     const id = deterministicId(seed, "network-id");
     const driver = config.Driver || "bridge";
@@ -589,6 +643,27 @@ export function networkRemove(
     return ok();
 }
 
+/** Networks the daemon creates itself, which are never pruned. */
+const PREDEFINED_NETWORKS = new Set(["bridge", "host", "none"]);
+
+export function networkPrune(
+    state: MockState,
+    emitter: EventEmitter,
+    clock: Clock,
+): MutationResult<{ NetworksDeleted: string[] }> {
+    const deleted: string[] = [];
+    for (const net of [...state.networks.values()]) {
+        if (PREDEFINED_NETWORKS.has(net.Name) || net.Ingress) continue;
+        // Only running containers have endpoints on a network
+        if (net.Containers && Object.keys(net.Containers).length > 0) continue;
+
+        deleted.push(net.Name);
+        state.networks.delete(net.Id);
+        emitter.emit(makeEvent(clock, "network", "destroy", net.Id, { name: net.Name, type: net.Driver }));
+    }
+    return ok({ NetworksDeleted: deleted });
+}
+
 export function networkConnect(
     state: MockState,
     netId: string,
@@ -604,6 +679,9 @@ export function networkConnect(
     const cr = resolveContainer(state, ctrId);
     if ("error" in cr) return cr;
     const ctr = cr.ok;
+    if (ctr.NetworkSettings.Networks?.[net.Name]) {
+        return fail(403, `endpoint with name ${ctr.Name.replace(/^\//, "")} already exists in network ${net.Name}`);
+    }
 
     // Build endpoint
     const epSeed = ctr.Id + net.Id;
@@ -720,6 +798,12 @@ export function volumeRemove(
     const vol = state.volumes.get(name);
     if (!vol) return fail(404, `get ${name}: no such volume`);
 
+    // Like the daemon, refuse volumes any container mounts, running or not
+    const users = [...state.containers.values()]
+        .filter((c) => c.Mounts.some((m) => m.Type === "volume" && m.Name === name))
+        .map((c) => c.Id);
+    if (users.length > 0) return fail(409, `remove ${name}: volume is in use - [${users.join(", ")}]`);
+
     state.volumes.delete(name);
 
     emitter.emit(makeEvent(clock, "volume", "destroy", name, { driver: vol.Driver }));
@@ -727,6 +811,40 @@ export function volumeRemove(
     return ok();
 }
 
+/** Label the daemon puts on volumes created without a name. */
+const ANONYMOUS_VOLUME_LABEL = "com.docker.volume.anonymous";
+
+export function volumePrune(
+    state: MockState,
+    all: boolean,
+    emitter: EventEmitter,
+    clock: Clock,
+): MutationResult<{ VolumesDeleted: string[]; SpaceReclaimed: number }> {
+    // A volume mounted by any container, running or not, is in use
+    const used = new Set<string>();
+    for (const c of state.containers.values()) {
+        for (const m of c.Mounts) {
+            if (m.Type === "volume" && m.Name) used.add(m.Name);
+        }
+    }
+
+    const deleted: string[] = [];
+    let spaceReclaimed = 0;
+    for (const vol of [...state.volumes.values()]) {
+        if (used.has(vol.Name)) continue;
+        // Like API >= 1.42: without `all`, only anonymous volumes are pruned
+        if (!all && vol.Labels?.[ANONYMOUS_VOLUME_LABEL] === undefined) continue;
+
+        deleted.push(vol.Name);
+        // Same size /system/df reports for the volume
+        spaceReclaimed += vol.UsageData?.Size ?? deterministicInt(vol.Name + "size", 0, 2_000_000_000);
+        state.volumes.delete(vol.Name);
+        emitter.emit(makeEvent(clock, "volume", "destroy", vol.Name, { driver: vol.Driver }));
+    }
+
+    return ok({ VolumesDeleted: deleted, SpaceReclaimed: spaceReclaimed });
+}
+
 // ---------------------------------------------------------------------------
 // Image mutations
 // ---------------------------------------------------------------------------
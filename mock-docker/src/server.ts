@@ -1,9 +1,12 @@
 import { createServer as createHttpServer, type IncomingMessage, type ServerResponse } from "node:http";
+import type { Duplex } from "node:stream";
+import { PassThrough } from "node:stream";
 import type { MockState } from "./state.js";
 import type { EventEmitter } from "./events.js";
 import type { Clock } from "./clock.js";
 import type { InitOptions } from "./init.js";
 import type { MutationResult } from "./mutations.js";
+import { Chaos } from "./chaos.js";
 
 // ---------------------------------------------------------------------------
 // Types
@@ -18,6 +21,7 @@ export interface RequestContext {
     emitter: EventEmitter;
     clock: Clock;
     initOpts: InitOptions;
+    chaos: Chaos;
     e2eMode: boolean;
     logInterval: number;
     statsInterval: number;
@@ -37,6 +41,7 @@ export interface ServerOptions {
     emitter: EventEmitter;
     clock: Clock;
     initOpts: InitOptions;
+    chaos?: Chaos;
     e2eMode?: boolean;
     logInterval?: number;
     statsInterval?: number;
@@ -195,17 +200,68 @@ function parseQueryString(search: string): Record<string, string> {
     return params;
 }
 
+// ---------------------------------------------------------------------------
+// Hijacked connections
+// ---------------------------------------------------------------------------
+
+/**
+ * Writes a route's response straight onto an upgraded socket. Only the
+ * parts of ServerResponse the route handlers use are implemented.
+ */
+function hijackedResponse(socket: Duplex): ServerResponse {
+    let headersSent = false;
+    const res = {
+        get headersSent() {
+            return headersSent;
+        },
+        writeHead(statusCode: number, headers: Record<string, string | number> = {}) {
+            if (headersSent) return res;
+            headersSent = true;
+            const lines =
+                statusCode === 200
+                    ? [
+                          "HTTP/1.1 101 UPGRADED",
+                          `Content-Type: ${headers["Content-Type"] ?? "application/vnd.docker.raw-stream"}`,
+                          "Connection: Upgrade",
+                          "Upgrade: tcp",
+                      ]
+                    : [
+                          `HTTP/1.1 ${statusCode} ${statusCode < 300 ? "OK" : "Error"}`,
+                          ...Object.entries(headers).map(([k, v]) => `${k}: ${v}`),
+                          "Connection: close",
+                      ];
+            socket.write(lines.join("\r\n") + "\r\n\r\n");
+            return res;
+        },
+        setHeader() {
+            return res;
+        },
+        write(chunk: string | Buffer) {
+            if (!headersSent) res.writeHead(200);
+            return socket.writable ? socket.write(chunk) : false;
+        },
+        end(chunk?: string | Buffer) {
+            if (!headersSent) res.writeHead(200);
+            if (chunk !== undefined && socket.writable) socket.write(chunk);
+            socket.end();
+            return res;
+        },
+    };
+    return res as unknown as ServerResponse;
+}
+
 // ---------------------------------------------------------------------------
 // Server factory
 // ---------------------------------------------------------------------------
 
 export function createServer(opts: ServerOptions, routes: Route[]) {
+    const chaos = opts.chaos ?? new Chaos(opts.state, opts.emitter, opts.clock, opts.e2eMode ?? false);
     const parsedRoutes: ParsedRoute[] = routes.map((r) => {
         const { segments, greedy } = parsePattern(r.pattern);
         return { method: r.method, segments, greedy, handler: r.handler };
     });
 
-    const server = createHttpServer(async (req, res) => {
+    const dispatch = async (req: IncomingMessage, res: ServerResponse) => {
         try {
             const url = new URL(req.url || "/", "http://localhost");
             // Strip version prefix
@@ -230,6 +286,7 @@ export function createServer(opts: ServerOptions, routes: Route[]) {
                         emitter: opts.emitter,
                         clock: opts.clock,
                         initOpts: opts.initOpts,
+                        chaos,
                         e2eMode: opts.e2eMode ?? false,
                         logInterval: opts.logInterval ?? 5000,
                         statsInterval: opts.statsInterval ?? 1000,
@@ -246,6 +303,22 @@ export function createServer(opts: ServerOptions, routes: Route[]) {
             console.error("Handler error:", err);
             sendError(res, 500, message);
         }
+    };
+
+    const server = createHttpServer(dispatch);
+
+    // Hijacked requests (exec start, attach) ask for "Upgrade: tcp". The
+    // route handlers still see a request and a response: the request
+    // streams what the client sends after its headers, and a 200 response
+    // is turned into "101 UPGRADED" with the raw stream following.
+    server.on("upgrade", (req: IncomingMessage, socket: Duplex, head: Buffer) => {
+        const body = new PassThrough();
+        Object.assign(body, { url: req.url, method: req.method, headers: req.headers });
+        if (head.length > 0) body.write(head);
+        socket.pipe(body);
+        socket.on("close", () => body.emit("close"));
+        socket.on("error", () => socket.destroy());
+        void dispatch(body as unknown as IncomingMessage, hijackedResponse(socket));
     });
 
     return {
@@ -256,6 +329,7 @@ export function createServer(opts: ServerOptions, routes: Route[]) {
             });
         },
         stop(): Promise<void> {
+            chaos.reset();
             return new Promise((resolve, reject) => {
                 server.close((err) => (err ? reject(err) : resolve()));
             });
@@ -44,6 +44,14 @@ export function getPrompt(session: ShellSession): string {
     return `${user}@${hostname}:${session.cwd}${suffix} `;
 }
 
+/**
+ * Exit code of a one-shot command from its output: 127 when the shell
+ * didn't find it, like bash, and 0 otherwise.
+ */
+export function commandExitCode(output: string | null): number {
+    return output !== null && /^bash: \S+: command not found$/.test(output) ? 127 : 0;
+}
+
 /**
  * Process a shell command. Returns the output string, or null for `exit`.
  */
@@ -0,0 +1,189 @@
+import { describe, it, expect, beforeEach, afterEach, vi } from "vitest";
+import { MockState } from "../src/state.js";
+import { FixedClock } from "../src/clock.js";
+import { EventEmitter } from "../src/events.js";
+import { Chaos } from "../src/chaos.js";
+import type { DockerEvent } from "../src/list-types.js";
+import type { ContainerInspect } from "../src/types.js";
+
+// ---------------------------------------------------------------------------
+// Test fixture
+// ---------------------------------------------------------------------------
+
+interface TestEnv {
+    state: MockState;
+    events: DockerEvent[];
+    chaos: Chaos;
+}
+
+function makeContainer(i: number): ContainerInspect {
+    return {
+        Id: `ctr${String(i).padStart(3, "0")}aabbccddee00112233445566778899aabbccddee00112233445566778`,
+        Created: "2025-01-01T00:00:00Z",
+        Path: "/bin/sh",
+        Args: [],
+        State: {
+            Status: "running",
+            Running: true,
+            Paused: false,
+            Restarting: false,
+            OOMKilled: false,
+            Dead: false,
+            Pid: 1000 + i,
+            ExitCode: 0,
+            Error: "",
+            StartedAt: "2025-01-01T00:00:00Z",
+            FinishedAt: "0001-01-01T00:00:00Z",
+            Health: { Status: "healthy", FailingStreak: 0, Log: [] },
+        },
+        Image: "sha256:aabbcc",
+        Name: `/chaos-svc${i}-1`,
+        HostConfig: { RestartPolicy: { Name: "always", MaximumRetryCount: 0 } },
+        Mounts: [],
+        Config: {
+            Image: "myapp:latest",
+            Healthcheck: { Test: ["CMD", "true"] },
+            Labels: {
+                "com.docker.compose.project": "chaos",
+                "com.docker.compose.service": `svc${i}`,
+            },
+        },
+        NetworkSettings: { Networks: {} },
+    };
+}
+
+function makeTestEnv(count = 20): TestEnv {
+    const state = new MockState();
+    for (let i = 0; i < count; i++) {
+        const c = makeContainer(i);
+        state.containers.set(c.Id, c);
+    }
+    const emitter = new EventEmitter();
+    const events: DockerEvent[] = [];
+    emitter.subscribe((e) => events.push(e));
+    const clock = new FixedClock(new Date("2025-01-01T00:00:00Z"));
+    return { state, events, chaos: new Chaos(state, emitter, clock, true) };
+}
+
+function running(state: MockState): number {
+    return [...state.containers.values()].filter((c) => c.State.Running).length;
+}
+
+beforeEach(() => { vi.useFakeTimers(); });
+afterEach(() => { vi.useRealTimers(); });
+
+// ---------------------------------------------------------------------------
+// Configuration
+// ---------------------------------------------------------------------------
+
+describe("configure", () => {
+    it("is off by default", () => {
+        const { chaos, events } = makeTestEnv();
+        expect(chaos.status().running).toBe(false);
+        vi.advanceTimersByTime(60_000);
+        expect(events).toHaveLength(0);
+    });
+
+    it("rejects invalid settings and keeps the old ones", () => {
+        const { chaos } = makeTestEnv();
+        expect(chaos.configure({ intensity: 2 })).toMatch(/intensity/);
+        expect(chaos.configure({ interval: 10 })).toMatch(/interval/);
+        expect(chaos.configure({ maxDelay: -1 })).toMatch(/maxDelay/);
+        expect(chaos.status().config.intensity).toBe(0);
+        expect(chaos.status().running).toBe(false);
+    });
+});
+
+// ---------------------------------------------------------------------------
+// Rounds
+// ---------------------------------------------------------------------------
+
+describe("rounds", () => {
+    it("kills containers and starts them again", () => {
+        const { state, chaos, events } = makeTestEnv();
+        expect(chaos.configure({ intensity: 1, interval: 1000 })).toBeNull();
+        vi.advanceTimersByTime(10_000);
+
+        const { stats } = chaos.status();
+        expect(stats.rounds).toBe(10);
+        expect(stats.kills).toBeGreaterThan(0);
+        expect(events.filter((e) => e.Action === "die")).toHaveLength(stats.kills);
+        expect(events.filter((e) => e.Action === "start")).toHaveLength(stats.restarts);
+
+        // Every kill is followed by a start within three rounds
+        chaos.configure({ intensity: 0 });
+        vi.advanceTimersByTime(10_000);
+        expect(running(state)).toBe(20);
+    });
+
+    it("flaps health", () => {
+        const { chaos, events } = makeTestEnv();
+        chaos.configure({ intensity: 1, interval: 1000 });
+        vi.advanceTimersByTime(10_000);
+
+        const { stats } = chaos.status();
+        expect(stats.flaps).toBeGreaterThan(0);
+        expect(events.filter((e) => e.Action.startsWith("health_status: "))).toHaveLength(stats.flaps);
+    });
+
+    it("disturbs the same containers for the same seed", () => {
+        const run = (seed: string) => {
+            const { chaos, events } = makeTestEnv();
+            chaos.configure({ intensity: 0.5, interval: 1000, seed });
+            vi.advanceTimersByTime(20_000);
+            chaos.reset();
+            return events.map((e) => `${e.Action} ${e.Actor.ID}`);
+        };
+        const first = run("soak");
+        expect(first.length).toBeGreaterThan(0);
+        expect(run("soak")).toEqual(first);
+        expect(run("other")).not.toEqual(first);
+    });
+
+    it("brings killed containers back when turned off", () => {
+        const { state, chaos } = makeTestEnv();
+        chaos.configure({ intensity: 1, interval: 1000 });
+        while (running(state) === 20) {
+            vi.advanceTimersByTime(1000);
+        }
+        chaos.configure({ intensity: 0 });
+        expect(running(state)).toBe(20);
+        expect(chaos.status().running).toBe(false);
+    });
+
+    it("drops pending restarts on reset", () => {
+        const { state, chaos, events } = makeTestEnv();
+        chaos.configure({ intensity: 1, interval: 1000 });
+        while (running(state) === 20) {
+            vi.advanceTimersByTime(1000);
+        }
+        chaos.reset();
+        const seen = events.length;
+        vi.advanceTimersByTime(60_000);
+        expect(events).toHaveLength(seen);
+        expect(chaos.status().stats.rounds).toBe(0);
+    });
+});
+
+// ---------------------------------------------------------------------------
+// Compose delays
+// ---------------------------------------------------------------------------
+
+describe("composeDelay", () => {
+    it("is 0 while chaos is off", () => {
+        const { chaos } = makeTestEnv();
+        expect(chaos.composeDelay()).toBe(0);
+    });
+
+    it("scales with the intensity", () => {
+        const { chaos } = makeTestEnv();
+        chaos.configure({ intensity: 0.5, maxDelay: 1000 });
+        const delays = Array.from({ length: 50 }, () => chaos.composeDelay());
+        for (const d of delays) {
+            expect(d).toBeGreaterThanOrEqual(0);
+            expect(d).toBeLessThanOrEqual(500);
+        }
+        expect(new Set(delays).size).toBeGreaterThan(1);
+        expect(chaos.status().stats.delays).toBe(50);
+    });
+});
@@ -1,5 +1,5 @@
 import { describe, it, expect } from "vitest";
-import { generateStartupLogs, generateShutdownLogs, generatePeriodicLogLine, getHistoricalLogs } from "../src/logs.js";
+import { generateStartupLogs, generateShutdownLogs, generatePeriodicLogLine, getHistoricalLogs, logStream } from "../src/logs.js";
 import { generateStack } from "../src/generator.js";
 import { parseCompose } from "../src/compose-parser.js";
 import { parseStackMockConfig } from "../src/mock-config.js";
@@ -150,3 +150,16 @@ describe("getHistoricalLogs", () => {
         }
     });
 });
+
+describe("logStream", () => {
+    it("sends warnings and errors to stderr", () => {
+        expect(logStream("2025-01-15T00:00:00.000Z ERROR [db] connection lost")).toBe(2);
+        expect(logStream("2025/01/15 00:00:00 [warn] 1#1: low disk")).toBe(2);
+        expect(logStream("time=2025-01-15 level=error msg=boom")).toBe(2);
+    });
+
+    it("sends everything else to stdout", () => {
+        expect(logStream("2025-01-15T00:00:00.000Z INFO [server] Ready to accept connections")).toBe(1);
+        expect(logStream("GET /error.html 200")).toBe(1);
+    });
+});
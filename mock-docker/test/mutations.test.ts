@@ -14,12 +14,15 @@ import {
     containerCreate,
     containerRename,
     containerKill,
+    containerSetHealth,
     networkCreate,
     networkRemove,
+    networkPrune,
     networkConnect,
     networkDisconnect,
     volumeCreate,
     volumeRemove,
+    volumePrune,
     imageRemove,
     imagePrune,
 } from "../src/mutations.js";
@@ -473,6 +476,23 @@ describe("containerRemove", () => {
         // stop: kill, die, stop; then destroy
         expect(actions).toEqual(["kill", "die", "stop", "destroy"]);
     });
+
+    it("removes its unshared anonymous volumes with volumes", () => {
+        const { state, clock, emitter, stoppedContainer, runningContainer } = env;
+        const anon = { "com.docker.volume.anonymous": "" };
+        volumeCreate(state, { Name: "anon-own", Labels: anon }, emitter, clock);
+        volumeCreate(state, { Name: "anon-shared", Labels: anon }, emitter, clock);
+        volumeCreate(state, { Name: "named-vol" }, emitter, clock);
+        const mount = (name: string) => ({ Type: "volume", Name: name, Source: "", Destination: "/" + name, Mode: "", RW: true, Propagation: "" });
+        stoppedContainer.Mounts.push(mount("anon-own"), mount("anon-shared"), mount("named-vol"));
+        runningContainer.Mounts.push(mount("anon-shared"));
+
+        const result = containerRemove(state, stoppedContainer.Id, emitter, clock, { volumes: true });
+        expect("ok" in result).toBe(true);
+        expect(state.volumes.has("anon-own")).toBe(false);
+        expect(state.volumes.has("anon-shared")).toBe(true);
+        expect(state.volumes.has("named-vol")).toBe(true);
+    });
 });
 
 // ---------------------------------------------------------------------------
@@ -517,6 +537,50 @@ describe("containerKill", () => {
     });
 });
 
+// ---------------------------------------------------------------------------
+// Container: health
+// ---------------------------------------------------------------------------
+
+describe("containerSetHealth", () => {
+    let env: TestEnv;
+    beforeEach(() => {
+        env = makeTestState();
+        env.runningContainer.Config.Healthcheck = { Test: ["CMD", "true"], Retries: 2 };
+        env.runningContainer.State.Health = { Status: "healthy", FailingStreak: 0, Log: [] };
+    });
+
+    it("flips the status and emits health_status", () => {
+        const { state, clock, emitter, events, runningContainer } = env;
+        const result = containerSetHealth(state, runningContainer.Id, "unhealthy", emitter, clock);
+        expect("ok" in result).toBe(true);
+
+        const health = state.containers.get(runningContainer.Id)!.State.Health!;
+        expect(health.Status).toBe("unhealthy");
+        expect(health.FailingStreak).toBe(2);
+        expect(health.Log).toHaveLength(1);
+        expect(health.Log[0].ExitCode).toBe(1);
+
+        expect(events).toHaveLength(1);
+        expect(events[0].Action).toBe("health_status: unhealthy");
+        expect(events[0].Actor.Attributes.name).toBe("myproject-api-1");
+    });
+
+    it("returns 304 if the status is unchanged", () => {
+        const { state, clock, emitter, events, runningContainer } = env;
+        const result = containerSetHealth(state, runningContainer.Id, "healthy", emitter, clock);
+        if ("error" in result) expect(result.statusCode).toBe(304);
+        else expect.unreachable();
+        expect(events).toHaveLength(0);
+    });
+
+    it("returns 409 without a health status", () => {
+        const { state, clock, emitter, stoppedContainer } = env;
+        const result = containerSetHealth(state, stoppedContainer.Id, "unhealthy", emitter, clock);
+        if ("error" in result) expect(result.statusCode).toBe(409);
+        else expect.unreachable();
+    });
+});
+
 // ---------------------------------------------------------------------------
 // Container: rename
 // ---------------------------------------------------------------------------
@@ -544,6 +608,15 @@ describe("containerRename", () => {
 
         expect(network.Containers![runningContainer.Id].Name).toBe("new-name");
     });
+
+    it("returns 409 for a name in use", () => {
+        const { state, clock, emitter, events, runningContainer } = env;
+        const result = containerRename(state, runningContainer.Id, "myproject-web-1", emitter, clock);
+        expect("error" in result).toBe(true);
+        if ("error" in result) expect(result.statusCode).toBe(409);
+        expect(state.containers.get(runningContainer.Id)!.Name).toBe("/myproject-api-1");
+        expect(events).toHaveLength(0);
+    });
 });
 
 // ---------------------------------------------------------------------------
@@ -661,6 +734,33 @@ describe("networkCreate", () => {
         expect(events[0].Type).toBe("network");
         expect(events[0].Action).toBe("create");
     });
+
+    it("keeps the IPAM, internal and attachable settings", () => {
+        const { state, clock, emitter } = env;
+        const result = networkCreate(state, {
+            Name: "backend",
+            Driver: "overlay",
+            Internal: true,
+            Attachable: true,
+            IPAM: { Config: [{ Subnet: "10.5.1.0/24", Gateway: "10.5.1.254" }] },
+        }, emitter, clock);
+        expect("ok" in result).toBe(true);
+        if ("ok" in result) {
+            const net = state.networks.get(result.ok.Id)!;
+            expect(net.Driver).toBe("overlay");
+            expect(net.Internal).toBe(true);
+            expect(net.Attachable).toBe(true);
+            expect(net.IPAM.Config).toEqual([{ Subnet: "10.5.1.0/24", Gateway: "10.5.1.254" }]);
+        }
+    });
+
+    it("returns 409 for a name in use", () => {
+        const { state, clock, emitter, events } = env;
+        const result = networkCreate(state, { Name: "myproject_default" }, emitter, clock);
+        expect("error" in result).toBe(true);
+        if ("error" in result) expect(result.statusCode).toBe(409);
+        expect(events).toHaveLength(0);
+    });
 });
 
 describe("networkRemove", () => {
@@ -686,6 +786,28 @@ describe("networkRemove", () => {
     });
 });
 
+describe("networkPrune", () => {
+    let env: TestEnv;
+    beforeEach(() => { env = makeTestState(); });
+
+    it("removes custom networks without endpoints only", () => {
+        const { state, clock, emitter, events, network } = env;
+        networkCreate(state, { Name: "unused-net" }, emitter, clock);
+        networkCreate(state, { Name: "bridge" }, emitter, clock);
+        events.length = 0;
+
+        const result = networkPrune(state, emitter, clock);
+        expect("ok" in result).toBe(true);
+        if ("ok" in result) expect(result.ok.NetworksDeleted).toEqual(["unused-net"]);
+
+        // The running container's network and the predefined one stay
+        expect(state.networks.has(network.Id)).toBe(true);
+        expect([...state.networks.values()].some((n) => n.Name === "bridge")).toBe(true);
+        expect(events).toHaveLength(1);
+        expect(events[0].Action).toBe("destroy");
+    });
+});
+
 // ---------------------------------------------------------------------------
 // Network: connect / disconnect
 // ---------------------------------------------------------------------------
@@ -722,6 +844,14 @@ describe("networkConnect", () => {
             expect(events[0].Actor.Attributes.container).toBe(stoppedContainer.Id);
         }
     });
+
+    it("returns 403 for a container already connected", () => {
+        const { state, clock, emitter, events, runningContainer, network } = env;
+        const result = networkConnect(state, network.Id, runningContainer.Id, {}, emitter, clock);
+        expect("error" in result).toBe(true);
+        if ("error" in result) expect(result.statusCode).toBe(403);
+        expect(events).toHaveLength(0);
+    });
 });
 
 describe("networkDisconnect", () => {
@@ -788,6 +918,20 @@ describe("volumeRemove", () => {
         expect(events[0].Action).toBe("destroy");
     });
 
+    it("refuses a volume a container mounts", () => {
+        const { state, clock, emitter, stoppedContainer } = env;
+        volumeCreate(state, { Name: "used-vol" }, emitter, clock);
+        stoppedContainer.Mounts.push({ Type: "volume", Name: "used-vol", Source: "", Destination: "/data", Mode: "", RW: true, Propagation: "" });
+
+        const result = volumeRemove(state, "used-vol", emitter, clock);
+        expect("error" in result).toBe(true);
+        if ("error" in result) {
+            expect(result.statusCode).toBe(409);
+            expect(result.error).toContain(stoppedContainer.Id);
+        }
+        expect(state.volumes.has("used-vol")).toBe(true);
+    });
+
     it("returns 404 for unknown volume", () => {
         const { state, clock, emitter } = env;
         const result = volumeRemove(state, "nonexistent", emitter, clock);
@@ -796,6 +940,32 @@ describe("volumeRemove", () => {
     });
 });
 
+describe("volumePrune", () => {
+    let env: TestEnv;
+    beforeEach(() => { env = makeTestState(); });
+
+    it("prunes unused anonymous volumes, or all unused ones with all", () => {
+        const { state, clock, emitter, stoppedContainer } = env;
+        volumeCreate(state, { Name: "named-vol" }, emitter, clock);
+        volumeCreate(state, { Name: "anon-vol", Labels: { "com.docker.volume.anonymous": "" } }, emitter, clock);
+        volumeCreate(state, { Name: "used-vol" }, emitter, clock);
+        stoppedContainer.Mounts.push({ Type: "volume", Name: "used-vol", Source: "", Destination: "/data", Mode: "", RW: true, Propagation: "" });
+        state.volumes.get("anon-vol")!.UsageData = { Size: 1000, RefCount: 0 };
+
+        let result = volumePrune(state, false, emitter, clock);
+        expect("ok" in result).toBe(true);
+        if ("ok" in result) {
+            expect(result.ok.VolumesDeleted).toEqual(["anon-vol"]);
+            expect(result.ok.SpaceReclaimed).toBe(1000);
+        }
+
+        result = volumePrune(state, true, emitter, clock);
+        if ("ok" in result) expect(result.ok.VolumesDeleted).toEqual(["named-vol"]);
+        // A stopped container's volume is still in use
+        expect(state.volumes.has("used-vol")).toBe(true);
+    });
+});
+
 // ---------------------------------------------------------------------------
 // Image: remove
 // ---------------------------------------------------------------------------
@@ -142,7 +142,27 @@ describe("system", () => {
         expect(typeof body.ContainersRunning).toBe("number");
     });
 
-    it("GET /events streams and receives emitted events", async () => {
+    it("GET /system/df returns usage of every object", async () => {
+        const r = await req(socketPath, "GET", "/system/df");
+        expect(r.statusCode).toBe(200);
+        const body = json(r) as {
+            LayersSize: number;
+            Images: Array<{ Size: number; SharedSize: number }>;
+            Containers: Array<{ SizeRw: number }>;
+            Volumes: Array<{ UsageData: { Size: number; RefCount: number } }>;
+            BuildCache: unknown[];
+        };
+        expect(body.LayersSize).toBe(body.Images.reduce((sum, img) => sum + img.Size, 0));
+        expect(body.Images.every((img) => img.SharedSize === 0)).toBe(true);
+        expect(body.Containers.every((c) => typeof c.SizeRw === "number")).toBe(true);
+        expect(body.Volumes.every((v) => typeof v.UsageData.Size === "number" && typeof v.UsageData.RefCount === "number")).toBe(true);
+        expect(body.BuildCache).toEqual([]);
+
+        // Deterministic across requests
+        expect((await req(socketPath, "GET", "/system/df")).body).toBe(r.body);
+    });
+
+        it("GET /events streams and receives emitted events", async () => {
         const received: string[] = [];
         const request = httpRequest(
             { socketPath, path: "/events", method: "GET" },
@@ -407,6 +427,18 @@ describe("containers", () => {
         expect(r.body.length).toBeGreaterThan(0);
     });
 
+    it("GET /containers/:id/logs?stderr=1 returns only stderr frames", async () => {
+        const listR = await req(socketPath, "GET", "/containers/json");
+        const list = json(listR) as Array<{ Id: string }>;
+        if (list.length === 0) return;
+        const id = list[0].Id;
+
+        const r = await req(socketPath, "GET", `/containers/${id}/logs?stderr=1`);
+        expect(r.statusCode).toBe(200);
+        // Frame headers start with the stream: 1 = stdout, 2 = stderr
+        expect(r.body).not.toContain("\u0001\u0000\u0000\u0000");
+    });
+
     it("GET /containers/:id/logs returns 404 for unknown", async () => {
         const r = await req(socketPath, "GET", "/containers/nonexistent/logs");
         expect(r.statusCode).toBe(404);
@@ -665,6 +697,34 @@ describe("mock reset", () => {
     });
 });
 
+describe("mock chaos", () => {
+    it("configures chaos and hands out compose delays", async () => {
+        const offR = await req(socketPath, "GET", "/_mock/chaos");
+        expect(offR.statusCode).toBe(200);
+        expect((json(offR) as { running: boolean }).running).toBe(false);
+
+        const badR = await req(socketPath, "POST", "/_mock/chaos", { intensity: 5 });
+        expect(badR.statusCode).toBe(400);
+
+        // A long interval, so no round runs during the test
+        const onR = await req(socketPath, "POST", "/_mock/chaos", { intensity: 0.5, interval: 600_000, maxDelay: 100 });
+        expect(onR.statusCode).toBe(200);
+        const on = json(onR) as { running: boolean; config: { intensity: number } };
+        expect(on.running).toBe(true);
+        expect(on.config.intensity).toBe(0.5);
+
+        const delayR = await req(socketPath, "POST", "/_mock/chaos/delay");
+        const { delay } = json(delayR) as { delay: number };
+        expect(delay).toBeGreaterThanOrEqual(0);
+        expect(delay).toBeLessThanOrEqual(50);
+
+        // Reset turns it off again
+        await req(socketPath, "POST", "/_mock/reset");
+        const afterR = await req(socketPath, "GET", "/_mock/chaos");
+        expect((json(afterR) as { running: boolean }).running).toBe(false);
+    });
+});
+
 // ---------------------------------------------------------------------------
 // E2E mode
 // ---------------------------------------------------------------------------
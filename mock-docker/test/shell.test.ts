@@ -1,7 +1,7 @@
 import { describe, it, expect, beforeEach } from "vitest";
 import { FixedClock } from "../src/clock.js";
 import type { ContainerInspect } from "../src/types.js";
-import { createShellSession, processCommand, getPrompt } from "../src/shell.js";
+import { createShellSession, processCommand, getPrompt, commandExitCode } from "../src/shell.js";
 import type { ShellSession } from "../src/shell.js";
 
 // ---------------------------------------------------------------------------
@@ -329,4 +329,15 @@ describe("shell", () => {
             expect(getPrompt(session)).toBe("root@testhost:/tmp# ");
         });
     });
+
+    describe("commandExitCode", () => {
+        it("is 127 for an unknown command", () => {
+            expect(commandExitCode(processCommand(session, "nosuchcmd --flag"))).toBe(127);
+        });
+
+        it("is 0 for a known command", () => {
+            expect(commandExitCode(processCommand(session, "echo command not found"))).toBe(0);
+            expect(commandExitCode(processCommand(session, "exit"))).toBe(0);
+        });
+    });
 });
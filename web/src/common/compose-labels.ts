@@ -17,3 +17,10 @@ export const LABEL_IMAGEUPDATES_CHANGELOG = "dockge.imageupdates.changelog";
 // Prefix for URL labels. Format: dockge.urls.<name> = "<url>"
 // Multiple URLs can be added per service with different <name> suffixes.
 export const LABEL_URLS_PREFIX = "dockge.urls.";
+
+// Service extension (not a label) holding Dockge's own service options.
+export const EXTENSION_DOCKGE = "x-dockge";
+
+// Delay before the service starts in a staged startup, e.g. "20s".
+// Inside the x-dockge extension.
+export const EXTENSION_START_DELAY = "start_delay";
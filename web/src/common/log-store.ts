@@ -22,6 +22,8 @@ export interface LogLineEntry {
     type: "log";
     nanos: number;
     html: string;
+    // Written to stderr rather than stdout
+    stderr: boolean;
 }
 
 export interface BannerEntry {
@@ -63,8 +65,8 @@ export interface LogStoreOptions {
 export interface LogStore {
     /** Reactive sorted array of log entries. */
     entries: ShallowRef<LogEntry[]>;
-    /** Add a single log line (already parsed by the server). */
-    addLine(ts: number, line: string): void;
+    /** Add a single log line (already parsed by the server) and the stream it was written to. */
+    addLine(ts: number, line: string, stream?: string): void;
     /** Clean up event store subscription and pending rAF. */
     destroy(): void;
 }
@@ -212,12 +214,12 @@ export function createLogStore(opts: LogStoreOptions): LogStore {
 
     // ── Add line (from server JSON) ─────────────────────────────────────
 
-    function addLine(ts: number, line: string) {
+    function addLine(ts: number, line: string, stream?: string) {
         if (destroyed) {
             return;
         }
         const html = ansi.ansi_to_html(line);
-        pending.push({ type: "log", nanos: ts, html });
+        pending.push({ type: "log", nanos: ts, html, stderr: stream === "stderr" });
         scheduleBatchInsert();
     }
 
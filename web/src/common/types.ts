@@ -115,6 +115,7 @@ export type InfoData = {
     primaryHostname?: string,
     serverTimezone?: string,
     serverTimezoneOffset?: string,
+    hostTerminal?: boolean,
 }
 
 // --- Turnstile global ---
@@ -0,0 +1,46 @@
+import type { EditorView } from "@codemirror/view";
+import { useSocket } from "./useSocket";
+
+/** Matches the Go compose.Diagnostic type; line and column are 1-based. */
+export interface ComposeDiagnostic {
+    line: number;
+    column: number;
+    severity: "error" | "warning";
+    message: string;
+}
+
+/**
+ * Lint sources for the compose editors (vue-codemirror6's linter prop).
+ * The backend runs the same checks as on save, so the editor shows
+ * schema errors inline before the user saves.
+ */
+export function useComposeLint() {
+    const { emit } = useSocket();
+
+    function toEditorDiagnostics(view: EditorView, diags: ComposeDiagnostic[]) {
+        const doc = view.state.doc;
+        return diags.map((d) => {
+            const line = doc.line(Math.min(Math.max(d.line, 1), doc.lines));
+            const from = Math.min(line.from + Math.max(d.column - 1, 0), line.to);
+            return {
+                from,
+                to: line.to,
+                severity: d.severity,
+                message: d.message,
+            };
+        });
+    }
+
+    function linter(override: boolean) {
+        return (view: EditorView) => new Promise((resolve) => {
+            emit("validateCompose", view.state.doc.toString(), override, (res: any) => {
+                resolve(res.ok ? toEditorDiagnostics(view, res.diagnostics) : []);
+            });
+        });
+    }
+
+    return {
+        composeLinter: linter(false),
+        overrideLinter: linter(true),
+    };
+}
@@ -8,8 +8,11 @@ import { useStackStore } from "../stores/stackStore";
 import { useNetworkStore } from "../stores/networkStore";
 import { useImageStore } from "../stores/imageStore";
 import { useVolumeStore } from "../stores/volumeStore";
-import { useUpdateStore } from "../stores/updateStore";
+import { useUpdateStore, type ReleaseUpdate } from "../stores/updateStore";
+import { useStackGroupStore } from "../stores/stackGroupStore";
 import { useEventStore } from "../stores/eventStore";
+import { useAgentStore, type AgentInfo } from "../stores/agentStore";
+import { useAppToast } from "./useAppToast";
 
 // --- Plain WebSocket wrapper (replaces socket.io-client) ---
 
@@ -78,6 +81,18 @@ export class DockgeWebSocket {
     }
 
     emit(event: string, ...args: unknown[]) {
+        this.send(event, args, false);
+    }
+
+    /**
+     * Emit a request the app makes on its own (polling, resyncs). The server
+     * doesn't count it as user activity for the idle session timeout.
+     */
+    emitBackground(event: string, ...args: unknown[]) {
+        this.send(event, args, true);
+    }
+
+    private send(event: string, args: unknown[], background: boolean) {
         // Last arg may be a callback (ack pattern)
         const last = args[args.length - 1];
         const hasCallback = typeof last === "function";
@@ -87,6 +102,9 @@ export class DockgeWebSocket {
             event,
             args,
         };
+        if (background) {
+            msg.background = true;
+        }
 
         if (callback) {
             const id = this.nextId++;
@@ -189,8 +207,8 @@ export class DockgeWebSocket {
 
 let socket: DockgeWebSocket;
 
-function t(key: string): string {
-    return (i18n.global as any).t(key);
+function t(key: string, values?: any): string {
+    return (i18n.global as any).t(key, values);
 }
 
 // Reactive state
@@ -285,6 +303,48 @@ function emit(eventName: string, ...args: unknown[]) {
     getSocket().emit(eventName, ...args);
 }
 
+function emitBackground(eventName: string, ...args: unknown[]) {
+    getSocket().emitBackground(eventName, ...args);
+}
+
+/**
+ * Emit an event to the agent at endpoint, or handle it locally if endpoint
+ * is empty. The agent acks the request like a local handler would.
+ */
+function agentEmit(endpoint: string, eventName: string, ...args: unknown[]) {
+    if (endpoint) {
+        emit("agent", endpoint, eventName, ...args);
+    } else {
+        emit(eventName, ...args);
+    }
+}
+
+/**
+ * Emit an event that may require sudo mode. If the backend answers
+ * "sudoRequired", prompt for the password, elevate, and retry once.
+ */
+function emitWithSudo(eventName: string, ...args: unknown[]) {
+    const callback = args.pop() as (res: any) => void;
+    emit(eventName, ...args, (res: any) => {
+        if (res?.ok || res?.msg !== "sudoRequired") {
+            callback(res);
+            return;
+        }
+        const password = window.prompt(t("sudoPrompt"));
+        if (!password) {
+            callback(res);
+            return;
+        }
+        emit("sudo", password, (sudoRes: any) => {
+            if (!sudoRes.ok) {
+                callback(sudoRes);
+                return;
+            }
+            emit(eventName, ...args, callback);
+        });
+    });
+}
+
 function getJWTPayload() {
     const jwtToken = storage().token;
 
@@ -332,7 +392,7 @@ function login(
 }
 
 function loginByToken(token: string) {
-    socket.emit("loginByToken", token, (res: any) => {
+    socket.emitBackground("loginByToken", token, (res: any) => {
         allowLoginDialog.value = true;
 
         if (!res.ok) {
@@ -361,6 +421,21 @@ function clearData() {
 function afterLogin() {
     // Broadcasts (stacks, containers, networks, images, volumes, updates)
     // are sent automatically by the backend on authenticated connect.
+    // Agents send theirs once this connection opens a channel to them.
+    emit("getAgentList", (res: any) => {
+        if (res?.ok) {
+            setAgentList(res.agents);
+        }
+    });
+}
+
+/** Update the agents and connect to those that came online. */
+function setAgentList(list: AgentInfo[]) {
+    const agentStore = useAgentStore();
+    agentStore.setAgents(list);
+    for (const endpoint of agentStore.takeUnconnected()) {
+        emit("agentConnect", endpoint, () => {});
+    }
 }
 
 // --- Initialization ---
@@ -419,6 +494,7 @@ export function initWebSocket() {
         socketIO.connectionErrorMsg = `${t("Lost connection to the socket server. Reconnecting...")}`;
         socketIO.connected = false;
         resetDataReady();
+        useAgentStore().resetConnections();
     });
 
     socket.on("connect_error", (err: any) => {
@@ -457,12 +533,51 @@ export function initWebSocket() {
         location.reload();
     });
 
+    // Server-enforced idle timeout: drop the stored token so the reconnect
+    // lands on the login dialog instead of silently logging back in.
+    socket.on("sessionExpired", () => {
+        storage().removeItem("token");
+        socketIO.token = null;
+        loggedIn.value = false;
+        username.value = null;
+        allowLoginDialog.value = true;
+    });
+
+    // A stack crossed its resource budget (the stacks broadcast carries the flag)
+    socket.on("stackBudgetExceeded", (data: any) => {
+        useAppToast().toastWarning(t("stackBudgetExceeded", [data?.stackName]));
+    });
+
+    // A container's healthcheck started failing
+    socket.on("stackUnhealthy", (data: any) => {
+        useAppToast().toastWarning(t("stackUnhealthy", [data?.serviceName || data?.container, data?.stackName]));
+    });
+
     // --- Broadcast channel listeners (normalized model) ---
     // Each channel pushes its data directly to the corresponding Pinia store.
 
+    // Stack broadcasts carry only the stacks that changed since revision
+    // `since`; a gap means a missed delta, so refetch the whole list.
     socket.on("stacks", (data: any) => {
+        const store = useStackStore();
         const broadcast = data?.items ?? data;
-        useStackStore().mergeStacks(broadcast as Record<string, any>);
+        if (data?.revision === undefined) {
+            store.mergeStacks(broadcast as Record<string, any>);
+        } else if (data.full) {
+            // Sent on login; a restarted server starts over at revision 1
+            store.replaceStacks(broadcast);
+            store.revision = data.revision;
+        } else if ((data.since ?? 0) === store.revision) {
+            store.mergeStacks(broadcast as Record<string, any>);
+            store.revision = data.revision;
+        } else if (data.revision > store.revision) {
+            socket.emitBackground("getStackList", (res: any) => {
+                if (res?.ok && res.revision >= store.revision) {
+                    store.replaceStacks(res.items ?? {});
+                    store.revision = res.revision;
+                }
+            });
+        }
         markChannel("stacks");
     });
 
@@ -491,12 +606,12 @@ export function initWebSocket() {
     });
 
     socket.on("updates", (data: unknown) => {
-        const payload = data as { keys?: string[]; complete?: boolean } | string[];
+        const payload = data as { keys?: string[]; releases?: Record<string, ReleaseUpdate>; complete?: boolean } | string[];
         if (Array.isArray(payload)) {
             // Legacy format: plain string[]
             useUpdateStore().setUpdates(payload);
         } else {
-            useUpdateStore().setUpdates(payload.keys ?? []);
+            useUpdateStore().setUpdates(payload.keys ?? [], payload.releases);
             if (payload.complete) {
                 markChannel("updatesComplete");
             }
@@ -504,12 +619,33 @@ export function initWebSocket() {
         markChannel("updates");
     });
 
+    socket.on("stackGroups", (data: any) => {
+        if (Array.isArray(data?.groups)) {
+            useStackGroupStore().setGroups(data.groups);
+        }
+    });
+
     socket.on("updateCheckComplete", (...args: unknown[]) => {
         const data = (typeof args[0] === "object" && args[0] !== null) ? args[0] as Record<string, unknown> : {};
         console.debug(`Image update check complete: ${data.servicesWithUpdates ?? 0} services with updates (${data.durationMs ?? 0}ms)`);
         markChannel("updateCheckComplete");
     });
 
+    // --- Agents ---
+    // Pushes from agents are relayed namespaced by endpoint and kept apart
+    // from the local stores.
+    socket.on("agentList", (data: any) => {
+        if (Array.isArray(data)) {
+            setAgentList(data);
+        }
+    });
+
+    socket.on("agent", (data: any) => {
+        if (data?.endpoint && data.event) {
+            useAgentStore().handleEvent(data.endpoint, data.event, data.data);
+        }
+    });
+
     // --- Event history channel (afterLogin) ---
     socket.on("events", (data: any) => {
         const items = data?.items ?? data;
@@ -569,6 +705,9 @@ export function useSocket() {
         storage,
         getSocket,
         emit,
+        emitBackground,
+        agentEmit,
+        emitWithSudo,
         getJWTPayload,
         getTurnstileSiteKey,
         login,
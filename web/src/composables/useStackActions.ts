@@ -10,7 +10,7 @@ export function useStackActions(
     progressTerminalRef: Ref<InstanceType<typeof ProgressTerminal> | undefined>,
 ) {
     const router = useRouter();
-    const { emit } = useSocket();
+    const { emit, emitWithSudo } = useSocket();
     const { toastRes, toastSuccess } = useAppToast();
     const containerStore = useContainerStore();
 
@@ -20,6 +20,10 @@ export function useStackActions(
     const deleteStackFiles = ref(false);
     const showForceDeleteDialog = ref(false);
     const showUpdateDialog = ref(false);
+    const showArchiveDialog = ref(false);
+    // Other stacks using this stack's networks or volumes, reported by the
+    // backend on a delete attempt. Once shown, the next delete goes ahead.
+    const stackDependents = ref<{ stack: string; kind: string; name: string }[]>([]);
 
     // Track which action is in flight so the event watcher knows what toast to show.
     let pendingAction: string | null = null;
@@ -74,10 +78,23 @@ export function useStackActions(
         }
     });
 
-    function startStack() {
+    // pull is the pull policy of this start; "" uses the stack's own.
+    function startStack(pull = "") {
         pendingAction = "start";
         startComposeAction();
-        emit("startStack", stack.name, (res: any) => {
+        emit("startStack", stack.name, { pull }, (res: any) => {
+            if (!res.ok) {
+                stopComposeAction();
+                toastRes(res);
+            }
+        });
+    }
+
+    // Starts only the given services; the server remembers them for next time.
+    function startServices(services: string[]) {
+        pendingAction = "start";
+        startComposeAction();
+        emit("startServices", stack.name, services, {}, (res: any) => {
             if (!res.ok) {
                 stopComposeAction();
                 toastRes(res);
@@ -130,7 +147,15 @@ export function useStackActions(
     }
 
     function deleteDialog() {
-        emit("deleteStack", stack.name, { deleteStackFiles: deleteStackFiles.value }, (res: any) => {
+        emit("deleteStack", stack.name, {
+            deleteStackFiles: deleteStackFiles.value,
+            ignoreDependents: stackDependents.value.length > 0,
+        }, (res: any) => {
+            if (res.dependents) {
+                stackDependents.value = res.dependents;
+                showDeleteDialog.value = true;
+                return;
+            }
             toastRes(res);
             if (res.ok) {
                 router.push("/stacks");
@@ -140,8 +165,27 @@ export function useStackActions(
         });
     }
 
+    // Archiving takes the stack down; the output shows in the progress terminal.
+    function archiveStack() {
+        progressTerminalRef.value?.show();
+        emit("archiveStack", stack.name, (res: any) => {
+            toastRes(res);
+        });
+    }
+
+    function unarchiveStack() {
+        emit("unarchiveStack", stack.name, (res: any) => {
+            toastRes(res);
+        });
+    }
+
     function forceDeleteDialog() {
-        emit("forceDeleteStack", stack.name, (res: any) => {
+        emitWithSudo("forceDeleteStack", stack.name, { ignoreDependents: stackDependents.value.length > 0 }, (res: any) => {
+            if (res.dependents) {
+                stackDependents.value = res.dependents;
+                showForceDeleteDialog.value = true;
+                return;
+            }
             toastRes(res);
             if (res.ok) {
                 router.push("/stacks");
@@ -167,15 +211,20 @@ export function useStackActions(
         deleteStackFiles,
         showForceDeleteDialog,
         showUpdateDialog,
+        showArchiveDialog,
+        stackDependents,
         startComposeAction,
         stopComposeAction,
         startStack,
+        startServices,
         stopStack,
         downStack,
         restartStack,
         doUpdateStack,
         deleteDialog,
         forceDeleteDialog,
+        archiveStack,
+        unarchiveStack,
         checkImageUpdates,
     };
 }
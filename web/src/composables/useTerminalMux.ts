@@ -1,21 +1,48 @@
 import { ref, type Ref } from "vue";
 import { useSocket } from "./useSocket";
 
+// docker exec options of an exec terminal
+export interface ExecOptions {
+    user?: string;
+    workdir?: string;
+    env?: string[];
+    // Command to run instead of a shell (not remembered)
+    cmd?: string[];
+}
+
 export interface TerminalJoinOptions {
     type: string;
     stack?: string;
     service?: string;
     container?: string;
     shell?: string;
+    // Unset uses the user's remembered exec options
+    exec?: ExecOptions;
+    // Agent the terminal runs on ("" or unset = this instance)
+    endpoint?: string;
+    // "stack-logs" only: services to show (unset = all) and history lines per container
+    services?: string[];
+    tail?: number;
+    // Log terminals only: "stdout" or "stderr" to show only that stream (unset = both)
+    stream?: string;
+}
+
+interface TerminalResumeOptions {
+    streamId?: string;
+    offset?: number;
 }
 
 export interface TerminalSession {
     sessionId: Ref<number | null>;
     connected: Ref<boolean>;
     onData: (handler: (data: Uint8Array) => void) => void;
-    onExited: (handler: () => void) => void;
+    // exitCode is null when the server doesn't know it
+    onExited: (handler: (exitCode: number | null) => void) => void;
+    onReset: (handler: () => void) => void;
     sendInput: (data: string) => void;
     sendResize: (rows: number, cols: number) => void;
+    // Reports output as processed (rendered), for the server's flow control
+    ack: (bytes: number) => void;
     leave: () => void;
 }
 
@@ -23,13 +50,57 @@ interface PendingSession {
     sessionId: Ref<number | null>;
     connected: Ref<boolean>;
     dataHandler: ((data: Uint8Array) => void) | null;
-    exitedHandler: (() => void) | null;
+    exitedHandler: ((exitCode: number | null) => void) | null;
+    resetHandler: (() => void) | null;
     opts: TerminalJoinOptions;
+    // Position in the server's stream, so a rejoin only replays missed output
+    streamId: string;
+    offset: number;
+    // Processed output not yet acked
+    unacked: number;
 }
 
+// Binary frame opcodes: [2 bytes sessionId BE] [1 byte opcode] [payload]
+const OP_INPUT = 0x00;
+const OP_RESIZE = 0x01;
+const OP_ACK = 0x02;
+
+// Input frames stay well under the server's 1 MB message limit, so a large
+// paste goes out as several frames instead of closing the connection.
+const MAX_INPUT_FRAME = 32 * 1024;
+
+// Processed output is acked in batches of this many bytes. The server
+// holds output back once 256 KB are unacked, so this must stay well below.
+const ACK_BATCH = 16 * 1024;
+
 const encoder = new TextEncoder();
 let muxInstance: TerminalMux | null = null;
 
+/** Splits UTF-8 bytes into chunks of at most max bytes that don't cut a character in two. */
+function splitUTF8(bytes: Uint8Array, max: number): Uint8Array[] {
+    const chunks: Uint8Array[] = [];
+    let start = 0;
+    while (bytes.length - start > max) {
+        let end = start + max;
+        // Back up to the start of the character at end (continuation bytes are 10xxxxxx)
+        while (end > start && (bytes[end] & 0xc0) === 0x80) {
+            end--;
+        }
+        chunks.push(bytes.subarray(start, end));
+        start = end;
+    }
+    chunks.push(bytes.subarray(start));
+    return chunks;
+}
+
+function sessionFrame(sessionId: number, opcode: number, payloadLength: number): Uint8Array {
+    const msg = new Uint8Array(3 + payloadLength);
+    msg[0] = (sessionId >> 8) & 0xff;
+    msg[1] = sessionId & 0xff;
+    msg[2] = opcode;
+    return msg;
+}
+
 class TerminalMux {
     private sessions = new Map<number, PendingSession>();
     private binaryHandler: ((sessionId: number, data: Uint8Array) => void) | null = null;
@@ -41,8 +112,9 @@ class TerminalMux {
         // Register binary frame handler
         this.binaryHandler = (sessionId: number, data: Uint8Array) => {
             const session = this.sessions.get(sessionId);
-            if (session?.dataHandler) {
-                session.dataHandler(data);
+            if (session) {
+                session.offset += data.length;
+                session.dataHandler?.(data);
             }
         };
         socket.onBinary(this.binaryHandler);
@@ -53,19 +125,21 @@ class TerminalMux {
             if (sessionId != null) {
                 const session = this.sessions.get(sessionId);
                 if (session?.exitedHandler) {
-                    session.exitedHandler();
+                    session.exitedHandler(data.exitCode ?? null);
                 }
             }
         });
 
-        // On reconnect, re-join all active sessions
+        // On reconnect, re-join all active sessions, resuming their streams
         socket.on("connect", () => {
             // Small delay to let auth complete before re-joining
             setTimeout(() => {
-                for (const [, session] of this.sessions) {
+                const sessions = [...this.sessions.values()];
+                this.sessions.clear();
+                for (const session of sessions) {
                     session.sessionId.value = null;
                     session.connected.value = false;
-                    this.doJoin(session);
+                    this.doJoin(session, { streamId: session.streamId, offset: session.offset });
                 }
             }, 500);
         });
@@ -80,7 +154,11 @@ class TerminalMux {
             connected,
             dataHandler: null,
             exitedHandler: null,
+            resetHandler: null,
             opts,
+            streamId: "",
+            offset: 0,
+            unacked: 0,
         };
 
         this.doJoin(pending);
@@ -90,35 +168,40 @@ class TerminalMux {
             connected,
             onData: (handler) => { pending.dataHandler = handler; },
             onExited: (handler) => { pending.exitedHandler = handler; },
+            onReset: (handler) => { pending.resetHandler = handler; },
             sendInput: (data: string) => {
                 if (sessionId.value == null) return;
-                const encoded = encoder.encode(data);
-                const msg = new Uint8Array(3 + encoded.length);
-                msg[0] = (sessionId.value >> 8) & 0xff;
-                msg[1] = sessionId.value & 0xff;
-                msg[2] = 0x00; // input opcode
-                msg.set(encoded, 3);
                 const { getSocket } = useSocket();
-                getSocket().sendBinary(msg.buffer);
+                for (const chunk of splitUTF8(encoder.encode(data), MAX_INPUT_FRAME)) {
+                    const msg = sessionFrame(sessionId.value, OP_INPUT, chunk.length);
+                    msg.set(chunk, 3);
+                    getSocket().sendBinary(msg.buffer);
+                }
             },
             sendResize: (rows: number, cols: number) => {
                 if (sessionId.value == null) return;
-                const msg = new Uint8Array(7);
-                msg[0] = (sessionId.value >> 8) & 0xff;
-                msg[1] = sessionId.value & 0xff;
-                msg[2] = 0x01; // resize opcode
+                const msg = sessionFrame(sessionId.value, OP_RESIZE, 4);
                 const view = new DataView(msg.buffer);
                 view.setUint16(3, rows, false);
                 view.setUint16(5, cols, false);
                 const { getSocket } = useSocket();
                 getSocket().sendBinary(msg.buffer);
             },
+            ack: (bytes: number) => {
+                pending.unacked += bytes;
+                if (sessionId.value == null || pending.unacked < ACK_BATCH) return;
+                const msg = sessionFrame(sessionId.value, OP_ACK, 4);
+                new DataView(msg.buffer).setUint32(3, pending.unacked, false);
+                pending.unacked = 0;
+                const { getSocket } = useSocket();
+                getSocket().sendBinary(msg.buffer);
+            },
             leave: () => {
                 if (sessionId.value != null) {
                     const sid = sessionId.value;
                     this.sessions.delete(sid);
-                    const { emit } = useSocket();
-                    emit("terminalLeave", { sessionId: sid });
+                    const { agentEmit } = useSocket();
+                    agentEmit(opts.endpoint ?? "", "terminalLeave", { sessionId: sid });
                     sessionId.value = null;
                     connected.value = false;
                 }
@@ -126,15 +209,44 @@ class TerminalMux {
         };
     }
 
-    private doJoin(session: PendingSession) {
-        const { emit } = useSocket();
-        emit("terminalJoin", session.opts, (res: any) => {
+    private doJoin(session: PendingSession, resume?: TerminalResumeOptions) {
+        const { agentEmit, emitWithSudo } = useSocket();
+        const { endpoint, ...opts } = session.opts;
+        // A new session starts with nothing unacked on the server
+        session.unacked = 0;
+        const onJoined = (res: any) => {
             if (res?.ok && res.sessionId != null) {
+                // Without a resume the server replays its whole buffer, so
+                // whatever was rendered before the reconnect must go.
+                if (resume?.streamId && !res.resumed) {
+                    session.resetHandler?.();
+                }
+                session.streamId = res.streamId || "";
+                session.offset = res.offset || 0;
                 session.sessionId.value = res.sessionId;
                 session.connected.value = true;
                 this.sessions.set(res.sessionId, session);
             }
-        });
+        };
+        // A stack log stream is the client's own and can't be resumed; a
+        // rejoin starts a new one, which resets the view.
+        if (opts.type === "stack-logs") {
+            const { services, tail, stream } = opts;
+            agentEmit(endpoint ?? "", "followStackLogs", opts.stack, { services, tail, stream, flowControl: true }, onJoined);
+            return;
+        }
+        // The host shell has its own admin-only event, but rejoins and
+        // resumes like any other interactive terminal. It and the console
+        // need sudo to open a shell.
+        if (opts.type === "host") {
+            emitWithSudo("hostTerminal", { ...opts, ...resume, flowControl: true }, onJoined);
+            return;
+        }
+        if (opts.type === "console") {
+            emitWithSudo("terminalJoin", { ...opts, ...resume, flowControl: true }, onJoined);
+            return;
+        }
+        agentEmit(endpoint ?? "", "terminalJoin", { ...opts, ...resume, flowControl: true }, onJoined);
     }
 }
 
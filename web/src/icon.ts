@@ -24,6 +24,9 @@ import {
     faTimes,
     faTimesCircle,
     faTrash,
+    faKey,
+    faLock,
+    faTriangleExclamation,
     faCheckCircle,
     faStream,
     faSave,
@@ -34,6 +37,7 @@ import {
     faQuestionCircle,
     faImages,
     faUpload,
+    faDownload,
     faCopy,
     faCheck,
     faFile,
@@ -44,6 +48,8 @@ import {
     faChevronUp,
     faSignOutAlt,
     faPen,
+    faPaperPlane,
+    faCodeCompare,
     faExternalLinkSquareAlt,
     faSpinner,
     faUndo,
@@ -54,7 +60,10 @@ import {
     faFilter,
     faInfoCircle,
     faClone,
+    faCamera,
     faCertificate,
+    faUser,
+    faTag,
     faTerminal, faWarehouse, faHome, faRocket, faFileLines,
     faRotate,
     faCloudArrowDown, faArrowsRotate,
@@ -72,6 +81,12 @@ import {
     faCrosshairs,
     faArrowTurnDown,
     faArrowRight,
+    faArrowLeft,
+    faServer,
+    faFolder,
+    faArrowDown,
+    faFolderOpen,
+    faEllipsisVertical,
 } from "@fortawesome/free-solid-svg-icons";
 
 library.add(
@@ -92,6 +107,9 @@ library.add(
     faTimes,
     faTimesCircle,
     faTrash,
+    faKey,
+    faLock,
+    faTriangleExclamation,
     faCheckCircle,
     faStream,
     faSave,
@@ -102,6 +120,7 @@ library.add(
     faQuestionCircle,
     faImages,
     faUpload,
+    faDownload,
     faCopy,
     faCheck,
     faFile,
@@ -112,6 +131,8 @@ library.add(
     faChevronUp,
     faSignOutAlt,
     faPen,
+    faPaperPlane,
+    faCodeCompare,
     faExternalLinkSquareAlt,
     faSpinner,
     faUndo,
@@ -123,7 +144,10 @@ library.add(
     faFilter,
     faInfoCircle,
     faClone,
+    faCamera,
     faCertificate,
+    faUser,
+    faTag,
     faTerminal,
     faWarehouse,
     faHome,
@@ -145,6 +169,12 @@ library.add(
     faCrosshairs,
     faArrowTurnDown,
     faArrowRight,
+    faArrowLeft,
+    faServer,
+    faFolder,
+    faArrowDown,
+    faFolderOpen,
+    faEllipsisVertical,
 );
 
 export { FontAwesomeIcon };
@@ -16,6 +16,18 @@ import Appearance from "./components/settings/Appearance.vue";
 import General from "./components/settings/General.vue";
 const Security = () => import("./components/settings/Security.vue");
 const GlobalEnv = () => import("./components/settings/GlobalEnv.vue");
+const ImagePinning = () => import("./components/settings/ImagePinning.vue");
+const ResourceBudgets = () => import("./components/settings/ResourceBudgets.vue");
+const IgnoredUpdates = () => import("./components/settings/IgnoredUpdates.vue");
+const Housekeeping = () => import("./components/settings/Housekeeping.vue");
+const DiskUsage = () => import("./components/settings/DiskUsage.vue");
+const Discovery = () => import("./components/settings/Discovery.vue");
+const Notifications = () => import("./components/settings/Notifications.vue");
+const Autoheal = () => import("./components/settings/Autoheal.vue");
+const Agents = () => import("./components/settings/Agents.vue");
+const EnvReplace = () => import("./components/settings/EnvReplace.vue");
+const Backup = () => import("./components/settings/Backup.vue");
+const GraphQLAPI = () => import("./components/settings/GraphQLAPI.vue");
 import About from "./components/settings/About.vue";
 
 const routes = [
@@ -40,6 +52,16 @@ const routes = [
                                 path: "/stacks/:stackName",
                                 component: Compose,
                             },
+                            {
+                                path: "/templates",
+                                component: () => import("./pages/Templates.vue"),
+                                name: "templates",
+                            },
+                            {
+                                path: "/agents/:endpoint/stacks/:stackName",
+                                component: () => import("./pages/AgentStack.vue"),
+                                name: "agentStack",
+                            },
                             {
                                 path: "/terminal/:stackName/:serviceName/:type",
                                 component: ContainerTerminal,
@@ -156,6 +178,54 @@ const routes = [
                                 path: "globalEnv",
                                 component: GlobalEnv,
                             },
+                            {
+                                path: "imagePinning",
+                                component: ImagePinning,
+                            },
+                            {
+                                path: "resourceBudgets",
+                                component: ResourceBudgets,
+                            },
+                            {
+                                path: "ignoredUpdates",
+                                component: IgnoredUpdates,
+                            },
+                            {
+                                path: "housekeeping",
+                                component: Housekeeping,
+                            },
+                            {
+                                path: "diskUsage",
+                                component: DiskUsage,
+                            },
+                            {
+                                path: "discovery",
+                                component: Discovery,
+                            },
+                            {
+                                path: "notifications",
+                                component: Notifications,
+                            },
+                            {
+                                path: "autoheal",
+                                component: Autoheal,
+                            },
+                            {
+                                path: "agents",
+                                component: Agents,
+                            },
+                            {
+                                path: "envReplace",
+                                component: EnvReplace,
+                            },
+                            {
+                                path: "backup",
+                                component: Backup,
+                            },
+                            {
+                                path: "graphql",
+                                component: GraphQLAPI,
+                            },
                             {
                                 path: "about",
                                 component: About,
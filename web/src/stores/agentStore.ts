@@ -0,0 +1,159 @@
+import { defineStore } from "pinia";
+import { computed, reactive, ref } from "vue";
+import { deriveStatus, hasUnhealthy, type StackBroadcastEntry, type EnrichedStack } from "./stackStore";
+import type { ContainerBroadcast } from "./containerStore";
+import { RUNNING, RUNNING_AND_EXITED, UNHEALTHY } from "../common/util-common";
+
+/** Matches the Go agentInfo type. */
+export interface AgentInfo {
+    endpoint: string;
+    name: string;
+    createdBy?: string;
+    createdAt: number;
+    lastSeen: number;
+    online: boolean;
+    rotatedAt?: number;
+    rotationPending?: boolean;
+}
+
+/** Stacks and containers pushed by one agent. */
+interface AgentState {
+    stacks: Map<string, StackBroadcastEntry>;
+    containers: Map<string, ContainerBroadcast>;
+}
+
+/** Merge a map update. Null values delete the key; partial objects merge into existing. */
+function mergeMap<T>(target: Map<string, T>, data: Record<string, Partial<T> | null>) {
+    for (const [key, value] of Object.entries(data)) {
+        if (value === null) {
+            target.delete(key);
+        } else {
+            target.set(key, { ...target.get(key), ...value } as T);
+        }
+    }
+}
+
+/**
+ * Remote agents and their data. Each agent's broadcasts arrive as "agent"
+ * events and are kept per endpoint, apart from the local stores.
+ */
+export const useAgentStore = defineStore("agents", () => {
+    const agents = ref<AgentInfo[]>([]);
+    const state = reactive(new Map<string, AgentState>());
+
+    // Agents whose channel this connection has opened
+    const connected = new Set<string>();
+
+    function stateOf(endpoint: string): AgentState {
+        let s = state.get(endpoint);
+        if (!s) {
+            s = { stacks: new Map(), containers: new Map() };
+            state.set(endpoint, s);
+        }
+        return s;
+    }
+
+    function setAgents(list: AgentInfo[]) {
+        agents.value = list;
+        const known = new Set(list.map((a) => a.endpoint));
+        for (const a of list) {
+            if (!a.online) {
+                connected.delete(a.endpoint);
+            }
+        }
+        for (const endpoint of [...state.keys()]) {
+            if (!known.has(endpoint)) {
+                state.delete(endpoint);
+                connected.delete(endpoint);
+            }
+        }
+    }
+
+    /** Apply a push relayed from an agent. */
+    function handleEvent(endpoint: string, event: string, data: any) {
+        const items = data?.items ?? data;
+        switch (event) {
+            case "stacks":
+                mergeMap(stateOf(endpoint).stacks, items);
+                break;
+            case "containers":
+                mergeMap(stateOf(endpoint).containers, items);
+                break;
+        }
+    }
+
+    /** Agents that are online but not connected on this connection yet. */
+    function takeUnconnected(): string[] {
+        const result: string[] = [];
+        for (const a of agents.value) {
+            if (a.online && !connected.has(a.endpoint)) {
+                connected.add(a.endpoint);
+                result.push(a.endpoint);
+            }
+        }
+        return result;
+    }
+
+    /** Forget channels after the connection to the controller dropped. */
+    function resetConnections() {
+        connected.clear();
+    }
+
+    function agentName(endpoint: string): string {
+        return agents.value.find((a) => a.endpoint === endpoint)?.name || endpoint;
+    }
+
+    /** An agent's stacks, with status derived from its containers. */
+    function stacksOf(endpoint: string): EnrichedStack[] {
+        const s = state.get(endpoint);
+        if (!s) {
+            return [];
+        }
+        return [...s.stacks.values()]
+            .sort((a, b) => a.name.localeCompare(b.name))
+            .map((stack): EnrichedStack => {
+                const containers = containersOf(endpoint, stack.name);
+                const status = deriveStatus(containers, stack.ignoreStatus, stack.jobs);
+                return {
+                    name: stack.name,
+                    composeFileName: stack.composeFileName,
+                    images: stack.images,
+                    ignoreStatus: stack.ignoreStatus,
+                    jobs: stack.jobs,
+                    isManagedByDockge: stack.isManagedByDockge,
+                    status,
+                    started: status === RUNNING || status === RUNNING_AND_EXITED || status === UNHEALTHY,
+                    recreateNecessary: false,
+                    imageUpdatesAvailable: false,
+                    overBudget: !!stack.overBudget,
+                    archived: !!stack.archived,
+                    projectConflict: stack.projectConflict ?? [],
+                    hasUnhealthy: hasUnhealthy(containers),
+                    tags: [],
+                };
+            });
+    }
+
+    function containersOf(endpoint: string, stackName?: string): ContainerBroadcast[] {
+        const s = state.get(endpoint);
+        if (!s) {
+            return [];
+        }
+        const list = [...s.containers.values()];
+        return stackName === undefined ? list : list.filter((c) => c.stackName === stackName);
+    }
+
+    const onlineAgents = computed(() => agents.value.filter((a) => a.online));
+
+    return {
+        agents,
+        onlineAgents,
+        setAgents,
+        handleEvent,
+        takeUnconnected,
+        resetConnections,
+        agentName,
+        stacksOf,
+        containersOf,
+    };
+});
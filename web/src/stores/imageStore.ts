@@ -8,6 +8,7 @@ export interface ImageSummary {
     id: string;
     repoTags: string[];
     size: string;
+    sizeBytes: number;
     created: string;
     dangling: boolean;
 }
@@ -0,0 +1,36 @@
+import { defineStore } from "pinia";
+import { computed, ref } from "vue";
+
+/** Matches the Go models.StackGroup type. */
+export interface StackGroup {
+    id: number;
+    name: string;
+    stacks: string[];
+    order: number;
+}
+
+export const useStackGroupStore = defineStore("stackGroups", () => {
+    /** Groups in display order, as the server keeps them. */
+    const groups = ref<StackGroup[]>([]);
+
+    function setGroups(data: StackGroup[]) {
+        groups.value = data;
+    }
+
+    /** Stack name → the group it's in. */
+    const groupOf = computed(() => {
+        const result: Record<string, StackGroup> = {};
+        for (const group of groups.value) {
+            for (const name of group.stacks) {
+                result[name] = group;
+            }
+        }
+        return result;
+    });
+
+    return {
+        groups,
+        groupOf,
+        setGroups,
+    };
+});
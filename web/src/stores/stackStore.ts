@@ -16,8 +16,14 @@ export interface StackBroadcastEntry {
     name: string;
     composeFileName: string;
     ignoreStatus?: Record<string, boolean>;
+    /** One-shot services; their exited containers are finished runs. */
+    jobs?: Record<string, boolean>;
     images: Record<string, string>;
     isManagedByDockge: boolean;
+    overBudget?: boolean;
+    archived?: boolean;
+    /** Other stacks with the same compose project name. */
+    projectConflict?: string[];
 }
 
 export interface EnrichedStack {
@@ -25,18 +31,30 @@ export interface EnrichedStack {
     composeFileName: string;
     images: Record<string, string>;
     ignoreStatus?: Record<string, boolean>;
+    jobs?: Record<string, boolean>;
     isManagedByDockge: boolean;
     status: number;
     started: boolean;
     recreateNecessary: boolean;
     imageUpdatesAvailable: boolean;
+    overBudget: boolean;
+    archived: boolean;
+    projectConflict: string[];
+    /** Any service is unhealthy, including status-ignored ones. */
+    hasUnhealthy: boolean;
     tags: string[];
 }
 
-/** Derive stack status from container states. */
-function deriveStatus(
+/** Whether any container is unhealthy, ignored services included. */
+export function hasUnhealthy(containers: ContainerBroadcast[]): boolean {
+    return containers.some((c) => c.health === "unhealthy");
+}
+
+/** Derive stack status from container states. Job containers only count while running. */
+export function deriveStatus(
     containers: ContainerBroadcast[],
-    ignoreStatus?: Record<string, boolean>
+    ignoreStatus?: Record<string, boolean>,
+    jobs?: Record<string, boolean>
 ): number {
     let running = 0;
     let exited = 0;
@@ -49,6 +67,9 @@ function deriveStatus(
         if (ignoreStatus && ignoreStatus[c.serviceName]) {
             continue;
         }
+        if (jobs && jobs[c.serviceName] && c.state !== "running") {
+            continue;
+        }
         if (c.health === "unhealthy") {
             unhealthy++;
         } else {
@@ -82,6 +103,8 @@ function deriveStatus(
 export const useStackStore = defineStore("stacks", () => {
     const stackMap = reactive(new Map<string, StackBroadcastEntry>());
     const loading = ref(true);
+    /** Revision of the stacks channel the map is at; 0 = unknown. */
+    const revision = ref(0);
 
     /** Merge a map update. Null values delete the key; non-null values upsert. */
     function mergeStacks(data: Record<string, StackBroadcastEntry | null>) {
@@ -95,6 +118,12 @@ export const useStackStore = defineStore("stacks", () => {
         loading.value = false;
     }
 
+    /** Replace every stack with a full stack list. */
+    function replaceStacks(data: Record<string, StackBroadcastEntry>) {
+        stackMap.clear();
+        mergeStacks(data);
+    }
+
     /** Sorted raw stacks array (backward-compatible). */
     const rawStacks = computed(() =>
         [...stackMap.values()].sort((a, b) => a.name < b.name ? -1 : a.name > b.name ? 1 : 0)
@@ -107,7 +136,7 @@ export const useStackStore = defineStore("stacks", () => {
 
         return rawStacks.value.map((s): EnrichedStack => {
             const stackContainers = containerStore.byStack(s.name);
-            const status = deriveStatus(stackContainers, s.ignoreStatus);
+            const status = deriveStatus(stackContainers, s.ignoreStatus, s.jobs);
             const started = status === STATUS_RUNNING ||
                 status === STATUS_RUNNING_AND_EXITED ||
                 status === STATUS_UNHEALTHY;
@@ -138,11 +167,16 @@ export const useStackStore = defineStore("stacks", () => {
                 composeFileName: s.composeFileName,
                 images: s.images,
                 ignoreStatus: s.ignoreStatus,
+                jobs: s.jobs,
                 isManagedByDockge: s.isManagedByDockge,
                 status,
                 started,
                 recreateNecessary,
                 imageUpdatesAvailable,
+                overBudget: !!s.overBudget,
+                archived: !!s.archived,
+                projectConflict: s.projectConflict ?? [],
+                hasUnhealthy: hasUnhealthy(stackContainers),
                 tags: [],
             };
         });
@@ -178,6 +212,10 @@ export const useStackStore = defineStore("stacks", () => {
                 started,
                 recreateNecessary: false,
                 imageUpdatesAvailable: false,
+                overBudget: false,
+                archived: false,
+                projectConflict: [],
+                hasUnhealthy: hasUnhealthy(stackContainers),
                 tags: [],
             });
         }
@@ -191,7 +229,9 @@ export const useStackStore = defineStore("stacks", () => {
         rawStacks,
         stackMap,
         loading,
+        revision,
         mergeStacks,
+        replaceStacks,
         stacks,
         unmanagedStacks,
         allStacks,
@@ -1,13 +1,24 @@
 import { defineStore } from "pinia";
 import { ref } from "vue";
 
+/** Newer version tags for a semver-tagged image, by level. */
+export interface ReleaseUpdate {
+    kind: "patch" | "minor" | "major";
+    patch?: string;
+    minor?: string;
+    major?: string;
+}
+
 export const useUpdateStore = defineStore("updates", () => {
     /** Array of "stackName/serviceName" keys that have image updates available. */
     const updatedServices = ref<string[]>([]);
+    /** "stackName/serviceName" → newer releases, for semver-tagged images. */
+    const releases = ref<Record<string, ReleaseUpdate>>({});
     const loading = ref(true);
 
-    function setUpdates(data: string[]) {
+    function setUpdates(data: string[], releaseData?: Record<string, ReleaseUpdate>) {
         updatedServices.value = data;
+        releases.value = releaseData ?? {};
         loading.value = false;
     }
 
@@ -16,10 +27,17 @@ export const useUpdateStore = defineStore("updates", () => {
         return updatedServices.value.includes(key);
     }
 
+    /** Newer releases for a service, if its image has a version tag. */
+    function release(key: string): ReleaseUpdate | undefined {
+        return releases.value[key];
+    }
+
     return {
         updatedServices,
+        releases,
         loading,
         setUpdates,
         hasUpdate,
+        release,
     };
 });
@@ -185,3 +185,13 @@ export function getToastErrorTimeout(): number | false {
     return errorTimeout;
 }
 
+
+/**
+ * Returns the headers that pass the server's double-submit CSRF check,
+ * for fetch() calls that change state.
+ * @returns {Record<string, string>} The X-CSRF-Token header, if the token cookie is set.
+ */
+export function csrfHeaders(): Record<string, string> {
+    const match = document.cookie.match(/(?:^|;\s*)dockge_csrf=([^;]+)/);
+    return match ? { "X-CSRF-Token": match[1] } : {};
+}
@@ -8,15 +8,6 @@ declare const DEVCONTAINER: string | undefined;
 declare const CODESPACE_NAME: string;
 declare const GITHUB_CODESPACES_PORT_FORWARDING_DOMAIN: string;
 
-declare module "composerize" {
-    export default function composerize(
-        command: string,
-        existingCompose?: string,
-        composeVersion?: string,
-        indent?: number,
-    ): string;
-}
-
 declare module "*.vue" {
     import type { DefineComponent } from "vue";
     const component: DefineComponent<{}, {}, any>;
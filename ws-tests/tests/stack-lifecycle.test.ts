@@ -145,6 +145,13 @@ describe("stack-lifecycle", () => {
             const yaml = "services:\n  app:\n    image: alpine\n";
             await cmd.sendAndReceive("saveStack", "force-delete-me", yaml, "", "", false);
 
+            const denied = await cmd.sendAndReceive("forceDeleteStack", "force-delete-me");
+            expect(denied.ok).toBe(false);
+            expect(denied.msg).toBe("sudoRequired");
+
+            const sudo = await cmd.sendAndReceive("sudo", "testpass123");
+            expect(sudo.ok).toBe(true);
+
             const { ack } = await cmd.sendAction("forceDeleteStack", "force-delete-me");
             expect(ack.ok).toBe(true);
         } finally {